@@ -1,5 +1,6 @@
-import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+import { createRuntime, failure, formatTemplatePreview, success, usageError } from '../utils/formatters.js';
 import { parseOptionalJsonInput, asOptionalString, asOptionalRecord, asStringArray } from '../utils/validation.js';
+const AGENT_RUN_USAGE = 'ax agent run <agent-id> --task <text> [--input <json-object>] [--files <path,...>]';
 export async function agentCommand(args, options) {
     const subcommand = args[0] ?? 'list';
     const runtime = createRuntime(options);
@@ -65,16 +66,22 @@ export async function agentCommand(args, options) {
         case 'run': {
             const agentId = args[1] ?? options.agent;
             if (agentId === undefined || agentId.length === 0) {
-                return usageError('ax agent run <agent-id> --task <text> [--input <json-object>]');
+                return usageError(AGENT_RUN_USAGE);
             }
             const parsed = parseOptionalJsonInput(options.input, 'Agent run');
             if (parsed.error !== undefined) {
                 return failure(parsed.error);
             }
+            const filesIndex = args.indexOf('--files');
+            const files = filesIndex === -1 ? undefined : args[filesIndex + 1]?.split(',').map((entry) => entry.trim()).filter((entry) => entry.length > 0);
+            if (filesIndex !== -1 && (files === undefined || files.length === 0)) {
+                return usageError(AGENT_RUN_USAGE);
+            }
             const result = await runtime.runAgent({
                 agentId,
                 task: options.task,
                 input: parsed.value,
+                files,
                 provider: options.provider,
                 traceId: options.traceId,
                 surface: 'cli',
@@ -86,6 +93,9 @@ export async function agentCommand(args, options) {
                 `Mode: ${result.executionMode}`,
                 `Success: ${result.success ? 'yes' : 'no'}`,
                 result.content.length > 0 ? `Output:\n${result.content}` : undefined,
+                result.anchors !== undefined && result.anchors.length > 0
+                    ? `Cites: ${result.anchors.map((anchor) => `${anchor.path}:${anchor.line}${anchor.endLine !== undefined ? `-${anchor.endLine}` : ''}`).join(', ')}`
+                    : undefined,
                 result.error?.message ? `Error: ${result.error.message}` : undefined,
                 ...(result.warnings.map((warning) => `Warning: ${warning}`)),
             ].filter((value) => value !== undefined);
@@ -93,6 +103,23 @@ export async function agentCommand(args, options) {
                 ? success(lines.join('\n'), result)
                 : failure(lines.join('\n'), result);
         }
+        case 'render': {
+            const agentId = args[1] ?? options.agent;
+            if (agentId === undefined || agentId.length === 0) {
+                return usageError('ax agent render <agent-id> [--task <text>] [--input <json-object>]');
+            }
+            const parsed = parseOptionalJsonInput(options.input, 'Agent render');
+            if (parsed.error !== undefined) {
+                return failure(parsed.error);
+            }
+            try {
+                const preview = await runtime.previewTemplates({ agentId, task: options.task, input: parsed.value });
+                return success(formatTemplatePreview(preview), preview);
+            }
+            catch (error) {
+                return failure(error instanceof Error ? error.message : String(error));
+            }
+        }
         case 'recommend': {
             const task = options.task ?? args.slice(1).join(' ').trim();
             if (task.length === 0) {
@@ -112,7 +139,7 @@ export async function agentCommand(args, options) {
             return success(lines.join('\n'), recommendations);
         }
         default:
-            return usageError('ax agent [list|get|register|remove|capabilities|run|recommend]');
+            return usageError('ax agent [list|get|register|remove|capabilities|run|render|recommend]');
     }
 }
 function parseRegistrationInput(input) {
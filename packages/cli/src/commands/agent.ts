@@ -1,7 +1,9 @@
 import type { CLIOptions, CommandResult } from '../types.js';
-import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+import { createRuntime, failure, formatTemplatePreview, success, usageError } from '../utils/formatters.js';
 import { parseOptionalJsonInput, asOptionalString, asOptionalRecord, asStringArray } from '../utils/validation.js';
 
+const AGENT_RUN_USAGE = 'ax agent run <agent-id> --task <text> [--input <json-object>] [--files <path,...>]';
+
 interface AgentRegistrationInput {
   agentId: string;
   name: string;
@@ -84,7 +86,7 @@ export async function agentCommand(args: string[], options: CLIOptions): Promise
     case 'run': {
       const agentId = args[1] ?? options.agent;
       if (agentId === undefined || agentId.length === 0) {
-        return usageError('ax agent run <agent-id> --task <text> [--input <json-object>]');
+        return usageError(AGENT_RUN_USAGE);
       }
 
       const parsed = parseOptionalJsonInput(options.input, 'Agent run');
@@ -92,10 +94,17 @@ export async function agentCommand(args: string[], options: CLIOptions): Promise
         return failure(parsed.error);
       }
 
+      const filesIndex = args.indexOf('--files');
+      const files = filesIndex === -1 ? undefined : args[filesIndex + 1]?.split(',').map((entry) => entry.trim()).filter((entry) => entry.length > 0);
+      if (filesIndex !== -1 && (files === undefined || files.length === 0)) {
+        return usageError(AGENT_RUN_USAGE);
+      }
+
       const result = await runtime.runAgent({
         agentId,
         task: options.task,
         input: parsed.value,
+        files,
         provider: options.provider,
         traceId: options.traceId,
         surface: 'cli',
@@ -108,6 +117,9 @@ export async function agentCommand(args: string[], options: CLIOptions): Promise
         `Mode: ${result.executionMode}`,
         `Success: ${result.success ? 'yes' : 'no'}`,
         result.content.length > 0 ? `Output:\n${result.content}` : undefined,
+        result.anchors !== undefined && result.anchors.length > 0
+          ? `Cites: ${result.anchors.map((anchor) => `${anchor.path}:${anchor.line}${anchor.endLine !== undefined ? `-${anchor.endLine}` : ''}`).join(', ')}`
+          : undefined,
         result.error?.message ? `Error: ${result.error.message}` : undefined,
         ...(result.warnings.map((warning) => `Warning: ${warning}`)),
       ].filter((value): value is string => value !== undefined);
@@ -116,6 +128,24 @@ export async function agentCommand(args: string[], options: CLIOptions): Promise
         ? success(lines.join('\n'), result)
         : failure(lines.join('\n'), result);
     }
+    case 'render': {
+      const agentId = args[1] ?? options.agent;
+      if (agentId === undefined || agentId.length === 0) {
+        return usageError('ax agent render <agent-id> [--task <text>] [--input <json-object>]');
+      }
+
+      const parsed = parseOptionalJsonInput(options.input, 'Agent render');
+      if (parsed.error !== undefined) {
+        return failure(parsed.error);
+      }
+
+      try {
+        const preview = await runtime.previewTemplates({ agentId, task: options.task, input: parsed.value });
+        return success(formatTemplatePreview(preview), preview);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
     case 'recommend': {
       const task = options.task ?? args.slice(1).join(' ').trim();
       if (task.length === 0) {
@@ -140,7 +170,7 @@ export async function agentCommand(args: string[], options: CLIOptions): Promise
       return success(lines.join('\n'), recommendations);
     }
     default:
-      return usageError('ax agent [list|get|register|remove|capabilities|run|recommend]');
+      return usageError('ax agent [list|get|register|remove|capabilities|run|render|recommend]');
   }
 }
 
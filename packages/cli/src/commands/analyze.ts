@@ -0,0 +1,431 @@
+import { formatImportGraphDot, type CodeMetricsSort } from '@defai.digital/shared-runtime';
+import type { CLIOptions, CommandResult } from '../types.js';
+import { createRuntime, failure, failureFromError, success, usageError } from '../utils/formatters.js';
+
+const ANALYZE_USAGE = 'ax analyze <dead-code|complexity|duplicates|includes|deps|impls|tests|lsif|embeds|fixture|conformance> [paths...] [options] (see ax analyze help)';
+
+export async function analyzeCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  const subcommand = args[0];
+  const basePath = options.outputDir ?? process.cwd();
+
+  switch (subcommand) {
+    case 'help':
+      return success([
+        'AX Analyze',
+        '',
+        'Usage:',
+        '  ax analyze dead-code [paths...] [--unexported-only] [--limit <n>]',
+        '  ax analyze complexity [paths...] [--query <symbol-query>] [--sort cognitive|cyclomatic|nesting|parameters|lines] [--min-cognitive <n>] [--min-cyclomatic <n>]',
+        '  ax analyze duplicates [paths...] [--min-tokens <n>] [--min-lines <n>]',
+        '  ax analyze includes [paths...] [--include-dir <dir>...]',
+        '  ax analyze deps [paths...] [--dot]',
+        '  ax analyze impls <interface|type> [paths...]',
+        '  ax analyze tests [paths...] [--untested]',
+        '  ax analyze lsif [paths...] [--output <file>]',
+        '  ax analyze embeds [paths...] [--file <path>...]',
+        '  ax analyze fixture <file> [--name <name>] [--output <dir>] [--redact <word>...]',
+        '  ax analyze conformance [dir]',
+        '',
+        'dead-code lists TS/JS, Go, C/C++, and Java/Kotlin declarations whose name is',
+        'never referenced outside their own definition anywhere in the workspace',
+        '(comments ignored). Paths narrow where declarations are reported; references',
+        'are always counted across the whole workspace. Exported symbols may still be',
+        'used by other packages; --unexported-only leaves them out. Spring and JUnit',
+        'annotated declarations are wired by the framework and never reported.',
+        '',
+        'complexity ranks functions and methods by heuristic cognitive complexity',
+        '(branches weighted by nesting), with cyclomatic complexity, parameter count,',
+        'and size alongside; --limit caps the list (default 50). --query takes a',
+        'symbol query such as "kind:method receiver:Server". Workflows given',
+        '{"metrics": {"paths": [...], "top": 5}} as input see the worst offenders as',
+        '{{metrics.list}}.',
+        '',
+        'duplicates finds code copied between files, or ported between languages:',
+        'runs of at least --min-tokens tokens (default 50) and --min-lines lines',
+        '(default 5) that match once identifiers and literals are normalized. Each',
+        'group lists every copy with the symbol it sits in, most duplicated lines',
+        'first. Workflows given {"duplicates": {"paths": [...], "top": 5}} as input',
+        'see the groups as {{duplicates.list}}.',
+        '',
+        'includes maps the #include graph of C/C++ files and cgo preambles,',
+        'listing external headers, unresolved includes, and include cycles.',
+        'Quoted includes resolve next to the including file, then under each',
+        '--include-dir (default: ., include, src).',
+        '',
+        'deps maps which Go packages import which, resolving import paths',
+        'through the go.mod files in the workspace, and lists external',
+        'packages and import cycles. Paths narrow the importing packages.',
+        '--dot prints the graph in Graphviz DOT with cycle edges in red;',
+        '--format json gives the full graph with every import\'s file and line.',
+        '',
+        'impls lists the types implementing an interface: Go types whose own',
+        'methods, plus those promoted from embedded fields, cover every method',
+        'the interface requires (by name), and TS/Java/Kotlin classes that',
+        'declare it; *T marks Go types that implement it only through a pointer.',
+        'Given a concrete type, it lists the interfaces the type satisfies',
+        'instead. Library interfaces such as io.Reader are known.',
+        '',
+        'tests maps Go Test, Benchmark, Fuzz, and Example functions to the',
+        'functions and methods they exercise: the one a test is named after',
+        '(TestServer_Start for Server.Start, TestParseEmpty for Parse) and the',
+        'ones it calls, directly or through helpers in test files. It lists the',
+        'functions no test reaches, exported first; --untested lists only those.',
+        '',
+        'lsif writes the parsed symbols and references as an LSIF dump for',
+        'code-intelligence tools and code review systems, to --output (default',
+        '.automatosx/exports/dump.lsif). Uses are linked to declarations by name;',
+        'a use several declarations match equally well is left unlinked.',
+        '',
+        'embeds lists //go:embed directives with the files each one pulls into',
+        'the build, and patterns that match nothing. --file names files or',
+        'directories about to be moved or deleted and reports the directives',
+        'that depend on them.',
+        '',
+        'fixture turns a file the parser gets wrong into a conformance fixture:',
+        'a sanitized copy (comment text and string contents blanked, --redact',
+        'words replaced, line numbers kept) plus <file>.symbols.json with the',
+        'symbols parsed today, written to .automatosx/parser-fixtures. Edit the',
+        'snapshot to what the parser should report and share both files.',
+        'conformance re-parses every fixture in a directory and fails on any',
+        'symbol that differs from its snapshot.',
+      ].join('\n'));
+    case 'dead-code': {
+      const paths: string[] = [];
+      let includeExported = true;
+      let limit: number | undefined;
+      for (let index = 1; index < args.length; index += 1) {
+        const token = args[index];
+        if (token === '--unexported-only') {
+          includeExported = false;
+        } else if (token === '--limit') {
+          limit = Number.parseInt(args[index + 1] ?? '', 10);
+          if (!Number.isInteger(limit) || limit <= 0) {
+            return usageError(ANALYZE_USAGE);
+          }
+          index += 1;
+        } else if (token !== undefined && token.startsWith('--')) {
+          return usageError(ANALYZE_USAGE);
+        } else if (token !== undefined) {
+          paths.push(token);
+        }
+      }
+      const report = await createRuntime(options).findDeadCode({ paths, includeExported, limit, basePath });
+      if (report.symbols.length === 0) {
+        return success(`No unreferenced symbols found in ${report.declarations} declarations.`, report);
+      }
+      const lines = [
+        `Unreferenced symbols: ${report.counts.unexported} unexported, ${report.counts.exported} exported (of ${report.declarations} declarations in ${report.scannedFiles} files).`,
+        ...report.symbols.map((symbol) => `- ${symbol.path}:${symbol.line}  ${symbol.kind} ${symbol.receiver !== undefined ? `${symbol.receiver}.` : ''}${symbol.name}${symbol.exported ? '  (exported)' : ''}`),
+      ];
+      if (report.truncated) {
+        lines.push('Output truncated; raise --limit to see more.');
+      }
+      return success(lines.join('\n'), report);
+    }
+    case 'includes': {
+      const paths: string[] = [];
+      const includeDirs: string[] = [];
+      for (let index = 1; index < args.length; index += 1) {
+        const token = args[index];
+        if (token === '--include-dir' && args[index + 1] !== undefined) {
+          includeDirs.push(args[index + 1]!);
+          index += 1;
+        } else if (token !== undefined && token.startsWith('--')) {
+          return usageError(ANALYZE_USAGE);
+        } else if (token !== undefined) {
+          paths.push(token);
+        }
+      }
+      const graph = await createRuntime(options).buildIncludeGraph({
+        paths,
+        includeDirs: includeDirs.length > 0 ? includeDirs : undefined,
+        basePath,
+      });
+      if (graph.edges.length === 0) {
+        return success('No #include directives found.', graph);
+      }
+      const lines = [
+        `Include graph: ${graph.nodes.length} files, ${graph.edges.length} includes, ${graph.external.length} external headers.`,
+        ...graph.nodes
+          .filter((node) => node.includes.length > 0)
+          .map((node) => `- ${node.path} -> ${node.includes.join(', ')}`),
+      ];
+      if (graph.unresolved.length > 0) {
+        lines.push('Unresolved:', ...graph.unresolved.map((edge) => `- ${edge.from}:${edge.line} "${edge.include}"`));
+      }
+      if (graph.cycles.length > 0) {
+        lines.push('Cycles:', ...graph.cycles.map((cycle) => `- ${[...cycle, cycle[0]].join(' -> ')}`));
+      }
+      return success(lines.join('\n'), graph);
+    }
+    case 'deps': {
+      const paths = args.slice(1).filter((token) => token !== '--dot');
+      if (paths.some((path) => path.startsWith('--'))) {
+        return usageError(ANALYZE_USAGE);
+      }
+      const graph = await createRuntime(options).buildImportGraph({ paths, basePath });
+      if (args.includes('--dot')) {
+        return success(formatImportGraphDot(graph), graph);
+      }
+      if (graph.nodes.length === 0) {
+        return success('No Go packages found.', graph);
+      }
+      const lines = [
+        `Import graph: ${graph.nodes.length} packages, ${graph.nodes.reduce((sum, node) => sum + node.imports.length, 0)} workspace imports, ${graph.external.length} external packages.`,
+        ...graph.nodes
+          .filter((node) => node.imports.length > 0)
+          .map((node) => `- ${node.package} -> ${node.imports.join(', ')}`),
+      ];
+      if (graph.cycles.length > 0) {
+        lines.push('Cycles:', ...graph.cycles.map((cycle) => `- ${[...cycle, cycle[0]].join(' -> ')}`));
+      }
+      return success(lines.join('\n'), graph);
+    }
+    case 'impls': {
+      const symbol = args[1];
+      const paths = args.slice(2);
+      if (symbol === undefined || symbol.startsWith('--') || paths.some((path) => path.startsWith('--'))) {
+        return usageError(ANALYZE_USAGE);
+      }
+      try {
+        const report = await createRuntime(options).findImplementations({ symbol, paths, basePath });
+        const describe = (entry: { name: string; path: string; line: number; pointer?: boolean; promoted?: string[] }) =>
+          `- ${entry.path}:${entry.line}  ${entry.pointer === true ? '*' : ''}${entry.name}${entry.promoted !== undefined ? `  (promoted: ${entry.promoted.join(', ')})` : ''}`;
+        if (report.methods.length === 0 && !report.declarations.some((declaration) => declaration.kind === 'interface')) {
+          return success(report.interfaces.length === 0
+            ? `${symbol} satisfies no workspace interface.`
+            : [`Interfaces satisfied by ${symbol} (${report.interfaces.length}):`, ...report.interfaces.map(describe)].join('\n'), report);
+        }
+        const lines = [
+          `Implementations of ${symbol} (${report.implementations.length})${report.methods.length > 0 ? `, requiring ${report.methods.join(', ')}` : ''}:`,
+          ...report.implementations.map(describe),
+        ];
+        if (report.unresolved.length > 0) {
+          lines.push(`Methods of ${report.unresolved.join(', ')} are not checked; they are declared outside the workspace.`);
+        }
+        return success(lines.join('\n'), report);
+      } catch (error) {
+        return failureFromError('find implementations', error);
+      }
+    }
+    case 'tests': {
+      const paths = args.slice(1).filter((token) => token !== '--untested');
+      if (paths.some((path) => path.startsWith('--'))) {
+        return usageError(ANALYZE_USAGE);
+      }
+      const map = await createRuntime(options).mapGoTests({ paths, basePath });
+      const total = map.tested.length + map.untested.length;
+      if (total === 0) {
+        return success('No Go functions found.', map);
+      }
+      const untested = map.untested.map((entry) => `- ${entry.name} ${entry.path}:${entry.line}${entry.exported ? '' : ' (unexported)'}`);
+      if (args.includes('--untested')) {
+        return success(map.untested.length === 0 ? 'Every function is targeted by a test.' : [`${map.untested.length} untested functions:`, ...untested].join('\n'), map);
+      }
+      return success([
+        `${map.tests.length} tests target ${map.tested.length} of ${total} functions.`,
+        ...map.tests.map((test) => `- ${test.name} -> ${test.targets.length > 0 ? test.targets.map((target) => target.name).join(', ') : '(nothing in the workspace)'}`),
+        ...(map.untested.length > 0 ? ['Untested:', ...untested] : []),
+      ].join('\n'), map);
+    }
+    case 'lsif': {
+      let outputPath: string | undefined;
+      const paths: string[] = [];
+      for (let index = 1; index < args.length; index += 1) {
+        const token = args[index]!;
+        if (token === '--output' && args[index + 1] !== undefined) {
+          outputPath = args[index + 1];
+          index += 1;
+        } else if (!token.startsWith('--')) {
+          paths.push(token);
+        } else {
+          return usageError(ANALYZE_USAGE);
+        }
+      }
+      const dump = await createRuntime(options).exportLsif({ paths, outputPath, basePath });
+      return success([
+        `Wrote ${dump.outputPath} (${dump.elements} elements, ${dump.bytes} bytes).`,
+        `${dump.documents} documents, ${dump.definitions} definitions, ${dump.references} linked uses${dump.ambiguous > 0 ? `, ${dump.ambiguous} ambiguous uses left unlinked` : ''}.`,
+      ].join('\n'), dump);
+    }
+    case 'embeds': {
+      const paths: string[] = [];
+      const files: string[] = [];
+      for (let index = 1; index < args.length; index += 1) {
+        const token = args[index];
+        if (token === '--file' && args[index + 1] !== undefined) {
+          files.push(args[index + 1]!);
+          index += 1;
+        } else if (token !== undefined && token.startsWith('--')) {
+          return usageError(ANALYZE_USAGE);
+        } else if (token !== undefined) {
+          paths.push(token);
+        }
+      }
+      const result = await createRuntime(options).findGoEmbeds({ paths, files, basePath });
+      if (result.directives.length === 0) {
+        return success(`No //go:embed directives found in ${result.scannedFiles} Go files.`, result);
+      }
+      const lines = [
+        `Embed directives (${result.directives.length}):`,
+        ...result.directives.map((directive) => `- ${directive.path}:${directive.line} ${directive.symbol}: ${directive.patterns.join(' ')} (${directive.files.length} files)`),
+      ];
+      const unmatched = result.directives.flatMap((directive) => directive.unmatched.map((pattern) => `- ${directive.path}:${directive.line} "${pattern}"`));
+      if (unmatched.length > 0) {
+        lines.push('Patterns matching no files (go build fails):', ...unmatched);
+      }
+      if (files.length > 0) {
+        const referenced = Object.entries(result.referenced);
+        lines.push(referenced.length === 0
+          ? 'None of the given files are embedded.'
+          : ['Embedded, so moving or deleting them breaks the build:', ...referenced.map(([file, users]) =>
+            `- ${file} <- ${users.map((user) => `${user.path}:${user.line} ${user.symbol} ("${user.pattern}")`).join(', ')}`)].join('\n'));
+      }
+      return success(lines.join('\n'), result);
+    }
+    case 'complexity': {
+      const paths: string[] = [];
+      let query: string | undefined;
+      let sort: CodeMetricsSort | undefined;
+      let minCognitive: number | undefined;
+      let minCyclomatic: number | undefined;
+      for (let index = 1; index < args.length; index += 1) {
+        const token = args[index];
+        const value = args[index + 1];
+        if (token === '--query' && value !== undefined) {
+          query = value;
+          index += 1;
+        } else if (token === '--sort' && value !== undefined) {
+          sort = value as CodeMetricsSort;
+          index += 1;
+        } else if ((token === '--min-cognitive' || token === '--min-cyclomatic') && value !== undefined) {
+          const threshold = Number.parseInt(value, 10);
+          if (!Number.isInteger(threshold) || threshold < 0) {
+            return usageError(ANALYZE_USAGE);
+          }
+          if (token === '--min-cognitive') {
+            minCognitive = threshold;
+          } else {
+            minCyclomatic = threshold;
+          }
+          index += 1;
+        } else if (token !== undefined && !token.startsWith('--')) {
+          paths.push(token);
+        } else {
+          return usageError(ANALYZE_USAGE);
+        }
+      }
+      try {
+        const report = await createRuntime(options).getCodeMetrics({ paths, query, sort, minCognitive, minCyclomatic, limit: options.limit, basePath });
+        const { summary } = report;
+        if (report.symbols.length === 0) {
+          return success(`No functions matched in ${report.scannedFiles} files.`, report);
+        }
+        return success([
+          `Complexity: ${summary.functions} functions in ${report.scannedFiles} files; average cognitive ${summary.averageCognitive}, cyclomatic ${summary.averageCyclomatic}; ${summary.overCognitive} over cognitive ${summary.thresholds.cognitive}, ${summary.overCyclomatic} over cyclomatic ${summary.thresholds.cyclomatic}.`,
+          `Worst by ${report.sort}${report.truncated ? ` (first ${report.symbols.length})` : ''}:`,
+          ...report.symbols.map((metrics) => `- ${metrics.path}:${metrics.line}  ${metrics.name}  cognitive ${metrics.cognitive}  cyclomatic ${metrics.cyclomatic}  nesting ${metrics.nesting}  params ${metrics.parameters}  lines ${metrics.lines}`),
+        ].join('\n'), report);
+      } catch (error) {
+        return failureFromError('measure complexity', error);
+      }
+    }
+    case 'duplicates': {
+      const paths: string[] = [];
+      let minTokens: number | undefined;
+      let minLines: number | undefined;
+      for (let index = 1; index < args.length; index += 1) {
+        const token = args[index];
+        const value = args[index + 1];
+        if ((token === '--min-tokens' || token === '--min-lines') && value !== undefined) {
+          const threshold = Number.parseInt(value, 10);
+          if (!Number.isInteger(threshold) || threshold <= 0) {
+            return usageError(ANALYZE_USAGE);
+          }
+          if (token === '--min-tokens') {
+            minTokens = threshold;
+          } else {
+            minLines = threshold;
+          }
+          index += 1;
+        } else if (token !== undefined && !token.startsWith('--')) {
+          paths.push(token);
+        } else {
+          return usageError(ANALYZE_USAGE);
+        }
+      }
+      try {
+        const report = await createRuntime(options).findDuplicates({ paths, minTokens, minLines, limit: options.limit, basePath });
+        const { summary } = report;
+        if (report.groups.length === 0) {
+          return success(`No duplicate code of ${report.minTokens}+ tokens in ${report.scannedFiles} files.`, report);
+        }
+        return success([
+          `Duplicates: ${summary.groups} groups, ${summary.fragments} copies, ${summary.duplicatedLines} of ${summary.codeLines} code lines (${summary.percentage}%) in ${report.scannedFiles} files.`,
+          ...report.groups.flatMap((group) => [
+            `${group.id}  ${group.lines} lines x${group.fragments.length}  ${group.languages.join(', ')}`,
+            ...group.fragments.map((fragment) => `  - ${fragment.path}:${fragment.line}-${fragment.endLine}${fragment.symbol !== undefined ? `  ${fragment.symbol}` : ''}`),
+          ]),
+          ...(report.truncated ? [`Showing the first ${report.groups.length}; raise --limit to see more.`] : []),
+        ].join('\n'), report);
+      } catch (error) {
+        return failureFromError('find duplicates', error);
+      }
+    }
+    case 'fixture': {
+      let path: string | undefined;
+      let name: string | undefined;
+      let outputDir: string | undefined;
+      const redact: string[] = [];
+      for (let index = 1; index < args.length; index += 1) {
+        const token = args[index];
+        if (token === '--name' && args[index + 1] !== undefined) {
+          name = args[index + 1];
+          index += 1;
+        } else if (token === '--output' && args[index + 1] !== undefined) {
+          outputDir = args[index + 1];
+          index += 1;
+        } else if (token === '--redact' && args[index + 1] !== undefined) {
+          redact.push(args[index + 1]!);
+          index += 1;
+        } else if (token !== undefined && !token.startsWith('--') && path === undefined) {
+          path = token;
+        } else {
+          return usageError(ANALYZE_USAGE);
+        }
+      }
+      if (path === undefined) {
+        return usageError(ANALYZE_USAGE);
+      }
+      try {
+        const fixture = await createRuntime(options).createParserFixture({ path, name, outputDir, redact, basePath });
+        return success([
+          `Wrote ${fixture.sourcePath} and ${fixture.snapshotPath} (${fixture.symbols.length} symbols).`,
+          `Sanitized ${fixture.sanitized.comments} comments and ${fixture.sanitized.strings} strings; ${fixture.sanitized.redactions} redactions.`,
+          ...fixture.symbols.map((symbol) => `- ${symbol.line}-${symbol.endLine}  ${symbol.kind} ${symbol.receiver !== undefined ? `${symbol.receiver}.` : ''}${symbol.name}`),
+          'Review the copy, then correct the snapshot wherever the parser is wrong.',
+        ].join('\n'), fixture);
+      } catch (error) {
+        return failureFromError('create parser fixture', error);
+      }
+    }
+    case 'conformance': {
+      if (args.length > 2 || args[1]?.startsWith('--') === true) {
+        return usageError(ANALYZE_USAGE);
+      }
+      const report = await createRuntime(options).checkParserFixtures({ dir: args[1], basePath });
+      if (report.fixtures.length === 0) {
+        return success(`No parser fixtures found in ${report.directory}.`, report);
+      }
+      const lines = [`Parser conformance: ${report.passed} passed, ${report.failed} failed (${report.directory}).`];
+      for (const fixture of report.fixtures.filter((entry) => !entry.passed)) {
+        lines.push(`- ${fixture.source}${fixture.error !== undefined ? `: ${fixture.error}` : ''}`);
+        lines.push(...fixture.missing.map((symbol) => `    missing    ${symbol.line}  ${symbol.kind} ${symbol.receiver !== undefined ? `${symbol.receiver}.` : ''}${symbol.name}  ${symbol.signature}`));
+        lines.push(...fixture.unexpected.map((symbol) => `    unexpected ${symbol.line}  ${symbol.kind} ${symbol.receiver !== undefined ? `${symbol.receiver}.` : ''}${symbol.name}  ${symbol.signature}`));
+      }
+      return report.failed === 0 ? success(lines.join('\n'), report) : failure(lines.join('\n'), report);
+    }
+    default:
+      return usageError(ANALYZE_USAGE);
+  }
+}
@@ -0,0 +1,166 @@
+import { spawnSync } from 'node:child_process';
+import { mkdtemp, readFile, rm, writeFile } from 'node:fs/promises';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { createInterface } from 'node:readline';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+const APPLY_USAGE = 'ax apply <patch-file|-> [--yes] [--minimal-diff]';
+const HUNK_PROMPT_HELP = [
+    'y - apply this hunk',
+    'n - skip this hunk (you can say why; the agent sees it next run)',
+    'e - edit this hunk in $EDITOR, then apply it',
+    'a - apply this and all remaining hunks',
+    'q - skip this and all remaining hunks',
+].join('\n');
+export async function applyCommand(args, options) {
+    const acceptAll = args.includes('--yes');
+    const minimalDiff = args.includes('--minimal-diff') ? true : undefined;
+    const positionals = args.filter((arg) => arg !== '--yes' && arg !== '--minimal-diff');
+    const source = positionals[0];
+    if (source === undefined || positionals.length > 1 || positionals.some((arg) => arg.startsWith('--'))) {
+        return usageError(APPLY_USAGE);
+    }
+    let patch;
+    try {
+        patch = source === '-' ? await readStdin() : await readFile(source, 'utf8');
+    }
+    catch (error) {
+        return failure(`Cannot read patch: ${error instanceof Error ? error.message : String(error)}`);
+    }
+    const runtime = createRuntime(options);
+    const files = runtime.parsePatch({ patch });
+    if (files.length === 0) {
+        return failure('No hunks found; expected a unified diff.');
+    }
+    let decisions;
+    if (acceptAll) {
+        decisions = files.flatMap((file) => file.hunks.map((hunk) => ({ path: file.path, hunkIndex: hunk.index, decision: 'accept'          })));
+    }
+    else if (process.stdin.isTTY === true && process.stdout.isTTY === true && source !== '-') {
+        decisions = await reviewInteractively(files);
+    }
+    else {
+        return failure('Per-hunk review needs an interactive terminal. Pass --yes to apply every hunk.');
+    }
+    try {
+        const result = await runtime.reviewPatch({
+            patch,
+            decisions,
+            minimalDiff,
+            agentId: options.agent,
+            sessionId: options.sessionId,
+            basePath: options.outputDir ?? process.cwd(),
+        });
+        const appliedHunks = result.applied.reduce((sum, file) => sum + file.hunks.length, 0);
+        const lines = [`Applied ${appliedHunks} hunk(s) to ${result.applied.length} file(s); rejected ${result.rejected.length}.`];
+        const fallbacks = result.strategies.filter((entry) => entry.strategy !== 'exact');
+        if (fallbacks.length > 0) {
+            lines.push(`Placed with fallback matching: ${fallbacks.map((entry) => `${entry.path}#${entry.hunkIndex + 1} (${entry.strategy})`).join(', ')}`);
+        }
+        if (result.restyled.length > 0) {
+            lines.push(`Matched file style: ${result.restyled.map((entry) => `${entry.path} (${entry.adjustments.join(', ')})`).join('; ')}`);
+        }
+        const bounced = result.rejected.filter((entry) => entry.bounced === true);
+        if (bounced.length > 0) {
+            lines.push('Bounced back to the agent:', ...bounced.map((entry) => `- ${entry.path}#${entry.hunkIndex + 1}: ${entry.reason}`));
+        }
+        if (result.edited.length > 0) {
+            lines.push(`Edited before applying: ${result.edited.map((entry) => `${entry.path}#${entry.hunkIndex + 1}`).join(', ')}`);
+        }
+        if (result.feedbackIds.length > 0) {
+            lines.push(`Recorded ${result.feedbackIds.length} rejection(s) as feedback${options.agent !== undefined ? ` for ${options.agent}` : ''}.`);
+        }
+        return success(lines.join('\n'), result);
+    }
+    catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+    }
+}
+async function reviewInteractively(files) {
+    const rl = createInterface({ input: process.stdin, output: process.stdout });
+    const decisions = [];
+    let remaining;
+    try {
+        for (const file of files) {
+            for (const hunk of file.hunks) {
+                if (remaining !== undefined) {
+                    decisions.push({ path: file.path, hunkIndex: hunk.index, decision: remaining });
+                    continue;
+                }
+                process.stdout.write(`\n${formatHunk(file, hunk)}\n`);
+                let decided = false;
+                while (!decided) {
+                    const answer = (await ask(rl, `(${hunk.index + 1}/${file.hunks.length}) Apply this hunk [y,n,e,a,q,?]? `)).trim().toLowerCase();
+                    decided = true;
+                    switch (answer) {
+                        case 'y':
+                            decisions.push({ path: file.path, hunkIndex: hunk.index, decision: 'accept' });
+                            break;
+                        case 'n': {
+                            const reason = (await ask(rl, 'Reason (optional): ')).trim();
+                            decisions.push({ path: file.path, hunkIndex: hunk.index, decision: 'reject', reason: reason.length > 0 ? reason : undefined });
+                            break;
+                        }
+                        case 'e': {
+                            const lines = await editHunk(hunk);
+                            decisions.push({ path: file.path, hunkIndex: hunk.index, decision: 'edit', lines });
+                            break;
+                        }
+                        case 'a':
+                            remaining = 'accept';
+                            decisions.push({ path: file.path, hunkIndex: hunk.index, decision: 'accept' });
+                            break;
+                        case 'q':
+                            remaining = 'reject';
+                            decisions.push({ path: file.path, hunkIndex: hunk.index, decision: 'reject' });
+                            break;
+                        default:
+                            process.stdout.write(`${HUNK_PROMPT_HELP}\n`);
+                            decided = false;
+                    }
+                }
+            }
+        }
+    }
+    finally {
+        rl.close();
+    }
+    return decisions;
+}
+function formatHunk(file, hunk) {
+    const color = (line) => {
+        if (line.startsWith('+')) {
+            return `\u001b[32m${line}\u001b[0m`;
+        }
+        if (line.startsWith('-')) {
+            return `\u001b[31m${line}\u001b[0m`;
+        }
+        return line;
+    };
+    return [`\u001b[1m${file.path}\u001b[0m`, `\u001b[36m${hunk.header}\u001b[0m`, ...hunk.lines.map(color)].join('\n');
+}
+async function editHunk(hunk) {
+    const dir = await mkdtemp(join(tmpdir(), 'ax-apply-'));
+    const path = join(dir, 'hunk.diff');
+    try {
+        await writeFile(path, `${hunk.lines.join('\n')}\n`, 'utf8');
+        const editor = process.env.VISUAL ?? process.env.EDITOR ?? 'vi';
+        spawnSync(`${editor} "${path}"`, { stdio: 'inherit', shell: true });
+        return (await readFile(path, 'utf8')).replace(/\n$/, '').split('\n');
+    }
+    finally {
+        await rm(dir, { recursive: true, force: true });
+    }
+}
+function ask(rl, question) {
+    return new Promise((resolve) => {
+        rl.question(question, resolve);
+    });
+}
+async function readStdin() {
+    const chunks = [];
+    for await (const chunk of process.stdin) {
+        chunks.push(chunk);
+    }
+    return Buffer.concat(chunks).toString('utf8');
+}
@@ -0,0 +1,47 @@
+import type { CLIOptions, CommandResult } from '../types.js';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+
+const ASK_USAGE = 'ax ask "<question>" [--max-tokens <n>]';
+
+export async function askCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  const positionals: string[] = [];
+  let maxTokens: number | undefined;
+  for (let index = 0; index < args.length; index += 1) {
+    const token = args[index];
+    if (token === '--max-tokens') {
+      const parsed = Number.parseInt(args[index + 1] ?? '', 10);
+      if (!Number.isFinite(parsed) || parsed <= 0) {
+        return failure('Ask max-tokens must be a positive integer.');
+      }
+      maxTokens = parsed;
+      index += 1;
+    } else if (token !== undefined && token.startsWith('--')) {
+      return usageError(ASK_USAGE);
+    } else if (token !== undefined) {
+      positionals.push(token);
+    }
+  }
+
+  const question = positionals.join(' ').trim();
+  if (question.length === 0) {
+    return usageError(ASK_USAGE);
+  }
+
+  const runtime = createRuntime(options);
+  const result = await runtime.askQuestion({
+    question,
+    provider: options.provider,
+    maxTokens,
+    sessionId: options.sessionId,
+    basePath: options.outputDir ?? process.cwd(),
+    surface: 'cli',
+  });
+  if (!result.success) {
+    return failure(`Ask failed: ${result.error ?? 'Unknown error'}`, result);
+  }
+
+  const sourceText = result.sources.length === 0
+    ? '\nNo matching memory or documentation was found.'
+    : `\nSources:\n${result.sources.map((source) => `  [${source.index}] ${source.kind} ${source.ref}${source.link !== undefined ? ` ${source.link}` : ''}`).join('\n')}`;
+  return success(`${result.answer}\n${sourceText}`, result);
+}
@@ -0,0 +1,66 @@
+import type { CLIOptions, CommandResult } from '../types.js';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+
+const BACKUP_USAGE = 'ax backup create [--output <file>] | ax backup verify <archive> | ax backup restore <archive>';
+
+export async function backupCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  const subcommand = args[0];
+  const basePath = options.outputDir ?? process.cwd();
+  const runtime = createRuntime(options);
+
+  switch (subcommand) {
+    case 'help':
+      return success([
+        'AX Backup',
+        '',
+        'Usage:',
+        `  ${BACKUP_USAGE}`,
+        '',
+        'Archives everything under .automatosx/ (config, memory, sessions, traces, artifacts)',
+        'into a single checksummed file. Restore verifies every entry and path before',
+        'writing, snapshots the current state to .automatosx/backups/ so it can be undone,',
+        'then builds the restored state beside .automatosx/ and swaps it in whole.',
+      ].join('\n'));
+    case 'create': {
+      let outputPath: string | undefined;
+      const rest = args.slice(1);
+      if (rest.length > 0) {
+        if (rest[0] !== '--output' || rest[1] === undefined || rest.length > 2) {
+          return usageError(BACKUP_USAGE);
+        }
+        outputPath = rest[1];
+      }
+      const backup = await runtime.createBackup({ outputPath, basePath });
+      return success(
+        `Backup written to ${backup.archivePath} (${backup.files.length} files, ${backup.totalBytes} bytes, sha256 ${backup.checksum.slice(0, 12)}).`,
+        backup,
+      );
+    }
+    case 'verify':
+    case 'restore': {
+      const archivePath = args[1];
+      if (archivePath === undefined || args.length > 2) {
+        return usageError(BACKUP_USAGE);
+      }
+      try {
+        if (subcommand === 'verify') {
+          const manifest = await runtime.verifyBackup({ archivePath });
+          return success(`Backup OK: ${manifest.files.length} files from ${manifest.createdAt} (AutomatosX ${manifest.productVersion ?? 'unknown'}).`, manifest);
+        }
+        const restored = await runtime.restoreBackup({ archivePath, basePath });
+        const lines = [`Restored ${restored.restored.length} files from the ${restored.createdAt} backup.`];
+        if (restored.safetyBackupPath !== undefined) {
+          lines.push(`Previous state saved to ${restored.safetyBackupPath}.`);
+        }
+        if (restored.previousStatePath !== undefined) {
+          lines.push(`Could not move the backups directory back; the replaced state, including its backups, was kept at ${restored.previousStatePath}.`);
+        }
+        return success(lines.join('\n'), restored);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    default:
+      return usageError(BACKUP_USAGE);
+  }
+}
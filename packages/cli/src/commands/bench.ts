@@ -0,0 +1,95 @@
+import { createInterface } from 'node:readline';
+import type { RuntimeBenchResponse } from '@defai.digital/shared-runtime';
+import type { CLIOptions, CommandResult } from '../types.js';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+
+const BENCH_USAGE = 'ax bench [run|approve|list] [task-id...] [--update]';
+
+export async function benchCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  const subcommand = args[0] === 'run' || args[0] === 'approve' || args[0] === 'list' || args[0] === 'help'
+    ? args[0]
+    : 'run';
+  const rest = args[0] === subcommand ? args.slice(1) : args;
+  const update = rest.includes('--update');
+  const unexpected = rest.find((token) => token.startsWith('--') && token !== '--update');
+  if (unexpected !== undefined) {
+    return usageError(BENCH_USAGE);
+  }
+  const taskIds = rest.filter((token) => !token.startsWith('--'));
+  const basePath = options.outputDir ?? process.cwd();
+  const runtime = createRuntime(options);
+
+  switch (subcommand) {
+    case 'help':
+      return success([
+        'AX Bench',
+        '',
+        'Usage:',
+        `  ${BENCH_USAGE}`,
+        '',
+        'Runs template tasks from .automatosx/bench/tasks/*.json and diffs each output against',
+        'its approved golden file in .automatosx/bench/golden/. Use "ax bench approve" or',
+        '--update to accept the latest outputs as the new golden files.',
+      ].join('\n'));
+    case 'list': {
+      const tasks = await runtime.listBenchTasks({ basePath });
+      if (tasks.length === 0) {
+        return success('No bench tasks found in .automatosx/bench/tasks.', tasks);
+      }
+      return success(['Bench tasks:', ...tasks.map((task) => `- ${task.taskId}${task.rubricId !== undefined ? ` (rubric: ${task.rubricId})` : ''}`)].join('\n'), tasks);
+    }
+    case 'approve': {
+      const approved = await runtime.approveBench({ taskIds, basePath });
+      if (approved.length === 0) {
+        return failure('No bench outputs to approve. Run "ax bench run" first.');
+      }
+      return success(`Approved ${approved.length} golden file${approved.length === 1 ? '' : 's'}: ${approved.join(', ')}.`, { approved });
+    }
+    case 'run': {
+      const result = await runtime.runBench({
+        taskIds,
+        provider: options.provider,
+        sessionId: options.sessionId,
+        basePath,
+      });
+      if (result.results.length === 0) {
+        return success('No bench tasks found in .automatosx/bench/tasks.', result);
+      }
+
+      const pending = result.results.filter((entry) => entry.status === 'changed' || entry.status === 'new').map((entry) => entry.taskId);
+      const report = formatBenchReport(result);
+      if (pending.length > 0 && (update || await confirmApproval(pending, options, report))) {
+        const approved = await runtime.approveBench({ taskIds: pending, basePath });
+        return success(`${report}\nApproved ${approved.length} golden file${approved.length === 1 ? '' : 's'}.`, { ...result, approved });
+      }
+      return result.passed ? success(report, result) : failure(report, result);
+    }
+  }
+}
+
+function formatBenchReport(result: RuntimeBenchResponse): string {
+  const lines = [
+    `Bench: ${result.summary.match} match, ${result.summary.changed} changed, ${result.summary.new} new, ${result.summary.failed} failed.`,
+  ];
+  for (const entry of result.results) {
+    const rubric = entry.rubric !== undefined ? ` rubric ${entry.rubric.rubricId}=${entry.rubric.score}${entry.rubric.passed ? '' : ' (below threshold)'}` : '';
+    lines.push(`- [${entry.status}] ${entry.taskId}${rubric}${entry.error !== undefined ? `: ${entry.error}` : ''}`);
+    lines.push(...entry.diff.map((line) => `    ${line}`));
+  }
+  return lines.join('\n');
+}
+
+async function confirmApproval(pending: string[], options: CLIOptions, report: string): Promise<boolean> {
+  if (options.format === 'json' || process.stdin.isTTY !== true || process.stdout.isTTY !== true) {
+    return false;
+  }
+  process.stdout.write(`${report}\n`);
+  const rl = createInterface({ input: process.stdin, output: process.stdout });
+  return new Promise<boolean>((resolve) => {
+    rl.question(`Approve ${pending.length} updated output${pending.length === 1 ? '' : 's'} as golden? [y/N] `, (answer) => {
+      rl.close();
+      const normalized = answer.toLowerCase().trim();
+      resolve(normalized === 'y' || normalized === 'yes');
+    });
+  });
+}
@@ -1,4 +1,6 @@
 import { readFile } from 'node:fs/promises';
+import { createInterface } from 'node:readline';
+import { formatClarifications, type ClarifyingQuestion, type RuntimeClarificationResponse } from '@defai.digital/shared-runtime';
 import type { CLIOptions, CommandResult } from '../types.js';
 import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
 import { splitCommaList } from '../utils/validation.js';
@@ -16,6 +18,8 @@ interface ParsedCallArgs {
   goal?: string;
   intent?: CallIntent;
   maxRounds?: number;
+  clarify: boolean;
+  maxQuestions?: number;
   error?: string;
 }
 
@@ -31,9 +35,10 @@ export async function callCommand(args: string[], options: CLIOptions): Promise<
   const basePath = options.outputDir ?? process.cwd();
   const runtime = createRuntime(options);
   const prompt = await buildPrompt(parsed.prompt, parsed.files);
-  if (parsed.autonomous || parsed.goal !== undefined || parsed.intent !== undefined) {
+  if (parsed.autonomous || parsed.clarify || parsed.goal !== undefined || parsed.intent !== undefined) {
     return runAutonomousCall(runtime, {
       ...parsed,
+      task: parsed.prompt,
       prompt,
       basePath,
       options,
@@ -74,6 +79,7 @@ function parseCallArgs(args: string[]): ParsedCallArgs {
     files: [],
     autonomous: false,
     requireReal: false,
+    clarify: false,
   };
   const positionals: string[] = [];
 
@@ -97,6 +103,10 @@ function parseCallArgs(args: string[]): ParsedCallArgs {
       parsed.requireReal = true;
       continue;
     }
+    if (name === 'clarify') {
+      parsed.clarify = true;
+      continue;
+    }
 
     const value = args[index + 1];
     if (value === undefined || value.startsWith('--')) {
@@ -144,6 +154,14 @@ function parseCallArgs(args: string[]): ParsedCallArgs {
         parsed.maxRounds = maxRounds;
         break;
       }
+      case 'max-questions': {
+        const maxQuestions = Number.parseInt(value, 10);
+        if (!Number.isFinite(maxQuestions) || maxQuestions < 0) {
+          return { ...parsed, error: 'Call max-questions must be a non-negative integer.' };
+        }
+        parsed.maxQuestions = maxQuestions;
+        break;
+      }
       default:
         return { ...parsed, error: `Unknown call flag: --${name}.` };
     }
@@ -179,6 +197,7 @@ async function buildPrompt(prompt: string, files: string[]): Promise<string> {
 async function runAutonomousCall(
   runtime: ReturnType<typeof createRuntime>,
   request: ParsedCallArgs & {
+    task: string;
     prompt: string;
     basePath: string;
     options: CLIOptions;
@@ -186,6 +205,18 @@ async function runAutonomousCall(
 ): Promise<CommandResult> {
   const intent = request.intent ?? classifyIntent(request.prompt, request.files);
   const phases = selectAutonomyPhases(intent, request.maxRounds);
+  // Clarification runs before the first (planning) round, on the task as typed rather than with attached files.
+  const clarification = request.clarify || await runtime.getConfig('clarification.enabled') === true
+    ? await runtime.clarifyTask({
+      task: request.task,
+      goal: request.goal,
+      files: request.files,
+      maxQuestions: request.maxQuestions,
+      basePath: request.basePath,
+    })
+    : undefined;
+  const clarifications = clarification?.ambiguous === true ? await askClarifyingQuestions(clarification, request.options) : [];
+  const basePrompt = clarifications.length === 0 ? request.prompt : `${request.prompt}\n\n${formatClarifications(clarifications)}`;
   const rounds: Array<{
     phase: string;
     traceId: string;
@@ -200,7 +231,7 @@ async function runAutonomousCall(
     const roundPrompt = buildAutonomyPrompt({
       intent,
       goal: request.goal,
-      basePrompt: request.prompt,
+      basePrompt,
       phase,
       previousContent,
     });
@@ -249,6 +280,7 @@ async function runAutonomousCall(
     `Intent: ${intent}`,
     `Provider: ${request.options.provider ?? 'claude'}`,
     `Execution modes: ${Array.from(new Set(rounds.map((round) => round.executionMode))).join(', ')}`,
+    ...renderClarification(clarification, clarifications),
     'Rounds:',
     ...rounds.map((round, index) => `- ${index + 1}. ${round.phase} (${round.traceId}, ${round.executionMode})`),
     '',
@@ -257,11 +289,48 @@ async function runAutonomousCall(
   ].join('\n'), {
     intent,
     goal: request.goal,
+    ...(clarification !== undefined ? { clarification: { ...clarification, questions: clarifications } } : {}),
     rounds,
     finalContent: finalRound?.content ?? '',
   });
 }
 
+// Asks the user whatever memory and specs did not answer; left open without an interactive terminal or under --format json.
+async function askClarifyingQuestions(clarification: RuntimeClarificationResponse, options: CLIOptions): Promise<ClarifyingQuestion[]> {
+  if (
+    clarification.questions.every((question) => question.answer !== undefined)
+    || options.format === 'json'
+    || process.stdin.isTTY !== true
+    || process.stdout.isTTY !== true
+  ) {
+    return clarification.questions;
+  }
+  process.stdout.write(`The task looks ambiguous (confidence ${clarification.confidence}). Answer or press Enter to let the planner assume.\n`);
+  const rl = createInterface({ input: process.stdin, output: process.stdout });
+  const ask = (question: string) => new Promise<string>((resolve) => {
+    rl.question(`${question} `, resolve);
+  });
+  const questions: ClarifyingQuestion[] = [];
+  for (const question of clarification.questions) {
+    const answer = question.answer === undefined ? (await ask(question.question)).trim() : '';
+    questions.push(answer.length > 0 ? { ...question, answer, source: 'user' } : question);
+  }
+  rl.close();
+  return questions;
+}
+
+function renderClarification(clarification: RuntimeClarificationResponse | undefined, questions: ClarifyingQuestion[]): string[] {
+  if (clarification === undefined) {
+    return [];
+  }
+  const open = questions.filter((question) => question.answer === undefined).length;
+  return [
+    clarification.ambiguous
+      ? `Clarification: confidence ${clarification.confidence} below ${clarification.threshold}; ${questions.length - open} of ${questions.length} question${questions.length === 1 ? '' : 's'} answered${open > 0 ? ', the rest left to stated assumptions' : ''}`
+      : `Clarification: confidence ${clarification.confidence}, no questions needed`,
+  ];
+}
+
 function classifyIntent(prompt: string, files: string[]): CallIntent {
   const normalized = prompt.toLowerCase();
   if (
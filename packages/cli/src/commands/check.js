@@ -0,0 +1,112 @@
+import { copyFile, mkdir } from 'node:fs/promises';
+import { dirname, resolve } from 'node:path';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+const CHECK_USAGE = 'ax check [paths...] [--base <ref>] [--focus security|correctness|maintainability|all] [--fail-on critical|warning|note|never] [--sarif <file>] [--max-files <n>]';
+const CHECK_FINDINGS_SHOWN = 20;
+const SEVERITY_RANK = { note: 1, warning: 2, critical: 3 };
+export async function checkCommand(args, options) {
+    if (args[0] === 'help') {
+        return success([
+            'AX Check',
+            '',
+            'Usage:',
+            `  ${CHECK_USAGE}`,
+            '',
+            'Runs the review heuristics as a CI gate. With --base, only files changed',
+            'since the merge base with <ref> are checked (e.g. origin/main on a pull',
+            'request). The command exits non-zero when a finding is at or above',
+            '--fail-on (default: critical). --sarif copies the SARIF report to <file>',
+            'for code scanning upload.',
+            '',
+            'Forbidden patterns in .automatosx/patterns (tree-sitter queries, see the README)',
+            'are checked too and reported as forbidden.<id>, with their suggested fix.',
+        ].join('\n'));
+    }
+    const paths = [];
+    let base;
+    let focus = 'all';
+    let failOn = 'critical';
+    let sarifFile;
+    let maxFiles = 200;
+    for (let index = 0; index < args.length; index += 1) {
+        const token = args[index];
+        const value = args[index + 1];
+        if (token === '--base' && value !== undefined) {
+            base = value;
+        }
+        else if (token === '--focus' && (value === 'all' || value === 'security' || value === 'correctness' || value === 'maintainability')) {
+            focus = value;
+        }
+        else if (token === '--fail-on' && (value === 'critical' || value === 'warning' || value === 'note' || value === 'never')) {
+            failOn = value;
+        }
+        else if (token === '--sarif' && value !== undefined) {
+            sarifFile = value;
+        }
+        else if (token === '--max-files' && value !== undefined && Number.parseInt(value, 10) > 0) {
+            maxFiles = Number.parseInt(value, 10);
+        }
+        else if (token !== undefined && token.startsWith('--')) {
+            return usageError(CHECK_USAGE);
+        }
+        else {
+            if (token !== undefined) {
+                paths.push(token);
+            }
+            continue;
+        }
+        index += 1;
+    }
+    const basePath = options.outputDir ?? process.cwd();
+    const runtime = createRuntime(options);
+    let targets = paths.length > 0 ? paths : ['.'];
+    if (base !== undefined) {
+        let changed;
+        try {
+            const diff = await runtime.gitDiff({ basePath, commit: `${base}...HEAD`, nameOnly: true, paths });
+            changed = diff.diff.split('\n').map((line) => line.trim()).filter((line) => line.length > 0);
+        }
+        catch (error) {
+            return failure(`Check failed: ${error instanceof Error ? error.message : String(error)}`);
+        }
+        if (changed.length === 0) {
+            return success(`No files changed since ${base}; nothing to check.`, { base, changed });
+        }
+        targets = changed;
+    }
+    const result = await runtime.analyzeReview({
+        paths: targets,
+        focus,
+        maxFiles,
+        traceId: options.traceId,
+        sessionId: options.sessionId,
+        basePath,
+        surface: 'cli',
+    });
+    if (!result.success) {
+        return failure(`Check failed: ${result.error?.message ?? 'Unknown error'}`, result);
+    }
+    if (sarifFile !== undefined) {
+        const destination = resolve(basePath, sarifFile);
+        await mkdir(dirname(destination), { recursive: true });
+        await copyFile(result.sarifPath, destination);
+    }
+    const blocking = failOn === 'never'
+        ? []
+        : result.findings.filter((finding) => SEVERITY_RANK[finding.severity] >= SEVERITY_RANK[failOn]);
+    const lines = [
+        `Checked ${result.filesScanned} files: ${result.summary.critical} critical, ${result.summary.warning} warning, ${result.summary.note} note.`,
+        ...result.findings.slice(0, CHECK_FINDINGS_SHOWN).map((finding) => `- [${finding.severity}] ${finding.file}:${finding.line} ${finding.ruleId}${finding.fix !== undefined ? ` (fix: ${finding.fix})` : ''}`),
+    ];
+    if (result.findings.length > CHECK_FINDINGS_SHOWN) {
+        lines.push(`... ${result.findings.length - CHECK_FINDINGS_SHOWN} more in ${result.reportPath}`);
+    }
+    if (sarifFile !== undefined) {
+        lines.push(`SARIF written to ${sarifFile}.`);
+    }
+    if (blocking.length > 0) {
+        lines.push(`Check failed: ${blocking.length} finding(s) at or above ${failOn}.`);
+        return failure(lines.join('\n'), result);
+    }
+    return success(lines.join('\n'), result);
+}
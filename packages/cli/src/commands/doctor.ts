@@ -1,6 +1,6 @@
 import { constants } from 'node:fs';
 import { access, readFile } from 'node:fs/promises';
-import { join } from 'node:path';
+import { basename, join } from 'node:path';
 import { createMcpServerSurface } from '@defai.digital/mcp-server';
 import type { CLIOptions, CommandResult } from '../types.js';
 import { createRuntime, failure, success } from '../utils/formatters.js';
@@ -231,8 +231,20 @@ export async function doctorCommand(_args: string[], options: CLIOptions): Promi
     });
   }
 
+  const plugins = await runtime.loadExtractorPlugins({ basePath });
+  if (plugins.loaded.length > 0 || plugins.errors.length > 0) {
+    checks.push({
+      id: 'extractor-plugins',
+      status: plugins.errors.length === 0 ? 'ok' : 'fail',
+      message: plugins.errors.length === 0
+        ? `Extractor plugins loaded (${plugins.loaded.map((plugin) => `${plugin.language}: ${plugin.extensions.join(', ')}`).join('; ')}).`
+        : `Extractor plugins failed validation (${plugins.errors.map((plugin) => `${basename(plugin.sourcePath)}: ${plugin.error}`).join('; ')}).`,
+    });
+  }
+
   try {
-    const tools = createMcpServerSurface({ basePath }).listTools();
+    const surface = createMcpServerSurface({ basePath });
+    const tools = surface.listTools();
     const missingTools = REQUIRED_MCP_TOOLS.filter((toolName) => !tools.includes(toolName));
     checks.push({
       id: 'mcp-surface',
@@ -241,6 +253,22 @@ export async function doctorCommand(_args: string[], options: CLIOptions): Promi
         ? `MCP surface is available (${tools.length} tools).`
         : `MCP surface is missing required tools (${missingTools.join(', ')}).`,
     });
+    const compositeErrors = surface.listCompositeToolErrors();
+    if (compositeErrors.length > 0) {
+      checks.push({
+        id: 'composite-tools',
+        status: 'fail',
+        message: `Composite tools were not registered (${compositeErrors.join('; ')}).`,
+      });
+    }
+    const clientProfileErrors = surface.listClientProfileErrors();
+    if (clientProfileErrors.length > 0) {
+      checks.push({
+        id: 'mcp-clients',
+        status: 'fail',
+        message: `MCP client profiles were not loaded (${clientProfileErrors.join('; ')}).`,
+      });
+    }
   } catch (error) {
     const message = error instanceof Error ? error.message : String(error);
     checks.push({
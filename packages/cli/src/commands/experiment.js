@@ -0,0 +1,175 @@
+import { createRuntime, failure, failureFromError, success, usageError } from '../utils/formatters.js';
+const EXPERIMENT_USAGE = 'ax experiment run "<task>" [--variants <n>] [--strategies a,b] [--providers a,b] [--model <model>] [--files a,b] [--check "<command>"]... | list | show <id> | pick <id> [variant]';
+export async function experimentCommand(args, options) {
+    const basePath = options.outputDir ?? process.cwd();
+    const [subcommand, ...rest] = args;
+    if (subcommand === undefined || subcommand === 'help') {
+        return success([
+            'AX Experiment',
+            '',
+            'Usage:',
+            '  ax experiment run "<task>" [--variants <n>] [--strategies a,b] [--providers a,b]',
+            '                    [--model <model>] [--files a,b] [--check "<command>"]...',
+            '  ax experiment list',
+            '  ax experiment show <id>',
+            '  ax experiment pick <id> [variant]',
+            '',
+            'Runs the same task --variants ways (default 3), each with its own strategy',
+            '(minimal, thorough, refactor, or experiment.strategies in config) and, with',
+            '--providers, its own provider. Each variant\'s diff is applied to a separate',
+            'copy of the workspace and checked against the definition of done there:',
+            'the --check commands, else .automatosx/done/experiment.json, else the',
+            'workspace\'s tests. The workspace itself is untouched until you pick one.',
+            '',
+            'run prints a comparison table, best first; pick applies a variant\'s diff,',
+            'the recommended one by default.',
+        ].join('\n'));
+    }
+    const runtime = createRuntime(options);
+    try {
+        switch (subcommand) {
+            case 'run': {
+                const parsed = parseRunArgs(rest, options.task);
+                if (parsed === undefined) {
+                    return usageError(EXPERIMENT_USAGE);
+                }
+                const record = await runtime.runExperiment({
+                    task: parsed.task,
+                    variants: parsed.variants,
+                    strategies: parsed.strategies,
+                    providers: parsed.providers ?? (options.provider !== undefined ? [options.provider] : undefined),
+                    model: parsed.model,
+                    files: parsed.files,
+                    done: parsed.checks.length > 0 ? parsed.checks : undefined,
+                    sessionId: options.sessionId,
+                    basePath,
+                });
+                return success(formatExperiment(record), record);
+            }
+            case 'list': {
+                if (rest.length > 0) {
+                    return usageError(EXPERIMENT_USAGE);
+                }
+                const experiments = await runtime.listExperiments({ basePath });
+                if (experiments.length === 0) {
+                    return success('No experiments yet. Start one with: ax experiment run "<task>"', experiments);
+                }
+                return success([
+                    'Experiments:',
+                    ...experiments.map((experiment) => {
+                        const passed = experiment.variants.filter((variant) => variant.status === 'passed').length;
+                        const state = experiment.picked !== undefined ? `picked ${experiment.picked.variantId}` : experiment.recommended !== undefined ? `recommended ${experiment.recommended}` : 'no usable variant';
+                        return `- ${experiment.experimentId} ${experiment.createdAt}: ${passed}/${experiment.variants.length} passed, ${state} - ${experiment.task}`;
+                    }),
+                ].join('\n'), experiments);
+            }
+            case 'show': {
+                if (rest.length !== 1) {
+                    return usageError(EXPERIMENT_USAGE);
+                }
+                const record = await runtime.getExperiment({ experimentId: rest[0], basePath });
+                return success(formatExperiment(record), record);
+            }
+            case 'pick': {
+                if (rest.length < 1 || rest.length > 2) {
+                    return usageError(EXPERIMENT_USAGE);
+                }
+                const picked = await runtime.pickExperimentVariant({ experimentId: rest[0], variantId: rest[1], sessionId: options.sessionId, basePath });
+                const written = picked.patch.applied.map((file) => file.path);
+                const text = [
+                    `Applied ${picked.variantId} of ${picked.experiment.experimentId} to ${written.length} files${written.length > 0 ? `: ${written.join(', ')}` : ''}.`,
+                    ...picked.patch.rejected.map((hunk) => `- sent back ${hunk.path} hunk ${hunk.hunkIndex}${hunk.reason !== undefined ? `: ${hunk.reason}` : ''}`),
+                ].join('\n');
+                return picked.patch.rejected.length === 0 ? success(text, picked) : failure(text, picked);
+            }
+            default:
+                return usageError(EXPERIMENT_USAGE);
+        }
+    }
+    catch (error) {
+        return failureFromError(`${subcommand} experiment`, error);
+    }
+}
+function formatExperiment(record) {
+    const header = ['Variant', 'Strategy', 'Provider', 'Status', 'Checks', 'Files', 'Diff', 'Time'];
+    const rows = record.variants.map((variant) => [
+        variant.variantId,
+        variant.strategy,
+        variant.provider ?? 'default',
+        variant.status,
+        variant.status === 'error' ? '-' : `${variant.passedCriteria}/${variant.checkedCriteria}`,
+        String(variant.files.length),
+        `+${variant.additions} -${variant.deletions}`,
+        `${(variant.latencyMs / 1000).toFixed(1)}s`,
+    ]);
+    const widths = header.map((title, column) => Math.max(title.length, ...rows.map((row) => row[column].length)));
+    const line = (cells) => cells.map((cell, column) => cell.padEnd(widths[column])).join('  ').trimEnd();
+    const problems = record.variants.flatMap((variant) => problemLines(variant));
+    return [
+        `Experiment ${record.experimentId}: ${record.task}`,
+        `Criteria: ${record.criteria.length > 0 ? record.criteria.map((criterion) => criterion.id).join(', ') : 'none'}`,
+        '',
+        line(header),
+        line(widths.map((width) => '-'.repeat(width))),
+        ...rows.map(line),
+        ...(problems.length > 0 ? ['', ...problems] : []),
+        '',
+        record.picked !== undefined
+            ? `Picked ${record.picked.variantId} at ${record.picked.at}.`
+            : record.recommended !== undefined
+                ? `Recommended: ${record.recommended}. Merge it with: ax experiment pick ${record.experimentId} ${record.recommended}`
+                : 'No variant produced a diff that applied.',
+    ].join('\n');
+}
+function problemLines(variant) {
+    if (variant.error !== undefined) {
+        return [`${variant.variantId}: ${variant.error}`];
+    }
+    return (variant.verification?.results ?? [])
+        .filter((result) => result.status === 'failed')
+        .map((result) => `${variant.variantId}: ${result.id} failed${result.detail !== undefined ? ` - ${result.detail.split('\n')[0]}` : ''}`);
+}
+function parseRunArgs(args, taskOption) {
+    const words = [];
+    const parsed = { checks: [] };
+    const list = (value) => value.split(',').map((entry) => entry.trim()).filter((entry) => entry.length > 0);
+    for (let index = 0; index < args.length; index += 1) {
+        const token = args[index] ?? '';
+        if (!token.startsWith('--')) {
+            words.push(token);
+            continue;
+        }
+        const value = args[index + 1];
+        if (value === undefined || value.startsWith('--')) {
+            return undefined;
+        }
+        if (token === '--variants') {
+            const count = Number(value);
+            if (!Number.isInteger(count) || count < 1) {
+                return undefined;
+            }
+            parsed.variants = count;
+        }
+        else if (token === '--strategies') {
+            parsed.strategies = list(value);
+        }
+        else if (token === '--providers') {
+            parsed.providers = list(value);
+        }
+        else if (token === '--model') {
+            parsed.model = value;
+        }
+        else if (token === '--files') {
+            parsed.files = list(value);
+        }
+        else if (token === '--check') {
+            parsed.checks.push(value);
+        }
+        else {
+            return undefined;
+        }
+        index += 1;
+    }
+    const task = words.length > 0 ? words.join(' ') : taskOption;
+    return task !== undefined && task.trim().length > 0 ? { ...parsed, task: task.trim() } : undefined;
+}
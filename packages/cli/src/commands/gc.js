@@ -0,0 +1,92 @@
+import { createRuntime, failureFromError, success, usageError } from '../utils/formatters.js';
+const GC_USAGE = 'ax gc [--max-age-days <n>] [--prefix <branch-prefix>] [--yes] [--force]';
+export async function gcCommand(args, options) {
+    const basePath = options.outputDir ?? process.cwd();
+    if (args[0] === 'help') {
+        return success([
+            'AX GC',
+            '',
+            'Usage:',
+            `  ${GC_USAGE}`,
+            '',
+            'Finds what abandoned runs leave behind: ax/ branches (or',
+            '--prefix) and their worktrees with no commits for --max-age-days (default 14,',
+            'or gc.maxAgeDays in config), worktrees whose directory is gone, scratch',
+            'directories from interrupted apply, sync, and backup runs, session recordings',
+            'in .automatosx/recordings with nothing recorded for as long, and sessions',
+            'still active with no activity for as long. Each is listed with what it holds:',
+            'commits and files not on the base branch, uncommitted changes, files and size.',
+            '',
+            'Only reports unless --yes or --force is given. With --yes, merged branches,',
+            'clean worktrees, and sandboxes are removed; unmerged branches and worktrees',
+            'with uncommitted changes are kept unless --force is given. The current',
+            'branch, the base branch, main, master, and locked worktrees are always kept.',
+        ].join('\n'));
+    }
+    const parsed = parseGcArgs(args);
+    if (parsed === undefined) {
+        return usageError(GC_USAGE);
+    }
+    try {
+        const { yes, ...request } = parsed;
+        const dryRun = options.dryRun === true || (yes !== true && request.force !== true);
+        const result = await createRuntime(options).collectGarbage({ ...request, dryRun, basePath });
+        return success(formatGcReport(result), result);
+    }
+    catch (error) {
+        return failureFromError('collect garbage', error);
+    }
+}
+function formatGcReport(result) {
+    const verb = result.dryRun ? 'Would remove' : 'Removed';
+    const item = (action, text, reason) => `- ${action === 'remove' ? (result.dryRun ? 'remove' : 'removed') : 'keep'} ${text}${reason !== undefined ? ` (${reason})` : ''}`;
+    const removed = [...result.branches, ...result.worktrees, ...result.sandboxes].filter((entry) => entry.action === 'remove').length + result.recordings.length + result.recordingObjects.files + result.sessions.length;
+    const kept = [...result.branches, ...result.worktrees].filter((entry) => entry.action === 'keep').length;
+    if (removed + kept === 0) {
+        return `Nothing stale: no ${result.branchPrefix} branches, worktrees, sandboxes, recordings, or sessions idle for ${result.maxAgeDays} days.`;
+    }
+    return [
+        `${verb} ${removed} stale items${kept > 0 ? `; kept ${kept}` : ''} (idle ${result.maxAgeDays}+ days${result.base !== undefined ? `, compared with ${result.base}` : ''}).`,
+        ...(result.branches.length > 0 ? ['', 'Branches:'] : []),
+        ...result.branches.map((branch) => item(branch.action, `${branch.name}: ${branch.ahead > 0 ? `${branch.ahead} unmerged commits touching ${branch.files} files` : 'merged'}, last "${branch.subject}" ${branch.ageDays}d ago`, branch.reason)),
+        ...(result.worktrees.length > 0 ? ['', 'Worktrees:'] : []),
+        ...result.worktrees.map((worktree) => item(worktree.action, `${worktree.path}${worktree.branch !== undefined ? ` [${worktree.branch}]` : ''}: ${worktree.missing ? 'directory is gone' : `${worktree.changes} uncommitted changes, ${worktree.ageDays}d old`}`, worktree.reason)),
+        ...(result.sandboxes.length > 0 ? ['', 'Sandboxes:'] : []),
+        ...result.sandboxes.map((sandbox) => item(sandbox.action, `${sandbox.path}: ${sandbox.files} files, ${sandbox.bytes} bytes, ${sandbox.ageDays}d old`)),
+        ...(result.recordings.length > 0 ? ['', 'Recordings:'] : []),
+        ...result.recordings.map((recording) => item('remove', `${recording.sessionId}: ${recording.events} events, ${recording.bytes} bytes, ${recording.ageDays}d old`)),
+        ...(result.recordingObjects.files > 0 ? [`- ${result.dryRun ? 'remove' : 'removed'} ${result.recordingObjects.files} recorded file contents no kept recording uses (${result.recordingObjects.bytes} bytes)`] : []),
+        ...(result.sessions.length > 0 ? ['', 'Sessions:'] : []),
+        ...result.sessions.map((session) => `- ${result.dryRun ? 'close' : 'closed'} ${session.sessionId}: ${session.task} (${session.participants} participants, idle ${session.ageDays}d)`),
+        ...(result.dryRun && removed > 0 ? ['', 'Nothing was removed. Re-run with --yes to remove these.'] : []),
+    ].join('\n');
+}
+function parseGcArgs(args) {
+    const parsed = {};
+    for (let index = 0; index < args.length; index += 1) {
+        const token = args[index] ?? '';
+        const value = args[index + 1];
+        if (token === '--force' || token === '--yes') {
+            parsed[token === '--force' ? 'force' : 'yes'] = true;
+            continue;
+        }
+        if (value === undefined || value.startsWith('--')) {
+            return undefined;
+        }
+        if (token === '--max-age-days') {
+            const days = Number(value);
+            if (!Number.isFinite(days) || days <= 0) {
+                return undefined;
+            }
+            parsed.maxAgeDays = days;
+        }
+        else if (token === '--prefix' && value.trim().length > 0) {
+            parsed.branchPrefix = value;
+        }
+        else {
+            return undefined;
+        }
+        index += 1;
+    }
+    return parsed;
+}
@@ -0,0 +1,71 @@
+import { join } from 'node:path';
+import type { CLIOptions, CommandResult } from '../types.js';
+import { createRuntime, success, usageError } from '../utils/formatters.js';
+
+const HANDOFF_USAGE = 'ax handoff [--hours <n>] [--output <file>]';
+const DEFAULT_WINDOW_HOURS = 24;
+
+interface ParsedHandoffArgs {
+  windowHours: number;
+  outputPath?: string;
+  error?: string;
+}
+
+export async function handoffCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  if (args[0] === 'help') {
+    return success([
+      'AX On-Call Handoff',
+      '',
+      'Usage:',
+      `  ${HANDOFF_USAGE}`,
+      '',
+      'Compiles recent incidents, fix sessions, open follow-ups (failed sessions and the',
+      '"backlog" memory namespace), and risky in-flight changes into a handoff document.',
+    ].join('\n'));
+  }
+
+  const parsed = parseHandoffArgs(args);
+  if (parsed.error !== undefined) {
+    return usageError(HANDOFF_USAGE);
+  }
+
+  const basePath = options.outputDir ?? process.cwd();
+  const handoff = await createRuntime(options).summarizeHandoff({
+    windowHours: parsed.windowHours,
+    outputPath: parsed.outputPath ?? join(basePath, '.automatosx', 'handoffs', `handoff-${Date.now()}.md`),
+    basePath,
+  });
+
+  const summary = [
+    `Handoff written to ${handoff.artifactPath}.`,
+    `Incidents: ${handoff.incidents.length}. Fix sessions: ${handoff.fixSessions.length}. Follow-ups: ${handoff.followUps.length}. Risky changes: ${handoff.riskyChanges.length}.`,
+  ];
+  return success(options.verbose ? `${summary.join('\n')}\n\n${handoff.markdown}` : summary.join('\n'), handoff);
+}
+
+function parseHandoffArgs(args: string[]): ParsedHandoffArgs {
+  const parsed: ParsedHandoffArgs = { windowHours: DEFAULT_WINDOW_HOURS };
+
+  for (let index = 0; index < args.length; index += 1) {
+    const token = args[index];
+    const value = args[index + 1];
+    if (token === '--hours') {
+      const hours = Number.parseInt(value ?? '', 10);
+      if (!Number.isFinite(hours) || hours <= 0) {
+        return { ...parsed, error: 'Handoff hours must be a positive integer.' };
+      }
+      parsed.windowHours = hours;
+      index += 1;
+    } else if (token === '--output') {
+      if (value === undefined || value.startsWith('--')) {
+        return { ...parsed, error: 'Missing value for --output.' };
+      }
+      parsed.outputPath = value;
+      index += 1;
+    } else {
+      return { ...parsed, error: `Unknown handoff argument: ${token}.` };
+    }
+  }
+
+  return parsed;
+}
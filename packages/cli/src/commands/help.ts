@@ -9,6 +9,7 @@ export const RETAINED_COMMANDS = [
   { command: 'status', description: 'Show active sessions, running traces, and provider/runtime readiness.' },
   { command: 'config', description: 'Inspect or update workspace config used by the runtime and provider bridge.' },
   { command: 'cleanup', description: 'Auto-close stale sessions and traces from shared runtime storage.' },
+  { command: 'gc', description: 'Remove stale ax/ branches and worktrees, leftover sandboxes, and abandoned sessions.' },
   { command: 'resume', description: 'Rerun a prior workflow or discussion trace from stored execution context.' },
   { command: 'call', description: 'Call a provider directly through the shared runtime bridge.' },
   { command: 'list', description: 'List available workflows from the shared runtime loader.' },
@@ -22,11 +23,27 @@ export const ADVANCED_COMMANDS = [
   { command: 'guard', description: 'List, apply, and evaluate workflow guard policies.' },
   { command: 'agent', description: 'Inspect or register agents through the shared runtime state store.' },
   { command: 'mcp', description: 'Inspect available MCP tools or invoke them through the local MCP surface.' },
-  { command: 'session', description: 'Create and manage collaboration sessions through shared runtime state.' },
+  { command: 'session', description: 'Create and manage collaboration sessions through shared runtime state, and replay recorded ones.' },
   { command: 'review', description: 'Run deterministic v14-native code review heuristics with durable artifacts.' },
   { command: 'history', description: 'View past workflow run history from the trace store.' },
+  { command: 'apply', description: 'Review a unified diff per hunk (accept, reject, edit) before writing it.' },
+  { command: 'ask', description: 'Answer a question from memory and documentation without write tools.' },
+  { command: 'sync', description: 'Synchronize memory, specs, and workflow definitions with an encrypted remote.' },
+  { command: 'backup', description: 'Archive or restore all .automatosx state (config, memory, sessions, artifacts) with integrity checks.' },
+  { command: 'memory', description: 'Export, import, prune, or deduplicate key-value and semantic memory.' },
+  { command: 'snapshot', description: 'Browse or restore content-addressed working tree snapshots taken during a session.' },
+  { command: 'analyze', description: 'Find unreferenced (dead) functions, types, and methods, rank complex functions, map C/C++ includes, check Go embeds, or build parser fixtures.' },
+  { command: 'check', description: 'Gate CI on review findings for changed files and emit SARIF for code scanning.' },
+  { command: 'webhook', description: 'Run workflows from "/ax" pull request comments and post results back to GitHub.' },
+  { command: 'migrate', description: 'Rewrite older .automatosx config, workflows, and agent profiles to the current schema, with backups.' },
+  { command: 'report-bug', description: 'File a prefilled bug report from the latest sanitized crash bundle.' },
+  { command: 'telemetry', description: 'Manage opt-in anonymized telemetry: preview the report, send it, or run a self-hosted collector.' },
+  { command: 'bench', description: 'Diff agent outputs for template tasks against approved golden files.' },
+  { command: 'experiment', description: 'Run a task several ways in isolated sandboxes, compare how each fares against the checks, and apply the best.' },
+  { command: 'handoff', description: 'Compile incidents, fix sessions, follow-ups, and in-flight risk into an on-call handoff.' },
   { command: 'iterate', description: 'Repeat a command until success, iteration budget, or time budget is exhausted.' },
   { command: 'monitor', description: 'Launch a local HTTP dashboard showing sessions, traces, and agents.' },
+  { command: 'todos', description: 'List TODO/FIXME/HACK comments by enclosing symbol and age, and link them to the backlog.' },
   { command: 'scaffold', description: 'Generate contract-first components: schemas, domain packages, guard policies.' },
   { command: 'update', description: 'Check for CLI updates and optionally install the latest version.' },
 ] as const;
@@ -4,6 +4,7 @@ export { initCommand } from './init.js';
 export { doctorCommand } from './doctor.js';
 export { configCommand } from './config.js';
 export { cleanupCommand } from './cleanup.js';
+export { gcCommand } from './gc.js';
 export { callCommand } from './call.js';
 export { abilityCommand } from './ability.js';
 export { listCommand } from './list.js';
@@ -20,7 +21,23 @@ export { reviewCommand } from './review.js';
 export { shipCommand, architectCommand, auditCommand, qaCommand, releaseCommand, WORKFLOW_COMMAND_DEFINITIONS, getWorkflowCommandDefinition, } from './workflows.js';
 export { helpCommand, WORKFLOW_FIRST_QUICKSTART } from './help.js';
 export { historyCommand } from './history.js';
+export { applyCommand } from './apply.js';
+export { askCommand } from './ask.js';
+export { syncCommand } from './sync.js';
+export { backupCommand } from './backup.js';
+export { memoryCommand } from './memory.js';
+export { snapshotCommand } from './snapshot.js';
+export { analyzeCommand } from './analyze.js';
+export { checkCommand } from './check.js';
+export { webhookCommand } from './webhook.js';
+export { migrateCommand } from './migrate.js';
+export { reportBugCommand } from './report-bug.js';
+export { telemetryCommand } from './telemetry.js';
+export { benchCommand } from './bench.js';
+export { experimentCommand } from './experiment.js';
+export { handoffCommand } from './handoff.js';
 export { iterateCommand } from './iterate.js';
 export { monitorCommand } from './monitor.js';
 export { scaffoldCommand } from './scaffold.js';
+export { todosCommand } from './todos.js';
 export { updateCommand } from './update.js';
@@ -1,15 +1,30 @@
-import { createMcpServerSurface, createMcpStdioServer } from '@defai.digital/mcp-server';
-import { failure, success, usageError } from '../utils/formatters.js';
+import { createMcpServerSurface, createMcpStdioServer, startMcpHttpServer } from '@defai.digital/mcp-server';
+import { writeMcpToolTuning } from '@defai.digital/shared-runtime';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
 import { parseOptionalJsonInput } from '../utils/validation.js';
+const MCP_SERVE_USAGE = 'ax mcp serve [--client <profile>] [--http] [--port <n>] [--host <host>] [--token <token>] [--allow-origin <origin>]';
+const MCP_USAGE_REPORT_USAGE = 'ax mcp usage [--tool <name>] [--client <profile>] [--since <iso>] [--until <iso>] [--tune]';
+const DEFAULT_MCP_HTTP_PORT = 8788;
 export async function mcpCommand(args, options) {
     const subcommand = args[0] ?? 'tools';
     const basePath = options.outputDir ?? process.cwd();
     const surface = createMcpServerSurface({ basePath });
     switch (subcommand) {
         case 'tools': {
-            const tools = surface.listToolDefinitions();
+            // `--client <profile>` shows the tools as that kind of client sees them.
+            let client;
+            if (args[1] !== undefined) {
+                if (args[1] !== '--client' || args[2] === undefined) {
+                    return usageError('ax mcp tools [--client <profile>]');
+                }
+                client = findClientProfile(surface.listClientProfiles(), args[2]);
+                if (client === undefined) {
+                    return failure(unknownClientMessage(surface.listClientProfiles(), args[2]));
+                }
+            }
+            const tools = surface.listToolDefinitions(client);
             const lines = [
-                'Available MCP tools:',
+                client === undefined ? 'Available MCP tools:' : `MCP tools for ${client.name} clients:`,
                 ...tools.map((tool) => `- ${tool.name}: ${tool.description}`),
             ];
             return success(lines.join('\n'), tools);
@@ -89,9 +104,139 @@ export async function mcpCommand(args, options) {
             ].join('\n'), prompt);
         }
         case 'serve': {
-            const server = createMcpStdioServer({ basePath });
-            await server.serve();
-            return success('MCP stdio server closed.');
+            let http = false;
+            let port = DEFAULT_MCP_HTTP_PORT;
+            let host;
+            const tokens = (process.env.AX_MCP_TOKEN ?? '').split(',').map((token) => token.trim()).filter((token) => token.length > 0);
+            const allowedOrigins = [];
+            let client;
+            for (let index = 1; index < args.length; index += 1) {
+                const token = args[index];
+                if (token === '--http') {
+                    http = true;
+                    continue;
+                }
+                const value = args[index + 1];
+                if (value === undefined || value.startsWith('--')) {
+                    return usageError(MCP_SERVE_USAGE);
+                }
+                if (token === '--client') {
+                    client = value;
+                    index += 1;
+                    continue;
+                }
+                if (token === '--port') {
+                    port = Number.parseInt(value, 10);
+                    if (!Number.isInteger(port) || port <= 0) {
+                        return usageError(MCP_SERVE_USAGE);
+                    }
+                }
+                else if (token === '--host') {
+                    host = value;
+                }
+                else if (token === '--token') {
+                    tokens.push(value);
+                }
+                else if (token === '--allow-origin') {
+                    allowedOrigins.push(value);
+                }
+                else {
+                    return usageError(MCP_SERVE_USAGE);
+                }
+                http = true;
+                index += 1;
+            }
+            if (http && tokens.length === 0) {
+                return failure('Set AX_MCP_TOKEN or pass --token; MCP over HTTP does not accept unauthenticated clients.');
+            }
+            if (client !== undefined && findClientProfile(surface.listClientProfiles(), client) === undefined) {
+                return failure(unknownClientMessage(surface.listClientProfiles(), client));
+            }
+            // Checked up front so a broken extractor plugin is reported at startup; stdout carries the JSON-RPC stream.
+            const plugins = await createRuntime(options).loadExtractorPlugins({ basePath });
+            for (const plugin of plugins.errors) {
+                process.stderr.write(`Skipping extractor plugin ${plugin.sourcePath}: ${plugin.error}\n`);
+            }
+            for (const error of surface.listCompositeToolErrors()) {
+                process.stderr.write(`Skipping composite tool: ${error}\n`);
+            }
+            for (const error of surface.listClientProfileErrors()) {
+                process.stderr.write(`Skipping MCP client profile: ${error}\n`);
+            }
+            if (!http) {
+                const server = createMcpStdioServer({ basePath, client });
+                await server.serve();
+                return success('MCP stdio server closed.');
+            }
+            const server = await startMcpHttpServer({ basePath, tokens, port, host, allowedOrigins, client });
+            const origin = `http://${host ?? '127.0.0.1'}:${server.port}`;
+            console.log(`\nMCP server listening on ${origin}/mcp (Streamable HTTP) and ${origin}/sse (HTTP+SSE).`);
+            console.log('Clients authenticate with "Authorization: Bearer <token>". Press Ctrl+C to stop.\n');
+            const shutdown = () => {
+                void server.close().then(() => process.exit(0));
+            };
+            process.on('SIGINT', shutdown);
+            process.on('SIGTERM', shutdown);
+            await new Promise(() => { /* runs until interrupted */ });
+            return { success: true, exitCode: 0, message: undefined, data: null };
+        }
+        case 'usage': {
+            const filter = {};
+            let tune = false;
+            for (let index = 1; index < args.length; index += 1) {
+                const token = args[index];
+                if (token === '--tune') {
+                    tune = true;
+                    continue;
+                }
+                const value = args[index + 1];
+                if (value === undefined || value.startsWith('--')) {
+                    return usageError(MCP_USAGE_REPORT_USAGE);
+                }
+                if (token === '--tool') {
+                    filter.tool = value;
+                }
+                else if (token === '--client') {
+                    filter.client = value;
+                }
+                else if (token === '--since') {
+                    filter.since = value;
+                }
+                else if (token === '--until') {
+                    filter.until = value;
+                }
+                else {
+                    return usageError(MCP_USAGE_REPORT_USAGE);
+                }
+                index += 1;
+            }
+            const report = await surface.toolUsageReport(filter);
+            if (tune) {
+                const path = await writeMcpToolTuning(basePath, report.suggestions);
+                return success([
+                    `Wrote ${report.suggestions.length} tuned tool descriptions to ${path}; MCP servers started from now on serve them.`,
+                    ...report.suggestions.map((suggestion) => `- ${suggestion.tool}: ${suggestion.reason}`),
+                ].join('\n'), report);
+            }
+            if (report.calls === 0) {
+                return success(report.enabled
+                    ? 'No MCP tool calls match.'
+                    : 'No MCP tool calls are logged. Set "mcp": { "usage": true } in .automatosx/config.json to start.', report);
+            }
+            return success([
+                `${report.calls} MCP tool calls:`,
+                ...report.tools.flatMap((tool) => [
+                    `- ${tool.tool}: ${tool.calls} calls, ${tool.invalid} invalid, ${tool.failed} failed, ${tool.averageDurationMs}ms average (last ${tool.lastAt})`,
+                    ...(tool.arguments.length > 0 ? [`  arguments: ${tool.arguments.map((entry) => `${entry.name} (${entry.count})`).join(', ')}`] : []),
+                    ...tool.validationErrors.map((entry) => `  invalid: ${entry.name} (${entry.count})`),
+                ]),
+                ...(report.unknownTools.length > 0 ? ['', 'Unknown tools called:', ...report.unknownTools.map((entry) => `- ${entry.name} (${entry.count})`)] : []),
+                ...(report.unused.length > 0 ? ['', `${report.unused.length} tools were never called.`] : []),
+                ...(report.suggestions.length > 0
+                    ? ['', 'Suggested descriptions (apply with --tune):', ...report.suggestions.flatMap((suggestion) => [`- ${suggestion.tool}: ${suggestion.reason}`, `  ${suggestion.description}`])]
+                    : []),
+                ...(report.enabled ? [] : ['', 'mcp.usage is off, so nothing new is being logged.']),
+            ].join('\n'), report);
         }
         case 'call':
         case 'invoke': {
@@ -110,6 +255,12 @@ export async function mcpCommand(args, options) {
             return success(`MCP tool ${toolName} completed successfully.`, result.data);
         }
         default:
-            return usageError('ax mcp [tools|describe|resources|read|prompts|prompt|call|serve]');
+            return usageError('ax mcp [tools|describe|resources|read|prompts|prompt|call|serve|usage]');
     }
 }
+function findClientProfile(profiles, name) {
+    return profiles.find((profile) => profile.name === name);
+}
+function unknownClientMessage(profiles, name) {
+    return `Unknown MCP client profile: ${name}. Known profiles: ${profiles.map((profile) => profile.name).join(', ')}`;
+}
@@ -0,0 +1,616 @@
+import { CONVERSATION_SOURCES, isConversationSource, migrateMemorySchema, type MemoryAuditRecord } from '@defai.digital/shared-runtime';
+import type { CLIOptions, CommandResult } from '../types.js';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+
+const MEMORY_USAGE = 'ax memory search <query> [--namespace <ns>] [--since <iso>] [--until <iso>] [--page-size <n>] [--cursor <token>] | ax memory export [--output <file>] [--namespace <ns>] | ax memory import <file> [--namespace <ns>] [--overwrite] [--dry-run] | ax memory import-history [--source claude-code|gemini-cli] [--namespace <ns>] [--since <iso>] [--dry-run] | ax memory prune | ax memory fetch-model | ax memory dedup [--namespace <ns>] [--threshold <0-1>] [--dry-run] | ax memory compact [--namespace <ns>] [--min-age-days <n>] [--min-cluster-size <n>] [--threshold <0-1>] [--dry-run] | ax memory snapshot | ax memory snapshots | ax memory restore --snapshot <id|latest> [--dry-run] | ax memory feedback <key> --helpful|--unhelpful [--namespace <ns>] [--semantic] [--query <text>] | ax memory graph <path|symbol|session|agent|key> [--depth <1-4>] [--limit <n>] [--namespace <ns>] | ax memory index-docs [paths...] [--namespace <ns>] | ax memory audit [--actor <id|kind:id>] [--action read|write|delete] [--namespace <ns>] [--key <key>] [--since <iso>] [--until <iso>] [--limit <n>] | ax memory redactions [--rule <name>] [--namespace <ns>] [--since <iso>] [--until <iso>] [--limit <n>] | ax memory as-of <iso-time> [--namespace <ns>] [--key <key>] | ax memory migrate [--to <version>] [--dry-run]';
+
+interface ParsedMemoryArgs {
+  positional: string[];
+  outputPath?: string;
+  namespace?: string;
+  threshold?: number;
+  minAgeDays?: number;
+  minClusterSize?: number;
+  depth?: number;
+  limit?: number;
+  to?: number;
+  pageSize?: number;
+  cursor?: string;
+  snapshot?: string;
+  query?: string;
+  actor?: string;
+  action?: string;
+  rule?: string;
+  source?: string;
+  key?: string;
+  since?: string;
+  until?: string;
+  helpful?: boolean;
+  semantic: boolean;
+  overwrite: boolean;
+  error?: string;
+}
+
+export async function memoryCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  const subcommand = args[0];
+  const basePath = options.outputDir ?? process.cwd();
+
+  if (subcommand === 'help') {
+    return success([
+      'AX Memory',
+      '',
+      'Usage:',
+      `  ${MEMORY_USAGE}`,
+      '',
+      'Search pages through every key-value entry matching the query, best first,',
+      '--page-size (default 50, at most 500) at a time. Each page ends with a --cursor',
+      'for the next one; pass it with the same query and filters.',
+      '',
+      'Exports key-value and semantic memory to a versioned JSONL bundle (a header line,',
+      'then one entry per line, with semantic embeddings and metadata) for moving project',
+      'knowledge between machines or archiving it. Import keeps local entries that are',
+      'newer than the bundle unless --overwrite is given; --dry-run only counts changes.',
+      '',
+      'Import-history reads this workspace\'s sessions from Claude Code',
+      '(~/.claude/projects) and Gemini CLI (~/.gemini/tmp) and stores each prompt with',
+      'the answer to it as a semantic entry in the conversations namespace (or',
+      '--namespace), tagged with its source and carrying the session, message, time,',
+      'and history file it came from. Tool calls, tool output, and slash commands are',
+      'left out, secrets are redacted as for any memory write, and exchanges imported',
+      'before are skipped, so it can be rerun. --source limits it to one CLI and',
+      '--since to newer exchanges.',
+      '',
+      'Prune removes expired entries and applies memory.retention from config',
+      '(maxAgeDays, maxEntries, maxBytes); it also runs after memory writes, at most',
+      'once per pruneIntervalMinutes (default 60). memory.quotas caps each agent\'s',
+      'entries (maxEntries, maxBytes, eviction oldest-write or importance, overridden under',
+      'agents.<id>); prune holds every agent to its quota and reports the evictions.',
+      '',
+      'Fetch-model downloads the semantic.embeddings model (Xenova/all-MiniLM-L6-v2',
+      'unless another is configured) into .automatosx/models. Embeddings never',
+      'download on their own unless semantic.embeddings.offline is false.',
+      '',
+      'Dedup merges duplicate entries within each namespace: key-value entries with',
+      'identical values, and semantic entries with the same normalized content or',
+      'embeddings at least --threshold similar (cosine, default 0.95). The newest',
+      'entry is kept with the union of tags and a mergedFrom list in its metadata;',
+      'removed entries are logged in full to .automatosx/runtime/memory-dedup.jsonl.',
+      '',
+      'Compact summarizes clusters of old, related semantic entries into one entry each:',
+      'entries not updated for --min-age-days (default 30) whose term vectors are at',
+      'least --threshold similar (default 0.5) are grouped, and groups of at least',
+      '--min-cluster-size (default 3) are replaced by a summary of their opening',
+      'sentences. Originals go to .automatosx/memory-archive first. With',
+      'memory.compaction in config (same settings, plus intervalMinutes, default 1440)',
+      'it also runs after memory writes once the interval has passed.',
+      '',
+      'With memory.encryption enabled, memory values and semantic content are stored',
+      'AES-256-GCM encrypted and exports are encrypted bundles. The key comes from',
+      'AX_MEMORY_KEY (or memory.encryption.keyEnv), else the OS keychain (service',
+      '"automatosx", account "memory"); importing an encrypted bundle needs the same key.',
+      '',
+      'Snapshot writes all memory to .automatosx/memory-snapshots and keeps the newest',
+      'memory.snapshots.keep (default 7); with memory.snapshots.schedule set to daily or',
+      'weekly one is also taken after memory writes once the newest is that old.',
+      'Restore --snapshot <id|latest> replaces memory with a snapshot, deleting entries',
+      'it does not contain, after first snapshotting the current memory.',
+      '',
+      'Feedback marks a key-value entry (or with --semantic, a semantic one) helpful or',
+      'unhelpful. Later searches in the same memory scope rank it up or down, more so',
+      'for queries sharing words with --query; votes are kept in',
+      '.automatosx/runtime/memory-feedback.json.',
+      '',
+      'Graph shows what memory knows about a file, directory, symbol, session, agent,',
+      'or memory key: entries about or mentioning it, the sessions they came from, the',
+      'agents that wrote them, and the symbols of indexed files, within --depth hops',
+      '(default 2), nearest and newest first.',
+      '',
+      'Index-docs stores the doc comment of every documented Go declaration under the',
+      'given paths (default: the whole workspace) as a semantic entry keyed',
+      '<path>#<symbol> in the code-docs namespace (or --namespace), with the symbol\'s',
+      'signature, so semantic search finds code by what its docs say. Rerunning it',
+      'removes entries for symbols whose doc comment or declaration is gone.',
+      '',
+      'Audit lists memory reads, writes, and deletes, newest first, with who made them',
+      '(agent, tool, or user), when, and the key or query. They are logged to',
+      '.automatosx/runtime/memory-audit.jsonl while memory.audit is on in config',
+      '(true, or { "enabled": true, "maxFileBytes": <n> }; the log rolls over past 10 MB).',
+      '',
+      'Secrets are redacted before memory, traces, or session recordings are written',
+      '(imports and sync pulls included) and before audit queries are logged:',
+      'private keys, cloud and API tokens, JWTs, bearer tokens, passwords in',
+      'URLs and assignments, credential-named fields, and other long random-looking',
+      'tokens become [REDACTED:<rule>]. Redactions lists what was found (never the',
+      'secret), newest first, with totals per rule, from',
+      '.automatosx/runtime/redactions.jsonl. memory.redaction in config adds rules',
+      '({ "patterns": { "<rule>": "<regex>" } }), tunes or turns off entropy detection',
+      '({ "entropy": false }), or turns redaction off (false).',
+      '',
+      'As-of shows memory exactly as it stood at a past time, including entries since',
+      'changed or deleted, to see what context an agent had during a session. The',
+      'sqlite and postgres backends keep every version of every entry for this;',
+      'memory.retention.historyDays drops versions that long after they were replaced.',
+      '',
+      'Migrate brings the memory database schema to the latest version, or with --to',
+      'rolls it back to an earlier one for downgrading ax; --dry-run lists the steps',
+      'without applying them. Memory is also migrated forward whenever it is opened.',
+      'A SQLite database is first copied to <db>.v<from>.bak, and a schema newer than',
+      'this version of ax understands is refused rather than read.',
+    ].join('\n'));
+  }
+
+  const parsed = parseMemoryArgs(args.slice(1));
+  if (parsed.error !== undefined) {
+    return usageError(MEMORY_USAGE);
+  }
+
+  // Before the runtime is created, since opening its store migrates the schema forward.
+  if (subcommand === 'migrate') {
+    if (parsed.positional.length > 0 || hasFlags({ ...parsed, to: undefined })) {
+      return usageError(MEMORY_USAGE);
+    }
+    try {
+      const result = await migrateMemorySchema(basePath, { to: parsed.to, dryRun: options.dryRun === true });
+      if (result.steps.length === 0) {
+        return success(`The ${result.backend} memory schema is at v${result.from}; nothing to migrate.`, result);
+      }
+      return success([
+        `${result.dryRun ? 'Would migrate' : 'Migrated'} the ${result.backend} memory schema from v${result.from} to v${result.to}:`,
+        ...result.steps.map((step) => `- ${result.direction === 'down' ? 'roll back' : 'apply'} v${step.version} ${step.name}`),
+        ...(result.backupPath !== undefined ? [`The database as it was before is saved at ${result.backupPath}.`] : []),
+      ].join('\n'), result);
+    } catch (error) {
+      return failure(error instanceof Error ? error.message : String(error));
+    }
+  }
+
+  const runtime = createRuntime(options);
+  switch (subcommand) {
+    case 'search': {
+      const query = parsed.positional[0];
+      if (query === undefined || parsed.positional.length > 1
+        || hasFlags({ ...parsed, namespace: undefined, since: undefined, until: undefined, pageSize: undefined, cursor: undefined })) {
+        return usageError(MEMORY_USAGE);
+      }
+      try {
+        const page = await runtime.searchMemoryPage(query, parsed.namespace, { since: parsed.since, until: parsed.until }, { pageSize: parsed.pageSize, cursor: parsed.cursor });
+        if (page.total === 0) {
+          return success(`No memory entries match "${query}".`, page);
+        }
+        return success([
+          `Entries ${page.offset + 1}-${page.offset + page.entries.length} of ${page.total} matching "${query}":`,
+          ...page.entries.map((entry) => `- ${entry.namespace ?? 'default'}/${entry.key} = ${JSON.stringify(entry.value)}`),
+          ...(page.nextCursor !== undefined ? ['', `Next page: --cursor ${page.nextCursor}`] : []),
+        ].join('\n'), page);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'export': {
+      if (parsed.positional.length > 0 || parsed.snapshot !== undefined || parsed.overwrite) {
+        return usageError(MEMORY_USAGE);
+      }
+      const result = await runtime.exportMemory({ outputPath: parsed.outputPath, namespace: parsed.namespace, basePath });
+      return success(
+        `Exported ${result.counts.memory} memory and ${result.counts.semantic} semantic entries to ${result.outputPath} (schema v${result.schemaVersion}, ${result.bytes} bytes${result.encrypted ? ', encrypted' : ''}).`,
+        result,
+      );
+    }
+    case 'import': {
+      const inputPath = parsed.positional[0];
+      if (inputPath === undefined || parsed.positional.length > 1 || parsed.outputPath !== undefined || parsed.snapshot !== undefined) {
+        return usageError(MEMORY_USAGE);
+      }
+      try {
+        const result = await runtime.importMemory({
+          inputPath,
+          namespace: parsed.namespace,
+          overwrite: parsed.overwrite,
+          dryRun: options.dryRun === true,
+        });
+        return success([
+          `${result.dryRun ? 'Would import' : 'Imported'} ${result.imported.memory} memory and ${result.imported.semantic} semantic entries from ${result.machine} (exported ${result.exportedAt}).`,
+          ...(result.skipped.memory + result.skipped.semantic > 0
+            ? [`Kept ${result.skipped.memory + result.skipped.semantic} local entries that are as new or newer; pass --overwrite to replace them.`]
+            : []),
+        ].join('\n'), result);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'import-history': {
+      if (parsed.positional.length > 0 || hasFlags({ ...parsed, source: undefined, namespace: undefined, since: undefined })) {
+        return usageError(MEMORY_USAGE);
+      }
+      if (parsed.source !== undefined && !isConversationSource(parsed.source)) {
+        return failure(`Unknown --source ${parsed.source}; use ${CONVERSATION_SOURCES.join(' or ')}.`);
+      }
+      try {
+        const result = await runtime.importConversations({
+          sources: parsed.source !== undefined && isConversationSource(parsed.source) ? [parsed.source] : undefined,
+          namespace: parsed.namespace,
+          since: parsed.since,
+          dryRun: options.dryRun === true,
+        });
+        if (result.sources.every((source) => source.exchanges === 0)) {
+          return success(`No ${parsed.source ?? 'Claude Code or Gemini CLI'} history found for this workspace.`, result);
+        }
+        return success([
+          `${result.dryRun ? 'Would import' : 'Imported'} ${result.imported} exchanges into ${result.namespace}${result.skipped > 0 ? `; ${result.skipped} were imported before` : ''}.`,
+          ...result.sources.map((source) => `- ${source.source}: ${source.exchanges} exchanges in ${source.sessions} sessions, ${source.imported} new`),
+        ].join('\n'), result);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'prune': {
+      if (parsed.positional.length > 0 || hasFlags(parsed)) {
+        return usageError(MEMORY_USAGE);
+      }
+      const result = await runtime.pruneMemory({ basePath });
+      const removed = result.expired + result.aged + result.evicted;
+      return success([
+        `Pruned ${removed} memory entries (${result.expired} expired, ${result.aged} past max age, ${result.evicted} over limits); ${result.remaining.entries} remain (${result.remaining.bytes} bytes).`,
+        ...(result.policy.maxAgeMs === undefined && result.policy.maxEntries === undefined && result.policy.maxBytes === undefined
+          ? ['No memory.retention limits are configured, so only expired entries and old history were removed.']
+          : []),
+        ...(result.quotas ?? []).map((quota) => `- ${quota.agentId}: evicted ${quota.evicted.length} over its quota; ${quota.remaining.entries} remain (${quota.remaining.bytes} bytes).`),
+      ].join('\n'), result);
+    }
+    case 'fetch-model': {
+      if (parsed.positional.length > 0 || hasFlags(parsed)) {
+        return usageError(MEMORY_USAGE);
+      }
+      try {
+        const fetched = await runtime.fetchEmbeddingModel({ basePath });
+        return success(`Embedding model ${fetched.model} is ready in ${fetched.modelsDir}.`, fetched);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'dedup': {
+      if (parsed.positional.length > 0 || parsed.outputPath !== undefined || parsed.snapshot !== undefined || parsed.overwrite) {
+        return usageError(MEMORY_USAGE);
+      }
+      try {
+        const result = await runtime.dedupeMemory({
+          namespace: parsed.namespace,
+          threshold: parsed.threshold,
+          dryRun: options.dryRun === true,
+          basePath,
+        });
+        if (result.groups.length === 0) {
+          return success('No duplicate memory entries found.', result);
+        }
+        const duplicates = result.groups.reduce((sum, group) => sum + group.duplicates.length, 0);
+        return success([
+          result.dryRun
+            ? `Would merge ${duplicates} duplicate entries into ${result.groups.length} kept entries.`
+            : `Merged ${result.removed.memory} memory and ${result.removed.semantic} semantic duplicates into ${result.groups.length} kept entries; removed entries are logged in ${result.logPath}.`,
+          ...result.groups.map((group) => `- ${group.type} ${group.namespace !== undefined ? `${group.namespace}/` : ''}${group.kept} <- ${group.duplicates
+            .map((duplicate) => `${duplicate.key} (${duplicate.reason === 'hash' ? 'identical' : `similarity ${duplicate.similarity}`})`)
+            .join(', ')}`),
+        ].join('\n'), result);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'compact': {
+      if (parsed.positional.length > 0 || parsed.outputPath !== undefined || parsed.snapshot !== undefined || parsed.overwrite) {
+        return usageError(MEMORY_USAGE);
+      }
+      try {
+        const result = await runtime.compactMemory({
+          namespace: parsed.namespace,
+          minAgeDays: parsed.minAgeDays,
+          minClusterSize: parsed.minClusterSize,
+          similarity: parsed.threshold,
+          dryRun: options.dryRun === true,
+          basePath,
+        });
+        if (result.clusters.length === 0) {
+          return success(`No clusters of ${result.minClusterSize} or more related entries older than ${result.minAgeDays} days to compact.`, result);
+        }
+        const members = result.clusters.reduce((sum, cluster) => sum + cluster.members.length, 0);
+        return success([
+          result.dryRun
+            ? `Would compact ${members} entries into ${result.clusters.length} summaries.`
+            : `Compacted ${result.compacted} entries into ${result.clusters.length} summaries; the originals are archived in ${result.archivePath}.`,
+          ...result.clusters.map((cluster) => `- ${cluster.namespace !== undefined ? `${cluster.namespace}/` : ''}${cluster.key} <- ${cluster.members.join(', ')}`),
+        ].join('\n'), result);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'snapshot': {
+      if (parsed.positional.length > 0 || hasFlags(parsed)) {
+        return usageError(MEMORY_USAGE);
+      }
+      const result = await runtime.snapshotMemory({ basePath });
+      return success([
+        `Snapshot ${result.snapshotId}: ${result.counts.memory} memory and ${result.counts.semantic} semantic entries written to ${result.path} (${result.bytes} bytes${result.encrypted ? ', encrypted' : ''}).`,
+        ...(result.removed.length > 0 ? [`Removed ${result.removed.length} older snapshots: ${result.removed.join(', ')}.`] : []),
+      ].join('\n'), result);
+    }
+    case 'snapshots': {
+      if (parsed.positional.length > 0 || hasFlags(parsed)) {
+        return usageError(MEMORY_USAGE);
+      }
+      const snapshots = await runtime.listMemorySnapshots({ basePath });
+      if (snapshots.length === 0) {
+        return success('No memory snapshots found.', snapshots);
+      }
+      return success(
+        snapshots.map((snapshot) => `- ${snapshot.snapshotId}  ${snapshot.createdAt}  ${snapshot.bytes} bytes${snapshot.encrypted ? ', encrypted' : ''}`).join('\n'),
+        snapshots,
+      );
+    }
+    case 'restore': {
+      if (parsed.snapshot === undefined || parsed.positional.length > 0 || parsed.outputPath !== undefined || parsed.namespace !== undefined || parsed.threshold !== undefined || parsed.overwrite) {
+        return usageError(MEMORY_USAGE);
+      }
+      try {
+        const result = await runtime.restoreMemory({ snapshotId: parsed.snapshot, dryRun: options.dryRun === true, basePath });
+        return success([
+          result.dryRun
+            ? `Would restore ${result.restored.memory} memory and ${result.restored.semantic} semantic entries from ${result.snapshotId} (${result.createdAt}) and remove ${result.removed.memory + result.removed.semantic} entries it does not contain.`
+            : `Restored ${result.restored.memory} memory and ${result.restored.semantic} semantic entries from ${result.snapshotId} (${result.createdAt}); removed ${result.removed.memory + result.removed.semantic} entries it did not contain.`,
+          ...(result.safetySnapshotId !== undefined ? [`Memory before the restore was saved as ${result.safetySnapshotId}.`] : []),
+        ].join('\n'), result);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'feedback': {
+      const key = parsed.positional[0];
+      if (key === undefined || parsed.positional.length > 1 || parsed.helpful === undefined || parsed.outputPath !== undefined || parsed.threshold !== undefined || parsed.snapshot !== undefined || parsed.overwrite) {
+        return usageError(MEMORY_USAGE);
+      }
+      try {
+        const result = await runtime.recordMemoryFeedback({
+          key,
+          namespace: parsed.namespace,
+          kind: parsed.semantic ? 'semantic' : 'memory',
+          helpful: parsed.helpful,
+          query: parsed.query,
+        });
+        return success(
+          `Marked ${result.namespace !== undefined ? `${result.namespace}/` : ''}${result.key} ${parsed.helpful ? 'helpful' : 'unhelpful'} (${result.helpful} helpful, ${result.unhelpful} unhelpful; score x${result.boost}).`,
+          result,
+        );
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'graph': {
+      const query = parsed.positional[0];
+      if (query === undefined || parsed.positional.length > 1 || parsed.outputPath !== undefined || parsed.threshold !== undefined || parsed.snapshot !== undefined || parsed.overwrite) {
+        return usageError(MEMORY_USAGE);
+      }
+      try {
+        const result = await runtime.queryKnowledgeGraph({ query, depth: parsed.depth, limit: parsed.limit, namespace: parsed.namespace });
+        if (result.start.length === 0) {
+          return success(`Nothing in memory or sessions links to ${query}.`, result);
+        }
+        return success([
+          `${result.nodes.length - result.start.length} nodes linked to ${query} within ${result.depth} hops${result.truncated ? ` (first ${result.nodes.length} shown)` : ''}:`,
+          ...result.nodes.map((node) => `${'  '.repeat(node.distance)}- ${node.kind} ${node.label}${node.updatedAt !== undefined ? ` (${node.updatedAt.slice(0, 10)})` : ''}${node.summary !== undefined ? `: ${node.summary}` : ''}`),
+          '',
+          'Links:',
+          ...result.edges.map((edge) => `- ${edge.from} ${edge.relation} ${edge.to}`),
+        ].join('\n'), result);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'index-docs': {
+      if (hasFlags({ ...parsed, namespace: undefined })) {
+        return usageError(MEMORY_USAGE);
+      }
+      try {
+        const result = await runtime.indexDocComments({
+          paths: parsed.positional.length > 0 ? parsed.positional : undefined,
+          namespace: parsed.namespace,
+          basePath,
+        });
+        if (result.indexed.length === 0 && result.removed.length === 0) {
+          return success(`No documented declarations in ${result.scannedFiles} files.`, result);
+        }
+        return success([
+          `Indexed ${result.indexed.length} doc comments from ${result.scannedFiles} files into ${result.namespace}${result.removed.length > 0 ? `; removed ${result.removed.length} stale entries` : ''}.`,
+          ...result.indexed.map((entry) => `- ${entry.symbol} (${entry.kind}) ${entry.path}:${entry.line}`),
+        ].join('\n'), result);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'audit': {
+      if (parsed.positional.length > 0 || parsed.outputPath !== undefined || parsed.threshold !== undefined || parsed.snapshot !== undefined || parsed.overwrite) {
+        return usageError(MEMORY_USAGE);
+      }
+      if (parsed.action !== undefined && parsed.action !== 'read' && parsed.action !== 'write' && parsed.action !== 'delete') {
+        return usageError(MEMORY_USAGE);
+      }
+      const result = await runtime.queryMemoryAudit({
+        actor: parsed.actor,
+        action: parsed.action,
+        namespace: parsed.namespace,
+        key: parsed.key,
+        since: parsed.since,
+        until: parsed.until,
+        limit: parsed.limit ?? options.limit,
+      });
+      if (result.matched === 0) {
+        return success(result.enabled
+          ? 'No memory accesses match.'
+          : 'No memory accesses are logged. Set "memory": { "audit": true } in .automatosx/config.json to start.', result);
+      }
+      return success([
+        `${result.matched} memory accesses${result.records.length < result.matched ? ` (newest ${result.records.length} shown)` : ''}:`,
+        ...result.records.map((record) => `- ${record.at} ${record.actor.kind}:${record.actor.id} ${record.action} ${record.operation} ${auditTarget(record)} (${record.count})`),
+        '',
+        'By actor:',
+        ...result.actors.map((actor) => `- ${actor.actor}: ${actor.reads} reads, ${actor.writes} writes, ${actor.deletes} deletes (last ${actor.lastAt})`),
+        ...(result.enabled ? [] : ['', 'memory.audit is off, so nothing new is being logged.']),
+      ].join('\n'), result);
+    }
+    case 'redactions': {
+      if (parsed.positional.length > 0 || hasFlags({ ...parsed, rule: undefined, namespace: undefined, since: undefined, until: undefined, limit: undefined })) {
+        return usageError(MEMORY_USAGE);
+      }
+      const result = await runtime.queryMemoryRedactions({
+        rule: parsed.rule,
+        namespace: parsed.namespace,
+        since: parsed.since,
+        until: parsed.until,
+        limit: parsed.limit ?? options.limit,
+      });
+      if (result.matched === 0) {
+        return success(result.enabled
+          ? 'No secrets have been redacted.'
+          : 'Secret redaction is off. Remove "redaction": false from memory in .automatosx/config.json to turn it on.', result);
+      }
+      return success([
+        `${result.matched} writes had secrets redacted${result.records.length < result.matched ? ` (newest ${result.records.length} shown)` : ''}:`,
+        ...result.records.map((record) => `- ${record.at} ${record.target} ${record.key !== undefined ? (record.namespace !== undefined ? `${record.namespace}/${record.key}` : record.key) : record.namespace ?? '*'}: ${record.findings.map((finding) => `${finding.rule} x${finding.count}`).join(', ')}`),
+        '',
+        'By rule:',
+        ...result.rules.map((rule) => `- ${rule.rule}: ${rule.count}`),
+        ...(result.enabled ? [] : ['', 'memory.redaction is off, so new writes are stored as given.']),
+      ].join('\n'), result);
+    }
+    case 'as-of': {
+      const asOf = parsed.positional[0];
+      if (asOf === undefined || parsed.positional.length > 1 || hasFlags({ ...parsed, namespace: undefined, key: undefined })) {
+        return usageError(MEMORY_USAGE);
+      }
+      try {
+        const result = await runtime.memoryAsOf(asOf, { namespace: parsed.namespace, key: parsed.key });
+        if (result.memory.length === 0 && result.semantic.length === 0) {
+          return success(`No memory entries existed at ${result.asOf}.`, result);
+        }
+        return success([
+          `Memory as of ${result.asOf}:`,
+          ...result.memory.map((entry) => `- ${entry.namespace}/${entry.key} = ${JSON.stringify(entry.value)} (updated ${entry.updatedAt})`),
+          ...result.semantic.map((entry) => `- semantic ${entry.namespace}/${entry.key}: ${entry.content.split('\n')[0]} (updated ${entry.updatedAt})`),
+        ].join('\n'), result);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    default:
+      return usageError(MEMORY_USAGE);
+  }
+}
+
+// What a logged access touched: the entry, else the search query, else the namespace.
+function auditTarget(record: MemoryAuditRecord): string {
+  if (record.key !== undefined) {
+    return record.namespace !== undefined ? `${record.namespace}/${record.key}` : record.key;
+  }
+  return record.query !== undefined ? `"${record.query}"` : record.namespace ?? '*';
+}
+
+function hasFlags(parsed: ParsedMemoryArgs): boolean {
+  return parsed.outputPath !== undefined
+    || parsed.namespace !== undefined
+    || parsed.threshold !== undefined
+    || parsed.minAgeDays !== undefined
+    || parsed.minClusterSize !== undefined
+    || parsed.depth !== undefined
+    || parsed.limit !== undefined
+    || parsed.to !== undefined
+    || parsed.pageSize !== undefined
+    || parsed.cursor !== undefined
+    || parsed.snapshot !== undefined
+    || parsed.query !== undefined
+    || parsed.actor !== undefined
+    || parsed.action !== undefined
+    || parsed.rule !== undefined
+    || parsed.source !== undefined
+    || parsed.key !== undefined
+    || parsed.since !== undefined
+    || parsed.until !== undefined
+    || parsed.helpful !== undefined
+    || parsed.semantic
+    || parsed.overwrite;
+}
+
+function parseMemoryArgs(args: string[]): ParsedMemoryArgs {
+  const parsed: ParsedMemoryArgs = { positional: [], semantic: false, overwrite: false };
+
+  for (let index = 0; index < args.length; index += 1) {
+    const token = args[index] ?? '';
+    const value = args[index + 1];
+    if (token === '--output' || token === '--namespace' || token === '--snapshot' || token === '--query' || token === '--cursor'
+      || token === '--actor' || token === '--action' || token === '--rule' || token === '--source' || token === '--key' || token === '--since' || token === '--until') {
+      if (value === undefined || value.startsWith('--')) {
+        return { ...parsed, error: `Missing value for ${token}.` };
+      }
+      if (token === '--output') {
+        parsed.outputPath = value;
+      } else if (token === '--namespace') {
+        parsed.namespace = value;
+      } else if (token === '--snapshot') {
+        parsed.snapshot = value;
+      } else if (token === '--query') {
+        parsed.query = value;
+      } else if (token === '--cursor') {
+        parsed.cursor = value;
+      } else if (token === '--actor') {
+        parsed.actor = value;
+      } else if (token === '--action') {
+        parsed.action = value;
+      } else if (token === '--rule') {
+        parsed.rule = value;
+      } else if (token === '--source') {
+        parsed.source = value;
+      } else if (token === '--key') {
+        parsed.key = value;
+      } else if (token === '--since') {
+        parsed.since = value;
+      } else {
+        parsed.until = value;
+      }
+      index += 1;
+    } else if (token === '--threshold') {
+      const threshold = Number(value);
+      if (value === undefined || !Number.isFinite(threshold)) {
+        return { ...parsed, error: 'Missing or invalid value for --threshold.' };
+      }
+      parsed.threshold = threshold;
+      index += 1;
+    } else if (token === '--min-age-days' || token === '--min-cluster-size' || token === '--depth' || token === '--limit' || token === '--to' || token === '--page-size') {
+      const number = Number(value);
+      if (value === undefined || !Number.isFinite(number)) {
+        return { ...parsed, error: `Missing or invalid value for ${token}.` };
+      }
+      if (token === '--min-age-days') {
+        parsed.minAgeDays = number;
+      } else if (token === '--min-cluster-size') {
+        parsed.minClusterSize = number;
+      } else if (token === '--depth') {
+        parsed.depth = number;
+      } else if (token === '--to') {
+        parsed.to = number;
+      } else if (token === '--page-size') {
+        parsed.pageSize = number;
+      } else {
+        parsed.limit = number;
+      }
+      index += 1;
+    } else if (token === '--overwrite') {
+      parsed.overwrite = true;
+    } else if (token === '--helpful' || token === '--unhelpful') {
+      parsed.helpful = token === '--helpful';
+    } else if (token === '--semantic') {
+      parsed.semantic = true;
+    } else if (token.startsWith('--')) {
+      return { ...parsed, error: `Unknown memory flag: ${token}.` };
+    } else {
+      parsed.positional.push(token);
+    }
+  }
+
+  return parsed;
+}
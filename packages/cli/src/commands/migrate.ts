@@ -0,0 +1,45 @@
+import type { CLIOptions, CommandResult } from '../types.js';
+import { createRuntime, success, usageError } from '../utils/formatters.js';
+
+const MIGRATE_USAGE = 'ax migrate [--dry-run] [--workflow-dir <dir>]';
+
+export async function migrateCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  if (args[0] === 'help') {
+    return success([
+      'AX Migrate',
+      '',
+      'Usage:',
+      `  ${MIGRATE_USAGE}`,
+      '',
+      'Detects config, workflow, and agent files written by older AutomatosX versions and',
+      'rewrites them to the current schema. Originals are copied to',
+      '.automatosx/backups/migrate-<timestamp>/ first; --dry-run only lists the changes.',
+    ].join('\n'));
+  }
+
+  if (args.length > 0) {
+    return usageError(MIGRATE_USAGE);
+  }
+  const dryRun = options.dryRun === true;
+
+  const result = await createRuntime(options).migrateWorkspace({
+    dryRun,
+    workflowDir: options.workflowDir,
+    basePath: options.outputDir ?? process.cwd(),
+  });
+
+  const lines: string[] = [];
+  if (result.changes.length === 0) {
+    lines.push('Workspace already matches the current schema. Nothing to migrate.');
+  } else {
+    lines.push(dryRun
+      ? `Dry run: ${result.changes.length} file${result.changes.length === 1 ? '' : 's'} would be migrated.`
+      : `Migrated ${result.changes.length} file${result.changes.length === 1 ? '' : 's'}. Backups: ${result.backupDir}`);
+    for (const entry of result.changes) {
+      lines.push(`- [${entry.target}] ${entry.path}`);
+      lines.push(...entry.changes.map((change) => `    ${change}`));
+    }
+  }
+  lines.push(...result.notices.map((notice) => `Note: ${notice}`));
+  return success(lines.join('\n'), result);
+}
@@ -7,12 +7,27 @@
  *   ax monitor                # Auto-select port in 3000-3999
  *   ax monitor --port 8080    # Use specific port
  *   ax monitor --no-open      # Don't auto-open browser
+ *
+ * The memory browser at /memory filters entries by tag, agent, date range,
+ * and path, with facet counts for drilling down; /api/memory returns the same as JSON.
+ * With memory.audit on, the dashboard shows who read and wrote memory, and
+ * /api/memory-audit returns the latest logged accesses. /api/index-progress
+ * reports the latest source indexing pass, parsed on the parser worker pool.
  */
 import { createServer } from 'node:http';
+import { matchesMemoryFilter, normalizeMemoryFilter, } from '@defai.digital/shared-runtime';
 import { createRuntime, failure } from '../utils/formatters.js';
 const DEFAULT_PORT_MIN = 3000;
 const DEFAULT_PORT_MAX = 3999;
 const MAX_PORT_ATTEMPTS = 20;
+const MAX_FINDINGS_SHOWN = 20;
+const MAX_SNAPSHOTS_SHOWN = 20;
+const MAX_TECH_DEBT_SHOWN = 20;
+const MAX_COMPLEX_FUNCTIONS_SHOWN = 10;
+const MAX_MEMORY_ENTRIES_SHOWN = 50;
+const MAX_MEMORY_ACCESSES_SHOWN = 20;
+// Scanning the workspace for markers, complexity, or symbols is too slow to repeat on every auto-refresh.
+const TECH_DEBT_CACHE_MS = 60_000;
 function tryPort(port, handler) {
     return new Promise((resolve) => {
         const server = createServer(handler);
@@ -41,8 +56,279 @@ async function startServer(portMin, portMax, explicitPort, handler) {
     }
     throw new Error(`No available port found in range ${portMin}-${portMax}.`);
 }
+function escapeHtml(value) {
+    return value.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;').replace(/"/g, '&quot;');
+}
+// Review findings carry editor deep links (see the `editor` config key), so
+// each one opens at file:line in the user's editor.
+function renderFindings(traces) {
+    const findings = traces.flatMap((trace) => {
+        const output = trace.output;
+        return trace.workflowId === 'review' && Array.isArray(output?.findings)
+            ? output.findings
+            : [];
+    }).slice(0, MAX_FINDINGS_SHOWN);
+    if (findings.length === 0) {
+        return '';
+    }
+    const items = findings.map((finding) => {
+        const location = escapeHtml(`${finding.file ?? ''}:${finding.line ?? ''}`);
+        const target = finding.link !== undefined ? `<a href="${escapeHtml(finding.link)}">${location}</a>` : location;
+        return `    <li>[${escapeHtml(finding.severity ?? '')}] ${target} ${escapeHtml(finding.ruleId ?? '')}</li>`;
+    });
+    return `  <h2 style="color:#79c0ff;font-size:0.9rem;margin-top:24px;">Review Findings</h2>
+  <ul class="findings">
+${items.join('\n')}
+  </ul>`;
+}
+// Newest first; each entry links to the file listing of that step.
+function renderSnapshots(snapshots) {
+    if (snapshots.length === 0) {
+        return '';
+    }
+    const items = snapshots.slice(-MAX_SNAPSHOTS_SHOWN).reverse().map((snapshot) => {
+        const link = `<a href="/snapshots/${encodeURIComponent(snapshot.snapshotId)}">${escapeHtml(snapshot.snapshotId)}</a>`;
+        return `    <li>${link} ${escapeHtml(snapshot.createdAt)} ${snapshot.fileCount} files ${escapeHtml(snapshot.label ?? '')}</li>`;
+    });
+    return `  <h2 style="color:#79c0ff;font-size:0.9rem;margin-top:24px;">Workspace Snapshots</h2>
+  <ul class="findings">
+${items.join('\n')}
+  </ul>`;
+}
+function renderTechDebt(debt) {
+    if (debt === undefined || debt.items.length === 0) {
+        return '';
+    }
+    const counts = `${debt.counts.todo} TODO &bull; ${debt.counts.fixme} FIXME &bull; ${debt.counts.hack} HACK &bull; ${debt.counts.bug} BUG &bull; ${debt.counts.deprecated} deprecated`;
+    const owners = debt.owners.slice(0, 5).map((entry) => `${escapeHtml(entry.owner)} (${entry.count})`).join(', ');
+    const items = debt.items.slice(0, MAX_TECH_DEBT_SHOWN).map((item) => `    <li>[${item.kind}] ${escapeHtml(`${item.path}:${item.line}`)}${item.owner !== undefined ? ` @${escapeHtml(item.owner)}` : ''} ${escapeHtml(item.text)}</li>`);
+    return `  <h2 style="color:#79c0ff;font-size:0.9rem;margin-top:24px;">Tech Debt</h2>
+  <p class="label">${counts} &bull; owners: ${owners}</p>
+  <ul class="findings">
+${items.join('\n')}
+  </ul>`;
+}
+// Horizontal bars scaled to the largest value; labels and values stay readable without scripts.
+function renderBars(rows) {
+    const max = Math.max(1, ...rows.map((row) => row.value));
+    return rows.map((row) =>
+        `    <div class="bar"><span class="bar-label">${escapeHtml(row.label)}</span><span class="bar-fill" style="width:${Math.round((row.value / max) * 100)}%"></span><span class="bar-value">${row.value}</span></div>`).join('\n');
+}
+function renderCodeMetrics(metrics) {
+    if (metrics === undefined || metrics.summary.functions === 0) {
+        return '';
+    }
+    const { summary } = metrics;
+    const items = metrics.symbols.slice(0, MAX_COMPLEX_FUNCTIONS_SHOWN).map((entry) =>
+        `    <li>${escapeHtml(`${entry.path}:${entry.line}`)} ${escapeHtml(entry.name)} cognitive ${entry.cognitive} &bull; cyclomatic ${entry.cyclomatic} &bull; nesting ${entry.nesting} &bull; ${entry.parameters} params &bull; ${entry.lines} lines</li>`);
+    return `  <h2 style="color:#79c0ff;font-size:0.9rem;margin-top:24px;">Complexity</h2>
+  <p class="label">${summary.functions} functions &bull; average cognitive ${summary.averageCognitive}, cyclomatic ${summary.averageCyclomatic} &bull; ${summary.overCognitive} over cognitive ${summary.thresholds.cognitive}, ${summary.overCyclomatic} over cyclomatic ${summary.thresholds.cyclomatic}</p>
+  <div class="grid">
+    <div class="card">
+      <h2>Cyclomatic Distribution</h2>
+${renderBars(summary.distribution.map((band) => ({ label: band.band, value: band.count })))}
+    </div>
+    <div class="card">
+      <h2>Hotspot Files (cognitive)</h2>
+${renderBars(summary.hotspots.map((file) => ({ label: file.path, value: file.cognitive })))}
+    </div>
+  </div>
+  <ul class="findings">
+${items.join('\n')}
+  </ul>`;
+}
+// What the parsers find in the workspace, so the monitor shows the project's own structure.
+function renderSymbolMap(symbolMap) {
+    if (symbolMap === undefined || symbolMap.declarations === 0) {
+        return '';
+    }
+    return `  <h2 style="color:#79c0ff;font-size:0.9rem;margin-top:24px;">Symbol Map</h2>
+  <p class="label">${symbolMap.declarations} declarations in ${symbolMap.scannedFiles} files &bull; ${symbolMap.exported} exported &bull; ${symbolMap.components} React components</p>
+  <div class="grid">
+    <div class="card">
+      <h2>By Language</h2>
+${renderBars(symbolMap.languages.map((entry) => ({ label: `${entry.language} (${entry.files} files)`, value: entry.declarations })))}
+    </div>
+    <div class="card">
+      <h2>By Kind</h2>
+${renderBars(symbolMap.kinds.map((entry) => ({ label: entry.kind, value: entry.count })))}
+    </div>
+${symbolMap.central !== undefined && symbolMap.central.length > 0 ? `    <div class="card">
+      <h2>Most Central</h2>
+${renderBars(symbolMap.central.map((entry) => ({ label: `${entry.name} (${entry.path}:${entry.line})`, value: entry.score })))}
+    </div>
+` : ''}  </div>`;
+}
+// Files parsed by the latest indexing pass of any ax process in the workspace.
+function renderIndexProgress(progress) {
+    if (progress === undefined) {
+        return '';
+    }
+    const done = progress.parsed + progress.failed;
+    const state = progress.finishedAt !== undefined ? `finished ${escapeHtml(progress.finishedAt)}` : `running since ${escapeHtml(progress.startedAt)}`;
+    return `  <h2 style="color:#79c0ff;font-size:0.9rem;margin-top:24px;">Source Indexing</h2>
+  <p class="label">${state} &bull; ${progress.workers > 0 ? `${progress.workers} parser workers` : 'parsed in process'}</p>
+  <div class="card">
+    <div class="count">${done} / ${progress.total}</div>
+    <div class="label">changed files parsed${progress.failed > 0 ? ` &bull; ${progress.failed} unreadable` : ''} &bull; updated ${escapeHtml(progress.updatedAt)}</div>
+  </div>`;
+}
+// Who touched memory, and how, over the whole log; the list shows the latest accesses.
+function renderMemoryAudit(audit) {
+    if (audit === undefined || audit.matched === 0) {
+        return '';
+    }
+    const items = audit.records.map((record) => {
+        const target = record.key !== undefined
+            ? `${record.namespace !== undefined ? `${record.namespace}/` : ''}${record.key}`
+            : record.query ?? record.namespace ?? '*';
+        return `    <li>${escapeHtml(record.at)} ${escapeHtml(`${record.actor.kind}:${record.actor.id}`)} ${record.action} ${record.operation} ${escapeHtml(target)} (${record.count})</li>`;
+    });
+    return `  <h2 style="color:#79c0ff;font-size:0.9rem;margin-top:24px;">Memory Access</h2>
+  <p class="label">${audit.matched} accesses by ${audit.actors.length} actors${audit.enabled ? '' : ' &bull; memory.audit is off'}</p>
+  <div class="grid">
+    <div class="card">
+      <h2>Reads by Actor</h2>
+${renderBars(audit.actors.map((actor) => ({ label: actor.actor, value: actor.reads })))}
+    </div>
+    <div class="card">
+      <h2>Writes and Deletes by Actor</h2>
+${renderBars(audit.actors.map((actor) => ({ label: actor.actor, value: actor.writes + actor.deletes })))}
+    </div>
+  </div>
+  <ul class="findings">
+${items.join('\n')}
+  </ul>`;
+}
+function buildSnapshotHtml(snapshot) {
+    const base = `/snapshots/${encodeURIComponent(snapshot.snapshotId)}`;
+    const items = snapshot.files.map((file) => `    <li><a href="${base}/${file.path.split('/').map(encodeURIComponent).join('/')}">${escapeHtml(file.path)}</a> ${file.size} bytes</li>`);
+    return `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <title>Snapshot ${escapeHtml(snapshot.snapshotId)}</title>
+  <style>
+    body { font-family: monospace; background: #0d1117; color: #c9d1d9; margin: 0; padding: 20px; }
+    h1 { color: #58a6ff; font-size: 1.2rem; margin-bottom: 4px; }
+    .subtitle { color: #6e7681; font-size: 0.8rem; margin-bottom: 20px; }
+    .findings { font-size: 0.8rem; padding-left: 20px; }
+    .findings a { color: #58a6ff; }
+  </style>
+</head>
+<body>
+  <h1>Snapshot ${escapeHtml(snapshot.snapshotId)}</h1>
+  <p class="subtitle">${escapeHtml(snapshot.createdAt)} &bull; ${escapeHtml(snapshot.label ?? '')} &bull; restore with <code>ax snapshot checkout ${escapeHtml(snapshot.snapshotId)}</code></p>
+  <ul class="findings">
+${items.join('\n')}
+  </ul>
+  <p><a href="/" style="color:#58a6ff;">&larr; Monitor</a></p>
+</body>
+</html>`;
+}
+// `/memory?q=&namespace=&tags=a,b&agent=&since=&until=&path=`; empty parameters are ignored.
+function parseMemoryBrowserQuery(params) {
+    const param = (name) => {
+        const value = params.get(name)?.trim();
+        return value !== undefined && value.length > 0 ? value : undefined;
+    };
+    return {
+        query: param('q') ?? '',
+        namespace: param('namespace'),
+        filter: {
+            tags: param('tags')?.split(',').map((tag) => tag.trim()).filter((tag) => tag.length > 0),
+            agentId: param('agent'),
+            since: param('since'),
+            until: param('until'),
+            path: param('path'),
+        },
+    };
+}
+function memoryBrowserHref(request, change) {
+    const params = new URLSearchParams();
+    const values = {
+        q: request.query,
+        namespace: request.namespace,
+        tags: request.filter.tags?.join(','),
+        agent: request.filter.agentId,
+        since: request.filter.since,
+        until: request.filter.until,
+        path: request.filter.path,
+        ...change,
+    };
+    for (const [name, value] of Object.entries(values)) {
+        if (value !== undefined && value.length > 0) {
+            params.set(name, value);
+        }
+    }
+    return `/memory?${params.toString()}`;
+}
+// Each facet value links to the current view narrowed by it; tags add to the selected tags.
+function renderFacet(title, counts, href) {
+    const items = counts.map((facet) => `      <li><a href="${escapeHtml(href(facet.value))}">${escapeHtml(facet.value)}</a> (${facet.count})</li>`);
+    return `    <div class="card">
+      <h2>${title}</h2>
+      <ul class="findings">
+${items.length > 0 ? items.join('\n') : '        <li class="label">none</li>'}
+      </ul>
+    </div>`;
+}
+function buildMemoryBrowserHtml(request, data) {
+    const { facets } = data;
+    const selectedTags = request.filter.tags ?? [];
+    const field = (name, label, value) => `<label class="label">${label} <input name="${name}" value="${escapeHtml(value ?? '')}"></label>`;
+    const items = data.entries.map((entry) => {
+        const text = entry.kind === 'memory' ? JSON.stringify(entry.value) ?? '' : entry.content;
+        const tags = (entry.tags ?? []).map((tag) => `#${escapeHtml(tag)}`).join(' ');
+        return `    <li>[${entry.kind}] ${escapeHtml(`${entry.namespace ?? 'default'}/${entry.key}`)} ${tags}${entry.agentId !== undefined ? ` @${escapeHtml(entry.agentId)}` : ''} <span class="label">${escapeHtml(entry.updatedAt)}</span><br>${escapeHtml(text.slice(0, 200))}</li>`;
+    });
+    return `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <title>Memory Browser</title>
+  <style>
+    body { font-family: monospace; background: #0d1117; color: #c9d1d9; margin: 0; padding: 20px; }
+    h1 { color: #58a6ff; font-size: 1.2rem; margin-bottom: 4px; }
+    .subtitle { color: #6e7681; font-size: 0.8rem; margin-bottom: 20px; }
+    .grid { display: grid; grid-template-columns: repeat(auto-fit, minmax(220px, 1fr)); gap: 16px; margin-bottom: 16px; }
+    .card { background: #161b22; border: 1px solid #30363d; border-radius: 6px; padding: 16px; }
+    .card h2 { color: #79c0ff; font-size: 0.9rem; margin: 0 0 8px; }
+    .label { color: #6e7681; font-size: 0.75rem; }
+    input { font-family: monospace; background: #0d1117; color: #c9d1d9; border: 1px solid #30363d; width: 120px; }
+    .findings { font-size: 0.8rem; padding-left: 20px; }
+    .findings li { margin-bottom: 6px; }
+    .findings a { color: #58a6ff; }
+  </style>
+</head>
+<body>
+  <h1>Memory Browser</h1>
+  <p class="subtitle">${facets.total} matching entries &bull; <a href="/memory" style="color:#58a6ff;">clear filters</a></p>
+  <form method="get" action="/memory">
+    ${field('q', 'query', request.query)}
+    ${field('namespace', 'namespace', request.namespace)}
+    ${field('tags', 'tags', selectedTags.join(','))}
+    ${field('agent', 'agent', request.filter.agentId)}
+    ${field('since', 'since', request.filter.since)}
+    ${field('until', 'until', request.filter.until)}
+    ${field('path', 'path', request.filter.path)}
+    <button type="submit">Filter</button>
+  </form>
+  <div class="grid" style="margin-top:16px;">
+${renderFacet('Namespaces', facets.namespaces, (value) => memoryBrowserHref(request, { namespace: value }))}
+${renderFacet('Tags', facets.tags, (value) => memoryBrowserHref(request, { tags: [...new Set([...selectedTags, value])].join(',') }))}
+${renderFacet('Agents', facets.agents, (value) => memoryBrowserHref(request, { agent: value }))}
+${renderFacet('Paths', facets.paths, (value) => memoryBrowserHref(request, { path: value }))}
+  </div>
+  <ul class="findings">
+${items.join('\n')}
+  </ul>
+  <p><a href="/" style="color:#58a6ff;">&larr; Monitor</a></p>
+</body>
+</html>`;
+}
 function buildDashboardHtml(data) {
-    const json = JSON.stringify(data, null, 2);
+    const json = JSON.stringify({ sessions: data.sessions, traces: data.traces, agents: data.agents }, null, 2);
     return `<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -60,11 +346,17 @@ function buildDashboardHtml(data) {
     .label { color: #6e7681; font-size: 0.75rem; }
     pre { background: #0d1117; border: 1px solid #21262d; border-radius: 4px; padding: 12px; overflow: auto; font-size: 0.75rem; max-height: 300px; }
     .refresh { color: #6e7681; font-size: 0.75rem; margin-top: 20px; }
+    .findings { font-size: 0.8rem; padding-left: 20px; }
+    .findings a { color: #58a6ff; }
+    .bar { display: flex; align-items: center; gap: 8px; font-size: 0.75rem; margin: 4px 0; }
+    .bar-label { flex: 0 0 40%; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; color: #6e7681; }
+    .bar-fill { height: 10px; background: #f0883e; border-radius: 2px; min-width: 2px; }
+    .bar-value { color: #c9d1d9; }
   </style>
 </head>
 <body>
   <h1>AutomatosX Monitor</h1>
-  <p class="subtitle">Localhost only &bull; Auto-refreshes every 10s</p>
+  <p class="subtitle">Localhost only &bull; Auto-refreshes every 10s &bull; <a href="/memory" style="color:#58a6ff;">Memory browser</a></p>
   <div class="grid">
     <div class="card">
       <h2>Active Sessions</h2>
@@ -82,6 +374,13 @@ function buildDashboardHtml(data) {
       <div class="label">total</div>
     </div>
   </div>
+${renderFindings(data.traces)}
+${renderSnapshots(data.snapshots)}
+${renderTechDebt(data.techDebt)}
+${renderCodeMetrics(data.codeMetrics)}
+${renderIndexProgress(data.indexProgress)}
+${renderSymbolMap(data.symbolMap)}
+${renderMemoryAudit(data.memoryAudit)}
   <h2 style="color:#79c0ff;font-size:0.9rem;margin-top:24px;">Raw State</h2>
   <pre id="raw">${json.replace(/</g, '&lt;').replace(/>/g, '&gt;')}</pre>
   <p class="refresh">Last updated: <span id="ts">${new Date().toISOString()}</span></p>
@@ -104,6 +403,48 @@ export async function monitorCommand(args, options) {
         };
     }
     const runtime = createRuntime(options);
+    const basePath = options.outputDir ?? process.cwd();
+    let techDebtCache;
+    const loadTechDebt = async () => {
+        if (techDebtCache === undefined || Date.now() - techDebtCache.at > TECH_DEBT_CACHE_MS) {
+            techDebtCache = { at: Date.now(), debt: await runtime.listTechDebt({ basePath }) };
+        }
+        return techDebtCache.debt;
+    };
+    let codeMetricsCache;
+    const loadCodeMetrics = async () => {
+        if (codeMetricsCache === undefined || Date.now() - codeMetricsCache.at > TECH_DEBT_CACHE_MS) {
+            codeMetricsCache = { at: Date.now(), metrics: await runtime.getCodeMetrics({ basePath }) };
+        }
+        return codeMetricsCache.metrics;
+    };
+    let symbolMapCache;
+    const loadSymbolMap = async () => {
+        if (symbolMapCache === undefined || Date.now() - symbolMapCache.at > TECH_DEBT_CACHE_MS) {
+            symbolMapCache = { at: Date.now(), symbolMap: await runtime.getSymbolMap({ basePath }) };
+        }
+        return symbolMapCache.symbolMap;
+    };
+    // Key-value entries come from memory search; semantic entries from semantic search when there is a query.
+    const loadMemoryBrowser = async (request) => {
+        const filter = normalizeMemoryFilter(request.filter);
+        const { tags, ...rest } = filter;
+        const [facets, memory, semantic] = await Promise.all([
+            runtime.memoryFacets({ ...filter, namespace: request.namespace }),
+            runtime.searchMemory(request.query, request.namespace, filter),
+            request.query.length > 0
+                ? runtime.searchSemantic(request.query, { namespace: request.namespace, filterTags: tags, ...rest, topK: MAX_MEMORY_ENTRIES_SHOWN })
+                : runtime.listSemantic({ namespace: request.namespace }).then((entries) => entries.filter((entry) => matchesMemoryFilter(entry, filter))),
+        ]);
+        const entries = [
+            ...memory.map((entry) => ({ ...entry, kind: 'memory' })),
+            ...semantic.map((entry) => ({ ...entry, kind: 'semantic' })),
+        ];
+        if (request.query.length === 0) {
+            entries.sort((left, right) => right.updatedAt.localeCompare(left.updatedAt));
+        }
+        return { facets, entries: entries.slice(0, MAX_MEMORY_ENTRIES_SHOWN) };
+    };
     // Parse --port
     let explicitPort;
     const portIdx = args.indexOf('--port');
@@ -138,15 +479,135 @@ export async function monitorCommand(args, options) {
             }
             return;
         }
+        if (req.url === '/api/tech-debt') {
+            try {
+                res.writeHead(200, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify(await loadTechDebt()));
+            }
+            catch (err) {
+                res.writeHead(500, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify({ error: err instanceof Error ? err.message : String(err) }));
+            }
+            return;
+        }
+        if (req.url === '/api/code-metrics') {
+            try {
+                res.writeHead(200, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify(await loadCodeMetrics()));
+            }
+            catch (err) {
+                res.writeHead(500, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify({ error: err instanceof Error ? err.message : String(err) }));
+            }
+            return;
+        }
+        if (req.url === '/api/symbols') {
+            try {
+                res.writeHead(200, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify(await loadSymbolMap()));
+            }
+            catch (err) {
+                res.writeHead(500, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify({ error: err instanceof Error ? err.message : String(err) }));
+            }
+            return;
+        }
+        if (req.url === '/api/index-progress') {
+            try {
+                res.writeHead(200, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify((await runtime.getIndexProgress({ basePath })) ?? null));
+            }
+            catch (err) {
+                res.writeHead(500, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify({ error: err instanceof Error ? err.message : String(err) }));
+            }
+            return;
+        }
+        if (req.url === '/api/snapshots') {
+            try {
+                res.writeHead(200, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify(await runtime.listSnapshots({ basePath })));
+            }
+            catch (err) {
+                res.writeHead(500, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify({ error: err instanceof Error ? err.message : String(err) }));
+            }
+            return;
+        }
+        if (req.url === '/api/memory-audit') {
+            try {
+                res.writeHead(200, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify(await runtime.queryMemoryAudit({ limit: MAX_MEMORY_ACCESSES_SHOWN })));
+            }
+            catch (err) {
+                res.writeHead(500, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify({ error: err instanceof Error ? err.message : String(err) }));
+            }
+            return;
+        }
+        if (req.url?.split('?')[0] === '/api/memory') {
+            try {
+                const request = parseMemoryBrowserQuery(new URL(req.url, 'http://localhost').searchParams);
+                const data = await loadMemoryBrowser(request);
+                res.writeHead(200, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify(data));
+            }
+            catch (err) {
+                res.writeHead(500, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify({ error: err instanceof Error ? err.message : String(err) }));
+            }
+            return;
+        }
+        if (req.url?.split('?')[0] === '/memory') {
+            try {
+                const request = parseMemoryBrowserQuery(new URL(req.url, 'http://localhost').searchParams);
+                const data = await loadMemoryBrowser(request);
+                res.writeHead(200, { 'Content-Type': 'text/html; charset=utf-8' });
+                res.end(buildMemoryBrowserHtml(request, data));
+            }
+            catch (err) {
+                res.writeHead(500, { 'Content-Type': 'text/plain' });
+                res.end(`Error loading memory: ${err instanceof Error ? err.message : String(err)}`);
+            }
+            return;
+        }
+        // /snapshots/<id> lists the files of one step; /snapshots/<id>/<path> serves one file as text.
+        if (req.url?.startsWith('/snapshots/') === true) {
+            const [encodedId = '', ...pathParts] = req.url.slice('/snapshots/'.length).split('?')[0].split('/');
+            try {
+                const snapshotId = decodeURIComponent(encodedId);
+                if (pathParts.length === 0) {
+                    const snapshot = await runtime.getSnapshot({ snapshotId, basePath });
+                    res.writeHead(200, { 'Content-Type': 'text/html; charset=utf-8' });
+                    res.end(buildSnapshotHtml(snapshot));
+                }
+                else {
+                    const content = await runtime.readSnapshotFile({ snapshotId, path: pathParts.map(decodeURIComponent).join('/'), basePath });
+                    res.writeHead(200, { 'Content-Type': 'text/plain; charset=utf-8' });
+                    res.end(content);
+                }
+            }
+            catch (err) {
+                res.writeHead(404, { 'Content-Type': 'text/plain' });
+                res.end(err instanceof Error ? err.message : String(err));
+            }
+            return;
+        }
         if (req.url === '/' || req.url === '/index.html') {
             try {
-                const [sessions, traces, agents] = await Promise.all([
+                const [sessions, traces, agents, snapshots, techDebt, codeMetrics, symbolMap, memoryAudit, indexProgress] = await Promise.all([
                     runtime.listSessions(),
                     runtime.listTraces(options.limit ?? 20),
                     runtime.listAgents(),
+                    runtime.listSnapshots({ basePath }),
+                    loadTechDebt(),
+                    loadCodeMetrics(),
+                    loadSymbolMap(),
+                    runtime.queryMemoryAudit({ limit: MAX_MEMORY_ACCESSES_SHOWN }),
+                    runtime.getIndexProgress({ basePath }),
                 ]);
                 res.writeHead(200, { 'Content-Type': 'text/html; charset=utf-8' });
-                res.end(buildDashboardHtml({ sessions, traces, agents }));
+                res.end(buildDashboardHtml({ sessions, traces, agents, snapshots, techDebt, codeMetrics, symbolMap, memoryAudit, indexProgress }));
             }
             catch (err) {
                 res.writeHead(500, { 'Content-Type': 'text/plain' });
@@ -0,0 +1,68 @@
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+const REPORT_BUG_USAGE = 'ax report-bug [--bundle <path>] [--submit]';
+export async function reportBugCommand(args, options) {
+    if (args[0] === 'help') {
+        return success([
+            'AX Report Bug',
+            '',
+            'Usage:',
+            `  ${REPORT_BUG_USAGE}`,
+            '',
+            'Turns the latest crash bundle from .automatosx/crash-reports/ (or --bundle) into a',
+            'prefilled GitHub issue. Secrets, workspace paths, and home directories are stripped',
+            'before the bundle is written. --submit files the issue with the gh CLI.',
+        ].join('\n'));
+    }
+    let bundlePath;
+    let submit = false;
+    for (let index = 0; index < args.length; index += 1) {
+        const token = args[index];
+        if (token === '--submit') {
+            submit = true;
+        }
+        else if (token === '--bundle' && args[index + 1] !== undefined && !args[index + 1].startsWith('--')) {
+            bundlePath = args[index + 1];
+            index += 1;
+        }
+        else {
+            return usageError(REPORT_BUG_USAGE);
+        }
+    }
+    let report;
+    try {
+        report = await createRuntime(options).prepareBugReport({
+            bundlePath,
+            submit,
+            basePath: options.outputDir ?? process.cwd(),
+        });
+    }
+    catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+    }
+    if (report.submittedIssueUrl !== undefined) {
+        return success(`Bug report filed: ${report.submittedIssueUrl}`, report);
+    }
+    return success([
+        `Crash bundle: ${report.bundlePath}`,
+        `Title: ${report.title}`,
+        '',
+        'Open this link to file a prefilled issue (review it before submitting):',
+        report.issueUrl,
+    ].join('\n'), report);
+}
+export async function recordCliCrash(error, parsed, version) {
+    const message = error instanceof Error ? error.message : String(error);
+    try {
+        const crash = await createRuntime(parsed.options).recordCrash({
+            error,
+            surface: 'cli',
+            command: parsed.command,
+            version,
+            basePath: parsed.options.outputDir ?? process.cwd(),
+        });
+        return failure(`AutomatosX crashed: ${message}\nDiagnostic bundle written to ${crash.bundlePath}.\nRun "ax report-bug" to file a prefilled issue.`, { bundlePath: crash.bundlePath, category: crash.bundle.category });
+    }
+    catch {
+        return failure(`AutomatosX crashed: ${message}`);
+    }
+}
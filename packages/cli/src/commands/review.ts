@@ -1,6 +1,8 @@
 import type { CLIOptions, CommandResult } from '../types.js';
 import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
 
+const REVIEW_FINDINGS_SHOWN = 20;
+
 type ReviewFocus = 'all' | 'security' | 'correctness' | 'maintainability';
 
 interface ParsedReviewArgs {
@@ -12,6 +14,12 @@ interface ParsedReviewArgs {
 }
 
 export async function reviewCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  if (args[0] === 'diff') {
+    return structuralDiff(args.slice(1), options);
+  }
+  if (args[0] === 'api') {
+    return apiDiff(args.slice(1), options);
+  }
   const parsed = parseReviewArgs(args);
 
   if (parsed.error !== undefined) {
@@ -26,6 +34,13 @@ export async function reviewCommand(args: string[], options: CLIOptions): Promis
         'Usage:',
         '  ax review analyze <paths...> [--focus security|correctness|maintainability|all] [--max-files <n>]',
         '  ax review list',
+        '  ax review diff [--base <ref>] [--head <ref>] [paths...]',
+        '  ax review api [--base <ref>] [--head <ref>] [--fail-on-breaking] [paths...]',
+        '',
+        'api compares the exported API of the Go packages changed since --base',
+        '(default HEAD) and reports added, removed, and changed symbols, marking',
+        'the changes that break callers. --fail-on-breaking exits 1 when there',
+        'are any, for release workflows.',
       ].join('\n'));
     case 'list':
       return listReviews(options);
@@ -130,10 +145,90 @@ async function analyzeReview(parsed: ParsedReviewArgs, options: CLIOptions): Pro
     return failure(`Review failed: ${result.error?.message ?? 'Unknown error'}`, result);
   }
 
-  return success(
+  const lines = [
     `Review completed with trace ${result.traceId}. Files scanned: ${result.filesScanned}. Findings: ${result.findings.length}.`,
-    result,
-  );
+    ...result.findings.slice(0, REVIEW_FINDINGS_SHOWN).map((finding) =>
+      `- [${finding.severity}] ${finding.file}:${finding.line} ${finding.ruleId}${finding.link !== undefined ? ` ${finding.link}` : ''}`),
+  ];
+  if (result.findings.length > REVIEW_FINDINGS_SHOWN) {
+    lines.push(`... ${result.findings.length - REVIEW_FINDINGS_SHOWN} more in ${result.reportPath}`);
+  }
+  return success(lines.join('\n'), result);
+}
+
+async function structuralDiff(args: string[], options: CLIOptions): Promise<CommandResult> {
+  let base: string | undefined;
+  let head: string | undefined;
+  const paths: string[] = [];
+  for (let index = 0; index < args.length; index += 1) {
+    const token = args[index];
+    if ((token === '--base' || token === '--head') && args[index + 1] !== undefined) {
+      if (token === '--base') {
+        base = args[index + 1];
+      } else {
+        head = args[index + 1];
+      }
+      index += 1;
+    } else if (token !== undefined && token.startsWith('--')) {
+      return usageError('ax review diff [--base <ref>] [--head <ref>] [paths...]');
+    } else if (token !== undefined) {
+      paths.push(token);
+    }
+  }
+
+  const runtime = createRuntime(options);
+  const result = await runtime.structuralDiff({
+    base,
+    head,
+    paths,
+    basePath: options.outputDir ?? process.cwd(),
+  });
+  const lines = [
+    `Structural changes from ${result.base} to ${result.head ?? 'the working tree'}:`,
+    result.summary,
+  ];
+  if (result.skipped.length > 0) {
+    lines.push(`Not analyzed (unsupported language): ${result.skipped.join(', ')}`);
+  }
+  return success(lines.join('\n'), result);
+}
+
+async function apiDiff(args: string[], options: CLIOptions): Promise<CommandResult> {
+  const usage = 'ax review api [--base <ref>] [--head <ref>] [--fail-on-breaking] [paths...]';
+  let base: string | undefined;
+  let head: string | undefined;
+  let failOnBreaking = false;
+  const paths: string[] = [];
+  for (let index = 0; index < args.length; index += 1) {
+    const token = args[index];
+    if ((token === '--base' || token === '--head') && args[index + 1] !== undefined) {
+      if (token === '--base') {
+        base = args[index + 1];
+      } else {
+        head = args[index + 1];
+      }
+      index += 1;
+    } else if (token === '--fail-on-breaking') {
+      failOnBreaking = true;
+    } else if (token !== undefined && token.startsWith('--')) {
+      return usageError(usage);
+    } else if (token !== undefined) {
+      paths.push(token);
+    }
+  }
+
+  const runtime = createRuntime(options);
+  const result = await runtime.apiDiff({
+    base,
+    head,
+    paths,
+    basePath: options.outputDir ?? process.cwd(),
+  });
+  const message = [
+    `Exported API changes from ${result.base} to ${result.head ?? 'the working tree'}${result.breaking > 0 ? ` (${result.breaking} breaking)` : ''}:`,
+    result.summary,
+  ].join('\n');
+  return failOnBreaking && result.breaking > 0 ? failure(message, result) : success(message, result);
 }
 
 async function listReviews(options: CLIOptions): Promise<CommandResult> {
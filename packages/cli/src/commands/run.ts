@@ -1,7 +1,8 @@
 import { existsSync } from 'node:fs';
 import { join } from 'node:path';
 import type { CommandResult, CLIOptions } from '../types.js';
-import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+import type { DefinitionOfDone, DefinitionOfDoneVerification, RuntimeSloGateResponse } from '@defai.digital/shared-runtime';
+import { createRuntime, failure, formatDefinitionOfDone, formatTemplatePreview, success, usageError } from '../utils/formatters.js';
 import { parseOptionalJsonInput } from '../utils/validation.js';
 
 interface WorkflowStepSummary {
@@ -26,6 +27,9 @@ interface WorkflowExecutionSummary {
     failedStepId?: string;
   };
   stepResults: WorkflowStepSummary[];
+  definitionOfDone?: DefinitionOfDone;
+  doneVerification?: DefinitionOfDoneVerification;
+  sloGate?: RuntimeSloGateResponse;
 }
 
 export async function runCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
@@ -48,6 +52,21 @@ export async function runCommand(args: string[], options: CLIOptions): Promise<C
   const basePath = options.outputDir ?? process.cwd();
   const runtime = createRuntime(options);
 
+  if (options.dryRun === true) {
+    // Shows the step prompts with {{...}} placeholders filled in, without running anything.
+    try {
+      const preview = await runtime.previewTemplates({
+        workflowId,
+        workflowDir,
+        basePath,
+        input: buildWorkflowInput(workflowId, args, options, workflowInputParse.value ?? {}),
+      });
+      return success(formatTemplatePreview(preview), preview);
+    } catch (error) {
+      return failure(error instanceof Error ? error.message : String(error));
+    }
+  }
+
   try {
     const execution = await runtime.runWorkflow({
       workflowId,
@@ -72,6 +91,9 @@ export async function runCommand(args: string[], options: CLIOptions): Promise<C
     }
 
     const stepSummary = formatStepSummary(execution);
+    const doneSummary = execution.definitionOfDone !== undefined && execution.definitionOfDone.criteria.length > 0
+      ? `\n\n${formatDefinitionOfDone(execution.definitionOfDone, execution.doneVerification)}`
+      : '';
     const data = {
       traceId: execution.traceId,
       workflowId,
@@ -86,13 +108,19 @@ export async function runCommand(args: string[], options: CLIOptions): Promise<C
         retryCount: stepResult.retryCount,
         error: stepResult.error?.message,
       })),
+      definitionOfDone: execution.definitionOfDone,
+      doneVerification: execution.doneVerification,
+      ...(execution.sloGate !== undefined ? { sloGate: execution.sloGate } : {}),
     };
+    const sloWarning = execution.sloGate?.decision === 'warn'
+      ? `\nSLO gate warning: ${execution.sloGate.reasons.join('; ')}`
+      : '';
 
     if (execution.success) {
-      return success(`Workflow "${workflowId}" completed successfully.${stepSummary}`, data);
+      return success(`Workflow "${workflowId}" completed successfully.${sloWarning}${stepSummary}${doneSummary}`, data);
     }
 
-    return failure(`Workflow "${workflowId}" failed: ${execution.error?.message ?? 'Unknown error'}.${stepSummary}`, data);
+    return failure(`Workflow "${workflowId}" failed: ${execution.error?.message ?? 'Unknown error'}.${stepSummary}${doneSummary}`, data);
   } catch (error) {
     const message = error instanceof Error ? error.message : String(error);
     return failure(`Failed to run workflow "${workflowId}": ${message}`);
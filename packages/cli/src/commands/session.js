@@ -1,5 +1,6 @@
 import { randomUUID } from 'node:crypto';
-import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+import { createInterface } from 'node:readline';
+import { createRuntime, failure, failureFromError, success, usageError } from '../utils/formatters.js';
 import { parseJsonInput, asString, asOptionalString, asOptionalRecord, asStringValue } from '../utils/validation.js';
 export async function sessionCommand(args, options) {
     const subcommand = args[0] ?? 'list';
@@ -127,9 +128,131 @@ export async function sessionCommand(args, options) {
             const session = await runtime.failSession(sessionId, message.value);
             return success(`Session failed: ${session.sessionId}`, session);
         }
+        case 'replay': {
+            const parsed = parseReplayArgs(args.slice(1));
+            if (parsed === undefined) {
+                return usageError(REPLAY_USAGE);
+            }
+            const basePath = options.outputDir ?? process.cwd();
+            try {
+                if (parsed.execute) {
+                    const replay = await runtime.replaySession({ sessionId: parsed.sessionId, to: parsed.to, keepSandbox: parsed.keep, basePath });
+                    const text = formatReplay(replay);
+                    return replay.deterministic ? success(text, replay) : failure(text, replay);
+                }
+                const events = (await runtime.getSessionRecording({ sessionId: parsed.sessionId, basePath }))
+                    .filter((event) => parsed.to === undefined || event.seq <= parsed.to);
+                if (events.length === 0) {
+                    return failure(`Session ${parsed.sessionId} has no recording. Runs are recorded when given --session-id.`);
+                }
+                if (parsed.step) {
+                    await stepThrough(events, options);
+                }
+                return success([
+                    `Session ${parsed.sessionId}: ${events.length} recorded events`,
+                    ...events.map((event) => formatEvent(event, false)),
+                    '',
+                    `Re-run it with provider responses from the recording: ax session replay ${parsed.sessionId} --execute`,
+                ].join('\n'), events);
+            }
+            catch (error) {
+                return failureFromError('replay session', error);
+            }
+        }
         default:
-            return usageError('ax session [list|get|create|join|leave|complete|fail]');
+            return usageError('ax session [list|get|create|join|leave|complete|fail|replay]');
+    }
+}
+const REPLAY_USAGE = 'ax session replay <session-id> [--step] [--execute] [--to <seq>] [--keep]';
+function parseReplayArgs(args) {
+    const parsed = { step: false, execute: false, keep: false };
+    const words = [];
+    for (let index = 0; index < args.length; index += 1) {
+        const token = args[index] ?? '';
+        if (token === '--step') {
+            parsed.step = true;
+        }
+        else if (token === '--execute') {
+            parsed.execute = true;
+        }
+        else if (token === '--keep') {
+            parsed.keep = true;
+        }
+        else if (token === '--to') {
+            const to = Number(args[index + 1]);
+            if (!Number.isInteger(to) || to < 1) {
+                return undefined;
+            }
+            parsed.to = to;
+            index += 1;
+        }
+        else if (token.startsWith('--')) {
+            return undefined;
+        }
+        else {
+            words.push(token);
+        }
     }
+    // Stepping is through the recording; --keep only means something for a re-run.
+    if (words.length !== 1 || (parsed.step && parsed.execute) || (parsed.keep && !parsed.execute)) {
+        return undefined;
+    }
+    return { ...parsed, sessionId: words[0] };
+}
+// Shows one event at a time in full, waiting for Enter; q stops. Outside a terminal there is nothing to wait on.
+async function stepThrough(events, options) {
+    if (options.format === 'json' || process.stdin.isTTY !== true || process.stdout.isTTY !== true) {
+        return;
+    }
+    const rl = createInterface({ input: process.stdin, output: process.stdout });
+    try {
+        for (const event of events) {
+            process.stdout.write(`${formatEvent(event, true)}\n`);
+            const answer = await new Promise((resolve) => rl.question(`[${event.seq}/${events.length}] Enter for the next event, q to stop: `, resolve));
+            if (answer.trim().toLowerCase() === 'q') {
+                break;
+            }
+        }
+    }
+    finally {
+        rl.close();
+    }
+}
+function formatEvent(event, full) {
+    const text = (value) => full ? `\n${value.split('\n').map((line) => `    ${line}`).join('\n')}` : ` "${value.split('\n')[0] ?? ''}"`;
+    const prefix = `#${event.seq} ${event.at}`;
+    switch (event.kind) {
+        case 'operation': {
+            const { prompt, task, ...rest } = event.request;
+            const fields = ['workflowId', 'agentId', 'provider', 'model'].flatMap((key) => typeof rest[key] === 'string' ? [`${key}=${String(rest[key])}`] : []);
+            const said = typeof prompt === 'string' ? prompt : typeof task === 'string' ? task : undefined;
+            return `${prefix} run ${event.operation}${fields.length > 0 ? ` ${fields.join(' ')}` : ''}${said !== undefined ? `:${text(said)}` : ''}`;
+        }
+        case 'result':
+            return `${prefix} ${event.operation} ${event.success ? 'succeeded' : `failed${event.error !== undefined ? `: ${event.error}` : ''}`}`;
+        case 'provider': {
+            const response = event.outcome.type === 'unavailable' ? undefined : event.outcome.response;
+            const answer = event.outcome.type === 'unavailable'
+                ? ` unavailable: ${event.outcome.error}`
+                : response?.success === true ? ` ->${text(response.content ?? '')}` : ` failed: ${response?.error ?? 'no error given'}`;
+            return `${prefix} provider ${event.request.provider}/${event.request.model ?? 'default'}:${text(event.request.prompt)}${answer}`;
+        }
+        case 'tool':
+            return `${prefix} ${event.source} tool ${event.tool} ${JSON.stringify(event.args)} ${event.success ? 'ok' : `failed${event.error !== undefined ? `: ${event.error}` : ''}`} (${event.durationMs}ms)`;
+        case 'file':
+            return `${prefix} ${event.action} ${event.path} ${(event.before ?? 'none').slice(0, 12)} -> ${(event.after ?? 'none').slice(0, 12)}`;
+    }
+}
+function formatReplay(replay) {
+    return [
+        `Replayed session ${replay.sessionId}: ${replay.operations.length} run${replay.operations.length === 1 ? '' : 's'}, ${replay.providerCalls.served}/${replay.providerCalls.recorded} provider responses served from the recording, ${replay.files.replayed}/${replay.files.recorded} file changes.`,
+        ...replay.operations.map((operation) => `- #${operation.seq} ${operation.operation} ${operation.success ? 'succeeded' : `failed${operation.error !== undefined ? `: ${operation.error}` : ''}`}`),
+        '',
+        replay.deterministic
+            ? 'Deterministic: the replay matched the recording.'
+            : ['Diverged from the recording:', ...replay.divergences.map((divergence) => `- ${divergence.seq !== undefined ? `#${divergence.seq} ` : ''}${divergence.kind}: ${divergence.detail}`)].join('\n'),
+        ...(replay.sandbox !== undefined ? ['', `Sandbox kept at ${replay.sandbox}`] : []),
+    ].join('\n');
 }
 function normalizeRole(value) {
     return value === 'initiator' || value === 'collaborator' || value === 'delegate'
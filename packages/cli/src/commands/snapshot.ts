@@ -0,0 +1,101 @@
+import type { CLIOptions, CommandResult } from '../types.js';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+
+const SNAPSHOT_USAGE = 'ax snapshot list | ax snapshot take [--label <text>] | ax snapshot show <session>@<step> | ax snapshot checkout <session>@<step> [--into <dir>] [paths...]';
+
+export async function snapshotCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  const subcommand = args[0];
+  const basePath = options.outputDir ?? process.cwd();
+  const runtime = createRuntime(options);
+
+  switch (subcommand) {
+    case 'help':
+      return success([
+        'AX Snapshot',
+        '',
+        'Usage:',
+        `  ${SNAPSHOT_USAGE}`,
+        '',
+        'Snapshots record the working tree (tracked and untracked, minus ignored files)',
+        'as numbered steps of a session, deduplicated by content under .automatosx/snapshots/.',
+        'Set "snapshots": {"enabled": true} in config to snapshot after every workflow step.',
+        'checkout restores the working tree in place (saving the current state first);',
+        '--into extracts the snapshot to another directory instead.',
+      ].join('\n'));
+    case 'list': {
+      if (args.length > 1) {
+        return usageError(SNAPSHOT_USAGE);
+      }
+      const snapshots = await runtime.listSnapshots({ sessionId: options.sessionId, basePath });
+      if (snapshots.length === 0) {
+        return success('No snapshots found.', snapshots);
+      }
+      return success([
+        'Snapshots:',
+        ...snapshots.map((snapshot) => `- ${snapshot.snapshotId}  ${snapshot.createdAt}  ${snapshot.fileCount} files${snapshot.label !== undefined ? `  ${snapshot.label}` : ''}`),
+      ].join('\n'), snapshots);
+    }
+    case 'take': {
+      let label: string | undefined;
+      const rest = args.slice(1);
+      if (rest.length > 0) {
+        if (rest[0] !== '--label' || rest[1] === undefined || rest.length > 2) {
+          return usageError(SNAPSHOT_USAGE);
+        }
+        label = rest[1];
+      }
+      const snapshot = await runtime.takeSnapshot({ sessionId: options.sessionId, label, basePath });
+      const lines = [`Snapshot ${snapshot.snapshotId}: ${snapshot.fileCount} files, ${snapshot.newObjects} new blobs.`];
+      if (snapshot.skipped.length > 0) {
+        lines.push(`Skipped (too large): ${snapshot.skipped.join(', ')}`);
+      }
+      return success(lines.join('\n'), snapshot);
+    }
+    case 'show': {
+      const snapshotId = args[1];
+      if (snapshotId === undefined || args.length > 2) {
+        return usageError(SNAPSHOT_USAGE);
+      }
+      try {
+        const snapshot = await runtime.getSnapshot({ snapshotId, basePath });
+        return success([
+          `Snapshot ${snapshot.snapshotId} (${snapshot.createdAt})${snapshot.label !== undefined ? `: ${snapshot.label}` : ''}`,
+          ...snapshot.files.map((file) => `  ${file.path}  ${file.size} bytes`),
+        ].join('\n'), snapshot);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    case 'checkout': {
+      const snapshotId = args[1];
+      let targetDir: string | undefined;
+      const paths: string[] = [];
+      for (let index = 2; index < args.length; index += 1) {
+        const token = args[index];
+        if (token === '--into' && args[index + 1] !== undefined) {
+          targetDir = args[index + 1];
+          index += 1;
+        } else if (token !== undefined && token.startsWith('--')) {
+          return usageError(SNAPSHOT_USAGE);
+        } else if (token !== undefined) {
+          paths.push(token);
+        }
+      }
+      if (snapshotId === undefined) {
+        return usageError(SNAPSHOT_USAGE);
+      }
+      try {
+        const result = await runtime.checkoutSnapshot({ snapshotId, targetDir, paths, basePath });
+        const lines = [`Checked out ${result.snapshotId} into ${result.targetDir}: ${result.written.length} files written, ${result.removed.length} removed.`];
+        if (result.safetySnapshotId !== undefined) {
+          lines.push(`Previous state saved as ${result.safetySnapshotId}.`);
+        }
+        return success(lines.join('\n'), result);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    default:
+      return usageError(SNAPSHOT_USAGE);
+  }
+}
@@ -15,6 +15,13 @@ export async function statusCommand(args, options) {
         `Provider mode: ${status.runtime.providerExecutionMode}`,
         `Default provider: ${status.runtime.defaultProvider ?? 'n/a'}`,
         `Configured executors: ${status.runtime.configuredExecutors.length > 0 ? status.runtime.configuredExecutors.join(', ') : 'none'}`,
+        `Latency routing: ${formatLatencyRouting(status.runtime.latencyRouting)}`,
+        `Request hedging: ${formatHedging(status.runtime.hedging)}`,
+        '',
+        'Provider latency:',
+        ...(status.runtime.providerLatency.length > 0
+            ? status.runtime.providerLatency.map((entry) => `- ${entry.provider}${entry.model !== undefined ? `/${entry.model}` : ''} p50 ${entry.p50}ms, p95 ${entry.p95}ms, p99 ${entry.p99}ms (${entry.samples} calls, ${entry.failures} failed)`)
+            : ['- none']),
         '',
         'Active sessions:',
         ...(status.activeSessions.length > 0
@@ -32,3 +39,16 @@ export async function statusCommand(args, options) {
             : ['- none']),
     ].join('\n'), status);
 }
+function formatLatencyRouting(routing) {
+    if (!routing.enabled) {
+        return 'off';
+    }
+    const routed = routing.routedProvider !== undefined ? `${routing.routedProvider} since ${routing.routedAt}` : 'no qualifying provider yet';
+    return `${routed} (${routing.percentile} across ${routing.providers.join(', ')})`;
+}
+function formatHedging(hedging) {
+    if (!hedging.enabled) {
+        return 'off';
+    }
+    return `after ${hedging.trigger} to ${hedging.providers.join(', ')} (${hedging.hedgesLastHour}/${hedging.maxPerHour} in the last hour)`;
+}
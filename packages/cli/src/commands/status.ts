@@ -1,3 +1,4 @@
+import type { RuntimeStatusResponse } from '@defai.digital/shared-runtime';
 import type { CLIOptions, CommandResult } from '../types.js';
 import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
 
@@ -19,6 +20,13 @@ export async function statusCommand(args: string[], options: CLIOptions): Promis
     `Provider mode: ${status.runtime.providerExecutionMode}`,
     `Default provider: ${status.runtime.defaultProvider ?? 'n/a'}`,
     `Configured executors: ${status.runtime.configuredExecutors.length > 0 ? status.runtime.configuredExecutors.join(', ') : 'none'}`,
+    `Latency routing: ${formatLatencyRouting(status.runtime.latencyRouting)}`,
+    `Request hedging: ${formatHedging(status.runtime.hedging)}`,
+    '',
+    'Provider latency:',
+    ...(status.runtime.providerLatency.length > 0
+      ? status.runtime.providerLatency.map((entry) => `- ${entry.provider}${entry.model !== undefined ? `/${entry.model}` : ''} p50 ${entry.p50}ms, p95 ${entry.p95}ms, p99 ${entry.p99}ms (${entry.samples} calls, ${entry.failures} failed)`)
+      : ['- none']),
     '',
     'Active sessions:',
     ...(status.activeSessions.length > 0
@@ -36,3 +44,18 @@ export async function statusCommand(args: string[], options: CLIOptions): Promis
       : ['- none']),
   ].join('\n'), status);
 }
+
+function formatLatencyRouting(routing: RuntimeStatusResponse['runtime']['latencyRouting']): string {
+  if (!routing.enabled) {
+    return 'off';
+  }
+  const routed = routing.routedProvider !== undefined ? `${routing.routedProvider} since ${routing.routedAt}` : 'no qualifying provider yet';
+  return `${routed} (${routing.percentile} across ${routing.providers.join(', ')})`;
+}
+
+function formatHedging(hedging: RuntimeStatusResponse['runtime']['hedging']): string {
+  if (!hedging.enabled) {
+    return 'off';
+  }
+  return `after ${hedging.trigger} to ${hedging.providers.join(', ')} (${hedging.hedgesLastHour}/${hedging.maxPerHour} in the last hour)`;
+}
@@ -0,0 +1,74 @@
+import type { CLIOptions, CommandResult } from '../types.js';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+
+const SYNC_USAGE = 'ax sync [status]';
+
+export async function syncCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  const subcommand = args[0];
+  const basePath = options.outputDir ?? process.cwd();
+  const runtime = createRuntime(options);
+
+  if (args.length > 1) {
+    return usageError(SYNC_USAGE);
+  }
+
+  switch (subcommand) {
+    case 'help':
+      return success([
+        'AX Sync',
+        '',
+        'Usage:',
+        `  ${SYNC_USAGE}`,
+        '',
+        'Synchronizes memory, specs, and workflow definitions with an encrypted remote so',
+        'context follows you between machines. Caches, traces, and artifacts stay local.',
+        '',
+        'Configure .automatosx/config.json:',
+        '  "sync": { "remote": { "type": "git", "url": "git@github.com:me/ax-state.git" } }',
+        '  "sync": { "remote": { "type": "s3", "bucket": "my-bucket", "prefix": "ax" } }',
+        'and export AX_SYNC_PASSPHRASE (or the variable named by sync.passphraseEnv).',
+        '',
+        'When both machines changed the same entry, the newest edit wins and the other',
+        'version is kept under .automatosx/sync/conflicts/.',
+        '',
+        'For memory shared by a team, set "privacy" in the sync config:',
+        '  "privacy": "generalize"  paths become workspace-relative or ~/...; account and',
+        '                          machine names, email users, and IP addresses are replaced',
+        '  "privacy": "strip"       as generalize, but any path outside the workspace and',
+        '                          whole email addresses are replaced too',
+        '  "privacy": { "mode": "strip", "redact": ["ACME-[0-9]+"] } also redacts matches',
+        'Either mode rounds update times to the day and leaves this machine unnamed.',
+      ].join('\n'));
+    case 'status': {
+      const status = await runtime.getSyncStatus({ basePath });
+      if (!status.configured) {
+        return success('Sync is not configured. Run "ax sync help" for setup.', status);
+      }
+      return success([
+        `Remote: ${status.remote}`,
+        `Passphrase: ${status.passphraseSet ? 'set' : `missing (export ${status.passphraseEnv})`}`,
+        `Privacy: ${status.privacy ?? 'off'}`,
+        `Last synced: ${status.lastSyncedAt ?? 'never'}`,
+      ].join('\n'), status);
+    }
+    case undefined: {
+      try {
+        const result = await runtime.syncState({ basePath });
+        const lines = [
+          `Synced with ${result.remote}: ${result.pulled.length} pulled, ${result.pushed.length} pushed, ${result.deletedLocally.length} deleted locally.`,
+        ];
+        if (result.conflicts.length > 0) {
+          lines.push(`${result.conflicts.length} conflict(s) resolved newest-wins; previous versions saved to ${result.conflictLogPath}.`);
+        }
+        if (result.scrubbed !== undefined && result.scrubbed > 0) {
+          lines.push(`${result.scrubbed} memory entr${result.scrubbed === 1 ? 'y was' : 'ies were'} shared with identifying details removed.`);
+        }
+        return success(lines.join('\n'), result);
+      } catch (error) {
+        return failure(error instanceof Error ? error.message : String(error));
+      }
+    }
+    default:
+      return usageError(SYNC_USAGE);
+  }
+}
@@ -0,0 +1,161 @@
+import { createInterface } from 'node:readline';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+const TELEMETRY_USAGE = 'ax telemetry [status|enable|disable|preview|send|collect] [--endpoint <url>] [--days <n>] [--yes] [--port <n>] [--output <file>]';
+const SUBCOMMANDS = ['status', 'enable', 'disable', 'preview', 'send', 'collect', 'help'];
+export async function telemetryCommand(args, options) {
+    const subcommand = SUBCOMMANDS.includes(args[0])
+        ? args[0]
+        : 'status';
+    const parsed = parseTelemetryArgs(args[0] === subcommand ? args.slice(1) : args);
+    if (parsed.error !== undefined) {
+        return usageError(TELEMETRY_USAGE);
+    }
+    const basePath = options.outputDir ?? process.cwd();
+    const runtime = createRuntime(options);
+    switch (subcommand) {
+        case 'help':
+            return success([
+                'AX Telemetry',
+                '',
+                'Usage:',
+                `  ${TELEMETRY_USAGE}`,
+                '',
+                'Telemetry is off by default. When enabled, "ax telemetry send" posts an anonymized report',
+                '(feature usage counts, error codes, latency percentiles) to the configured endpoint.',
+                'Prompts, paths, messages, and custom workflow names never leave the machine.',
+                'Run "ax telemetry preview" to see exactly what would be sent, and',
+                '"ax telemetry collect" to run a self-hosted collector.',
+            ].join('\n'));
+        case 'status': {
+            const status = await runtime.getTelemetryStatus({ basePath });
+            return success([
+                `Telemetry: ${status.enabled ? 'enabled' : 'disabled'}`,
+                `Endpoint: ${status.endpoint ?? '(not configured)'}`,
+                `Last sent: ${status.lastSentAt ?? 'never'}`,
+            ].join('\n'), status);
+        }
+        case 'enable': {
+            await runtime.setConfig('telemetry.enabled', true);
+            if (parsed.endpoint !== undefined) {
+                await runtime.setConfig('telemetry.endpoint', parsed.endpoint);
+            }
+            const status = await runtime.getTelemetryStatus({ basePath });
+            return success(status.endpoint === undefined
+                ? 'Telemetry enabled. Set an endpoint with "ax telemetry enable --endpoint <url>" before sending.'
+                : `Telemetry enabled. Reports will be sent to ${status.endpoint}.`,
+            status);
+        }
+        case 'disable':
+            await runtime.setConfig('telemetry.enabled', false);
+            return success('Telemetry disabled.', await runtime.getTelemetryStatus({ basePath }));
+        case 'preview': {
+            const report = await runtime.previewTelemetry({ windowDays: parsed.windowDays, basePath });
+            return success(formatTelemetryReport(report), report);
+        }
+        case 'send': {
+            const status = await runtime.getTelemetryStatus({ basePath });
+            if (!status.enabled) {
+                return failure('Telemetry is disabled. Run "ax telemetry enable" to opt in.');
+            }
+            const endpoint = parsed.endpoint ?? status.endpoint;
+            if (endpoint === undefined) {
+                return failure('No telemetry endpoint configured. Run "ax telemetry enable --endpoint <url>".');
+            }
+            const report = await runtime.previewTelemetry({ windowDays: parsed.windowDays, basePath });
+            if (!parsed.yes && !await confirmSend(endpoint, report, options)) {
+                return failure(`Telemetry not sent. Review the report with "ax telemetry preview" and re-run with --yes.`, report);
+            }
+            const sent = await runtime.sendTelemetry({ windowDays: parsed.windowDays, endpoint, basePath });
+            return success(`Telemetry report sent to ${sent.endpoint}.`, sent);
+        }
+        case 'collect': {
+            const collector = await runtime.startTelemetryCollector({
+                port: parsed.port,
+                outputPath: parsed.outputPath,
+                basePath,
+            });
+            console.log(`\nTelemetry collector listening on http://127.0.0.1:${collector.port}/`);
+            console.log(`Appending reports to ${collector.outputPath}. Press Ctrl+C to stop.\n`);
+            const shutdown = () => {
+                void collector.close().then(() => process.exit(0));
+            };
+            process.on('SIGINT', shutdown);
+            process.on('SIGTERM', shutdown);
+            await new Promise(() => { /* runs until interrupted */ });
+            return { success: true, exitCode: 0, message: undefined, data: null };
+        }
+    }
+}
+function formatTelemetryReport(report) {
+    const lines = [
+        `Telemetry preview (last ${report.windowDays} days, install ${report.installHash.slice(0, 12)}):`,
+    ];
+    if (report.features.length === 0) {
+        lines.push('- No feature usage recorded.');
+    }
+    for (const feature of report.features) {
+        lines.push(`- ${feature.feature}: ${feature.runs} run${feature.runs === 1 ? '' : 's'}, ${feature.failures} failed, p50 ${feature.latencyMs.p50}ms, p95 ${feature.latencyMs.p95}ms, p99 ${feature.latencyMs.p99}ms`);
+    }
+    const categories = Object.entries(report.errorCategories);
+    if (categories.length > 0) {
+        lines.push(`Error categories: ${categories.map(([category, count]) => `${category}=${count}`).join(', ')}`);
+    }
+    const strategies = Object.entries(report.patchStrategies);
+    if (strategies.length > 0) {
+        lines.push(`Patch strategies: ${strategies.map(([strategy, count]) => `${strategy}=${count}`).join(', ')}`);
+    }
+    return lines.join('\n');
+}
+async function confirmSend(endpoint, report, options) {
+    if (options.format === 'json' || process.stdin.isTTY !== true || process.stdout.isTTY !== true) {
+        return false;
+    }
+    process.stdout.write(`${formatTelemetryReport(report)}\n`);
+    const rl = createInterface({ input: process.stdin, output: process.stdout });
+    return new Promise((resolve) => {
+        rl.question(`Send this report to ${endpoint}? [y/N] `, (answer) => {
+            rl.close();
+            const normalized = answer.toLowerCase().trim();
+            resolve(normalized === 'y' || normalized === 'yes');
+        });
+    });
+}
+function parseTelemetryArgs(args) {
+    const parsed = { yes: false };
+    for (let index = 0; index < args.length; index += 1) {
+        const token = args[index];
+        const value = args[index + 1];
+        if (token === '--yes') {
+            parsed.yes = true;
+        }
+        else if (token === '--days' || token === '--port') {
+            const number = Number.parseInt(value ?? '', 10);
+            if (!Number.isFinite(number) || number <= 0) {
+                return { ...parsed, error: `${token} must be a positive integer.` };
+            }
+            if (token === '--days') {
+                parsed.windowDays = number;
+            }
+            else {
+                parsed.port = number;
+            }
+            index += 1;
+        }
+        else if (token === '--endpoint' || token === '--output') {
+            if (value === undefined || value.startsWith('--')) {
+                return { ...parsed, error: `Missing value for ${token}.` };
+            }
+            if (token === '--endpoint') {
+                parsed.endpoint = value;
+            }
+            else {
+                parsed.outputPath = value;
+            }
+            index += 1;
+        }
+        else {
+            return { ...parsed, error: `Unknown telemetry argument: ${token}.` };
+        }
+    }
+    return parsed;
+}
@@ -0,0 +1,102 @@
+import { createRuntime, failureFromError, success, usageError } from '../utils/formatters.js';
+const TODOS_USAGE = 'ax todos [link] [paths...] [--kind <todo|fixme|hack>...] [--owner <name>] [--symbol <name>] [--older-than <days>]';
+const KINDS = ['todo', 'fixme', 'hack'];
+export async function todosCommand(args, options) {
+    const basePath = options.outputDir ?? process.cwd();
+    if (args[0] === 'help') {
+        return success([
+            'AX Todos',
+            '',
+            'Usage:',
+            `  ${TODOS_USAGE}`,
+            '',
+            'Lists TODO, FIXME, and HACK comments with the function, method, or type they',
+            'sit in and their age from git blame, oldest first. --symbol matches a full',
+            'name (Cart.total), everything under a type (Cart), or a bare method name.',
+            '',
+            'link records each matching TODO in the backlog memory namespace, keyed by a',
+            'stable id, and marks open entries resolved once their TODO is gone from the',
+            'scanned paths. Workflows given {"todos": {"paths": [...]}} as input see the',
+            'TODOs as {{todos.list}} and link the backlog after a successful run.',
+        ].join('\n'));
+    }
+    const link = args[0] === 'link';
+    const parsed = parseTodoArgs(link ? args.slice(1) : args);
+    if (parsed === undefined) {
+        return usageError(TODOS_USAGE);
+    }
+    const runtime = createRuntime(options);
+    try {
+        if (link) {
+            const result = await runtime.linkTodos({ ...parsed, basePath });
+            return success([
+                `Linked ${result.items.length} TODOs to the backlog: ${result.created.length} new, ${result.updated.length} updated, ${result.resolved.length} resolved.`,
+                ...result.resolved.map((key) => `- resolved ${key}`),
+            ].join('\n'), result);
+        }
+        const result = await runtime.listTodos({ ...parsed, limit: options.limit, basePath });
+        if (result.items.length === 0) {
+            return success(`No TODOs found in ${result.scannedFiles} files.`, result);
+        }
+        return success([
+            `TODOs: ${result.counts.todo} TODO, ${result.counts.fixme} FIXME, ${result.counts.hack} HACK in ${result.scannedFiles} files${result.truncated ? ` (showing ${result.items.length})` : ''}.`,
+            ...result.items.map(formatTodo),
+        ].join('\n'), result);
+    }
+    catch (error) {
+        return failureFromError(link ? 'link TODOs' : 'list TODOs', error);
+    }
+}
+function formatTodo(item) {
+    const details = [
+        item.ageDays !== undefined ? `${item.ageDays}d` : 'uncommitted',
+        item.owner ?? item.author,
+        item.backlog !== undefined ? `${item.backlog.key} ${item.backlog.status}` : undefined,
+    ].filter((detail) => detail !== undefined);
+    return `- ${item.kind.toUpperCase()} ${item.path}:${item.line}${item.symbol !== undefined ? ` ${item.symbol}` : ''} ${item.text || '(no text)'} [${details.join(', ')}]`;
+}
+function parseTodoArgs(args) {
+    const paths = [];
+    const kinds = [];
+    const filter = {};
+    for (let index = 0; index < args.length; index += 1) {
+        const token = args[index] ?? '';
+        const value = args[index + 1];
+        if (!token.startsWith('--')) {
+            paths.push(token);
+            continue;
+        }
+        if (value === undefined || value.startsWith('--')) {
+            return undefined;
+        }
+        if (token === '--kind') {
+            const kind = value.toLowerCase();
+            if (!KINDS.includes(kind)) {
+                return undefined;
+            }
+            kinds.push(kind);
+        }
+        else if (token === '--owner') {
+            filter.owner = value;
+        }
+        else if (token === '--symbol') {
+            filter.symbol = value;
+        }
+        else if (token === '--older-than') {
+            const days = Number(value);
+            if (!Number.isFinite(days) || days < 0) {
+                return undefined;
+            }
+            filter.minAgeDays = days;
+        }
+        else {
+            return undefined;
+        }
+        index += 1;
+    }
+    return {
+        ...filter,
+        ...(paths.length > 0 ? { paths } : {}),
+        ...(kinds.length > 0 ? { kinds } : {}),
+    };
+}
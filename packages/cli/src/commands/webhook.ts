@@ -0,0 +1,93 @@
+import type { CLIOptions, CommandResult } from '../types.js';
+import { createRuntime, failure, success, usageError } from '../utils/formatters.js';
+
+const WEBHOOK_USAGE = 'ax webhook serve [--port <n>] [--host <host>] [--secret <secret>] [--api-url <url>]';
+
+export async function webhookCommand(args: string[], options: CLIOptions): Promise<CommandResult> {
+  const subcommand = args[0] ?? 'help';
+  if (subcommand === 'help') {
+    return success([
+      'AX Webhook',
+      '',
+      'Usage:',
+      `  ${WEBHOOK_USAGE}`,
+      '',
+      'Serves GitHub issue_comment webhooks so pull request comments such as',
+      '"/ax fix lint" run the workflow mapped under prCommands.commands in',
+      '.automatosx/config.json, with the pull request as workflow input.',
+      'Progress and results are posted back as comments using GITHUB_TOKEN.',
+      'Only users in allowedUsers or with an allowed author association',
+      '(default: OWNER, MEMBER, COLLABORATOR) may run commands; set',
+      'prCommands.repositories to restrict commands per repository.',
+      '',
+      'With automationRules configured, issues and pull_request events run',
+      'workflows too, e.g. { "label": "ax:triage", "workflow": "triage" }.',
+      'Rules with "approval": true wait until the ax:approved label is added.',
+      'The webhook secret comes from --secret or AX_WEBHOOK_SECRET.',
+    ].join('\n'));
+  }
+  if (subcommand !== 'serve') {
+    return usageError(WEBHOOK_USAGE);
+  }
+
+  let port = 8787;
+  let host: string | undefined;
+  let secret = process.env.AX_WEBHOOK_SECRET;
+  let apiUrl = process.env.GITHUB_API_URL;
+  for (let index = 1; index < args.length; index += 1) {
+    const token = args[index];
+    const value = args[index + 1];
+    if (value === undefined || value.startsWith('--')) {
+      return usageError(WEBHOOK_USAGE);
+    }
+    if (token === '--port') {
+      port = Number.parseInt(value, 10);
+      if (!Number.isInteger(port) || port <= 0) {
+        return usageError(WEBHOOK_USAGE);
+      }
+    } else if (token === '--host') {
+      host = value;
+    } else if (token === '--secret') {
+      secret = value;
+    } else if (token === '--api-url') {
+      apiUrl = value;
+    } else {
+      return usageError(WEBHOOK_USAGE);
+    }
+    index += 1;
+  }
+
+  const githubToken = process.env.GITHUB_TOKEN ?? process.env.GH_TOKEN;
+  if (githubToken === undefined || githubToken.length === 0) {
+    return failure('Set GITHUB_TOKEN (or GH_TOKEN) so results can be posted back to pull requests.');
+  }
+  if (secret === undefined || secret.length === 0) {
+    return failure('Set AX_WEBHOOK_SECRET or pass --secret; unsigned webhook deliveries are not accepted.');
+  }
+
+  const server = await createRuntime(options).startPrCommandServer({
+    token: githubToken,
+    secret,
+    port,
+    host,
+    apiUrl,
+    basePath: options.outputDir ?? process.cwd(),
+    onOutcome: (outcome) => {
+      if (outcome.status !== 'ignored') {
+        const target = outcome.repository !== undefined ? `${outcome.repository}#${outcome.number} ` : '';
+        console.log(`${target}${outcome.status}${outcome.workflowId !== undefined ? ` ${outcome.workflowId}` : ''}${outcome.rule !== undefined ? ` [${outcome.rule}]` : ''}${outcome.traceId !== undefined ? ` (trace ${outcome.traceId})` : ''}${outcome.reason !== undefined ? `: ${outcome.reason}` : ''}`);
+      }
+    },
+  });
+  console.log(`\nPR command webhook listening on http://${host ?? '127.0.0.1'}:${server.port}/`);
+  console.log('Point a GitHub webhook (issue_comment, issues, and pull_request events) at this URL. Press Ctrl+C to stop.\n');
+
+  const shutdown = (): void => {
+    void server.close().then(() => process.exit(0));
+  };
+  process.on('SIGINT', shutdown);
+  process.on('SIGTERM', shutdown);
+
+  await new Promise(() => { /* runs until interrupted */ });
+  return { success: true, exitCode: 0, message: undefined, data: null };
+}
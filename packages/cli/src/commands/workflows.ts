@@ -1,4 +1,6 @@
-import { failure, success } from '../utils/formatters.js';
+import { join } from 'node:path';
+import { createRuntime, failure, success } from '../utils/formatters.js';
+import type { RuntimeRollbackPlaybookResponse } from '@defai.digital/shared-runtime';
 import type { CLIOptions, CommandHandler, CommandResult } from '../types.js';
 import {
   buildWorkflowInput,
@@ -28,7 +30,15 @@ async function executeWorkflowCommand(
     return failure(`Invalid workflow command input: ${validation}`);
   }
 
+  // The runtime runs the SLO gate for release, so a blocked release comes back as a failed dispatch.
   const result = await dispatch(workflowInput);
+  const { sloGate } = result;
+  if (sloGate?.decision === 'block') {
+    return failure(
+      `Workflow ${commandId} blocked by SLO gate: ${sloGate.reasons.join('; ')}`,
+      result,
+    );
+  }
   if (!result.success) {
     return failure(
       `Workflow ${commandId} failed${result.errorMessage !== undefined ? `: ${result.errorMessage}` : ''}`,
@@ -46,12 +56,51 @@ async function executeWorkflowCommand(
     );
   }
 
-  return success(`Workflow ${commandId} dispatched with trace ${result.traceId}.`, {
+  const sloWarning = sloGate?.decision === 'warn'
+    ? `\nSLO gate warning: ${sloGate.reasons.join('; ')}`
+    : '';
+  const rollbackPlaybook = await generateReleaseRollbackPlaybook(commandId, workflowInput.arguments, result, options);
+
+  return success(`Workflow ${commandId} dispatched with trace ${result.traceId}.${sloWarning}`, {
     ...result,
     workflow: buildWorkflowInput(workflowInput),
+    ...(rollbackPlaybook !== undefined ? { rollbackPlaybook } : {}),
   });
 }
 
+async function generateReleaseRollbackPlaybook(
+  commandId: WorkflowCommandId,
+  workflowArguments: Record<string, string | boolean>,
+  result: { traceId: string; outputDir: string },
+  options: CLIOptions,
+): Promise<Pick<RuntimeRollbackPlaybookResponse, 'traceId' | 'artifactPath' | 'validated' | 'gaps'> | undefined> {
+  if (commandId !== 'release') {
+    return undefined;
+  }
+
+  const commits = typeof workflowArguments.commits === 'string' ? workflowArguments.commits : undefined;
+  const [base, head] = commits?.includes('..') === true ? commits.split(/\.{2,3}/) : [];
+  try {
+    const playbook = await createRuntime(options).generateRollbackPlaybook({
+      base: base !== undefined && base.length > 0 ? base : undefined,
+      head: head !== undefined && head.length > 0 ? head : undefined,
+      releaseVersion: typeof workflowArguments.releaseVersion === 'string' ? workflowArguments.releaseVersion : undefined,
+      releaseTraceId: result.traceId,
+      sessionId: options.sessionId,
+      outputPath: join(result.outputDir, 'artifacts', 'rollback-playbook.md'),
+    });
+    return {
+      traceId: playbook.traceId,
+      artifactPath: playbook.artifactPath,
+      validated: playbook.validated,
+      gaps: playbook.gaps,
+    };
+  } catch {
+    // A release outside a git checkout (or with an unknown range) still dispatches; it just has no playbook.
+    return undefined;
+  }
+}
+
 export async function shipCommand(_args: string[], _options: CLIOptions): Promise<CommandResult> {
   return executeWorkflowCommand('ship', _args, _options);
 }
@@ -1,5 +1,5 @@
 import packageJson from '../../../package.json' with { type: 'json' };
-import { abilityCommand, agentCommand, architectCommand, auditCommand, callCommand, cleanupCommand, configCommand, doctorCommand, discussCommand, feedbackCommand, guardCommand, helpCommand, historyCommand, initCommand, iterateCommand, monitorCommand, listCommand, mcpCommand, qaCommand, releaseCommand, reviewCommand, resumeCommand, runCommand, scaffoldCommand, sessionCommand, setupCommand, shipCommand, statusCommand, traceCommand, updateCommand, } from './commands/index.js';
+import { abilityCommand, agentCommand, architectCommand, auditCommand, callCommand, cleanupCommand, configCommand, gcCommand, doctorCommand, discussCommand, feedbackCommand, guardCommand, helpCommand, historyCommand, applyCommand, askCommand, syncCommand, backupCommand, memoryCommand, snapshotCommand, analyzeCommand, checkCommand, webhookCommand, migrateCommand, reportBugCommand, telemetryCommand, benchCommand, experimentCommand, handoffCommand, initCommand, iterateCommand, monitorCommand, listCommand, mcpCommand, qaCommand, releaseCommand, reviewCommand, resumeCommand, runCommand, scaffoldCommand, todosCommand, sessionCommand, setupCommand, shipCommand, statusCommand, traceCommand, updateCommand, } from './commands/index.js';
 import { failure, success } from './utils/formatters.js';
 export const CLI_VERSION = packageJson.version;
 export const CLI_COMMAND_NAMES = [
@@ -20,11 +20,28 @@ export const CLI_COMMAND_NAMES = [
     'status',
     'config',
     'cleanup',
+    'gc',
     'feedback',
     'history',
+    'apply',
+    'ask',
+    'sync',
+    'backup',
+    'memory',
+    'snapshot',
+    'analyze',
+    'check',
+    'webhook',
+    'migrate',
+    'report-bug',
+    'telemetry',
+    'bench',
+    'experiment',
+    'handoff',
     'list',
     'monitor',
     'scaffold',
+    'todos',
     'trace',
     'discuss',
     'guard',
@@ -84,14 +101,31 @@ const COMMAND_REGISTRY = {
     status: statusCommand,
     config: configCommand,
     cleanup: cleanupCommand,
+    gc: gcCommand,
     ability: abilityCommand,
     feedback: feedbackCommand,
     call: callCommand,
     history: historyCommand,
+    apply: applyCommand,
+    ask: askCommand,
+    sync: syncCommand,
+    backup: backupCommand,
+    memory: memoryCommand,
+    snapshot: snapshotCommand,
+    analyze: analyzeCommand,
+    check: checkCommand,
+    webhook: webhookCommand,
+    migrate: migrateCommand,
+    'report-bug': reportBugCommand,
+    telemetry: telemetryCommand,
+    bench: benchCommand,
+    experiment: experimentCommand,
+    handoff: handoffCommand,
     iterate: iterateCommand,
     list: listCommand,
     monitor: monitorCommand,
     scaffold: scaffoldCommand,
+    todos: todosCommand,
     trace: traceCommand,
     discuss: discussCommand,
     guard: guardCommand,
@@ -115,6 +149,7 @@ const COMMAND_HELP = {
         usage: [
             'ax run <workflow-id>',
             'ax run <workflow-id> --input <json-object>',
+            'ax run <workflow-id> --dry-run',
         ],
     },
     call: {
@@ -125,6 +160,7 @@ const COMMAND_HELP = {
             'ax call --system "<system-prompt>" "<prompt>"',
             'ax call --autonomous --intent analysis --max-rounds 2 "<prompt>"',
             'ax call --autonomous --goal "<outcome>" --require-real "<prompt>"',
+            'ax call --clarify --max-questions 2 "<prompt>"',
         ],
     },
     ship: {
@@ -227,6 +263,14 @@ const COMMAND_HELP = {
             'ax cleanup traces [max-age-ms]',
         ],
     },
+    gc: {
+        description: 'Report stale ax/ branches and worktrees, leftover sandboxes, and abandoned sessions; --yes removes them.',
+        usage: [
+            'ax gc',
+            'ax gc --yes --max-age-days 30',
+            'ax gc --prefix agent/ --force',
+        ],
+    },
     list: {
         description: 'List workflows visible to the shared runtime loader.',
         usage: [
@@ -272,28 +316,35 @@ const COMMAND_HELP = {
             'ax agent register --input <json-object>',
             'ax agent remove <agent-id>',
             'ax agent capabilities',
-            'ax agent run <agent-id> --task <text>',
+            'ax agent run <agent-id> --task <text> [--files <path,...>]',
+            'ax agent render <agent-id> --task <text>',
             'ax agent recommend --task <text>',
         ],
     },
     mcp: {
-        description: 'Inspect MCP tools, resources, prompts, or invoke one through the local MCP surface.',
+        description: 'Inspect MCP tools, resources, prompts, or invoke one through the local MCP surface, serve MCP over stdio or HTTP, or report how clients use its tools.',
         usage: [
-            'ax mcp tools',
+            'ax mcp tools [--client <profile>]',
             'ax mcp describe <tool-name>',
             'ax mcp resources',
             'ax mcp read <resource-uri>',
             'ax mcp prompts',
             'ax mcp prompt <prompt-name> --input <json-object>',
             'ax mcp call <tool-name> --input <json-object>',
+            'ax mcp serve',
+            'ax mcp serve --http --port 8788 --token <token>',
+            'ax mcp serve --client gemini-cli',
+            'ax mcp usage --since 2026-05-01',
+            'ax mcp usage --tune',
         ],
     },
     session: {
-        description: 'Create and manage collaboration sessions.',
+        description: 'Create and manage collaboration sessions, and replay what they recorded.',
         usage: [
             'ax session list',
             'ax session create --input <json-object>',
             'ax session join <session-id> --input <json-object>',
+            'ax session replay <session-id> [--step] [--execute] [--to <seq>] [--keep]',
         ],
     },
     review: {
@@ -302,6 +353,7 @@ const COMMAND_HELP = {
             'ax review analyze <paths...>',
             'ax review analyze <paths...> --focus security',
             'ax review list',
+            'ax review diff --base main --head HEAD',
         ],
     },
     version: {
@@ -321,6 +373,153 @@ const COMMAND_HELP = {
             'ax history --verbose',
         ],
     },
+    apply: {
+        description: 'Review a proposed patch hunk by hunk and write only what you accept.',
+        usage: [
+            'ax apply changes.diff',
+            'ax apply changes.diff --agent refactorer',
+            'git diff | ax apply - --yes',
+            'ax apply agent.diff --yes --minimal-diff',
+        ],
+    },
+    ask: {
+        description: 'Ask a read-only question answered from project memory and docs.',
+        usage: [
+            'ax ask "How are workflow retries configured?"',
+            'ax ask "Where is the trace store?" --max-tokens 400',
+        ],
+    },
+    sync: {
+        description: 'Sync memory, specs, and workflows across machines through an encrypted git or S3 remote.',
+        usage: [
+            'ax sync',
+            'ax sync status',
+        ],
+    },
+    backup: {
+        description: 'Create, verify, or restore a checksummed archive of .automatosx state.',
+        usage: [
+            'ax backup create',
+            'ax backup create --output state.axbackup',
+            'ax backup verify state.axbackup',
+            'ax backup restore state.axbackup',
+        ],
+    },
+    memory: {
+        description: 'Search, export, import, prune, deduplicate, compact, snapshot, or restore key-value and semantic memory, give feedback on search results, query it as of a past time, or migrate its schema.',
+        usage: [
+            'ax memory search "session expiry" --namespace decisions --page-size 20',
+            'ax memory export',
+            'ax memory export --namespace decisions --output decisions.jsonl',
+            'ax memory import decisions.jsonl --dry-run',
+            'ax memory import decisions.jsonl --overwrite',
+            'ax memory import-history --source claude-code --since 2026-05-01',
+            'ax memory prune',
+            'ax memory dedup --dry-run',
+            'ax memory dedup --namespace decisions --threshold 0.9',
+            'ax memory compact --min-age-days 60 --dry-run',
+            'ax memory snapshot',
+            'ax memory restore --snapshot latest --dry-run',
+            'ax memory feedback token-ttl --namespace decisions --helpful --query "session expiry"',
+            'ax memory graph src/server.go --depth 3',
+            'ax memory audit --actor tool:memory.search --since 2026-05-01',
+            'ax memory redactions --rule api-key',
+            'ax memory as-of 2026-05-01T09:30:00Z --namespace decisions',
+            'ax memory migrate --dry-run',
+            'ax memory migrate --to 4',
+        ],
+    },
+    snapshot: {
+        description: 'List, inspect, or check out working tree snapshots recorded during a session.',
+        usage: [
+            'ax snapshot list --session-id <id>',
+            'ax snapshot take --label "before refactor"',
+            'ax snapshot show <session>@6',
+            'ax snapshot checkout <session>@6 --into /tmp/step-6',
+        ],
+    },
+    analyze: {
+        description: 'Static analysis over the workspace: unreferenced symbols, complexity hotspots, duplicate code, the C/C++ include graph, Go embeds, and parser conformance fixtures.',
+        usage: [
+            'ax analyze dead-code',
+            'ax analyze dead-code src --unexported-only',
+            'ax analyze dead-code --limit 50',
+            'ax analyze complexity packages/cli --limit 20',
+            'ax analyze duplicates src --min-tokens 80',
+            'ax analyze includes native --include-dir native/include',
+            'ax analyze embeds --file web/static',
+            'ax analyze fixture pkg/store/list.go --redact acme',
+            'ax analyze conformance',
+        ],
+    },
+    check: {
+        description: 'CI gate: review changed files, write SARIF, and exit non-zero on findings at or above a severity.',
+        usage: [
+            'ax check',
+            'ax check --base origin/main --sarif ax-check.sarif',
+            'ax check src --focus security --fail-on warning',
+        ],
+    },
+    webhook: {
+        description: 'Serve GitHub PR comment webhooks that run "/ax <command>" as mapped workflows and reply with results.',
+        usage: [
+            'ax webhook serve',
+            'ax webhook serve --port 8787 --host 0.0.0.0',
+        ],
+    },
+    migrate: {
+        description: 'Upgrade config, workflow, and agent files from older versions to the current schema.',
+        usage: [
+            'ax migrate --dry-run',
+            'ax migrate',
+        ],
+    },
+    'report-bug': {
+        description: 'Turn the latest crash bundle into a prefilled GitHub issue.',
+        usage: [
+            'ax report-bug',
+            'ax report-bug --bundle .automatosx/crash-reports/crash-<ts>.json',
+            'ax report-bug --submit',
+        ],
+    },
+    telemetry: {
+        description: 'Inspect, preview, send, or self-host anonymized opt-in usage telemetry.',
+        usage: [
+            'ax telemetry',
+            'ax telemetry enable --endpoint http://localhost:4318/',
+            'ax telemetry preview',
+            'ax telemetry send',
+            'ax telemetry collect --port 4318',
+        ],
+    },
+    bench: {
+        description: 'Run golden-file snapshot checks for deterministic agent template tasks.',
+        usage: [
+            'ax bench',
+            'ax bench run <task-id...>',
+            'ax bench run --update',
+            'ax bench approve [task-id...]',
+            'ax bench list',
+        ],
+    },
+    experiment: {
+        description: 'Run a task several ways in isolated copies of the workspace, check each against the definition of done, and pick one to apply.',
+        usage: [
+            'ax experiment run "<task>" --variants 3',
+            'ax experiment run "<task>" --providers claude,gemini --check "npm test"',
+            'ax experiment list',
+            'ax experiment show <id>',
+            'ax experiment pick <id> [variant]',
+        ],
+    },
+    handoff: {
+        description: 'Compile an on-call handoff document from incidents, sessions, and the backlog.',
+        usage: [
+            'ax handoff',
+            'ax handoff --hours 12',
+            'ax handoff --output handoff.md',
+        ],
+    },
     iterate: {
         description: 'Repeat a runnable command until success, iteration budget, or time budget is exhausted.',
         usage: [
@@ -338,6 +537,15 @@ const COMMAND_HELP = {
             'ax monitor --no-open',
         ],
     },
+    todos: {
+        description: 'List TODO, FIXME, and HACK comments with their enclosing symbol and git age, and keep the backlog in step.',
+        usage: [
+            'ax todos',
+            'ax todos packages/cli --kind fixme --older-than 90',
+            'ax todos --symbol Cart.total --owner alice',
+            'ax todos link packages/cli',
+        ],
+    },
     scaffold: {
         description: 'Generate contract-first components: Zod schemas, domain packages, guard policies.',
         usage: [
@@ -1,9 +1,16 @@
 #!/usr/bin/env node
-import { executeCli, parseCommand, renderCommandResult } from './index.js';
+import { recordCliCrash } from './commands/report-bug.js';
+import { CLI_VERSION, executeCli, parseCommand, renderCommandResult } from './index.js';
+import type { CommandResult } from './types.js';
 
 const argv = process.argv.slice(2);
 const parsed = parseCommand(argv);
-const result = await executeCli(argv);
+let result: CommandResult;
+try {
+  result = await executeCli(argv);
+} catch (error) {
+  result = await recordCliCrash(error, parsed, CLI_VERSION);
+}
 const output = renderCommandResult(result, parsed.options);
 
 if (output.length > 0) {
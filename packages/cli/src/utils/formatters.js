@@ -28,3 +28,37 @@ export function failureFromError(action, error) {
 export function usageError(usage) {
     return failure(`Usage: ${usage}`);
 }
+export function formatTemplatePreview(preview) {
+    const done = preview.definitionOfDone !== undefined ? ['', formatDefinitionOfDone(preview.definitionOfDone)] : [];
+    if (preview.templates.length === 0) {
+        return [`No prompt templates in ${preview.kind} ${preview.id}.`, ...done].join('\n');
+    }
+    return [
+        `Template preview: ${preview.kind} ${preview.id}`,
+        ...preview.templates.flatMap((entry) => [
+            '',
+            `[${entry.field}]`,
+            entry.text,
+            ...(entry.missing.length > 0 ? [`Missing: ${entry.missing.join(', ')}`] : []),
+            ...(entry.deferred.length > 0 ? [`Filled in at run time: ${entry.deferred.join(', ')}`] : []),
+        ]),
+        ...done,
+    ].join('\n');
+}
+// One line per criterion, marked with its result once the run has been checked.
+export function formatDefinitionOfDone(definition, verification) {
+    if (definition.criteria.length === 0) {
+        return `Definition of done: no criteria (edit ${definition.path} to add some)`;
+    }
+    const results = new Map(verification?.results.map((result) => [result.id, result]));
+    return [
+        `Definition of done (${definition.path}):`,
+        ...definition.criteria.map((criterion) => {
+            const result = results.get(criterion.id);
+            const mark = result === undefined ? '-' : result.status === 'passed' ? '✓' : result.status === 'failed' ? '✗' : '?';
+            const check = criterion.kind === 'file' ? `${criterion.path} exists` : criterion.command ?? 'checked by hand';
+            const detail = result?.status === 'failed' && result.detail !== undefined ? ` — ${result.detail}` : '';
+            return `  ${mark} [${criterion.kind}] ${criterion.id}: ${criterion.description ?? check}${detail}`;
+        }),
+    ].join('\n');
+}
@@ -1,5 +1,10 @@
 import { getErrorMessage } from '@defai.digital/contracts';
-import { createSharedRuntimeService } from '@defai.digital/shared-runtime';
+import {
+  createSharedRuntimeService,
+  type DefinitionOfDone,
+  type DefinitionOfDoneVerification,
+  type RuntimeTemplatePreview,
+} from '@defai.digital/shared-runtime';
 import type { CLIOptions, CommandResult } from '../types.js';
 
 export function createRuntime(options: CLIOptions): ReturnType<typeof createSharedRuntimeService> {
@@ -35,3 +40,39 @@ export function usageError(usage: string): CommandResult {
   return failure(`Usage: ${usage}`);
 }
 
+export function formatTemplatePreview(preview: RuntimeTemplatePreview): string {
+  const done = preview.definitionOfDone !== undefined ? ['', formatDefinitionOfDone(preview.definitionOfDone)] : [];
+  if (preview.templates.length === 0) {
+    return [`No prompt templates in ${preview.kind} ${preview.id}.`, ...done].join('\n');
+  }
+  return [
+    `Template preview: ${preview.kind} ${preview.id}`,
+    ...preview.templates.flatMap((entry) => [
+      '',
+      `[${entry.field}]`,
+      entry.text,
+      ...(entry.missing.length > 0 ? [`Missing: ${entry.missing.join(', ')}`] : []),
+      ...(entry.deferred.length > 0 ? [`Filled in at run time: ${entry.deferred.join(', ')}`] : []),
+    ]),
+    ...done,
+  ].join('\n');
+}
+
+// One line per criterion, marked with its result once the run has been checked.
+export function formatDefinitionOfDone(definition: DefinitionOfDone, verification?: DefinitionOfDoneVerification): string {
+  if (definition.criteria.length === 0) {
+    return `Definition of done: no criteria (edit ${definition.path} to add some)`;
+  }
+  const results = new Map(verification?.results.map((result) => [result.id, result]));
+  return [
+    `Definition of done (${definition.path}):`,
+    ...definition.criteria.map((criterion) => {
+      const result = results.get(criterion.id);
+      const mark = result === undefined ? '-' : result.status === 'passed' ? '✓' : result.status === 'failed' ? '✗' : '?';
+      const check = criterion.kind === 'file' ? `${criterion.path} exists` : criterion.command ?? 'checked by hand';
+      const detail = result?.status === 'failed' && result.detail !== undefined ? ` — ${result.detail}` : '';
+      return `  ${mark} [${criterion.kind}] ${criterion.id}: ${criterion.description ?? check}${detail}`;
+    }),
+  ].join('\n');
+}
+
@@ -244,10 +244,10 @@ export async function dispatch(input, options) {
             const errorCode = execution.errorCode ?? 'workflow_dispatch_failed';
             const errorMessage = execution.errorMessage ?? 'Workflow dispatch failed';
             const failResult = await updateWorkflowArtifactsStatus(artifactWriteResult, 'failed', errorMessage);
-            return { ...failResult, success: false, traceId, errorCode, errorMessage };
+            return { ...failResult, success: false, traceId, errorCode, errorMessage, ...(execution.sloGate !== undefined ? { sloGate: execution.sloGate } : {}) };
         }
         const successResult = await updateWorkflowArtifactsStatus(artifactWriteResult, 'dispatched');
-        return { ...successResult, success: true, traceId };
+        return { ...successResult, success: true, traceId, ...(execution.sloGate !== undefined ? { sloGate: execution.sloGate } : {}) };
     }
     catch (error) {
         const errorMessage = error instanceof Error ? error.message : String(error);
@@ -484,6 +484,7 @@ function previewInputs(preview) {
 }
 async function executeWorkflowWithCLI(payload) {
     const runResult = await runCommand([payload.workflowId], toRunOptions(payload));
+    const sloGate = (runResult.data)?.sloGate;
     if (!runResult.success) {
         return {
             success: false,
@@ -492,8 +493,9 @@ async function executeWorkflowWithCLI(payload) {
             manifestPath: undefined,
             summaryPath: undefined,
             artifactPaths: [],
-            errorCode: 'workflow_runtime_failed',
+            errorCode: sloGate?.decision === 'block' ? 'slo_gate_blocked' : 'workflow_runtime_failed',
             errorMessage: runResult.message ?? 'Workflow runtime failed',
+            sloGate,
         };
     }
     return {
@@ -503,6 +505,7 @@ async function executeWorkflowWithCLI(payload) {
         manifestPath: undefined,
         summaryPath: undefined,
         artifactPaths: [],
+        sloGate,
     };
 }
 function resolveOutputDirFromPayload(payload) {
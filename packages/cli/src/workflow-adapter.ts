@@ -1,6 +1,7 @@
 import { randomUUID } from 'node:crypto';
 import { mkdir, readFile, writeFile } from 'node:fs/promises';
 import { join } from 'node:path';
+import type { RuntimeSloGateResponse } from '@defai.digital/shared-runtime';
 import { runCommand } from './commands/run.js';
 import type { CLIOptions, CommandResult } from './types.js';
 
@@ -41,6 +42,8 @@ export interface WorkflowCommandResult {
   artifactPaths?: string[];
   errorCode?: string;
   errorMessage?: string;
+  // The runtime's SLO gate decision, for gated workflows such as release.
+  sloGate?: RuntimeSloGateResponse;
 }
 
 export interface WorkflowInputPayload {
@@ -357,11 +360,11 @@ export async function dispatch(
       const errorCode = execution.errorCode ?? 'workflow_dispatch_failed';
       const errorMessage = execution.errorMessage ?? 'Workflow dispatch failed';
       const failResult = await updateWorkflowArtifactsStatus(artifactWriteResult, 'failed', errorMessage);
-      return { ...failResult, success: false, traceId, errorCode, errorMessage };
+      return { ...failResult, success: false, traceId, errorCode, errorMessage, ...(execution.sloGate !== undefined ? { sloGate: execution.sloGate } : {}) };
     }
 
     const successResult = await updateWorkflowArtifactsStatus(artifactWriteResult, 'dispatched');
-    return { ...successResult, success: true, traceId };
+    return { ...successResult, success: true, traceId, ...(execution.sloGate !== undefined ? { sloGate: execution.sloGate } : {}) };
   } catch (error) {
     const errorMessage = error instanceof Error ? error.message : String(error);
     const failResult = await updateWorkflowArtifactsStatus(artifactWriteResult, 'failed', errorMessage);
@@ -679,6 +682,7 @@ async function executeWorkflowWithCLI(payload: WorkflowInputPayload): Promise<Wo
     [payload.workflowId],
     toRunOptions(payload),
   );
+  const sloGate = (runResult.data as { sloGate?: RuntimeSloGateResponse } | undefined)?.sloGate;
 
   if (!runResult.success) {
     return {
@@ -688,8 +692,9 @@ async function executeWorkflowWithCLI(payload: WorkflowInputPayload): Promise<Wo
       manifestPath: undefined,
       summaryPath: undefined,
       artifactPaths: [],
-      errorCode: 'workflow_runtime_failed',
+      errorCode: sloGate?.decision === 'block' ? 'slo_gate_blocked' : 'workflow_runtime_failed',
       errorMessage: runResult.message ?? 'Workflow runtime failed',
+      sloGate,
     };
   }
 
@@ -700,6 +705,7 @@ async function executeWorkflowWithCLI(payload: WorkflowInputPayload): Promise<Wo
     manifestPath: undefined,
     summaryPath: undefined,
     artifactPaths: [],
+    sloGate,
   };
 }
 
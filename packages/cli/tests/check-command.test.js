@@ -0,0 +1,99 @@
+import { execFileSync } from 'node:child_process';
+import { mkdirSync } from 'node:fs';
+import { readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { checkCommand, } from '../src/commands/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `check-command-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+function defaultOptions(overrides = {}) {
+    return {
+        help: false,
+        version: false,
+        verbose: false,
+        format: 'text',
+        workflowDir: undefined,
+        workflowId: undefined,
+        traceId: undefined,
+        limit: undefined,
+        input: undefined,
+        iterate: false,
+        maxIterations: undefined,
+        maxTime: undefined,
+        noContext: false,
+        category: undefined,
+        tags: undefined,
+        agent: undefined,
+        task: undefined,
+        core: undefined,
+        maxTokens: undefined,
+        refresh: undefined,
+        compact: false,
+        team: undefined,
+        provider: 'claude',
+        outputDir: undefined,
+        dryRun: false,
+        quiet: false,
+        ...overrides,
+    };
+}
+function git(cwd, ...args) {
+    execFileSync('git', ['-c', 'user.email=ci@example.com', '-c', 'user.name=ci', ...args], { cwd, stdio: 'ignore' });
+}
+describe('check command', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('writes SARIF and fails only at or above the configured severity', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        mkdirSync(join(tempDir, 'src'), { recursive: true });
+        await writeFile(join(tempDir, 'src', 'notes.ts'), '// TODO: tidy up\nexport const value = 1;\n', 'utf8');
+        await writeFile(join(tempDir, 'src', 'shell.ts'), 'import { execSync } from "node:child_process";\nexecSync("ls");\n', 'utf8');
+        const passed = await checkCommand(['src', '--sarif', 'out/ax.sarif'], defaultOptions({ outputDir: tempDir }));
+        expect(passed.success).toBe(true);
+        expect(passed.exitCode).toBe(0);
+        expect(passed.message).toContain('0 critical');
+        const sarif = JSON.parse(await readFile(join(tempDir, 'out', 'ax.sarif'), 'utf8'));
+        expect(sarif).toMatchObject({ version: '2.1.0', runs: [{ tool: { driver: { name: 'AutomatosX' } } }] });
+        expect(sarif.runs[0].tool.driver.rules.map((rule) => rule.id)).toEqual(expect.arrayContaining([
+            'maintainability.todo',
+            'security.command-exec',
+        ]));
+        expect(sarif.runs[0].results.find((result) => result.ruleId === 'maintainability.todo')).toEqual({
+            ruleId: 'maintainability.todo',
+            level: 'note',
+            message: { text: 'TODO found in retained review scope.' },
+            locations: [{ physicalLocation: { artifactLocation: { uri: 'src/notes.ts' }, region: { startLine: 1 } } }],
+        });
+        const failed = await checkCommand(['src', '--fail-on', 'warning'], defaultOptions({ outputDir: tempDir }));
+        expect(failed.success).toBe(false);
+        expect(failed.exitCode).toBe(1);
+        expect(failed.message).toMatch(/Check failed: \d+ finding\(s\) at or above warning\./);
+        const invalid = await checkCommand(['--fail-on', 'sometimes'], defaultOptions({ outputDir: tempDir }));
+        expect(invalid.message).toContain('Usage: ax check');
+    });
+    it('limits the check to files changed since --base', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        git(tempDir, 'init', '-q');
+        await writeFile(join(tempDir, 'legacy.ts'), 'export const run = (code: string) => eval(code);\n', 'utf8');
+        git(tempDir, 'add', '-A');
+        git(tempDir, 'commit', '-q', '-m', 'base');
+        git(tempDir, 'tag', 'base');
+        const unchanged = await checkCommand(['--base', 'base'], defaultOptions({ outputDir: tempDir }));
+        expect(unchanged.success).toBe(true);
+        expect(unchanged.message).toBe('No files changed since base; nothing to check.');
+        await writeFile(join(tempDir, 'feature.ts'), '// TODO: handle errors\nexport const feature = 1;\n', 'utf8');
+        git(tempDir, 'add', '-A');
+        git(tempDir, 'commit', '-q', '-m', 'feature');
+        const changed = await checkCommand(['--base', 'base'], defaultOptions({ outputDir: tempDir }));
+        expect(changed.success).toBe(true);
+        expect(changed.message).toContain('Checked 1 files: 0 critical, 0 warning, 1 note.');
+        expect(changed.message).toContain('feature.ts:1 maintainability.todo');
+    });
+});
@@ -1,10 +1,42 @@
+import { createHash, randomUUID, timingSafeEqual } from 'node:crypto';
 import { access, mkdir, readFile, writeFile } from 'node:fs/promises';
+import { createServer, type IncomingMessage, type ServerResponse } from 'node:http';
+import type { AddressInfo } from 'node:net';
 import { dirname, join, relative, resolve } from 'node:path';
 import { createInterface, type Interface } from 'node:readline';
 import type { StepGuardPolicy } from '@defai.digital/contracts';
 import { createDashboardService, type DashboardService } from '@defai.digital/monitoring';
-import { createSharedRuntimeService, type SharedRuntimeService } from '@defai.digital/shared-runtime';
-import type { ReviewFocus } from '@defai.digital/shared-runtime';
+import {
+  appendMcpToolCall,
+  createSharedRuntimeService,
+  describeToolForClient,
+  detectGeneratedCode,
+  findMcpClientProfile,
+  formatImportGraphDot,
+  mcpClientAllowsTool,
+  queryMcpToolUsage,
+  readCompositeTools,
+  readMcpClientProfiles,
+  readMcpToolTuning,
+  readMcpToolUsageConfig,
+  runCompositeTool,
+  type McpClientProfile,
+  type SharedRuntimeService,
+} from '@defai.digital/shared-runtime';
+import type {
+  CallGraphDirection,
+  ChunkingStrategy,
+  CodeMetricsSort,
+  CompositeToolDefinition,
+  McpToolUsageFilter,
+  MemoryActorKind,
+  MemoryAuditAction,
+  MemoryFilter,
+  ReviewFocus,
+  RuntimeMcpToolUsageResponse,
+  SemanticSearchMode,
+  TechDebtKind,
+} from '@defai.digital/shared-runtime';
 
 export interface MpcToolResult {
   success: boolean;
@@ -68,12 +100,22 @@ export interface McpResourceContent {
 
 export interface McpServerSurface {
   listTools(): string[];
-  listToolDefinitions(): McpToolDefinition[];
-  invokeTool(toolName: string, args?: Record<string, unknown>): Promise<MpcToolResult>;
+  // With a client profile, only the tools it allows, described for it.
+  listToolDefinitions(client?: McpClientProfile): McpToolDefinition[];
+  invokeTool(toolName: string, args?: Record<string, unknown>, client?: McpClientProfile): Promise<MpcToolResult>;
   listResources(): McpResourceDefinition[];
   readResource(uri: string): Promise<McpResourceContent>;
   listPrompts(): McpPromptDefinition[];
   getPrompt(name: string, args?: Record<string, unknown>): Promise<McpPromptResult>;
+  // Composite tools from config.json that were not registered, with the reason.
+  listCompositeToolErrors(): string[];
+  // Profiles from `mcp.clients` in config.json, then the built-in ones.
+  listClientProfiles(): McpClientProfile[];
+  listClientProfileErrors(): string[];
+  // Logs a client's tool call while `mcp.usage` is on in config.json; argument values are left out.
+  recordToolCall(call: { toolName: string; args: Record<string, unknown>; result: MpcToolResult; client?: McpClientProfile; durationMs: number }): Promise<void>;
+  // Logged calls per tool, with descriptions suggested for tools clients keep calling with invalid arguments.
+  toolUsageReport(filter?: McpToolUsageFilter): Promise<RuntimeMcpToolUsageResponse>;
 }
 
 // MCP JSON-RPC 2.0 types
@@ -103,11 +145,30 @@ interface RateLimiter {
 }
 
 const MCP_VERSION = '2024-11-05';
+// Protocol versions echoed back to clients that ask for them; others are offered MCP_VERSION.
+const SUPPORTED_MCP_VERSIONS = [MCP_VERSION, '2025-03-26'];
 const SERVER_NAME = 'automatosx';
 const SERVER_VERSION = '14.0.0';
 const DEFAULT_TOOL_PREFIX = 'ax_';
 
+// MCP over HTTP: the Streamable HTTP endpoint, and the event stream and message endpoint of the older HTTP+SSE transport.
+const MCP_HTTP_PATH = '/mcp';
+const MCP_SSE_PATH = '/sse';
+const MCP_SSE_MESSAGES_PATH = '/messages';
+const MCP_SESSION_HEADER = 'Mcp-Session-Id';
+const MAX_MCP_HTTP_BODY_BYTES = 4 * 1024 * 1024;
+// Streamable HTTP sessions unused for this long are dropped; their clients initialize again.
+const MCP_SESSION_IDLE_MS = 30 * 60_000;
+const MCP_SESSION_SWEEP_MS = 60_000;
+// Past this many open sessions, new ones are only opened in place of the caller's own.
+const DEFAULT_MAX_MCP_HTTP_SESSIONS = 100;
+// Comment lines on open event streams keep proxies from closing them as idle.
+const MCP_SSE_KEEPALIVE_MS = 25_000;
+const SSE_HEADERS = { 'Content-Type': 'text/event-stream', 'Cache-Control': 'no-cache', Connection: 'keep-alive' };
+
 // JSON-RPC error codes
+const RPC_PARSE_ERROR = -32700;
+const RPC_INVALID_REQUEST = -32600;
 const RPC_METHOD_NOT_FOUND = -32601;
 const RPC_INVALID_PARAMS = -32602;
 const RPC_INTERNAL_ERROR = -32603;
@@ -188,6 +249,17 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
       basePath: { type: 'string' },
     }, ['workflowId']),
   },
+  {
+    name: 'workflow.done',
+    description: 'Show the definition of done a workflow run is checked against, and optionally check it now.',
+    inputSchema: objectSchema({
+      workflowId: { type: 'string' },
+      workflowDir: { type: 'string' },
+      basePath: { type: 'string' },
+      input: objectSchema({}, [], true),
+      verify: { type: 'boolean', description: 'Run the checks instead of only listing them.' },
+    }, ['workflowId']),
+  },
   {
     name: 'trace.get',
     description: 'Load a single trace by id.',
@@ -267,11 +339,12 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
   },
   {
     name: 'agent.run',
-    description: 'Execute a registered agent through the shared runtime.',
+    description: 'Execute a registered agent through the shared runtime. Workspace files listed in files are packed into the prompt with numbered lines, and the result lists the path:line anchors the output cites.',
     inputSchema: objectSchema({
       agentId: { type: 'string' },
       task: { type: 'string' },
       input: objectSchema({}, [], true),
+      files: { type: 'array', items: { type: 'string' } },
       traceId: { type: 'string' },
       sessionId: { type: 'string' },
       basePath: { type: 'string' },
@@ -421,20 +494,41 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
       limit: { type: 'integer' },
     }),
   },
+  {
+    name: 'tech_debt.list',
+    description: 'List TODO, FIXME, HACK, BUG, and Deprecated: comment markers with file, line, and owner (explicit or via git blame) for backlog grooming.',
+    inputSchema: objectSchema({
+      paths: { type: 'array', items: { type: 'string' } },
+      kinds: { type: 'array', items: { type: 'string', enum: ['todo', 'fixme', 'hack', 'bug', 'deprecated'] } },
+      owner: { type: 'string' },
+      blame: { type: 'boolean' },
+      limit: { type: 'integer' },
+      basePath: { type: 'string' },
+    }),
+  },
   {
     name: 'memory.retrieve',
     description: 'Retrieve a single memory entry by key.',
     inputSchema: objectSchema({
       key: { type: 'string' },
       namespace: { type: 'string' },
+      scope: { type: 'string' },
     }, ['key']),
   },
   {
     name: 'memory.search',
-    description: 'Search memory entries by query.',
+    description: 'Search memory entries by query. tags (all must match), agentId, since/until (ISO dates, bounding updatedAt), and path (a file or directory the entry is about) narrow the results. Without pageSize or cursor, returns the best 200 matches. With pageSize (1-500, default 50) or cursor, returns one page of every match as { entries, total, offset, nextCursor }; repeat the same query and filters with cursor set to nextCursor until it is absent.',
     inputSchema: objectSchema({
       query: { type: 'string' },
       namespace: { type: 'string' },
+      scope: { type: 'string' },
+      tags: { type: 'array', items: { type: 'string' } },
+      agentId: { type: 'string' },
+      since: { type: 'string' },
+      until: { type: 'string' },
+      path: { type: 'string' },
+      pageSize: { type: 'integer' },
+      cursor: { type: 'string' },
     }, ['query']),
   },
   {
@@ -443,15 +537,21 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     inputSchema: objectSchema({
       key: { type: 'string' },
       namespace: { type: 'string' },
+      scope: { type: 'string' },
     }, ['key']),
   },
   {
     name: 'memory.store',
-    description: 'Store a memory entry.',
+    description: 'Store a memory entry. With ttlMs, the entry expires that many milliseconds after it is stored. agentId counts the entry against that agent\'s memory quota, and importance (0-1) protects it under importance eviction. tags label the entry for filtered search and facets.',
     inputSchema: objectSchema({
       key: { type: 'string' },
       namespace: { type: 'string' },
+      scope: { type: 'string' },
       value: objectSchema({}, [], true),
+      tags: { type: 'array', items: { type: 'string' } },
+      ttlMs: { type: 'number' },
+      agentId: { type: 'string' },
+      importance: { type: 'number' },
     }, ['key']),
   },
   {
@@ -459,28 +559,43 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     description: 'List memory entries.',
     inputSchema: objectSchema({
       namespace: { type: 'string' },
+      scope: { type: 'string' },
     }),
   },
   {
     name: 'semantic.store',
-    description: 'Store semantic content for later similarity search.',
+    description: 'Store semantic content for later similarity search. With a source path, code is stored as one item per function/type (with its doc comment), keyed <key>#<symbol>.',
     inputSchema: objectSchema({
       key: { type: 'string' },
       namespace: { type: 'string' },
+      scope: { type: 'string' },
       content: { type: 'string' },
       tags: { type: 'array', items: { type: 'string' } },
       metadata: objectSchema({}, [], true),
+      path: { type: 'string' },
+      chunking: { type: 'string', enum: ['declarations', 'lines', 'whole'] },
+      ttlMs: { type: 'number' },
+      agentId: { type: 'string' },
+      importance: { type: 'number' },
     }, ['key', 'content']),
   },
   {
     name: 'semantic.search',
-    description: 'Search semantic content by similarity.',
+    description: 'Search semantic content. hybrid (the default unless config sets semantic.search) fuses vector similarity with full-text keyword matching, so exact identifiers rank alongside paraphrases; vector and keyword use one ranking. keywordWeight (0-1) tilts hybrid results. agentId, since/until (ISO dates), and path (a source file or directory) narrow results before topK applies. Chunks of generated files are left out unless includeGenerated is true, and then rank below hand-written code.',
     inputSchema: objectSchema({
       query: { type: 'string' },
       namespace: { type: 'string' },
+      scope: { type: 'string' },
       filterTags: { type: 'array', items: { type: 'string' } },
+      agentId: { type: 'string' },
+      since: { type: 'string' },
+      until: { type: 'string' },
+      path: { type: 'string' },
       topK: { type: 'integer' },
       minSimilarity: { type: 'number' },
+      mode: { type: 'string', enum: ['hybrid', 'vector', 'keyword'] },
+      keywordWeight: { type: 'number' },
+      includeGenerated: { type: 'boolean' },
     }, ['query']),
   },
   {
@@ -489,6 +604,7 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     inputSchema: objectSchema({
       key: { type: 'string' },
       namespace: { type: 'string' },
+      scope: { type: 'string' },
     }, ['key']),
   },
   {
@@ -496,6 +612,7 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     description: 'List semantic items by namespace or key prefix.',
     inputSchema: objectSchema({
       namespace: { type: 'string' },
+      scope: { type: 'string' },
       keyPrefix: { type: 'string' },
       filterTags: { type: 'array', items: { type: 'string' } },
       limit: { type: 'integer' },
@@ -507,6 +624,7 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     inputSchema: objectSchema({
       key: { type: 'string' },
       namespace: { type: 'string' },
+      scope: { type: 'string' },
     }, ['key']),
   },
   {
@@ -514,6 +632,7 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     description: 'Return semantic namespace statistics.',
     inputSchema: objectSchema({
       namespace: { type: 'string' },
+      scope: { type: 'string' },
     }),
   },
   {
@@ -521,9 +640,20 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     description: 'Clear all semantic items in a namespace.',
     inputSchema: objectSchema({
       namespace: { type: 'string' },
+      scope: { type: 'string' },
       confirm: { type: 'boolean' },
     }, ['namespace', 'confirm']),
   },
+  {
+    name: 'semantic.index_docs',
+    description: 'Store the doc comment of every documented Go declaration (optionally only under paths) as a semantic entry keyed <path>#<symbol>, with its signature, in namespace code-docs unless another is given, so semantic.search finds code by what its docs say. Entries for symbols that lost their doc comment are removed.',
+    inputSchema: objectSchema({
+      paths: { type: 'array', items: { type: 'string' } },
+      namespace: { type: 'string' },
+      scope: { type: 'string' },
+      agentId: { type: 'string' },
+    }),
+  },
   {
     name: 'feedback.submit',
     description: 'Submit a durable feedback event for an agent run or task.',
@@ -618,12 +748,13 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
   },
   {
     name: 'file.write',
-    description: 'Write content to a workspace-relative file path.',
+    description: 'Write content to a workspace-relative file path. Overwrites keep the existing file indentation, quotes, and line endings unless preserveStyle is false. Overwriting a generated file (protobuf output, mocks, bundles, or a "generated" header) returns a warning: change its source and regenerate instead.',
     inputSchema: objectSchema({
       path: { type: 'string' },
       content: { type: 'string' },
       overwrite: { type: 'boolean' },
       createDirectories: { type: 'boolean' },
+      preserveStyle: { type: 'boolean' },
       basePath: { type: 'string' },
     }, ['path', 'content']),
   },
@@ -654,6 +785,170 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
       stat: { type: 'boolean' },
     }),
   },
+  {
+    name: 'diff.structural',
+    description: 'Report declaration-level changes (function added or removed, signature changed, body-only change) for TS/JS, Go, C/C++, Java/Kotlin, Rust, and Python files between two refs or against the working tree.',
+    inputSchema: objectSchema({
+      base: { type: 'string' },
+      head: { type: 'string' },
+      paths: { type: 'array', items: { type: 'string' } },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.api_diff',
+    description: 'Compare the exported API of the Go packages changed between two refs (or a ref and the working tree): exported symbols added or removed, signature changes such as a constructor gaining a parameter, exported struct fields added, removed, or retyped, and interface method set changes, each marked breaking or not. Whole packages are compared, so moving a symbol between files is no change.',
+    inputSchema: objectSchema({
+      base: { type: 'string' },
+      head: { type: 'string' },
+      paths: { type: 'array', items: { type: 'string' } },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.find_symbols',
+    description: 'Find declarations with a query such as `kind:func receiver:Server name:~Start exported:true`. Fields: kind, name, receiver, exported, path, lang, annotation (e.g. `annotation:RestController`), platform (Go build constraints, e.g. `platform:linux/arm64+integration`), tag (Go struct tags, e.g. `tag:json:user_id`); Go structs list their fields and tags; `~` for regex, `*` wildcards, `-` to negate, bare words match names. Covers TS/JS, Go, C/C++, Java/Kotlin, Rust, and Python.',
+    inputSchema: objectSchema({
+      query: { type: 'string' },
+      paths: { type: 'array', items: { type: 'string' } },
+      limit: { type: 'integer' },
+      basePath: { type: 'string' },
+    }, ['query']),
+  },
+  {
+    name: 'code.rank_symbols',
+    description: 'Rank declarations by PageRank over the reference graph (a use of a name inside one declaration links it to the declaration the name resolves to), so the core abstractions much of the code depends on come first and leaf utilities last. Each entry has its score (1 is average), uses, and referring declarations. Paths narrow the list, not the graph.',
+    inputSchema: objectSchema({
+      paths: { type: 'array', items: { type: 'string' } },
+      limit: { type: 'integer' },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.get_metrics',
+    description: 'Heuristic complexity per function and method: cyclomatic and cognitive complexity, nesting depth, parameter count, and size, worst first, with workspace averages, a cyclomatic distribution, and the files carrying the most complexity. Narrow with paths or a code.find_symbols query.',
+    inputSchema: objectSchema({
+      paths: { type: 'array', items: { type: 'string' } },
+      query: { type: 'string' },
+      sort: { type: 'string', enum: ['cognitive', 'cyclomatic', 'nesting', 'parameters', 'lines'] },
+      minCognitive: { type: 'integer' },
+      minCyclomatic: { type: 'integer' },
+      limit: { type: 'integer' },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.find_duplicates',
+    description: 'Copied code across files and languages: runs of at least minTokens (default 50) tokens that match once identifiers and literals are normalized, grouped per copy with the enclosing symbol of each, most duplicated lines first. Use it to find copies to consolidate into shared helpers.',
+    inputSchema: objectSchema({
+      paths: { type: 'array', items: { type: 'string' } },
+      minTokens: { type: 'integer' },
+      minLines: { type: 'integer' },
+      limit: { type: 'integer' },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.rename_impact',
+    description: 'Given a symbol (`Name` or `Receiver.Name`), list every file and line a rename must change: definitions, interface members and implementations, call sites, Go struct tags, and string literals to review. Name-based across TS/JS, Go, C/C++, Java/Kotlin, Rust, and Python.',
+    inputSchema: objectSchema({
+      symbol: { type: 'string' },
+      paths: { type: 'array', items: { type: 'string' } },
+      limit: { type: 'integer' },
+      basePath: { type: 'string' },
+    }, ['symbol']),
+  },
+  {
+    name: 'code.definition',
+    description: 'Go to definition: declarations of a symbol (`Name` or `Receiver.Name`) or of the identifier at a file position (1-based line and column), best match first. Name-based across TS/JS, Go, C/C++, Java/Kotlin, Rust, Python, and extractor plugins.',
+    inputSchema: objectSchema({
+      symbol: { type: 'string' },
+      path: { type: 'string' },
+      line: { type: 'integer' },
+      column: { type: 'integer' },
+      paths: { type: 'array', items: { type: 'string' } },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.references',
+    description: 'Find references: every code use of a symbol (`Name` or `Receiver.Name`) or of the identifier at a file position, with its qualifier (`server` in `server.start()`). Comments and strings are ignored; set includeDefinitions to false to list only uses. Uses of a Go generic carry their type arguments, and `Name[string]` keeps only that instantiation.',
+    inputSchema: objectSchema({
+      symbol: { type: 'string' },
+      path: { type: 'string' },
+      line: { type: 'integer' },
+      column: { type: 'integer' },
+      paths: { type: 'array', items: { type: 'string' } },
+      includeDefinitions: { type: 'boolean' },
+      limit: { type: 'integer' },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.get_call_graph',
+    description: 'Call graph per function and method: calls from (callees), to (callers), or both directions of a symbol (`Name` or `Receiver.Name`), `depth` calls deep, or every edge when no symbol is given. Calls through an interface are dynamic edges to the method on each implementation; callees outside the workspace are listed as external.',
+    inputSchema: objectSchema({
+      symbol: { type: 'string' },
+      direction: { type: 'string', enum: ['callees', 'callers', 'both'] },
+      depth: { type: 'integer' },
+      paths: { type: 'array', items: { type: 'string' } },
+      limit: { type: 'integer' },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.implementations',
+    description: 'Types implementing an interface: Go types whose own and promoted (embedded) methods cover the interface, and TS/Java/Kotlin classes that declare it. Given a concrete type, lists the interfaces it satisfies. Accepts common library interfaces such as `io.Reader` or `error`.',
+    inputSchema: objectSchema({
+      symbol: { type: 'string' },
+      paths: { type: 'array', items: { type: 'string' } },
+      basePath: { type: 'string' },
+    }, ['symbol']),
+  },
+  {
+    name: 'code.test_map',
+    description: 'Map Go Test, Benchmark, Fuzz, and Example functions to the package functions and methods they exercise, by test name (TestServer_Start -> Server.Start, TestParseEmpty -> Parse) and by the calls they make, through test helpers included. Lists every function with the tests that target it and the untested ones, exported first, so test generation can start where tests are missing.',
+    inputSchema: objectSchema({
+      paths: { type: 'array', items: { type: 'string' } },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.export_lsif',
+    description: 'Export parsed symbols and references as an LSIF 0.5 dump (JSON lines) for external code-intelligence tools and code review systems: definitions with signature and doc comment hovers, uses linked by name, and export monikers for exported symbols. Writes to outputPath (default .automatosx/exports/dump.lsif) and returns counts, including uses left unlinked because several declarations match.',
+    inputSchema: objectSchema({
+      paths: { type: 'array', items: { type: 'string' } },
+      outputPath: { type: 'string' },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.include_graph',
+    description: 'Map `#include` dependencies of C/C++ files, including cgo preambles in Go files: per-file includes and includers, external headers, unresolved includes, and include cycles.',
+    inputSchema: objectSchema({
+      paths: { type: 'array', items: { type: 'string' } },
+      includeDirs: { type: 'array', items: { type: 'string' } },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.import_graph',
+    description: 'Map the package-level import graph of the workspace\'s Go code: per-package imports and importers (resolved through go.mod module paths), every import with its file and line, external and standard-library packages, and import cycles. format "dot" adds a Graphviz rendering.',
+    inputSchema: objectSchema({
+      paths: { type: 'array', items: { type: 'string' } },
+      format: { type: 'string', enum: ['json', 'dot'] },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'code.go_embeds',
+    description: 'List `//go:embed` directives with the workspace files each one embeds and patterns that match nothing. Pass files before moving or deleting them to see which directives depend on them.',
+    inputSchema: objectSchema({
+      paths: { type: 'array', items: { type: 'string' } },
+      files: { type: 'array', items: { type: 'string' } },
+      basePath: { type: 'string' },
+    }),
+  },
   {
     name: 'commit.prepare',
     description: 'Prepare a conventional commit message from local changes.',
@@ -796,12 +1091,12 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
   {
     name: 'memory.stats',
     description: 'Return count of memory entries per namespace.',
-    inputSchema: objectSchema({ namespace: { type: 'string' } }),
+    inputSchema: objectSchema({ namespace: { type: 'string' }, scope: { type: 'string' } }),
   },
   {
     name: 'memory.clear',
     description: 'Delete all memory entries in a namespace.',
-    inputSchema: objectSchema({ namespace: { type: 'string' } }, ['namespace']),
+    inputSchema: objectSchema({ namespace: { type: 'string' }, scope: { type: 'string' } }, ['namespace']),
   },
   {
     name: 'memory.bulk_delete',
@@ -809,6 +1104,7 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     inputSchema: objectSchema({
       keys: { type: 'array', items: { type: 'string' } },
       namespace: { type: 'string' },
+      scope: { type: 'string' },
     }, ['keys']),
   },
   // ── Telemetry / metrics ────────────────────────────────────────────────────
@@ -879,6 +1175,19 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     description: 'Retry a failed task.',
     inputSchema: objectSchema({ taskId: { type: 'string' } }, ['taskId']),
   },
+  {
+    name: 'task.clarify',
+    description: 'Assess how ambiguous a task is before planning it. Below the confidence threshold (clarification.threshold in config, default 0.6), returns up to maxQuestions targeted questions (default 3), each answered from memory or specs where a good match exists; ask the user the unanswered ones or state assumptions.',
+    inputSchema: objectSchema({
+      task: { type: 'string' },
+      goal: { type: 'string' },
+      files: { type: 'array', items: { type: 'string' } },
+      threshold: { type: 'number' },
+      maxQuestions: { type: 'integer' },
+      autoAnswer: { type: 'boolean' },
+      basePath: { type: 'string' },
+    }, ['task']),
+  },
   // ── Scaffold (MCP surface) ─────────────────────────────────────────────────
   {
     name: 'scaffold.contract',
@@ -941,7 +1250,7 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
   {
     name: 'memory.export',
     description: 'Export all memory entries (optionally filtered by namespace) as JSON.',
-    inputSchema: objectSchema({ namespace: { type: 'string' } }),
+    inputSchema: objectSchema({ namespace: { type: 'string' }, scope: { type: 'string' } }),
   },
   {
     name: 'memory.import',
@@ -949,8 +1258,104 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     inputSchema: objectSchema({
       entries: { type: 'array', items: objectSchema({ key: { type: 'string' }, namespace: { type: 'string' }, value: objectSchema({}, [], true) }, ['key']) },
       overwrite: { type: 'boolean' },
+      scope: { type: 'string' },
     }, ['entries']),
   },
+  {
+    name: 'memory.dedup',
+    description: 'Merge duplicate memory entries: identical key-value values, and semantic entries with the same content or embeddings at least `threshold` similar (default 0.95). The newest entry is kept with provenance in its metadata.',
+    inputSchema: objectSchema({
+      namespace: { type: 'string' },
+      threshold: { type: 'number' },
+      dryRun: { type: 'boolean' },
+      scope: { type: 'string' },
+    }),
+  },
+  {
+    name: 'memory.compact',
+    description: 'Summarize clusters of old, related semantic entries into one consolidated entry each, archiving the originals under .automatosx/memory-archive. minAgeDays, minClusterSize, and similarity (0-1) default to memory.compaction in config (30 days, 3 entries, 0.5).',
+    inputSchema: objectSchema({
+      namespace: { type: 'string' },
+      minAgeDays: { type: 'number' },
+      minClusterSize: { type: 'integer' },
+      similarity: { type: 'number' },
+      dryRun: { type: 'boolean' },
+      scope: { type: 'string' },
+    }),
+  },
+  {
+    name: 'memory.facets',
+    description: 'Count key-value and semantic memory entries by namespace, tag, agent, and path, most frequent first. Takes the memory.search filters, so each count shows how many entries a further drill-down would leave.',
+    inputSchema: objectSchema({
+      namespace: { type: 'string' },
+      scope: { type: 'string' },
+      tags: { type: 'array', items: { type: 'string' } },
+      agentId: { type: 'string' },
+      since: { type: 'string' },
+      until: { type: 'string' },
+      path: { type: 'string' },
+      limit: { type: 'integer' },
+    }),
+  },
+  {
+    name: 'memory.feedback',
+    description: 'Mark a retrieved memory entry as helpful or unhelpful. Later memory.search (kind memory, the default) or semantic.search (kind semantic) calls in the same memory scope rank it higher or lower, more so for queries like the one given.',
+    inputSchema: objectSchema({
+      key: { type: 'string' },
+      namespace: { type: 'string' },
+      scope: { type: 'string' },
+      kind: { type: 'string', enum: ['memory', 'semantic'] },
+      helpful: { type: 'boolean' },
+      query: { type: 'string' },
+      agentId: { type: 'string' },
+    }, ['key', 'helpful']),
+  },
+  {
+    name: 'memory.graph',
+    description: 'Traverse the knowledge graph linking memory and semantic entries, sessions, agents, files, and symbols. query is a file path (e.g. src/server.go), directory, symbol name, session id, agent id, memory key, or node id such as session:abc; returns the linked nodes within depth hops (default 2, at most 4), nearest and newest first, with the edges between them.',
+    inputSchema: objectSchema({
+      query: { type: 'string' },
+      depth: { type: 'integer' },
+      limit: { type: 'integer' },
+      namespace: { type: 'string' },
+      scope: { type: 'string' },
+    }, ['query']),
+  },
+  {
+    name: 'memory.audit',
+    description: 'Query the memory access audit log, kept while memory.audit is on in config: every memory and semantic read, write, and delete with its actor (agent, tool, or user), time, namespace, key or query, and the entries involved. Returns matching records newest first and per-actor read/write/delete counts.',
+    inputSchema: objectSchema({
+      actor: { type: 'string', description: 'Actor id, or kind:id such as tool:memory.search.' },
+      actorKind: { type: 'string', enum: ['agent', 'tool', 'user'] },
+      action: { type: 'string', enum: ['read', 'write', 'delete'] },
+      namespace: { type: 'string' },
+      key: { type: 'string' },
+      since: { type: 'string' },
+      until: { type: 'string' },
+      limit: { type: 'integer' },
+    }),
+  },
+  {
+    name: 'memory.redactions',
+    description: 'Report secrets redacted before memory was written or the memory audit log was appended: API keys, tokens, passwords, private keys, and high-entropy strings, which are stored as [REDACTED:<rule>]. Returns the writes involved newest first, with the rules that matched and how often, never the secrets themselves, and totals per rule.',
+    inputSchema: objectSchema({
+      rule: { type: 'string', description: 'Rule name, such as api-key, assignment, or high-entropy.' },
+      namespace: { type: 'string' },
+      since: { type: 'string' },
+      until: { type: 'string' },
+      limit: { type: 'integer' },
+    }),
+  },
+  {
+    name: 'memory.as_of',
+    description: 'Return the key-value and semantic memory entries exactly as they stood at a past time, including entries since changed or deleted, to reconstruct what context an agent saw during a session. asOf is an ISO 8601 timestamp. Needs the sqlite or postgres memory backend; history older than memory.retention.historyDays is pruned.',
+    inputSchema: objectSchema({
+      asOf: { type: 'string' },
+      namespace: { type: 'string' },
+      key: { type: 'string' },
+      scope: { type: 'string' },
+    }, ['asOf']),
+  },
   // ── Timer ──────────────────────────────────────────────────────────────────
   {
     name: 'timer.start',
@@ -1073,12 +1478,88 @@ const TOOL_DEFINITIONS: McpToolDefinition[] = [
     description: 'List stored design artifacts.',
     inputSchema: objectSchema({ domain: { type: 'string' } }),
   },
+  // ── Evaluation rubrics ─────────────────────────────────────────────────────
+  {
+    name: 'rubric.list',
+    description: 'List project evaluation rubrics defined in .automatosx/rubrics/*.yaml.',
+    inputSchema: objectSchema({ basePath: { type: 'string' } }),
+  },
+  {
+    name: 'rubric.evaluate',
+    description: 'Score an output (and optional changed files) against a project evaluation rubric.',
+    inputSchema: objectSchema({
+      rubricId: { type: 'string' },
+      output: { type: 'string' },
+      changedFiles: { type: 'array', items: { type: 'string' } },
+      basePath: { type: 'string' },
+    }, ['rubricId', 'output']),
+  },
+  // ── Deploy verification ────────────────────────────────────────────────────
+  {
+    name: 'canary.verify',
+    description: 'Poll canary health endpoints for a window, compare against baseline, and trigger the rollback workflow on regression.',
+    inputSchema: objectSchema({
+      workflowId: { type: 'string' },
+      endpoints: { type: 'array', items: { type: 'string' } },
+      windowMs: { type: 'integer' },
+      intervalMs: { type: 'integer' },
+      baseline: objectSchema({
+        errorRate: { type: 'number' },
+        p95LatencyMs: { type: 'number' },
+      }),
+      rollbackWorkflowId: { type: 'string' },
+      autoRollback: { type: 'boolean' },
+      traceId: { type: 'string' },
+      sessionId: { type: 'string' },
+      basePath: { type: 'string' },
+    }),
+  },
+  {
+    name: 'release.rollback_playbook',
+    description: 'Generate a rollback playbook (reverts, migration reversals, flag flips) validated against the changes in a release range.',
+    inputSchema: objectSchema({
+      base: { type: 'string' },
+      head: { type: 'string' },
+      releaseVersion: { type: 'string' },
+      releaseTraceId: { type: 'string' },
+      outputPath: { type: 'string' },
+      pullRequest: { type: 'string' },
+      traceId: { type: 'string' },
+      sessionId: { type: 'string' },
+      basePath: { type: 'string' },
+    }),
+  },
+  // ── Dependencies ───────────────────────────────────────────────────────────
+  {
+    name: 'dependency.audit',
+    description: 'Parse go.mod and go.work, and flag outdated, replaced, locally replaced, pseudo-versioned, or +incompatible modules before suggesting imports.',
+    inputSchema: objectSchema({
+      checkUpdates: { type: 'boolean' },
+      basePath: { type: 'string' },
+    }),
+  },
 ];
 
 export interface McpStdioServer {
   serve(): Promise<void>;
 }
 
+export interface McpHttpServer {
+  port: number;
+  close(): Promise<void>;
+}
+
+type JsonRpcDispatcher = (request: JsonRpcRequest) => Promise<JsonRpcResponse | undefined>;
+
+interface McpHttpSession {
+  dispatch: JsonRpcDispatcher;
+  lastUsed: number;
+  // Digest of the bearer token that opened the session; only that token may use it.
+  token: string;
+  // The event stream of an HTTP+SSE client, which carries every response for the session.
+  stream?: ServerResponse;
+}
+
 export function createMcpStdioServer(config: {
   runtimeService?: SharedRuntimeService;
   dashboardService?: DashboardService;
@@ -1087,61 +1568,373 @@ export function createMcpStdioServer(config: {
   output?: NodeJS.WritableStream;
   rateLimit?: RateLimitConfig;
   toolPrefix?: string;
+  // Serve every client as this profile instead of detecting it from `initialize`.
+  client?: string;
 } = {}): McpStdioServer {
+  const runtimeService = config.runtimeService ?? createSharedRuntimeService({ basePath: config.basePath ?? process.cwd() });
   const surface = createMcpServerSurface({
-    runtimeService: config.runtimeService,
+    runtimeService,
     dashboardService: config.dashboardService,
     basePath: config.basePath,
     toolPrefix: config.toolPrefix,
   });
+  const client = requireClientProfile(surface, config.client);
 
   const input = config.input ?? process.stdin;
   const output = config.output ?? process.stdout;
-  const rateLimiter = createRateLimiter(config.rateLimit);
   let rl: Interface | undefined;
-  let shuttingDown = false;
+  const dispatch = createJsonRpcDispatcher({
+    surface,
+    runtimeService,
+    basePath: config.basePath,
+    rateLimit: config.rateLimit,
+    client,
+    onShutdown: () => queueMicrotask(() => rl?.close()),
+  });
 
   function send(response: JsonRpcResponse): void {
     output.write(`${JSON.stringify(response)}\n`);
   }
 
-  function sendError(id: string | number | null, code: number, message: string, data?: unknown): void {
-    const response: JsonRpcResponse = { jsonrpc: '2.0', id, error: { code, message } };
-    if (data !== undefined) {
-      response.error!.data = data;
+  async function handleRequest(request: JsonRpcRequest): Promise<void> {
+    const response = await dispatch(request);
+    if (response !== undefined) {
+      send(response);
     }
-    send(response);
   }
 
-  function buildToolDefinitions(): McpToolDefinition[] {
-    return surface.listToolDefinitions();
+  return {
+    serve(): Promise<void> {
+      return new Promise((resolve) => {
+        rl = createInterface({ input, terminal: false });
+        const pending: Promise<void>[] = [];
+
+        rl.on('line', (line) => {
+          const trimmed = line.trim();
+          if (trimmed.length === 0) {
+            return;
+          }
+          let request: JsonRpcRequest;
+          try {
+            request = JSON.parse(trimmed) as JsonRpcRequest;
+          } catch {
+            send(jsonRpcError(null, RPC_PARSE_ERROR, 'Parse error'));
+            return;
+          }
+          pending.push(handleRequest(request));
+        });
+
+        rl.on('close', () => {
+          void Promise.all(pending).then(() => { resolve(); });
+        });
+      });
+    },
+  };
+}
+
+/**
+ * Serves MCP over HTTP so remote and browser-based clients can share one
+ * instance. `POST /mcp` is the Streamable HTTP transport: `initialize` opens a
+ * session returned in `Mcp-Session-Id`, later requests send that header back,
+ * and `DELETE /mcp` ends it. `GET /sse` plus `POST /messages?sessionId=` is the
+ * older HTTP+SSE transport. Every request needs `Authorization: Bearer <token>`
+ * with one of `tokens`, and a session only answers the token that opened it.
+ * Requests carrying an `Origin` header are refused unless it is in
+ * `allowedOrigins`. The rate limit is per token, however many sessions it
+ * opens, and at most `maxSessions` are kept, no more than
+ * `maxSessionsPerToken` (an even share by default) for any one token. Idle
+ * sessions are dropped after 30 minutes, and a token at its cap gives up its
+ * own least recently used session, event streams included, to open another.
+ */
+export function startMcpHttpServer(config: {
+  tokens: string[];
+  runtimeService?: SharedRuntimeService;
+  dashboardService?: DashboardService;
+  basePath?: string;
+  rateLimit?: RateLimitConfig;
+  toolPrefix?: string;
+  port?: number;
+  host?: string;
+  allowedOrigins?: string[];
+  maxSessions?: number;
+  maxSessionsPerToken?: number;
+  // Serve every client as this profile instead of detecting it from `initialize`.
+  client?: string;
+}): Promise<McpHttpServer> {
+  const tokenDigests = config.tokens.filter((token) => token.length > 0).map((token) => createHash('sha256').update(token).digest());
+  if (tokenDigests.length === 0) {
+    return Promise.reject(new Error('MCP over HTTP needs at least one bearer token'));
   }
+  const runtimeService = config.runtimeService ?? createSharedRuntimeService({ basePath: config.basePath ?? process.cwd() });
+  const surface = createMcpServerSurface({
+    runtimeService,
+    dashboardService: config.dashboardService,
+    basePath: config.basePath,
+    toolPrefix: config.toolPrefix,
+  });
+  let client: McpClientProfile | undefined;
+  try {
+    client = requireClientProfile(surface, config.client);
+  } catch (error) {
+    return Promise.reject(error);
+  }
+  const allowedOrigins = new Set(config.allowedOrigins ?? []);
+  const maxSessions = Math.max(1, config.maxSessions ?? DEFAULT_MAX_MCP_HTTP_SESSIONS);
+  // By default each token gets an even share, so no one token can fill the server.
+  const maxSessionsPerToken = Math.max(1, config.maxSessionsPerToken ?? Math.floor(maxSessions / tokenDigests.length));
+  const sessions = new Map<string, McpHttpSession>();
+  // Keyed by token digest, so opening more sessions doesn't buy more requests.
+  const rateLimiters = new Map<string, RateLimiter>();
 
-  async function handleRequest(request: JsonRpcRequest): Promise<void> {
+  function sweepIdleSessions(): void {
+    const now = Date.now();
+    for (const [id, session] of sessions) {
+      if (session.stream === undefined && now - session.lastUsed > MCP_SESSION_IDLE_MS) {
+        sessions.delete(id);
+      }
+    }
+  }
+  const sweeper = setInterval(sweepIdleSessions, MCP_SESSION_SWEEP_MS);
+  sweeper.unref();
+
+  // A token at its own cap gives up its least recently used session, event
+  // stream or not; sessions of other tokens are never dropped to make room.
+  // Undefined when the server is full and the token has nothing to give up.
+  function openSession(token: string): { id: string; session: McpHttpSession } | undefined {
+    sweepIdleSessions();
+    const own = [...sessions].filter(([, session]) => session.token === token).sort(([, left], [, right]) => left.lastUsed - right.lastUsed);
+    if (own.length >= maxSessionsPerToken || sessions.size >= maxSessions) {
+      const oldest = own[0];
+      if (oldest === undefined) {
+        return undefined;
+      }
+      sessions.delete(oldest[0]);
+      oldest[1].stream?.end();
+    }
+    let rateLimiter = rateLimiters.get(token);
+    if (rateLimiter === undefined) {
+      rateLimiter = createRateLimiter(config.rateLimit);
+      rateLimiters.set(token, rateLimiter);
+    }
+    const id = randomUUID();
+    const session: McpHttpSession = {
+      dispatch: createJsonRpcDispatcher({ surface, runtimeService, basePath: config.basePath, rateLimiter, client }),
+      lastUsed: Date.now(),
+      token,
+    };
+    sessions.set(id, session);
+    return { id, session };
+  }
+
+  // A session opened with another token is treated as unknown, so its id is no use to anyone else.
+  function findSession(sessionId: string | undefined, token: string): McpHttpSession | undefined {
+    const session = sessionId !== undefined ? sessions.get(sessionId) : undefined;
+    return session?.token === token ? session : undefined;
+  }
+
+  async function handleHttpRequest(req: IncomingMessage, res: ServerResponse): Promise<void> {
+    const url = new URL(req.url ?? '/', 'http://localhost');
+    const origin = req.headers.origin;
+    if (origin !== undefined) {
+      // Browsers send Origin; checking it stops other sites from reaching the server through a visitor's browser.
+      if (!allowedOrigins.has(origin)) {
+        respondJson(res, 403, { error: `Origin ${origin} is not allowed` });
+        return;
+      }
+      res.setHeader('Access-Control-Allow-Origin', origin);
+      res.setHeader('Access-Control-Expose-Headers', MCP_SESSION_HEADER);
+      res.setHeader('Vary', 'Origin');
+    }
+    if (req.method === 'OPTIONS') {
+      res.writeHead(204, {
+        'Access-Control-Allow-Methods': 'GET, POST, DELETE, OPTIONS',
+        'Access-Control-Allow-Headers': `Authorization, Content-Type, Accept, ${MCP_SESSION_HEADER}, Mcp-Protocol-Version`,
+        'Access-Control-Max-Age': '600',
+      });
+      res.end();
+      return;
+    }
+    const token = authorizedBearer(req.headers.authorization, tokenDigests);
+    if (token === undefined) {
+      res.setHeader('WWW-Authenticate', 'Bearer');
+      respondJson(res, 401, { error: 'Missing or invalid bearer token' });
+      return;
+    }
+
+    if (url.pathname === MCP_HTTP_PATH) {
+      await handleStreamableHttp(req, res, token);
+    } else if (url.pathname === MCP_SSE_PATH && req.method === 'GET') {
+      openEventStream(res, token);
+    } else if (url.pathname === MCP_SSE_MESSAGES_PATH && req.method === 'POST') {
+      await handleSseMessage(req, res, url.searchParams.get('sessionId'), token);
+    } else {
+      respondJson(res, 404, { error: `No MCP endpoint at ${req.method ?? 'GET'} ${url.pathname}` });
+    }
+  }
+
+  async function handleStreamableHttp(req: IncomingMessage, res: ServerResponse, token: string): Promise<void> {
+    const sessionHeader = req.headers[MCP_SESSION_HEADER.toLowerCase()];
+    const sessionId = typeof sessionHeader === 'string' ? sessionHeader : undefined;
+    if (req.method === 'DELETE') {
+      if (sessionId === undefined || findSession(sessionId, token) === undefined || !sessions.delete(sessionId)) {
+        respondJson(res, 404, { error: 'Unknown MCP session' });
+        return;
+      }
+      res.writeHead(204).end();
+      return;
+    }
+    if (req.method !== 'POST') {
+      // Nothing is pushed to clients unprompted, so there is no standalone stream to open.
+      res.setHeader('Allow', 'POST, DELETE');
+      respondJson(res, 405, { error: `Send MCP messages with POST ${MCP_HTTP_PATH}` });
+      return;
+    }
+
+    const body = await readJsonRpcBody(req, res);
+    if (body === undefined) {
+      return;
+    }
+    let session: McpHttpSession | undefined;
+    if (body.messages.some((message) => message.method === 'initialize')) {
+      const opened = openSession(token);
+      if (opened === undefined) {
+        respondJson(res, 503, jsonRpcError(null, RPC_INVALID_REQUEST, `Too many open MCP sessions (max ${maxSessions}); try again later`));
+        return;
+      }
+      session = opened.session;
+      res.setHeader(MCP_SESSION_HEADER, opened.id);
+    } else if (sessionId === undefined) {
+      respondJson(res, 400, jsonRpcError(null, RPC_INVALID_REQUEST, `${MCP_SESSION_HEADER} header is required; send initialize first`));
+      return;
+    } else {
+      session = findSession(sessionId, token);
+      if (session === undefined) {
+        respondJson(res, 404, jsonRpcError(null, RPC_INVALID_REQUEST, 'Unknown or expired MCP session; send initialize again'));
+        return;
+      }
+    }
+
+    session.lastUsed = Date.now();
+    const responses = await dispatchAll(session, body.messages);
+    if (responses.length === 0) {
+      res.writeHead(202).end();
+      return;
+    }
+    const accept = req.headers.accept ?? '';
+    if (accept.includes('text/event-stream') && !accept.includes('application/json')) {
+      res.writeHead(200, SSE_HEADERS);
+      res.end(responses.map((response) => sseEvent('message', JSON.stringify(response))).join(''));
+      return;
+    }
+    respondJson(res, 200, body.batch ? responses : responses[0]);
+  }
+
+  function openEventStream(res: ServerResponse, token: string): void {
+    const opened = openSession(token);
+    if (opened === undefined) {
+      respondJson(res, 503, { error: `Too many open MCP sessions (max ${maxSessions}); try again later` });
+      return;
+    }
+    const { id, session } = opened;
+    session.stream = res;
+    res.writeHead(200, SSE_HEADERS);
+    res.write(sseEvent('endpoint', `${MCP_SSE_MESSAGES_PATH}?sessionId=${id}`));
+    const keepAlive = setInterval(() => res.write(': keepalive\n\n'), MCP_SSE_KEEPALIVE_MS);
+    res.on('close', () => {
+      clearInterval(keepAlive);
+      sessions.delete(id);
+    });
+  }
+
+  async function handleSseMessage(req: IncomingMessage, res: ServerResponse, sessionId: string | null, token: string): Promise<void> {
+    const session = findSession(sessionId ?? undefined, token);
+    if (session?.stream === undefined) {
+      respondJson(res, 404, { error: `Unknown MCP session; open GET ${MCP_SSE_PATH} first` });
+      return;
+    }
+    const body = await readJsonRpcBody(req, res);
+    if (body === undefined) {
+      return;
+    }
+    session.lastUsed = Date.now();
+    res.writeHead(202).end();
+    for (const response of await dispatchAll(session, body.messages)) {
+      if (!session.stream.writableEnded) {
+        session.stream.write(sseEvent('message', JSON.stringify(response)));
+      }
+    }
+  }
+
+  const server = createServer((req, res) => {
+    handleHttpRequest(req, res).catch((error) => {
+      if (!res.headersSent) {
+        respondJson(res, 500, { error: error instanceof Error ? error.message : String(error) });
+      } else if (!res.writableEnded) {
+        res.end();
+      }
+    });
+  });
+
+  return new Promise((resolve, reject) => {
+    server.once('error', reject);
+    server.listen(config.port ?? 0, config.host ?? '127.0.0.1', () => {
+      resolve({
+        port: (server.address() as AddressInfo).port,
+        close: async () => {
+          clearInterval(sweeper);
+          for (const session of sessions.values()) {
+            session.stream?.end();
+          }
+          sessions.clear();
+          await new Promise<void>((done) => server.close(() => done()));
+        },
+      });
+    });
+  });
+}
+
+// One client's view of the server: the tool surface is shared, the rate limit, client profile, and shutdown state are not.
+function createJsonRpcDispatcher(config: {
+  surface: McpServerSurface;
+  runtimeService: SharedRuntimeService;
+  basePath?: string;
+  rateLimit?: RateLimitConfig;
+  // Shared with other dispatchers, e.g. every HTTP session opened with one token.
+  rateLimiter?: RateLimiter;
+  client?: McpClientProfile;
+  onShutdown?: () => void;
+}): JsonRpcDispatcher {
+  const { surface, runtimeService } = config;
+  const rateLimiter = config.rateLimiter ?? createRateLimiter(config.rateLimit);
+  let shuttingDown = false;
+  let client = config.client;
+
+  return async (request) => {
     const { id, method, params } = request;
 
     try {
       if (shuttingDown && method !== 'shutdown') {
-        sendError(id, RPC_SERVER_SHUTTING_DOWN, 'Server is shutting down');
-        return;
+        return jsonRpcError(id, RPC_SERVER_SHUTTING_DOWN, 'Server is shutting down');
       }
 
       if (isRateLimitedMethod(method) && !rateLimiter.allow()) {
-        sendError(
+        return jsonRpcError(
           id,
           RPC_RATE_LIMITED,
           `Rate limit exceeded: max ${rateLimiter.maxRequests} requests per ${rateLimiter.windowMs}ms`,
         );
-        return;
       }
 
       switch (method) {
         case 'initialize': {
-          send({
+          const clientInfo = isRecord(params?.clientInfo) ? params.clientInfo : {};
+          client = config.client ?? findMcpClientProfile(surface.listClientProfiles(), asOptionalString(clientInfo.name));
+          const requestedVersion = params?.protocolVersion;
+          return {
             jsonrpc: '2.0',
             id,
             result: {
-              protocolVersion: MCP_VERSION,
+              protocolVersion: typeof requestedVersion === 'string' && SUPPORTED_MCP_VERSIONS.includes(requestedVersion) ? requestedVersion : MCP_VERSION,
               serverInfo: { name: SERVER_NAME, version: SERVER_VERSION },
               capabilities: {
                 tools: { listChanged: false },
@@ -1149,146 +1942,184 @@ export function createMcpStdioServer(config: {
                 prompts: { listChanged: false },
               },
             },
-          });
-          break;
+          };
         }
 
         case 'notifications/initialized':
-          break;
+          return undefined;
 
-        case 'tools/list': {
-          send({
+        case 'tools/list':
+          return {
             jsonrpc: '2.0',
             id,
-            result: { tools: buildToolDefinitions() },
-          });
-          break;
-        }
+            result: { tools: surface.listToolDefinitions(client) },
+          };
 
         case 'tools/call': {
           const toolName = params?.name;
           if (typeof toolName !== 'string' || toolName.length === 0) {
-            sendError(id, RPC_INVALID_PARAMS, 'tools/call requires params.name');
-            break;
+            return jsonRpcError(id, RPC_INVALID_PARAMS, 'tools/call requires params.name');
           }
           const toolArgs = isRecord(params?.arguments) ? params.arguments : {};
-          const result = await surface.invokeTool(toolName, toolArgs);
+          const startedAt = Date.now();
+          const result = await surface.invokeTool(toolName, toolArgs, client);
+          await surface.recordToolCall({ toolName, args: toolArgs, result, client, durationMs: Date.now() - startedAt });
           if (result.success) {
-            send({
+            return {
               jsonrpc: '2.0',
               id,
               result: {
                 content: [{ type: 'text', text: JSON.stringify(result.data, null, 2) }],
               },
-            });
-          } else {
-            send({
-              jsonrpc: '2.0',
-              id,
-              result: {
-                content: [{ type: 'text', text: result.error ?? 'Tool failed' }],
-                isError: true,
-              },
-            });
+            };
           }
-          break;
+          return {
+            jsonrpc: '2.0',
+            id,
+            result: {
+              content: [{ type: 'text', text: result.error ?? 'Tool failed' }],
+              isError: true,
+            },
+          };
         }
 
-        case 'resources/list': {
-          send({
+        case 'resources/list':
+          return {
             jsonrpc: '2.0',
             id,
             result: { resources: surface.listResources() },
-          });
-          break;
-        }
+          };
 
         case 'resources/read': {
           const uri = params?.uri;
           if (typeof uri !== 'string' || uri.length === 0) {
-            sendError(id, RPC_INVALID_PARAMS, 'resources/read requires params.uri');
-            break;
+            return jsonRpcError(id, RPC_INVALID_PARAMS, 'resources/read requires params.uri');
           }
           const content = await surface.readResource(uri);
-          send({
+          return {
             jsonrpc: '2.0',
             id,
             result: { contents: [content] },
-          });
-          break;
+          };
         }
 
-        case 'prompts/list': {
-          send({
+        case 'prompts/list':
+          return {
             jsonrpc: '2.0',
             id,
             result: { prompts: surface.listPrompts() },
-          });
-          break;
-        }
+          };
 
         case 'prompts/get': {
           const name = params?.name;
           if (typeof name !== 'string' || name.length === 0) {
-            sendError(id, RPC_INVALID_PARAMS, 'prompts/get requires params.name');
-            break;
+            return jsonRpcError(id, RPC_INVALID_PARAMS, 'prompts/get requires params.name');
           }
           const prompt = await surface.getPrompt(name, isRecord(params?.arguments) ? params.arguments : {});
-          send({
+          return {
             jsonrpc: '2.0',
             id,
             result: prompt,
-          });
-          break;
+          };
         }
 
-        case 'shutdown': {
+        case 'shutdown':
           shuttingDown = true;
-          send({ jsonrpc: '2.0', id, result: {} });
-          queueMicrotask(() => rl?.close());
-          break;
-        }
+          config.onShutdown?.();
+          return { jsonrpc: '2.0', id, result: {} };
 
-        case 'ping': {
-          send({ jsonrpc: '2.0', id, result: {} });
-          break;
-        }
+        case 'ping':
+          return { jsonrpc: '2.0', id, result: {} };
 
         default:
-          sendError(id, RPC_METHOD_NOT_FOUND, `Method not found: ${method}`);
+          return jsonRpcError(id, RPC_METHOD_NOT_FOUND, `Method not found: ${method}`);
       }
     } catch (error) {
-      sendError(id, RPC_INTERNAL_ERROR, error instanceof Error ? error.message : String(error));
+      const toolName = method === 'tools/call' && typeof params?.name === 'string' ? params.name : undefined;
+      void runtimeService.recordCrash({
+        error,
+        surface: 'mcp',
+        command: toolName ?? method,
+        version: SERVER_VERSION,
+        basePath: config.basePath,
+      }).catch(() => undefined);
+      return jsonRpcError(id, RPC_INTERNAL_ERROR, error instanceof Error ? error.message : String(error));
+    }
+  };
+}
+
+function requireClientProfile(surface: McpServerSurface, name: string | undefined): McpClientProfile | undefined {
+  if (name === undefined) {
+    return undefined;
+  }
+  const profiles = surface.listClientProfiles();
+  const profile = profiles.find((entry) => entry.name === name);
+  if (profile === undefined) {
+    throw new Error(`Unknown MCP client profile "${name}"; known profiles: ${profiles.map((entry) => entry.name).join(', ')}`);
+  }
+  return profile;
+}
+
+function jsonRpcError(id: string | number | null, code: number, message: string, data?: unknown): JsonRpcResponse {
+  const response: JsonRpcResponse = { jsonrpc: '2.0', id, error: { code, message } };
+  if (data !== undefined) {
+    response.error!.data = data;
+  }
+  return response;
+}
+
+// Responses for the requests among `messages`; notifications get none.
+async function dispatchAll(session: McpHttpSession, messages: JsonRpcRequest[]): Promise<JsonRpcResponse[]> {
+  const responses = await Promise.all(messages.map((message) => session.dispatch(message)));
+  return responses.filter((response, index): response is JsonRpcResponse => response !== undefined && messages[index]?.id !== undefined);
+}
+
+async function readJsonRpcBody(req: IncomingMessage, res: ServerResponse): Promise<{ messages: JsonRpcRequest[]; batch: boolean } | undefined> {
+  const chunks: Buffer[] = [];
+  let size = 0;
+  for await (const chunk of req) {
+    const buffer = chunk as Buffer;
+    size += buffer.length;
+    if (size > MAX_MCP_HTTP_BODY_BYTES) {
+      respondJson(res, 413, jsonRpcError(null, RPC_INVALID_REQUEST, 'Payload too large'));
+      return undefined;
     }
+    chunks.push(buffer);
   }
 
-  return {
-    serve(): Promise<void> {
-      return new Promise((resolve) => {
-        rl = createInterface({ input, terminal: false });
-        const pending: Promise<void>[] = [];
+  let parsed: unknown;
+  try {
+    parsed = JSON.parse(Buffer.concat(chunks).toString('utf8'));
+  } catch {
+    respondJson(res, 400, jsonRpcError(null, RPC_PARSE_ERROR, 'Parse error'));
+    return undefined;
+  }
+  const messages = Array.isArray(parsed) ? parsed : [parsed];
+  if (messages.length === 0 || !messages.every((message) => isRecord(message) && message.jsonrpc === '2.0' && typeof message.method === 'string')) {
+    respondJson(res, 400, jsonRpcError(null, RPC_INVALID_REQUEST, 'Body must be a JSON-RPC 2.0 request, notification, or batch of them'));
+    return undefined;
+  }
+  return { messages: messages as JsonRpcRequest[], batch: Array.isArray(parsed) };
+}
 
-        rl.on('line', (line) => {
-          const trimmed = line.trim();
-          if (trimmed.length === 0) {
-            return;
-          }
-          let request: JsonRpcRequest;
-          try {
-            request = JSON.parse(trimmed) as JsonRpcRequest;
-          } catch {
-            sendError(null, -32700, 'Parse error');
-            return;
-          }
-          pending.push(handleRequest(request));
-        });
+// Tokens are compared as SHA-256 digests so the comparison takes the same time
+// whatever their length. Returns the digest, in hex, of an accepted token.
+function authorizedBearer(header: string | undefined, tokenDigests: Buffer[]): string | undefined {
+  const match = /^Bearer\s+(\S+)\s*$/i.exec(header ?? '');
+  if (match === null) {
+    return undefined;
+  }
+  const digest = createHash('sha256').update(match[1]!).digest();
+  return tokenDigests.some((expected) => timingSafeEqual(expected, digest)) ? digest.toString('hex') : undefined;
+}
 
-        rl.on('close', () => {
-          void Promise.all(pending).then(() => { resolve(); });
-        });
-      });
-    },
-  };
+function sseEvent(event: string, data: string): string {
+  return `event: ${event}\ndata: ${data}\n\n`;
+}
+
+function respondJson(res: ServerResponse, status: number, body: unknown): void {
+  res.writeHead(status, { 'Content-Type': 'application/json' });
+  res.end(JSON.stringify(body));
 }
 
 export function createMcpServerSurface(config: {
@@ -1303,6 +2134,19 @@ export function createMcpServerSurface(config: {
     traceStore: runtimeService.getStores().traceStore,
   });
 
+  // ── Memory scopes ─────────────────────────────────────────────────────────
+  // Memory tools called with `scope` use that project or team's memory instead of the configured one.
+  // Their reads and writes are audited as the tool's.
+  const memoryRuntimes = new Map<string, SharedRuntimeService>();
+  const memoryRuntime = (args: Record<string, unknown>, toolName: string): SharedRuntimeService => {
+    const scope = asOptionalString(args.scope);
+    const id = `${scope ?? ''}\u0000${toolName}`;
+    const scoped = memoryRuntimes.get(id)
+      ?? (scope === undefined ? runtimeService : runtimeService.withMemoryScope(scope)).withMemoryActor({ kind: 'tool', id: toolName });
+    memoryRuntimes.set(id, scoped);
+    return scoped;
+  };
+
   // ── In-process timer state ────────────────────────────────────────────────
   const timerStore = new Map<string, number>(); // name → startTime ms
   const MAX_ACTIVE_TIMERS = 1000;
@@ -1347,31 +2191,103 @@ export function createMcpServerSurface(config: {
     }
   }
 
+  const compositeTools = loadCompositeTools(basePath);
+  const clientProfiles = readMcpClientProfiles(basePath);
+  const usageConfig = readMcpToolUsageConfig(basePath);
+  // Descriptions tuned by `ax mcp usage --tune` replace the built-in ones; client profiles can still override them.
+  const toolTuning = readMcpToolTuning(basePath);
+  const untunedToolDefinitions = [...TOOL_DEFINITIONS, ...compositeTools.definitions];
+  const registeredToolDefinitions = untunedToolDefinitions.map((definition) => toolTuning[definition.name] === undefined
+    ? definition
+    : { ...definition, description: toolTuning[definition.name]! });
   const requestedToolPrefix = resolveToolPrefix(config.toolPrefix);
   const aliasDefinitions = requestedToolPrefix === undefined
     ? []
-    : TOOL_DEFINITIONS.map((definition) => ({
+    : registeredToolDefinitions.map((definition) => ({
       ...definition,
       name: toPrefixedToolName(definition.name, requestedToolPrefix),
       description: `${definition.description} Alias for ${definition.name}.`,
     }));
-  const toolDefinitions = [...TOOL_DEFINITIONS, ...aliasDefinitions];
-  const canonicalToolDefinitionMap = new Map(TOOL_DEFINITIONS.map((definition) => [definition.name, definition] as const));
+  const toolDefinitions = [...registeredToolDefinitions, ...aliasDefinitions];
+  const canonicalToolDefinitionMap = new Map(registeredToolDefinitions.map((definition) => [definition.name, definition] as const));
   const aliasToCanonicalMap = new Map<string, string>();
-  for (const definition of TOOL_DEFINITIONS) {
+  for (const definition of registeredToolDefinitions) {
     aliasToCanonicalMap.set(toPrefixedToolName(definition.name, DEFAULT_TOOL_PREFIX), definition.name);
     if (requestedToolPrefix !== undefined) {
       aliasToCanonicalMap.set(toPrefixedToolName(definition.name, requestedToolPrefix), definition.name);
     }
   }
+  const toCanonicalToolName = (toolName: string): string => aliasToCanonicalMap.get(toolName) ?? toolName;
+
+  const surface: McpServerSurface = {
+    listTools() {
+      return toolDefinitions.map((definition) => definition.name);
+    },
+
+    listToolDefinitions(client) {
+      if (client === undefined) {
+        return toolDefinitions.map((definition) => ({ ...definition }));
+      }
+      return toolDefinitions
+        .filter((definition) => mcpClientAllowsTool(client, toCanonicalToolName(definition.name)))
+        .map((definition) => ({
+          ...definition,
+          description: describeToolForClient(client, toCanonicalToolName(definition.name), definition.description),
+        }));
+    },
 
-  return {
-    listTools() {
-      return toolDefinitions.map((definition) => definition.name);
+    listCompositeToolErrors() {
+      return [...compositeTools.errors];
+    },
+
+    listClientProfiles() {
+      return [...clientProfiles.profiles];
+    },
+
+    listClientProfileErrors() {
+      return [...clientProfiles.errors];
+    },
+
+    async recordToolCall(call) {
+      const canonicalToolName = toCanonicalToolName(call.toolName);
+      const sessionId = asOptionalString(call.args.sessionId);
+      if (sessionId !== undefined) {
+        await runtimeService.recordSessionToolCall({
+          sessionId,
+          tool: canonicalToolName,
+          args: call.args,
+          success: call.result.success,
+          ...(call.result.error !== undefined ? { error: call.result.error } : {}),
+          durationMs: call.durationMs,
+          basePath,
+        });
+      }
+      if (!usageConfig.enabled) {
+        return;
+      }
+      const definition = canonicalToolDefinitionMap.get(canonicalToolName);
+      const outcome = definition === undefined
+        ? 'unknown'
+        : call.result.success
+          ? 'ok'
+          : validateInput(call.args, definition.inputSchema) !== undefined ? 'invalid' : 'failed';
+      try {
+        await appendMcpToolCall(basePath, usageConfig, {
+          tool: canonicalToolName,
+          ...(canonicalToolName !== call.toolName ? { calledAs: call.toolName } : {}),
+          ...(call.client !== undefined ? { client: call.client.name } : {}),
+          outcome,
+          arguments: Object.keys(call.args).sort(),
+          ...(call.result.error !== undefined ? { error: call.result.error } : {}),
+          durationMs: call.durationMs,
+        });
+      } catch {
+        // Usage tracking never fails the call it describes.
+      }
     },
 
-    listToolDefinitions() {
-      return toolDefinitions.map((definition) => ({ ...definition }));
+    toolUsageReport(filter = {}) {
+      return queryMcpToolUsage(basePath, usageConfig.enabled, untunedToolDefinitions, filter);
     },
 
     listResources() {
@@ -1542,9 +2458,9 @@ export function createMcpServerSurface(config: {
       }
     },
 
-    async invokeTool(toolName, args = {}) {
+    async invokeTool(toolName, args = {}, client) {
       try {
-        const canonicalToolName = aliasToCanonicalMap.get(toolName) ?? toolName;
+        const canonicalToolName = toCanonicalToolName(toolName);
         const definition = canonicalToolDefinitionMap.get(canonicalToolName);
         if (definition === undefined) {
           return {
@@ -1553,6 +2469,21 @@ export function createMcpServerSurface(config: {
           };
         }
 
+        // Composite steps are called without a client, so they may use tools the client is not shown.
+        if (client !== undefined && !mcpClientAllowsTool(client, canonicalToolName)) {
+          return {
+            success: false,
+            error: `Tool ${toolName} is not available to ${client.name} clients`,
+          };
+        }
+        const inputBytes = client?.maxInputBytes !== undefined ? Buffer.byteLength(JSON.stringify(args)) : 0;
+        if (client?.maxInputBytes !== undefined && inputBytes > client.maxInputBytes) {
+          return {
+            success: false,
+            error: `Arguments to ${toolName} are ${inputBytes} bytes; ${client.name} clients may send at most ${client.maxInputBytes}`,
+          };
+        }
+
         const validationError = validateInput(args, definition.inputSchema);
         if (validationError !== undefined) {
           return {
@@ -1561,6 +2492,16 @@ export function createMcpServerSurface(config: {
           };
         }
 
+        const composite = compositeTools.tools.get(canonicalToolName);
+        if (composite !== undefined) {
+          const result = await runCompositeTool(composite, args, (stepTool, stepArgs) => surface.invokeTool(stepTool, stepArgs));
+          return {
+            success: result.success,
+            data: { output: result.output, steps: result.steps },
+            error: result.error,
+          };
+        }
+
         switch (canonicalToolName) {
           case 'workflow.run':
             return {
@@ -1593,6 +2534,17 @@ export function createMcpServerSurface(config: {
                 basePath: asOptionalString(args.basePath),
               }),
             };
+          case 'workflow.done':
+            return {
+              success: true,
+              data: await runtimeService.checkDefinitionOfDone({
+                workflowId: asString(args.workflowId, 'workflowId'),
+                workflowDir: asOptionalString(args.workflowDir),
+                basePath: asOptionalString(args.basePath),
+                input: asInput(args.input),
+                verify: args.verify === true,
+              }),
+            };
           case 'trace.get':
             return {
               success: true,
@@ -1663,6 +2615,7 @@ export function createMcpServerSurface(config: {
                 agentId: asString(args.agentId, 'agentId'),
                 task: asOptionalString(args.task),
                 input: isRecord(args.input) ? args.input : undefined,
+                files: asStringArray(args.files),
                 traceId: asOptionalString(args.traceId),
                 sessionId: asOptionalString(args.sessionId),
                 basePath: asOptionalString(args.basePath),
@@ -1818,26 +2771,50 @@ export function createMcpServerSurface(config: {
               success: true,
               data: await runtimeService.listReviewTraces(asOptionalNumber(args.limit)),
             };
+          case 'tech_debt.list':
+            return {
+              success: true,
+              data: await runtimeService.listTechDebt({
+                paths: asStringArray(args.paths),
+                kinds: asStringArray(args.kinds)?.filter(isTechDebtKind),
+                owner: asOptionalString(args.owner),
+                blame: args.blame === true,
+                limit: asOptionalNumber(args.limit),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
           case 'memory.retrieve':
             return {
               success: true,
-              data: await runtimeService.getMemory(
+              data: await memoryRuntime(args, canonicalToolName).getMemory(
                 asString(args.key, 'key'),
                 asOptionalString(args.namespace),
               ),
             };
           case 'memory.search':
+            if (args.pageSize !== undefined || args.cursor !== undefined) {
+              return {
+                success: true,
+                data: await memoryRuntime(args, canonicalToolName).searchMemoryPage(
+                  asString(args.query, 'query'),
+                  asOptionalString(args.namespace),
+                  memoryFilterArgs(args),
+                  { pageSize: asOptionalNumber(args.pageSize), cursor: asOptionalString(args.cursor) },
+                ),
+              };
+            }
             return {
               success: true,
-              data: await runtimeService.searchMemory(
+              data: await memoryRuntime(args, canonicalToolName).searchMemory(
                 asString(args.query, 'query'),
                 asOptionalString(args.namespace),
+                memoryFilterArgs(args),
               ),
             };
           case 'memory.delete':
             return {
               success: true,
-              data: { deleted: await runtimeService.deleteMemory(
+              data: { deleted: await memoryRuntime(args, canonicalToolName).deleteMemory(
                 asString(args.key, 'key'),
                 asOptionalString(args.namespace),
               ) },
@@ -1845,42 +2822,73 @@ export function createMcpServerSurface(config: {
           case 'memory.store':
             return {
               success: true,
-              data: await runtimeService.storeMemory({
+              data: await memoryRuntime(args, canonicalToolName).storeMemory({
                 key: asString(args.key, 'key'),
                 namespace: asOptionalString(args.namespace),
                 value: args.value,
+                tags: asStringArray(args.tags),
+                ttlMs: asOptionalNumber(args.ttlMs),
+                agentId: asOptionalString(args.agentId),
+                importance: asOptionalNumber(args.importance),
               }),
             };
           case 'memory.list':
             return {
               success: true,
-              data: await runtimeService.listMemory(asOptionalString(args.namespace)),
+              data: await memoryRuntime(args, canonicalToolName).listMemory(asOptionalString(args.namespace)),
             };
           case 'semantic.store':
+            if (asOptionalString(args.path) !== undefined) {
+              return {
+                success: true,
+                data: await memoryRuntime(args, canonicalToolName).storeSemanticFile({
+                  key: asString(args.key, 'key'),
+                  path: asString(args.path, 'path'),
+                  namespace: asOptionalString(args.namespace),
+                  content: asString(args.content, 'content'),
+                  tags: asStringArray(args.tags),
+                  metadata: isRecord(args.metadata) ? args.metadata : undefined,
+                  chunking: asOptionalChunkingStrategy(args.chunking),
+                  ttlMs: asOptionalNumber(args.ttlMs),
+                  agentId: asOptionalString(args.agentId),
+                  importance: asOptionalNumber(args.importance),
+                }),
+              };
+            }
             return {
               success: true,
-              data: await runtimeService.storeSemantic({
+              data: await memoryRuntime(args, canonicalToolName).storeSemantic({
                 key: asString(args.key, 'key'),
                 namespace: asOptionalString(args.namespace),
                 content: asString(args.content, 'content'),
                 tags: asStringArray(args.tags),
                 metadata: isRecord(args.metadata) ? args.metadata : undefined,
+                ttlMs: asOptionalNumber(args.ttlMs),
+                agentId: asOptionalString(args.agentId),
+                importance: asOptionalNumber(args.importance),
               }),
             };
           case 'semantic.search':
             return {
               success: true,
-              data: await runtimeService.searchSemantic(asString(args.query, 'query'), {
+              data: await memoryRuntime(args, canonicalToolName).searchSemantic(asString(args.query, 'query'), {
                 namespace: asOptionalString(args.namespace),
                 filterTags: asStringArray(args.filterTags),
                 topK: asOptionalNumber(args.topK),
                 minSimilarity: asOptionalFloat(args.minSimilarity),
+                mode: asOptionalSemanticSearchMode(args.mode),
+                keywordWeight: asOptionalFloat(args.keywordWeight),
+                includeGenerated: args.includeGenerated === true,
+                agentId: asOptionalString(args.agentId),
+                since: asOptionalString(args.since),
+                until: asOptionalString(args.until),
+                path: asOptionalString(args.path),
               }),
             };
           case 'semantic.get':
             return {
               success: true,
-              data: await runtimeService.getSemantic(
+              data: await memoryRuntime(args, canonicalToolName).getSemantic(
                 asString(args.key, 'key'),
                 asOptionalString(args.namespace),
               ),
@@ -1888,7 +2896,7 @@ export function createMcpServerSurface(config: {
           case 'semantic.list':
             return {
               success: true,
-              data: await runtimeService.listSemantic({
+              data: await memoryRuntime(args, canonicalToolName).listSemantic({
                 namespace: asOptionalString(args.namespace),
                 keyPrefix: asOptionalString(args.keyPrefix),
                 filterTags: asStringArray(args.filterTags),
@@ -1898,12 +2906,12 @@ export function createMcpServerSurface(config: {
           case 'semantic.delete':
             return {
               success: true,
-              data: { deleted: await runtimeService.deleteSemantic(asString(args.key, 'key'), asOptionalString(args.namespace)) },
+              data: { deleted: await memoryRuntime(args, canonicalToolName).deleteSemantic(asString(args.key, 'key'), asOptionalString(args.namespace)) },
             };
           case 'semantic.stats':
             return {
               success: true,
-              data: await runtimeService.semanticStats(asOptionalString(args.namespace)),
+              data: await memoryRuntime(args, canonicalToolName).semanticStats(asOptionalString(args.namespace)),
             };
           case 'semantic.clear':
             if (args.confirm !== true) {
@@ -1911,7 +2919,16 @@ export function createMcpServerSurface(config: {
             }
             return {
               success: true,
-              data: { cleared: await runtimeService.clearSemantic(asString(args.namespace, 'namespace')) },
+              data: { cleared: await memoryRuntime(args, canonicalToolName).clearSemantic(asString(args.namespace, 'namespace')) },
+            };
+          case 'semantic.index_docs':
+            return {
+              success: true,
+              data: await memoryRuntime(args, canonicalToolName).indexDocComments({
+                paths: asStringArray(args.paths),
+                namespace: asOptionalString(args.namespace),
+                agentId: asOptionalString(args.agentId),
+              }),
             };
           case 'feedback.submit':
             return {
@@ -2008,10 +3025,31 @@ export function createMcpServerSurface(config: {
             if (createDirectories) {
               await mkdir(dirname(filePath), { recursive: true });
             }
-            await writeFile(filePath, asString(args.content, 'content'), 'utf8');
+            let content = asString(args.content, 'content');
+            let adjustments: string[] = [];
+            const original = await pathExists(filePath) ? await readFile(filePath, 'utf8') : undefined;
+            if (args.preserveStyle !== false && original !== undefined) {
+              const conformed = runtimeService.conformToFileStyle({
+                path: filePath,
+                content,
+                original,
+              });
+              content = conformed.content;
+              adjustments = conformed.adjustments;
+            }
+            // The write still happens: regenerating may be exactly what the agent is doing by hand.
+            const generated = original !== undefined ? detectGeneratedCode(asString(args.path, 'path'), original) : undefined;
+            await writeFile(filePath, content, 'utf8');
             return {
               success: true,
-              data: { path: filePath, written: true },
+              data: {
+                path: filePath,
+                written: true,
+                ...(adjustments.length > 0 ? { restyled: adjustments } : {}),
+                ...(generated !== undefined
+                  ? { warning: `${asString(args.path, 'path')} looks generated (${generated.reason}); hand edits are lost when it is regenerated, so change its source and regenerate instead.` }
+                  : {}),
+              },
             };
           }
           case 'directory.create': {
@@ -2040,6 +3078,168 @@ export function createMcpServerSurface(config: {
                 stat: typeof args.stat === 'boolean' ? args.stat : undefined,
               }),
             };
+          case 'diff.structural':
+            return {
+              success: true,
+              data: await runtimeService.structuralDiff({
+                base: asOptionalString(args.base),
+                head: asOptionalString(args.head),
+                paths: asStringArray(args.paths),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.api_diff':
+            return {
+              success: true,
+              data: await runtimeService.apiDiff({
+                base: asOptionalString(args.base),
+                head: asOptionalString(args.head),
+                paths: asStringArray(args.paths),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.find_symbols':
+            return {
+              success: true,
+              data: await runtimeService.findSymbols({
+                query: asString(args.query, 'query'),
+                paths: asStringArray(args.paths),
+                limit: asOptionalNumber(args.limit),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.rank_symbols':
+            return {
+              success: true,
+              data: await runtimeService.rankSymbols({
+                paths: asStringArray(args.paths),
+                limit: asOptionalNumber(args.limit),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.get_metrics':
+            return {
+              success: true,
+              data: await runtimeService.getCodeMetrics({
+                paths: asStringArray(args.paths),
+                query: asOptionalString(args.query),
+                sort: asOptionalString(args.sort) as CodeMetricsSort | undefined,
+                minCognitive: asOptionalNumber(args.minCognitive),
+                minCyclomatic: asOptionalNumber(args.minCyclomatic),
+                limit: asOptionalNumber(args.limit),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.find_duplicates':
+            return {
+              success: true,
+              data: await runtimeService.findDuplicates({
+                paths: asStringArray(args.paths),
+                minTokens: asOptionalNumber(args.minTokens),
+                minLines: asOptionalNumber(args.minLines),
+                limit: asOptionalNumber(args.limit),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.rename_impact':
+            return {
+              success: true,
+              data: await runtimeService.analyzeRenameImpact({
+                symbol: asString(args.symbol, 'symbol'),
+                paths: asStringArray(args.paths),
+                limit: asOptionalNumber(args.limit),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.definition':
+            return {
+              success: true,
+              data: await runtimeService.findDefinition({
+                symbol: asOptionalString(args.symbol),
+                path: asOptionalString(args.path),
+                line: asOptionalNumber(args.line),
+                column: asOptionalNumber(args.column),
+                paths: asStringArray(args.paths),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.references':
+            return {
+              success: true,
+              data: await runtimeService.findReferences({
+                symbol: asOptionalString(args.symbol),
+                path: asOptionalString(args.path),
+                line: asOptionalNumber(args.line),
+                column: asOptionalNumber(args.column),
+                paths: asStringArray(args.paths),
+                includeDefinitions: typeof args.includeDefinitions === 'boolean' ? args.includeDefinitions : undefined,
+                limit: asOptionalNumber(args.limit),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.get_call_graph':
+            return {
+              success: true,
+              data: await runtimeService.getCallGraph({
+                symbol: asOptionalString(args.symbol),
+                direction: asOptionalCallGraphDirection(args.direction),
+                depth: asOptionalNumber(args.depth),
+                paths: asStringArray(args.paths),
+                limit: asOptionalNumber(args.limit),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.implementations':
+            return {
+              success: true,
+              data: await runtimeService.findImplementations({
+                symbol: asString(args.symbol, 'symbol'),
+                paths: asStringArray(args.paths),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.test_map':
+            return {
+              success: true,
+              data: await runtimeService.mapGoTests({
+                paths: asStringArray(args.paths),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.export_lsif':
+            return {
+              success: true,
+              data: await runtimeService.exportLsif({
+                paths: asStringArray(args.paths),
+                outputPath: asOptionalString(args.outputPath),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.include_graph':
+            return {
+              success: true,
+              data: await runtimeService.buildIncludeGraph({
+                paths: asStringArray(args.paths),
+                includeDirs: asStringArray(args.includeDirs),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          case 'code.import_graph': {
+            const graph = await runtimeService.buildImportGraph({
+              paths: asStringArray(args.paths),
+              basePath: asOptionalString(args.basePath),
+            });
+            return { success: true, data: args.format === 'dot' ? { ...graph, dot: formatImportGraphDot(graph) } : graph };
+          }
+          case 'code.go_embeds':
+            return {
+              success: true,
+              data: await runtimeService.findGoEmbeds({
+                paths: asStringArray(args.paths),
+                files: asStringArray(args.files),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
           case 'commit.prepare':
             return {
               success: true,
@@ -2176,7 +3376,7 @@ export function createMcpServerSurface(config: {
           }
           // ── Memory import/export ────────────────────────────────────────
           case 'memory.export': {
-            const entries = await runtimeService.listMemory(asOptionalString(args.namespace));
+            const entries = await memoryRuntime(args, canonicalToolName).listMemory(asOptionalString(args.namespace));
             return { success: true, data: { entries, count: entries.length } };
           }
           case 'memory.import': {
@@ -2187,14 +3387,96 @@ export function createMcpServerSurface(config: {
               const key = asOptionalString(e['key']);
               if (key === undefined) { skipped++; continue; }
               if (!overwrite) {
-                const existing = await runtimeService.getMemory(key, asOptionalString(e['namespace']));
+                const existing = await memoryRuntime(args, canonicalToolName).getMemory(key, asOptionalString(e['namespace']));
                 if (existing !== undefined) { skipped++; continue; }
               }
-              await runtimeService.storeMemory({ key, namespace: asOptionalString(e['namespace']), value: e['value'] });
+              await memoryRuntime(args, canonicalToolName).storeMemory({ key, namespace: asOptionalString(e['namespace']), value: e['value'] });
               imported++;
             }
             return { success: true, data: { imported, skipped } };
           }
+          case 'memory.dedup': {
+            const result = await memoryRuntime(args, canonicalToolName).dedupeMemory({
+              namespace: asOptionalString(args.namespace),
+              threshold: asOptionalNumber(args.threshold),
+              dryRun: args.dryRun === true,
+            });
+            return { success: true, data: result };
+          }
+          case 'memory.compact': {
+            const result = await memoryRuntime(args, canonicalToolName).compactMemory({
+              namespace: asOptionalString(args.namespace),
+              minAgeDays: asOptionalNumber(args.minAgeDays),
+              minClusterSize: asOptionalNumber(args.minClusterSize),
+              similarity: asOptionalNumber(args.similarity),
+              dryRun: args.dryRun === true,
+            });
+            return { success: true, data: result };
+          }
+          case 'memory.facets': {
+            const result = await memoryRuntime(args, canonicalToolName).memoryFacets({
+              ...memoryFilterArgs(args),
+              namespace: asOptionalString(args.namespace),
+              limit: asOptionalNumber(args.limit),
+            });
+            return { success: true, data: result };
+          }
+          case 'memory.feedback': {
+            if (typeof args.helpful !== 'boolean') {
+              throw new Error('helpful is required');
+            }
+            const result = await memoryRuntime(args, canonicalToolName).recordMemoryFeedback({
+              key: asString(args.key, 'key'),
+              namespace: asOptionalString(args.namespace),
+              kind: args.kind === 'semantic' ? 'semantic' : 'memory',
+              helpful: args.helpful,
+              query: asOptionalString(args.query),
+              agentId: asOptionalString(args.agentId),
+            });
+            return { success: true, data: result };
+          }
+          case 'memory.graph': {
+            const result = await memoryRuntime(args, canonicalToolName).queryKnowledgeGraph({
+              query: asString(args.query, 'query'),
+              depth: asOptionalNumber(args.depth),
+              limit: asOptionalNumber(args.limit),
+              namespace: asOptionalString(args.namespace),
+            });
+            return { success: true, data: result };
+          }
+          case 'memory.redactions':
+            return {
+              success: true,
+              data: await runtimeService.queryMemoryRedactions({
+                rule: asOptionalString(args.rule),
+                namespace: asOptionalString(args.namespace),
+                since: asOptionalString(args.since),
+                until: asOptionalString(args.until),
+                limit: asOptionalNumber(args.limit),
+              }),
+            };
+          case 'memory.audit': {
+            const actorKind = asOptionalString(args.actorKind);
+            const action = asOptionalString(args.action);
+            const result = await runtimeService.queryMemoryAudit({
+              actor: asOptionalString(args.actor),
+              actorKind: actorKind !== undefined && isMemoryActorKind(actorKind) ? actorKind : undefined,
+              action: action !== undefined && isMemoryAuditAction(action) ? action : undefined,
+              namespace: asOptionalString(args.namespace),
+              key: asOptionalString(args.key),
+              since: asOptionalString(args.since),
+              until: asOptionalString(args.until),
+              limit: asOptionalNumber(args.limit),
+            });
+            return { success: true, data: result };
+          }
+          case 'memory.as_of': {
+            const result = await memoryRuntime(args, canonicalToolName).memoryAsOf(asString(args.asOf, 'asOf'), {
+              namespace: asOptionalString(args.namespace),
+              key: asOptionalString(args.key),
+            });
+            return { success: true, data: result };
+          }
           // ── Timers ──────────────────────────────────────────────────────
           case 'timer.start': {
             const name = asString(args.name, 'name');
@@ -2313,7 +3595,7 @@ export function createMcpServerSurface(config: {
           }
           // ── Memory extras ──────────────────────────────────────────────
           case 'memory.stats': {
-            const entries = await runtimeService.listMemory(asOptionalString(args.namespace));
+            const entries = await memoryRuntime(args, canonicalToolName).listMemory(asOptionalString(args.namespace));
             const byNs: Record<string, number> = {};
             for (const e of entries) {
               const ns: string = e.namespace ?? 'default';
@@ -2323,16 +3605,16 @@ export function createMcpServerSurface(config: {
           }
           case 'memory.clear': {
             const ns = asString(args.namespace, 'namespace');
-            const entries = await runtimeService.listMemory(ns);
+            const entries = await memoryRuntime(args, canonicalToolName).listMemory(ns);
             let deleted = 0;
-            for (const e of entries) { if (await runtimeService.deleteMemory(e.key, ns)) deleted++; }
+            for (const e of entries) { if (await memoryRuntime(args, canonicalToolName).deleteMemory(e.key, ns)) deleted++; }
             return { success: true, data: { namespace: ns, deleted } };
           }
           case 'memory.bulk_delete': {
             const keys = asStringArray(args.keys) ?? [];
             const ns = asOptionalString(args.namespace);
             let deleted = 0;
-            for (const k of keys) { if (await runtimeService.deleteMemory(k, ns)) deleted++; }
+            for (const k of keys) { if (await memoryRuntime(args, canonicalToolName).deleteMemory(k, ns)) deleted++; }
             return { success: true, data: { deleted, requested: keys.length } };
           }
           // ── Telemetry / metrics ─────────────────────────────────────────
@@ -2417,6 +3699,19 @@ export function createMcpServerSurface(config: {
             task.status = 'pending'; task.updatedAt = new Date().toISOString(); delete task.error;
             return { success: true, data: task };
           }
+          case 'task.clarify':
+            return {
+              success: true,
+              data: await runtimeService.clarifyTask({
+                task: asString(args.task, 'task'),
+                goal: asOptionalString(args.goal),
+                files: asStringArray(args.files),
+                threshold: asOptionalNumber(args.threshold),
+                maxQuestions: asOptionalNumber(args.maxQuestions),
+                autoAnswer: typeof args.autoAnswer === 'boolean' ? args.autoAnswer : undefined,
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
           // ── Scaffold (MCP surface) ──────────────────────────────────────
           case 'scaffold.contract':
           case 'scaffold.domain':
@@ -2446,6 +3741,69 @@ export function createMcpServerSurface(config: {
               },
             };
           }
+          // ── Evaluation rubrics ──────────────────────────────────────────
+          case 'rubric.list':
+            return {
+              success: true,
+              data: await runtimeService.listRubrics({ basePath: asOptionalString(args.basePath) }),
+            };
+          case 'rubric.evaluate':
+            return {
+              success: true,
+              data: await runtimeService.evaluateRubric({
+                rubricId: asString(args.rubricId, 'rubricId'),
+                output: asString(args.output, 'output'),
+                changedFiles: asStringArray(args.changedFiles),
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
+          // ── Deploy verification ─────────────────────────────────────────
+          case 'canary.verify':
+            return {
+              success: true,
+              data: await runtimeService.verifyCanary({
+                workflowId: asOptionalString(args.workflowId),
+                endpoints: asStringArray(args.endpoints),
+                windowMs: asOptionalNumber(args.windowMs),
+                intervalMs: asOptionalNumber(args.intervalMs),
+                baseline: isRecord(args.baseline)
+                  ? {
+                    errorRate: asOptionalNumber(args.baseline.errorRate),
+                    p95LatencyMs: asOptionalNumber(args.baseline.p95LatencyMs),
+                  }
+                  : undefined,
+                rollbackWorkflowId: asOptionalString(args.rollbackWorkflowId),
+                autoRollback: typeof args.autoRollback === 'boolean' ? args.autoRollback : undefined,
+                traceId: asOptionalString(args.traceId),
+                sessionId: asOptionalString(args.sessionId),
+                basePath: asOptionalString(args.basePath),
+                surface: 'mcp',
+              }),
+            };
+          case 'release.rollback_playbook':
+            return {
+              success: true,
+              data: await runtimeService.generateRollbackPlaybook({
+                base: asOptionalString(args.base),
+                head: asOptionalString(args.head),
+                releaseVersion: asOptionalString(args.releaseVersion),
+                releaseTraceId: asOptionalString(args.releaseTraceId),
+                outputPath: asOptionalString(args.outputPath),
+                pullRequest: asOptionalString(args.pullRequest),
+                traceId: asOptionalString(args.traceId),
+                sessionId: asOptionalString(args.sessionId),
+                basePath: asOptionalString(args.basePath),
+                surface: 'mcp',
+              }),
+            };
+          case 'dependency.audit':
+            return {
+              success: true,
+              data: await runtimeService.auditDependencies({
+                checkUpdates: typeof args.checkUpdates === 'boolean' ? args.checkUpdates : undefined,
+                basePath: asOptionalString(args.basePath),
+              }),
+            };
           default:
             return {
               success: false,
@@ -2460,6 +3818,49 @@ export function createMcpServerSurface(config: {
       }
     },
   };
+  return surface;
+}
+
+// Steps may only call built-in tools (canonical or ax_ names), so a composite
+// cannot recurse into another composite.
+function loadCompositeTools(basePath: string): {
+  tools: Map<string, CompositeToolDefinition>;
+  definitions: McpToolDefinition[];
+  errors: string[];
+} {
+  const { tools, errors } = readCompositeTools(basePath);
+  const builtinNames = new Map<string, string>();
+  for (const definition of TOOL_DEFINITIONS) {
+    builtinNames.set(definition.name, definition.name);
+    builtinNames.set(toPrefixedToolName(definition.name, DEFAULT_TOOL_PREFIX), definition.name);
+  }
+
+  const registered = new Map<string, CompositeToolDefinition>();
+  for (const tool of tools) {
+    if (builtinNames.has(tool.name)) {
+      errors.push(`Composite tool ${tool.name} conflicts with a built-in tool`);
+      continue;
+    }
+    const unknownStep = tool.steps.find((step) => !builtinNames.has(step.tool));
+    if (unknownStep !== undefined) {
+      errors.push(`Composite tool ${tool.name} step ${unknownStep.id} calls unknown tool ${unknownStep.tool}`);
+      continue;
+    }
+    registered.set(tool.name, {
+      ...tool,
+      steps: tool.steps.map((step) => ({ ...step, tool: builtinNames.get(step.tool)! })),
+    });
+  }
+
+  return {
+    tools: registered,
+    definitions: [...registered.values()].map((tool) => ({
+      name: tool.name,
+      description: `${tool.description} Composite: ${tool.steps.map((step) => step.tool).join(' -> ')}.`,
+      inputSchema: (tool.inputSchema as JsonSchema | undefined) ?? objectSchema({}, [], true),
+    })),
+    errors,
+  };
 }
 
 function resolveToolPrefix(explicitPrefix: string | undefined): string | undefined {
@@ -2643,6 +4044,16 @@ function asStringArray(value: unknown): string[] | undefined {
   return value.filter((entry): entry is string => typeof entry === 'string' && entry.length > 0);
 }
 
+function memoryFilterArgs(args: Record<string, unknown>): MemoryFilter {
+  return {
+    tags: asStringArray(args.tags),
+    agentId: asOptionalString(args.agentId),
+    since: asOptionalString(args.since),
+    until: asOptionalString(args.until),
+    path: asOptionalString(args.path),
+  };
+}
+
 function asParallelTasks(value: unknown): Array<{
   taskId: string;
   agentId: string;
@@ -2697,6 +4108,30 @@ function asOptionalReviewFocus(value: unknown): ReviewFocus | undefined {
     : undefined;
 }
 
+function asOptionalChunkingStrategy(value: unknown): ChunkingStrategy | undefined {
+  return value === 'declarations' || value === 'lines' || value === 'whole' ? value : undefined;
+}
+
+function asOptionalSemanticSearchMode(value: unknown): SemanticSearchMode | undefined {
+  return value === 'hybrid' || value === 'vector' || value === 'keyword' ? value : undefined;
+}
+
+function asOptionalCallGraphDirection(value: unknown): CallGraphDirection | undefined {
+  return value === 'callees' || value === 'callers' || value === 'both' ? value : undefined;
+}
+
+function isMemoryActorKind(value: string): value is MemoryActorKind {
+  return value === 'agent' || value === 'tool' || value === 'user';
+}
+
+function isMemoryAuditAction(value: string): value is MemoryAuditAction {
+  return value === 'read' || value === 'write' || value === 'delete';
+}
+
+function isTechDebtKind(value: string): value is TechDebtKind {
+  return value === 'todo' || value === 'fixme' || value === 'hack' || value === 'bug' || value === 'deprecated';
+}
+
 function isRecord(value: unknown): value is Record<string, unknown> {
   return value !== null && typeof value === 'object' && !Array.isArray(value);
 }
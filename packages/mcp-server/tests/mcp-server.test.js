@@ -1,13 +1,13 @@
 import { mkdirSync } from 'node:fs';
 import { execFile } from 'node:child_process';
-import { rm, writeFile } from 'node:fs/promises';
+import { readFile, rm, writeFile } from 'node:fs/promises';
 import { join } from 'node:path';
 import { Readable, Writable } from 'node:stream';
 import { promisify } from 'node:util';
 import { afterEach, describe, expect, it } from 'vitest';
-import { createSharedRuntimeService } from '@defai.digital/shared-runtime';
+import { createSharedRuntimeService, writeMcpToolTuning } from '@defai.digital/shared-runtime';
 import { initCommand, setupCommand } from '../../cli/src/commands/index.js';
-import { createMcpServerSurface, createMcpStdioServer } from '../src/index.js';
+import { createMcpServerSurface, createMcpStdioServer, startMcpHttpServer } from '../src/index.js';
 const execFileAsync = promisify(execFile);
 function createTempDir() {
     const dir = join(process.cwd(), '.tmp', `mcp-surface-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
@@ -898,6 +898,273 @@ describe('mcp server surface', () => {
         const shutdown = responses.find((entry) => entry.id === 4);
         expect(shutdown?.result).toEqual({});
     });
+    it('serves MCP over Streamable HTTP to clients with a bearer token', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const server = await startMcpHttpServer({ basePath: tempDir, tokens: ['secret-a', 'secret-b'], allowedOrigins: ['https://app.example.com'] });
+        const url = `http://127.0.0.1:${server.port}/mcp`;
+        const post = (body, headers = {}) => fetch(url, {
+            method: 'POST',
+            headers: { Authorization: 'Bearer secret-b', 'Content-Type': 'application/json', Accept: 'application/json, text/event-stream', ...headers },
+            body: JSON.stringify(body),
+        });
+        try {
+            expect((await post({ jsonrpc: '2.0', id: 1, method: 'ping' }, { Authorization: 'Bearer wrong' })).status).toBe(401);
+            expect((await post({ jsonrpc: '2.0', id: 1, method: 'ping' }, { Origin: 'https://evil.example.com' })).status).toBe(403);
+            const initialized = await post({ jsonrpc: '2.0', id: 1, method: 'initialize', params: { protocolVersion: '2024-11-05', clientInfo: { name: 'remote' } } }, { Origin: 'https://app.example.com' });
+            expect(initialized.status).toBe(200);
+            expect(initialized.headers.get('access-control-allow-origin')).toBe('https://app.example.com');
+            const sessionId = initialized.headers.get('mcp-session-id');
+            expect(sessionId).toBeTruthy();
+            expect((await initialized.json()).result.serverInfo.name).toBe('automatosx');
+            const session = { 'Mcp-Session-Id': sessionId };
+            expect((await post({ jsonrpc: '2.0', method: 'notifications/initialized' }, session)).status).toBe(202);
+            const batch = await post([
+                { jsonrpc: '2.0', id: 2, method: 'tools/list' },
+                { jsonrpc: '2.0', id: 3, method: 'tools/call', params: { name: 'memory.store', arguments: { key: 'remote', value: { note: 'hello' } } } },
+            ], session);
+            const responses = await batch.json();
+            expect(responses.map((response) => response.id)).toEqual([2, 3]);
+            expect(responses[0].result.tools.some((tool) => tool.name === 'workflow.run')).toBe(true);
+            expect(responses[1].result.isError).toBeUndefined();
+            const streamed = await post({ jsonrpc: '2.0', id: 4, method: 'ping' }, { ...session, Accept: 'text/event-stream' });
+            expect(streamed.headers.get('content-type')).toBe('text/event-stream');
+            expect(await streamed.text()).toBe('event: message\ndata: {"jsonrpc":"2.0","id":4,"result":{}}\n\n');
+            expect((await post({ jsonrpc: '2.0', id: 5, method: 'tools/list' })).status).toBe(400);
+            // A session only answers the token that opened it.
+            expect((await post({ jsonrpc: '2.0', id: 5, method: 'tools/list' }, { ...session, Authorization: 'Bearer secret-a' })).status).toBe(404);
+            expect((await fetch(url, { method: 'DELETE', headers: { Authorization: 'Bearer secret-a', ...session } })).status).toBe(404);
+            expect((await fetch(url, { method: 'DELETE', headers: { Authorization: 'Bearer secret-b', ...session } })).status).toBe(204);
+            expect((await post({ jsonrpc: '2.0', id: 6, method: 'tools/list' }, session)).status).toBe(404);
+        }
+        finally {
+            await server.close();
+        }
+    });
+    it('rate limits HTTP clients per token and caps open sessions', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const server = await startMcpHttpServer({ basePath: tempDir, tokens: ['secret-a', 'secret-b'], rateLimit: { maxRequests: 2, windowMs: 60_000 }, maxSessions: 4 });
+        const url = `http://127.0.0.1:${server.port}/mcp`;
+        const post = async (token, body, sessionId) => fetch(url, {
+            method: 'POST',
+            headers: { Authorization: `Bearer ${token}`, 'Content-Type': 'application/json', ...(sessionId !== undefined ? { 'Mcp-Session-Id': sessionId } : {}) },
+            body: JSON.stringify(body),
+        });
+        const initialize = async (token) => (await post(token, { jsonrpc: '2.0', id: 1, method: 'initialize', params: {} })).headers.get('mcp-session-id');
+        const listTools = async (token, sessionId) => ((await (await post(token, { jsonrpc: '2.0', id: 2, method: 'tools/list' }, sessionId)).json()));
+        try {
+            const first = await initialize('secret-a');
+            const second = await initialize('secret-a');
+            expect((await listTools('secret-a', first)).result).toBeDefined();
+            expect((await listTools('secret-a', second)).result).toBeDefined();
+            // A fresh session doesn't reset the token's budget.
+            expect((await listTools('secret-a', second)).error.message).toContain('Rate limit exceeded');
+            // Another token still has its share, and opening it drops nothing.
+            const other = await initialize('secret-b');
+            expect((await listTools('secret-b', other)).result).toBeDefined();
+            expect((await post('secret-a', { jsonrpc: '2.0', id: 3, method: 'ping' }, first)).status).toBe(200);
+            // A token past its share gives up its own least recently used session.
+            const third = await initialize('secret-a');
+            expect(third).not.toBe(second);
+            expect((await post('secret-a', { jsonrpc: '2.0', id: 3, method: 'ping' }, second)).status).toBe(404);
+            expect((await post('secret-a', { jsonrpc: '2.0', id: 3, method: 'ping' }, first)).status).toBe(200);
+            expect((await post('secret-b', { jsonrpc: '2.0', id: 3, method: 'ping' }, other)).status).toBe(200);
+        }
+        finally {
+            await server.close();
+        }
+    });
+    it('serves MCP over the HTTP+SSE transport', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const server = await startMcpHttpServer({ basePath: tempDir, tokens: ['secret'] });
+        const base = `http://127.0.0.1:${server.port}`;
+        const authorization = { Authorization: 'Bearer secret' };
+        try {
+            expect((await fetch(`${base}/sse`)).status).toBe(401);
+            const stream = await fetch(`${base}/sse`, { headers: authorization });
+            expect(stream.headers.get('content-type')).toBe('text/event-stream');
+            const reader = stream.body.getReader();
+            const decoder = new TextDecoder();
+            let received = '';
+            const nextEvent = async () => {
+                while (!received.includes('\n\n')) {
+                    const chunk = await reader.read();
+                    received += decoder.decode(chunk.value);
+                }
+                const [raw = '', ...rest] = received.split('\n\n');
+                received = rest.join('\n\n');
+                const fields = Object.fromEntries(raw.split('\n').map((line) => [line.slice(0, line.indexOf(':')), line.slice(line.indexOf(':') + 2)]));
+                return { event: fields.event, data: fields.data };
+            };
+            const endpoint = await nextEvent();
+            expect(endpoint.event).toBe('endpoint');
+            expect(endpoint.data.startsWith('/messages?sessionId=')).toBe(true);
+            const posted = await fetch(`${base}${endpoint.data}`, {
+                method: 'POST',
+                headers: { ...authorization, 'Content-Type': 'application/json' },
+                body: JSON.stringify({ jsonrpc: '2.0', id: 7, method: 'resources/list' }),
+            });
+            expect(posted.status).toBe(202);
+            const message = await nextEvent();
+            expect(message.event).toBe('message');
+            expect(JSON.parse(message.data).result.resources.some((resource) => resource.uri === 'ax://workflow/catalog')).toBe(true);
+            expect((await fetch(`${base}/messages?sessionId=unknown`, { method: 'POST', headers: authorization, body: '{}' })).status).toBe(404);
+            await reader.cancel();
+        }
+        finally {
+            await server.close();
+        }
+    });
+    it('registers composite tools from config and runs their steps with mapped arguments', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        mkdirSync(join(tempDir, '.automatosx'), { recursive: true });
+        await writeFile(join(tempDir, '.automatosx', 'config.json'), `${JSON.stringify({
+            compositeTools: {
+                remember_and_recall: {
+                    description: 'Store a note, then read it back.',
+                    inputSchema: {
+                        type: 'object',
+                        properties: { key: { type: 'string' }, note: { type: 'string' }, tags: { type: 'array', items: { type: 'string' } } },
+                        required: ['key', 'note'],
+                    },
+                    steps: [
+                        { id: 'store', tool: 'ax_memory_store', args: { key: '{{input.key}}', value: { note: 'note: {{input.note}}', tags: '{{input.tags}}' } } },
+                        { id: 'recall', tool: 'memory.retrieve', args: { key: '{{input.key}}' } },
+                        { id: 'tagged', tool: 'memory.retrieve', when: '${input.tags}', args: { key: '{{input.key}}' } },
+                        { id: 'broken', tool: 'file.exists', when: '${steps.recall.data.value.tags.length} >= 3', continueOnError: true },
+                    ],
+                    output: { note: '{{steps.recall.data.value.note}}', tags: '{{steps.recall.data.value.tags}}' },
+                },
+                'workflow.run': { steps: [{ tool: 'workflow.list' }] },
+                recurse: { steps: [{ tool: 'remember_and_recall' }] },
+                empty: { steps: [] },
+            },
+        }, null, 2)}\n`, 'utf8');
+        const surface = createMcpServerSurface({ basePath: tempDir, toolPrefix: 'team_' });
+        const tools = surface.listTools();
+        expect(tools).toContain('remember_and_recall');
+        expect(tools).toContain('team_remember_and_recall');
+        expect(tools).not.toContain('recurse');
+        expect(surface.listToolDefinitions().find((tool) => tool.name === 'remember_and_recall')?.description)
+            .toBe('Store a note, then read it back. Composite: memory.store -> memory.retrieve -> memory.retrieve -> file.exists.');
+        expect(surface.listCompositeToolErrors()).toEqual([
+            'Composite tool empty needs at least one step',
+            'Composite tool workflow.run conflicts with a built-in tool',
+            'Composite tool recurse step step1 calls unknown tool remember_and_recall',
+        ]);
+        const invalid = await surface.invokeTool('remember_and_recall', { key: 'deploy' });
+        expect(invalid.success).toBe(false);
+        const untagged = await surface.invokeTool('ax_remember_and_recall', { key: 'deploy', note: 'use blue/green' });
+        expect(untagged).toMatchObject({
+            success: true,
+            data: {
+                output: { note: 'note: use blue/green' },
+                steps: [
+                    { id: 'store', tool: 'memory.store', status: 'succeeded' },
+                    { id: 'recall', tool: 'memory.retrieve', status: 'succeeded' },
+                    { id: 'tagged', tool: 'memory.retrieve', status: 'skipped' },
+                    { id: 'broken', tool: 'file.exists', status: 'skipped' },
+                ],
+            },
+        });
+        const tagged = await surface.invokeTool('team_remember_and_recall', { key: 'release', note: 'freeze', tags: ['ops', 'prod', 'q3'] });
+        expect(tagged.success).toBe(true);
+        const taggedData = tagged.data;
+        expect(taggedData.output).toEqual({ note: 'note: freeze', tags: ['ops', 'prod', 'q3'] });
+        expect(taggedData.steps.map((step) => step.status)).toEqual(['succeeded', 'succeeded', 'succeeded', 'failed']);
+        expect(taggedData.steps[3]?.error).toContain('path');
+    });
+    it('tailors tools to the connecting client with per-client profiles', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        mkdirSync(join(tempDir, '.automatosx'), { recursive: true });
+        await writeFile(join(tempDir, '.automatosx', 'config.json'), `${JSON.stringify({
+            mcp: {
+                clients: {
+                    'gemini-cli': { tools: ['memory.*'], exclude: ['memory.clear'], descriptions: { 'memory.store': 'Save a note.' }, maxInputBytes: 64 },
+                    default: { tools: 'workflow.*' },
+                    broken: { tools: 5 },
+                },
+            },
+        }, null, 2)}\n`, 'utf8');
+        const surface = createMcpServerSurface({ basePath: tempDir });
+        expect(surface.listClientProfiles().map((profile) => profile.name)).toEqual(['gemini-cli', 'default', 'claude-code', 'cursor']);
+        expect(surface.listClientProfileErrors()).toEqual(['MCP client profile broken tools must be a string or a list of strings']);
+        const cursor = surface.listClientProfiles().find((profile) => profile.name === 'cursor');
+        const cursorTools = surface.listToolDefinitions(cursor);
+        expect(cursorTools.length).toBeLessThanOrEqual(40);
+        expect(cursorTools.every((tool) => tool.description.length <= 300)).toBe(true);
+        const serveOnce = async (clientName, calls) => {
+            const outputChunks = [];
+            const output = new Writable({
+                write(chunk, _enc, cb) {
+                    outputChunks.push(chunk.toString());
+                    cb();
+                },
+            });
+            const requests = [
+                { jsonrpc: '2.0', id: 1, method: 'initialize', params: { protocolVersion: '2025-03-26', clientInfo: { name: clientName, version: '1.0.0' } } },
+                { jsonrpc: '2.0', id: 2, method: 'tools/list' },
+                ...calls.map((params, index) => ({ jsonrpc: '2.0', id: 3 + index, method: 'tools/call', params })),
+                { jsonrpc: '2.0', id: 99, method: 'shutdown' },
+            ].map((request) => JSON.stringify(request)).join('\n') + '\n';
+            await createMcpStdioServer({ basePath: tempDir, input: Readable.from([requests]), output }).serve();
+            return outputChunks.join('').trim().split('\n').map((line) => JSON.parse(line));
+        };
+        const gemini = await serveOnce('gemini-cli-mcp-client', [
+            { name: 'memory.store', arguments: { key: 'a', value: { n: 1 } } },
+            { name: 'ax_memory_store', arguments: { key: 'b', value: { note: 'x'.repeat(100) } } },
+            { name: 'workflow.list', arguments: {} },
+        ]);
+        expect(gemini.find((entry) => entry.id === 1)?.result?.protocolVersion).toBe('2025-03-26');
+        const geminiTools = (gemini.find((entry) => entry.id === 2)?.result?.tools ?? []);
+        expect(geminiTools.every((tool) => tool.name.startsWith('memory.'))).toBe(true);
+        expect(geminiTools.some((tool) => tool.name === 'memory.clear')).toBe(false);
+        expect(geminiTools.find((tool) => tool.name === 'memory.store')?.description).toBe('Save a note.');
+        expect(gemini.find((entry) => entry.id === 3)?.result?.isError).toBeUndefined();
+        expect(gemini.find((entry) => entry.id === 4)?.result?.content?.[0]?.text).toContain('gemini-cli clients may send at most 64');
+        expect(gemini.find((entry) => entry.id === 5)?.result?.content?.[0]?.text).toBe('Tool workflow.list is not available to gemini-cli clients');
+        const custom = await serveOnce('my-agent', []);
+        const customTools = (custom.find((entry) => entry.id === 2)?.result?.tools ?? []);
+        expect(customTools.length).toBeGreaterThan(0);
+        expect(customTools.every((tool) => tool.name.startsWith('workflow.'))).toBe(true);
+        expect(() => createMcpStdioServer({ basePath: tempDir, client: 'nope' })).toThrow('Unknown MCP client profile "nope"');
+    });
+    it('logs client tool calls without argument values and tunes descriptions from them', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        mkdirSync(join(tempDir, '.automatosx'), { recursive: true });
+        await writeFile(join(tempDir, '.automatosx', 'config.json'), `${JSON.stringify({ mcp: { usage: true } }, null, 2)}\n`, 'utf8');
+        const calls = [
+            { name: 'memory.store', arguments: { key: 'owner', value: { name: 'secret-owner' } } },
+            { name: 'ax_memory_store', arguments: { value: { name: 'x' } } },
+            { name: 'memory.store', arguments: { key: 'y', body: 'x' } },
+            { name: 'memory.save', arguments: { key: 'z' } },
+        ];
+        const requests = calls.map((params, index) => JSON.stringify({ jsonrpc: '2.0', id: index + 1, method: 'tools/call', params })).join('\n') + '\n';
+        const output = new Writable({ write(_chunk, _enc, cb) { cb(); } });
+        await createMcpStdioServer({ basePath: tempDir, input: Readable.from([requests]), output }).serve();
+        const log = await readFile(join(tempDir, '.automatosx', 'runtime', 'mcp-tool-usage.jsonl'), 'utf8');
+        expect(log).not.toContain('secret-owner');
+        const surface = createMcpServerSurface({ basePath: tempDir });
+        const report = await surface.toolUsageReport();
+        expect(report).toMatchObject({ enabled: true, calls: 4, unknownTools: [{ name: 'memory.save', count: 1 }] });
+        expect(report.tools).toHaveLength(1);
+        expect(report.tools[0]).toMatchObject({ tool: 'memory.store', calls: 3, invalid: 2, failed: 0 });
+        expect(report.tools[0].validationErrors.map((entry) => entry.name)).toEqual(['body is not allowed', 'key is required']);
+        expect(report.unused).toContain('memory.retrieve');
+        const [suggestion] = report.suggestions;
+        expect(suggestion?.tool).toBe('memory.store');
+        expect(suggestion?.description).toContain('Required: key. Accepted arguments: key, namespace, scope, value, tags, ttlMs, agentId, importance.');
+        expect(suggestion?.description).toContain('Example: {"key":"<key>","value":{}}');
+        await writeMcpToolTuning(tempDir, report.suggestions);
+        const tuned = createMcpServerSurface({ basePath: tempDir });
+        expect(tuned.listToolDefinitions().find((tool) => tool.name === 'memory.store')?.description).toBe(suggestion?.description);
+        expect((await tuned.toolUsageReport()).suggestions[0]?.description).toBe(suggestion?.description);
+    });
     it('keeps the CLI MCP surface runnable as a process after protocol expansion', async () => {
         const tempDir = createTempDir();
         tempDirs.push(tempDir);
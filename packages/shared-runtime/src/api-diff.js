@@ -0,0 +1,175 @@
+import { execFile } from 'node:child_process';
+import { readdir, readFile } from 'node:fs/promises';
+import { join, posix } from 'node:path';
+import { promisify } from 'node:util';
+import { diffDeclarations, extractDeclarations, stripStringsAndComments, } from './structural-diff.js';
+const execFileAsync = promisify(execFile);
+/**
+ * Compares the exported API of the Go packages changed between `base` and
+ * `head` (or the working tree). Each package is read whole at both
+ * revisions, so a symbol moving between files of a package is no change.
+ * Methods count only on exported types, structs are compared by their
+ * exported fields, and body-only changes are left out. Removals, signature
+ * changes, removed or retyped fields, and changed interface method sets are
+ * breaking; additions are not.
+ */
+export async function collectApiDiff(request) {
+    const oldRef = request.head === undefined
+        ? request.base
+        : (await git(request.basePath, ['merge-base', request.base, request.head])).trim();
+    const range = request.head === undefined ? [request.base] : [`${request.base}...${request.head}`];
+    const nameStatus = await git(request.basePath, [
+        'diff', '--name-only', '--no-renames', ...range,
+        ...(request.paths !== undefined && request.paths.length > 0 ? ['--', ...request.paths] : []),
+    ]);
+    const changed = nameStatus.split('\n').filter((path) => path.length > 0);
+    const packages = [...new Set(changed.filter(isGoSource).map((path) => posix.dirname(path)))].sort();
+    const diffs = [];
+    for (const dir of packages) {
+        const before = await readPackage(request.basePath, dir, oldRef);
+        const after = await readPackage(request.basePath, dir, request.head);
+        const changes = diffPackageApi(before, after);
+        if (changes.length > 0) {
+            diffs.push({ package: dir, changes });
+        }
+    }
+    return {
+        base: request.base,
+        head: request.head,
+        packages: diffs,
+        breaking: diffs.reduce((sum, diff) => sum + diff.changes.filter((change) => change.breaking).length, 0),
+        skipped: changed.filter((path) => !isGoSource(path)),
+        summary: summarizeApiDiff(diffs),
+    };
+}
+function diffPackageApi(before, after) {
+    const list = (api) => [...api.values()].map((entry) => entry.declaration);
+    const changes = [];
+    for (const change of diffDeclarations(list(before), list(after))) {
+        const key = apiKey(change);
+        const old = before.get(key);
+        const current = after.get(key);
+        const at = current ?? old;
+        const base = { kind: change.kind, name: change.name, path: at.path, line: at.declaration.line };
+        if (change.change === 'added') {
+            changes.push({ change: 'added', ...base, after: change.after, breaking: false });
+        }
+        else if (change.change === 'removed') {
+            changes.push({ change: 'removed', ...base, before: change.before, breaking: true });
+        }
+        else if (old !== undefined && current !== undefined && change.kind === 'interface') {
+            const members = { before: interfaceMembers(old), after: interfaceMembers(current) };
+            const details = [
+                ...members.before.filter((member) => !members.after.includes(member)).map((member) => `removed method ${member}`),
+                ...members.after.filter((member) => !members.before.includes(member)).map((member) => `added method ${member}`),
+            ];
+            if (details.length > 0) {
+                // New methods break implementations outside the package, removed ones break callers.
+                changes.push({ change: 'changed', ...base, breaking: true, details });
+            }
+        }
+        else if (old !== undefined && current !== undefined && old.declaration.fields !== undefined && current.declaration.fields !== undefined) {
+            // A Go struct's signature spells out its fields, unexported ones included.
+            const fields = { before: exportedFields(old.declaration), after: exportedFields(current.declaration) };
+            const details = [];
+            let breaking = false;
+            for (const [name, type] of fields.before) {
+                const now = fields.after.get(name);
+                if (now === undefined) {
+                    details.push(`removed field ${name}`);
+                    breaking = true;
+                }
+                else if (now !== type) {
+                    details.push(`changed field ${name} from ${type} to ${now}`);
+                    breaking = true;
+                }
+            }
+            for (const [name, type] of fields.after) {
+                if (!fields.before.has(name)) {
+                    details.push(`added field ${name} ${type}`);
+                }
+            }
+            if (details.length > 0) {
+                changes.push({ change: 'changed', ...base, breaking, details });
+            }
+        }
+        else if (change.change === 'signature-changed') {
+            changes.push({ change: 'changed', ...base, before: change.before, after: change.after, breaking: true });
+        }
+    }
+    return changes.sort((left, right) => Number(right.breaking) - Number(left.breaking) || left.name.localeCompare(right.name));
+}
+// One line per package, breaking changes first.
+export function summarizeApiDiff(packages) {
+    if (packages.length === 0) {
+        return 'No exported API changes.';
+    }
+    return packages.map((diff) => {
+        const parts = diff.changes.map((change) => {
+            const label = `${change.kind} ${change.name}`;
+            const text = change.change === 'added'
+                ? `added ${label}`
+                : change.change === 'removed'
+                    ? `removed ${label}`
+                    : change.details !== undefined
+                        ? `changed ${label} (${change.details.join(', ')})`
+                        : `changed signature of ${label}`;
+            return change.breaking ? `${text} [breaking]` : text;
+        });
+        return `- ${diff.package}: ${parts.join('; ')}`;
+    }).join('\n');
+}
+async function readPackage(basePath, dir, ref) {
+    let paths;
+    if (ref === undefined) {
+        const entries = await readdir(join(basePath, dir), { withFileTypes: true }).catch(() => []);
+        paths = entries.filter((entry) => entry.isFile()).map((entry) => (dir === '.' ? entry.name : `${dir}/${entry.name}`));
+    }
+    else {
+        const listed = await git(basePath, ['ls-tree', '--name-only', ref, ...(dir === '.' ? [] : ['--', `${dir}/`])]).catch(() => '');
+        paths = listed.split('\n').filter((path) => path.length > 0);
+    }
+    const api = new Map();
+    for (const path of paths.filter(isGoSource)) {
+        const content = ref === undefined
+            ? await readFile(join(basePath, path), 'utf8')
+            : await git(basePath, ['show', `${ref}:${path}`]);
+        for (const declaration of extractDeclarations(content, path)) {
+            const receiver = declaration.kind === 'method' ? declaration.name.slice(0, declaration.name.indexOf('.')) : undefined;
+            // A method on an unexported type is only reachable through an interface, which lists it anyway.
+            if (!declaration.exported || (receiver !== undefined && !/^[A-Z]/.test(receiver))) {
+                continue;
+            }
+            api.set(apiKey(declaration), { declaration, path, content });
+        }
+    }
+    return api;
+}
+function apiKey(declaration) {
+    return `${declaration.kind === 'method' ? 'method' : 'top'}:${declaration.name}`;
+}
+function exportedFields(declaration) {
+    return new Map((declaration.fields ?? [])
+        .filter((field) => /^[A-Z]/.test(field.name))
+        .map((field) => [field.name, field.type.replace(/\s+/g, ' ')]));
+}
+// Method signatures and embedded interfaces, one per line of the interface body.
+function interfaceMembers(entry) {
+    const lines = stripStringsAndComments(entry.content, true, '.go').split('\n').slice(entry.declaration.line - 1, entry.declaration.endLine);
+    const body = lines.join('\n');
+    const inner = body.slice(body.indexOf('{') + 1, body.lastIndexOf('}'));
+    return inner.split(/[\n;]/).map((member) => member.trim().replace(/\s+/g, ' ')).filter((member) => member.length > 0);
+}
+function isGoSource(path) {
+    return path.endsWith('.go') && !path.endsWith('_test.go');
+}
+async function git(basePath, args) {
+    try {
+        const { stdout } = await execFileAsync('git', args, { cwd: basePath, maxBuffer: 1024 * 1024 * 16 });
+        return stdout;
+    }
+    catch (error) {
+        const message = error instanceof Error ? error.message : String(error);
+        throw new Error(`git ${args[0] ?? 'command'} failed: ${message}`);
+    }
+}
@@ -0,0 +1,251 @@
+import { readdir, readFile } from 'node:fs/promises';
+import { extname, join, relative, sep } from 'node:path';
+import type { MemoryEntry } from '@defai.digital/state-store';
+import { filterAxIgnored } from './axignore.js';
+import { detectGeneratedCode } from './generated-code.js';
+import { loadReferenceIndex } from './reference-index.js';
+import { symbolCentrality } from './symbol-rank.js';
+
+export const DEFAULT_ASK_MAX_TOKENS = 800;
+export const DEFAULT_ASK_CONTEXT_CHARS = 12_000;
+
+const ASK_SOURCE_LIMIT = 8;
+// Lines of a declaration given as context; long bodies are cut.
+const MAX_SYMBOL_LINES = 40;
+const DOC_EXTENSIONS = ['.md', '.mdx', '.txt'];
+const DOC_ROOTS = ['docs', join('.automatosx', 'specs')];
+const IGNORED_DIRS = new Set(['node_modules', '.git', 'dist', 'build', 'coverage']);
+const STOP_WORDS = new Set([
+  'the', 'and', 'for', 'are', 'how', 'what', 'why', 'does', 'where', 'which', 'when', 'who',
+  'this', 'that', 'with', 'from', 'into', 'can', 'you', 'our', 'use', 'used', 'work', 'works',
+]);
+
+export const ASK_SYSTEM_PROMPT = [
+  'You answer questions about this codebase for a teammate.',
+  'This is a read-only session: do not propose edits, run commands, or call tools.',
+  'Answer only from the context provided. Cite sources as [n]. If the context does',
+  'not contain the answer, say so and suggest where to look. Keep answers short.',
+].join(' ');
+
+export interface AskSource {
+  index: number;
+  kind: 'memory' | 'doc' | 'symbol';
+  ref: string;
+  score: number;
+  file?: string;
+  line?: number;
+  link?: string;
+}
+
+export interface AskContext {
+  prompt: string;
+  sources: AskSource[];
+}
+
+export interface RuntimeAskResponse {
+  question: string;
+  answer: string;
+  success: boolean;
+  provider: string;
+  traceId: string;
+  latencyMs: number;
+  sources: AskSource[];
+  warnings: string[];
+  error?: string;
+}
+
+export interface AskCandidate {
+  kind: AskSource['kind'];
+  ref: string;
+  text: string;
+  score: number;
+  file?: string;
+  line?: number;
+}
+
+export async function buildAskContext(request: {
+  basePath: string;
+  question: string;
+  memory: MemoryEntry[];
+  maxChars?: number;
+}): Promise<AskContext> {
+  const maxChars = request.maxChars ?? DEFAULT_ASK_CONTEXT_CHARS;
+  const ranked = await rankAskCandidates({ ...request, symbols: true });
+  const sources: AskSource[] = [];
+  const blocks: string[] = [];
+  let used = 0;
+  for (const candidate of ranked) {
+    if (sources.length >= ASK_SOURCE_LIMIT || used >= maxChars) {
+      break;
+    }
+    const text = candidate.text.trim().slice(0, maxChars - used);
+    const index = sources.length + 1;
+    sources.push({ index, kind: candidate.kind, ref: candidate.ref, score: candidate.score, file: candidate.file, line: candidate.line });
+    blocks.push(`[${index}] ${candidate.kind} ${candidate.ref}\n${text}`);
+    used += text.length;
+  }
+
+  const prompt = [
+    `Question: ${request.question}`,
+    '',
+    'Context:',
+    blocks.length > 0 ? blocks.join('\n\n') : '(no matching memory or documentation found)',
+  ].join('\n');
+  return { prompt, sources };
+}
+
+// Memory entries and doc sections (root docs, docs/, .automatosx/specs) sharing terms with the question, best first.
+// With `symbols`, code declarations named after the question's terms compete too.
+export async function rankAskCandidates(request: {
+  basePath: string;
+  question: string;
+  memory: MemoryEntry[];
+  symbols?: boolean;
+}): Promise<AskCandidate[]> {
+  const terms = extractTerms(request.question);
+  const candidates: AskCandidate[] = [];
+  for (const entry of request.memory) {
+    const ref = entry.namespace !== undefined ? `${entry.namespace}/${entry.key}` : entry.key;
+    const text = typeof entry.value === 'string' ? entry.value : JSON.stringify(entry.value);
+    candidates.push({ kind: 'memory', ref, text, score: scoreText(`${ref} ${text}`, terms) });
+  }
+  for (const path of await listDocs(request.basePath)) {
+    let content: string;
+    try {
+      content = await readFile(join(request.basePath, path), 'utf8');
+    } catch {
+      continue;
+    }
+    // Generated reference docs restate code the question is better answered from.
+    if (detectGeneratedCode(path, content) !== undefined) {
+      continue;
+    }
+    for (const section of splitSections(content)) {
+      const ref = section.heading !== undefined ? `${path}#${section.heading}` : path;
+      candidates.push({
+        kind: 'doc',
+        ref,
+        text: section.text,
+        score: scoreText(`${ref} ${section.text}`, terms),
+        file: path,
+        line: section.line,
+      });
+    }
+  }
+  if (request.symbols === true) {
+    candidates.push(...await rankSymbolCandidates(request.basePath, terms));
+  }
+  return candidates
+    .filter((candidate) => candidate.score > 0)
+    .sort((left, right) => right.score - left.score || left.ref.localeCompare(right.ref));
+}
+
+// Declarations whose name or doc comment shares terms with the question. The
+// match is weighted by centrality in the reference graph, from half for leaf
+// utilities to double for core abstractions, so those are surfaced first.
+async function rankSymbolCandidates(basePath: string, terms: string[]): Promise<AskCandidate[]> {
+  const files = await loadReferenceIndex(basePath);
+  const centrality = symbolCentrality(files);
+  const candidates: AskCandidate[] = [];
+  for (const [path, file] of files) {
+    if (detectGeneratedCode(path, file.lines.join('\n')) !== undefined) {
+      continue;
+    }
+    for (const symbol of file.declarations) {
+      const name = symbol.receiver !== undefined ? `${symbol.receiver}.${symbol.name}` : symbol.name;
+      const matched = scoreText(`${name} ${symbol.doc ?? ''}`, terms);
+      if (matched === 0) {
+        continue;
+      }
+      const weight = Math.min(2, 0.5 + (centrality.get(`${path}:${symbol.line}`) ?? 0) / 2);
+      const body = file.lines.slice(symbol.line - 1, Math.min(symbol.endLine, symbol.line - 1 + MAX_SYMBOL_LINES));
+      candidates.push({
+        kind: 'symbol',
+        ref: `${path}#${name}`,
+        text: [...(symbol.doc !== undefined ? [symbol.doc] : []), ...body].join('\n'),
+        score: Math.round(matched * weight * 100) / 100,
+        file: path,
+        line: symbol.line,
+      });
+    }
+  }
+  return candidates;
+}
+
+function extractTerms(question: string): string[] {
+  const terms = question
+    .toLowerCase()
+    .split(/[^a-z0-9_-]+/)
+    .filter((term) => term.length >= 3 && !STOP_WORDS.has(term));
+  return [...new Set(terms)];
+}
+
+function scoreText(text: string, terms: string[]): number {
+  const haystack = text.toLowerCase();
+  let score = 0;
+  for (const term of terms) {
+    let from = haystack.indexOf(term);
+    let hits = 0;
+    while (from !== -1 && hits < 5) {
+      hits += 1;
+      from = haystack.indexOf(term, from + term.length);
+    }
+    // Distinct terms matter more than repeats of one term.
+    score += hits > 0 ? 2 + hits : 0;
+  }
+  return score;
+}
+
+function splitSections(content: string): Array<{ heading?: string; text: string; line: number }> {
+  const sections: Array<{ heading?: string; text: string; line: number }> = [];
+  let current: { heading?: string; lines: string[]; line: number } = { lines: [], line: 1 };
+  content.split('\n').forEach((line, index) => {
+    const match = /^#{1,3}\s+(.+?)\s*$/.exec(line);
+    if (match !== null) {
+      if (current.lines.join('').trim().length > 0) {
+        sections.push({ heading: current.heading, text: current.lines.join('\n'), line: current.line });
+      }
+      current = { heading: match[1], lines: [line], line: index + 1 };
+    } else {
+      current.lines.push(line);
+    }
+  });
+  if (current.lines.join('').trim().length > 0) {
+    sections.push({ heading: current.heading, text: current.lines.join('\n'), line: current.line });
+  }
+  return sections;
+}
+
+async function listDocs(basePath: string): Promise<string[]> {
+  const docs: string[] = [];
+  try {
+    for (const entry of await readdir(basePath, { withFileTypes: true })) {
+      if (entry.isFile() && DOC_EXTENSIONS.includes(extname(entry.name).toLowerCase())) {
+        docs.push(entry.name);
+      }
+    }
+  } catch {
+    return [];
+  }
+
+  const walk = async (dir: string): Promise<void> => {
+    let entries;
+    try {
+      entries = await readdir(dir, { withFileTypes: true });
+    } catch {
+      return;
+    }
+    for (const entry of entries) {
+      const absolutePath = join(dir, entry.name);
+      if (entry.isDirectory() && !IGNORED_DIRS.has(entry.name)) {
+        await walk(absolutePath);
+      } else if (entry.isFile() && DOC_EXTENSIONS.includes(extname(entry.name).toLowerCase())) {
+        docs.push(relative(basePath, absolutePath).split(sep).join('/'));
+      }
+    }
+  };
+  for (const root of DOC_ROOTS) {
+    await walk(join(basePath, root));
+  }
+  return filterAxIgnored(basePath, docs.sort());
+}
@@ -0,0 +1,199 @@
+import { open, readFile } from 'node:fs/promises';
+import { join } from 'node:path';
+
+export const AXIGNORE_FILE = '.axignore';
+
+// Header rules look at this much of the start of a file.
+const HEADER_BYTES = 1024;
+const HEADER_LINES = 10;
+
+export interface AxIgnorePathRule {
+  pattern: string;
+  negated: boolean;
+  directoryOnly: boolean;
+  regex: RegExp;
+}
+
+export interface AxIgnoreRules {
+  paths: AxIgnorePathRule[];
+  // `@header <text>`: files whose first lines contain the text, e.g. "DO NOT EDIT".
+  headers: string[];
+}
+
+/**
+ * Parses `.axignore`: gitignore syntax (`#` comments, `!` negation, trailing
+ * `/` for directories, leading `/` or an inner `/` to anchor at the root,
+ * `*`, `?`, `**` and `[...]`), plus `@header <text>` lines that ignore files
+ * by their content.
+ */
+export function parseAxIgnore(text: string): AxIgnoreRules {
+  const rules: AxIgnoreRules = { paths: [], headers: [] };
+  for (const rawLine of text.split(/\r?\n/)) {
+    const line = rawLine.replace(/(?<!\\)\s+$/, '');
+    if (line.length === 0 || line.startsWith('#')) {
+      continue;
+    }
+    if (line.startsWith('@header ')) {
+      const header = line.slice('@header '.length).trim();
+      if (header.length > 0) {
+        rules.headers.push(header);
+      }
+      continue;
+    }
+    const negated = line.startsWith('!');
+    let pattern = (negated ? line.slice(1) : line).replace(/^\\([#!@])/, '$1');
+    const directoryOnly = pattern.endsWith('/');
+    pattern = pattern.replace(/\/+$/, '');
+    if (pattern.length === 0) {
+      continue;
+    }
+    const anchored = pattern.includes('/');
+    const body = globToRegex(pattern.replace(/^\//, ''));
+    rules.paths.push({
+      pattern: line,
+      negated,
+      directoryOnly,
+      regex: new RegExp(anchored ? `^${body}$` : `(?:^|/)${body}$`),
+    });
+  }
+  return rules;
+}
+
+// A workspace without `.axignore` ignores nothing.
+export async function loadAxIgnore(basePath: string): Promise<AxIgnoreRules> {
+  try {
+    return parseAxIgnore(await readFile(join(basePath, AXIGNORE_FILE), 'utf8'));
+  } catch {
+    return { paths: [], headers: [] };
+  }
+}
+
+export function isAxIgnoreEmpty(rules: AxIgnoreRules): boolean {
+  return rules.paths.length === 0 && rules.headers.length === 0;
+}
+
+/**
+ * Whether a workspace-relative, `/`-separated path is ignored. As in git, a
+ * file under an ignored directory stays ignored even if a later `!` rule
+ * matches it. Header rules apply only when `content` is given.
+ */
+export function isAxIgnored(rules: AxIgnoreRules, path: string, content?: string): boolean {
+  const parts = path.replace(/^\.\//, '').split('/');
+  for (let depth = 1; depth < parts.length; depth += 1) {
+    if (matchPath(rules, parts.slice(0, depth).join('/'), true)) {
+      return true;
+    }
+  }
+  if (matchPath(rules, parts.join('/'), false)) {
+    return true;
+  }
+  return content !== undefined && matchesHeader(rules, content);
+}
+
+export function isAxIgnoredDirectory(rules: AxIgnoreRules, path: string): boolean {
+  const parts = path.replace(/^\.\//, '').replace(/\/+$/, '').split('/');
+  for (let depth = 1; depth <= parts.length; depth += 1) {
+    if (matchPath(rules, parts.slice(0, depth).join('/'), true)) {
+      return true;
+    }
+  }
+  return false;
+}
+
+// Drops ignored paths, reading the start of each remaining file only when there are header rules.
+export async function filterAxIgnored(basePath: string, paths: string[], rules?: AxIgnoreRules): Promise<string[]> {
+  const axignore = rules ?? await loadAxIgnore(basePath);
+  if (isAxIgnoreEmpty(axignore)) {
+    return paths;
+  }
+  const kept: string[] = [];
+  for (const path of paths) {
+    if (!await isAxIgnoredFile(basePath, path, axignore)) {
+      kept.push(path);
+    }
+  }
+  return kept;
+}
+
+export async function isAxIgnoredFile(basePath: string, path: string, rules: AxIgnoreRules): Promise<boolean> {
+  if (isAxIgnored(rules, path)) {
+    return true;
+  }
+  return rules.headers.length > 0 && matchesHeader(rules, await readHeader(join(basePath, path)));
+}
+
+function matchPath(rules: AxIgnoreRules, path: string, directory: boolean): boolean {
+  let ignored = false;
+  for (const rule of rules.paths) {
+    if ((!rule.directoryOnly || directory) && rule.regex.test(path)) {
+      ignored = !rule.negated;
+    }
+  }
+  return ignored;
+}
+
+function matchesHeader(rules: AxIgnoreRules, content: string): boolean {
+  if (rules.headers.length === 0) {
+    return false;
+  }
+  const header = content.slice(0, HEADER_BYTES).split('\n').slice(0, HEADER_LINES).join('\n');
+  return rules.headers.some((text) => header.includes(text));
+}
+
+async function readHeader(filePath: string): Promise<string> {
+  let handle;
+  try {
+    handle = await open(filePath, 'r');
+    const buffer = Buffer.alloc(HEADER_BYTES);
+    const { bytesRead } = await handle.read(buffer, 0, HEADER_BYTES, 0);
+    return buffer.subarray(0, bytesRead).toString('utf8');
+  } catch {
+    return '';
+  } finally {
+    await handle?.close();
+  }
+}
+
+function globToRegex(glob: string): string {
+  let out = '';
+  for (let index = 0; index < glob.length; index += 1) {
+    const char = glob[index]!;
+    if (char === '*' && glob[index + 1] === '*') {
+      const slashAfter = glob[index + 2] === '/';
+      const atSegmentStart = index === 0 || glob[index - 1] === '/';
+      if (atSegmentStart && slashAfter) {
+        out += '(?:.*/)?';
+        index += 2;
+      } else if (atSegmentStart && index + 2 === glob.length) {
+        out += '.*';
+        index += 1;
+      } else {
+        out += '[^/]*';
+        index += 1;
+      }
+    } else if (char === '*') {
+      out += '[^/]*';
+    } else if (char === '?') {
+      out += '[^/]';
+    } else if (char === '[') {
+      const close = glob.indexOf(']', index + 2);
+      if (close === -1) {
+        out += '\\[';
+      } else {
+        const set = glob.slice(index + 1, close).replace(/^!/, '^').replace(/\\/g, '\\\\');
+        out += `[${set}]`;
+        index = close;
+      }
+    } else if (char === '\\' && index + 1 < glob.length) {
+      out += escapeRegex(glob[index + 1]!);
+      index += 1;
+    } else {
+      out += escapeRegex(char);
+    }
+  }
+  return out;
+}
+
+function escapeRegex(value: string): string {
+  return value.replace(/[.*+?^${}()|[\]\\/]/g, '\\$&');
+}
@@ -0,0 +1,267 @@
+import { createHash } from 'node:crypto';
+import { mkdir, readdir, readFile, rename, rm, stat, writeFile } from 'node:fs/promises';
+import { tmpdir } from 'node:os';
+import { dirname, extname, isAbsolute, join, relative, sep } from 'node:path';
+import { DatabaseSync } from 'node:sqlite';
+import { promisify } from 'node:util';
+import { gunzip, gzip } from 'node:zlib';
+
+const gzipAsync = promisify(gzip);
+const gunzipAsync = promisify(gunzip);
+
+export const BACKUP_FORMAT = 'automatosx-backup';
+export const BACKUP_FORMAT_VERSION = 1;
+export const BACKUPS_DIR = join('.automatosx', 'backups');
+
+const AUTOMATOSX_DIR = '.automatosx';
+const SQLITE_SIDECAR_SUFFIXES = ['-wal', '-shm', '-journal'];
+
+export interface BackupFileEntry {
+  path: string;
+  size: number;
+  sha256: string;
+}
+
+export interface BackupManifest {
+  format: string;
+  formatVersion: number;
+  createdAt: string;
+  productVersion?: string;
+  files: BackupFileEntry[];
+  checksum: string;
+}
+
+export interface RuntimeBackupResponse {
+  archivePath: string;
+  createdAt: string;
+  files: BackupFileEntry[];
+  totalBytes: number;
+  checksum: string;
+}
+
+export interface RuntimeRestoreResponse {
+  archivePath: string;
+  createdAt: string;
+  restored: string[];
+  safetyBackupPath?: string;
+  /** Set when the replaced state could not be cleaned up and was kept here instead. */
+  previousStatePath?: string;
+}
+
+interface BackupArchive extends BackupManifest {
+  contents: Record<string, string>;
+}
+
+export async function createBackup(request: { basePath: string; outputPath?: string; productVersion?: string; now?: Date }): Promise<RuntimeBackupResponse> {
+  const stateDir = join(request.basePath, AUTOMATOSX_DIR);
+  const now = request.now ?? new Date();
+  const archivePath = request.outputPath ?? join(request.basePath, BACKUPS_DIR, `automatosx-${now.getTime()}.axbackup`);
+  const paths = await listStateFiles(stateDir, stateDir);
+
+  const files: BackupFileEntry[] = [];
+  const contents: Record<string, string> = {};
+  for (const path of paths) {
+    const data = await readStateFile(join(stateDir, path));
+    files.push({ path, size: data.length, sha256: sha256(data) });
+    contents[path] = data.toString('base64');
+  }
+
+  const archive: BackupArchive = {
+    format: BACKUP_FORMAT,
+    formatVersion: BACKUP_FORMAT_VERSION,
+    createdAt: now.toISOString(),
+    productVersion: request.productVersion,
+    files,
+    checksum: manifestChecksum(files),
+    contents,
+  };
+  await mkdir(dirname(archivePath), { recursive: true });
+  await writeFile(archivePath, await gzipAsync(Buffer.from(JSON.stringify(archive), 'utf8')));
+
+  return {
+    archivePath,
+    createdAt: archive.createdAt,
+    files,
+    totalBytes: files.reduce((sum, file) => sum + file.size, 0),
+    checksum: archive.checksum,
+  };
+}
+
+export async function verifyBackup(archivePath: string): Promise<BackupManifest> {
+  const archive = await readVerifiedArchive(archivePath);
+  return {
+    format: archive.format,
+    formatVersion: archive.formatVersion,
+    createdAt: archive.createdAt,
+    productVersion: archive.productVersion,
+    files: archive.files,
+    checksum: archive.checksum,
+  };
+}
+
+export async function restoreBackup(request: {
+  basePath: string;
+  archivePath: string;
+  productVersion?: string;
+}): Promise<RuntimeRestoreResponse> {
+  // Verify every file and path before the first write so a corrupt or hostile
+  // archive never leaves the workspace half-restored.
+  const archive = await readVerifiedArchive(request.archivePath);
+  const stateDir = join(request.basePath, AUTOMATOSX_DIR);
+
+  // Whatever is there now is archived first, so a restore can always be undone.
+  let safetyBackupPath: string | undefined;
+  if ((await listStateFiles(stateDir, stateDir)).length > 0) {
+    safetyBackupPath = (await createBackup({
+      basePath: request.basePath,
+      outputPath: join(request.basePath, BACKUPS_DIR, `pre-restore-${Date.now()}.axbackup`),
+      productVersion: request.productVersion,
+    })).archivePath;
+  }
+
+  // The archive is written out next to the state directory, then swapped in
+  // with renames, so a failed write leaves the current state untouched.
+  const stamp = `${process.pid}-${Date.now()}`;
+  const staging = `${stateDir}.restore-${stamp}`;
+  const previous = `${stateDir}.previous-${stamp}`;
+  try {
+    await mkdir(staging, { recursive: true });
+    for (const file of archive.files) {
+      const target = resolveInside(staging, file.path);
+      await mkdir(dirname(target), { recursive: true });
+      await writeFile(target, Buffer.from(archive.contents[file.path] ?? '', 'base64'));
+    }
+  } catch (error) {
+    await rm(staging, { recursive: true, force: true });
+    throw error;
+  }
+
+  const hadState = await stat(stateDir).then((info) => info.isDirectory(), () => false);
+  if (hadState) {
+    await rename(stateDir, previous);
+  }
+  try {
+    await rename(staging, stateDir);
+  } catch (error) {
+    if (hadState) {
+      await rename(previous, stateDir);
+    }
+    await rm(staging, { recursive: true, force: true });
+    throw error;
+  }
+  let previousStatePath: string | undefined;
+  if (hadState) {
+    // Backups aren't archived, so they, including the safety backup, move over
+    // as they are. The replaced state is only removed once they are safe.
+    const previousBackups = join(previous, 'backups');
+    const moved = await stat(previousBackups).then(
+      () => rename(previousBackups, join(request.basePath, BACKUPS_DIR)).then(() => true, () => false),
+      () => true,
+    );
+    if (moved) {
+      await rm(previous, { recursive: true, force: true });
+    } else {
+      previousStatePath = previous;
+    }
+  }
+
+  return {
+    archivePath: request.archivePath,
+    createdAt: archive.createdAt,
+    restored: archive.files.map((file) => file.path),
+    safetyBackupPath,
+    previousStatePath,
+  };
+}
+
+async function readVerifiedArchive(archivePath: string): Promise<BackupArchive> {
+  let archive: BackupArchive;
+  try {
+    archive = JSON.parse((await gunzipAsync(await readFile(archivePath))).toString('utf8')) as BackupArchive;
+  } catch (error) {
+    throw new Error(`Cannot read backup ${archivePath}: ${error instanceof Error ? error.message : String(error)}`);
+  }
+  if (archive.format !== BACKUP_FORMAT || archive.formatVersion !== BACKUP_FORMAT_VERSION || !Array.isArray(archive.files)) {
+    throw new Error(`${archivePath} is not an AutomatosX backup (format ${BACKUP_FORMAT} v${BACKUP_FORMAT_VERSION}).`);
+  }
+  if (manifestChecksum(archive.files) !== archive.checksum) {
+    throw new Error('Backup manifest checksum mismatch; the archive is corrupt.');
+  }
+  const seen = new Set<string>();
+  for (const file of archive.files) {
+    if (typeof file.path !== 'string' || seen.has(file.path) || file.path.split('/')[0] === 'backups') {
+      throw new Error(`Backup entry is not a restorable state file: ${String(file.path)}`);
+    }
+    seen.add(file.path);
+    // Only the shape of the path matters here, so any root will do.
+    resolveInside(AUTOMATOSX_DIR, file.path);
+  }
+  for (const file of archive.files) {
+    const data = Buffer.from(archive.contents?.[file.path] ?? '', 'base64');
+    if (data.length !== file.size || sha256(data) !== file.sha256) {
+      throw new Error(`Backup integrity check failed for ${file.path}.`);
+    }
+  }
+  return archive;
+}
+
+async function listStateFiles(root: string, dir: string): Promise<string[]> {
+  let entries;
+  try {
+    entries = await readdir(dir, { withFileTypes: true });
+  } catch {
+    return [];
+  }
+
+  const files: string[] = [];
+  for (const entry of entries) {
+    const absolutePath = join(dir, entry.name);
+    const path = relative(root, absolutePath).split(sep).join('/');
+    if (path === 'backups' || SQLITE_SIDECAR_SUFFIXES.some((suffix) => path.endsWith(`.db${suffix}`))) {
+      continue;
+    }
+    if (entry.isDirectory()) {
+      files.push(...await listStateFiles(root, absolutePath));
+    } else if (entry.isFile()) {
+      files.push(path);
+    }
+  }
+  return files.sort();
+}
+
+// SQLite stores run in WAL mode, so copying the main file alone can miss
+// committed pages. VACUUM INTO produces a consistent single-file snapshot.
+async function readStateFile(path: string): Promise<Buffer> {
+  if (extname(path) !== '.db') {
+    return readFile(path);
+  }
+  const snapshotPath = join(tmpdir(), `automatosx-backup-${process.pid}-${Date.now()}.db`);
+  const db = new DatabaseSync(path);
+  try {
+    db.exec(`VACUUM INTO '${snapshotPath.replace(/'/g, "''")}'`);
+  } finally {
+    db.close();
+  }
+  try {
+    return await readFile(snapshotPath);
+  } finally {
+    await rm(snapshotPath, { force: true });
+  }
+}
+
+function resolveInside(root: string, path: string): string {
+  const target = join(root, path);
+  const relativePath = relative(root, target);
+  if (isAbsolute(path) || relativePath.startsWith('..') || relativePath === '' || relativePath.split(sep).includes('..')) {
+    throw new Error(`Backup entry escapes ${AUTOMATOSX_DIR}: ${path}`);
+  }
+  return target;
+}
+
+function manifestChecksum(files: BackupFileEntry[]): string {
+  return sha256(Buffer.from(files.map((file) => `${file.path}\0${file.size}\0${file.sha256}`).join('\n'), 'utf8'));
+}
+
+function sha256(data: Buffer): string {
+  return createHash('sha256').update(data).digest('hex');
+}
@@ -0,0 +1,191 @@
+import { copyFile, mkdir, readdir, readFile, writeFile } from 'node:fs/promises';
+import { extname, join } from 'node:path';
+import type { RubricEvaluation } from './rubrics.js';
+
+export const BENCH_DIR = join('.automatosx', 'bench');
+
+export interface BenchTask {
+  taskId: string;
+  prompt: string;
+  provider?: string;
+  systemPrompt?: string;
+  rubricId?: string;
+}
+
+export type BenchResultStatus = 'match' | 'changed' | 'new' | 'failed';
+
+export interface BenchTaskResult {
+  taskId: string;
+  status: BenchResultStatus;
+  diff: string[];
+  traceId?: string;
+  latencyMs?: number;
+  rubric?: RubricEvaluation;
+  error?: string;
+}
+
+export interface RuntimeBenchResponse {
+  results: BenchTaskResult[];
+  summary: Record<BenchResultStatus, number>;
+  passed: boolean;
+}
+
+export interface BenchTaskOutput {
+  content: string;
+  traceId?: string;
+  latencyMs?: number;
+}
+
+export interface RuntimeBenchRequest {
+  basePath: string;
+  taskIds?: string[];
+  execute: (task: BenchTask) => Promise<BenchTaskOutput>;
+  evaluate?: (rubricId: string, output: string) => Promise<RubricEvaluation>;
+}
+
+export async function loadBenchTasks(basePath: string): Promise<BenchTask[]> {
+  const tasksDir = join(basePath, BENCH_DIR, 'tasks');
+  let fileNames: string[];
+  try {
+    fileNames = await readdir(tasksDir);
+  } catch {
+    return [];
+  }
+
+  const tasks: BenchTask[] = [];
+  for (const fileName of fileNames.filter((name) => extname(name) === '.json').sort()) {
+    const raw = JSON.parse(await readFile(join(tasksDir, fileName), 'utf8')) as Record<string, unknown>;
+    if (typeof raw.prompt !== 'string' || raw.prompt.length === 0) {
+      throw new Error(`Bench task ${fileName} is missing a prompt`);
+    }
+    tasks.push({
+      taskId: typeof raw.taskId === 'string' ? raw.taskId : fileName.slice(0, -'.json'.length),
+      prompt: raw.prompt,
+      provider: typeof raw.provider === 'string' ? raw.provider : undefined,
+      systemPrompt: typeof raw.systemPrompt === 'string' ? raw.systemPrompt : undefined,
+      rubricId: typeof raw.rubricId === 'string' ? raw.rubricId : undefined,
+    });
+  }
+  return tasks;
+}
+
+export async function runBench(request: RuntimeBenchRequest): Promise<RuntimeBenchResponse> {
+  const tasks = selectTasks(await loadBenchTasks(request.basePath), request.taskIds);
+  const latestDir = join(request.basePath, BENCH_DIR, 'latest');
+  await mkdir(latestDir, { recursive: true });
+
+  const results: BenchTaskResult[] = [];
+  for (const task of tasks) {
+    try {
+      const output = await request.execute(task);
+      const actual = normalizeOutput(output.content);
+      await writeFile(join(latestDir, `${task.taskId}.md`), actual, 'utf8');
+      const expected = await readOptionalFile(goldenPath(request.basePath, task.taskId));
+      const diff = expected === undefined ? [] : diffLines(expected, actual);
+      results.push({
+        taskId: task.taskId,
+        status: expected === undefined ? 'new' : diff.length === 0 ? 'match' : 'changed',
+        diff,
+        traceId: output.traceId,
+        latencyMs: output.latencyMs,
+        rubric: task.rubricId !== undefined && request.evaluate !== undefined
+          ? await request.evaluate(task.rubricId, actual)
+          : undefined,
+      });
+    } catch (error) {
+      results.push({
+        taskId: task.taskId,
+        status: 'failed',
+        diff: [],
+        error: error instanceof Error ? error.message : String(error),
+      });
+    }
+  }
+
+  const summary: Record<BenchResultStatus, number> = { match: 0, changed: 0, new: 0, failed: 0 };
+  for (const result of results) {
+    summary[result.status] += 1;
+  }
+  return {
+    results,
+    summary,
+    passed: summary.changed === 0 && summary.failed === 0 && results.every((result) => result.rubric?.passed !== false),
+  };
+}
+
+export async function approveBenchOutputs(basePath: string, taskIds?: string[]): Promise<string[]> {
+  const latestDir = join(basePath, BENCH_DIR, 'latest');
+  let available: string[];
+  try {
+    available = (await readdir(latestDir)).filter((name) => name.endsWith('.md')).map((name) => name.slice(0, -'.md'.length));
+  } catch {
+    return [];
+  }
+
+  const approved = taskIds === undefined || taskIds.length === 0
+    ? available.sort()
+    : taskIds.filter((taskId) => available.includes(taskId));
+  await mkdir(join(basePath, BENCH_DIR, 'golden'), { recursive: true });
+  for (const taskId of approved) {
+    await copyFile(join(latestDir, `${taskId}.md`), goldenPath(basePath, taskId));
+  }
+  return approved;
+}
+
+export function diffLines(expected: string, actual: string): string[] {
+  const left = expected.split('\n');
+  const right = actual.split('\n');
+  const width = right.length + 1;
+  const lengths = new Array<number>((left.length + 1) * width).fill(0);
+  const at = (i: number, j: number) => lengths[i * width + j] ?? 0;
+  for (let i = left.length - 1; i >= 0; i -= 1) {
+    for (let j = right.length - 1; j >= 0; j -= 1) {
+      lengths[i * width + j] = left[i] === right[j] ? at(i + 1, j + 1) + 1 : Math.max(at(i + 1, j), at(i, j + 1));
+    }
+  }
+
+  const diff: string[] = [];
+  let i = 0;
+  let j = 0;
+  while (i < left.length || j < right.length) {
+    if (i < left.length && j < right.length && left[i] === right[j]) {
+      i += 1;
+      j += 1;
+    } else if (j < right.length && (i === left.length || at(i, j + 1) >= at(i + 1, j))) {
+      diff.push(`+ ${right[j]}`);
+      j += 1;
+    } else {
+      diff.push(`- ${left[i]}`);
+      i += 1;
+    }
+  }
+  return diff;
+}
+
+function selectTasks(tasks: BenchTask[], taskIds: string[] | undefined): BenchTask[] {
+  if (taskIds === undefined || taskIds.length === 0) {
+    return tasks;
+  }
+  const unknown = taskIds.filter((taskId) => !tasks.some((task) => task.taskId === taskId));
+  if (unknown.length > 0) {
+    throw new Error(`Unknown bench task: ${unknown.join(', ')}`);
+  }
+  return tasks.filter((task) => taskIds.includes(task.taskId));
+}
+
+function normalizeOutput(content: string): string {
+  const lines = content.replace(/\r\n/g, '\n').split('\n').map((line) => line.trimEnd());
+  return `${lines.join('\n').trim()}\n`;
+}
+
+function goldenPath(basePath: string, taskId: string): string {
+  return join(basePath, BENCH_DIR, 'golden', `${taskId}.md`);
+}
+
+async function readOptionalFile(path: string): Promise<string | undefined> {
+  try {
+    return await readFile(path, 'utf8');
+  } catch {
+    return undefined;
+  }
+}
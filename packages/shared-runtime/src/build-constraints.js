@@ -0,0 +1,112 @@
+const GOOS = new Set([
+    'aix', 'android', 'darwin', 'dragonfly', 'freebsd', 'hurd', 'illumos', 'ios', 'js', 'linux', 'nacl', 'netbsd',
+    'openbsd', 'plan9', 'solaris', 'wasip1', 'windows', 'zos',
+]);
+const GOARCH = new Set([
+    '386', 'amd64', 'amd64p32', 'arm', 'arm64', 'arm64be', 'armbe', 'loong64', 'mips', 'mips64', 'mips64le', 'mips64p32',
+    'mips64p32le', 'mipsle', 'ppc', 'ppc64', 'ppc64le', 'riscv', 'riscv64', 's390', 's390x', 'sparc', 'sparc64', 'wasm',
+]);
+const UNIX = new Set(['aix', 'android', 'darwin', 'dragonfly', 'freebsd', 'hurd', 'illumos', 'ios', 'linux', 'netbsd', 'openbsd', 'solaris']);
+// GOOS values that also satisfy another one's tag, as in `go/build`.
+const IMPLIED_GOOS = { android: 'linux', illumos: 'solaris', ios: 'darwin' };
+/**
+ * The build constraint of a Go file as one expression: its `//go:build` line
+ * (or legacy `// +build` lines) above the package clause, and-ed with the
+ * GOOS/GOARCH implied by a `_linux`, `_arm64`, or `_linux_arm64` file name
+ * suffix. Undefined when the file builds everywhere.
+ */
+export function goBuildConstraint(content, path) {
+    const parts = [];
+    const legacy = [];
+    for (const line of content.split(/\r?\n/)) {
+        const trimmed = line.trim();
+        if (trimmed.startsWith('package ') || (trimmed.length > 0 && !trimmed.startsWith('//') && !trimmed.startsWith('/*') && !trimmed.startsWith('*'))) {
+            break;
+        }
+        const build = /^\/\/go:build\s+(.+)$/.exec(trimmed)?.[1];
+        if (build !== undefined) {
+            parts.push(build.trim());
+        }
+        const plus = /^\/\/\s*\+build\s+(.+)$/.exec(trimmed)?.[1];
+        if (plus !== undefined) {
+            // Spaces separate alternatives, commas join terms: `linux,amd64 darwin`.
+            legacy.push(plus.trim().split(/\s+/).map((option) => option.split(',').join(' && ')).join(' || '));
+        }
+    }
+    if (parts.length === 0 && legacy.length > 0) {
+        parts.push(legacy.map((expression) => (legacy.length > 1 && expression.includes('||') ? `(${expression})` : expression)).join(' && '));
+    }
+    const name = (path.split('/').pop() ?? '').replace(/\.go$/, '').replace(/_test$/, '');
+    const segments = name.split('_');
+    const last = segments[segments.length - 1] ?? '';
+    const previous = segments[segments.length - 2] ?? '';
+    const suffix = [];
+    if (segments.length > 2 && GOOS.has(previous) && GOARCH.has(last)) {
+        suffix.push(previous, last);
+    }
+    else if (segments.length > 1 && (GOOS.has(last) || GOARCH.has(last))) {
+        suffix.push(last);
+    }
+    if (suffix.length > 0) {
+        parts.push(...suffix);
+    }
+    if (parts.length === 0) {
+        return undefined;
+    }
+    return parts.length === 1 ? parts[0] : parts.map((part) => (/\|\|/.test(part) ? `(${part})` : part)).join(' && ');
+}
+/**
+ * Parses `linux`, `linux/arm64`, or `linux/amd64+integration+cgo`. The
+ * architecture defaults to amd64.
+ */
+export function parseGoPlatform(value) {
+    const [target = '', ...tags] = value.trim().split('+');
+    const [goos = '', goarch = 'amd64'] = target.split('/');
+    if (!GOOS.has(goos) || !GOARCH.has(goarch) || tags.some((tag) => !/^[\w.]+$/.test(tag))) {
+        throw new Error(`Invalid platform "${value}". Expected GOOS[/GOARCH][+tag...], e.g. linux/arm64 or windows+integration.`);
+    }
+    return { goos, goarch, tags };
+}
+/**
+ * Evaluates a `//go:build` expression (`!`, `&&`, `||`, parentheses) for a
+ * platform. The GOOS and GOARCH tags, `unix` on Unix-like systems, `gc`, and
+ * every `go1.N` release tag are set, plus the platform's extra tags.
+ */
+export function matchesBuildConstraint(expression, platform) {
+    const satisfied = (tag) => tag === platform.goos || tag === platform.goarch || tag === IMPLIED_GOOS[platform.goos]
+        || (tag === 'unix' && UNIX.has(platform.goos)) || tag === 'gc' || /^go1\.\d+$/.test(tag) || platform.tags.includes(tag);
+    const tokens = expression.match(/&&|\|\||[!()]|[\w.]+/g) ?? [];
+    let position = 0;
+    const parseOr = () => {
+        let value = parseAnd();
+        while (tokens[position] === '||') {
+            position += 1;
+            const right = parseAnd();
+            value = value || right;
+        }
+        return value;
+    };
+    const parseAnd = () => {
+        let value = parseUnary();
+        while (tokens[position] === '&&') {
+            position += 1;
+            const right = parseUnary();
+            value = value && right;
+        }
+        return value;
+    };
+    const parseUnary = () => {
+        const token = tokens[position];
+        position += 1;
+        if (token === '!') {
+            return !parseUnary();
+        }
+        if (token === '(') {
+            const value = parseOr();
+            position += 1;
+            return value;
+        }
+        return token !== undefined && satisfied(token);
+    };
+    return parseOr();
+}
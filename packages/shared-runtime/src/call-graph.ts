@@ -0,0 +1,414 @@
+import { dirname, extname } from 'node:path';
+import { implementerNames } from './implementations.js';
+import { parseSymbol } from './rename-impact.js';
+import { loadReferenceIndex, type IndexedFile } from './reference-index.js';
+import type { SymbolMatch } from './symbol-query.js';
+
+export interface CallGraphNode {
+  // `path:line` of the declaration.
+  id: string;
+  // `Receiver.Name` for methods.
+  name: string;
+  kind: 'function' | 'method';
+  path: string;
+  line: number;
+}
+
+export interface CallGraphEdge {
+  from: string;
+  to: string;
+  // Line of the call site in the caller's file.
+  line: number;
+  // The call goes through an interface, so `to` is one of its implementations.
+  dynamic?: boolean;
+  // The interface a dynamic call was made through.
+  via?: string;
+}
+
+export interface UnresolvedCall {
+  from: string;
+  // As written, e.g. `conn.Close`.
+  call: string;
+  line: number;
+  // Methods of that name the call could reach.
+  candidates: number;
+}
+
+export interface RuntimeCallGraphResponse {
+  symbol?: string;
+  direction: CallGraphDirection;
+  depth: number;
+  // Declarations the walk started from; empty for the whole graph.
+  roots: string[];
+  nodes: CallGraphNode[];
+  edges: CallGraphEdge[];
+  // Called names that are not declared in the workspace, such as `fmt.Println`.
+  external: string[];
+  // Method calls on a value of unknown type that several receivers could answer.
+  unresolved: UnresolvedCall[];
+  scannedFiles: number;
+  truncated: boolean;
+}
+
+export type CallGraphDirection = 'callees' | 'callers' | 'both';
+
+export interface ResolvedCalls {
+  nodes: Map<string, CallGraphNode>;
+  edges: CallGraphEdge[];
+  external: Map<string, Set<string>>;
+  unresolved: UnresolvedCall[];
+}
+
+type Callable = SymbolMatch & { id: string };
+
+const DEFAULT_DEPTH = 2;
+const MAX_DEPTH = 10;
+const DEFAULT_LIMIT = 500;
+// Call syntax right after an identifier: `(`, Go `[T](`, or TS/Java `<T>(`.
+const CALL_SUFFIX = /^\s*(?:\[[^\]()]*\]|<[\w\s,.[\]|]*>)?\s*\(/;
+// Go built-in functions and the predeclared types used as conversions, `string(b)`.
+const GO_PREDECLARED = new Set([
+  'append', 'cap', 'clear', 'close', 'complex', 'copy', 'delete', 'imag', 'len', 'make', 'max', 'min', 'new', 'panic',
+  'print', 'println', 'real', 'recover', 'any', 'bool', 'byte', 'complex64', 'complex128', 'error', 'float32', 'float64',
+  'int', 'int8', 'int16', 'int32', 'int64', 'rune', 'string', 'uint', 'uint8', 'uint16', 'uint32', 'uint64', 'uintptr',
+]);
+const SELF_QUALIFIERS = new Set(['this', 'self', 'super']);
+
+/**
+ * Who calls whom, per function and method, built on the reference index: a
+ * call is an identifier followed by `(` inside a function's span. Calls are
+ * resolved by name and scope rather than by type: a Go package qualifier
+ * reaches functions in that package's directory, `this`/`self` or the Go
+ * receiver reaches the caller's own type, and any other qualifier is typed
+ * from its declaration in the caller (`s *Store`, `s := &Store{}`,
+ * `s := NewStore()`) or a field of the caller's type. A value typed as an
+ * interface makes a dynamic edge to that method on each implementation.
+ *
+ * With a symbol, the graph is walked from its declarations `depth` calls deep
+ * towards callees, callers, or both; without one, every edge is returned.
+ */
+export async function buildCallGraph(request: {
+  basePath: string;
+  symbol?: string;
+  direction?: CallGraphDirection;
+  depth?: number;
+  paths?: string[];
+  limit?: number;
+}): Promise<RuntimeCallGraphResponse> {
+  const files = await loadReferenceIndex(request.basePath, request.paths);
+  const direction = request.direction ?? 'callees';
+  const depth = Math.min(Math.max(request.depth ?? DEFAULT_DEPTH, 1), MAX_DEPTH);
+  const limit = request.limit ?? DEFAULT_LIMIT;
+  const calls = resolveCalls(files);
+
+  let roots: string[] = [];
+  let edges = calls.edges;
+  if (request.symbol !== undefined) {
+    const target = parseSymbol(request.symbol);
+    const qualified = target.receiver !== undefined ? `${target.receiver}.${target.name}` : undefined;
+    roots = [...calls.nodes.values()]
+      .filter((node) => (qualified !== undefined ? node.name === qualified : node.name.split('.').pop() === target.name))
+      .map((node) => node.id);
+    if (roots.length === 0) {
+      throw new Error(`No function or method named ${request.symbol}`);
+    }
+    edges = walk(calls.edges, roots, direction, depth);
+  }
+
+  const ids = new Set([...roots, ...edges.flatMap((edge) => [edge.from, edge.to])]);
+  // External and unresolved calls are reported for the declarations whose calls were walked.
+  const walked = new Set(direction === 'callers' ? roots : [...roots, ...edges.map((edge) => edge.from)]);
+  return {
+    ...(request.symbol !== undefined ? { symbol: request.symbol } : {}),
+    direction,
+    depth,
+    roots,
+    nodes: [...ids].map((id) => calls.nodes.get(id)!).sort((left, right) => left.path.localeCompare(right.path) || left.line - right.line),
+    edges: edges.slice(0, limit),
+    external: [...calls.external.entries()]
+      .filter(([, from]) => request.symbol === undefined || [...from].some((id) => walked.has(id)))
+      .map(([name]) => name)
+      .sort(),
+    unresolved: calls.unresolved.filter((call) => request.symbol === undefined || walked.has(call.from)),
+    scannedFiles: files.size,
+    truncated: edges.length > limit,
+  };
+}
+
+/**
+ * Every call site in the indexed files, resolved to the declarations it can
+ * reach. Shared by the call graph and the analyses built on it.
+ */
+export function resolveCalls(files: Map<string, IndexedFile>): ResolvedCalls {
+  const callables = new Map<string, Callable[]>();
+  const others = new Map<string, SymbolMatch[]>();
+  const nodes = new Map<string, CallGraphNode>();
+  for (const [path, file] of files) {
+    for (const symbol of file.declarations) {
+      if (symbol.kind === 'function' || symbol.kind === 'method') {
+        const callable = { ...symbol, id: `${path}:${symbol.line}` };
+        callables.set(symbol.name, [...(callables.get(symbol.name) ?? []), callable]);
+        nodes.set(callable.id, {
+          id: callable.id,
+          name: symbol.receiver !== undefined ? `${symbol.receiver}.${symbol.name}` : symbol.name,
+          kind: symbol.kind,
+          path,
+          line: symbol.line,
+        });
+      } else {
+        others.set(symbol.name, [...(others.get(symbol.name) ?? []), symbol]);
+      }
+    }
+  }
+
+  const edges: CallGraphEdge[] = [];
+  const external = new Map<string, Set<string>>();
+  const unresolved: UnresolvedCall[] = [];
+  const seen = new Set<string>();
+  const implementers = new Map<string, Set<string>>();
+  const implementersOf = (name: string) => {
+    if (!implementers.has(name)) {
+      implementers.set(name, implementerNames(files, name));
+    }
+    return implementers.get(name)!;
+  };
+  for (const [path, file] of files) {
+    const go = extname(path).toLowerCase() === '.go';
+    const imports = go ? goImports(file.lines) : new Map<string, string>();
+    const spans = file.declarations
+      .filter((symbol) => symbol.kind === 'function' || symbol.kind === 'method')
+      .sort((left, right) => (left.endLine - left.line) - (right.endLine - right.line));
+    for (const [name, references] of file.references) {
+      for (const reference of references) {
+        const caller = spans.find((symbol) => symbol.line <= reference.line && reference.line <= symbol.endLine);
+        const line = file.lines[reference.line - 1] ?? '';
+        if (caller === undefined || !CALL_SUFFIX.test(line.slice(reference.column - 1 + name.length))) {
+          continue;
+        }
+        if (reference.line === caller.line && name === caller.name && reference.qualifier === undefined) {
+          continue;
+        }
+        const from = `${path}:${caller.line}`;
+        const call = reference.qualifier !== undefined ? `${reference.qualifier}.${name}` : name;
+        const resolution = resolveCall({ files, implementersOf, path, go, imports, caller, name, qualifier: reference.qualifier, callables, others });
+        if (resolution === 'ignore') {
+          continue;
+        }
+        if (resolution === 'external') {
+          if (!(go && reference.qualifier === undefined && GO_PREDECLARED.has(name))) {
+            external.set(call, (external.get(call) ?? new Set()).add(from));
+          }
+          continue;
+        }
+        if ('candidates' in resolution) {
+          unresolved.push({ from, call, line: reference.line, candidates: resolution.candidates });
+          continue;
+        }
+        for (const target of resolution.targets) {
+          const key = `${from}>${target.id}`;
+          if (seen.has(key)) {
+            continue;
+          }
+          seen.add(key);
+          edges.push({
+            from,
+            to: target.id,
+            line: reference.line,
+            ...(resolution.via !== undefined ? { dynamic: true, via: resolution.via } : {}),
+          });
+        }
+      }
+    }
+  }
+  edges.sort((left, right) => left.from.localeCompare(right.from) || left.line - right.line || left.to.localeCompare(right.to));
+  unresolved.sort((left, right) => left.from.localeCompare(right.from) || left.line - right.line);
+  return { nodes, edges, external, unresolved };
+}
+
+function resolveCall(context: {
+  files: Map<string, IndexedFile>;
+  implementersOf: (name: string) => Set<string>;
+  path: string;
+  go: boolean;
+  imports: Map<string, string>;
+  caller: SymbolMatch;
+  name: string;
+  qualifier?: string;
+  callables: Map<string, Callable[]>;
+  others: Map<string, SymbolMatch[]>;
+}): 'ignore' | 'external' | { targets: Callable[]; via?: string } | { candidates: number } {
+  const { path, go, caller, name, qualifier } = context;
+  const candidates = context.callables.get(name) ?? [];
+  const functions = candidates.filter((symbol) => symbol.kind === 'function');
+  const methods = candidates.filter((symbol) => symbol.kind === 'method');
+  const directory = dirname(path);
+
+  if (qualifier === undefined) {
+    // Go sees only its own package unqualified; other languages import by name.
+    const scoped = go
+      ? functions.filter((symbol) => dirname(symbol.path) === directory)
+      : pickNearest(functions, path);
+    if (scoped.length > 0) {
+      return { targets: scoped };
+    }
+    if (!go && caller.receiver !== undefined) {
+      const own = methods.filter((symbol) => symbol.receiver === caller.receiver);
+      if (own.length > 0) {
+        return { targets: own };
+      }
+    }
+    return (context.others.get(name) ?? []).length > 0 ? 'ignore' : 'external';
+  }
+
+  if (go && context.imports.has(qualifier)) {
+    const importPath = context.imports.get(qualifier)!;
+    const targets = functions.filter((symbol) => {
+      const dir = dirname(symbol.path);
+      return dir !== '.' && (importPath === dir || importPath.endsWith(`/${dir}`));
+    });
+    return targets.length > 0 ? { targets } : 'external';
+  }
+
+  if (caller.receiver !== undefined && (SELF_QUALIFIERS.has(qualifier) || qualifier === goReceiverVariable(caller))) {
+    const own = methods.filter((symbol) => symbol.receiver === caller.receiver);
+    if (own.length > 0) {
+      return { targets: own };
+    }
+  }
+
+  // Static calls and Rust/C++ paths: `Server.create()`, `Server::new()`.
+  const statics = methods.filter((symbol) => symbol.receiver === qualifier);
+  if (statics.length > 0) {
+    return { targets: statics };
+  }
+
+  const type = inferType(context.files, caller, qualifier, context.others);
+  if (type !== undefined) {
+    const declared = context.others.get(type) ?? [];
+    if (declared.some((symbol) => symbol.kind === 'interface')) {
+      const implementers = context.implementersOf(type);
+      const dispatched = methods.filter((symbol) => implementers.has(symbol.receiver!));
+      // Without known implementers, any method of that name may answer.
+      const targets = dispatched.length > 0 ? dispatched : methods;
+      return targets.length > 0 ? { targets, via: type } : 'external';
+    }
+    const typed = methods.filter((symbol) => symbol.receiver === type);
+    if (typed.length > 0) {
+      return { targets: typed };
+    }
+  }
+  if (methods.length === 1) {
+    return { targets: methods };
+  }
+  if (methods.length > 1) {
+    return { candidates: methods.length };
+  }
+  return 'external';
+}
+
+// Same file first, then the same directory, then anywhere.
+function pickNearest(symbols: Callable[], path: string): Callable[] {
+  const sameFile = symbols.filter((symbol) => symbol.path === path);
+  if (sameFile.length > 0) {
+    return sameFile;
+  }
+  const sameDirectory = symbols.filter((symbol) => dirname(symbol.path) === dirname(path));
+  if (sameDirectory.length > 0) {
+    return sameDirectory;
+  }
+  return symbols.length === 1 ? symbols : symbols.filter((symbol) => symbol.exported);
+}
+
+// `s` in `func (s *Server) Start()`.
+function goReceiverVariable(symbol: SymbolMatch): string | undefined {
+  return /^func\s*\(\s*(\w+)\s+\*?\s*\w/.exec(symbol.signature)?.[1];
+}
+
+/**
+ * The type name of a variable from how the caller declares it, or from a
+ * field of the caller's own type. Package qualifiers are dropped.
+ */
+function inferType(files: Map<string, IndexedFile>, caller: SymbolMatch, variable: string, others: Map<string, SymbolMatch[]>): string | undefined {
+  const name = escapeRegExp(variable);
+  const source = (symbol: SymbolMatch) => (files.get(symbol.path)?.lines ?? []).slice(symbol.line - 1, symbol.endLine).join('\n');
+  const text = source(caller);
+  const patterns = [
+    new RegExp(`\\b${name}\\s*:?=\\s*&?([\\w.]+)\\s*\\{`),
+    new RegExp(`\\b${name}\\s*:?=\\s*(?:[\\w]+\\.)?New(\\w+)\\s*\\(`),
+    new RegExp(`\\b${name}\\s*=\\s*new\\s+([\\w.]+)`),
+    new RegExp(`\\bvar\\s+${name}\\s+\\*?([\\w.]+)`),
+    new RegExp(`[(,]\\s*${name}\\s+\\*?([\\w.]+)`),
+    new RegExp(`\\b${name}\\??\\s*:\\s*([\\w.]+)`),
+  ];
+  for (const pattern of patterns) {
+    const type = pattern.exec(text)?.[1]?.split('.').pop();
+    if (type !== undefined && others.has(type)) {
+      return type;
+    }
+  }
+  if (caller.receiver === undefined) {
+    return undefined;
+  }
+  // A field of the receiver: `s.store.Get()`.
+  for (const owner of others.get(caller.receiver) ?? []) {
+    const field = new RegExp(`^\\s*(?:(?:private|protected|public|readonly)\\s+)*${name}\\??\\s*:?\\s+\\*?([\\w.]+)`, 'm')
+      .exec(source(owner))?.[1]?.split('.').pop();
+    if (field !== undefined && others.has(field)) {
+      return field;
+    }
+  }
+  return undefined;
+}
+
+// Import name (alias or last path element, skipping a `/vN` suffix) to import path.
+export function goImports(lines: string[]): Map<string, string> {
+  const imports = new Map<string, string>();
+  const text = lines.join('\n');
+  const specs = [
+    ...[...text.matchAll(/^import\s+((?:[\w.]+\s+)?"[^"]+")/gm)].map((match) => match[1]!),
+    ...[...text.matchAll(/^import\s*\(([\s\S]*?)^\)/gm)].flatMap((match) => match[1]!.split('\n')),
+  ];
+  for (const spec of specs) {
+    const match = /^\s*([\w.]+)?\s*"([^"]+)"/.exec(spec);
+    if (match?.[2] === undefined || match[1] === '.' || match[1] === '_') {
+      continue;
+    }
+    const segments = match[2].split('/');
+    const last = /^v\d+$/.test(segments[segments.length - 1] ?? '') && segments.length > 1 ? segments[segments.length - 2]! : segments[segments.length - 1]!;
+    imports.set(match[1] ?? last, match[2]);
+  }
+  return imports;
+}
+
+function walk(edges: CallGraphEdge[], roots: string[], direction: CallGraphDirection, depth: number): CallGraphEdge[] {
+  const kept = new Set<CallGraphEdge>();
+  const step = (forward: boolean) => {
+    let frontier = new Set(roots);
+    const visited = new Set(roots);
+    for (let level = 0; level < depth && frontier.size > 0; level += 1) {
+      const next = new Set<string>();
+      for (const edge of edges) {
+        const [from, to] = forward ? [edge.from, edge.to] : [edge.to, edge.from];
+        if (frontier.has(from)) {
+          kept.add(edge);
+          if (!visited.has(to)) {
+            visited.add(to);
+            next.add(to);
+          }
+        }
+      }
+      frontier = next;
+    }
+  };
+  if (direction !== 'callers') {
+    step(true);
+  }
+  if (direction !== 'callees') {
+    step(false);
+  }
+  return edges.filter((edge) => kept.has(edge));
+}
+
+function escapeRegExp(value: string): string {
+  return value.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
@@ -0,0 +1,216 @@
+import { randomUUID } from 'node:crypto';
+const DEFAULT_WINDOW_MS = 60_000;
+const DEFAULT_INTERVAL_MS = 10_000;
+const DEFAULT_TIMEOUT_MS = 5_000;
+const DEFAULT_MAX_ERROR_RATE_INCREASE = 0.05;
+const DEFAULT_MAX_LATENCY_RATIO = 1.5;
+export async function runCanaryVerification(traceStore, request) {
+    const startedAt = new Date().toISOString();
+    const endpoints = request.config.endpoints ?? [];
+    const baseline = request.config.baseline ?? {};
+    if (endpoints.length === 0) {
+        return {
+            verdict: 'skipped',
+            workflowId: request.workflowId,
+            samples: 0,
+            errorRate: 0,
+            p95LatencyMs: 0,
+            baseline,
+            endpoints: [],
+            reasons: ['No canary endpoints configured (canary.endpoints).'],
+            startedAt,
+            completedAt: startedAt,
+        };
+    }
+    const traceId = request.traceId ?? randomUUID();
+    const probe = request.probe ?? probeEndpoint;
+    const sleep = request.sleep ?? delay;
+    const windowMs = Math.max(0, request.config.windowMs ?? DEFAULT_WINDOW_MS);
+    const intervalMs = Math.max(1, request.config.intervalMs ?? DEFAULT_INTERVAL_MS);
+    const timeoutMs = request.config.timeoutMs ?? DEFAULT_TIMEOUT_MS;
+    const rounds = Math.max(1, Math.floor(windowMs / intervalMs));
+    const results = [];
+    for (let round = 0; round < rounds; round += 1) {
+        if (round > 0) {
+            await sleep(intervalMs);
+        }
+        results.push(...await Promise.all(endpoints.map((endpoint) => probe(endpoint, request.config.headers ?? {}, timeoutMs, request.config.expectedStatuses))));
+    }
+    const summaries = endpoints.map((endpoint) => summarizeProbes(endpoint, results.filter((result) => result.endpoint === endpoint)));
+    const overall = summarizeProbes('*', results);
+    const reasons = compareCanaryToBaseline(overall, baseline, request.config);
+    const verdict = reasons.length > 0 ? 'regressed' : 'healthy';
+    let rollback;
+    const rollbackWorkflowId = request.config.rollbackWorkflowId;
+    if (
+        verdict === 'regressed'
+        && request.config.autoRollback !== false
+        && rollbackWorkflowId !== undefined
+        && request.triggerRollback !== undefined
+    ) {
+        try {
+            rollback = await request.triggerRollback(rollbackWorkflowId, reasons);
+        }
+        catch (error) {
+            rollback = {
+                workflowId: rollbackWorkflowId,
+                success: false,
+                error: error instanceof Error ? error.message : String(error),
+            };
+        }
+    }
+    const completedAt = new Date().toISOString();
+    const response = {
+        traceId,
+        verdict,
+        workflowId: request.workflowId,
+        samples: overall.samples,
+        errorRate: overall.errorRate,
+        p95LatencyMs: overall.p95LatencyMs,
+        baseline,
+        endpoints: summaries,
+        reasons: verdict === 'healthy' ? [`Canary healthy across ${overall.samples} samples.`] : reasons,
+        rollback,
+        startedAt,
+        completedAt,
+    };
+    await traceStore.upsertTrace({
+        traceId,
+        workflowId: 'canary',
+        surface: request.surface ?? 'cli',
+        status: verdict === 'healthy' ? 'completed' : 'failed',
+        startedAt,
+        completedAt,
+        input: {
+            endpoints,
+            windowMs,
+            intervalMs,
+            workflowId: request.workflowId,
+        },
+        stepResults: [
+            {
+                stepId: 'poll-endpoints',
+                success: overall.failures === 0,
+                durationMs: Math.max(0, Date.parse(completedAt) - Date.parse(startedAt)),
+                retryCount: 0,
+            },
+            ...(rollback === undefined ? [] : [{
+                stepId: 'rollback',
+                success: rollback.success,
+                durationMs: 0,
+                retryCount: 0,
+                error: rollback.error,
+            }]),
+        ],
+        output: {
+            verdict,
+            errorRate: response.errorRate,
+            p95LatencyMs: response.p95LatencyMs,
+            endpoints: summaries,
+            reasons: response.reasons,
+            rollback,
+        },
+        error: verdict === 'regressed'
+            ? { code: 'CANARY_REGRESSION', message: reasons.join('; ') }
+            : undefined,
+        metadata: {
+            sessionId: request.sessionId,
+            verifiedWorkflowId: request.workflowId,
+            verdict,
+            rollbackTraceId: rollback?.traceId,
+        },
+    });
+    return response;
+}
+export function compareCanaryToBaseline(observed, baseline, config) {
+    const reasons = [];
+    const maxErrorRate = (baseline.errorRate ?? 0) + (config.maxErrorRateIncrease ?? DEFAULT_MAX_ERROR_RATE_INCREASE);
+    if (observed.errorRate > maxErrorRate) {
+        reasons.push(`error rate ${formatRate(observed.errorRate)} exceeds allowed ${formatRate(maxErrorRate)}`);
+    }
+    if (baseline.p95LatencyMs !== undefined && baseline.p95LatencyMs > 0) {
+        const maxLatency = baseline.p95LatencyMs * (config.maxLatencyRatio ?? DEFAULT_MAX_LATENCY_RATIO);
+        if (observed.p95LatencyMs > maxLatency) {
+            reasons.push(`p95 latency ${observed.p95LatencyMs}ms exceeds allowed ${Math.round(maxLatency)}ms`);
+        }
+    }
+    return reasons;
+}
+export function resolveCanaryConfig(value, overrides = {}) {
+    const source = isRecord(value) ? value : {};
+    const headers = isRecord(source.headers)
+        ? Object.fromEntries(Object.entries(source.headers).filter((entry) => typeof entry[1] === 'string'))
+        : undefined;
+    const baseline = isRecord(source.baseline)
+        ? {
+            errorRate: asOptionalNumber(source.baseline.errorRate),
+            p95LatencyMs: asOptionalNumber(source.baseline.p95LatencyMs),
+        }
+        : undefined;
+    return {
+        endpoints: overrides.endpoints ?? (Array.isArray(source.endpoints)
+            ? source.endpoints.filter((entry) => typeof entry === 'string' && entry.length > 0)
+            : undefined),
+        headers,
+        expectedStatuses: Array.isArray(source.expectedStatuses)
+            ? source.expectedStatuses.filter((entry) => typeof entry === 'number' && Number.isInteger(entry))
+            : undefined,
+        windowMs: overrides.windowMs ?? asOptionalNumber(source.windowMs),
+        intervalMs: overrides.intervalMs ?? asOptionalNumber(source.intervalMs),
+        timeoutMs: asOptionalNumber(source.timeoutMs),
+        baseline: overrides.baseline ?? baseline,
+        maxErrorRateIncrease: asOptionalNumber(source.maxErrorRateIncrease),
+        maxLatencyRatio: asOptionalNumber(source.maxLatencyRatio),
+        rollbackWorkflowId: overrides.rollbackWorkflowId ?? (typeof source.rollbackWorkflowId === 'string' ? source.rollbackWorkflowId : undefined),
+        autoRollback: overrides.autoRollback ?? (typeof source.autoRollback === 'boolean' ? source.autoRollback : undefined),
+    };
+}
+function summarizeProbes(endpoint, results) {
+    const failures = results.filter((result) => !result.ok).length;
+    const latencies = results.map((result) => result.latencyMs).sort((left, right) => left - right);
+    const p95Index = Math.max(0, Math.ceil(latencies.length * 0.95) - 1);
+    return {
+        endpoint,
+        samples: results.length,
+        failures,
+        errorRate: results.length === 0 ? 0 : failures / results.length,
+        p95LatencyMs: latencies[p95Index] ?? 0,
+    };
+}
+// A 401, 404, or 429 is a broken canary too, so only expected statuses count as healthy.
+async function probeEndpoint(endpoint, headers, timeoutMs, expectedStatuses) {
+    const started = Date.now();
+    try {
+        const response = await fetch(endpoint, {
+            headers,
+            signal: AbortSignal.timeout(timeoutMs),
+        });
+        await response.arrayBuffer();
+        return {
+            endpoint,
+            ok: expectedStatuses !== undefined && expectedStatuses.length > 0 ? expectedStatuses.includes(response.status) : response.ok,
+            status: response.status,
+            latencyMs: Date.now() - started,
+        };
+    }
+    catch (error) {
+        return {
+            endpoint,
+            ok: false,
+            latencyMs: Date.now() - started,
+            error: error instanceof Error ? error.message : String(error),
+        };
+    }
+}
+function delay(ms) {
+    return new Promise((resolve) => setTimeout(resolve, ms));
+}
+function formatRate(value) {
+    return `${Math.round(value * 1000) / 10}%`;
+}
+function asOptionalNumber(value) {
+    return typeof value === 'number' && Number.isFinite(value) ? value : undefined;
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
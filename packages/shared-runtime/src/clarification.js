@@ -0,0 +1,114 @@
+import { rankAskCandidates } from './ask.js';
+export const DEFAULT_CLARIFY_THRESHOLD = 0.6;
+export const DEFAULT_MAX_CLARIFYING_QUESTIONS = 3;
+// A memory entry or doc section answers a question when it scores at least this
+// (about two question terms, each found more than once).
+const MIN_ANSWER_SCORE = 8;
+const MAX_ANSWER_CHARS = 300;
+const VAGUE_WORDS = /\b(improve|better|optimi[sz]e|clean ?up|faster|nicer|enhance|handle|support|stuff|something|somehow|etc)\b/gi;
+const CRITERIA_WORDS = /\b(should|must|so that|expect(?:s|ed)?|returns?|until|tests?|when)\b/i;
+const TARGET = /`[^`]+`|\b[\w-]+\/[\w./-]+|\b[\w-]+\.[a-z]{1,5}\b|\b[a-z]+[A-Z]\w*|\b[a-z0-9]+_[a-z0-9_]+\b|\b[A-Z][a-z]+[A-Z]\w*/;
+const CHOICE = /\b([\w-]{3,}) or ([\w-]{3,})\b/i;
+const DANGLING_REFERENT = /^(it|this|that|these|those|they)\b/i;
+// `clarification` in config: `{"enabled": false, "threshold": 0.6, "maxQuestions": 3, "autoAnswer": true}`.
+export function resolveClarificationConfig(value) {
+    const config = isRecord(value) ? value : {};
+    return {
+        enabled: config.enabled === true,
+        threshold: typeof config.threshold === 'number' && config.threshold >= 0 && config.threshold <= 1 ? config.threshold : DEFAULT_CLARIFY_THRESHOLD,
+        maxQuestions: typeof config.maxQuestions === 'number' && Number.isInteger(config.maxQuestions) && config.maxQuestions >= 0
+            ? config.maxQuestions
+            : DEFAULT_MAX_CLARIFYING_QUESTIONS,
+        autoAnswer: config.autoAnswer !== false,
+    };
+}
+/**
+ * How clearly a task says what to do, from 1 down, and what it leaves open.
+ * Short tasks, no named file or symbol, vague goals ("improve", "clean up"),
+ * an undecided "A or B", no success criteria, and an opening "it"/"this"
+ * each lower the confidence; each gap comes with the question that closes it.
+ */
+export function assessTaskClarity(task, context = {}) {
+    const text = [task, context.goal].filter((part) => part !== undefined && part.trim().length > 0).join('\n').trim();
+    const words = text.split(/\s+/).filter((word) => word.length > 0).length;
+    const gaps = [];
+    if (words < 12) {
+        gaps.push({
+            gap: 'detail',
+            penalty: words < 6 ? 0.3 : 0.1,
+            question: 'What should happen that does not happen today, and where?',
+            search: text,
+        });
+    }
+    if ((context.files ?? []).length === 0 && !TARGET.test(text)) {
+        gaps.push({ gap: 'target', penalty: 0.2, question: 'Which files, modules, or functions does this touch?', search: `${text} module file` });
+    }
+    const vague = [...new Set([...text.matchAll(VAGUE_WORDS)].map((match) => match[1].toLowerCase()))];
+    if (vague.length > 0) {
+        gaps.push({
+            gap: 'outcome',
+            penalty: Math.min(0.3, 0.15 * vague.length),
+            question: `What counts as done for "${vague.join('", "')}": which behavior or metric should change, and by how much?`,
+            search: `${text} target metric`,
+        });
+    }
+    const choice = CHOICE.exec(text);
+    if (choice !== null && choice[2].toLowerCase() !== 'not') {
+        gaps.push({ gap: 'choice', penalty: 0.15, question: `Which should it be: ${choice[1]} or ${choice[2]}?`, search: `${choice[1]} ${choice[2]} decision` });
+    }
+    if (!CRITERIA_WORDS.test(text)) {
+        gaps.push({
+            gap: 'acceptance',
+            penalty: 0.1,
+            question: 'How will we know it works: which tests or observable behavior must pass?',
+            search: `${text} acceptance criteria tests`,
+        });
+    }
+    const referent = DANGLING_REFERENT.exec(task.trim());
+    if (referent !== null) {
+        gaps.push({ gap: 'referent', penalty: 0.1, question: `What does "${referent[1]}" refer to?`, search: text });
+    }
+    const confidence = Math.max(0, 1 - gaps.reduce((sum, gap) => sum + gap.penalty, 0));
+    return { confidence: Number(confidence.toFixed(2)), gaps: gaps.sort((left, right) => right.penalty - left.penalty) };
+}
+/**
+ * The clarification phase before planning: when the task's confidence is
+ * under the threshold, up to `maxQuestions` questions for its biggest gaps,
+ * each answered from memory or specs where one matches well enough. The
+ * caller asks the user the rest, or plans on stated assumptions.
+ */
+export async function clarifyTask(request) {
+    const { confidence, gaps } = assessTaskClarity(request.task, request);
+    const ambiguous = confidence < request.config.threshold;
+    const questions = [];
+    for (const gap of ambiguous ? gaps.slice(0, request.config.maxQuestions) : []) {
+        const [best] = request.config.autoAnswer
+            ? await rankAskCandidates({ basePath: request.basePath, question: gap.search, memory: request.memory })
+            : [];
+        questions.push({
+            gap: gap.gap,
+            question: gap.question,
+            ...(best !== undefined && best.score >= MIN_ANSWER_SCORE ? { answer: excerpt(best.text), source: best.ref } : {}),
+        });
+    }
+    return { task: request.task, confidence, threshold: request.config.threshold, ambiguous, questions };
+}
+// The clarifications as a prompt section; unanswered questions ask the planner to state its assumption.
+export function formatClarifications(questions) {
+    if (questions.length === 0) {
+        return '';
+    }
+    return [
+        'Clarifications:',
+        ...questions.map((question) => question.answer !== undefined
+            ? `Q: ${question.question}\nA: ${question.answer}${question.source !== undefined ? ` (from ${question.source})` : ''}`
+            : `Q: ${question.question}\nA: (unanswered; state the assumption you plan on)`),
+    ].join('\n');
+}
+function excerpt(text) {
+    const collapsed = text.replace(/^#{1,3}\s+.*$/m, '').trim().replace(/\s+/g, ' ');
+    return collapsed.length > MAX_ANSWER_CHARS ? `${collapsed.slice(0, MAX_ANSWER_CHARS - 3)}...` : collapsed;
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,192 @@
+import { extname } from 'node:path';
+import type { GeneratedCodeKind } from './generated-code.js';
+import { extractDeclarations, supportsStructuralDiff, type DeclarationKind } from './structural-diff.js';
+
+export type ChunkingStrategy = 'declarations' | 'lines' | 'whole';
+
+export interface ChunkingConfig {
+  default: ChunkingStrategy;
+  // Keyed by language name (typescript, javascript, go, python, ...) or extension (".sql").
+  languages: Record<string, ChunkingStrategy>;
+  maxLines: number;
+}
+
+export interface CodeChunk {
+  name: string;
+  kind: DeclarationKind | 'module' | 'lines';
+  startLine: number;
+  endLine: number;
+  content: string;
+}
+
+export interface RuntimeSemanticFileResponse {
+  key: string;
+  path: string;
+  strategy: ChunkingStrategy;
+  chunks: Array<{ key: string; name: string; kind: CodeChunk['kind']; startLine: number; endLine: number }>;
+  // Entries from a previous version of the file that no longer exist.
+  removed: string[];
+  // The path matched `.axignore`, so nothing was stored.
+  ignored?: boolean;
+  // Set for generated files; their chunks are tagged `generated` and left out of search by default.
+  generated?: GeneratedCodeKind;
+}
+
+const DEFAULT_MAX_LINES = 80;
+const CHUNKING_STRATEGIES: readonly ChunkingStrategy[] = ['declarations', 'lines', 'whole'];
+const LANGUAGES: Record<string, string> = {
+  '.ts': 'typescript',
+  '.tsx': 'typescript',
+  '.mts': 'typescript',
+  '.cts': 'typescript',
+  '.js': 'javascript',
+  '.jsx': 'javascript',
+  '.mjs': 'javascript',
+  '.cjs': 'javascript',
+  '.go': 'go',
+  '.py': 'python',
+  '.rs': 'rust',
+  '.java': 'java',
+  '.md': 'markdown',
+};
+// Lines that carry no retrievable meaning outside their declarations.
+const BOILERPLATE_LINE = /^\s*(?:$|import\b|package\b|export\s+(?:\*|\{[^}]*\})\s+from\b|['"]use strict['"];?$|[)}\]];?$)/;
+const DOC_LINE = /^\s*(?:\/\/|\/\*|\*|@[\w.]+|#\[|#\s)/;
+
+/**
+ * Reads `semantic.chunking` from config, e.g.
+ * `{"default": "lines", "go": "declarations", "maxLines": 60}`.
+ * Unconfigured languages chunk by declaration where the language is
+ * understood and fall back to fixed-size line windows otherwise.
+ */
+export function resolveChunkingConfig(value: unknown): ChunkingConfig {
+  const config: ChunkingConfig = { default: 'declarations', languages: {}, maxLines: DEFAULT_MAX_LINES };
+  if (!isRecord(value)) {
+    return config;
+  }
+  for (const [key, entry] of Object.entries(value)) {
+    if (key === 'maxLines') {
+      if (typeof entry === 'number' && Number.isInteger(entry) && entry > 0) {
+        config.maxLines = entry;
+      }
+    } else if (isChunkingStrategy(entry)) {
+      if (key === 'default') {
+        config.default = entry;
+      } else {
+        config.languages[key] = entry;
+      }
+    }
+  }
+  return config;
+}
+
+export function isChunkingStrategy(value: unknown): value is ChunkingStrategy {
+  return typeof value === 'string' && (CHUNKING_STRATEGIES as readonly string[]).includes(value);
+}
+
+export function resolveChunkingStrategy(path: string, config: ChunkingConfig): ChunkingStrategy {
+  const extension = extname(path).toLowerCase();
+  const strategy = config.languages[LANGUAGES[extension] ?? ''] ?? config.languages[extension] ?? config.default;
+  return strategy === 'declarations' && !supportsStructuralDiff(path) ? 'lines' : strategy;
+}
+
+export function chunkCode(content: string, path: string, config: ChunkingConfig): { strategy: ChunkingStrategy; chunks: CodeChunk[] } {
+  const strategy = resolveChunkingStrategy(path, config);
+  const lines = content.split(/\r?\n/);
+  if (strategy === 'whole') {
+    return { strategy, chunks: [{ name: path, kind: 'module', startLine: 1, endLine: lines.length, content }] };
+  }
+  const chunks = strategy === 'declarations' ? chunkByDeclaration(lines, content, path) : chunkByLines(lines, 1, config.maxLines, 'lines');
+  return { strategy, chunks: uniqueNames(chunks.flatMap((chunk) => splitOversized(chunk, config.maxLines))) };
+}
+
+// One chunk per function, type, class, or method, each with the comments and
+// decorators directly above it. A class chunk keeps its header and fields;
+// its methods become chunks of their own. Top-level code outside any
+// declaration (other than imports) is gathered into a single module chunk.
+function chunkByDeclaration(lines: string[], content: string, path: string): CodeChunk[] {
+  const ranges = extractDeclarations(content, path).map((declaration) => ({
+    declaration,
+    start: docStart(lines, declaration.line - 1),
+    end: declaration.endLine - 1,
+  }));
+  const owner = new Array<number>(lines.length).fill(-1);
+  // Outer declarations first, so nested methods claim their lines afterwards.
+  ranges
+    .map((range, index) => ({ range, index }))
+    .sort((left, right) => (right.range.end - right.range.start) - (left.range.end - left.range.start))
+    .forEach(({ range, index }) => owner.fill(index, range.start, range.end + 1));
+
+  const chunks: CodeChunk[] = ranges.flatMap((range, index) => {
+    const owned = lineNumbers(range.start, range.end).filter((line) => owner[line] === index);
+    if (owned.length === 0) {
+      return [];
+    }
+    return [{
+      name: range.declaration.name,
+      kind: range.declaration.kind,
+      startLine: range.start + 1,
+      endLine: range.end + 1,
+      content: owned.map((line) => lines[line]).join('\n'),
+    }];
+  });
+
+  const loose = lineNumbers(0, lines.length - 1).filter((line) => owner[line] === -1 && !BOILERPLATE_LINE.test(lines[line] ?? ''));
+  if (loose.length > 0) {
+    chunks.unshift({
+      name: '(module)',
+      kind: 'module',
+      startLine: loose[0]! + 1,
+      endLine: loose.at(-1)! + 1,
+      content: loose.map((line) => lines[line]).join('\n'),
+    });
+  }
+  return chunks;
+}
+
+function chunkByLines(lines: string[], firstLine: number, maxLines: number, name: string): CodeChunk[] {
+  const chunks: CodeChunk[] = [];
+  for (let start = 0; start < lines.length; start += maxLines) {
+    const window = lines.slice(start, start + maxLines);
+    if (window.every((line) => line.trim().length === 0)) {
+      continue;
+    }
+    const startLine = firstLine + start;
+    const endLine = startLine + window.length - 1;
+    chunks.push({ name: `${name} ${startLine}-${endLine}`, kind: 'lines', startLine, endLine, content: window.join('\n') });
+  }
+  return chunks;
+}
+
+function splitOversized(chunk: CodeChunk, maxLines: number): CodeChunk[] {
+  const lines = chunk.content.split('\n');
+  if (chunk.kind === 'lines' || lines.length <= maxLines) {
+    return [chunk];
+  }
+  return chunkByLines(lines, chunk.startLine, maxLines, chunk.name).map((part) => ({ ...part, kind: chunk.kind }));
+}
+
+// Overloads and same-named members would otherwise collide when used as keys.
+function uniqueNames(chunks: CodeChunk[]): CodeChunk[] {
+  const counts = new Map<string, number>();
+  for (const chunk of chunks) {
+    counts.set(chunk.name, (counts.get(chunk.name) ?? 0) + 1);
+  }
+  return chunks.map((chunk) => (counts.get(chunk.name) ?? 0) > 1 ? { ...chunk, name: `${chunk.name}@${chunk.startLine}` } : chunk);
+}
+
+function docStart(lines: string[], index: number): number {
+  let start = index;
+  while (start > 0 && DOC_LINE.test(lines[start - 1] ?? '')) {
+    start -= 1;
+  }
+  return start;
+}
+
+function lineNumbers(start: number, end: number): number[] {
+  return Array.from({ length: Math.max(0, end - start + 1) }, (_, offset) => start + offset);
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
@@ -0,0 +1,335 @@
+import { createHash } from 'node:crypto';
+import { readFile, stat } from 'node:fs/promises';
+import { join, resolve } from 'node:path';
+import { extractDeclarations, stripStringsAndComments } from './structural-diff.js';
+import { languageOf, listSourceFiles } from './symbol-query.js';
+const MAX_SCAN_BYTES = 1024 * 1024;
+const DEFAULT_LIMIT = 50;
+const DEFAULT_MIN_TOKENS = 50;
+const DEFAULT_MIN_LINES = 5;
+// Below this nearly every statement matches another.
+const MIN_WINDOW = 10;
+// Windows repeated more often than this are boilerplate, not copies worth a helper.
+const MAX_OCCURRENCES = 50;
+// Lists of literals (`"a", "b", ...`) normalize to a few tokens repeated; logic uses many.
+const MIN_DISTINCT_TOKENS = 8;
+const DEFAULT_WORKFLOW_TOP = 10;
+const SAME_COPY_OVERLAP = 0.8;
+const HASH_BASE = 1_000_003;
+const HASH_MOD = 1_000_000_007;
+const TOKEN = /[A-Za-z_$][\w$]*|\d[\w.]*|(["'`])\s*\1|===|!==|=>|->|:=|==|!=|<=|>=|&&|\|\||\?\?|\+\+|--|[^\s\w]/g;
+// Imports and package clauses repeat everywhere without being copies.
+const SKIPPED_LINE = /^\s*(?:import|package|#\s*include|using)\b|^\s*export\s+(?:\*|\{[^}]*\})\s+from\b/;
+// Words that mean the same thing across languages share a token, so a port still matches.
+const CANONICAL = {
+    function: 'fn',
+    func: 'fn',
+    fun: 'fn',
+    const: 'let',
+    let: 'let',
+    var: 'let',
+    val: 'let',
+    null: 'nil',
+    nil: 'nil',
+    undefined: 'nil',
+    NULL: 'nil',
+    nullptr: 'nil',
+    this: 'self',
+    self: 'self',
+    ':=': '=',
+    '===': '==',
+    '!==': '!=',
+};
+const KEYWORDS = new Set([
+    'if', 'else', 'for', 'foreach', 'while', 'do', 'switch', 'case', 'default', 'break', 'continue', 'return',
+    'try', 'catch', 'finally', 'throw', 'new', 'class', 'struct', 'interface', 'enum', 'type', 'extends',
+    'implements', 'async', 'await', 'yield', 'in', 'of', 'range', 'go', 'defer', 'select', 'true', 'false',
+    'typeof', 'instanceof', 'when', 'is', 'as',
+]);
+// Per workspace; a file is re-tokenized only when its size or mtime changes.
+const tokenIndexes = new Map();
+/**
+ * Finds code copied between files, or within one, under `paths`. Identifiers
+ * and literals are normalized away, so renamed copies still match, and
+ * keywords that differ only by language (`func`/`function`, `nil`/`null`)
+ * are unified, so ports across languages match too. Copies of at least
+ * `minTokens` tokens are grouped by their normalized text.
+ */
+export async function findDuplicates(request) {
+    const minTokens = Math.max(MIN_WINDOW, Math.floor(request.minTokens ?? DEFAULT_MIN_TOKENS));
+    const minLines = request.minLines ?? DEFAULT_MIN_LINES;
+    const files = [...(await loadTokenIndex(request.basePath, request.paths))].map(([path, file]) => ({ path, file }));
+    const ids = new Map();
+    const streams = files.map(({ file }) => file.tokens.map((token) => {
+        let id = ids.get(token);
+        if (id === undefined) {
+            id = ids.size + 1;
+            ids.set(token, id);
+        }
+        return id;
+    }));
+    const windows = new Map();
+    let power = 1;
+    for (let index = 1; index < minTokens; index += 1) {
+        power = (power * HASH_BASE) % HASH_MOD;
+    }
+    streams.forEach((stream, streamIndex) => {
+        let hash = 0;
+        for (let index = 0; index < stream.length; index += 1) {
+            if (index >= minTokens) {
+                hash = (hash - ((stream[index - minTokens] * power) % HASH_MOD) + HASH_MOD) % HASH_MOD;
+            }
+            hash = (hash * HASH_BASE + stream[index]) % HASH_MOD;
+            if (index >= minTokens - 1) {
+                const occurrences = windows.get(hash) ?? [];
+                occurrences.push({ stream: streamIndex, start: index - minTokens + 1 });
+                windows.set(hash, occurrences);
+            }
+        }
+    });
+    const matches = new Map();
+    for (const occurrences of windows.values()) {
+        if (occurrences.length < 2 || occurrences.length > MAX_OCCURRENCES) {
+            continue;
+        }
+        for (let first = 0; first < occurrences.length; first += 1) {
+            for (let second = first + 1; second < occurrences.length; second += 1) {
+                const left = occurrences[first];
+                const right = occurrences[second];
+                const leftStream = streams[left.stream];
+                const rightStream = streams[right.stream];
+                const sameFile = left.stream === right.stream;
+                if (sameFile && right.start - left.start < minTokens) {
+                    continue;
+                }
+                // Only the start of a run is extended; later windows of the same run are skipped here.
+                if (left.start > 0 && right.start > 0 && leftStream[left.start - 1] === rightStream[right.start - 1]) {
+                    continue;
+                }
+                let length = 0;
+                while (
+                    right.start + length < rightStream.length
+                    && leftStream[left.start + length] === rightStream[right.start + length]
+                    && (!sameFile || left.start + length < right.start)
+                ) {
+                    length += 1;
+                }
+                if (length < minTokens || new Set(leftStream.slice(left.start, left.start + length)).size < MIN_DISTINCT_TOKENS) {
+                    continue;
+                }
+                const key = createHash('sha1').update(files[left.stream].file.tokens.slice(left.start, left.start + length).join(' ')).digest('hex');
+                const match = matches.get(key) ?? { length, fragments: new Map() };
+                match.fragments.set(`${left.stream}:${left.start}`, left);
+                match.fragments.set(`${right.stream}:${right.start}`, right);
+                matches.set(key, match);
+            }
+        }
+    }
+    // Runs that differ by a token or two at their ends are one copy seen from different pairs.
+    const merged = [];
+    for (const [key, match] of [...matches].sort((left, right) => right[1].length - left[1].length)) {
+        const fragments = [...match.fragments.values()]
+            .map(({ stream, start }) => toFragment(files[stream].path, files[stream].file, start, match.length))
+            .filter((fragment, index, all) => !all.slice(0, index).some((earlier) => overlaps(earlier, fragment)));
+        if (fragments.length < 2) {
+            continue;
+        }
+        // Joining on a single shared fragment would chain unrelated clones; at least half of them must be shared.
+        const target = merged.find((group) => fragments
+            .filter((fragment) => group.fragments.some((existing) => sameCopy(existing, fragment))).length * 2 >= fragments.length);
+        if (target === undefined) {
+            merged.push({ key, tokens: match.length, fragments });
+            continue;
+        }
+        target.tokens = Math.min(target.tokens, match.length);
+        for (const fragment of fragments) {
+            const existing = target.fragments.find((candidate) => sameCopy(candidate, fragment));
+            if (existing === undefined) {
+                if (!target.fragments.some((candidate) => overlaps(candidate, fragment))) {
+                    target.fragments.push(fragment);
+                }
+            }
+            else {
+                existing.line = Math.min(existing.line, fragment.line);
+                existing.endLine = Math.max(existing.endLine, fragment.endLine);
+            }
+        }
+    }
+    const groups = [];
+    for (const { key, tokens, fragments } of merged) {
+        fragments.sort((left, right) => left.path.localeCompare(right.path) || left.line - right.line);
+        const lines = Math.max(...fragments.map((fragment) => fragment.endLine - fragment.line + 1));
+        if (lines < minLines) {
+            continue;
+        }
+        groups.push({
+            id: `dup-${key.slice(0, 8)}`,
+            tokens,
+            lines,
+            languages: [...new Set(fragments.map((fragment) => languageOf(fragment.path) ?? 'unknown'))].sort(),
+            fragments,
+        });
+    }
+    groups.sort((left, right) => (right.fragments.length - 1) * right.lines - (left.fragments.length - 1) * left.lines
+        || right.tokens - left.tokens
+        || left.fragments[0].path.localeCompare(right.fragments[0].path)
+        || left.fragments[0].line - right.fragments[0].line);
+    const limit = request.limit ?? DEFAULT_LIMIT;
+    return {
+        minTokens,
+        groups: groups.slice(0, limit),
+        summary: summarizeDuplicates(groups, files.map(({ file }) => file)),
+        scannedFiles: files.length,
+        truncated: groups.length > limit,
+    };
+}
+/**
+ * Reads the copies a workflow asks to consolidate through `input.duplicates`:
+ * `true` for the whole workspace, a path, or an object with `paths`,
+ * `minTokens`, `minLines`, and `top` (default 10 groups). Returns undefined
+ * when the input has none.
+ */
+export function resolveDuplicatesScope(value) {
+    if (value === true) {
+        return { limit: DEFAULT_WORKFLOW_TOP };
+    }
+    if (typeof value === 'string') {
+        return { paths: [value], limit: DEFAULT_WORKFLOW_TOP };
+    }
+    if (value === null || typeof value !== 'object' || Array.isArray(value)) {
+        return undefined;
+    }
+    const scope = value;
+    const paths = typeof scope.paths === 'string'
+        ? [scope.paths]
+        : Array.isArray(scope.paths) ? scope.paths.filter((entry) => typeof entry === 'string') : [];
+    const number = (entry) => typeof entry === 'number' && Number.isFinite(entry) ? entry : undefined;
+    return {
+        ...(paths.length > 0 ? { paths } : {}),
+        ...(number(scope.minTokens) !== undefined ? { minTokens: number(scope.minTokens) } : {}),
+        ...(number(scope.minLines) !== undefined ? { minLines: number(scope.minLines) } : {}),
+        limit: number(scope.top) ?? DEFAULT_WORKFLOW_TOP,
+    };
+}
+// One line per group, for prompts: `- dup-1a2b3c4d 24 lines x2 (go, ts): src/a.ts:10-33 parseA, api/b.go:5-28 ParseB`.
+export function renderDuplicates(groups) {
+    if (groups.length === 0) {
+        return 'No duplicate code in scope.';
+    }
+    return groups.map((group) => `- ${group.id} ${group.lines} lines x${group.fragments.length} (${group.languages.join(', ')}): ${group.fragments
+        .map((fragment) => `${fragment.path}:${fragment.line}-${fragment.endLine}${fragment.symbol !== undefined ? ` ${fragment.symbol}` : ''}`)
+        .join(', ')}`).join('\n');
+}
+
+// Normalized tokens for one file's content, each with its line.
+export function tokenizeForDuplicates(content, path = '') {
+  const tokens           = [];
+  const lines           = [];
+  stripStringsAndComments(content, false, path).split('\n').forEach((text, index) => {
+    if (SKIPPED_LINE.test(text)) {
+      return;
+    }
+    for (const match of text.matchAll(TOKEN)) {
+      const token = normalizeToken(match[0]);
+      if (token !== undefined) {
+        tokens.push(token);
+        lines.push(index + 1);
+      }
+    }
+  });
+  return { tokens, lines };
+}
+
+function normalizeToken(token        )                     {
+  if (token === ';') {
+    return undefined;
+  }
+  const canonical = CANONICAL[token];
+  if (canonical !== undefined) {
+    return canonical;
+  }
+  if (/^[A-Za-z_$]/.test(token)) {
+    return KEYWORDS.has(token) ? token : '$';
+  }
+  if (/^\d/.test(token)) {
+    return '0';
+  }
+  if (/^["'`]/.test(token)) {
+        return '"';
+    }
+    return token;
+}
+function toFragment(path, file, start, length) {
+    const line = file.lines[start];
+    const endLine = file.lines[start + length - 1];
+    const symbol = file.symbols
+        .filter((entry) => entry.line <= line && entry.endLine >= line)
+        .sort((left, right) => (left.endLine - left.line) - (right.endLine - right.line))[0];
+    return { path, line, endLine, ...(symbol !== undefined ? { symbol: symbol.name } : {}) };
+}
+// Two fragments of one file that cover nearly the same lines.
+function sameCopy(left, right) {
+    if (left.path !== right.path) {
+        return false;
+    }
+    const overlap = Math.min(left.endLine, right.endLine) - Math.max(left.line, right.line) + 1;
+    const longest = Math.max(left.endLine - left.line, right.endLine - right.line) + 1;
+    return overlap / longest >= SAME_COPY_OVERLAP;
+}
+function overlaps(left, right) {
+    return left.path === right.path && left.line <= right.endLine && right.line <= left.endLine;
+}
+function summarizeDuplicates(groups, files) {
+    const covered = new Set();
+    for (const group of groups) {
+        for (const fragment of group.fragments) {
+            for (let line = fragment.line; line <= fragment.endLine; line += 1) {
+                covered.add(`${fragment.path}:${line}`);
+            }
+        }
+    }
+    const codeLines = files.reduce((total, file) => total + new Set(file.lines).size, 0);
+    return {
+        groups: groups.length,
+        fragments: groups.reduce((total, group) => total + group.fragments.length, 0),
+        duplicatedLines: covered.size,
+        codeLines,
+        percentage: codeLines === 0 ? 0 : Number(((covered.size / codeLines) * 100).toFixed(1)),
+    };
+}
+async function loadTokenIndex(basePath, paths) {
+    const key = resolve(basePath);
+    const cache = tokenIndexes.get(key) ?? new Map();
+    tokenIndexes.set(key, cache);
+    const files = new Map();
+    for (const path of await listSourceFiles(basePath, paths)) {
+        const absolutePath = join(basePath, path);
+        let info;
+        try {
+            info = await stat(absolutePath);
+        }
+        catch {
+            continue;
+        }
+        if (info.size > MAX_SCAN_BYTES) {
+            continue;
+        }
+        const cached = cache.get(path);
+        if (cached !== undefined && cached.mtimeMs === info.mtimeMs && cached.size === info.size) {
+            files.set(path, cached);
+            continue;
+        }
+        const content = await readFile(absolutePath, 'utf8');
+        const tokenized = {
+            mtimeMs: info.mtimeMs,
+            size: info.size,
+            ...tokenizeForDuplicates(content, path),
+            symbols: extractDeclarations(content, path)
+                .filter((declaration) => declaration.kind !== 'variable')
+                .map((declaration) => ({ name: declaration.name, line: declaration.line, endLine: declaration.endLine })),
+        };
+        cache.set(path, tokenized);
+        files.set(path, tokenized);
+    }
+    return files;
+}
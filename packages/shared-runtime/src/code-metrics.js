@@ -0,0 +1,434 @@
+import { readFile, stat } from 'node:fs/promises';
+import { join, resolve } from 'node:path';
+import { extractDeclarations, stripStringsAndComments } from './structural-diff.js';
+import { listSourceFiles, matchesSymbolQuery, parseSymbolQuery, toSymbolMatch } from './symbol-query.js';
+export const CODE_METRICS_SORTS = ['cognitive', 'cyclomatic', 'nesting', 'parameters', 'lines'];
+// Past these a function is worth a refactor look; they match common linter defaults.
+export const CYCLOMATIC_THRESHOLD = 10;
+export const COGNITIVE_THRESHOLD = 15;
+const MAX_SCAN_BYTES = 1024 * 1024;
+const DEFAULT_LIMIT = 50;
+// Worst offenders handed to a refactor workflow when its input doesn't say how many.
+const DEFAULT_WORKFLOW_TOP = 10;
+const MAX_HOTSPOTS = 10;
+const BANDS = [
+    { band: '1-5', max: 5 },
+    { band: '6-10', max: 10 },
+    { band: '11-20', max: 20 },
+    { band: '21+', max: Number.POSITIVE_INFINITY },
+];
+const TOKEN = /\b(?:if|else|for|foreach|loop|while|do|switch|match|case|catch|select)\b|&&|\|\||\?\?|\?(?![.?:),=;])|=>|[{}();]/g;
+// Per workspace; a file is re-measured only when its size or mtime changes.
+const metricsIndexes = new Map();
+/**
+ * Measures every function and method under `paths`, optionally narrowed by a
+ * symbol query (`kind:method receiver:Server`), and returns the worst by
+ * `sort` alongside workspace-wide figures. The measures are heuristics read
+ * from the source text, not a full parse, so treat them as a ranking.
+ */
+export async function collectCodeMetrics(request) {
+    const sort = request.sort ?? 'cognitive';
+    if (!CODE_METRICS_SORTS.includes(sort)) {
+        throw new Error(`Unknown metrics sort "${sort}"; use ${CODE_METRICS_SORTS.join(', ')}.`);
+    }
+    const terms = request.query !== undefined && request.query.trim().length > 0 ? parseSymbolQuery(request.query) : [];
+    const files = await loadMetricsIndex(request.basePath, request.paths);
+    const all = [];
+    for (const [path, file] of files) {
+        for (const { exported, receiver, signature, ...metrics } of file.metrics) {
+            const symbol = { kind: metrics.kind, name: metrics.name.slice(receiver !== undefined ? receiver.length + 1 : 0), receiver, exported, path, line: metrics.line, endLine: metrics.endLine, signature };
+            if (terms.length === 0 || matchesSymbolQuery(symbol, terms)) {
+                all.push(metrics);
+            }
+        }
+    }
+    const selected = all
+        .filter((metrics) => request.minCyclomatic === undefined || metrics.cyclomatic >= request.minCyclomatic)
+        .filter((metrics) => request.minCognitive === undefined || metrics.cognitive >= request.minCognitive)
+        .sort((left, right) => right[sort] - left[sort]
+            || right.cognitive - left.cognitive
+            || right.cyclomatic - left.cyclomatic
+            || left.path.localeCompare(right.path)
+            || left.line - right.line);
+    const limit = request.limit ?? DEFAULT_LIMIT;
+    return {
+        sort,
+        symbols: selected.slice(0, limit),
+        summary: summarizeMetrics(all),
+        scannedFiles: files.size,
+        truncated: selected.length > limit,
+    };
+}
+// Metrics for each function and method in one file's content.
+export function measureDeclarations(content, path) {
+    return measureFile(content, path).map(({ exported: _exported, receiver: _receiver, signature: _signature, ...metrics }) => metrics);
+}
+/**
+ * Reads the refactor targets a workflow asks for through `input.metrics`:
+ * `true` for the whole workspace, a path, or an object with `paths`, `query`,
+ * `sort`, `top`, `minCognitive`, and `minCyclomatic`. At most `top` (default
+ * 10) functions are targeted. Returns undefined when the input has none.
+ */
+export function resolveMetricsScope(value) {
+    if (value === true) {
+        return { limit: DEFAULT_WORKFLOW_TOP };
+    }
+    if (typeof value === 'string') {
+        return { paths: [value], limit: DEFAULT_WORKFLOW_TOP };
+    }
+    if (value === null || typeof value !== 'object' || Array.isArray(value)) {
+        return undefined;
+    }
+    const scope = value;
+    const paths = typeof scope.paths === 'string'
+        ? [scope.paths]
+        : Array.isArray(scope.paths) ? scope.paths.filter((entry) => typeof entry === 'string') : [];
+    const number = (entry) => typeof entry === 'number' && Number.isFinite(entry) ? entry : undefined;
+    return {
+        ...(paths.length > 0 ? { paths } : {}),
+        ...(typeof scope.query === 'string' ? { query: scope.query } : {}),
+        ...(CODE_METRICS_SORTS.includes(scope.sort) ? { sort: scope.sort } : {}),
+        ...(number(scope.minCognitive) !== undefined ? { minCognitive: number(scope.minCognitive) } : {}),
+        ...(number(scope.minCyclomatic) !== undefined ? { minCyclomatic: number(scope.minCyclomatic) } : {}),
+        limit: number(scope.top) ?? DEFAULT_WORKFLOW_TOP,
+    };
+}
+// One line per function, for prompts: `- src/cart.ts:12 Cart.total cognitive 18, cyclomatic 11, 4 params, 60 lines`.
+export function renderCodeMetrics(symbols) {
+    if (symbols.length === 0) {
+        return 'No functions in scope.';
+    }
+    return symbols.map((metrics) => `- ${metrics.path}:${metrics.line} ${metrics.name} cognitive ${metrics.cognitive}, cyclomatic ${metrics.cyclomatic}, nesting ${metrics.nesting}, ${metrics.parameters} params, ${metrics.lines} lines`).join('\n');
+}
+function measureFile(content, path) {
+    const code = stripStringsAndComments(content, false, path).split('\n');
+    return extractDeclarations(content, path)
+        .filter((declaration) => declaration.kind === 'function' || declaration.kind === 'method')
+        .map((declaration) => {
+            const { exported, receiver, signature } = toSymbolMatch(declaration, path);
+            return { ...measureDeclaration(declaration, code, path), exported, ...(receiver !== undefined ? { receiver } : {}), signature };
+        });
+}
+function measureDeclaration(declaration, code, path) {
+    const text = code.slice(declaration.line - 1, declaration.endLine).join('\n');
+    const { cyclomatic, cognitive, nesting } = /\.pyi?$/i.test(path) ? measurePythonComplexity(text) : measureComplexity(text);
+    return {
+        name: declaration.name,
+        kind: declaration.kind,
+        path,
+        line: declaration.line,
+        endLine: declaration.endLine,
+        cyclomatic,
+        cognitive,
+        nesting,
+        parameters: countParameters(declaration.signature),
+        lines: declaration.endLine - declaration.line + 1,
+        codeLines: text.split('\n').filter((line) => line.trim().length > 0).length,
+    };
+}
+// `text` has comments and strings blanked; scanning starts at the body's opening brace.
+function measureComplexity(text) {
+    let cyclomatic = 1;
+    let cognitive = 0;
+    let deepest = 0;
+    let parens = 0;
+    let started = false;
+    // What opened each enclosing brace: a branch or nested function nests, `do` also marks its
+    // closing `while`, and a Rust `match` counts each `=>` arm like a case.
+    const blocks = [];
+    let pending = 'plain';
+    let closedDo = false;
+    let lastOperator;
+    let previous;
+    for (const match of text.matchAll(TOKEN)) {
+        const token = match[0];
+        if (!started) {
+            if (token === '(') {
+                parens += 1;
+            }
+            else if (token === ')') {
+                parens -= 1;
+            }
+            else if (token === '{' && parens === 0) {
+                started = true;
+                parens = 0;
+            }
+            continue;
+        }
+        const nesting = blocks.filter((block) => block !== 'plain').length;
+        const afterDo = closedDo;
+        closedDo = false;
+        switch (token) {
+            case '{':
+                blocks.push(pending);
+                deepest = Math.max(deepest, nesting + (pending === 'plain' ? 0 : 1));
+                pending = 'plain';
+                lastOperator = undefined;
+                break;
+            case '}':
+                closedDo = blocks.pop() === 'do';
+                lastOperator = undefined;
+                break;
+            case '(':
+                parens += 1;
+                break;
+            case ')':
+                parens -= 1;
+                break;
+            case ';':
+                if (parens === 0) {
+                    pending = 'plain';
+                    lastOperator = undefined;
+                }
+                break;
+            case 'if':
+                cyclomatic += 1;
+                cognitive += previous === 'else' ? 0 : 1 + nesting;
+                pending = 'nest';
+                break;
+            case 'else':
+                cognitive += 1;
+                pending = 'nest';
+                break;
+            case 'while':
+                cyclomatic += 1;
+                // The `while` closing a do-while was counted at `do`.
+                if (!afterDo) {
+                    cognitive += 1 + nesting;
+                    pending = 'nest';
+                }
+                break;
+            case 'for':
+            case 'foreach':
+            case 'loop':
+            case 'switch':
+            case 'match':
+            case 'select':
+            case 'catch':
+                cyclomatic += token === 'switch' || token === 'match' ? 0 : 1;
+                cognitive += 1 + nesting;
+                pending = token === 'match' ? 'match' : 'nest';
+                break;
+            case 'do':
+                cognitive += 1 + nesting;
+                pending = 'do';
+                break;
+            case 'case':
+                cyclomatic += 1;
+                break;
+            case '&&':
+            case '||':
+                cyclomatic += 1;
+                cognitive += lastOperator === token ? 0 : 1;
+                lastOperator = token;
+                break;
+            case '??':
+                cyclomatic += 1;
+                break;
+            case '?':
+                cyclomatic += 1;
+                cognitive += 1 + nesting;
+                break;
+            case '=>':
+                if (blocks[blocks.length - 1] === 'match') {
+                    cyclomatic += 1;
+                }
+                else {
+                    pending = 'nest';
+                }
+                break;
+        }
+        previous = token;
+    }
+    return { cyclomatic, cognitive, nesting: deepest };
+}
+// Python has no braces: nesting follows indentation, one statement per
+// logical line, and `and`/`or` play the part of `&&`/`||`. Branches inside a
+// line (conditional expressions, comprehension filters) count like ternaries.
+function measurePythonComplexity(text) {
+    let cyclomatic = 1;
+    let cognitive = 0;
+    let deepest = 0;
+    // Indentation of each enclosing statement that nests: branches, loops, handlers, and nested functions.
+    const nests = [];
+    let brackets = 0;
+    let continued = false;
+    let header = true;
+    let lastOperator;
+    for (const line of text.split('\n')) {
+        if (line.trim().length === 0) {
+            continue;
+        }
+        let rest = line.trim();
+        if (brackets === 0 && !continued) {
+            const indent = line.search(/\S/);
+            while (nests.length > 0 && nests[nests.length - 1] >= indent) {
+                nests.pop();
+            }
+            const nesting = nests.length;
+            // The function's own header is not a branch.
+            const keyword = header ? null : /^(?:async\s+)?(if|elif|else|for|while|except|match|case|def)\b/.exec(rest);
+            header = false;
+            lastOperator = undefined;
+            switch (keyword?.[1]) {
+                case 'if':
+                case 'for':
+                case 'while':
+                case 'except':
+                    cyclomatic += 1;
+                    cognitive += 1 + nesting;
+                    break;
+                case 'match':
+                    cognitive += 1 + nesting;
+                    break;
+                case 'elif':
+                    cyclomatic += 1;
+                    cognitive += 1;
+                    break;
+                case 'else':
+                    cognitive += 1;
+                    break;
+                case 'case':
+                    cyclomatic += 1;
+                    break;
+            }
+            if (keyword !== null && keyword[1] !== 'case') {
+                nests.push(indent);
+                deepest = Math.max(deepest, nests.length);
+            }
+            rest = rest.slice(keyword?.[0].length ?? 0);
+        }
+        for (const [token] of rest.matchAll(/\b(?:if|and|or)\b|[([{}\])]/g)) {
+            if ('([{'.includes(token)) {
+                brackets += 1;
+            }
+            else if (')]}'.includes(token)) {
+                brackets -= 1;
+            }
+            else if (token === 'if') {
+                cyclomatic += 1;
+                cognitive += 1 + nests.length;
+            }
+            else {
+                cyclomatic += 1;
+                cognitive += lastOperator === token ? 0 : 1;
+                lastOperator = token;
+            }
+        }
+        continued = /\\\s*$/.test(line);
+    }
+    return { cyclomatic, cognitive, nesting: deepest };
+}
+// Top-level entries in the parameter list; a Go method's receiver, a Rust or
+// Python method's `self` (or `cls`), and Python's bare `*` and `/` markers are
+// not parameters.
+function countParameters(header) {
+    // Python decorators come first and may have argument lists of their own.
+    const signature = header.slice(Math.max(0, header.search(/\bdef\s+\w+\s*\(/)));
+    const groups = [];
+    let depth = 0;
+    let start = -1;
+    for (let index = 0; index < signature.length; index += 1) {
+        const char = signature[index];
+        if (char === '(') {
+            if (depth === 0) {
+                start = index + 1;
+            }
+            depth += 1;
+        }
+        else if (char === ')') {
+            depth -= 1;
+            if (depth === 0 && start !== -1) {
+                groups.push(signature.slice(start, index));
+                start = -1;
+            }
+        }
+    }
+    const list = /^func\s*\(/.test(signature) ? groups[1] : groups[0];
+    if (list === undefined) {
+        // `x => x * 2`
+        return /=>\s*$/.test(signature) ? 1 : 0;
+    }
+    if (/^\s*(?:void)?\s*$/.test(list)) {
+        return 0;
+    }
+    const python = /^def\b/.test(signature);
+    let count = /^\s*(?:&\s*(?:'\w+\s+)?)?(?:mut\s+)?self\b/.test(list) || (python && /^\s*cls\b/.test(list)) ? 0 : 1;
+    if (python) {
+        count -= list.split(',').filter((entry) => /^\s*[*/]\s*$/.test(entry)).length;
+    }
+    let nested = 0;
+    for (let index = 0; index < list.length; index += 1) {
+        const char = list[index];
+        if ('([{'.includes(char) || (char === '<')) {
+            nested += 1;
+        }
+        else if (')]}'.includes(char) || (char === '>' && list[index - 1] !== '=' && list[index - 1] !== '-')) {
+            nested -= 1;
+        }
+        else if (char === ',' && nested === 0) {
+            count += 1;
+        }
+    }
+    return count;
+}
+function summarizeMetrics(metrics) {
+    const byPath = new Map();
+    for (const entry of metrics) {
+        const file = byPath.get(entry.path) ?? { path: entry.path, functions: 0, cognitive: 0 };
+        file.functions += 1;
+        file.cognitive += entry.cognitive;
+        byPath.set(entry.path, file);
+    }
+    const average = (values) => values.length === 0 ? 0 : Number((values.reduce((sum, value) => sum + value, 0) / values.length).toFixed(2));
+    return {
+        functions: metrics.length,
+        averageCyclomatic: average(metrics.map((entry) => entry.cyclomatic)),
+        averageCognitive: average(metrics.map((entry) => entry.cognitive)),
+        maxCyclomatic: Math.max(0, ...metrics.map((entry) => entry.cyclomatic)),
+        maxCognitive: Math.max(0, ...metrics.map((entry) => entry.cognitive)),
+        thresholds: { cyclomatic: CYCLOMATIC_THRESHOLD, cognitive: COGNITIVE_THRESHOLD },
+        overCyclomatic: metrics.filter((entry) => entry.cyclomatic > CYCLOMATIC_THRESHOLD).length,
+        overCognitive: metrics.filter((entry) => entry.cognitive > COGNITIVE_THRESHOLD).length,
+        distribution: BANDS.map(({ band, max }, index) => ({
+            band,
+            count: metrics.filter((entry) => entry.cyclomatic <= max && (index === 0 || entry.cyclomatic > BANDS[index - 1].max)).length,
+        })),
+        hotspots: [...byPath.values()]
+            .filter((file) => file.cognitive > 0)
+            .sort((left, right) => right.cognitive - left.cognitive || left.path.localeCompare(right.path))
+            .slice(0, MAX_HOTSPOTS),
+    };
+}
+async function loadMetricsIndex(basePath, paths) {
+    const key = resolve(basePath);
+    const cache = metricsIndexes.get(key) ?? new Map();
+    metricsIndexes.set(key, cache);
+    const files = new Map();
+    for (const path of await listSourceFiles(basePath, paths)) {
+        const absolutePath = join(basePath, path);
+        let info;
+        try {
+            info = await stat(absolutePath);
+        }
+        catch {
+            continue;
+        }
+        if (info.size > MAX_SCAN_BYTES) {
+            continue;
+        }
+        const cached = cache.get(path);
+        if (cached !== undefined && cached.mtimeMs === info.mtimeMs && cached.size === info.size) {
+            files.set(path, cached);
+            continue;
+        }
+        const measured = {
+            mtimeMs: info.mtimeMs,
+            size: info.size,
+            metrics: measureFile(await readFile(absolutePath, 'utf8'), path),
+        };
+        cache.set(path, measured);
+        files.set(path, measured);
+    }
+    return files;
+}
@@ -0,0 +1,271 @@
+import { extname } from 'node:path';
+const JS_EXTENSIONS = new Set(['.ts', '.mts', '.cts', '.js', '.mjs', '.cjs']);
+const MIN_QUOTE_SAMPLES = 3;
+export function detectCodeStyle(content, path) {
+    const lines = content.split('\n').map((line) => line.replace(/\r$/, ''));
+    return {
+        indent: detectIndent(lines),
+        quote: isJavaScriptLike(path) ? detectQuote(lines) : undefined,
+        lineEnding: content.includes('\r\n') ? '\r\n' : '\n',
+        sortedImports: isJavaScriptLike(path) && importsSorted(lines),
+    };
+}
+/**
+ * Rewrites generated lines to the target file's conventions: indentation unit,
+ * quote style for plain string literals, and line endings. Lines are matched
+ * one to one, so the pass never adds or removes lines.
+ */
+export function conformLines(lines, target, source, path) {
+    const adjustments = new Set();
+    // Quotes are converted per literal, so mixed generated code needs no source style.
+    const quote = target.quote;
+    const reindent = target.indent !== undefined && source.indent !== undefined && !sameIndent(target.indent, source.indent);
+    const conformed = lines.map((line) => {
+        let next = line.replace(/\r$/, '');
+        if (reindent) {
+            const reindented = reindentLine(next, source.indent, target.indent);
+            if (reindented !== next) {
+                adjustments.add(`indentation → ${describeIndent(target.indent )}`);
+                next = reindented;
+            }
+        }
+        if (quote !== undefined && isJavaScriptLike(path)) {
+            const requoted = convertQuotes(next, quote);
+            if (requoted !== next) {
+                adjustments.add(`quotes → ${quote}`);
+                next = requoted;
+            }
+        }
+        if (target.lineEnding === '\r\n') {
+            next = `${next}\r`;
+        }
+        return next;
+    });
+    if (target.lineEnding === '\r\n' && lines.some((line) => !line.endsWith('\r'))) {
+        adjustments.add('line endings → CRLF');
+    }
+    return { lines: conformed, adjustments: [...adjustments] };
+}
+/** Restyles a whole replacement file against the file it overwrites. */
+export function conformToFileStyle(content, original, path) {
+    const target = detectCodeStyle(original, path);
+    const source = detectCodeStyle(content, path);
+    const hadFinalNewline = content.endsWith('\n');
+    const lines = content.replace(/\r?\n$/, '').split('\n');
+    const result = conformLines(lines, target, source, path);
+    let conformed = result.lines;
+    const adjustments = [...result.adjustments];
+    if (target.sortedImports) {
+        const sorted = sortImportBlock(conformed);
+        if (sorted !== undefined) {
+            conformed = sorted;
+            adjustments.push('imports sorted');
+        }
+    }
+    // Conformed lines carry their own \r under CRLF, so joining on \n is enough.
+    return { content: conformed.join('\n') + (hadFinalNewline ? '\n' : ''), adjustments };
+}
+/** Sorts the leading block of single-line imports by module specifier; undefined when already sorted. */
+export function sortImportBlock(lines) {
+    const block = findImportBlock(lines);
+    if (block === undefined) {
+        return undefined;
+    }
+    const imports = lines.slice(block.start, block.end);
+    const sorted = [...imports].sort((left, right) => compareSpecifiers(importSpecifier(left), importSpecifier(right)));
+    if (sorted.every((line, index) => line === imports[index])) {
+        return undefined;
+    }
+    return [...lines.slice(0, block.start), ...sorted, ...lines.slice(block.end)];
+}
+function detectIndent(lines) {
+    let tabLines = 0;
+    let spaceLines = 0;
+    const deltas = new Map();
+    let previous = 0;
+    for (const line of lines) {
+        if (line.trim().length === 0) {
+            continue;
+        }
+        const leading = /^[ \t]*/.exec(line)?.[0] ?? '';
+        if (leading.startsWith('\t')) {
+            tabLines += 1;
+            continue;
+        }
+        // JSDoc continuation lines (" * ...") are offset by one and would skew the unit.
+        if (/^ +\*/.test(line)) {
+            continue;
+        }
+        const width = leading.length;
+        if (width > 0) {
+            spaceLines += 1;
+        }
+        const delta = width - previous;
+        if (delta > 0) {
+            deltas.set(delta, (deltas.get(delta) ?? 0) + 1);
+        }
+        previous = width;
+    }
+    if (tabLines > spaceLines) {
+        return { kind: 'tab' };
+    }
+    let best;
+    for (const [delta, count] of deltas) {
+        if (best === undefined || count > best[1] || (count === best[1] && delta < best[0])) {
+            best = [delta, count];
+        }
+    }
+    return best === undefined ? undefined : { kind: 'space', width: best[0] };
+}
+function detectQuote(lines) {
+    let single = 0;
+    let double = 0;
+    for (const line of lines) {
+        for (const literal of scanStringLiterals(line) ?? []) {
+            if (literal === "'") {
+                single += 1;
+            }
+            else if (literal === '"') {
+                double += 1;
+            }
+        }
+    }
+    if (single >= MIN_QUOTE_SAMPLES && single > double * 2) {
+        return 'single';
+    }
+    if (double >= MIN_QUOTE_SAMPLES && double > single * 2) {
+        return 'double';
+    }
+    return undefined;
+}
+function reindentLine(line, source, target) {
+    const leading = /^[ \t]*/.exec(line)?.[0] ?? '';
+    if (leading.length === 0) {
+        return line;
+    }
+    let level;
+    let remainder;
+    if (source.kind === 'tab') {
+        level = /^\t*/.exec(leading)?.[0].length ?? 0;
+        remainder = leading.length - level;
+    }
+    else {
+        if (leading.includes('\t')) {
+            return line;
+        }
+        level = Math.floor(leading.length / source.width);
+        remainder = leading.length % source.width;
+    }
+    const indent = target.kind === 'tab' ? '\t'.repeat(level) : ' '.repeat(level * target.width);
+    return `${indent}${' '.repeat(remainder)}${line.slice(leading.length)}`;
+}
+function convertQuotes(line, target) {
+    // Lines with a bare slash may hold regex literals the scanner cannot read safely.
+    if (scanStringLiterals(line) === undefined) {
+        return line;
+    }
+    const from = target === 'single' ? '"' : "'";
+    const to = target === 'single' ? "'" : '"';
+    let output = '';
+    let index = 0;
+    while (index < line.length) {
+        const char = line[index] ?? '';
+        if (char === '/' && line[index + 1] === '/') {
+            return output + line.slice(index);
+        }
+        if (char === '"' || char === "'" || char === '`') {
+            const end = findClosingQuote(line, index);
+            if (end === undefined) {
+                return output + line.slice(index);
+            }
+            const body = line.slice(index + 1, end);
+            output += char === from && !body.includes(to) && !body.includes('\\')
+                ? `${to}${body}${to}`
+                : line.slice(index, end + 1);
+            index = end + 1;
+            continue;
+        }
+        output += char;
+        index += 1;
+    }
+    return output;
+}
+// Returns the opening quote of each literal on the line, or undefined when the
+// line has a slash outside strings and comments (possible regex literal).
+function scanStringLiterals(line) {
+    const quotes = [];
+    let index = 0;
+    while (index < line.length) {
+        const char = line[index] ?? '';
+        if (char === '/' && line[index + 1] === '/') {
+            break;
+        }
+        if (char === '/') {
+            return undefined;
+        }
+        if (char === '"' || char === "'" || char === '`') {
+            const end = findClosingQuote(line, index);
+            if (end === undefined) {
+                break;
+            }
+            quotes.push(char);
+            index = end + 1;
+            continue;
+        }
+        index += 1;
+    }
+    return quotes;
+}
+function findClosingQuote(line, start) {
+    const quote = line[start];
+    for (let index = start + 1; index < line.length; index += 1) {
+        if (line[index] === '\\') {
+            index += 1;
+        }
+        else if (line[index] === quote) {
+            return index;
+        }
+    }
+    return undefined;
+}
+function importsSorted(lines) {
+    const block = findImportBlock(lines);
+    if (block === undefined || block.end - block.start < 2) {
+        return false;
+    }
+    const specifiers = lines.slice(block.start, block.end).map(importSpecifier);
+    return specifiers.every((specifier, index) => index === 0 || compareSpecifiers(specifiers[index - 1] ?? '', specifier) <= 0);
+}
+function findImportBlock(lines) {
+    const isImport = (line) => /^import\s.*['"][^'"]+['"];?\s*$/.test(line.replace(/\r$/, ''));
+    const start = lines.findIndex(isImport);
+    if (start === -1) {
+        return undefined;
+    }
+    let end = start;
+    while (end < lines.length && isImport(lines[end] ?? '')) {
+        end += 1;
+    }
+    return { start, end };
+}
+function importSpecifier(line) {
+    return /['"]([^'"]+)['"];?\s*$/.exec(line.replace(/\r$/, ''))?.[1] ?? '';
+}
+function compareSpecifiers(left, right) {
+    // Package imports before relative ones, the usual eslint import/order grouping.
+    const leftRelative = left.startsWith('.');
+    const rightRelative = right.startsWith('.');
+    if (leftRelative !== rightRelative) {
+        return leftRelative ? 1 : -1;
+    }
+    return left.localeCompare(right);
+}
+function sameIndent(left, right) {
+    return left.kind === right.kind && (left.kind === 'tab' || left.width === (right).width);
+}
+function describeIndent(indent) {
+    return indent.kind === 'tab' ? 'tabs' : `${indent.width} spaces`;
+}
+function isJavaScriptLike(path) {
+    return JS_EXTENSIONS.has(extname(path).toLowerCase());
+}
@@ -0,0 +1,196 @@
+import { readFileSync } from 'node:fs';
+import { join } from 'node:path';
+import { lookupTemplateValue, renderTemplate } from '@defai.digital/workflow-engine';
+const TOOL_NAME = /^[a-z][a-z0-9_.-]*$/;
+const SINGLE_PLACEHOLDER = /^\{\{\s*([A-Za-z_][\w-]*(?:\.[\w-]+)*)\s*\}\}$/;
+const CONDITION_REFERENCE = /^\$\{([^}]+)\}$/;
+const CONDITION_COMPARISON = /^\$\{([^}]+)\}\s*(===|!==|==|!=|>=|<=|>|<)\s*(.+)$/;
+/**
+ * Reads `compositeTools` from .automatosx/config.json. This is synchronous
+ * because MCP tool lists are built when the surface is created.
+ */
+export function readCompositeTools(basePath) {
+    let config;
+    try {
+        config = JSON.parse(readFileSync(join(basePath, '.automatosx', 'config.json'), 'utf8'));
+    }
+    catch {
+        return { tools: [], errors: [] };
+    }
+    return parseCompositeTools(isRecord(config) ? config.compositeTools : undefined);
+}
+// Invalid entries are reported in `errors` and left out; the rest still load.
+export function parseCompositeTools(value) {
+    if (value === undefined) {
+        return { tools: [], errors: [] };
+    }
+    if (!isRecord(value)) {
+        return { tools: [], errors: ['compositeTools must be an object keyed by tool name'] };
+    }
+    const tools = [];
+    const errors = [];
+    for (const [name, entry] of Object.entries(value)) {
+        try {
+            tools.push(parseCompositeTool(name, entry));
+        }
+        catch (error) {
+            errors.push(error instanceof Error ? error.message : String(error));
+        }
+    }
+    return { tools, errors };
+}
+/**
+ * Runs the steps in order, mapping each step's args from the composite's
+ * input and earlier results. A step whose `when` is false is skipped; a failed
+ * step stops the pipeline unless it sets `continueOnError`.
+ */
+export async function runCompositeTool(definition, input, invoke) {
+    const results = [];
+    const steps = {};
+    const variables = { input, steps };
+    let lastData;
+    for (const step of definition.steps) {
+        if (step.when !== undefined && !evaluateCompositeCondition(step.when, variables)) {
+            steps[step.id] = { status: 'skipped', success: false };
+            results.push({ id: step.id, tool: step.tool, status: 'skipped' });
+            continue;
+        }
+        const result = await invoke(step.tool, mapCompositeValue(step.args, variables));
+        if (result.success) {
+            steps[step.id] = { status: 'succeeded', success: true, data: result.data };
+            results.push({ id: step.id, tool: step.tool, status: 'succeeded', data: result.data });
+            lastData = result.data;
+            continue;
+        }
+        steps[step.id] = { status: 'failed', success: false, error: result.error };
+        results.push({ id: step.id, tool: step.tool, status: 'failed', error: result.error });
+        if (!step.continueOnError) {
+            return {
+                success: false,
+                steps: results,
+                error: `Step ${step.id} (${step.tool}) failed: ${result.error ?? 'unknown error'}`,
+            };
+        }
+    }
+    return {
+        success: true,
+        output: definition.output === undefined ? lastData : mapCompositeValue(definition.output, variables),
+        steps: results,
+    };
+}
+export function mapCompositeValue(value, variables) {
+    if (typeof value === 'string') {
+        const single = SINGLE_PLACEHOLDER.exec(value);
+        return single !== null ? lookupTemplateValue(variables, single[1]) : renderTemplate(value, variables).text;
+    }
+    if (Array.isArray(value)) {
+        return value.map((entry) => mapCompositeValue(entry, variables));
+    }
+    if (isRecord(value)) {
+        // Unresolved single placeholders drop the key, so optional tool arguments stay unset.
+        return Object.fromEntries(Object.entries(value)
+            .map(([key, entry]) => [key, mapCompositeValue(entry, variables)])
+            .filter(([, entry]) => entry !== undefined));
+    }
+    return value;
+}
+export function evaluateCompositeCondition(condition, variables) {
+    const trimmed = condition.trim();
+    if (trimmed.startsWith('!')) {
+        return !evaluateCompositeCondition(trimmed.slice(1), variables);
+    }
+    const comparison = CONDITION_COMPARISON.exec(trimmed);
+    if (comparison !== null) {
+        const actual = lookupTemplateValue(variables, comparison[1].trim());
+        return compareValues(actual, parseLiteral(comparison[3].trim()), comparison[2]);
+    }
+    const reference = CONDITION_REFERENCE.exec(trimmed);
+    if (reference !== null) {
+        const value = lookupTemplateValue(variables, reference[1].trim());
+        // An empty list (no lint findings, no failing tests) counts as false.
+        return Array.isArray(value) ? value.length > 0 : Boolean(value);
+    }
+    return trimmed === 'true';
+}
+function parseCompositeTool(name, value) {
+    if (!TOOL_NAME.test(name)) {
+        throw new Error(`Composite tool "${name}" must use lower-case letters, digits, "_", ".", or "-"`);
+    }
+    if (!isRecord(value)) {
+        throw new Error(`Composite tool ${name} must be an object`);
+    }
+    if (!Array.isArray(value.steps) || value.steps.length === 0) {
+        throw new Error(`Composite tool ${name} needs at least one step`);
+    }
+    const ids = new Set();
+    const steps = value.steps.map((entry, index) => {
+        if (!isRecord(entry) || typeof entry.tool !== 'string' || entry.tool.length === 0) {
+            throw new Error(`Composite tool ${name} step ${index + 1} needs a tool`);
+        }
+        const id = typeof entry.id === 'string' && entry.id.length > 0 ? entry.id : `step${index + 1}`;
+        if (ids.has(id)) {
+            throw new Error(`Composite tool ${name} has more than one step "${id}"`);
+        }
+        ids.add(id);
+        if (entry.args !== undefined && !isRecord(entry.args)) {
+            throw new Error(`Composite tool ${name} step ${id} args must be an object`);
+        }
+        if (entry.when !== undefined && (typeof entry.when !== 'string' || !isCondition(entry.when))) {
+            throw new Error(`Composite tool ${name} step ${id} has an unsupported when: use \${path}, !\${path}, or \${path} <op> <value>`);
+        }
+        return {
+            id,
+            tool: entry.tool,
+            args: entry.args ?? {},
+            when: entry.when,
+            continueOnError: entry.continueOnError === true,
+        };
+    });
+    if (value.inputSchema !== undefined && (!isRecord(value.inputSchema) || value.inputSchema.type !== 'object')) {
+        throw new Error(`Composite tool ${name} inputSchema must be an object schema`);
+    }
+    return {
+        name,
+        description: typeof value.description === 'string' && value.description.length > 0
+            ? value.description
+            : `Runs ${steps.map((step) => step.tool).join(', then ')}.`,
+        inputSchema: value.inputSchema,
+        steps,
+        output: value.output,
+    };
+}
+function isCondition(condition) {
+    const trimmed = condition.trim().replace(/^!/, '').trim();
+    return CONDITION_REFERENCE.test(trimmed) || CONDITION_COMPARISON.test(trimmed) || trimmed === 'true' || trimmed === 'false';
+}
+function parseLiteral(value) {
+    try {
+        return JSON.parse(value);
+    }
+    catch {
+        return value.replace(/^'(.*)'$/, '$1');
+    }
+}
+function compareValues(actual, expected, op) {
+    switch (op) {
+        case '===':
+        case '==':
+            return actual === expected || String(actual) === String(expected);
+        case '!==':
+        case '!=':
+            return actual !== expected && String(actual) !== String(expected);
+        case '>':
+            return Number(actual) > Number(expected);
+        case '>=':
+            return Number(actual) >= Number(expected);
+        case '<':
+            return Number(actual) < Number(expected);
+        case '<=':
+            return Number(actual) <= Number(expected);
+        default:
+            return false;
+    }
+}
+function isRecord(value) {
+    return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
@@ -0,0 +1,212 @@
+import { createHash } from 'node:crypto';
+import { readdir, readFile } from 'node:fs/promises';
+import { homedir } from 'node:os';
+import { join, resolve } from 'node:path';
+
+export const CONVERSATION_IMPORT_NAMESPACE = 'conversations';
+export const CONVERSATION_SOURCES = ['claude-code', 'gemini-cli'] as const;
+
+// Long answers are cut here; the opening of an answer is what searches match on.
+const MAX_EXCHANGE_CHARS = 8_000;
+// Slash commands, their output, and injected notices rather than things a person asked.
+const NOT_A_PROMPT = /^\s*(?:<command-name>|<command-message>|<local-command-stdout>|<local-command-stderr>|<system-reminder>|Caveat: )/;
+
+export type ConversationSource = typeof CONVERSATION_SOURCES[number];
+
+export interface ConversationExchange {
+  source: ConversationSource;
+  sessionId: string;
+  // Id of the prompt's message, stable across re-reads of the same history.
+  messageId: string;
+  at?: string;
+  prompt: string;
+  response: string;
+  file: string;
+  model?: string;
+}
+
+export interface ConversationImportSourceSummary {
+  source: ConversationSource;
+  sessions: number;
+  exchanges: number;
+  imported: number;
+  // Exchanges already in memory from an earlier import.
+  skipped: number;
+}
+
+export interface RuntimeConversationImportResponse {
+  namespace: string;
+  dryRun: boolean;
+  imported: number;
+  skipped: number;
+  sources: ConversationImportSourceSummary[];
+}
+
+export function isConversationSource(value: string): value is ConversationSource {
+  return (CONVERSATION_SOURCES as readonly string[]).includes(value);
+}
+
+/**
+ * Reads the prompts and answers of this workspace's sessions from the history
+ * Claude Code keeps in ~/.claude/projects and Gemini CLI in ~/.gemini/tmp,
+ * oldest first. A prompt is paired with the text the model answered before the
+ * next prompt; tool calls, tool output, slash commands, and prompts without an
+ * answer are left out. Missing or unreadable history reads as none.
+ */
+export async function readConversationHistory(request: {
+  basePath: string;
+  sources?: ConversationSource[];
+  since?: string;
+  homeDir?: string;
+}): Promise<Array<{ source: ConversationSource; sessions: number; exchanges: ConversationExchange[] }>> {
+  const home = request.homeDir ?? homedir();
+  const workspace = resolve(request.basePath);
+  const sources = request.sources ?? [...CONVERSATION_SOURCES];
+  const since = request.since === undefined ? undefined : Date.parse(request.since);
+  if (since !== undefined && Number.isNaN(since)) {
+    throw new Error(`Invalid --since time: ${request.since}`);
+  }
+  const results: Array<{ source: ConversationSource; sessions: number; exchanges: ConversationExchange[] }> = [];
+  for (const source of CONVERSATION_SOURCES.filter((candidate) => sources.includes(candidate))) {
+    const sessions = source === 'claude-code' ? await readClaudeCodeSessions(home, workspace) : await readGeminiCliSessions(home, workspace);
+    const exchanges = sessions.flat().filter((exchange) => since === undefined || (exchange.at !== undefined && Date.parse(exchange.at) >= since));
+    exchanges.sort((left, right) => (left.at ?? '').localeCompare(right.at ?? ''));
+    results.push({ source, sessions: new Set(exchanges.map((exchange) => exchange.sessionId)).size, exchanges });
+  }
+  return results;
+}
+
+export function conversationMemoryKey(exchange: ConversationExchange): string {
+  return `${exchange.source}/${exchange.sessionId}/${exchange.messageId}`;
+}
+
+export function conversationMemoryContent(exchange: ConversationExchange): string {
+  const content = `User: ${exchange.prompt}\n\nAssistant: ${exchange.response}`;
+  return content.length > MAX_EXCHANGE_CHARS ? `${content.slice(0, MAX_EXCHANGE_CHARS)}…` : content;
+}
+
+// Claude Code names each project directory after its path with every other character turned into `-`.
+async function readClaudeCodeSessions(home: string, workspace: string): Promise<ConversationExchange[][]> {
+  const directory = join(home, '.claude', 'projects', workspace.replace(/[^A-Za-z0-9]/g, '-'));
+  const sessions: ConversationExchange[][] = [];
+  for (const name of await listFiles(directory, '.jsonl')) {
+    const file = join(directory, name);
+    const exchanges: ConversationExchange[] = [];
+    let current: ConversationExchange | undefined;
+    for (const line of (await readText(file)).split('\n')) {
+      const record = parseJson(line);
+      if (!isRecord(record) || record.isSidechain === true || !isRecord(record.message)) {
+        continue;
+      }
+      if (record.type === 'user' && record.isMeta !== true) {
+        const prompt = messageText(record.message.content);
+        if (prompt.length > 0 && !NOT_A_PROMPT.test(prompt)) {
+          current = {
+            source: 'claude-code',
+            sessionId: typeof record.sessionId === 'string' ? record.sessionId : name.replace(/\.jsonl$/, ''),
+            messageId: typeof record.uuid === 'string' ? record.uuid : String(exchanges.length),
+            ...(typeof record.timestamp === 'string' ? { at: record.timestamp } : {}),
+            prompt,
+            response: '',
+            file,
+          };
+          exchanges.push(current);
+        }
+      } else if (record.type === 'assistant' && current !== undefined) {
+        current.response = joinText(current.response, messageText(record.message.content));
+        if (typeof record.message.model === 'string') {
+          current.model = record.message.model;
+        }
+      }
+    }
+    sessions.push(exchanges.filter((exchange) => exchange.response.length > 0));
+  }
+  return sessions;
+}
+
+// Gemini CLI keeps a project's chats under the SHA-256 of its path, one JSON file per session.
+async function readGeminiCliSessions(home: string, workspace: string): Promise<ConversationExchange[][]> {
+  const directory = join(home, '.gemini', 'tmp', createHash('sha256').update(workspace).digest('hex'), 'chats');
+  const sessions: ConversationExchange[][] = [];
+  for (const name of await listFiles(directory, '.json')) {
+    const file = join(directory, name);
+    const session = parseJson(await readText(file));
+    if (!isRecord(session) || !Array.isArray(session.messages)) {
+      continue;
+    }
+    const sessionId = typeof session.sessionId === 'string' ? session.sessionId : name.replace(/\.json$/, '');
+    const exchanges: ConversationExchange[] = [];
+    let current: ConversationExchange | undefined;
+    for (const [index, message] of session.messages.entries()) {
+      if (!isRecord(message)) {
+        continue;
+      }
+      const text = messageText(message.content);
+      if (message.type === 'user' && text.length > 0 && !text.startsWith('/')) {
+        current = {
+          source: 'gemini-cli',
+          sessionId,
+          messageId: typeof message.id === 'string' ? message.id : String(index),
+          ...(typeof message.timestamp === 'string' ? { at: message.timestamp } : {}),
+          prompt: text,
+          response: '',
+          file,
+        };
+        exchanges.push(current);
+      } else if (message.type === 'gemini' && current !== undefined) {
+        current.response = joinText(current.response, text);
+        if (typeof message.model === 'string') {
+          current.model = message.model;
+        }
+      }
+    }
+    sessions.push(exchanges.filter((exchange) => exchange.response.length > 0));
+  }
+  return sessions;
+}
+
+// Plain strings, or the text parts of a content array; tool calls and results carry no text part.
+function messageText(content: unknown): string {
+  if (typeof content === 'string') {
+    return content.trim();
+  }
+  if (!Array.isArray(content)) {
+    return '';
+  }
+  return content
+    .flatMap((part) => (isRecord(part) && (part.type === undefined || part.type === 'text') && typeof part.text === 'string' ? [part.text.trim()] : []))
+    .filter((text) => text.length > 0)
+    .join('\n\n');
+}
+
+function joinText(left: string, right: string): string {
+  return left.length === 0 ? right : right.length === 0 ? left : `${left}\n\n${right}`;
+}
+
+async function listFiles(directory: string, extension: string): Promise<string[]> {
+  try {
+    return (await readdir(directory)).filter((name) => name.endsWith(extension)).sort();
+  } catch {
+    return [];
+  }
+}
+
+async function readText(file: string): Promise<string> {
+  try {
+    return await readFile(file, 'utf8');
+  } catch {
+    return '';
+  }
+}
+
+function parseJson(text: string): unknown {
+  try {
+    return JSON.parse(text) as unknown;
+  } catch {
+    return undefined;
+  }
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,200 @@
+import { execFile } from 'node:child_process';
+import { mkdir, readdir, readFile, writeFile } from 'node:fs/promises';
+import { homedir } from 'node:os';
+import { join } from 'node:path';
+import { promisify } from 'node:util';
+const execFileAsync = promisify(execFile);
+export const CRASH_REPORTS_DIR = join('.automatosx', 'crash-reports');
+export const BUG_REPORT_REPOSITORY = 'defai-digital/AutomatosX';
+const REDACTED = '[REDACTED]';
+const MAX_STACK_FRAMES = 15;
+const MAX_ISSUE_URL_BODY_LENGTH = 6000;
+const SENSITIVE_KEY_PATTERN = /(secret|token|password|passwd|api[-_]?key|credential|authorization|cookie|private[-_]?key)/i;
+const SECRET_VALUE_PATTERNS = [
+    [/\bsk-[A-Za-z0-9_-]{16,}/g, REDACTED],
+    [/\bgh[pousr]_[A-Za-z0-9]{20,}/g, REDACTED],
+    [/\bAKIA[0-9A-Z]{16}\b/g, REDACTED],
+    [/\bxox[abpr]-[A-Za-z0-9-]{10,}/g, REDACTED],
+    [/\bBearer\s+[A-Za-z0-9._~+/-]+=*/gi, `Bearer ${REDACTED}`],
+    [/\b(api[-_]?key|token|secret|password)(["']?\s*[:=]\s*["']?)[^\s"',}]+/gi, `$1$2${REDACTED}`],
+];
+export function buildCrashBundle(input) {
+    const now = input.now ?? new Date();
+    const error = input.error instanceof Error ? input.error : new Error(String(input.error));
+    const code = readErrorCode(error);
+    const scrub = (text) => sanitizeText(text, input.basePath);
+    return {
+        bundleId: `crash-${now.getTime()}`,
+        createdAt: now.toISOString(),
+        surface: input.surface,
+        category: categorizeCrash(error, code),
+        command: input.command,
+        error: {
+            name: error.name,
+            message: scrub(error.message),
+            code,
+            stack: (error.stack ?? '')
+                .split('\n')
+                .slice(1)
+                .map((line) => scrub(line.trim()))
+                .filter((line) => line.length > 0)
+                .slice(0, MAX_STACK_FRAMES),
+        },
+        versions: {
+            automatosx: input.version,
+            node: process.version,
+            platform: process.platform,
+            arch: process.arch,
+        },
+        config: sanitizeConfig(input.config, input.basePath),
+        recentActivity: input.traces.map((trace) => [
+            trace.startedAt,
+            trace.surface,
+            trace.workflowId,
+            trace.status,
+            trace.error?.code,
+        ].filter((part) => part !== undefined).join(' ')),
+    };
+}
+export async function writeCrashBundle(basePath, bundle) {
+    const reportsDir = join(basePath, CRASH_REPORTS_DIR);
+    await mkdir(reportsDir, { recursive: true });
+    const bundlePath = join(reportsDir, `${bundle.bundleId}.json`);
+    await writeFile(bundlePath, `${JSON.stringify(bundle, null, 2)}\n`, 'utf8');
+    return bundlePath;
+}
+export async function readCrashBundle(basePath, bundlePath) {
+    let resolvedPath = bundlePath;
+    if (resolvedPath === undefined) {
+        const reportsDir = join(basePath, CRASH_REPORTS_DIR);
+        let fileNames;
+        try {
+            fileNames = await readdir(reportsDir);
+        }
+        catch {
+            return undefined;
+        }
+        const latest = fileNames
+            .filter((name) => /^crash-\d+\.json$/.test(name))
+            .sort((left, right) => Number.parseInt(right.slice(6), 10) - Number.parseInt(left.slice(6), 10))[0];
+        if (latest === undefined) {
+            return undefined;
+        }
+        resolvedPath = join(reportsDir, latest);
+    }
+    return {
+        bundlePath: resolvedPath,
+        bundle: JSON.parse(await readFile(resolvedPath, 'utf8')),
+    };
+}
+export function renderBugReport(report) {
+    const { bundle } = report;
+    const title = `[crash] ${bundle.surface}${bundle.command !== undefined ? ` ${bundle.command}` : ''}: ${bundle.category} error (${bundle.error.name})`;
+    const summary = [
+        '## What happened',
+        '',
+        '<!-- Describe what you were doing when the crash occurred. -->',
+        '',
+        '## Environment',
+        '',
+        `- AutomatosX: ${bundle.versions.automatosx ?? 'unknown'}`,
+        `- Node.js: ${bundle.versions.node}`,
+        `- Platform: ${bundle.versions.platform} (${bundle.versions.arch})`,
+        `- Surface: ${bundle.surface}`,
+        `- Failing request: ${bundle.command ?? 'unknown'} (${bundle.category})`,
+        '',
+        '## Error',
+        '',
+        '```',
+        `${bundle.error.name}${bundle.error.code !== undefined ? ` [${bundle.error.code}]` : ''}: ${bundle.error.message}`,
+        ...bundle.error.stack,
+        '```',
+    ];
+    const attachment = [
+        '',
+        '<details><summary>Diagnostic bundle (secrets stripped)</summary>',
+        '',
+        '```json',
+        JSON.stringify(bundle, null, 2),
+        '```',
+        '',
+        '</details>',
+    ];
+    const body = [...summary, ...attachment].join('\n');
+    const urlBody = body.length <= MAX_ISSUE_URL_BODY_LENGTH
+        ? body
+        : [...summary, '', `_Diagnostic bundle too large for the issue link; attach ${bundle.bundleId}.json from .automatosx/crash-reports/._`].join('\n');
+    const query = new URLSearchParams({ labels: 'bug', title, body: urlBody });
+    return {
+        ...report,
+        title,
+        body,
+        issueUrl: `https://github.com/${BUG_REPORT_REPOSITORY}/issues/new?${query.toString()}`,
+    };
+}
+export async function submitBugReport(basePath, report) {
+    const bodyPath = report.bundlePath.replace(/\.json$/, '.md');
+    await writeFile(bodyPath, report.body, 'utf8');
+    try {
+        const { stdout } = await execFileAsync('gh', [
+            'issue', 'create',
+            '--repo', BUG_REPORT_REPOSITORY,
+            '--title', report.title,
+            '--label', 'bug',
+            '--body-file', bodyPath,
+        ], { cwd: basePath, maxBuffer: 1024 * 1024 * 4 });
+        return stdout.trim();
+    }
+    catch (error) {
+        const message = error instanceof Error ? error.message : String(error);
+        throw new Error(`issue create failed: ${message}`);
+    }
+}
+export function sanitizeConfig(value, basePath) {
+    if (typeof value === 'string') {
+        return sanitizeText(value, basePath);
+    }
+    if (Array.isArray(value)) {
+        return value.map((entry) => sanitizeConfig(entry, basePath));
+    }
+    if (value !== null && typeof value === 'object') {
+        return Object.fromEntries(Object.entries(value).map(([key, entry]) => [
+            key,
+            SENSITIVE_KEY_PATTERN.test(key) ? REDACTED : sanitizeConfig(entry, basePath),
+        ]));
+    }
+    return value;
+}
+export function sanitizeText(text, basePath) {
+    let sanitized = text;
+    for (const [pattern, replacement] of SECRET_VALUE_PATTERNS) {
+        sanitized = sanitized.replace(pattern, replacement);
+    }
+    if (basePath.length > 1) {
+        sanitized = sanitized.split(basePath).join('<workspace>');
+    }
+    const home = homedir();
+    if (home.length > 1) {
+        sanitized = sanitized.split(home).join('~');
+    }
+    return sanitized;
+}
+function categorizeCrash(error, code) {
+    if (code !== undefined && ['ENOENT', 'EACCES', 'EPERM', 'EISDIR', 'ENOTDIR', 'EEXIST', 'ENOSPC', 'EMFILE'].includes(code)) {
+        return 'filesystem';
+    }
+    if ((code !== undefined && ['ECONNREFUSED', 'ECONNRESET', 'ETIMEDOUT', 'ENOTFOUND', 'EAI_AGAIN'].includes(code)) || /fetch failed|socket hang up/i.test(error.message)) {
+        return 'network';
+    }
+    if (error instanceof SyntaxError && /JSON|YAML/i.test(error.message)) {
+        return 'config';
+    }
+    if (/provider/i.test(error.message)) {
+        return 'provider';
+    }
+    return 'internal';
+}
+function readErrorCode(error) {
+    const code = (error).code;
+    return typeof code === 'string' ? code : undefined;
+}
@@ -0,0 +1,119 @@
+import { readFile, stat } from 'node:fs/promises';
+import { join } from 'node:path';
+import { extractDeclarations, stripStringsAndComments } from './structural-diff.js';
+import { listSourceFiles, toSymbolMatch, type SymbolMatch } from './symbol-query.js';
+
+export interface RuntimeDeadCodeResponse {
+  symbols: SymbolMatch[];
+  counts: { exported: number; unexported: number };
+  declarations: number;
+  scannedFiles: number;
+  truncated: boolean;
+}
+
+const MAX_SCAN_BYTES = 1024 * 1024;
+const DEFAULT_LIMIT = 200;
+const IDENTIFIER = /[A-Za-z_$][\w$]*/g;
+const TEST_FILE = /(?:_test\.go|\.(?:test|spec)\.[cm]?[jt]sx?|(?:Test|Tests|IT)\.(?:java|kt)|(?:^|\/)test_[^/]*\.py|_test\.py|(?:^|\/)conftest\.py)$/;
+// Called by the runtime or test harness rather than by name in the source;
+// so are Python's dunder methods (`__init__`, `__eq__`).
+const ENTRY_POINTS = new Set(['main', 'init', 'constructor']);
+const DUNDER = /(?:^|\.)__\w+__$/;
+const GO_TEST_FUNCTION = /^(?:Test|Benchmark|Example|Fuzz)[A-Z_]?/;
+// Java/Kotlin annotations, Rust attributes, and Python decorators under which a
+// framework (Spring, JUnit, the Rust test harness, C callers, Flask, pytest)
+// calls or wires the symbol.
+const FRAMEWORK_ANNOTATIONS = new Set([
+  'SpringBootApplication', 'Component', 'Service', 'Repository', 'Controller', 'RestController', 'ControllerAdvice',
+  'RestControllerAdvice', 'Configuration', 'Bean', 'RequestMapping', 'GetMapping', 'PostMapping', 'PutMapping',
+  'DeleteMapping', 'PatchMapping', 'ExceptionHandler', 'Scheduled', 'EventListener', 'KafkaListener', 'PostConstruct',
+  'PreDestroy', 'Override', 'Test', 'ParameterizedTest', 'BeforeEach', 'AfterEach', 'BeforeAll', 'AfterAll',
+  'test', 'bench', 'no_mangle',
+  'route', 'get', 'post', 'put', 'delete', 'patch', 'fixture', 'property', 'setter', 'deleter', 'abstractmethod',
+]);
+
+/**
+ * Flags declarations whose name never appears outside a declaration of that
+ * name in any TS/JS, Go, C/C++, Java/Kotlin, Rust, or Python file of the
+ * workspace, comments excluded. Declarations a framework wires up through
+ * annotations (Spring stereotypes, request mappings, JUnit and Rust tests,
+ * Flask routes, pytest fixtures) are never flagged. There is no
+ * type-aware call graph, so a name counts as referenced wherever it occurs
+ * (members are matched by bare name); the report therefore errs towards
+ * missing dead code rather than flagging live code. Exported symbols may still be used by other
+ * packages and are reported separately via `exported`.
+ */
+export async function findDeadCode(request: {
+  basePath: string;
+  paths?: string[];
+  includeExported?: boolean;
+  limit?: number;
+}): Promise<RuntimeDeadCodeResponse> {
+  const inScope = new Set(await listSourceFiles(request.basePath, request.paths));
+  const occurrences = new Map<string, Map<string, number[]>>();
+  const totals = new Map<string, number>();
+  const declared: SymbolMatch[] = [];
+  const candidates: SymbolMatch[] = [];
+  let scannedFiles = 0;
+
+  for (const path of await listSourceFiles(request.basePath)) {
+    const absolutePath = join(request.basePath, path);
+    try {
+      if ((await stat(absolutePath)).size > MAX_SCAN_BYTES) {
+        continue;
+      }
+    } catch {
+      continue;
+    }
+    scannedFiles += 1;
+    const content = await readFile(absolutePath, 'utf8');
+    const fileOccurrences = new Map<string, number[]>();
+    stripStringsAndComments(content, true, path).split('\n').forEach((line, index) => {
+      for (const [name] of line.matchAll(IDENTIFIER)) {
+        const lines = fileOccurrences.get(name) ?? [];
+        lines.push(index + 1);
+        fileOccurrences.set(name, lines);
+        totals.set(name, (totals.get(name) ?? 0) + 1);
+      }
+    });
+    occurrences.set(path, fileOccurrences);
+    const symbols = extractDeclarations(content, path).map((declaration) => toSymbolMatch(declaration, path));
+    declared.push(...symbols);
+    if (inScope.has(path) && !TEST_FILE.test(path)) {
+      candidates.push(...symbols);
+    }
+  }
+
+  // Occurrences inside any declaration of the same name (its own body, an
+  // overload, or a compiled twin of the same source) are not uses.
+  const spans = new Map<string, SymbolMatch[]>();
+  for (const symbol of declared) {
+    spans.set(symbol.name, [...(spans.get(symbol.name) ?? []), symbol]);
+  }
+  const dead = candidates.filter((symbol) => {
+    if (ENTRY_POINTS.has(symbol.name) || DUNDER.test(symbol.name) || (symbol.path.endsWith('.go') && GO_TEST_FUNCTION.test(symbol.name))) {
+      return false;
+    }
+    if (symbol.annotations?.some((annotation) => FRAMEWORK_ANNOTATIONS.has(annotation))) {
+      return false;
+    }
+    if (symbol.exported && request.includeExported === false) {
+      return false;
+    }
+    const definitions = (spans.get(symbol.name) ?? [])
+      .reduce((count, span) => count + (occurrences.get(span.path)?.get(symbol.name) ?? []).filter((line) => line >= span.line && line <= span.endLine).length, 0);
+    return (totals.get(symbol.name) ?? 0) - definitions <= 0;
+  });
+
+  const limit = request.limit ?? DEFAULT_LIMIT;
+  return {
+    symbols: dead.slice(0, limit),
+    counts: {
+      exported: dead.filter((symbol) => symbol.exported).length,
+      unexported: dead.filter((symbol) => !symbol.exported).length,
+    },
+    declarations: candidates.length,
+    scannedFiles,
+    truncated: dead.length > limit,
+  };
+}
@@ -0,0 +1,194 @@
+import { exec } from 'node:child_process';
+import { mkdir, readFile, writeFile } from 'node:fs/promises';
+import { dirname, isAbsolute, join, relative, resolve } from 'node:path';
+import { promisify } from 'node:util';
+const execAsync = promisify(exec);
+export const DEFINITION_OF_DONE_DIR = join('.automatosx', 'done');
+const DEFAULT_COMMAND_TIMEOUT_MS = 10 * 60 * 1000;
+// Output kept from a failing command: its last characters, where the failure usually is.
+const MAX_DETAIL_CHARS = 400;
+const CRITERION_KINDS = ['test', 'file', 'behavior'];
+/**
+ * The definition of done a workflow run is checked against, or undefined
+ * when the run's input sets `done: false`. Criteria come from the `done`
+ * input, else `.automatosx/done/<workflowId>.json`, else the workflow's
+ * `metadata.done`, else the workspace's test command. The last two are
+ * written to that file when it doesn't exist yet, so users can edit what
+ * later runs must satisfy. Throws on malformed criteria.
+ */
+export async function resolveDefinitionOfDone(request) {
+    if (request.input?.done === false) {
+        return undefined;
+    }
+    const path = join(request.basePath, DEFINITION_OF_DONE_DIR, `${request.workflowId}.json`);
+    if (request.input?.done !== undefined) {
+        return { workflowId: request.workflowId, source: 'input', path, criteria: parseDoneCriteria(request.input.done, 'the done input') };
+    }
+    const saved = await readSavedCriteria(path);
+    if (saved !== undefined) {
+        return { workflowId: request.workflowId, source: 'file', path, criteria: parseDoneCriteria(saved, path) };
+    }
+    const definition = request.workflowDone !== undefined
+        ? { workflowId: request.workflowId, source: 'workflow', path, criteria: parseDoneCriteria(request.workflowDone, `workflow ${request.workflowId}`) }
+        : { workflowId: request.workflowId, source: 'default', path, criteria: await defaultDoneCriteria(request.basePath) };
+    await mkdir(dirname(path), { recursive: true });
+    await writeFile(path, `${JSON.stringify({ workflowId: request.workflowId, criteria: definition.criteria }, null, 2)}\n`, 'utf8');
+    return definition;
+}
+/**
+ * Accepts a list of criteria or `{ "criteria": [...] }`. A string is a test
+ * command; ids default to `<kind>-<n>`.
+ */
+export function parseDoneCriteria(value, origin) {
+    const list = isRecord(value) ? value.criteria : value;
+    if (!Array.isArray(list)) {
+        throw new Error(`Invalid definition of done in ${origin}: expected a list of criteria.`);
+    }
+    const ids = new Set();
+    return list.map((item, index) => {
+        const criterion = normalizeCriterion(typeof item === 'string' ? { kind: 'test', command: item } : item, index, origin);
+        if (ids.has(criterion.id)) {
+            throw new Error(`Invalid definition of done in ${origin}: duplicate criterion id "${criterion.id}".`);
+        }
+        ids.add(criterion.id);
+        return criterion;
+    });
+}
+// Runs every criterion, one at a time, from the workspace root.
+export async function verifyDefinitionOfDone(request) {
+    const results = [];
+    for (const criterion of request.criteria) {
+        const started = Date.now();
+        const outcome = await checkCriterion(request.basePath, criterion);
+        results.push({ id: criterion.id, kind: criterion.kind, ...outcome, durationMs: Date.now() - started });
+    }
+    return {
+        passed: results.every((result) => result.status !== 'failed'),
+        checkedAt: (request.now ?? new Date()).toISOString(),
+        results,
+    };
+}
+async function checkCriterion(basePath, criterion) {
+    if (criterion.kind === 'file') {
+        const path = resolve(basePath, criterion.path);
+        const fromBase = relative(resolve(basePath), path);
+        if (fromBase.startsWith('..') || isAbsolute(fromBase)) {
+            return { status: 'failed', detail: `${criterion.path} is outside the workspace` };
+        }
+        let content;
+        try {
+            content = await readFile(path, 'utf8');
+        }
+        catch {
+            return { status: 'failed', detail: `${criterion.path} does not exist` };
+        }
+        return criterion.contains === undefined || content.includes(criterion.contains)
+            ? { status: 'passed' }
+            : { status: 'failed', detail: `${criterion.path} does not contain "${criterion.contains}"` };
+    }
+    if (criterion.command === undefined) {
+        return { status: 'manual', detail: criterion.description };
+    }
+    try {
+        const { stdout, stderr } = await execAsync(criterion.command, {
+            cwd: basePath,
+            timeout: criterion.timeoutMs ?? DEFAULT_COMMAND_TIMEOUT_MS,
+            maxBuffer: 16 * 1024 * 1024,
+        });
+        if (criterion.expect !== undefined && !`${stdout}${stderr}`.includes(criterion.expect)) {
+            return { status: 'failed', detail: `output did not include "${criterion.expect}": ${tail(`${stdout}${stderr}`)}` };
+        }
+        return { status: 'passed' };
+    }
+    catch (error) {
+        const failure = error;
+        const reason = failure.killed === true ? 'timed out' : `exited with ${failure.code ?? 'an error'}`;
+        return { status: 'failed', detail: `\`${criterion.command}\` ${reason}: ${tail(`${failure.stdout ?? ''}${failure.stderr ?? ''}`) || failure.message}` };
+    }
+}
+// What "the tests pass" means for the workspace, when it has a test setup we recognize.
+async function defaultDoneCriteria(basePath) {
+    try {
+        const manifest = JSON.parse(await readFile(join(basePath, 'package.json'), 'utf8'));
+        const test = isRecord(manifest) && isRecord(manifest.scripts) ? manifest.scripts.test : undefined;
+        // `npm init` writes a test script that always fails.
+        if (typeof test === 'string' && !test.includes('no test specified')) {
+            return [{ id: 'tests', kind: 'test', description: 'The test suite passes', command: 'npm test' }];
+        }
+    }
+    catch {
+        // Not a Node project; try the next.
+    }
+    for (const [manifest, command] of [['go.mod', 'go test ./...'], ['Cargo.toml', 'cargo test']]) {
+        try {
+            await readFile(join(basePath, manifest), 'utf8');
+            return [{ id: 'tests', kind: 'test', description: 'The test suite passes', command }];
+        }
+        catch {
+            continue;
+        }
+    }
+    return [];
+}
+function normalizeCriterion(value, index, origin) {
+    const invalid = (reason) => new Error(`Invalid definition of done in ${origin}: criterion ${index + 1} ${reason}.`);
+    if (!isRecord(value)) {
+        throw invalid('must be an object or a test command');
+    }
+    const kind = value.kind;
+    if (typeof kind !== 'string' || !CRITERION_KINDS.includes(kind)) {
+        throw invalid(`needs a kind of ${CRITERION_KINDS.join(', ')}`);
+    }
+    const text = (field) => {
+        const fieldValue = value[field];
+        if (fieldValue === undefined) {
+            return undefined;
+        }
+        if (typeof fieldValue !== 'string' || fieldValue.trim().length === 0) {
+            throw invalid(`has an empty or non-string ${field}`);
+        }
+        return fieldValue.trim();
+    };
+    const criterion = {
+        id: text('id') ?? `${kind}-${index + 1}`,
+        kind: kind,
+        ...(text('description') !== undefined ? { description: text('description') } : {}),
+        ...(text('command') !== undefined ? { command: text('command') } : {}),
+        ...(text('path') !== undefined ? { path: text('path') } : {}),
+        ...(text('contains') !== undefined ? { contains: text('contains') } : {}),
+        ...(text('expect') !== undefined ? { expect: text('expect') } : {}),
+        ...(typeof value.timeoutMs === 'number' && value.timeoutMs > 0 ? { timeoutMs: value.timeoutMs } : {}),
+    };
+    if (criterion.kind === 'test' && criterion.command === undefined) {
+        throw invalid('is a test without a command');
+    }
+    if (criterion.kind === 'file' && criterion.path === undefined) {
+        throw invalid('is a file without a path');
+    }
+    if (criterion.kind === 'behavior' && criterion.command === undefined && criterion.description === undefined) {
+        throw invalid('is a behavior without a command or description');
+    }
+    return criterion;
+}
+async function readSavedCriteria(path) {
+    let raw;
+    try {
+        raw = await readFile(path, 'utf8');
+    }
+    catch {
+        return undefined;
+    }
+    try {
+        return JSON.parse(raw);
+    }
+    catch (error) {
+        throw new Error(`Invalid definition of done in ${path}: ${error instanceof Error ? error.message : String(error)}`);
+    }
+}
+function tail(output) {
+    const trimmed = output.trim();
+    return trimmed.length > MAX_DETAIL_CHARS ? `...${trimmed.slice(-(MAX_DETAIL_CHARS - 3))}` : trimmed;
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
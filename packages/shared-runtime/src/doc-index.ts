@@ -0,0 +1,62 @@
+import type { IndexedFile } from './reference-index.js';
+import type { DeclarationKind } from './structural-diff.js';
+
+export interface DocEntry {
+  // `<path>#<symbol>`, the same shape as declaration chunks stored from a source file.
+  key: string;
+  // `Server.Start` for methods.
+  symbol: string;
+  kind: DeclarationKind;
+  path: string;
+  line: number;
+  endLine: number;
+  signature: string;
+  doc: string;
+}
+
+export interface RuntimeDocIndexResponse {
+  namespace: string;
+  indexed: Array<Pick<DocEntry, 'key' | 'symbol' | 'kind' | 'path' | 'line'>>;
+  // Entries for symbols that lost their doc comment or no longer exist.
+  removed: string[];
+  scannedFiles: number;
+}
+
+export const DOC_NAMESPACE = 'code-docs';
+export const DOC_TAG = 'doc-comment';
+
+/**
+ * The documented declarations of the indexed files, one entry per symbol.
+ * Same-named symbols in one file (Go `init` functions) are told apart by line.
+ */
+export function collectDocEntries(files: Map<string, IndexedFile>): DocEntry[] {
+  const entries: DocEntry[] = [];
+  for (const [path, file] of files) {
+    const documented = file.declarations.filter((declaration) => declaration.doc !== undefined);
+    const counts = new Map<string, number>();
+    for (const declaration of documented) {
+      const symbol = declaration.receiver !== undefined ? `${declaration.receiver}.${declaration.name}` : declaration.name;
+      counts.set(symbol, (counts.get(symbol) ?? 0) + 1);
+    }
+    for (const declaration of documented) {
+      const symbol = declaration.receiver !== undefined ? `${declaration.receiver}.${declaration.name}` : declaration.name;
+      entries.push({
+        key: `${path}#${(counts.get(symbol) ?? 0) > 1 ? `${symbol}@${declaration.line}` : symbol}`,
+        symbol,
+        kind: declaration.kind,
+        path,
+        line: declaration.line,
+        endLine: declaration.endLine,
+        signature: declaration.signature,
+        doc: declaration.doc!,
+      });
+    }
+  }
+  return entries;
+}
+
+// What is embedded and searched: the doc reads as prose, and the name and
+// signature let identifier searches find it too.
+export function docEntryContent(entry: DocEntry): string {
+  return `${entry.symbol} (${entry.kind})\n${entry.signature}\n\n${entry.doc}`;
+}
@@ -0,0 +1,62 @@
+import { basename, isAbsolute, join, relative, sep } from 'node:path';
+export const EDITOR_LINK_ENV = 'AX_EDITOR_LINK';
+// Placeholders: {path} absolute file path, {relativePath} path from the
+// workspace root, {project} workspace folder name, {line}, {column}.
+export const EDITOR_LINK_PRESETS = {
+    vscode: 'vscode://file/{path}:{line}:{column}',
+    'vscode-insiders': 'vscode-insiders://file/{path}:{line}:{column}',
+    cursor: 'cursor://file/{path}:{line}:{column}',
+    jetbrains: 'jetbrains://idea/navigate/reference?project={project}&path={relativePath}:{line}',
+    idea: 'idea://open?file={path}&line={line}',
+    sublime: 'subl://open?url=file://{path}&line={line}',
+    file: 'file://{path}',
+};
+/**
+ * Resolves the link template from, in order: the AX_EDITOR_LINK environment
+ * variable (per user), the workspace `editor` config, then the vscode preset.
+ * Returns undefined when links are turned off with "none".
+ */
+export function resolveEditorLinkTemplate(config, env = process.env) {
+    const configured = env[EDITOR_LINK_ENV] ?? readConfiguredScheme(config) ?? 'vscode';
+    if (configured === 'none' || configured.length === 0) {
+        return undefined;
+    }
+    if (configured.includes('{')) {
+        return configured;
+    }
+    const preset = EDITOR_LINK_PRESETS[configured];
+    if (preset === undefined) {
+        throw new Error(`Unknown editor link scheme "${configured}". Use one of ${Object.keys(EDITOR_LINK_PRESETS).join(', ')}, "none", or a template with {path} and {line}.`);
+    }
+    return preset;
+}
+export function buildEditorLink(template, basePath, location) {
+    const absolutePath = isAbsolute(location.file) ? location.file : join(basePath, location.file);
+    const encodePath = (path) => path.split(sep).join('/').split('/').map(encodeURIComponent).join('/');
+    const values = {
+        path: encodePath(absolutePath).replace(/^\/?/, '/').replace(/^\/([A-Za-z])%3A/, '/$1:'),
+        relativePath: encodePath(relative(basePath, absolutePath)),
+        project: encodeURIComponent(basename(basePath)),
+        line: String(location.line),
+        column: String(location.column ?? 1),
+    };
+    return template
+        .replace(/\{(path|relativePath|project|line|column)\}/g, (_match, key) => values[key] ?? '')
+        // vscode://file//abs would otherwise double the slash on POSIX paths.
+        .replace(/:\/\/file\/\//, '://file/');
+}
+function readConfiguredScheme(config) {
+    if (typeof config === 'string') {
+        return config;
+    }
+    if (config !== null && typeof config === 'object' && !Array.isArray(config)) {
+        const record = config;
+        if (typeof record.linkTemplate === 'string') {
+            return record.linkTemplate;
+        }
+        if (typeof record.scheme === 'string') {
+            return record.scheme;
+        }
+    }
+    return undefined;
+}
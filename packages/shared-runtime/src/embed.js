@@ -0,0 +1,154 @@
+import { createTraceStore } from '@defai.digital/trace-store';
+import { createSharedRuntimeService, } from './index.js';
+/**
+ * Programmatic entry point for Node applications that embed AutomatosX,
+ * published as `@defai.digital/shared-runtime/embed`. It wraps the same
+ * runtime service the CLI and MCP server use, so runs, traces and memory
+ * written here are visible to `ax` and the monitor, and vice versa.
+ */
+export function createOrchestrator(options = {}) {
+    const { provider, model, sessionId, ...runtimeConfig } = options;
+    const listeners = new Set();
+    const emit = (event) => {
+        for (const listener of listeners) {
+            try {
+                listener(event);
+            }
+            catch {
+                // A failing listener must not break the run that produced the event.
+            }
+        }
+    };
+    const traceStore = observeTraceStore(runtimeConfig.traceStore ?? createTraceStore({ basePath: runtimeConfig.basePath ?? process.cwd() }), emit);
+    const runtime = createSharedRuntimeService({ ...runtimeConfig, traceStore });
+    const on = (listener) => {
+        listeners.add(listener);
+        return () => {
+            listeners.delete(listener);
+        };
+    };
+    return {
+        runtime,
+        memory: {
+            store: (key, value, namespace) => runtime.storeMemory({ key, value, namespace }),
+            get: (key, namespace) => runtime.getMemory(key, namespace),
+            search: (query, namespace) => runtime.searchMemory(query, namespace),
+            list: (namespace) => runtime.listMemory(namespace),
+            delete: (key, namespace) => runtime.deleteMemory(key, namespace),
+            semantic: {
+                store: (entry) => runtime.storeSemantic(entry),
+                storeFile: (entry) => runtime.storeSemanticFile(entry),
+                search: (query, searchOptions) => runtime.searchSemantic(query, searchOptions),
+                get: (key, namespace) => runtime.getSemantic(key, namespace),
+                delete: (key, namespace) => runtime.deleteSemantic(key, namespace),
+            },
+        },
+        runAgent(agentId, task, runOptions = {}) {
+            return runtime.runAgent({ provider, model, sessionId, ...runOptions, agentId, task });
+        },
+        runWorkflow(workflowId, runOptions = {}) {
+            return runtime.runWorkflow({ provider, model, sessionId, ...runOptions, workflowId });
+        },
+        call(prompt, callOptions = {}) {
+            return runtime.callProvider({ provider, model, sessionId, ...callOptions, prompt });
+        },
+        on,
+        events(eventOptions = {}) {
+            return createEventIterator(on, eventOptions.signal);
+        },
+    };
+}
+/**
+ * One-shot convenience for a single agent run. Prefer createOrchestrator
+ * when running several tasks, so stores and provider bridges are reused.
+ */
+export function runAgent(request) {
+    const { basePath, traceStore, stateStore, maxConcurrentDiscussions, maxProvidersPerDiscussion, maxDiscussionRounds, ...agentRequest } = request;
+    return createSharedRuntimeService({ basePath, traceStore, stateStore, maxConcurrentDiscussions, maxProvidersPerDiscussion, maxDiscussionRounds })
+        .runAgent({ ...agentRequest, basePath });
+}
+// Every run (agent, workflow, discussion, provider call) is recorded through
+// the trace store, so observing its writes yields progress events for all of
+// them, including nested runs, without threading callbacks through the runtime.
+function observeTraceStore(store, emit) {
+    const reportedSteps = new Map();
+    return {
+        async upsertTrace(record) {
+            const stored = await store.upsertTrace(record);
+            const at = new Date().toISOString();
+            const { traceId, workflowId } = stored;
+            let reported = reportedSteps.get(traceId);
+            if (reported === undefined) {
+                reported = 0;
+                const parentTraceId = typeof stored.metadata?.parentTraceId === 'string' ? stored.metadata.parentTraceId : undefined;
+                emit({ type: 'run.started', traceId, workflowId, at, ...(parentTraceId === undefined ? {} : { parentTraceId }) });
+            }
+            for (const step of stored.stepResults.slice(reported)) {
+                emit({ type: 'step.completed', traceId, workflowId, at, step });
+            }
+            reportedSteps.set(traceId, stored.stepResults.length);
+            if (stored.status === 'completed') {
+                reportedSteps.delete(traceId);
+                emit({ type: 'run.completed', traceId, workflowId, at, output: stored.output });
+            }
+            else if (stored.status === 'failed') {
+                reportedSteps.delete(traceId);
+                emit({ type: 'run.failed', traceId, workflowId, at, error: stored.error });
+            }
+            return stored;
+        },
+        getTrace: (traceId) => store.getTrace(traceId),
+        listTraces: (limit) => store.listTraces(limit),
+        closeStuckTraces: (maxAgeMs) => store.closeStuckTraces(maxAgeMs),
+    };
+}
+function createEventIterator(subscribe, signal) {
+    const queue = [];
+    let waiting;
+    let done = false;
+    const finish = () => {
+        done = true;
+        unsubscribe();
+        signal?.removeEventListener('abort', finish);
+        queue.length = 0;
+        waiting?.({ value: undefined, done: true });
+        waiting = undefined;
+        return { value: undefined, done: true };
+    };
+    const unsubscribe = subscribe((event) => {
+        if (waiting !== undefined) {
+            const resolve = waiting;
+            waiting = undefined;
+            resolve({ value: event, done: false });
+        }
+        else {
+            queue.push(event);
+        }
+    });
+    if (signal?.aborted === true) {
+        finish();
+    }
+    else {
+        signal?.addEventListener('abort', finish, { once: true });
+    }
+    return {
+        next() {
+            const event = queue.shift();
+            if (event !== undefined) {
+                return Promise.resolve({ value: event, done: false });
+            }
+            if (done) {
+                return Promise.resolve({ value: undefined, done: true });
+            }
+            return new Promise((resolve) => {
+                waiting = resolve;
+            });
+        },
+        return() {
+            return Promise.resolve(finish());
+        },
+        [Symbol.asyncIterator]() {
+            return this;
+        },
+    };
+}
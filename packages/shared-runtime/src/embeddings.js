@@ -0,0 +1,201 @@
+import { createHash } from 'node:crypto';
+import { mkdir, readFile, rename, writeFile } from 'node:fs/promises';
+import { dirname, isAbsolute, join } from 'node:path';
+import { createMemoryCipher } from '@defai.digital/state-store';
+export const EMBEDDING_INDEX_FILE = join('.automatosx', 'runtime', 'embeddings.json');
+// Models are read from here as `<dir>/<owner>/<model>/`, and downloads are cached here.
+export const EMBEDDING_MODELS_DIR = join('.automatosx', 'models');
+// Resolved at runtime so transformers.js stays an optional dependency for projects on term vectors.
+const TRANSFORMERS_MODULE = '@huggingface/transformers';
+const DEFAULT_LOCAL_MODEL = 'Xenova/all-MiniLM-L6-v2';
+const DEFAULT_BATCH_SIZE = 16;
+/**
+ * `semantic.embeddings` in config, e.g. `{"provider": "local", "model": "Xenova/all-MiniLM-L6-v2"}`.
+ * Without it, or with `"provider": "terms"`, semantic search compares term vectors.
+ */
+export function resolveEmbeddingConfig(value, basePath) {
+    if (!isRecord(value) || value.provider === undefined || value.provider === 'terms') {
+        return undefined;
+    }
+    if (value.provider !== 'local') {
+        throw new Error(`semantic.embeddings.provider must be "local" or "terms", not ${JSON.stringify(value.provider)}`);
+    }
+    const modelsDir = typeof value.modelsDir === 'string' && value.modelsDir.length > 0 ? value.modelsDir : EMBEDDING_MODELS_DIR;
+    return {
+        model: typeof value.model === 'string' && value.model.trim().length > 0 ? value.model.trim() : DEFAULT_LOCAL_MODEL,
+        modelsDir: isAbsolute(modelsDir) ? modelsDir : join(basePath, modelsDir),
+        offline: value.offline !== false,
+        batchSize: typeof value.batchSize === 'number' && value.batchSize >= 1 ? Math.floor(value.batchSize) : DEFAULT_BATCH_SIZE,
+    };
+}
+/**
+ * Embeds with an ONNX sentence-embedding model through transformers.js, in
+ * process. Content never leaves the machine. The model is read from
+ * `modelsDir`, and only downloaded there on first use when `offline` is off.
+ */
+export function createLocalEmbeddingProvider(config) {
+    let extractor;
+    return {
+        id: `local:${config.model}`,
+        async embed(texts) {
+            extractor ??= loadExtractor(config).catch((error) => {
+                extractor = undefined;
+                throw error;
+            });
+            const run = await extractor;
+            const vectors = [];
+            for (let index = 0; index < texts.length; index += config.batchSize) {
+                const output = await run(texts.slice(index, index + config.batchSize), { pooling: 'mean', normalize: true });
+                vectors.push(...output.tolist());
+            }
+            return vectors;
+        },
+    };
+}
+/**
+ * Downloads the configured model into `modelsDir`, or checks that it loads
+ * when it is already there, so an offline setup can be prepared up front.
+ */
+export async function fetchEmbeddingModel(config) {
+    await loadExtractor({ ...config, offline: false });
+}
+// Embeds entries the index has no vector for yet; called after writes so searches find them ready.
+export async function indexEmbeddings(request) {
+    const index = await readEmbeddingIndex(request.basePath, request.provider.id, request.encryptionKey);
+    if (await embedMissing(index, request.provider, request.entries.map((entry) => entry.content))) {
+        await writeEmbeddingIndex(request.basePath, index, request.encryptionKey);
+    }
+}
+/**
+ * Ranks entries by cosine similarity between their embedding and the query's,
+ * best first. Entries without a vector are embedded first, so the first search
+ * after switching models indexes all of memory once. With `prune`, vectors of
+ * content missing from `entries` are dropped; set it only when `entries` is
+ * every entry there is.
+ */
+export async function rankByEmbedding(request) {
+    const index = await readEmbeddingIndex(request.basePath, request.provider.id, request.encryptionKey);
+    let changed = await embedMissing(index, request.provider, request.entries.map((entry) => entry.content));
+    if (request.prune === true) {
+        const current = new Set(request.entries.map((entry) => contentHash(entry.content)));
+        for (const hash of Object.keys(index.vectors)) {
+            if (!current.has(hash)) {
+                delete index.vectors[hash];
+                changed = true;
+            }
+        }
+    }
+    if (changed) {
+        await writeEmbeddingIndex(request.basePath, index, request.encryptionKey);
+    }
+    const [queryVector] = await request.provider.embed([request.query]);
+    if (queryVector === undefined) {
+        return [];
+    }
+    return request.entries
+        .map((entry) => {
+            const encoded = index.vectors[contentHash(entry.content)];
+            return { entry, score: encoded === undefined ? 0 : Number(cosineSimilarity(queryVector, decodeVector(encoded)).toFixed(6)) };
+        })
+        .sort((left, right) => right.score - left.score || right.entry.updatedAt.localeCompare(left.entry.updatedAt) || left.entry.key.localeCompare(right.entry.key));
+}
+async function loadExtractor(config) {
+    let transformers;
+    try {
+        transformers = await import(TRANSFORMERS_MODULE);
+    }
+    catch {
+        throw new Error(`Local embeddings need the "${TRANSFORMERS_MODULE}" package; install it with \`npm install ${TRANSFORMERS_MODULE}\``);
+    }
+    transformers.env.localModelPath = config.modelsDir;
+    transformers.env.cacheDir = config.modelsDir;
+    transformers.env.allowLocalModels = true;
+    transformers.env.allowRemoteModels = !config.offline;
+    try {
+        return await transformers.pipeline('feature-extraction', config.model);
+    }
+    catch (error) {
+        const reason = error instanceof Error ? error.message : String(error);
+        throw new Error(config.offline
+            ? `Embedding model ${config.model} is not in ${config.modelsDir}; download it with \`ax memory fetch-model\`, or set semantic.embeddings.offline to false to fetch it on first use (${reason})`
+            : `Could not load embedding model ${config.model}: ${reason}`);
+    }
+}
+// Returns whether any vector was added.
+async function embedMissing(index, provider, contents) {
+    const missing = new Map();
+    for (const content of contents) {
+        const hash = contentHash(content);
+        if (index.vectors[hash] === undefined) {
+            missing.set(hash, content);
+        }
+    }
+    if (missing.size === 0) {
+        return false;
+    }
+    const vectors = await provider.embed([...missing.values()]);
+    [...missing.keys()].forEach((hash, position) => {
+        const vector = vectors[position];
+        if (vector !== undefined) {
+            index.vectors[hash] = encodeVector(vector);
+        }
+    });
+    return true;
+}
+async function readEmbeddingIndex(basePath, providerId, encryptionKey) {
+    let parsed;
+    try {
+        parsed = JSON.parse(await readFile(join(basePath, EMBEDDING_INDEX_FILE), 'utf8'));
+        if (isRecord(parsed) && typeof parsed.sealed === 'string') {
+            if (encryptionKey === undefined) {
+                // Written under a key this process doesn't have; start over rather than fail searches.
+                return { provider: providerId, vectors: {} };
+            }
+            parsed = JSON.parse(createMemoryCipher(encryptionKey).open(parsed.sealed));
+        }
+    }
+    catch {
+        return { provider: providerId, vectors: {} };
+    }
+    // Vectors from another model live in a different space; they are all recomputed.
+    if (!isRecord(parsed) || parsed.provider !== providerId || !isRecord(parsed.vectors)) {
+        return { provider: providerId, vectors: {} };
+    }
+    return {
+        provider: providerId,
+        vectors: Object.fromEntries(Object.entries(parsed.vectors).filter((entry) => typeof entry[1] === 'string')),
+    };
+}
+// Vectors are derived from memory content, so they are sealed whenever memory is encrypted.
+async function writeEmbeddingIndex(basePath, index, encryptionKey) {
+    const path = join(basePath, EMBEDDING_INDEX_FILE);
+    const body = encryptionKey !== undefined ? { sealed: createMemoryCipher(encryptionKey).seal(JSON.stringify(index)) } : index;
+    await mkdir(dirname(path), { recursive: true });
+    const tempPath = `${path}.${process.pid}.tmp`;
+    await writeFile(tempPath, JSON.stringify(body), 'utf8');
+    await rename(tempPath, path);
+}
+function contentHash(content) {
+    return createHash('sha256').update(content).digest('hex');
+}
+function encodeVector(vector) {
+    return Buffer.from(new Float32Array(vector).buffer).toString('base64');
+}
+function decodeVector(encoded) {
+    const bytes = Buffer.from(encoded, 'base64');
+    return new Float32Array(bytes.buffer.slice(bytes.byteOffset, bytes.byteOffset + bytes.byteLength));
+}
+function cosineSimilarity(left, right) {
+    let dot = 0;
+    let leftNorm = 0;
+    let rightNorm = 0;
+    for (let index = 0; index < Math.min(left.length, right.length); index += 1) {
+        dot += left[index] * right[index];
+        leftNorm += left[index] * left[index];
+        rightNorm += right[index] * right[index];
+    }
+    return leftNorm === 0 || rightNorm === 0 ? 0 : dot / Math.sqrt(leftNorm * rightNorm);
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
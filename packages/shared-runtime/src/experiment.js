@@ -0,0 +1,175 @@
+import { randomUUID } from 'node:crypto';
+import { mkdir, readdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { dirname, join } from 'node:path';
+import { verifyDefinitionOfDone } from './definition-of-done.js';
+import { applyPatchReview, parseUnifiedDiff } from './patch-review.js';
+import { createWorkspaceSandbox } from './snapshot.js';
+export const EXPERIMENTS_DIR = join('.automatosx', 'experiments');
+export const EXPERIMENT_SANDBOX_PREFIX = 'ax-experiment-';
+export const DEFAULT_EXPERIMENT_VARIANTS = 3;
+export const MAX_EXPERIMENT_VARIANTS = 8;
+// How each strategy steers the model; `experiment.strategies` in config adds more.
+export const EXPERIMENT_STRATEGIES = {
+    minimal: 'Make the smallest change that completes the task, touching as few lines and files as you can.',
+    thorough: 'Complete the task fully: handle edge cases and errors, and add or update tests for the change.',
+    refactor: 'Complete the task, restructuring the code around it where that leaves it clearer and simpler.',
+};
+const DIFF_INSTRUCTIONS = [
+    'Respond with a unified diff against the workspace that completes the task: --- a/<path> and +++ b/<path> headers, then @@ hunks with a few lines of context.',
+    'Use --- /dev/null for new files. Only the diff is applied, so put anything else you need to say before it.',
+].join(' ');
+/**
+ * Spreads `count` variants over the strategies and providers given, in turn,
+ * so three variants over two providers try every strategy and both providers.
+ * Unknown strategies are errors.
+ */
+export function planExperimentVariants(request) {
+    const count = request.count ?? DEFAULT_EXPERIMENT_VARIANTS;
+    if (!Number.isInteger(count) || count < 1 || count > MAX_EXPERIMENT_VARIANTS) {
+        throw new Error(`variants must be an integer from 1 to ${MAX_EXPERIMENT_VARIANTS}`);
+    }
+    const strategies = request.strategies !== undefined && request.strategies.length > 0 ? request.strategies : Object.keys(request.guidance);
+    const unknown = strategies.filter((strategy) => request.guidance[strategy] === undefined);
+    if (unknown.length > 0) {
+        throw new Error(`Unknown experiment strategy ${unknown.join(', ')}; use ${Object.keys(request.guidance).join(', ')}.`);
+    }
+    const providers = request.providers !== undefined && request.providers.length > 0 ? request.providers : [undefined];
+    return Array.from({ length: count }, (_, index) => {
+        const provider = providers[index % providers.length];
+        return {
+            strategy: strategies[index % strategies.length],
+            ...(provider !== undefined ? { provider } : {}),
+            ...(request.model !== undefined ? { model: request.model } : {}),
+        };
+    });
+}
+export function resolveExperimentStrategies(config) {
+    const custom = isRecord(config) && isRecord(config.strategies) ? config.strategies : {};
+    return {
+        ...EXPERIMENT_STRATEGIES,
+        ...Object.fromEntries(Object.entries(custom).filter((entry) => typeof entry[1] === 'string' && entry[1].length > 0)),
+    };
+}
+export function buildExperimentPrompt(task, guidance) {
+    return [task, '', `Approach: ${guidance}`, '', DIFF_INSTRUCTIONS].join('\n');
+}
+/**
+ * Runs each variant in its own copy of the workspace: asks for a diff, applies
+ * it to the copy, and checks the criteria there, so variants can't see each
+ * other's changes and the workspace itself is untouched. Copies are removed
+ * afterwards; the diffs are kept in the record for `pickExperimentVariant`.
+ */
+export async function runExperiment(request) {
+    const results = [];
+    for (const [index, variant] of request.variants.entries()) {
+        const variantId = `v${index + 1}`;
+        const base = { variantId, ...variant, passedCriteria: 0, checkedCriteria: 0, files: [], additions: 0, deletions: 0, latencyMs: 0, patch: '' };
+        let output;
+        try {
+            output = await request.execute(variant, buildExperimentPrompt(request.task, request.guidance[variant.strategy] ?? ''));
+        }
+        catch (error) {
+            results.push({ ...base, status: 'error', error: error instanceof Error ? error.message : String(error) });
+            continue;
+        }
+        const measured = { ...base, latencyMs: output.latencyMs, ...(output.tokens !== undefined ? { tokens: output.tokens } : {}), ...(output.traceId !== undefined ? { traceId: output.traceId } : {}) };
+        const diff = parseUnifiedDiff(output.content);
+        if (diff.length === 0) {
+            results.push({ ...measured, status: 'error', error: 'The response had no unified diff.' });
+            continue;
+        }
+        const lines = diff.flatMap((file) => file.hunks.flatMap((hunk) => hunk.lines));
+        const changed = {
+            ...measured,
+            patch: output.content,
+            files: diff.map((file) => file.path),
+            additions: lines.filter((line) => line.startsWith('+')).length,
+            deletions: lines.filter((line) => line.startsWith('-')).length,
+        };
+        const sandbox = await createWorkspaceSandbox(request.basePath, EXPERIMENT_SANDBOX_PREFIX);
+        try {
+            await applyPatchReview({
+                basePath: sandbox,
+                patch: output.content,
+                decisions: diff.flatMap((file) => file.hunks.map((hunk) => ({ path: file.path, hunkIndex: hunk.index, decision: 'accept' }))),
+            });
+        }
+        catch (error) {
+            await rm(sandbox, { recursive: true, force: true });
+            results.push({ ...changed, status: 'error', error: `The diff did not apply: ${error instanceof Error ? error.message : String(error)}` });
+            continue;
+        }
+        try {
+            const verification = await verifyDefinitionOfDone({ basePath: sandbox, criteria: request.criteria, now: request.now });
+            const checked = verification.results.filter((result) => result.status !== 'manual');
+            results.push({
+                ...changed,
+                status: verification.passed ? 'passed' : 'failed',
+                passedCriteria: checked.filter((result) => result.status === 'passed').length,
+                checkedCriteria: checked.length,
+                verification,
+            });
+        }
+        finally {
+            await rm(sandbox, { recursive: true, force: true });
+        }
+    }
+    const ranked = rankExperimentVariants(results);
+    const best = ranked[0];
+    return {
+        experimentId: `exp-${randomUUID().slice(0, 8)}`,
+        task: request.task,
+        createdAt: (request.now ?? new Date()).toISOString(),
+        ...(request.files !== undefined && request.files.length > 0 ? { files: request.files } : {}),
+        criteria: request.criteria,
+        variants: ranked,
+        ...(best !== undefined && best.status !== 'error' ? { recommended: best.variantId } : {}),
+    };
+}
+export function rankExperimentVariants(variants) {
+    const order = { passed: 0, failed: 1, error: 2 };
+    return [...variants].sort((left, right) => order[left.status] - order[right.status]
+        || right.passedCriteria - left.passedCriteria
+        || (left.additions + left.deletions) - (right.additions + right.deletions)
+        || left.latencyMs - right.latencyMs
+        || left.variantId.localeCompare(right.variantId, undefined, { numeric: true }));
+}
+export async function saveExperiment(basePath, record) {
+    const path = join(basePath, EXPERIMENTS_DIR, `${record.experimentId}.json`);
+    await mkdir(dirname(path), { recursive: true });
+    await writeFile(path, `${JSON.stringify(record, null, 2)}\n`, 'utf8');
+}
+export async function readExperiment(basePath, experimentId) {
+    if (!/^[\w.-]+$/.test(experimentId)) {
+        throw new Error(`Invalid experiment id: ${experimentId}`);
+    }
+    try {
+        return JSON.parse(await readFile(join(basePath, EXPERIMENTS_DIR, `${experimentId}.json`), 'utf8'));
+    }
+    catch {
+        throw new Error(`Experiment ${experimentId} not found. Run "ax experiment list" to see experiments.`);
+    }
+}
+// Newest first.
+export async function listExperiments(basePath) {
+    let names;
+    try {
+        names = (await readdir(join(basePath, EXPERIMENTS_DIR))).filter((name) => name.endsWith('.json'));
+    }
+    catch {
+        return [];
+    }
+    const records = [];
+    for (const name of names) {
+        try {
+            records.push(await readExperiment(basePath, name.slice(0, -'.json'.length)));
+        }
+        catch {
+            // Unreadable records are skipped rather than hiding the rest.
+        }
+    }
+    return records.sort((left, right) => right.createdAt.localeCompare(left.createdAt));
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
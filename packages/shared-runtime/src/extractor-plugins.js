@@ -0,0 +1,103 @@
+import { readdir } from 'node:fs/promises';
+import { extname, join } from 'node:path';
+import { pathToFileURL } from 'node:url';
+import { registerDeclarationExtractor, } from './structural-diff.js';
+export const EXTRACTORS_DIR = join('.automatosx', 'extractors');
+const DECLARATION_KINDS = ['function', 'method', 'class', 'interface', 'type', 'enum', 'variable', 'macro'];
+/**
+ * Imports every `.js`/`.mjs` module in `.automatosx/extractors` and registers
+ * its extractor, exported as `default` or as `extractor`:
+ *
+ *   export default {
+ *     language: 'terraform',
+ *     extensions: ['.tf'],
+ *     // [{ kind: 'type', name: 'aws_s3_bucket.logs', line: 3, endLine: 9, signature, body }]
+ *     extract(content, path) { return parseResources(content); },
+ *   };
+ *
+ * Each plugin is checked before it is registered, including a dry run on an
+ * empty file, so a broken plugin is reported here rather than mid-analysis.
+ */
+export async function loadExtractorPlugins(basePath) {
+    const pluginsDir = join(basePath, EXTRACTORS_DIR);
+    let fileNames;
+    try {
+        fileNames = await readdir(pluginsDir);
+    }
+    catch {
+        return { loaded: [], errors: [] };
+    }
+    const report = { loaded: [], errors: [] };
+    for (const fileName of fileNames.filter((name) => ['.js', '.mjs'].includes(extname(name))).sort()) {
+        const sourcePath = join(pluginsDir, fileName);
+        try {
+            const module = await import(pathToFileURL(sourcePath).href);
+            const extractor = parseDeclarationExtractor(module.default ?? module.extractor, sourcePath);
+            registerDeclarationExtractor(extractor);
+            report.loaded.push({ language: extractor.language, extensions: extractor.extensions, sourcePath });
+        }
+        catch (error) {
+            report.errors.push({ sourcePath, error: error instanceof Error ? error.message : String(error) });
+        }
+    }
+    return report;
+}
+export function parseDeclarationExtractor(value, sourcePath) {
+    if (!isRecord(value)) {
+        throw new Error(`Extractor ${sourcePath} must export an object as default or as "extractor"`);
+    }
+    const language = typeof value.language === 'string' ? value.language.trim().toLowerCase() : '';
+    if (language.length === 0) {
+        throw new Error(`Extractor ${sourcePath} is missing language`);
+    }
+    const extensions = Array.isArray(value.extensions)
+        ? value.extensions.filter((entry) => typeof entry === 'string').map((entry) => entry.toLowerCase())
+        : [];
+    if (extensions.length === 0 || extensions.some((extension) => !/^\.[a-z0-9._-]+$/.test(extension))) {
+        throw new Error(`Extractor ${language} must list file extensions such as ".tf"`);
+    }
+    const extract = value.extract;
+    if (typeof extract !== 'function') {
+        throw new Error(`Extractor ${language} must define extract(content, path)`);
+    }
+    const extractor = {
+        language,
+        extensions,
+        extract: (content, path) => {
+            const declarations = extract.call(value, content, path);
+            if (!Array.isArray(declarations)) {
+                throw new Error(`Extractor ${language} returned ${typeof declarations} for ${path}, expected an array`);
+            }
+            return declarations.map((entry, index) => toDeclaration(entry, `Extractor ${language} declaration ${index + 1} in ${path}`));
+        },
+    };
+    extractor.extract('', `empty${extensions[0]}`);
+    return extractor;
+}
+function toDeclaration(value, label) {
+    if (!isRecord(value)) {
+        throw new Error(`${label} must be an object`);
+    }
+    if (typeof value.name !== 'string' || value.name.length === 0) {
+        throw new Error(`${label} is missing name`);
+    }
+    if (!DECLARATION_KINDS.includes(value.kind)) {
+        throw new Error(`${label} has unknown kind "${String(value.kind)}" (expected ${DECLARATION_KINDS.join(', ')})`);
+    }
+    if (!Number.isInteger(value.line) || (value.line) < 1) {
+        throw new Error(`${label} needs a 1-based line`);
+    }
+    const line = value.line;
+    return {
+        kind: value.kind,
+        name: value.name,
+        exported: value.exported === true,
+        signature: typeof value.signature === 'string' ? value.signature : value.name,
+        body: typeof value.body === 'string' ? value.body : '',
+        line,
+        endLine: Number.isInteger(value.endLine) && (value.endLine) >= line ? value.endLine : line,
+    };
+}
+function isRecord(value) {
+    return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
@@ -0,0 +1,144 @@
+import { readFile } from 'node:fs/promises';
+import { extname, isAbsolute, relative, resolve, sep } from 'node:path';
+import { filterAxIgnored } from './axignore.js';
+
+export const DEFAULT_FILE_PACK_MAX_CHARS = 200_000;
+
+export const FILE_PACK_ANCHOR_INSTRUCTIONS = [
+  'Files are attached with numbered lines.',
+  'Cite code as path:line or path:start-end, using the paths exactly as given and those line numbers.',
+  'When proposing a change, give a unified diff against the given paths whose hunk headers use the same line numbers.',
+].join(' ');
+
+// Claude reads XML-tagged documents best; other providers get Markdown sections.
+export type FilePackFormat = 'xml' | 'markdown';
+
+export interface PackedFile {
+  path: string;
+  // Lines included, from line 1; fewer than the file has when `truncated`.
+  lines: number;
+  truncated: boolean;
+}
+
+export interface FilePack {
+  format: FilePackFormat;
+  text: string;
+  files: PackedFile[];
+  skipped: Array<{ path: string; reason: string }>;
+}
+
+export interface FileAnchor {
+  path: string;
+  line: number;
+  endLine?: number;
+}
+
+export function filePackFormatFor(provider: string | undefined): FilePackFormat {
+  return provider === undefined || provider === 'claude' ? 'xml' : 'markdown';
+}
+
+/**
+ * Bundles files for a provider prompt. Files come in path order whatever order
+ * they were asked for in, so the same selection always packs to the same text,
+ * and every line carries its number for responses to cite. Files outside the
+ * workspace, ignored by .axignore, binary, or unreadable are skipped; past
+ * `maxChars` the file in progress is cut at a line and the rest are skipped.
+ */
+export async function packFiles(request: {
+  basePath: string;
+  files: string[];
+  provider?: string;
+  maxChars?: number;
+}): Promise<FilePack> {
+  const format = filePackFormatFor(request.provider);
+  const maxChars = request.maxChars ?? DEFAULT_FILE_PACK_MAX_CHARS;
+  const skipped: FilePack['skipped'] = [];
+  const candidates = new Set<string>();
+  for (const file of request.files) {
+    const path = relative(request.basePath, resolve(request.basePath, file)).split(sep).join('/');
+    if (path.length === 0 || path.startsWith('../') || isAbsolute(path)) {
+      skipped.push({ path: file, reason: 'outside the workspace' });
+    } else {
+      candidates.add(path);
+    }
+  }
+  const sorted = [...candidates].sort((left, right) => (left < right ? -1 : left > right ? 1 : 0));
+  const kept = new Set(await filterAxIgnored(request.basePath, sorted));
+
+  const files: PackedFile[] = [];
+  const blocks: string[] = [];
+  let used = 0;
+  for (const path of sorted) {
+    if (!kept.has(path)) {
+      skipped.push({ path, reason: 'ignored by .axignore' });
+      continue;
+    }
+    if (used >= maxChars) {
+      skipped.push({ path, reason: `pack is full at ${maxChars} characters` });
+      continue;
+    }
+    let content: string;
+    try {
+      content = await readFile(resolve(request.basePath, path), 'utf8');
+    } catch {
+      skipped.push({ path, reason: 'not readable' });
+      continue;
+    }
+    if (content.includes('\0')) {
+      skipped.push({ path, reason: 'binary' });
+      continue;
+    }
+    const lines = content.replace(/\r?\n$/, '').split(/\r?\n/);
+    const width = String(lines.length).length;
+    const numbered: string[] = [];
+    for (const [index, line] of lines.entries()) {
+      const text = `${String(index + 1).padStart(width)}| ${line}`;
+      if (used + text.length + 1 > maxChars && numbered.length > 0) {
+        break;
+      }
+      numbered.push(text);
+      used += text.length + 1;
+    }
+    const truncated = numbered.length < lines.length;
+    files.push({ path, lines: numbered.length, truncated });
+    blocks.push(formatFileBlock(format, path, numbered, truncated ? lines.length : undefined));
+  }
+
+  return { format, text: blocks.join('\n\n'), files, skipped };
+}
+
+/**
+ * Finds the `path:line` and `path:start-end` anchors a response cites, keeping
+ * only those that point into a packed file, in the order they first appear.
+ */
+export function resolveFileAnchors(text: string, pack: Pick<FilePack, 'files'>): FileAnchor[] {
+  const lineCounts = new Map(pack.files.map((file) => [file.path, file.lines]));
+  const anchors: FileAnchor[] = [];
+  const seen = new Set<string>();
+  for (const match of text.matchAll(/(?:\.\/)?([\w@.+-]+(?:\/[\w@.+-]+)*):(\d+)(?:-(\d+))?/g)) {
+    const path = match[1] ?? '';
+    const lines = lineCounts.get(path);
+    const line = Number(match[2]);
+    const endLine = match[3] === undefined ? undefined : Number(match[3]);
+    if (lines === undefined || line < 1 || line > lines || (endLine !== undefined && (endLine < line || endLine > lines))) {
+      continue;
+    }
+    const id = `${path}:${line}-${endLine ?? line}`;
+    if (!seen.has(id)) {
+      seen.add(id);
+      anchors.push(endLine === undefined || endLine === line ? { path, line } : { path, line, endLine });
+    }
+  }
+  return anchors;
+}
+
+function formatFileBlock(format: FilePackFormat, path: string, numbered: string[], totalLines: number | undefined): string {
+  const range = `1-${numbered.length}${totalLines !== undefined ? ` of ${totalLines}` : ''}`;
+  if (format === 'xml') {
+    return [`<file path="${path.replace(/"/g, '&quot;')}" lines="${range}">`, ...numbered, '</file>'].join('\n');
+  }
+  // A fence longer than any backtick run in the file, so the file can't close it.
+  const longestRun = Math.max(0, ...numbered.map((line) => Math.max(0, ...(line.match(/`+/g) ?? []).map((run) => run.length))));
+  const fence = '`'.repeat(Math.max(3, longestRun + 1));
+  return [`### ${path} (lines ${range})`, `${fence}${extname(path).slice(1)}`, ...numbered, fence].join('\n');
+}
@@ -0,0 +1,271 @@
+import { access, readdir, readFile } from 'node:fs/promises';
+import { createRequire } from 'node:module';
+import { extname, join } from 'node:path';
+import { parse as parseYaml } from 'yaml';
+export const FORBIDDEN_PATTERNS_DIR = join('.automatosx', 'patterns');
+export const GRAMMARS_DIR = join('.automatosx', 'grammars');
+// Resolved at runtime so tree-sitter stays an optional dependency for projects without forbidden patterns.
+const TREE_SITTER_MODULE = 'web-tree-sitter';
+const GRAMMAR_PACKAGE = 'tree-sitter-wasms';
+// The capture that marks the offending node; without it, a match's first capture does.
+const MATCH_CAPTURE = 'match';
+const SEVERITIES = ['critical', 'warning', 'note'];
+const CATEGORIES = ['security', 'correctness', 'maintainability'];
+// Tree-sitter grammar names by file extension.
+const LANGUAGE_EXTENSIONS = {
+    go: ['.go'],
+    typescript: ['.ts', '.mts', '.cts'],
+    tsx: ['.tsx'],
+    javascript: ['.js', '.jsx', '.mjs', '.cjs'],
+    python: ['.py'],
+    rust: ['.rs'],
+    java: ['.java'],
+    ruby: ['.rb'],
+    c: ['.c', '.h'],
+    cpp: ['.cc', '.cpp', '.cxx', '.hh', '.hpp'],
+    c_sharp: ['.cs'],
+    kotlin: ['.kt'],
+    php: ['.php'],
+    bash: ['.sh'],
+};
+/**
+ * Reads every `.yaml`/`.yml` file in `.automatosx/patterns`. A file holds one
+ * pattern or a `patterns` list:
+ *
+ *   patterns:
+ *     - id: no-println
+ *       language: go
+ *       query: |
+ *         ((call_expression
+ *            function: (selector_expression operand: (identifier) @pkg field: (field_identifier) @fn)
+ *            arguments: (argument_list) @args) @match
+ *          (#eq? @pkg "fmt") (#eq? @fn "Println"))
+ *       message: Log through slog instead of printing.
+ *       fix: slog.Info{{args}}
+ *       exclude: ['**_test.go']
+ */
+export async function loadForbiddenPatterns(basePath) {
+    const patternsDir = join(basePath, FORBIDDEN_PATTERNS_DIR);
+    let fileNames;
+    try {
+        fileNames = await readdir(patternsDir);
+    }
+    catch {
+        return [];
+    }
+    const patterns = [];
+    for (const fileName of fileNames.filter((name) => ['.yaml', '.yml'].includes(extname(name))).sort()) {
+        const sourcePath = join(patternsDir, fileName);
+        const parsed = parseYaml(await readFile(sourcePath, 'utf8'));
+        const entries = isRecord(parsed) && Array.isArray(parsed.patterns) ? parsed.patterns : [parsed];
+        patterns.push(...entries.map((entry) => parseForbiddenPattern(entry, sourcePath)));
+    }
+    const seen = new Set();
+    for (const pattern of patterns) {
+        if (seen.has(pattern.id)) {
+            throw new Error(`Forbidden pattern ${pattern.id} is defined twice (again in ${pattern.sourcePath})`);
+        }
+        seen.add(pattern.id);
+    }
+    return patterns;
+}
+export function parseForbiddenPattern(value, sourcePath) {
+    if (!isRecord(value)) {
+        throw new Error(`Forbidden pattern in ${sourcePath} must be a YAML mapping`);
+    }
+    const id = typeof value.id === 'string' ? value.id.trim() : '';
+    if (id.length === 0) {
+        throw new Error(`Forbidden pattern in ${sourcePath} is missing id`);
+    }
+    const language = typeof value.language === 'string' ? value.language.trim().toLowerCase() : '';
+    if (LANGUAGE_EXTENSIONS[language] === undefined) {
+        throw new Error(`Forbidden pattern ${id} needs a language of ${Object.keys(LANGUAGE_EXTENSIONS).join(', ')}`);
+    }
+    if (typeof value.query !== 'string' || value.query.trim().length === 0) {
+        throw new Error(`Forbidden pattern ${id} is missing query`);
+    }
+    if (typeof value.message !== 'string' || value.message.trim().length === 0) {
+        throw new Error(`Forbidden pattern ${id} is missing message`);
+    }
+    const severity = value.severity ?? 'warning';
+    if (!SEVERITIES.includes(severity)) {
+        throw new Error(`Forbidden pattern ${id} needs a severity of ${SEVERITIES.join(', ')}`);
+    }
+    const category = value.category ?? 'maintainability';
+    if (!CATEGORIES.includes(category)) {
+        throw new Error(`Forbidden pattern ${id} needs a category of ${CATEGORIES.join(', ')}`);
+    }
+    return {
+        id,
+        language,
+        query: value.query,
+        message: value.message.trim(),
+        ...(typeof value.fix === 'string' ? { fix: value.fix } : {}),
+        paths: asPatternList(value.paths),
+        exclude: asPatternList(value.exclude),
+        severity: severity,
+        category: category,
+        sourcePath,
+    };
+}
+// Extensions of every language the patterns cover, so file walks can include them.
+export function forbiddenPatternExtensions(patterns) {
+    return [...new Set(patterns.flatMap((pattern) => LANGUAGE_EXTENSIONS[pattern.language] ?? []))];
+}
+export function patternsForPath(patterns, path) {
+    const normalized = path.split('\\').join('/');
+    const extension = extname(normalized).toLowerCase();
+    return patterns.filter((pattern) => (LANGUAGE_EXTENSIONS[pattern.language] ?? []).includes(extension)
+        && (pattern.paths.length === 0 || pattern.paths.some((glob) => globToRegExp(glob).test(normalized)))
+        && !pattern.exclude.some((glob) => globToRegExp(glob).test(normalized)));
+}
+// Matches of every pattern that applies to `path`, in file order.
+export async function findForbiddenPatterns(request) {
+    const found = [];
+    for (const pattern of patternsForPath(request.patterns, request.path)) {
+        let matches;
+        try {
+            matches = await request.runQuery({ language: pattern.language, source: request.content, query: pattern.query });
+        }
+        catch (error) {
+            throw new Error(`Forbidden pattern ${pattern.id} could not run on ${request.path}: ${error instanceof Error ? error.message : String(error)}`);
+        }
+        for (const captures of matches) {
+            const node = captures.find((capture) => capture.name === MATCH_CAPTURE) ?? captures[0];
+            if (node === undefined) {
+                continue;
+            }
+            found.push({
+                patternId: pattern.id,
+                path: request.path,
+                line: node.startLine,
+                column: node.startColumn,
+                endLine: node.endLine,
+                text: node.text,
+                message: pattern.message,
+                severity: pattern.severity,
+                category: pattern.category,
+                ...(pattern.fix !== undefined ? { fix: renderFix(pattern.fix, captures) } : {}),
+            });
+        }
+    }
+    return found.sort((left, right) => left.line - right.line || left.column - right.column || left.patternId.localeCompare(right.patternId));
+}
+// One line an agent can act on: what was matched, why it is forbidden, and what to write instead.
+export function describeForbiddenMatch(match) {
+    const snippet = firstLine(match.text);
+    const fix = match.fix !== undefined ? ` Suggested fix: replace it with \`${firstLine(match.fix)}\`.` : '';
+    return `forbidden pattern ${match.patternId} at line ${match.line} (\`${snippet}\`): ${match.message}${fix}`;
+}
+/**
+ * Parses with web-tree-sitter. Grammars are read from
+ * `.automatosx/grammars/tree-sitter-<language>.wasm`, else from the
+ * tree-sitter-wasms package. Parsers, grammars, and compiled queries are
+ * cached for the runner's lifetime.
+ */
+export function createTreeSitterQueryRunner(basePath) {
+    let treeSitter;
+    const languages = new Map();
+    const queries = new Map();
+    return async ({ language, source, query }) => {
+        treeSitter ??= loadTreeSitter();
+        const module = await treeSitter;
+        let grammar = languages.get(language);
+        if (grammar === undefined) {
+            grammar = loadGrammar(module, basePath, language);
+            languages.set(language, grammar);
+        }
+        const loaded = await grammar;
+        const queryKey = `${language}\u0000${query}`;
+        let compiled = queries.get(queryKey);
+        if (compiled === undefined) {
+            compiled = typeof loaded.query === 'function' ? loaded.query(query) : new module.Query(loaded, query);
+            queries.set(queryKey, compiled);
+        }
+        const parser = new module.Parser();
+        parser.setLanguage(loaded);
+        const tree = parser.parse(source);
+        try {
+            return compiled.matches(tree.rootNode).map((match) => match.captures.map((capture) => ({
+                name: capture.name,
+                text: capture.node.text,
+                startLine: capture.node.startPosition.row + 1,
+                startColumn: capture.node.startPosition.column + 1,
+                endLine: capture.node.endPosition.row + 1,
+            })));
+        }
+        finally {
+            tree.delete?.();
+            parser.delete?.();
+        }
+    };
+}
+async function loadTreeSitter() {
+    let loaded;
+    try {
+        loaded = await import(TREE_SITTER_MODULE);
+    }
+    catch {
+        throw new Error(`Forbidden patterns need the "${TREE_SITTER_MODULE}" package; install it with \`npm install ${TREE_SITTER_MODULE} ${GRAMMAR_PACKAGE}\``);
+    }
+    const Parser = (loaded.Parser ?? loaded.default);
+    await Parser.init();
+    return {
+        Parser,
+        Language: loaded.Language ?? Parser.Language,
+        Query: loaded.Query,
+    };
+}
+async function loadGrammar(module, basePath, language) {
+    const fileName = `tree-sitter-${language}.wasm`;
+    let path = join(basePath, GRAMMARS_DIR, fileName);
+    try {
+        await access(path);
+    }
+    catch {
+        // Not vendored into the project; use the grammar package.
+        try {
+            path = createRequire(import.meta.url).resolve(`${GRAMMAR_PACKAGE}/out/${fileName}`);
+        }
+        catch {
+            throw new Error(`No tree-sitter grammar for ${language}: add ${join(GRAMMARS_DIR, fileName)} or install ${GRAMMAR_PACKAGE}`);
+        }
+    }
+    return module.Language.load(path);
+}
+function renderFix(fix, captures) {
+    return fix.replace(/\{\{\s*([\w.-]+)\s*\}\}/g, (placeholder, name) => captures.find((capture) => capture.name === name)?.text ?? placeholder);
+}
+function firstLine(text) {
+    const [first = ''] = text.trim().split('\n');
+    return text.trim().includes('\n') ? `${first.trimEnd()} ...` : first;
+}
+function globToRegExp(glob) {
+    let pattern = '';
+    for (let index = 0; index < glob.length; index += 1) {
+        const char = glob[index];
+        if (char === '*' && glob[index + 1] === '*') {
+            pattern += '.*';
+            index += glob[index + 2] === '/' ? 2 : 1;
+        }
+        else if (char === '*') {
+            pattern += '[^/]*';
+        }
+        else if (char === '?') {
+            pattern += '[^/]';
+        }
+        else {
+            pattern += char.replace(/[.+^${}()|[\]\\]/g, '\\$&');
+        }
+    }
+    return new RegExp(`^${pattern}$`);
+}
+function asPatternList(value) {
+    if (typeof value === 'string') {
+        return [value];
+    }
+    return Array.isArray(value) ? value.filter((entry) => typeof entry === 'string' && entry.length > 0) : [];
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,74 @@
+// Semantic entries stored from generated files carry this tag.
+export const GENERATED_TAG = 'generated';
+// When generated entries are searched, their scores are scaled by this so hand-written matches rank first.
+export const GENERATED_SCORE_WEIGHT = 0.5;
+// Markers are looked for in the comment lines at the start of a file.
+const HEADER_BYTES = 2048;
+const HEADER_LINES = 15;
+// Minified output: some line this long near the top, and lines this long on average.
+const MINIFIED_LINE_CHARS = 1000;
+const MINIFIED_AVERAGE_CHARS = 300;
+const MINIFIED_SAMPLE_BYTES = 64 * 1024;
+// Tool-specific headers first, so a protoc header reports protobuf rather than the generic marker it also carries.
+const SPECIFIC_HEADERS = [
+    { kind: 'protobuf', pattern: /generated by the protocol buffer compiler|code generated by protoc-gen-|protoc-gen-\w+ v?\d/i, reason: 'protoc header' },
+    { kind: 'mock', pattern: /code generated by (?:mockgen|mockery)|generated by mockgen|autogenerated mock/i, reason: 'mock generator header' },
+    { kind: 'bundle', pattern: /webpackBootstrap|bundled by (?:esbuild|rollup|webpack|parcel)/i, reason: 'bundler header' },
+];
+const PATHS = [
+    {
+        kind: 'protobuf',
+        pattern: /(?:\.pb\.(?:go|cc|h|swift|dart)|\.pb\.gw\.go|_pb2(?:_grpc)?\.pyi?|_(?:grpc_)?pb\.(?:js|d\.ts|ts))$/,
+        reason: 'protobuf output file name',
+    },
+    { kind: 'mock', pattern: /(?:^|\/)(?:mock_[^/]+|[^/]+_mock|mocks\/[^/]+)\.go$/, reason: 'Go mock file name' },
+    { kind: 'bundle', pattern: /\.(?:min|bundle)\.(?:js|mjs|cjs|css)$|\.chunk\.js$/, reason: 'minified or bundled file name' },
+];
+// Go's `// Code generated ... DO NOT EDIT.` convention, `@generated`, and the usual generator banners.
+const GENERIC_HEADER = /code generated .*do not edit|@generated\b|do not edit|auto-?generated|this (?:file|code) (?:was|is) (?:automatically |auto-)?generated/i;
+const COMMENT_LINE = /^\s*(?:\/\/|\/\*|\*|#|<!--|--|;)/;
+/**
+ * Whether a file is generated output: protobuf code, mocks, bundles, or
+ * anything whose leading comments say it is generated. `path` is
+ * workspace-relative and `/`-separated; without `content` only the file
+ * name is checked.
+ */
+export function detectGeneratedCode(path, content) {
+    const comments = content !== undefined ? headerComments(content) : '';
+    for (const rule of SPECIFIC_HEADERS) {
+        if (rule.pattern.test(comments)) {
+            return { kind: rule.kind, reason: rule.reason };
+        }
+    }
+    const normalized = path.replace(/\\/g, '/');
+    for (const rule of PATHS) {
+        if (rule.pattern.test(normalized)) {
+            return { kind: rule.kind, reason: rule.reason };
+        }
+    }
+    const marker = GENERIC_HEADER.exec(comments);
+    if (marker !== null) {
+        return { kind: 'generated', reason: `"${marker[0]}" header` };
+    }
+    if (content !== undefined && isMinified(content)) {
+        return { kind: 'bundle', reason: 'minified content' };
+    }
+    return undefined;
+}
+// The comment lines among the first lines of a file, so a marker quoted in code or a string does not count.
+function headerComments(content) {
+    return content
+        .slice(0, HEADER_BYTES)
+        .split('\n')
+        .slice(0, HEADER_LINES)
+        .filter((line) => COMMENT_LINE.test(line))
+        .join('\n');
+}
+function isMinified(content) {
+    const lines = content.slice(0, MINIFIED_SAMPLE_BYTES).split('\n').filter((line) => line.trim().length > 0);
+    if (lines.length === 0 || !lines.slice(0, 5).some((line) => line.length >= MINIFIED_LINE_CHARS)) {
+        return false;
+    }
+    const total = lines.reduce((sum, line) => sum + line.length, 0);
+    return total / lines.length >= MINIFIED_AVERAGE_CHARS;
+}
@@ -0,0 +1,157 @@
+import { readFile, stat } from 'node:fs/promises';
+import { join, posix } from 'node:path';
+import { listWorkspaceFiles } from './snapshot.js';
+import { extractDeclarations } from './structural-diff.js';
+
+export interface GoEmbedDirective {
+  path: string;
+  line: number;
+  // Variable the directive initialises.
+  symbol: string;
+  patterns: string[];
+  // Workspace files the patterns pull into the build.
+  files: string[];
+  // Patterns that match nothing, which `go build` rejects.
+  unmatched: string[];
+}
+
+export interface RuntimeGoEmbedResponse {
+  directives: GoEmbedDirective[];
+  // Requested files (or directories) that embed directives depend on, keyed by path.
+  referenced: Record<string, Array<{ path: string; line: number; symbol: string; pattern: string }>>;
+  scannedFiles: number;
+}
+
+const MAX_SCAN_BYTES = 1024 * 1024;
+
+/**
+ * Resolves the `//go:embed` directives in the workspace's Go files against
+ * the files next to them. Patterns follow Go's rules: they are relative to the
+ * package directory, use path.Match syntax, and a matched directory embeds
+ * everything below it except names starting with `.` or `_` (unless the
+ * pattern has the `all:` prefix). With `files`, `referenced` lists which
+ * directives would break if those files were moved or deleted.
+ */
+export async function findGoEmbeds(request: {
+  basePath: string;
+  paths?: string[];
+  files?: string[];
+}): Promise<RuntimeGoEmbedResponse> {
+  const workspaceFiles = await listWorkspaceFiles(request.basePath);
+  const prefixes = normalizePaths(request.paths ?? []);
+  const goFiles = workspaceFiles.filter((path) =>
+    path.endsWith('.go') && (prefixes.length === 0 || prefixes.some((prefix) => path === prefix || path.startsWith(`${prefix}/`))));
+
+  const directives: GoEmbedDirective[] = [];
+  for (const path of goFiles) {
+    const absolutePath = join(request.basePath, path);
+    try {
+      if ((await stat(absolutePath)).size > MAX_SCAN_BYTES) {
+        continue;
+      }
+    } catch {
+      continue;
+    }
+    const content = await readFile(absolutePath, 'utf8');
+    if (!content.includes('//go:embed')) {
+      continue;
+    }
+    const packageDir = posix.dirname(path);
+    const packageFiles = workspaceFiles
+      .filter((file) => packageDir === '.' || file.startsWith(`${packageDir}/`))
+      .map((file) => (packageDir === '.' ? file : file.slice(packageDir.length + 1)));
+    for (const declaration of extractDeclarations(content, path)) {
+      if (declaration.embeds === undefined) {
+        continue;
+      }
+      const files = new Set<string>();
+      const unmatched: string[] = [];
+      for (const pattern of declaration.embeds) {
+        const matched = packageFiles.filter((file) => matchesEmbedPattern(pattern, file));
+        if (matched.length === 0) {
+          unmatched.push(pattern);
+        }
+        matched.forEach((file) => files.add(packageDir === '.' ? file : `${packageDir}/${file}`));
+      }
+      directives.push({
+        path,
+        line: declaration.line,
+        symbol: declaration.name,
+        patterns: declaration.embeds,
+        files: [...files].sort(),
+        unmatched,
+      });
+    }
+  }
+
+  const referenced: RuntimeGoEmbedResponse['referenced'] = {};
+  for (const requested of normalizePaths(request.files ?? [])) {
+    const users = directives.flatMap((directive) => {
+      const packageDir = posix.dirname(directive.path);
+      return directive.files
+        .filter((file) => file === requested || file.startsWith(`${requested}/`))
+        .flatMap((file) => {
+          const relative = packageDir === '.' ? file : file.slice(packageDir.length + 1);
+          const pattern = directive.patterns.find((candidate) => matchesEmbedPattern(candidate, relative));
+          return pattern === undefined ? [] : [{ path: directive.path, line: directive.line, symbol: directive.symbol, pattern }];
+        });
+    });
+    const unique = [...new Map(users.map((user) => [`${user.path}:${user.line}:${user.pattern}`, user])).values()];
+    if (unique.length > 0) {
+      referenced[requested] = unique;
+    }
+  }
+
+  return { directives, referenced, scannedFiles: goFiles.length };
+}
+
+// `file` is relative to the package directory.
+export function matchesEmbedPattern(pattern: string, file: string): boolean {
+  const all = pattern.startsWith('all:');
+  const glob = globToRegExp(all ? pattern.slice(4) : pattern);
+  const parts = file.split('/');
+  for (let depth = 1; depth <= parts.length; depth += 1) {
+    if (!glob.test(parts.slice(0, depth).join('/'))) {
+      continue;
+    }
+    // An exact match is embedded as named; below a matched directory, hidden files are skipped.
+    const below = parts.slice(depth);
+    return all || below.every((part) => !part.startsWith('.') && !part.startsWith('_'));
+  }
+  return false;
+}
+
+function globToRegExp(pattern: string): RegExp {
+  let source = '';
+  for (let index = 0; index < pattern.length; index += 1) {
+    const char = pattern[index]!;
+    if (char === '*') {
+      source += '[^/]*';
+    } else if (char === '?') {
+      source += '[^/]';
+    } else if (char === '[') {
+      const close = pattern.indexOf(']', index + 2);
+      if (close === -1) {
+        source += '\\[';
+        continue;
+      }
+      const body = pattern.slice(index + 1, close);
+      source += `[${body.startsWith('^') ? `^${body.slice(1)}` : body}]`;
+      index = close;
+    } else if (char === '\\' && index + 1 < pattern.length) {
+      index += 1;
+      source += escapeRegExp(pattern[index]!);
+    } else {
+      source += escapeRegExp(char);
+    }
+  }
+  return new RegExp(`^${source}$`);
+}
+
+function normalizePaths(paths: string[]): string[] {
+  return paths.map((path) => path.replace(/^\.\//, '').replace(/\/+$/, '')).filter((path) => path.length > 0 && path !== '.');
+}
+
+function escapeRegExp(value: string): string {
+  return value.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
@@ -0,0 +1,238 @@
+import { execFile } from 'node:child_process';
+import { readFile } from 'node:fs/promises';
+import { isAbsolute, join, relative, sep } from 'node:path';
+import { promisify } from 'node:util';
+const execFileAsync = promisify(execFile);
+const PSEUDO_VERSION = /-(?:0\.)?\d{14}-[0-9a-f]{12}$/;
+const UPDATE_CHECK_TIMEOUT_MS = 60_000;
+// Returns module path → newest available version for a module directory.
+export function parseGoMod(content, file = 'go.mod') {
+    const parsed = { file, requires: [], replaces: [], excludes: [] };
+    for (const { verb, args, comment } of readDirectives(content)) {
+        switch (verb) {
+            case 'module':
+                parsed.module = args[0];
+                break;
+            case 'go':
+                parsed.goVersion = args[0];
+                break;
+            case 'toolchain':
+                parsed.toolchain = args[0];
+                break;
+            case 'require':
+                if (args[0] !== undefined && args[1] !== undefined) {
+                    parsed.requires.push({ path: args[0], version: args[1], indirect: /\bindirect\b/.test(comment) });
+                }
+                break;
+            case 'replace': {
+                const replace = parseReplace(args);
+                if (replace !== undefined) {
+                    parsed.replaces.push(replace);
+                }
+                break;
+            }
+            case 'exclude':
+                if (args[0] !== undefined && args[1] !== undefined) {
+                    parsed.excludes.push({ path: args[0], version: args[1] });
+                }
+                break;
+            default:
+                break;
+        }
+    }
+    return parsed;
+}
+export function parseGoWork(content, file = 'go.work') {
+    const parsed = { file, use: [], replaces: [] };
+    for (const { verb, args } of readDirectives(content)) {
+        if (verb === 'go') {
+            parsed.goVersion = args[0];
+        }
+        else if (verb === 'use' && args[0] !== undefined) {
+            parsed.use.push(args[0]);
+        }
+        else if (verb === 'replace') {
+            const replace = parseReplace(args);
+            if (replace !== undefined) {
+                parsed.replaces.push(replace);
+            }
+        }
+    }
+    return parsed;
+}
+/**
+ * Reads go.work (when present) and every module it uses, or the root go.mod
+ * otherwise, and flags requirements an agent should not build on blindly.
+ * Outdated modules are only reported when an update lister is available;
+ * by default that is `go list -m -u`, which needs the Go toolchain.
+ */
+export async function auditGoDependencies(request) {
+    const warnings = [];
+    const workspaceContent = await readOptional(join(request.basePath, 'go.work'));
+    const workspace = workspaceContent === undefined ? undefined : parseGoWork(workspaceContent);
+    const moduleDirs = workspace === undefined || workspace.use.length === 0 ? ['.'] : workspace.use;
+    const located = [];
+    for (const dir of moduleDirs) {
+        const absoluteDir = isAbsolute(dir) ? dir : join(request.basePath, dir);
+        const content = await readOptional(join(absoluteDir, 'go.mod'));
+        if (content === undefined) {
+            if (workspace !== undefined) {
+                warnings.push(`go.work uses ${dir}, but it has no go.mod.`);
+            }
+            continue;
+        }
+        located.push({ dir: absoluteDir, module: parseGoMod(content, toPosix(relative(request.basePath, join(absoluteDir, 'go.mod')))) });
+    }
+    const modules = located.map((entry) => entry.module);
+    if (modules.length === 0 && workspace === undefined) {
+        warnings.push('No go.mod or go.work found.');
+    }
+    const findings = [];
+    const replaceSources = [
+        ...(workspace?.replaces.map((replace) => ({ replace, file: workspace.file })) ?? []),
+        ...modules.flatMap((module) => module.replaces.map((replace) => ({ replace, file: module.file }))),
+    ];
+    for (const { replace, file } of replaceSources) {
+        const target = replace.newVersion !== undefined ? `${replace.newPath}@${replace.newVersion}` : replace.newPath;
+        findings.push({
+            kind: replace.local ? 'local-replace' : 'replaced',
+            module: replace.oldPath,
+            version: replace.oldVersion,
+            file,
+            message: replace.local
+                ? `${replace.oldPath} is replaced by local directory ${replace.newPath}; imports resolve to that checkout, not the published module.`
+                : `${replace.oldPath} is replaced by ${target}; check the fork before relying on upstream APIs.`,
+        });
+    }
+    for (const module of modules) {
+        for (const requirement of module.requires) {
+            if (PSEUDO_VERSION.test(requirement.version)) {
+                findings.push({
+                    kind: 'pseudo-version',
+                    module: requirement.path,
+                    version: requirement.version,
+                    file: module.file,
+                    message: `${requirement.path} is pinned to an untagged commit (${requirement.version}).`,
+                });
+            }
+            if (requirement.version.endsWith('+incompatible')) {
+                findings.push({
+                    kind: 'incompatible',
+                    module: requirement.path,
+                    version: requirement.version,
+                    file: module.file,
+                    message: `${requirement.path}@${requirement.version} predates Go modules; prefer a /vN module path if one exists.`,
+                });
+            }
+        }
+    }
+    if (request.checkUpdates !== false && modules.length > 0) {
+        const listUpdates = request.listUpdates ?? listUpdatesWithGo;
+        for (const { dir, module } of located) {
+            let updates;
+            try {
+                updates = await listUpdates(dir);
+            }
+            catch (error) {
+                warnings.push(`Skipped update check for ${module.file}: ${error instanceof Error ? error.message : String(error)}`);
+                continue;
+            }
+            for (const requirement of module.requires) {
+                const latest = updates.get(requirement.path);
+                if (latest !== undefined && latest !== requirement.version) {
+                    findings.push({
+                        kind: 'outdated',
+                        module: requirement.path,
+                        version: requirement.version,
+                        latest,
+                        file: module.file,
+                        message: `${requirement.path} ${requirement.version} is behind ${latest}${requirement.indirect ? ' (indirect)' : ''}.`,
+                    });
+                }
+            }
+        }
+    }
+    return { workspace, modules, findings, warnings };
+}
+async function listUpdatesWithGo(moduleDir) {
+    const { stdout } = await execFileAsync('go', ['list', '-m', '-u', '-json', 'all'], {
+        cwd: moduleDir,
+        timeout: UPDATE_CHECK_TIMEOUT_MS,
+        maxBuffer: 16 * 1024 * 1024,
+    });
+    const updates = new Map();
+    // `go list -json` prints a stream of objects rather than an array.
+    for (const chunk of stdout.split(/\n}\n?/)) {
+        if (chunk.trim().length === 0) {
+            continue;
+        }
+        const entry = JSON.parse(`${chunk}\n}`);
+        if (entry.Path !== undefined && entry.Update?.Version !== undefined) {
+            updates.set(entry.Path, entry.Update.Version);
+        }
+    }
+    return updates;
+}
+// Flattens single-line and parenthesized directives into one entry per line.
+function readDirectives(content) {
+    const directives = [];
+    let block;
+    for (const rawLine of content.split(/\r?\n/)) {
+        const commentIndex = rawLine.indexOf('//');
+        const comment = commentIndex === -1 ? '' : rawLine.slice(commentIndex + 2).trim();
+        const line = (commentIndex === -1 ? rawLine : rawLine.slice(0, commentIndex)).trim();
+        if (line.length === 0) {
+            continue;
+        }
+        if (block !== undefined) {
+            if (line === ')') {
+                block = undefined;
+            }
+            else {
+                directives.push({ verb: block, args: tokenize(line), comment });
+            }
+            continue;
+        }
+        const tokens = tokenize(line);
+        const verb = tokens[0] ?? '';
+        if (tokens[1] === '(') {
+            block = verb;
+        }
+        else {
+            directives.push({ verb, args: tokens.slice(1), comment });
+        }
+    }
+    return directives;
+}
+function tokenize(line) {
+    return [...line.matchAll(/"((?:[^"\\]|\\.)*)"|`([^`]*)`|(\S+)/g)].map((match) => match[1] ?? match[2] ?? match[3] ?? '');
+}
+function parseReplace(args) {
+    const arrow = args.indexOf('=>');
+    if (arrow === -1) {
+        return undefined;
+    }
+    const [oldPath, oldVersion] = args.slice(0, arrow);
+    const [newPath, newVersion] = args.slice(arrow + 1);
+    if (oldPath === undefined || newPath === undefined) {
+        return undefined;
+    }
+    return {
+        oldPath,
+        oldVersion,
+        newPath,
+        newVersion,
+        local: newVersion === undefined && (newPath.startsWith('./') || newPath.startsWith('../') || isAbsolute(newPath)),
+    };
+}
+async function readOptional(path) {
+    try {
+        return await readFile(path, 'utf8');
+    }
+    catch {
+        return undefined;
+    }
+}
+function toPosix(path) {
+    return path.split(sep).join('/');
+}
@@ -0,0 +1,303 @@
+import { execFile } from 'node:child_process';
+import { readFile } from 'node:fs/promises';
+import { isAbsolute, join, relative, sep } from 'node:path';
+import { promisify } from 'node:util';
+
+const execFileAsync = promisify(execFile);
+
+const PSEUDO_VERSION = /-(?:0\.)?\d{14}-[0-9a-f]{12}$/;
+const UPDATE_CHECK_TIMEOUT_MS = 60_000;
+
+export interface GoModRequire {
+  path: string;
+  version: string;
+  indirect: boolean;
+}
+
+export interface GoModReplace {
+  oldPath: string;
+  oldVersion?: string;
+  newPath: string;
+  newVersion?: string;
+  // Replacements with a filesystem path instead of a module version.
+  local: boolean;
+}
+
+export interface GoModFile {
+  file: string;
+  module?: string;
+  goVersion?: string;
+  toolchain?: string;
+  requires: GoModRequire[];
+  replaces: GoModReplace[];
+  excludes: Array<{ path: string; version: string }>;
+}
+
+export interface GoWorkFile {
+  file: string;
+  goVersion?: string;
+  use: string[];
+  replaces: GoModReplace[];
+}
+
+export type DependencyFindingKind = 'outdated' | 'replaced' | 'local-replace' | 'pseudo-version' | 'incompatible';
+
+export interface DependencyFinding {
+  kind: DependencyFindingKind;
+  module: string;
+  version?: string;
+  latest?: string;
+  file: string;
+  message: string;
+}
+
+export interface RuntimeDependencyAudit {
+  workspace?: GoWorkFile;
+  modules: GoModFile[];
+  findings: DependencyFinding[];
+  warnings: string[];
+}
+
+// Returns module path → newest available version for a module directory.
+export type GoUpdateLister = (moduleDir: string) => Promise<Map<string, string>>;
+
+export function parseGoMod(content: string, file = 'go.mod'): GoModFile {
+  const parsed: GoModFile = { file, requires: [], replaces: [], excludes: [] };
+  for (const { verb, args, comment } of readDirectives(content)) {
+    switch (verb) {
+      case 'module':
+        parsed.module = args[0];
+        break;
+      case 'go':
+        parsed.goVersion = args[0];
+        break;
+      case 'toolchain':
+        parsed.toolchain = args[0];
+        break;
+      case 'require':
+        if (args[0] !== undefined && args[1] !== undefined) {
+          parsed.requires.push({ path: args[0], version: args[1], indirect: /\bindirect\b/.test(comment) });
+        }
+        break;
+      case 'replace': {
+        const replace = parseReplace(args);
+        if (replace !== undefined) {
+          parsed.replaces.push(replace);
+        }
+        break;
+      }
+      case 'exclude':
+        if (args[0] !== undefined && args[1] !== undefined) {
+          parsed.excludes.push({ path: args[0], version: args[1] });
+        }
+        break;
+      default:
+        break;
+    }
+  }
+  return parsed;
+}
+
+export function parseGoWork(content: string, file = 'go.work'): GoWorkFile {
+  const parsed: GoWorkFile = { file, use: [], replaces: [] };
+  for (const { verb, args } of readDirectives(content)) {
+    if (verb === 'go') {
+      parsed.goVersion = args[0];
+    } else if (verb === 'use' && args[0] !== undefined) {
+      parsed.use.push(args[0]);
+    } else if (verb === 'replace') {
+      const replace = parseReplace(args);
+      if (replace !== undefined) {
+        parsed.replaces.push(replace);
+      }
+    }
+  }
+  return parsed;
+}
+
+/**
+ * Reads go.work (when present) and every module it uses, or the root go.mod
+ * otherwise, and flags requirements an agent should not build on blindly.
+ * Outdated modules are only reported when an update lister is available;
+ * by default that is `go list -m -u`, which needs the Go toolchain.
+ */
+export async function auditGoDependencies(request: {
+  basePath: string;
+  checkUpdates?: boolean;
+  listUpdates?: GoUpdateLister;
+}): Promise<RuntimeDependencyAudit> {
+  const warnings: string[] = [];
+  const workspaceContent = await readOptional(join(request.basePath, 'go.work'));
+  const workspace = workspaceContent === undefined ? undefined : parseGoWork(workspaceContent);
+  const moduleDirs = workspace === undefined || workspace.use.length === 0 ? ['.'] : workspace.use;
+
+  const located: Array<{ dir: string; module: GoModFile }> = [];
+  for (const dir of moduleDirs) {
+    const absoluteDir = isAbsolute(dir) ? dir : join(request.basePath, dir);
+    const content = await readOptional(join(absoluteDir, 'go.mod'));
+    if (content === undefined) {
+      if (workspace !== undefined) {
+        warnings.push(`go.work uses ${dir}, but it has no go.mod.`);
+      }
+      continue;
+    }
+    located.push({ dir: absoluteDir, module: parseGoMod(content, toPosix(relative(request.basePath, join(absoluteDir, 'go.mod')))) });
+  }
+  const modules = located.map((entry) => entry.module);
+  if (modules.length === 0 && workspace === undefined) {
+    warnings.push('No go.mod or go.work found.');
+  }
+
+  const findings: DependencyFinding[] = [];
+  const replaceSources = [
+    ...(workspace?.replaces.map((replace) => ({ replace, file: workspace.file })) ?? []),
+    ...modules.flatMap((module) => module.replaces.map((replace) => ({ replace, file: module.file }))),
+  ];
+  for (const { replace, file } of replaceSources) {
+    const target = replace.newVersion !== undefined ? `${replace.newPath}@${replace.newVersion}` : replace.newPath;
+    findings.push({
+      kind: replace.local ? 'local-replace' : 'replaced',
+      module: replace.oldPath,
+      version: replace.oldVersion,
+      file,
+      message: replace.local
+        ? `${replace.oldPath} is replaced by local directory ${replace.newPath}; imports resolve to that checkout, not the published module.`
+        : `${replace.oldPath} is replaced by ${target}; check the fork before relying on upstream APIs.`,
+    });
+  }
+  for (const module of modules) {
+    for (const requirement of module.requires) {
+      if (PSEUDO_VERSION.test(requirement.version)) {
+        findings.push({
+          kind: 'pseudo-version',
+          module: requirement.path,
+          version: requirement.version,
+          file: module.file,
+          message: `${requirement.path} is pinned to an untagged commit (${requirement.version}).`,
+        });
+      }
+      if (requirement.version.endsWith('+incompatible')) {
+        findings.push({
+          kind: 'incompatible',
+          module: requirement.path,
+          version: requirement.version,
+          file: module.file,
+          message: `${requirement.path}@${requirement.version} predates Go modules; prefer a /vN module path if one exists.`,
+        });
+      }
+    }
+  }
+
+  if (request.checkUpdates !== false && modules.length > 0) {
+    const listUpdates = request.listUpdates ?? listUpdatesWithGo;
+    for (const { dir, module } of located) {
+      let updates: Map<string, string>;
+      try {
+        updates = await listUpdates(dir);
+      } catch (error) {
+        warnings.push(`Skipped update check for ${module.file}: ${error instanceof Error ? error.message : String(error)}`);
+        continue;
+      }
+      for (const requirement of module.requires) {
+        const latest = updates.get(requirement.path);
+        if (latest !== undefined && latest !== requirement.version) {
+          findings.push({
+            kind: 'outdated',
+            module: requirement.path,
+            version: requirement.version,
+            latest,
+            file: module.file,
+            message: `${requirement.path} ${requirement.version} is behind ${latest}${requirement.indirect ? ' (indirect)' : ''}.`,
+          });
+        }
+      }
+    }
+  }
+
+  return { workspace, modules, findings, warnings };
+}
+
+async function listUpdatesWithGo(moduleDir: string): Promise<Map<string, string>> {
+  const { stdout } = await execFileAsync('go', ['list', '-m', '-u', '-json', 'all'], {
+    cwd: moduleDir,
+    timeout: UPDATE_CHECK_TIMEOUT_MS,
+    maxBuffer: 16 * 1024 * 1024,
+  });
+  const updates = new Map<string, string>();
+  // `go list -json` prints a stream of objects rather than an array.
+  for (const chunk of stdout.split(/\n}\n?/)) {
+    if (chunk.trim().length === 0) {
+      continue;
+    }
+    const entry = JSON.parse(`${chunk}\n}`) as { Path?: string; Update?: { Version?: string } };
+    if (entry.Path !== undefined && entry.Update?.Version !== undefined) {
+      updates.set(entry.Path, entry.Update.Version);
+    }
+  }
+  return updates;
+}
+
+// Flattens single-line and parenthesized directives into one entry per line.
+function readDirectives(content: string): Array<{ verb: string; args: string[]; comment: string }> {
+  const directives: Array<{ verb: string; args: string[]; comment: string }> = [];
+  let block: string | undefined;
+  for (const rawLine of content.split(/\r?\n/)) {
+    const commentIndex = rawLine.indexOf('//');
+    const comment = commentIndex === -1 ? '' : rawLine.slice(commentIndex + 2).trim();
+    const line = (commentIndex === -1 ? rawLine : rawLine.slice(0, commentIndex)).trim();
+    if (line.length === 0) {
+      continue;
+    }
+    if (block !== undefined) {
+      if (line === ')') {
+        block = undefined;
+      } else {
+        directives.push({ verb: block, args: tokenize(line), comment });
+      }
+      continue;
+    }
+    const tokens = tokenize(line);
+    const verb = tokens[0] ?? '';
+    if (tokens[1] === '(') {
+      block = verb;
+    } else {
+      directives.push({ verb, args: tokens.slice(1), comment });
+    }
+  }
+  return directives;
+}
+
+function tokenize(line: string): string[] {
+  return [...line.matchAll(/"((?:[^"\\]|\\.)*)"|`([^`]*)`|(\S+)/g)].map((match) => match[1] ?? match[2] ?? match[3] ?? '');
+}
+
+function parseReplace(args: string[]): GoModReplace | undefined {
+  const arrow = args.indexOf('=>');
+  if (arrow === -1) {
+    return undefined;
+  }
+  const [oldPath, oldVersion] = args.slice(0, arrow);
+  const [newPath, newVersion] = args.slice(arrow + 1);
+  if (oldPath === undefined || newPath === undefined) {
+    return undefined;
+  }
+  return {
+    oldPath,
+    oldVersion,
+    newPath,
+    newVersion,
+    local: newVersion === undefined && (newPath.startsWith('./') || newPath.startsWith('../') || isAbsolute(newPath)),
+  };
+}
+
+async function readOptional(path: string): Promise<string | undefined> {
+  try {
+    return await readFile(path, 'utf8');
+  } catch {
+    return undefined;
+  }
+}
+
+function toPosix(path: string): string {
+  return path.split(sep).join('/');
+}
@@ -0,0 +1,118 @@
+export const TODO_BACKLOG_NAMESPACE = 'backlog';
+const FIX_SESSION_PATTERN = /\b(fix|hotfix|incident|bug|outage|rollback|revert|regression)\b/i;
+const CLOSED_BACKLOG_STATUSES = new Set(['done', 'closed', 'resolved', 'wontfix']);
+const MAX_LISTED_FILES = 20;
+export function buildOnCallHandoff(sources) {
+    const now = sources.now ?? new Date();
+    const sinceMs = now.getTime() - sources.windowHours * 60 * 60 * 1000;
+    const since = new Date(sinceMs).toISOString();
+    const inWindow = (iso) => Date.parse(iso) >= sinceMs;
+    const incidents = sources.traces
+        .filter((trace) => trace.status === 'failed' && inWindow(trace.completedAt ?? trace.startedAt))
+        .map((trace) => ({
+            traceId: trace.traceId,
+            workflowId: trace.workflowId,
+            startedAt: trace.startedAt,
+            sessionId: typeof trace.metadata?.sessionId === 'string' ? trace.metadata.sessionId : undefined,
+            error: trace.error?.message,
+        }));
+    const fixSessions = sources.sessions
+        .filter((session) => inWindow(session.updatedAt) && FIX_SESSION_PATTERN.test(session.task))
+        .map((session) => ({
+            sessionId: session.sessionId,
+            task: session.task,
+            status: session.status,
+            updatedAt: session.updatedAt,
+            outcome: session.summary ?? session.error?.message,
+        }));
+    const followUps = [
+        ...sources.backlog.flatMap((entry) => toBacklogFollowUp(entry)),
+        ...sources.sessions
+            .filter((session) => session.status === 'failed' && inWindow(session.updatedAt))
+            .map((session) => ({
+                source: 'session',
+                id: session.sessionId,
+                title: `Failed session needs follow-up: ${session.task}`,
+                owner: session.initiator,
+            })),
+    ];
+    const riskyChanges = [
+        ...sources.traces
+            .filter((trace) => trace.status === 'running')
+            .map((trace) => ({
+                kind: 'running-trace',
+                id: trace.traceId,
+                description: `${trace.workflowId} still running since ${trace.startedAt}`,
+            })),
+        ...sources.sessions
+            .filter((session) => session.status === 'active')
+            .map((session) => ({
+                kind: 'active-session',
+                id: session.sessionId,
+                description: `${session.task} (${session.participants.filter((participant) => participant.leftAt === undefined).length} active participants)`,
+            })),
+        ...sources.uncommittedFiles.slice(0, MAX_LISTED_FILES).map((path) => ({
+            kind: 'uncommitted',
+            id: path,
+            description: `Uncommitted change in ${path}`,
+        })),
+    ];
+    const handoff = {
+        generatedAt: now.toISOString(),
+        since,
+        windowHours: sources.windowHours,
+        incidents,
+        fixSessions,
+        followUps,
+        riskyChanges,
+    };
+    return { ...handoff, markdown: renderOnCallHandoff(handoff) };
+}
+export function renderOnCallHandoff(handoff) {
+    const lines = [
+        '# On-Call Handoff',
+        '',
+        `Generated: ${handoff.generatedAt}`,
+        `Window: last ${handoff.windowHours}h (since ${handoff.since})`,
+        '',
+        '## Incidents',
+        '',
+        ...listOrNone(handoff.incidents.map((incident) => `- ${incident.workflowId} \`${incident.traceId}\` at ${incident.startedAt}${incident.error !== undefined ? `: ${incident.error}` : ''}`)),
+        '',
+        '## Fix Sessions',
+        '',
+        ...listOrNone(handoff.fixSessions.map((session) => `- [${session.status}] ${session.task} \`${session.sessionId}\`${session.outcome !== undefined ? ` — ${session.outcome}` : ''}`)),
+        '',
+        '## Open Follow-ups',
+        '',
+        ...listOrNone(handoff.followUps.map((followUp) => `- ${followUp.title}${followUp.owner !== undefined ? ` (owner: ${followUp.owner})` : ''}${followUp.priority !== undefined ? ` [${followUp.priority}]` : ''}`)),
+        '',
+        '## Risky In-Flight Changes',
+        '',
+        ...listOrNone(handoff.riskyChanges.map((change) => `- [${change.kind}] ${change.description}`)),
+    ];
+    return `${lines.join('\n')}\n`;
+}
+function toBacklogFollowUp(entry) {
+    const value = entry.value;
+    if (typeof value === 'string') {
+        return [{ source: 'backlog', id: entry.key, title: value }];
+    }
+    if (value === null || typeof value !== 'object' || Array.isArray(value)) {
+        return [];
+    }
+    const record = value;
+    if (typeof record.status === 'string' && CLOSED_BACKLOG_STATUSES.has(record.status.toLowerCase())) {
+        return [];
+    }
+    return [{
+        source: 'backlog',
+        id: entry.key,
+        title: typeof record.title === 'string' ? record.title : entry.key,
+        owner: typeof record.owner === 'string' ? record.owner : undefined,
+        priority: typeof record.priority === 'string' ? record.priority : undefined,
+    }];
+}
+function listOrNone(lines) {
+    return lines.length > 0 ? lines : ['- None'];
+}
@@ -0,0 +1,243 @@
+import { dirname, extname } from 'node:path';
+import { parseSymbol } from './rename-impact.js';
+import { loadReferenceIndex } from './reference-index.js';
+import { stripStringsAndComments } from './structural-diff.js';
+// Standard library interfaces commonly embedded or implemented, by import path name.
+const GO_LIBRARY_INTERFACES = {
+    'error': ['Error'],
+    'fmt.Stringer': ['String'],
+    'io.Reader': ['Read'],
+    'io.Writer': ['Write'],
+    'io.Closer': ['Close'],
+    'io.ReadWriter': ['Read', 'Write'],
+    'io.ReadCloser': ['Read', 'Close'],
+    'io.WriteCloser': ['Write', 'Close'],
+    'io.ReadWriteCloser': ['Read', 'Write', 'Close'],
+    'sort.Interface': ['Len', 'Less', 'Swap'],
+    'http.Handler': ['ServeHTTP'],
+    'json.Marshaler': ['MarshalJSON'],
+    'json.Unmarshaler': ['UnmarshalJSON'],
+};
+/**
+ * Types that implement an interface. Go implementations are structural: a
+ * named type qualifies when its methods, plus those promoted from embedded
+ * fields, cover every method the interface and its embedded interfaces
+ * require. Matching is by method name; parameter types are not compared.
+ * TS, Java, and Kotlin classes qualify when they name the interface in an
+ * `implements` clause or supertype list, or extend a class that does.
+ * Given a concrete type instead, lists the workspace interfaces it satisfies.
+ */
+export async function findImplementations(request) {
+    const files = await loadReferenceIndex(request.basePath, request.paths);
+    const index = new TypeIndex(files);
+    const target = parseSymbol(request.symbol);
+    const library = target.receiver !== undefined || !index.types.has(target.name) ? GO_LIBRARY_INTERFACES[request.symbol.trim()] : undefined;
+    if (target.receiver !== undefined && library === undefined) {
+        throw new Error(`Pass an interface or type name, not a method: ${request.symbol}`);
+    }
+    const declared = index.types.get(target.name) ?? [];
+    const interfaces = declared.filter((symbol) => symbol.kind === 'interface');
+    if (library === undefined && declared.length === 0) {
+        throw new Error(`No interface or type named ${request.symbol}`);
+    }
+    if (library !== undefined || interfaces.length > 0) {
+        const required = library !== undefined ? { methods: library, unresolved: [] } : index.interfaceMethods(target.name);
+        return {
+            symbol: request.symbol,
+            declarations: interfaces,
+            methods: required.methods,
+            implementations: library !== undefined ? index.goImplementations(library) : index.implementations(target.name),
+            interfaces: [],
+            unresolved: required.unresolved,
+            scannedFiles: files.size,
+        };
+    }
+    const satisfied = [...index.types.values()].flat()
+        .filter((symbol) => symbol.kind === 'interface' && symbol.name !== target.name)
+        .filter((symbol) => index.implementations(symbol.name).some((implementation) => implementation.name === target.name))
+        .map((symbol) => ({ name: symbol.name, path: symbol.path, line: symbol.line }));
+    return {
+        symbol: request.symbol,
+        declarations: declared,
+        methods: [],
+        implementations: [],
+        interfaces: [...new Map(satisfied.map((entry) => [`${entry.path}:${entry.line}`, entry])).values()],
+        unresolved: [],
+        scannedFiles: files.size,
+    };
+}
+/**
+ * Type names implementing the interface, for narrowing calls made through it.
+ * Empty when the interface is unknown or requires nothing.
+ */
+export function implementerNames(files, name) {
+    return new Set(new TypeIndex(files).implementations(name).map((implementation) => implementation.name));
+}
+class TypeIndex {
+    types = new Map();
+    methods = new Map();
+    files;
+    constructor(files) {
+        this.files = files;
+        for (const file of files.values()) {
+            for (const symbol of file.declarations) {
+                if (symbol.kind === 'method' && symbol.receiver !== undefined) {
+                    this.methods.set(symbol.receiver, [...(this.methods.get(symbol.receiver) ?? []), symbol]);
+                }
+                else if (symbol.kind === 'interface' || symbol.kind === 'type' || symbol.kind === 'class') {
+                    this.types.set(symbol.name, [...(this.types.get(symbol.name) ?? []), symbol]);
+                }
+            }
+        }
+    }
+    implementations(name) {
+        const interfaces = (this.types.get(name) ?? []).filter((symbol) => symbol.kind === 'interface');
+        const found = [];
+        if (interfaces.some((symbol) => isGo(symbol.path))) {
+            const { methods } = this.interfaceMethods(name);
+            found.push(...this.goImplementations(methods));
+        }
+        if (interfaces.some((symbol) => !isGo(symbol.path))) {
+            found.push(...this.explicitImplementations(name));
+        }
+        return found;
+    }
+    // Methods an interface requires, following embedded interfaces.
+    interfaceMethods(name, seen = new Set()) {
+        const methods = new Set();
+        const unresolved = new Set();
+        seen.add(name);
+        for (const symbol of (this.types.get(name) ?? []).filter((candidate) => candidate.kind === 'interface' && isGo(candidate.path))) {
+            for (const entry of this.bodyEntries(symbol)) {
+                const method = /^(\w+)\s*\(/.exec(entry)?.[1];
+                if (method !== undefined) {
+                    methods.add(method);
+                    continue;
+                }
+                const embedded = /^([\w.]+)$/.exec(entry)?.[1];
+                if (embedded === undefined) {
+                    continue;
+                }
+                const local = embedded.split('.').pop();
+                if (GO_LIBRARY_INTERFACES[embedded] !== undefined) {
+                    GO_LIBRARY_INTERFACES[embedded].forEach((libraryMethod) => methods.add(libraryMethod));
+                }
+                else if ((this.types.get(local) ?? []).some((candidate) => candidate.kind === 'interface')) {
+                    if (!seen.has(local)) {
+                        const inner = this.interfaceMethods(local, seen);
+                        inner.methods.forEach((innerMethod) => methods.add(innerMethod));
+                        inner.unresolved.forEach((innerName) => unresolved.add(innerName));
+                    }
+                }
+                else if (!/^(?:any|comparable|bool|byte|rune|string|u?int\d*|uintptr|float\d+|complex\d+)$/.test(embedded)) {
+                    unresolved.add(embedded);
+                }
+            }
+        }
+        return { methods: [...methods].sort(), unresolved: [...unresolved].sort() };
+    }
+    goImplementations(required) {
+        if (required.length === 0) {
+            return [];
+        }
+        const found = [];
+        for (const symbol of [...this.types.values()].flat()) {
+            if (symbol.kind !== 'type' || !isGo(symbol.path)) {
+                continue;
+            }
+            const methodSet = this.goMethodSet(symbol);
+            if (!required.every((method) => methodSet.has(method))) {
+                continue;
+            }
+            const entries = required.map((method) => methodSet.get(method));
+            const promoted = required.filter((method) => methodSet.get(method).via !== undefined);
+            found.push({
+                name: symbol.name,
+                path: symbol.path,
+                line: symbol.line,
+                ...(entries.some((entry) => entry.pointer) ? { pointer: true } : {}),
+                ...(promoted.length > 0 ? { promoted } : {}),
+            });
+        }
+        return found.sort((left, right) => left.path.localeCompare(right.path) || left.line - right.line);
+    }
+    // Own methods in the type's package, then methods promoted from embedded fields.
+    goMethodSet(symbol, seen = new Set()) {
+        const set = new Map();
+        seen.add(symbol.name);
+        const own = this.methods.get(symbol.name) ?? [];
+        const samePackage = own.filter((method) => isGo(method.path) && dirname(method.path) === dirname(symbol.path));
+        for (const method of samePackage.length > 0 ? samePackage : own.filter((candidate) => isGo(candidate.path))) {
+            set.set(method.name, { pointer: /^func\s*\(\s*\w*\s*\*/.test(method.signature) });
+        }
+        for (const field of (symbol.fields ?? []).filter((candidate) => candidate.embedded === true)) {
+            const embedded = field.type.replace(/^\*/, '').replace(/\[.*$/, '');
+            const local = field.name;
+            if (seen.has(local)) {
+                continue;
+            }
+            const inner = (this.types.get(local) ?? []).find((candidate) => isGo(candidate.path));
+            const promoted = inner === undefined
+                ? new Map((GO_LIBRARY_INTERFACES[embedded] ?? []).map((method) => [method, { pointer: false }]))
+                : inner.kind === 'interface'
+                    ? new Map(this.interfaceMethods(local).methods.map((method) => [method, { pointer: false }]))
+                    : this.goMethodSet(inner, seen);
+            for (const [method, promotedEntry] of promoted) {
+                if (!set.has(method)) {
+                    set.set(method, { pointer: promotedEntry.pointer, via: local });
+                }
+            }
+        }
+        return set;
+    }
+    // Classes naming the interface as a supertype, and their subclasses.
+    explicitImplementations(name) {
+        const classes = [...this.types.values()].flat().filter((symbol) => symbol.kind === 'class' && !isGo(symbol.path));
+        const matched = new Map();
+        let frontier = [name];
+        while (frontier.length > 0) {
+            const next = [];
+            for (const symbol of classes) {
+                if (!matched.has(`${symbol.path}:${symbol.line}`) && supertypes(symbol).some((supertype) => frontier.includes(supertype))) {
+                    matched.set(`${symbol.path}:${symbol.line}`, symbol);
+                    next.push(symbol.name);
+                }
+            }
+            frontier = next;
+        }
+        return [...matched.values()]
+            .map((symbol) => ({ name: symbol.name, path: symbol.path, line: symbol.line, explicit: true }))
+            .sort((left, right) => left.path.localeCompare(right.path) || left.line - right.line);
+    }
+    // Members of an interface, one per entry, comments and string contents blanked.
+    bodyEntries(symbol) {
+        const text = stripStringsAndComments((this.files.get(symbol.path)?.lines ?? []).slice(symbol.line - 1, symbol.endLine).join('\n'), false, symbol.path);
+        const open = text.indexOf('{');
+        const close = text.lastIndexOf('}');
+        if (open === -1 || close <= open) {
+            return [];
+        }
+        return text.slice(open + 1, close).split(/[\n;]/).map((entry) => entry.trim()).filter((entry) => entry.length > 0);
+    }
+}
+// Names after `implements`/`extends`, or after the Kotlin `:`, without type arguments or qualifiers.
+function supertypes(symbol) {
+    let header = symbol.signature.replace(/<[^<>]*>/g, '').replace(/<[^<>]*>/g, '');
+    let lists;
+    if (/\.kts?$/.test(symbol.path)) {
+        while (/\([^()]*\)/.test(header)) {
+            header = header.replace(/\([^()]*\)/g, '');
+        }
+        lists = [/:\s*([^{]+)/.exec(header)?.[1] ?? ''];
+    }
+    else {
+        lists = header.split(/\b(?:implements|extends)\b/).slice(1);
+    }
+    return lists
+        .flatMap((list) => list.split(','))
+        .map((entry) => (entry.trim().split(/\s+/)[0] ?? '').split('.').pop())
+        .filter((entry) => entry.length > 0);
+}
+function isGo(path) {
+    return extname(path).toLowerCase() === '.go';
+}
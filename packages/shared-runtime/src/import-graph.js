@@ -0,0 +1,144 @@
+import { readFile, stat } from 'node:fs/promises';
+import { join, posix } from 'node:path';
+import { parseGoMod } from './go-modules.js';
+import { findCycles } from './include-graph.js';
+import { listWorkspaceFiles } from './snapshot.js';
+const MAX_SCAN_BYTES = 1024 * 1024;
+/**
+ * Builds the package-level import graph of the workspace's Go code: one node
+ * per directory of non-test `.go` files, edges for the imports that resolve to
+ * another workspace package through the go.mod module path covering it.
+ * `testdata` and `vendor` are skipped, as the go tool does, and test files are
+ * left out because an external `_test` package may import its own package.
+ */
+export async function buildImportGraph(request) {
+    const workspaceFiles = await listWorkspaceFiles(request.basePath);
+    const prefixes = (request.paths ?? []).map((path) => path.replace(/^\.\//, '').replace(/\/+$/, '')).filter((path) => path.length > 0 && path !== '.');
+    const modules = [];
+    for (const path of workspaceFiles.filter((file) => file === 'go.mod' || file.endsWith('/go.mod'))) {
+        const module = parseGoMod(await readFile(join(request.basePath, path), 'utf8').catch(() => ''), path).module;
+        if (module !== undefined) {
+            modules.push({ dir: posix.dirname(path), module });
+        }
+    }
+    // Deepest module directory first, so nested modules win over the one around them.
+    const depth = (dir) => (dir === '.' ? 0 : dir.split('/').length);
+    modules.sort((left, right) => depth(right.dir) - depth(left.dir));
+    const goFiles = workspaceFiles.filter((path) => path.endsWith('.go') && !path.endsWith('_test.go') && !/(?:^|\/)(?:testdata|vendor)\//.test(path));
+    const nodes = new Map();
+    for (const path of goFiles) {
+        const dir = posix.dirname(path);
+        const existing = nodes.get(dir) ?? { package: dir, ...packageImportPath(dir, modules), name: posix.basename(dir), files: 0, imports: [], importedBy: [] };
+        existing.files += 1;
+        nodes.set(dir, existing);
+    }
+    const byImportPath = new Map([...nodes.values()].flatMap((node) => (node.importPath !== undefined ? [[node.importPath, node.package]] : [])));
+    const edges = [];
+    let scannedFiles = 0;
+    for (const path of goFiles) {
+        if (prefixes.length > 0 && !prefixes.some((prefix) => path === prefix || path.startsWith(`${prefix}/`))) {
+            continue;
+        }
+        const absolutePath = join(request.basePath, path);
+        try {
+            if ((await stat(absolutePath)).size > MAX_SCAN_BYTES) {
+                continue;
+            }
+        }
+        catch {
+            continue;
+        }
+        const content = await readFile(absolutePath, 'utf8');
+        scannedFiles += 1;
+        const from = posix.dirname(path);
+        const name = /^\s*package\s+(\w+)/m.exec(content)?.[1];
+        if (name !== undefined) {
+            nodes.get(from).name = name;
+        }
+        for (const spec of goImportSpecs(content)) {
+            if (spec.import === 'C') {
+                continue;
+            }
+            const resolved = byImportPath.get(spec.import);
+            edges.push({
+                from,
+                path,
+                line: spec.line,
+                import: spec.import,
+                ...(resolved !== undefined ? { resolved } : {}),
+                ...(!spec.import.split('/')[0].includes('.') && resolved === undefined ? { std: true } : {}),
+            });
+            const node = nodes.get(from);
+            if (resolved !== undefined && resolved !== from && !node.imports.includes(resolved)) {
+                node.imports.push(resolved);
+                nodes.get(resolved).importedBy.push(from);
+            }
+        }
+    }
+    const included = [...nodes.values()].filter((node) => edges.some((edge) => edge.from === node.package || edge.resolved === node.package));
+    return {
+        nodes: (prefixes.length > 0 ? included : [...nodes.values()]).sort((left, right) => left.package.localeCompare(right.package)),
+        edges,
+        external: [...new Set(edges.filter((edge) => edge.resolved === undefined).map((edge) => edge.import))].sort(),
+        cycles: findCycles(new Map([...nodes].map(([dir, node]) => [dir, node.imports]))),
+        scannedFiles,
+    };
+}
+/**
+ * Renders the graph for Graphviz: workspace packages as boxes, edges that
+ * take part in an import cycle in red. External packages are left out.
+ */
+export function formatImportGraphDot(graph) {
+    const cyclic = new Set(graph.cycles.flatMap((cycle) => cycle.map((dir, index) => `${dir}\n${cycle[(index + 1) % cycle.length]}`)));
+    const label = (node) => node.importPath ?? node.package;
+    const ids = new Map(graph.nodes.map((node) => [node.package, JSON.stringify(label(node))]));
+    return [
+        'digraph imports {',
+        '  rankdir=LR;',
+        '  node [shape=box];',
+        ...graph.nodes.map((node) => `  ${ids.get(node.package)};`),
+        ...graph.nodes.flatMap((node) => node.imports
+            .filter((target) => ids.has(target))
+            .map((target) => `  ${ids.get(node.package)} -> ${ids.get(target)}${cyclic.has(`${node.package}\n${target}`) ? ' [color=red]' : ''};`)),
+        '}',
+    ].join('\n');
+}
+function packageImportPath(dir, modules) {
+    const owner = modules.find((module) => module.dir === '.' || dir === module.dir || dir.startsWith(`${module.dir}/`));
+    if (owner === undefined) {
+        return {};
+    }
+    const rest = owner.dir === '.' ? dir : dir.slice(owner.dir.length + 1);
+    return { importPath: rest === '.' || rest.length === 0 ? owner.module : `${owner.module}/${rest}` };
+}
+// Single and grouped import declarations, with the line each path is on.
+function goImportSpecs(content) {
+    const specs = [];
+    const lines = content.split('\n');
+    let grouped = false;
+    for (const [index, line] of lines.entries()) {
+        if (grouped) {
+            if (/^\s*\)/.test(line)) {
+                grouped = false;
+                continue;
+            }
+            const match = /^\s*(?:[\w.]+\s+)?"([^"]+)"/.exec(line);
+            if (match?.[1] !== undefined) {
+                specs.push({ import: match[1], line: index + 1 });
+            }
+            continue;
+        }
+        if (/^import\s*\(/.test(line)) {
+            grouped = true;
+            continue;
+        }
+        const single = /^import\s+(?:[\w.]+\s+)?"([^"]+)"/.exec(line);
+        if (single?.[1] !== undefined) {
+            specs.push({ import: single[1], line: index + 1 });
+        }
+        else if (/^(?:func|type|var|const)\b/.test(line)) {
+            break;
+        }
+    }
+    return specs;
+}
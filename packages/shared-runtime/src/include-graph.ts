@@ -0,0 +1,194 @@
+import { readFile, stat } from 'node:fs/promises';
+import { dirname, extname, join, posix } from 'node:path';
+import { listWorkspaceFiles } from './snapshot.js';
+import { C_HEADER_EXTENSIONS, C_SOURCE_EXTENSIONS } from './structural-diff.js';
+
+export interface IncludeEdge {
+  from: string;
+  line: number;
+  include: string;
+  // `#include <...>`; quoted includes are project-relative.
+  system: boolean;
+  // Workspace path the include resolved to, when it is part of the project.
+  resolved?: string;
+  // Written in a cgo preamble above `import "C"` rather than a C/C++ file.
+  cgo?: boolean;
+}
+
+export interface IncludeGraphNode {
+  path: string;
+  includes: string[];
+  includedBy: string[];
+}
+
+export interface RuntimeIncludeGraphResponse {
+  nodes: IncludeGraphNode[];
+  edges: IncludeEdge[];
+  // Headers outside the workspace, such as <stdio.h>.
+  external: string[];
+  // Quoted includes that matched no workspace file.
+  unresolved: IncludeEdge[];
+  cycles: string[][];
+}
+
+const MAX_SCAN_BYTES = 1024 * 1024;
+const DEFAULT_INCLUDE_DIRS = ['.', 'include', 'src'];
+const INCLUDE = /^\s*#\s*include\s*([<"])([^>"]+)[>"]/;
+
+/**
+ * Builds the `#include` graph of the C/C++ files in the workspace, plus the
+ * includes in cgo preambles so Go packages that wrap C show up as includers.
+ * Quoted includes resolve against the including file's directory, then the
+ * include directories; angle includes that match a workspace file are treated
+ * as project headers too.
+ */
+export async function buildIncludeGraph(request: {
+  basePath: string;
+  paths?: string[];
+  includeDirs?: string[];
+}): Promise<RuntimeIncludeGraphResponse> {
+  const workspaceFiles = await listWorkspaceFiles(request.basePath);
+  const known = new Set(workspaceFiles);
+  const prefixes = (request.paths ?? []).map((path) => path.replace(/^\.\//, '').replace(/\/+$/, '')).filter((path) => path.length > 0 && path !== '.');
+  const includeDirs = request.includeDirs ?? DEFAULT_INCLUDE_DIRS;
+  const edges: IncludeEdge[] = [];
+
+  for (const path of workspaceFiles) {
+    const extension = extname(path).toLowerCase();
+    const isC = C_SOURCE_EXTENSIONS.has(extension) || C_HEADER_EXTENSIONS.has(extension);
+    if ((!isC && extension !== '.go') || (prefixes.length > 0 && !prefixes.some((prefix) => path === prefix || path.startsWith(`${prefix}/`)))) {
+      continue;
+    }
+    const absolutePath = join(request.basePath, path);
+    try {
+      if ((await stat(absolutePath)).size > MAX_SCAN_BYTES) {
+        continue;
+      }
+    } catch {
+      continue;
+    }
+    const content = await readFile(absolutePath, 'utf8');
+    const directives = isC ? findIncludes(content.split('\n'), 0) : findCgoIncludes(content);
+    for (const directive of directives) {
+      const resolved = resolveInclude(directive.include, directive.system, path, includeDirs, known, workspaceFiles);
+      edges.push({
+        from: path,
+        line: directive.line,
+        include: directive.include,
+        system: directive.system,
+        ...(resolved !== undefined ? { resolved } : {}),
+        ...(isC ? {} : { cgo: true }),
+      });
+    }
+  }
+
+  const nodes = new Map<string, IncludeGraphNode>();
+  const node = (path: string): IncludeGraphNode => {
+    const existing = nodes.get(path) ?? { path, includes: [], includedBy: [] };
+    nodes.set(path, existing);
+    return existing;
+  };
+  for (const edge of edges) {
+    const from = node(edge.from);
+    if (edge.resolved !== undefined) {
+      from.includes.push(edge.resolved);
+      node(edge.resolved).includedBy.push(edge.from);
+    }
+  }
+
+  return {
+    nodes: [...nodes.values()].sort((left, right) => left.path.localeCompare(right.path)),
+    edges,
+    external: [...new Set(edges.filter((edge) => edge.system && edge.resolved === undefined).map((edge) => edge.include))].sort(),
+    unresolved: edges.filter((edge) => !edge.system && edge.resolved === undefined),
+    cycles: findCycles(new Map([...nodes].map(([path, entry]) => [path, entry.includes]))),
+  };
+}
+
+function findIncludes(lines: string[], offset: number): Array<{ include: string; system: boolean; line: number }> {
+  const includes: Array<{ include: string; system: boolean; line: number }> = [];
+  lines.forEach((line, index) => {
+    const match = INCLUDE.exec(line);
+    if (match?.[2] !== undefined) {
+      includes.push({ include: match[2].trim(), system: match[1] === '<', line: offset + index + 1 });
+    }
+  });
+  return includes;
+}
+
+// The comment block directly above `import "C"` is the cgo preamble.
+function findCgoIncludes(content: string): Array<{ include: string; system: boolean; line: number }> {
+  const lines = content.split('\n');
+  const importLine = lines.findIndex((line) => /^\s*import\s+"C"\s*$/.test(line));
+  if (importLine <= 0) {
+    return [];
+  }
+  let start = importLine - 1;
+  if (/\*\/\s*$/.test(lines[start] ?? '')) {
+    while (start > 0 && !(lines[start] ?? '').includes('/*')) {
+      start -= 1;
+    }
+  } else {
+    while (start > 0 && /^\s*\/\//.test(lines[start - 1] ?? '')) {
+      start -= 1;
+    }
+    if (!/^\s*\/\//.test(lines[start] ?? '')) {
+      return [];
+    }
+  }
+  const preamble = lines.slice(start, importLine).map((line) => line.replace(/^\s*(?:\/\/|\/\*)/, '').replace(/\*\/\s*$/, ''));
+  return findIncludes(preamble, start);
+}
+
+function resolveInclude(
+  include: string,
+  system: boolean,
+  from: string,
+  includeDirs: string[],
+  known: Set<string>,
+  workspaceFiles: string[],
+): string | undefined {
+  const candidates = [
+    ...(system ? [] : [posix.join(dirname(from), include)]),
+    ...includeDirs.map((dir) => posix.join(dir, include)),
+  ].map((candidate) => posix.normalize(candidate));
+  const direct = candidates.find((candidate) => known.has(candidate));
+  if (direct !== undefined) {
+    return direct;
+  }
+  // Build systems add include paths we cannot see; a unique suffix match stands in.
+  const suffixed = workspaceFiles.filter((path) => path.endsWith(`/${include}`));
+  return suffixed.length === 1 ? suffixed[0] : undefined;
+}
+
+// One cycle per distinct set of nodes, each starting where the depth-first walk entered it.
+export function findCycles(graph: Map<string, string[]>): string[][] {
+  const cycles: string[][] = [];
+  const seen = new Set<string>();
+  const state = new Map<string, 'visiting' | 'done'>();
+  const stack: string[] = [];
+  const visit = (path: string): void => {
+    state.set(path, 'visiting');
+    stack.push(path);
+    for (const next of graph.get(path) ?? []) {
+      if (state.get(next) === 'visiting') {
+        const cycle = stack.slice(stack.indexOf(next));
+        const key = [...cycle].sort().join('\n');
+        if (!seen.has(key)) {
+          seen.add(key);
+          cycles.push(cycle);
+        }
+      } else if (state.get(next) === undefined) {
+        visit(next);
+      }
+    }
+    stack.pop();
+    state.set(path, 'done');
+  };
+  for (const path of [...graph.keys()].sort()) {
+    if (state.get(path) === undefined) {
+      visit(path);
+    }
+  }
+  return cycles;
+}
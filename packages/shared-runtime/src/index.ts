@@ -1,7 +1,7 @@
 import { randomUUID } from 'node:crypto';
 import { execFile } from 'node:child_process';
 import { mkdir, readFile, writeFile } from 'node:fs/promises';
-import { join } from 'node:path';
+import { dirname, isAbsolute, join, relative, resolve, sep } from 'node:path';
 import { promisify } from 'node:util';
 import {
   createRealStepExecutor,
@@ -9,6 +9,7 @@ import {
   createWorkflowRunner,
   createStepGuardEngine,
   findWorkflowDir,
+  renderTemplate,
   type StepResult,
   type StepGuardContext,
   type StepGuardPolicy,
@@ -22,14 +23,23 @@ import {
   type TraceSurface,
 } from '@defai.digital/trace-store';
 import {
+  createFilteredStateStore,
+  createScopedStateStore,
   createStateStore,
+  fuseSemanticRankings,
+  normalizeMemoryScope,
+  PostgresStateStore,
   type AgentEntry,
   type FeedbackEntry,
+  type MemoryAsOf,
   type MemoryEntry,
   type PolicyEntry,
   type SemanticEntry,
   type SemanticNamespaceStats,
+  type SemanticSearchMode,
+  type SemanticSearchOptions,
   type SemanticSearchResult,
+  type SemanticVectorIndex,
   type SessionEntry,
   type SessionParticipantRole,
   type StateStore,
@@ -42,7 +52,350 @@ import {
   type ReviewSeverity,
   type RuntimeReviewResponse,
 } from './review.js';
+import { buildEditorLink, resolveEditorLinkTemplate } from './editor-links.js';
 import { createProviderBridge } from './provider-bridge.js';
+import {
+  claimHedgeBudget,
+  countRecentHedges,
+  readLatencyRoutingStatus,
+  readProviderPercentile,
+  resolveLatencyRoutingConfig,
+  routeByLatency,
+  summarizeProviderLatency,
+  type LatencyRoutingStatus,
+  type ProviderLatencySummary,
+} from './provider-latency.js';
+import {
+  describeHedgeTrigger,
+  resolveHedgingConfig,
+  runHedged,
+  type HedgingConfig,
+  type HedgingStatus,
+} from './request-hedging.js';
+import {
+  isSloGatedWorkflow,
+  resolveSloGateConfig,
+  runSloGate,
+  type RuntimeSloGateResponse,
+} from './slo-gate.js';
+import {
+  resolveCanaryConfig,
+  runCanaryVerification,
+  type CanaryBaseline,
+  type RuntimeCanaryResponse,
+} from './canary.js';
+import {
+  generateRollbackPlaybook,
+  type RuntimeRollbackPlaybookResponse,
+} from './rollback-playbook.js';
+import {
+  buildOnCallHandoff,
+  TODO_BACKLOG_NAMESPACE,
+  type RuntimeHandoffResponse,
+} from './handoff.js';
+import {
+  evaluateRubric,
+  loadRubrics,
+  type Rubric,
+  type RubricEvaluation,
+} from './rubrics.js';
+import {
+  approveBenchOutputs,
+  loadBenchTasks,
+  runBench,
+  type BenchTask,
+  type RuntimeBenchResponse,
+} from './bench.js';
+import {
+  listExperiments,
+  planExperimentVariants,
+  readExperiment,
+  resolveExperimentStrategies,
+  runExperiment,
+  saveExperiment,
+  type ExperimentRecord,
+} from './experiment.js';
+import {
+  appendFileChangeEvent,
+  appendSessionEvent,
+  readFileForRecording,
+  readSessionRecording,
+  recordProviderCalls,
+  replayRecordedSession,
+  resolveSessionRecordingEnabled,
+  type RuntimeSessionReplayResponse,
+  type SessionEvent,
+  type SessionOperation,
+  type SessionOperationEvent,
+  type SessionRecordingEvent,
+} from './session-recording.js';
+import {
+  buildTelemetryReport,
+  readInstallId,
+  readLastSentAt,
+  recordSentReport,
+  resolveTelemetryConfig,
+  sendTelemetryReport,
+  startTelemetryCollector,
+  TELEMETRY_DIR,
+  type RuntimeTelemetrySendResponse,
+  type RuntimeTelemetryStatus,
+  type TelemetryCollector,
+  type TelemetryReport,
+} from './telemetry.js';
+import {
+  buildCrashBundle,
+  readCrashBundle,
+  renderBugReport,
+  submitBugReport,
+  writeCrashBundle,
+  type RuntimeBugReport,
+  type RuntimeCrashReport,
+} from './crash-report.js';
+import {
+  CURRENT_PRODUCT_VERSION,
+  migrateWorkspace,
+  type RuntimeMigrationResponse,
+} from './workspace-migration.js';
+import {
+  createBackup,
+  restoreBackup,
+  verifyBackup,
+  type BackupManifest,
+  type RuntimeBackupResponse,
+  type RuntimeRestoreResponse,
+} from './backup.js';
+import {
+  describeSyncRemote,
+  readLastSyncedAt,
+  resolveSyncConfig,
+  syncState,
+  type RuntimeSyncResponse,
+  type RuntimeSyncStatus,
+} from './state-sync.js';
+import { createSyncPrivacyFilter, localPrivacyContext, resolveSyncPrivacy } from './sync-privacy.js';
+import {
+  exportMemoryBundle,
+  importMemoryBundle,
+  type RuntimeMemoryExportResponse,
+  type RuntimeMemoryImportResponse,
+} from './memory-bundle.js';
+import {
+  collectWorkspaceGarbage,
+  DEFAULT_GC_BRANCH_PREFIX,
+  DEFAULT_GC_MAX_AGE_DAYS,
+  findStaleSessions,
+  type RuntimeGcResponse,
+} from './workspace-gc.js';
+import {
+  CONVERSATION_IMPORT_NAMESPACE,
+  conversationMemoryContent,
+  conversationMemoryKey,
+  readConversationHistory,
+  type ConversationImportSourceSummary,
+  type ConversationSource,
+  type RuntimeConversationImportResponse,
+} from './conversation-import.js';
+import {
+  enforceMemoryQuotas,
+  memoryQuotaFor,
+  pruneMemoryIfDue,
+  pruneMemoryNow,
+  resolveMemoryRetentionConfig,
+  type RuntimeMemoryPruneResponse,
+} from './memory-retention.js';
+import { dedupeMemory, type RuntimeMemoryDedupResponse } from './memory-dedup.js';
+import {
+  compactMemory,
+  compactMemoryIfDue,
+  resolveMemoryCompactionConfig,
+  type RuntimeMemoryCompactionResponse,
+} from './memory-compaction.js';
+import {
+  listMemorySnapshots,
+  resolveMemorySnapshotConfig,
+  restoreMemorySnapshot,
+  snapshotMemoryIfDue,
+  takeMemorySnapshot,
+  type MemorySnapshotSummary,
+  type RuntimeMemoryRestoreResponse,
+  type RuntimeMemorySnapshotResponse,
+} from './memory-snapshots.js';
+import { detectGeneratedCode, GENERATED_SCORE_WEIGHT, GENERATED_TAG } from './generated-code.js';
+import {
+  countMemoryFacets,
+  isMemoryFilterEmpty,
+  matchesMemoryFilter,
+  normalizeMemoryFilter,
+  type MemoryFilter,
+  type RuntimeMemoryFacetsResponse,
+} from './memory-facets.js';
+import { paginate, type PageRequest } from './pagination.js';
+import { FILE_PACK_ANCHOR_INSTRUCTIONS, packFiles, resolveFileAnchors, type FileAnchor } from './file-pack.js';
+import {
+  appendMemoryFeedback,
+  hasMemoryFeedback,
+  readMemoryFeedback,
+  rerankByFeedback,
+  type MemoryFeedbackKind,
+  type RuntimeMemoryFeedbackResponse,
+} from './memory-feedback.js';
+import { buildKnowledgeGraph, traverseKnowledgeGraph, type RuntimeKnowledgeGraphResponse } from './knowledge-graph.js';
+import { resolveDefinitionOfDone, verifyDefinitionOfDone, type DefinitionOfDone, type DefinitionOfDoneVerification } from './definition-of-done.js';
+import {
+  appendMemoryAudit,
+  defaultMemoryActor,
+  memoryAuditRefs,
+  queryMemoryAudit,
+  resolveMemoryAuditConfig,
+  type MemoryActor,
+  type MemoryAuditFilter,
+  type MemoryAuditRecord,
+  type RuntimeMemoryAuditResponse,
+} from './memory-audit.js';
+import {
+  appendSecretRedaction,
+  querySecretRedactions,
+  redactSecrets,
+  resolveSecretRedactionConfig,
+  type RuntimeSecretRedactionResponse,
+  type SecretRedactionFilter,
+  type SecretRedactionTarget,
+} from './secret-redaction.js';
+import { loadMemoryBackendConfig } from './memory-backend.js';
+import { loadMemoryEncryptionKey } from './memory-encryption.js';
+import {
+  ASK_SYSTEM_PROMPT,
+  DEFAULT_ASK_MAX_TOKENS,
+  buildAskContext,
+  type RuntimeAskResponse,
+} from './ask.js';
+import {
+  clarifyTask,
+  resolveClarificationConfig,
+  type RuntimeClarificationResponse,
+} from './clarification.js';
+import {
+  HUNK_REJECTED_FEEDBACK_TYPE,
+  applyPatchReview,
+  formatRejectedHunks,
+  parseUnifiedDiff,
+  resolvePatchStrategies,
+  resolvePreserveStyle,
+  type DiffFile,
+  type HunkDecision,
+  type PatchStrategy,
+  type RuntimePatchReviewResponse,
+} from './patch-review.js';
+import { conformToFileStyle, type ConformResult } from './code-style.js';
+import {
+  DEFAULT_MINIMAL_DIFF_POLICY,
+  resolveMinimalDiffPolicy,
+} from './minimal-diff.js';
+import {
+  createTreeSitterQueryRunner,
+  loadForbiddenPatterns,
+  type TreeSitterQueryRunner,
+} from './forbidden-patterns.js';
+import {
+  createLocalEmbeddingProvider,
+  fetchEmbeddingModel,
+  indexEmbeddings,
+  rankByEmbedding,
+  resolveEmbeddingConfig,
+  type EmbeddingProvider,
+} from './embeddings.js';
+import { auditGoDependencies, type RuntimeDependencyAudit } from './go-modules.js';
+import {
+  collectStructuralDiff,
+  type RuntimeStructuralDiffResponse,
+  type StructuralFileDiff,
+} from './structural-diff.js';
+import {
+  checkoutSnapshot,
+  listSnapshots,
+  readSnapshot,
+  readSnapshotFile,
+  resolveSnapshotConfig,
+  takeSnapshot,
+  type RuntimeSnapshotCheckoutResponse,
+  type RuntimeSnapshotResponse,
+  type SnapshotSummary,
+  type WorkspaceSnapshot,
+} from './snapshot.js';
+import { harvestTechDebt, type RuntimeTechDebtResponse, type TechDebtKind } from './tech-debt.js';
+import {
+  linkTodos,
+  listTodos,
+  renderTodoList,
+  resolveTodoScope,
+  type RuntimeTodoLinkResponse,
+  type RuntimeTodoResponse,
+  type TodoFilter,
+} from './todos.js';
+import {
+  chunkCode,
+  resolveChunkingConfig,
+  type ChunkingStrategy,
+  type RuntimeSemanticFileResponse,
+} from './code-chunking.js';
+import { isAxIgnored, loadAxIgnore } from './axignore.js';
+import { findSymbols, summarizeSymbols, type RuntimeSymbolMapResponse, type RuntimeSymbolSearchResponse } from './symbol-query.js';
+import {
+  collectCodeMetrics,
+  renderCodeMetrics,
+  resolveMetricsScope,
+  type CodeMetricsFilter,
+  type RuntimeCodeMetricsResponse,
+} from './code-metrics.js';
+import {
+  findDuplicates,
+  renderDuplicates,
+  resolveDuplicatesScope,
+  type DuplicateFilter,
+  type RuntimeDuplicatesResponse,
+} from './code-duplicates.js';
+import { findDeadCode, type RuntimeDeadCodeResponse } from './dead-code.js';
+import {
+  createGitHubCommentPoster,
+  resolveAutomationRules,
+  resolvePrCommandConfig,
+  startPrCommandServer,
+  type PrCommandOutcome,
+  type PrCommandServer,
+} from './pr-commands.js';
+import { analyzeRenameImpact, type RuntimeRenameImpactResponse } from './rename-impact.js';
+import {
+  findDefinition,
+  findReferences,
+  loadReferenceIndex,
+  type RuntimeDefinitionResponse,
+  type RuntimeReferencesResponse,
+} from './reference-index.js';
+import { buildIncludeGraph, type RuntimeIncludeGraphResponse } from './include-graph.js';
+import { buildImportGraph, type RuntimeImportGraphResponse } from './import-graph.js';
+import { collectApiDiff, type RuntimeApiDiffResponse } from './api-diff.js';
+import { buildCallGraph, type CallGraphDirection, type RuntimeCallGraphResponse } from './call-graph.js';
+import { findImplementations, type RuntimeImplementationsResponse } from './implementations.js';
+import { mapGoTests, type RuntimeTestMapResponse } from './test-map.js';
+import { exportLsif, type RuntimeLsifExportResponse } from './lsif-export.js';
+import { rankSymbols, type RuntimeSymbolRankResponse } from './symbol-rank.js';
+import { readIndexProgress, type IndexProgress } from './parse-pool.js';
+import { collectDocEntries, docEntryContent, DOC_NAMESPACE, DOC_TAG, type RuntimeDocIndexResponse } from './doc-index.js';
+import { findGoEmbeds, type RuntimeGoEmbedResponse } from './go-embeds.js';
+import {
+  checkParserFixtures,
+  createParserFixture,
+  type RuntimeParserConformanceResponse,
+  type RuntimeParserFixtureResponse,
+} from './parser-fixtures.js';
+import { loadExtractorPlugins, type ExtractorPluginReport } from './extractor-plugins.js';
+import {
+  collectTemplateVariables,
+  listStepTemplates,
+  renderTemplatePreviews,
+  type RuntimeTemplatePreview,
+} from './prompt-templates.js';
 
 const execFileAsync = promisify(execFile);
 
@@ -90,6 +443,11 @@ export interface RuntimeWorkflowResponse {
   };
   totalDurationMs?: number;
   workflowDir: string;
+  definitionOfDone?: DefinitionOfDone;
+  // Set when every step succeeded and there were criteria to check.
+  doneVerification?: DefinitionOfDoneVerification;
+  // Set for gated workflows (release by default) when an SLO endpoint is configured.
+  sloGate?: RuntimeSloGateResponse;
 }
 
 export interface RuntimeDiscussionResponse {
@@ -196,6 +554,9 @@ export interface RuntimeStatusResponse {
     defaultProvider?: string;
     providerExecutionMode: 'auto' | 'simulate' | 'require-real';
     configuredExecutors: string[];
+    providerLatency: ProviderLatencySummary[];
+    latencyRouting: LatencyRoutingStatus;
+    hedging: HedgingStatus;
   };
   activeSessions: SessionEntry[];
   runningTraces: TraceRecord[];
@@ -208,6 +569,15 @@ export interface RuntimeGitDiffResponse {
   basePath: string;
 }
 
+export interface RuntimeMemorySearchPage {
+  entries: MemoryEntry[];
+  // Matches across all pages.
+  total: number;
+  offset: number;
+  // Pass back as `cursor`, with the same query and filters, for the next page; absent on the last one.
+  nextCursor?: string;
+}
+
 export interface RuntimeGuardPolicySummary {
   policyId: string;
   name: string;
@@ -236,6 +606,8 @@ export interface RuntimeAgentRunRequest {
   timeoutMs?: number;
   task?: string;
   input?: Record<string, unknown>;
+  // Workspace files packed into the prompt with numbered lines.
+  files?: string[];
   surface?: TraceSurface;
   parentTraceId?: string;
   rootTraceId?: string;
@@ -256,6 +628,8 @@ export interface RuntimeAgentRunResponse {
     outputTokens: number;
     totalTokens: number;
   };
+  // With `files`: the packed lines the output cites as path:line.
+  anchors?: FileAnchor[];
   error?: {
     code?: string;
     message?: string;
@@ -459,6 +833,9 @@ export interface RuntimePrReviewResponse {
   changedFiles: string[];
   diffStat: string;
   summary: string;
+  // Declaration-level changes (function added, signature changed, body-only change).
+  structuralChanges: StructuralFileDiff[];
+  structuralSummary: string;
 }
 
 export interface RuntimePrCreateResponse {
@@ -483,14 +860,211 @@ export interface SharedRuntimeService {
   runParallel(request: RuntimeParallelRunRequest): Promise<RuntimeParallelRunResponse>;
   getStatus(request?: { limit?: number }): Promise<RuntimeStatusResponse>;
   gitStatus(request?: { basePath?: string }): Promise<RuntimeGitStatusResponse>;
-  gitDiff(request?: { basePath?: string; paths?: string[]; staged?: boolean; commit?: string; stat?: boolean }): Promise<RuntimeGitDiffResponse>;
+  gitDiff(request?: { basePath?: string; paths?: string[]; staged?: boolean; commit?: string; stat?: boolean; nameOnly?: boolean }): Promise<RuntimeGitDiffResponse>;
   commitPrepare(request?: { basePath?: string; paths?: string[]; stageAll?: boolean; type?: string; scope?: string }): Promise<RuntimeCommitPrepareResponse>;
   reviewPullRequest(request?: { basePath?: string; base?: string; head?: string }): Promise<RuntimePrReviewResponse>;
   createPullRequest(request: { title: string; body?: string; base?: string; head?: string; draft?: boolean; basePath?: string }): Promise<RuntimePrCreateResponse>;
   listWorkflows(options?: { workflowDir?: string; basePath?: string }): Promise<Array<{ workflowId: string; name?: string; version: string; steps: number }>>;
   describeWorkflow(request: { workflowId: string; workflowDir?: string; basePath?: string }): Promise<RuntimeWorkflowDescription | undefined>;
+  // Resolves (and on first use, writes) a workflow's definition of done; with `verify`, checks it now.
+  checkDefinitionOfDone(request: {
+    workflowId: string;
+    workflowDir?: string;
+    basePath?: string;
+    input?: Record<string, unknown>;
+    verify?: boolean;
+  }): Promise<{ definitionOfDone?: DefinitionOfDone; verification?: DefinitionOfDoneVerification }>;
   analyzeReview(request: { paths: string[]; focus?: ReviewFocus; maxFiles?: number; traceId?: string; sessionId?: string; basePath?: string; surface?: TraceSurface }): Promise<RuntimeReviewResponse>;
   listReviewTraces(limit?: number): Promise<TraceRecord[]>;
+  checkSloGate(request?: { workflowId?: string; traceId?: string; sessionId?: string; basePath?: string; surface?: TraceSurface }): Promise<RuntimeSloGateResponse>;
+  verifyCanary(request?: { workflowId?: string; traceId?: string; sessionId?: string; basePath?: string; surface?: TraceSurface; endpoints?: string[]; windowMs?: number; intervalMs?: number; baseline?: CanaryBaseline; rollbackWorkflowId?: string; autoRollback?: boolean }): Promise<RuntimeCanaryResponse>;
+  generateRollbackPlaybook(request?: { base?: string; head?: string; releaseVersion?: string; releaseTraceId?: string; traceId?: string; sessionId?: string; outputPath?: string; pullRequest?: string; basePath?: string; surface?: TraceSurface }): Promise<RuntimeRollbackPlaybookResponse>;
+  summarizeHandoff(request?: { windowHours?: number; outputPath?: string; traceLimit?: number; basePath?: string }): Promise<RuntimeHandoffResponse>;
+  listRubrics(request?: { basePath?: string }): Promise<Rubric[]>;
+  evaluateRubric(request: { rubricId: string; output: string; changedFiles?: string[]; basePath?: string }): Promise<RubricEvaluation>;
+  listBenchTasks(request?: { basePath?: string }): Promise<BenchTask[]>;
+  runBench(request?: { taskIds?: string[]; provider?: string; sessionId?: string; basePath?: string; surface?: TraceSurface }): Promise<RuntimeBenchResponse>;
+  // Tries the task `variants` ways in separate copies of the workspace and checks each against the definition of done.
+  runExperiment(request: {
+    task: string;
+    variants?: number;
+    strategies?: string[];
+    providers?: string[];
+    model?: string;
+    files?: string[];
+    // Criteria as for a workflow's `done` input; else `.automatosx/done/experiment.json` or the workspace's tests.
+    done?: unknown;
+    sessionId?: string;
+    basePath?: string;
+    surface?: TraceSurface;
+  }): Promise<ExperimentRecord>;
+  listExperiments(request?: { basePath?: string }): Promise<ExperimentRecord[]>;
+  getExperiment(request: { experimentId: string; basePath?: string }): Promise<ExperimentRecord>;
+  // Applies a variant's diff, the recommended one by default, to the workspace.
+  pickExperimentVariant(request: { experimentId: string; variantId?: string; sessionId?: string; basePath?: string; surface?: TraceSurface }): Promise<{ experiment: ExperimentRecord; variantId: string; patch: RuntimePatchReviewResponse }>;
+  approveBench(request?: { taskIds?: string[]; basePath?: string }): Promise<string[]>;
+  getTelemetryStatus(request?: { basePath?: string }): Promise<RuntimeTelemetryStatus>;
+  previewTelemetry(request?: { windowDays?: number; basePath?: string }): Promise<TelemetryReport>;
+  sendTelemetry(request?: { windowDays?: number; endpoint?: string; basePath?: string }): Promise<RuntimeTelemetrySendResponse>;
+  startTelemetryCollector(request?: { port?: number; host?: string; outputPath?: string; basePath?: string }): Promise<TelemetryCollector>;
+  recordCrash(request: { error: unknown; surface?: TraceSurface; command?: string; version?: string; basePath?: string }): Promise<RuntimeCrashReport>;
+  prepareBugReport(request?: { bundlePath?: string; submit?: boolean; basePath?: string }): Promise<RuntimeBugReport>;
+  migrateWorkspace(request?: { dryRun?: boolean; workflowDir?: string; basePath?: string }): Promise<RuntimeMigrationResponse>;
+  createBackup(request?: { outputPath?: string; basePath?: string }): Promise<RuntimeBackupResponse>;
+  verifyBackup(request: { archivePath: string }): Promise<BackupManifest>;
+  restoreBackup(request: { archivePath: string; basePath?: string }): Promise<RuntimeRestoreResponse>;
+  getSyncStatus(request?: { basePath?: string }): Promise<RuntimeSyncStatus>;
+  syncState(request?: { basePath?: string }): Promise<RuntimeSyncResponse>;
+  exportMemory(request?: { outputPath?: string; namespace?: string; basePath?: string }): Promise<RuntimeMemoryExportResponse>;
+  importMemory(request: { inputPath: string; namespace?: string; overwrite?: boolean; dryRun?: boolean }): Promise<RuntimeMemoryImportResponse>;
+  // Stores this workspace's Claude Code and Gemini CLI exchanges as semantic entries, once each, in `conversations` by default.
+  importConversations(request?: { sources?: ConversationSource[]; namespace?: string; since?: string; dryRun?: boolean; homeDir?: string }): Promise<RuntimeConversationImportResponse>;
+  // Applies `memory.retention` from config now, regardless of the background prune interval.
+  pruneMemory(request?: { basePath?: string }): Promise<RuntimeMemoryPruneResponse>;
+  // Downloads the `semantic.embeddings` model (the default one when none is configured) so searches can run offline.
+  fetchEmbeddingModel(request?: { basePath?: string }): Promise<{ model: string; modelsDir: string }>;
+  dedupeMemory(request?: { namespace?: string; threshold?: number; dryRun?: boolean; basePath?: string }): Promise<RuntimeMemoryDedupResponse>;
+  // Thresholds default to `memory.compaction` in config; the request overrides them for one run.
+  compactMemory(request?: {
+    namespace?: string;
+    minAgeDays?: number;
+    minClusterSize?: number;
+    similarity?: number;
+    dryRun?: boolean;
+    basePath?: string;
+  }): Promise<RuntimeMemoryCompactionResponse>;
+  // Snapshots go to `.automatosx/memory-snapshots`, keeping `memory.snapshots.keep` of them.
+  snapshotMemory(request?: { basePath?: string }): Promise<RuntimeMemorySnapshotResponse>;
+  listMemorySnapshots(request?: { basePath?: string }): Promise<MemorySnapshotSummary[]>;
+  restoreMemory(request: { snapshotId: string; dryRun?: boolean; basePath?: string }): Promise<RuntimeMemoryRestoreResponse>;
+  // Counts by namespace, tag, agent, and path across key-value and semantic entries matching the filter.
+  memoryFacets(request?: MemoryFilter & { namespace?: string; limit?: number }): Promise<RuntimeMemoryFacetsResponse>;
+  // Marks a retrieved entry helpful or not; later searches in this memory scope rank it up or down.
+  recordMemoryFeedback(request: {
+    key: string;
+    namespace?: string;
+    // Defaults to `memory` (key-value); `semantic` for semantic search results.
+    kind?: MemoryFeedbackKind;
+    helpful: boolean;
+    query?: string;
+    agentId?: string;
+  }): Promise<RuntimeMemoryFeedbackResponse>;
+  // Memory reads, writes, and deletes logged while `memory.audit` was on, newest first.
+  queryMemoryAudit(filter?: MemoryAuditFilter): Promise<RuntimeMemoryAuditResponse>;
+  // Secrets scrubbed from memory writes and the audit log, newest first, with totals per rule.
+  queryMemoryRedactions(filter?: SecretRedactionFilter): Promise<RuntimeSecretRedactionResponse>;
+  // What memory, sessions, and agents say about a file, symbol, session, agent, or memory key, and how they link.
+  queryKnowledgeGraph(request: { query: string; depth?: number; limit?: number; namespace?: string }): Promise<RuntimeKnowledgeGraphResponse>;
+  askQuestion(request: {
+    question: string;
+    provider?: string;
+    maxTokens?: number;
+    sessionId?: string;
+    basePath?: string;
+    surface?: TraceSurface;
+  }): Promise<RuntimeAskResponse>;
+  // Questions for an ambiguous task before planning, answered from memory and specs where possible; defaults from `clarification` in config.
+  clarifyTask(request: {
+    task: string;
+    goal?: string;
+    files?: string[];
+    threshold?: number;
+    maxQuestions?: number;
+    autoAnswer?: boolean;
+    basePath?: string;
+  }): Promise<RuntimeClarificationResponse>;
+  parsePatch(request: { patch: string }): DiffFile[];
+  reviewPatch(request: {
+    patch: string;
+    decisions: HunkDecision[];
+    strategies?: PatchStrategy[];
+    preserveStyle?: boolean;
+    minimalDiff?: boolean;
+    agentId?: string;
+    task?: string;
+    sessionId?: string;
+    basePath?: string;
+    surface?: TraceSurface;
+  }): Promise<RuntimePatchReviewResponse>;
+  conformToFileStyle(request: { path: string; content: string; original: string }): ConformResult;
+  auditDependencies(request?: { basePath?: string; checkUpdates?: boolean }): Promise<RuntimeDependencyAudit>;
+  structuralDiff(request?: { base?: string; head?: string; paths?: string[]; basePath?: string }): Promise<RuntimeStructuralDiffResponse>;
+  apiDiff(request?: { base?: string; head?: string; paths?: string[]; basePath?: string }): Promise<RuntimeApiDiffResponse>;
+  takeSnapshot(request?: { sessionId?: string; label?: string; basePath?: string }): Promise<RuntimeSnapshotResponse>;
+  listSnapshots(request?: { sessionId?: string; basePath?: string }): Promise<SnapshotSummary[]>;
+  getSnapshot(request: { snapshotId: string; basePath?: string }): Promise<WorkspaceSnapshot>;
+  readSnapshotFile(request: { snapshotId: string; path: string; basePath?: string }): Promise<Buffer>;
+  checkoutSnapshot(request: { snapshotId: string; targetDir?: string; paths?: string[]; basePath?: string }): Promise<RuntimeSnapshotCheckoutResponse>;
+  listTechDebt(request?: { paths?: string[]; kinds?: TechDebtKind[]; owner?: string; blame?: boolean; limit?: number; basePath?: string }): Promise<RuntimeTechDebtResponse>;
+  listTodos(request?: TodoFilter & { limit?: number; basePath?: string }): Promise<RuntimeTodoResponse>;
+  // Creates or updates a backlog entry per TODO and resolves entries whose TODO is gone.
+  linkTodos(request?: TodoFilter & { basePath?: string }): Promise<RuntimeTodoLinkResponse>;
+  findSymbols(request: { query: string; paths?: string[]; limit?: number; basePath?: string }): Promise<RuntimeSymbolSearchResponse>;
+  // Declaration counts by language and kind, React components included, and the most central declarations.
+  getSymbolMap(request?: { paths?: string[]; basePath?: string }): Promise<RuntimeSymbolMapResponse>;
+  // Declarations by PageRank over the reference graph, most central first.
+  rankSymbols(request?: { paths?: string[]; limit?: number; basePath?: string }): Promise<RuntimeSymbolRankResponse>;
+  // The latest source indexing pass, from the progress file any process indexing the workspace writes.
+  getIndexProgress(request?: { basePath?: string }): Promise<IndexProgress | undefined>;
+  // Complexity, parameter count, and size per function and method, worst first.
+  getCodeMetrics(request?: CodeMetricsFilter & { basePath?: string }): Promise<RuntimeCodeMetricsResponse>;
+  // Copied code across files and languages, grouped by normalized tokens, most duplicated lines first.
+  findDuplicates(request?: DuplicateFilter & { basePath?: string }): Promise<RuntimeDuplicatesResponse>;
+  findDeadCode(request?: { paths?: string[]; includeExported?: boolean; limit?: number; basePath?: string }): Promise<RuntimeDeadCodeResponse>;
+  startPrCommandServer(request: {
+    token: string;
+    secret?: string;
+    port?: number;
+    host?: string;
+    apiUrl?: string;
+    basePath?: string;
+    onOutcome?: (outcome: PrCommandOutcome) => void;
+  }): Promise<PrCommandServer>;
+  analyzeRenameImpact(request: { symbol: string; paths?: string[]; limit?: number; basePath?: string }): Promise<RuntimeRenameImpactResponse>;
+  findDefinition(request: {
+    symbol?: string;
+    path?: string;
+    line?: number;
+    column?: number;
+    paths?: string[];
+    basePath?: string;
+  }): Promise<RuntimeDefinitionResponse>;
+  findReferences(request: {
+    symbol?: string;
+    path?: string;
+    line?: number;
+    column?: number;
+    paths?: string[];
+    includeDefinitions?: boolean;
+    limit?: number;
+    basePath?: string;
+  }): Promise<RuntimeReferencesResponse>;
+  getCallGraph(request?: {
+    symbol?: string;
+    direction?: CallGraphDirection;
+    depth?: number;
+    paths?: string[];
+    limit?: number;
+    basePath?: string;
+  }): Promise<RuntimeCallGraphResponse>;
+  findImplementations(request: { symbol: string; paths?: string[]; basePath?: string }): Promise<RuntimeImplementationsResponse>;
+  mapGoTests(request?: { paths?: string[]; basePath?: string }): Promise<RuntimeTestMapResponse>;
+  // Symbols and references as an LSIF dump, written to outputPath (default .automatosx/exports/dump.lsif).
+  exportLsif(request?: { paths?: string[]; outputPath?: string; basePath?: string }): Promise<RuntimeLsifExportResponse>;
+  buildIncludeGraph(request?: { paths?: string[]; includeDirs?: string[]; basePath?: string }): Promise<RuntimeIncludeGraphResponse>;
+  buildImportGraph(request?: { paths?: string[]; basePath?: string }): Promise<RuntimeImportGraphResponse>;
+  findGoEmbeds(request?: { paths?: string[]; files?: string[]; basePath?: string }): Promise<RuntimeGoEmbedResponse>;
+  createParserFixture(request: { path: string; name?: string; outputDir?: string; redact?: string[]; basePath?: string }): Promise<RuntimeParserFixtureResponse>;
+  checkParserFixtures(request?: { dir?: string; basePath?: string }): Promise<RuntimeParserConformanceResponse>;
+  loadExtractorPlugins(request?: { basePath?: string }): Promise<ExtractorPluginReport>;
+  previewTemplates(request: {
+    agentId?: string;
+    workflowId?: string;
+    workflowDir?: string;
+    task?: string;
+    input?: Record<string, unknown>;
+    basePath?: string;
+  }): Promise<RuntimeTemplatePreview>;
   getConfig(path?: string): Promise<unknown>;
   showConfig(): Promise<Record<string, unknown>>;
   setConfig(path: string, value: unknown): Promise<Record<string, unknown>>;
@@ -500,13 +1074,37 @@ export interface SharedRuntimeService {
   listTracesBySession(sessionId: string, limit?: number): Promise<TraceRecord[]>;
   listTraces(limit?: number): Promise<TraceRecord[]>;
   closeStuckTraces(maxAgeMs?: number): Promise<TraceRecord[]>;
-  storeMemory(entry: { key: string; namespace?: string; value: unknown }): Promise<MemoryEntry>;
+  storeMemory(entry: { key: string; namespace?: string; value: unknown; tags?: string[]; ttlMs?: number; agentId?: string; importance?: number }): Promise<MemoryEntry>;
   getMemory(key: string, namespace?: string): Promise<MemoryEntry | undefined>;
-  searchMemory(query: string, namespace?: string): Promise<MemoryEntry[]>;
+  // Entries marked helpful through recordMemoryFeedback move up, unhelpful ones down; searchSemantic does the same.
+  searchMemory(query: string, namespace?: string, filter?: MemoryFilter): Promise<MemoryEntry[]>;
+  // Every match rather than the best 200, a page at a time, in the same order on every call.
+  searchMemoryPage(query: string, namespace?: string, filter?: MemoryFilter, page?: PageRequest): Promise<RuntimeMemorySearchPage>;
   deleteMemory(key: string, namespace?: string): Promise<boolean>;
   listMemory(namespace?: string): Promise<MemoryEntry[]>;
-  storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown> }): Promise<SemanticEntry>;
-  searchSemantic(query: string, options?: { namespace?: string; filterTags?: string[]; topK?: number; minSimilarity?: number }): Promise<SemanticSearchResult[]>;
+  // Memory and semantic entries as they stood at `asOf`, including ones since changed or deleted.
+  memoryAsOf(asOf: string, options?: { namespace?: string; key?: string }): Promise<MemoryAsOf>;
+  storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown>; ttlMs?: number; agentId?: string; importance?: number }): Promise<SemanticEntry>;
+  storeSemanticFile(entry: {
+    path: string;
+    content: string;
+    key?: string;
+    namespace?: string;
+    tags?: string[];
+    metadata?: Record<string, unknown>;
+    chunking?: ChunkingStrategy;
+    ttlMs?: number;
+    agentId?: string;
+    importance?: number;
+    basePath?: string;
+  }): Promise<RuntimeSemanticFileResponse>;
+  // Stores the doc comment of every documented declaration under `paths` as a
+  // semantic entry keyed `<path>#<symbol>` (namespace code-docs by default).
+  indexDocComments(request?: { paths?: string[]; namespace?: string; agentId?: string; basePath?: string }): Promise<RuntimeDocIndexResponse>;
+  // Mode and keyword weight default to `semantic.search` in config, else hybrid at 0.5.
+  // Tags are filtered by `filterTags`; the other MemoryFilter fields narrow results before topK applies.
+  // Chunks of generated files are left out unless includeGenerated is set or filterTags asks for `generated`, and rank below hand-written ones.
+  searchSemantic(query: string, options?: SemanticSearchOptions & Omit<MemoryFilter, 'tags'> & { includeGenerated?: boolean }): Promise<SemanticSearchResult[]>;
   getSemantic(key: string, namespace?: string): Promise<SemanticEntry | undefined>;
   listSemantic(options?: { namespace?: string; keyPrefix?: string; filterTags?: string[]; limit?: number }): Promise<SemanticEntry[]>;
   deleteSemantic(key: string, namespace?: string): Promise<boolean>;
@@ -568,13 +1166,43 @@ export interface SharedRuntimeService {
   completeSession(sessionId: string, summary?: string): Promise<SessionEntry>;
   failSession(sessionId: string, message: string): Promise<SessionEntry>;
   closeStuckSessions(maxAgeMs?: number): Promise<SessionEntry[]>;
+  // Provider calls, tool calls, file changes, and runs recorded for a session, oldest first.
+  getSessionRecording(request: { sessionId: string; basePath?: string }): Promise<SessionRecordingEvent[]>;
+  // Notes a tool call a client made for a session; the MCP server calls this for tools given a `sessionId`.
+  recordSessionToolCall(request: {
+    sessionId: string;
+    tool: string;
+    args: Record<string, unknown>;
+    success: boolean;
+    error?: string;
+    durationMs: number;
+    basePath?: string;
+  }): Promise<void>;
+  // Runs a session's recorded runs again in a copy of the workspace, answering provider calls from the recording, and reports where it diverged.
+  replaySession(request: { sessionId: string; to?: number; keepSandbox?: boolean; basePath?: string }): Promise<RuntimeSessionReplayResponse>;
+  // Removes stale `ax/` branches and worktrees, leftover scratch directories, and abandoned sessions; `gc` in config sets the defaults.
+  collectGarbage(request?: { maxAgeDays?: number; branchPrefix?: string; force?: boolean; dryRun?: boolean; basePath?: string; tmpDir?: string }): Promise<RuntimeGcResponse>;
   getStores(): { traceStore: TraceStore; stateStore: StateStore };
+  // The same runtime with memory and semantic entries kept under another project or team scope.
+  withMemoryScope(scope: string): SharedRuntimeService;
+  // The same runtime, with memory accesses logged as this actor's.
+  withMemoryActor(actor: MemoryActor): SharedRuntimeService;
 }
 
 export interface SharedRuntimeConfig {
   basePath?: string;
   traceStore?: TraceStore;
   stateStore?: StateStore;
+  // Project or team whose memory this runtime sees; defaults to `memory.scope` in config.
+  memoryScope?: string;
+  // Who memory accesses are logged as when `memory.audit` is on; defaults to the OS user.
+  memoryActor?: MemoryActor;
+  // Runs forbidden-pattern queries in reviews and patch checks; web-tree-sitter unless given.
+  queryRunner?: TreeSitterQueryRunner;
+  // Embeds semantic memory for vector search; from `semantic.embeddings` in config unless given.
+  embeddingProvider?: EmbeddingProvider;
+  // Runs provider prompts; a bridge to the configured provider commands unless given, as when replaying a session.
+  providerBridge?: ReturnType<typeof createProviderBridge>;
   maxConcurrentDiscussions?: number;
   maxProvidersPerDiscussion?: number;
   maxDiscussionRounds?: number;
@@ -583,6 +1211,9 @@ export interface SharedRuntimeConfig {
 const DEFAULT_DISCUSSION_CONCURRENCY = 2;
 const DEFAULT_DISCUSSION_PROVIDER_BUDGET = 3;
 const DEFAULT_DISCUSSION_ROUNDS = 3;
+const CRASH_ACTIVITY_LIMIT = 20;
+// Central declarations listed with the symbol map counts.
+const SYMBOL_MAP_CENTRAL_LIMIT = 10;
 const BUILTIN_GUARD_POLICIES: StepGuardPolicy[] = [
   {
     policyId: 'step-validation',
@@ -690,9 +1321,62 @@ const BUILTIN_ABILITIES: RuntimeAbility[] = [
 
 export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): SharedRuntimeService {
   const basePath = config.basePath ?? process.cwd();
-  const traceStore = config.traceStore ?? createTraceStore({ basePath });
-  const stateStore = config.stateStore ?? createStateStore({ basePath });
-  const providerBridge = createProviderBridge({ basePath });
+  const memoryEncryptionKey = loadMemoryEncryptionKey(basePath);
+  // Scrubs secrets from what is about to be stored or logged, noting what was found (never the secret) in the redaction log.
+  const redactForMemory = async <T>(value: T, target: SecretRedactionTarget, ref: { namespace?: string; key?: string }): Promise<T> => {
+    const redaction = redactSecrets(value, resolveSecretRedactionConfig((await readWorkspaceConfig(basePath)).memory));
+    if (redaction.findings.length > 0) {
+      try {
+        await appendSecretRedaction(basePath, { target, ...ref, findings: redaction.findings });
+      } catch {
+        // An unwritable log must not fail the write; the secret is already gone.
+      }
+    }
+    return redaction.value;
+  };
+  // Every trace and memory write goes through redaction here, whichever feature makes it: bundle imports, sync pulls, compaction, todos, ...
+  const baseTraceStore = config.traceStore ?? createTraceStore({ basePath });
+  const traceStore: TraceStore = {
+    upsertTrace: async (record) => baseTraceStore.upsertTrace(await redactForMemory(record, 'trace', { key: record.traceId })),
+    getTrace: (traceId) => baseTraceStore.getTrace(traceId),
+    listTraces: (limit) => baseTraceStore.listTraces(limit),
+    closeStuckTraces: (maxAgeMs) => baseTraceStore.closeStuckTraces(maxAgeMs),
+  };
+  const openedStateStore = config.stateStore ?? createStateStore({ basePath, encryptionKey: memoryEncryptionKey, ...loadMemoryBackendConfig(basePath) });
+  // A shared Postgres backend is the team's copy as soon as it's written, so `sync.privacy` applies to every write there, not just to what `ax sync` pushes.
+  const shareMemory = async <T>(value: T): Promise<T> => {
+    const sync = (await readWorkspaceConfig(basePath)).sync;
+    const policy = resolveSyncPrivacy(isRecord(sync) ? sync.privacy : undefined);
+    return policy === undefined ? value : createSyncPrivacyFilter(policy, localPrivacyContext(basePath))(value) as T;
+  };
+  const baseStateStore = openedStateStore instanceof PostgresStateStore
+    ? createFilteredStateStore(openedStateStore, { memory: (value) => shareMemory(value), semantic: (entry) => shareMemory(entry) })
+    : openedStateStore;
+  const stateStore = createFilteredStateStore(createScopedStateStore(baseStateStore, config.memoryScope ?? (() => readMemoryScope(basePath))), {
+    memory: (value, ref) => redactForMemory(value, 'memory', ref),
+    semantic: (entry, ref) => redactForMemory(entry, 'semantic', ref),
+  });
+  const currentMemoryScope = async (): Promise<string | undefined> => normalizeMemoryScope(config.memoryScope ?? await readMemoryScope(basePath));
+  // Logs one memory access when `memory.audit` is on. Writes made for an agent are the agent's; everything else is the runtime's actor.
+  const auditMemory = async (record: Omit<MemoryAuditRecord, 'at' | 'actor' | 'scope'>, agentId?: string): Promise<void> => {
+    const auditConfig = resolveMemoryAuditConfig((await readWorkspaceConfig(basePath)).memory);
+    if (!auditConfig.enabled) {
+      return;
+    }
+    try {
+      const scope = await currentMemoryScope();
+      const query = record.query !== undefined ? await redactForMemory(record.query, 'memory-audit', { namespace: record.namespace }) : undefined;
+      await appendMemoryAudit(basePath, auditConfig, {
+        ...record,
+        ...(query !== undefined ? { query } : {}),
+        actor: agentId !== undefined ? { kind: 'agent', id: agentId } : config.memoryActor ?? defaultMemoryActor(),
+        ...(scope !== undefined ? { scope } : {}),
+      });
+    } catch {
+      // An unwritable log must not fail the memory access.
+    }
+  };
+  const providerBridge = config.providerBridge ?? createProviderBridge({ basePath });
   const discussionCoordinator = createDiscussionCoordinator({
     maxConcurrentDiscussions: config.maxConcurrentDiscussions ?? DEFAULT_DISCUSSION_CONCURRENCY,
     maxProvidersPerDiscussion: config.maxProvidersPerDiscussion ?? DEFAULT_DISCUSSION_PROVIDER_BUDGET,
@@ -703,8 +1387,92 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
   providerBridgeCache.set(basePath, providerBridge);
   const discussionCoordinatorCache = new Map<string, DiscussionCoordinator>();
   discussionCoordinatorCache.set(basePath, discussionCoordinator);
+  // Loaded grammars and compiled queries are kept per workspace.
+  const queryRunnerCache = new Map<string, TreeSitterQueryRunner>();
+  const resolveQueryRunner = (requestBasePath: string): TreeSitterQueryRunner => {
+    if (config.queryRunner !== undefined) {
+      return config.queryRunner;
+    }
+    const runner = queryRunnerCache.get(requestBasePath) ?? createTreeSitterQueryRunner(requestBasePath);
+    queryRunnerCache.set(requestBasePath, runner);
+    return runner;
+  };
+  // Undefined while semantic search uses term vectors; a loaded model is kept for the runtime's lifetime.
+  const embeddingProviderCache = new Map<string, EmbeddingProvider>();
+  const resolveEmbeddingProvider = async (): Promise<EmbeddingProvider | undefined> => {
+    if (config.embeddingProvider !== undefined) {
+      return config.embeddingProvider;
+    }
+    const workspace = await readWorkspaceConfig(basePath);
+    const embeddings = resolveEmbeddingConfig(isRecord(workspace.semantic) ? workspace.semantic.embeddings : undefined, basePath);
+    if (embeddings === undefined) {
+      return undefined;
+    }
+    const cacheKey = JSON.stringify(embeddings);
+    const provider = embeddingProviderCache.get(cacheKey) ?? createLocalEmbeddingProvider(embeddings);
+    embeddingProviderCache.set(cacheKey, provider);
+    return provider;
+  };
+  // Best effort, like retention: a search embeds whatever is still missing and reports failures.
+  const indexEmbeddingsInBackground = async (entries: SemanticEntry[]): Promise<void> => {
+    try {
+      const provider = await resolveEmbeddingProvider();
+      if (provider !== undefined && entries.length > 0) {
+        if (stateStore.vectorIndex !== undefined) {
+          await embedIntoVectorIndex(stateStore.vectorIndex, provider, entries);
+        } else {
+          await indexEmbeddings({ basePath, provider, entries, encryptionKey: memoryEncryptionKey });
+        }
+      }
+    } catch {
+      // Searched entries are embedded on demand.
+    }
+  };
+  // Vectors live next to the entries in stores with a vector index, keyed by the provider's model.
+  const embedIntoVectorIndex = async (index: SemanticVectorIndex, provider: EmbeddingProvider, entries: SemanticEntry[]): Promise<void> => {
+    if (entries.length === 0) return;
+    const vectors = await provider.embed(entries.map((entry) => entry.content));
+    await index.store(provider.id, entries.map((entry, position) => ({ key: entry.key, namespace: entry.namespace, vector: vectors[position] ?? [] })));
+  };
+  // Vector and hybrid search over dense embeddings; keyword ranking stays with the store.
+  const searchSemanticByEmbedding = async (
+    query: string,
+    options: SemanticSearchOptions,
+    provider: EmbeddingProvider,
+    prune: boolean,
+  ): Promise<SemanticSearchResult[]> => {
+    const filters = { namespace: options.namespace, filterTags: options.filterTags };
+    let byVector: Array<{ entry: SemanticEntry; score: number }>;
+    if (stateStore.vectorIndex !== undefined) {
+      const index = stateStore.vectorIndex;
+      await embedIntoVectorIndex(index, provider, await index.listUnembedded(provider.id, filters));
+      const [queryVector] = await provider.embed([query]);
+      byVector = (await index.search(provider.id, queryVector ?? [], {
+        ...filters,
+        minSimilarity: options.minSimilarity,
+        // Hybrid fusion needs the whole vector ranking.
+        limit: options.mode === 'hybrid' ? undefined : options.topK,
+      })).map(({ score, ...entry }) => ({ entry, score }));
+    } else {
+      const entries = await stateStore.listSemantic(filters);
+      byVector = (await rankByEmbedding({ basePath, provider, query, entries, encryptionKey: memoryEncryptionKey, prune }))
+        .filter(({ score }) => score >= (options.minSimilarity ?? 0));
+    }
+    const ranked = options.mode === 'hybrid'
+      ? fuseSemanticRankings(
+        byVector.filter(({ score }) => score > 0),
+        (await stateStore.searchSemantic(query, { namespace: options.namespace, filterTags: options.filterTags, mode: 'keyword' }))
+          .map((entry) => ({ entry, score: entry.score })),
+        options.keywordWeight,
+      )
+      : byVector.map(({ entry, score }) => ({ ...entry, score }));
+    return options.topK === undefined ? ranked : ranked.slice(0, Math.max(0, options.topK));
+  };
 
   const resolveProviderBridge = (requestBasePath?: string) => {
+    if (config.providerBridge !== undefined) {
+      return config.providerBridge;
+    }
     const resolvedBasePath = requestBasePath ?? basePath;
     const cached = providerBridgeCache.get(resolvedBasePath);
     if (cached !== undefined) {
@@ -731,9 +1499,97 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
     return created;
   };
 
-  return {
+  // Adds to the recording `ax session replay` reads. Only runs given a session
+  // are recorded, and only with `sessions.record: true`; a recording that can't
+  // be written never fails what it records.
+  const recordSession = async (
+    requestBasePath: string | undefined,
+    sessionId: string | undefined,
+    record: (workspacePath: string, sessionId: string) => Promise<void>,
+  ): Promise<void> => {
+    if (sessionId === undefined) {
+      return;
+    }
+    const workspacePath = requestBasePath ?? basePath;
+    try {
+      if (resolveSessionRecordingEnabled((await readWorkspaceConfig(workspacePath)).sessions)) {
+        await record(workspacePath, sessionId);
+      }
+    } catch {
+      // Recording is best effort.
+    }
+  };
+  const recordSessionEvent = (requestBasePath: string | undefined, sessionId: string | undefined, event: SessionEvent) =>
+    recordSession(requestBasePath, sessionId, async (workspacePath, id) => appendSessionEvent(workspacePath, id, await redactForMemory(event, 'session-recording', { key: id })));
+  const sessionProviderBridge = (request: { basePath?: string; sessionId?: string; traceId?: string }) => {
+    const bridge = resolveProviderBridge(request.basePath);
+    return request.sessionId === undefined
+      ? bridge
+      : recordProviderCalls(bridge, (providerRequest, outcome) => recordSessionEvent(request.basePath, request.sessionId, {
+        kind: 'provider',
+        ...(request.traceId !== undefined ? { traceId: request.traceId } : {}),
+        request: providerRequest,
+        outcome,
+      }));
+  };
+  const sessionToolExecutor = (request: { basePath?: string; sessionId?: string; traceId?: string }) => {
+    const executor = createToolExecutor();
+    return request.sessionId === undefined ? executor : {
+      ...executor,
+      execute: async (toolName: string, args: Record<string, unknown>) => {
+        const result = await executor.execute(toolName, args);
+        await recordSessionEvent(request.basePath, request.sessionId, {
+          kind: 'tool',
+          source: 'workflow',
+          ...(request.traceId !== undefined ? { traceId: request.traceId } : {}),
+          tool: toolName,
+          args,
+          success: result.success,
+          durationMs: result.durationMs,
+        });
+        return result;
+      },
+    };
+  };
+  // Notes the request that started a run and how it ended, so a replay can run it again and compare.
+  const recordRun = async <T extends { traceId: string; success?: boolean; error?: { message?: string } }>(
+    operation: SessionOperation,
+    request: { basePath?: string; sessionId?: string; surface?: TraceSurface; traceId?: string },
+    run: (traceId: string | undefined) => Promise<T>,
+  ): Promise<T> => {
+    if (request.sessionId === undefined) {
+      return run(request.traceId);
+    }
+    // Patch runs make their own trace id.
+    const traceId = operation === 'patch' ? undefined : request.traceId ?? randomUUID();
+    const { basePath: _basePath, sessionId: _sessionId, surface: _surface, traceId: _traceId, ...recorded } = request;
+    await recordSessionEvent(request.basePath, request.sessionId, { kind: 'operation', operation, ...(traceId !== undefined ? { traceId } : {}), request: recorded });
+    let result: T;
+    try {
+      result = await run(traceId);
+    } catch (error) {
+      await recordSessionEvent(request.basePath, request.sessionId, {
+        kind: 'result',
+        operation,
+        ...(traceId !== undefined ? { traceId } : {}),
+        success: false,
+        error: error instanceof Error ? error.message : String(error),
+      });
+      throw error;
+    }
+    await recordSessionEvent(request.basePath, request.sessionId, {
+      kind: 'result',
+      operation,
+      traceId: result.traceId,
+      success: result.success !== false,
+      ...(result.error?.message !== undefined ? { error: result.error.message } : {}),
+    });
+    return result;
+  };
+
+  const service: SharedRuntimeService = {
     async callProvider(request) {
-      const runtimeProviderBridge = resolveProviderBridge(request.basePath);
+      const runtimeProviderBridge = sessionProviderBridge(request);
       const traceId = request.traceId ?? randomUUID();
       const startedAt = new Date().toISOString();
       const resolvedProvider = request.provider ?? 'claude';
@@ -892,7 +1748,7 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
     },
 
     async runWorkflow(request) {
-      const runtimeProviderBridge = resolveProviderBridge(request.basePath);
+      const runtimeProviderBridge = sessionProviderBridge(request);
       const runtimeDiscussionCoordinator = resolveDiscussionCoordinator(request.basePath);
       const workflowDir = resolveWorkflowDir(request.workflowDir, request.basePath, basePath);
       const loader = createWorkflowLoader({ workflowsDir: workflowDir });
@@ -928,6 +1784,40 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
 
       const traceId = request.traceId ?? randomUUID();
       const startedAt = new Date().toISOString();
+      // Gated here rather than in the CLI so releases started over MCP or the API are held to the same budgets.
+      const sloConfig = resolveSloGateConfig((await readWorkspaceConfig(request.basePath ?? basePath)).slo);
+      const checkedGate = isSloGatedWorkflow(sloConfig, request.workflowId)
+        ? await runSloGate(traceStore, {
+          config: sloConfig,
+          workflowId: request.workflowId,
+          sessionId: request.sessionId,
+          surface: request.surface ?? 'cli',
+        })
+        : undefined;
+      const sloGate = checkedGate?.decision === 'skipped' ? undefined : checkedGate;
+      if (sloGate?.decision === 'block') {
+        const error = { code: 'SLO_GATE_BLOCKED', message: `Blocked by SLO gate: ${sloGate.reasons.join('; ')}` };
+        await traceStore.upsertTrace({
+          traceId,
+          workflowId: request.workflowId,
+          surface: request.surface ?? 'cli',
+          status: 'failed',
+          startedAt,
+          completedAt: new Date().toISOString(),
+          input: request.input,
+          stepResults: [],
+          error,
+          metadata: { workflowDir, sessionId: request.sessionId, sloGateTraceId: sloGate.traceId },
+        });
+        return { traceId, workflowId: request.workflowId, success: false, stepResults: [], error, totalDurationMs: 0, workflowDir, sloGate };
+      }
+      // Settled before any step runs, so the run is checked against what it was told "done" means.
+      const definitionOfDone = await resolveDefinitionOfDone({
+        basePath: request.basePath ?? basePath,
+        workflowId: request.workflowId,
+        input: request.input,
+        workflowDone: workflow.metadata?.done,
+      });
       await traceStore.upsertTrace({
         traceId,
         workflowId: request.workflowId,
@@ -941,28 +1831,80 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
           provider: request.provider,
           model: request.model,
           sessionId: request.sessionId,
+          definitionOfDone,
         },
       });
 
+      const stepExecutor = createRealStepExecutor({
+        promptExecutor: createPromptExecutor(runtimeProviderBridge, request.basePath ?? basePath, request.provider, request.model),
+        toolExecutor: sessionToolExecutor({ ...request, traceId }),
+        discussionExecutor: createDiscussionExecutor(traceId, request.provider, runtimeDiscussionCoordinator),
+        defaultProvider: request.provider ?? 'claude',
+        defaultModel: request.model ?? 'v14-shared-runtime',
+        templateVariables: {
+          ...await collectTemplateVariables({ basePath: request.basePath ?? basePath, input: request.input }),
+          ...await collectTodoVariables(request.basePath ?? basePath, request.input, stateStore, true),
+          ...await collectMetricsVariables(request.basePath ?? basePath, request.input),
+          ...await collectDuplicatesVariables(request.basePath ?? basePath, request.input),
+        },
+      });
+      const workspacePath = request.basePath ?? basePath;
+      const snapshotConfig = resolveSnapshotConfig((await readWorkspaceConfig(workspacePath)).snapshots);
+      const snapshotIds: string[] = [];
+      let completedSteps = 0;
       const runner = createWorkflowRunner({
         executionId: traceId,
         agentId: request.surface ?? 'cli',
-        stepExecutor: createRealStepExecutor({
-          promptExecutor: createPromptExecutor(runtimeProviderBridge, request.provider, request.model),
-          toolExecutor: createToolExecutor(),
-          discussionExecutor: createDiscussionExecutor(traceId, request.provider, runtimeDiscussionCoordinator),
-          defaultProvider: request.provider ?? 'claude',
-          defaultModel: request.model ?? 'v14-shared-runtime',
-        }),
+        // The snapshot is awaited inside the step so it records the tree
+        // before the next step starts changing it.
+        stepExecutor: snapshotConfig.enabled
+          ? async (step, context) => {
+            const result = await stepExecutor(step, context);
+            completedSteps += 1;
+            if (completedSteps % snapshotConfig.everySteps === 0) {
+              try {
+                snapshotIds.push((await takeSnapshot({
+                  basePath: workspacePath,
+                  sessionId: request.sessionId ?? traceId,
+                  label: `${request.workflowId}: ${step.stepId}`,
+                  traceId,
+                  stepId: step.stepId,
+                  maxFileBytes: snapshotConfig.maxFileBytes,
+                })).snapshotId);
+              } catch {
+                // A failed snapshot must not fail the workflow step.
+              }
+            }
+            return result;
+          }
+          : stepExecutor,
       });
 
       const result = await runner.run(workflow, request.input ?? {});
+      const todoScope = resolveTodoScope(request.input?.todos);
+      if (result.success && todoScope !== undefined) {
+        try {
+          await linkTodos({ ...todoScope, basePath: workspacePath, state: stateStore });
+        } catch {
+          // The backlog catches up on the next link.
+        }
+      }
+      const doneVerification = result.success && definitionOfDone !== undefined && definitionOfDone.criteria.length > 0
+        ? await verifyDefinitionOfDone({ basePath: workspacePath, criteria: definitionOfDone.criteria })
+        : undefined;
+      const success = result.success && doneVerification?.passed !== false;
+      const error = success || doneVerification === undefined || !result.success
+        ? result.error
+        : {
+          code: 'DEFINITION_OF_DONE_FAILED',
+          message: `Definition of done not met: ${doneVerification.results.filter((entry) => entry.status === 'failed').map((entry) => entry.id).join(', ')}`,
+        };
       const completedAt = new Date().toISOString();
       await traceStore.upsertTrace({
         traceId,
         workflowId: request.workflowId,
         surface: request.surface ?? 'cli',
-        status: result.success ? 'completed' : 'failed',
+        status: success ? 'completed' : 'failed',
         startedAt,
         completedAt,
         input: request.input,
@@ -974,25 +1916,32 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
           error: stepResult.error?.message,
         })),
         output: result.output,
-        error: result.error,
+        error,
         metadata: {
           workflowDir,
           provider: request.provider,
           model: request.model,
           totalDurationMs: result.totalDurationMs,
           sessionId: request.sessionId,
+          snapshotIds: snapshotIds.length > 0 ? snapshotIds : undefined,
+          definitionOfDone,
+          doneVerification,
+          sloGateTraceId: sloGate?.traceId,
         },
       });
 
       return {
         traceId,
         workflowId: request.workflowId,
-        success: result.success,
+        success,
         stepResults: result.stepResults,
         output: result.output,
-        error: result.error,
+        error,
         totalDurationMs: result.totalDurationMs,
         workflowDir,
+        definitionOfDone,
+        doneVerification,
+        ...(sloGate !== undefined ? { sloGate } : {}),
       };
     },
 
@@ -1247,7 +2196,7 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
     },
 
     async runAgent(request) {
-      const runtimeProviderBridge = resolveProviderBridge(request.basePath);
+      const runtimeProviderBridge = sessionProviderBridge(request);
       const traceId = request.traceId ?? randomUUID();
       const agent = await stateStore.getAgent(request.agentId);
       const startedAt = new Date().toISOString();
@@ -1297,8 +2246,16 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
       const resolvedProvider = request.provider ?? asOptionalString(metadata.provider) ?? 'claude';
       const resolvedModel = request.model ?? asOptionalString(metadata.model) ?? 'v14-agent-run';
       const task = resolveAgentTask(request.task, request.input, agent);
-      const prompt = buildAgentPrompt(agent, task, request.input, metadata);
-      const systemPrompt = resolveAgentSystemPrompt(agent, metadata);
+      const rejectedChanges = formatRejectedHunks(await stateStore.listFeedback({ agentId: agent.agentId, limit: 20 }));
+      const pack = request.files !== undefined && request.files.length > 0
+        ? await packFiles({ basePath: request.basePath ?? basePath, files: request.files, provider: resolvedProvider })
+        : undefined;
+      const agentPrompt = buildAgentPrompt(agent, task, request.input, metadata, rejectedChanges);
+      const prompt = pack !== undefined ? `${agentPrompt}\n\n${pack.text}` : agentPrompt;
+      const templateVariables = await collectAgentTemplateVariables(request.basePath ?? basePath, agent, task, request.input);
+      const agentSystemPrompt = renderTemplate(resolveAgentSystemPrompt(agent, metadata), templateVariables).text;
+      const systemPrompt = pack !== undefined ? `${agentSystemPrompt}\n\n${FILE_PACK_ANCHOR_INSTRUCTIONS}` : agentSystemPrompt;
+      const packWarnings = (pack?.skipped ?? []).map((entry) => `Left ${entry.path} out of the file pack: ${entry.reason}.`);
 
       await traceStore.upsertTrace({
         traceId,
@@ -1310,6 +2267,7 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
           agentId: agent.agentId,
           task,
           input: request.input,
+          files: pack?.files.map((file) => file.path),
         },
         stepResults: [],
         metadata: {
@@ -1334,7 +2292,7 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
       const completedAt = new Date().toISOString();
 
       if (bridgeResult.type === 'response' || bridgeResult.type === 'failure') {
-        const warnings = bridgeResult.type === 'failure' ? [bridgeResult.response.error ?? 'Agent execution failed.'] : [];
+        const warnings = [...packWarnings, ...(bridgeResult.type === 'failure' ? [bridgeResult.response.error ?? 'Agent execution failed.'] : [])];
         await traceStore.upsertTrace({
           traceId,
           workflowId: 'agent.run',
@@ -1346,6 +2304,7 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
             agentId: agent.agentId,
             task,
             input: request.input,
+            files: pack?.files.map((file) => file.path),
           },
           stepResults: [
             {
@@ -1390,6 +2349,7 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
           executionMode: 'subprocess',
           warnings,
           usage: bridgeResult.response.usage,
+          ...(pack !== undefined ? { anchors: resolveFileAnchors(bridgeResult.response.content ?? '', pack) } : {}),
           error: bridgeResult.response.success ? undefined : {
             code: bridgeResult.response.errorCode,
             message: bridgeResult.response.error,
@@ -1398,7 +2358,7 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
       }
 
       const content = buildSimulatedAgentOutput(agent, task, request.input);
-      const warnings = [`No provider executor configured for "${resolvedProvider}". Returned simulated agent output.`];
+      const warnings = [...packWarnings, `No provider executor configured for "${resolvedProvider}". Returned simulated agent output.`];
       const usage = {
         inputTokens: tokenize(prompt),
         outputTokens: tokenize(content),
@@ -1415,6 +2375,7 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
           agentId: agent.agentId,
           task,
           input: request.input,
+          files: pack?.files.map((file) => file.path),
         },
         stepResults: [
           {
@@ -1454,6 +2415,7 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
         executionMode: 'simulated',
         warnings,
         usage,
+        ...(pack !== undefined ? { anchors: resolveFileAnchors(content, pack) } : {}),
       };
     },
 
@@ -1667,14 +2629,16 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
 
     async getStatus(request) {
       const limit = request?.limit ?? 10;
-      const [sessions, traces, config] = await Promise.all([
+      const [sessions, traces, config, providerLatency] = await Promise.all([
         stateStore.listSessions(),
         traceStore.listTraces(Math.max(limit * 3, limit)),
         readWorkspaceConfig(basePath),
+        summarizeProviderLatency(basePath),
       ]);
       const activeSessions = sessions.filter((session) => session.status === 'active').slice(0, limit);
       const runningTraces = traces.filter((trace) => trace.status === 'running').slice(0, limit);
       const recentFailedTraces = traces.filter((trace) => trace.status === 'failed').slice(0, limit);
+      const hedgingConfig = resolveHedgingConfig(config.routing);
 
       return {
         sessions: {
@@ -1697,6 +2661,15 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
               : undefined,
           providerExecutionMode: providerBridge.getExecutionMode(),
           configuredExecutors: listConfiguredExecutors(config),
+          providerLatency,
+          latencyRouting: await readLatencyRoutingStatus(basePath, resolveLatencyRoutingConfig(config.routing)),
+          hedging: {
+            enabled: hedgingConfig.enabled,
+            providers: hedgingConfig.providers,
+            trigger: describeHedgeTrigger(hedgingConfig),
+            hedgesLastHour: await countRecentHedges(basePath),
+            maxPerHour: hedgingConfig.maxPerHour,
+          },
         },
         activeSessions,
         runningTraces,
@@ -1717,6 +2690,9 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
       if (request?.stat === true) {
         command.push('--stat');
       }
+      if (request?.nameOnly === true) {
+        command.push('--name-only');
+      }
       if (typeof request?.commit === 'string' && request.commit.length > 0) {
         command.push(request.commit);
       }
@@ -1799,15 +2775,39 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
       };
     },
 
-    analyzeReview(request) {
+    async checkDefinitionOfDone(request) {
+      const workflowBasePath = request.basePath ?? basePath;
+      const workflowDir = resolveWorkflowDir(request.workflowDir, request.basePath, basePath);
+      const workflow = await createWorkflowLoader({ workflowsDir: workflowDir }).load(request.workflowId);
+      if (workflow === undefined) {
+        throw new Error(`Workflow "${request.workflowId}" not found`);
+      }
+      const definitionOfDone = await resolveDefinitionOfDone({
+        basePath: workflowBasePath,
+        workflowId: request.workflowId,
+        input: request.input,
+        workflowDone: workflow.metadata?.done,
+      });
+      return {
+        definitionOfDone,
+        verification: request.verify === true && definitionOfDone !== undefined
+          ? await verifyDefinitionOfDone({ basePath: workflowBasePath, criteria: definitionOfDone.criteria })
+          : undefined,
+      };
+    },
+
+    async analyzeReview(request) {
+      const reviewBasePath = request.basePath ?? basePath;
       return runReviewAnalysis(traceStore, {
         paths: request.paths,
         focus: request.focus,
         maxFiles: request.maxFiles,
         traceId: request.traceId,
         sessionId: request.sessionId,
-        basePath: request.basePath ?? basePath,
+        basePath: reviewBasePath,
         surface: request.surface ?? 'cli',
+        editorLinkTemplate: resolveEditorLinkTemplate((await readWorkspaceConfig(reviewBasePath)).editor),
+        runQuery: resolveQueryRunner(reviewBasePath),
       });
     },
 
@@ -1815,125 +2815,1351 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
       return listReviewTraces(traceStore, limit);
     },
 
-    async getConfig(path) {
-      const config = await readWorkspaceConfig(basePath);
-      if (path === undefined || path.length === 0) {
-        return config;
-      }
-      return getValueAtPath(config, path);
-    },
-
-    showConfig() {
-      return readWorkspaceConfig(basePath);
+    async checkSloGate(request = {}) {
+      const config = await readWorkspaceConfig(request.basePath ?? basePath);
+      return runSloGate(traceStore, {
+        config: resolveSloGateConfig(config.slo),
+        workflowId: request.workflowId,
+        traceId: request.traceId,
+        sessionId: request.sessionId,
+        surface: request.surface ?? 'cli',
+      });
     },
 
-    async setConfig(path, value) {
-      const config = await readWorkspaceConfig(basePath);
-      setValueAtPath(config, path, value);
-      await writeWorkspaceConfig(basePath, config);
-      return config;
+    async verifyCanary(request = {}) {
+      const resolvedBasePath = request.basePath ?? basePath;
+      const config = await readWorkspaceConfig(resolvedBasePath);
+      const surface = request.surface ?? 'cli';
+      return runCanaryVerification(traceStore, {
+        config: resolveCanaryConfig(config.canary, {
+          endpoints: request.endpoints,
+          windowMs: request.windowMs,
+          intervalMs: request.intervalMs,
+          baseline: request.baseline,
+          rollbackWorkflowId: request.rollbackWorkflowId,
+          autoRollback: request.autoRollback,
+        }),
+        workflowId: request.workflowId,
+        traceId: request.traceId,
+        sessionId: request.sessionId,
+        surface,
+        triggerRollback: async (workflowId, reasons) => {
+          const result = await this.runWorkflow({
+            workflowId,
+            sessionId: request.sessionId,
+            basePath: resolvedBasePath,
+            input: {
+              reason: 'canary-regression',
+              reasons,
+              verifiedWorkflowId: request.workflowId,
+            },
+            surface,
+          });
+          return {
+            workflowId,
+            traceId: result.traceId,
+            success: result.success,
+            error: result.error?.message,
+          };
+        },
+      });
     },
 
-    getTrace(traceId) {
-      return traceStore.getTrace(traceId);
+    generateRollbackPlaybook(request = {}) {
+      return generateRollbackPlaybook(traceStore, {
+        ...request,
+        basePath: request.basePath ?? basePath,
+        surface: request.surface ?? 'cli',
+      });
     },
 
-    async analyzeTrace(traceId) {
-      const trace = await traceStore.getTrace(traceId);
-      if (trace === undefined) {
-        return undefined;
+    async summarizeHandoff(request = {}) {
+      const [traces, sessions, backlog, git] = await Promise.all([
+        traceStore.listTraces(request.traceLimit ?? 200),
+        stateStore.listSessions(),
+        stateStore.listMemory(TODO_BACKLOG_NAMESPACE),
+        getGitStatus(request.basePath ?? basePath).catch(() => undefined),
+      ]);
+      const uncommittedFiles = git === undefined
+        ? []
+        : [...new Set([...git.staged, ...git.unstaged].map((file) => file.path).concat(git.untracked))];
+      const handoff: RuntimeHandoffResponse = buildOnCallHandoff({
+        traces,
+        sessions,
+        backlog,
+        uncommittedFiles,
+        windowHours: request.windowHours ?? 24,
+      });
+      if (request.outputPath !== undefined) {
+        await mkdir(dirname(request.outputPath), { recursive: true });
+        await writeFile(request.outputPath, handoff.markdown, 'utf8');
+        handoff.artifactPath = request.outputPath;
       }
-
-      return analyzeTraceRecord(trace);
+      return handoff;
     },
 
-    async getTraceTree(traceId) {
-      const traces = await traceStore.listTraces();
-      return buildTraceTree(traces, traceId);
+    listRubrics(request) {
+      return loadRubrics(request?.basePath ?? basePath);
     },
 
-    listTraces(limit) {
-      return traceStore.listTraces(limit);
+    async evaluateRubric(request) {
+      const rubrics = await loadRubrics(request.basePath ?? basePath);
+      const rubric = rubrics.find((entry) => entry.rubricId === request.rubricId);
+      if (rubric === undefined) {
+        throw new Error(`Rubric not found: ${request.rubricId}`);
+      }
+      return evaluateRubric(rubric, { output: request.output, changedFiles: request.changedFiles });
     },
 
-    closeStuckTraces(maxAgeMs) {
-      return traceStore.closeStuckTraces(maxAgeMs);
+    listBenchTasks(request) {
+      return loadBenchTasks(request?.basePath ?? basePath);
     },
 
-    async listTracesBySession(sessionId, limit) {
-      const traces = await traceStore.listTraces();
-      const filtered = traces.filter((trace) => trace.metadata?.sessionId === sessionId);
-      return limit === undefined ? filtered : filtered.slice(0, limit);
+    runBench(request = {}) {
+      const benchBasePath = request.basePath ?? basePath;
+      return runBench({
+        basePath: benchBasePath,
+        taskIds: request.taskIds,
+        execute: async (task) => {
+          const response = await this.callProvider({
+            prompt: task.prompt,
+            systemPrompt: task.systemPrompt,
+            provider: task.provider ?? request.provider,
+            sessionId: request.sessionId,
+            basePath: benchBasePath,
+            surface: request.surface ?? 'cli',
+          });
+          if (!response.success) {
+            throw new Error(response.error?.message ?? `Provider ${response.provider} failed`);
+          }
+          return { content: response.content, traceId: response.traceId, latencyMs: response.latencyMs };
+        },
+        evaluate: (rubricId, output) => this.evaluateRubric({ rubricId, output, basePath: benchBasePath }),
+      });
     },
 
-    storeMemory(entry) {
-      return stateStore.storeMemory(entry);
+    async runExperiment(request) {
+      const experimentBasePath = request.basePath ?? basePath;
+      const guidance = resolveExperimentStrategies((await readWorkspaceConfig(experimentBasePath)).experiment);
+      const variants = planExperimentVariants({
+        count: request.variants,
+        strategies: request.strategies,
+        providers: request.providers,
+        model: request.model,
+        guidance,
+      });
+      const definitionOfDone = await resolveDefinitionOfDone({
+        basePath: experimentBasePath,
+        workflowId: 'experiment',
+        input: request.done !== undefined ? { done: request.done } : undefined,
+      });
+      const record = await runExperiment({
+        basePath: experimentBasePath,
+        task: request.task,
+        variants,
+        guidance,
+        criteria: definitionOfDone?.criteria ?? [],
+        files: request.files,
+        execute: async (variant, prompt) => {
+          const pack = request.files !== undefined && request.files.length > 0
+            ? await packFiles({ basePath: experimentBasePath, files: request.files, provider: variant.provider })
+            : undefined;
+          const response = await this.callProvider({
+            prompt: pack !== undefined ? `${prompt}\n\n${pack.text}` : prompt,
+            systemPrompt: pack !== undefined ? FILE_PACK_ANCHOR_INSTRUCTIONS : undefined,
+            provider: variant.provider,
+            model: variant.model,
+            sessionId: request.sessionId,
+            basePath: experimentBasePath,
+            surface: request.surface ?? 'cli',
+          });
+          if (!response.success) {
+            throw new Error(response.error?.message ?? `Provider ${response.provider} failed`);
+          }
+          return { content: response.content, latencyMs: response.latencyMs, tokens: response.usage?.totalTokens, traceId: response.traceId };
+        },
+      });
+      await saveExperiment(experimentBasePath, record);
+      return record;
     },
 
-    getMemory(key, namespace) {
-      return stateStore.getMemory(key, namespace);
+    listExperiments(request = {}) {
+      return listExperiments(request.basePath ?? basePath);
     },
 
-    searchMemory(query, namespace) {
-      return stateStore.searchMemory(query, namespace);
+    getExperiment(request) {
+      return readExperiment(request.basePath ?? basePath, request.experimentId);
     },
 
-    deleteMemory(key, namespace) {
-      return stateStore.deleteMemory(key, namespace);
+    async pickExperimentVariant(request) {
+      const experimentBasePath = request.basePath ?? basePath;
+      const experiment = await readExperiment(experimentBasePath, request.experimentId);
+      const variantId = request.variantId ?? experiment.recommended;
+      const variant = experiment.variants.find((entry) => entry.variantId === variantId);
+      if (variant === undefined) {
+        throw new Error(variantId === undefined
+          ? `Experiment ${experiment.experimentId} has no variant whose diff applied; pass one of ${experiment.variants.map((entry) => entry.variantId).join(', ')}.`
+          : `Experiment ${experiment.experimentId} has no variant ${variantId}.`);
+      }
+      const files = parseUnifiedDiff(variant.patch);
+      if (files.length === 0) {
+        throw new Error(`Variant ${variant.variantId} has no diff to apply${variant.error !== undefined ? `: ${variant.error}` : '.'}`);
+      }
+      const patch = await this.reviewPatch({
+        patch: variant.patch,
+        decisions: files.flatMap((file) => file.hunks.map((hunk) => ({ path: file.path, hunkIndex: hunk.index, decision: 'accept' as const }))),
+        task: experiment.task,
+        sessionId: request.sessionId,
+        basePath: experimentBasePath,
+        surface: request.surface,
+      });
+      const picked = { ...experiment, picked: { variantId: variant.variantId, at: new Date().toISOString() } };
+      await saveExperiment(experimentBasePath, picked);
+      return { experiment: picked, variantId: variant.variantId, patch };
     },
 
-    listMemory(namespace) {
-      return stateStore.listMemory(namespace);
+    approveBench(request = {}) {
+      return approveBenchOutputs(request.basePath ?? basePath, request.taskIds);
     },
 
-    storeSemantic(entry) {
-      return stateStore.storeSemantic(entry);
+    async getTelemetryStatus(request = {}) {
+      const telemetryBasePath = request.basePath ?? basePath;
+      const config = await readWorkspaceConfig(telemetryBasePath);
+      return {
+        ...resolveTelemetryConfig(config.telemetry),
+        lastSentAt: await readLastSentAt(telemetryBasePath),
+      };
     },
 
-    searchSemantic(query, options) {
-      return stateStore.searchSemantic(query, options);
+    async previewTelemetry(request = {}) {
+      const telemetryBasePath = request.basePath ?? basePath;
+      return buildTelemetryReport(await traceStore.listTraces(), {
+        installId: await readInstallId(telemetryBasePath),
+        windowDays: request.windowDays,
+      });
     },
 
-    getSemantic(key, namespace) {
-      return stateStore.getSemantic(key, namespace);
+    async sendTelemetry(request = {}) {
+      const telemetryBasePath = request.basePath ?? basePath;
+      const config = resolveTelemetryConfig((await readWorkspaceConfig(telemetryBasePath)).telemetry);
+      if (!config.enabled) {
+        throw new Error('Telemetry is disabled. Run "ax telemetry enable" to opt in.');
+      }
+      const endpoint = request.endpoint ?? config.endpoint;
+      if (endpoint === undefined) {
+        throw new Error('No telemetry endpoint configured. Set telemetry.endpoint to your collector URL.');
+      }
+      const report = await this.previewTelemetry({ windowDays: request.windowDays, basePath: telemetryBasePath });
+      await sendTelemetryReport(report, endpoint);
+      const sentAt = new Date().toISOString();
+      await recordSentReport(telemetryBasePath, report, endpoint, sentAt);
+      return { endpoint, sentAt, report };
     },
 
-    listSemantic(options) {
-      return stateStore.listSemantic(options);
+    startTelemetryCollector(request = {}) {
+      return startTelemetryCollector({
+        port: request.port,
+        host: request.host,
+        outputPath: request.outputPath ?? join(request.basePath ?? basePath, TELEMETRY_DIR, 'collected.jsonl'),
+      });
     },
 
-    deleteSemantic(key, namespace) {
-      return stateStore.deleteSemantic(key, namespace);
+    async recordCrash(request) {
+      const crashBasePath = request.basePath ?? basePath;
+      // The crash may itself come from a broken config or trace store, so
+      // collect what is readable and never let the reporter throw.
+      const config = await readWorkspaceConfig(crashBasePath).catch(() => ({}));
+      const traces = await traceStore.listTraces(CRASH_ACTIVITY_LIMIT).catch(() => []);
+      const bundle = buildCrashBundle({
+        error: request.error,
+        surface: request.surface ?? 'cli',
+        command: request.command,
+        version: request.version,
+        basePath: crashBasePath,
+        config,
+        traces,
+      });
+      return { bundlePath: await writeCrashBundle(crashBasePath, bundle), bundle };
     },
 
-    clearSemantic(namespace) {
-      return stateStore.clearSemantic(namespace);
+    async prepareBugReport(request = {}) {
+      const bugBasePath = request.basePath ?? basePath;
+      const report = await readCrashBundle(bugBasePath, request.bundlePath);
+      if (report === undefined) {
+        throw new Error('No crash reports found in .automatosx/crash-reports.');
+      }
+      const bugReport = renderBugReport(report);
+      if (request.submit !== true) {
+        return bugReport;
+      }
+      return { ...bugReport, submittedIssueUrl: await submitBugReport(bugBasePath, bugReport) };
     },
 
-    semanticStats(namespace) {
-      return stateStore.semanticStats(namespace);
+    migrateWorkspace(request = {}) {
+      const migrationBasePath = request.basePath ?? basePath;
+      return migrateWorkspace({
+        basePath: migrationBasePath,
+        workflowDir: resolveWorkflowDir(request.workflowDir, request.basePath, basePath),
+        dryRun: request.dryRun,
+        registerAgent: (entry) => stateStore.registerAgent(entry),
+      });
     },
 
-    submitFeedback(entry) {
-      return stateStore.submitFeedback(entry);
+    createBackup(request = {}) {
+      return createBackup({
+        basePath: request.basePath ?? basePath,
+        outputPath: request.outputPath,
+        productVersion: CURRENT_PRODUCT_VERSION,
+      });
     },
 
-    listFeedbackHistory(options) {
-      return stateStore.listFeedback(options);
+    verifyBackup(request) {
+      return verifyBackup(request.archivePath);
     },
 
-    async getFeedbackStats(agentId) {
-      const entries = await stateStore.listFeedback({ agentId });
-      return buildFeedbackStats(agentId, entries);
+    restoreBackup(request) {
+      return restoreBackup({
+        basePath: request.basePath ?? basePath,
+        archivePath: request.archivePath,
+        productVersion: CURRENT_PRODUCT_VERSION,
+      });
     },
 
-    async getFeedbackOverview() {
-      const entries = await stateStore.listFeedback();
-      return buildFeedbackOverview(entries);
+    async getSyncStatus(request = {}) {
+      const syncBasePath = request.basePath ?? basePath;
+      const config = resolveSyncConfig((await readWorkspaceConfig(syncBasePath)).sync);
+      return {
+        configured: config !== undefined,
+        remote: config === undefined ? undefined : describeSyncRemote(config.remote),
+        passphraseEnv: config?.passphraseEnv,
+        passphraseSet: config === undefined ? false : (process.env[config.passphraseEnv] ?? '').length > 0,
+        privacy: config?.privacy?.mode,
+        lastSyncedAt: await readLastSyncedAt(syncBasePath),
+      };
     },
 
-    async getFeedbackAdjustments(agentId) {
-      const entries = await stateStore.listFeedback({ agentId });
+    async syncState(request = {}) {
+      const syncBasePath = request.basePath ?? basePath;
+      const config = resolveSyncConfig((await readWorkspaceConfig(syncBasePath)).sync);
+      if (config === undefined) {
+        throw new Error('No sync remote configured. Set sync.remote to {"type":"git","url":...} or {"type":"s3","bucket":...}.');
+      }
+      const passphrase = process.env[config.passphraseEnv];
+      if (passphrase === undefined || passphrase.length === 0) {
+        throw new Error(`Set ${config.passphraseEnv} to the sync passphrase; state is never uploaded unencrypted.`);
+      }
+      return syncState({
+        basePath: syncBasePath,
+        config,
+        passphrase,
+        state: {
+          listMemory: () => stateStore.listMemory(),
+          storeMemory: (entry) => stateStore.storeMemory(entry),
+          deleteMemory: (key, namespace) => stateStore.deleteMemory(key, namespace),
+        },
+      });
+    },
+
+    async exportMemory(request = {}) {
+      const exported = await exportMemoryBundle({
+        basePath: request.basePath ?? basePath,
+        state: stateStore,
+        outputPath: request.outputPath,
+        namespace: request.namespace,
+        encryptionKey: memoryEncryptionKey,
+      });
+      await auditMemory({ action: 'read', operation: 'memory.export', namespace: request.namespace, count: exported.counts.memory + exported.counts.semantic });
+      return exported;
+    },
+
+    async importMemory(request) {
+      const imported = await importMemoryBundle({ ...request, state: stateStore, encryptionKey: memoryEncryptionKey });
+      if (!imported.dryRun) {
+        await auditMemory({ action: 'write', operation: 'memory.import', namespace: request.namespace, count: imported.imported.memory + imported.imported.semantic });
+      }
+      return imported;
+    },
+
+    async importConversations(request = {}) {
+      const namespace = request.namespace ?? CONVERSATION_IMPORT_NAMESPACE;
+      const dryRun = request.dryRun === true;
+      const history = await readConversationHistory({ basePath, sources: request.sources, since: request.since, homeDir: request.homeDir });
+      const stored: SemanticEntry[] = [];
+      const sources: ConversationImportSourceSummary[] = [];
+      for (const { source, sessions, exchanges } of history) {
+        let imported = 0;
+        let skipped = 0;
+        for (const exchange of exchanges) {
+          const key = conversationMemoryKey(exchange);
+          if (await stateStore.getSemantic(key, namespace) !== undefined) {
+            skipped += 1;
+            continue;
+          }
+          imported += 1;
+          if (dryRun) {
+            continue;
+          }
+          stored.push(await stateStore.storeSemantic({
+            key,
+            namespace,
+            content: conversationMemoryContent(exchange),
+            tags: ['conversation', source],
+            metadata: {
+              source,
+              sessionId: exchange.sessionId,
+              messageId: exchange.messageId,
+              ...(exchange.at !== undefined ? { at: exchange.at } : {}),
+              ...(exchange.model !== undefined ? { model: exchange.model } : {}),
+              file: exchange.file,
+            },
+          }));
+        }
+        sources.push({ source, sessions, exchanges: exchanges.length, imported, skipped });
+      }
+      if (stored.length > 0) {
+        await auditMemory({ action: 'write', operation: 'memory.import', namespace, keys: memoryAuditRefs(stored), count: stored.length });
+        await indexEmbeddingsInBackground(stored);
+      }
+      return {
+        namespace,
+        dryRun,
+        imported: sources.reduce((total, source) => total + source.imported, 0),
+        skipped: sources.reduce((total, source) => total + source.skipped, 0),
+        sources,
+      };
+    },
+
+    async pruneMemory(request = {}) {
+      const pruneBasePath = request.basePath ?? basePath;
+      const config = resolveMemoryRetentionConfig((await readWorkspaceConfig(pruneBasePath)).memory);
+      const response = await pruneMemoryNow(pruneBasePath, config, (policy, now) => stateStore.pruneMemory(policy, now));
+      const owners = [...await stateStore.listMemory(), ...await stateStore.listSemantic()]
+        .flatMap((entry) => entry.agentId !== undefined ? [entry.agentId] : []);
+      const quotas = await enforceMemoryQuotas(config.quotas, owners, (agentId, quota, now) => stateStore.enforceMemoryQuota(agentId, quota, now));
+      return quotas.length > 0 ? { ...response, quotas } : response;
+    },
+
+    async fetchEmbeddingModel(request = {}) {
+      const fetchBasePath = request.basePath ?? basePath;
+      const semantic = (await readWorkspaceConfig(fetchBasePath)).semantic;
+      const embeddings = isRecord(semantic) && isRecord(semantic.embeddings) && semantic.embeddings.provider !== undefined ? semantic.embeddings : { provider: 'local' };
+      const config = resolveEmbeddingConfig(embeddings, fetchBasePath);
+      if (config === undefined) {
+        throw new Error('semantic.embeddings uses term vectors, so there is no model to fetch');
+      }
+      await fetchEmbeddingModel(config);
+      return { model: config.model, modelsDir: config.modelsDir };
+    },
+
+    dedupeMemory(request = {}) {
+      return dedupeMemory({
+        basePath: request.basePath ?? basePath,
+        state: stateStore,
+        namespace: request.namespace,
+        threshold: request.threshold,
+        dryRun: request.dryRun,
+      });
+    },
+
+    async compactMemory(request = {}) {
+      const compactionBasePath = request.basePath ?? basePath;
+      const config = resolveMemoryCompactionConfig((await readWorkspaceConfig(compactionBasePath)).memory);
+      return compactMemory({
+        basePath: compactionBasePath,
+        state: stateStore,
+        config: {
+          ...config,
+          ...(request.minAgeDays !== undefined ? { minAgeDays: request.minAgeDays } : {}),
+          ...(request.minClusterSize !== undefined ? { minClusterSize: request.minClusterSize } : {}),
+          ...(request.similarity !== undefined ? { similarity: request.similarity } : {}),
+        },
+        namespace: request.namespace,
+        dryRun: request.dryRun,
+      });
+    },
+
+    async snapshotMemory(request = {}) {
+      const snapshotBasePath = request.basePath ?? basePath;
+      const config = resolveMemorySnapshotConfig((await readWorkspaceConfig(snapshotBasePath)).memory);
+      return takeMemorySnapshot({ basePath: snapshotBasePath, state: stateStore, keep: config.keep, encryptionKey: memoryEncryptionKey });
+    },
+
+    listMemorySnapshots(request = {}) {
+      return listMemorySnapshots(request.basePath ?? basePath);
+    },
+
+    async restoreMemory(request) {
+      const restored = await restoreMemorySnapshot({
+        basePath: request.basePath ?? basePath,
+        state: stateStore,
+        snapshotId: request.snapshotId,
+        dryRun: request.dryRun,
+        encryptionKey: memoryEncryptionKey,
+      });
+      if (!restored.dryRun) {
+        await auditMemory({ action: 'write', operation: 'memory.restore', count: restored.restored.memory + restored.restored.semantic });
+      }
+      return restored;
+    },
+
+    async memoryFacets(request = {}) {
+      const { namespace, limit, ...filter } = request;
+      const normalized = normalizeMemoryFilter(filter);
+      const entries = [...await stateStore.listMemory(namespace), ...await stateStore.listSemantic({ namespace })];
+      return countMemoryFacets(entries.filter((entry) => matchesMemoryFilter(entry, normalized)), limit);
+    },
+
+    async recordMemoryFeedback(request) {
+      const kind = request.kind ?? 'memory';
+      const entry = kind === 'semantic'
+        ? await stateStore.getSemantic(request.key, request.namespace)
+        : await stateStore.getMemory(request.key, request.namespace);
+      if (entry === undefined) {
+        throw new Error(`Memory entry not found: ${request.namespace !== undefined ? `${request.namespace}/` : ''}${request.key}`);
+      }
+      return appendMemoryFeedback(
+        basePath,
+        { kind, scope: await currentMemoryScope(), namespace: request.namespace, key: request.key },
+        { helpful: request.helpful, query: request.query, agentId: request.agentId },
+      );
+    },
+
+    async queryMemoryAudit(filter = {}) {
+      return queryMemoryAudit(basePath, resolveMemoryAuditConfig((await readWorkspaceConfig(basePath)).memory).enabled, filter);
+    },
+
+    async queryMemoryRedactions(filter = {}) {
+      return querySecretRedactions(basePath, resolveSecretRedactionConfig((await readWorkspaceConfig(basePath)).memory).enabled, filter);
+    },
+
+    async queryKnowledgeGraph(request) {
+      const graph = buildKnowledgeGraph({
+        memory: await stateStore.listMemory(request.namespace),
+        semantic: await stateStore.listSemantic({ namespace: request.namespace }),
+        sessions: await stateStore.listSessions(),
+      });
+      return traverseKnowledgeGraph(graph, request.query, { depth: request.depth, limit: request.limit });
+    },
+
+    async askQuestion(request) {
+      const askBasePath = request.basePath ?? basePath;
+      const context = await buildAskContext({
+        basePath: askBasePath,
+        question: request.question,
+        memory: await stateStore.listMemory(),
+      });
+      const linkTemplate = resolveEditorLinkTemplate((await readWorkspaceConfig(askBasePath)).editor);
+      if (linkTemplate !== undefined) {
+        for (const source of context.sources) {
+          if (source.file !== undefined && source.line !== undefined) {
+            source.link = buildEditorLink(linkTemplate, askBasePath, { file: source.file, line: source.line });
+          }
+        }
+      }
+      // Plain provider call: no tools are exposed, so the session cannot write anything.
+      const response = await this.callProvider({
+        prompt: context.prompt,
+        systemPrompt: ASK_SYSTEM_PROMPT,
+        provider: request.provider,
+        maxTokens: request.maxTokens ?? DEFAULT_ASK_MAX_TOKENS,
+        temperature: 0,
+        sessionId: request.sessionId,
+        basePath: askBasePath,
+        surface: request.surface ?? 'cli',
+      });
+      return {
+        question: request.question,
+        answer: response.content,
+        success: response.success,
+        provider: response.provider,
+        traceId: response.traceId,
+        latencyMs: response.latencyMs,
+        sources: context.sources,
+        warnings: response.warnings,
+        error: response.error?.message,
+      };
+    },
+
+    async clarifyTask(request) {
+      const clarifyBasePath = request.basePath ?? basePath;
+      const config = resolveClarificationConfig((await readWorkspaceConfig(clarifyBasePath)).clarification);
+      return clarifyTask({
+        basePath: clarifyBasePath,
+        task: request.task,
+        goal: request.goal,
+        files: request.files,
+        memory: await stateStore.listMemory(),
+        config: {
+          ...config,
+          ...(request.threshold !== undefined ? { threshold: request.threshold } : {}),
+          ...(request.maxQuestions !== undefined ? { maxQuestions: request.maxQuestions } : {}),
+          ...(request.autoAnswer !== undefined ? { autoAnswer: request.autoAnswer } : {}),
+        },
+      });
+    },
+
+    parsePatch(request) {
+      return parseUnifiedDiff(request.patch);
+    },
+
+    async reviewPatch(request) {
+      const patchBasePath = request.basePath ?? basePath;
+      const traceId = randomUUID();
+      const startedAt = new Date().toISOString();
+      const patchConfig = (await readWorkspaceConfig(patchBasePath)).patch;
+      const strategies = request.strategies ?? resolvePatchStrategies(patchConfig);
+      const preserveStyle = request.preserveStyle ?? resolvePreserveStyle(patchConfig);
+      const configuredPolicy = resolveMinimalDiffPolicy(patchConfig);
+      const minimalDiff = request.minimalDiff === false
+        ? undefined
+        : request.minimalDiff === true ? configuredPolicy ?? DEFAULT_MINIMAL_DIFF_POLICY : configuredPolicy;
+      const before = new Map<string, Buffer | undefined>();
+      if (request.sessionId !== undefined) {
+        for (const file of parseUnifiedDiff(request.patch)) {
+          before.set(file.path, await readFileForRecording(patchBasePath, file.path));
+        }
+      }
+      let result;
+      try {
+        const patterns = await loadForbiddenPatterns(patchBasePath);
+        result = await applyPatchReview({
+          basePath: patchBasePath,
+          patch: request.patch,
+          decisions: request.decisions,
+          strategies,
+          preserveStyle,
+          minimalDiff,
+          forbiddenPatterns: patterns.length > 0 ? { patterns, runQuery: resolveQueryRunner(patchBasePath) } : undefined,
+        });
+      } catch (error) {
+        await traceStore.upsertTrace({
+          traceId,
+          workflowId: 'patch.apply',
+          surface: request.surface ?? 'cli',
+          status: 'failed',
+          startedAt,
+          completedAt: new Date().toISOString(),
+          input: { strategies, minimalDiff },
+          stepResults: [],
+          error: { code: 'PATCH_APPLY_FAILED', message: error instanceof Error ? error.message : String(error) },
+          metadata: { sessionId: request.sessionId, agentId: request.agentId, command: 'apply' },
+        });
+        throw error;
+      }
+      const strategyCounts: Record<string, number> = {};
+      for (const entry of result.strategies) {
+        strategyCounts[entry.strategy] = (strategyCounts[entry.strategy] ?? 0) + 1;
+      }
+      await traceStore.upsertTrace({
+        traceId,
+        workflowId: 'patch.apply',
+        surface: request.surface ?? 'cli',
+        status: 'completed',
+        startedAt,
+        completedAt: new Date().toISOString(),
+        input: { strategies, minimalDiff },
+        stepResults: [],
+        output: {
+          appliedFiles: result.applied.length,
+          rejectedHunks: result.rejected.length,
+          bouncedHunks: result.rejected.filter((entry) => entry.bounced === true).length,
+          editedHunks: result.edited.length,
+          restyledFiles: result.restyled.length,
+          strategyCounts,
+        },
+        metadata: { sessionId: request.sessionId, agentId: request.agentId, command: 'apply' },
+      });
+      for (const file of result.applied) {
+        await recordSession(patchBasePath, request.sessionId, async (workspacePath, sessionId) => appendFileChangeEvent(workspacePath, sessionId, {
+          traceId,
+          path: file.path,
+          before: before.get(file.path),
+          after: file.deleted === true ? undefined : await readFileForRecording(patchBasePath, file.path),
+        }));
+      }
+      const hunksByKey = new Map(parseUnifiedDiff(request.patch)
+        .flatMap((file) => file.hunks.map((hunk) => [`${file.path}#${hunk.index}`, hunk] as const)));
+      const feedbackIds: string[] = [];
+      for (const rejection of result.rejected) {
+        const hunk = hunksByKey.get(`${rejection.path}#${rejection.hunkIndex}`);
+        const entry = await stateStore.submitFeedback({
+          selectedAgent: request.agentId ?? 'unknown',
+          feedbackType: HUNK_REJECTED_FEEDBACK_TYPE,
+          taskDescription: request.task ?? `Proposed change to ${rejection.path}`,
+          userComment: rejection.reason,
+          outcome: 'rejected',
+          sessionId: request.sessionId,
+          metadata: {
+            path: rejection.path,
+            hunkIndex: rejection.hunkIndex,
+            header: hunk?.header,
+            lines: hunk?.lines,
+          },
+        });
+        feedbackIds.push(entry.feedbackId);
+      }
+      return { traceId, ...result, feedbackIds };
+    },
+
+    conformToFileStyle(request) {
+      return conformToFileStyle(request.content, request.original, request.path);
+    },
+
+    async auditDependencies(request = {}) {
+      return auditGoDependencies({
+        basePath: request.basePath ?? basePath,
+        checkUpdates: request.checkUpdates,
+      });
+    },
+
+    async structuralDiff(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return collectStructuralDiff({
+        basePath: request.basePath ?? basePath,
+        base: request.base ?? 'HEAD',
+        head: request.head,
+        paths: request.paths,
+      });
+    },
+
+    async apiDiff(request = {}) {
+      return collectApiDiff({
+        basePath: request.basePath ?? basePath,
+        base: request.base ?? 'HEAD',
+        head: request.head,
+        paths: request.paths,
+      });
+    },
+
+    async takeSnapshot(request = {}) {
+      const snapshotBasePath = request.basePath ?? basePath;
+      const config = resolveSnapshotConfig((await readWorkspaceConfig(snapshotBasePath)).snapshots);
+      return takeSnapshot({
+        basePath: snapshotBasePath,
+        sessionId: request.sessionId ?? 'manual',
+        label: request.label,
+        maxFileBytes: config.maxFileBytes,
+      });
+    },
+
+    async listSnapshots(request = {}) {
+      return listSnapshots({ basePath: request.basePath ?? basePath, sessionId: request.sessionId });
+    },
+
+    async getSnapshot(request) {
+      return readSnapshot({ basePath: request.basePath ?? basePath, snapshotId: request.snapshotId });
+    },
+
+    async readSnapshotFile(request) {
+      return readSnapshotFile({ basePath: request.basePath ?? basePath, snapshotId: request.snapshotId, path: request.path });
+    },
+
+    async checkoutSnapshot(request) {
+      return checkoutSnapshot({
+        basePath: request.basePath ?? basePath,
+        snapshotId: request.snapshotId,
+        targetDir: request.targetDir,
+        paths: request.paths,
+      });
+    },
+
+    async listTechDebt(request = {}) {
+      return harvestTechDebt({
+        basePath: request.basePath ?? basePath,
+        paths: request.paths,
+        kinds: request.kinds,
+        owner: request.owner,
+        blame: request.blame,
+        limit: request.limit,
+      });
+    },
+
+    async listTodos(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return listTodos({ ...request, basePath: request.basePath ?? basePath, state: stateStore });
+    },
+
+    async linkTodos(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return linkTodos({ ...request, basePath: request.basePath ?? basePath, state: stateStore });
+    },
+
+    async findSymbols(request) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return findSymbols({
+        basePath: request.basePath ?? basePath,
+        query: request.query,
+        paths: request.paths,
+        limit: request.limit,
+      });
+    },
+
+    async getSymbolMap(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      const summary = await summarizeSymbols({ basePath: request.basePath ?? basePath, paths: request.paths });
+      const ranked = await rankSymbols({ basePath: request.basePath ?? basePath, paths: request.paths, limit: SYMBOL_MAP_CENTRAL_LIMIT });
+      return { ...summary, central: ranked.symbols };
+    },
+
+    async rankSymbols(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return rankSymbols({ basePath: request.basePath ?? basePath, paths: request.paths, limit: request.limit });
+    },
+
+    async getIndexProgress(request = {}) {
+      return readIndexProgress(request.basePath ?? basePath);
+    },
+
+    async getCodeMetrics(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return collectCodeMetrics({ ...request, basePath: request.basePath ?? basePath });
+    },
+
+    async findDuplicates(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return findDuplicates({ ...request, basePath: request.basePath ?? basePath });
+    },
+
+    async findDeadCode(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return findDeadCode({
+        basePath: request.basePath ?? basePath,
+        paths: request.paths,
+        includeExported: request.includeExported,
+        limit: request.limit,
+      });
+    },
+
+    async startPrCommandServer(request) {
+      const serverBasePath = request.basePath ?? basePath;
+      const config = await readWorkspaceConfig(serverBasePath);
+      return startPrCommandServer({
+        config: resolvePrCommandConfig(config.prCommands),
+        rules: resolveAutomationRules(config.automationRules),
+        secret: request.secret,
+        port: request.port,
+        host: request.host,
+        onOutcome: request.onOutcome,
+        dependencies: {
+          runWorkflow: ({ workflowId, input }) => this.runWorkflow({ workflowId, input, basePath: serverBasePath, surface: 'cli' }),
+          postComment: createGitHubCommentPoster({ token: request.token, apiUrl: request.apiUrl }),
+        },
+      });
+    },
+
+    async analyzeRenameImpact(request) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return analyzeRenameImpact({
+        basePath: request.basePath ?? basePath,
+        symbol: request.symbol,
+        paths: request.paths,
+        limit: request.limit,
+      });
+    },
+
+    async findDefinition(request) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return findDefinition({
+        basePath: request.basePath ?? basePath,
+        symbol: request.symbol,
+        path: request.path,
+        line: request.line,
+        column: request.column,
+        paths: request.paths,
+      });
+    },
+
+    async findReferences(request) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return findReferences({
+        basePath: request.basePath ?? basePath,
+        symbol: request.symbol,
+        path: request.path,
+        line: request.line,
+        column: request.column,
+        paths: request.paths,
+        includeDefinitions: request.includeDefinitions,
+        limit: request.limit,
+      });
+    },
+
+    async getCallGraph(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return buildCallGraph({
+        basePath: request.basePath ?? basePath,
+        symbol: request.symbol,
+        direction: request.direction,
+        depth: request.depth,
+        paths: request.paths,
+        limit: request.limit,
+      });
+    },
+
+    async findImplementations(request) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return findImplementations({
+        basePath: request.basePath ?? basePath,
+        symbol: request.symbol,
+        paths: request.paths,
+      });
+    },
+
+    async mapGoTests(request = {}) {
+      return mapGoTests({
+        basePath: request.basePath ?? basePath,
+        paths: request.paths,
+      });
+    },
+
+    async exportLsif(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return exportLsif({
+        basePath: request.basePath ?? basePath,
+        paths: request.paths,
+        outputPath: request.outputPath,
+      });
+    },
+
+    async buildIncludeGraph(request = {}) {
+      return buildIncludeGraph({
+        basePath: request.basePath ?? basePath,
+        paths: request.paths,
+        includeDirs: request.includeDirs,
+      });
+    },
+
+    async buildImportGraph(request = {}) {
+      return buildImportGraph({
+        basePath: request.basePath ?? basePath,
+        paths: request.paths,
+      });
+    },
+
+    async findGoEmbeds(request = {}) {
+      return findGoEmbeds({
+        basePath: request.basePath ?? basePath,
+        paths: request.paths,
+        files: request.files,
+      });
+    },
+
+    async createParserFixture(request) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return createParserFixture({
+        basePath: request.basePath ?? basePath,
+        path: request.path,
+        name: request.name,
+        outputDir: request.outputDir,
+        redact: request.redact,
+      });
+    },
+
+    async checkParserFixtures(request = {}) {
+      await ensureExtractorPlugins(request.basePath ?? basePath);
+      return checkParserFixtures({
+        basePath: request.basePath ?? basePath,
+        dir: request.dir,
+      });
+    },
+
+    async loadExtractorPlugins(request = {}) {
+      return ensureExtractorPlugins(request.basePath ?? basePath);
+    },
+
+    async previewTemplates(request) {
+      const previewBasePath = request.basePath ?? basePath;
+      if (request.agentId !== undefined) {
+        const agent = await stateStore.getAgent(request.agentId);
+        if (agent === undefined) {
+          throw new Error(`Agent "${request.agentId}" is not registered.`);
+        }
+        const metadata = isRecord(agent.metadata) ? agent.metadata : {};
+        const task = resolveAgentTask(request.task, request.input, agent);
+        return {
+          kind: 'agent',
+          id: agent.agentId,
+          templates: renderTemplatePreviews(
+            [{ field: 'systemPrompt', template: resolveAgentSystemPrompt(agent, metadata) }],
+            await collectAgentTemplateVariables(previewBasePath, agent, task, request.input),
+          ),
+        };
+      }
+      if (request.workflowId === undefined) {
+        throw new Error('previewTemplates requires an agentId or a workflowId');
+      }
+      const workflowDir = resolveWorkflowDir(request.workflowDir, request.basePath, basePath);
+      const workflow = await createWorkflowLoader({ workflowsDir: workflowDir }).load(request.workflowId);
+      if (workflow === undefined) {
+        throw new Error(`Workflow "${request.workflowId}" not found`);
+      }
+      return {
+        kind: 'workflow',
+        id: request.workflowId,
+        templates: renderTemplatePreviews(
+          listStepTemplates(workflow.steps),
+          {
+            ...await collectTemplateVariables({ basePath: previewBasePath, input: request.input }),
+            ...await collectTodoVariables(previewBasePath, request.input, stateStore, false),
+            ...await collectMetricsVariables(previewBasePath, request.input),
+            ...await collectDuplicatesVariables(previewBasePath, request.input),
+          },
+        ),
+        definitionOfDone: await resolveDefinitionOfDone({
+          basePath: previewBasePath,
+          workflowId: request.workflowId,
+          input: request.input,
+          workflowDone: workflow.metadata?.done,
+        }),
+      };
+    },
+
+    async getConfig(path) {
+      const config = await readWorkspaceConfig(basePath);
+      if (path === undefined || path.length === 0) {
+        return config;
+      }
+      return getValueAtPath(config, path);
+    },
+
+    showConfig() {
+      return readWorkspaceConfig(basePath);
+    },
+
+    async setConfig(path, value) {
+      const config = await readWorkspaceConfig(basePath);
+      setValueAtPath(config, path, value);
+      await writeWorkspaceConfig(basePath, config);
+      return config;
+    },
+
+    getTrace(traceId) {
+      return traceStore.getTrace(traceId);
+    },
+
+    async analyzeTrace(traceId) {
+      const trace = await traceStore.getTrace(traceId);
+      if (trace === undefined) {
+        return undefined;
+      }
+
+      return analyzeTraceRecord(trace);
+    },
+
+    async getTraceTree(traceId) {
+      const traces = await traceStore.listTraces();
+      return buildTraceTree(traces, traceId);
+    },
+
+    listTraces(limit) {
+      return traceStore.listTraces(limit);
+    },
+
+    closeStuckTraces(maxAgeMs) {
+      return traceStore.closeStuckTraces(maxAgeMs);
+    },
+
+    async listTracesBySession(sessionId, limit) {
+      const traces = await traceStore.listTraces();
+      const filtered = traces.filter((trace) => trace.metadata?.sessionId === sessionId);
+      return limit === undefined ? filtered : filtered.slice(0, limit);
+    },
+
+    async storeMemory(entry) {
+      const stored = await stateStore.storeMemory(entry);
+      await auditMemory({ action: 'write', operation: 'memory.store', namespace: stored.namespace, key: stored.key, count: 1 }, stored.agentId);
+      await pruneMemoryInBackground(basePath, stateStore, stored.agentId);
+      await compactMemoryInBackground(basePath, stateStore);
+      await snapshotMemoryInBackground(basePath, stateStore, memoryEncryptionKey);
+      return stored;
+    },
+
+    async getMemory(key, namespace) {
+      const entry = await stateStore.getMemory(key, namespace);
+      await auditMemory({ action: 'read', operation: 'memory.get', namespace, key, count: entry === undefined ? 0 : 1 });
+      return entry;
+    },
+
+    async searchMemory(query, namespace, filter) {
+      const normalized = normalizeMemoryFilter(filter);
+      const matches = await stateStore.searchMemory(query, namespace);
+      const filtered = isMemoryFilterEmpty(normalized) ? matches : matches.filter((entry) => matchesMemoryFilter(entry, normalized));
+      const results = rerankByFeedback(filtered, await readMemoryFeedback(basePath), 'memory', await currentMemoryScope(), query);
+      await auditMemory({ action: 'read', operation: 'memory.search', namespace, query, keys: memoryAuditRefs(results), count: results.length });
+      return results;
+    },
+
+    async searchMemoryPage(query, namespace, filter, page = {}) {
+      const normalized = normalizeMemoryFilter(filter);
+      const matches = await stateStore.searchMemory(query, namespace, { limit: Number.POSITIVE_INFINITY });
+      const filtered = isMemoryFilterEmpty(normalized) ? matches : matches.filter((entry) => matchesMemoryFilter(entry, normalized));
+      const results = rerankByFeedback(filtered, await readMemoryFeedback(basePath), 'memory', await currentMemoryScope(), query);
+      const { items, ...position } = paginate(results, page, { query, namespace, filter: normalized }, (entry) => `${entry.namespace ?? ''}\0${entry.key}\0${entry.updatedAt}`);
+      await auditMemory({ action: 'read', operation: 'memory.search', namespace, query, keys: memoryAuditRefs(items), count: items.length });
+      return { entries: items, ...position };
+    },
+
+    async deleteMemory(key, namespace) {
+      const deleted = await stateStore.deleteMemory(key, namespace);
+      await auditMemory({ action: 'delete', operation: 'memory.delete', namespace, key, count: deleted ? 1 : 0 });
+      return deleted;
+    },
+
+    async listMemory(namespace) {
+      const entries = await stateStore.listMemory(namespace);
+      await auditMemory({ action: 'read', operation: 'memory.list', namespace, keys: memoryAuditRefs(entries), count: entries.length });
+      return entries;
+    },
+
+    async memoryAsOf(asOf, options = {}) {
+      const snapshot = await stateStore.memoryAsOf(asOf, options);
+      const entries = [...snapshot.memory, ...snapshot.semantic];
+      await auditMemory({ action: 'read', operation: 'memory.as_of', namespace: options.namespace, key: options.key, keys: memoryAuditRefs(entries), count: entries.length });
+      return snapshot;
+    },
+
+    async storeSemantic(entry) {
+      const stored = await stateStore.storeSemantic(entry);
+      await auditMemory({ action: 'write', operation: 'semantic.store', namespace: stored.namespace, key: stored.key, count: 1 }, stored.agentId);
+      await indexEmbeddingsInBackground([stored]);
+      await pruneMemoryInBackground(basePath, stateStore, stored.agentId);
+      await compactMemoryInBackground(basePath, stateStore);
+      await snapshotMemoryInBackground(basePath, stateStore, memoryEncryptionKey);
+      return stored;
+    },
+
+    async storeSemanticFile(entry) {
+      await ensureExtractorPlugins(entry.basePath ?? basePath);
+      const config = await readWorkspaceConfig(entry.basePath ?? basePath);
+      const chunking = resolveChunkingConfig(isRecord(config.semantic) ? config.semantic.chunking : undefined);
+      const { strategy, chunks } = chunkCode(
+        entry.content,
+        entry.path,
+        entry.chunking === undefined ? chunking : { ...chunking, default: entry.chunking, languages: {} },
+      );
+      const key = entry.key ?? entry.path;
+      // An `.axignore`d file stores nothing, and any chunks stored before it was ignored are removed below.
+      const fileBasePath = entry.basePath ?? basePath;
+      const ignored = isAxIgnored(await loadAxIgnore(fileBasePath), relative(fileBasePath, resolve(fileBasePath, entry.path)).split(sep).join('/'), entry.content);
+      const stored = ignored ? [] : chunks.map((chunk) => ({ ...chunk, key: strategy === 'whole' ? key : `${key}#${chunk.name}` }));
+      const generated = detectGeneratedCode(entry.path, entry.content);
+
+      // Chunks of an earlier version whose symbol is gone would keep matching searches.
+      const current = new Set(stored.map((chunk) => chunk.key));
+      const previous = (await stateStore.listSemantic({ namespace: entry.namespace, keyPrefix: key }))
+        .filter((item) => item.namespace === entry.namespace && (item.key === key || item.key.startsWith(`${key}#`)));
+      const removed: string[] = [];
+      for (const item of previous) {
+        if (!current.has(item.key)) {
+          await stateStore.deleteSemantic(item.key, entry.namespace);
+          removed.push(item.key);
+        }
+      }
+
+      const storedChunks: SemanticEntry[] = [];
+      for (const chunk of stored) {
+        storedChunks.push(await stateStore.storeSemantic({
+          key: chunk.key,
+          namespace: entry.namespace,
+          content: chunk.content,
+          tags: generated !== undefined ? [...entry.tags ?? [], GENERATED_TAG] : entry.tags,
+          metadata: {
+            ...entry.metadata,
+            path: entry.path,
+            ...(generated !== undefined ? { generated: generated.kind } : {}),
+            symbol: chunk.name,
+            kind: chunk.kind,
+            startLine: chunk.startLine,
+            endLine: chunk.endLine,
+            chunking: strategy,
+          },
+          ttlMs: entry.ttlMs,
+          agentId: entry.agentId,
+          importance: entry.importance,
+        }));
+      }
+      await indexEmbeddingsInBackground(storedChunks);
+      await auditMemory({
+        action: 'write',
+        operation: 'semantic.store',
+        namespace: entry.namespace,
+        key,
+        keys: memoryAuditRefs(stored.map((chunk) => ({ key: chunk.key, namespace: entry.namespace }))),
+        count: stored.length,
+      }, entry.agentId);
+      if (removed.length > 0) {
+        await auditMemory({
+          action: 'delete',
+          operation: 'semantic.delete',
+          namespace: entry.namespace,
+          key,
+          keys: memoryAuditRefs(removed.map((chunkKey) => ({ key: chunkKey, namespace: entry.namespace }))),
+          count: removed.length,
+        }, entry.agentId);
+      }
+      await pruneMemoryInBackground(basePath, stateStore, entry.agentId);
+      await compactMemoryInBackground(basePath, stateStore);
+      await snapshotMemoryInBackground(basePath, stateStore, memoryEncryptionKey);
+      return {
+        key,
+        path: entry.path,
+        strategy,
+        chunks: stored.map(({ key: chunkKey, name, kind, startLine, endLine }) => ({ key: chunkKey, name, kind, startLine, endLine })),
+        removed,
+        ...(ignored ? { ignored: true } : {}),
+        ...(generated !== undefined && !ignored ? { generated: generated.kind } : {}),
+      };
+    },
+
+    async indexDocComments(request = {}) {
+      const fileBasePath = request.basePath ?? basePath;
+      await ensureExtractorPlugins(fileBasePath);
+      const namespace = request.namespace ?? DOC_NAMESPACE;
+      const files = await loadReferenceIndex(fileBasePath, request.paths);
+      const ignore = await loadAxIgnore(fileBasePath);
+      const entries = collectDocEntries(files).filter((entry) => !isAxIgnored(ignore, entry.path, files.get(entry.path)!.lines.join('\n')));
+
+      // Without paths the whole workspace was scanned, so entries for deleted files go too.
+      const current = new Set(entries.map((entry) => entry.key));
+      const removed: string[] = [];
+      for (const item of await stateStore.listSemantic({ namespace, filterTags: [DOC_TAG] })) {
+        const path = item.key.slice(0, item.key.indexOf('#'));
+        if (item.namespace === namespace && !current.has(item.key) && (request.paths === undefined || files.has(path))) {
+          await stateStore.deleteSemantic(item.key, namespace);
+          removed.push(item.key);
+        }
+      }
+
+      const stored: SemanticEntry[] = [];
+      for (const entry of entries) {
+        stored.push(await stateStore.storeSemantic({
+          key: entry.key,
+          namespace,
+          content: docEntryContent(entry),
+          tags: [DOC_TAG],
+          metadata: {
+            path: entry.path,
+            symbol: entry.symbol,
+            kind: entry.kind,
+            startLine: entry.line,
+            endLine: entry.endLine,
+          },
+          agentId: request.agentId,
+        }));
+      }
+      await indexEmbeddingsInBackground(stored);
+      if (stored.length > 0) {
+        await auditMemory({
+          action: 'write',
+          operation: 'semantic.index_docs',
+          namespace,
+          keys: memoryAuditRefs(stored.map((entry) => ({ key: entry.key, namespace }))),
+          count: stored.length,
+        }, request.agentId);
+      }
+      if (removed.length > 0) {
+        await auditMemory({
+          action: 'delete',
+          operation: 'semantic.delete',
+          namespace,
+          keys: memoryAuditRefs(removed.map((key) => ({ key, namespace }))),
+          count: removed.length,
+        }, request.agentId);
+      }
+      await pruneMemoryInBackground(basePath, stateStore, request.agentId);
+      return {
+        namespace,
+        indexed: entries.map(({ key, symbol, kind, path, line }) => ({ key, symbol, kind, path, line })),
+        removed,
+        scannedFiles: files.size,
+      };
+    },
+
+    async searchSemantic(query, options = {}) {
+      const { agentId, since, until, path, includeGenerated, ...searchOptions } = options;
+      const audited = async (found: SemanticSearchResult[]): Promise<SemanticSearchResult[]> => {
+        await auditMemory({ action: 'read', operation: 'semantic.search', namespace: searchOptions.namespace, query, keys: memoryAuditRefs(found), count: found.length });
+        return found;
+      };
+      const filter = normalizeMemoryFilter({ agentId, since, until, path });
+      // Generated files stay out of context unless asked for, or searched for by their tag.
+      const hideGenerated = includeGenerated !== true && !(searchOptions.filterTags ?? []).some((tag) => tag.trim().toLowerCase() === GENERATED_TAG);
+      const feedback = await readMemoryFeedback(basePath);
+      const scope = await currentMemoryScope();
+      const postFilter = hideGenerated || hasMemoryFeedback(feedback, 'semantic', scope) || !isMemoryFilterEmpty(filter);
+      const config = await readWorkspaceConfig(basePath);
+      const search = resolveSemanticSearchConfig(isRecord(config.semantic) ? config.semantic.search : undefined);
+      const rankOptions: SemanticSearchOptions = {
+        ...searchOptions,
+        mode: searchOptions.mode ?? search.mode,
+        keywordWeight: searchOptions.keywordWeight ?? search.keywordWeight,
+        // Filtered-out entries would take topK slots, and feedback can reorder results, so it is applied afterwards.
+        ...(postFilter ? { topK: undefined } : {}),
+      };
+      const embeddings = rankOptions.mode !== 'keyword' ? await resolveEmbeddingProvider() : undefined;
+      // Only an unscoped search of every namespace sees all entries, so only it prunes stale vectors.
+      const prune = scope === undefined && searchOptions.namespace === undefined && (searchOptions.filterTags ?? []).length === 0;
+      const results = embeddings !== undefined
+        ? await searchSemanticByEmbedding(query, rankOptions, embeddings, prune)
+        : await stateStore.searchSemantic(query, rankOptions);
+      if (!postFilter) {
+        return audited(results);
+      }
+      const filtered = results
+        .filter((entry) => !(hideGenerated && entry.tags.includes(GENERATED_TAG)) && matchesMemoryFilter(entry, filter))
+        .map((entry) => entry.tags.includes(GENERATED_TAG) ? { ...entry, score: entry.score * GENERATED_SCORE_WEIGHT } : entry)
+        .sort((left, right) => right.score - left.score);
+      const reranked = rerankByFeedback(filtered, feedback, 'semantic', scope, query);
+      return audited(searchOptions.topK === undefined ? reranked : reranked.slice(0, Math.max(0, searchOptions.topK)));
+    },
+
+    async getSemantic(key, namespace) {
+      const entry = await stateStore.getSemantic(key, namespace);
+      await auditMemory({ action: 'read', operation: 'semantic.get', namespace, key, count: entry === undefined ? 0 : 1 });
+      return entry;
+    },
+
+    async listSemantic(options) {
+      const entries = await stateStore.listSemantic(options);
+      await auditMemory({ action: 'read', operation: 'semantic.list', namespace: options?.namespace, keys: memoryAuditRefs(entries), count: entries.length });
+      return entries;
+    },
+
+    async deleteSemantic(key, namespace) {
+      const deleted = await stateStore.deleteSemantic(key, namespace);
+      await auditMemory({ action: 'delete', operation: 'semantic.delete', namespace, key, count: deleted ? 1 : 0 });
+      return deleted;
+    },
+
+    async clearSemantic(namespace) {
+      const cleared = await stateStore.clearSemantic(namespace);
+      await auditMemory({ action: 'delete', operation: 'semantic.clear', namespace, count: cleared });
+      return cleared;
+    },
+
+    semanticStats(namespace) {
+      return stateStore.semanticStats(namespace);
+    },
+
+    submitFeedback(entry) {
+      return stateStore.submitFeedback(entry);
+    },
+
+    listFeedbackHistory(options) {
+      return stateStore.listFeedback(options);
+    },
+
+    async getFeedbackStats(agentId) {
+      const entries = await stateStore.listFeedback({ agentId });
+      return buildFeedbackStats(agentId, entries);
+    },
+
+    async getFeedbackOverview() {
+      const entries = await stateStore.listFeedback();
+      return buildFeedbackOverview(entries);
+    },
+
+    async getFeedbackAdjustments(agentId) {
+      const entries = await stateStore.listFeedback({ agentId });
       return buildFeedbackAdjustment(agentId, entries);
     },
 
@@ -2056,9 +4282,83 @@ export function createSharedRuntimeService(config: SharedRuntimeConfig = {}): Sh
       return stateStore.closeStuckSessions(maxAgeMs);
     },
 
+    getSessionRecording(request) {
+      return readSessionRecording(request.basePath ?? basePath, request.sessionId);
+    },
+
+    recordSessionToolCall(request) {
+      return recordSessionEvent(request.basePath, request.sessionId, {
+        kind: 'tool',
+        source: 'mcp',
+        tool: request.tool,
+        args: request.args,
+        success: request.success,
+        ...(request.error !== undefined ? { error: request.error } : {}),
+        durationMs: request.durationMs,
+      });
+    },
+
+    async replaySession(request) {
+      const replayBasePath = request.basePath ?? basePath;
+      const events = await readSessionRecording(replayBasePath, request.sessionId);
+      if (!events.some((event) => event.kind === 'operation')) {
+        throw new Error(`Session ${request.sessionId} has no recorded runs to replay. Runs are recorded when given a session id.`);
+      }
+      const redaction = resolveSecretRedactionConfig((await readWorkspaceConfig(basePath)).memory);
+      return replayRecordedSession({
+        basePath: replayBasePath,
+        sessionId: request.sessionId,
+        events,
+        to: request.to,
+        keepSandbox: request.keepSandbox,
+        redact: (value) => redactSecrets(value, redaction).value,
+        createRunner: (sandbox, replayBridge) => {
+          const replay = createSharedRuntimeService({
+            basePath: sandbox,
+            providerBridge: replayBridge,
+            memoryScope: config.memoryScope,
+            queryRunner: config.queryRunner,
+          });
+          return (event) => rerunSessionOperation(replay, event, { sessionId: request.sessionId, basePath: sandbox, recordedBasePath: replayBasePath });
+        },
+      });
+    },
+
+    async collectGarbage(request = {}) {
+      const gcBasePath = request.basePath ?? basePath;
+      const config = (await readWorkspaceConfig(gcBasePath)).gc;
+      const maxAgeDays = request.maxAgeDays ?? (isRecord(config) && typeof config.maxAgeDays === 'number' ? config.maxAgeDays : DEFAULT_GC_MAX_AGE_DAYS);
+      const branchPrefix = request.branchPrefix ?? (isRecord(config) && typeof config.branchPrefix === 'string' ? config.branchPrefix : DEFAULT_GC_BRANCH_PREFIX);
+      const dryRun = request.dryRun === true;
+      const now = new Date();
+      const report = await collectWorkspaceGarbage({ basePath: gcBasePath, maxAgeDays, branchPrefix, force: request.force, dryRun, tmpDir: request.tmpDir, now });
+      const sessions = findStaleSessions(await stateStore.listSessions(), maxAgeDays, now);
+      if (!dryRun && sessions.length > 0) {
+        await stateStore.closeStuckSessions(maxAgeDays * 86_400_000);
+      }
+      return { ...report, dryRun, maxAgeDays, branchPrefix, sessions };
+    },
+
     getStores() {
       return { traceStore, stateStore };
     },
+
+    withMemoryScope(scope) {
+      return createSharedRuntimeService({ ...config, basePath, traceStore: baseTraceStore, stateStore: baseStateStore, memoryScope: scope });
+    },
+
+    withMemoryActor(actor) {
+      return createSharedRuntimeService({ ...config, basePath, traceStore: baseTraceStore, stateStore: baseStateStore, memoryActor: actor });
+    },
+  };
+
+  // Each recorded run is wrapped here rather than in its method so nested calls through `this` are recorded too.
+  return {
+    ...service,
+    callProvider: (request) => recordRun('call', request, (traceId) => service.callProvider({ ...request, traceId })),
+    runAgent: (request) => recordRun('agent', request, (traceId) => service.runAgent({ ...request, traceId })),
+    runWorkflow: (request) => recordRun('workflow', request, (traceId) => service.runWorkflow({ ...request, traceId })),
+    reviewPatch: (request) => recordRun('patch', request, () => service.reviewPatch(request)),
   };
 }
 
@@ -2142,14 +4442,16 @@ async function reviewPullRequest(request: {
   base?: string;
   head?: string;
 }): Promise<RuntimePrReviewResponse> {
+  await ensureExtractorPlugins(request.basePath);
   const base = request.base ?? 'main';
   const head = request.head ?? 'HEAD';
   const diffRange = `${base}...${head}`;
 
-  const [diffStatResult, filesResult, commitsResult] = await Promise.all([
+  const [diffStatResult, filesResult, commitsResult, structural] = await Promise.all([
     execGit(request.basePath, ['diff', '--stat', diffRange]),
     execGit(request.basePath, ['diff', '--name-only', diffRange]),
     execGit(request.basePath, ['log', '--oneline', `${base}..${head}`]),
+    collectStructuralDiff({ basePath: request.basePath, base, head }),
   ]);
 
   const changedFiles = filesResult.stdout.split('\n').map((line) => line.trim()).filter((line) => line.length > 0);
@@ -2162,9 +4464,19 @@ async function reviewPullRequest(request: {
     changedFiles,
     diffStat: diffStatResult.stdout,
     summary: `${changedFiles.length} changed file${changedFiles.length === 1 ? '' : 's'} across ${commits.length} commit${commits.length === 1 ? '' : 's'} from ${base} to ${head}.`,
+    structuralChanges: structural.files,
+    structuralSummary: structural.summary,
   };
 }
 
+// The default PR body leads with the file/commit counts, then lists declaration-level changes.
+async function buildPullRequestBody(basePath: string, base: string, head: string): Promise<string> {
+  const review = await reviewPullRequest({ basePath, base, head });
+  return review.structuralChanges.length > 0
+    ? `${review.summary}\n\n${review.structuralSummary}`
+    : review.summary;
+}
+
 async function createPullRequest(request: {
   basePath: string;
   title: string;
@@ -2175,11 +4487,7 @@ async function createPullRequest(request: {
 }): Promise<RuntimePrCreateResponse> {
   const base = request.base ?? 'main';
   const head = request.head ?? 'HEAD';
-  const body = request.body ?? (await reviewPullRequest({
-    basePath: request.basePath,
-    base,
-    head,
-  })).summary;
+  const body = request.body ?? await buildPullRequestBody(request.basePath, base, head);
   const command = ['pr', 'create', '--title', request.title, '--body', body, '--base', base, '--head', head];
   if (request.draft === true) {
     command.push('--draft');
@@ -2506,6 +4814,20 @@ function resolveAgentTask(
   return `Run the ${agent.name} agent.`;
 }
 
+// Agent system prompts also see `agent.id`, `agent.name`, and the resolved `task`.
+async function collectAgentTemplateVariables(
+  basePath: string,
+  agent: AgentEntry,
+  task: string,
+  input: Record<string, unknown> | undefined,
+): Promise<Record<string, unknown>> {
+  return {
+    ...(await collectTemplateVariables({ basePath, input })),
+    agent: { id: agent.agentId, name: agent.name },
+    task,
+  };
+}
+
 function resolveAgentSystemPrompt(agent: AgentEntry, metadata: Record<string, unknown>): string {
   const explicit = asOptionalString(metadata.systemPrompt) ?? asOptionalString(metadata.instructions);
   if (explicit !== undefined && explicit.trim().length > 0) {
@@ -2523,6 +4845,7 @@ function buildAgentPrompt(
   task: string,
   input: Record<string, unknown> | undefined,
   metadata: Record<string, unknown>,
+  rejectedChanges?: string,
 ): string {
   const team = asOptionalString(metadata.team);
   const sections = [
@@ -2531,6 +4854,7 @@ function buildAgentPrompt(
     agent.capabilities.length > 0 ? `Capabilities: ${agent.capabilities.join(', ')}` : undefined,
     team !== undefined ? `Team: ${team}` : undefined,
     input !== undefined ? `Input:\n${JSON.stringify(input, null, 2)}` : undefined,
+    rejectedChanges,
   ];
 
   return sections.filter((value): value is string => value !== undefined && value.length > 0).join('\n\n');
@@ -2909,6 +5233,7 @@ function resolveTraceDuration(trace: TraceRecord): number {
 
 function createPromptExecutor(
   providerBridge: ReturnType<typeof createProviderBridge>,
+  basePath: string,
   provider?: string,
   model?: string,
 ) {
@@ -2922,9 +5247,16 @@ function createPromptExecutor(
       maxTokens?: number;
       temperature?: number;
       timeout?: number;
+      latencySensitive?: boolean;
     }) => {
-      const resolvedProvider = request.provider ?? provider ?? 'claude';
-      const bridgeResult = await providerBridge.executePrompt({
+      let resolvedProvider = request.provider ?? provider ?? 'claude';
+      let hedging: HedgingConfig | undefined;
+      if (request.latencySensitive === true) {
+        const routing = (await readWorkspaceConfig(basePath)).routing;
+        resolvedProvider = await routeByLatency(basePath, resolvedProvider, resolveLatencyRoutingConfig(routing));
+        hedging = resolveHedgingConfig(routing);
+      }
+      const providerRequest = {
         provider: resolvedProvider,
         prompt: request.prompt,
         systemPrompt: request.systemPrompt,
@@ -2932,7 +5264,17 @@ function createPromptExecutor(
         maxTokens: request.maxTokens,
         temperature: request.temperature,
         timeoutMs: request.timeout,
-      });
+      };
+      const hedge = hedging?.enabled === true ? await planHedge(basePath, resolvedProvider, hedging) : undefined;
+      const bridgeResult = hedge !== undefined
+        ? (await runHedged({
+          primary: (signal) => providerBridge.executePrompt({ ...providerRequest, signal }),
+          backup: (signal) => providerBridge.executePrompt({ ...providerRequest, provider: hedge.backupProvider, signal }),
+          delayMs: hedge.delayMs,
+          claim: () => claimHedgeBudget(basePath, hedge.maxPerHour),
+          isSuccess: (outcome) => outcome.type === 'response' && outcome.response.success,
+        })).result
+        : await providerBridge.executePrompt(providerRequest);
 
       if (bridgeResult.type === 'response' || bridgeResult.type === 'failure') {
         return bridgeResult.response;
@@ -2958,6 +5300,96 @@ function createPromptExecutor(
   };
 }
 
+// No hedge without a backup provider, or while the primary has too few samples to time it.
+async function planHedge(
+  basePath: string,
+  primary: string,
+  config: HedgingConfig,
+): Promise<{ backupProvider: string; delayMs: number; maxPerHour: number } | undefined> {
+  const backupProvider = config.providers.find((candidate) => candidate !== primary);
+  if (backupProvider === undefined) {
+    return undefined;
+  }
+  const delayMs = config.afterMs ?? await readProviderPercentile(basePath, primary, config.percentile);
+  return delayMs === undefined ? undefined : { backupProvider, delayMs, maxPerHour: config.maxPerHour };
+}
+
+async function readMemoryScope(basePath: string): Promise<string | undefined> {
+  const memory = (await readWorkspaceConfig(basePath)).memory;
+  return isRecord(memory) && typeof memory.scope === 'string' ? memory.scope : undefined;
+}
+
+// Retention and the writing agent's quota run after memory writes; a failed
+// prune never fails the write that triggered it.
+async function pruneMemoryInBackground(basePath: string, stateStore: StateStore, agentId?: string): Promise<void> {
+  try {
+    const config = resolveMemoryRetentionConfig((await readWorkspaceConfig(basePath)).memory);
+    const quota = agentId !== undefined ? memoryQuotaFor(config.quotas, agentId) : undefined;
+    if (agentId !== undefined && quota !== undefined) {
+      await stateStore.enforceMemoryQuota(agentId, quota);
+    }
+    await pruneMemoryIfDue(basePath, config, (policy, now) => stateStore.pruneMemory(policy, now));
+  } catch {
+    // Best effort; the next write tries again.
+  }
+}
+
+// Compaction (`memory.compaction`) runs on the first memory write once its
+// interval has passed, like retention; a failure never fails the write.
+async function compactMemoryInBackground(basePath: string, stateStore: StateStore): Promise<void> {
+  try {
+    const config = resolveMemoryCompactionConfig((await readWorkspaceConfig(basePath)).memory);
+    await compactMemoryIfDue({ basePath, config, state: stateStore });
+  } catch {
+    // Best effort; the next write tries again.
+  }
+}
+
+// Scheduled snapshots (`memory.snapshots.schedule`) are taken on the first
+// memory write after one falls due, the same way retention runs.
+async function snapshotMemoryInBackground(basePath: string, stateStore: StateStore, encryptionKey?: string): Promise<void> {
+  try {
+    const config = resolveMemorySnapshotConfig((await readWorkspaceConfig(basePath)).memory);
+    await snapshotMemoryIfDue({ basePath, config, state: stateStore, encryptionKey });
+  } catch {
+    // Best effort; the next write tries again.
+  }
+}
+
+// Runs a recorded run again under its recorded trace id, so the replay's traces line up with the original's.
+async function rerunSessionOperation(
+  runtime: SharedRuntimeService,
+  event: SessionOperationEvent,
+  target: { sessionId: string; basePath: string; recordedBasePath: string },
+): Promise<{ traceId?: string; success: boolean; error?: string }> {
+  const where = { sessionId: target.sessionId, basePath: target.basePath, surface: 'cli' as const };
+  const common = { ...where, ...(event.traceId !== undefined ? { traceId: event.traceId } : {}) };
+  switch (event.operation) {
+    case 'call': {
+      const result = await runtime.callProvider({ ...event.request as unknown as RuntimeCallRequest, ...common });
+      return { traceId: result.traceId, success: result.success, error: result.error?.message };
+    }
+    case 'agent': {
+      const result = await runtime.runAgent({ ...event.request as unknown as RuntimeAgentRunRequest, ...common });
+      return { traceId: result.traceId, success: result.success, error: result.error?.message };
+    }
+    case 'workflow': {
+      const request = event.request as unknown as RuntimeWorkflowRequest;
+      // Workflows in the workspace are read from the copy.
+      const fromWorkspace = request.workflowDir !== undefined ? relative(target.recordedBasePath, resolve(target.recordedBasePath, request.workflowDir)) : undefined;
+      const workflowDir = fromWorkspace !== undefined && !fromWorkspace.startsWith('..') && !isAbsolute(fromWorkspace)
+        ? join(target.basePath, fromWorkspace)
+        : request.workflowDir;
+      const result = await runtime.runWorkflow({ ...request, ...common, ...(workflowDir !== undefined ? { workflowDir } : {}) });
+      return { traceId: result.traceId, success: result.success, error: result.error?.message };
+    }
+    case 'patch': {
+      const result = await runtime.reviewPatch({ ...event.request as unknown as Parameters<SharedRuntimeService['reviewPatch']>[0], ...where });
+      return { traceId: result.traceId, success: true };
+    }
+  }
+}
+
 function createToolExecutor() {
   return {
     isToolAvailable: (toolName: string) => toolName.trim().length > 0,
@@ -3448,6 +5880,68 @@ function yieldToEventLoop(): Promise<void> {
   });
 }
 
+// Extractors register process-wide, so each workspace's plugins load once however many runtimes share the process.
+const extractorPluginLoads = new Map<string, Promise<ExtractorPluginReport>>();
+
+function ensureExtractorPlugins(basePath: string): Promise<ExtractorPluginReport> {
+  const key = resolve(basePath);
+  let load = extractorPluginLoads.get(key);
+  if (load === undefined) {
+    load = loadExtractorPlugins(key);
+    extractorPluginLoads.set(key, load);
+  }
+  return load;
+}
+
+// `input.todos` scopes a workflow to TODOs, exposed to prompts as `todos.list`,
+// `todos.items`, and `todos.count`. Runs link them to the backlog; previews only read.
+async function collectTodoVariables(
+  basePath: string,
+  input: Record<string, unknown> | undefined,
+  stateStore: StateStore,
+  link: boolean,
+): Promise<Record<string, unknown>> {
+  const scope = resolveTodoScope(input?.todos);
+  if (scope === undefined) {
+    return {};
+  }
+  await ensureExtractorPlugins(basePath);
+  const items = link
+    ? (await linkTodos({ ...scope, basePath, state: stateStore })).items
+    : (await listTodos({ ...scope, basePath, state: stateStore, limit: Number.POSITIVE_INFINITY })).items;
+  return { todos: { count: items.length, items, list: renderTodoList(items) } };
+}
+
+// `input.metrics` points a refactor workflow at the most complex functions,
+// exposed to prompts as `metrics.list`, `metrics.items`, and `metrics.count`.
+async function collectMetricsVariables(
+  basePath: string,
+  input: Record<string, unknown> | undefined,
+): Promise<Record<string, unknown>> {
+  const scope = resolveMetricsScope(input?.metrics);
+  if (scope === undefined) {
+    return {};
+  }
+  await ensureExtractorPlugins(basePath);
+  const { symbols } = await collectCodeMetrics({ ...scope, basePath });
+  return { metrics: { count: symbols.length, items: symbols, list: renderCodeMetrics(symbols) } };
+}
+
+// `input.duplicates` hands a refactor workflow the copies to consolidate into
+// shared helpers, as `duplicates.list`, `duplicates.items`, and `duplicates.count`.
+async function collectDuplicatesVariables(
+  basePath: string,
+  input: Record<string, unknown> | undefined,
+): Promise<Record<string, unknown>> {
+  const scope = resolveDuplicatesScope(input?.duplicates);
+  if (scope === undefined) {
+    return {};
+  }
+  await ensureExtractorPlugins(basePath);
+  const { groups } = await findDuplicates({ ...scope, basePath });
+  return { duplicates: { count: groups.length, items: groups, list: renderDuplicates(groups) } };
+}
+
 async function readWorkspaceConfig(basePath: string): Promise<Record<string, unknown>> {
   const configPath = join(basePath, '.automatosx', 'config.json');
   try {
@@ -3465,6 +5959,21 @@ async function writeWorkspaceConfig(basePath: string, config: Record<string, unk
   await writeFile(configPath, `${JSON.stringify(config, null, 2)}\n`, 'utf8');
 }
 
+// `semantic.search` in config, e.g. `{"mode": "hybrid", "keywordWeight": 0.7}`.
+function resolveSemanticSearchConfig(value: unknown): { mode: SemanticSearchMode; keywordWeight: number } {
+  const config: { mode: SemanticSearchMode; keywordWeight: number } = { mode: 'hybrid', keywordWeight: 0.5 };
+  if (!isRecord(value)) {
+    return config;
+  }
+  if (value.mode === 'vector' || value.mode === 'keyword' || value.mode === 'hybrid') {
+    config.mode = value.mode;
+  }
+  if (typeof value.keywordWeight === 'number' && value.keywordWeight >= 0 && value.keywordWeight <= 1) {
+    config.keywordWeight = value.keywordWeight;
+  }
+  return config;
+}
+
 function getValueAtPath(config: Record<string, unknown>, path: string): unknown {
   const parts = path.split('.').filter((part) => part.length > 0);
   let current: unknown = config;
@@ -3507,4 +6016,399 @@ export type {
   ReviewFocus,
   ReviewSeverity,
   RuntimeReviewResponse,
+  SarifLog,
 } from './review.js';
+export type {
+  RuntimeSloGateResponse,
+  SloGateConfig,
+  SloGateDecision,
+  SloStatus,
+} from './slo-gate.js';
+export type {
+  CanaryBaseline,
+  CanaryConfig,
+  CanaryEndpointSummary,
+  CanaryRollbackResult,
+  CanaryVerdict,
+  RuntimeCanaryResponse,
+} from './canary.js';
+export type {
+  RollbackChangedFile,
+  RollbackPlaybookStep,
+  RollbackStepKind,
+  RuntimeRollbackPlaybookResponse,
+} from './rollback-playbook.js';
+export type {
+  HandoffFollowUp,
+  HandoffIncident,
+  HandoffRiskyChange,
+  HandoffSession,
+  RuntimeHandoffResponse,
+} from './handoff.js';
+export type {
+  Rubric,
+  RubricCriterion,
+  RubricCriterionResult,
+  RubricCriterionStatus,
+  RubricEvaluation,
+} from './rubrics.js';
+export type {
+  BenchResultStatus,
+  BenchTask,
+  BenchTaskResult,
+  RuntimeBenchResponse,
+} from './bench.js';
+export type {
+  RuntimeTelemetrySendResponse,
+  RuntimeTelemetryStatus,
+  TelemetryCollector,
+  TelemetryConfig,
+  TelemetryFeatureUsage,
+  TelemetryLatency,
+  TelemetryReport,
+} from './telemetry.js';
+export type {
+  LatencyPercentile,
+  LatencyRoutingStatus,
+  ProviderLatencySummary,
+} from './provider-latency.js';
+export type { HedgingStatus } from './request-hedging.js';
+export type {
+  CrashBundle,
+  CrashCategory,
+  RuntimeBugReport,
+  RuntimeCrashReport,
+} from './crash-report.js';
+export type {
+  MigrationChange,
+  MigrationTarget,
+  RuntimeMigrationResponse,
+} from './workspace-migration.js';
+export type {
+  BackupFileEntry,
+  BackupManifest,
+  RuntimeBackupResponse,
+  RuntimeRestoreResponse,
+} from './backup.js';
+export type {
+  RuntimeSyncResponse,
+  RuntimeSyncStatus,
+  SyncConflict,
+  SyncRemote,
+} from './state-sync.js';
+export type { SyncPrivacyMode, SyncPrivacyPolicy } from './sync-privacy.js';
+export type {
+  ExperimentRecord,
+  ExperimentVariantResult,
+  ExperimentVariantSpec,
+  ExperimentVariantStatus,
+} from './experiment.js';
+export type {
+  RuntimeSessionReplayResponse,
+  SessionEvent,
+  SessionOperation,
+  SessionRecordingEvent,
+  SessionReplayDivergence,
+} from './session-recording.js';
+export type {
+  MemoryBundleHeader,
+  MemoryBundleRecord,
+  RuntimeMemoryExportResponse,
+  RuntimeMemoryImportResponse,
+} from './memory-bundle.js';
+export type {
+  GcAction,
+  RuntimeGcResponse,
+  StaleBranch,
+  StaleRecording,
+  StaleSandbox,
+  StaleSession,
+  StaleWorktree,
+} from './workspace-gc.js';
+export type {
+  ConversationImportSourceSummary,
+  ConversationSource,
+  RuntimeConversationImportResponse,
+} from './conversation-import.js';
+export type {
+  MemoryQuotaConfig,
+  MemoryRetentionConfig,
+  RuntimeMemoryPruneResponse,
+} from './memory-retention.js';
+export type { MemoryBackendConfig } from './memory-backend.js';
+export type {
+  MemorySnapshotConfig,
+  MemorySnapshotSchedule,
+  MemorySnapshotSummary,
+  RuntimeMemoryRestoreResponse,
+  RuntimeMemorySnapshotResponse,
+} from './memory-snapshots.js';
+export type { MemoryFacetCount, MemoryFilter, RuntimeMemoryFacetsResponse } from './memory-facets.js';
+export type { PageRequest } from './pagination.js';
+export type { MemoryFeedbackKind, RuntimeMemoryFeedbackResponse } from './memory-feedback.js';
+export type { GeneratedCodeKind, GeneratedCodeMatch } from './generated-code.js';
+export type { MemoryEncryptionConfig } from './memory-encryption.js';
+export type {
+  MemoryDuplicate,
+  MemoryDuplicateGroup,
+  RuntimeMemoryDedupResponse,
+} from './memory-dedup.js';
+export type {
+  MemoryCompactionCluster,
+  MemoryCompactionConfig,
+  RuntimeMemoryCompactionResponse,
+} from './memory-compaction.js';
+export type {
+  AskSource,
+  RuntimeAskResponse,
+} from './ask.js';
+export type {
+  MemoryActor,
+  MemoryActorKind,
+  MemoryAuditAction,
+  MemoryAuditActorSummary,
+  MemoryAuditFilter,
+  MemoryAuditOperation,
+  MemoryAuditRecord,
+  RuntimeMemoryAuditResponse,
+} from './memory-audit.js';
+export type {
+  RuntimeSecretRedactionResponse,
+  SecretRedactionFilter,
+  SecretRedactionFinding,
+  SecretRedactionRecord,
+  SecretRedactionTarget,
+} from './secret-redaction.js';
+export type {
+  KnowledgeEdge,
+  KnowledgeNode,
+  KnowledgeNodeKind,
+  KnowledgeRelation,
+  RuntimeKnowledgeGraphResponse,
+} from './knowledge-graph.js';
+export type {
+  ClarificationConfig,
+  ClarifyingGap,
+  ClarifyingQuestion,
+  RuntimeClarificationResponse,
+} from './clarification.js';
+export type {
+  DefinitionOfDone,
+  DefinitionOfDoneSource,
+  DefinitionOfDoneVerification,
+  DoneCriterion,
+  DoneCriterionKind,
+  DoneCriterionResult,
+} from './definition-of-done.js';
+export type {
+  DiffFile,
+  DiffHunk,
+  HunkDecision,
+  HunkDecisionKind,
+  PatchStrategy,
+  RuntimePatchReviewResponse,
+} from './patch-review.js';
+export type {
+  CodeStyle,
+  ConformResult,
+  IndentStyle,
+} from './code-style.js';
+export type {
+  HunkDiffMeasure,
+  MinimalDiffPolicy,
+  MinimalDiffViolation,
+} from './minimal-diff.js';
+export type {
+  ForbiddenPattern,
+  ForbiddenPatternMatch,
+  QueryCapture,
+  TreeSitterQueryRunner,
+} from './forbidden-patterns.js';
+export type { EmbeddingProvider, LocalEmbeddingConfig } from './embeddings.js';
+export type {
+  DependencyFinding,
+  DependencyFindingKind,
+  GoModFile,
+  GoModReplace,
+  GoModRequire,
+  GoWorkFile,
+  RuntimeDependencyAudit,
+} from './go-modules.js';
+export type {
+  Declaration,
+  DeclarationExtractor,
+  DeclarationKind,
+  RuntimeStructuralDiffResponse,
+  StructuralChange,
+  StructuralChangeKind,
+  StructuralFileDiff,
+} from './structural-diff.js';
+export type {
+  RuntimeSnapshotCheckoutResponse,
+  RuntimeSnapshotResponse,
+  SnapshotConfig,
+  SnapshotFileEntry,
+  SnapshotSummary,
+  WorkspaceSnapshot,
+} from './snapshot.js';
+export type {
+  RuntimeTechDebtResponse,
+  TechDebtItem,
+  TechDebtKind,
+} from './tech-debt.js';
+export type {
+  RuntimeTodoLinkResponse,
+  RuntimeTodoResponse,
+  TodoFilter,
+  TodoItem,
+  TodoKind,
+} from './todos.js';
+export type {
+  ChunkingConfig,
+  ChunkingStrategy,
+  CodeChunk,
+  RuntimeSemanticFileResponse,
+} from './code-chunking.js';
+export type {
+  RuntimeSymbolMapResponse,
+  RuntimeSymbolSearchResponse,
+  SymbolMatch,
+  SymbolQueryField,
+  SymbolQueryTerm,
+} from './symbol-query.js';
+export type {
+  CodeMetricsFilter,
+  CodeMetricsSort,
+  CodeMetricsSummary,
+  RuntimeCodeMetricsResponse,
+  SymbolMetrics,
+} from './code-metrics.js';
+export type {
+  DuplicateFilter,
+  DuplicateFragment,
+  DuplicateGroup,
+  DuplicatesSummary,
+  RuntimeDuplicatesResponse,
+} from './code-duplicates.js';
+export type { RuntimeDeadCodeResponse } from './dead-code.js';
+export type {
+  AutomationRule,
+  PrCommandConfig,
+  PrCommandDefinition,
+  PrCommandOutcome,
+  PrCommandServer,
+} from './pr-commands.js';
+export type {
+  RenameImpactKind,
+  RenameImpactLocation,
+  RuntimeRenameImpactResponse,
+} from './rename-impact.js';
+export type {
+  IdentifierReference,
+  ReferenceLocation,
+  RuntimeDefinitionResponse,
+  RuntimeReferencesResponse,
+} from './reference-index.js';
+export type { IndexProgress, ParserPoolConfig } from './parse-pool.js';
+export type { RuntimeLsifExportResponse } from './lsif-export.js';
+export type { RuntimeSymbolRankResponse, SymbolRank } from './symbol-rank.js';
+export type {
+  CallGraphDirection,
+  CallGraphEdge,
+  CallGraphNode,
+  RuntimeCallGraphResponse,
+  UnresolvedCall,
+} from './call-graph.js';
+export type {
+  Implementation,
+  RuntimeImplementationsResponse,
+} from './implementations.js';
+export type {
+  FunctionTests,
+  GoTestKind,
+  RuntimeTestMapResponse,
+  TestFunction,
+  TestTarget,
+} from './test-map.js';
+export type {
+  DocEntry,
+  RuntimeDocIndexResponse,
+} from './doc-index.js';
+export type {
+  IncludeEdge,
+  IncludeGraphNode,
+  RuntimeIncludeGraphResponse,
+} from './include-graph.js';
+export type {
+  ImportGraphNode,
+  PackageImport,
+  RuntimeImportGraphResponse,
+} from './import-graph.js';
+export type {
+  ApiChange,
+  ApiChangeKind,
+  ApiPackageDiff,
+  RuntimeApiDiffResponse,
+} from './api-diff.js';
+export type {
+  GoEmbedDirective,
+  RuntimeGoEmbedResponse,
+} from './go-embeds.js';
+export type {
+  ParserFixtureCheck,
+  ParserFixtureSymbol,
+  RuntimeParserConformanceResponse,
+  RuntimeParserFixtureResponse,
+} from './parser-fixtures.js';
+export type { ExtractorPluginReport } from './extractor-plugins.js';
+export type { RuntimeTemplatePreview, TemplatePreviewEntry } from './prompt-templates.js';
+export type {
+  MemoryAsOf,
+  MemoryEvictionPolicy,
+  MemoryPruneResult,
+  MemoryQuota,
+  MemoryQuotaResult,
+  MemoryRetentionPolicy,
+  SemanticSearchMode,
+  SemanticSearchOptions,
+} from '@defai.digital/state-store';
+export { matchesMemoryFilter, normalizeMemoryFilter } from './memory-facets.js';
+export { detectGeneratedCode, GENERATED_TAG } from './generated-code.js';
+export { readCompositeTools, runCompositeTool } from './composite-tools.js';
+export { formatClarifications } from './clarification.js';
+export { createLocalEmbeddingProvider, fetchEmbeddingModel, resolveEmbeddingConfig } from './embeddings.js';
+export { describeToolForClient, findMcpClientProfile, mcpClientAllowsTool, readMcpClientProfiles } from './mcp-clients.js';
+export {
+  appendMcpToolCall,
+  MCP_TOOL_TUNING_FILE,
+  MCP_TOOL_USAGE_FILE,
+  queryMcpToolUsage,
+  readMcpToolTuning,
+  readMcpToolUsageConfig,
+  writeMcpToolTuning,
+} from './mcp-tool-usage.js';
+export { migrateMemorySchema } from './memory-backend.js';
+export { formatImportGraphDot } from './import-graph.js';
+export { INDEX_PROGRESS_FILE, resolveParserPoolConfig } from './parse-pool.js';
+export { LSIF_EXPORT_FILE } from './lsif-export.js';
+export { redactSecrets, resolveSecretRedactionConfig } from './secret-redaction.js';
+export { filePackFormatFor, packFiles, resolveFileAnchors } from './file-pack.js';
+export { CONVERSATION_SOURCES, isConversationSource } from './conversation-import.js';
+export { DEFAULT_GC_BRANCH_PREFIX, DEFAULT_GC_MAX_AGE_DAYS } from './workspace-gc.js';
+export { DEFAULT_EXPERIMENT_VARIANTS, EXPERIMENT_STRATEGIES, MAX_EXPERIMENT_VARIANTS } from './experiment.js';
+export type {
+  CompositeToolDefinition,
+  CompositeToolResult,
+  CompositeToolStep,
+  CompositeToolStepResult,
+} from './composite-tools.js';
+export type { McpClientProfile } from './mcp-clients.js';
+export type { FileAnchor, FilePack, FilePackFormat, PackedFile } from './file-pack.js';
+export type {
+  McpToolCallOutcome,
+  McpToolCallRecord,
+  McpToolDescriptionSuggestion,
+  McpToolUsageFilter,
+  McpToolUsageSummary,
+  RuntimeMcpToolUsageResponse,
+} from './mcp-tool-usage.js';
@@ -0,0 +1,253 @@
+import type { MemoryEntry, SemanticEntry, SessionEntry } from '@defai.digital/state-store';
+import { memoryEntryPath } from './memory-facets.js';
+
+export const DEFAULT_GRAPH_DEPTH = 2;
+export const MAX_GRAPH_DEPTH = 4;
+export const DEFAULT_GRAPH_LIMIT = 50;
+
+const SUMMARY_CHARS = 160;
+// Identifiers shorter than this are too common in prose to count as mentioning a symbol.
+const MIN_SYMBOL_MENTION = 4;
+const FILE_MENTION = /(?<![\w/.@-])((?:[\w@-][\w@.-]*\/)*[\w@-][\w.-]*\.(?:tsx?|jsx?|mjs|cjs|go|py|rs|java|kt|rb|c|h|cc|cpp|hpp|cs|swift|php|json|ya?ml|toml|md|sql|proto|sh))(?![\w/-])/g;
+const IDENTIFIER = /[A-Za-z_$][\w$]*/g;
+const NODE_KINDS: readonly KnowledgeNodeKind[] = ['file', 'symbol', 'session', 'agent', 'memory', 'semantic'];
+
+export type KnowledgeNodeKind = 'memory' | 'semantic' | 'session' | 'agent' | 'file' | 'symbol';
+
+// Edges point from the memory or session that makes the link to what it links to.
+export type KnowledgeRelation =
+  // An entry or session was written or started by an agent.
+  | 'written-by'
+  // A session had the agent as a participant.
+  | 'participant'
+  // An entry is stored for the file or symbol (`metadata.path`, `metadata.symbol`, or a `path` in its value).
+  | 'about'
+  // The file path or a known symbol name appears in an entry's or session's text.
+  | 'mentions'
+  // An entry records the session it came from (`sessionId` in its value or metadata).
+  | 'from-session'
+  // A symbol is declared in a file.
+  | 'defined-in';
+
+export interface KnowledgeNode {
+  // `<kind>:<ref>`, e.g. `file:src/server.go`, `memory:adr/retry-decision`, `symbol:src/server.go#Serve`.
+  id: string;
+  kind: KnowledgeNodeKind;
+  label: string;
+  updatedAt?: string;
+  // The opening of an entry's content or a session's task.
+  summary?: string;
+}
+
+export interface KnowledgeEdge {
+  from: string;
+  to: string;
+  relation: KnowledgeRelation;
+}
+
+export interface KnowledgeGraph {
+  nodes: Map<string, KnowledgeNode>;
+  edges: KnowledgeEdge[];
+}
+
+export interface RuntimeKnowledgeGraphResponse {
+  query: string;
+  // The nodes the query resolved to; empty when nothing is known about it.
+  start: string[];
+  depth: number;
+  // Nearest first, newest first among nodes at the same distance.
+  nodes: Array<KnowledgeNode & { distance: number }>;
+  // Edges between the returned nodes.
+  edges: KnowledgeEdge[];
+  // More nodes were within `depth` than `limit` allowed.
+  truncated: boolean;
+}
+
+/**
+ * Links memory entries, semantic entries, and sessions to the agents that
+ * wrote them, the sessions they came from, and the files and symbols they are
+ * about or mention. Built from the stores on each call, so it never goes
+ * stale; symbols come from source files indexed with storeSemanticFile.
+ */
+export function buildKnowledgeGraph(sources: { memory: MemoryEntry[]; semantic: SemanticEntry[]; sessions: SessionEntry[] }): KnowledgeGraph {
+  const graph: KnowledgeGraph = { nodes: new Map(), edges: [] };
+  const seenEdges = new Set<string>();
+  const addNode = (node: KnowledgeNode): string => {
+    const existing = graph.nodes.get(node.id);
+    if (existing === undefined || (node.updatedAt !== undefined && (existing.updatedAt ?? '') < node.updatedAt)) {
+      graph.nodes.set(node.id, { ...existing, ...node });
+    }
+    return node.id;
+  };
+  const addEdge = (from: string, to: string, relation: KnowledgeRelation): void => {
+    const id = `${from}\u0000${to}\u0000${relation}`;
+    if (from !== to && !seenEdges.has(id)) {
+      seenEdges.add(id);
+      graph.edges.push({ from, to, relation });
+    }
+  };
+  const fileNode = (path: string): string => addNode({ id: `file:${path}`, kind: 'file', label: path });
+  const agentNode = (agentId: string): string => addNode({ id: `agent:${agentId}`, kind: 'agent', label: agentId });
+  const sessionNode = (sessionId: string): string => addNode({ id: `session:${sessionId}`, kind: 'session', label: sessionId });
+
+  // Symbols first, so entries and sessions written before a file was indexed still link to its symbols.
+  const symbolsByName = new Map<string, string[]>();
+  for (const entry of sources.semantic) {
+    const path = memoryEntryPath(entry);
+    const symbol = entry.metadata?.symbol;
+    const kind = entry.metadata?.kind;
+    if (path === undefined || typeof symbol !== 'string' || kind === 'module' || kind === 'lines') {
+      continue;
+    }
+    const id = addNode({ id: `symbol:${path}#${symbol}`, kind: 'symbol', label: symbol, updatedAt: entry.updatedAt });
+    addEdge(id, fileNode(path), 'defined-in');
+    const name = symbol.split('.').at(-1)!;
+    symbolsByName.set(name, [...(symbolsByName.get(name) ?? []), id]);
+  }
+  const linkMentions = (from: string, text: string): void => {
+    for (const match of text.matchAll(FILE_MENTION)) {
+      addEdge(from, fileNode(normalizePath(match[1]!)), 'mentions');
+    }
+    for (const name of new Set(text.match(IDENTIFIER) ?? [])) {
+      if (name.length >= MIN_SYMBOL_MENTION) {
+        for (const symbol of symbolsByName.get(name) ?? []) {
+          addEdge(from, symbol, 'mentions');
+        }
+      }
+    }
+  };
+
+  for (const entry of [...sources.memory, ...sources.semantic]) {
+    const semantic = 'content' in entry;
+    const ref = entry.namespace !== undefined ? `${entry.namespace}/${entry.key}` : entry.key;
+    const text = semantic ? entry.content : typeof entry.value === 'string' ? entry.value : JSON.stringify(entry.value);
+    const id = addNode({
+      id: `${semantic ? 'semantic' : 'memory'}:${ref}`,
+      kind: semantic ? 'semantic' : 'memory',
+      label: ref,
+      updatedAt: entry.updatedAt,
+      summary: summarize(text),
+    });
+    if (entry.agentId !== undefined) {
+      addEdge(id, agentNode(entry.agentId), 'written-by');
+    }
+    const path = memoryEntryPath(entry);
+    if (path !== undefined) {
+      const symbol = semantic ? entry.metadata?.symbol : undefined;
+      addEdge(id, typeof symbol === 'string' && graph.nodes.has(`symbol:${path}#${symbol}`) ? `symbol:${path}#${symbol}` : fileNode(path), 'about');
+    }
+    const details = semantic ? entry.metadata : isRecord(entry.value) ? entry.value : undefined;
+    if (typeof details?.sessionId === 'string' && details.sessionId.length > 0) {
+      addEdge(id, sessionNode(details.sessionId), 'from-session');
+    }
+    // Indexed source chunks are the code itself; their identifiers are not mentions.
+    if (!semantic || entry.metadata?.chunking === undefined) {
+      linkMentions(id, text);
+    }
+  }
+
+  for (const session of sources.sessions) {
+    const id = addNode({
+      id: `session:${session.sessionId}`,
+      kind: 'session',
+      label: session.sessionId,
+      updatedAt: session.updatedAt,
+      summary: summarize(session.task),
+    });
+    addEdge(id, agentNode(session.initiator), 'written-by');
+    for (const participant of session.participants) {
+      if (participant.agentId !== session.initiator) {
+        addEdge(id, agentNode(participant.agentId), 'participant');
+      }
+    }
+    linkMentions(id, [session.task, session.summary ?? ''].join('\n'));
+  }
+  return graph;
+}
+
+/**
+ * Everything linked to `query` within `depth` hops, following edges either
+ * way. The query is a node id (`session:abc`), or a file path, symbol name,
+ * session id, agent id, or memory key, tried in that order; a directory path
+ * starts from every file under it.
+ */
+export function traverseKnowledgeGraph(
+  graph: KnowledgeGraph,
+  query: string,
+  options: { depth?: number; limit?: number } = {},
+): RuntimeKnowledgeGraphResponse {
+  const depth = options.depth ?? DEFAULT_GRAPH_DEPTH;
+  if (!Number.isInteger(depth) || depth < 1 || depth > MAX_GRAPH_DEPTH) {
+    throw new Error(`Graph depth must be a whole number from 1 to ${MAX_GRAPH_DEPTH}, got ${depth}.`);
+  }
+  const limit = Math.max(1, options.limit ?? DEFAULT_GRAPH_LIMIT);
+  const start = resolveStart(graph, query.trim());
+
+  const neighbors = new Map<string, string[]>();
+  for (const edge of graph.edges) {
+    neighbors.set(edge.from, [...(neighbors.get(edge.from) ?? []), edge.to]);
+    neighbors.set(edge.to, [...(neighbors.get(edge.to) ?? []), edge.from]);
+  }
+  const distances = new Map(start.map((id) => [id, 0]));
+  let frontier = start;
+  for (let distance = 1; distance <= depth && frontier.length > 0; distance += 1) {
+    const next: string[] = [];
+    for (const id of frontier) {
+      for (const neighbor of neighbors.get(id) ?? []) {
+        if (!distances.has(neighbor)) {
+          distances.set(neighbor, distance);
+          next.push(neighbor);
+        }
+      }
+    }
+    frontier = next;
+  }
+
+  const reached = [...distances]
+    .map(([id, distance]) => ({ ...graph.nodes.get(id)!, distance }))
+    .sort((left, right) => left.distance - right.distance
+      || (right.updatedAt ?? '').localeCompare(left.updatedAt ?? '')
+      || left.id.localeCompare(right.id));
+  const nodes = reached.slice(0, limit);
+  const included = new Set(nodes.map((node) => node.id));
+  return {
+    query,
+    start,
+    depth,
+    nodes,
+    edges: graph.edges.filter((edge) => included.has(edge.from) && included.has(edge.to)),
+    truncated: reached.length > nodes.length,
+  };
+}
+
+function resolveStart(graph: KnowledgeGraph, query: string): string[] {
+  if (graph.nodes.has(query)) {
+    return [query];
+  }
+  const path = normalizePath(query);
+  for (const kind of NODE_KINDS) {
+    const ids = kind === 'symbol'
+      ? [...graph.nodes.values()].filter((node) => node.kind === 'symbol' && (node.label === query || node.id === `symbol:${query}`)).map((node) => node.id)
+      : [`${kind}:${kind === 'file' ? path : query}`].filter((id) => graph.nodes.has(id));
+    if (ids.length > 0) {
+      return ids.sort();
+    }
+  }
+  return [...graph.nodes.values()]
+    .filter((node) => node.kind === 'file' && path.length > 0 && node.label.startsWith(`${path}/`))
+    .map((node) => node.id)
+    .sort();
+}
+
+function summarize(text: string): string {
+  const collapsed = text.trim().replace(/\s+/g, ' ');
+  return collapsed.length > SUMMARY_CHARS ? `${collapsed.slice(0, SUMMARY_CHARS - 3)}...` : collapsed;
+}
+
+function normalizePath(path: string): string {
+  return path.trim().replace(/\\/g, '/').replace(/^(\.\/)+/, '').replace(/\/+$/, '');
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
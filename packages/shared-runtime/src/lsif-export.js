@@ -0,0 +1,152 @@
+import { mkdir, writeFile } from 'node:fs/promises';
+import { dirname, join, resolve } from 'node:path';
+import { pathToFileURL } from 'node:url';
+import { loadReferenceIndex, resolveByName } from './reference-index.js';
+import { languageOf } from './symbol-query.js';
+import { CURRENT_PRODUCT_VERSION } from './workspace-migration.js';
+export const LSIF_EXPORT_FILE = join('.automatosx', 'exports', 'dump.lsif');
+// LSIF language identifiers, as editors name them.
+const LANGUAGE_IDS = { ts: 'typescript', js: 'javascript' };
+/**
+ * Writes the parsed symbols and references of the workspace as an LSIF 0.5
+ * dump (JSON lines), for code-intelligence tools and code review systems that
+ * import LSIF. Every declaration becomes a definition with a hover of its
+ * signature and doc comment; exported ones also get an `automatosx` export
+ * moniker (`path:Name`). Uses are linked by name, as `ax_code_references`
+ * finds them: a qualifier matching a receiver wins, then the same file, then
+ * the same directory, and a use still matching several declarations is left
+ * out. SCIP is not written: it is a protobuf format, and the packages carry
+ * no protobuf runtime.
+ */
+export async function exportLsif(request) {
+    const files = await loadReferenceIndex(request.basePath, request.paths);
+    const root = resolve(request.basePath);
+    const elements = [];
+    let nextId = 1;
+    const emit = (element) => {
+        const id = nextId++;
+        elements.push(JSON.stringify({ id, ...element }));
+        return id;
+    };
+    const vertex = (label, fields = {}) => emit({ type: 'vertex', label, ...fields });
+    const edge = (label, outV, inV, fields = {}) => emit({ type: 'edge', label, outV, ...(Array.isArray(inV) ? { inVs: inV } : { inV }), ...fields });
+    vertex('metaData', {
+        version: '0.5.0',
+        projectRoot: pathToFileURL(root).href,
+        positionEncoding: 'utf-16',
+        toolInfo: { name: 'automatosx', version: CURRENT_PRODUCT_VERSION },
+    });
+    const project = vertex('project', { kind: 'workspace' });
+    // One result set per declaration, with its hover and moniker.
+    const declarations = new Map();
+    const byName = new Map();
+    for (const file of files.values()) {
+        for (const symbol of file.declarations) {
+            const key = declarationKey(symbol.path, symbol.line, symbol.name);
+            if (declarations.has(key)) {
+                continue;
+            }
+            const resultSet = vertex('resultSet');
+            const language = languageId(symbol.path);
+            const hover = vertex('hoverResult', {
+                result: { contents: [{ language, value: symbol.signature }, ...(symbol.doc !== undefined ? [symbol.doc] : [])] },
+            });
+            edge('textDocument/hover', resultSet, hover);
+            if (symbol.exported) {
+                const identifier = `${symbol.path}:${symbol.receiver !== undefined ? `${symbol.receiver}.` : ''}${symbol.name}`;
+                edge('moniker', resultSet, vertex('moniker', { scheme: 'automatosx', identifier, kind: 'export', unique: 'workspace' }));
+            }
+            declarations.set(key, { symbol, resultSet, uses: new Map() });
+            byName.set(symbol.name, [...(byName.get(symbol.name) ?? []), symbol]);
+        }
+    }
+    let references = 0;
+    let ambiguous = 0;
+    const documents = [];
+    for (const [path, file] of files) {
+        const document = vertex('document', { uri: pathToFileURL(join(root, path)).href, languageId: languageId(path) });
+        documents.push(document);
+        const ranges = [];
+        const definedHere = new Set();
+        const occurrences = [...file.references.values()].flat().sort((left, right) => left.line - right.line || left.column - right.column);
+        for (const reference of occurrences) {
+            const candidates = byName.get(reference.name);
+            // A Go package clause names the package, not the declaration `package main` would otherwise hit.
+            if (candidates === undefined || (path.endsWith('.go') && /^\s*package\s/.test(file.lines[reference.line - 1] ?? ''))) {
+                continue;
+            }
+            const key = declarationKey(path, reference.line, reference.name);
+            // The first occurrence of a declared name on its declaration line is the definition.
+            if (declarations.has(key) && !definedHere.has(key)) {
+                definedHere.add(key);
+                const entry = declarations.get(key);
+                const range = vertex('range', rangeOf(reference));
+                edge('next', range, entry.resultSet);
+                entry.definition = { document, range };
+                ranges.push(range);
+                continue;
+            }
+            const target = resolveByName(reference, path, candidates);
+            if (target === undefined) {
+                ambiguous += 1;
+                continue;
+            }
+            const entry = declarations.get(declarationKey(target.path, target.line, target.name));
+            const range = vertex('range', rangeOf(reference));
+            edge('next', range, entry.resultSet);
+            entry.uses.set(document, [...(entry.uses.get(document) ?? []), range]);
+            ranges.push(range);
+            references += 1;
+        }
+        if (ranges.length > 0) {
+            edge('contains', document, ranges);
+        }
+    }
+    if (documents.length > 0) {
+        edge('contains', project, documents);
+    }
+    let definitions = 0;
+    for (const entry of declarations.values()) {
+        if (entry.definition === undefined && entry.uses.size === 0) {
+            continue;
+        }
+        const referenceResult = vertex('referenceResult');
+        edge('textDocument/references', entry.resultSet, referenceResult);
+        if (entry.definition !== undefined) {
+            definitions += 1;
+            const definitionResult = vertex('definitionResult');
+            edge('textDocument/definition', entry.resultSet, definitionResult);
+            edge('item', definitionResult, [entry.definition.range], { shard: entry.definition.document });
+            edge('item', referenceResult, [entry.definition.range], { shard: entry.definition.document, property: 'definitions' });
+        }
+        for (const [document, ranges] of entry.uses) {
+            edge('item', referenceResult, ranges, { shard: document, property: 'references' });
+        }
+    }
+    const outputPath = request.outputPath ?? join(request.basePath, LSIF_EXPORT_FILE);
+    const content = `${elements.join('\n')}\n`;
+    await mkdir(dirname(outputPath), { recursive: true });
+    await writeFile(outputPath, content, 'utf8');
+    return {
+        outputPath,
+        documents: documents.length,
+        definitions,
+        references,
+        ambiguous,
+        elements: elements.length,
+        bytes: Buffer.byteLength(content),
+    };
+}
+// LSIF positions are 0-based; reference columns are 1-based.
+function rangeOf(reference) {
+    const line = reference.line - 1;
+    const character = reference.column - 1;
+    return { start: { line, character }, end: { line, character: character + reference.name.length } };
+}
+function languageId(path) {
+    const language = languageOf(path) ?? 'plaintext';
+    return LANGUAGE_IDS[language] ?? language;
+}
+function declarationKey(path, line, name) {
+    return `${path}:${line}:${name}`;
+}
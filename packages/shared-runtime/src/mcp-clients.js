@@ -0,0 +1,153 @@
+import { readFileSync } from 'node:fs';
+import { join } from 'node:path';
+// Applies to clients that no other profile matches.
+export const DEFAULT_MCP_CLIENT_PROFILE = 'default';
+// Day-to-day coding tools, few enough for clients that only use the first 40 tools they are given.
+export const CORE_MCP_TOOLS = [
+    'workflow.run',
+    'workflow.list',
+    'workflow.describe',
+    'trace.get',
+    'trace.list',
+    'agent.list',
+    'agent.run',
+    'agent.recommend',
+    'discuss.run',
+    'discuss.quick',
+    'session.create',
+    'session.get',
+    'session.list',
+    'review.analyze',
+    'memory.retrieve',
+    'memory.search',
+    'memory.store',
+    'memory.list',
+    'semantic.store',
+    'semantic.search',
+    'semantic.get',
+    'git.status',
+    'git.diff',
+    'diff.structural',
+    'code.find_symbols',
+    'code.definition',
+    'code.references',
+    'code.rename_impact',
+    'commit.prepare',
+    'pr.review',
+    'guard.check',
+    'config.get',
+    'task.submit',
+    'task.status',
+    'research.query',
+    'dependency.audit',
+];
+export const BUILTIN_MCP_CLIENT_PROFILES = [
+    { name: 'claude-code', match: ['claude-code'], tools: [], exclude: [], descriptions: {} },
+    { name: 'gemini-cli', match: ['gemini'], tools: CORE_MCP_TOOLS, exclude: [], descriptions: {}, maxDescriptionLength: 300 },
+    { name: 'cursor', match: ['cursor'], tools: CORE_MCP_TOOLS, exclude: [], descriptions: {}, maxDescriptionLength: 300 },
+];
+/**
+ * Reads `mcp.clients` from .automatosx/config.json, ahead of the built-in
+ * profiles. Synchronous because MCP tool lists are built when the surface is
+ * created.
+ */
+export function readMcpClientProfiles(basePath) {
+    let config;
+    try {
+        config = JSON.parse(readFileSync(join(basePath, '.automatosx', 'config.json'), 'utf8'));
+    }
+    catch {
+        return { profiles: [...BUILTIN_MCP_CLIENT_PROFILES], errors: [] };
+    }
+    return parseMcpClientProfiles(isRecord(config) && isRecord(config.mcp) ? config.mcp.clients : undefined);
+}
+// A configured profile named like a built-in one replaces it. Invalid entries are reported in `errors` and left out.
+export function parseMcpClientProfiles(value) {
+    if (value === undefined) {
+        return { profiles: [...BUILTIN_MCP_CLIENT_PROFILES], errors: [] };
+    }
+    if (!isRecord(value)) {
+        return { profiles: [...BUILTIN_MCP_CLIENT_PROFILES], errors: ['mcp.clients must be an object keyed by profile name'] };
+    }
+    const profiles = [];
+    const errors = [];
+    for (const [name, entry] of Object.entries(value)) {
+        try {
+            profiles.push(parseMcpClientProfile(name, entry));
+        }
+        catch (error) {
+            errors.push(error instanceof Error ? error.message : String(error));
+        }
+    }
+    const configured = new Set(Object.keys(value));
+    return { profiles: [...profiles, ...BUILTIN_MCP_CLIENT_PROFILES.filter((profile) => !configured.has(profile.name))], errors };
+}
+// The first profile matching the client's name, else the `default` profile if there is one.
+export function findMcpClientProfile(profiles, clientName) {
+    const name = (clientName ?? '').toLowerCase();
+    return profiles.find((profile) => name.length > 0 && profile.match.some((pattern) => name.includes(pattern.toLowerCase())))
+        ?? profiles.find((profile) => profile.name === DEFAULT_MCP_CLIENT_PROFILE);
+}
+export function mcpClientAllowsTool(profile, toolName) {
+    return (profile.tools.length === 0 || profile.tools.some((pattern) => matchesToolPattern(pattern, toolName)))
+        && !profile.exclude.some((pattern) => matchesToolPattern(pattern, toolName));
+}
+// A long description is cut at the last sentence that fits, so what remains still reads as written.
+export function describeToolForClient(profile, toolName, description) {
+    const text = profile.descriptions[toolName] ?? description;
+    const limit = profile.maxDescriptionLength;
+    if (limit === undefined || text.length <= limit) {
+        return text;
+    }
+    const sentenceEnd = text.slice(0, limit).lastIndexOf('. ');
+    return sentenceEnd > 0 ? text.slice(0, sentenceEnd + 1) : `${text.slice(0, Math.max(0, limit - 3)).trimEnd()}...`;
+}
+function parseMcpClientProfile(name, value) {
+    if (!isRecord(value)) {
+        throw new Error(`MCP client profile ${name} must be an object`);
+    }
+    const match = asStringList(value.match, `MCP client profile ${name} match`);
+    const descriptions = value.descriptions ?? {};
+    if (!isRecord(descriptions) || !Object.values(descriptions).every((entry) => typeof entry === 'string')) {
+        throw new Error(`MCP client profile ${name} descriptions must map tool names to strings`);
+    }
+    return {
+        name,
+        // A profile matches clients named like it unless told otherwise; `default` matches none by name.
+        match: match ?? (name === DEFAULT_MCP_CLIENT_PROFILE ? [] : [name]),
+        tools: asStringList(value.tools, `MCP client profile ${name} tools`) ?? [],
+        exclude: asStringList(value.exclude, `MCP client profile ${name} exclude`) ?? [],
+        descriptions: descriptions,
+        maxDescriptionLength: asPositiveInteger(value.maxDescriptionLength, `MCP client profile ${name} maxDescriptionLength`),
+        maxInputBytes: asPositiveInteger(value.maxInputBytes, `MCP client profile ${name} maxInputBytes`),
+    };
+}
+function matchesToolPattern(pattern, toolName) {
+    if (!pattern.includes('*')) {
+        return pattern === toolName;
+    }
+    const source = pattern.split('*').map((part) => part.replace(/[.+?^${}()|[\]\\]/g, '\\$&')).join('.*');
+    return new RegExp(`^${source}$`).test(toolName);
+}
+function asStringList(value, label) {
+    if (value === undefined) {
+        return undefined;
+    }
+    const list = typeof value === 'string' ? [value] : value;
+    if (!Array.isArray(list) || !list.every((entry) => typeof entry === 'string' && entry.length > 0)) {
+        throw new Error(`${label} must be a string or a list of strings`);
+    }
+    return list;
+}
+function asPositiveInteger(value, label) {
+    if (value === undefined) {
+        return undefined;
+    }
+    if (typeof value !== 'number' || !Number.isInteger(value) || value <= 0) {
+        throw new Error(`${label} must be a positive integer`);
+    }
+    return value;
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,232 @@
+import { readFileSync } from 'node:fs';
+import { appendFile, mkdir, readFile, rename, stat, writeFile } from 'node:fs/promises';
+import { dirname, join } from 'node:path';
+export const MCP_TOOL_USAGE_FILE = join('.automatosx', 'runtime', 'mcp-tool-usage.jsonl');
+// The log rolls over to this file past `maxFileBytes`; reports read both.
+export const MCP_TOOL_USAGE_PREVIOUS_FILE = join('.automatosx', 'runtime', 'mcp-tool-usage.1.jsonl');
+// Descriptions written by `ax mcp usage --tune`, served in place of the built-in ones while the file exists.
+export const MCP_TOOL_TUNING_FILE = join('.automatosx', 'mcp-tool-tuning.json');
+const DEFAULT_MAX_FILE_BYTES = 10 * 1024 * 1024;
+const MAX_ERROR_LENGTH = 200;
+// A tool gets a tuned description once this many of its calls failed schema validation.
+const MIN_INVALID_CALLS = 2;
+// `mcp.usage` in config: `true`, or `{ "enabled": true, "maxFileBytes": 10485760 }`. Off by default.
+export function resolveMcpToolUsageConfig(mcpConfig) {
+    const value = isRecord(mcpConfig) ? mcpConfig.usage : undefined;
+    if (value === true) {
+        return { enabled: true, maxFileBytes: DEFAULT_MAX_FILE_BYTES };
+    }
+    if (!isRecord(value)) {
+        return { enabled: false, maxFileBytes: DEFAULT_MAX_FILE_BYTES };
+    }
+    return {
+        enabled: value.enabled !== false,
+        maxFileBytes: typeof value.maxFileBytes === 'number' && value.maxFileBytes > 0 ? value.maxFileBytes : DEFAULT_MAX_FILE_BYTES,
+    };
+}
+// Synchronous, like readMcpClientProfiles, because it is read when the MCP surface is created.
+export function readMcpToolUsageConfig(basePath) {
+    try {
+        const config = JSON.parse(readFileSync(join(basePath, '.automatosx', 'config.json'), 'utf8'));
+        return resolveMcpToolUsageConfig(isRecord(config) ? config.mcp : undefined);
+    }
+    catch {
+        return resolveMcpToolUsageConfig(undefined);
+    }
+}
+export async function appendMcpToolCall(basePath, config, record, now = new Date()) {
+    const path = join(basePath, MCP_TOOL_USAGE_FILE);
+    await mkdir(dirname(path), { recursive: true });
+    try {
+        if ((await stat(path)).size >= config.maxFileBytes) {
+            await rename(path, join(basePath, MCP_TOOL_USAGE_PREVIOUS_FILE));
+        }
+    }
+    catch {
+        // No log yet.
+    }
+    const line = {
+        at: now.toISOString(),
+        ...record,
+        ...(record.error !== undefined ? { error: record.error.slice(0, MAX_ERROR_LENGTH) } : {}),
+    };
+    await appendFile(path, `${JSON.stringify(line)}\n`, 'utf8');
+}
+/**
+ * Summarizes logged calls per tool and suggests descriptions for tools that
+ * clients keep calling with invalid arguments: the rules they broke, and an
+ * example built from the arguments of calls that succeeded. Suggestions start
+ * from `definitions`, so pass the built-in descriptions, not tuned ones.
+ */
+export async function queryMcpToolUsage(basePath, enabled, definitions, filter = {}) {
+    const records = [
+        ...await readUsageFile(join(basePath, MCP_TOOL_USAGE_PREVIOUS_FILE)),
+        ...await readUsageFile(join(basePath, MCP_TOOL_USAGE_FILE)),
+    ].filter((record) => matchesUsageFilter(record, filter));
+    const byTool = new Map();
+    const unknownTools = new Map();
+    for (const record of records) {
+        if (record.outcome === 'unknown') {
+            unknownTools.set(record.tool, (unknownTools.get(record.tool) ?? 0) + 1);
+            continue;
+        }
+        const calls = byTool.get(record.tool) ?? [];
+        calls.push(record);
+        byTool.set(record.tool, calls);
+    }
+    const tools = [...byTool.entries()]
+        .map(([tool, calls]) => summarizeTool(tool, calls))
+        .sort((left, right) => right.calls - left.calls || left.tool.localeCompare(right.tool));
+    const suggestions = definitions.flatMap((definition) => {
+        const summary = tools.find((entry) => entry.tool === definition.name);
+        const suggestion = summary !== undefined ? suggestDescription(definition, summary, byTool.get(definition.name) ?? []) : undefined;
+        return suggestion !== undefined ? [suggestion] : [];
+    });
+    return {
+        enabled,
+        calls: records.length,
+        tools,
+        unknownTools: sortCounts(unknownTools),
+        unused: filter.tool !== undefined ? [] : definitions.map((definition) => definition.name).filter((name) => !byTool.has(name)),
+        suggestions,
+    };
+}
+// Tuned descriptions by tool name; empty when the project has not tuned any.
+export function readMcpToolTuning(basePath) {
+    try {
+        const parsed = JSON.parse(readFileSync(join(basePath, MCP_TOOL_TUNING_FILE), 'utf8'));
+        const descriptions = isRecord(parsed) && isRecord(parsed.descriptions) ? parsed.descriptions : {};
+        return Object.fromEntries(Object.entries(descriptions).filter((entry) => typeof entry[1] === 'string'));
+    }
+    catch {
+        return {};
+    }
+}
+// Replaces any earlier tuning, so tools whose calls now validate go back to their built-in descriptions.
+export async function writeMcpToolTuning(basePath, suggestions, now = new Date()) {
+    const path = join(basePath, MCP_TOOL_TUNING_FILE);
+    await mkdir(dirname(path), { recursive: true });
+    await writeFile(path, `${JSON.stringify({
+        tunedAt: now.toISOString(),
+        descriptions: Object.fromEntries(suggestions.map((suggestion) => [suggestion.tool, suggestion.description])),
+    }, null, 2)}\n`, 'utf8');
+    return path;
+}
+function summarizeTool(tool, calls) {
+    const argumentCounts = new Map();
+    const errorCounts = new Map();
+    for (const call of calls) {
+        for (const name of call.arguments) {
+            argumentCounts.set(name, (argumentCounts.get(name) ?? 0) + 1);
+        }
+        if (call.outcome === 'invalid' && call.error !== undefined) {
+            errorCounts.set(call.error, (errorCounts.get(call.error) ?? 0) + 1);
+        }
+    }
+    return {
+        tool,
+        calls: calls.length,
+        failed: calls.filter((call) => call.outcome === 'failed').length,
+        invalid: calls.filter((call) => call.outcome === 'invalid').length,
+        lastAt: calls.reduce((latest, call) => call.at > latest ? call.at : latest, ''),
+        averageDurationMs: Math.round(calls.reduce((total, call) => total + call.durationMs, 0) / calls.length),
+        arguments: sortCounts(argumentCounts),
+        validationErrors: sortCounts(errorCounts),
+    };
+}
+function suggestDescription(definition, summary, calls) {
+    if (summary.invalid < MIN_INVALID_CALLS) {
+        return undefined;
+    }
+    const properties = definition.inputSchema.properties ?? {};
+    const required = definition.inputSchema.required ?? [];
+    const errors = summary.validationErrors.map((entry) => entry.name);
+    const notes = [];
+    if (required.length > 0 && errors.some((error) => error.endsWith(' is required'))) {
+        notes.push(`Required: ${required.join(', ')}.`);
+    }
+    if (errors.some((error) => error.endsWith(' is not allowed'))) {
+        notes.push(Object.keys(properties).length > 0 ? `Accepted arguments: ${Object.keys(properties).join(', ')}.` : 'Takes no arguments.');
+    }
+    // The argument set successful calls used most, else just the required ones.
+    const sets = new Map();
+    for (const call of calls.filter((entry) => entry.outcome === 'ok')) {
+        const key = [...call.arguments].sort().join(',');
+        sets.set(key, (sets.get(key) ?? 0) + 1);
+    }
+    const common = sortCounts(sets)[0]?.name;
+    const exampleNames = (common !== undefined ? common.split(',') : required).filter((name) => name in properties);
+    if (exampleNames.length > 0) {
+        notes.push(`Example: ${JSON.stringify(Object.fromEntries(exampleNames.map((name) => [name, exampleValue(name, properties[name])])))}`);
+    }
+    if (notes.length === 0) {
+        return undefined;
+    }
+    return {
+        tool: definition.name,
+        reason: `${summary.invalid} of ${summary.calls} calls failed validation, most often: ${errors[0] ?? 'invalid arguments'}`,
+        description: `${definition.description} ${notes.join(' ')}`,
+    };
+}
+function exampleValue(name, schema) {
+    if (schema.enum !== undefined && schema.enum.length > 0) {
+        return schema.enum[0];
+    }
+    switch (schema.type) {
+        case 'integer':
+        case 'number':
+            return 1;
+        case 'boolean':
+            return true;
+        case 'array':
+            return schema.items?.type === 'string' ? [`<${name}>`] : [];
+        case 'object':
+            return {};
+        default:
+            return `<${name}>`;
+    }
+}
+function matchesUsageFilter(record, filter) {
+    if (filter.tool !== undefined && filter.tool !== record.tool && filter.tool !== record.calledAs) {
+        return false;
+    }
+    if (filter.client !== undefined && filter.client !== record.client) {
+        return false;
+    }
+    if (filter.since !== undefined && record.at < filter.since) {
+        return false;
+    }
+    return filter.until === undefined || record.at <= filter.until;
+}
+function sortCounts(counts) {
+    return [...counts.entries()]
+        .map(([name, count]) => ({ name, count }))
+        .sort((left, right) => right.count - left.count || left.name.localeCompare(right.name));
+}
+async function readUsageFile(path) {
+    let raw;
+    try {
+        raw = await readFile(path, 'utf8');
+    }
+    catch {
+        return [];
+    }
+    return raw.split('\n').flatMap((line) => {
+        if (line.trim().length === 0) {
+            return [];
+        }
+        try {
+            const parsed = JSON.parse(line);
+            return isRecord(parsed) && typeof parsed.at === 'string' && typeof parsed.tool === 'string' && Array.isArray(parsed.arguments)
+                ? [parsed]
+                : [];
+        }
+        catch {
+            // A line cut short by a crash; the rest of the log still reads.
+            return [];
+        }
+    });
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
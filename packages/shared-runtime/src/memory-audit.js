@@ -0,0 +1,121 @@
+import { appendFile, mkdir, readFile, rename, stat } from 'node:fs/promises';
+import { userInfo } from 'node:os';
+import { dirname, join } from 'node:path';
+export const MEMORY_AUDIT_FILE = join('.automatosx', 'runtime', 'memory-audit.jsonl');
+// The log rolls over to this file past `maxFileBytes`; queries read both.
+export const MEMORY_AUDIT_PREVIOUS_FILE = join('.automatosx', 'runtime', 'memory-audit.1.jsonl');
+const DEFAULT_MAX_FILE_BYTES = 10 * 1024 * 1024;
+const DEFAULT_AUDIT_LIMIT = 50;
+// Keys listed per record; a read that returned more keeps the count only.
+const MAX_KEYS_PER_RECORD = 50;
+// `memory.audit` in config: `true`, or `{ "enabled": true, "maxFileBytes": 10485760 }`. Off by default.
+export function resolveMemoryAuditConfig(memoryConfig) {
+    const value = isRecord(memoryConfig) ? memoryConfig.audit : undefined;
+    if (value === true) {
+        return { enabled: true, maxFileBytes: DEFAULT_MAX_FILE_BYTES };
+    }
+    if (!isRecord(value)) {
+        return { enabled: false, maxFileBytes: DEFAULT_MAX_FILE_BYTES };
+    }
+    return {
+        enabled: value.enabled !== false,
+        maxFileBytes: typeof value.maxFileBytes === 'number' && value.maxFileBytes > 0 ? value.maxFileBytes : DEFAULT_MAX_FILE_BYTES,
+    };
+}
+// Who is acting when nobody said: the person running the process.
+export function defaultMemoryActor() {
+    try {
+        return { kind: 'user', id: userInfo().username };
+    }
+    catch {
+        return { kind: 'user', id: process.env.USER ?? process.env.USERNAME ?? 'unknown' };
+    }
+}
+// `namespace/key` for each entry, as audit records list them.
+export function memoryAuditRefs(entries) {
+    return entries.map((entry) => entry.namespace !== undefined ? `${entry.namespace}/${entry.key}` : entry.key);
+}
+export async function appendMemoryAudit(basePath, config, record, now = new Date()) {
+    const path = join(basePath, MEMORY_AUDIT_FILE);
+    await mkdir(dirname(path), { recursive: true });
+    try {
+        if ((await stat(path)).size >= config.maxFileBytes) {
+            await rename(path, join(basePath, MEMORY_AUDIT_PREVIOUS_FILE));
+        }
+    }
+    catch {
+        // No log yet.
+    }
+    const { keys, ...rest } = record;
+    const line = {
+        at: now.toISOString(),
+        ...rest,
+        ...(keys !== undefined && keys.length > 0 && keys.length <= MAX_KEYS_PER_RECORD ? { keys } : {}),
+    };
+    await appendFile(path, `${JSON.stringify(line)}\n`, 'utf8');
+}
+export async function queryMemoryAudit(basePath, enabled, filter = {}) {
+    const records = [
+        ...await readAuditFile(join(basePath, MEMORY_AUDIT_PREVIOUS_FILE)),
+        ...await readAuditFile(join(basePath, MEMORY_AUDIT_FILE)),
+    ].filter((record) => matchesAuditFilter(record, filter)).reverse();
+    const actors = new Map();
+    for (const record of records) {
+        const actor = `${record.actor.kind}:${record.actor.id}`;
+        const summary = actors.get(actor) ?? { actor, reads: 0, writes: 0, deletes: 0, lastAt: record.at };
+        summary[record.action === 'read' ? 'reads' : record.action === 'write' ? 'writes' : 'deletes'] += 1;
+        actors.set(actor, summary);
+    }
+    return {
+        enabled,
+        records: records.slice(0, Math.max(0, filter.limit ?? DEFAULT_AUDIT_LIMIT)),
+        matched: records.length,
+        actors: [...actors.values()].sort((left, right) => right.lastAt.localeCompare(left.lastAt)),
+    };
+}
+function matchesAuditFilter(record, filter) {
+    if (filter.actor !== undefined && filter.actor !== record.actor.id && filter.actor !== `${record.actor.kind}:${record.actor.id}`) {
+        return false;
+    }
+    if (filter.actorKind !== undefined && filter.actorKind !== record.actor.kind) {
+        return false;
+    }
+    if (filter.action !== undefined && filter.action !== record.action) {
+        return false;
+    }
+    if (filter.namespace !== undefined && filter.namespace !== record.namespace) {
+        return false;
+    }
+    if (filter.key !== undefined && filter.key !== record.key && !(record.keys ?? []).some((ref) => ref === filter.key || ref.endsWith(`/${filter.key}`))) {
+        return false;
+    }
+    if (filter.since !== undefined && record.at < filter.since) {
+        return false;
+    }
+    return filter.until === undefined || record.at <= filter.until;
+}
+async function readAuditFile(path) {
+    let raw;
+    try {
+        raw = await readFile(path, 'utf8');
+    }
+    catch {
+        return [];
+    }
+    return raw.split('\n').flatMap((line) => {
+        if (line.trim().length === 0) {
+            return [];
+        }
+        try {
+            const parsed = JSON.parse(line);
+            return isRecord(parsed) && typeof parsed.at === 'string' && isRecord(parsed.actor) ? [parsed] : [];
+        }
+        catch {
+            // A line cut short by a crash; the rest of the log still reads.
+            return [];
+        }
+    });
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
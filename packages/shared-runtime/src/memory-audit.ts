@@ -0,0 +1,209 @@
+import { appendFile, mkdir, readFile, rename, stat } from 'node:fs/promises';
+import { userInfo } from 'node:os';
+import { dirname, join } from 'node:path';
+
+export const MEMORY_AUDIT_FILE = join('.automatosx', 'runtime', 'memory-audit.jsonl');
+// The log rolls over to this file past `maxFileBytes`; queries read both.
+export const MEMORY_AUDIT_PREVIOUS_FILE = join('.automatosx', 'runtime', 'memory-audit.1.jsonl');
+
+const DEFAULT_MAX_FILE_BYTES = 10 * 1024 * 1024;
+const DEFAULT_AUDIT_LIMIT = 50;
+// Keys listed per record; a read that returned more keeps the count only.
+const MAX_KEYS_PER_RECORD = 50;
+
+export type MemoryActorKind = 'agent' | 'tool' | 'user';
+
+export interface MemoryActor {
+  kind: MemoryActorKind;
+  id: string;
+}
+
+export type MemoryAuditAction = 'read' | 'write' | 'delete';
+
+export type MemoryAuditOperation =
+  | 'memory.store'
+  | 'memory.get'
+  | 'memory.search'
+  | 'memory.list'
+  | 'memory.as_of'
+  | 'memory.delete'
+  | 'memory.export'
+  | 'memory.import'
+  | 'memory.restore'
+  | 'semantic.store'
+  | 'semantic.get'
+  | 'semantic.search'
+  | 'semantic.list'
+  | 'semantic.delete'
+  | 'semantic.clear'
+  | 'semantic.index_docs';
+
+export interface MemoryAuditRecord {
+  at: string;
+  action: MemoryAuditAction;
+  operation: MemoryAuditOperation;
+  actor: MemoryActor;
+  scope?: string;
+  namespace?: string;
+  // The entry asked for, by get, store, and delete.
+  key?: string;
+  query?: string;
+  // Entries returned, written, or deleted, as `namespace/key`; left out past MAX_KEYS_PER_RECORD.
+  keys?: string[];
+  count: number;
+}
+
+export interface MemoryAuditConfig {
+  enabled: boolean;
+  maxFileBytes: number;
+}
+
+export interface MemoryAuditFilter {
+  // An actor id, or `kind:id` such as `tool:memory.search`.
+  actor?: string;
+  actorKind?: MemoryActorKind;
+  action?: MemoryAuditAction;
+  namespace?: string;
+  // Matches the record's key or any key it returned.
+  key?: string;
+  since?: string;
+  until?: string;
+  limit?: number;
+}
+
+export interface MemoryAuditActorSummary {
+  actor: string;
+  reads: number;
+  writes: number;
+  deletes: number;
+  lastAt: string;
+}
+
+export interface RuntimeMemoryAuditResponse {
+  enabled: boolean;
+  // Newest first, at most `limit`.
+  records: MemoryAuditRecord[];
+  matched: number;
+  // Every matching record, not only those returned.
+  actors: MemoryAuditActorSummary[];
+}
+
+// `memory.audit` in config: `true`, or `{ "enabled": true, "maxFileBytes": 10485760 }`. Off by default.
+export function resolveMemoryAuditConfig(memoryConfig: unknown): MemoryAuditConfig {
+  const value = isRecord(memoryConfig) ? memoryConfig.audit : undefined;
+  if (value === true) {
+    return { enabled: true, maxFileBytes: DEFAULT_MAX_FILE_BYTES };
+  }
+  if (!isRecord(value)) {
+    return { enabled: false, maxFileBytes: DEFAULT_MAX_FILE_BYTES };
+  }
+  return {
+    enabled: value.enabled !== false,
+    maxFileBytes: typeof value.maxFileBytes === 'number' && value.maxFileBytes > 0 ? value.maxFileBytes : DEFAULT_MAX_FILE_BYTES,
+  };
+}
+
+// Who is acting when nobody said: the person running the process.
+export function defaultMemoryActor(): MemoryActor {
+  try {
+    return { kind: 'user', id: userInfo().username };
+  } catch {
+    return { kind: 'user', id: process.env.USER ?? process.env.USERNAME ?? 'unknown' };
+  }
+}
+
+// `namespace/key` for each entry, as audit records list them.
+export function memoryAuditRefs(entries: Array<{ key: string; namespace?: string }>): string[] {
+  return entries.map((entry) => entry.namespace !== undefined ? `${entry.namespace}/${entry.key}` : entry.key);
+}
+
+export async function appendMemoryAudit(
+  basePath: string,
+  config: MemoryAuditConfig,
+  record: Omit<MemoryAuditRecord, 'at' | 'keys'> & { keys?: string[] },
+  now = new Date(),
+): Promise<void> {
+  const path = join(basePath, MEMORY_AUDIT_FILE);
+  await mkdir(dirname(path), { recursive: true });
+  try {
+    if ((await stat(path)).size >= config.maxFileBytes) {
+      await rename(path, join(basePath, MEMORY_AUDIT_PREVIOUS_FILE));
+    }
+  } catch {
+    // No log yet.
+  }
+  const { keys, ...rest } = record;
+  const line: MemoryAuditRecord = {
+    at: now.toISOString(),
+    ...rest,
+    ...(keys !== undefined && keys.length > 0 && keys.length <= MAX_KEYS_PER_RECORD ? { keys } : {}),
+  };
+  await appendFile(path, `${JSON.stringify(line)}\n`, 'utf8');
+}
+
+export async function queryMemoryAudit(basePath: string, enabled: boolean, filter: MemoryAuditFilter = {}): Promise<RuntimeMemoryAuditResponse> {
+  const records = [
+    ...await readAuditFile(join(basePath, MEMORY_AUDIT_PREVIOUS_FILE)),
+    ...await readAuditFile(join(basePath, MEMORY_AUDIT_FILE)),
+  ].filter((record) => matchesAuditFilter(record, filter)).reverse();
+  const actors = new Map<string, MemoryAuditActorSummary>();
+  for (const record of records) {
+    const actor = `${record.actor.kind}:${record.actor.id}`;
+    const summary = actors.get(actor) ?? { actor, reads: 0, writes: 0, deletes: 0, lastAt: record.at };
+    summary[record.action === 'read' ? 'reads' : record.action === 'write' ? 'writes' : 'deletes'] += 1;
+    actors.set(actor, summary);
+  }
+  return {
+    enabled,
+    records: records.slice(0, Math.max(0, filter.limit ?? DEFAULT_AUDIT_LIMIT)),
+    matched: records.length,
+    actors: [...actors.values()].sort((left, right) => right.lastAt.localeCompare(left.lastAt)),
+  };
+}
+
+function matchesAuditFilter(record: MemoryAuditRecord, filter: MemoryAuditFilter): boolean {
+  if (filter.actor !== undefined && filter.actor !== record.actor.id && filter.actor !== `${record.actor.kind}:${record.actor.id}`) {
+    return false;
+  }
+  if (filter.actorKind !== undefined && filter.actorKind !== record.actor.kind) {
+    return false;
+  }
+  if (filter.action !== undefined && filter.action !== record.action) {
+    return false;
+  }
+  if (filter.namespace !== undefined && filter.namespace !== record.namespace) {
+    return false;
+  }
+  if (filter.key !== undefined && filter.key !== record.key && !(record.keys ?? []).some((ref) => ref === filter.key || ref.endsWith(`/${filter.key}`))) {
+    return false;
+  }
+  if (filter.since !== undefined && record.at < filter.since) {
+    return false;
+  }
+  return filter.until === undefined || record.at <= filter.until;
+}
+
+async function readAuditFile(path: string): Promise<MemoryAuditRecord[]> {
+  let raw: string;
+  try {
+    raw = await readFile(path, 'utf8');
+  } catch {
+    return [];
+  }
+  return raw.split('\n').flatMap((line) => {
+    if (line.trim().length === 0) {
+      return [];
+    }
+    try {
+      const parsed = JSON.parse(line) as unknown;
+      return isRecord(parsed) && typeof parsed.at === 'string' && isRecord(parsed.actor) ? [parsed as unknown as MemoryAuditRecord] : [];
+    } catch {
+      // A line cut short by a crash; the rest of the log still reads.
+      return [];
+    }
+  });
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,44 @@
+import { readFileSync } from 'node:fs';
+import { join } from 'node:path';
+import { migrateStateStoreSchema, } from '@defai.digital/state-store';
+export const DEFAULT_MEMORY_DATABASE_URL_ENV = 'AX_MEMORY_DATABASE_URL';
+// `memory.backend` and `memory.postgres` in config: `{"backend": "postgres", "postgres": {"connectionStringEnv": ..., "maxConnections": ...}}`.
+// The connection string is read from the environment so credentials stay out of the workspace.
+export function resolveMemoryBackendConfig(memory, env = process.env) {
+    if (!isRecord(memory) || (memory.backend !== 'sqlite' && memory.backend !== 'json' && memory.backend !== 'postgres')) {
+        return {};
+    }
+    if (memory.backend !== 'postgres') {
+        return { backend: memory.backend };
+    }
+    const postgres = isRecord(memory.postgres) ? memory.postgres : {};
+    const urlEnv = typeof postgres.connectionStringEnv === 'string' && postgres.connectionStringEnv.length > 0
+        ? postgres.connectionStringEnv
+        : DEFAULT_MEMORY_DATABASE_URL_ENV;
+    const connectionString = env[urlEnv];
+    if (connectionString === undefined || connectionString.length === 0) {
+        throw new Error(`memory.backend is "postgres" but ${urlEnv} is not set.`);
+    }
+    const maxConnections = typeof postgres.maxConnections === 'number' && Number.isInteger(postgres.maxConnections) && postgres.maxConnections > 0
+        ? postgres.maxConnections
+        : undefined;
+    return { backend: 'postgres', postgres: { connectionString, ...(maxConnections !== undefined ? { maxConnections } : {}) } };
+}
+// Migrates the configured memory backend without opening a runtime, whose store would migrate forward first.
+export function migrateMemorySchema(basePath, options = {}) {
+    return migrateStateStoreSchema({ basePath, ...loadMemoryBackendConfig(basePath) }, options);
+}
+// Read synchronously: the state store is opened while the runtime is constructed.
+export function loadMemoryBackendConfig(basePath, env = process.env) {
+    let config;
+    try {
+        config = JSON.parse(readFileSync(join(basePath, '.automatosx', 'config.json'), 'utf8'));
+    }
+    catch {
+        return {};
+    }
+    return resolveMemoryBackendConfig(isRecord(config) ? config.memory : undefined, env);
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,215 @@
+import { mkdir, readFile, writeFile } from 'node:fs/promises';
+import { hostname } from 'node:os';
+import { dirname, join } from 'node:path';
+import { decryptPayload, encryptPayload } from './state-sync.js';
+export const MEMORY_BUNDLE_FORMAT = 'automatosx-memory';
+export const MEMORY_BUNDLE_SCHEMA_VERSION = 1;
+export const MEMORY_EXPORTS_DIR = join('.automatosx', 'exports');
+/**
+ * Writes key-value memory and semantic entries to a JSONL bundle: a header
+ * line with the format, schema version, and counts, then one record per line
+ * so large bundles stream and diff cleanly. With an encryption key the whole
+ * bundle is sealed in the same AES-256-GCM envelope that state sync uses.
+ */
+export async function exportMemoryBundle(request) {
+    const now = request.now ?? new Date();
+    const extension = request.encryptionKey !== undefined ? '.jsonl.enc' : '.jsonl';
+    const outputPath = request.outputPath ?? join(request.basePath, MEMORY_EXPORTS_DIR, `memory-${now.getTime()}${extension}`);
+    const [memory, semantic] = await Promise.all([
+        request.state.listMemory(request.namespace),
+        request.state.listSemantic({ namespace: request.namespace }),
+    ]);
+    const records = [
+        ...memory.map((entry) => ({
+            type: 'memory',
+            key: entry.key,
+            ...(entry.namespace !== undefined ? { namespace: entry.namespace } : {}),
+            value: entry.value,
+            ...(entry.tags !== undefined ? { tags: entry.tags } : {}),
+            updatedAt: entry.updatedAt,
+        })),
+        ...semantic.map((entry) => ({
+            type: 'semantic',
+            key: entry.key,
+            ...(entry.namespace !== undefined ? { namespace: entry.namespace } : {}),
+            content: entry.content,
+            tags: entry.tags,
+            ...(entry.metadata !== undefined ? { metadata: entry.metadata } : {}),
+            embedding: entry.tokenFreq,
+            updatedAt: entry.updatedAt,
+        })),
+    ].sort((left, right) => left.type.localeCompare(right.type)
+        || (left.namespace ?? '').localeCompare(right.namespace ?? '')
+        || left.key.localeCompare(right.key));
+    const counts = { memory: memory.length, semantic: semantic.length };
+    const header = {
+        type: 'header',
+        format: MEMORY_BUNDLE_FORMAT,
+        schemaVersion: MEMORY_BUNDLE_SCHEMA_VERSION,
+        exportedAt: now.toISOString(),
+        machine: hostname(),
+        ...(request.namespace !== undefined ? { namespace: request.namespace } : {}),
+        counts,
+    };
+    const jsonl = [header, ...records].map((line) => JSON.stringify(line)).join('\n') + '\n';
+    const content = request.encryptionKey !== undefined ? `${encryptPayload(jsonl, request.encryptionKey)}\n` : jsonl;
+    await mkdir(dirname(outputPath), { recursive: true });
+    await writeFile(outputPath, content, 'utf8');
+    return {
+        outputPath,
+        schemaVersion: MEMORY_BUNDLE_SCHEMA_VERSION,
+        exportedAt: header.exportedAt,
+        counts,
+        bytes: Buffer.byteLength(content, 'utf8'),
+        encrypted: request.encryptionKey !== undefined,
+    };
+}
+/**
+ * Loads a bundle into the store. An entry replaces the local one only when
+ * the bundle's copy was updated later, or always with `overwrite`; imported
+ * entries take the import time as their update time, so importing the same
+ * bundle twice changes nothing. Semantic entries are re-indexed from their
+ * content rather than trusting the bundle's embedding.
+ */
+export async function importMemoryBundle(request) {
+    const bundle = await readMemoryBundle(request.inputPath, request.encryptionKey);
+    const imported = { memory: 0, semantic: 0 };
+    const skipped = { memory: 0, semantic: 0 };
+    for (const record of bundle.records) {
+        if (request.namespace !== undefined && record.namespace !== request.namespace) {
+            continue;
+        }
+        const existing = record.type === 'memory'
+            ? await request.state.getMemory(record.key, record.namespace)
+            : await request.state.getSemantic(record.key, record.namespace);
+        if (existing !== undefined && request.overwrite !== true && existing.updatedAt >= record.updatedAt) {
+            skipped[record.type] += 1;
+            continue;
+        }
+        imported[record.type] += 1;
+        if (request.dryRun === true) {
+            continue;
+        }
+        if (record.type === 'memory') {
+            await request.state.storeMemory({ key: record.key, namespace: record.namespace, value: record.value, tags: record.tags });
+        }
+        else {
+            await request.state.storeSemantic({
+                key: record.key,
+                namespace: record.namespace,
+                content: record.content,
+                tags: record.tags,
+                metadata: record.metadata,
+            });
+        }
+    }
+    return {
+        inputPath: request.inputPath,
+        schemaVersion: bundle.header.schemaVersion,
+        exportedAt: bundle.header.exportedAt,
+        machine: bundle.header.machine,
+        imported,
+        skipped,
+        dryRun: request.dryRun === true,
+    };
+}
+// Reads a bundle file, decrypting it first when it was exported encrypted.
+export async function readMemoryBundle(inputPath, encryptionKey) {
+    return parseMemoryBundle(openMemoryBundle(await readFile(inputPath, 'utf8'), encryptionKey));
+}
+export function parseMemoryBundle(content) {
+    const lines = content.split('\n').map((line, index) => ({ line: line.trim(), number: index + 1 })).filter(({ line }) => line.length > 0);
+    const [first, ...rest] = lines.map(({ line, number }) => {
+        try {
+            return { value: JSON.parse(line), number };
+        }
+        catch {
+            throw new Error(`Memory bundle line ${number} is not valid JSON.`);
+        }
+    });
+    const header = first?.value;
+    if (!isRecord(header) || header.type !== 'header' || header.format !== MEMORY_BUNDLE_FORMAT) {
+        throw new Error(`Not a memory bundle: the first line must be an ${MEMORY_BUNDLE_FORMAT} header.`);
+    }
+    if (typeof header.schemaVersion !== 'number' || !Number.isInteger(header.schemaVersion) || header.schemaVersion < 1) {
+        throw new Error('Memory bundle header has no valid schemaVersion.');
+    }
+    if (header.schemaVersion > MEMORY_BUNDLE_SCHEMA_VERSION) {
+        throw new Error(`Memory bundle schema ${header.schemaVersion} is newer than this version of AutomatosX supports (${MEMORY_BUNDLE_SCHEMA_VERSION}). Upgrade to import it.`);
+    }
+    const records = rest.map(({ value, number }) => {
+        const record = parseRecord(value);
+        if (record === undefined) {
+            throw new Error(`Memory bundle line ${number} is not a memory or semantic record.`);
+        }
+        return record;
+    });
+    return {
+        header: {
+            type: 'header',
+            format: MEMORY_BUNDLE_FORMAT,
+            schemaVersion: header.schemaVersion,
+            exportedAt: typeof header.exportedAt === 'string' ? header.exportedAt : '',
+            machine: typeof header.machine === 'string' ? header.machine : 'unknown',
+            ...(typeof header.namespace === 'string' ? { namespace: header.namespace } : {}),
+            counts: {
+                memory: records.filter((record) => record.type === 'memory').length,
+                semantic: records.filter((record) => record.type === 'semantic').length,
+            },
+        },
+        records,
+    };
+}
+// Encrypted bundles are a single JSON envelope; plain bundles are JSONL starting with a header line.
+function openMemoryBundle(content, encryptionKey) {
+    let envelope;
+    try {
+        envelope = JSON.parse(content.trim());
+    }
+    catch {
+        return content;
+    }
+    if (!isRecord(envelope) || envelope.alg === undefined) {
+        return content;
+    }
+    if (encryptionKey === undefined) {
+        throw new Error('Memory bundle is encrypted; enable memory.encryption with the key it was exported under to import it.');
+    }
+    try {
+        return decryptPayload(content.trim(), encryptionKey);
+    }
+    catch {
+        throw new Error('Cannot decrypt memory bundle: wrong encryption key or corrupted file.');
+    }
+}
+function parseRecord(value) {
+    if (!isRecord(value) || typeof value.key !== 'string' || typeof value.updatedAt !== 'string') {
+        return undefined;
+    }
+    if (value.namespace !== undefined && typeof value.namespace !== 'string') {
+        return undefined;
+    }
+    const namespace = typeof value.namespace === 'string' ? { namespace: value.namespace } : {};
+    const tags = Array.isArray(value.tags) ? value.tags.filter((tag) => typeof tag === 'string') : [];
+    if (value.type === 'memory' && 'value' in value) {
+        return { type: 'memory', key: value.key, ...namespace, value: value.value, ...(tags.length > 0 ? { tags } : {}), updatedAt: value.updatedAt };
+    }
+    if (value.type === 'semantic' && typeof value.content === 'string') {
+        return {
+            type: 'semantic',
+            key: value.key,
+            ...namespace,
+            content: value.content,
+            tags,
+            ...(isRecord(value.metadata) ? { metadata: value.metadata } : {}),
+            embedding: isRecord(value.embedding)
+                ? Object.fromEntries(Object.entries(value.embedding).filter((entry) => typeof entry[1] === 'number'))
+                : {},
+            updatedAt: value.updatedAt,
+        };
+    }
+    return undefined;
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
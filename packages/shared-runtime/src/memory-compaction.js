@@ -0,0 +1,195 @@
+import { createHash } from 'node:crypto';
+import { appendFile, mkdir, readFile, writeFile } from 'node:fs/promises';
+import { dirname, join } from 'node:path';
+import { cosineSimilarity } from './memory-dedup.js';
+export const MEMORY_ARCHIVE_DIR = join('.automatosx', 'memory-archive');
+export const MEMORY_COMPACTION_FILE = join('.automatosx', 'runtime', 'memory-compaction.json');
+const DAY_MS = 24 * 60 * 60 * 1000;
+const DEFAULT_MIN_AGE_DAYS = 30;
+const DEFAULT_MIN_CLUSTER_SIZE = 3;
+const DEFAULT_SIMILARITY = 0.5;
+const DEFAULT_INTERVAL_MINUTES = 24 * 60;
+// A summary lists at most this many of its entries; the rest are counted.
+const MAX_SUMMARY_LINES = 20;
+const MAX_LINE_CHARS = 200;
+// `memory.compaction` in config: `{"minAgeDays": 30, "minClusterSize": 3, "similarity": 0.5, "intervalMinutes": 1440}`.
+export function resolveMemoryCompactionConfig(memory) {
+    const compaction = isRecord(memory) && isRecord(memory.compaction) ? memory.compaction : undefined;
+    const value = compaction ?? {};
+    const similarity = typeof value.similarity === 'number' && value.similarity > 0 && value.similarity <= 1 ? value.similarity : DEFAULT_SIMILARITY;
+    const minClusterSize = typeof value.minClusterSize === 'number' && Number.isInteger(value.minClusterSize) && value.minClusterSize >= 2
+        ? value.minClusterSize
+        : DEFAULT_MIN_CLUSTER_SIZE;
+    const interval = typeof value.intervalMinutes === 'number' && value.intervalMinutes >= 0 ? value.intervalMinutes : DEFAULT_INTERVAL_MINUTES;
+    return {
+        enabled: compaction !== undefined,
+        minAgeDays: typeof value.minAgeDays === 'number' && value.minAgeDays >= 0 ? value.minAgeDays : DEFAULT_MIN_AGE_DAYS,
+        minClusterSize,
+        similarity,
+        intervalMs: Math.round(interval * 60 * 1000),
+    };
+}
+/**
+ * Replaces each cluster of old, related semantic entries with one summary
+ * entry. Entries not updated for `minAgeDays` are grouped within their
+ * namespace by term-vector similarity; clusters of at least `minClusterSize`
+ * are written in full to an archive under `.automatosx/memory-archive`, then
+ * removed. The summary keeps each entry's opening sentence, the union of
+ * their tags, and a `compactedFrom` list pointing back at the archive.
+ * Indexed source chunks and earlier summaries are never compacted.
+ */
+export async function compactMemory(request) {
+    const { minAgeDays, minClusterSize, similarity } = request.config;
+    if (!Number.isFinite(similarity) || similarity <= 0 || similarity > 1) {
+        throw new Error(`Compaction similarity must be greater than 0 and at most 1, got ${similarity}.`);
+    }
+    if (!Number.isInteger(minClusterSize) || minClusterSize < 2) {
+        throw new Error(`Compaction cluster size must be a whole number of at least 2, got ${minClusterSize}.`);
+    }
+    if (!Number.isFinite(minAgeDays) || minAgeDays < 0) {
+        throw new Error(`Compaction minimum age must be 0 or more days, got ${minAgeDays}.`);
+    }
+    const now = request.now ?? new Date();
+    const dryRun = request.dryRun === true;
+    const cutoff = new Date(now.getTime() - minAgeDays * DAY_MS).toISOString();
+    const candidates = (await request.state.listSemantic({ namespace: request.namespace }))
+        .filter((entry) => entry.updatedAt <= cutoff && entry.metadata?.chunking === undefined && entry.metadata?.compactedFrom === undefined);
+    const clusters = clusterRelated(candidates, similarity).filter((cluster) => cluster.length >= minClusterSize);
+    const archivePath = join(request.basePath, MEMORY_ARCHIVE_DIR, `compaction-${now.toISOString().replace(/[-:.]/g, '')}.jsonl`);
+    const response = {
+        compactedAt: now.toISOString(),
+        dryRun,
+        minAgeDays,
+        minClusterSize,
+        similarity,
+        clusters: clusters.map((cluster) => ({
+            ...(cluster[0].namespace !== undefined ? { namespace: cluster[0].namespace } : {}),
+            key: summaryKey(cluster),
+            members: cluster.map((entry) => entry.key),
+        })),
+        compacted: 0,
+    };
+    if (dryRun) {
+        return response;
+    }
+    if (clusters.length === 0) {
+        await recordCompaction(request.basePath, response);
+        return response;
+    }
+    // Archive before anything is removed, so a failed run loses nothing.
+    await mkdir(dirname(archivePath), { recursive: true });
+    await appendFile(archivePath, clusters.flatMap((cluster) => cluster.map(({ tokenFreq: _tokenFreq, ...entry }) => JSON.stringify({
+        ...Object.fromEntries(Object.entries(entry).filter(([, value]) => value !== undefined)),
+        compactedInto: summaryKey(cluster),
+    }))).join('\n') + '\n', 'utf8');
+    for (const cluster of clusters) {
+        const oldestFirst = [...cluster].sort((left, right) => left.updatedAt.localeCompare(right.updatedAt));
+        const agents = new Set(cluster.map((entry) => entry.agentId));
+        const importance = Math.max(...cluster.map((entry) => entry.importance ?? -1));
+        await request.state.storeSemantic({
+            key: summaryKey(cluster),
+            namespace: cluster[0].namespace,
+            content: summarize(oldestFirst),
+            tags: [...new Set(cluster.flatMap((entry) => entry.tags))].sort(),
+            metadata: {
+                compactedFrom: oldestFirst.map((entry) => ({ key: entry.key, updatedAt: entry.updatedAt })),
+                compactedAt: now.toISOString(),
+                archive: archivePath,
+            },
+            ...(agents.size === 1 && cluster[0].agentId !== undefined ? { agentId: cluster[0].agentId } : {}),
+            ...(importance >= 0 ? { importance } : {}),
+        });
+        for (const entry of cluster) {
+            if (await request.state.deleteSemantic(entry.key, entry.namespace)) {
+                response.compacted += 1;
+            }
+        }
+    }
+    await recordCompaction(request.basePath, { ...response, archivePath });
+    return { ...response, archivePath };
+}
+/**
+ * The background job: compacts when `memory.compaction` is configured and
+ * the last compaction (from any process) is at least `intervalMs` old.
+ */
+export async function compactMemoryIfDue(request) {
+    if (!request.config.enabled || request.config.intervalMs === 0) {
+        return undefined;
+    }
+    const now = request.now ?? new Date();
+    const last = await readLastCompaction(request.basePath);
+    if (last !== undefined && now.getTime() - Date.parse(last) < request.config.intervalMs) {
+        return undefined;
+    }
+    return compactMemory({ ...request, now });
+}
+// Greedy single pass, newest first: each entry joins the first cluster whose newest entry it resembles.
+function clusterRelated(entries, similarity) {
+    const byNamespace = new Map();
+    for (const entry of entries) {
+        const namespace = entry.namespace ?? '';
+        byNamespace.set(namespace, [...(byNamespace.get(namespace) ?? []), entry]);
+    }
+    const clusters = [];
+    for (const namespace of [...byNamespace.keys()].sort()) {
+        const sorted = byNamespace.get(namespace)
+            .sort((left, right) => right.updatedAt.localeCompare(left.updatedAt) || left.key.localeCompare(right.key));
+        const namespaceClusters = [];
+        for (const entry of sorted) {
+            const cluster = namespaceClusters.find((members) => cosineSimilarity(members[0].tokenFreq, entry.tokenFreq) >= similarity);
+            if (cluster !== undefined) {
+                cluster.push(entry);
+            }
+            else {
+                namespaceClusters.push([entry]);
+            }
+        }
+        clusters.push(...namespaceClusters);
+    }
+    return clusters;
+}
+function summaryKey(cluster) {
+    const keys = cluster.map((entry) => entry.key).sort();
+    return `compacted-${createHash('sha256').update(JSON.stringify(keys)).digest('hex').slice(0, 12)}`;
+}
+function summarize(oldestFirst) {
+    const lines = [];
+    const seen = new Set();
+    for (const entry of oldestFirst) {
+        const line = openingSentence(entry.content);
+        if (line.length > 0 && !seen.has(line.toLowerCase())) {
+            seen.add(line.toLowerCase());
+            lines.push(line);
+        }
+    }
+    const first = oldestFirst[0].updatedAt.slice(0, 10);
+    const last = oldestFirst[oldestFirst.length - 1].updatedAt.slice(0, 10);
+    return [
+        `Summary of ${oldestFirst.length} related entries from ${first} to ${last}:`,
+        ...lines.slice(0, MAX_SUMMARY_LINES).map((line) => `- ${line}`),
+        ...(lines.length > MAX_SUMMARY_LINES ? [`- ...and ${lines.length - MAX_SUMMARY_LINES} more`] : []),
+    ].join('\n');
+}
+function openingSentence(content) {
+    const text = content.trim().replace(/\s+/g, ' ');
+    const sentence = /^.*?[.!?](?=\s|$)/.exec(text)?.[0] ?? text;
+    return sentence.length > MAX_LINE_CHARS ? `${sentence.slice(0, MAX_LINE_CHARS - 3)}...` : sentence;
+}
+// The last run, from either the background job or an explicit compaction, spaces out the next background run.
+async function recordCompaction(basePath, response) {
+    const path = join(basePath, MEMORY_COMPACTION_FILE);
+    await mkdir(dirname(path), { recursive: true });
+    await writeFile(path, `${JSON.stringify(response, null, 2)}\n`, 'utf8');
+}
+async function readLastCompaction(basePath) {
+    try {
+        const parsed = JSON.parse(await readFile(join(basePath, MEMORY_COMPACTION_FILE), 'utf8'));
+        return isRecord(parsed) && typeof parsed.compactedAt === 'string' ? parsed.compactedAt : undefined;
+    }
+    catch {
+        return undefined;
+    }
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
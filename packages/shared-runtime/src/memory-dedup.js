@@ -0,0 +1,169 @@
+import { createHash } from 'node:crypto';
+import { appendFile, mkdir } from 'node:fs/promises';
+import { dirname, join } from 'node:path';
+export const MEMORY_DEDUP_LOG_FILE = join('.automatosx', 'runtime', 'memory-dedup.jsonl');
+export const DEFAULT_DEDUP_THRESHOLD = 0.95;
+/**
+ * Merges duplicate memory within each namespace. Key-value entries merge only
+ * when their values hash the same, since each value is its own record.
+ * Semantic entries also merge when the cosine similarity of their term
+ * embeddings reaches `threshold`. The newest entry of a group is kept and
+ * takes the union of the group's tags plus a `mergedFrom` list in its
+ * metadata; every removed entry is appended in full to the dedup log so a
+ * merge can be undone by hand.
+ */
+export async function dedupeMemory(request) {
+    const threshold = request.threshold ?? DEFAULT_DEDUP_THRESHOLD;
+    if (!Number.isFinite(threshold) || threshold <= 0 || threshold > 1) {
+        throw new Error(`Dedup threshold must be greater than 0 and at most 1, got ${threshold}.`);
+    }
+    const now = request.now ?? new Date();
+    const dryRun = request.dryRun === true;
+    const [memory, semantic] = await Promise.all([
+        request.state.listMemory(request.namespace),
+        request.state.listSemantic({ namespace: request.namespace }),
+    ]);
+    const groups = [];
+    const log = [];
+    const removed = { memory: 0, semantic: 0 };
+    for (const cluster of clusterEntries(memory, (entry) => hashValue(entry.value), () => undefined, threshold)) {
+        const [kept, ...duplicates] = cluster;
+        groups.push(toGroup('memory', kept.entry, duplicates));
+        for (const duplicate of duplicates) {
+            log.push({ type: 'memory', ...withoutUndefined(duplicate.entry), mergedInto: kept.entry.key, reason: duplicate.reason, similarity: duplicate.similarity });
+            if (!dryRun && await request.state.deleteMemory(duplicate.entry.key, duplicate.entry.namespace)) {
+                removed.memory += 1;
+            }
+        }
+    }
+    for (const cluster of clusterEntries(semantic, (entry) => hashContent(entry.content), (entry) => entry.tokenFreq, threshold)) {
+        const [kept, ...duplicates] = cluster;
+        const survivor = kept.entry;
+        groups.push(toGroup('semantic', survivor, duplicates));
+        for (const duplicate of duplicates) {
+            const { tokenFreq: _tokenFreq, ...entry } = duplicate.entry;
+            log.push({ type: 'semantic', ...withoutUndefined(entry), mergedInto: survivor.key, reason: duplicate.reason, similarity: duplicate.similarity });
+        }
+        if (dryRun) {
+            continue;
+        }
+        const previous = Array.isArray(survivor.metadata?.mergedFrom) ? survivor.metadata.mergedFrom : [];
+        await request.state.storeSemantic({
+            key: survivor.key,
+            namespace: survivor.namespace,
+            content: survivor.content,
+            tags: [...new Set(cluster.flatMap(({ entry }) => entry.tags))],
+            metadata: {
+                ...Object.assign({}, ...duplicates.map(({ entry }) => entry.metadata ?? {}).reverse()),
+                ...survivor.metadata,
+                mergedFrom: [
+                    ...previous,
+                    ...duplicates.flatMap(({ entry, reason, similarity }) => [
+                        { key: entry.key, updatedAt: entry.updatedAt, reason, similarity, mergedAt: now.toISOString() },
+                        ...(Array.isArray(entry.metadata?.mergedFrom) ? entry.metadata.mergedFrom : []),
+                    ]),
+                ],
+            },
+            ttlMs: longestTtl(cluster.map(({ entry }) => entry), now),
+            agentId: survivor.agentId,
+            importance: survivor.importance,
+        });
+        for (const duplicate of duplicates) {
+            if (await request.state.deleteSemantic(duplicate.entry.key, duplicate.entry.namespace)) {
+                removed.semantic += 1;
+            }
+        }
+    }
+    let logPath;
+    if (!dryRun && log.length > 0) {
+        logPath = join(request.basePath, MEMORY_DEDUP_LOG_FILE);
+        await mkdir(dirname(logPath), { recursive: true });
+        await appendFile(logPath, log.map((line) => JSON.stringify({ dedupedAt: now.toISOString(), ...line })).join('\n') + '\n', 'utf8');
+    }
+    return { threshold, dryRun, groups, removed, ...(logPath !== undefined ? { logPath } : {}) };
+}
+export function cosineSimilarity(left, right) {
+    let dot = 0;
+    for (const [term, count] of Object.entries(left)) {
+        dot += count * (right[term] ?? 0);
+    }
+    const magnitude = Math.sqrt(sumOfSquares(left)) * Math.sqrt(sumOfSquares(right));
+    return magnitude === 0 ? 0 : Number((dot / magnitude).toFixed(4));
+}
+// Greedy single pass, newest first: each entry joins the first kept entry it duplicates.
+function clusterEntries(entries, hash, embedding, threshold) {
+    const byNamespace = new Map();
+    for (const entry of entries) {
+        const namespace = entry.namespace ?? '';
+        byNamespace.set(namespace, [...(byNamespace.get(namespace) ?? []), entry]);
+    }
+    const clusters = [];
+    for (const namespace of [...byNamespace.keys()].sort()) {
+        const sorted = byNamespace.get(namespace)
+            .sort((left, right) => right.updatedAt.localeCompare(left.updatedAt) || left.key.localeCompare(right.key));
+        const heads = [];
+        for (const entry of sorted) {
+            const entryHash = hash(entry);
+            const entryEmbedding = embedding(entry);
+            let joined = false;
+            for (const head of heads) {
+                if (head.hash === entryHash) {
+                    head.members.push({ entry, reason: 'hash', similarity: 1 });
+                    joined = true;
+                    break;
+                }
+                const similarity = head.embedding !== undefined && entryEmbedding !== undefined ? cosineSimilarity(head.embedding, entryEmbedding) : 0;
+                if (similarity >= threshold) {
+                    head.members.push({ entry, reason: 'similarity', similarity });
+                    joined = true;
+                    break;
+                }
+            }
+            if (!joined) {
+                heads.push({ hash: entryHash, embedding: entryEmbedding, members: [{ entry, reason: 'hash', similarity: 1 }] });
+            }
+        }
+        clusters.push(...heads.map((head) => head.members).filter((members) => members.length > 1));
+    }
+    return clusters;
+}
+function toGroup(type, kept, duplicates) {
+    return {
+        type,
+        ...(kept.namespace !== undefined ? { namespace: kept.namespace } : {}),
+        kept: kept.key,
+        duplicates: duplicates.map(({ entry, reason, similarity }) => ({ key: entry.key, updatedAt: entry.updatedAt, reason, similarity })),
+    };
+}
+// No TTL if any merged entry had none; otherwise the latest expiry in the group.
+function longestTtl(entries, now) {
+    if (entries.some((entry) => entry.expiresAt === undefined)) {
+        return undefined;
+    }
+    const latest = Math.max(...entries.map((entry) => Date.parse(entry.expiresAt)));
+    return Math.max(1, latest - now.getTime());
+}
+function hashValue(value) {
+    return createHash('sha256').update(JSON.stringify(sortKeys(value)) ?? 'undefined').digest('hex');
+}
+// Case and whitespace don't make semantic content distinct.
+function hashContent(content) {
+    return createHash('sha256').update(content.trim().replace(/\s+/g, ' ').toLowerCase()).digest('hex');
+}
+function sortKeys(value) {
+    if (Array.isArray(value)) {
+        return value.map(sortKeys);
+    }
+    if (value !== null && typeof value === 'object') {
+        return Object.fromEntries(Object.entries(value)
+            .sort(([left], [right]) => left.localeCompare(right))
+            .map(([key, entry]) => [key, sortKeys(entry)]));
+    }
+    return value;
+}
+function sumOfSquares(vector) {
+    return Object.values(vector).reduce((sum, count) => sum + count * count, 0);
+}
+function withoutUndefined(value) {
+    return Object.fromEntries(Object.entries(value).filter(([, entry]) => entry !== undefined));
+}
@@ -0,0 +1,242 @@
+import { createHash } from 'node:crypto';
+import { appendFile, mkdir } from 'node:fs/promises';
+import { dirname, join } from 'node:path';
+import type { MemoryEntry, SemanticEntry } from '@defai.digital/state-store';
+
+export const MEMORY_DEDUP_LOG_FILE = join('.automatosx', 'runtime', 'memory-dedup.jsonl');
+export const DEFAULT_DEDUP_THRESHOLD = 0.95;
+
+export type MemoryDuplicateReason = 'hash' | 'similarity';
+
+export interface MemoryDuplicate {
+  key: string;
+  updatedAt: string;
+  reason: MemoryDuplicateReason;
+  // Cosine similarity to the kept entry's embedding; 1 for identical content.
+  similarity: number;
+}
+
+export interface MemoryDuplicateGroup {
+  type: 'memory' | 'semantic';
+  namespace?: string;
+  // The most recently updated entry, which absorbs the others.
+  kept: string;
+  duplicates: MemoryDuplicate[];
+}
+
+export interface RuntimeMemoryDedupResponse {
+  threshold: number;
+  dryRun: boolean;
+  groups: MemoryDuplicateGroup[];
+  removed: { memory: number; semantic: number };
+  // Where the removed entries were recorded in full; unset on a dry run or when nothing merged.
+  logPath?: string;
+}
+
+export interface MemoryDedupStateAccess {
+  listMemory(namespace?: string): Promise<MemoryEntry[]>;
+  deleteMemory(key: string, namespace?: string): Promise<boolean>;
+  listSemantic(options?: { namespace?: string }): Promise<SemanticEntry[]>;
+  storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown>; ttlMs?: number; agentId?: string; importance?: number }): Promise<unknown>;
+  deleteSemantic(key: string, namespace?: string): Promise<boolean>;
+}
+
+/**
+ * Merges duplicate memory within each namespace. Key-value entries merge only
+ * when their values hash the same, since each value is its own record.
+ * Semantic entries also merge when the cosine similarity of their term
+ * embeddings reaches `threshold`. The newest entry of a group is kept and
+ * takes the union of the group's tags plus a `mergedFrom` list in its
+ * metadata; every removed entry is appended in full to the dedup log so a
+ * merge can be undone by hand.
+ */
+export async function dedupeMemory(request: {
+  basePath: string;
+  state: MemoryDedupStateAccess;
+  namespace?: string;
+  threshold?: number;
+  dryRun?: boolean;
+  now?: Date;
+}): Promise<RuntimeMemoryDedupResponse> {
+  const threshold = request.threshold ?? DEFAULT_DEDUP_THRESHOLD;
+  if (!Number.isFinite(threshold) || threshold <= 0 || threshold > 1) {
+    throw new Error(`Dedup threshold must be greater than 0 and at most 1, got ${threshold}.`);
+  }
+  const now = request.now ?? new Date();
+  const dryRun = request.dryRun === true;
+  const [memory, semantic] = await Promise.all([
+    request.state.listMemory(request.namespace),
+    request.state.listSemantic({ namespace: request.namespace }),
+  ]);
+
+  const groups: MemoryDuplicateGroup[] = [];
+  const log: Array<Record<string, unknown>> = [];
+  const removed = { memory: 0, semantic: 0 };
+
+  for (const cluster of clusterEntries(memory, (entry) => hashValue(entry.value), () => undefined, threshold)) {
+    const [kept, ...duplicates] = cluster;
+    groups.push(toGroup('memory', kept!.entry, duplicates));
+    for (const duplicate of duplicates) {
+      log.push({ type: 'memory', ...withoutUndefined(duplicate.entry), mergedInto: kept!.entry.key, reason: duplicate.reason, similarity: duplicate.similarity });
+      if (!dryRun && await request.state.deleteMemory(duplicate.entry.key, duplicate.entry.namespace)) {
+        removed.memory += 1;
+      }
+    }
+  }
+
+  for (const cluster of clusterEntries(semantic, (entry) => hashContent(entry.content), (entry) => entry.tokenFreq, threshold)) {
+    const [kept, ...duplicates] = cluster;
+    const survivor = kept!.entry;
+    groups.push(toGroup('semantic', survivor, duplicates));
+    for (const duplicate of duplicates) {
+      const { tokenFreq: _tokenFreq, ...entry } = duplicate.entry;
+      log.push({ type: 'semantic', ...withoutUndefined(entry), mergedInto: survivor.key, reason: duplicate.reason, similarity: duplicate.similarity });
+    }
+    if (dryRun) {
+      continue;
+    }
+    const previous = Array.isArray(survivor.metadata?.mergedFrom) ? survivor.metadata.mergedFrom : [];
+    await request.state.storeSemantic({
+      key: survivor.key,
+      namespace: survivor.namespace,
+      content: survivor.content,
+      tags: [...new Set(cluster.flatMap(({ entry }) => entry.tags))],
+      metadata: {
+        ...Object.assign({}, ...duplicates.map(({ entry }) => entry.metadata ?? {}).reverse()),
+        ...survivor.metadata,
+        mergedFrom: [
+          ...previous,
+          ...duplicates.flatMap(({ entry, reason, similarity }) => [
+            { key: entry.key, updatedAt: entry.updatedAt, reason, similarity, mergedAt: now.toISOString() },
+            ...(Array.isArray(entry.metadata?.mergedFrom) ? entry.metadata.mergedFrom : []),
+          ]),
+        ],
+      },
+      ttlMs: longestTtl(cluster.map(({ entry }) => entry), now),
+      agentId: survivor.agentId,
+      importance: survivor.importance,
+    });
+    for (const duplicate of duplicates) {
+      if (await request.state.deleteSemantic(duplicate.entry.key, duplicate.entry.namespace)) {
+        removed.semantic += 1;
+      }
+    }
+  }
+
+  let logPath: string | undefined;
+  if (!dryRun && log.length > 0) {
+    logPath = join(request.basePath, MEMORY_DEDUP_LOG_FILE);
+    await mkdir(dirname(logPath), { recursive: true });
+    await appendFile(logPath, log.map((line) => JSON.stringify({ dedupedAt: now.toISOString(), ...line })).join('\n') + '\n', 'utf8');
+  }
+  return { threshold, dryRun, groups, removed, ...(logPath !== undefined ? { logPath } : {}) };
+}
+
+export function cosineSimilarity(left: Record<string, number>, right: Record<string, number>): number {
+  let dot = 0;
+  for (const [term, count] of Object.entries(left)) {
+    dot += count * (right[term] ?? 0);
+  }
+  const magnitude = Math.sqrt(sumOfSquares(left)) * Math.sqrt(sumOfSquares(right));
+  return magnitude === 0 ? 0 : Number((dot / magnitude).toFixed(4));
+}
+
+interface ClusterMember<T> {
+  entry: T;
+  reason: MemoryDuplicateReason;
+  similarity: number;
+}
+
+// Greedy single pass, newest first: each entry joins the first kept entry it duplicates.
+function clusterEntries<T extends { key: string; namespace?: string; updatedAt: string }>(
+  entries: T[],
+  hash: (entry: T) => string,
+  embedding: (entry: T) => Record<string, number> | undefined,
+  threshold: number,
+): Array<Array<ClusterMember<T>>> {
+  const byNamespace = new Map<string, T[]>();
+  for (const entry of entries) {
+    const namespace = entry.namespace ?? '';
+    byNamespace.set(namespace, [...(byNamespace.get(namespace) ?? []), entry]);
+  }
+  const clusters: Array<Array<ClusterMember<T>>> = [];
+  for (const namespace of [...byNamespace.keys()].sort()) {
+    const sorted = byNamespace.get(namespace)!
+      .sort((left, right) => right.updatedAt.localeCompare(left.updatedAt) || left.key.localeCompare(right.key));
+    const heads: Array<{ hash: string; embedding?: Record<string, number>; members: Array<ClusterMember<T>> }> = [];
+    for (const entry of sorted) {
+      const entryHash = hash(entry);
+      const entryEmbedding = embedding(entry);
+      let joined = false;
+      for (const head of heads) {
+        if (head.hash === entryHash) {
+          head.members.push({ entry, reason: 'hash', similarity: 1 });
+          joined = true;
+          break;
+        }
+        const similarity = head.embedding !== undefined && entryEmbedding !== undefined ? cosineSimilarity(head.embedding, entryEmbedding) : 0;
+        if (similarity >= threshold) {
+          head.members.push({ entry, reason: 'similarity', similarity });
+          joined = true;
+          break;
+        }
+      }
+      if (!joined) {
+        heads.push({ hash: entryHash, embedding: entryEmbedding, members: [{ entry, reason: 'hash', similarity: 1 }] });
+      }
+    }
+    clusters.push(...heads.map((head) => head.members).filter((members) => members.length > 1));
+  }
+  return clusters;
+}
+
+function toGroup<T extends { key: string; namespace?: string; updatedAt: string }>(
+  type: MemoryDuplicateGroup['type'],
+  kept: T,
+  duplicates: Array<ClusterMember<T>>,
+): MemoryDuplicateGroup {
+  return {
+    type,
+    ...(kept.namespace !== undefined ? { namespace: kept.namespace } : {}),
+    kept: kept.key,
+    duplicates: duplicates.map(({ entry, reason, similarity }) => ({ key: entry.key, updatedAt: entry.updatedAt, reason, similarity })),
+  };
+}
+
+// No TTL if any merged entry had none; otherwise the latest expiry in the group.
+function longestTtl(entries: Array<{ expiresAt?: string }>, now: Date): number | undefined {
+  if (entries.some((entry) => entry.expiresAt === undefined)) {
+    return undefined;
+  }
+  const latest = Math.max(...entries.map((entry) => Date.parse(entry.expiresAt!)));
+  return Math.max(1, latest - now.getTime());
+}
+
+function hashValue(value: unknown): string {
+  return createHash('sha256').update(JSON.stringify(sortKeys(value)) ?? 'undefined').digest('hex');
+}
+
+// Case and whitespace don't make semantic content distinct.
+function hashContent(content: string): string {
+  return createHash('sha256').update(content.trim().replace(/\s+/g, ' ').toLowerCase()).digest('hex');
+}
+
+function sortKeys(value: unknown): unknown {
+  if (Array.isArray(value)) {
+    return value.map(sortKeys);
+  }
+  if (value !== null && typeof value === 'object') {
+    return Object.fromEntries(Object.entries(value as Record<string, unknown>)
+      .sort(([left], [right]) => left.localeCompare(right))
+      .map(([key, entry]) => [key, sortKeys(entry)]));
+  }
+  return value;
+}
+
+function sumOfSquares(vector: Record<string, number>): number {
+  return Object.values(vector).reduce((sum, count) => sum + count * count, 0);
+}
+
+function withoutUndefined<T extends object>(value: T): Partial<T> {
+  return Object.fromEntries(Object.entries(value).filter(([, entry]) => entry !== undefined)) as Partial<T>;
+}
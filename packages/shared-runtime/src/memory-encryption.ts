@@ -0,0 +1,99 @@
+import { execFileSync } from 'node:child_process';
+import { readFileSync } from 'node:fs';
+import { join } from 'node:path';
+
+export const DEFAULT_MEMORY_KEY_ENV = 'AX_MEMORY_KEY';
+export const DEFAULT_MEMORY_KEYCHAIN_SERVICE = 'automatosx';
+export const DEFAULT_MEMORY_KEYCHAIN_ACCOUNT = 'memory';
+
+export interface MemoryEncryptionConfig {
+  keyEnv: string;
+  // Looked up when the environment variable is unset; absent when disabled.
+  keychain?: { service: string; account: string };
+}
+
+export type KeychainReader = (service: string, account: string) => string | undefined;
+
+// `memory.encryption` in config: `true`, or `{"keyEnv": ..., "keychain": {"service": ..., "account": ...} | false}`.
+export function resolveMemoryEncryptionConfig(value: unknown): MemoryEncryptionConfig | undefined {
+  if (value === true) {
+    return {
+      keyEnv: DEFAULT_MEMORY_KEY_ENV,
+      keychain: { service: DEFAULT_MEMORY_KEYCHAIN_SERVICE, account: DEFAULT_MEMORY_KEYCHAIN_ACCOUNT },
+    };
+  }
+  if (!isRecord(value) || value.enabled === false) {
+    return undefined;
+  }
+  const keychain = isRecord(value.keychain) ? value.keychain : {};
+  return {
+    keyEnv: typeof value.keyEnv === 'string' && value.keyEnv.length > 0 ? value.keyEnv : DEFAULT_MEMORY_KEY_ENV,
+    ...(value.keychain !== false
+      ? {
+        keychain: {
+          service: typeof keychain.service === 'string' ? keychain.service : DEFAULT_MEMORY_KEYCHAIN_SERVICE,
+          account: typeof keychain.account === 'string' ? keychain.account : DEFAULT_MEMORY_KEYCHAIN_ACCOUNT,
+        },
+      }
+      : {}),
+  };
+}
+
+/**
+ * The key for an encrypted workspace: the configured environment variable,
+ * then the OS keychain. Throws rather than returning nothing, so memory is
+ * never written in plaintext to a workspace that asked for encryption.
+ */
+export function readMemoryEncryptionKey(
+  config: MemoryEncryptionConfig,
+  env: NodeJS.ProcessEnv = process.env,
+  readKeychain: KeychainReader = readKeychainSecret,
+): string {
+  const fromEnv = env[config.keyEnv];
+  if (fromEnv !== undefined && fromEnv.length > 0) {
+    return fromEnv;
+  }
+  const fromKeychain = config.keychain !== undefined ? readKeychain(config.keychain.service, config.keychain.account) : undefined;
+  if (fromKeychain !== undefined && fromKeychain.length > 0) {
+    return fromKeychain;
+  }
+  throw new Error(config.keychain !== undefined
+    ? `memory.encryption is enabled but no key was found: set ${config.keyEnv} or store one in the OS keychain (service "${config.keychain.service}", account "${config.keychain.account}").`
+    : `memory.encryption is enabled but ${config.keyEnv} is not set.`);
+}
+
+// Read synchronously: the state store is opened while the runtime is constructed.
+export function loadMemoryEncryptionKey(
+  basePath: string,
+  env: NodeJS.ProcessEnv = process.env,
+  readKeychain: KeychainReader = readKeychainSecret,
+): string | undefined {
+  let config: unknown;
+  try {
+    config = JSON.parse(readFileSync(join(basePath, '.automatosx', 'config.json'), 'utf8')) as unknown;
+  } catch {
+    return undefined;
+  }
+  const encryption = resolveMemoryEncryptionConfig(isRecord(config) && isRecord(config.memory) ? config.memory.encryption : undefined);
+  return encryption !== undefined ? readMemoryEncryptionKey(encryption, env, readKeychain) : undefined;
+}
+
+// macOS Keychain via `security`, or the Secret Service via `secret-tool` elsewhere.
+export function readKeychainSecret(service: string, account: string): string | undefined {
+  if (process.platform === 'win32') {
+    return undefined;
+  }
+  const [command, args]: [string, string[]] = process.platform === 'darwin'
+    ? ['security', ['find-generic-password', '-s', service, '-a', account, '-w']]
+    : ['secret-tool', ['lookup', 'service', service, 'account', account]];
+  try {
+    const secret = execFileSync(command, args, { encoding: 'utf8', stdio: ['ignore', 'pipe', 'ignore'], timeout: 5_000 }).trim();
+    return secret.length > 0 ? secret : undefined;
+  } catch {
+    return undefined;
+  }
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
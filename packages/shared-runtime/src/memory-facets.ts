@@ -0,0 +1,134 @@
+import type { MemoryEntry, SemanticEntry } from '@defai.digital/state-store';
+
+const DEFAULT_FACET_LIMIT = 20;
+
+// Narrows memory search and facet counts; every given field must match.
+export interface MemoryFilter {
+  // Entries carrying all of these tags.
+  tags?: string[];
+  agentId?: string;
+  // Bounds on updatedAt, inclusive. ISO timestamps or dates.
+  since?: string;
+  until?: string;
+  // A file or directory; matches entries about that path or anything under it.
+  path?: string;
+}
+
+export interface MemoryFacetCount {
+  value: string;
+  count: number;
+}
+
+export interface RuntimeMemoryFacetsResponse {
+  total: number;
+  namespaces: MemoryFacetCount[];
+  tags: MemoryFacetCount[];
+  agents: MemoryFacetCount[];
+  paths: MemoryFacetCount[];
+}
+
+/**
+ * Lowercases tags, turns dates into ISO timestamps, and trims `./` and
+ * trailing slashes from the path. Throws on a date that does not parse.
+ */
+export function normalizeMemoryFilter(filter: MemoryFilter = {}): MemoryFilter {
+  const tags = Array.from(new Set((filter.tags ?? []).map((tag) => tag.trim().toLowerCase()).filter((tag) => tag.length > 0))).sort();
+  const path = filter.path !== undefined ? normalizePath(filter.path) : '';
+  return {
+    ...(tags.length > 0 ? { tags } : {}),
+    ...(filter.agentId !== undefined && filter.agentId.length > 0 ? { agentId: filter.agentId } : {}),
+    ...(filter.since !== undefined && filter.since.length > 0 ? { since: parseFilterDate('since', filter.since) } : {}),
+    ...(filter.until !== undefined && filter.until.length > 0 ? { until: parseFilterDate('until', filter.until) } : {}),
+    ...(path.length > 0 ? { path } : {}),
+  };
+}
+
+export function isMemoryFilterEmpty(filter: MemoryFilter): boolean {
+  return filter.tags === undefined && filter.agentId === undefined && filter.since === undefined
+    && filter.until === undefined && filter.path === undefined;
+}
+
+// Expects a filter from normalizeMemoryFilter.
+export function matchesMemoryFilter(entry: MemoryEntry | SemanticEntry, filter: MemoryFilter): boolean {
+  if (filter.tags !== undefined && !filter.tags.every((tag) => (entry.tags ?? []).includes(tag))) {
+    return false;
+  }
+  if (filter.agentId !== undefined && entry.agentId !== filter.agentId) {
+    return false;
+  }
+  if (filter.since !== undefined && entry.updatedAt < filter.since) {
+    return false;
+  }
+  if (filter.until !== undefined && entry.updatedAt > filter.until) {
+    return false;
+  }
+  if (filter.path !== undefined) {
+    const path = memoryEntryPath(entry);
+    return path !== undefined && (path === filter.path || path.startsWith(`${filter.path}/`));
+  }
+  return true;
+}
+
+/**
+ * The artifact an entry is about: `metadata.path` on semantic entries (set by
+ * storeSemanticFile), or a string `path` on an object key-value entry.
+ */
+export function memoryEntryPath(entry: MemoryEntry | SemanticEntry): string | undefined {
+  const source = 'content' in entry ? entry.metadata : entry.value;
+  if (source === null || typeof source !== 'object' || Array.isArray(source)) {
+    return undefined;
+  }
+  const path = (source as Record<string, unknown>).path;
+  return typeof path === 'string' && path.length > 0 ? normalizePath(path) : undefined;
+}
+
+// Most frequent values first, at most `limit` of each facet.
+export function countMemoryFacets(entries: Array<MemoryEntry | SemanticEntry>, limit = DEFAULT_FACET_LIMIT): RuntimeMemoryFacetsResponse {
+  const namespaces = new Map<string, number>();
+  const tags = new Map<string, number>();
+  const agents = new Map<string, number>();
+  const paths = new Map<string, number>();
+  for (const entry of entries) {
+    increment(namespaces, entry.namespace ?? 'default');
+    for (const tag of entry.tags ?? []) {
+      increment(tags, tag);
+    }
+    if (entry.agentId !== undefined) {
+      increment(agents, entry.agentId);
+    }
+    const path = memoryEntryPath(entry);
+    if (path !== undefined) {
+      increment(paths, path);
+    }
+  }
+  return {
+    total: entries.length,
+    namespaces: topCounts(namespaces, limit),
+    tags: topCounts(tags, limit),
+    agents: topCounts(agents, limit),
+    paths: topCounts(paths, limit),
+  };
+}
+
+function increment(counts: Map<string, number>, value: string): void {
+  counts.set(value, (counts.get(value) ?? 0) + 1);
+}
+
+function topCounts(counts: Map<string, number>, limit: number): MemoryFacetCount[] {
+  return [...counts]
+    .map(([value, count]) => ({ value, count }))
+    .sort((left, right) => right.count - left.count || left.value.localeCompare(right.value))
+    .slice(0, Math.max(0, limit));
+}
+
+function parseFilterDate(field: 'since' | 'until', value: string): string {
+  const time = Date.parse(value);
+  if (Number.isNaN(time)) {
+    throw new Error(`Invalid ${field} date: ${value}`);
+  }
+  return new Date(time).toISOString();
+}
+
+function normalizePath(path: string): string {
+  return path.trim().replace(/\\/g, '/').replace(/^(\.\/)+/, '').replace(/\/+$/, '');
+}
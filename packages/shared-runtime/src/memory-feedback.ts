@@ -0,0 +1,181 @@
+import { mkdir, readFile, writeFile } from 'node:fs/promises';
+import { dirname, join } from 'node:path';
+
+export const MEMORY_FEEDBACK_FILE = join('.automatosx', 'runtime', 'memory-feedback.json');
+
+// Older votes on an entry are dropped past this many.
+const MAX_VOTES_PER_ENTRY = 50;
+// The most feedback can scale a score: by 1 ± this.
+const MAX_BOOST = 0.5;
+// Pseudo-votes of no opinion, so one vote moves an entry a little and many move it more.
+const PRIOR_VOTES = 2;
+// A vote given for an unrelated query still counts this much; one for the same query counts fully.
+const UNRELATED_QUERY_WEIGHT = 0.5;
+
+export type MemoryFeedbackKind = 'memory' | 'semantic';
+
+export interface MemoryFeedbackVote {
+  helpful: boolean;
+  // The search that retrieved the entry, when known.
+  query?: string;
+  agentId?: string;
+  at: string;
+}
+
+export interface MemoryFeedbackRecord {
+  kind: MemoryFeedbackKind;
+  // Memory scope the entry belongs to; feedback in one project does not rerank another's.
+  scope?: string;
+  namespace?: string;
+  key: string;
+  votes: MemoryFeedbackVote[];
+}
+
+export interface MemoryFeedbackFile {
+  version: 1;
+  entries: Record<string, MemoryFeedbackRecord>;
+}
+
+export interface RuntimeMemoryFeedbackResponse {
+  kind: MemoryFeedbackKind;
+  namespace?: string;
+  key: string;
+  helpful: number;
+  unhelpful: number;
+  // What a search with no query overlap now multiplies the entry's score by.
+  boost: number;
+}
+
+export async function readMemoryFeedback(basePath: string): Promise<MemoryFeedbackFile> {
+  try {
+    const parsed = JSON.parse(await readFile(join(basePath, MEMORY_FEEDBACK_FILE), 'utf8')) as unknown;
+    if (isRecord(parsed) && isRecord(parsed.entries)) {
+      return { version: 1, entries: parsed.entries as Record<string, MemoryFeedbackRecord> };
+    }
+  } catch {
+    // No feedback yet, or an unreadable file: rank without it.
+  }
+  return { version: 1, entries: {} };
+}
+
+export async function appendMemoryFeedback(
+  basePath: string,
+  entry: { kind: MemoryFeedbackKind; scope?: string; namespace?: string; key: string },
+  vote: Omit<MemoryFeedbackVote, 'at'>,
+  now = new Date(),
+): Promise<RuntimeMemoryFeedbackResponse> {
+  const feedback = await readMemoryFeedback(basePath);
+  const id = feedbackId(entry.kind, entry.scope, entry.namespace, entry.key);
+  const record: MemoryFeedbackRecord = feedback.entries[id] ?? {
+    kind: entry.kind,
+    ...(entry.scope !== undefined ? { scope: entry.scope } : {}),
+    ...(entry.namespace !== undefined ? { namespace: entry.namespace } : {}),
+    key: entry.key,
+    votes: [],
+  };
+  const query = vote.query?.trim();
+  record.votes = [
+    ...record.votes,
+    {
+      helpful: vote.helpful,
+      ...(query !== undefined && query.length > 0 ? { query } : {}),
+      ...(vote.agentId !== undefined ? { agentId: vote.agentId } : {}),
+      at: now.toISOString(),
+    },
+  ].slice(-MAX_VOTES_PER_ENTRY);
+  feedback.entries[id] = record;
+
+  const path = join(basePath, MEMORY_FEEDBACK_FILE);
+  await mkdir(dirname(path), { recursive: true });
+  await writeFile(path, `${JSON.stringify(feedback, null, 2)}\n`, 'utf8');
+  const helpful = record.votes.filter((item) => item.helpful).length;
+  return {
+    kind: entry.kind,
+    ...(entry.namespace !== undefined ? { namespace: entry.namespace } : {}),
+    key: entry.key,
+    helpful,
+    unhelpful: record.votes.length - helpful,
+    boost: feedbackBoost(record.votes, ''),
+  };
+}
+
+/**
+ * The factor feedback applies to an entry's score for `query`, between
+ * 1 - MAX_BOOST and 1 + MAX_BOOST. Votes given for searches sharing terms
+ * with `query` count more than votes given for unrelated ones.
+ */
+export function feedbackBoost(votes: MemoryFeedbackVote[], query: string): number {
+  if (votes.length === 0) {
+    return 1;
+  }
+  const terms = queryTerms(query);
+  let signal = 0;
+  let weight = 0;
+  for (const vote of votes) {
+    const voteWeight = UNRELATED_QUERY_WEIGHT + (1 - UNRELATED_QUERY_WEIGHT) * overlap(terms, queryTerms(vote.query ?? ''));
+    signal += vote.helpful ? voteWeight : -voteWeight;
+    weight += voteWeight;
+  }
+  return Number((1 + MAX_BOOST * (signal / (weight + PRIOR_VOTES))).toFixed(4));
+}
+
+/**
+ * Reorders search results by their feedback. Scored (semantic) results have
+ * their score scaled and are re-sorted by it; unscored ones keep the store's
+ * order among entries with equal boosts.
+ */
+export function rerankByFeedback<T extends { key: string; namespace?: string; score?: number }>(
+  results: T[],
+  feedback: MemoryFeedbackFile,
+  kind: MemoryFeedbackKind,
+  scope: string | undefined,
+  query: string,
+): T[] {
+  const boosts = results.map((result) => {
+    const record = feedback.entries[feedbackId(kind, scope, result.namespace, result.key)];
+    return record !== undefined ? feedbackBoost(record.votes, query) : 1;
+  });
+  if (boosts.every((boost) => boost === 1)) {
+    return results;
+  }
+  return results
+    .map((result, index) => ({
+      result: result.score !== undefined && boosts[index] !== 1 ? { ...result, score: result.score * (boosts[index] ?? 1) } : result,
+      boost: boosts[index] ?? 1,
+      index,
+    }))
+    .sort((left, right) => left.result.score !== undefined && right.result.score !== undefined
+      ? right.result.score - left.result.score || left.index - right.index
+      : right.boost - left.boost || left.index - right.index)
+    .map(({ result }) => result);
+}
+
+export function hasMemoryFeedback(feedback: MemoryFeedbackFile, kind: MemoryFeedbackKind, scope: string | undefined): boolean {
+  return Object.values(feedback.entries).some((record) => record.kind === kind && record.scope === scope);
+}
+
+function feedbackId(kind: MemoryFeedbackKind, scope: string | undefined, namespace: string | undefined, key: string): string {
+  return JSON.stringify([kind, scope ?? null, namespace ?? 'default', key]);
+}
+
+function queryTerms(query: string): Set<string> {
+  return new Set(query.toLowerCase().split(/[^\p{L}\p{N}_]+/u).filter((term) => term.length > 1));
+}
+
+// Jaccard similarity of two term sets; 0 when either is empty.
+function overlap(left: Set<string>, right: Set<string>): number {
+  if (left.size === 0 || right.size === 0) {
+    return 0;
+  }
+  let shared = 0;
+  for (const term of left) {
+    if (right.has(term)) {
+      shared += 1;
+    }
+  }
+  return shared / (left.size + right.size - shared);
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
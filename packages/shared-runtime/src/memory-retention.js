@@ -0,0 +1,110 @@
+import { mkdir, readFile, writeFile } from 'node:fs/promises';
+import { dirname, join } from 'node:path';
+import { DEFAULT_HISTORY_MAX_AGE_MS } from '@defai.digital/state-store';
+export const MEMORY_PRUNE_FILE = join('.automatosx', 'runtime', 'memory-prune.json');
+const DAY_MS = 24 * 60 * 60 * 1000;
+const DEFAULT_PRUNE_INTERVAL_MINUTES = 60;
+export function resolveMemoryRetentionConfig(value) {
+    const retention = isRecord(value) && isRecord(value.retention) ? value.retention : {};
+    const maxAgeDays = positive(retention.maxAgeDays);
+    const maxEntries = positive(retention.maxEntries);
+    const maxBytes = positive(retention.maxBytes);
+    const historyDays = positive(retention.historyDays);
+    const policy = {
+        ...(maxAgeDays !== undefined ? { maxAgeMs: Math.round(maxAgeDays * DAY_MS) } : {}),
+        ...(maxEntries !== undefined ? { maxEntries: Math.floor(maxEntries) } : {}),
+        ...(maxBytes !== undefined ? { maxBytes: Math.floor(maxBytes) } : {}),
+        historyMaxAgeMs: historyDays !== undefined ? Math.round(historyDays * DAY_MS) : DEFAULT_HISTORY_MAX_AGE_MS,
+    };
+    const interval = typeof retention.pruneIntervalMinutes === 'number' && retention.pruneIntervalMinutes >= 0
+        ? retention.pruneIntervalMinutes
+        : DEFAULT_PRUNE_INTERVAL_MINUTES;
+    const quotas = isRecord(value) && isRecord(value.quotas) ? value.quotas : {};
+    const agents = isRecord(quotas.agents) ? quotas.agents : {};
+    return {
+        enabled: Object.keys(policy).length > 0,
+        policy,
+        pruneIntervalMs: Math.round(interval * 60 * 1000),
+        quotas: {
+            default: readQuota(quotas),
+            agents: Object.fromEntries(Object.entries(agents).flatMap(([agentId, quota]) => {
+                const resolved = isRecord(quota) ? readQuota(quota) : undefined;
+                return resolved !== undefined ? [[agentId, resolved]] : [];
+            })),
+        },
+    };
+}
+// The quota an agent's writes are held to, if any limit applies to it.
+export function memoryQuotaFor(config, agentId) {
+    const quota = { ...config.default, ...config.agents[agentId] };
+    return quota.maxEntries !== undefined || quota.maxBytes !== undefined ? quota : undefined;
+}
+/**
+ * Holds each agent to its quota, for the agents that own entries plus any
+ * named in the config, and reports only those that had entries evicted.
+ */
+export async function enforceMemoryQuotas(config, agentIds, enforce, now = new Date()) {
+    const results = [];
+    for (const agentId of new Set([...agentIds, ...Object.keys(config.agents)])) {
+        const quota = memoryQuotaFor(config, agentId);
+        if (quota === undefined) {
+            continue;
+        }
+        const result = await enforce(agentId, quota, now);
+        if (result.evicted.length > 0) {
+            results.push(result);
+        }
+    }
+    return results.sort((left, right) => left.agentId.localeCompare(right.agentId));
+}
+export async function pruneMemoryNow(basePath, config, prune, now = new Date()) {
+    const result = await prune(config.policy, now);
+    const response = { ...result, prunedAt: now.toISOString(), policy: config.policy };
+    const path = join(basePath, MEMORY_PRUNE_FILE);
+    await mkdir(dirname(path), { recursive: true });
+    await writeFile(path, `${JSON.stringify(response, null, 2)}\n`, 'utf8');
+    return response;
+}
+/**
+ * The background pruner: runs pruneMemoryNow when retention is configured
+ * and the last prune (from any process) is at least `pruneIntervalMs` old.
+ * Called after memory writes, so a project that stops writing stops paying
+ * for it.
+ */
+export async function pruneMemoryIfDue(basePath, config, prune, now = new Date()) {
+    if (!config.enabled || config.pruneIntervalMs === 0) {
+        return undefined;
+    }
+    const last = await readLastPrune(basePath);
+    if (last !== undefined && now.getTime() - Date.parse(last) < config.pruneIntervalMs) {
+        return undefined;
+    }
+    return pruneMemoryNow(basePath, config, prune, now);
+}
+async function readLastPrune(basePath) {
+    try {
+        const parsed = JSON.parse(await readFile(join(basePath, MEMORY_PRUNE_FILE), 'utf8'));
+        return isRecord(parsed) && typeof parsed.prunedAt === 'string' ? parsed.prunedAt : undefined;
+    }
+    catch {
+        return undefined;
+    }
+}
+function readQuota(value) {
+    const maxEntries = positive(value.maxEntries);
+    const maxBytes = positive(value.maxBytes);
+    const quota = {
+        ...(maxEntries !== undefined ? { maxEntries: Math.floor(maxEntries) } : {}),
+        ...(maxBytes !== undefined ? { maxBytes: Math.floor(maxBytes) } : {}),
+        ...(value.eviction === 'oldest-write' || value.eviction === 'importance' ? { eviction: value.eviction } : {}),
+        // `lru` was this policy's old name, though it never tracked reads.
+        ...(value.eviction === 'lru' ? { eviction: 'oldest-write' } : {}),
+    };
+    return Object.keys(quota).length > 0 ? quota : undefined;
+}
+function positive(value) {
+    return typeof value === 'number' && Number.isFinite(value) && value > 0 ? value : undefined;
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,159 @@
+import { mkdir, readFile, writeFile } from 'node:fs/promises';
+import { dirname, join } from 'node:path';
+import { DEFAULT_HISTORY_MAX_AGE_MS, type MemoryPruneResult, type MemoryQuota, type MemoryQuotaResult, type MemoryRetentionPolicy } from '@defai.digital/state-store';
+
+export const MEMORY_PRUNE_FILE = join('.automatosx', 'runtime', 'memory-prune.json');
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+const DEFAULT_PRUNE_INTERVAL_MINUTES = 60;
+
+export interface MemoryRetentionConfig {
+  // True once any limit applies; the background pruner only runs then. History
+  // always has an age limit, so by default it runs to drop old versions.
+  enabled: boolean;
+  policy: MemoryRetentionPolicy;
+  // Minimum time between background prunes; 0 turns the background pruner off.
+  pruneIntervalMs: number;
+  quotas: MemoryQuotaConfig;
+}
+
+// Per-agent caps from `memory.quotas`: the top-level limits apply to every
+// agent, and `agents.<id>` overrides them for one agent.
+export interface MemoryQuotaConfig {
+  default?: MemoryQuota;
+  agents: Record<string, MemoryQuota>;
+}
+
+export interface RuntimeMemoryPruneResponse extends MemoryPruneResult {
+  prunedAt: string;
+  policy: MemoryRetentionPolicy;
+  // Agents whose quota evicted entries during an explicit prune.
+  quotas?: MemoryQuotaResult[];
+}
+
+export function resolveMemoryRetentionConfig(value: unknown): MemoryRetentionConfig {
+  const retention = isRecord(value) && isRecord(value.retention) ? value.retention : {};
+  const maxAgeDays = positive(retention.maxAgeDays);
+  const maxEntries = positive(retention.maxEntries);
+  const maxBytes = positive(retention.maxBytes);
+  const historyDays = positive(retention.historyDays);
+  const policy: MemoryRetentionPolicy = {
+    ...(maxAgeDays !== undefined ? { maxAgeMs: Math.round(maxAgeDays * DAY_MS) } : {}),
+    ...(maxEntries !== undefined ? { maxEntries: Math.floor(maxEntries) } : {}),
+    ...(maxBytes !== undefined ? { maxBytes: Math.floor(maxBytes) } : {}),
+    historyMaxAgeMs: historyDays !== undefined ? Math.round(historyDays * DAY_MS) : DEFAULT_HISTORY_MAX_AGE_MS,
+  };
+  const interval = typeof retention.pruneIntervalMinutes === 'number' && retention.pruneIntervalMinutes >= 0
+    ? retention.pruneIntervalMinutes
+    : DEFAULT_PRUNE_INTERVAL_MINUTES;
+  const quotas = isRecord(value) && isRecord(value.quotas) ? value.quotas : {};
+  const agents = isRecord(quotas.agents) ? quotas.agents : {};
+  return {
+    enabled: Object.keys(policy).length > 0,
+    policy,
+    pruneIntervalMs: Math.round(interval * 60 * 1000),
+    quotas: {
+      default: readQuota(quotas),
+      agents: Object.fromEntries(Object.entries(agents).flatMap(([agentId, quota]) => {
+        const resolved = isRecord(quota) ? readQuota(quota) : undefined;
+        return resolved !== undefined ? [[agentId, resolved]] : [];
+      })),
+    },
+  };
+}
+
+// The quota an agent's writes are held to, if any limit applies to it.
+export function memoryQuotaFor(config: MemoryQuotaConfig, agentId: string): MemoryQuota | undefined {
+  const quota = { ...config.default, ...config.agents[agentId] };
+  return quota.maxEntries !== undefined || quota.maxBytes !== undefined ? quota : undefined;
+}
+
+/**
+ * Holds each agent to its quota, for the agents that own entries plus any
+ * named in the config, and reports only those that had entries evicted.
+ */
+export async function enforceMemoryQuotas(
+  config: MemoryQuotaConfig,
+  agentIds: Iterable<string>,
+  enforce: (agentId: string, quota: MemoryQuota, now: Date) => Promise<MemoryQuotaResult>,
+  now = new Date(),
+): Promise<MemoryQuotaResult[]> {
+  const results: MemoryQuotaResult[] = [];
+  for (const agentId of new Set([...agentIds, ...Object.keys(config.agents)])) {
+    const quota = memoryQuotaFor(config, agentId);
+    if (quota === undefined) {
+      continue;
+    }
+    const result = await enforce(agentId, quota, now);
+    if (result.evicted.length > 0) {
+      results.push(result);
+    }
+  }
+  return results.sort((left, right) => left.agentId.localeCompare(right.agentId));
+}
+
+export async function pruneMemoryNow(
+  basePath: string,
+  config: MemoryRetentionConfig,
+  prune: (policy: MemoryRetentionPolicy, now: Date) => Promise<MemoryPruneResult>,
+  now = new Date(),
+): Promise<RuntimeMemoryPruneResponse> {
+  const result = await prune(config.policy, now);
+  const response = { ...result, prunedAt: now.toISOString(), policy: config.policy };
+  const path = join(basePath, MEMORY_PRUNE_FILE);
+  await mkdir(dirname(path), { recursive: true });
+  await writeFile(path, `${JSON.stringify(response, null, 2)}\n`, 'utf8');
+  return response;
+}
+
+/**
+ * The background pruner: runs pruneMemoryNow when retention is configured
+ * and the last prune (from any process) is at least `pruneIntervalMs` old.
+ * Called after memory writes, so a project that stops writing stops paying
+ * for it.
+ */
+export async function pruneMemoryIfDue(
+  basePath: string,
+  config: MemoryRetentionConfig,
+  prune: (policy: MemoryRetentionPolicy, now: Date) => Promise<MemoryPruneResult>,
+  now = new Date(),
+): Promise<RuntimeMemoryPruneResponse | undefined> {
+  if (!config.enabled || config.pruneIntervalMs === 0) {
+    return undefined;
+  }
+  const last = await readLastPrune(basePath);
+  if (last !== undefined && now.getTime() - Date.parse(last) < config.pruneIntervalMs) {
+    return undefined;
+  }
+  return pruneMemoryNow(basePath, config, prune, now);
+}
+
+async function readLastPrune(basePath: string): Promise<string | undefined> {
+  try {
+    const parsed = JSON.parse(await readFile(join(basePath, MEMORY_PRUNE_FILE), 'utf8')) as unknown;
+    return isRecord(parsed) && typeof parsed.prunedAt === 'string' ? parsed.prunedAt : undefined;
+  } catch {
+    return undefined;
+  }
+}
+
+function readQuota(value: Record<string, unknown>): MemoryQuota | undefined {
+  const maxEntries = positive(value.maxEntries);
+  const maxBytes = positive(value.maxBytes);
+  const quota: MemoryQuota = {
+    ...(maxEntries !== undefined ? { maxEntries: Math.floor(maxEntries) } : {}),
+    ...(maxBytes !== undefined ? { maxBytes: Math.floor(maxBytes) } : {}),
+    ...(value.eviction === 'oldest-write' || value.eviction === 'importance' ? { eviction: value.eviction } : {}),
+    // `lru` was this policy's old name, though it never tracked reads.
+    ...(value.eviction === 'lru' ? { eviction: 'oldest-write' as const } : {}),
+  };
+  return Object.keys(quota).length > 0 ? quota : undefined;
+}
+
+function positive(value: unknown): number | undefined {
+  return typeof value === 'number' && Number.isFinite(value) && value > 0 ? value : undefined;
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,212 @@
+import { readdir, rm, stat } from 'node:fs/promises';
+import { join } from 'node:path';
+import type { MemoryEntry, SemanticEntry } from '@defai.digital/state-store';
+import {
+  exportMemoryBundle,
+  importMemoryBundle,
+  readMemoryBundle,
+  type MemoryBundleCounts,
+  type MemoryBundleStateAccess,
+} from './memory-bundle.js';
+
+export const MEMORY_SNAPSHOTS_DIR = join('.automatosx', 'memory-snapshots');
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+const DEFAULT_KEEP = 7;
+const SNAPSHOT_FILE = /^(memory-(\d{8}T\d{9}Z))\.jsonl(\.enc)?$/;
+
+export type MemorySnapshotSchedule = 'daily' | 'weekly';
+
+export interface MemorySnapshotConfig {
+  schedule?: MemorySnapshotSchedule;
+  // Snapshots kept by the scheduler and `ax memory snapshot`; older ones are deleted.
+  keep: number;
+}
+
+export interface MemorySnapshotSummary {
+  snapshotId: string;
+  path: string;
+  createdAt: string;
+  bytes: number;
+  encrypted: boolean;
+}
+
+export interface RuntimeMemorySnapshotResponse extends MemorySnapshotSummary {
+  counts: MemoryBundleCounts;
+  // Older snapshots deleted to stay within `keep`.
+  removed: string[];
+}
+
+export interface RuntimeMemoryRestoreResponse {
+  snapshotId: string;
+  createdAt: string;
+  restored: MemoryBundleCounts;
+  // Live entries that were not in the snapshot.
+  removed: MemoryBundleCounts;
+  // Taken before restoring, so the restore itself can be undone.
+  safetySnapshotId?: string;
+  dryRun: boolean;
+}
+
+export interface MemorySnapshotStateAccess extends MemoryBundleStateAccess {
+  deleteMemory(key: string, namespace?: string): Promise<boolean>;
+  deleteSemantic(key: string, namespace?: string): Promise<boolean>;
+}
+
+// `memory.snapshots` in config: `{"schedule": "daily" | "weekly", "keep": 7}`. Without a schedule nothing runs in the background.
+export function resolveMemorySnapshotConfig(memory: unknown): MemorySnapshotConfig {
+  const snapshots = isRecord(memory) && isRecord(memory.snapshots) ? memory.snapshots : {};
+  const keep = typeof snapshots.keep === 'number' && Number.isInteger(snapshots.keep) && snapshots.keep > 0 ? snapshots.keep : DEFAULT_KEEP;
+  return {
+    ...(snapshots.schedule === 'daily' || snapshots.schedule === 'weekly' ? { schedule: snapshots.schedule } : {}),
+    keep,
+  };
+}
+
+/**
+ * Writes every memory and semantic entry to a bundle under
+ * `.automatosx/memory-snapshots`, encrypted when memory is, then deletes the
+ * oldest snapshots beyond `keep` when given.
+ */
+export async function takeMemorySnapshot(request: {
+  basePath: string;
+  state: MemoryBundleStateAccess;
+  keep?: number;
+  encryptionKey?: string;
+  now?: Date;
+}): Promise<RuntimeMemorySnapshotResponse> {
+  const now = request.now ?? new Date();
+  const snapshotId = `memory-${now.toISOString().replace(/[-:.]/g, '')}`;
+  const exported = await exportMemoryBundle({
+    basePath: request.basePath,
+    state: request.state,
+    outputPath: join(request.basePath, MEMORY_SNAPSHOTS_DIR, `${snapshotId}.jsonl${request.encryptionKey !== undefined ? '.enc' : ''}`),
+    encryptionKey: request.encryptionKey,
+    now,
+  });
+  const removed: string[] = [];
+  if (request.keep !== undefined) {
+    for (const old of (await listMemorySnapshots(request.basePath)).slice(request.keep)) {
+      await rm(old.path, { force: true });
+      removed.push(old.snapshotId);
+    }
+  }
+  return {
+    snapshotId,
+    path: exported.outputPath,
+    createdAt: exported.exportedAt,
+    bytes: exported.bytes,
+    encrypted: exported.encrypted,
+    counts: exported.counts,
+    removed,
+  };
+}
+
+// Newest first.
+export async function listMemorySnapshots(basePath: string): Promise<MemorySnapshotSummary[]> {
+  const dir = join(basePath, MEMORY_SNAPSHOTS_DIR);
+  let files: string[];
+  try {
+    files = await readdir(dir);
+  } catch {
+    return [];
+  }
+  const snapshots: MemorySnapshotSummary[] = [];
+  for (const file of files) {
+    const match = SNAPSHOT_FILE.exec(file);
+    if (match === null) {
+      continue;
+    }
+    const path = join(dir, file);
+    snapshots.push({
+      snapshotId: match[1]!,
+      path,
+      createdAt: parseSnapshotTime(match[2]!),
+      bytes: (await stat(path)).size,
+      encrypted: match[3] !== undefined,
+    });
+  }
+  return snapshots.sort((left, right) => right.snapshotId.localeCompare(left.snapshotId));
+}
+
+/**
+ * The scheduler: takes a snapshot when one is configured and the newest is
+ * at least a day (or a week) old. Called after memory writes, like the
+ * retention pruner.
+ */
+export async function snapshotMemoryIfDue(request: {
+  basePath: string;
+  config: MemorySnapshotConfig;
+  state: MemoryBundleStateAccess;
+  encryptionKey?: string;
+  now?: Date;
+}): Promise<RuntimeMemorySnapshotResponse | undefined> {
+  if (request.config.schedule === undefined) {
+    return undefined;
+  }
+  const now = request.now ?? new Date();
+  const intervalMs = request.config.schedule === 'weekly' ? 7 * DAY_MS : DAY_MS;
+  const latest = (await listMemorySnapshots(request.basePath))[0];
+  if (latest !== undefined && now.getTime() - Date.parse(latest.createdAt) < intervalMs) {
+    return undefined;
+  }
+  return takeMemorySnapshot({ ...request, keep: request.config.keep, now });
+}
+
+/**
+ * Puts memory back the way a snapshot recorded it: entries missing from the
+ * snapshot are deleted and the snapshot's entries overwrite live ones. The
+ * current memory is snapshotted first and never pruned by this call, so a
+ * mistaken restore can be restored away.
+ */
+export async function restoreMemorySnapshot(request: {
+  basePath: string;
+  state: MemorySnapshotStateAccess;
+  // A snapshot id from listMemorySnapshots, or `latest`.
+  snapshotId: string;
+  encryptionKey?: string;
+  dryRun?: boolean;
+  now?: Date;
+}): Promise<RuntimeMemoryRestoreResponse> {
+  const snapshots = await listMemorySnapshots(request.basePath);
+  const snapshot = request.snapshotId === 'latest' ? snapshots[0] : snapshots.find((entry) => entry.snapshotId === request.snapshotId);
+  if (snapshot === undefined) {
+    throw new Error(request.snapshotId === 'latest'
+      ? `No memory snapshots found in ${MEMORY_SNAPSHOTS_DIR}.`
+      : `Memory snapshot not found: ${request.snapshotId}`);
+  }
+  const bundle = await readMemoryBundle(snapshot.path, request.encryptionKey);
+  const kept = new Set(bundle.records.map((record) => entryId(record.type, record)));
+  const [memory, semantic] = await Promise.all([request.state.listMemory(), request.state.listSemantic()]);
+  const staleMemory = memory.filter((entry) => !kept.has(entryId('memory', entry)));
+  const staleSemantic = semantic.filter((entry) => !kept.has(entryId('semantic', entry)));
+  const removed = { memory: staleMemory.length, semantic: staleSemantic.length };
+  const restored = { memory: bundle.header.counts.memory, semantic: bundle.header.counts.semantic };
+  if (request.dryRun === true) {
+    return { snapshotId: snapshot.snapshotId, createdAt: snapshot.createdAt, restored, removed, dryRun: true };
+  }
+
+  const safety = await takeMemorySnapshot({ basePath: request.basePath, state: request.state, encryptionKey: request.encryptionKey, now: request.now });
+  for (const entry of staleMemory) {
+    await request.state.deleteMemory(entry.key, entry.namespace);
+  }
+  for (const entry of staleSemantic) {
+    await request.state.deleteSemantic(entry.key, entry.namespace);
+  }
+  await importMemoryBundle({ inputPath: snapshot.path, state: request.state, overwrite: true, encryptionKey: request.encryptionKey });
+  return { snapshotId: snapshot.snapshotId, createdAt: snapshot.createdAt, restored, removed, safetySnapshotId: safety.snapshotId, dryRun: false };
+}
+
+function entryId(type: 'memory' | 'semantic', entry: Pick<MemoryEntry | SemanticEntry, 'key' | 'namespace'>): string {
+  return `${type}\u0000${entry.namespace ?? ''}\u0000${entry.key}`;
+}
+
+// `20261016T063111149Z` back to an ISO timestamp.
+function parseSnapshotTime(compact: string): string {
+  const [, year, month, day, hour, minute, second, ms] = /^(\d{4})(\d{2})(\d{2})T(\d{2})(\d{2})(\d{2})(\d{3})Z$/.exec(compact)!;
+  return `${year}-${month}-${day}T${hour}:${minute}:${second}.${ms}Z`;
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
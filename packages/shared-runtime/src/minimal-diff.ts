@@ -0,0 +1,126 @@
+import type { DiffHunk } from './patch-review.js';
+
+export const MINIMAL_DIFF_INSTRUCTION = 'Produce a targeted change: touch only the lines whose behavior must change and keep the existing formatting.';
+
+export interface MinimalDiffPolicy {
+  // Changed lines per hunk that differ from a removed line only in whitespace, quotes, or trailing punctuation.
+  maxCosmeticLines: number;
+  // Share of the original file a patch may rewrite before the whole file is bounced.
+  maxRewriteRatio: number;
+  // Files shorter than this are exempt from the rewrite ratio.
+  minFileLines: number;
+}
+
+export const DEFAULT_MINIMAL_DIFF_POLICY: MinimalDiffPolicy = {
+  maxCosmeticLines: 2,
+  maxRewriteRatio: 0.5,
+  minFileLines: 20,
+};
+
+export interface HunkDiffMeasure {
+  changedLines: number;
+  cosmeticLines: number;
+}
+
+export interface MinimalDiffViolation {
+  hunkIndex: number;
+  reason: string;
+}
+
+// Reads the `patch.minimalDiff` config key: true for the defaults, or an object overriding them. Off unless set.
+export function resolveMinimalDiffPolicy(config: unknown): MinimalDiffPolicy | undefined {
+  const value = config !== null && typeof config === 'object' ? (config as { minimalDiff?: unknown }).minimalDiff : undefined;
+  if (value === true) {
+    return { ...DEFAULT_MINIMAL_DIFF_POLICY };
+  }
+  if (value === null || typeof value !== 'object') {
+    return undefined;
+  }
+  const overrides = value as Partial<Record<keyof MinimalDiffPolicy, unknown>>;
+  const pick = (key: keyof MinimalDiffPolicy) => {
+    const entry = overrides[key];
+    return typeof entry === 'number' && Number.isFinite(entry) && entry >= 0 ? entry : DEFAULT_MINIMAL_DIFF_POLICY[key];
+  };
+  return {
+    maxCosmeticLines: pick('maxCosmeticLines'),
+    maxRewriteRatio: pick('maxRewriteRatio'),
+    minFileLines: pick('minFileLines'),
+  };
+}
+
+/**
+ * Counts changed lines in a hunk and how many of them are cosmetic: an added
+ * line is cosmetic when it matches a removed line once whitespace, quote
+ * characters, and trailing `;`/`,` are ignored, but is not identical to it.
+ */
+export function measureHunk(lines: string[]): HunkDiffMeasure {
+  const removed = lines.filter((line) => line.startsWith('-')).map((line) => line.slice(1));
+  const added = lines.filter((line) => line.startsWith('+')).map((line) => line.slice(1));
+  const exact = countLines(removed);
+  const normalized = countLines(removed.map(normalizeLine));
+  let cosmeticLines = 0;
+  for (const line of added) {
+    const exactCount = exact.get(line) ?? 0;
+    if (exactCount > 0) {
+      // An identical line is a move, not a reformat.
+      exact.set(line, exactCount - 1);
+      decrement(normalized, normalizeLine(line));
+      continue;
+    }
+    if (decrement(normalized, normalizeLine(line))) {
+      cosmeticLines += 1;
+    }
+  }
+  return { changedLines: Math.max(removed.length, added.length), cosmeticLines };
+}
+
+export function checkMinimalDiff(
+  hunks: Array<Pick<DiffHunk, 'index' | 'lines'>>,
+  originalLineCount: number,
+  policy: MinimalDiffPolicy,
+): MinimalDiffViolation[] {
+  const violations: MinimalDiffViolation[] = [];
+  let totalChanged = 0;
+  for (const hunk of hunks) {
+    const measure = measureHunk(hunk.lines);
+    totalChanged += measure.changedLines;
+    if (measure.cosmeticLines > policy.maxCosmeticLines) {
+      violations.push({
+        hunkIndex: hunk.index,
+        reason: `Reformats ${measure.cosmeticLines} of ${measure.changedLines} changed lines without changing behavior. ${MINIMAL_DIFF_INSTRUCTION}`,
+      });
+    }
+  }
+
+  if (originalLineCount >= policy.minFileLines && totalChanged / originalLineCount > policy.maxRewriteRatio) {
+    const percent = Math.round((totalChanged / originalLineCount) * 100);
+    const reason = `Rewrites ${totalChanged} of ${originalLineCount} lines (${percent}%), over the ${Math.round(policy.maxRewriteRatio * 100)}% minimal-diff limit. ${MINIMAL_DIFF_INSTRUCTION}`;
+    for (const hunk of hunks) {
+      if (!violations.some((violation) => violation.hunkIndex === hunk.index)) {
+        violations.push({ hunkIndex: hunk.index, reason });
+      }
+    }
+  }
+  return violations.sort((left, right) => left.hunkIndex - right.hunkIndex);
+}
+
+function normalizeLine(line: string): string {
+  return line.replace(/\s+/g, '').replace(/['"`]/g, '"').replace(/[;,]$/, '');
+}
+
+function countLines(lines: string[]): Map<string, number> {
+  const counts = new Map<string, number>();
+  for (const line of lines) {
+    counts.set(line, (counts.get(line) ?? 0) + 1);
+  }
+  return counts;
+}
+
+function decrement(counts: Map<string, number>, key: string): boolean {
+  const count = counts.get(key) ?? 0;
+  if (count === 0) {
+    return false;
+  }
+  counts.set(key, count - 1);
+  return true;
+}
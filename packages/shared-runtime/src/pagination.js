@@ -0,0 +1,53 @@
+import { createHash } from 'node:crypto';
+export const DEFAULT_PAGE_SIZE = 50;
+export const MAX_PAGE_SIZE = 500;
+/**
+ * Cuts one page out of a complete, ordered result list. Cursors are opaque
+ * tokens holding the next offset, a digest of `request` (the query and
+ * filters that produced `items`), and a digest of the results already served,
+ * each named by `identify`. A cursor passed with a different query is refused,
+ * and so is one whose earlier pages no longer match the results, e.g. after
+ * an entry was stored, deleted, or reranked between pages, since its offset
+ * would then skip or repeat entries. Start again without a cursor.
+ */
+export function paginate(items, page, request, identify = (item) => JSON.stringify(item) ?? '') {
+    const pageSize = page.pageSize ?? DEFAULT_PAGE_SIZE;
+    if (!Number.isInteger(pageSize) || pageSize < 1 || pageSize > MAX_PAGE_SIZE) {
+        throw new Error(`pageSize must be an integer from 1 to ${MAX_PAGE_SIZE}, not ${pageSize}`);
+    }
+    const digest = requestDigest(request);
+    const served = (end) => createHash('sha256').update(items.slice(0, end).map(identify).join('\n')).digest('base64url').slice(0, 16);
+    const offset = page.cursor === undefined ? 0 : decodeCursor(page.cursor, digest, served);
+    const next = offset + pageSize;
+    return {
+        items: items.slice(offset, next),
+        total: items.length,
+        offset,
+        ...(next < items.length ? { nextCursor: Buffer.from(JSON.stringify({ o: next, d: digest, v: served(next) })).toString('base64url') } : {}),
+    };
+}
+function decodeCursor(cursor, digest, served) {
+    let parsed;
+    try {
+        parsed = JSON.parse(Buffer.from(cursor, 'base64url').toString('utf8'));
+    }
+    catch {
+        throw new Error('Invalid cursor; pass the nextCursor of a previous page unchanged');
+    }
+    if (!isRecord(parsed) || typeof parsed.o !== 'number' || !Number.isInteger(parsed.o) || parsed.o < 0 || typeof parsed.d !== 'string' || typeof parsed.v !== 'string') {
+        throw new Error('Invalid cursor; pass the nextCursor of a previous page unchanged');
+    }
+    if (parsed.d !== digest) {
+        throw new Error('This cursor belongs to a different query; repeat the query and filters it came from, or start again without a cursor');
+    }
+    if (parsed.v !== served(parsed.o)) {
+        throw new Error('The results changed since this cursor was issued; start again without a cursor');
+    }
+    return parsed.o;
+}
+function requestDigest(request) {
+    return createHash('sha256').update(JSON.stringify(request) ?? '').digest('base64url').slice(0, 16);
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,74 @@
+import { createHash } from 'node:crypto';
+
+export const DEFAULT_PAGE_SIZE = 50;
+export const MAX_PAGE_SIZE = 500;
+
+export interface PageRequest {
+  // Results per page, from 1 to MAX_PAGE_SIZE; DEFAULT_PAGE_SIZE unless given.
+  pageSize?: number;
+  // The nextCursor of the previous page; without one, the first page.
+  cursor?: string;
+}
+
+export interface Page<T> {
+  items: T[];
+  // Every result across all pages.
+  total: number;
+  // Position of the first item among all results.
+  offset: number;
+  // Absent on the last page.
+  nextCursor?: string;
+}
+
+/**
+ * Cuts one page out of a complete, ordered result list. Cursors are opaque
+ * tokens holding the next offset, a digest of `request` (the query and
+ * filters that produced `items`), and a digest of the results already served,
+ * each named by `identify`. A cursor passed with a different query is refused,
+ * and so is one whose earlier pages no longer match the results, e.g. after
+ * an entry was stored, deleted, or reranked between pages, since its offset
+ * would then skip or repeat entries. Start again without a cursor.
+ */
+export function paginate<T>(items: T[], page: PageRequest, request: unknown, identify: (item: T) => string = (item) => JSON.stringify(item) ?? ''): Page<T> {
+  const pageSize = page.pageSize ?? DEFAULT_PAGE_SIZE;
+  if (!Number.isInteger(pageSize) || pageSize < 1 || pageSize > MAX_PAGE_SIZE) {
+    throw new Error(`pageSize must be an integer from 1 to ${MAX_PAGE_SIZE}, not ${pageSize}`);
+  }
+  const digest = requestDigest(request);
+  const served = (end: number) => createHash('sha256').update(items.slice(0, end).map(identify).join('\n')).digest('base64url').slice(0, 16);
+  const offset = page.cursor === undefined ? 0 : decodeCursor(page.cursor, digest, served);
+  const next = offset + pageSize;
+  return {
+    items: items.slice(offset, next),
+    total: items.length,
+    offset,
+    ...(next < items.length ? { nextCursor: Buffer.from(JSON.stringify({ o: next, d: digest, v: served(next) })).toString('base64url') } : {}),
+  };
+}
+
+function decodeCursor(cursor: string, digest: string, served: (end: number) => string): number {
+  let parsed: unknown;
+  try {
+    parsed = JSON.parse(Buffer.from(cursor, 'base64url').toString('utf8'));
+  } catch {
+    throw new Error('Invalid cursor; pass the nextCursor of a previous page unchanged');
+  }
+  if (!isRecord(parsed) || typeof parsed.o !== 'number' || !Number.isInteger(parsed.o) || parsed.o < 0 || typeof parsed.d !== 'string' || typeof parsed.v !== 'string') {
+    throw new Error('Invalid cursor; pass the nextCursor of a previous page unchanged');
+  }
+  if (parsed.d !== digest) {
+    throw new Error('This cursor belongs to a different query; repeat the query and filters it came from, or start again without a cursor');
+  }
+  if (parsed.v !== served(parsed.o)) {
+    throw new Error('The results changed since this cursor was issued; start again without a cursor');
+  }
+  return parsed.o;
+}
+
+function requestDigest(request: unknown): string {
+  return createHash('sha256').update(JSON.stringify(request) ?? '').digest('base64url').slice(0, 16);
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
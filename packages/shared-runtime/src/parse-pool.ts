@@ -0,0 +1,204 @@
+import { mkdir, readFile, rename, writeFile } from 'node:fs/promises';
+import { availableParallelism } from 'node:os';
+import { dirname, join } from 'node:path';
+import { Worker } from 'node:worker_threads';
+import type { IdentifierReference } from './reference-index.js';
+import type { SymbolMatch } from './symbol-query.js';
+
+// Written while files are parsed, for `ax monitor` and `/api/index-progress`.
+export const INDEX_PROGRESS_FILE = join('.automatosx', 'runtime', 'index-progress.json');
+
+const DEFAULT_MAX_WORKERS = 4;
+const DEFAULT_MAX_IN_FLIGHT_BYTES = 16 * 1024 * 1024;
+// Below this many files, starting threads costs more than parsing them here.
+const MIN_POOLED_FILES = 32;
+const PROGRESS_INTERVAL_MS = 500;
+
+export interface ParserPoolConfig {
+  // Parse threads; 0 parses every file on the calling thread.
+  workers: number;
+  // Source bytes handed to threads and not yet parsed, across all threads.
+  maxInFlightBytes: number;
+}
+
+export interface ParseJob {
+  path: string;
+  absolutePath: string;
+  size: number;
+  mtimeMs: number;
+  // False for files a plugin extractor handles, which only the calling thread has loaded.
+  pooled: boolean;
+}
+
+export interface ParsedSource {
+  lines: string[];
+  declarations: SymbolMatch[];
+  references: IdentifierReference[];
+}
+
+export interface IndexProgress {
+  startedAt: string;
+  updatedAt: string;
+  // Unset while parsing is under way, or when the process stopped before it finished.
+  finishedAt?: string;
+  // Files that changed since they were last indexed.
+  total: number;
+  parsed: number;
+  failed: number;
+  workers: number;
+}
+
+// `parser` in config: `{ "workers": 4, "maxInFlightBytes": 16777216 }`. Workers default to one less than the CPUs, at most 4.
+export function resolveParserPoolConfig(parser: unknown): ParserPoolConfig {
+  const value = isRecord(parser) ? parser : {};
+  return {
+    workers: typeof value.workers === 'number' && Number.isInteger(value.workers) && value.workers >= 0
+      ? value.workers
+      : Math.min(DEFAULT_MAX_WORKERS, Math.max(0, availableParallelism() - 1)),
+    maxInFlightBytes: typeof value.maxInFlightBytes === 'number' && value.maxInFlightBytes > 0
+      ? value.maxInFlightBytes
+      : DEFAULT_MAX_IN_FLIGHT_BYTES,
+  };
+}
+
+export async function readParserPoolConfig(basePath: string): Promise<ParserPoolConfig> {
+  try {
+    const config = JSON.parse(await readFile(join(basePath, '.automatosx', 'config.json'), 'utf8')) as unknown;
+    return resolveParserPoolConfig(isRecord(config) ? config.parser : undefined);
+  } catch {
+    return resolveParserPoolConfig(undefined);
+  }
+}
+
+export async function readIndexProgress(basePath: string): Promise<IndexProgress | undefined> {
+  try {
+    return JSON.parse(await readFile(join(basePath, INDEX_PROGRESS_FILE), 'utf8')) as IndexProgress;
+  } catch {
+    return undefined;
+  }
+}
+
+/**
+ * Parses files on a pool of worker threads, each holding one file at a
+ * time, and hands each result to `onParsed` on the calling thread. A file is
+ * dispatched only while the bytes in flight stay under `maxInFlightBytes`
+ * (one file always goes through), so memory stays bounded however many files
+ * change at once. Files that are not `pooled`, and small batches, are parsed
+ * here with `parse`. Files that fail to read or parse are skipped. Progress
+ * is written to INDEX_PROGRESS_FILE as it goes.
+ */
+export async function parseFiles(
+  basePath: string,
+  jobs: ParseJob[],
+  parse: (job: ParseJob) => Promise<ParsedSource>,
+  onParsed: (job: ParseJob, source: ParsedSource) => void,
+): Promise<void> {
+  const config = await readParserPoolConfig(basePath);
+  const pooled = jobs.length >= MIN_POOLED_FILES ? jobs.filter((job) => job.pooled) : [];
+  const workerCount = Math.min(config.workers, pooled.length);
+  const progress: IndexProgress = {
+    startedAt: new Date().toISOString(),
+    updatedAt: new Date().toISOString(),
+    total: jobs.length,
+    parsed: 0,
+    failed: 0,
+    workers: workerCount,
+  };
+  let reportedAt = 0;
+  const report = async (force = false) => {
+    if (force || Date.now() - reportedAt >= PROGRESS_INTERVAL_MS) {
+      reportedAt = Date.now();
+      progress.updatedAt = new Date().toISOString();
+      await writeIndexProgress(basePath, progress);
+    }
+  };
+  const settle = async (job: ParseJob, source: ParsedSource | undefined) => {
+    if (source === undefined) {
+      progress.failed += 1;
+    } else {
+      progress.parsed += 1;
+      onParsed(job, source);
+    }
+    await report();
+  };
+
+  await report(true);
+  const local = workerCount === 0 ? jobs : jobs.filter((job) => !job.pooled);
+  await Promise.all([
+    workerCount === 0 ? Promise.resolve() : runPool(pooled, workerCount, config.maxInFlightBytes, settle),
+    (async () => {
+      for (const job of local) {
+        await settle(job, await parse(job).catch(() => undefined));
+      }
+    })(),
+  ]);
+  progress.finishedAt = new Date().toISOString();
+  await report(true);
+}
+
+async function runPool(
+  jobs: ParseJob[],
+  workerCount: number,
+  maxInFlightBytes: number,
+  settle: (job: ParseJob, source: ParsedSource | undefined) => Promise<void>,
+): Promise<void> {
+  const workers = Array.from({ length: workerCount }, () => new Worker(new URL('./parse-worker.js', import.meta.url)));
+  try {
+    await new Promise<void>((resolve, reject) => {
+      const idle = [...workers];
+      const busy = new Map<Worker, ParseJob>();
+      let next = 0;
+      let inFlightBytes = 0;
+      const dispatch = () => {
+        while (idle.length > 0 && next < jobs.length) {
+          const job = jobs[next]!;
+          if (busy.size > 0 && inFlightBytes + job.size > maxInFlightBytes) {
+            return;
+          }
+          const worker = idle.pop()!;
+          next += 1;
+          inFlightBytes += job.size;
+          busy.set(worker, job);
+          worker.postMessage({ path: job.path, absolutePath: job.absolutePath });
+        }
+        if (busy.size === 0 && next >= jobs.length) {
+          resolve();
+        }
+      };
+      for (const worker of workers) {
+        worker.on('message', (message: { source?: ParsedSource }) => {
+          const job = busy.get(worker)!;
+          busy.delete(worker);
+          inFlightBytes -= job.size;
+          idle.push(worker);
+          settle(job, message.source).then(dispatch, reject);
+        });
+        worker.on('error', reject);
+        worker.on('exit', (code) => {
+          if (busy.has(worker)) {
+            reject(new Error(`Parser worker exited with code ${code} while parsing ${busy.get(worker)!.path}`));
+          }
+        });
+      }
+      dispatch();
+    });
+  } finally {
+    await Promise.all(workers.map((worker) => worker.terminate()));
+  }
+}
+
+// Written to a temporary file first so readers never see half of it.
+async function writeIndexProgress(basePath: string, progress: IndexProgress): Promise<void> {
+  const path = join(basePath, INDEX_PROGRESS_FILE);
+  try {
+    await mkdir(dirname(path), { recursive: true });
+    await writeFile(`${path}.tmp`, `${JSON.stringify(progress, null, 2)}\n`, 'utf8');
+    await rename(`${path}.tmp`, path);
+  } catch {
+    // Progress is informational; indexing goes on without it.
+  }
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
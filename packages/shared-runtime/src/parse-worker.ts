@@ -0,0 +1,11 @@
+import { readFile } from 'node:fs/promises';
+import { parentPort } from 'node:worker_threads';
+import { parseSourceFile } from './reference-index.js';
+
+// A parse pool thread: reads and parses one file per message and posts the result back.
+// A file that cannot be read or parsed comes back without a source.
+parentPort?.on('message', (job: { path: string; absolutePath: string }) => {
+  readFile(job.absolutePath, 'utf8')
+    .then((content) => parentPort!.postMessage({ source: parseSourceFile(content, job.path) }))
+    .catch(() => parentPort!.postMessage({}));
+});
@@ -0,0 +1,244 @@
+import { mkdir, readdir, readFile, writeFile } from 'node:fs/promises';
+import { basename, extname, join, relative, resolve } from 'node:path';
+import { extractDeclarations, supportsStructuralDiff } from './structural-diff.js';
+import { toSymbolMatch } from './symbol-query.js';
+export const PARSER_FIXTURE_DIR = join('.automatosx', 'parser-fixtures');
+export const PARSER_FIXTURE_SNAPSHOT_SUFFIX = '.symbols.json';
+const C_LIKE_EXTENSIONS = new Set([
+    '.ts', '.tsx', '.mts', '.cts', '.js', '.jsx', '.mjs', '.cjs',
+    '.go', '.c', '.h', '.cc', '.cpp', '.cxx', '.hh', '.hpp', '.hxx',
+    '.java', '.kt', '.kts', '.rs',
+]);
+// Blanked with `#` comments and triple-quoted strings instead.
+const PYTHON_EXTENSIONS = new Set(['.py', '.pyi']);
+// Comments the parsers read: Go build and embed directives, cgo preambles and exports, TS references.
+const KEPT_COMMENT = /^(?:\/\/go:|\/\/ ?\+build\b|\/\/\s*#|\/\/export\s|\/\/\/\s*<reference\b)/;
+const FIXTURE_NAME = /^[\w.-]+$/;
+/**
+ * Makes a source file safe to share as a parser fixture without moving any
+ * declaration: comment text and string contents are blanked (import paths,
+ * preprocessor lines, and directive comments are kept because the parsers
+ * read them), and each `redact` word is replaced wherever it appears,
+ * identifiers included, keeping the case of its first letter so Go
+ * exportedness survives. Line breaks are preserved, so line numbers match the
+ * original. Files in languages added by extractor plugins only get the
+ * redaction.
+ */
+export function sanitizeFixtureSource(content, path, redact = []) {
+    const stats = { comments: 0, strings: 0, redactions: 0 };
+    const extension = extname(path).toLowerCase();
+    let sanitized = C_LIKE_EXTENSIONS.has(extension) || PYTHON_EXTENSIONS.has(extension) ? blankCommentsAndStrings(content, path, stats) : content;
+    for (const word of redact.map((entry) => entry.trim()).filter((entry) => entry.length > 0)) {
+        sanitized = sanitized.replace(new RegExp(escapeRegExp(word), 'gi'), (match) => {
+            stats.redactions += 1;
+            return /^[A-Z]/.test(match) ? 'Redacted' : 'redacted';
+        });
+    }
+    return { content: sanitized, stats };
+}
+export function snapshotParserSymbols(content, path) {
+    return extractDeclarations(content, path).map((declaration) => {
+        const { path: _path, ...symbol } = toSymbolMatch(declaration, path);
+        return symbol;
+    });
+}
+/**
+ * Turns a workspace file into a conformance fixture: the sanitized source
+ * plus a `<file>.symbols.json` snapshot of what the parser extracts from it
+ * today. When the parser gets a construct wrong, edit the snapshot to what it
+ * should report; the fixture then fails until the parser is fixed.
+ */
+export async function createParserFixture(request) {
+    const sourcePath = resolve(request.basePath, request.path);
+    const extension = extname(sourcePath);
+    if (!supportsStructuralDiff(sourcePath)) {
+        throw new Error(`No parser handles ${extension === '' ? basename(sourcePath) : `${extension} files`}.`);
+    }
+    const redact = request.redact ?? [];
+    const name = sanitizeFixtureSource(request.name ?? basename(sourcePath, extension), '', redact).content;
+    if (!FIXTURE_NAME.test(name)) {
+        throw new Error(`Fixture name "${name}" may only contain letters, digits, ".", "_", and "-".`);
+    }
+    const { content, stats } = sanitizeFixtureSource(await readFile(sourcePath, 'utf8'), sourcePath, redact);
+    const source = `${name}${extension}`;
+    const symbols = snapshotParserSymbols(content, source);
+    const snapshot = { version: 1, source, symbols };
+    const outputDir = resolve(request.basePath, request.outputDir ?? PARSER_FIXTURE_DIR);
+    await mkdir(outputDir, { recursive: true });
+    const fixturePath = join(outputDir, source);
+    const snapshotPath = `${fixturePath}${PARSER_FIXTURE_SNAPSHOT_SUFFIX}`;
+    await writeFile(fixturePath, content, 'utf8');
+    await writeFile(snapshotPath, `${JSON.stringify(snapshot, null, 2)}\n`, 'utf8');
+    return {
+        name,
+        sourcePath: displayPath(request.basePath, fixturePath),
+        snapshotPath: displayPath(request.basePath, snapshotPath),
+        symbols,
+        sanitized: stats,
+    };
+}
+// Re-parses every fixture in `dir` and compares the result with its snapshot.
+export async function checkParserFixtures(request) {
+    const directory = resolve(request.basePath, request.dir ?? PARSER_FIXTURE_DIR);
+    let entries;
+    try {
+        entries = await readdir(directory);
+    }
+    catch {
+        entries = [];
+    }
+    const fixtures = [];
+    for (const entry of entries.filter((file) => file.endsWith(PARSER_FIXTURE_SNAPSHOT_SUFFIX)).sort()) {
+        const fallback = entry.slice(0, -PARSER_FIXTURE_SNAPSHOT_SUFFIX.length);
+        const name = basename(fallback, extname(fallback));
+        try {
+            const snapshot = JSON.parse(await readFile(join(directory, entry), 'utf8'));
+            const source = typeof snapshot.source === 'string' ? snapshot.source : fallback;
+            const expected = Array.isArray(snapshot.symbols) ? snapshot.symbols : [];
+            const actual = snapshotParserSymbols(await readFile(join(directory, source), 'utf8'), source);
+            const actualKeys = new Set(actual.map(symbolKey));
+            const expectedKeys = new Set(expected.map(symbolKey));
+            const missing = expected.filter((symbol) => !actualKeys.has(symbolKey(symbol)));
+            const unexpected = actual.filter((symbol) => !expectedKeys.has(symbolKey(symbol)));
+            fixtures.push({ name, source, passed: missing.length === 0 && unexpected.length === 0, missing, unexpected });
+        }
+        catch (error) {
+            fixtures.push({
+                name,
+                source: fallback,
+                passed: false,
+                missing: [],
+                unexpected: [],
+                error: error instanceof Error ? error.message : String(error),
+            });
+        }
+    }
+    const passed = fixtures.filter((fixture) => fixture.passed).length;
+    return { directory: displayPath(request.basePath, directory), fixtures, passed, failed: fixtures.length - passed };
+}
+function blankCommentsAndStrings(content, path, stats) {
+    const keptLines = goImportBlockLines(content, path);
+    const rust = extname(path).toLowerCase() === '.rs';
+    const python = PYTHON_EXTENSIONS.has(extname(path).toLowerCase());
+    let output = '';
+    let line = 1;
+    let index = 0;
+    while (index < content.length) {
+        const char = content[index];
+        const next = content[index + 1];
+        if (char === '\n') {
+            line += 1;
+            output += char;
+            index += 1;
+        }
+        else if (python && char === '#') {
+            const end = content.indexOf('\n', index);
+            const comment = content.slice(index, end === -1 ? content.length : end);
+            if (line === 1 && comment.startsWith('#!')) {
+                output += comment;
+            }
+            else {
+                output += '#';
+                stats.comments += 1;
+            }
+            index += comment.length;
+        }
+        else if (!python && char === '/' && next === '/') {
+            const end = content.indexOf('\n', index);
+            const comment = content.slice(index, end === -1 ? content.length : end);
+            if (KEPT_COMMENT.test(comment)) {
+                output += comment;
+            }
+            else {
+                output += '//';
+                stats.comments += 1;
+            }
+            index += comment.length;
+        }
+        else if (!python && char === '/' && next === '*') {
+            const end = content.indexOf('*/', index + 2);
+            const comment = content.slice(index, end === -1 ? content.length : end + 2);
+            const breaks = comment.split('\n').length - 1;
+            output += `/*${'\n'.repeat(breaks)}*/`;
+            line += breaks;
+            stats.comments += 1;
+            index += comment.length;
+        }
+        else if (char === '\'' && rust && /^'[A-Za-z_]\w*(?![\w'])/.test(content.slice(index, index + 256))) {
+            // A lifetime or loop label, not a char literal.
+            output += char;
+            index += 1;
+        }
+        else if (char === '"' || char === '\'' || (char === '`' && !python)) {
+            const lineText = content.slice(content.lastIndexOf('\n', index - 1) + 1, index);
+            const keep = keptLines.has(line) || /^\s*(?:import\b|package\b|#)/.test(lineText) || /\b(?:from|require\s*\(|import\s*\()\s*$/.test(lineText);
+            // Python's triple-quoted strings span lines, like template literals.
+            const quote = python && content.startsWith(char.repeat(3), index) ? char.repeat(3) : char;
+            let end = index + quote.length;
+            while (end < content.length && !content.startsWith(quote, end) && !(content[end] === '\n' && quote !== '`' && quote.length === 1)) {
+                end += content[end] === '\\' ? 2 : 1;
+            }
+            const body = content.slice(index + quote.length, Math.min(end, content.length));
+            if (keep || body.length === 0) {
+                output += quote + body;
+            }
+            else {
+                output += quote + body.replace(/[^\n]/g, 'x');
+                stats.strings += 1;
+            }
+            line += body.split('\n').length - 1;
+            if (content.startsWith(quote, end)) {
+                output += quote;
+                end += quote.length;
+            }
+            index = end;
+        }
+        else {
+            output += char;
+            index += 1;
+        }
+    }
+    return output;
+}
+// Lines inside Go `import ( ... )` blocks, whose strings are package paths.
+function goImportBlockLines(content, path) {
+    const lines = new Set();
+    if (extname(path).toLowerCase() !== '.go') {
+        return lines;
+    }
+    for (const match of content.matchAll(/^import\s*\(([^)]*)\)/gm)) {
+        const first = content.slice(0, match.index).split('\n').length;
+        const count = match[0].split('\n').length;
+        for (let offset = 0; offset < count; offset += 1) {
+            lines.add(first + offset);
+        }
+    }
+    return lines;
+}
+// Key order is fixed so hand-edited snapshots compare equal.
+function symbolKey(symbol) {
+    return JSON.stringify([
+        symbol.kind,
+        symbol.name,
+        symbol.receiver ?? null,
+        symbol.exported,
+        symbol.line,
+        symbol.endLine,
+        symbol.signature,
+        symbol.embeds ?? null,
+        symbol.cgo ?? null,
+        symbol.annotations ?? null,
+        symbol.typeParams ?? null,
+        symbol.build ?? null,
+        symbol.fields ?? null,
+        symbol.doc ?? null,
+    ]);
+}
+// Workspace-relative when inside the workspace, absolute otherwise.
+function displayPath(basePath, path) {
+    const relativePath = relative(basePath, path);
+    return relativePath.startsWith('..') ? path : relativePath || '.';
+}
+function escapeRegExp(value) {
+    return value.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
@@ -0,0 +1,473 @@
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { dirname, join, relative, sep } from 'node:path';
+import type { FeedbackEntry } from '@defai.digital/state-store';
+import { conformLines, detectCodeStyle, sortImportBlock } from './code-style.js';
+import {
+  describeForbiddenMatch,
+  findForbiddenPatterns,
+  patternsForPath,
+  type ForbiddenPattern,
+  type ForbiddenPatternMatch,
+  type TreeSitterQueryRunner,
+} from './forbidden-patterns.js';
+import { checkMinimalDiff, type MinimalDiffPolicy } from './minimal-diff.js';
+
+export const HUNK_REJECTED_FEEDBACK_TYPE = 'hunk-rejected';
+
+export const PATCH_STRATEGIES = ['exact', 'offset', 'whitespace', 'fuzz'] as const;
+
+const NULL_PATH = '/dev/null';
+const REJECTION_PROMPT_LIMIT = 5;
+// Like patch(1) --fuzz=2: up to two outer context lines may be ignored.
+const MAX_FUZZ = 2;
+const HUNK_HEADER = /^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)$/;
+
+export interface DiffHunk {
+  index: number;
+  header: string;
+  oldStart: number;
+  oldLines: number;
+  newStart: number;
+  newLines: number;
+  lines: string[];
+}
+
+export interface DiffFile {
+  path: string;
+  oldPath?: string;
+  newPath?: string;
+  hunks: DiffHunk[];
+}
+
+export type HunkDecisionKind = 'accept' | 'reject' | 'edit';
+export type PatchStrategy = typeof PATCH_STRATEGIES[number];
+
+export interface HunkApplication {
+  // Position of the hunk in the list passed to applyHunks.
+  index: number;
+  strategy: PatchStrategy;
+}
+
+export interface HunkDecision {
+  path: string;
+  hunkIndex: number;
+  decision: HunkDecisionKind;
+  // Replacement hunk body (lines prefixed with ' ', '-', '+') for edit decisions.
+  lines?: string[];
+  reason?: string;
+}
+
+export interface RuntimePatchReviewResponse {
+  traceId: string;
+  applied: Array<{ path: string; hunks: number[]; deleted?: boolean }>;
+  // bounced marks hunks the reviewer accepted but the minimal-diff or forbidden-pattern check sent back.
+  rejected: Array<{ path: string; hunkIndex: number; reason?: string; bounced?: boolean }>;
+  edited: Array<{ path: string; hunkIndex: number }>;
+  strategies: Array<{ path: string; hunkIndex: number; strategy: PatchStrategy }>;
+  restyled: Array<{ path: string; adjustments: string[] }>;
+  feedbackIds: string[];
+}
+
+interface HunkMatch {
+  position: number;
+  length: number;
+  replacement: string[];
+}
+
+type StrategyMatcher = (lines: string[], body: string[], expected: number) => HunkMatch | undefined;
+
+// Tried in the configured order; each is more forgiving than the last, so the
+// first match is also the most faithful placement.
+const STRATEGY_MATCHERS: Record<PatchStrategy, StrategyMatcher> = {
+  exact: (lines, body, expected) => matchAt(lines, body, expected, sameLine),
+  offset: (lines, body, expected) => matchNearest(lines, body, expected, sameLine),
+  whitespace: (lines, body, expected) => matchNearest(lines, body, expected, sameIgnoringWhitespace),
+  fuzz: (lines, body, expected) => {
+    for (let fuzz = 1; fuzz <= MAX_FUZZ; fuzz += 1) {
+      const trimmed = trimContext(body, fuzz);
+      if (trimmed === undefined) {
+        break;
+      }
+      const match = matchNearest(lines, trimmed.body, expected + trimmed.leading, sameIgnoringWhitespace);
+      if (match !== undefined) {
+        return match;
+      }
+    }
+    return undefined;
+  },
+};
+
+export function parseUnifiedDiff(text: string): DiffFile[] {
+  const files: DiffFile[] = [];
+  let file: DiffFile | undefined;
+  let hunk: DiffHunk | undefined;
+
+  for (const line of text.replace(/\r\n/g, '\n').split('\n')) {
+    if (line.startsWith('--- ')) {
+      hunk = undefined;
+      file = { path: '', oldPath: parseDiffPath(line.slice(4)), hunks: [] };
+      files.push(file);
+      continue;
+    }
+    if (line.startsWith('+++ ') && file !== undefined && hunk === undefined) {
+      file.newPath = parseDiffPath(line.slice(4));
+      file.path = file.newPath ?? file.oldPath ?? '';
+      continue;
+    }
+    const header = HUNK_HEADER.exec(line);
+    if (header !== null && file !== undefined) {
+      hunk = {
+        index: file.hunks.length,
+        header: line,
+        oldStart: Number(header[1]),
+        oldLines: header[2] === undefined ? 1 : Number(header[2]),
+        newStart: Number(header[3]),
+        newLines: header[4] === undefined ? 1 : Number(header[4]),
+        lines: [],
+      };
+      file.hunks.push(hunk);
+      continue;
+    }
+    if (hunk !== undefined && (line.startsWith(' ') || line.startsWith('-') || line.startsWith('+') || line.startsWith('\\'))) {
+      hunk.lines.push(line);
+    }
+  }
+
+  return files.filter((entry) => entry.path.length > 0 && entry.hunks.length > 0);
+}
+
+// Reads the `patch.strategies` config key; unknown names are ignored.
+export function resolvePatchStrategies(config: unknown): PatchStrategy[] {
+  const value = config !== null && typeof config === 'object' ? (config as { strategies?: unknown }).strategies : undefined;
+  if (!Array.isArray(value)) {
+    return [...PATCH_STRATEGIES];
+  }
+  const strategies = value.filter((entry): entry is PatchStrategy => PATCH_STRATEGIES.includes(entry as PatchStrategy));
+  return strategies.length > 0 ? strategies : [...PATCH_STRATEGIES];
+}
+
+// Reads the `patch.preserveStyle` config key; added lines follow the file's own style unless it is false.
+export function resolvePreserveStyle(config: unknown): boolean {
+  const value = config !== null && typeof config === 'object' ? (config as { preserveStyle?: unknown }).preserveStyle : undefined;
+  return value !== false;
+}
+
+export function applyHunks(
+  original: string,
+  hunks: Array<Pick<DiffHunk, 'oldStart' | 'lines'>>,
+  strategies: PatchStrategy[] = [...PATCH_STRATEGIES],
+): { content: string; applications: HunkApplication[] } {
+  const hadTrailingNewline = original.length === 0 || original.endsWith('\n');
+  const lines = original.length === 0 ? [] : original.replace(/\n$/, '').split('\n');
+  const applications: HunkApplication[] = [];
+  // Hunks are applied bottom-up so earlier line numbers stay valid.
+  const ordered = hunks.map((hunk, index) => ({ ...hunk, index })).sort((left, right) => right.oldStart - left.oldStart);
+
+  for (const hunk of ordered) {
+    const body = hunk.lines.filter((line) => !line.startsWith('\\'));
+    const expected = Math.max(hunk.oldStart - 1, 0);
+    let applied = false;
+    for (const strategy of strategies) {
+      const match = STRATEGY_MATCHERS[strategy](lines, body, expected);
+      if (match !== undefined) {
+        lines.splice(match.position, match.length, ...match.replacement);
+        applications.push({ index: hunk.index, strategy });
+        applied = true;
+        break;
+      }
+    }
+    if (!applied) {
+      throw new Error(`Hunk at line ${hunk.oldStart} does not match the current file contents (tried ${strategies.join(', ')}).`);
+    }
+  }
+
+  const content = lines.length === 0 ? '' : hadTrailingNewline ? `${lines.join('\n')}\n` : lines.join('\n');
+  return { content, applications };
+}
+
+export async function applyPatchReview(request: {
+  basePath: string;
+  patch: string;
+  decisions: HunkDecision[];
+  strategies?: PatchStrategy[];
+  preserveStyle?: boolean;
+  minimalDiff?: MinimalDiffPolicy;
+  forbiddenPatterns?: { patterns: ForbiddenPattern[]; runQuery: TreeSitterQueryRunner };
+}): Promise<Omit<RuntimePatchReviewResponse, 'traceId' | 'feedbackIds'>> {
+  const files = parseUnifiedDiff(request.patch);
+  const decisionFor = (path: string, hunkIndex: number) =>
+    request.decisions.find((decision) => decision.path === path && decision.hunkIndex === hunkIndex);
+
+  const applied: RuntimePatchReviewResponse['applied'] = [];
+  const rejected: RuntimePatchReviewResponse['rejected'] = [];
+  const edited: RuntimePatchReviewResponse['edited'] = [];
+  const strategies: RuntimePatchReviewResponse['strategies'] = [];
+  const restyled: RuntimePatchReviewResponse['restyled'] = [];
+  const writes: Array<{ target: string; content?: string }> = [];
+
+  // Resolve every file before writing any, so a stale hunk aborts the whole review.
+  for (const file of files) {
+    let accepted: Array<Pick<DiffHunk, 'index' | 'oldStart' | 'lines'>> = [];
+    let acceptedIndexes: number[] = [];
+    for (const hunk of file.hunks) {
+      // Hunks without a decision are never written.
+      const decision = decisionFor(file.path, hunk.index);
+      if (decision === undefined || decision.decision === 'reject') {
+        rejected.push({ path: file.path, hunkIndex: hunk.index, reason: decision?.reason });
+        continue;
+      }
+      if (decision.decision === 'edit') {
+        if (decision.lines === undefined) {
+          throw new Error(`Edit decision for ${file.path} hunk ${hunk.index} has no replacement lines.`);
+        }
+        edited.push({ path: file.path, hunkIndex: hunk.index });
+        accepted.push({ index: hunk.index, oldStart: hunk.oldStart, lines: decision.lines });
+      } else {
+        accepted.push(hunk);
+      }
+      acceptedIndexes.push(hunk.index);
+    }
+    if (accepted.length === 0) {
+      continue;
+    }
+
+    const target = resolveInside(request.basePath, file.path);
+    const isNew = file.oldPath === undefined;
+    const original = isNew ? '' : await readFile(target, 'utf8');
+    if (request.minimalDiff !== undefined && !isNew) {
+      // Hunks the reviewer edited by hand are theirs to judge.
+      const editedIndexes = new Set(edited.filter((entry) => entry.path === file.path).map((entry) => entry.hunkIndex));
+      const violations = checkMinimalDiff(
+        accepted.filter((hunk) => !editedIndexes.has(hunk.index)),
+        original.length === 0 ? 0 : original.replace(/\n$/, '').split('\n').length,
+        request.minimalDiff,
+      );
+      for (const violation of violations) {
+        rejected.push({ path: file.path, hunkIndex: violation.hunkIndex, reason: violation.reason, bounced: true });
+      }
+      const bounced = new Set(violations.map((violation) => violation.hunkIndex));
+      accepted = accepted.filter((hunk) => !bounced.has(hunk.index));
+      acceptedIndexes = acceptedIndexes.filter((index) => !bounced.has(index));
+      if (accepted.length === 0) {
+        continue;
+      }
+    }
+    const preserveStyle = request.preserveStyle !== false && !isNew;
+    const build = (hunks: typeof accepted) => {
+      const styled = preserveStyle ? restyleHunks(hunks, original, file.path) : { hunks, adjustments: [] as string[] };
+      const { content: patched, applications } = applyHunks(original, styled.hunks, request.strategies);
+      let content = patched;
+      if (preserveStyle && detectCodeStyle(original, file.path).sortedImports) {
+        const sorted = sortImportBlock(content.split('\n'));
+        if (sorted !== undefined) {
+          content = sorted.join('\n');
+          styled.adjustments.push('imports sorted');
+        }
+      }
+      return { ...styled, content, applications };
+    };
+    let built = build(accepted);
+    if (request.forbiddenPatterns !== undefined && patternsForPath(request.forbiddenPatterns.patterns, file.path).length > 0) {
+      // Hunks that introduce a forbidden pattern go back to the agent, with the pattern's suggested fix as the reason.
+      const reasons = new Map<number, string[]>();
+      for (const match of await introducedForbiddenMatches(original, built.content, file.path, request.forbiddenPatterns)) {
+        const hunkIndex = hunkIntroducing(built.hunks, built.content, match.line);
+        reasons.set(hunkIndex, [...(reasons.get(hunkIndex) ?? []), describeForbiddenMatch(match)]);
+      }
+      for (const [hunkIndex, hunkReasons] of reasons) {
+        rejected.push({ path: file.path, hunkIndex, reason: hunkReasons.join('; '), bounced: true });
+      }
+      if (reasons.size > 0) {
+        accepted = accepted.filter((hunk) => !reasons.has(hunk.index));
+        acceptedIndexes = acceptedIndexes.filter((index) => !reasons.has(index));
+        if (accepted.length === 0) {
+          continue;
+        }
+        built = build(accepted);
+      }
+    }
+    const { content, applications, adjustments } = built;
+    if (adjustments.length > 0) {
+      restyled.push({ path: file.path, adjustments });
+    }
+    for (const application of applications) {
+      strategies.push({ path: file.path, hunkIndex: accepted[application.index]?.index ?? application.index, strategy: application.strategy });
+    }
+    const deleted = file.newPath === undefined && acceptedIndexes.length === file.hunks.length && content.length === 0;
+    writes.push({ target, content: deleted ? undefined : content });
+    applied.push({ path: file.path, hunks: acceptedIndexes, ...(deleted ? { deleted } : {}) });
+  }
+
+  for (const write of writes) {
+    if (write.content === undefined) {
+      await rm(write.target, { force: true });
+    } else {
+      await mkdir(dirname(write.target), { recursive: true });
+      await writeFile(write.target, write.content, 'utf8');
+    }
+  }
+
+  strategies.sort((left, right) => left.path.localeCompare(right.path) || left.hunkIndex - right.hunkIndex);
+  rejected.sort((left, right) => left.path.localeCompare(right.path) || left.hunkIndex - right.hunkIndex);
+  return { applied, rejected, edited, strategies, restyled };
+}
+
+// Matches in the patched file beyond those the original already had; existing violations are not the patch's doing.
+async function introducedForbiddenMatches(
+  original: string,
+  patched: string,
+  path: string,
+  check: { patterns: ForbiddenPattern[]; runQuery: TreeSitterQueryRunner },
+): Promise<ForbiddenPatternMatch[]> {
+  const existing = new Map<string, number>();
+  if (original.length > 0) {
+    for (const match of await findForbiddenPatterns({ path, content: original, ...check })) {
+      const key = `${match.patternId}\u0000${match.text}`;
+      existing.set(key, (existing.get(key) ?? 0) + 1);
+    }
+  }
+  return (await findForbiddenPatterns({ path, content: patched, ...check })).filter((match) => {
+    const key = `${match.patternId}\u0000${match.text}`;
+    const count = existing.get(key) ?? 0;
+    existing.set(key, count - 1);
+    return count <= 0;
+  });
+}
+
+// The hunk that added the patched file's line `line`; the first hunk when no added line matches it exactly.
+function hunkIntroducing(hunks: Array<Pick<DiffHunk, 'index' | 'lines'>>, patched: string, line: number): number {
+  const text = (patched.split('\n')[line - 1] ?? '').trim();
+  const owner = hunks.find((hunk) => hunk.lines.some((entry) => entry.startsWith('+') && entry.slice(1).trim() === text));
+  return owner?.index ?? hunks[0]?.index ?? 0;
+}
+
+// Rewrites only the added lines of each hunk to the target file's style. The
+// patch's own style is read from all of its lines, context included, since a
+// single hunk is often too short to show an indentation unit.
+function restyleHunks<T extends Pick<DiffHunk, 'lines'>>(
+  hunks: T[],
+  original: string,
+  path: string,
+): { hunks: T[]; adjustments: string[] } {
+  const target = detectCodeStyle(original, path);
+  const patchLines = hunks.flatMap((hunk) => hunk.lines.filter((line) => !line.startsWith('\\')).map((line) => line.slice(1)));
+  const source = detectCodeStyle(patchLines.join('\n'), path);
+  const adjustments = new Set<string>();
+  const restyledHunks = hunks.map((hunk) => {
+    const lines = hunk.lines.map((line) => {
+      if (!line.startsWith('+')) {
+        return line;
+      }
+      const result = conformLines([line.slice(1)], target, source, path);
+      result.adjustments.forEach((adjustment) => adjustments.add(adjustment));
+      return `+${result.lines[0] ?? ''}`;
+    });
+    return { ...hunk, lines };
+  });
+  return { hunks: restyledHunks, adjustments: [...adjustments] };
+}
+
+// Summarizes recent hunk rejections so the agent's next run sees what was turned down and why.
+export function formatRejectedHunks(feedback: FeedbackEntry[]): string | undefined {
+  const rejections = feedback
+    .filter((entry) => entry.feedbackType === HUNK_REJECTED_FEEDBACK_TYPE)
+    .slice(0, REJECTION_PROMPT_LIMIT);
+  if (rejections.length === 0) {
+    return undefined;
+  }
+  return [
+    'Previously rejected changes:',
+    ...rejections.map((entry) => {
+      const path = typeof entry.metadata?.path === 'string' ? entry.metadata.path : entry.taskDescription;
+      const header = typeof entry.metadata?.header === 'string' ? ` ${entry.metadata.header}` : '';
+      return `- ${path}${header}${entry.userComment !== undefined ? `: ${entry.userComment}` : ''}`;
+    }),
+  ].join('\n');
+}
+
+function matchAt(
+  lines: string[],
+  body: string[],
+  position: number,
+  same: (left: string, right: string) => boolean,
+): HunkMatch | undefined {
+  const before = body.filter((line) => !line.startsWith('+'));
+  if (position < 0 || position + before.length > lines.length) {
+    return undefined;
+  }
+  if (!before.every((line, offset) => same(lines[position + offset] ?? '', line.slice(1)))) {
+    return undefined;
+  }
+  // Context lines keep the file's current text so reformatting is not undone.
+  const replacement: string[] = [];
+  let cursor = position;
+  for (const line of body) {
+    if (line.startsWith('+')) {
+      replacement.push(line.slice(1));
+    } else {
+      if (line.startsWith(' ')) {
+        replacement.push(lines[cursor] ?? line.slice(1));
+      }
+      cursor += 1;
+    }
+  }
+  return { position, length: before.length, replacement };
+}
+
+function matchNearest(
+  lines: string[],
+  body: string[],
+  expected: number,
+  same: (left: string, right: string) => boolean,
+): HunkMatch | undefined {
+  // Allow the hunk to drift, as patch(1) does, when earlier edits shifted lines.
+  for (let offset = 0; offset <= lines.length; offset += 1) {
+    const match = matchAt(lines, body, expected - offset, same) ?? matchAt(lines, body, expected + offset, same);
+    if (match !== undefined) {
+      return match;
+    }
+  }
+  return undefined;
+}
+
+function trimContext(body: string[], fuzz: number): { body: string[]; leading: number } | undefined {
+  let start = 0;
+  let end = body.length;
+  while (start < fuzz && body[start]?.startsWith(' ')) {
+    start += 1;
+  }
+  while (body.length - end < fuzz && end > start && body[end - 1]?.startsWith(' ')) {
+    end -= 1;
+  }
+  const trimmed = body.slice(start, end);
+  // Nothing left to anchor on once all context is gone.
+  if ((start === 0 && end === body.length) || !trimmed.some((line) => line.startsWith(' ') || line.startsWith('-'))) {
+    return undefined;
+  }
+  return { body: trimmed, leading: start };
+}
+
+function sameLine(left: string, right: string): boolean {
+  return left === right;
+}
+
+function sameIgnoringWhitespace(left: string, right: string): boolean {
+  return left.trim().replace(/\s+/g, ' ') === right.trim().replace(/\s+/g, ' ');
+}
+
+function parseDiffPath(raw: string): string | undefined {
+  const path = raw.split('\t')[0]?.trim() ?? '';
+  if (path === NULL_PATH) {
+    return undefined;
+  }
+  return path.replace(/^[ab]\//, '');
+}
+
+function resolveInside(root: string, path: string): string {
+  const target = join(root, path);
+  const relativePath = relative(root, target);
+  if (relativePath.startsWith('..') || relativePath === '' || relativePath.split(sep).includes('..')) {
+    throw new Error(`Patch path escapes the workspace: ${path}`);
+  }
+  return target;
+}
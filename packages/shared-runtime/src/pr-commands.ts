@@ -0,0 +1,568 @@
+import { createHmac, timingSafeEqual } from 'node:crypto';
+import { createServer, type IncomingMessage, type ServerResponse } from 'node:http';
+import type { AddressInfo } from 'node:net';
+
+export const DEFAULT_PR_COMMAND_PREFIX = '/ax';
+// Comment authors GitHub reports as having write access to the repository.
+const DEFAULT_ALLOWED_ASSOCIATIONS = ['OWNER', 'MEMBER', 'COLLABORATOR'];
+const MAX_WEBHOOK_PAYLOAD_BYTES = 1024 * 1024;
+const MAX_COMMENT_OUTPUT_CHARS = 4000;
+export const DEFAULT_APPROVAL_LABEL = 'ax:approved';
+
+export interface PrCommandDefinition {
+  workflow: string;
+  input?: Record<string, unknown>;
+}
+
+export interface PrCommandRepositoryConfig {
+  allowedUsers?: string[];
+  allowedAssociations?: string[];
+  commands?: Record<string, PrCommandDefinition>;
+}
+
+export interface PrCommandConfig {
+  prefix: string;
+  commands: Record<string, PrCommandDefinition>;
+  allowedUsers: string[];
+  allowedAssociations: string[];
+  // When non-empty, only these repositories (owner/name) accept commands.
+  repositories: Record<string, PrCommandRepositoryConfig>;
+}
+
+export interface PrCommentEvent {
+  repository: string;
+  number: number;
+  title: string;
+  url: string;
+  commentId: number;
+  body: string;
+  user: string;
+  association: string;
+}
+
+export interface PrCommandMatch {
+  name: string;
+  definition: PrCommandDefinition;
+  args: string[];
+}
+
+export interface AutomationRule {
+  name: string;
+  // `issues` or `pull_request`; unset matches both.
+  event?: 'issues' | 'pull_request';
+  // Webhook action such as `labeled` or `opened`.
+  action: string;
+  // For `labeled`, the label that was added; for other actions, a label the item must carry.
+  label?: string;
+  workflow: string;
+  input?: Record<string, unknown>;
+  // Hold the run until the approval label is added after the trigger.
+  approval?: { label: string; users: string[] };
+  repositories?: string[];
+}
+
+export interface AutomationEvent {
+  event: 'issues' | 'pull_request';
+  action: string;
+  repository: string;
+  number: number;
+  title: string;
+  url: string;
+  // The label added or removed, for `labeled` and `unlabeled`.
+  label?: string;
+  labels: string[];
+  sender: string;
+}
+
+export interface PrCommandOutcome {
+  status: 'ignored' | 'denied' | 'unknown' | 'awaiting-approval' | 'completed' | 'failed';
+  repository?: string;
+  number?: number;
+  command?: string;
+  rule?: string;
+  workflowId?: string;
+  traceId?: string;
+  reason?: string;
+}
+
+export interface PrCommandDependencies {
+  runWorkflow(request: { workflowId: string; input: Record<string, unknown> }): Promise<{
+    traceId: string;
+    success: boolean;
+    output?: unknown;
+    error?: { message?: string };
+  }>;
+  postComment(repository: string, number: number, body: string): Promise<void>;
+}
+
+export interface PrCommandServer {
+  port: number;
+  close(): Promise<void>;
+}
+
+/**
+ * Reads the `prCommands` config block. Commands map the words after the
+ * prefix to a workflow, either as a bare workflow id or `{ workflow, input }`:
+ * `{ "commands": { "fix lint": "fix-lint" }, "repositories": { "acme/api": { "allowedUsers": ["alice"] } } }`.
+ */
+export function resolvePrCommandConfig(value: unknown): PrCommandConfig {
+  const config = isRecord(value) ? value : {};
+  const repositories: Record<string, PrCommandRepositoryConfig> = {};
+  for (const [name, entry] of Object.entries(isRecord(config.repositories) ? config.repositories : {})) {
+    if (!isRecord(entry)) {
+      continue;
+    }
+    repositories[name.toLowerCase()] = {
+      allowedUsers: Array.isArray(entry.allowedUsers) ? toStringList(entry.allowedUsers) : undefined,
+      allowedAssociations: Array.isArray(entry.allowedAssociations) ? toStringList(entry.allowedAssociations) : undefined,
+      commands: isRecord(entry.commands) ? resolveCommands(entry.commands) : undefined,
+    };
+  }
+  return {
+    prefix: typeof config.prefix === 'string' && config.prefix.trim().length > 0 ? config.prefix.trim() : DEFAULT_PR_COMMAND_PREFIX,
+    commands: resolveCommands(isRecord(config.commands) ? config.commands : {}),
+    allowedUsers: toStringList(config.allowedUsers),
+    allowedAssociations: Array.isArray(config.allowedAssociations) ? toStringList(config.allowedAssociations) : DEFAULT_ALLOWED_ASSOCIATIONS,
+    repositories,
+  };
+}
+
+// The first line of the comment that starts with the prefix, without it.
+export function parsePrCommand(body: string, prefix = DEFAULT_PR_COMMAND_PREFIX): string | undefined {
+  for (const line of body.split(/\r?\n/)) {
+    const trimmed = line.trim();
+    if (trimmed === prefix || trimmed.startsWith(`${prefix} `)) {
+      return trimmed.slice(prefix.length).trim();
+    }
+  }
+  return undefined;
+}
+
+// Longest configured command whose words prefix the comment; the rest become args.
+export function matchPrCommand(text: string, commands: Record<string, PrCommandDefinition>): PrCommandMatch | undefined {
+  const words = text.split(/\s+/).filter((word) => word.length > 0);
+  let match: PrCommandMatch | undefined;
+  for (const [name, definition] of Object.entries(commands)) {
+    const commandWords = name.toLowerCase().split(/\s+/).filter((word) => word.length > 0);
+    const matches = commandWords.length > 0
+      && commandWords.length <= words.length
+      && commandWords.every((word, index) => words[index]!.toLowerCase() === word);
+    if (matches && (match === undefined || commandWords.length > match.name.split(/\s+/).length)) {
+      match = { name, definition, args: words.slice(commandWords.length) };
+    }
+  }
+  return match;
+}
+
+export function authorizePrCommand(
+  config: PrCommandConfig,
+  event: Pick<PrCommentEvent, 'repository' | 'user' | 'association'>,
+): { allowed: boolean; reason?: string } {
+  const repositoryConfig = config.repositories[event.repository.toLowerCase()];
+  if (Object.keys(config.repositories).length > 0 && repositoryConfig === undefined) {
+    return { allowed: false, reason: `${event.repository} is not configured for AutomatosX commands.` };
+  }
+  const allowedUsers = repositoryConfig?.allowedUsers ?? config.allowedUsers;
+  const allowedAssociations = repositoryConfig?.allowedAssociations ?? config.allowedAssociations;
+  if (allowedUsers.some((user) => user.toLowerCase() === event.user.toLowerCase())) {
+    return { allowed: true };
+  }
+  if (allowedAssociations.some((association) => association.toUpperCase() === event.association.toUpperCase())) {
+    return { allowed: true };
+  }
+  return { allowed: false, reason: `@${event.user} is not allowed to run AutomatosX commands in ${event.repository}.` };
+}
+
+// Checks GitHub's X-Hub-Signature-256 header against the raw request body.
+export function verifyWebhookSignature(secret: string, body: Buffer, signature: string | undefined): boolean {
+  if (signature === undefined || !signature.startsWith('sha256=')) {
+    return false;
+  }
+  const expected = Buffer.from(`sha256=${createHmac('sha256', secret).update(body).digest('hex')}`);
+  const actual = Buffer.from(signature);
+  return expected.length === actual.length && timingSafeEqual(expected, actual);
+}
+
+// Only new comments on pull requests carry commands; issue comments are ignored.
+export function extractPrCommentEvent(payload: unknown): PrCommentEvent | undefined {
+  if (!isRecord(payload) || payload.action !== 'created') {
+    return undefined;
+  }
+  const { issue, comment, repository } = payload;
+  if (!isRecord(issue) || !isRecord(issue.pull_request) || !isRecord(comment) || !isRecord(repository)) {
+    return undefined;
+  }
+  const user = isRecord(comment.user) ? comment.user.login : undefined;
+  if (typeof issue.number !== 'number' || typeof comment.body !== 'string' || typeof user !== 'string' || typeof repository.full_name !== 'string') {
+    return undefined;
+  }
+  return {
+    repository: repository.full_name,
+    number: issue.number,
+    title: typeof issue.title === 'string' ? issue.title : '',
+    url: typeof issue.html_url === 'string' ? issue.html_url : '',
+    commentId: typeof comment.id === 'number' ? comment.id : 0,
+    body: comment.body,
+    user,
+    association: typeof comment.author_association === 'string' ? comment.author_association : 'NONE',
+  };
+}
+
+export async function handlePrComment(
+  config: PrCommandConfig,
+  payload: unknown,
+  dependencies: PrCommandDependencies,
+): Promise<PrCommandOutcome> {
+  const event = extractPrCommentEvent(payload);
+  const text = event === undefined ? undefined : parsePrCommand(event.body, config.prefix);
+  if (event === undefined || text === undefined) {
+    return { status: 'ignored' };
+  }
+  const target = { repository: event.repository, number: event.number };
+  const quoted = `> ${config.prefix} ${text}`.trimEnd();
+
+  const authorization = authorizePrCommand(config, event);
+  if (!authorization.allowed) {
+    await dependencies.postComment(event.repository, event.number, `${quoted}\n\n${authorization.reason}`);
+    return { ...target, status: 'denied', reason: authorization.reason };
+  }
+
+  const commands = { ...config.commands, ...config.repositories[event.repository.toLowerCase()]?.commands };
+  const match = matchPrCommand(text, commands);
+  if (match === undefined) {
+    const available = Object.keys(commands).map((name) => `\`${config.prefix} ${name}\``).join(', ');
+    const reason = `Unknown command. Available: ${available.length > 0 ? available : '(none configured)'}.`;
+    await dependencies.postComment(event.repository, event.number, `${quoted}\n\n${reason}`);
+    return { ...target, status: 'unknown', reason };
+  }
+
+  const workflowId = match.definition.workflow;
+  const outcome = await runAndReport(dependencies, target, quoted, `Running workflow \`${workflowId}\` for @${event.user}...`, workflowId, {
+    ...match.definition.input,
+    command: match.name,
+    args: match.args,
+    requestedBy: event.user,
+    pullRequest: {
+      repository: event.repository,
+      number: event.number,
+      title: event.title,
+      url: event.url,
+      commentId: event.commentId,
+    },
+  });
+  return { ...outcome, command: match.name };
+}
+
+/**
+ * Reads the `automationRules` config list. Each rule maps an issue or pull
+ * request event to a workflow, e.g. `{ "label": "ax:triage", "workflow": "triage" }`
+ * or `{ "label": "ax:fix", "workflow": "fix", "approval": true }`. Rules default
+ * to the `labeled` action; `approval` holds the run until the approval label
+ * (default `ax:approved`) is added after the trigger, optionally by `users` only.
+ */
+export function resolveAutomationRules(value: unknown): AutomationRule[] {
+  const rules: AutomationRule[] = [];
+  for (const [index, entry] of (Array.isArray(value) ? value : []).entries()) {
+    if (!isRecord(entry) || typeof entry.workflow !== 'string' || entry.workflow.length === 0) {
+      continue;
+    }
+    const label = typeof entry.label === 'string' && entry.label.length > 0 ? entry.label : undefined;
+    const action = typeof entry.action === 'string' && entry.action.length > 0 ? entry.action : label !== undefined ? 'labeled' : undefined;
+    if (action === undefined) {
+      continue;
+    }
+    const approval = entry.approval === true ? {} : isRecord(entry.approval) ? entry.approval : undefined;
+    rules.push({
+      name: typeof entry.name === 'string' && entry.name.length > 0 ? entry.name : label ?? `rule-${index + 1}`,
+      ...(entry.event === 'issues' || entry.event === 'pull_request' ? { event: entry.event } : {}),
+      action,
+      ...(label !== undefined ? { label } : {}),
+      workflow: entry.workflow,
+      ...(isRecord(entry.input) ? { input: entry.input } : {}),
+      ...(approval !== undefined
+        ? { approval: { label: typeof approval.label === 'string' && approval.label.length > 0 ? approval.label : DEFAULT_APPROVAL_LABEL, users: toStringList(approval.users) } }
+        : {}),
+      ...(Array.isArray(entry.repositories) ? { repositories: toStringList(entry.repositories) } : {}),
+    });
+  }
+  return rules;
+}
+
+export function extractAutomationEvent(eventName: string, payload: unknown): AutomationEvent | undefined {
+  if ((eventName !== 'issues' && eventName !== 'pull_request') || !isRecord(payload) || typeof payload.action !== 'string') {
+    return undefined;
+  }
+  const item = eventName === 'issues' ? payload.issue : payload.pull_request;
+  const { repository, sender } = payload;
+  if (!isRecord(item) || typeof item.number !== 'number' || !isRecord(repository) || typeof repository.full_name !== 'string') {
+    return undefined;
+  }
+  const labelName = isRecord(payload.label) && typeof payload.label.name === 'string' ? payload.label.name : undefined;
+  return {
+    event: eventName,
+    action: payload.action,
+    repository: repository.full_name,
+    number: item.number,
+    title: typeof item.title === 'string' ? item.title : '',
+    url: typeof item.html_url === 'string' ? item.html_url : '',
+    ...(labelName !== undefined ? { label: labelName } : {}),
+    labels: (Array.isArray(item.labels) ? item.labels : [])
+      .map((label) => (isRecord(label) && typeof label.name === 'string' ? label.name : undefined))
+      .filter((label): label is string => label !== undefined),
+    sender: isRecord(sender) && typeof sender.login === 'string' ? sender.login : 'unknown',
+  };
+}
+
+/**
+ * Runs the rules an issue or pull request event triggers. A gated rule posts
+ * a note and waits, even when the approval label is already on the item;
+ * adding the approval label afterwards runs every gated rule whose trigger
+ * label is still on the item.
+ */
+export async function handleAutomationEvent(
+  rules: AutomationRule[],
+  eventName: string,
+  payload: unknown,
+  dependencies: PrCommandDependencies,
+): Promise<PrCommandOutcome[]> {
+  const event = extractAutomationEvent(eventName, payload);
+  if (event === undefined) {
+    return [{ status: 'ignored' }];
+  }
+  const target = { repository: event.repository, number: event.number };
+  const hasLabel = (label: string | undefined) => label !== undefined && event.labels.some((candidate) => sameLabel(candidate, label));
+  const applicable = rules.filter((rule) =>
+    (rule.event === undefined || rule.event === event.event)
+    && (rule.repositories === undefined || rule.repositories.some((repository) => repository.toLowerCase() === event.repository.toLowerCase())));
+
+  const outcomes: PrCommandOutcome[] = [];
+  const run = async (rule: AutomationRule, quoted: string): Promise<void> => {
+    const outcome = await runAndReport(dependencies, target, quoted, `Running workflow \`${rule.workflow}\` (rule \`${rule.name}\`)...`, rule.workflow, {
+      ...rule.input,
+      rule: rule.name,
+      trigger: { event: event.event, action: event.action, ...(event.label !== undefined ? { label: event.label } : {}), sender: event.sender },
+      [event.event === 'issues' ? 'issue' : 'pullRequest']: { repository: event.repository, number: event.number, title: event.title, url: event.url, labels: event.labels },
+    });
+    outcomes.push({ ...outcome, rule: rule.name });
+  };
+
+  for (const rule of applicable) {
+    const triggered = rule.action === event.action
+      && (event.action === 'labeled' ? sameLabel(event.label ?? '', rule.label ?? '') : rule.label === undefined || hasLabel(rule.label));
+    const approved = event.action === 'labeled'
+      && rule.approval !== undefined
+      && sameLabel(event.label ?? '', rule.approval.label)
+      && (rule.label === undefined || hasLabel(rule.label));
+    if (!triggered && !approved) {
+      continue;
+    }
+    const quoted = `> ${event.action === 'labeled' ? `Label \`${event.label}\` added` : `${event.event === 'issues' ? 'Issue' : 'Pull request'} ${event.action}`} by @${event.sender}`;
+    if (rule.approval === undefined) {
+      await run(rule, quoted);
+      continue;
+    }
+    const approvers = rule.approval.users;
+    if (approved && approvers.length > 0 && !approvers.some((user) => user.toLowerCase() === event.sender.toLowerCase())) {
+      const reason = `@${event.sender} cannot approve rule \`${rule.name}\`; approvers: ${approvers.map((user) => `@${user}`).join(', ')}.`;
+      await dependencies.postComment(event.repository, event.number, `${quoted}\n\n${reason}`);
+      outcomes.push({ ...target, status: 'denied', rule: rule.name, workflowId: rule.workflow, reason });
+    } else if (approved) {
+      await run(rule, quoted);
+    } else {
+      // Only adding the approval label after the trigger approves; a label put on beforehand doesn't count.
+      const reason = hasLabel(rule.approval.label)
+        ? `Workflow \`${rule.workflow}\` (rule \`${rule.name}\`) is waiting for approval: the \`${rule.approval.label}\` label was already on it, so remove it and add it again to run it.`
+        : `Workflow \`${rule.workflow}\` (rule \`${rule.name}\`) is waiting for approval: add the \`${rule.approval.label}\` label to run it.`;
+      await dependencies.postComment(event.repository, event.number, `${quoted}\n\n${reason}`);
+      outcomes.push({ ...target, status: 'awaiting-approval', rule: rule.name, workflowId: rule.workflow, reason });
+    }
+  }
+  return outcomes.length > 0 ? outcomes : [{ ...target, status: 'ignored' }];
+}
+
+export function createGitHubCommentPoster(options: { token: string; apiUrl?: string }): PrCommandDependencies['postComment'] {
+  const apiUrl = (options.apiUrl ?? 'https://api.github.com').replace(/\/+$/, '');
+  return async (repository, number, body) => {
+    const response = await fetch(`${apiUrl}/repos/${repository}/issues/${number}/comments`, {
+      method: 'POST',
+      headers: {
+        Accept: 'application/vnd.github+json',
+        Authorization: `Bearer ${options.token}`,
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ body }),
+    });
+    if (!response.ok) {
+      throw new Error(`GitHub comment on ${repository}#${number} failed with ${response.status}`);
+    }
+  };
+}
+
+/**
+ * Serves GitHub `issue_comment` webhooks, plus `issues` and `pull_request`
+ * events when automation rules are configured. Deliveries are acknowledged
+ * with 202 straight away because workflows outlive GitHub's delivery timeout;
+ * progress and results are reported back as comments instead. `close()` waits
+ * for in-flight commands.
+ */
+export function startPrCommandServer(options: {
+  config: PrCommandConfig;
+  rules?: AutomationRule[];
+  dependencies: PrCommandDependencies;
+  secret?: string;
+  port?: number;
+  host?: string;
+  onOutcome?: (outcome: PrCommandOutcome) => void;
+}): Promise<PrCommandServer> {
+  const pending = new Set<Promise<void>>();
+  const server = createServer((req, res) => {
+    handleWebhookRequest(req, res, options, pending).catch((error) => {
+      if (!res.writableEnded) {
+        respondJson(res, 500, { error: error instanceof Error ? error.message : String(error) });
+      }
+    });
+  });
+
+  return new Promise((resolve, reject) => {
+    server.once('error', reject);
+    server.listen(options.port ?? 0, options.host ?? '127.0.0.1', () => {
+      resolve({
+        port: (server.address() as AddressInfo).port,
+        close: async () => {
+          await new Promise<void>((done) => server.close(() => done()));
+          await Promise.all(pending);
+        },
+      });
+    });
+  });
+}
+
+async function handleWebhookRequest(
+  req: IncomingMessage,
+  res: ServerResponse,
+  options: Parameters<typeof startPrCommandServer>[0],
+  pending: Set<Promise<void>>,
+): Promise<void> {
+  if (req.method !== 'POST') {
+    respondJson(res, 405, { error: 'Only POST is supported' });
+    return;
+  }
+
+  const chunks: Buffer[] = [];
+  let size = 0;
+  for await (const chunk of req) {
+    const buffer = chunk as Buffer;
+    size += buffer.length;
+    if (size > MAX_WEBHOOK_PAYLOAD_BYTES) {
+      respondJson(res, 413, { error: 'Payload too large' });
+      return;
+    }
+    chunks.push(buffer);
+  }
+  const body = Buffer.concat(chunks);
+  const signature = req.headers['x-hub-signature-256'];
+  if (options.secret !== undefined && !verifyWebhookSignature(options.secret, body, typeof signature === 'string' ? signature : undefined)) {
+    respondJson(res, 401, { error: 'Invalid signature' });
+    return;
+  }
+
+  const eventName = req.headers['x-github-event'];
+  if (eventName === 'ping') {
+    respondJson(res, 200, { pong: true });
+    return;
+  }
+  const automation = (eventName === 'issues' || eventName === 'pull_request') && (options.rules ?? []).length > 0;
+  if (eventName !== 'issue_comment' && !automation) {
+    respondJson(res, 202, { accepted: false, reason: `Ignoring ${String(eventName)} event` });
+    return;
+  }
+
+  let payload: unknown;
+  try {
+    payload = JSON.parse(body.toString('utf8'));
+  } catch {
+    respondJson(res, 400, { error: 'Body must be JSON' });
+    return;
+  }
+  respondJson(res, 202, { accepted: true });
+
+  const handled = automation
+    ? handleAutomationEvent(options.rules ?? [], eventName, payload, options.dependencies)
+    : handlePrComment(options.config, payload, options.dependencies).then((outcome) => [outcome]);
+  const task = handled
+    .catch((error): PrCommandOutcome[] => [{ status: 'failed', reason: error instanceof Error ? error.message : String(error) }])
+    .then((outcomes) => outcomes.forEach((outcome) => options.onOutcome?.(outcome)))
+    .finally(() => pending.delete(task));
+  pending.add(task);
+}
+
+async function runAndReport(
+  dependencies: PrCommandDependencies,
+  target: { repository: string; number: number },
+  quoted: string,
+  started: string,
+  workflowId: string,
+  input: Record<string, unknown>,
+): Promise<PrCommandOutcome> {
+  await dependencies.postComment(target.repository, target.number, `${quoted}\n\n${started}`);
+  let result: Awaited<ReturnType<PrCommandDependencies['runWorkflow']>>;
+  try {
+    result = await dependencies.runWorkflow({ workflowId, input });
+  } catch (error) {
+    const reason = error instanceof Error ? error.message : String(error);
+    await dependencies.postComment(target.repository, target.number, `${quoted}\n\nWorkflow \`${workflowId}\` could not start: ${reason}`);
+    return { ...target, status: 'failed', workflowId, reason };
+  }
+
+  const header = result.success
+    ? `Workflow \`${workflowId}\` completed (trace \`${result.traceId}\`).`
+    : `Workflow \`${workflowId}\` failed (trace \`${result.traceId}\`): ${result.error?.message ?? 'unknown error'}`;
+  await dependencies.postComment(target.repository, target.number, [quoted, '', header, ...formatOutput(result.output)].join('\n'));
+  return {
+    ...target,
+    status: result.success ? 'completed' : 'failed',
+    workflowId,
+    traceId: result.traceId,
+    reason: result.success ? undefined : result.error?.message,
+  };
+}
+
+function sameLabel(left: string, right: string): boolean {
+  return left.toLowerCase() === right.toLowerCase();
+}
+
+function formatOutput(output: unknown): string[] {
+  if (output === undefined || output === null) {
+    return [];
+  }
+  let text = typeof output === 'string' ? output : JSON.stringify(output, null, 2);
+  if (text.length > MAX_COMMENT_OUTPUT_CHARS) {
+    text = `${text.slice(0, MAX_COMMENT_OUTPUT_CHARS)}\n... (truncated)`;
+  }
+  return ['', '<details><summary>Output</summary>', '', '```', text, '```', '</details>'];
+}
+
+function resolveCommands(value: Record<string, unknown>): Record<string, PrCommandDefinition> {
+  const commands: Record<string, PrCommandDefinition> = {};
+  for (const [name, entry] of Object.entries(value)) {
+    if (typeof entry === 'string' && entry.length > 0) {
+      commands[name] = { workflow: entry };
+    } else if (isRecord(entry) && typeof entry.workflow === 'string' && entry.workflow.length > 0) {
+      commands[name] = { workflow: entry.workflow, ...(isRecord(entry.input) ? { input: entry.input } : {}) };
+    }
+  }
+  return commands;
+}
+
+function toStringList(value: unknown): string[] {
+  return Array.isArray(value) ? value.filter((entry): entry is string => typeof entry === 'string' && entry.length > 0) : [];
+}
+
+function respondJson(res: ServerResponse, status: number, body: Record<string, unknown>): void {
+  res.writeHead(status, { 'Content-Type': 'application/json' });
+  res.end(JSON.stringify(body));
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
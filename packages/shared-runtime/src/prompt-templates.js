@@ -0,0 +1,64 @@
+import { execFile } from 'node:child_process';
+import { readFile } from 'node:fs/promises';
+import { basename, join, resolve } from 'node:path';
+import { promisify } from 'node:util';
+import { renderTemplate, TEMPLATED_STEP_FIELDS } from '@defai.digital/workflow-engine';
+const execFileAsync = promisify(execFile);
+/**
+ * The variables agent and workflow prompts can reference: `project.name`
+ * (package.json name, else the directory name), `project.root`,
+ * `git.branch`, `git.commit`, `env.*`, and `input.*`. Git values are left
+ * out when the workspace is not a repository.
+ */
+export async function collectTemplateVariables(request) {
+    const [name, branch, commit] = await Promise.all([
+        readProjectName(request.basePath),
+        readGit(request.basePath, ['rev-parse', '--abbrev-ref', 'HEAD']),
+        readGit(request.basePath, ['rev-parse', '--short', 'HEAD']),
+    ]);
+    return {
+        project: { name, root: resolve(request.basePath) },
+        git: { branch, commit },
+        env: { ...(request.env ?? process.env) },
+        input: request.input ?? {},
+    };
+}
+export function renderTemplatePreviews(templates, variables) {
+    return templates.map(({ field, template }) => {
+        const { text, missing } = renderTemplate(template, variables);
+        return {
+            field,
+            text,
+            missing: missing.filter((path) => !path.startsWith('steps.')),
+            deferred: missing.filter((path) => path.startsWith('steps.')),
+        };
+    });
+}
+export function listStepTemplates(steps) {
+    return steps.flatMap((step) => TEMPLATED_STEP_FIELDS.flatMap((field) => {
+        const template = step.config?.[field];
+        return typeof template === 'string' ? [{ field: `${step.stepId}.${field}`, template }] : [];
+    }));
+}
+async function readProjectName(basePath) {
+    try {
+        const manifest = JSON.parse(await readFile(join(basePath, 'package.json'), 'utf8'));
+        if (typeof manifest.name === 'string' && manifest.name.length > 0) {
+            return manifest.name;
+        }
+    }
+    catch {
+        // Not a Node project; fall back to the directory name.
+    }
+    return basename(resolve(basePath));
+}
+async function readGit(basePath, args) {
+    try {
+        const { stdout } = await execFileAsync('git', args, { cwd: basePath });
+        const value = stdout.trim();
+        return value.length > 0 ? value : undefined;
+    }
+    catch {
+        return undefined;
+    }
+}
@@ -1,6 +1,7 @@
 import { spawn, spawnSync } from 'node:child_process';
 import { readFile } from 'node:fs/promises';
 import { join } from 'node:path';
+import { recordProviderLatency } from './provider-latency.js';
 const DEFAULT_PROVIDER_TIMEOUT_MS = 30_000;
 const PROVIDER_NATIVE_COMMANDS = {
     claude: { command: 'claude', protocol: 'raw-stdin' },
@@ -37,7 +38,18 @@ export function createProviderBridge(config) {
                     error: `No provider executor configured for "${request.provider}".`,
                 };
             }
-            return executeProviderSubprocess(providerConfig, request, config.basePath, env);
+            const outcome = await executeProviderSubprocess(providerConfig, request, config.basePath, env);
+            // A cancelled call says nothing about how long the provider would have taken.
+            if (outcome.type !== 'unavailable' && outcome.response.errorCode !== 'PROVIDER_CANCELLED') {
+                // Latency history is best effort; a failed write never fails the call.
+                await recordProviderLatency(config.basePath, {
+                    provider: request.provider,
+                    model: request.model,
+                    latencyMs: outcome.response.latencyMs,
+                    success: outcome.response.success,
+                }).catch(() => undefined);
+            }
+            return outcome;
         },
     };
 }
@@ -72,7 +84,12 @@ async function executeProviderSubprocess(providerConfig, request, basePath, env)
     let stdout = '';
     let stderr = '';
     let timedOut = false;
+    let cancelled = false;
     return new Promise((resolve) => {
+        if (request.signal?.aborted === true) {
+            resolve(cancelledOutcome(request, 0));
+            return;
+        }
         const child = spawn(providerConfig.command, buildProviderSpawnArgs(providerConfig, request), {
             cwd: basePath,
             env,
@@ -82,6 +99,11 @@ async function executeProviderSubprocess(providerConfig, request, basePath, env)
             timedOut = true;
             child.kill('SIGKILL');
         }, timeoutMs);
+        const onAbort = () => {
+            cancelled = true;
+            child.kill('SIGKILL');
+        };
+        request.signal?.addEventListener('abort', onAbort, { once: true });
         child.stdout.setEncoding('utf8');
         child.stdout.on('data', (chunk) => {
             stdout += chunk;
@@ -92,6 +114,7 @@ async function executeProviderSubprocess(providerConfig, request, basePath, env)
         });
         child.on('error', (error) => {
             clearTimeout(timer);
+            request.signal?.removeEventListener('abort', onAbort);
             child.stdin?.destroy();
             resolve({
                 type: 'failure',
@@ -108,6 +131,11 @@ async function executeProviderSubprocess(providerConfig, request, basePath, env)
         });
         child.on('close', (code) => {
             clearTimeout(timer);
+            request.signal?.removeEventListener('abort', onAbort);
+            if (cancelled) {
+                resolve(cancelledOutcome(request, Date.now() - startedAt));
+                return;
+            }
             if (timedOut) {
                 resolve({
                     type: 'failure',
@@ -170,6 +198,20 @@ async function executeProviderSubprocess(providerConfig, request, basePath, env)
         }
     });
 }
+function cancelledOutcome(request, latencyMs) {
+    return {
+        type: 'failure',
+        response: {
+            success: false,
+            provider: request.provider,
+            model: request.model,
+            latencyMs,
+            errorCode: 'PROVIDER_CANCELLED',
+            error: `Provider "${request.provider}" call was cancelled.`,
+            mode: 'subprocess',
+        },
+    };
+}
 function normalizeProviderOutput(stdout, request, latencyMs) {
     const trimmed = stdout.trim();
     if (trimmed.length === 0) {
@@ -1,6 +1,7 @@
 import { spawn, spawnSync } from 'node:child_process';
 import { readFile } from 'node:fs/promises';
 import { join } from 'node:path';
+import { recordProviderLatency } from './provider-latency.js';
 
 export type ProviderExecutionMode = 'auto' | 'simulate' | 'require-real';
 export type ProviderExecutionProtocol = 'json-stdio' | 'raw-stdin' | 'argv-last';
@@ -13,6 +14,8 @@ export interface ProviderExecutionRequest {
   maxTokens?: number;
   temperature?: number;
   timeoutMs?: number;
+  // Aborting kills the provider process; the call fails with PROVIDER_CANCELLED.
+  signal?: AbortSignal;
 }
 
 export interface ProviderExecutionResponse {
@@ -88,7 +91,18 @@ export function createProviderBridge(config: {
         };
       }
 
-      return executeProviderSubprocess(providerConfig, request, config.basePath, env);
+      const outcome = await executeProviderSubprocess(providerConfig, request, config.basePath, env);
+      // A cancelled call says nothing about how long the provider would have taken.
+      if (outcome.type !== 'unavailable' && outcome.response.errorCode !== 'PROVIDER_CANCELLED') {
+        // Latency history is best effort; a failed write never fails the call.
+        await recordProviderLatency(config.basePath, {
+          provider: request.provider,
+          model: request.model,
+          latencyMs: outcome.response.latencyMs,
+          success: outcome.response.success,
+        }).catch(() => undefined);
+      }
+      return outcome;
     },
   };
 }
@@ -138,8 +152,13 @@ async function executeProviderSubprocess(
   let stdout = '';
   let stderr = '';
   let timedOut = false;
+  let cancelled = false;
 
   return new Promise<ProviderExecutionOutcome>((resolve) => {
+    if (request.signal?.aborted === true) {
+      resolve(cancelledOutcome(request, 0));
+      return;
+    }
     const child = spawn(providerConfig.command, buildProviderSpawnArgs(providerConfig, request), {
       cwd: basePath,
       env,
@@ -150,6 +169,11 @@ async function executeProviderSubprocess(
       timedOut = true;
       child.kill('SIGKILL');
     }, timeoutMs);
+    const onAbort = () => {
+      cancelled = true;
+      child.kill('SIGKILL');
+    };
+    request.signal?.addEventListener('abort', onAbort, { once: true });
 
     child.stdout.setEncoding('utf8');
     child.stdout.on('data', (chunk: string) => {
@@ -163,6 +187,7 @@ async function executeProviderSubprocess(
 
     child.on('error', (error) => {
       clearTimeout(timer);
+      request.signal?.removeEventListener('abort', onAbort);
       child.stdin?.destroy();
       resolve({
         type: 'failure',
@@ -180,6 +205,12 @@ async function executeProviderSubprocess(
 
     child.on('close', (code) => {
       clearTimeout(timer);
+      request.signal?.removeEventListener('abort', onAbort);
+
+      if (cancelled) {
+        resolve(cancelledOutcome(request, Date.now() - startedAt));
+        return;
+      }
 
       if (timedOut) {
         resolve({
@@ -245,6 +276,21 @@ async function executeProviderSubprocess(
   });
 }
 
+function cancelledOutcome(request: ProviderExecutionRequest, latencyMs: number): ProviderExecutionOutcome {
+  return {
+    type: 'failure',
+    response: {
+      success: false,
+      provider: request.provider,
+      model: request.model,
+      latencyMs,
+      errorCode: 'PROVIDER_CANCELLED',
+      error: `Provider "${request.provider}" call was cancelled.`,
+      mode: 'subprocess',
+    },
+  };
+}
+
 function normalizeProviderOutput(
   stdout: string,
   request: ProviderExecutionRequest,
@@ -0,0 +1,192 @@
+import { mkdir, readFile, writeFile } from 'node:fs/promises';
+import { dirname, join } from 'node:path';
+export const PROVIDER_LATENCY_FILE = join('.automatosx', 'provider-latency.json');
+// Samples kept per provider/model; percentiles roll over this window.
+const WINDOW_SIZE = 50;
+const DEFAULT_MIN_SAMPLES = 5;
+const DEFAULT_SWITCH_MARGIN = 0.2;
+const MAX_FAILURE_RATE = 0.5;
+const HOUR_MS = 60 * 60 * 1000;
+// Writes from one process are chained so concurrent steps don't drop samples.
+const pendingWrites = new Map();
+export function resolveLatencyRoutingConfig(value) {
+    const latency = isRecord(value) && isRecord(value.latency) ? value.latency : {};
+    const providers = Array.isArray(latency.providers)
+        ? [...new Set(latency.providers.filter((provider) => typeof provider === 'string' && provider.length > 0))]
+        : [];
+    const percentile = latency.percentile === 'p50' || latency.percentile === 'p99' ? latency.percentile : 'p95';
+    return {
+        enabled: latency.enabled === true && providers.length > 1,
+        providers,
+        percentile,
+        minSamples: typeof latency.minSamples === 'number' && latency.minSamples >= 1 ? Math.floor(latency.minSamples) : DEFAULT_MIN_SAMPLES,
+        switchMargin: typeof latency.switchMargin === 'number' && latency.switchMargin >= 0 && latency.switchMargin < 1
+            ? latency.switchMargin
+            : DEFAULT_SWITCH_MARGIN,
+    };
+}
+export function recordProviderLatency(basePath, sample) {
+    return updateLatencyFile(basePath, (file) => {
+        const key = sampleKey(sample.provider, sample.model);
+        const samples = file.samples[key] ?? [];
+        samples.push({ at: new Date().toISOString(), latencyMs: Math.max(0, Math.round(sample.latencyMs)), success: sample.success });
+        file.samples[key] = samples.slice(-WINDOW_SIZE);
+    });
+}
+export async function summarizeProviderLatency(basePath) {
+    const file = await readLatencyFile(basePath);
+    return Object.entries(file.samples)
+        .map(([key, samples]) => {
+            const [provider = key, model] = key.split('\u0000');
+            return {
+                provider,
+                ...(model !== undefined && model.length > 0 ? { model } : {}),
+                ...summarizeSamples(samples),
+            };
+        })
+        .filter((summary) => summary.samples > 0)
+        .sort((left, right) => left.provider.localeCompare(right.provider) || (left.model ?? '').localeCompare(right.model ?? ''));
+}
+export async function readLatencyRoutingStatus(basePath, config) {
+    const file = await readLatencyFile(basePath);
+    return {
+        enabled: config.enabled,
+        providers: config.providers,
+        percentile: config.percentile,
+        ...(config.enabled && file.routed !== undefined ? { routedProvider: file.routed.provider, routedAt: file.routed.at } : {}),
+    };
+}
+/**
+ * The provider a latency-sensitive step should use. A provider qualifies once
+ * it has `minSamples` successful calls in the window and fails less than half
+ * the time; the fastest one by the configured percentile wins. Routing stays
+ * on the previous choice until another provider beats it by `switchMargin`,
+ * so percentiles that drift past each other don't flip every call. Without
+ * any qualifying provider the requested one is kept.
+ */
+export async function routeByLatency(basePath, requested, config) {
+    if (!config.enabled) {
+        return requested;
+    }
+    let routed = requested;
+    await updateLatencyFile(basePath, (file) => {
+        const timings = new Map();
+        for (const provider of config.providers) {
+            const timing = qualifyingPercentile(file, provider, config.percentile, config.minSamples);
+            if (timing !== undefined) {
+                timings.set(provider, timing);
+            }
+        }
+        const ranked = [...timings.entries()].sort((left, right) => left[1] - right[1] || config.providers.indexOf(left[0]) - config.providers.indexOf(right[0]));
+        const fastest = ranked[0];
+        if (fastest === undefined) {
+            return;
+        }
+        const current = file.routed?.provider;
+        const currentTiming = current === undefined ? undefined : timings.get(current);
+        if (current !== undefined && currentTiming !== undefined && fastest[1] > currentTiming * (1 - config.switchMargin)) {
+            routed = current;
+            return;
+        }
+        routed = fastest[0];
+        if (current !== routed) {
+            file.routed = { provider: routed, at: new Date().toISOString() };
+        }
+    });
+    return routed;
+}
+/**
+ * A provider's latency at the given percentile across its models, or
+ * undefined until it qualifies for routing (see routeByLatency).
+ */
+export async function readProviderPercentile(basePath, provider, percentile, minSamples = DEFAULT_MIN_SAMPLES) {
+    return qualifyingPercentile(await readLatencyFile(basePath), provider, percentile, minSamples);
+}
+// Takes one hedge from the hourly budget; false once `maxPerHour` hedges were sent in the last hour.
+export async function claimHedgeBudget(basePath, maxPerHour, now = new Date()) {
+    let claimed = false;
+    await updateLatencyFile(basePath, (file) => {
+        const recent = (file.hedges ?? []).filter((at) => now.getTime() - Date.parse(at) < HOUR_MS);
+        claimed = recent.length < maxPerHour;
+        file.hedges = claimed ? [...recent, now.toISOString()] : recent;
+    });
+    return claimed;
+}
+export async function countRecentHedges(basePath, now = new Date()) {
+    const file = await readLatencyFile(basePath);
+    return (file.hedges ?? []).filter((at) => now.getTime() - Date.parse(at) < HOUR_MS).length;
+}
+function qualifyingPercentile(file, provider, percentile, minSamples) {
+    const samples = Object.entries(file.samples)
+        .filter(([key]) => key.split('\u0000')[0] === provider)
+        .flatMap(([, entries]) => entries);
+    const summary = summarizeSamples(samples);
+    const successes = summary.samples - summary.failures;
+    return successes >= minSamples && summary.failures / summary.samples < MAX_FAILURE_RATE ? summary[percentile] : undefined;
+}
+function summarizeSamples(samples) {
+    const sorted = samples.filter((sample) => sample.success).map((sample) => sample.latencyMs).sort((left, right) => left - right);
+    return {
+        samples: samples.length,
+        failures: samples.filter((sample) => !sample.success).length,
+        p50: percentile(sorted, 0.5),
+        p95: percentile(sorted, 0.95),
+        p99: percentile(sorted, 0.99),
+        lastAt: samples.map((sample) => sample.at).sort().at(-1) ?? '',
+    };
+}
+function percentile(sorted, rank) {
+    if (sorted.length === 0) {
+        return 0;
+    }
+    const index = Math.min(sorted.length - 1, Math.ceil(rank * sorted.length) - 1);
+    return sorted[Math.max(0, index)] ?? 0;
+}
+function sampleKey(provider, model) {
+    return `${provider}\u0000${model ?? ''}`;
+}
+function updateLatencyFile(basePath, update) {
+    const path = join(basePath, PROVIDER_LATENCY_FILE);
+    const next = (pendingWrites.get(path) ?? Promise.resolve()).then(async () => {
+        const file = await readLatencyFile(basePath);
+        update(file);
+        await mkdir(dirname(path), { recursive: true });
+        await writeFile(path, `${JSON.stringify(file, null, 2)}\n`, 'utf8');
+    });
+    const settled = next.catch(() => undefined);
+    pendingWrites.set(path, settled);
+    void settled.then(() => {
+        if (pendingWrites.get(path) === settled) {
+            pendingWrites.delete(path);
+        }
+    });
+    return next;
+}
+async function readLatencyFile(basePath) {
+    let parsed;
+    try {
+        parsed = JSON.parse(await readFile(join(basePath, PROVIDER_LATENCY_FILE), 'utf8'));
+    }
+    catch {
+        return { samples: {} };
+    }
+    const samples = {};
+    if (isRecord(parsed) && isRecord(parsed.samples)) {
+        for (const [key, entries] of Object.entries(parsed.samples)) {
+            if (Array.isArray(entries)) {
+                samples[key] = entries.filter((entry) =>
+                    isRecord(entry) && typeof entry.at === 'string' && typeof entry.latencyMs === 'number' && typeof entry.success === 'boolean');
+            }
+        }
+    }
+    const routed = isRecord(parsed) && isRecord(parsed.routed) && typeof parsed.routed.provider === 'string' && typeof parsed.routed.at === 'string'
+        ? { provider: parsed.routed.provider, at: parsed.routed.at }
+        : undefined;
+    const hedges = isRecord(parsed) && Array.isArray(parsed.hedges)
+        ? parsed.hedges.filter((at) => typeof at === 'string')
+        : undefined;
+    return { samples, ...(routed !== undefined ? { routed } : {}), ...(hedges !== undefined ? { hedges } : {}) };
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
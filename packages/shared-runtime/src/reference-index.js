@@ -0,0 +1,247 @@
+import { readFile, stat } from 'node:fs/promises';
+import { dirname, join, resolve } from 'node:path';
+import { parseFiles } from './parse-pool.js';
+import { isInterpolated, parseSymbol } from './rename-impact.js';
+import { extractDeclarations, findClosingBracket, findDeclarationExtractor, splitTopLevel, stripStringsAndComments } from './structural-diff.js';
+import { listSourceFiles, toSymbolMatch } from './symbol-query.js';
+const MAX_SCAN_BYTES = 1024 * 1024;
+const DEFAULT_LIMIT = 500;
+const IDENTIFIER = /(?<![\w$])[A-Za-z_$][\w$]*/g;
+const QUALIFIER = /([A-Za-z_$][\w$]*)\s*(?:\?\.|\.|->|::)\s*$/;
+// Keywords of the built-in languages; they are never definitions worth indexing.
+const KEYWORDS = new Set([
+    'abstract', 'as', 'async', 'await', 'break', 'case', 'catch', 'chan', 'class', 'const', 'continue', 'default', 'defer',
+    'delete', 'do', 'else', 'enum', 'export', 'extends', 'false', 'final', 'finally', 'for', 'from', 'fun', 'func', 'go',
+    'goto', 'if', 'implements', 'import', 'in', 'instanceof', 'interface', 'is', 'let', 'map', 'new', 'nil', 'null',
+    'object', 'package', 'private', 'protected', 'public', 'range', 'return', 'select', 'sizeof', 'static', 'struct',
+    'super', 'switch', 'this', 'throw', 'throws', 'true', 'try', 'type', 'typedef', 'typeof', 'undefined', 'union', 'val',
+    'var', 'void', 'when', 'while', 'yield',
+]);
+// Per workspace; a file is re-parsed only when its size or mtime changes.
+const referenceIndexes = new Map();
+/**
+ * Identifiers used in code, with comments and string contents ignored
+ * (template literal `${...}` expressions count as code). Keywords are skipped.
+ */
+export function extractReferences(content, path = '') {
+    const code = stripStringsAndComments(content, false, path).split('\n');
+    const references = [];
+    stripStringsAndComments(content, true, path).split('\n').forEach((line, index) => {
+        for (const match of line.matchAll(IDENTIFIER)) {
+            const name = match[0];
+            const column = match.index ?? 0;
+            if (KEYWORDS.has(name)) {
+                continue;
+            }
+            if (code[index]?.slice(column, column + name.length) !== name && !isInterpolated(line, column)) {
+                continue;
+            }
+            const qualifier = QUALIFIER.exec(line.slice(0, column))?.[1];
+            references.push({ name, line: index + 1, column: column + 1, ...(qualifier !== undefined ? { qualifier } : {}) });
+        }
+    });
+    return references;
+}
+/**
+ * Go to definition for `Name`, `Receiver.Name`, or the identifier at a
+ * 1-based line and column of a file. Resolution is by name, not by type: a
+ * qualifier such as `server` in `server.start()` only ranks declarations whose
+ * receiver matches it (case-insensitively) first.
+ */
+export async function findDefinition(request) {
+    const files = await loadReferenceIndex(request.basePath, request.paths);
+    const target = resolveTarget(request, files);
+    const preferred = (target.receiver ?? target.at?.qualifier)?.toLowerCase();
+    const score = (symbol) =>
+        (preferred !== undefined && symbol.receiver?.toLowerCase() === preferred ? 0 : 2) + (symbol.path === target.at?.path ? 0 : 1);
+    const definitions = [...files.values()]
+        .flatMap((file) => file.declarations)
+        .filter((symbol) => symbol.name === target.name)
+        .filter((symbol) => target.receiver === undefined || symbol.receiver === target.receiver)
+        .sort((left, right) => score(left) - score(right) || left.path.localeCompare(right.path) || left.line - right.line);
+    return {
+        name: target.name,
+        ...(target.receiver !== undefined ? { receiver: target.receiver } : {}),
+        ...(target.at !== undefined ? { at: target.at } : {}),
+        definitions,
+        scannedFiles: files.size,
+    };
+}
+/**
+ * Every use of a name across the workspace, its declarations included unless
+ * `includeDefinitions` is false. For `Receiver.Name`, declarations of the same
+ * name on other receivers are left out; call sites cannot be told apart
+ * without types, so all of them are listed. Uses of a Go generic carry their
+ * explicit type arguments, and `Processor[string]` keeps only that
+ * instantiation; inferred ones (`Map(xs, f)`) have none.
+ */
+export async function findReferences(request) {
+    const files = await loadReferenceIndex(request.basePath, request.paths);
+    const target = resolveTarget(request, files);
+    const generic = [...files.values()].some((file) => file.declarations.some((symbol) => symbol.name === target.name && symbol.typeParams !== undefined));
+    const found = [];
+    for (const [path, file] of files) {
+        const declarations = file.declarations.filter((symbol) => symbol.name === target.name);
+        for (const reference of file.references.get(target.name) ?? []) {
+            const declaration = declarations.find((symbol) => symbol.line === reference.line);
+            if (declaration !== undefined && target.receiver !== undefined && declaration.receiver !== target.receiver) {
+                continue;
+            }
+            if (declaration !== undefined && request.includeDefinitions === false) {
+                continue;
+            }
+            const typeArgs = generic && declaration === undefined ? instantiationAt(file.lines[reference.line - 1] ?? '', reference) : undefined;
+            if (target.typeArgs !== undefined && typeArgs?.join(',') !== target.typeArgs.join(',')) {
+                continue;
+            }
+            found.push({
+                path,
+                line: reference.line,
+                column: reference.column,
+                kind: declaration !== undefined ? 'definition' : 'reference',
+                text: (file.lines[reference.line - 1] ?? '').trim(),
+                ...(reference.qualifier !== undefined ? { qualifier: reference.qualifier } : {}),
+                ...(typeArgs !== undefined ? { typeArgs } : {}),
+            });
+        }
+    }
+    found.sort((left, right) => left.path.localeCompare(right.path) || left.line - right.line || left.column - right.column);
+    const limit = request.limit ?? DEFAULT_LIMIT;
+    const instantiations = new Map();
+    for (const location of found.filter((candidate) => candidate.typeArgs !== undefined)) {
+        const key = location.typeArgs.join(',');
+        instantiations.set(key, { typeArgs: location.typeArgs, count: (instantiations.get(key)?.count ?? 0) + 1 });
+    }
+    return {
+        name: target.name,
+        ...(target.receiver !== undefined ? { receiver: target.receiver } : {}),
+        references: found.slice(0, limit),
+        files: [...new Set(found.map((location) => location.path))],
+        ...(instantiations.size > 0 ? { instantiations: [...instantiations.values()].sort((left, right) => right.count - left.count) } : {}),
+        scannedFiles: files.size,
+        truncated: found.length > limit,
+    };
+}
+// The parsed source files under `paths`, re-read only when they change on disk.
+export async function loadReferenceIndex(basePath, paths) {
+    const key = resolve(basePath);
+    const cache = referenceIndexes.get(key) ?? new Map();
+    referenceIndexes.set(key, cache);
+    const listed = [];
+    const stale = [];
+    for (const path of await listSourceFiles(basePath, paths)) {
+        const absolutePath = join(basePath, path);
+        let info;
+        try {
+            info = await stat(absolutePath);
+        }
+        catch {
+            continue;
+        }
+        if (info.size > MAX_SCAN_BYTES) {
+            continue;
+        }
+        listed.push(path);
+        const cached = cache.get(path);
+        if (cached === undefined || cached.mtimeMs !== info.mtimeMs || cached.size !== info.size) {
+            // Plugin extractors are registered on this thread only.
+            stale.push({ path, absolutePath, size: info.size, mtimeMs: info.mtimeMs, pooled: findDeclarationExtractor(path) === undefined });
+        }
+    }
+    const parsed = new Set();
+    if (stale.length > 0) {
+        await parseFiles(basePath, stale, async (job) => parseSourceFile(await readFile(job.absolutePath, 'utf8'), job.path), (job, source) => {
+            const references = new Map();
+            for (const reference of source.references) {
+                references.set(reference.name, [...(references.get(reference.name) ?? []), reference]);
+            }
+            parsed.add(job.path);
+            cache.set(job.path, { mtimeMs: job.mtimeMs, size: job.size, lines: source.lines, declarations: source.declarations, references });
+        });
+    }
+    // A file that could not be read or parsed is left out rather than served stale.
+    const reparsed = new Set(stale.map((job) => job.path));
+    const files = new Map();
+    for (const path of listed) {
+        const indexed = cache.get(path);
+        if (indexed !== undefined && (!reparsed.has(path) || parsed.has(path))) {
+            files.set(path, indexed);
+        }
+    }
+    return files;
+}
+// What indexing needs of one file; runs on parse pool threads too.
+export function parseSourceFile(content, path) {
+    return {
+        lines: content.split('\n'),
+        declarations: extractDeclarations(content, path).map((declaration) => toSymbolMatch(declaration, path)),
+        references: extractReferences(content, path),
+    };
+}
+/**
+ * The one declaration a use of a name refers to, judged without types: a
+ * qualifier matching a receiver (case-insensitively) narrows first, then the
+ * same file, then the same directory. Undefined when several remain.
+ */
+export function resolveByName(reference, path, candidates) {
+    let matches = candidates;
+    const qualifier = reference.qualifier?.toLowerCase();
+    const narrowings = [
+        (symbol) => qualifier !== undefined && symbol.receiver?.toLowerCase() === qualifier,
+        (symbol) => symbol.path === path,
+        (symbol) => dirname(symbol.path) === dirname(path),
+    ];
+    for (const narrowing of narrowings) {
+        const narrowed = matches.filter(narrowing);
+        if (matches.length > 1 && narrowed.length > 0) {
+            matches = narrowed;
+        }
+    }
+    return matches.length === 1 ? matches[0] : undefined;
+}
+// `[string, int]` right after a reference, with whitespace dropped so `Pair[K,V]` and `Pair[K, V]` agree.
+// The receiver of a method on a generic type (`func (l *List[T])`) is a declaration, not an instantiation.
+function instantiationAt(line, reference) {
+    const open = reference.column - 1 + reference.name.length;
+    if (line[open] !== '[' || (/^\s*func\s*\(/.test(line) && open < line.indexOf(')'))) {
+        return undefined;
+    }
+    return parseTypeArgs(line.slice(open + 1, findClosingBracket(line, open)));
+}
+function parseTypeArgs(text) {
+    return splitTopLevel(text, ',').map((arg) => arg.replace(/\s+/g, ''));
+}
+function resolveTarget(request, files) {
+    if (request.symbol !== undefined) {
+        // `Processor[string]` names one instantiation of a generic.
+        const instantiation = /^([^[]+)\[(.+)\]$/.exec(request.symbol.trim());
+        return instantiation === null
+            ? parseSymbol(request.symbol)
+            : { ...parseSymbol(instantiation[1]), typeArgs: parseTypeArgs(instantiation[2]) };
+    }
+    if (request.path === undefined || request.line === undefined || request.column === undefined) {
+        throw new Error('Pass a symbol, or a path with a 1-based line and column.');
+    }
+    const path = request.path.replace(/^\.\//, '');
+    const file = files.get(path);
+    if (file === undefined) {
+        throw new Error(`${path} is not an indexed source file`);
+    }
+    const reference = [...file.references.values()]
+        .flat()
+        .find((candidate) => candidate.line === request.line
+            && request.column >= candidate.column
+            && request.column < candidate.column + candidate.name.length);
+    if (reference === undefined) {
+        throw new Error(`No identifier at ${path}:${request.line}:${request.column}`);
+    }
+    return {
+        name: reference.name,
+        at: {
+            path,
+            line: reference.line,
+            column: reference.column,
+            ...(reference.qualifier !== undefined ? { qualifier: reference.qualifier } : {}),
+        },
+    };
+}
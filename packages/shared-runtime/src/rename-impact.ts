@@ -0,0 +1,234 @@
+import { readFile, stat } from 'node:fs/promises';
+import { join } from 'node:path';
+import { extractDeclarations, stripStringsAndComments } from './structural-diff.js';
+import { listSourceFiles, toSymbolMatch, type SymbolMatch } from './symbol-query.js';
+
+export type RenameImpactKind = 'definition' | 'interface-member' | 'implementation' | 'struct-tag' | 'reference' | 'string';
+
+export interface RenameImpactLocation {
+  path: string;
+  line: number;
+  column: number;
+  kind: RenameImpactKind;
+  text: string;
+  // Interface, struct, or receiver the location belongs to, when known.
+  container?: string;
+}
+
+export interface RuntimeRenameImpactResponse {
+  symbol: string;
+  name: string;
+  receiver?: string;
+  locations: RenameImpactLocation[];
+  files: string[];
+  counts: Record<RenameImpactKind, number>;
+  scannedFiles: number;
+  truncated: boolean;
+}
+
+const MAX_SCAN_BYTES = 1024 * 1024;
+const DEFAULT_LIMIT = 500;
+// When one line matches several ways, the most specific kind is reported.
+const KIND_PRIORITY: RenameImpactKind[] = ['definition', 'interface-member', 'implementation', 'struct-tag', 'reference', 'string'];
+
+interface ScannedFile {
+  path: string;
+  lines: string[];
+  code: string[];
+  withStrings: string[];
+  symbols: SymbolMatch[];
+}
+
+/**
+ * Lists every line a rename of `symbol` (`Name` or `Receiver.Name`) would
+ * touch across the TS/JS, Go, C/C++, Java/Kotlin, Rust, and Python files of the workspace.
+ * Matching is by name, not by type: call sites of same-named members on other
+ * types are included, and methods of that name become implementations once
+ * any interface declares the member. String literals mentioning the name are listed separately for
+ * review, as are Go struct tags whose value spells the name (user_id for UserID).
+ */
+export async function analyzeRenameImpact(request: {
+  basePath: string;
+  symbol: string;
+  paths?: string[];
+  limit?: number;
+}): Promise<RuntimeRenameImpactResponse> {
+  const { name, receiver } = parseSymbol(request.symbol);
+  const files: ScannedFile[] = [];
+  for (const path of await listSourceFiles(request.basePath, request.paths)) {
+    const absolutePath = join(request.basePath, path);
+    try {
+      if ((await stat(absolutePath)).size > MAX_SCAN_BYTES) {
+        continue;
+      }
+    } catch {
+      continue;
+    }
+    const content = await readFile(absolutePath, 'utf8');
+    if (!content.includes(name) && !content.toLowerCase().includes(toSnakeCase(name))) {
+      files.push({ path, lines: [], code: [], withStrings: [], symbols: [] });
+      continue;
+    }
+    files.push({
+      path,
+      lines: content.split('\n'),
+      code: stripStringsAndComments(content, false, path).split('\n'),
+      withStrings: stripStringsAndComments(content, true, path).split('\n'),
+      symbols: extractDeclarations(content, path).map((declaration) => toSymbolMatch(declaration, path)),
+    });
+  }
+
+  const found = new Map<string, RenameImpactLocation>();
+  const add = (file: ScannedFile, index: number, column: number, kind: RenameImpactKind, container?: string): void => {
+    // A field and its own tag share a line but are separate edits.
+    const key = `${file.path}:${index + 1}${kind === 'struct-tag' ? ':tag' : ''}`;
+    const existing = found.get(key);
+    if (existing !== undefined && KIND_PRIORITY.indexOf(existing.kind) <= KIND_PRIORITY.indexOf(kind)) {
+      return;
+    }
+    found.set(key, {
+      path: file.path,
+      line: index + 1,
+      column: column + 1,
+      kind,
+      text: (file.lines[index] ?? '').trim(),
+      ...(container !== undefined ? { container } : {}),
+    });
+  };
+
+  const declaredByInterface = files.some((file) => findInterfaceMembers(file, name).length > 0);
+  // Lines of same-named methods on other receivers, which a qualified rename leaves alone.
+  const untouched = new Set<string>();
+  for (const file of files) {
+    for (const symbol of file.symbols.filter((candidate) => candidate.name === name)) {
+      if (receiver === undefined || symbol.receiver === receiver) {
+        add(file, symbol.line - 1, columnOf(file.lines[symbol.line - 1], name), 'definition', symbol.receiver);
+      } else if (symbol.receiver !== undefined && declaredByInterface) {
+        add(file, symbol.line - 1, columnOf(file.lines[symbol.line - 1], name), 'implementation', symbol.receiver);
+      } else {
+        untouched.add(`${file.path}:${symbol.line}`);
+      }
+    }
+    for (const member of findInterfaceMembers(file, name)) {
+      add(file, member.index, member.column, 'interface-member', member.container);
+    }
+    for (const field of findStructFields(file, name)) {
+      add(file, field.index, field.column, receiver === undefined || receiver === field.container ? 'definition' : 'reference', field.container);
+    }
+    for (const tag of findStructTags(file, name)) {
+      add(file, tag.index, tag.column, 'struct-tag', tag.container);
+    }
+  }
+
+  const identifier = new RegExp(`(?<![\\w$])${escapeRegExp(name)}(?![\\w$])`, 'g');
+  for (const file of files) {
+    file.withStrings.forEach((line, index) => {
+      if (untouched.has(`${file.path}:${index + 1}`)) {
+        return;
+      }
+      for (const match of line.matchAll(identifier)) {
+        const column = match.index ?? 0;
+        const inCode = file.code[index]?.slice(column, column + name.length) === name;
+        add(file, index, column, inCode || isInterpolated(line, column) ? 'reference' : 'string');
+      }
+    });
+  }
+
+  const locations = [...found.values()].sort((left, right) => left.path.localeCompare(right.path) || left.line - right.line);
+  const limit = request.limit ?? DEFAULT_LIMIT;
+  const counts = Object.fromEntries(KIND_PRIORITY.map((kind) => [kind, 0])) as Record<RenameImpactKind, number>;
+  for (const location of locations) {
+    counts[location.kind] += 1;
+  }
+  return {
+    symbol: request.symbol,
+    name,
+    ...(receiver !== undefined ? { receiver } : {}),
+    locations: locations.slice(0, limit),
+    files: [...new Set(locations.map((location) => location.path))],
+    counts,
+    scannedFiles: files.length,
+    truncated: locations.length > limit,
+  };
+}
+
+// Accepts `Name`, `Receiver.Name`, and Go's `(*Receiver).Name`.
+export function parseSymbol(symbol: string): { name: string; receiver?: string } {
+  const normalized = symbol.trim().replace(/^\(\*?([\w$]+)\)/, '$1');
+  if (!/^[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)?$/.test(normalized)) {
+    throw new Error(`Invalid symbol "${symbol}". Expected Name or Receiver.Name.`);
+  }
+  const dot = normalized.indexOf('.');
+  return dot === -1 ? { name: normalized } : { name: normalized.slice(dot + 1), receiver: normalized.slice(0, dot) };
+}
+
+function findInterfaceMembers(file: ScannedFile, name: string): Array<{ index: number; column: number; container: string }> {
+  const member = new RegExp(`^(\\s*(?:readonly\\s+)?)${escapeRegExp(name)}\\??\\s*[(:<]`);
+  return file.symbols
+    .filter((symbol) => symbol.kind === 'interface')
+    .flatMap((symbol) => spanIndexes(symbol)
+      .map((index) => ({ index, match: member.exec(file.code[index] ?? '') }))
+      .filter((entry): entry is { index: number; match: RegExpExecArray } => entry.match !== null)
+      .map(({ index, match }) => ({ index, column: match[1]!.length, container: symbol.name })));
+}
+
+function findStructFields(file: ScannedFile, name: string): Array<{ index: number; column: number; container: string }> {
+  if (!file.path.endsWith('.go')) {
+    return [];
+  }
+  const field = new RegExp(`^(\\s+)${escapeRegExp(name)}(?:\\s*,\\s*\\w+)*\\s+[\\w*\\[\\]{}.]`);
+  return goStructs(file)
+    .flatMap((symbol) => spanIndexes(symbol)
+      .map((index) => ({ index, match: field.exec(file.code[index] ?? '') }))
+      .filter((entry): entry is { index: number; match: RegExpExecArray } => entry.match !== null)
+      .map(({ index, match }) => ({ index, column: match[1]!.length, container: symbol.name })));
+}
+
+// Tag values such as `json:"user_id,omitempty"` naming the field being renamed.
+function findStructTags(file: ScannedFile, name: string): Array<{ index: number; column: number; container: string }> {
+  if (!file.path.endsWith('.go')) {
+    return [];
+  }
+  const spellings = new Set([name, name.toLowerCase(), `${name[0]!.toLowerCase()}${name.slice(1)}`, toSnakeCase(name)]);
+  return goStructs(file).flatMap((symbol) => spanIndexes(symbol).flatMap((index) => {
+    const line = file.lines[index] ?? '';
+    const tag = /`([^`]*)`/.exec(line);
+    if (tag === null) {
+      return [];
+    }
+    for (const entry of tag[1]!.matchAll(/\w+:"([^",]*)/g)) {
+      if (spellings.has(entry[1]!)) {
+        return [{ index, column: (tag.index ?? 0) + 1 + (entry.index ?? 0) + entry[0].length - entry[1]!.length, container: symbol.name }];
+      }
+    }
+    return [];
+  }));
+}
+
+function goStructs(file: ScannedFile): SymbolMatch[] {
+  return file.symbols.filter((symbol) => symbol.kind === 'type' && /\bstruct\s*\{/.test(symbol.signature));
+}
+
+// Zero-based indexes of the lines inside a declaration's braces.
+function spanIndexes(symbol: SymbolMatch): number[] {
+  return Array.from({ length: Math.max(0, symbol.endLine - symbol.line - 1) }, (_, offset) => symbol.line + offset);
+}
+
+// Inside `${...}` of a template literal, which is code rather than text.
+export function isInterpolated(line: string, column: number): boolean {
+  const before = line.slice(0, column);
+  return before.lastIndexOf('${') > before.lastIndexOf('}');
+}
+
+function columnOf(line: string | undefined, name: string): number {
+  const column = line?.search(new RegExp(`(?<![\\w$])${escapeRegExp(name)}(?![\\w$])`)) ?? -1;
+  return Math.max(0, column);
+}
+
+function toSnakeCase(name: string): string {
+  return name.replace(/([a-z0-9])([A-Z])/g, '$1_$2').replace(/([A-Z]+)([A-Z][a-z])/g, '$1_$2').toLowerCase();
+}
+
+function escapeRegExp(value: string): string {
+  return value.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
@@ -0,0 +1,65 @@
+const DEFAULT_MAX_HEDGES_PER_HOUR = 20;
+export function resolveHedgingConfig(value) {
+    const hedging = isRecord(value) && isRecord(value.hedging) ? value.hedging : {};
+    const providers = Array.isArray(hedging.providers)
+        ? [...new Set(hedging.providers.filter((provider) => typeof provider === 'string' && provider.length > 0))]
+        : [];
+    return {
+        enabled: hedging.enabled === true && providers.length > 0,
+        providers,
+        ...(typeof hedging.afterMs === 'number' && hedging.afterMs >= 0 ? { afterMs: Math.floor(hedging.afterMs) } : {}),
+        percentile: hedging.percentile === 'p50' || hedging.percentile === 'p99' ? hedging.percentile : 'p95',
+        maxPerHour: typeof hedging.maxPerHour === 'number' && hedging.maxPerHour >= 0 ? Math.floor(hedging.maxPerHour) : DEFAULT_MAX_HEDGES_PER_HOUR,
+    };
+}
+/**
+ * Runs `primary`, and if it hasn't settled after `delayMs` (and `claim` grants
+ * budget), `backup` alongside it. The first successful result wins and the
+ * other call is aborted. A call that throws counts as a failed one. When both
+ * fail, the primary's failure is returned (or thrown) so errors read the same
+ * as without hedging; a `claim` that throws means no hedge.
+ */
+export async function runHedged(options) {
+    const primaryController = new AbortController();
+    const backupController = new AbortController();
+    const primary = options.primary(primaryController.signal);
+    let timer;
+    const delay = new Promise((resolve) => {
+        timer = setTimeout(() => resolve('hedge'), options.delayMs);
+    });
+    const first = await Promise.race([primary.then(() => 'primary', () => 'primary'), delay]);
+    clearTimeout(timer);
+    if (first === 'primary' || !(await options.claim().catch(() => false))) {
+        return { result: await primary, hedged: false, winner: 'primary' };
+    }
+    const backup = Promise.resolve().then(() => options.backup(backupController.signal));
+    const settled = await new Promise((resolve, reject) => {
+        const outcomes = {};
+        const finish = (winner, outcome) => {
+            outcomes[winner] = outcome;
+            if (outcome.ok && options.isSuccess(outcome.result)) {
+                (winner === 'primary' ? backupController : primaryController).abort();
+                resolve({ result: outcome.result, hedged: true, winner });
+            }
+            else if (outcomes.primary !== undefined && outcomes.backup !== undefined) {
+                if (outcomes.primary.ok) {
+                    resolve({ result: outcomes.primary.result, hedged: true, winner: 'primary' });
+                }
+                else {
+                    reject(outcomes.primary.error);
+                }
+            }
+        };
+        primary.then((result) => finish('primary', { ok: true, result }), (error) => finish('primary', { ok: false, error }));
+        backup.then((result) => finish('backup', { ok: true, result }), (error) => finish('backup', { ok: false, error }));
+    });
+    // Let the cancelled call wind down so no process outlives the step.
+    await Promise.allSettled([primary, backup]);
+    return settled;
+}
+export function describeHedgeTrigger(config) {
+    return config.afterMs !== undefined ? `${config.afterMs}ms` : `primary ${config.percentile}`;
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -1,6 +1,14 @@
 import { randomUUID } from 'node:crypto';
 import { mkdir, readFile, readdir, stat, writeFile } from 'node:fs/promises';
-import { extname, join, relative, resolve } from 'node:path';
+import { extname, join, relative, resolve, sep } from 'node:path';
+import { isAxIgnoredDirectory, isAxIgnoredFile, loadAxIgnore } from './axignore.js';
+import { buildEditorLink } from './editor-links.js';
+import { createTreeSitterQueryRunner, findForbiddenPatterns, forbiddenPatternExtensions, loadForbiddenPatterns, } from './forbidden-patterns.js';
+const SARIF_LEVELS = {
+    critical: 'error',
+    warning: 'warning',
+    note: 'note',
+};
 const ALLOWED_EXTENSIONS = new Set(['.ts', '.tsx', '.js', '.jsx', '.mjs', '.cjs']);
 const IGNORED_DIRS = new Set(['.git', 'node_modules', '.tmp', '.automatosx']);
 export async function runReviewAnalysis(traceStore, request) {
@@ -25,16 +33,40 @@ export async function runReviewAnalysis(traceStore, request) {
         },
     });
     try {
-        const files = await collectFiles(request.paths, maxFiles, request.basePath);
+        const patterns = await loadForbiddenPatterns(request.basePath);
+        const extensions = new Set([...ALLOWED_EXTENSIONS, ...forbiddenPatternExtensions(patterns)]);
+        const files = await collectFiles(request.paths, maxFiles, request.basePath, extensions);
         const findings = [];
+        const runQuery = patterns.length > 0 ? request.runQuery ?? createTreeSitterQueryRunner(request.basePath) : undefined;
         for (const file of files) {
             const content = await readFile(file, 'utf8');
-            findings.push(...scanFile(file, content, focus, request.basePath));
+            if (ALLOWED_EXTENSIONS.has(extname(file))) {
+                findings.push(...scanFile(file, content, focus, request.basePath));
+            }
+            if (runQuery !== undefined) {
+                const path = relative(request.basePath, file).split(sep).join('/');
+                const matches = await findForbiddenPatterns({ path, content, patterns, runQuery });
+                findings.push(...matches.filter((match) => focus === 'all' || focus === match.category).map((match) => ({
+                    severity: match.severity,
+                    category: match.category,
+                    ruleId: `forbidden.${match.patternId}`,
+                    message: match.message,
+                    file: relative(request.basePath, file),
+                    line: match.line,
+                    ...(match.fix !== undefined ? { fix: match.fix } : {}),
+                })));
+            }
+        }
+        if (request.editorLinkTemplate !== undefined) {
+            for (const finding of findings) {
+                finding.link = buildEditorLink(request.editorLinkTemplate, request.basePath, finding);
+            }
         }
         const counts = summarizeFindings(findings);
         const artifactDir = join(request.basePath, '.automatosx', 'reviews', traceId);
         const reportPath = join(artifactDir, 'report.md');
         const dataPath = join(artifactDir, 'review.json');
+        const sarifPath = join(artifactDir, 'review.sarif');
         await mkdir(artifactDir, { recursive: true });
         await writeFile(reportPath, buildMarkdownReport(traceId, focus, files, findings, counts), 'utf8');
         await writeFile(dataPath, `${JSON.stringify({
@@ -45,6 +77,7 @@ export async function runReviewAnalysis(traceStore, request) {
             findings,
             summary: counts,
         }, null, 2)}\n`, 'utf8');
+        await writeFile(sarifPath, `${JSON.stringify(buildSarifLog(findings), null, 2)}\n`, 'utf8');
         const completedAt = new Date().toISOString();
         await traceStore.upsertTrace({
             traceId,
@@ -71,6 +104,7 @@ export async function runReviewAnalysis(traceStore, request) {
                 summary: counts,
                 reportPath,
                 dataPath,
+                sarifPath,
             },
             metadata: {
                 filesScanned: files.length,
@@ -87,6 +121,7 @@ export async function runReviewAnalysis(traceStore, request) {
             summary: counts,
             reportPath,
             dataPath,
+            sarifPath,
         };
     }
     catch (error) {
@@ -134,6 +169,7 @@ export async function runReviewAnalysis(traceStore, request) {
             },
             reportPath: join(request.basePath, '.automatosx', 'reviews', traceId, 'report.md'),
             dataPath: join(request.basePath, '.automatosx', 'reviews', traceId, 'review.json'),
+            sarifPath: join(request.basePath, '.automatosx', 'reviews', traceId, 'review.sarif'),
             error: {
                 code: 'REVIEW_FAILED',
                 message,
@@ -146,25 +182,69 @@ export async function listReviewTraces(traceStore, limit) {
     const reviews = traces.filter((trace) => trace.workflowId === 'review');
     return limit === undefined ? reviews : reviews.slice(0, limit);
 }
+/**
+ * Converts review findings to SARIF 2.1.0 so code scanning tools such as
+ * GitHub's can annotate them inline. Paths stay relative to the workspace.
+ */
+export function buildSarifLog(findings) {
+    const rules = new Map();
+    for (const finding of findings) {
+        if (!rules.has(finding.ruleId)) {
+            rules.set(finding.ruleId, finding.message);
+        }
+    }
+    return {
+        version: '2.1.0',
+        $schema: 'https://json.schemastore.org/sarif-2.1.0.json',
+        runs: [
+            {
+                tool: {
+                    driver: {
+                        name: 'AutomatosX',
+                        informationUri: 'https://github.com/defai-digital/AutomatosX',
+                        rules: [...rules].map(([id, text]) => ({ id, shortDescription: { text } })),
+                    },
+                },
+                results: findings.map((finding) => ({
+                    ruleId: finding.ruleId,
+                    level: SARIF_LEVELS[finding.severity],
+                    message: { text: finding.message },
+                    locations: [
+                        {
+                            physicalLocation: {
+                                artifactLocation: { uri: finding.file.split(sep).join('/') },
+                                region: { startLine: finding.line },
+                            },
+                        },
+                    ],
+                })),
+            },
+        ],
+    };
+}
 function safeDurationMs(startedAt, completedAt) {
     const duration = Date.parse(completedAt) - Date.parse(startedAt);
     return Number.isFinite(duration) ? Math.max(0, duration) : 0;
 }
-async function collectFiles(inputPaths, maxFiles, basePath) {
+async function collectFiles(inputPaths, maxFiles, basePath, extensions) {
     const results = [];
+    const axignore = await loadAxIgnore(basePath);
     for (const rawPath of inputPaths) {
         const filePath = resolve(basePath, rawPath);
-        await visit(filePath, results, maxFiles);
+        await visit(filePath, results, maxFiles, { basePath, axignore, extensions });
         if (results.length >= maxFiles) {
             break;
         }
     }
     return results;
 }
-async function visit(filePath, results, maxFiles) {
+async function visit(filePath, results, maxFiles, scope) {
     if (results.length >= maxFiles) {
         return;
     }
+    // Paths outside the workspace are reviewed as given.
+    const relativePath = relative(scope.basePath, filePath).split(sep).join('/');
+    const inWorkspace = relativePath.length > 0 && !relativePath.startsWith('../') && relativePath !== '..';
     let stats;
     try {
         stats = await stat(filePath);
@@ -173,6 +253,9 @@ async function visit(filePath, results, maxFiles) {
         return;
     }
     if (stats.isDirectory()) {
+        if (inWorkspace && isAxIgnoredDirectory(scope.axignore, relativePath)) {
+            return;
+        }
         let entries;
         try {
             entries = await readdir(filePath, { withFileTypes: true, encoding: 'utf8' });
@@ -184,14 +267,17 @@ async function visit(filePath, results, maxFiles) {
             if (IGNORED_DIRS.has(entry.name)) {
                 continue;
             }
-            await visit(join(filePath, entry.name), results, maxFiles);
+            await visit(join(filePath, entry.name), results, maxFiles, scope);
             if (results.length >= maxFiles) {
                 break;
             }
         }
         return;
     }
-    if (stats.isFile() && ALLOWED_EXTENSIONS.has(extname(filePath))) {
+    if (stats.isFile() && scope.extensions.has(extname(filePath))) {
+        if (inWorkspace && await isAxIgnoredFile(scope.basePath, relativePath, scope.axignore)) {
+            return;
+        }
         results.push(filePath);
     }
 }
@@ -298,7 +384,10 @@ function buildMarkdownReport(traceId, focus, files, findings, counts) {
         return `${lines.join('\n')}\n`;
     }
     for (const finding of findings) {
-        lines.push(`- [${finding.severity}] ${finding.file}:${finding.line} ${finding.ruleId} - ${finding.message}`);
+        const location = finding.link !== undefined
+            ? `[${finding.file}:${finding.line}](${finding.link})`
+            : `${finding.file}:${finding.line}`;
+        lines.push(`- [${finding.severity}] ${location} ${finding.ruleId} - ${finding.message}${finding.fix !== undefined ? ` Suggested fix: \`${finding.fix}\`` : ''}`);
     }
     return `${lines.join('\n')}\n`;
 }
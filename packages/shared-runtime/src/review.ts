@@ -1,7 +1,16 @@
 import { randomUUID } from 'node:crypto';
 import { mkdir, readFile, readdir, stat, writeFile } from 'node:fs/promises';
-import { extname, join, relative, resolve } from 'node:path';
+import { extname, join, relative, resolve, sep } from 'node:path';
 import type { TraceRecord, TraceStore, TraceSurface } from '@defai.digital/trace-store';
+import { isAxIgnoredDirectory, isAxIgnoredFile, loadAxIgnore, type AxIgnoreRules } from './axignore.js';
+import { buildEditorLink } from './editor-links.js';
+import {
+  createTreeSitterQueryRunner,
+  findForbiddenPatterns,
+  forbiddenPatternExtensions,
+  loadForbiddenPatterns,
+  type TreeSitterQueryRunner,
+} from './forbidden-patterns.js';
 
 export type ReviewFocus = 'all' | 'security' | 'correctness' | 'maintainability';
 export type ReviewSeverity = 'critical' | 'warning' | 'note';
@@ -13,6 +22,9 @@ export interface ReviewFinding {
   message: string;
   file: string;
   line: number;
+  link?: string;
+  // Suggested replacement, from a forbidden pattern's fix.
+  fix?: string;
 }
 
 export interface RuntimeReviewRequest {
@@ -23,6 +35,9 @@ export interface RuntimeReviewRequest {
   sessionId?: string;
   basePath: string;
   surface?: TraceSurface;
+  editorLinkTemplate?: string;
+  // Runs forbidden-pattern queries; web-tree-sitter unless given.
+  runQuery?: TreeSitterQueryRunner;
 }
 
 export interface RuntimeReviewResponse {
@@ -34,12 +49,32 @@ export interface RuntimeReviewResponse {
   summary: Record<ReviewSeverity, number>;
   reportPath: string;
   dataPath: string;
+  sarifPath: string;
   error?: {
     code?: string;
     message?: string;
   };
 }
 
+export interface SarifLog {
+  version: '2.1.0';
+  $schema: string;
+  runs: Array<{
+    tool: { driver: { name: string; informationUri: string; rules: Array<{ id: string; shortDescription: { text: string } }> } };
+    results: Array<{
+      ruleId: string;
+      level: 'error' | 'warning' | 'note';
+      message: { text: string };
+      locations: Array<{ physicalLocation: { artifactLocation: { uri: string }; region: { startLine: number } } }>;
+    }>;
+  }>;
+}
+
+const SARIF_LEVELS: Record<ReviewSeverity, 'error' | 'warning' | 'note'> = {
+  critical: 'error',
+  warning: 'warning',
+  note: 'note',
+};
 const ALLOWED_EXTENSIONS = new Set(['.ts', '.tsx', '.js', '.jsx', '.mjs', '.cjs']);
 const IGNORED_DIRS = new Set(['.git', 'node_modules', '.tmp', '.automatosx']);
 
@@ -70,18 +105,42 @@ export async function runReviewAnalysis(
   });
 
   try {
-    const files = await collectFiles(request.paths, maxFiles, request.basePath);
+    const patterns = await loadForbiddenPatterns(request.basePath);
+    const extensions = new Set([...ALLOWED_EXTENSIONS, ...forbiddenPatternExtensions(patterns)]);
+    const files = await collectFiles(request.paths, maxFiles, request.basePath, extensions);
     const findings: ReviewFinding[] = [];
+    const runQuery = patterns.length > 0 ? request.runQuery ?? createTreeSitterQueryRunner(request.basePath) : undefined;
 
     for (const file of files) {
       const content = await readFile(file, 'utf8');
-      findings.push(...scanFile(file, content, focus, request.basePath));
+      if (ALLOWED_EXTENSIONS.has(extname(file))) {
+        findings.push(...scanFile(file, content, focus, request.basePath));
+      }
+      if (runQuery !== undefined) {
+        const path = relative(request.basePath, file).split(sep).join('/');
+        const matches = await findForbiddenPatterns({ path, content, patterns, runQuery });
+        findings.push(...matches.filter((match) => focus === 'all' || focus === match.category).map((match): ReviewFinding => ({
+          severity: match.severity,
+          category: match.category,
+          ruleId: `forbidden.${match.patternId}`,
+          message: match.message,
+          file: relative(request.basePath, file),
+          line: match.line,
+          ...(match.fix !== undefined ? { fix: match.fix } : {}),
+        })));
+      }
+    }
+    if (request.editorLinkTemplate !== undefined) {
+      for (const finding of findings) {
+        finding.link = buildEditorLink(request.editorLinkTemplate, request.basePath, finding);
+      }
     }
 
     const counts = summarizeFindings(findings);
     const artifactDir = join(request.basePath, '.automatosx', 'reviews', traceId);
     const reportPath = join(artifactDir, 'report.md');
     const dataPath = join(artifactDir, 'review.json');
+    const sarifPath = join(artifactDir, 'review.sarif');
     await mkdir(artifactDir, { recursive: true });
     await writeFile(reportPath, buildMarkdownReport(traceId, focus, files, findings, counts), 'utf8');
     await writeFile(dataPath, `${JSON.stringify({
@@ -92,6 +151,7 @@ export async function runReviewAnalysis(
       findings,
       summary: counts,
     }, null, 2)}\n`, 'utf8');
+    await writeFile(sarifPath, `${JSON.stringify(buildSarifLog(findings), null, 2)}\n`, 'utf8');
 
     const completedAt = new Date().toISOString();
     await traceStore.upsertTrace({
@@ -119,6 +179,7 @@ export async function runReviewAnalysis(
         summary: counts,
         reportPath,
         dataPath,
+        sarifPath,
       },
       metadata: {
         filesScanned: files.length,
@@ -136,6 +197,7 @@ export async function runReviewAnalysis(
       summary: counts,
       reportPath,
       dataPath,
+      sarifPath,
     };
   } catch (error) {
     const completedAt = new Date().toISOString();
@@ -184,6 +246,7 @@ export async function runReviewAnalysis(
       },
       reportPath: join(request.basePath, '.automatosx', 'reviews', traceId, 'report.md'),
       dataPath: join(request.basePath, '.automatosx', 'reviews', traceId, 'review.json'),
+      sarifPath: join(request.basePath, '.automatosx', 'reviews', traceId, 'review.sarif'),
       error: {
         code: 'REVIEW_FAILED',
         message,
@@ -198,16 +261,58 @@ export async function listReviewTraces(traceStore: TraceStore, limit?: number):
   return limit === undefined ? reviews : reviews.slice(0, limit);
 }
 
+/**
+ * Converts review findings to SARIF 2.1.0 so code scanning tools such as
+ * GitHub's can annotate them inline. Paths stay relative to the workspace.
+ */
+export function buildSarifLog(findings: ReviewFinding[]): SarifLog {
+  const rules = new Map<string, string>();
+  for (const finding of findings) {
+    if (!rules.has(finding.ruleId)) {
+      rules.set(finding.ruleId, finding.message);
+    }
+  }
+  return {
+    version: '2.1.0',
+    $schema: 'https://json.schemastore.org/sarif-2.1.0.json',
+    runs: [
+      {
+        tool: {
+          driver: {
+            name: 'AutomatosX',
+            informationUri: 'https://github.com/defai-digital/AutomatosX',
+            rules: [...rules].map(([id, text]) => ({ id, shortDescription: { text } })),
+          },
+        },
+        results: findings.map((finding) => ({
+          ruleId: finding.ruleId,
+          level: SARIF_LEVELS[finding.severity],
+          message: { text: finding.message },
+          locations: [
+            {
+              physicalLocation: {
+                artifactLocation: { uri: finding.file.split(sep).join('/') },
+                region: { startLine: finding.line },
+              },
+            },
+          ],
+        })),
+      },
+    ],
+  };
+}
+
 function safeDurationMs(startedAt: string, completedAt: string): number {
   const duration = Date.parse(completedAt) - Date.parse(startedAt);
   return Number.isFinite(duration) ? Math.max(0, duration) : 0;
 }
 
-async function collectFiles(inputPaths: string[], maxFiles: number, basePath: string): Promise<string[]> {
+async function collectFiles(inputPaths: string[], maxFiles: number, basePath: string, extensions: Set<string>): Promise<string[]> {
   const results: string[] = [];
+  const axignore = await loadAxIgnore(basePath);
   for (const rawPath of inputPaths) {
     const filePath = resolve(basePath, rawPath);
-    await visit(filePath, results, maxFiles);
+    await visit(filePath, results, maxFiles, { basePath, axignore, extensions });
     if (results.length >= maxFiles) {
       break;
     }
@@ -215,10 +320,18 @@ async function collectFiles(inputPaths: string[], maxFiles: number, basePath: st
   return results;
 }
 
-async function visit(filePath: string, results: string[], maxFiles: number): Promise<void> {
+async function visit(
+  filePath: string,
+  results: string[],
+  maxFiles: number,
+  scope: { basePath: string; axignore: AxIgnoreRules; extensions: Set<string> },
+): Promise<void> {
   if (results.length >= maxFiles) {
     return;
   }
+  // Paths outside the workspace are reviewed as given.
+  const relativePath = relative(scope.basePath, filePath).split(sep).join('/');
+  const inWorkspace = relativePath.length > 0 && !relativePath.startsWith('../') && relativePath !== '..';
 
   let stats: Awaited<ReturnType<typeof stat>>;
   try {
@@ -228,6 +341,9 @@ async function visit(filePath: string, results: string[], maxFiles: number): Pro
   }
 
   if (stats.isDirectory()) {
+    if (inWorkspace && isAxIgnoredDirectory(scope.axignore, relativePath)) {
+      return;
+    }
     let entries;
     try {
       entries = await readdir(filePath, { withFileTypes: true, encoding: 'utf8' });
@@ -238,7 +354,7 @@ async function visit(filePath: string, results: string[], maxFiles: number): Pro
       if (IGNORED_DIRS.has(entry.name)) {
         continue;
       }
-      await visit(join(filePath, entry.name), results, maxFiles);
+      await visit(join(filePath, entry.name), results, maxFiles, scope);
       if (results.length >= maxFiles) {
         break;
       }
@@ -246,7 +362,10 @@ async function visit(filePath: string, results: string[], maxFiles: number): Pro
     return;
   }
 
-  if (stats.isFile() && ALLOWED_EXTENSIONS.has(extname(filePath))) {
+  if (stats.isFile() && scope.extensions.has(extname(filePath))) {
+    if (inWorkspace && await isAxIgnoredFile(scope.basePath, relativePath, scope.axignore)) {
+      return;
+    }
     results.push(filePath);
   }
 }
@@ -377,7 +496,10 @@ function buildMarkdownReport(
   }
 
   for (const finding of findings) {
-    lines.push(`- [${finding.severity}] ${finding.file}:${finding.line} ${finding.ruleId} - ${finding.message}`);
+    const location = finding.link !== undefined
+      ? `[${finding.file}:${finding.line}](${finding.link})`
+      : `${finding.file}:${finding.line}`;
+    lines.push(`- [${finding.severity}] ${location} ${finding.ruleId} - ${finding.message}${finding.fix !== undefined ? ` Suggested fix: \`${finding.fix}\`` : ''}`);
   }
 
   return `${lines.join('\n')}\n`;
@@ -0,0 +1,223 @@
+import { execFile } from 'node:child_process';
+import { randomUUID } from 'node:crypto';
+import { mkdir, writeFile } from 'node:fs/promises';
+import { dirname } from 'node:path';
+import { promisify } from 'node:util';
+const execFileAsync = promisify(execFile);
+const MIGRATION_PATH_PATTERN = /(^|\/)migrations?\//i;
+const DOWN_MIGRATION_PATTERN = /(\.down\.|_down\.|\/down\.)/i;
+const FLAG_FILE_PATTERN = /(^|\/)[^/]*flags?[^/]*\.json$/i;
+export async function generateRollbackPlaybook(traceStore, request) {
+    const startedAt = new Date().toISOString();
+    const traceId = request.traceId ?? randomUUID();
+    const base = request.base ?? await resolveDefaultBase(request.basePath);
+    const head = request.head ?? 'HEAD';
+    const [logResult, filesResult, headSha] = await Promise.all([
+        execGit(request.basePath, ['log', '--format=%H%x09%s', `${base}..${head}`]),
+        execGit(request.basePath, ['diff', '--name-status', `${base}...${head}`]),
+        execGit(request.basePath, ['rev-parse', head]),
+    ]);
+    const commits = logResult.stdout.split('\n').filter((line) => line.length > 0).map((line) => {
+        const [sha = '', ...subject] = line.split('\t');
+        return { sha, subject: subject.join('\t') };
+    });
+    const changedFiles = parseNameStatus(filesResult.stdout);
+    const steps = [];
+    const gaps = [];
+    const migrations = changedFiles.filter((file) => MIGRATION_PATH_PATTERN.test(file.path) && !DOWN_MIGRATION_PATTERN.test(file.path));
+    const downMigrations = new Set(changedFiles.filter((file) => DOWN_MIGRATION_PATTERN.test(file.path)).map((file) => file.path));
+    for (const migration of [...migrations].reverse()) {
+        if (migration.status !== 'A') {
+            gaps.push(`Migration ${migration.path} was modified in place (${migration.status}); verify data written by the new version before reverting.`);
+            continue;
+        }
+        const downPath = findDownMigration(migration.path, downMigrations);
+        if (downPath === undefined) {
+            gaps.push(`Migration ${migration.path} has no matching down migration in this release.`);
+            continue;
+        }
+        steps.push({
+            kind: 'migration',
+            description: `Reverse migration ${migration.path} before reverting code.`,
+            command: `run down migration ${downPath}`,
+            files: [migration.path, downPath],
+        });
+    }
+    for (const flagFile of changedFiles.filter((file) => FLAG_FILE_PATTERN.test(file.path))) {
+        const flips = await diffFlagFile(request.basePath, base, head, flagFile);
+        if (flips === undefined) {
+            gaps.push(`Flag file ${flagFile.path} changed but could not be parsed as JSON; review flag values manually.`);
+            continue;
+        }
+        for (const flip of flips) {
+            steps.push({
+                kind: 'flag',
+                description: `Set ${flip.key} in ${flagFile.path} back to ${JSON.stringify(flip.before)} (release set ${JSON.stringify(flip.after)}).`,
+                files: [flagFile.path],
+            });
+        }
+    }
+    if (commits.length > 0) {
+        const shas = commits.map((commit) => commit.sha.slice(0, 12));
+        steps.push({
+            kind: 'revert',
+            description: `Revert ${commits.length} release commit${commits.length === 1 ? '' : 's'} newest first.`,
+            command: `git revert --no-edit ${shas.join(' ')}`,
+            files: changedFiles.map((file) => file.path),
+        });
+    }
+    else {
+        gaps.push(`No commits found between ${base} and ${head}; nothing to revert.`);
+    }
+    steps.push({
+        kind: 'verify',
+        description: `Confirm the deployed revision no longer matches ${headSha.stdout.trim().slice(0, 12)} and health checks pass.`,
+        files: [],
+    });
+    const response = {
+        traceId,
+        base,
+        head,
+        releaseVersion: request.releaseVersion,
+        releaseTraceId: request.releaseTraceId,
+        commits,
+        changedFiles,
+        steps,
+        gaps,
+        validated: gaps.length === 0,
+        markdown: '',
+    };
+    response.markdown = renderRollbackPlaybook(response);
+    if (request.outputPath !== undefined) {
+        await mkdir(dirname(request.outputPath), { recursive: true });
+        await writeFile(request.outputPath, response.markdown, 'utf8');
+        response.artifactPath = request.outputPath;
+    }
+    if (request.pullRequest !== undefined) {
+        response.pullRequestComment = await commentOnPullRequest(request.basePath, request.pullRequest, response.markdown);
+    }
+    await traceStore.upsertTrace({
+        traceId,
+        workflowId: 'rollback-playbook',
+        surface: request.surface ?? 'cli',
+        status: 'completed',
+        startedAt,
+        completedAt: new Date().toISOString(),
+        input: {
+            base,
+            head,
+            releaseVersion: request.releaseVersion,
+        },
+        stepResults: [
+            {
+                stepId: 'generate-playbook',
+                success: true,
+                durationMs: Math.max(0, Date.now() - Date.parse(startedAt)),
+                retryCount: 0,
+            },
+        ],
+        output: {
+            steps,
+            gaps,
+            validated: response.validated,
+            artifactPath: response.artifactPath,
+            pullRequest: request.pullRequest,
+        },
+        metadata: {
+            sessionId: request.sessionId,
+            parentTraceId: request.releaseTraceId,
+            releaseVersion: request.releaseVersion,
+        },
+    });
+    return response;
+}
+export function renderRollbackPlaybook(playbook) {
+    const lines = [
+        `# Rollback Playbook${playbook.releaseVersion !== undefined ? ` for ${playbook.releaseVersion}` : ''}`,
+        '',
+        `Range: \`${playbook.base}..${playbook.head}\` (${playbook.commits.length} commits, ${playbook.changedFiles.length} files)`,
+        `Validated: ${playbook.validated ? 'yes' : 'no'}`,
+        '',
+        '## Steps',
+        '',
+        ...playbook.steps.flatMap((step, index) => [
+            `${index + 1}. [${step.kind}] ${step.description}`,
+            ...(step.command !== undefined ? [`   \`${step.command}\``] : []),
+        ]),
+    ];
+    if (playbook.gaps.length > 0) {
+        lines.push('', '## Gaps', '', ...playbook.gaps.map((gap) => `- ${gap}`));
+    }
+    return `${lines.join('\n')}\n`;
+}
+function parseNameStatus(output) {
+    return output.split('\n').filter((line) => line.length > 0).map((line) => {
+        const parts = line.split('\t');
+        const status = (parts[0] ?? '').charAt(0);
+        return { status, path: parts[parts.length - 1] ?? '' };
+    });
+}
+function findDownMigration(path, downMigrations) {
+    const stem = path.replace(/([._/])up(\.[^./]+)$/i, '').replace(/\.[^./]+$/, '');
+    return [...downMigrations].find((candidate) => candidate.startsWith(stem));
+}
+async function diffFlagFile(basePath, base, head, file) {
+    try {
+        const before = file.status === 'A' ? {} : JSON.parse((await execGit(basePath, ['show', `${base}:${file.path}`])).stdout);
+        const after = file.status === 'D' ? {} : JSON.parse((await execGit(basePath, ['show', `${head}:${file.path}`])).stdout);
+        const beforeFlags = flattenFlags(before);
+        const afterFlags = flattenFlags(after);
+        const keys = [...new Set([...beforeFlags.keys(), ...afterFlags.keys()])].sort();
+        return keys
+            .filter((key) => JSON.stringify(beforeFlags.get(key)) !== JSON.stringify(afterFlags.get(key)))
+            .map((key) => ({ key, before: beforeFlags.get(key) ?? null, after: afterFlags.get(key) ?? null }));
+    }
+    catch {
+        return undefined;
+    }
+}
+function flattenFlags(value, prefix = '', flags = new Map()) {
+    if (value !== null && typeof value === 'object' && !Array.isArray(value)) {
+        for (const [key, child] of Object.entries(value)) {
+            flattenFlags(child, prefix.length > 0 ? `${prefix}.${key}` : key, flags);
+        }
+    }
+    else if (prefix.length > 0) {
+        flags.set(prefix, value);
+    }
+    return flags;
+}
+async function commentOnPullRequest(basePath, pullRequest, body) {
+    try {
+        const { stdout, stderr } = await execFileAsync('gh', ['pr', 'comment', pullRequest, '--body', body], {
+            cwd: basePath,
+            maxBuffer: 1024 * 1024 * 4,
+        });
+        return `${stdout}${stderr}`.trim();
+    }
+    catch (error) {
+        const message = error instanceof Error ? error.message : String(error);
+        throw new Error(`pr comment failed: ${message}`);
+    }
+}
+async function resolveDefaultBase(basePath) {
+    try {
+        const { stdout } = await execGit(basePath, ['describe', '--tags', '--abbrev=0', 'HEAD^']);
+        return stdout.trim() || 'main';
+    }
+    catch {
+        return 'main';
+    }
+}
+async function execGit(basePath, args) {
+    try {
+        return await execFileAsync('git', args, {
+            cwd: basePath,
+            maxBuffer: 1024 * 1024 * 4,
+        });
+    }
+    catch (error) {
+        const message = error instanceof Error ? error.message : String(error);
+        throw new Error(`git ${args[0] ?? 'command'} failed: ${message}`);
+    }
+}
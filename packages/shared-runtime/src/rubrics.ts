@@ -0,0 +1,182 @@
+import { readdir, readFile } from 'node:fs/promises';
+import { extname, join } from 'node:path';
+import { parse as parseYaml } from 'yaml';
+
+export const RUBRICS_DIR = join('.automatosx', 'rubrics');
+
+export interface RubricCriterion {
+  id: string;
+  description: string;
+  weight: number;
+  mustMatch?: string[];
+  mustNotMatch?: string[];
+  mustTouch?: string[];
+}
+
+export interface Rubric {
+  rubricId: string;
+  name: string;
+  description?: string;
+  appliesTo: string[];
+  passThreshold: number;
+  criteria: RubricCriterion[];
+  sourcePath: string;
+}
+
+export type RubricCriterionStatus = 'passed' | 'failed' | 'skipped';
+
+export interface RubricCriterionResult {
+  id: string;
+  description: string;
+  weight: number;
+  status: RubricCriterionStatus;
+  detail: string;
+}
+
+export interface RubricEvaluation {
+  rubricId: string;
+  score: number;
+  passed: boolean;
+  passThreshold: number;
+  criteria: RubricCriterionResult[];
+}
+
+export interface RubricSubject {
+  output: string;
+  changedFiles?: string[];
+}
+
+const DEFAULT_PASS_THRESHOLD = 0.7;
+
+export async function loadRubrics(basePath: string): Promise<Rubric[]> {
+  const rubricsDir = join(basePath, RUBRICS_DIR);
+  let fileNames: string[];
+  try {
+    fileNames = await readdir(rubricsDir);
+  } catch {
+    return [];
+  }
+
+  const rubrics: Rubric[] = [];
+  for (const fileName of fileNames.filter((name) => ['.yaml', '.yml'].includes(extname(name))).sort()) {
+    const sourcePath = join(rubricsDir, fileName);
+    rubrics.push(parseRubric(parseYaml(await readFile(sourcePath, 'utf8')), sourcePath));
+  }
+  return rubrics;
+}
+
+export function parseRubric(value: unknown, sourcePath: string): Rubric {
+  if (!isRecord(value)) {
+    throw new Error(`Rubric ${sourcePath} must be a YAML mapping`);
+  }
+  const rubricId = typeof value.rubricId === 'string' ? value.rubricId : undefined;
+  if (rubricId === undefined || rubricId.length === 0) {
+    throw new Error(`Rubric ${sourcePath} is missing rubricId`);
+  }
+  if (!Array.isArray(value.criteria) || value.criteria.length === 0) {
+    throw new Error(`Rubric ${rubricId} must define at least one criterion`);
+  }
+
+  const criteria = value.criteria.map((entry, index): RubricCriterion => {
+    if (!isRecord(entry)) {
+      throw new Error(`Rubric ${rubricId} criterion ${index + 1} must be a mapping`);
+    }
+    const criterion: RubricCriterion = {
+      id: typeof entry.id === 'string' ? entry.id : `criterion-${index + 1}`,
+      description: typeof entry.description === 'string' ? entry.description : '',
+      weight: typeof entry.weight === 'number' && entry.weight > 0 ? entry.weight : 1,
+      mustMatch: asPatternList(entry.mustMatch),
+      mustNotMatch: asPatternList(entry.mustNotMatch),
+      mustTouch: asPatternList(entry.mustTouch),
+    };
+    if (criterion.mustMatch === undefined && criterion.mustNotMatch === undefined && criterion.mustTouch === undefined) {
+      throw new Error(`Rubric ${rubricId} criterion ${criterion.id} needs mustMatch, mustNotMatch, or mustTouch`);
+    }
+    return criterion;
+  });
+
+  return {
+    rubricId,
+    name: typeof value.name === 'string' ? value.name : rubricId,
+    description: typeof value.description === 'string' ? value.description : undefined,
+    appliesTo: asPatternList(value.appliesTo) ?? [],
+    passThreshold: typeof value.passThreshold === 'number' ? value.passThreshold : DEFAULT_PASS_THRESHOLD,
+    criteria,
+    sourcePath,
+  };
+}
+
+export function evaluateRubric(rubric: Rubric, subject: RubricSubject): RubricEvaluation {
+  const criteria = rubric.criteria.map((criterion) => evaluateCriterion(criterion, subject));
+  const scored = criteria.filter((result) => result.status !== 'skipped');
+  const totalWeight = scored.reduce((sum, result) => sum + result.weight, 0);
+  const passedWeight = scored.filter((result) => result.status === 'passed').reduce((sum, result) => sum + result.weight, 0);
+  const score = totalWeight === 0 ? 1 : Number((passedWeight / totalWeight).toFixed(4));
+
+  return {
+    rubricId: rubric.rubricId,
+    score,
+    passed: score >= rubric.passThreshold,
+    passThreshold: rubric.passThreshold,
+    criteria,
+  };
+}
+
+function evaluateCriterion(criterion: RubricCriterion, subject: RubricSubject): RubricCriterionResult {
+  const base = { id: criterion.id, description: criterion.description, weight: criterion.weight };
+
+  const missing = (criterion.mustMatch ?? []).filter((pattern) => !new RegExp(pattern, 'm').test(subject.output));
+  if (missing.length > 0) {
+    return { ...base, status: 'failed', detail: `Output does not match: ${missing.join(', ')}` };
+  }
+
+  const forbidden = (criterion.mustNotMatch ?? []).filter((pattern) => new RegExp(pattern, 'm').test(subject.output));
+  if (forbidden.length > 0) {
+    return { ...base, status: 'failed', detail: `Output matches forbidden pattern: ${forbidden.join(', ')}` };
+  }
+
+  if (criterion.mustTouch !== undefined) {
+    if (subject.changedFiles === undefined) {
+      return { ...base, status: 'skipped', detail: 'No changed files supplied for mustTouch.' };
+    }
+    const untouched = criterion.mustTouch.filter((glob) => !subject.changedFiles!.some((path) => globToRegExp(glob).test(path)));
+    if (untouched.length > 0) {
+      return { ...base, status: 'failed', detail: `No changed file matches: ${untouched.join(', ')}` };
+    }
+  }
+
+  return { ...base, status: 'passed', detail: 'Criterion satisfied.' };
+}
+
+function globToRegExp(glob: string): RegExp {
+  let pattern = '';
+  for (let index = 0; index < glob.length; index += 1) {
+    const char = glob[index]!;
+    if (char === '*' && glob[index + 1] === '*') {
+      pattern += '.*';
+      index += glob[index + 2] === '/' ? 2 : 1;
+    } else if (char === '*') {
+      pattern += '[^/]*';
+    } else if (char === '?') {
+      pattern += '[^/]';
+    } else {
+      pattern += char.replace(/[.+^${}()|[\]\\]/g, '\\$&');
+    }
+  }
+  return new RegExp(`^${pattern}$`);
+}
+
+function asPatternList(value: unknown): string[] | undefined {
+  if (typeof value === 'string') {
+    return [value];
+  }
+  if (Array.isArray(value)) {
+    const entries = value.filter((entry): entry is string => typeof entry === 'string' && entry.length > 0);
+    return entries.length > 0 ? entries : undefined;
+  }
+  return undefined;
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,234 @@
+import { appendFile, mkdir, readFile } from 'node:fs/promises';
+import { dirname, join } from 'node:path';
+
+export const SECRET_REDACTION_LOG_FILE = join('.automatosx', 'runtime', 'redactions.jsonl');
+
+const DEFAULT_ENTROPY_MIN_LENGTH = 24;
+// Share of the most entropy a token of its length could have: generated keys
+// score near 1, camelCase identifiers with a digit or two around 0.85.
+const DEFAULT_ENTROPY_THRESHOLD = 0.92;
+const DEFAULT_REDACTION_LIMIT = 50;
+// Object fields whose whole string value is a secret, whatever it looks like.
+const SENSITIVE_FIELD = /^(?:api[-_]?key|access[-_]?token|auth[-_]?token|refresh[-_]?token|client[-_]?secret|secret|token|password|passwd|pwd|private[-_]?key)$/i;
+
+export interface SecretRedactionRule {
+  name: string;
+  // A `secret` named group limits the redaction to that part of the match, keeping labels like `password=`.
+  pattern: RegExp;
+}
+
+export interface SecretRedactionConfig {
+  enabled: boolean;
+  rules: SecretRedactionRule[];
+  // Unset when entropy detection is off.
+  entropy?: { minLength: number; threshold: number };
+}
+
+export interface SecretRedactionFinding {
+  rule: string;
+  count: number;
+}
+
+export type SecretRedactionTarget = 'memory' | 'semantic' | 'memory-audit' | 'trace' | 'session-recording';
+
+export interface SecretRedactionRecord {
+  at: string;
+  target: SecretRedactionTarget;
+  namespace?: string;
+  key?: string;
+  // What was found, never the secret itself.
+  findings: SecretRedactionFinding[];
+}
+
+export interface SecretRedactionFilter {
+  rule?: string;
+  namespace?: string;
+  since?: string;
+  until?: string;
+  limit?: number;
+}
+
+export interface RuntimeSecretRedactionResponse {
+  enabled: boolean;
+  // Newest first, at most `limit`.
+  records: SecretRedactionRecord[];
+  matched: number;
+  // Secrets redacted per rule across every matching record.
+  rules: SecretRedactionFinding[];
+}
+
+export const BUILTIN_SECRET_REDACTION_RULES: SecretRedactionRule[] = [
+  { name: 'private-key', pattern: /-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----/g },
+  { name: 'aws-access-key', pattern: /\b(?:AKIA|ASIA)[0-9A-Z]{16}\b/g },
+  { name: 'github-token', pattern: /\bgh[pousr]_[A-Za-z0-9]{20,}/g },
+  { name: 'slack-token', pattern: /\bxox[abprs]-[A-Za-z0-9-]{10,}/g },
+  { name: 'api-key', pattern: /\bsk-[A-Za-z0-9_-]{16,}/g },
+  { name: 'jwt', pattern: /\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}/g },
+  { name: 'bearer-token', pattern: /\bBearer\s+(?<secret>[A-Za-z0-9._~+/-]{8,}=*)/gi },
+  { name: 'url-password', pattern: /\b[a-z][a-z0-9+.-]*:\/\/[^\s:/@]+:(?<secret>[^\s@/]+)@/gi },
+  {
+    name: 'assignment',
+    // The value needs a digit or symbol, or real length, so prose like "token: rotate weekly" is left alone.
+    pattern: /\b(?:api[-_]?key|access[-_]?token|auth[-_]?token|client[-_]?secret|secret|token|password|passwd|pwd)["']?\s*[:=]\s*["']?(?<secret>(?=[^\s"',;}]*[\d!@#$%^&*])[^\s"',;}]{6,}|[^\s"',;}]{16,})/gi,
+  },
+];
+
+/**
+ * `memory.redaction` in config. On by default with the built-in rules and
+ * entropy detection; `false` turns it off, and `{ "patterns": { name: regex },
+ * "entropy": false | { "minLength", "threshold" } }` adds rules or tunes
+ * detection. Invalid patterns are errors rather than silently unredacted.
+ */
+export function resolveSecretRedactionConfig(memoryConfig: unknown): SecretRedactionConfig {
+  const value = isRecord(memoryConfig) ? memoryConfig.redaction : undefined;
+  const defaults = { minLength: DEFAULT_ENTROPY_MIN_LENGTH, threshold: DEFAULT_ENTROPY_THRESHOLD };
+  if (value === false || (isRecord(value) && value.enabled === false)) {
+    return { enabled: false, rules: [] };
+  }
+  if (!isRecord(value)) {
+    return { enabled: true, rules: BUILTIN_SECRET_REDACTION_RULES, entropy: defaults };
+  }
+  const rules = [...BUILTIN_SECRET_REDACTION_RULES];
+  const patterns = value.patterns ?? {};
+  if (!isRecord(patterns)) {
+    throw new Error('memory.redaction.patterns must map rule names to regular expressions');
+  }
+  for (const [name, pattern] of Object.entries(patterns)) {
+    if (typeof pattern !== 'string' || pattern.length === 0) {
+      throw new Error(`memory.redaction.patterns.${name} must be a regular expression`);
+    }
+    try {
+      rules.push({ name, pattern: new RegExp(pattern, 'g') });
+    } catch {
+      throw new Error(`memory.redaction.patterns.${name} is not a valid regular expression: ${pattern}`);
+    }
+  }
+  const entropy = value.entropy;
+  return {
+    enabled: true,
+    rules,
+    ...(entropy === false ? {} : {
+      entropy: {
+        minLength: isRecord(entropy) && typeof entropy.minLength === 'number' && entropy.minLength > 0 ? entropy.minLength : defaults.minLength,
+        threshold: isRecord(entropy) && typeof entropy.threshold === 'number' && entropy.threshold > 0 && entropy.threshold <= 1 ? entropy.threshold : defaults.threshold,
+      },
+    }),
+  };
+}
+
+/**
+ * Replaces secrets in every string `value` holds with `[REDACTED:<rule>]`,
+ * leaving object keys, numbers, and the rest of each string as they were.
+ * String fields named like credentials (`password`, `apiKey`, ...) are
+ * redacted whole. Rules run in order, then entropy detection over what is
+ * left, so a token a rule names is reported under that rule. Redacting a
+ * redacted value finds nothing more.
+ */
+export function redactSecrets<T>(value: T, config: SecretRedactionConfig): { value: T; findings: SecretRedactionFinding[] } {
+  const counts = new Map<string, number>();
+  if (!config.enabled) {
+    return { value, findings: [] };
+  }
+  const found = (rule: string): string => {
+    counts.set(rule, (counts.get(rule) ?? 0) + 1);
+    return `[REDACTED:${rule}]`;
+  };
+  const redactText = (text: string): string => {
+    let redacted = text;
+    for (const rule of config.rules) {
+      redacted = redacted.replace(rule.pattern, (...args: unknown[]) => {
+        const match = args[0] as string;
+        const groups = args.at(-1);
+        const secret = isRecord(groups) && typeof groups.secret === 'string' ? groups.secret : undefined;
+        if (secret !== undefined && secret.startsWith('[REDACTED')) {
+          return match;
+        }
+        return secret !== undefined ? match.replace(secret, found(rule.name)) : found(rule.name);
+      });
+    }
+    const entropy = config.entropy;
+    if (entropy !== undefined) {
+      redacted = redacted.replace(/[A-Za-z0-9_=-]+/g, (token) => (isHighEntropyToken(token, entropy.minLength, entropy.threshold) ? found('high-entropy') : token));
+    }
+    return redacted;
+  };
+  const walk = (entry: unknown): unknown => {
+    if (typeof entry === 'string') {
+      return redactText(entry);
+    }
+    if (Array.isArray(entry)) {
+      return entry.map(walk);
+    }
+    if (isRecord(entry)) {
+      return Object.fromEntries(Object.entries(entry).map(([key, item]) => [
+        key,
+        SENSITIVE_FIELD.test(key) && typeof item === 'string' && item.length > 0 && !item.startsWith('[REDACTED') ? found('sensitive-field') : walk(item),
+      ]));
+    }
+    return entry;
+  };
+  const redacted = walk(value) as T;
+  return { value: redacted, findings: [...counts.entries()].map(([rule, count]) => ({ rule, count })) };
+}
+
+export async function appendSecretRedaction(basePath: string, record: Omit<SecretRedactionRecord, 'at'>, now = new Date()): Promise<void> {
+  const path = join(basePath, SECRET_REDACTION_LOG_FILE);
+  await mkdir(dirname(path), { recursive: true });
+  await appendFile(path, `${JSON.stringify({ at: now.toISOString(), ...record })}\n`, 'utf8');
+}
+
+export async function querySecretRedactions(
+  basePath: string,
+  enabled: boolean,
+  filter: SecretRedactionFilter = {},
+): Promise<RuntimeSecretRedactionResponse> {
+  let raw = '';
+  try {
+    raw = await readFile(join(basePath, SECRET_REDACTION_LOG_FILE), 'utf8');
+  } catch {
+    // Nothing redacted yet.
+  }
+  const records = raw.split('\n').flatMap((line) => {
+    try {
+      const parsed = JSON.parse(line) as unknown;
+      return isRecord(parsed) && typeof parsed.at === 'string' && Array.isArray(parsed.findings) ? [parsed as unknown as SecretRedactionRecord] : [];
+    } catch {
+      return [];
+    }
+  }).filter((record) => (filter.rule === undefined || record.findings.some((finding) => finding.rule === filter.rule))
+    && (filter.namespace === undefined || record.namespace === filter.namespace)
+    && (filter.since === undefined || record.at >= filter.since)
+    && (filter.until === undefined || record.at <= filter.until)).reverse();
+  const rules = new Map<string, number>();
+  for (const finding of records.flatMap((record) => record.findings)) {
+    rules.set(finding.rule, (rules.get(finding.rule) ?? 0) + finding.count);
+  }
+  return {
+    enabled,
+    records: records.slice(0, Math.max(0, filter.limit ?? DEFAULT_REDACTION_LIMIT)),
+    matched: records.length,
+    rules: [...rules.entries()].map(([rule, count]) => ({ rule, count })).sort((left, right) => right.count - left.count || left.rule.localeCompare(right.rule)),
+  };
+}
+
+// Long, mixed-case, digit-bearing, and close to random: the shape of generated keys, not of words, paths, or hex digests.
+function isHighEntropyToken(token: string, minLength: number, threshold: number): boolean {
+  if (token.length < minLength || !/[0-9]/.test(token) || !/[a-z]/.test(token) || !/[A-Z]/.test(token)) {
+    return false;
+  }
+  const frequencies = new Map<string, number>();
+  for (const character of token) {
+    frequencies.set(character, (frequencies.get(character) ?? 0) + 1);
+  }
+  let entropy = 0;
+  for (const count of frequencies.values()) {
+    const probability = count / token.length;
+    entropy -= probability * Math.log2(probability);
+  }
+  // A token can't have more distinct characters than its length, nor than the 64 the pattern allows.
+  return entropy / Math.log2(Math.min(token.length, 64)) >= threshold;
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,254 @@
+import { createHash } from 'node:crypto';
+import { appendFile, cp, mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { dirname, join, relative, sep } from 'node:path';
+import { createWorkspaceSandbox } from './snapshot.js';
+export const SESSION_RECORDINGS_DIR = join('.automatosx', 'recordings');
+export const SESSION_REPLAY_SANDBOX_PREFIX = 'ax-replay-';
+export const SESSION_RECORDING_OBJECTS_DIR = 'objects';
+// State a replay doesn't read: earlier recordings, snapshots, and experiments.
+const REPLAY_SKIPPED_STATE = new Set(['recordings', 'snapshots', 'experiments']);
+const MAX_DETAIL_CHARS = 120;
+// Off unless `sessions.record: true` in config: recordings hold prompts and responses, secrets redacted, and file contents as they were.
+export function resolveSessionRecordingEnabled(sessionsConfig) {
+    return isRecord(sessionsConfig) && sessionsConfig.record === true;
+}
+export async function appendSessionEvent(basePath, sessionId, event, now = new Date()) {
+    const path = recordingPath(basePath, sessionId);
+    await mkdir(dirname(path), { recursive: true });
+    await appendFile(path, `${JSON.stringify({ at: now.toISOString(), ...event })}\n`, 'utf8');
+}
+// Oldest first; a session never recorded reads as no events.
+export async function readSessionRecording(basePath, sessionId) {
+    let raw = '';
+    try {
+        raw = await readFile(recordingPath(basePath, sessionId), 'utf8');
+    }
+    catch {
+        return [];
+    }
+    return raw.split('\n').flatMap((line) => {
+        try {
+            const parsed = JSON.parse(line);
+            return isRecord(parsed) && typeof parsed.kind === 'string' && typeof parsed.at === 'string' ? [parsed] : [];
+        }
+        catch {
+            return [];
+        }
+    }).map((event, index) => ({ ...event, seq: index + 1 }));
+}
+// Undefined when the file doesn't exist.
+export async function readFileForRecording(basePath, path) {
+    return readFile(join(basePath, path)).catch(() => undefined);
+}
+// Content is kept by hash so a replay can put files back as they were before the session changed them.
+export async function appendFileChangeEvent(basePath, sessionId, change) {
+    await appendSessionEvent(basePath, sessionId, {
+        kind: 'file',
+        ...(change.traceId !== undefined ? { traceId: change.traceId } : {}),
+        path: change.path,
+        action: change.after === undefined ? 'delete' : 'write',
+        ...(change.before !== undefined ? { before: await storeRecordingObject(basePath, change.before) } : {}),
+        ...(change.after !== undefined ? { after: await storeRecordingObject(basePath, change.after) } : {}),
+    });
+}
+async function storeRecordingObject(basePath, content) {
+    const hash = createHash('sha256').update(content).digest('hex');
+    const path = join(basePath, SESSION_RECORDINGS_DIR, SESSION_RECORDING_OBJECTS_DIR, hash.slice(0, 2), hash.slice(2));
+    await mkdir(dirname(path), { recursive: true });
+    await writeFile(path, content, { flag: 'wx' }).catch((error) => {
+        if (error.code !== 'EEXIST') {
+            throw error;
+        }
+    });
+    return hash;
+}
+async function readRecordingObject(basePath, hash) {
+    return readFile(join(basePath, SESSION_RECORDINGS_DIR, SESSION_RECORDING_OBJECTS_DIR, hash.slice(0, 2), hash.slice(2)));
+}
+// Passes every call through to `bridge`, then hands the request and outcome to `record`.
+export function recordProviderCalls(bridge, record) {
+    return {
+        ...bridge,
+        async executePrompt(request) {
+            const outcome = await bridge.executePrompt(request);
+            const { signal: _signal, ...recorded } = request;
+            await record(recorded, outcome);
+            return outcome;
+        },
+    };
+}
+/**
+ * A provider bridge that answers from the recording instead of running
+ * providers: the nth call gets the nth recorded outcome. A request that
+ * differs from the recorded one is still answered as recorded, so the replay
+ * follows the original run, and the difference is noted as a divergence.
+ * Calls past the end of the recording fail with REPLAY_NOT_RECORDED.
+ * Recordings are stored redacted, so requests are compared after `redact`.
+ */
+export function createReplayProviderBridge(events, redact = (value) => value) {
+    const recorded = events.filter((event) => event.kind === 'provider');
+    const divergences = [];
+    let next = 0;
+    return {
+        getExecutionMode: () => 'simulate',
+        async executePrompt(request) {
+            const event = recorded[next];
+            if (event === undefined) {
+                divergences.push({ kind: 'provider', detail: `extra call to ${request.provider}: ${firstLine(request.prompt)}` });
+                return {
+                    type: 'failure',
+                    response: {
+                        success: false,
+                        provider: request.provider,
+                        model: request.model,
+                        latencyMs: 0,
+                        errorCode: 'REPLAY_NOT_RECORDED',
+                        error: `The recording has no response for this ${request.provider} call.`,
+                        mode: 'subprocess',
+                    },
+                };
+            }
+            next += 1;
+            const { signal: _signal, ...compared } = request;
+            const difference = describeRequestDifference(event.request, redact(compared));
+            if (difference !== undefined) {
+                divergences.push({ seq: event.seq, kind: 'provider', detail: difference });
+            }
+            return event.outcome;
+        },
+        served: () => next,
+        divergences: () => [
+            ...divergences,
+            ...recorded.slice(next).map((event) => ({ seq: event.seq, kind: 'provider', detail: `recorded call to ${event.request.provider} was not made` })),
+        ],
+    };
+}
+/**
+ * Re-runs a session's operations in a copy of the workspace, with provider
+ * calls answered from the recording, and compares what happens with what was
+ * recorded: provider requests, workflow tool steps, file writes, and whether
+ * each run succeeded. Files the session changed are first put back as they
+ * were before its first change; other files are as they are now. `to` stops
+ * after the operation at that seq.
+ */
+export async function replayRecordedSession(request) {
+    const events = request.events.filter((event) => request.to === undefined || event.seq <= request.to);
+    const sandbox = await createWorkspaceSandbox(request.basePath, SESSION_REPLAY_SANDBOX_PREFIX);
+    try {
+        const stateDir = join(request.basePath, '.automatosx');
+        await cp(stateDir, join(sandbox, '.automatosx'), {
+            recursive: true,
+            filter: (source) => !REPLAY_SKIPPED_STATE.has(relative(stateDir, source).split(sep)[0] ?? ''),
+        }).catch(() => undefined);
+        await restoreFilesBeforeSession(request.basePath, sandbox, request.events);
+        const bridge = createReplayProviderBridge(events, request.redact);
+        const run = request.createRunner(sandbox, bridge);
+        const divergences = [];
+        const operations = [];
+        for (const operation of events.filter((event) => event.kind === 'operation')) {
+            const outcome = await run(operation).catch((error) => ({ traceId: undefined, success: false, error: error instanceof Error ? error.message : String(error) }));
+            operations.push({ seq: operation.seq, operation: operation.operation, ...outcome });
+            // Patch runs get their trace id as they start, so theirs is only on the result.
+            const recorded = events.find((event) => event.kind === 'result' && event.seq > operation.seq && event.operation === operation.operation
+                && (operation.traceId === undefined || event.traceId === operation.traceId));
+            if (recorded?.kind === 'result' && recorded.success !== outcome.success) {
+                divergences.push({
+                    seq: recorded.seq,
+                    kind: 'result',
+                    detail: `${operation.operation} ${recorded.success ? 'succeeded' : 'failed'} when recorded but ${outcome.success ? 'succeeded' : `failed: ${outcome.error ?? 'no error given'}`}`,
+                });
+            }
+        }
+        const replayed = await readSessionRecording(sandbox, request.sessionId);
+        const recordedFiles = events.filter((event) => event.kind === 'file');
+        const replayedFiles = replayed.filter((event) => event.kind === 'file');
+        divergences.push(
+            ...bridge.divergences(),
+            ...compareInOrder(recordedFiles, replayedFiles, 'file', (event) => event.kind === 'file' ? `${event.action} ${event.path}${event.after !== undefined ? ` (${event.after.slice(0, 12)})` : ''}` : ''),
+            ...compareInOrder(events.filter((event) => event.kind === 'tool' && event.source === 'workflow'), replayed.filter((event) => event.kind === 'tool' && event.source === 'workflow'), 'tool', (event) => event.kind === 'tool' ? `${event.tool} ${JSON.stringify(event.args)}` : ''),
+        );
+        divergences.sort((left, right) => (left.seq ?? Number.MAX_SAFE_INTEGER) - (right.seq ?? Number.MAX_SAFE_INTEGER));
+        return {
+            sessionId: request.sessionId,
+            deterministic: divergences.length === 0,
+            operations,
+            providerCalls: { recorded: events.filter((event) => event.kind === 'provider').length, served: bridge.served() },
+            files: { recorded: recordedFiles.length, replayed: replayedFiles.length },
+            divergences,
+            ...(request.keepSandbox === true ? { sandbox } : {}),
+        };
+    }
+    finally {
+        if (request.keepSandbox !== true) {
+            await rm(sandbox, { recursive: true, force: true });
+        }
+    }
+}
+async function restoreFilesBeforeSession(basePath, sandbox, events) {
+    const restored = new Set();
+    for (const event of events) {
+        if (event.kind !== 'file' || restored.has(event.path)) {
+            continue;
+        }
+        restored.add(event.path);
+        const target = join(sandbox, event.path);
+        if (event.before === undefined) {
+            await rm(target, { force: true });
+        }
+        else {
+            await mkdir(dirname(target), { recursive: true });
+            await writeFile(target, await readRecordingObject(basePath, event.before));
+        }
+    }
+}
+function compareInOrder(recorded, replayed, kind, describe) {
+    const divergences = [];
+    for (let index = 0; index < Math.max(recorded.length, replayed.length); index += 1) {
+        const expected = recorded[index];
+        const actual = replayed[index];
+        if (expected === undefined) {
+            divergences.push({ kind, detail: `extra ${kind} event: ${truncate(describe(actual))}` });
+        }
+        else if (actual === undefined) {
+            divergences.push({ seq: expected.seq, kind, detail: `not reproduced: ${truncate(describe(expected))}` });
+        }
+        else if (describe(expected) !== describe(actual)) {
+            divergences.push({ seq: expected.seq, kind, detail: `recorded ${truncate(describe(expected))}, replayed ${truncate(describe(actual))}` });
+        }
+    }
+    return divergences;
+}
+function describeRequestDifference(recorded, actual) {
+    if (recorded.provider !== actual.provider) {
+        return `called ${actual.provider} instead of ${recorded.provider}`;
+    }
+    if (recorded.model !== actual.model) {
+        return `${actual.provider} model ${actual.model ?? 'default'} instead of ${recorded.model ?? 'default'}`;
+    }
+    if ((recorded.systemPrompt ?? '') !== (actual.systemPrompt ?? '')) {
+        return `${actual.provider} system prompt ${describeTextDifference(recorded.systemPrompt ?? '', actual.systemPrompt ?? '')}`;
+    }
+    if (recorded.prompt !== actual.prompt) {
+        return `${actual.provider} prompt ${describeTextDifference(recorded.prompt, actual.prompt)}`;
+    }
+    return undefined;
+}
+function describeTextDifference(recorded, actual) {
+    const expectedLines = recorded.split('\n');
+    const actualLines = actual.split('\n');
+    const line = expectedLines.findIndex((text, index) => text !== actualLines[index]);
+    const index = line === -1 ? expectedLines.length : line;
+    return `differs at line ${index + 1}: recorded "${truncate(expectedLines[index] ?? '')}", now "${truncate(actualLines[index] ?? '')}"`;
+}
+function recordingPath(basePath, sessionId) {
+    return join(basePath, SESSION_RECORDINGS_DIR, `${encodeURIComponent(sessionId)}.jsonl`);
+}
+function firstLine(text) {
+    return truncate(text.split('\n')[0] ?? '');
+}
+function truncate(text) {
+    return text.length > MAX_DETAIL_CHARS ? `${text.slice(0, MAX_DETAIL_CHARS)}…` : text;
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
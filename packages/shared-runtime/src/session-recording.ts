@@ -0,0 +1,342 @@
+import { createHash } from 'node:crypto';
+import { appendFile, cp, mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { dirname, join, relative, sep } from 'node:path';
+import type { ProviderExecutionMode, ProviderExecutionOutcome, ProviderExecutionRequest } from './provider-bridge.js';
+import { createWorkspaceSandbox } from './snapshot.js';
+
+export const SESSION_RECORDINGS_DIR = join('.automatosx', 'recordings');
+export const SESSION_REPLAY_SANDBOX_PREFIX = 'ax-replay-';
+export const SESSION_RECORDING_OBJECTS_DIR = 'objects';
+// State a replay doesn't read: earlier recordings, snapshots, and experiments.
+const REPLAY_SKIPPED_STATE = new Set(['recordings', 'snapshots', 'experiments']);
+const MAX_DETAIL_CHARS = 120;
+
+export type SessionOperation = 'call' | 'agent' | 'workflow' | 'patch';
+
+export type RecordedProviderRequest = Omit<ProviderExecutionRequest, 'signal'>;
+
+export type SessionEvent =
+  // The runtime request that started a run, minus where and for whom it ran.
+  | { kind: 'operation'; operation: SessionOperation; traceId?: string; request: Record<string, unknown> }
+  | { kind: 'result'; operation: SessionOperation; traceId?: string; success: boolean; error?: string }
+  | { kind: 'provider'; traceId?: string; request: RecordedProviderRequest; outcome: ProviderExecutionOutcome }
+  // `mcp` calls come from a client and are shown, not re-run; `workflow` calls are a workflow's tool steps.
+  | { kind: 'tool'; source: 'mcp' | 'workflow'; traceId?: string; tool: string; args: Record<string, unknown>; success: boolean; error?: string; durationMs: number }
+  // `before` and `after` are SHA-256 hashes of the content, kept under recordings/objects; unset when the file didn't exist.
+  | { kind: 'file'; traceId?: string; path: string; action: 'write' | 'delete'; before?: string; after?: string };
+
+// `seq` counts from 1 in recording order.
+export type SessionRecordingEvent = SessionEvent & { seq: number; at: string };
+
+export type SessionOperationEvent = Extract<SessionRecordingEvent, { kind: 'operation' }>;
+
+export interface SessionReplayDivergence {
+  // The recorded event that wasn't reproduced; unset for calls and writes the recording doesn't have.
+  seq?: number;
+  kind: 'provider' | 'tool' | 'file' | 'result';
+  detail: string;
+}
+
+export interface RuntimeSessionReplayResponse {
+  sessionId: string;
+  // Every provider request, tool step, file write, and outcome matched the recording.
+  deterministic: boolean;
+  operations: Array<{ seq: number; operation: SessionOperation; traceId?: string; success: boolean; error?: string }>;
+  providerCalls: { recorded: number; served: number };
+  files: { recorded: number; replayed: number };
+  divergences: SessionReplayDivergence[];
+  // Only with `keepSandbox`; otherwise the copy is removed.
+  sandbox?: string;
+}
+
+export interface ReplayProviderBridge {
+  getExecutionMode(): ProviderExecutionMode;
+  executePrompt(request: ProviderExecutionRequest): Promise<ProviderExecutionOutcome>;
+  served(): number;
+  divergences(): SessionReplayDivergence[];
+}
+
+// Off unless `sessions.record: true` in config: recordings hold prompts and responses, secrets redacted, and file contents as they were.
+export function resolveSessionRecordingEnabled(sessionsConfig: unknown): boolean {
+  return isRecord(sessionsConfig) && sessionsConfig.record === true;
+}
+
+export async function appendSessionEvent(basePath: string, sessionId: string, event: SessionEvent, now = new Date()): Promise<void> {
+  const path = recordingPath(basePath, sessionId);
+  await mkdir(dirname(path), { recursive: true });
+  await appendFile(path, `${JSON.stringify({ at: now.toISOString(), ...event })}\n`, 'utf8');
+}
+
+// Oldest first; a session never recorded reads as no events.
+export async function readSessionRecording(basePath: string, sessionId: string): Promise<SessionRecordingEvent[]> {
+  let raw = '';
+  try {
+    raw = await readFile(recordingPath(basePath, sessionId), 'utf8');
+  } catch {
+    return [];
+  }
+  return raw.split('\n').flatMap((line) => {
+    try {
+      const parsed = JSON.parse(line) as unknown;
+      return isRecord(parsed) && typeof parsed.kind === 'string' && typeof parsed.at === 'string' ? [parsed as unknown as Omit<SessionRecordingEvent, 'seq'>] : [];
+    } catch {
+      return [];
+    }
+  }).map((event, index) => ({ ...event, seq: index + 1 }) as SessionRecordingEvent);
+}
+
+// Undefined when the file doesn't exist.
+export async function readFileForRecording(basePath: string, path: string): Promise<Buffer | undefined> {
+  return readFile(join(basePath, path)).catch(() => undefined);
+}
+
+// Content is kept by hash so a replay can put files back as they were before the session changed them.
+export async function appendFileChangeEvent(
+  basePath: string,
+  sessionId: string,
+  change: { traceId?: string; path: string; before?: Buffer; after?: Buffer },
+): Promise<void> {
+  await appendSessionEvent(basePath, sessionId, {
+    kind: 'file',
+    ...(change.traceId !== undefined ? { traceId: change.traceId } : {}),
+    path: change.path,
+    action: change.after === undefined ? 'delete' : 'write',
+    ...(change.before !== undefined ? { before: await storeRecordingObject(basePath, change.before) } : {}),
+    ...(change.after !== undefined ? { after: await storeRecordingObject(basePath, change.after) } : {}),
+  });
+}
+
+async function storeRecordingObject(basePath: string, content: Buffer): Promise<string> {
+  const hash = createHash('sha256').update(content).digest('hex');
+  const path = join(basePath, SESSION_RECORDINGS_DIR, SESSION_RECORDING_OBJECTS_DIR, hash.slice(0, 2), hash.slice(2));
+  await mkdir(dirname(path), { recursive: true });
+  await writeFile(path, content, { flag: 'wx' }).catch((error: NodeJS.ErrnoException) => {
+    if (error.code !== 'EEXIST') {
+      throw error;
+    }
+  });
+  return hash;
+}
+
+async function readRecordingObject(basePath: string, hash: string): Promise<Buffer> {
+  return readFile(join(basePath, SESSION_RECORDINGS_DIR, SESSION_RECORDING_OBJECTS_DIR, hash.slice(0, 2), hash.slice(2)));
+}
+
+// Passes every call through to `bridge`, then hands the request and outcome to `record`.
+export function recordProviderCalls<T extends { executePrompt(request: ProviderExecutionRequest): Promise<ProviderExecutionOutcome> }>(
+  bridge: T,
+  record: (request: RecordedProviderRequest, outcome: ProviderExecutionOutcome) => Promise<void>,
+): T {
+  return {
+    ...bridge,
+    async executePrompt(request: ProviderExecutionRequest) {
+      const outcome = await bridge.executePrompt(request);
+      const { signal: _signal, ...recorded } = request;
+      await record(recorded, outcome);
+      return outcome;
+    },
+  };
+}
+
+/**
+ * A provider bridge that answers from the recording instead of running
+ * providers: the nth call gets the nth recorded outcome. A request that
+ * differs from the recorded one is still answered as recorded, so the replay
+ * follows the original run, and the difference is noted as a divergence.
+ * Calls past the end of the recording fail with REPLAY_NOT_RECORDED.
+ * Recordings are stored redacted, so requests are compared after `redact`.
+ */
+export function createReplayProviderBridge(events: SessionRecordingEvent[], redact: <T>(value: T) => T = (value) => value): ReplayProviderBridge {
+  const recorded = events.filter((event): event is Extract<SessionRecordingEvent, { kind: 'provider' }> => event.kind === 'provider');
+  const divergences: SessionReplayDivergence[] = [];
+  let next = 0;
+  return {
+    getExecutionMode: () => 'simulate',
+    async executePrompt(request) {
+      const event = recorded[next];
+      if (event === undefined) {
+        divergences.push({ kind: 'provider', detail: `extra call to ${request.provider}: ${firstLine(request.prompt)}` });
+        return {
+          type: 'failure',
+          response: {
+            success: false,
+            provider: request.provider,
+            model: request.model,
+            latencyMs: 0,
+            errorCode: 'REPLAY_NOT_RECORDED',
+            error: `The recording has no response for this ${request.provider} call.`,
+            mode: 'subprocess',
+          },
+        };
+      }
+      next += 1;
+      const { signal: _signal, ...compared } = request;
+      const difference = describeRequestDifference(event.request, redact(compared));
+      if (difference !== undefined) {
+        divergences.push({ seq: event.seq, kind: 'provider', detail: difference });
+      }
+      return event.outcome;
+    },
+    served: () => next,
+    divergences: () => [
+      ...divergences,
+      ...recorded.slice(next).map((event) => ({ seq: event.seq, kind: 'provider' as const, detail: `recorded call to ${event.request.provider} was not made` })),
+    ],
+  };
+}
+
+/**
+ * Re-runs a session's operations in a copy of the workspace, with provider
+ * calls answered from the recording, and compares what happens with what was
+ * recorded: provider requests, workflow tool steps, file writes, and whether
+ * each run succeeded. Files the session changed are first put back as they
+ * were before its first change; other files are as they are now. `to` stops
+ * after the operation at that seq.
+ */
+export async function replayRecordedSession(request: {
+  basePath: string;
+  sessionId: string;
+  events: SessionRecordingEvent[];
+  to?: number;
+  keepSandbox?: boolean;
+  // Applied to live provider requests before they are compared with the redacted recording.
+  redact?: <T>(value: T) => T;
+  // A runner over a runtime rooted at `sandbox` whose provider calls go to `bridge`.
+  createRunner: (sandbox: string, bridge: ReplayProviderBridge) => (operation: SessionOperationEvent) => Promise<{ traceId?: string; success: boolean; error?: string }>;
+}): Promise<RuntimeSessionReplayResponse> {
+  const events = request.events.filter((event) => request.to === undefined || event.seq <= request.to);
+  const sandbox = await createWorkspaceSandbox(request.basePath, SESSION_REPLAY_SANDBOX_PREFIX);
+  try {
+    const stateDir = join(request.basePath, '.automatosx');
+    await cp(stateDir, join(sandbox, '.automatosx'), {
+      recursive: true,
+      filter: (source) => !REPLAY_SKIPPED_STATE.has(relative(stateDir, source).split(sep)[0] ?? ''),
+    }).catch(() => undefined);
+    await restoreFilesBeforeSession(request.basePath, sandbox, request.events);
+
+    const bridge = createReplayProviderBridge(events, request.redact);
+    const run = request.createRunner(sandbox, bridge);
+    const divergences: SessionReplayDivergence[] = [];
+    const operations: RuntimeSessionReplayResponse['operations'] = [];
+    for (const operation of events.filter((event): event is SessionOperationEvent => event.kind === 'operation')) {
+      const outcome = await run(operation).catch((error: unknown) => ({ traceId: undefined, success: false, error: error instanceof Error ? error.message : String(error) }));
+      operations.push({ seq: operation.seq, operation: operation.operation, ...outcome });
+      // Patch runs get their trace id as they start, so theirs is only on the result.
+      const recorded = events.find((event) => event.kind === 'result' && event.seq > operation.seq && event.operation === operation.operation
+        && (operation.traceId === undefined || event.traceId === operation.traceId));
+      if (recorded?.kind === 'result' && recorded.success !== outcome.success) {
+        divergences.push({
+          seq: recorded.seq,
+          kind: 'result',
+          detail: `${operation.operation} ${recorded.success ? 'succeeded' : 'failed'} when recorded but ${outcome.success ? 'succeeded' : `failed: ${outcome.error ?? 'no error given'}`}`,
+        });
+      }
+    }
+
+    const replayed = await readSessionRecording(sandbox, request.sessionId);
+    const recordedFiles = events.filter((event) => event.kind === 'file');
+    const replayedFiles = replayed.filter((event) => event.kind === 'file');
+    divergences.push(
+      ...bridge.divergences(),
+      ...compareInOrder(recordedFiles, replayedFiles, 'file', (event) => event.kind === 'file' ? `${event.action} ${event.path}${event.after !== undefined ? ` (${event.after.slice(0, 12)})` : ''}` : ''),
+      ...compareInOrder(
+        events.filter((event) => event.kind === 'tool' && event.source === 'workflow'),
+        replayed.filter((event) => event.kind === 'tool' && event.source === 'workflow'),
+        'tool',
+        (event) => event.kind === 'tool' ? `${event.tool} ${JSON.stringify(event.args)}` : '',
+      ),
+    );
+    divergences.sort((left, right) => (left.seq ?? Number.MAX_SAFE_INTEGER) - (right.seq ?? Number.MAX_SAFE_INTEGER));
+    return {
+      sessionId: request.sessionId,
+      deterministic: divergences.length === 0,
+      operations,
+      providerCalls: { recorded: events.filter((event) => event.kind === 'provider').length, served: bridge.served() },
+      files: { recorded: recordedFiles.length, replayed: replayedFiles.length },
+      divergences,
+      ...(request.keepSandbox === true ? { sandbox } : {}),
+    };
+  } finally {
+    if (request.keepSandbox !== true) {
+      await rm(sandbox, { recursive: true, force: true });
+    }
+  }
+}
+
+async function restoreFilesBeforeSession(basePath: string, sandbox: string, events: SessionRecordingEvent[]): Promise<void> {
+  const restored = new Set<string>();
+  for (const event of events) {
+    if (event.kind !== 'file' || restored.has(event.path)) {
+      continue;
+    }
+    restored.add(event.path);
+    const target = join(sandbox, event.path);
+    if (event.before === undefined) {
+      await rm(target, { force: true });
+    } else {
+      await mkdir(dirname(target), { recursive: true });
+      await writeFile(target, await readRecordingObject(basePath, event.before));
+    }
+  }
+}
+
+function compareInOrder(
+  recorded: SessionRecordingEvent[],
+  replayed: SessionRecordingEvent[],
+  kind: 'tool' | 'file',
+  describe: (event: SessionRecordingEvent) => string,
+): SessionReplayDivergence[] {
+  const divergences: SessionReplayDivergence[] = [];
+  for (let index = 0; index < Math.max(recorded.length, replayed.length); index += 1) {
+    const expected = recorded[index];
+    const actual = replayed[index];
+    if (expected === undefined) {
+      divergences.push({ kind, detail: `extra ${kind} event: ${truncate(describe(actual!))}` });
+    } else if (actual === undefined) {
+      divergences.push({ seq: expected.seq, kind, detail: `not reproduced: ${truncate(describe(expected))}` });
+    } else if (describe(expected) !== describe(actual)) {
+      divergences.push({ seq: expected.seq, kind, detail: `recorded ${truncate(describe(expected))}, replayed ${truncate(describe(actual))}` });
+    }
+  }
+  return divergences;
+}
+
+function describeRequestDifference(recorded: RecordedProviderRequest, actual: RecordedProviderRequest): string | undefined {
+  if (recorded.provider !== actual.provider) {
+    return `called ${actual.provider} instead of ${recorded.provider}`;
+  }
+  if (recorded.model !== actual.model) {
+    return `${actual.provider} model ${actual.model ?? 'default'} instead of ${recorded.model ?? 'default'}`;
+  }
+  if ((recorded.systemPrompt ?? '') !== (actual.systemPrompt ?? '')) {
+    return `${actual.provider} system prompt ${describeTextDifference(recorded.systemPrompt ?? '', actual.systemPrompt ?? '')}`;
+  }
+  if (recorded.prompt !== actual.prompt) {
+    return `${actual.provider} prompt ${describeTextDifference(recorded.prompt, actual.prompt)}`;
+  }
+  return undefined;
+}
+
+function describeTextDifference(recorded: string, actual: string): string {
+  const expectedLines = recorded.split('\n');
+  const actualLines = actual.split('\n');
+  const line = expectedLines.findIndex((text, index) => text !== actualLines[index]);
+  const index = line === -1 ? expectedLines.length : line;
+  return `differs at line ${index + 1}: recorded "${truncate(expectedLines[index] ?? '')}", now "${truncate(actualLines[index] ?? '')}"`;
+}
+
+function recordingPath(basePath: string, sessionId: string): string {
+  return join(basePath, SESSION_RECORDINGS_DIR, `${encodeURIComponent(sessionId)}.jsonl`);
+}
+
+function firstLine(text: string): string {
+  return truncate(text.split('\n')[0] ?? '');
+}
+
+function truncate(text: string): string {
+  return text.length > MAX_DETAIL_CHARS ? `${text.slice(0, MAX_DETAIL_CHARS)}…` : text;
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
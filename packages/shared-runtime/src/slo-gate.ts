@@ -0,0 +1,249 @@
+import { randomUUID } from 'node:crypto';
+import type { TraceStore, TraceSurface } from '@defai.digital/trace-store';
+
+export type SloGateDecision = 'pass' | 'warn' | 'block' | 'skipped';
+
+export interface SloStatus {
+  name: string;
+  objective?: number;
+  errorBudgetRemaining: number;
+  burnRate?: number;
+}
+
+export interface SloGateConfig {
+  endpoint?: string;
+  headers?: Record<string, string>;
+  warnThreshold?: number;
+  blockThreshold?: number;
+  mode?: 'block' | 'warn';
+  onError?: 'block' | 'warn';
+  timeoutMs?: number;
+  // Workflows that run the gate before their first step; defaults to release.
+  workflows?: string[];
+}
+
+export type SloStatusFetcher = (endpoint: string, headers: Record<string, string>, timeoutMs: number) => Promise<unknown>;
+
+export interface RuntimeSloGateRequest {
+  config: SloGateConfig;
+  workflowId?: string;
+  traceId?: string;
+  sessionId?: string;
+  surface?: TraceSurface;
+  fetchStatus?: SloStatusFetcher;
+}
+
+export interface RuntimeSloGateResponse {
+  traceId?: string;
+  decision: SloGateDecision;
+  workflowId?: string;
+  endpoint?: string;
+  slos: SloStatus[];
+  reasons: string[];
+  checkedAt: string;
+  error?: {
+    code?: string;
+    message?: string;
+  };
+}
+
+const DEFAULT_WARN_THRESHOLD = 0.1;
+const DEFAULT_BLOCK_THRESHOLD = 0;
+const DEFAULT_TIMEOUT_MS = 5_000;
+const DEFAULT_GATED_WORKFLOWS = ['release'];
+
+export async function runSloGate(
+  traceStore: TraceStore,
+  request: RuntimeSloGateRequest,
+): Promise<RuntimeSloGateResponse> {
+  const checkedAt = new Date().toISOString();
+  const endpoint = request.config.endpoint;
+
+  if (endpoint === undefined || endpoint.length === 0) {
+    return {
+      decision: 'skipped',
+      workflowId: request.workflowId,
+      slos: [],
+      reasons: ['No SLO endpoint configured (slo.endpoint).'],
+      checkedAt,
+    };
+  }
+
+  const traceId = request.traceId ?? randomUUID();
+  const fetchStatus = request.fetchStatus ?? fetchSloStatus;
+  let response: RuntimeSloGateResponse;
+
+  try {
+    const payload = await fetchStatus(
+      endpoint,
+      request.config.headers ?? {},
+      request.config.timeoutMs ?? DEFAULT_TIMEOUT_MS,
+    );
+    const { slos, rejected } = parseSloStatuses(payload);
+    const { decision, reasons } = decideSloGate(slos, request.config, rejected);
+    response = {
+      traceId,
+      decision,
+      workflowId: request.workflowId,
+      endpoint,
+      slos,
+      reasons,
+      checkedAt,
+    };
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    const decision = request.config.onError === 'block' ? 'block' : 'warn';
+    response = {
+      traceId,
+      decision,
+      workflowId: request.workflowId,
+      endpoint,
+      slos: [],
+      reasons: [`SLO backend unavailable: ${message}`],
+      checkedAt,
+      error: {
+        code: 'SLO_BACKEND_UNAVAILABLE',
+        message,
+      },
+    };
+  }
+
+  const completedAt = new Date().toISOString();
+  await traceStore.upsertTrace({
+    traceId,
+    workflowId: 'slo-gate',
+    surface: request.surface ?? 'cli',
+    status: response.error === undefined ? 'completed' : 'failed',
+    startedAt: checkedAt,
+    completedAt,
+    input: {
+      endpoint,
+      workflowId: request.workflowId,
+    },
+    stepResults: [
+      {
+        stepId: 'evaluate-budgets',
+        success: response.error === undefined,
+        durationMs: Math.max(0, Date.parse(completedAt) - Date.parse(checkedAt)),
+        retryCount: 0,
+        error: response.error?.message,
+      },
+    ],
+    output: {
+      decision: response.decision,
+      slos: response.slos,
+      reasons: response.reasons,
+    },
+    error: response.error,
+    metadata: {
+      sessionId: request.sessionId,
+      gatedWorkflowId: request.workflowId,
+      decision: response.decision,
+    },
+  });
+
+  return response;
+}
+
+// The gate fails closed: an empty report, or one with entries it could not
+// read, may be hiding an exhausted budget, so it blocks like one.
+export function decideSloGate(
+  slos: SloStatus[],
+  config: SloGateConfig,
+  rejected: string[] = [],
+): { decision: Exclude<SloGateDecision, 'skipped'>; reasons: string[] } {
+  const warnThreshold = config.warnThreshold ?? DEFAULT_WARN_THRESHOLD;
+  const blockThreshold = config.blockThreshold ?? DEFAULT_BLOCK_THRESHOLD;
+  const exhausted = slos.filter((slo) => slo.errorBudgetRemaining <= blockThreshold);
+  const low = slos.filter((slo) => slo.errorBudgetRemaining > blockThreshold && slo.errorBudgetRemaining <= warnThreshold);
+  const unknown = [
+    ...(slos.length === 0 && rejected.length === 0 ? ['SLO backend reported no SLOs: budget status unknown'] : []),
+    ...rejected.map((entry) => `${entry}: budget status unknown`),
+  ];
+  const reasons = [
+    ...unknown,
+    ...exhausted.map((slo) => `${slo.name}: error budget exhausted (${formatBudget(slo.errorBudgetRemaining)} remaining)`),
+    ...low.map((slo) => `${slo.name}: error budget low (${formatBudget(slo.errorBudgetRemaining)} remaining)`),
+  ];
+
+  if (exhausted.length > 0 || unknown.length > 0) {
+    return { decision: config.mode === 'warn' ? 'warn' : 'block', reasons };
+  }
+  if (low.length > 0) {
+    return { decision: 'warn', reasons };
+  }
+  return { decision: 'pass', reasons: [`All ${slos.length} SLOs within budget.`] };
+}
+
+export function resolveSloGateConfig(value: unknown): SloGateConfig {
+  if (!isRecord(value)) {
+    return {};
+  }
+
+  const headers = isRecord(value.headers)
+    ? Object.fromEntries(Object.entries(value.headers).filter((entry): entry is [string, string] => typeof entry[1] === 'string'))
+    : undefined;
+
+  return {
+    endpoint: typeof value.endpoint === 'string' ? value.endpoint : undefined,
+    headers,
+    warnThreshold: typeof value.warnThreshold === 'number' ? value.warnThreshold : undefined,
+    blockThreshold: typeof value.blockThreshold === 'number' ? value.blockThreshold : undefined,
+    mode: value.mode === 'warn' || value.mode === 'block' ? value.mode : undefined,
+    onError: value.onError === 'warn' || value.onError === 'block' ? value.onError : undefined,
+    timeoutMs: typeof value.timeoutMs === 'number' ? value.timeoutMs : undefined,
+    workflows: Array.isArray(value.workflows) ? value.workflows.filter((entry): entry is string => typeof entry === 'string') : undefined,
+  };
+}
+
+export function isSloGatedWorkflow(config: SloGateConfig, workflowId: string): boolean {
+  return (config.workflows ?? DEFAULT_GATED_WORKFLOWS).includes(workflowId);
+}
+
+// Entries without a name or a numeric budget are returned as `rejected`, described by name or position.
+export function parseSloStatuses(payload: unknown): { slos: SloStatus[]; rejected: string[] } {
+  const entries = Array.isArray(payload)
+    ? payload
+    : isRecord(payload) && Array.isArray(payload.slos)
+      ? payload.slos
+      : undefined;
+
+  if (entries === undefined) {
+    throw new Error('SLO response must be an array or an object with an "slos" array');
+  }
+
+  const slos: SloStatus[] = [];
+  const rejected: string[] = [];
+  entries.forEach((entry: unknown, index: number) => {
+    if (!isRecord(entry) || typeof entry.name !== 'string' || typeof entry.errorBudgetRemaining !== 'number' || !Number.isFinite(entry.errorBudgetRemaining)) {
+      rejected.push(isRecord(entry) && typeof entry.name === 'string' ? entry.name : `entry ${index + 1}`);
+      return;
+    }
+    slos.push({
+      name: entry.name,
+      objective: typeof entry.objective === 'number' ? entry.objective : undefined,
+      errorBudgetRemaining: entry.errorBudgetRemaining,
+      burnRate: typeof entry.burnRate === 'number' ? entry.burnRate : undefined,
+    });
+  });
+  return { slos, rejected };
+}
+
+async function fetchSloStatus(endpoint: string, headers: Record<string, string>, timeoutMs: number): Promise<unknown> {
+  const response = await fetch(endpoint, {
+    headers: { accept: 'application/json', ...headers },
+    signal: AbortSignal.timeout(timeoutMs),
+  });
+  if (!response.ok) {
+    throw new Error(`metrics backend returned HTTP ${response.status}`);
+  }
+  return response.json() as Promise<unknown>;
+}
+
+function formatBudget(value: number): string {
+  return `${Math.round(value * 1000) / 10}%`;
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,318 @@
+import { execFile } from 'node:child_process';
+import { createHash } from 'node:crypto';
+import { copyFile, lstat, mkdir, mkdtemp, readdir, readFile, rename, rm, stat, symlink, writeFile } from 'node:fs/promises';
+import { tmpdir } from 'node:os';
+import { dirname, join, relative, sep } from 'node:path';
+import { promisify } from 'node:util';
+const execFileAsync = promisify(execFile);
+export const SNAPSHOTS_DIR = join('.automatosx', 'snapshots');
+const AUTOMATOSX_DIR = '.automatosx';
+const OBJECTS_DIR = 'objects';
+const MANIFESTS_DIR = 'manifests';
+const PRE_CHECKOUT_SESSION_ID = 'pre-checkout';
+const DEFAULT_MAX_FILE_BYTES = 2 * 1024 * 1024;
+// Only used outside git repositories, where .gitignore cannot be consulted.
+const WALK_SKIP_DIRS = new Set(['.git', AUTOMATOSX_DIR, 'node_modules']);
+export function resolveSnapshotConfig(value) {
+    if (!isRecord(value)) {
+        return { enabled: false, everySteps: 1, maxFileBytes: DEFAULT_MAX_FILE_BYTES };
+    }
+    return {
+        enabled: value.enabled === true,
+        everySteps: typeof value.everySteps === 'number' && Number.isInteger(value.everySteps) && value.everySteps > 0 ? value.everySteps : 1,
+        maxFileBytes: typeof value.maxFileBytes === 'number' && value.maxFileBytes > 0 ? value.maxFileBytes : DEFAULT_MAX_FILE_BYTES,
+    };
+}
+export function formatSnapshotId(sessionId, step) {
+    return `${sessionId}@${step}`;
+}
+/**
+ * Records the working tree as a numbered step of a session. File contents
+ * are stored once per distinct blob under .automatosx/snapshots/objects, so
+ * repeated snapshots of a mostly unchanged tree cost little more than their
+ * manifest. Snapshots are independent of git: uncommitted and untracked
+ * (but not ignored) files are included.
+ */
+export async function takeSnapshot(request) {
+    assertSessionId(request.sessionId);
+    const root = join(request.basePath, SNAPSHOTS_DIR);
+    const maxFileBytes = request.maxFileBytes ?? DEFAULT_MAX_FILE_BYTES;
+    const files = [];
+    const skipped = [];
+    let newObjects = 0;
+    for (const path of await listWorkspaceFiles(request.basePath)) {
+        let stats;
+        try {
+            stats = await lstat(join(request.basePath, path));
+        }
+        catch {
+            // Tracked by git but deleted from the working tree.
+            continue;
+        }
+        if (!stats.isFile()) {
+            continue;
+        }
+        if (stats.size > maxFileBytes) {
+            skipped.push(path);
+            continue;
+        }
+        const data = await readFile(join(request.basePath, path));
+        const hash = sha256(data);
+        if (await writeObject(root, hash, data)) {
+            newObjects += 1;
+        }
+        files.push({ path, size: data.length, sha256: hash });
+    }
+    const step = (await readSessionSteps(root, request.sessionId)).reduce((max, value) => Math.max(max, value), 0) + 1;
+    const snapshot = {
+        snapshotId: formatSnapshotId(request.sessionId, step),
+        sessionId: request.sessionId,
+        step,
+        label: request.label,
+        traceId: request.traceId,
+        stepId: request.stepId,
+        createdAt: (request.now ?? new Date()).toISOString(),
+        treeHash: treeHash(files),
+        fileCount: files.length,
+        totalBytes: files.reduce((sum, file) => sum + file.size, 0),
+        files,
+        skipped,
+    };
+    const manifestPath = join(root, MANIFESTS_DIR, encodeURIComponent(request.sessionId), `${step}.json`);
+    await mkdir(dirname(manifestPath), { recursive: true });
+    await writeFile(manifestPath, `${JSON.stringify(snapshot, null, 2)}\n`, 'utf8');
+    return { ...summarize(snapshot), newObjects, skipped };
+}
+export async function listSnapshots(request) {
+    const root = join(request.basePath, SNAPSHOTS_DIR);
+    const sessionIds = request.sessionId !== undefined
+        ? [request.sessionId]
+        : (await readdirOrEmpty(join(root, MANIFESTS_DIR))).map((name) => decodeURIComponent(name));
+    const summaries = [];
+    for (const sessionId of sessionIds) {
+        for (const step of await readSessionSteps(root, sessionId)) {
+            summaries.push(summarize(await readManifest(root, sessionId, step)));
+        }
+    }
+    return summaries.sort((left, right) => left.createdAt.localeCompare(right.createdAt) || left.step - right.step);
+}
+/**
+ * Resolves `<session>@<step>`, `<session>@latest` or a bare `<session>`
+ * (its latest step). A unique session id prefix is enough.
+ */
+export async function readSnapshot(request) {
+    const root = join(request.basePath, SNAPSHOTS_DIR);
+    const at = request.snapshotId.lastIndexOf('@');
+    const sessionRef = at === -1 ? request.snapshotId : request.snapshotId.slice(0, at);
+    const stepRef = at === -1 ? 'latest' : request.snapshotId.slice(at + 1);
+    const sessions = (await readdirOrEmpty(join(root, MANIFESTS_DIR))).map((name) => decodeURIComponent(name));
+    const matches = sessions.includes(sessionRef) ? [sessionRef] : sessions.filter((sessionId) => sessionId.startsWith(sessionRef));
+    if (matches.length !== 1 || sessionRef.length === 0) {
+        throw new Error(matches.length > 1
+            ? `Snapshot session "${sessionRef}" is ambiguous: ${matches.join(', ')}`
+            : `No snapshots found for session "${sessionRef}".`);
+    }
+    const sessionId = matches[0];
+    const steps = await readSessionSteps(root, sessionId);
+    const step = stepRef === 'latest' ? steps.at(-1) : Number.parseInt(stepRef, 10);
+    if (step === undefined || !steps.includes(step)) {
+        throw new Error(`Session "${sessionId}" has no snapshot at step ${stepRef} (steps: ${steps.join(', ') || 'none'}).`);
+    }
+    return readManifest(root, sessionId, step);
+}
+export async function readSnapshotFile(request) {
+    const snapshot = await readSnapshot(request);
+    const entry = snapshot.files.find((file) => file.path === request.path);
+    if (entry === undefined) {
+        throw new Error(`${request.path} is not in snapshot ${snapshot.snapshotId}.`);
+    }
+    return readObject(join(request.basePath, SNAPSHOTS_DIR), entry);
+}
+/**
+ * Writes a snapshot back out. Without targetDir the working tree itself is
+ * restored: files the snapshot does not contain are removed, and the current
+ * state is snapshotted first so the checkout can be undone. With targetDir
+ * the snapshot is extracted there and the working tree is left alone.
+ */
+export async function checkoutSnapshot(request) {
+    const root = join(request.basePath, SNAPSHOTS_DIR);
+    const snapshot = await readSnapshot(request);
+    const selected = (path) => request.paths === undefined
+        || request.paths.length === 0
+        || request.paths.some((prefix) => path === prefix || path.startsWith(`${prefix.replace(/\/+$/, '')}/`));
+    const entries = snapshot.files.filter((file) => selected(file.path));
+    // Read and verify every blob before the first write, so a damaged store
+    // never leaves the tree half-restored.
+    const contents = new Map();
+    for (const entry of entries) {
+        contents.set(entry.path, await readObject(root, entry));
+    }
+    const inPlace = request.targetDir === undefined;
+    const targetDir = request.targetDir ?? request.basePath;
+    let safetySnapshotId;
+    const removed = [];
+    if (inPlace) {
+        const safety = await takeSnapshot({
+            basePath: request.basePath,
+            sessionId: PRE_CHECKOUT_SESSION_ID,
+            label: `before checkout of ${snapshot.snapshotId}`,
+        });
+        safetySnapshotId = safety.snapshotId;
+        // Oversized files were never captured, so they are neither restorable
+        // from the snapshot nor recoverable from the safety copy; leave them.
+        const kept = new Set([...entries.map((entry) => entry.path), ...snapshot.skipped, ...safety.skipped]);
+        for (const path of await listWorkspaceFiles(request.basePath)) {
+            if (selected(path) && !kept.has(path)) {
+                await rm(join(request.basePath, path), { force: true });
+                removed.push(path);
+            }
+        }
+    }
+    for (const entry of entries) {
+        const target = resolveInside(targetDir, entry.path);
+        await mkdir(dirname(target), { recursive: true });
+        const staging = `${target}.checkout-tmp`;
+        await writeFile(staging, contents.get(entry.path));
+        await rename(staging, target);
+    }
+    return {
+        snapshotId: snapshot.snapshotId,
+        targetDir,
+        written: entries.map((entry) => entry.path),
+        removed,
+        safetySnapshotId,
+    };
+}
+// Prefers git so .gitignore is honoured; falls back to a plain walk.
+export async function listWorkspaceFiles(basePath) {
+    let paths;
+    try {
+        const { stdout } = await execFileAsync('git', ['ls-files', '-z', '--cached', '--others', '--exclude-standard'], {
+            cwd: basePath,
+            maxBuffer: 64 * 1024 * 1024,
+        });
+        paths = stdout.split('\0').filter((path) => path.length > 0);
+    }
+    catch {
+        paths = await walk(basePath, basePath);
+    }
+    return [...new Set(paths)]
+        .filter((path) => path !== AUTOMATOSX_DIR && !path.startsWith(`${AUTOMATOSX_DIR}/`))
+        .sort();
+}
+// A copy of the workspace files in a new temp directory named with `prefix`,
+// minus .automatosx and what git ignores. The root node_modules is linked
+// rather than copied so test commands still run there.
+export async function createWorkspaceSandbox(basePath, prefix) {
+    const sandbox = await mkdtemp(join(tmpdir(), prefix));
+    for (const path of await listWorkspaceFiles(basePath)) {
+        const target = join(sandbox, path);
+        await mkdir(dirname(target), { recursive: true });
+        await copyFile(join(basePath, path), target).catch(() => undefined);
+    }
+    const modules = join(basePath, 'node_modules');
+    if (await stat(modules).then((info) => info.isDirectory(), () => false)) {
+        await symlink(modules, join(sandbox, 'node_modules'), process.platform === 'win32' ? 'junction' : 'dir').catch(() => undefined);
+    }
+    return sandbox;
+}
+async function walk(root, dir) {
+    const files = [];
+    for (const entry of await readdirEntries(dir)) {
+        if (entry.isDirectory()) {
+            if (!WALK_SKIP_DIRS.has(entry.name)) {
+                files.push(...await walk(root, join(dir, entry.name)));
+            }
+        }
+        else if (entry.isFile()) {
+            files.push(relative(root, join(dir, entry.name)).split(sep).join('/'));
+        }
+    }
+    return files;
+}
+async function writeObject(root, hash, data) {
+    const objectPath = join(root, OBJECTS_DIR, hash.slice(0, 2), hash.slice(2));
+    try {
+        await lstat(objectPath);
+        return false;
+    }
+    catch {
+        // not stored yet
+    }
+    await mkdir(dirname(objectPath), { recursive: true });
+    const staging = `${objectPath}.${process.pid}.tmp`;
+    await writeFile(staging, data);
+    await rename(staging, objectPath);
+    return true;
+}
+async function readObject(root, entry) {
+    let data;
+    try {
+        data = await readFile(join(root, OBJECTS_DIR, entry.sha256.slice(0, 2), entry.sha256.slice(2)));
+    }
+    catch {
+        throw new Error(`Snapshot object for ${entry.path} is missing (${entry.sha256}).`);
+    }
+    if (sha256(data) !== entry.sha256) {
+        throw new Error(`Snapshot object for ${entry.path} is corrupt (${entry.sha256}).`);
+    }
+    return data;
+}
+async function readSessionSteps(root, sessionId) {
+    return (await readdirOrEmpty(join(root, MANIFESTS_DIR, encodeURIComponent(sessionId))))
+        .map((name) => /^(\d+)\.json$/.exec(name)?.[1])
+        .filter((step) => step !== undefined)
+        .map((step) => Number.parseInt(step, 10))
+        .sort((left, right) => left - right);
+}
+async function readManifest(root, sessionId, step) {
+    const manifestPath = join(root, MANIFESTS_DIR, encodeURIComponent(sessionId), `${step}.json`);
+    return JSON.parse(await readFile(manifestPath, 'utf8'));
+}
+function summarize(snapshot) {
+    return {
+        snapshotId: snapshot.snapshotId,
+        sessionId: snapshot.sessionId,
+        step: snapshot.step,
+        label: snapshot.label,
+        traceId: snapshot.traceId,
+        stepId: snapshot.stepId,
+        createdAt: snapshot.createdAt,
+        treeHash: snapshot.treeHash,
+        fileCount: snapshot.fileCount,
+        totalBytes: snapshot.totalBytes,
+    };
+}
+async function readdirOrEmpty(dir) {
+    return (await readdirEntries(dir)).map((entry) => entry.name);
+}
+async function readdirEntries(dir) {
+    try {
+        return await readdir(dir, { withFileTypes: true });
+    }
+    catch {
+        return [];
+    }
+}
+function assertSessionId(sessionId) {
+    if (sessionId.length === 0 || sessionId.includes('@')) {
+        throw new Error(`Invalid snapshot session id "${sessionId}".`);
+    }
+}
+function resolveInside(root, path) {
+    const target = join(root, path);
+    const relativePath = relative(root, target);
+    if (relativePath.startsWith('..') || relativePath === '' || relativePath.split(sep).includes('..')) {
+        throw new Error(`Snapshot entry escapes ${root}: ${path}`);
+    }
+    return target;
+}
+function treeHash(files) {
+    return sha256(Buffer.from(files.map((file) => `${file.path}\0${file.sha256}`).join('\n'), 'utf8'));
+}
+function sha256(data) {
+    return createHash('sha256').update(data).digest('hex');
+}
+function isRecord(value) {
+    return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
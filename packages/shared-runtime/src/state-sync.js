@@ -0,0 +1,381 @@
+import { execFile } from 'node:child_process';
+import { createCipheriv, createDecipheriv, createHash, randomBytes, scryptSync } from 'node:crypto';
+import { existsSync } from 'node:fs';
+import { mkdir, readdir, readFile, rm, stat, writeFile } from 'node:fs/promises';
+import { hostname, tmpdir } from 'node:os';
+import { dirname, extname, join, relative, sep } from 'node:path';
+import { promisify } from 'node:util';
+import { createSyncPrivacyFilter, generalizeTimestamp, localPrivacyContext, resolveSyncPrivacy, } from './sync-privacy.js';
+const execFileAsync = promisify(execFile);
+export const SYNC_DIR = join('.automatosx', 'sync');
+export const DEFAULT_SYNC_PASSPHRASE_ENV = 'AX_SYNC_PASSPHRASE';
+const AUTOMATOSX_DIR = '.automatosx';
+const SYNC_OBJECT_NAME = 'automatosx-state.enc';
+const SYNC_SCHEMA_VERSION = 1;
+// Only user-authored state travels; caches, traces, artifacts, and runtime
+// databases stay on the machine that produced them.
+const SYNCED_FILE_ROOTS = ['specs', 'workflows'];
+const WORKFLOW_EXTENSIONS = ['.json', '.yaml', '.yml'];
+const DEFAULT_SYNC_BRANCH = 'automatosx-sync';
+export function resolveSyncConfig(value) {
+    if (!isRecord(value) || !isRecord(value.remote)) {
+        return undefined;
+    }
+    const remote = value.remote;
+    const passphraseEnv = typeof value.passphraseEnv === 'string' ? value.passphraseEnv : DEFAULT_SYNC_PASSPHRASE_ENV;
+    const privacy = resolveSyncPrivacy(value.privacy);
+    if (remote.type === 'git' && typeof remote.url === 'string') {
+        return { remote: { type: 'git', url: remote.url, branch: asOptionalString(remote.branch) }, passphraseEnv, ...(privacy !== undefined ? { privacy } : {}) };
+    }
+    if (remote.type === 's3' && typeof remote.bucket === 'string') {
+        return {
+            remote: { type: 's3', bucket: remote.bucket, prefix: asOptionalString(remote.prefix), region: asOptionalString(remote.region) },
+            passphraseEnv,
+            ...(privacy !== undefined ? { privacy } : {}),
+        };
+    }
+    throw new Error('sync.remote must be {"type":"git","url":...} or {"type":"s3","bucket":...}');
+}
+export async function syncState(request) {
+    const { basePath, config, passphrase, state } = request;
+    const now = request.now ?? new Date();
+    // Under a privacy policy the machine name stays out of the snapshot and the git history too.
+    const machine = config.privacy !== undefined ? '<host>' : hostname();
+    const transport = createTransport(basePath, config.remote, config.privacy !== undefined ? 'ax sync' : `ax sync from ${machine}`);
+    const { snapshot: local, scrubbed } = await exportLocalSnapshot(basePath, state, now, machine, config.privacy);
+    const remotePayload = await transport.pull();
+    const remote = remotePayload === undefined
+        ? undefined
+        : JSON.parse(decryptPayload(remotePayload, passphrase));
+    const base = await readSyncBase(basePath);
+    const localItems = toItems(local);
+    const remoteItems = remote === undefined ? new Map() : toItems(remote);
+    const merged = new Map();
+    const pulled = [];
+    const pushed = [];
+    const deletedLocally = [];
+    const conflicts = [];
+    for (const id of new Set([...localItems.keys(), ...remoteItems.keys()])) {
+        const mine = localItems.get(id);
+        const theirs = remoteItems.get(id);
+        const baseHash = base.hashes[id];
+        if (mine !== undefined && theirs !== undefined && mine.hash === theirs.hash) {
+            merged.set(id, mine);
+        }
+        else if (theirs === undefined) {
+            // Unknown to the remote: either new here, or deleted there since the last sync.
+            if (mine !== undefined && baseHash === mine.hash) {
+                deletedLocally.push(mine);
+            }
+            else if (mine !== undefined) {
+                merged.set(id, mine);
+                pushed.push(id);
+            }
+        }
+        else if (mine === undefined) {
+            // Deleted here since the last sync, unless the remote changed it meanwhile.
+            if (baseHash !== theirs.hash) {
+                merged.set(id, theirs);
+                pulled.push(theirs);
+            }
+        }
+        else if (mine.hash === baseHash) {
+            merged.set(id, theirs);
+            pulled.push(theirs);
+        }
+        else if (theirs.hash === baseHash) {
+            merged.set(id, mine);
+            pushed.push(id);
+        }
+        else {
+            const winner = Date.parse(theirs.updatedAt) > Date.parse(mine.updatedAt) ? 'remote' : 'local';
+            conflicts.push({ id, winner, localUpdatedAt: mine.updatedAt, remoteUpdatedAt: theirs.updatedAt, local: mine, remote: theirs });
+            merged.set(id, winner === 'remote' ? theirs : mine);
+            if (winner === 'remote') {
+                pulled.push(theirs);
+            }
+            else {
+                pushed.push(id);
+            }
+        }
+    }
+    const pushedDeletions = remote === undefined
+        ? []
+        : [...remoteItems.keys()].filter((id) => !merged.has(id) && !deletedLocally.some((item) => item.id === id));
+    for (const item of pulled) {
+        await applyItem(basePath, state, item);
+    }
+    for (const item of deletedLocally) {
+        await removeItem(basePath, state, item);
+    }
+    let conflictLogPath;
+    if (conflicts.length > 0) {
+        conflictLogPath = join(basePath, SYNC_DIR, 'conflicts', `conflicts-${now.getTime()}.json`);
+        await mkdir(dirname(conflictLogPath), { recursive: true });
+        await writeFile(conflictLogPath, `${JSON.stringify(conflicts, null, 2)}\n`, 'utf8');
+    }
+    const mergedSnapshot = {
+        schemaVersion: SYNC_SCHEMA_VERSION,
+        machine,
+        exportedAt: now.toISOString(),
+        memory: [...merged.values()].flatMap((item) => item.memory !== undefined ? [item.memory] : []),
+        files: [...merged.values()].flatMap((item) => item.file !== undefined ? [item.file] : []),
+    };
+    if (remote === undefined || pushed.length > 0 || pushedDeletions.length > 0) {
+        await transport.push(encryptPayload(JSON.stringify(mergedSnapshot), passphrase));
+    }
+    await writeSyncBase(basePath, {
+        syncedAt: now.toISOString(),
+        hashes: Object.fromEntries([...merged.values()].map((item) => [item.id, item.hash])),
+    });
+    return {
+        remote: transport.describe(),
+        pulled: pulled.map((item) => item.id),
+        pushed: [...pushed, ...pushedDeletions.map((id) => `-${id}`)],
+        deletedLocally: deletedLocally.map((item) => item.id),
+        conflicts: conflicts.map(({ id, winner, localUpdatedAt, remoteUpdatedAt }) => ({ id, winner, localUpdatedAt, remoteUpdatedAt })),
+        conflictLogPath,
+        ...(config.privacy !== undefined ? { scrubbed } : {}),
+        syncedAt: now.toISOString(),
+    };
+}
+export function encryptPayload(plaintext, passphrase) {
+    const salt = randomBytes(16);
+    const iv = randomBytes(12);
+    const cipher = createCipheriv('aes-256-gcm', scryptSync(passphrase, salt, 32), iv);
+    const data = Buffer.concat([cipher.update(plaintext, 'utf8'), cipher.final()]);
+    return JSON.stringify({
+        v: 1,
+        alg: 'aes-256-gcm',
+        kdf: 'scrypt',
+        salt: salt.toString('base64'),
+        iv: iv.toString('base64'),
+        tag: cipher.getAuthTag().toString('base64'),
+        data: data.toString('base64'),
+    });
+}
+export function decryptPayload(payload, passphrase) {
+    const envelope = JSON.parse(payload);
+    if (envelope.alg !== 'aes-256-gcm' || envelope.kdf !== 'scrypt') {
+        throw new Error('Unsupported sync payload encryption');
+    }
+    const key = scryptSync(passphrase, Buffer.from(envelope.salt ?? '', 'base64'), 32);
+    const decipher = createDecipheriv('aes-256-gcm', key, Buffer.from(envelope.iv ?? '', 'base64'));
+    decipher.setAuthTag(Buffer.from(envelope.tag ?? '', 'base64'));
+    try {
+        return Buffer.concat([decipher.update(Buffer.from(envelope.data ?? '', 'base64')), decipher.final()]).toString('utf8');
+    }
+    catch {
+        throw new Error('Cannot decrypt remote state: wrong passphrase or corrupted payload');
+    }
+}
+export async function readLastSyncedAt(basePath) {
+    const base = await readSyncBase(basePath);
+    return base.syncedAt.length > 0 ? base.syncedAt : undefined;
+}
+export function describeSyncRemote(remote) {
+    return remote.type === 'git'
+        ? `git ${remote.url}#${remote.branch ?? DEFAULT_SYNC_BRANCH}`
+        : s3ObjectUrl(remote);
+}
+async function exportLocalSnapshot(basePath, state, now, machine, privacy) {
+    // The shared copy is what gets hashed, so entries pulled back already rewritten don't read as local edits.
+    const filter = privacy !== undefined ? createSyncPrivacyFilter(privacy, localPrivacyContext(basePath)) : undefined;
+    let scrubbed = 0;
+    const memory = (await state.listMemory()).map((entry) => {
+        const value = filter !== undefined ? filter(entry.value) : entry.value;
+        if (filter !== undefined && JSON.stringify(value) !== JSON.stringify(entry.value)) {
+            scrubbed += 1;
+        }
+        return {
+            namespace: entry.namespace,
+            key: entry.key,
+            value,
+            updatedAt: privacy !== undefined ? generalizeTimestamp(entry.updatedAt) : entry.updatedAt,
+            ...(entry.agentId !== undefined ? { agentId: entry.agentId } : {}),
+            ...(entry.importance !== undefined ? { importance: entry.importance } : {}),
+        };
+    });
+    const root = join(basePath, AUTOMATOSX_DIR);
+    const files = [];
+    for (const path of await listSyncedFiles(root)) {
+        const absolutePath = join(root, path);
+        files.push({
+            path,
+            content: await readFile(absolutePath, 'utf8'),
+            updatedAt: (await stat(absolutePath)).mtime.toISOString(),
+        });
+    }
+    return { snapshot: { schemaVersion: SYNC_SCHEMA_VERSION, machine, exportedAt: now.toISOString(), memory, files }, scrubbed };
+}
+async function listSyncedFiles(root) {
+    const files = [];
+    const walk = async (dir, recursive) => {
+        let entries;
+        try {
+            entries = await readdir(dir, { withFileTypes: true });
+        }
+        catch {
+            return;
+        }
+        for (const entry of entries) {
+            const absolutePath = join(dir, entry.name);
+            if (entry.isDirectory() && recursive) {
+                await walk(absolutePath, true);
+            }
+            else if (entry.isFile()) {
+                files.push(relative(root, absolutePath).split(sep).join('/'));
+            }
+        }
+    };
+    // specs/ is synced whole; workflows/ only holds definitions at the top level,
+    // with run artifacts nested below, so only those top-level files are synced.
+    await walk(join(root, 'specs'), true);
+    await walk(join(root, 'workflows'), false);
+    return files
+        .filter((path) => !path.startsWith('workflows/') || WORKFLOW_EXTENSIONS.includes(extname(path)))
+        .sort();
+}
+function toItems(snapshot) {
+    const items = new Map();
+    for (const memory of snapshot.memory ?? []) {
+        const id = `memory:${memory.namespace ?? ''}:${memory.key}`;
+        items.set(id, { id, hash: hashValue(JSON.stringify(memory.value)), updatedAt: memory.updatedAt, memory });
+    }
+    for (const file of snapshot.files ?? []) {
+        if (!SYNCED_FILE_ROOTS.some((root) => file.path.startsWith(`${root}/`)) || file.path.split('/').includes('..')) {
+            continue;
+        }
+        const id = `file:${file.path}`;
+        items.set(id, { id, hash: hashValue(file.content), updatedAt: file.updatedAt, file });
+    }
+    return items;
+}
+async function applyItem(basePath, state, item) {
+    if (item.memory !== undefined) {
+        await state.storeMemory({
+            key: item.memory.key,
+            namespace: item.memory.namespace,
+            value: item.memory.value,
+            agentId: item.memory.agentId,
+            importance: item.memory.importance,
+        });
+    }
+    else if (item.file !== undefined) {
+        const target = join(basePath, AUTOMATOSX_DIR, item.file.path);
+        await mkdir(dirname(target), { recursive: true });
+        await writeFile(target, item.file.content, 'utf8');
+    }
+}
+async function removeItem(basePath, state, item) {
+    if (item.memory !== undefined) {
+        await state.deleteMemory(item.memory.key, item.memory.namespace);
+    }
+    else if (item.file !== undefined) {
+        await rm(join(basePath, AUTOMATOSX_DIR, item.file.path), { force: true });
+    }
+}
+async function readSyncBase(basePath) {
+    try {
+        return JSON.parse(await readFile(join(basePath, SYNC_DIR, 'base.json'), 'utf8'));
+    }
+    catch {
+        return { syncedAt: '', hashes: {} };
+    }
+}
+async function writeSyncBase(basePath, base) {
+    await mkdir(join(basePath, SYNC_DIR), { recursive: true });
+    await writeFile(join(basePath, SYNC_DIR, 'base.json'), `${JSON.stringify(base, null, 2)}\n`, 'utf8');
+}
+function createTransport(basePath, remote, commitMessage) {
+    if (remote.type === 'git') {
+        return createGitTransport(join(basePath, SYNC_DIR, 'git'), remote.url, remote.branch ?? DEFAULT_SYNC_BRANCH, commitMessage);
+    }
+    return createS3Transport(remote);
+}
+function createGitTransport(workDir, url, branch, commitMessage) {
+    const git = (args) => execFileAsync('git', args, { cwd: workDir, maxBuffer: 1024 * 1024 * 16 });
+    return {
+        describe: () => `git ${url}#${branch}`,
+        async pull() {
+            if (!existsSync(join(workDir, '.git'))) {
+                await mkdir(workDir, { recursive: true });
+                await git(['init', '-q']);
+                await git(['remote', 'add', 'origin', url]);
+            }
+            try {
+                await git(['fetch', '-q', 'origin', branch]);
+            }
+            catch {
+                // the branch does not exist yet; the first push creates it
+                return undefined;
+            }
+            await git(['checkout', '-q', '-B', branch, 'FETCH_HEAD']);
+            try {
+                return await readFile(join(workDir, SYNC_OBJECT_NAME), 'utf8');
+            }
+            catch {
+                return undefined;
+            }
+        },
+        async push(payload) {
+            await writeFile(join(workDir, SYNC_OBJECT_NAME), payload, 'utf8');
+            await git(['add', SYNC_OBJECT_NAME]);
+            await git(['commit', '-q', '-m', commitMessage]);
+            try {
+                await git(['push', '-q', 'origin', `HEAD:${branch}`]);
+            }
+            catch (error) {
+                const message = error instanceof Error ? error.message : String(error);
+                throw new Error(`git push failed (another machine may have synced meanwhile; re-run ax sync): ${message}`);
+            }
+        },
+    };
+}
+function createS3Transport(remote) {
+    const objectUrl = s3ObjectUrl(remote);
+    const regionArgs = remote.region !== undefined ? ['--region', remote.region] : [];
+    return {
+        describe: () => objectUrl,
+        async pull() {
+            try {
+                const { stdout } = await execFileAsync('aws', ['s3', 'cp', objectUrl, '-', ...regionArgs], { maxBuffer: 1024 * 1024 * 64 });
+                return stdout;
+            }
+            catch (error) {
+                const message = error instanceof Error ? error.message : String(error);
+                if (/NoSuchKey|Not Found|404|does not exist/i.test(message)) {
+                    return undefined;
+                }
+                throw new Error(`aws s3 cp failed: ${message}`);
+            }
+        },
+        async push(payload) {
+            const stagingPath = join(tmpdir(), `automatosx-sync-${process.pid}-${Date.now()}.enc`);
+            await writeFile(stagingPath, payload, 'utf8');
+            try {
+                await execFileAsync('aws', ['s3', 'cp', stagingPath, objectUrl, ...regionArgs]);
+            }
+            catch (error) {
+                const message = error instanceof Error ? error.message : String(error);
+                throw new Error(`aws s3 cp failed: ${message}`);
+            }
+            finally {
+                await rm(stagingPath, { force: true });
+            }
+        },
+    };
+}
+function s3ObjectUrl(remote) {
+    const prefix = remote.prefix !== undefined ? `${remote.prefix.replace(/\/+$/, '')}/` : '';
+    return `s3://${remote.bucket}/${prefix}${SYNC_OBJECT_NAME}`;
+}
+function hashValue(value) {
+    return createHash('sha256').update(value).digest('hex');
+}
+function asOptionalString(value) {
+    return typeof value === 'string' && value.length > 0 ? value : undefined;
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,1207 @@
+import { execFile } from 'node:child_process';
+import { readFile } from 'node:fs/promises';
+import { extname, join } from 'node:path';
+import { promisify } from 'node:util';
+import { goBuildConstraint } from './build-constraints.js';
+const execFileAsync = promisify(execFile);
+const SCRIPT_EXTENSIONS = new Set(['.ts', '.tsx', '.mts', '.cts', '.js', '.jsx', '.mjs', '.cjs']);
+const GO_EXTENSIONS = new Set(['.go']);
+export const C_SOURCE_EXTENSIONS = new Set(['.c', '.cc', '.cpp', '.cxx']);
+export const C_HEADER_EXTENSIONS = new Set(['.h', '.hh', '.hpp', '.hxx']);
+const JAVA_EXTENSIONS = new Set(['.java']);
+const KOTLIN_EXTENSIONS = new Set(['.kt', '.kts']);
+const RUST_EXTENSIONS = new Set(['.rs']);
+const PYTHON_EXTENSIONS = new Set(['.py', '.pyi']);
+const JVM_MODIFIERS = '(?:(?:public|protected|private|internal|abstract|final|static|sealed|non-sealed|open|data|inner|value|inline|enum|annotation|companion|strictfp|default|synchronized|native|transient|volatile|override|suspend|operator|infix|tailrec|external|const|lateinit|expect|actual)\\s+)*';
+const C_CONTROL_KEYWORDS = new Set(['if', 'for', 'while', 'switch', 'return', 'sizeof', 'do', 'else', 'case', 'goto']);
+const RUST_VISIBILITY = '(pub(?:\\s*\\([^)]*\\))?\\s+)?';
+const RUST_QUALIFIERS = '(?:(?:default|const|async|unsafe|extern(?:\\s+"[^"]*")?)\\s+)*';
+// `'a` and `'outer:` are lifetimes and loop labels, not char literals.
+const RUST_LIFETIME = /'[A-Za-z_]\w*(?![\w'])/g;
+const PYTHON_DECORATOR = /^\s*@\s*([\w.]+)/;
+const registeredExtractors = new Map();
+export function supportsStructuralDiff(path) {
+    const extension = extname(path).toLowerCase();
+    return isBuiltinExtension(extension) || registeredExtractors.has(extension);
+}
+/**
+ * Routes the extractor's extensions to it. Built-in languages cannot be
+ * overridden, and an extension belongs to the first extractor that claims it.
+ */
+export function registerDeclarationExtractor(extractor) {
+    for (const extension of extractor.extensions) {
+        if (isBuiltinExtension(extension)) {
+            throw new Error(`Extension ${extension} is handled by a built-in extractor`);
+        }
+        const existing = registeredExtractors.get(extension);
+        if (existing !== undefined && existing.language !== extractor.language) {
+            throw new Error(`Extension ${extension} is already handled by the ${existing.language} extractor`);
+        }
+    }
+    extractor.extensions.forEach((extension) => registeredExtractors.set(extension, extractor));
+}
+export function findDeclarationExtractor(path) {
+    return registeredExtractors.get(extname(path).toLowerCase());
+}
+function isBuiltinExtension(extension) {
+    return SCRIPT_EXTENSIONS.has(extension) || GO_EXTENSIONS.has(extension)
+        || C_SOURCE_EXTENSIONS.has(extension) || C_HEADER_EXTENSIONS.has(extension)
+        || JAVA_EXTENSIONS.has(extension) || KOTLIN_EXTENSIONS.has(extension)
+        || RUST_EXTENSIONS.has(extension) || PYTHON_EXTENSIONS.has(extension);
+}
+/**
+ * Lists top-level declarations, plus class members and Go methods, without a
+ * full parser: declarations are found by their leading keyword at brace depth
+ * zero and closed by brace matching. The body is the last `{...}` block.
+ */
+export function extractDeclarations(content, path) {
+    const extension = extname(path).toLowerCase();
+    const plugin = registeredExtractors.get(extension);
+    if (plugin !== undefined) {
+        return plugin.extract(content, path);
+    }
+    const go = GO_EXTENSIONS.has(extension);
+    const lines = content.split(/\r?\n/);
+    if (C_SOURCE_EXTENSIONS.has(extension) || C_HEADER_EXTENSIONS.has(extension)) {
+        return extractCDeclarations(lines, C_HEADER_EXTENSIONS.has(extension));
+    }
+    if (JAVA_EXTENSIONS.has(extension) || KOTLIN_EXTENSIONS.has(extension)) {
+        const declarations = [];
+        scanJvmBody(lines, stripStringsAndComments(lines.join('\n')).split('\n'), 0, lines.length, undefined, KOTLIN_EXTENSIONS.has(extension), declarations);
+        return declarations;
+    }
+    if (RUST_EXTENSIONS.has(extension)) {
+        const declarations = [];
+        scanRustBody(lines, stripStringsAndComments(lines.join('\n'), false, path).split('\n'), 0, lines.length, { public: true, implicit: false, functionsOnly: false }, path, declarations);
+        return declarations;
+    }
+    if (PYTHON_EXTENSIONS.has(extension)) {
+        const declarations = [];
+        const text = lines.join('\n');
+        scanPythonBody(stripStringsAndComments(text, true, path).split('\n'), stripStringsAndComments(text, false, path).split('\n'), 0, lines.length, undefined, declarations);
+        return declarations;
+    }
+    const declarations = [];
+    const usesCgo = go && lines.some((line) => /^\s*import\s+"C"\s*$/.test(line));
+    const build = go ? goBuildConstraint(content, path) : undefined;
+    let index = 0;
+    while (index < lines.length) {
+        const line = lines[index] ?? '';
+        const header = go ? matchGoHeader(line) : matchScriptHeader(line);
+        if (header === undefined) {
+            index += 1;
+            continue;
+        }
+        const end = findStatementEnd(lines, index);
+        const text = lines.slice(index, end + 1).join('\n');
+        const { signature, body } = splitBody(text, header.kind);
+        if (!go && header.kind === 'class' && body.length > 0) {
+            // Methods are diffed on their own, so the class body keeps only fields and the like.
+            const { members, rest } = extractClassMembers(body, header.name, header.exported, index);
+            declarations.push({ ...header, signature: normalize(signature), body: normalize(rest), line: index + 1, endLine: end + 1, ...componentAnnotations(header, signature) }, ...members);
+        }
+        else {
+            declarations.push({
+                ...header,
+                signature: normalize(signature),
+                body: normalize(body),
+                line: index + 1,
+                endLine: end + 1,
+                ...(go ? { ...goAnnotations(lines, index, text, header.kind, usesCgo), ...goTypeParams(text), ...goStructFields(text), ...goDoc(lines, index) } : componentAnnotations(header, text)),
+                ...(build !== undefined ? { build } : {}),
+            });
+        }
+        index = end + 1;
+    }
+    return declarations;
+}
+export function diffDeclarations(before, after) {
+    const key = (declaration) => `${declaration.kind === 'method' ? 'method' : 'top'}:${declaration.name}`;
+    const previous = new Map(before.map((declaration) => [key(declaration), declaration]));
+    const changes = [];
+    for (const declaration of after) {
+        const old = previous.get(key(declaration));
+        previous.delete(key(declaration));
+        const base = { kind: declaration.kind, name: declaration.name, exported: declaration.exported, line: declaration.line };
+        if (old === undefined) {
+            changes.push({ change: 'added', ...base, after: declaration.signature });
+        }
+        else if (old.signature !== declaration.signature || old.kind !== declaration.kind) {
+            changes.push({ change: 'signature-changed', ...base, before: old.signature, after: declaration.signature });
+        }
+        else if (old.body !== declaration.body) {
+            changes.push({ change: 'body-changed', ...base });
+        }
+    }
+    for (const old of previous.values()) {
+        changes.push({ change: 'removed', kind: old.kind, name: old.name, exported: old.exported, before: old.signature });
+    }
+    return changes;
+}
+export function diffFileStructure(before, after, path) {
+    return diffDeclarations(extractDeclarations(before, path), extractDeclarations(after, path));
+}
+/**
+ * Compares files changed between `base` and `head` (or the working tree when
+ * head is omitted). With a head, the old side is the merge base, matching
+ * `git diff base...head`.
+ */
+export async function collectStructuralDiff(request) {
+    const oldRef = request.head === undefined
+        ? request.base
+        : (await git(request.basePath, ['merge-base', request.base, request.head])).trim();
+    const range = request.head === undefined ? [request.base] : [`${request.base}...${request.head}`];
+    const nameStatus = await git(request.basePath, [
+        'diff', '--name-status', '--no-renames', ...range,
+        ...(request.paths !== undefined && request.paths.length > 0 ? ['--', ...request.paths] : []),
+    ]);
+    const files = [];
+    const skipped = [];
+    for (const line of nameStatus.split('\n')) {
+        const [statusCode, path] = line.split('\t');
+        if (statusCode === undefined || path === undefined || path.length === 0) {
+            continue;
+        }
+        if (!supportsStructuralDiff(path)) {
+            skipped.push(path);
+            continue;
+        }
+        const status = statusCode.startsWith('A') ? 'added' : statusCode.startsWith('D') ? 'deleted' : 'modified';
+        const before = status === 'added' ? '' : await git(request.basePath, ['show', `${oldRef}:${path}`]);
+        const after = status === 'deleted'
+            ? ''
+            : request.head === undefined
+                ? await readFile(join(request.basePath, path), 'utf8')
+                : await git(request.basePath, ['show', `${request.head}:${path}`]);
+        const changes = diffFileStructure(before, after, path);
+        if (changes.length > 0) {
+            files.push({ path, status, changes });
+        }
+    }
+    return {
+        base: request.base,
+        head: request.head,
+        files,
+        skipped,
+        summary: summarizeStructuralDiff(files),
+    };
+}
+// One line per file, suitable for changelog drafts and review prompts.
+export function summarizeStructuralDiff(files) {
+    if (files.length === 0) {
+        return 'No declaration-level changes.';
+    }
+    return files.map((file) => {
+        const parts = file.changes.map((change) => {
+            const label = `${change.kind} ${change.name}${change.exported ? ' (exported)' : ''}`;
+            switch (change.change) {
+                case 'added':
+                    return `added ${label}`;
+                case 'removed':
+                    return `removed ${label}`;
+                case 'signature-changed':
+                    return `changed signature of ${label}`;
+                default:
+                    return `changed body of ${label}`;
+            }
+        });
+        return `- ${file.path}: ${parts.join('; ')}`;
+    }).join('\n');
+}
+function matchScriptHeader(line) {
+    const exported = /^export\s/.test(line);
+    const rest = line.replace(/^export\s+(default\s+)?/, '').replace(/^declare\s+/, '');
+    const patterns = [
+        ['function', /^(?:async\s+)?function\s*\*?\s*([\w$]+)/],
+        ['class', /^(?:abstract\s+)?class\s+([\w$]+)/],
+        ['interface', /^interface\s+([\w$]+)/],
+        ['type', /^type\s+([\w$]+)/],
+        ['enum', /^(?:const\s+)?enum\s+([\w$]+)/],
+        ['variable', /^(?:const|let|var)\s+([\w$]+)/],
+    ];
+    for (const [kind, pattern] of patterns) {
+        const match = pattern.exec(rest);
+        if (match?.[1] !== undefined) {
+            const isArrow = kind === 'variable' && /=\s*(?:async\s+)?(?:\([^)]*\)|[\w$]+)\s*(?::[^=]+)?=>/.test(rest);
+            return { kind: isArrow ? 'function' : kind, name: match[1], exported };
+        }
+    }
+    return undefined;
+}
+// A capitalized function or const that renders JSX or wraps one in memo or
+// forwardRef, or a class extending Component, is a React component.
+function componentAnnotations(header, text) {
+    if (!/^[A-Z]/.test(header.name)) {
+        return {};
+    }
+    const component = header.kind === 'class'
+        ? /\bextends\s+(?:React\.)?(?:Pure)?Component\b/.test(text)
+        : (header.kind === 'function' || header.kind === 'variable')
+            && (/=\s*(?:React\.)?(?:memo|forwardRef)\s*[(<]/.test(text) || /<\/[\w.]*>|\/>|\bReact\.createElement\(/.test(text));
+    return component ? { annotations: ['component'] } : {};
+}
+function matchGoHeader(line) {
+    const method = /^func\s+\(\s*\w*\s*\*?\s*([\w]+)(?:\[[^\]]*\])?\s*\)\s*(\w+)/.exec(line);
+    if (method?.[1] !== undefined && method[2] !== undefined) {
+        return { kind: 'method', name: `${method[1]}.${method[2]}`, exported: /^[A-Z]/.test(method[2]) };
+    }
+    const patterns = [
+        ['function', /^func\s+(\w+)/],
+        ['interface', /^type\s+(\w+)(?:\[[^\]]*\])?\s+interface\b/],
+        ['type', /^type\s+(\w+)/],
+        ['variable', /^(?:var|const)\s+(\w+)/],
+    ];
+    for (const [kind, pattern] of patterns) {
+        const match = pattern.exec(line);
+        if (match?.[1] !== undefined) {
+            return { kind, name: match[1], exported: /^[A-Z]/.test(match[1]) };
+        }
+    }
+    return undefined;
+}
+// Embed patterns from the directive comments directly above a variable, and
+// the C names a declaration reaches through cgo.
+function goAnnotations(lines, index, text, kind, usesCgo) {
+    const embeds = [];
+    for (let above = index - 1; kind === 'variable' && above >= 0 && /^\s*\/\//.test(lines[above] ?? ''); above -= 1) {
+        const directive = /^\s*\/\/go:embed\s+(.+)$/.exec(lines[above] ?? '');
+        if (directive?.[1] !== undefined) {
+            embeds.unshift(...parseEmbedPatterns(directive[1]));
+        }
+    }
+    const cgo = usesCgo ? [...new Set([...stripStringsAndComments(text).matchAll(/(?<![\w.])C\.(\w+)/g)].map((match) => match[1]))] : [];
+    return {
+        ...(embeds.length > 0 ? { embeds } : {}),
+        ...(cgo.length > 0 ? { cgo } : {}),
+    };
+}
+// The comment block ending on the line above, as `go doc` shows it: `//` or
+// `/* */` markers dropped, directives (`//go:embed`, `//line`, `+build`) left out.
+function goDoc(lines, index) {
+    const doc = [];
+    let above = index - 1;
+    if (/\*\/\s*$/.test(lines[above] ?? '')) {
+        const block = [];
+        for (; above >= 0; above -= 1) {
+            const line = lines[above] ?? '';
+            block.unshift(line);
+            if (line.includes('/*')) {
+                break;
+            }
+        }
+        doc.push(...block.join('\n').replace(/^\s*\/\*+/, '').replace(/\*+\/\s*$/, '').split('\n').map((line) => line.replace(/^\s*\*?\s?/, '').trimEnd()));
+    }
+    else {
+        for (; above >= 0 && /^\s*\/\//.test(lines[above] ?? ''); above -= 1) {
+            const text = (lines[above] ?? '').replace(/^\s*\/\/ ?/, '');
+            if (!/^(?:go:|line |\s*\+build\b|export |extern |nolint\b)/.test(text)) {
+                doc.unshift(text.trimEnd());
+            }
+        }
+    }
+    const text = doc.join('\n').trim();
+    return text.length > 0 ? { doc: text } : {};
+}
+// `[K comparable, V any]` after a function or type name; `[T, U any]` shares
+// the constraint across names. Array types (`type Buf [64]byte`) have none.
+function goTypeParams(text) {
+    const code = stripStringsAndComments(text, false, '.go');
+    const open = /^(?:func|type)\s+\w+\[/.exec(code)?.[0].length;
+    if (open === undefined) {
+        return {};
+    }
+    const list = code.slice(open, findClosingBracket(code, open - 1));
+    const typeParams = [];
+    let pending = [];
+    for (const part of splitTopLevel(list, ',')) {
+        const match = /^(\w+)(?:\s+(.+))?$/s.exec(part.trim());
+        if (match === null) {
+            return {};
+        }
+        pending.push(match[1]);
+        if (match[2] !== undefined) {
+            typeParams.push(...pending.map((name) => ({ name, constraint: match[2].replace(/\s+/g, ' ').trim() })));
+            pending = [];
+        }
+    }
+    return typeParams.length > 0 && pending.length === 0 ? { typeParams } : {};
+}
+// Fields of `type Name struct {...}`, one per name: `X, Y int` is two fields.
+// Nested struct types stay whole in the type.
+function goStructFields(text) {
+    const code = stripStringsAndComments(text, false, '.go');
+    const header = /^type\s+\w+(?:\[[^\]]*\])?\s+struct\s*\{/.exec(code);
+    if (header === null) {
+        return {};
+    }
+    const open = header[0].length - 1;
+    const close = findClosingBracket(code, open);
+    // Comments are blanked by position, so the same slice of the source keeps tags and drops comments.
+    const withTags = stripStringsAndComments(text, true, '.go');
+    const fields = [];
+    let start = open + 1;
+    let depth = 0;
+    for (let index = open + 1; index <= close; index += 1) {
+        const char = code[index];
+        if (char === '{' || char === '(' || char === '[') {
+            depth += 1;
+        }
+        else if ((char === '}' || char === ')' || char === ']') && index !== close) {
+            depth -= 1;
+        }
+        if ((char === '\n' || char === ';' || index === close) && depth === 0) {
+            fields.push(...parseStructField(withTags.slice(start, index)));
+            start = index + 1;
+        }
+    }
+    return fields.length > 0 ? { fields } : {};
+}
+function parseStructField(entry) {
+    let rest = entry.trim();
+    let tags;
+    const tag = /(?:`([^`]*)`|"((?:[^"\\]|\\.)*)")\s*$/.exec(rest);
+  if (tag !== null) {
+    rest = rest.slice(0, tag.index).trim();
+    tags = Object.fromEntries([...(tag[1] ?? tag[2]).matchAll(/(\w[\w.-]*):"((?:[^"\\]|\\.)*)"/g)].map((match) => [match[1], match[2]]));
+  }
+  if (rest.length === 0) {
+    return [];
+  }
+  const withTags = tags !== undefined && Object.keys(tags).length > 0 ? { tags } : {};
+  if (/^\*?[\w.]+(?:\[.*\])?$/.test(rest)) {
+    return [{ name: rest.replace(/^\*/, '').replace(/\[.*$/, '').split('.').pop(), type: rest, ...withTags, embedded: true }];
+  }
+  const named = /^(\w+(?:\s*,\s*\w+)*)\s+(.+)$/s.exec(rest);
+  if (named === null) {
+    return [];
+  }
+  const type = named[2].replace(/\s+/g, ' ').trim();
+  return named[1].split(',').map((name) => ({ name: name.trim(), type, ...withTags }));
+}
+// Index of the bracket closing the one at `open`, or the end of the text.
+export function findClosingBracket(text, open) {
+  let depth = 0;
+  for (let index = open; index < text.length; index += 1) {
+    if (text[index] === '[' || text[index] === '(' || text[index] === '{') {
+      depth += 1;
+    }
+    else if (text[index] === ']' || text[index] === ')' || text[index] === '}') {
+      depth -= 1;
+      if (depth === 0) {
+        return index;
+      }
+    }
+  }
+  return text.length;
+}
+// Splits on the separator where no bracket is open.
+export function splitTopLevel(text, separator) {
+  const parts = [];
+  let depth = 0;
+  let start = 0;
+  for (let index = 0; index < text.length; index += 1) {
+    const char = text[index];
+    if (char === '[' || char === '(' || char === '{') {
+      depth += 1;
+    }
+    else if (char === ']' || char === ')' || char === '}') {
+      depth -= 1;
+    }
+    else if (char === separator && depth === 0) {
+      parts.push(text.slice(start, index));
+      start = index + 1;
+    }
+  }
+  parts.push(text.slice(start));
+  return parts.filter((part) => part.trim().length > 0);
+}
+// Space-separated patterns; Go also accepts double- or back-quoted ones with spaces.
+export function parseEmbedPatterns(text) {
+  return [...text.matchAll(/"((?:[^"\\]|\\.)*)"|`([^`]*)`|(\S+)/g)].map((match) => match[1] ?? match[2] ?? match[3]);
+}
+/**
+ * C and C++ declarations: functions (out-of-line `Class::method` definitions
+ * become methods), structs, unions, classes, enums, typedefs, globals, and
+ * `#define` macros. Namespace and `extern "C"` wrappers are looked through;
+ * members declared inside a class body are not listed separately. `static`
+ * marks internal linkage, and everything declared in a header is exported.
+ */
+function extractCDeclarations(lines, header) {
+    const code = stripStringsAndComments(lines.join('\n')).split('\n');
+    const declarations = [];
+    let lastEnd = -1;
+    let index = 0;
+    while (index < lines.length) {
+        const line = lines[index] ?? '';
+        const define = /^\s*#\s*define\s+(\w+)(\([^)]*\))?/.exec(line);
+        if (define?.[1] !== undefined) {
+            let end = index;
+            while (end < lines.length - 1 && (lines[end] ?? '').trimEnd().endsWith('\\')) {
+                end += 1;
+            }
+            const text = lines.slice(index, end + 1).join('\n');
+            declarations.push({
+                kind: 'macro',
+                name: define[1],
+                exported: header,
+                signature: normalize(define[0]),
+                body: normalize(text.slice(text.indexOf(define[0]) + define[0].length)),
+                line: index + 1,
+                endLine: end + 1,
+            });
+            index = end + 1;
+            continue;
+        }
+        const kind = matchCHeader(code[index] ?? '');
+        if (kind === undefined) {
+            index += 1;
+            continue;
+        }
+        // GNU style puts the return type on its own line above the name.
+        const previous = (code[index - 1] ?? '').trim();
+        const start = kind === 'function' && index > 0 && index > lastEnd + 1 && /^[\w\s*&:<>,]+$/.test(previous) && !/^(?:return|else|case)\b/.test(previous) ? index - 1 : index;
+        const end = findCStatementEnd(code, index, kind === 'function');
+        const text = lines.slice(start, end + 1).join('\n');
+        const described = describeCDeclaration(code.slice(start, end + 1).join('\n'), kind, header);
+        if (described !== undefined) {
+            const { signature, body } = splitBody(text, described.kind);
+            declarations.push({ ...described, signature: normalize(signature), body: normalize(body), line: start + 1, endLine: end + 1 });
+            lastEnd = end;
+        }
+        index = end + 1;
+    }
+    // A prototype followed by its definition in the same file is one function.
+    const defined = new Set(declarations.filter((declaration) => declaration.body.length > 0).map((declaration) => `${declaration.kind}:${declaration.name}`));
+    return declarations.filter((declaration) =>
+        !((declaration.kind === 'function' || declaration.kind === 'method') && declaration.body.length === 0 && defined.has(`${declaration.kind}:${declaration.name}`)));
+}
+/**
+ * Java and Kotlin declarations in lines [from, to): classes, records, objects,
+ * interfaces (annotation types included), enums, and Kotlin type aliases,
+ * with methods and constructors as `Class.method`; Kotlin files also have
+ * top-level functions and properties. Companion object members belong to the
+ * enclosing class. Java members are exported when public or protected
+ * (interface members always are), Kotlin ones unless private or internal.
+ * Returns the line ranges consumed, so a class body can leave its members out.
+ */
+function scanJvmBody(lines, code, from, to, container, kotlin, declarations) {
+    const consumed = [];
+    let index = from;
+    while (index < to) {
+        if ((code[index] ?? '').trim().length === 0) {
+            index += 1;
+            continue;
+        }
+        const start = index;
+        const annotated = takeJvmAnnotations(code, index, to);
+        index = annotated.index;
+        const text = (code[index] ?? '').slice(annotated.column);
+        const end = Math.min(findJvmStatementEnd(code, index, kotlin), to - 1);
+        const header = matchJvmHeader(text, container, kotlin);
+        // Properties stay part of their class body, like Java fields.
+        if (header === undefined || (header.kind === 'variable' && container !== undefined)) {
+            index = end + 1;
+            continue;
+        }
+        const exported = (container === undefined || container.exported) && (kotlin
+            ? !/\b(?:private|internal)\b/.test(header.modifiers)
+            : /\b(?:public|protected)\b/.test(header.modifiers) || (container?.isInterface === true && !/\bprivate\b/.test(header.modifiers)));
+        const extras = annotated.names.length > 0 ? { annotations: annotated.names } : {};
+        if (header.kind === 'class' || header.kind === 'interface' || header.kind === 'enum') {
+            const bodyStart = code.slice(index, end + 1).findIndex((line) => line.includes('{'));
+            const companion = header.name === undefined;
+            const inner = companion && container !== undefined
+                ? container
+                : { name: header.name ?? 'Companion', exported, isInterface: header.kind === 'interface' };
+            // The type is listed ahead of its members; its slot is filled once they are known.
+            const slot = declarations.length;
+            if (!companion) {
+                declarations.push({ kind: header.kind, name: header.name, exported, signature: '', body: '', line: index + 1, endLine: end + 1, ...extras });
+            }
+            const members = bodyStart === -1 ? [] : scanJvmBody(lines, code, index + bodyStart + 1, end, inner, kotlin, declarations);
+            if (!companion) {
+                const own = lines.slice(start, end + 1).filter((_, offset) => !members.some(([first, last]) => start + offset >= first && start + offset <= last));
+                const { signature, body } = splitBody(own.join('\n'), header.kind);
+                declarations[slot] = { ...declarations[slot], signature: normalize(signature), body: normalize(body) };
+            }
+        }
+        else {
+            const text = lines.slice(start, end + 1).join('\n');
+            const { signature, body } = header.kind === 'function' && kotlin ? splitKotlinFunction(text) : splitBody(text, header.kind);
+            declarations.push({
+                kind: header.kind === 'function' && container !== undefined ? 'method' : header.kind,
+                name: header.kind === 'function' && container !== undefined ? `${container.name}.${header.name}` : header.name,
+                exported,
+                signature: normalize(signature),
+                body: normalize(body),
+                line: index + 1,
+                endLine: end + 1,
+                ...extras,
+            });
+        }
+        consumed.push([start, end]);
+        index = end + 1;
+    }
+    return consumed;
+}
+// Skips leading `@Name` and `@Name(...)` annotations, across lines if needed.
+function takeJvmAnnotations(code, start, to) {
+    const names = [];
+    let index = start;
+    let column = (code[index] ?? '').search(/\S/);
+    while (index < to) {
+        const rest = (code[index] ?? '').slice(column);
+        const annotation = /^@(?!interface\b)([\w.]+)\s*/.exec(rest);
+        if (annotation === null) {
+            break;
+        }
+        names.push(annotation[1].slice(annotation[1].lastIndexOf('.') + 1));
+        column += annotation[0].length;
+        if ((code[index] ?? '')[column] === '(') {
+            let depth = 0;
+            scan: for (; index < to; index += 1, column = 0) {
+                const line = code[index] ?? '';
+                for (; column < line.length; column += 1) {
+                    depth += line[column] === '(' ? 1 : line[column] === ')' ? -1 : 0;
+                    if (depth === 0) {
+                        column += 1;
+                        break scan;
+                    }
+                }
+            }
+        }
+        const next = (code[index] ?? '').slice(column).search(/\S/);
+        if (next !== -1) {
+            column += next;
+            continue;
+        }
+        do {
+            index += 1;
+        } while (index < to && (code[index] ?? '').trim().length === 0);
+        column = Math.max(0, (code[index] ?? '').search(/\S/));
+    }
+    return { names, index, column };
+}
+function matchJvmHeader(text, container, kotlin) {
+    const type = new RegExp(`^(${JVM_MODIFIERS}(?:fun\\s+(?=interface\\b))?)(class|interface|enum|record|@interface|object)\\b\\s*([A-Za-z_$][\\w$]*)?`).exec(text);
+    if (type !== null) {
+        const [, modifiers = '', keyword, name] = type;
+        if (name === undefined && !(keyword === 'object' && /\bcompanion\b/.test(modifiers))) {
+            return undefined;
+        }
+        const kind = keyword === 'interface' || keyword === '@interface' ? 'interface' : keyword === 'enum' || /\benum\b/.test(modifiers) ? 'enum' : 'class';
+        return { kind, modifiers, ...(name !== undefined ? { name } : {}) };
+    }
+    if (kotlin) {
+        const fun = new RegExp(`^(${JVM_MODIFIERS})fun\\s+(?:<[^>]*>\\s*)?(?:[\\w.<>?, ]+\\.)?([A-Za-z_]\\w*|\`[^\`]+\`)\\s*\\(`).exec(text);
+        if (fun !== null) {
+            return { kind: 'function', name: fun[2], modifiers: fun[1] ?? '' };
+        }
+        const constructor = new RegExp(`^(${JVM_MODIFIERS})constructor\\s*\\(`).exec(text);
+        if (constructor !== null && container !== undefined) {
+            return { kind: 'function', name: 'constructor', modifiers: constructor[1] ?? '' };
+        }
+        const alias = new RegExp(`^(${JVM_MODIFIERS})typealias\\s+(\\w+)`).exec(text);
+        if (alias !== null) {
+            return { kind: 'type', name: alias[2], modifiers: alias[1] ?? '' };
+        }
+        const property = new RegExp(`^(${JVM_MODIFIERS})(?:val|var)\\s+(?:<[^>]*>\\s*)?(?:[\\w.<>?]+\\.)?([A-Za-z_]\\w*)`).exec(text);
+        return property === null ? undefined : { kind: 'variable', name: property[2], modifiers: property[1] ?? '' };
+    }
+    if (container === undefined) {
+        return undefined;
+    }
+    const constructor = new RegExp(`^(${JVM_MODIFIERS})(?:<[^>]*>\\s*)?([A-Za-z_$][\\w$]*)\\s*\\(`).exec(text);
+    if (constructor !== null && constructor[2] === container.name) {
+        return { kind: 'function', name: container.name, modifiers: constructor[1] ?? '' };
+    }
+    const method = new RegExp(`^(${JVM_MODIFIERS})(?:<[^>]*>\\s*)?[\\w$.<>\\[\\]?, ]+?\\s+([A-Za-z_$][\\w$]*)\\s*\\(`).exec(text);
+    return method === null || /^(?:return|new|throw|else)\b/.test(text) ? undefined : { kind: 'function', name: method[2], modifiers: method[1] ?? '' };
+}
+// `fun f(x: Int) = x * 2` keeps its expression as the body, like a block body.
+function splitKotlinFunction(text) {
+    const stripped = stripStringsAndComments(text);
+    let depth = 0;
+    for (let index = 0; index < stripped.length; index += 1) {
+        const char = stripped[index];
+        if (char === '(' || char === '[' || char === '<') {
+            depth += 1;
+        }
+        else if (char === ')' || char === ']' || (char === '>' && stripped[index - 1] !== '-')) {
+            depth -= 1;
+        }
+        else if (depth === 0 && char === '{') {
+            break;
+        }
+        else if (depth === 0 && char === '=' && stripped[index + 1] !== '=') {
+            return { signature: text.slice(0, index), body: text.slice(index + 1) };
+        }
+    }
+    return splitBody(text, 'function');
+}
+// A declaration ends where its body closes, at a `;` outside brackets, or, in
+// Kotlin, at a line end that nothing continues. Expects blanked lines.
+function findJvmStatementEnd(code, start, kotlin) {
+    let depth = 0;
+    let opened = false;
+    for (let index = start; index < code.length; index += 1) {
+        for (const char of code[index] ?? '') {
+            if (char === '{' || char === '(' || char === '[') {
+                opened ||= char === '{' && depth === 0;
+                depth += 1;
+            }
+            else if (char === '}' || char === ')' || char === ']') {
+                depth -= 1;
+                if (depth <= 0 && char === '}' && opened) {
+                    return index;
+                }
+            }
+            else if (char === ';' && depth === 0) {
+                return index;
+            }
+        }
+        const trimmed = (code[index] ?? '').trim();
+        const next = (code[index + 1] ?? '').trim();
+        const continues = /(?:[=,(.:]|->)$/.test(trimmed) || /^(?:[{.:=?]|->|where\b|get\b|set\b|private\s+set\b)/.test(next);
+        if (kotlin && depth <= 0 && !opened && trimmed.length > 0 && !continues) {
+            return index;
+        }
+    }
+    return code.length - 1;
+}
+/**
+ * Rust items in lines [from, to): functions, structs and unions (as types),
+ * enums, traits (as interfaces), type aliases, consts and statics, and
+ * `macro_rules!` macros, looking through `mod { ... }` and `extern { ... }`
+ * blocks. Functions in trait and impl blocks are methods named
+ * `Type.method`, where the type is the impl's self type with generics
+ * dropped. Items are exported when plainly `pub` (not `pub(crate)`) outside
+ * private modules; macros when `#[macro_export]`. Returns the line ranges
+ * consumed, so a trait body can leave its methods out.
+ */
+function scanRustBody(lines, code, from, to, scope, path, declarations) {
+    const consumed = [];
+    let index = from;
+    while (index < to) {
+        if ((code[index] ?? '').trim().length === 0) {
+            index += 1;
+            continue;
+        }
+        const start = index;
+        const attributed = takeRustAttributes(code, index, to);
+        index = attributed.index;
+        const text = (code[index] ?? '').slice(attributed.column);
+        const end = Math.min(findJvmStatementEnd(code, index, false), to - 1);
+        const header = matchRustHeader(text);
+        if (header === undefined || (scope.functionsOnly && header.kind !== 'function')) {
+            index = end + 1;
+            continue;
+        }
+        const bodyStart = code.slice(index, end + 1).findIndex((line) => line.includes('{'));
+        if (header.block !== undefined) {
+            if (bodyStart !== -1) {
+                const inner = header.block === 'mod'
+                    ? { public: scope.public && header.visibility === 'pub', implicit: false, functionsOnly: false }
+                    : header.block === 'extern'
+                        ? { public: scope.public, implicit: false, functionsOnly: true }
+                        : rustImplScope(code.slice(index, index + bodyStart + 1).join(' '));
+                scanRustBody(lines, code, index + bodyStart + 1, end, inner, path, declarations);
+            }
+            index = end + 1;
+            continue;
+        }
+        const exported = header.kind === 'macro'
+            ? attributed.names.includes('macro_export')
+            : scope.implicit ? scope.public : scope.public && header.visibility === 'pub';
+        const extras = attributed.names.length > 0 ? { annotations: attributed.names } : {};
+        if (header.kind === 'interface') {
+            // The trait is listed ahead of its methods; its slot is filled once they are known.
+            const slot = declarations.length;
+            declarations.push({ kind: 'interface', name: header.name, exported, signature: '', body: '', line: index + 1, endLine: end + 1, ...extras });
+            const members = bodyStart === -1 ? [] : scanRustBody(lines, code, index + bodyStart + 1, end, { owner: header.name, public: exported, implicit: true, functionsOnly: true }, path, declarations);
+            const own = lines.slice(start, end + 1).filter((_, offset) => !members.some(([first, last]) => start + offset >= first && start + offset <= last));
+            declarations[slot] = { ...declarations[slot], signature: normalize(own.join('\n')) };
+        }
+        else {
+            const { signature, body } = splitBody(lines.slice(start, end + 1).join('\n'), header.kind, path);
+            declarations.push({
+                kind: header.kind === 'function' && scope.owner !== undefined ? 'method' : header.kind,
+                name: header.kind === 'function' && scope.owner !== undefined ? `${scope.owner}.${header.name}` : header.name,
+                exported,
+                signature: normalize(signature),
+                body: normalize(body),
+                line: index + 1,
+                endLine: end + 1,
+                ...extras,
+            });
+        }
+        consumed.push([start, end]);
+        index = end + 1;
+    }
+    return consumed;
+}
+function matchRustHeader(text) {
+    const fn = new RegExp(`^${RUST_VISIBILITY}${RUST_QUALIFIERS}fn\\s+([A-Za-z_]\\w*)`).exec(text);
+    if (fn !== null) {
+        return { kind: 'function', name: fn[2], visibility: (fn[1] ?? '').trim() };
+    }
+    const item = new RegExp(`^${RUST_VISIBILITY}(?:unsafe\\s+)?(?:auto\\s+)?(trait|struct|union|enum|type|const|static(?:\\s+mut)?)\\s+([A-Za-z_]\\w*)`).exec(text);
+    if (item !== null && item[3] !== '_') {
+        const keyword = item[2];
+        const kind = keyword === 'trait' ? 'interface' : keyword === 'enum' ? 'enum' : /^(?:const|static)/.test(keyword) ? 'variable' : 'type';
+        return { kind, name: item[3], visibility: (item[1] ?? '').trim() };
+    }
+    const macro = /^macro_rules!\s*([A-Za-z_]\w*)/.exec(text);
+    if (macro !== null) {
+        return { kind: 'macro', name: macro[1], visibility: '' };
+    }
+    const block = new RegExp(`^${RUST_VISIBILITY}(?:unsafe\\s+)?(?:(mod)\\s+\\w+\\s*(?:\\{|$)|(impl)\\b|(extern)(?:\\s+"[^"]*")?\\s*(?:\\{|$))`).exec(text);
+    if (block !== null) {
+        return { block: (block[2] ?? block[3] ?? block[4]), visibility: (block[1] ?? '').trim() };
+    }
+    return undefined;
+}
+// `impl<T: Ord> fmt::Display for Wrapper<T> where ...` holds methods of `Wrapper`, as public as the trait.
+function rustImplScope(header) {
+    let text = header.slice(0, header.indexOf('{') === -1 ? undefined : header.indexOf('{'));
+    for (let previous = ''; previous !== text;) {
+        previous = text;
+        text = text.replace(/<[^<>]*>/g, ' ');
+    }
+    const parts = text.replace(/\bwhere\b[\s\S]*$/, '').split(/\bfor\b/);
+    const owner = /([A-Za-z_]\w*)\s*$/.exec((parts.pop() ?? '').replace(/[^\w\s]/g, ' '))?.[1] ?? 'impl';
+    return { owner, public: true, implicit: parts.length > 0, functionsOnly: true };
+}
+// Skips leading `#[...]` outer and `#![...]` inner attributes, across lines if
+// needed. Outer attribute names are collected by their last path segment.
+function takeRustAttributes(code, start, to) {
+    const names = [];
+    let index = start;
+    let column = Math.max(0, (code[index] ?? '').search(/\S/));
+    while (index < to) {
+        const attribute = /^#(!?)\[\s*([\w:]+)/.exec((code[index] ?? '').slice(column));
+        if (attribute === null) {
+            break;
+        }
+        if (attribute[1] === '') {
+            names.push(attribute[2].slice(attribute[2].lastIndexOf(':') + 1));
+        }
+        column += 1 + attribute[1].length;
+        let depth = 0;
+        scan: for (; index < to; index += 1, column = 0) {
+            const line = code[index] ?? '';
+            for (; column < line.length; column += 1) {
+                depth += line[column] === '[' ? 1 : line[column] === ']' ? -1 : 0;
+                if (depth === 0) {
+                    column += 1;
+                    break scan;
+                }
+            }
+        }
+        const next = (code[index] ?? '').slice(column).search(/\S/);
+        if (next !== -1) {
+            column += next;
+            continue;
+        }
+        do {
+            index += 1;
+        } while (index < to && (code[index] ?? '').trim().length === 0);
+        column = Math.max(0, (code[index] ?? '').search(/\S/));
+    }
+    return { names, index, column };
+}
+/**
+ * Python declarations in lines [from, to): functions, classes with their
+ * methods as `Class.method`, module-level variables, and `type` aliases.
+ * A block ends where the indentation drops back to its header's. Decorators
+ * become annotations, and a `@dataclass` class keeps its fields in its
+ * signature since they make up its constructor. Names starting with `_` are
+ * not exported, dunder methods aside. `clean` has comments blanked and `code`
+ * strings too. Returns the line ranges consumed, so a class body can leave
+ * its methods out.
+ */
+function scanPythonBody(clean, code, from, to, owner, declarations) {
+    const consumed = [];
+    let index = from;
+    while (index < to) {
+        if ((code[index] ?? '').trim().length === 0) {
+            index += 1;
+            continue;
+        }
+        const start = index;
+        const decorators = [];
+        while (index < to && PYTHON_DECORATOR.test(code[index] ?? '')) {
+            const name = PYTHON_DECORATOR.exec(code[index] ?? '')[1];
+            decorators.push(name.slice(name.lastIndexOf('.') + 1));
+            index = findPythonLineEnd(code, index, to) + 1;
+        }
+        const headerEnd = findPythonLineEnd(code, index, to);
+        const end = findPythonBlockEnd(code, headerEnd, to, (code[index] ?? '').search(/\S/));
+        const header = matchPythonHeader((code[index] ?? '').trim(), owner);
+        if (header === undefined) {
+            index = end + 1;
+            continue;
+        }
+        const exported = (owner?.exported ?? true) && (!header.name.startsWith('_') || /^__\w+__$/.test(header.name));
+        const extras = decorators.length > 0 ? { annotations: decorators } : {};
+        // Offset of the header line within the declaration's text, where the split search starts.
+        const offset = clean.slice(start, index).reduce((length, line) => length + line.length + 1, 0);
+        if (header.kind === 'class') {
+            const slot = declarations.length;
+            declarations.push({ kind: 'class', name: header.name, exported, signature: '', body: '', line: index + 1, endLine: end + 1, ...extras });
+            const members = scanPythonBody(clean, code, headerEnd + 1, end + 1, { name: header.name, exported }, declarations);
+            const own = (line) => !members.some(([first, last]) => line >= first && line <= last);
+            const text = clean.slice(start, end + 1).map((line, at) => own(start + at) ? line : '').join('\n');
+            const colon = findPythonSplit(code.slice(start, end + 1).join('\n'), offset, ':');
+            const fields = decorators.includes('dataclass')
+                ? code.slice(headerEnd + 1, end + 1).flatMap((line, at) => own(headerEnd + 1 + at) && /^\s*[A-Za-z_]\w*\s*:(?!=)/.test(line) ? [headerEnd + 1 + at] : [])
+                : [];
+            const body = text.slice(colon + 1).split('\n').map((line, at) => fields.includes(headerEnd + at) ? '' : line);
+            declarations[slot] = {
+                ...declarations[slot],
+                signature: normalize([text.slice(0, colon), ...fields.map((line) => clean[line] ?? '')].join('\n'), false),
+                body: normalize(body.join('\n'), false),
+            };
+        }
+        else {
+            const text = clean.slice(start, end + 1).join('\n');
+            const split = findPythonSplit(code.slice(start, end + 1).join('\n'), offset, header.kind === 'function' ? ':' : '=');
+            declarations.push({
+                kind: header.kind === 'function' && owner !== undefined ? 'method' : header.kind,
+                name: header.kind === 'function' && owner !== undefined ? `${owner.name}.${header.name}` : header.name,
+                exported,
+                signature: normalize(split === -1 ? text : text.slice(0, split), false),
+                body: normalize(split === -1 ? '' : text.slice(split + 1), false),
+                line: index + 1,
+                endLine: end + 1,
+                ...extras,
+            });
+        }
+        consumed.push([start, end]);
+        index = end + 1;
+    }
+    return consumed;
+}
+function matchPythonHeader(text, owner) {
+    const definition = /^(?:async\s+)?(def|class)\s+([A-Za-z_]\w*)/.exec(text);
+    if (definition !== null) {
+        return { kind: definition[1] === 'def' ? 'function' : 'class', name: definition[2] };
+    }
+    // Class attributes stay part of the class body.
+    if (owner !== undefined) {
+        return undefined;
+    }
+    const alias = /^type\s+([A-Za-z_]\w*)\s*(?:\[[^\]]*\]\s*)?=/.exec(text);
+    if (alias !== null) {
+        return { kind: 'type', name: alias[1] };
+    }
+    const variable = /^([A-Za-z_]\w*)\s*(?::[^=]+)?=(?!=)/.exec(text);
+    return variable === null ? undefined : { kind: 'variable', name: variable[1] };
+}
+// Last line of the logical line starting at `start`: open brackets and a trailing backslash continue it.
+function findPythonLineEnd(code, start, to) {
+    let depth = 0;
+    for (let index = start; index < to; index += 1) {
+        const line = code[index] ?? '';
+        for (const char of line) {
+            depth += '([{'.includes(char) ? 1 : ')]}'.includes(char) ? -1 : 0;
+        }
+        if (depth <= 0 && !/\\\s*$/.test(line)) {
+            return index;
+        }
+    }
+    return to - 1;
+}
+// Last line of the block after a header ending at `headerEnd`: the lines indented deeper than the header.
+function findPythonBlockEnd(code, headerEnd, to, indent) {
+    let end = headerEnd;
+    for (let index = headerEnd + 1; index < to; index += 1) {
+        const line = code[index] ?? '';
+        if (line.trim().length === 0) {
+            continue;
+        }
+        if (line.search(/\S/) <= indent) {
+            break;
+        }
+        end = index;
+    }
+    return end;
+}
+// Offset of the `:` ending a header, or the `=` of an assignment, outside brackets.
+function findPythonSplit(code, from, separator) {
+    let depth = 0;
+    for (let index = from; index < code.length; index += 1) {
+        const char = code[index] ?? '';
+        if ('([{'.includes(char)) {
+            depth += 1;
+        }
+        else if (')]}'.includes(char)) {
+            depth -= 1;
+        }
+        else if (depth === 0 && char === separator && code[index + 1] !== '=' && (separator === ':' || !'=!<>:'.includes(code[index - 1] ?? ''))) {
+            return index;
+        }
+    }
+    return -1;
+}
+function matchCHeader(line) {
+    const trimmed = line.trim().replace(/^template\s*<[^>]*>\s*/, '');
+    if (trimmed.length === 0 || /^(?:[#}]|namespace\b|extern\s+"|using\b|(?:public|private|protected)\s*:)/.test(trimmed)) {
+        return undefined;
+    }
+    if (/^typedef\b/.test(trimmed)) {
+        return 'type';
+    }
+    const aggregate = /^(?:(?:static|extern|const|volatile|constexpr|inline)\s+)*(struct|union|class|enum)\b(?:\s+class\b)?(?:\s+\w+)*\s*(?::[^{;(]*)?(?:\{|$)/.exec(trimmed);
+    if (aggregate?.[1] !== undefined) {
+        return aggregate[1] === 'class' ? 'class' : aggregate[1] === 'enum' ? 'enum' : 'type';
+    }
+    if (/\boperator\b/.test(trimmed)) {
+        return 'function';
+    }
+    const call = /^([^=;(]*?)([A-Za-z_~][\w~]*(?:::~?[A-Za-z_]\w*)*)\s*\(/.exec(trimmed);
+    if (call?.[2] !== undefined) {
+        const firstWord = /^\w+/.exec(trimmed)?.[0] ?? '';
+        return C_CONTROL_KEYWORDS.has(firstWord) ? undefined : 'function';
+    }
+    return /^[\w:<>,\s*&]+?[\s*&]\**\s*[A-Za-z_]\w*\s*(?:\[[^\]]*\]\s*)*(?:=|;)/.test(trimmed) && !/^return\b/.test(trimmed)
+        ? 'variable'
+        : undefined;
+}
+function describeCDeclaration(text, kind, header) {
+    const exported = header || !/^\s*(?:template\s*<[^>]*>\s*)?(?:\w+\s+)*?static\b/.test(text);
+    if (kind === 'type' && /^\s*typedef\b/.test(text)) {
+        // typedef int (*handler_fn)(int); names the pointer; otherwise the last identifier wins.
+        const pointer = /\(\s*\*\s*(\w+)\s*\)\s*\(/.exec(text);
+        const name = pointer?.[1] ?? /(\w+)\s*(?:\[[^\]]*\]\s*)*;\s*$/.exec(text)?.[1];
+        return name === undefined ? undefined : { kind, name, exported: header };
+    }
+    if (kind === 'type' || kind === 'class' || kind === 'enum') {
+        const name = /\b(?:struct|union|class|enum)\b(?:\s+class\b)?\s+(?:\w+\s+)*?(\w+)\s*(?::[^{]*)?\{/.exec(text)?.[1];
+        // Forward declarations carry no body and are not listed.
+        return name === undefined ? undefined : { kind, name, exported: header };
+    }
+    if (kind === 'function') {
+        const match = /((?:\w+::)*operator\s*(?:\(\)|[^\s(]+))\s*\(/.exec(text) ?? /([A-Za-z_~][\w~]*(?:::~?[A-Za-z_]\w*)*)\s*\(/.exec(text.replace(/^\s*template\s*<[^>]*>\s*/, ''));
+        const before = match === null ? '' : text.slice(0, text.indexOf(match[0])).trim();
+        const qualified = match?.[1];
+        if (qualified === undefined || (before.length === 0 && !qualified.includes('::') && !/\{/.test(text))) {
+            // A bare call such as a macro invocation at file scope.
+            return undefined;
+        }
+        const parts = qualified.split('::');
+        return parts.length > 1
+            ? { kind: 'method', name: `${parts[parts.length - 2]}.${parts[parts.length - 1]}`, exported }
+            : { kind, name: qualified, exported };
+    }
+    const name = /([A-Za-z_]\w*)\s*(?:\[[^\]]*\]\s*)*(?:=|;)/.exec(text)?.[1];
+    return name === undefined ? undefined : { kind: 'variable', name, exported };
+}
+// Functions end with their closing brace; everything else runs to the `;` at
+// depth zero. Expects lines with strings and comments already blanked.
+function findCStatementEnd(code, start, isFunction) {
+    let depth = 0;
+    for (let index = start; index < code.length; index += 1) {
+        for (const char of code[index] ?? '') {
+            if (char === '{' || char === '(' || char === '[') {
+                depth += 1;
+            }
+            else if (char === '}' || char === ')' || char === ']') {
+                depth -= 1;
+                if (depth === 0 && char === '}' && isFunction) {
+                    return index;
+                }
+            }
+            else if (char === ';' && depth === 0) {
+                return index;
+            }
+        }
+    }
+    return code.length - 1;
+}
+function extractClassMembers(body, className, exported, classIndex) {
+    const lines = body.split('\n');
+    const members = [];
+    const rest = [lines[0] ?? ''];
+    let index = 1;
+    while (index < lines.length - 1) {
+        const line = lines[index] ?? '';
+        const match = /^\s+(?:(?:public|private|protected|static|async|readonly|override|abstract|get|set)\s+)*\*?\s*(#?[\w$]+)\s*(?:<[^>]*>)?\s*\(/.exec(line);
+        if (match?.[1] === undefined) {
+            rest.push(line);
+            index += 1;
+            continue;
+        }
+        const end = Math.min(findStatementEnd(lines, index), lines.length - 2);
+        const { signature, body: memberBody } = splitBody(lines.slice(index, end + 1).join('\n'), 'method');
+        members.push({
+            kind: 'method',
+            name: `${className}.${match[1]}`,
+            exported: exported && !/\b(private|protected)\b|#/.test(line),
+            signature: normalize(signature),
+            body: normalize(memberBody),
+            line: classIndex + index + 1,
+            endLine: classIndex + end + 1,
+        });
+        index = end + 1;
+    }
+    rest.push(...lines.slice(Math.max(index, 1)));
+    return { members, rest: rest.join('\n') };
+}
+// A statement ends on the first line where brackets are balanced and the line
+// does not continue onto the next (trailing operator or leading `|`/`.`).
+function findStatementEnd(lines, start) {
+    let depth = 0;
+    for (let index = start; index < lines.length; index += 1) {
+        const line = stripStringsAndComments(lines[index] ?? '');
+        for (const char of line) {
+            if (char === '{' || char === '(' || char === '[') {
+                depth += 1;
+            }
+            else if (char === '}' || char === ')' || char === ']') {
+                depth -= 1;
+            }
+        }
+        const trimmed = line.trim();
+        const next = (lines[index + 1] ?? '').trim();
+        const continues = /(?:[=|&,(+\-*/?:]|=>)$/.test(trimmed) || /^[|&.?:]/.test(next) || /^(?:extends|implements)\b/.test(next);
+        if (depth <= 0 && trimmed.length > 0 && !continues) {
+            return index;
+        }
+    }
+    return lines.length - 1;
+}
+function splitBody(text, kind, path = '') {
+    // Interfaces, type aliases, and enums are all signature: any change alters the API.
+    if (kind === 'interface' || kind === 'type' || kind === 'enum') {
+        return { signature: text, body: '' };
+    }
+    const stripped = stripStringsAndComments(text, false, path);
+    const close = stripped.trimEnd().replace(/;$/, '').trimEnd().length - 1;
+    if (stripped[close] !== '}') {
+        const arrow = stripped.indexOf('=>');
+        if (kind === 'function' && arrow !== -1) {
+            return { signature: text.slice(0, arrow + 2), body: text.slice(arrow + 2) };
+        }
+        const assign = stripped.search(/[^=!<>]=[^=>]/);
+        return assign === -1 || kind !== 'variable'
+            ? { signature: text, body: '' }
+            : { signature: text.slice(0, assign + 1), body: text.slice(assign + 2) };
+    }
+    let depth = 0;
+    for (let index = close; index >= 0; index -= 1) {
+        if (stripped[index] === '}') {
+            depth += 1;
+        }
+        else if (stripped[index] === '{') {
+            depth -= 1;
+            if (depth === 0) {
+                return { signature: text.slice(0, index), body: text.slice(index, close + 1) };
+            }
+        }
+    }
+    return { signature: text, body: '' };
+}
+// Blanks out string contents and comments so brackets inside them are ignored;
+// positions are preserved so offsets still index the original text. With
+// keepStrings, only comments are blanked. The path picks language rules:
+// Rust lifetimes are blanked too, and Python has `#` comments and
+// triple-quoted strings but no `//`, block comments, or regex literals.
+export function stripStringsAndComments(text, keepStrings = false, path = '') {
+    const extension = extname(path).toLowerCase();
+    const python = PYTHON_EXTENSIONS.has(extension);
+    if (RUST_EXTENSIONS.has(extension)) {
+        text = text.replace(RUST_LIFETIME, (lifetime) => ' '.repeat(lifetime.length));
+    }
+    let output = '';
+    let index = 0;
+    while (index < text.length) {
+        const char = text[index] ?? '';
+        if (python ? char === '#' : char === '/' && text[index + 1] === '/') {
+            const end = text.indexOf('\n', index);
+            const stop = end === -1 ? text.length : end;
+            output += ' '.repeat(stop - index);
+            index = stop;
+        }
+        else if (!python && char === '/' && text[index + 1] === '*') {
+            const end = text.indexOf('*/', index + 2);
+            const stop = end === -1 ? text.length : end + 2;
+            output += text.slice(index, stop).replace(/[^\n]/g, ' ');
+            index = stop;
+        }
+        else if (python && (text.startsWith('"""', index) || text.startsWith("'''", index))) {
+            const quotes = text.slice(index, index + 3);
+            let end = index + 3;
+            while (end < text.length && !text.startsWith(quotes, end)) {
+                end += text[end] === '\\' ? 2 : 1;
+            }
+            const stop = Math.min(end + 3, text.length);
+            output += keepStrings ? text.slice(index, stop) : `${quotes}${text.slice(index + 3, end).replace(/[^\n]/g, ' ')}${end < text.length ? quotes : ''}`;
+            index = stop;
+        }
+        else if (char === '"' || char === "'" || (char === '`' && !python)) {
+            let end = index + 1;
+            while (end < text.length && text[end] !== char && !(char !== '`' && text[end] === '\n')) {
+                end += text[end] === '\\' ? 2 : 1;
+            }
+            output += keepStrings ? text.slice(index, end + 1) : `${char}${text.slice(index + 1, end).replace(/[^\n]/g, ' ')}${end < text.length ? char : ''}`;
+            index = end + 1;
+        }
+        else if (!python && char === '/' && text[index + 1] !== '>' && !output.endsWith('<') && REGEX_PRECEDER.test(output.slice(-64).trimEnd())) {
+            // `</div>` and `/>` close JSX elements rather than start a regex.
+            const end = findRegexEnd(text, index);
+            output += end === -1 ? char : `/${' '.repeat(end - index - 1)}/`;
+            index = end === -1 ? index + 1 : end + 1;
+        }
+        else {
+            output += char;
+            index += 1;
+        }
+    }
+    return output;
+}
+// A slash starts a regex literal rather than a division when it follows an
+// operator, an opening bracket, or a keyword such as return.
+const REGEX_PRECEDER = /(?:^|[(,=:[!&|?{};+\-*%<>~^]|\b(?:return|typeof|case|in|of|yield|await))$/;
+function findRegexEnd(text, start) {
+    let inClass = false;
+    for (let index = start + 1; index < text.length; index += 1) {
+        const char = text[index];
+        if (char === '\n') {
+            return -1;
+        }
+        if (char === '\\') {
+            index += 1;
+        }
+        else if (char === '[') {
+            inClass = true;
+        }
+        else if (char === ']') {
+            inClass = false;
+        }
+        else if (char === '/' && !inClass) {
+            return index;
+        }
+    }
+    return -1;
+}
+// Pass stripComments = false for text whose comments are already blanked, where `//` may be an operator.
+function normalize(text, stripComments = true) {
+    return (stripComments ? text.replace(/\/\*[\s\S]*?\*\//g, '').replace(/(^|[^:])\/\/.*$/gm, '$1') : text)
+        .replace(/"/g, "'")
+        .replace(/\s+/g, ' ')
+        .replace(/\s*([{}()[\],;:=<>|&])\s*/g, '$1')
+        .replace(/[;,]([}\])])/g, '$1')
+        .trim()
+        .replace(/[;,]$/, '');
+}
+async function git(basePath, args) {
+    try {
+        const { stdout } = await execFileAsync('git', args, { cwd: basePath, maxBuffer: 1024 * 1024 * 16 });
+        return stdout;
+    }
+    catch (error) {
+        const message = error instanceof Error ? error.message : String(error);
+        throw new Error(`git ${args[0] ?? 'command'} failed: ${message}`);
+    }
+}
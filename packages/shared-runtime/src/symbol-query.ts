@@ -0,0 +1,331 @@
+import { readFile, stat } from 'node:fs/promises';
+import { extname, join } from 'node:path';
+import { filterAxIgnored } from './axignore.js';
+import { matchesBuildConstraint, parseGoPlatform } from './build-constraints.js';
+import { listWorkspaceFiles } from './snapshot.js';
+import {
+  extractDeclarations,
+  findDeclarationExtractor,
+  supportsStructuralDiff,
+  type Declaration,
+  type DeclarationKind,
+  type StructField,
+  type TypeParam,
+} from './structural-diff.js';
+import type { SymbolRank } from './symbol-rank.js';
+
+export type SymbolQueryField = 'kind' | 'name' | 'receiver' | 'exported' | 'path' | 'lang' | 'annotation' | 'platform' | 'tag';
+
+export interface SymbolQueryTerm {
+  field: SymbolQueryField;
+  value: string;
+  negated: boolean;
+}
+
+export interface SymbolMatch {
+  kind: DeclarationKind;
+  // Bare name; methods carry their class or Go receiver separately.
+  name: string;
+  receiver?: string;
+  exported: boolean;
+  path: string;
+  line: number;
+  endLine: number;
+  signature: string;
+  embeds?: string[];
+  cgo?: string[];
+  annotations?: string[];
+  typeParams?: TypeParam[];
+  build?: string;
+  fields?: StructField[];
+  doc?: string;
+}
+
+export interface RuntimeSymbolSearchResponse {
+  query: string;
+  terms: SymbolQueryTerm[];
+  symbols: SymbolMatch[];
+  scannedFiles: number;
+  truncated: boolean;
+}
+
+// Declarations per language and kind across the workspace; `components` counts
+// declarations annotated as React components.
+export interface RuntimeSymbolMapResponse {
+  scannedFiles: number;
+  declarations: number;
+  exported: number;
+  components: number;
+  languages: Array<{ language: string; files: number; declarations: number }>;
+  kinds: Array<{ kind: DeclarationKind; count: number }>;
+  // The most central declarations of the reference graph, added by the runtime's symbol map.
+  central?: SymbolRank[];
+}
+
+const FIELDS: readonly SymbolQueryField[] = ['kind', 'name', 'receiver', 'exported', 'path', 'lang', 'annotation', 'platform', 'tag'];
+const KIND_ALIASES: Record<string, DeclarationKind[]> = {
+  func: ['function', 'method'],
+  function: ['function', 'method'],
+  fn: ['function', 'method'],
+  method: ['method'],
+  class: ['class'],
+  struct: ['type'],
+  type: ['type', 'interface', 'enum'],
+  interface: ['interface'],
+  enum: ['enum'],
+  var: ['variable'],
+  const: ['variable'],
+  variable: ['variable'],
+  typedef: ['type'],
+  macro: ['macro'],
+};
+const LANGUAGES: Record<string, string> = {
+  '.ts': 'ts',
+  '.tsx': 'ts',
+  '.mts': 'ts',
+  '.cts': 'ts',
+  '.js': 'js',
+  '.jsx': 'js',
+  '.mjs': 'js',
+  '.cjs': 'js',
+  '.go': 'go',
+  '.c': 'c',
+  '.h': 'c',
+  '.cc': 'cpp',
+  '.cpp': 'cpp',
+  '.cxx': 'cpp',
+  '.hh': 'cpp',
+  '.hpp': 'cpp',
+  '.hxx': 'cpp',
+  '.java': 'java',
+  '.kt': 'kotlin',
+  '.kts': 'kotlin',
+  '.rs': 'rust',
+  '.py': 'python',
+  '.pyi': 'python',
+};
+const MAX_SCAN_BYTES = 1024 * 1024;
+const DEFAULT_LIMIT = 100;
+
+/**
+ * Parses queries such as `kind:func receiver:Server name:~Start exported:true`.
+ * Values match exactly, with `*`/`?` wildcards, or as a case-insensitive
+ * regular expression when prefixed with `~`. A leading `-` negates a term and
+ * a bare word is shorthand for `name:~word`. Quote values containing spaces.
+ * `platform:linux/arm64` keeps symbols whose Go build constraint holds there,
+ * and `tag:json:user_id` Go structs with a field tagged that way.
+ */
+export function parseSymbolQuery(query: string): SymbolQueryTerm[] {
+  const terms: SymbolQueryTerm[] = [];
+  for (const match of query.matchAll(/(-?)(?:(\w+):)?("(?:[^"\\]|\\.)*"|\S+)/g)) {
+    const negated = match[1] === '-';
+    const rawValue = match[3]!;
+    const value = rawValue.startsWith('"') ? rawValue.slice(1, -1).replace(/\\(.)/g, '$1') : rawValue;
+    if (match[2] === undefined) {
+      terms.push({ field: 'name', value: `~${value}`, negated });
+      continue;
+    }
+    const field = match[2].toLowerCase();
+    if (!(FIELDS as readonly string[]).includes(field)) {
+      throw new Error(`Unknown symbol query field "${match[2]}". Expected one of: ${FIELDS.join(', ')}`);
+    }
+    if (field === 'kind' && KIND_ALIASES[value.toLowerCase()] === undefined) {
+      throw new Error(`Unknown symbol kind "${value}". Expected one of: ${Object.keys(KIND_ALIASES).join(', ')}`);
+    }
+    if (field === 'exported' && value !== 'true' && value !== 'false') {
+      throw new Error(`exported: expects true or false, got "${value}"`);
+    }
+    if (field === 'platform') {
+      parseGoPlatform(value);
+    }
+    terms.push({ field: field as SymbolQueryField, value, negated });
+  }
+  return terms;
+}
+
+export function matchesSymbolQuery(symbol: SymbolMatch, terms: SymbolQueryTerm[]): boolean {
+  return terms.every((term) => matchesTerm(symbol, term) !== term.negated);
+}
+
+/**
+ * Runs a symbol query over the TS/JS, Go, C/C++, Java/Kotlin, Rust, and Python files in the
+ * workspace (tracked and untracked, minus ignored ones), parsing declarations
+ * on demand.
+ */
+export async function findSymbols(request: {
+  basePath: string;
+  query: string;
+  paths?: string[];
+  limit?: number;
+}): Promise<RuntimeSymbolSearchResponse> {
+  const terms = parseSymbolQuery(request.query);
+  const files = await listSourceFiles(request.basePath, request.paths);
+  const limit = request.limit ?? DEFAULT_LIMIT;
+  const symbols: SymbolMatch[] = [];
+  let scannedFiles = 0;
+  let truncated = false;
+  for (const path of files) {
+    const content = await readSourceFile(request.basePath, path);
+    if (content === undefined) {
+      continue;
+    }
+    scannedFiles += 1;
+    for (const declaration of extractDeclarations(content, path)) {
+      const symbol = toSymbolMatch(declaration, path);
+      if (!matchesSymbolQuery(symbol, terms)) {
+        continue;
+      }
+      if (symbols.length === limit) {
+        truncated = true;
+        break;
+      }
+      symbols.push(symbol);
+    }
+    if (truncated) {
+      break;
+    }
+  }
+  return { query: request.query, terms, symbols, scannedFiles, truncated };
+}
+
+/**
+ * Counts the declarations in the workspace's source files by language and
+ * kind, the same files and parsing that `findSymbols` uses.
+ */
+export async function summarizeSymbols(request: { basePath: string; paths?: string[] }): Promise<RuntimeSymbolMapResponse> {
+  const files = await listSourceFiles(request.basePath, request.paths);
+  const languages = new Map<string, { files: number; declarations: number }>();
+  const kinds = new Map<DeclarationKind, number>();
+  let scannedFiles = 0;
+  let declarations = 0;
+  let exported = 0;
+  let components = 0;
+  for (const path of files) {
+    const content = await readSourceFile(request.basePath, path);
+    if (content === undefined) {
+      continue;
+    }
+    scannedFiles += 1;
+    const found = extractDeclarations(content, path);
+    const language = languageOf(path) ?? extname(path).slice(1);
+    const entry = languages.get(language) ?? { files: 0, declarations: 0 };
+    languages.set(language, { files: entry.files + 1, declarations: entry.declarations + found.length });
+    for (const declaration of found) {
+      declarations += 1;
+      exported += declaration.exported ? 1 : 0;
+      components += declaration.annotations?.includes('component') === true ? 1 : 0;
+      kinds.set(declaration.kind, (kinds.get(declaration.kind) ?? 0) + 1);
+    }
+  }
+  return {
+    scannedFiles,
+    declarations,
+    exported,
+    components,
+    languages: [...languages.entries()]
+      .map(([language, counts]) => ({ language, ...counts }))
+      .sort((left, right) => right.declarations - left.declarations || left.language.localeCompare(right.language)),
+    kinds: [...kinds.entries()]
+      .map(([kind, count]) => ({ kind, count }))
+      .sort((left, right) => right.count - left.count || left.kind.localeCompare(right.kind)),
+  };
+}
+
+// Skips files that are gone or too large to parse.
+async function readSourceFile(basePath: string, path: string): Promise<string | undefined> {
+  const absolutePath = join(basePath, path);
+  try {
+    if ((await stat(absolutePath)).size > MAX_SCAN_BYTES) {
+      return undefined;
+    }
+    return await readFile(absolutePath, 'utf8');
+  } catch {
+    return undefined;
+  }
+}
+
+// TS/JS, Go, C/C++, Java/Kotlin, Rust, and Python files in the workspace not excluded by `.axignore`, optionally under the given directories.
+export async function listSourceFiles(basePath: string, paths: string[] = []): Promise<string[]> {
+  const prefixes = paths.map((path) => path.replace(/^\.\//, '').replace(/\/+$/, '')).filter((path) => path.length > 0 && path !== '.');
+  const files = (await listWorkspaceFiles(basePath))
+    .filter((path) => supportsStructuralDiff(path))
+    .filter((path) => prefixes.length === 0 || prefixes.some((prefix) => path === prefix || path.startsWith(`${prefix}/`)));
+  return filterAxIgnored(basePath, files);
+}
+
+// The `lang:` name of a source file, from its extension or extractor plugin.
+export function languageOf(path: string): string | undefined {
+  return LANGUAGES[extname(path).toLowerCase()] ?? findDeclarationExtractor(path)?.language;
+}
+
+export function toSymbolMatch(declaration: Declaration, path: string): SymbolMatch {
+  const dot = declaration.kind === 'method' ? declaration.name.lastIndexOf('.') : -1;
+  return {
+    kind: declaration.kind,
+    name: dot === -1 ? declaration.name : declaration.name.slice(dot + 1),
+    ...(dot === -1 ? {} : { receiver: declaration.name.slice(0, dot) }),
+    exported: declaration.exported,
+    path,
+    line: declaration.line,
+    endLine: declaration.endLine,
+    signature: declaration.signature,
+    ...(declaration.embeds !== undefined ? { embeds: declaration.embeds } : {}),
+    ...(declaration.cgo !== undefined ? { cgo: declaration.cgo } : {}),
+    ...(declaration.annotations !== undefined ? { annotations: declaration.annotations } : {}),
+    ...(declaration.typeParams !== undefined ? { typeParams: declaration.typeParams } : {}),
+    ...(declaration.build !== undefined ? { build: declaration.build } : {}),
+    ...(declaration.fields !== undefined ? { fields: declaration.fields } : {}),
+    ...(declaration.doc !== undefined ? { doc: declaration.doc } : {}),
+  };
+}
+
+function matchesTerm(symbol: SymbolMatch, term: SymbolQueryTerm): boolean {
+  switch (term.field) {
+    case 'kind':
+      return KIND_ALIASES[term.value.toLowerCase()]!.includes(symbol.kind);
+    case 'name':
+      // A dotted pattern names the receiver too: name:Server.Start.
+      return matchesValue(term.value.includes('.') && symbol.receiver !== undefined ? `${symbol.receiver}.${symbol.name}` : symbol.name, term.value);
+    case 'receiver':
+      return symbol.receiver !== undefined && matchesValue(symbol.receiver, term.value);
+    case 'exported':
+      return symbol.exported === (term.value === 'true');
+    case 'path':
+      return matchesValue(symbol.path, term.value) || (!/[*?~]/.test(term.value) && symbol.path.startsWith(`${term.value.replace(/\/+$/, '')}/`));
+    case 'lang':
+      return languageOf(symbol.path) === term.value.toLowerCase();
+    case 'annotation':
+      // Written without the @: annotation:RestController, annotation:*Mapping.
+      return (symbol.annotations ?? []).some((annotation) => matchesValue(annotation, term.value.replace(/^@/, '')));
+    case 'platform':
+      // platform:linux/arm64+integration; symbols without a build constraint build everywhere.
+      return symbol.build === undefined || matchesBuildConstraint(symbol.build, parseGoPlatform(term.value));
+    case 'tag': {
+      // tag:db finds structs with any db tag, tag:json:user_id the field serialized as user_id.
+      const colon = term.value.indexOf(':');
+      const key = colon === -1 ? term.value : term.value.slice(0, colon);
+      return (symbol.fields ?? []).some((field) => {
+        const tag = field.tags?.[key];
+        return tag !== undefined && (colon === -1 || matchesValue(tag.split(',')[0]!, term.value.slice(colon + 1)));
+      });
+    }
+  }
+}
+
+function matchesValue(actual: string, pattern: string): boolean {
+  if (pattern.startsWith('~')) {
+    try {
+      return new RegExp(pattern.slice(1), 'i').test(actual);
+    } catch {
+      return actual.toLowerCase().includes(pattern.slice(1).toLowerCase());
+    }
+  }
+  if (/[*?]/.test(pattern)) {
+    const source = pattern
+      .split(/(\*\*|\*|\?)/)
+      .map((part) => (part === '**' ? '.*' : part === '*' ? '[^/]*' : part === '?' ? '[^/]' : part.replace(/[.+^${}()|[\]\\]/g, '\\$&')))
+      .join('');
+    return new RegExp(`^${source}$`).test(actual);
+  }
+  return actual === pattern;
+}
@@ -0,0 +1,147 @@
+import { loadReferenceIndex, resolveByName, type IndexedFile } from './reference-index.js';
+import type { DeclarationKind } from './structural-diff.js';
+import type { SymbolMatch } from './symbol-query.js';
+
+export interface SymbolRank {
+  // `Server.Start` for methods.
+  name: string;
+  kind: DeclarationKind;
+  path: string;
+  line: number;
+  exported: boolean;
+  // PageRank over the reference graph, scaled so the average declaration scores 1.
+  score: number;
+  // Uses from other declarations, and how many declarations they come from.
+  references: number;
+  referrers: number;
+}
+
+export interface RuntimeSymbolRankResponse {
+  // Most central first.
+  symbols: SymbolRank[];
+  declarations: number;
+  // Distinct declaration-to-declaration links the scores were computed from.
+  edges: number;
+  scannedFiles: number;
+}
+
+const DAMPING = 0.85;
+const MAX_ITERATIONS = 50;
+const TOLERANCE = 1e-9;
+const DEFAULT_LIMIT = 50;
+
+/**
+ * Ranks the workspace's declarations by PageRank over the reference graph:
+ * each use of a name inside a declaration links that declaration to the one
+ * the name resolves to (see resolveByName), weighted by how often it is
+ * used. Core abstractions that much of the code leans on, directly or
+ * through other central symbols, score high; leaf utilities score low. Uses
+ * outside any declaration (imports, package clauses) and ambiguous names do
+ * not count. Paths narrow the symbols listed, not the graph.
+ */
+export async function rankSymbols(request: { basePath: string; paths?: string[]; limit?: number }): Promise<RuntimeSymbolRankResponse> {
+  const files = await loadReferenceIndex(request.basePath);
+  const graph = buildReferenceGraph(files);
+  const scores = pageRank(graph);
+  const prefixes = (request.paths ?? []).map((path) => path.replace(/^\.\//, '').replace(/\/+$/, '')).filter((path) => path.length > 0 && path !== '.');
+  const inScope = (path: string) => prefixes.length === 0 || prefixes.some((prefix) => path === prefix || path.startsWith(`${prefix}/`));
+  const symbols = graph.nodes
+    .map((symbol, index) => {
+      const incoming = graph.incoming[index]!;
+      return {
+        name: symbol.receiver !== undefined ? `${symbol.receiver}.${symbol.name}` : symbol.name,
+        kind: symbol.kind,
+        path: symbol.path,
+        line: symbol.line,
+        exported: symbol.exported,
+        score: Math.round(scores[index]! * graph.nodes.length * 1000) / 1000,
+        references: [...incoming.values()].reduce((sum, weight) => sum + weight, 0),
+        referrers: incoming.size,
+      };
+    })
+    .filter((symbol) => inScope(symbol.path))
+    .sort((left, right) => right.score - left.score || right.references - left.references || left.path.localeCompare(right.path) || left.line - right.line);
+  return {
+    symbols: symbols.slice(0, request.limit ?? DEFAULT_LIMIT),
+    declarations: graph.nodes.length,
+    edges: graph.incoming.reduce((sum, incoming) => sum + incoming.size, 0),
+    scannedFiles: files.size,
+  };
+}
+
+/**
+ * Centrality by `path:line` of each declaration, scaled so the average is 1,
+ * for ranking code context elsewhere.
+ */
+export function symbolCentrality(files: Map<string, IndexedFile>): Map<string, number> {
+  const graph = buildReferenceGraph(files);
+  const scores = pageRank(graph);
+  return new Map(graph.nodes.map((symbol, index) => [`${symbol.path}:${symbol.line}`, scores[index]! * graph.nodes.length]));
+}
+
+interface ReferenceGraph {
+  nodes: SymbolMatch[];
+  // Per node, the weight of each link from another node to it.
+  incoming: Array<Map<number, number>>;
+  // Per node, its total outgoing weight.
+  outgoing: number[];
+}
+
+function buildReferenceGraph(files: Map<string, IndexedFile>): ReferenceGraph {
+  const nodes: SymbolMatch[] = [];
+  const ids = new Map<SymbolMatch, number>();
+  const byName = new Map<string, SymbolMatch[]>();
+  for (const file of files.values()) {
+    for (const symbol of file.declarations) {
+      ids.set(symbol, nodes.length);
+      nodes.push(symbol);
+      byName.set(symbol.name, [...(byName.get(symbol.name) ?? []), symbol]);
+    }
+  }
+  const incoming = nodes.map(() => new Map<number, number>());
+  const outgoing = nodes.map(() => 0);
+  for (const [path, file] of files) {
+    // Innermost first, so a use inside a class method counts for the method.
+    const enclosing = [...file.declarations].sort((left, right) => (left.endLine - left.line) - (right.endLine - right.line));
+    for (const [name, references] of file.references) {
+      const candidates = byName.get(name);
+      if (candidates === undefined) {
+        continue;
+      }
+      for (const reference of references) {
+        const source = enclosing.find((symbol) => symbol.line <= reference.line && reference.line <= symbol.endLine);
+        const target = source === undefined ? undefined : resolveByName(reference, path, candidates);
+        if (source === undefined || target === undefined || target === source) {
+          continue;
+        }
+        const from = ids.get(source)!;
+        const to = ids.get(target)!;
+        incoming[to]!.set(from, (incoming[to]!.get(from) ?? 0) + 1);
+        outgoing[from]! += 1;
+      }
+    }
+  }
+  return { nodes, incoming, outgoing };
+}
+
+// Weighted PageRank; declarations that use nothing spread their rank evenly.
+function pageRank(graph: ReferenceGraph): number[] {
+  const count = graph.nodes.length;
+  let ranks = graph.nodes.map(() => 1 / count);
+  for (let iteration = 0; iteration < MAX_ITERATIONS; iteration += 1) {
+    const dangling = ranks.reduce((sum, rank, index) => sum + (graph.outgoing[index] === 0 ? rank : 0), 0);
+    const next = graph.incoming.map((incoming) => {
+      let linked = 0;
+      for (const [from, weight] of incoming) {
+        linked += ranks[from]! * weight / graph.outgoing[from]!;
+      }
+      return (1 - DAMPING) / count + DAMPING * (linked + dangling / count);
+    });
+    const delta = next.reduce((sum, rank, index) => sum + Math.abs(rank - ranks[index]!), 0);
+    ranks = next;
+    if (delta < TOLERANCE) {
+      break;
+    }
+  }
+  return ranks;
+}
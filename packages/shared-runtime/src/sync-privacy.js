@@ -0,0 +1,114 @@
+import { homedir, hostname, userInfo } from 'node:os';
+import { sep } from 'node:path';
+// Account names shared by too many machines, or too common as words, to say anything about a person.
+const GENERIC_USERNAMES = new Set(['root', 'admin', 'user', 'runner', 'ubuntu', 'localhost']);
+const POSIX_PATH = /(?<![\w.~<>/:@-])\/[\w.@+-]+(?:\/[\w.@+-]+)+\/?/g;
+const WINDOWS_PATH = /(?<![\w])[A-Za-z]:\\[^\s"'<>|*?]*/g;
+const OTHER_HOME = /^(?:\/home\/[^/]+|\/Users\/[^/]+|[A-Za-z]:\/Users\/[^/]+)(?=\/|$)/i;
+const EMAIL = /[\w.+-]+@([\w-]+(?:\.[\w-]+)+)/g;
+const IPV4 = /\b(?:\d{1,3}\.){3}\d{1,3}\b/g;
+const LOOPBACK_ADDRESSES = new Set(['127.0.0.1', '0.0.0.0']);
+export function resolveSyncPrivacy(value) {
+    if (value === undefined || value === 'off') {
+        return undefined;
+    }
+    const mode = isRecord(value) ? value.mode ?? 'generalize' : value;
+    if (mode !== 'generalize' && mode !== 'strip' && mode !== 'off') {
+        throw new Error('sync.privacy must be "off", "generalize", "strip", or {"mode":...,"redact":[...]}');
+    }
+    const redact = isRecord(value) ? value.redact ?? [] : [];
+    if (!Array.isArray(redact) || !redact.every((pattern) => typeof pattern === 'string' && pattern.length > 0)) {
+        throw new Error('sync.privacy.redact must be a list of regular expressions');
+    }
+    for (const pattern of redact) {
+        try {
+            new RegExp(pattern);
+        }
+        catch {
+            throw new Error(`sync.privacy.redact has an invalid regular expression: ${pattern}`);
+        }
+    }
+    return mode === 'off' ? undefined : { mode, redact: redact };
+}
+/**
+ * Builds the function that rewrites a memory value before it leaves this
+ * machine. Local paths, the account and machine names, email addresses, and
+ * IP addresses are replaced in every string the value holds; object keys and
+ * other values are kept. Rewriting a rewritten value changes nothing, so the
+ * same entry hashes the same on every sync.
+ */
+export function createSyncPrivacyFilter(policy, context) {
+    const workspace = toSlashes(context.basePath).replace(/\/+$/, '');
+    const home = context.homeDir !== undefined ? toSlashes(context.homeDir).replace(/\/+$/, '') : undefined;
+    // Host names often contain the account name (alice-laptop), so they go first.
+    const names = [
+        { name: context.hostname, placeholder: '<host>' },
+        { name: context.username, placeholder: '<user>' },
+    ].flatMap(({ name, placeholder }) => name !== undefined && name.length >= 3 && !GENERIC_USERNAMES.has(name.toLowerCase())
+        ? [{ pattern: new RegExp(`(?<![<\\w-])${escapeRegExp(name)}(?![>\\w-])`, 'gi'), placeholder }]
+        : []);
+    const redact = policy.redact.map((pattern) => new RegExp(pattern, 'g'));
+    const generalizePath = (path) => {
+        const normalized = toSlashes(path);
+        if (normalized === workspace || normalized.startsWith(`${workspace}/`)) {
+            return `<workspace>${normalized.slice(workspace.length)}`;
+        }
+        const homeRoot = home !== undefined && (normalized === home || normalized.startsWith(`${home}/`))
+            ? home
+            : normalized.match(OTHER_HOME)?.[0];
+        if (homeRoot !== undefined) {
+            return policy.mode === 'generalize' ? `~${normalized.slice(homeRoot.length)}` : '<path>';
+        }
+        return policy.mode === 'generalize' ? path : '<path>';
+    };
+    const scrubText = (text) => {
+        let scrubbed = text;
+        for (const pattern of redact) {
+            scrubbed = scrubbed.replace(pattern, '<redacted>');
+        }
+        scrubbed = scrubbed.replace(WINDOWS_PATH, generalizePath).replace(POSIX_PATH, generalizePath);
+        scrubbed = scrubbed.replace(EMAIL, (_match, domain) => (policy.mode === 'generalize' ? `<user>@${domain}` : '<email>'));
+        scrubbed = scrubbed.replace(IPV4, (address) => (LOOPBACK_ADDRESSES.has(address) ? address : '<ip>'));
+        for (const { pattern, placeholder } of names) {
+            scrubbed = scrubbed.replace(pattern, placeholder);
+        }
+        return scrubbed;
+    };
+    const scrub = (value) => {
+        if (typeof value === 'string') {
+            return scrubText(value);
+        }
+        if (Array.isArray(value)) {
+            return value.map(scrub);
+        }
+        if (isRecord(value)) {
+            return Object.fromEntries(Object.entries(value).map(([key, entry]) => [key, scrub(entry)]));
+        }
+        return value;
+    };
+    return scrub;
+}
+export function localPrivacyContext(basePath) {
+    let username;
+    try {
+        username = userInfo().username;
+    }
+    catch {
+        username = undefined;
+    }
+    return { basePath, homeDir: homedir(), username, hostname: hostname() };
+}
+// Only the day survives, so shared entries don't show when someone works.
+export function generalizeTimestamp(timestamp) {
+    const parsed = Date.parse(timestamp);
+    return Number.isNaN(parsed) ? timestamp : `${new Date(parsed).toISOString().slice(0, 10)}T00:00:00.000Z`;
+}
+function toSlashes(path) {
+    return sep === '\\' || path.includes('\\') ? path.replace(/\\/g, '/') : path;
+}
+function escapeRegExp(value) {
+    return value.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,220 @@
+import { execFile } from 'node:child_process';
+import { readFile, stat } from 'node:fs/promises';
+import { join } from 'node:path';
+import { promisify } from 'node:util';
+import { listWorkspaceFiles } from './snapshot.js';
+
+const execFileAsync = promisify(execFile);
+
+export type TechDebtKind = 'todo' | 'fixme' | 'hack' | 'bug' | 'deprecated';
+
+export const TECH_DEBT_KINDS: readonly TechDebtKind[] = ['todo', 'fixme', 'hack', 'bug', 'deprecated'];
+
+const MAX_SCAN_BYTES = 1024 * 1024;
+const DEFAULT_LIMIT = 500;
+const UNCOMMITTED_AUTHOR = 'Not Committed Yet';
+
+// A marker that opens the comment may omit the colon ("// TODO fix this");
+// anywhere else it needs one, so prose such as "fixes a BUG in" is ignored.
+const LEADING_MARKER = /^@?(TODO|FIXME|HACK|BUG)(?:[([]([^)\]]*)[)\]])?(?:[\s:-]+|$)(.*)$/;
+const INLINE_MARKER = /\b(TODO|FIXME|HACK|BUG)(?:[([]([^)\]]*)[)\]])?:\s*(.*)$/;
+const DEPRECATED_MARKER = /(?:^|\s)(?:@deprecated\b|Deprecated:)\s*(.*)$/;
+const COMMENT_OPENER = /^(?:\/\/+|\/\*+|<!--|#+)/;
+const CONTINUATION_LINE = /^\s*(?:\*+(?!\/)|--)\s?(.*)$/;
+
+export interface TechDebtItem {
+  kind: TechDebtKind;
+  path: string;
+  line: number;
+  text: string;
+  // Named in the marker, as in TODO(alice) or FIXME[bob], or a leading @carol.
+  owner?: string;
+  // Issue reference named in the marker: TODO(#123).
+  ref?: string;
+  // From git blame, when requested.
+  author?: string;
+  authoredAt?: string;
+}
+
+export interface RuntimeTechDebtResponse {
+  items: TechDebtItem[];
+  counts: Record<TechDebtKind, number>;
+  // Explicit owner, else blame author, else "unassigned"; most items first.
+  owners: Array<{ owner: string; count: number }>;
+  scannedFiles: number;
+  truncated: boolean;
+}
+
+export function extractTechDebt(content: string, path: string): TechDebtItem[] {
+  const items: TechDebtItem[] = [];
+  content.split(/\r?\n/).forEach((line, index) => {
+    const comment = commentText(line);
+    if (comment === undefined || comment.length === 0) {
+      return;
+    }
+    const marker = LEADING_MARKER.exec(comment) ?? INLINE_MARKER.exec(comment);
+    if (marker !== null) {
+      const item: TechDebtItem = { kind: marker[1]!.toLowerCase() as TechDebtKind, path, line: index + 1, text: (marker[3] ?? '').trim() };
+      assignOwner(item, marker[2]?.trim());
+      items.push(item);
+      return;
+    }
+    const deprecated = DEPRECATED_MARKER.exec(comment);
+    if (deprecated !== null) {
+      items.push({ kind: 'deprecated', path, line: index + 1, text: (deprecated[1] ?? '').trim() });
+    }
+  });
+  return items;
+}
+
+/**
+ * Scans the workspace (tracked and untracked files, minus ignored ones) for
+ * TODO, FIXME, HACK, BUG and deprecation markers in comments. With blame, items
+ * carry the author and time of their line, and those without an explicit
+ * owner are attributed to that author.
+ */
+export async function harvestTechDebt(request: {
+  basePath: string;
+  paths?: string[];
+  kinds?: TechDebtKind[];
+  owner?: string;
+  blame?: boolean;
+  limit?: number;
+}): Promise<RuntimeTechDebtResponse> {
+  const kinds = new Set(request.kinds !== undefined && request.kinds.length > 0 ? request.kinds : TECH_DEBT_KINDS);
+  const prefixes = (request.paths ?? []).map((path) => path.replace(/^\.\//, '').replace(/\/+$/, '')).filter((path) => path.length > 0 && path !== '.');
+  const files = (await listWorkspaceFiles(request.basePath))
+    .filter((path) => prefixes.length === 0 || prefixes.some((prefix) => path === prefix || path.startsWith(`${prefix}/`)));
+
+  let items: TechDebtItem[] = [];
+  let scannedFiles = 0;
+  for (const path of files) {
+    const absolutePath = join(request.basePath, path);
+    try {
+      if ((await stat(absolutePath)).size > MAX_SCAN_BYTES) {
+        continue;
+      }
+    } catch {
+      continue;
+    }
+    const content = await readFile(absolutePath, 'utf8');
+    if (content.includes('\0')) {
+      continue;
+    }
+    scannedFiles += 1;
+    const found = extractTechDebt(content, path).filter((item) => kinds.has(item.kind));
+    if (request.blame === true && found.length > 0) {
+      const blame = await blameLines(request.basePath, path);
+      for (const item of found) {
+        const entry = blame.get(item.line);
+        if (entry !== undefined) {
+          item.author = entry.author;
+          item.authoredAt = entry.authoredAt;
+        }
+      }
+    }
+    items.push(...found);
+  }
+
+  if (request.owner !== undefined) {
+    const owner = request.owner.replace(/^@/, '').toLowerCase();
+    items = items.filter((item) => (item.owner ?? item.author)?.toLowerCase() === owner);
+  }
+
+  const counts: Record<TechDebtKind, number> = { todo: 0, fixme: 0, hack: 0, bug: 0, deprecated: 0 };
+  const owners = new Map<string, number>();
+  for (const item of items) {
+    counts[item.kind] += 1;
+    const owner = item.owner ?? item.author ?? 'unassigned';
+    owners.set(owner, (owners.get(owner) ?? 0) + 1);
+  }
+  const limit = request.limit ?? DEFAULT_LIMIT;
+  return {
+    items: items.slice(0, limit),
+    counts,
+    owners: [...owners.entries()]
+      .map(([owner, count]) => ({ owner, count }))
+      .sort((left, right) => right.count - left.count || left.owner.localeCompare(right.owner)),
+    scannedFiles,
+    truncated: items.length > limit,
+  };
+}
+
+// Returns the text of the comment on a line, skipping comment openers that
+// sit inside string literals. Continuation lines of block comments count too.
+function commentText(line: string): string | undefined {
+  const continuation = CONTINUATION_LINE.exec(line);
+  if (continuation !== null) {
+    return stripCloser(continuation[1] ?? '');
+  }
+  let quote: string | undefined;
+  for (let index = 0; index < line.length; index += 1) {
+    const char = line[index]!;
+    if (quote !== undefined) {
+      if (char === '\\') {
+        index += 1;
+      } else if (char === quote) {
+        quote = undefined;
+      }
+      continue;
+    }
+    if (char === '"' || char === '\'' || char === '`') {
+      quote = char;
+      continue;
+    }
+    const opener = COMMENT_OPENER.exec(line.slice(index));
+    if (opener !== null) {
+      return stripCloser(line.slice(index + opener[0].length));
+    }
+  }
+  return undefined;
+}
+
+function stripCloser(comment: string): string {
+  return comment.replace(/\s*(?:\*\/|-->)\s*$/, '').trim();
+}
+
+function assignOwner(item: TechDebtItem, tag: string | undefined): void {
+  if (tag !== undefined && tag.length > 0) {
+    if (/^@?[\w.-]+$/.test(tag) && !/^\d+$/.test(tag)) {
+      item.owner = tag.replace(/^@/, '');
+    } else {
+      item.ref = tag;
+    }
+    return;
+  }
+  const mention = /^@([\w.-]+)[\s:,-]*(.*)$/.exec(item.text);
+  if (mention !== null) {
+    item.owner = mention[1];
+    item.text = mention[2] ?? '';
+  }
+}
+
+async function blameLines(basePath: string, path: string): Promise<Map<number, { author: string; authoredAt: string }>> {
+  const lines = new Map<number, { author: string; authoredAt: string }>();
+  let stdout: string;
+  try {
+    ({ stdout } = await execFileAsync('git', ['blame', '--line-porcelain', '--', path], { cwd: basePath, maxBuffer: 64 * 1024 * 1024 }));
+  } catch {
+    // Untracked file or not a git repository.
+    return lines;
+  }
+  let line: number | undefined;
+  let author: string | undefined;
+  let authoredAt: string | undefined;
+  for (const row of stdout.split('\n')) {
+    const header = /^[0-9a-f]{40} \d+ (\d+)/.exec(row);
+    if (header !== null) {
+      line = Number.parseInt(header[1]!, 10);
+      author = undefined;
+      authoredAt = undefined;
+    } else if (row.startsWith('author ')) {
+      author = row.slice('author '.length);
+    } else if (row.startsWith('author-time ')) {
+      authoredAt = new Date(Number.parseInt(row.slice('author-time '.length), 10) * 1000).toISOString();
+    } else if (row.startsWith('\t') && line !== undefined && author !== undefined && author !== UNCOMMITTED_AUTHOR) {
+      lines.set(line, { author, authoredAt: authoredAt ?? '' });
+    }
+  }
+  return lines;
+}
@@ -0,0 +1,233 @@
+import { createHash, randomUUID } from 'node:crypto';
+import { appendFile, mkdir, readFile, writeFile } from 'node:fs/promises';
+import { createServer } from 'node:http';
+import { dirname, join } from 'node:path';
+export const TELEMETRY_DIR = join('.automatosx', 'telemetry');
+export const TELEMETRY_SCHEMA_VERSION = 1;
+const DEFAULT_WINDOW_DAYS = 7;
+const MAX_COLLECTOR_PAYLOAD_BYTES = 1024 * 1024;
+// Built-in workflow ids are reported as-is; anything else is hashed so
+// project-specific workflow names never leave the machine.
+const KNOWN_FEATURES = new Set([
+    'call',
+    'review',
+    'discuss',
+    'discuss.recursive',
+    'agent.run',
+    'parallel.run',
+    'patch.apply',
+    'canary',
+    'rollback-playbook',
+    'slo-gate',
+    'ship',
+    'architect',
+    'audit',
+    'qa',
+    'release',
+    'accessibility',
+]);
+const KNOWN_PATCH_STRATEGIES = new Set(['exact', 'offset', 'whitespace', 'fuzz']);
+export function resolveTelemetryConfig(value) {
+    if (!isRecord(value)) {
+        return { enabled: false };
+    }
+    return {
+        enabled: value.enabled === true,
+        endpoint: typeof value.endpoint === 'string' && value.endpoint.length > 0 ? value.endpoint : undefined,
+    };
+}
+export async function readInstallId(basePath) {
+    const idPath = join(basePath, TELEMETRY_DIR, 'install-id');
+    try {
+        const existing = (await readFile(idPath, 'utf8')).trim();
+        if (existing.length > 0) {
+            return existing;
+        }
+    }
+    catch {
+        // first use; fall through and create one
+    }
+    const installId = randomUUID();
+    await mkdir(dirname(idPath), { recursive: true });
+    await writeFile(idPath, `${installId}\n`, 'utf8');
+    return installId;
+}
+export function buildTelemetryReport(traces, options) {
+    const now = options.now ?? new Date();
+    const windowDays = options.windowDays ?? DEFAULT_WINDOW_DAYS;
+    const cutoff = now.getTime() - windowDays * 24 * 60 * 60 * 1000;
+    const features = new Map();
+    const errorCategories = {};
+    const patchStrategies = {};
+    for (const trace of traces) {
+        const startedAt = Date.parse(trace.startedAt);
+        if (Number.isNaN(startedAt) || startedAt < cutoff || startedAt > now.getTime()) {
+            continue;
+        }
+        const feature = anonymizeFeature(trace.workflowId);
+        const entry = features.get(feature) ?? { runs: 0, failures: 0, surfaces: {}, durations: [] };
+        entry.runs += 1;
+        entry.surfaces[trace.surface] = (entry.surfaces[trace.surface] ?? 0) + 1;
+        if (trace.completedAt !== undefined) {
+            const completedAt = Date.parse(trace.completedAt);
+            if (!Number.isNaN(completedAt) && completedAt >= startedAt) {
+                entry.durations.push(completedAt - startedAt);
+            }
+        }
+        if (trace.status === 'failed') {
+            entry.failures += 1;
+            const category = categorizeError(trace.error?.code);
+            errorCategories[category] = (errorCategories[category] ?? 0) + 1;
+        }
+        features.set(feature, entry);
+        if (trace.workflowId === 'patch.apply') {
+            countPatchStrategies(trace.output, patchStrategies);
+        }
+    }
+    return {
+        schemaVersion: TELEMETRY_SCHEMA_VERSION,
+        installHash: hashValue(options.installId),
+        reportDate: now.toISOString().slice(0, 10),
+        windowDays,
+        features: [...features.entries()]
+            .map(([feature, entry]) => ({
+                feature,
+                runs: entry.runs,
+                failures: entry.failures,
+                surfaces: entry.surfaces,
+                latencyMs: summarizeLatency(entry.durations),
+            }))
+            .sort((left, right) => right.runs - left.runs || left.feature.localeCompare(right.feature)),
+        errorCategories,
+        patchStrategies,
+    };
+}
+// Which fallback placed each hunk, so the apply success rate per strategy can be tracked.
+function countPatchStrategies(output, counts) {
+    const strategyCounts = isRecord(output) && isRecord(output.strategyCounts) ? output.strategyCounts : {};
+    for (const [strategy, count] of Object.entries(strategyCounts)) {
+        if (KNOWN_PATCH_STRATEGIES.has(strategy) && typeof count === 'number') {
+            counts[strategy] = (counts[strategy] ?? 0) + count;
+        }
+    }
+}
+export async function sendTelemetryReport(report, endpoint) {
+    const response = await fetch(endpoint, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(report),
+    });
+    if (!response.ok) {
+        throw new Error(`Telemetry endpoint ${endpoint} responded with ${response.status}`);
+    }
+}
+export async function recordSentReport(basePath, report, endpoint, sentAt) {
+    const logPath = join(basePath, TELEMETRY_DIR, 'sent.jsonl');
+    await mkdir(dirname(logPath), { recursive: true });
+    await appendFile(logPath, `${JSON.stringify({ sentAt, endpoint, report })}\n`, 'utf8');
+}
+export async function readLastSentAt(basePath) {
+    let content;
+    try {
+        content = await readFile(join(basePath, TELEMETRY_DIR, 'sent.jsonl'), 'utf8');
+    }
+    catch {
+        return undefined;
+    }
+    const lines = content.trim().split('\n').filter((line) => line.length > 0);
+    const last = lines[lines.length - 1];
+    if (last === undefined) {
+        return undefined;
+    }
+    try {
+        const parsed = JSON.parse(last);
+        return typeof parsed.sentAt === 'string' ? parsed.sentAt : undefined;
+    }
+    catch {
+        return undefined;
+    }
+}
+export function startTelemetryCollector(options) {
+    const server = createServer((req, res) => {
+        handleCollectorRequest(req, res, options.outputPath).catch((error) => {
+            if (!res.writableEnded) {
+                respondJson(res, 500, { error: error instanceof Error ? error.message : String(error) });
+            }
+        });
+    });
+    return new Promise((resolve, reject) => {
+        server.once('error', reject);
+        server.listen(options.port ?? 0, options.host ?? '127.0.0.1', () => {
+            resolve({
+                port: (server.address()).port,
+                outputPath: options.outputPath,
+                close: () => new Promise((done) => server.close(() => done())),
+            });
+        });
+    });
+}
+async function handleCollectorRequest(req, res, outputPath) {
+    if (req.method !== 'POST') {
+        respondJson(res, 405, { error: 'Only POST is supported' });
+        return;
+    }
+    const chunks = [];
+    let size = 0;
+    for await (const chunk of req) {
+        const buffer = chunk;
+        size += buffer.length;
+        if (size > MAX_COLLECTOR_PAYLOAD_BYTES) {
+            respondJson(res, 413, { error: 'Payload too large' });
+            return;
+        }
+        chunks.push(buffer);
+    }
+    let report;
+    try {
+        report = JSON.parse(Buffer.concat(chunks).toString('utf8'));
+    }
+    catch {
+        respondJson(res, 400, { error: 'Body must be JSON' });
+        return;
+    }
+    if (!isRecord(report) || report.schemaVersion !== TELEMETRY_SCHEMA_VERSION || typeof report.installHash !== 'string') {
+        respondJson(res, 400, { error: 'Not a telemetry report' });
+        return;
+    }
+    await mkdir(dirname(outputPath), { recursive: true });
+    await appendFile(outputPath, `${JSON.stringify({ receivedAt: new Date().toISOString(), report })}\n`, 'utf8');
+    respondJson(res, 202, { accepted: true });
+}
+function respondJson(res, status, body) {
+    res.writeHead(status, { 'Content-Type': 'application/json' });
+    res.end(JSON.stringify(body));
+}
+function anonymizeFeature(workflowId) {
+    return KNOWN_FEATURES.has(workflowId) ? workflowId : `custom:${hashValue(workflowId).slice(0, 8)}`;
+}
+// Only machine-readable codes are reported; free-form messages may carry
+// paths, prompts, or secrets and are always dropped.
+function categorizeError(code) {
+    return code !== undefined && /^[A-Z][A-Z0-9_]*$/.test(code) ? code : 'OTHER';
+}
+function summarizeLatency(durations) {
+    const sorted = [...durations].sort((left, right) => left - right);
+    return {
+        p50: percentile(sorted, 0.5),
+        p95: percentile(sorted, 0.95),
+        p99: percentile(sorted, 0.99),
+    };
+}
+function percentile(sorted, rank) {
+    if (sorted.length === 0) {
+        return 0;
+    }
+    const index = Math.min(sorted.length - 1, Math.ceil(rank * sorted.length) - 1);
+    return sorted[Math.max(0, index)] ?? 0;
+}
+function hashValue(value) {
+    return createHash('sha256').update(value).digest('hex');
+}
+function isRecord(value) {
+    return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
@@ -0,0 +1,158 @@
+import { dirname } from 'node:path';
+import { resolveCalls, type CallGraphNode } from './call-graph.js';
+import { loadReferenceIndex } from './reference-index.js';
+
+export type GoTestKind = 'test' | 'benchmark' | 'fuzz' | 'example';
+
+export interface TestTarget {
+  // `path:line` of the function or method, as in the call graph.
+  id: string;
+  name: string;
+  path: string;
+  line: number;
+  // `name`: the test is named after it (TestServerStart for Server.Start);
+  // `call`: the test calls it, directly or through helpers in test files.
+  via: Array<'name' | 'call'>;
+}
+
+export interface TestFunction {
+  name: string;
+  kind: GoTestKind;
+  path: string;
+  line: number;
+  targets: TestTarget[];
+}
+
+// A package function or method with the tests that target it.
+export interface FunctionTests {
+  id: string;
+  name: string;
+  path: string;
+  line: number;
+  exported: boolean;
+  tests: string[];
+}
+
+export interface RuntimeTestMapResponse {
+  tests: TestFunction[];
+  tested: FunctionTests[];
+  // Functions and methods no test is named after or calls, exported first.
+  untested: FunctionTests[];
+  scannedFiles: number;
+}
+
+const TEST_NAME = /^(Test|Benchmark|Fuzz|Example)(?=$|[^a-z])_?(.*)$/;
+const TEST_KINDS: Record<string, GoTestKind> = { Test: 'test', Benchmark: 'benchmark', Fuzz: 'fuzz', Example: 'example' };
+// Entry points the go tool or runtime calls, never tested by name.
+const UNTESTABLE = new Set(['main', 'init']);
+
+/**
+ * Maps the Test, Benchmark, Fuzz, and Example functions of Go `_test.go`
+ * files to the package functions and methods they exercise, and lists the
+ * ones no test reaches. A test targets what it is named after, matched
+ * within its package (`TestParse` and `TestParseEmpty` name Parse,
+ * `TestServer_Start` and `ExampleServer_Start` name Server.Start), and what
+ * it calls through the call graph; calls through helper functions in test
+ * files count as the test's own. Paths narrow what is reported; tests
+ * anywhere in the workspace count towards the functions listed.
+ */
+export async function mapGoTests(request: { basePath: string; paths?: string[] }): Promise<RuntimeTestMapResponse> {
+  const files = await loadReferenceIndex(request.basePath);
+  const calls = resolveCalls(files);
+  const prefixes = (request.paths ?? []).map((path) => path.replace(/^\.\//, '').replace(/\/+$/, '')).filter((path) => path.length > 0 && path !== '.');
+  const inScope = (path: string) => prefixes.length === 0 || prefixes.some((prefix) => path === prefix || path.startsWith(`${prefix}/`));
+  const isTestFile = (path: string) => path.endsWith('_test.go');
+
+  const production = [...calls.nodes.values()].filter((node) => node.path.endsWith('.go') && !isTestFile(node.path));
+  const callees = new Map<string, string[]>();
+  for (const edge of calls.edges) {
+    callees.set(edge.from, [...(callees.get(edge.from) ?? []), edge.to]);
+  }
+
+  const tests: TestFunction[] = [];
+  for (const node of calls.nodes.values()) {
+    const match = TEST_NAME.exec(node.name);
+    if (!isTestFile(node.path) || node.kind !== 'function' || match === null || node.name === 'TestMain') {
+      continue;
+    }
+    const targets = new Map<string, TestTarget>();
+    const add = (target: CallGraphNode, via: 'name' | 'call') => {
+      const existing = targets.get(target.id) ?? { id: target.id, name: target.name, path: target.path, line: target.line, via: [] };
+      if (!existing.via.includes(via)) {
+        existing.via.push(via);
+      }
+      targets.set(target.id, existing);
+    };
+    for (const target of namedTargets(match[2] ?? '', production.filter((candidate) => dirname(candidate.path) === dirname(node.path)))) {
+      add(target, 'name');
+    }
+    // Helpers in test files are walked through; package code is where the walk stops.
+    const visited = new Set([node.id]);
+    let frontier = [node.id];
+    while (frontier.length > 0) {
+      const next: string[] = [];
+      for (const id of frontier) {
+        for (const to of callees.get(id) ?? []) {
+          const target = calls.nodes.get(to)!;
+          if (visited.has(to)) {
+            continue;
+          }
+          visited.add(to);
+          if (isTestFile(target.path)) {
+            next.push(to);
+          } else if (target.path.endsWith('.go')) {
+            add(target, 'call');
+          }
+        }
+      }
+      frontier = next;
+    }
+    tests.push({
+      name: node.name,
+      kind: TEST_KINDS[match[1]!]!,
+      path: node.path,
+      line: node.line,
+      targets: [...targets.values()].sort((left, right) => left.path.localeCompare(right.path) || left.line - right.line),
+    });
+  }
+
+  const testsOf = new Map<string, string[]>();
+  for (const test of tests) {
+    for (const target of test.targets) {
+      testsOf.set(target.id, [...(testsOf.get(target.id) ?? []), test.name]);
+    }
+  }
+  const exported = (node: CallGraphNode) => /^[A-Z]/.test(node.name.split('.').pop() ?? '');
+  const entries = production
+    .filter((node) => inScope(node.path) && !UNTESTABLE.has(node.name))
+    .map((node) => ({ id: node.id, name: node.name, path: node.path, line: node.line, exported: exported(node), tests: testsOf.get(node.id) ?? [] }))
+    .sort((left, right) => left.path.localeCompare(right.path) || left.line - right.line);
+  return {
+    tests: tests.filter((test) => inScope(test.path)).sort((left, right) => left.path.localeCompare(right.path) || left.line - right.line),
+    tested: entries.filter((entry) => entry.tests.length > 0),
+    untested: entries.filter((entry) => entry.tests.length === 0).sort((left, right) => Number(right.exported) - Number(left.exported)),
+    scannedFiles: files.size,
+  };
+}
+
+// The package functions a test name refers to: the longest name it spells
+// out, followed by nothing or a new word (TestParseEmpty -> Parse). Names are
+// capitalized, as unexported ones are in test names (TestParseHeader -> parseHeader).
+function namedTargets(rest: string, candidates: CallGraphNode[]): CallGraphNode[] {
+  const spelled = rest.replace(/_/g, '');
+  let best: CallGraphNode[] = [];
+  let bestLength = 0;
+  for (const candidate of candidates) {
+    const name = candidate.name.split('.').map((part) => part.charAt(0).toUpperCase() + part.slice(1)).join('');
+    const boundary = spelled[name.length];
+    if (!spelled.startsWith(name) || (boundary !== undefined && !/[A-Z0-9]/.test(boundary)) || name.length < bestLength) {
+      continue;
+    }
+    if (name.length > bestLength) {
+      best = [];
+      bestLength = name.length;
+    }
+    best.push(candidate);
+  }
+  return best;
+}
@@ -0,0 +1,266 @@
+import { createHash } from 'node:crypto';
+import { readFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import type { MemoryEntry } from '@defai.digital/state-store';
+import { TODO_BACKLOG_NAMESPACE } from './handoff.js';
+import { extractDeclarations, supportsStructuralDiff, type DeclarationKind } from './structural-diff.js';
+import { harvestTechDebt, type TechDebtItem } from './tech-debt.js';
+
+export type TodoKind = 'todo' | 'fixme' | 'hack';
+
+export const TODO_KINDS: readonly TodoKind[] = ['todo', 'fixme', 'hack'];
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+const DEFAULT_LIMIT = 200;
+
+export interface TodoItem extends TechDebtItem {
+  kind: TodoKind;
+  // Stable across line moves: derived from the path, enclosing symbol, kind, and text.
+  id: string;
+  // Innermost declaration containing the marker, as `Class.method` for methods.
+  symbol?: string;
+  symbolKind?: DeclarationKind;
+  // Whole days since the line was committed; unset for uncommitted lines.
+  ageDays?: number;
+  // The backlog entry tracking this TODO, once linked.
+  backlog?: { key: string; status: string };
+}
+
+export interface TodoFilter {
+  paths?: string[];
+  kinds?: TodoKind[];
+  owner?: string;
+  // Matches `Server.Start` exactly, everything under `Server`, or the bare name `Start`.
+  symbol?: string;
+  minAgeDays?: number;
+}
+
+export interface RuntimeTodoResponse {
+  items: TodoItem[];
+  counts: Record<TodoKind, number>;
+  scannedFiles: number;
+  truncated: boolean;
+}
+
+export interface RuntimeTodoLinkResponse {
+  created: string[];
+  updated: string[];
+  // Backlog entries whose TODO is gone from the code.
+  resolved: string[];
+  items: TodoItem[];
+}
+
+export interface TodoBacklogStateAccess {
+  listMemory(namespace?: string): Promise<MemoryEntry[]>;
+  storeMemory(entry: { key: string; namespace?: string; value: unknown }): Promise<unknown>;
+}
+
+/**
+ * Lists TODO, FIXME, and HACK markers with the symbol they sit in, their age
+ * from git blame, and the backlog entry tracking them when one exists.
+ * Oldest first, so the longest-standing debt leads.
+ */
+export async function listTodos(request: TodoFilter & {
+  basePath: string;
+  state?: TodoBacklogStateAccess;
+  limit?: number;
+  now?: Date;
+}): Promise<RuntimeTodoResponse> {
+  const { items, scannedFiles } = await collectTodos(request.basePath, request, request.now ?? new Date());
+  if (request.state !== undefined) {
+    const backlog = new Map((await request.state.listMemory(TODO_BACKLOG_NAMESPACE)).map((entry) => [entry.key, entry]));
+    for (const item of items) {
+      const entry = backlog.get(item.id);
+      if (entry !== undefined) {
+        item.backlog = { key: entry.key, status: backlogStatus(entry) };
+      }
+    }
+  }
+  const counts: Record<TodoKind, number> = { todo: 0, fixme: 0, hack: 0 };
+  for (const item of items) {
+    counts[item.kind] += 1;
+  }
+  const limit = request.limit ?? DEFAULT_LIMIT;
+  return { items: items.slice(0, limit), counts, scannedFiles, truncated: items.length > limit };
+}
+
+/**
+ * Keeps the backlog (the `backlog` memory namespace) in step with the code:
+ * each matching TODO gets an entry keyed by its id, existing entries follow
+ * the marker when it moves, and open entries whose TODO has disappeared from
+ * the scanned scope are marked resolved. Status, priority, and other fields
+ * set on an entry by hand are kept.
+ */
+export async function linkTodos(request: TodoFilter & {
+  basePath: string;
+  state: TodoBacklogStateAccess;
+  now?: Date;
+}): Promise<RuntimeTodoLinkResponse> {
+  const now = request.now ?? new Date();
+  const { items } = await collectTodos(request.basePath, request, now);
+  const backlog = new Map((await request.state.listMemory(TODO_BACKLOG_NAMESPACE)).map((entry) => [entry.key, entry]));
+  const created: string[] = [];
+  const updated: string[] = [];
+  for (const item of items) {
+    const existing = backlog.get(item.id);
+    const previous: Record<string, unknown> = existing !== undefined && isRecord(existing.value) ? existing.value : {};
+    const value = {
+      title: `${item.kind.toUpperCase()}: ${item.text || `${item.path}:${item.line}`}`,
+      status: 'open',
+      ...previous,
+      source: 'todo',
+      kind: item.kind,
+      path: item.path,
+      line: item.line,
+      ...(item.symbol !== undefined ? { symbol: item.symbol } : {}),
+      text: item.text,
+      ...((item.owner ?? item.author) !== undefined && previous.owner === undefined ? { owner: item.owner ?? item.author } : {}),
+      ...(item.authoredAt !== undefined ? { authoredAt: item.authoredAt } : {}),
+    };
+    if (existing === undefined) {
+      created.push(item.id);
+    } else if (JSON.stringify(previous) === JSON.stringify(value)) {
+      item.backlog = { key: item.id, status: backlogStatus(existing) };
+      continue;
+    } else {
+      updated.push(item.id);
+    }
+    await request.state.storeMemory({ key: item.id, namespace: TODO_BACKLOG_NAMESPACE, value });
+    item.backlog = { key: item.id, status: String(value.status) };
+  }
+
+  const current = new Set(items.map((item) => item.id));
+  const kinds = new Set<string>(request.kinds !== undefined && request.kinds.length > 0 ? request.kinds : TODO_KINDS);
+  const prefixes = normalizePrefixes(request.paths);
+  const resolved: string[] = [];
+  // An age filter hides TODOs that still exist, so nothing can be resolved under one.
+  for (const entry of request.minAgeDays === undefined ? backlog.values() : []) {
+    const value = entry.value;
+    if (current.has(entry.key) || !isRecord(value) || value.source !== 'todo' || backlogStatus(entry) !== 'open') {
+      continue;
+    }
+    if (typeof value.path !== 'string' || !kinds.has(String(value.kind)) || !matchesPrefix(value.path, prefixes)) {
+      continue;
+    }
+    // A TODO filtered out by owner or symbol wasn't looked for, so its absence proves nothing.
+    if (request.owner !== undefined && String(value.owner ?? '').toLowerCase() !== request.owner.replace(/^@/, '').toLowerCase()) {
+      continue;
+    }
+    if (request.symbol !== undefined && !matchesTodoSymbol(typeof value.symbol === 'string' ? value.symbol : undefined, request.symbol)) {
+      continue;
+    }
+    await request.state.storeMemory({
+      key: entry.key,
+      namespace: TODO_BACKLOG_NAMESPACE,
+      value: { ...value, status: 'resolved', resolvedAt: now.toISOString() },
+    });
+    resolved.push(entry.key);
+  }
+  return { created, updated, resolved, items };
+}
+
+/**
+ * Reads the TODO scope a workflow asks for through `input.todos`: `true` for
+ * the whole workspace, a path, or an object with `paths`, `kinds`, `owner`,
+ * and `symbol`. Returns undefined when the input has none.
+ */
+export function resolveTodoScope(value: unknown): TodoFilter | undefined {
+  if (value === true) {
+    return {};
+  }
+  if (typeof value === 'string') {
+    return { paths: [value] };
+  }
+  if (!isRecord(value)) {
+    return undefined;
+  }
+  const paths = typeof value.paths === 'string' ? [value.paths] : stringArray(value.paths);
+  const kinds = stringArray(value.kinds).filter((kind): kind is TodoKind => (TODO_KINDS as readonly string[]).includes(kind));
+  return {
+    ...(paths.length > 0 ? { paths } : {}),
+    ...(kinds.length > 0 ? { kinds } : {}),
+    ...(typeof value.owner === 'string' ? { owner: value.owner } : {}),
+    ...(typeof value.symbol === 'string' ? { symbol: value.symbol } : {}),
+  };
+}
+
+// One line per TODO, for prompts: `- FIXME src/cart.ts:12 (Cart.total) overflows [todo-1a2b3c4d]`.
+export function renderTodoList(items: TodoItem[]): string {
+  if (items.length === 0) {
+    return 'No TODO, FIXME, or HACK markers in scope.';
+  }
+  return items.map((item) => `- ${item.kind.toUpperCase()} ${item.path}:${item.line}${item.symbol !== undefined ? ` (${item.symbol})` : ''} ${item.text || '(no text)'} [${item.id}]`).join('\n');
+}
+
+export function matchesTodoSymbol(symbol: string | undefined, filter: string): boolean {
+  if (symbol === undefined) {
+    return false;
+  }
+  return symbol === filter || symbol.startsWith(`${filter}.`) || symbol.endsWith(`.${filter}`);
+}
+
+async function collectTodos(basePath: string, filter: TodoFilter, now: Date): Promise<{ items: TodoItem[]; scannedFiles: number }> {
+  const harvested = await harvestTechDebt({
+    basePath,
+    paths: filter.paths,
+    kinds: filter.kinds !== undefined && filter.kinds.length > 0 ? [...filter.kinds] : [...TODO_KINDS],
+    owner: filter.owner,
+    blame: true,
+    limit: Number.POSITIVE_INFINITY,
+  });
+  const byPath = new Map<string, TechDebtItem[]>();
+  for (const item of harvested.items) {
+    byPath.set(item.path, [...(byPath.get(item.path) ?? []), item]);
+  }
+
+  const items: TodoItem[] = [];
+  for (const [path, found] of byPath) {
+    const declarations = supportsStructuralDiff(path)
+      ? extractDeclarations(await readFile(join(basePath, path), 'utf8'), path)
+      : [];
+    const seen = new Map<string, number>();
+    for (const item of found) {
+      const enclosing = declarations
+        .filter((declaration) => declaration.line <= item.line && item.line <= declaration.endLine)
+        .sort((left, right) => right.line - left.line || left.endLine - right.endLine)[0];
+      const base = [item.path, enclosing?.name ?? '', item.kind, item.text].join('\0');
+      // Identical markers in one symbol are told apart by their order.
+      const occurrence = seen.get(base) ?? 0;
+      seen.set(base, occurrence + 1);
+      const authoredAt = item.authoredAt !== undefined && item.authoredAt.length > 0 ? Date.parse(item.authoredAt) : Number.NaN;
+      items.push({
+        ...item,
+        kind: item.kind as TodoKind,
+        id: `todo-${createHash('sha1').update(occurrence === 0 ? base : `${base}\0${occurrence}`).digest('hex').slice(0, 8)}`,
+        ...(enclosing !== undefined ? { symbol: enclosing.name, symbolKind: enclosing.kind } : {}),
+        ...(Number.isNaN(authoredAt) ? {} : { ageDays: Math.max(0, Math.floor((now.getTime() - authoredAt) / DAY_MS)) }),
+      });
+    }
+  }
+
+  const filtered = items
+    .filter((item) => filter.symbol === undefined || matchesTodoSymbol(item.symbol, filter.symbol))
+    .filter((item) => filter.minAgeDays === undefined || (item.ageDays ?? 0) >= filter.minAgeDays)
+    .sort((left, right) => (right.ageDays ?? -1) - (left.ageDays ?? -1) || left.path.localeCompare(right.path) || left.line - right.line);
+  return { items: filtered, scannedFiles: harvested.scannedFiles };
+}
+
+function backlogStatus(entry: MemoryEntry): string {
+  return isRecord(entry.value) && typeof entry.value.status === 'string' ? entry.value.status : 'open';
+}
+
+function normalizePrefixes(paths: string[] | undefined): string[] {
+  return (paths ?? []).map((path) => path.replace(/^\.\//, '').replace(/\/+$/, '')).filter((path) => path.length > 0 && path !== '.');
+}
+
+function matchesPrefix(path: string, prefixes: string[]): boolean {
+  return prefixes.length === 0 || prefixes.some((prefix) => path === prefix || path.startsWith(`${prefix}/`));
+}
+
+function stringArray(value: unknown): string[] {
+  return Array.isArray(value) ? value.filter((entry): entry is string => typeof entry === 'string') : [];
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
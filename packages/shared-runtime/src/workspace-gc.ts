@@ -0,0 +1,350 @@
+import { execFile } from 'node:child_process';
+import { readdir, rm, stat } from 'node:fs/promises';
+import { tmpdir } from 'node:os';
+import { join, resolve } from 'node:path';
+import { promisify } from 'node:util';
+import type { SessionEntry } from '@defai.digital/state-store';
+import { EXPERIMENT_SANDBOX_PREFIX } from './experiment.js';
+import { readSessionRecording, SESSION_RECORDING_OBJECTS_DIR, SESSION_RECORDINGS_DIR, SESSION_REPLAY_SANDBOX_PREFIX } from './session-recording.js';
+
+const execFileAsync = promisify(execFile);
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+export const DEFAULT_GC_MAX_AGE_DAYS = 14;
+export const DEFAULT_GC_BRANCH_PREFIX = 'ax/';
+// Scratch space `ax apply`, experiments, session replays, sync, and backup make and remove again.
+const SANDBOX_PREFIXES = ['ax-apply-', EXPERIMENT_SANDBOX_PREFIX, SESSION_REPLAY_SANDBOX_PREFIX, 'automatosx-sync-', 'automatosx-backup-'];
+// Never removed, whatever the prefix, along with the base branch.
+const PROTECTED_BRANCHES = ['main', 'master'];
+
+export type GcAction = 'remove' | 'keep';
+
+export interface StaleBranch {
+  name: string;
+  lastCommitAt: string;
+  ageDays: number;
+  subject: string;
+  // Commits not on the base branch, and the files they change; none means merged.
+  ahead: number;
+  files: number;
+  worktree?: string;
+  action: GcAction;
+  reason?: string;
+}
+
+export interface StaleWorktree {
+  path: string;
+  branch?: string;
+  // Whole days since the checked-out commit; unset when the directory is gone.
+  ageDays?: number;
+  missing: boolean;
+  // Uncommitted and untracked files.
+  changes: number;
+  action: GcAction;
+  reason?: string;
+}
+
+export interface StaleSandbox {
+  path: string;
+  ageDays: number;
+  files: number;
+  bytes: number;
+  action: GcAction;
+}
+
+export interface StaleRecording {
+  sessionId: string;
+  path: string;
+  // Days since the last event was recorded.
+  ageDays: number;
+  events: number;
+  bytes: number;
+}
+
+export interface WorkspaceGcReport {
+  base?: string;
+  branches: StaleBranch[];
+  worktrees: StaleWorktree[];
+  sandboxes: StaleSandbox[];
+  // Session recordings with nothing recorded for `maxAgeDays`, and the file contents only they refer to.
+  recordings: StaleRecording[];
+  recordingObjects: { files: number; bytes: number };
+}
+
+export interface StaleSession {
+  sessionId: string;
+  task: string;
+  ageDays: number;
+  participants: number;
+}
+
+export interface RuntimeGcResponse extends WorkspaceGcReport {
+  dryRun: boolean;
+  maxAgeDays: number;
+  branchPrefix: string;
+  // Active sessions with no activity for `maxAgeDays`, closed as failed like `ax cleanup sessions`.
+  sessions: StaleSession[];
+}
+
+export interface WorkspaceGcRequest {
+  basePath: string;
+  maxAgeDays?: number;
+  branchPrefix?: string;
+  // Also removes unmerged branches and worktrees with uncommitted changes.
+  force?: boolean;
+  dryRun?: boolean;
+  tmpDir?: string;
+  now?: Date;
+}
+
+/**
+ * Finds `ax/` branches and their worktrees untouched for `maxAgeDays`, and
+ * scratch directories left behind by interrupted runs and session recordings
+ * as old, with what each holds. Unless `dryRun`, removes them: merged branches and clean worktrees always,
+ * unmerged branches and worktrees with changes only with `force`. The
+ * current branch, the base branch, main and master, locked worktrees, and
+ * branches checked out in a kept worktree are never removed. Outside a git
+ * repository only sandboxes and recordings are looked at.
+ */
+export async function collectWorkspaceGarbage(request: WorkspaceGcRequest): Promise<WorkspaceGcReport> {
+  const now = request.now ?? new Date();
+  const maxAgeDays = request.maxAgeDays ?? DEFAULT_GC_MAX_AGE_DAYS;
+  const prefix = request.branchPrefix ?? DEFAULT_GC_BRANCH_PREFIX;
+  const force = request.force === true;
+  if (!Number.isFinite(maxAgeDays) || maxAgeDays <= 0) {
+    throw new Error(`gc max age must be a positive number of days, got ${maxAgeDays}`);
+  }
+  // An empty prefix would match every branch in the repository.
+  if (prefix.trim().length === 0) {
+    throw new Error('gc branch prefix must not be empty');
+  }
+  const git = (args: string[], cwd = request.basePath) => execFileAsync('git', args, { cwd, maxBuffer: 16 * 1024 * 1024 }).then(({ stdout }) => stdout.trim());
+
+  const sandboxes = await findSandboxes(request.tmpDir ?? tmpdir(), now, maxAgeDays);
+  const { recordings, recordingObjects, orphans } = await findStaleRecordings(request.basePath, now, maxAgeDays);
+  const report: WorkspaceGcReport = { branches: [], worktrees: [], sandboxes, recordings, recordingObjects };
+  let current: string;
+  try {
+    current = await git(['rev-parse', '--abbrev-ref', 'HEAD']);
+  } catch {
+    await removeScratch(report, orphans, request.dryRun === true);
+    return report;
+  }
+  const base = await resolveBaseBranch(git, current, prefix);
+  report.base = base;
+  // A remote base such as origin/main also protects its local main.
+  const protectedBranches = new Set([...PROTECTED_BRANCHES, ...(base !== undefined ? [base, base.replace(/^[^/]+\//, '')] : [])]);
+  const ageDays = (at: string) => Math.floor((now.getTime() - Date.parse(at)) / DAY_MS);
+
+  // The first entry is the main working tree.
+  const worktrees = parseWorktrees(await git(['worktree', 'list', '--porcelain'])).slice(1);
+  const worktreesByBranch = new Map<string, StaleWorktree>();
+  for (const worktree of worktrees) {
+    const branch = worktree.branch;
+    if (!worktree.missing && (branch === undefined || !branch.startsWith(prefix))) {
+      continue;
+    }
+    let changes = 0;
+    let committedAt: string | undefined;
+    if (!worktree.missing) {
+      changes = (await git(['status', '--porcelain'], worktree.path).catch(() => '')).split('\n').filter((line) => line.length > 0).length;
+      committedAt = await git(['log', '-1', '--format=%cI'], worktree.path).catch(() => undefined);
+    }
+    const age = committedAt !== undefined ? ageDays(committedAt) : undefined;
+    if (!worktree.missing && (age === undefined || age < maxAgeDays)) {
+      continue;
+    }
+    const reason = worktree.locked
+      ? 'locked'
+      : changes > 0 && !force ? `${changes} uncommitted changes; --force discards them` : undefined;
+    const stale: StaleWorktree = {
+      path: worktree.path,
+      ...(branch !== undefined ? { branch } : {}),
+      ...(age !== undefined ? { ageDays: age } : {}),
+      missing: worktree.missing,
+      changes,
+      action: reason === undefined ? 'remove' : 'keep',
+      ...(reason !== undefined ? { reason } : {}),
+    };
+    report.worktrees.push(stale);
+    if (branch !== undefined) {
+      worktreesByBranch.set(branch, stale);
+    }
+  }
+
+  const refs = await git(['for-each-ref', '--format=%(refname:short)%09%(committerdate:iso-strict)%09%(subject)', 'refs/heads']);
+  for (const line of refs.split('\n').filter((row) => row.length > 0)) {
+    const [name = '', lastCommitAt = '', ...subject] = line.split('\t');
+    const age = ageDays(lastCommitAt);
+    if (!name.startsWith(prefix) || age < maxAgeDays) {
+      continue;
+    }
+    const ahead = base !== undefined ? Number(await git(['rev-list', '--count', `${base}..${name}`])) : 0;
+    const files = ahead > 0 && base !== undefined ? (await git(['diff', '--name-only', `${base}...${name}`])).split('\n').filter((path) => path.length > 0).length : 0;
+    const worktree = worktreesByBranch.get(name);
+    const checkedOut = worktree === undefined ? worktrees.find((entry) => entry.branch === name) : worktree.action === 'keep' ? worktree : undefined;
+    const reason = name === current
+      ? 'current branch'
+      : protectedBranches.has(name) ? 'base branch'
+        : checkedOut !== undefined ? `checked out in ${checkedOut.path}`
+          : base === undefined ? 'no base branch to compare with'
+            : ahead > 0 && !force ? `${ahead} unmerged commits; --force deletes them` : undefined;
+    report.branches.push({
+      name,
+      lastCommitAt,
+      ageDays: age,
+      subject: subject.join('\t'),
+      ahead,
+      files,
+      ...(worktree !== undefined ? { worktree: worktree.path } : {}),
+      action: reason === undefined ? 'remove' : 'keep',
+      ...(reason !== undefined ? { reason } : {}),
+    });
+  }
+
+  if (request.dryRun !== true) {
+    for (const worktree of report.worktrees.filter((item) => item.action === 'remove' && !item.missing)) {
+      await git(['worktree', 'remove', ...(worktree.changes > 0 ? ['--force'] : []), worktree.path]);
+    }
+    if (report.worktrees.some((item) => item.action === 'remove' && item.missing)) {
+      await git(['worktree', 'prune']);
+    }
+    for (const branch of report.branches.filter((item) => item.action === 'remove')) {
+      // Merged is judged against the base branch, which `git branch -d` doesn't know about.
+      await git(['branch', '-D', branch.name]);
+    }
+  }
+  await removeScratch(report, orphans, request.dryRun === true);
+  return report;
+}
+
+export function findStaleSessions(sessions: SessionEntry[], maxAgeDays: number, now: Date): StaleSession[] {
+  return sessions.flatMap((session) => {
+    const ageMs = now.getTime() - Date.parse(session.updatedAt);
+    return session.status === 'active' && ageMs >= maxAgeDays * DAY_MS
+      ? [{ sessionId: session.sessionId, task: session.task, ageDays: Math.floor(ageMs / DAY_MS), participants: session.participants.length }]
+      : [];
+  });
+}
+
+// origin's default branch when there is one, else a local main or master.
+async function resolveBaseBranch(git: (args: string[]) => Promise<string>, current: string, prefix: string): Promise<string | undefined> {
+  const remoteHead = await git(['symbolic-ref', '--quiet', '--short', 'refs/remotes/origin/HEAD']).catch(() => '');
+  for (const candidate of [remoteHead, 'main', 'master']) {
+    if (candidate.length > 0 && await git(['rev-parse', '--verify', '--quiet', candidate]).then(() => true, () => false)) {
+      return candidate;
+    }
+  }
+  return current !== 'HEAD' && !current.startsWith(prefix) ? current : undefined;
+}
+
+function parseWorktrees(porcelain: string): Array<{ path: string; branch?: string; missing: boolean; locked: boolean }> {
+  return porcelain.split(/\n\n+/).flatMap((block) => {
+    const lines = block.split('\n');
+    const path = lines.find((line) => line.startsWith('worktree '))?.slice('worktree '.length);
+    if (path === undefined) {
+      return [];
+    }
+    const branch = lines.find((line) => line.startsWith('branch '))?.slice('branch '.length).replace(/^refs\/heads\//, '');
+    return [{
+      path: resolve(path),
+      ...(branch !== undefined ? { branch } : {}),
+      missing: lines.some((line) => line.startsWith('prunable')),
+      locked: lines.some((line) => line.startsWith('locked')),
+    }];
+  });
+}
+
+async function findSandboxes(directory: string, now: Date, maxAgeDays: number): Promise<StaleSandbox[]> {
+  let names: string[];
+  try {
+    names = await readdir(directory);
+  } catch {
+    return [];
+  }
+  const sandboxes: StaleSandbox[] = [];
+  for (const name of names.filter((entry) => SANDBOX_PREFIXES.some((prefix) => entry.startsWith(prefix))).sort()) {
+    const path = join(directory, name);
+    const info = await stat(path).catch(() => undefined);
+    if (info === undefined || now.getTime() - info.mtimeMs < maxAgeDays * DAY_MS) {
+      continue;
+    }
+    const size = await measure(path);
+    sandboxes.push({ path, ageDays: Math.floor((now.getTime() - info.mtimeMs) / DAY_MS), ...size, action: 'remove' });
+  }
+  return sandboxes;
+}
+
+// Objects are removed only once no kept recording refers to them and they are as old, so a run recording now keeps its own.
+async function findStaleRecordings(
+  basePath: string,
+  now: Date,
+  maxAgeDays: number,
+): Promise<{ recordings: StaleRecording[]; recordingObjects: { files: number; bytes: number }; orphans: string[] }> {
+  const directory = join(basePath, SESSION_RECORDINGS_DIR);
+  const recordings: StaleRecording[] = [];
+  const referenced = new Set<string>();
+  for (const name of (await readdir(directory).catch(() => [])).filter((entry) => entry.endsWith('.jsonl')).sort()) {
+    const path = join(directory, name);
+    const info = await stat(path).catch(() => undefined);
+    if (info === undefined) {
+      continue;
+    }
+    const sessionId = decodeURIComponent(name.slice(0, -'.jsonl'.length));
+    const events = await readSessionRecording(basePath, sessionId);
+    if (now.getTime() - info.mtimeMs < maxAgeDays * DAY_MS) {
+      for (const event of events) {
+        if (event.kind === 'file') {
+          for (const hash of [event.before, event.after]) {
+            if (hash !== undefined) referenced.add(hash);
+          }
+        }
+      }
+      continue;
+    }
+    recordings.push({ sessionId, path, ageDays: Math.floor((now.getTime() - info.mtimeMs) / DAY_MS), events: events.length, bytes: info.size });
+  }
+
+  const orphans: string[] = [];
+  const recordingObjects = { files: 0, bytes: 0 };
+  const objectsDir = join(directory, SESSION_RECORDING_OBJECTS_DIR);
+  for (const shard of (await readdir(objectsDir).catch(() => [])).sort()) {
+    for (const rest of (await readdir(join(objectsDir, shard)).catch(() => [])).sort()) {
+      const path = join(objectsDir, shard, rest);
+      const info = await stat(path).catch(() => undefined);
+      if (info === undefined || referenced.has(`${shard}${rest}`) || now.getTime() - info.mtimeMs < maxAgeDays * DAY_MS) {
+        continue;
+      }
+      orphans.push(path);
+      recordingObjects.files += 1;
+      recordingObjects.bytes += info.size;
+    }
+  }
+  return { recordings, recordingObjects, orphans };
+}
+
+async function measure(path: string): Promise<{ files: number; bytes: number }> {
+  const info = await stat(path).catch(() => undefined);
+  if (info === undefined) {
+    return { files: 0, bytes: 0 };
+  }
+  if (!info.isDirectory()) {
+    return { files: 1, bytes: info.size };
+  }
+  const total = { files: 0, bytes: 0 };
+  for (const entry of await readdir(path).catch(() => [])) {
+    const size = await measure(join(path, entry));
+    total.files += size.files;
+    total.bytes += size.bytes;
+  }
+  return total;
+}
+
+async function removeScratch(report: WorkspaceGcReport, recordingObjects: string[], dryRun: boolean): Promise<void> {
+  if (dryRun) {
+    return;
+  }
+  for (const path of [...report.sandboxes.map((sandbox) => sandbox.path), ...report.recordings.map((recording) => recording.path), ...recordingObjects]) {
+    await rm(path, { recursive: true, force: true });
+  }
+}
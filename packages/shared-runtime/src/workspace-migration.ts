@@ -0,0 +1,268 @@
+import { existsSync } from 'node:fs';
+import { copyFile, mkdir, readdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { basename, dirname, extname, join, relative } from 'node:path';
+import { parse as parseYaml, stringify as stringifyYaml } from 'yaml';
+
+export const CURRENT_PRODUCT_VERSION = '14.0.0';
+export const CURRENT_CONFIG_SCHEMA_VERSION = 1;
+
+const AUTOMATOSX_DIR = '.automatosx';
+const LEGACY_AGENTS_DIR = join(AUTOMATOSX_DIR, 'agents');
+const MIN_STEP_TIMEOUT_MS = 1000;
+const DEFAULT_WORKFLOW_VERSION = '1.0.0';
+
+export type MigrationTarget = 'config' | 'workflow' | 'agent';
+
+export interface MigrationChange {
+  target: MigrationTarget;
+  path: string;
+  changes: string[];
+}
+
+export interface RuntimeMigrationResponse {
+  dryRun: boolean;
+  backupDir?: string;
+  changes: MigrationChange[];
+  notices: string[];
+}
+
+export interface LegacyAgentRegistration {
+  agentId: string;
+  name: string;
+  capabilities: string[];
+  metadata: Record<string, unknown>;
+}
+
+export interface WorkspaceMigrationRequest {
+  basePath: string;
+  workflowDir?: string;
+  dryRun?: boolean;
+  registerAgent: (entry: LegacyAgentRegistration) => Promise<unknown>;
+}
+
+interface PlannedRewrite {
+  target: MigrationTarget;
+  absolutePath: string;
+  changes: string[];
+  content?: string;
+  agent?: LegacyAgentRegistration;
+}
+
+export async function migrateWorkspace(request: WorkspaceMigrationRequest): Promise<RuntimeMigrationResponse> {
+  const { basePath } = request;
+  const planned: PlannedRewrite[] = [];
+  const notices: string[] = [];
+
+  const configPlan = await planConfigMigration(join(basePath, AUTOMATOSX_DIR, 'config.json'));
+  if (configPlan !== undefined) {
+    planned.push(configPlan);
+  }
+  if (request.workflowDir !== undefined) {
+    planned.push(...await planWorkflowMigrations(request.workflowDir));
+  }
+  planned.push(...await planAgentMigrations(basePath));
+
+  const runtimeDir = join(basePath, AUTOMATOSX_DIR, 'runtime');
+  if (existsSync(join(runtimeDir, 'state.json')) && !existsSync(join(runtimeDir, 'state.db'))) {
+    notices.push('Legacy JSON state store found. Run "ax setup --migrate-storage" to import it into SQLite.');
+  }
+
+  const changes = planned.map((plan) => ({
+    target: plan.target,
+    path: relative(basePath, plan.absolutePath),
+    changes: plan.changes,
+  }));
+  if (request.dryRun === true || planned.length === 0) {
+    return { dryRun: request.dryRun === true, changes, notices };
+  }
+
+  const backupDir = join(basePath, AUTOMATOSX_DIR, 'backups', `migrate-${Date.now()}`);
+  for (const plan of planned) {
+    const backupPath = join(backupDir, relative(basePath, plan.absolutePath));
+    await mkdir(dirname(backupPath), { recursive: true });
+    await copyFile(plan.absolutePath, backupPath);
+    if (plan.agent !== undefined) {
+      await request.registerAgent(plan.agent);
+      await rm(plan.absolutePath);
+    } else if (plan.content !== undefined) {
+      await writeFile(plan.absolutePath, plan.content, 'utf8');
+    }
+  }
+
+  return { dryRun: false, backupDir, changes, notices };
+}
+
+async function planConfigMigration(configPath: string): Promise<PlannedRewrite | undefined> {
+  let config: unknown;
+  try {
+    config = JSON.parse(await readFile(configPath, 'utf8'));
+  } catch {
+    return undefined;
+  }
+  if (!isRecord(config)) {
+    return undefined;
+  }
+
+  const changes: string[] = [];
+  if (typeof config.schemaVersion !== 'number') {
+    config.schemaVersion = CURRENT_CONFIG_SCHEMA_VERSION;
+    changes.push(`Added schemaVersion ${CURRENT_CONFIG_SCHEMA_VERSION}.`);
+  }
+  if (typeof config.provider === 'string' && config.defaultProvider === undefined) {
+    config.defaultProvider = config.provider;
+    delete config.provider;
+    changes.push('Renamed provider to defaultProvider.');
+  }
+  const previousVersion = typeof config.productVersion === 'string' ? config.productVersion : undefined;
+  if (previousVersion === undefined || majorVersion(previousVersion) < majorVersion(CURRENT_PRODUCT_VERSION)) {
+    config.productVersion = CURRENT_PRODUCT_VERSION;
+    changes.push(`Set productVersion ${previousVersion ?? '(missing)'} -> ${CURRENT_PRODUCT_VERSION}.`);
+  }
+
+  return changes.length === 0
+    ? undefined
+    : { target: 'config', absolutePath: configPath, changes, content: `${JSON.stringify(config, null, 2)}\n` };
+}
+
+async function planWorkflowMigrations(workflowDir: string): Promise<PlannedRewrite[]> {
+  let fileNames: string[];
+  try {
+    fileNames = await readdir(workflowDir);
+  } catch {
+    return [];
+  }
+
+  const planned: PlannedRewrite[] = [];
+  for (const fileName of fileNames.sort()) {
+    const extension = extname(fileName);
+    if (!['.json', '.yaml', '.yml'].includes(extension)) {
+      continue;
+    }
+    const absolutePath = join(workflowDir, fileName);
+    let workflow: unknown;
+    try {
+      const raw = await readFile(absolutePath, 'utf8');
+      workflow = extension === '.json' ? JSON.parse(raw) : parseYaml(raw);
+    } catch {
+      continue;
+    }
+    if (!isRecord(workflow) || !Array.isArray(workflow.steps)) {
+      continue;
+    }
+
+    const changes = migrateWorkflowDefinition(workflow);
+    if (changes.length > 0) {
+      planned.push({
+        target: 'workflow',
+        absolutePath,
+        changes,
+        content: extension === '.json' ? `${JSON.stringify(workflow, null, 2)}\n` : stringifyYaml(workflow),
+      });
+    }
+  }
+  return planned;
+}
+
+export function migrateWorkflowDefinition(workflow: Record<string, unknown>): string[] {
+  const changes: string[] = [];
+  if (typeof workflow.id === 'string' && workflow.workflowId === undefined) {
+    workflow.workflowId = workflow.id;
+    delete workflow.id;
+    changes.push('Renamed id to workflowId.');
+  }
+  if (typeof workflow.version !== 'string') {
+    workflow.version = DEFAULT_WORKFLOW_VERSION;
+    changes.push(`Added version ${DEFAULT_WORKFLOW_VERSION}.`);
+  }
+
+  const steps = Array.isArray(workflow.steps) ? workflow.steps : [];
+  steps.forEach((step: unknown, index) => {
+    if (!isRecord(step)) {
+      return;
+    }
+    if (typeof step.id === 'string' && step.stepId === undefined) {
+      step.stepId = step.id;
+      delete step.id;
+      changes.push(`Step ${index + 1}: renamed id to stepId.`);
+    }
+    const label = typeof step.stepId === 'string' ? step.stepId : `${index + 1}`;
+    if (typeof step.prompt === 'string') {
+      const config = isRecord(step.config) ? step.config : {};
+      if (config.prompt === undefined) {
+        config.prompt = step.prompt;
+        step.config = config;
+        delete step.prompt;
+        changes.push(`Step ${label}: moved prompt into config.prompt.`);
+      }
+    }
+    if (typeof step.timeout === 'number' && step.timeout < MIN_STEP_TIMEOUT_MS) {
+      changes.push(`Step ${label}: raised timeout ${step.timeout}ms to the ${MIN_STEP_TIMEOUT_MS}ms minimum.`);
+      step.timeout = MIN_STEP_TIMEOUT_MS;
+    }
+  });
+  return changes;
+}
+
+async function planAgentMigrations(basePath: string): Promise<PlannedRewrite[]> {
+  const agentsDir = join(basePath, LEGACY_AGENTS_DIR);
+  let fileNames: string[];
+  try {
+    fileNames = await readdir(agentsDir);
+  } catch {
+    return [];
+  }
+
+  const planned: PlannedRewrite[] = [];
+  for (const fileName of fileNames.sort()) {
+    const extension = extname(fileName);
+    if (!['.json', '.yaml', '.yml'].includes(extension)) {
+      continue;
+    }
+    const absolutePath = join(agentsDir, fileName);
+    let profile: unknown;
+    try {
+      const raw = await readFile(absolutePath, 'utf8');
+      profile = extension === '.json' ? JSON.parse(raw) : parseYaml(raw);
+    } catch {
+      continue;
+    }
+    if (!isRecord(profile)) {
+      continue;
+    }
+
+    const fallbackId = basename(fileName, extension);
+    const agentId = typeof profile.agentId === 'string' ? profile.agentId : fallbackId;
+    const capabilities = asStringList(profile.capabilities) ?? asStringList(profile.abilities) ?? [];
+    const metadata: Record<string, unknown> = { migratedFrom: relative(basePath, absolutePath) };
+    for (const key of ['role', 'description', 'systemPrompt', 'provider']) {
+      if (profile[key] !== undefined) {
+        metadata[key] = profile[key];
+      }
+    }
+    planned.push({
+      target: 'agent',
+      absolutePath,
+      changes: [`Registered agent ${agentId} in the runtime store${capabilities.length > 0 ? ` with ${capabilities.length} capabilities` : ''}.`],
+      agent: {
+        agentId,
+        name: typeof profile.displayName === 'string' ? profile.displayName : typeof profile.name === 'string' ? profile.name : agentId,
+        capabilities,
+        metadata,
+      },
+    });
+  }
+  return planned;
+}
+
+function majorVersion(version: string): number {
+  const major = Number.parseInt(version.split('.')[0] ?? '', 10);
+  return Number.isFinite(major) ? major : 0;
+}
+
+function asStringList(value: unknown): string[] | undefined {
+  return Array.isArray(value) ? value.filter((entry): entry is string => typeof entry === 'string') : undefined;
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
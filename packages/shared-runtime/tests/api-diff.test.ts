@@ -0,0 +1,117 @@
+import { execFile } from 'node:child_process';
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { promisify } from 'node:util';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+
+const execFileAsync = promisify(execFile);
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `api-diff-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const SERVER_V1 = [
+  'package server',
+  '',
+  'type Config struct {',
+  '\tAddr    string',
+  '\tTimeout int',
+  '\tdebug   bool',
+  '}',
+  '',
+  'type Handler interface {',
+  '\tServe(path string) error',
+  '}',
+  '',
+  'type Server struct{ cfg Config }',
+  '',
+  'func NewServer(cfg Config) *Server { return &Server{cfg: cfg} }',
+  '',
+  'func (s *Server) Start() error { return nil }',
+  '',
+  'func (s *Server) Stop() {}',
+  '',
+  'type conn struct{}',
+  '',
+  'func (c *conn) Close() {}',
+].join('\n');
+
+const SERVER_V2 = [
+  'package server',
+  '',
+  'type Config struct {',
+  '\tAddr    string',
+  '\tTimeout int64',
+  '\tTLS     bool',
+  '}',
+  '',
+  'type Handler interface {',
+  '\tServe(path string) error',
+  '\tClose() error',
+  '}',
+  '',
+  'type Server struct{ cfg Config }',
+  '',
+  'func NewServer(cfg Config, logger func(string)) *Server { return &Server{cfg: cfg} }',
+  '',
+  'func (s *Server) Start() error {',
+  '\treturn s.listen()',
+  '}',
+  '',
+  'func (s *Server) listen() error { return nil }',
+  '',
+  'type conn struct{}',
+  '',
+  'func (c *conn) Close() {}',
+  '',
+  'func (c *conn) Flush() {}',
+].join('\n');
+
+describe('exported API diff', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('reports exported Go API changes per package and marks breaking ones', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await execFileAsync('git', ['init', '-b', 'main'], { cwd: tempDir });
+    await execFileAsync('git', ['config', 'user.email', 'test@example.com'], { cwd: tempDir });
+    await execFileAsync('git', ['config', 'user.name', 'Test User'], { cwd: tempDir });
+    await mkdir(join(tempDir, 'server'), { recursive: true });
+    await writeFile(join(tempDir, 'server', 'server.go'), SERVER_V1, 'utf8');
+    await writeFile(join(tempDir, 'server', 'version.go'), 'package server\n\nconst Version = "1"\n', 'utf8');
+    await execFileAsync('git', ['add', '-A'], { cwd: tempDir });
+    await execFileAsync('git', ['commit', '-m', 'v1'], { cwd: tempDir });
+
+    await writeFile(join(tempDir, 'server', 'server.go'), `${SERVER_V2}\n\nconst Version = "1"\n`, 'utf8');
+    await rm(join(tempDir, 'server', 'version.go'));
+    await writeFile(join(tempDir, 'server', 'server_test.go'), 'package server\n', 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const diff = await runtime.apiDiff({ base: 'HEAD' });
+    expect(diff.skipped).toEqual([]);
+    expect(diff.packages).toHaveLength(1);
+    const changes = diff.packages[0]!.changes.map((change) => [change.change, change.name, change.breaking, change.details ?? change.after ?? null]);
+    expect(changes).toEqual([
+      ['changed', 'Config', true, ['changed field Timeout from int to int64', 'added field TLS bool']],
+      ['changed', 'Handler', true, ['added method Close() error']],
+      ['changed', 'NewServer', true, 'func NewServer(cfg Config,logger func(string))*Server'],
+      ['removed', 'Server.Stop', true, null],
+    ]);
+    expect(diff.breaking).toBe(4);
+    expect(diff.summary).toContain('- server: changed type Config (changed field Timeout from int to int64, added field TLS bool) [breaking];');
+
+    await execFileAsync('git', ['add', '-A'], { cwd: tempDir });
+    await execFileAsync('git', ['commit', '-m', 'v2'], { cwd: tempDir });
+    const committed = await runtime.apiDiff({ base: 'HEAD~1', head: 'HEAD' });
+    expect(committed.skipped).toEqual(['server/server_test.go']);
+    expect(committed.packages[0]!.changes).toEqual(diff.packages[0]!.changes);
+  });
+});
@@ -0,0 +1,50 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `ask-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('read-only ask mode', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('grounds answers in matching memory and documentation sections', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeFile(join(tempDir, 'README.md'), [
+      '# Demo',
+      '',
+      '## Installation',
+      'Run npm install.',
+      '',
+      '## Retry policy',
+      'Workflow steps retry three times with exponential backoff.',
+      '',
+    ].join('\n'), 'utf8');
+    await mkdir(join(tempDir, 'docs'), { recursive: true });
+    await writeFile(join(tempDir, 'docs', 'unrelated.md'), '# Colors\nThe logo is blue.\n', 'utf8');
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    await runtime.storeMemory({ key: 'retry-decision', namespace: 'adr', value: 'Retry backoff caps at 30 seconds.' });
+
+    const result = await runtime.askQuestion({ question: 'How does the retry backoff work?', provider: 'claude' });
+
+    expect(result.success).toBe(true);
+    expect(result.sources.map((source) => source.ref)).toEqual(['README.md#Retry policy', 'adr/retry-decision']);
+    expect(result.answer).toContain('Workflow steps retry three times');
+    expect(result.answer).toContain('Retry backoff caps at 30 seconds.');
+    expect(result.answer).not.toContain('The logo is blue.');
+    expect(await runtime.getTrace(result.traceId)).toMatchObject({
+      input: { maxTokens: 800, temperature: 0 },
+    });
+  });
+});
@@ -0,0 +1,74 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { isAxIgnored, isAxIgnoredDirectory, parseAxIgnore } from '../src/axignore.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `axignore-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('axignore', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('matches gitignore patterns and header rules', () => {
+    const rules = parseAxIgnore([
+      '# vendored and generated code',
+      'vendor/',
+      '/dist',
+      '**/*.pb.go',
+      '!testdata/**/*.pb.go',
+      'gen/*.ts',
+      '!vendor/keep.ts',
+      '\\#notes.md',
+      '@header DO NOT EDIT',
+      '',
+    ].join('\n'));
+    expect(rules.headers).toEqual(['DO NOT EDIT']);
+    expect(isAxIgnored(rules, 'vendor/lib/a.ts')).toBe(true);
+    expect(isAxIgnored(rules, 'vendor/keep.ts')).toBe(true);
+    expect(isAxIgnored(rules, 'vendor')).toBe(false);
+    expect(isAxIgnoredDirectory(rules, 'src/vendor')).toBe(true);
+    expect(isAxIgnored(rules, 'dist/index.js')).toBe(true);
+    expect(isAxIgnored(rules, 'src/dist/index.js')).toBe(false);
+    expect(isAxIgnored(rules, 'api/v1/user.pb.go')).toBe(true);
+    expect(isAxIgnored(rules, 'testdata/api/user.pb.go')).toBe(false);
+    expect(isAxIgnored(rules, 'gen/types.ts')).toBe(true);
+    expect(isAxIgnored(rules, 'gen/nested/types.ts')).toBe(false);
+    expect(isAxIgnored(rules, '#notes.md')).toBe(true);
+    expect(isAxIgnored(rules, 'src/app.ts', '// Code generated by tool. DO NOT EDIT.\nexport {};\n')).toBe(true);
+    expect(isAxIgnored(rules, 'src/app.ts', 'export {};\n')).toBe(false);
+  });
+
+  it('keeps ignored files out of the index and semantic memory', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'src'), { recursive: true });
+    await mkdir(join(tempDir, 'vendor'), { recursive: true });
+    await writeFile(join(tempDir, 'src', 'cart.ts'), 'export function total(): number {\n  return 1;\n}\n');
+    await writeFile(join(tempDir, 'src', 'client.ts'), '// @generated by openapi. DO NOT EDIT.\nexport function fetchCart(): void {}\n');
+    await writeFile(join(tempDir, 'vendor', 'lib.ts'), 'export function vendored(): void {}\n');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const stored = await runtime.storeSemanticFile({ path: 'src/client.ts', content: 'export function fetchCart(): void {}\n', namespace: 'code' });
+    expect(stored.chunks).toHaveLength(1);
+    expect((await runtime.findSymbols({ query: 'kind:function' })).symbols.map((symbol) => symbol.name).sort()).toEqual(['fetchCart', 'total', 'vendored']);
+
+    await writeFile(join(tempDir, '.axignore'), 'vendor/\n@header DO NOT EDIT\n');
+    expect((await runtime.findSymbols({ query: 'kind:function' })).symbols.map((symbol) => symbol.name)).toEqual(['total']);
+    const ignored = await runtime.storeSemanticFile({
+      path: 'src/client.ts',
+      content: '// @generated by openapi. DO NOT EDIT.\nexport function fetchCart(): void {}\n',
+      namespace: 'code',
+    });
+    expect(ignored).toMatchObject({ chunks: [], removed: [stored.chunks[0]!.key], ignored: true });
+    expect(await runtime.listSemantic({ namespace: 'code' })).toEqual([]);
+  });
+});
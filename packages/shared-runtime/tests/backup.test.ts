@@ -0,0 +1,97 @@
+import { createHash } from 'node:crypto';
+import { existsSync, mkdirSync } from 'node:fs';
+import { mkdir, readdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { gunzipSync, gzipSync } from 'node:zlib';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `backup-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('state backup and restore', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('round-trips config, memory, and artifacts into a fresh workspace', async () => {
+    const source = createTempDir();
+    const target = createTempDir();
+    tempDirs.push(source, target);
+
+    const runtime = createSharedRuntimeService({ basePath: source });
+    await runtime.storeMemory({ key: 'deploy-notes', value: { region: 'eu-west-1' } });
+    await runtime.setConfig('defaultProvider', 'gemini');
+    await mkdir(join(source, '.automatosx', 'reviews', 'trace-1'), { recursive: true });
+    await writeFile(join(source, '.automatosx', 'reviews', 'trace-1', 'report.md'), '# Review\n', 'utf8');
+
+    const backup = await runtime.createBackup({ outputPath: join(source, 'state.axbackup') });
+    expect(backup.files.map((file) => file.path)).toEqual(expect.arrayContaining([
+      'config.json',
+      'reviews/trace-1/report.md',
+      'runtime/state.db',
+    ]));
+    expect((await runtime.verifyBackup({ archivePath: backup.archivePath })).checksum).toBe(backup.checksum);
+
+    await mkdir(join(target, '.automatosx'), { recursive: true });
+    await writeFile(join(target, '.automatosx', 'stale.json'), '{}\n', 'utf8');
+    const restored = await createSharedRuntimeService({ basePath: target }).restoreBackup({ archivePath: backup.archivePath });
+    expect(restored.safetyBackupPath).toBeDefined();
+    expect(existsSync(restored.safetyBackupPath!)).toBe(true);
+    // The state directory is replaced whole; what it held before lives on in the safety backup.
+    expect(existsSync(join(target, '.automatosx', 'stale.json'))).toBe(false);
+    expect((await readdir(target)).filter((name) => name.startsWith('.automatosx'))).toEqual(['.automatosx']);
+
+    const reopened = createSharedRuntimeService({ basePath: target });
+    expect(await reopened.getConfig('defaultProvider')).toBe('gemini');
+    expect((await reopened.getMemory('deploy-notes'))?.value).toEqual({ region: 'eu-west-1' });
+    expect(await readFile(join(target, '.automatosx', 'reviews', 'trace-1', 'report.md'), 'utf8')).toBe('# Review\n');
+  });
+
+  it('rejects tampered archives before writing anything', async () => {
+    const source = createTempDir();
+    const target = createTempDir();
+    tempDirs.push(source, target);
+
+    await mkdir(join(source, '.automatosx'), { recursive: true });
+    await writeFile(join(source, '.automatosx', 'config.json'), '{"defaultProvider":"claude"}\n', 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: source });
+    const backup = await runtime.createBackup({ outputPath: join(source, 'state.axbackup') });
+
+    const archive = JSON.parse(gunzipSync(await readFile(backup.archivePath)).toString('utf8')) as { contents: Record<string, string> };
+    archive.contents['config.json'] = Buffer.from('{"defaultProvider":"evil"}\n').toString('base64');
+    await writeFile(backup.archivePath, gzipSync(Buffer.from(JSON.stringify(archive))));
+
+    await expect(runtime.verifyBackup({ archivePath: backup.archivePath })).rejects.toThrow('integrity check failed for config.json');
+    await expect(runtime.restoreBackup({ archivePath: backup.archivePath, basePath: target })).rejects.toThrow('integrity check failed');
+    expect(existsSync(join(target, '.automatosx', 'config.json'))).toBe(false);
+  });
+
+  it('rejects archives with paths outside the state directory before writing anything', async () => {
+    const target = createTempDir();
+    tempDirs.push(target);
+    const entry = (path: string, data: Buffer) => ({ path, size: data.length, sha256: createHash('sha256').update(data).digest('hex') });
+    const config = Buffer.from('{"defaultProvider":"claude"}\n');
+    const escape = Buffer.from('pwned\n');
+    const files = [entry('config.json', config), entry('../escape.txt', escape)];
+    const archivePath = join(target, 'hostile.axbackup');
+    await writeFile(archivePath, gzipSync(Buffer.from(JSON.stringify({
+      format: 'automatosx-backup',
+      formatVersion: 1,
+      createdAt: new Date().toISOString(),
+      files,
+      checksum: createHash('sha256').update(files.map((file) => `${file.path}\0${file.size}\0${file.sha256}`).join('\n')).digest('hex'),
+      contents: { 'config.json': config.toString('base64'), '../escape.txt': escape.toString('base64') },
+    }))));
+
+    const runtime = createSharedRuntimeService({ basePath: target });
+    await expect(runtime.restoreBackup({ archivePath })).rejects.toThrow('Backup entry escapes .automatosx: ../escape.txt');
+    expect(existsSync(join(target, '.automatosx', 'config.json'))).toBe(false);
+    expect(existsSync(join(target, 'escape.txt'))).toBe(false);
+  });
+});
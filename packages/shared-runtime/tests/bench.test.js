@@ -0,0 +1,55 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { diffLines } from '../src/bench.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `bench-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+async function writeBenchTask(basePath, taskId, task) {
+    await mkdir(join(basePath, '.automatosx', 'bench', 'tasks'), { recursive: true });
+    await writeFile(join(basePath, '.automatosx', 'bench', 'tasks', `${taskId}.json`), JSON.stringify({ taskId, ...task }), 'utf8');
+}
+describe('bench golden files', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('diffs changed lines only', () => {
+        expect(diffLines('a\nb\nc\n', 'a\nB\nc\n')).toEqual(['+ B', '- b']);
+        expect(diffLines('same\n', 'same\n')).toEqual([]);
+    });
+    it('records new outputs, approves them, and flags later regressions', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writeBenchTask(tempDir, 'summarize-diff', { prompt: 'Summarize the diff' });
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const first = await runtime.runBench();
+        expect(first.summary).toEqual({ match: 0, changed: 0, new: 1, failed: 0 });
+        expect(await runtime.approveBench()).toEqual(['summarize-diff']);
+        const golden = await readFile(join(tempDir, '.automatosx', 'bench', 'golden', 'summarize-diff.md'), 'utf8');
+        expect(golden).toContain('Summarize the diff');
+        const second = await runtime.runBench();
+        expect(second.results[0]?.status).toBe('match');
+        expect(second.passed).toBe(true);
+        await writeBenchTask(tempDir, 'summarize-diff', { prompt: 'Summarize the staged diff' });
+        const third = await runtime.runBench({ taskIds: ['summarize-diff'] });
+        expect(third.results[0]?.status).toBe('changed');
+        expect(third.results[0]?.diff).toEqual(['+ Prompt: Summarize the staged diff', '- Prompt: Summarize the diff']);
+        expect(third.passed).toBe(false);
+    });
+    it('scores bench outputs with the task rubric', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writeBenchTask(tempDir, 'wrap-errors', { prompt: 'Wrap errors with context', rubricId: 'errors' });
+        await mkdir(join(tempDir, '.automatosx', 'rubrics'), { recursive: true });
+        await writeFile(join(tempDir, '.automatosx', 'rubrics', 'errors.yaml'), 'rubricId: errors\ncriteria:\n  - id: wrapping\n    mustMatch: "%w"\n', 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const result = await runtime.runBench();
+        expect(result.results[0]?.rubric).toMatchObject({ rubricId: 'errors', score: 0, passed: false });
+        expect(result.passed).toBe(false);
+    });
+});
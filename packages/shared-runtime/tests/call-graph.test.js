@@ -0,0 +1,117 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `call-graph-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const STORE_GO = [
+    'package store',
+    '',
+    'import "fmt"',
+    '',
+    'type Store interface {',
+    '\tGet(key string) (string, error)',
+    '}',
+    '',
+    'type DiskStore struct{}',
+    '',
+    'func (d *DiskStore) Get(key string) (string, error) {',
+    '\treturn d.read(key)',
+    '}',
+    '',
+    'func (d *DiskStore) read(key string) (string, error) {',
+    '\treturn fmt.Sprintf("%s", key), nil',
+    '}',
+    '',
+    'type MemStore struct{}',
+    '',
+    'func (m *MemStore) Get(key string) (string, error) {',
+    '\treturn "", nil',
+    '}',
+    '',
+    'func NewDiskStore() *DiskStore {',
+    '\treturn &DiskStore{}',
+    '}',
+    '',
+].join('\n');
+const API_GO = [
+    'package api',
+    '',
+    'import (',
+    '\t"example.com/app/store"',
+    ')',
+    '',
+    'type Handler struct {',
+    '\tbackend store.Store',
+    '}',
+    '',
+    'func (h *Handler) Serve(key string) string {',
+    '\tvalue, _ := h.backend.Get(key)',
+    '\treturn render(value)',
+    '}',
+    '',
+    'func render(value string) string {',
+    '\treturn strings.ToUpper(value)',
+    '}',
+    '',
+    'func Main() {',
+    '\tdisk := store.NewDiskStore()',
+    '\tdisk.Get("k")',
+    '\th := &Handler{}',
+    '\th.Serve(string(len("x")))',
+    '}',
+    '',
+].join('\n');
+describe('call graph', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('resolves package, receiver, typed, and interface calls in Go', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'store'), { recursive: true });
+        await mkdir(join(tempDir, 'api'), { recursive: true });
+        await writeFile(join(tempDir, 'go.mod'), 'module example.com/app\n\ngo 1.22\n', 'utf8');
+        await writeFile(join(tempDir, 'store', 'store.go'), STORE_GO, 'utf8');
+        await writeFile(join(tempDir, 'api', 'api.go'), API_GO, 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const named = (graph) => new Map(graph.nodes.map((node) => [node.id, node.name]));
+        const describeEdges = (graph) => {
+            const names = named(graph);
+            return graph.edges.map((edge) => `${names.get(edge.from)} -> ${names.get(edge.to)}${edge.via !== undefined ? ` via ${edge.via}` : ''}`);
+        };
+        const all = await runtime.getCallGraph();
+        expect(describeEdges(all)).toEqual([
+            'Handler.Serve -> DiskStore.Get via Store',
+            'Handler.Serve -> MemStore.Get via Store',
+            'Handler.Serve -> render',
+            'Main -> NewDiskStore',
+            'Main -> DiskStore.Get',
+            'Main -> Handler.Serve',
+            'DiskStore.Get -> DiskStore.read',
+        ]);
+        expect(all.external).toEqual(['fmt.Sprintf', 'strings.ToUpper']);
+        expect(all.edges[0]).toMatchObject({ from: 'api/api.go:11', line: 12, dynamic: true });
+        const serve = await runtime.getCallGraph({ symbol: '(*Handler).Serve', depth: 1 });
+        expect(serve.roots).toEqual(['api/api.go:11']);
+        expect(describeEdges(serve)).toEqual([
+            'Handler.Serve -> DiskStore.Get via Store',
+            'Handler.Serve -> MemStore.Get via Store',
+            'Handler.Serve -> render',
+        ]);
+        expect(serve.external).toEqual([]);
+        const callers = await runtime.getCallGraph({ symbol: 'read', direction: 'callers', depth: 3 });
+        expect(describeEdges(callers)).toEqual([
+            'Handler.Serve -> DiskStore.Get via Store',
+            'Main -> DiskStore.Get',
+            'Main -> Handler.Serve',
+            'DiskStore.Get -> DiskStore.read',
+        ]);
+        await expect(runtime.getCallGraph({ symbol: 'Missing' })).rejects.toThrow('No function or method named Missing');
+    });
+});
@@ -0,0 +1,138 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { createServer, type Server } from 'node:http';
+import type { AddressInfo } from 'node:net';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { compareCanaryToBaseline } from '../src/canary.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `canary-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+async function startHealthServer(statusCode: number): Promise<{ server: Server; url: string }> {
+  const server = createServer((_request, response) => {
+    response.writeHead(statusCode, { 'content-type': 'application/json' });
+    response.end(JSON.stringify({ status: statusCode < 500 ? 'ok' : 'error' }));
+  });
+  await new Promise<void>((resolve) => server.listen(0, '127.0.0.1', resolve));
+  const address = server.address() as AddressInfo;
+  return { server, url: `http://127.0.0.1:${address.port}/healthz` };
+}
+
+async function writeWorkspace(basePath: string, canary: Record<string, unknown>): Promise<void> {
+  await mkdir(join(basePath, '.automatosx'), { recursive: true });
+  await writeFile(join(basePath, '.automatosx', 'config.json'), `${JSON.stringify({ canary }, null, 2)}\n`, 'utf8');
+  await mkdir(join(basePath, 'workflows'), { recursive: true });
+  await writeFile(join(basePath, 'workflows', 'rollback.json'), `${JSON.stringify({
+    workflowId: 'rollback',
+    name: 'Rollback',
+    version: '1.0.0',
+    steps: [
+      { stepId: 'revert-deploy', type: 'prompt', config: { prompt: 'Revert the last deploy.' } },
+    ],
+  }, null, 2)}\n`, 'utf8');
+}
+
+describe('canary verification', () => {
+  const tempDirs: string[] = [];
+  const servers: Server[] = [];
+
+  afterEach(async () => {
+    await Promise.all(servers.splice(0).map((server) => new Promise((resolve) => server.close(resolve))));
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('flags error rate and latency regressions against the baseline', () => {
+    expect(compareCanaryToBaseline({ errorRate: 0.01, p95LatencyMs: 120 }, { errorRate: 0, p95LatencyMs: 100 }, {})).toEqual([]);
+    expect(compareCanaryToBaseline({ errorRate: 0.2, p95LatencyMs: 120 }, { errorRate: 0.01 }, {})).toEqual([
+      'error rate 20% exceeds allowed 6%',
+    ]);
+    expect(compareCanaryToBaseline({ errorRate: 0, p95LatencyMs: 400 }, { p95LatencyMs: 100 }, { maxLatencyRatio: 2 })).toEqual([
+      'p95 latency 400ms exceeds allowed 200ms',
+    ]);
+  });
+
+  it('skips verification when no endpoints are configured', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const result = await runtime.verifyCanary({ workflowId: 'release' });
+
+    expect(result.verdict).toBe('skipped');
+    expect(result.traceId).toBeUndefined();
+  });
+
+  it('reports a healthy canary without triggering rollback', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const { server, url } = await startHealthServer(200);
+    servers.push(server);
+    await writeWorkspace(tempDir, { endpoints: [url], windowMs: 0, rollbackWorkflowId: 'rollback' });
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const result = await runtime.verifyCanary({ workflowId: 'release', sessionId: 'deploy-session-001' });
+
+    expect(result.verdict).toBe('healthy');
+    expect(result.samples).toBe(1);
+    expect(result.rollback).toBeUndefined();
+  });
+
+  it('runs the rollback workflow when the canary regresses', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const { server, url } = await startHealthServer(503);
+    servers.push(server);
+    await writeWorkspace(tempDir, { endpoints: [url], windowMs: 0, rollbackWorkflowId: 'rollback' });
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const result = await runtime.verifyCanary({
+      workflowId: 'release',
+      sessionId: 'deploy-session-002',
+      traceId: 'canary-trace-002',
+    });
+
+    expect(result.verdict).toBe('regressed');
+    expect(result.reasons[0]).toContain('error rate 100%');
+    expect(result.rollback).toMatchObject({ workflowId: 'rollback', success: true });
+
+    const traces = await runtime.listTracesBySession('deploy-session-002');
+    const traceIds = traces.map((trace) => trace.traceId);
+    expect(traceIds).toContain('canary-trace-002');
+    expect(traceIds).toContain(result.rollback?.traceId);
+  });
+
+  it('does not roll back when auto-rollback is disabled', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const { server, url } = await startHealthServer(500);
+    servers.push(server);
+    await writeWorkspace(tempDir, { endpoints: [url], windowMs: 0, rollbackWorkflowId: 'rollback' });
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const result = await runtime.verifyCanary({ autoRollback: false });
+
+    expect(result.verdict).toBe('regressed');
+    expect(result.rollback).toBeUndefined();
+  });
+
+  it('counts a 404 as a failed probe unless the status is expected', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const { server, url } = await startHealthServer(404);
+    servers.push(server);
+    await writeWorkspace(tempDir, { endpoints: [url], windowMs: 0 });
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const missing = await runtime.verifyCanary({ autoRollback: false });
+    expect(missing.verdict).toBe('regressed');
+    expect(missing.endpoints[0]).toMatchObject({ samples: 1, failures: 1 });
+
+    await writeWorkspace(tempDir, { endpoints: [url], windowMs: 0, expectedStatuses: [200, 404] });
+    expect((await runtime.verifyCanary({ autoRollback: false })).verdict).toBe('healthy');
+  });
+});
@@ -0,0 +1,85 @@
+import { mkdirSync, writeFileSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService, formatClarifications } from '../src/index.js';
+import { assessTaskClarity, resolveClarificationConfig } from '../src/clarification.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `clarification-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(join(dir, '.automatosx', 'specs'), { recursive: true });
+  return dir;
+}
+
+describe('task clarification', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('scores vague tasks low and specific tasks high', () => {
+    const vague = assessTaskClarity('This needs to be better');
+    expect(vague.confidence).toBeLessThan(0.6);
+    expect(vague.gaps.map((gap) => gap.gap)).toEqual(['detail', 'target', 'outcome', 'acceptance', 'referent']);
+
+    const choice = assessTaskClarity('Store sessions in redis or postgres', { files: ['src/session.ts'] });
+    expect(choice.gaps.find((gap) => gap.gap === 'choice')?.question).toBe('Which should it be: redis or postgres?');
+
+    const specific = assessTaskClarity(
+      'Fix parseCallArgs in packages/cli/src/commands/call.ts so that --max-rounds 0 returns a usage error instead of running nothing',
+    );
+    expect(specific).toEqual({ confidence: 1, gaps: [] });
+  });
+
+  it('asks about the biggest gaps and answers what specs already cover', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    writeFileSync(join(tempDir, '.automatosx', 'specs', 'caching.md'), [
+      '# Caching',
+      '',
+      '## Caching target',
+      'Search caching must keep p95 latency under 200ms, measured by the caching benchmark.',
+      '',
+    ].join('\n'), 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const result = await runtime.clarifyTask({ task: 'Improve caching' });
+    expect(result.ambiguous).toBe(true);
+    expect(result.threshold).toBe(0.6);
+    expect(result.questions.map((question) => question.gap)).toEqual(['detail', 'target', 'outcome']);
+    const outcome = result.questions.find((question) => question.gap === 'outcome');
+    expect(outcome?.answer).toBe('Search caching must keep p95 latency under 200ms, measured by the caching benchmark.');
+    expect(outcome?.source).toBe('.automatosx/specs/caching.md#Caching target');
+    expect(result.questions.find((question) => question.gap === 'target')?.answer).toBeUndefined();
+
+    const unanswered = await runtime.clarifyTask({ task: 'Improve caching', autoAnswer: false, maxQuestions: 1 });
+    expect(unanswered.questions).toEqual([{ gap: 'detail', question: 'What should happen that does not happen today, and where?' }]);
+    expect(formatClarifications(unanswered.questions)).toBe([
+      'Clarifications:',
+      'Q: What should happen that does not happen today, and where?',
+      'A: (unanswered; state the assumption you plan on)',
+    ].join('\n'));
+
+    const clear = await runtime.clarifyTask({ task: 'Improve caching', threshold: 0.2 });
+    expect(clear).toMatchObject({ ambiguous: false, questions: [] });
+  });
+
+  it('reads thresholds from config', async () => {
+    expect(resolveClarificationConfig(undefined)).toEqual({ enabled: false, threshold: 0.6, maxQuestions: 3, autoAnswer: true });
+    expect(resolveClarificationConfig({ enabled: true, threshold: 2, maxQuestions: 1, autoAnswer: false }))
+      .toEqual({ enabled: true, threshold: 0.6, maxQuestions: 1, autoAnswer: false });
+
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    writeFileSync(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({ clarification: { threshold: 0.9, maxQuestions: 1 } }), 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const result = await runtime.clarifyTask({
+      task: 'Update src/cache.ts so stale entries expire after five minutes instead of never expiring',
+    });
+    expect(result).toMatchObject({ threshold: 0.9, ambiguous: false });
+    const strict = await runtime.clarifyTask({ task: 'Update src/cache.ts to improve eviction' });
+    expect(strict.ambiguous).toBe(true);
+    expect(strict.questions).toHaveLength(1);
+  });
+});
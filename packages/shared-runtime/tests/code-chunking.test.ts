@@ -0,0 +1,105 @@
+import { mkdirSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { chunkCode, resolveChunkingConfig } from '../src/code-chunking.js';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `code-chunking-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const CART_SOURCE = [
+  "import { readFile } from 'node:fs/promises';",
+  '',
+  'const DEFAULT_RETRIES = 3;',
+  '',
+  '/** Loads carts from disk. */',
+  'export class CartStore {',
+  '  private readonly carts = new Map<string, number>();',
+  '',
+  '  // Adds up every line item.',
+  '  total(id: string): number {',
+  '    return this.carts.get(id) ?? 0;',
+  '  }',
+  '',
+  '  async load(path: string): Promise<void> {',
+  "    await readFile(path, 'utf8');",
+  '  }',
+  '}',
+  '',
+  '/**',
+  ' * Retries a flaky call with exponential backoff.',
+  ' */',
+  'export function retry(fn: () => void): void {',
+  '  fn();',
+  '}',
+].join('\n');
+
+describe('code chunking', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('chunks per declaration with doc comments and honours per-language config', () => {
+    const { strategy, chunks } = chunkCode(CART_SOURCE, 'src/cart.ts', resolveChunkingConfig(undefined));
+    expect(strategy).toBe('declarations');
+    expect(chunks.map((chunk) => [chunk.name, chunk.kind, chunk.startLine, chunk.endLine])).toEqual([
+      ['DEFAULT_RETRIES', 'variable', 3, 3],
+      ['CartStore', 'class', 5, 17],
+      ['CartStore.total', 'method', 9, 12],
+      ['CartStore.load', 'method', 14, 16],
+      ['retry', 'function', 19, 24],
+    ]);
+    expect(chunks[1]?.content).toContain('private readonly carts');
+    expect(chunks[1]?.content).not.toContain('readFile');
+    expect(chunks[2]?.content.startsWith('  // Adds up every line item.')).toBe(true);
+    expect(chunks[4]?.content.startsWith('/**\n * Retries a flaky call')).toBe(true);
+
+    const go = 'package cart\n\n// Total sums the cart.\nfunc Total(items []int) int {\n\treturn 0\n}\n\ntype Cart struct {\n\tID string\n}\n';
+    expect(chunkCode(go, 'cart.go', resolveChunkingConfig(undefined)).chunks.map((chunk) => [chunk.name, chunk.startLine, chunk.endLine]))
+      .toEqual([['Total', 3, 6], ['Cart', 8, 10]]);
+
+    const config = resolveChunkingConfig({ typescript: 'lines', go: 'whole', maxLines: 10 });
+    expect(chunkCode(CART_SOURCE, 'src/cart.ts', config).chunks.map((chunk) => chunk.name)).toEqual(['lines 1-10', 'lines 11-20', 'lines 21-24']);
+    expect(chunkCode(go, 'cart.go', config).chunks).toHaveLength(1);
+    expect(chunkCode('plain notes', 'notes.txt', resolveChunkingConfig(undefined)).strategy).toBe('lines');
+  });
+
+  it('stores one semantic entry per symbol and drops symbols that disappear', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const first = await runtime.storeSemanticFile({ path: 'src/cart.ts', content: CART_SOURCE, namespace: 'code', tags: ['cart'] });
+    expect(first.chunks.map((chunk) => chunk.key)).toEqual([
+      'src/cart.ts#DEFAULT_RETRIES',
+      'src/cart.ts#CartStore',
+      'src/cart.ts#CartStore.total',
+      'src/cart.ts#CartStore.load',
+      'src/cart.ts#retry',
+    ]);
+    expect(first.removed).toEqual([]);
+
+    const [hit] = await runtime.searchSemantic('retry exponential backoff', { namespace: 'code', topK: 1 });
+    expect(hit).toMatchObject({
+      key: 'src/cart.ts#retry',
+      tags: ['cart'],
+      metadata: { path: 'src/cart.ts', symbol: 'retry', kind: 'function', startLine: 19, endLine: 24, chunking: 'declarations' },
+    });
+
+    const withoutRetry = CART_SOURCE.split('\n').slice(0, 17).join('\n');
+    const second = await runtime.storeSemanticFile({ path: 'src/cart.ts', content: withoutRetry, namespace: 'code' });
+    expect(second.removed).toEqual(['src/cart.ts#retry']);
+    expect((await runtime.listSemantic({ namespace: 'code' })).map((entry) => entry.key).sort()).toEqual([
+      'src/cart.ts#CartStore',
+      'src/cart.ts#CartStore.load',
+      'src/cart.ts#CartStore.total',
+      'src/cart.ts#DEFAULT_RETRIES',
+    ]);
+  });
+});
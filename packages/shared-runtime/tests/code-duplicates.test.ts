@@ -0,0 +1,141 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { resolveDuplicatesScope, tokenizeForDuplicates } from '../src/code-duplicates.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `code-duplicates-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const CART_TS = [
+  "import { Item } from './item';",
+  '',
+  'export function cartTotal(items: Item[], discount: number): number {',
+  '  let total = 0;',
+  '  for (const item of items) {',
+  '    if (item.quantity > 0 && item.price > 0) {',
+  '      total += item.price * item.quantity;',
+  '    } else if (item.refund) {',
+  '      total -= item.price;',
+  '    }',
+  '  }',
+  '  if (discount > 0) {',
+  '    total = total - total * discount / 100;',
+  '  }',
+  '  return Math.round(total * 100) / 100;',
+  '}',
+  '',
+].join('\n');
+
+// The same function, renamed and moved into a method.
+const INVOICE_TS = [
+  'export class Invoice {',
+  '  amount(lines: Line[], rebate: number): number {',
+  '    let sum = 0;',
+  '    for (const line of lines) {',
+  '      if (line.quantity > 0 && line.price > 0) {',
+  '        sum += line.price * line.quantity; // per line',
+  '      } else if (line.refund) {',
+  '        sum -= line.price;',
+  '      }',
+  '    }',
+  '    if (rebate > 0) {',
+  '      sum = sum - sum * rebate / 100;',
+  '    }',
+  '    return Math.round(sum * 100) / 100;',
+  '  }',
+  '}',
+  '',
+].join('\n');
+
+// Only the rounding tail was ported.
+const BILLING_JAVA = [
+  'package billing;',
+  '',
+  'class Billing {',
+  '  static double charge(Item item, double rate) {',
+  '    if (item.quantity > 0 && item.price > 0) {',
+  '      return item.price * item.quantity;',
+  '    } else if (item.refund) {',
+  '      return -item.price;',
+  '    }',
+  '    if (rate > 0) {',
+  '      rate = rate - rate * rate / 100;',
+  '    }',
+  '    return Math.round(rate * 100) / 100;',
+  '  }',
+  '}',
+  '',
+].join('\n');
+
+describe('duplicate code', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('normalizes identifiers, literals, and keywords that differ by language', () => {
+    const ts = tokenizeForDuplicates('import { a } from "a";\nfunction total(items) { return items.length ?? null; } // note\n');
+    const go = tokenizeForDuplicates('package cart\n\nfunc count(rows) { return rows.size ?? nil; }\n');
+    expect(ts.tokens).toEqual(go.tokens);
+    expect(ts.tokens).toEqual(['fn', '$', '(', '$', ')', '{', 'return', '$', '.', '$', '??', 'nil', '}']);
+    expect(ts.lines.every((line) => line === 2)).toBe(true);
+    expect(tokenizeForDuplicates('const label = "a b"; x === 42').tokens).toEqual(['let', '$', '=', '"', '$', '==', '0']);
+  });
+
+  it('groups renamed copies and ports with their enclosing symbols', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'src'), { recursive: true });
+    await mkdir(join(tempDir, 'lib'), { recursive: true });
+    await mkdir(join(tempDir, 'workflows'), { recursive: true });
+    await writeFile(join(tempDir, 'src', 'cart.ts'), CART_TS, 'utf8');
+    await writeFile(join(tempDir, 'src', 'invoice.ts'), INVOICE_TS, 'utf8');
+    await writeFile(join(tempDir, 'lib', 'Billing.java'), BILLING_JAVA, 'utf8');
+    await writeFile(join(tempDir, 'workflows', 'consolidate.json'), JSON.stringify({
+      workflowId: 'consolidate',
+      name: 'Consolidate',
+      version: '1.0.0',
+      steps: [{ stepId: 'plan', type: 'prompt', config: { prompt: 'Extract helpers for:\n{{duplicates.list}}' } }],
+    }), 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const report = await runtime.findDuplicates();
+    expect(report.groups).toHaveLength(1);
+    expect(report.groups[0]).toMatchObject({
+      lines: 14,
+      languages: ['ts'],
+      fragments: [
+        { path: 'src/cart.ts', line: 3, endLine: 16, symbol: 'cartTotal' },
+        { path: 'src/invoice.ts', line: 2, endLine: 15, symbol: 'Invoice.amount' },
+      ],
+    });
+    expect(report.groups[0]!.id).toMatch(/^dup-[0-9a-f]{8}$/);
+
+    const loose = await runtime.findDuplicates({ minTokens: 20 });
+    expect(loose.groups.map((group) => group.languages)).toEqual([['ts'], ['java', 'ts']]);
+    expect(loose.groups[1]!.fragments.map((fragment) => `${fragment.path}:${fragment.line} ${fragment.symbol}`)).toEqual([
+      'lib/Billing.java:9 Billing.charge',
+      'src/cart.ts:11 cartTotal',
+      'src/invoice.ts:10 Invoice.amount',
+    ]);
+    expect(loose.summary).toMatchObject({ groups: 2, fragments: 5 });
+    expect((await runtime.findDuplicates({ minTokens: 20, paths: ['lib', 'src/cart.ts'] })).groups).toHaveLength(1);
+    expect((await runtime.findDuplicates({ minLines: 15 })).groups).toEqual([]);
+
+    const preview = await runtime.previewTemplates({ workflowId: 'consolidate', workflowDir: join(tempDir, 'workflows'), input: { duplicates: true } });
+    expect(preview.templates[0]!.text).toBe(`Extract helpers for:\n- ${report.groups[0]!.id} 14 lines x2 (ts): src/cart.ts:3-16 cartTotal, src/invoice.ts:2-15 Invoice.amount`);
+  });
+
+  it('reads workflow duplicate scopes', () => {
+    expect(resolveDuplicatesScope(undefined)).toBeUndefined();
+    expect(resolveDuplicatesScope(true)).toEqual({ limit: 10 });
+    expect(resolveDuplicatesScope('packages/cli')).toEqual({ paths: ['packages/cli'], limit: 10 });
+    expect(resolveDuplicatesScope({ paths: 'src', minTokens: 80, minLines: 'x', top: 3 })).toEqual({ paths: ['src'], minTokens: 80, limit: 3 });
+  });
+});
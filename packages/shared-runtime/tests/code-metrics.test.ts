@@ -0,0 +1,151 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { measureDeclarations, resolveMetricsScope } from '../src/code-metrics.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `code-metrics-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const PRICE_TS = [
+  'export function price(items: Item[], coupon?: string, { tax = 0 }: Opts = {}): number {',
+  '  let total = 0;',
+  '  for (const item of items) {',
+  '    if (item.qty > 0 && item.price > 0 || item.free) {',
+  '      total += item.price; // if && || are ignored in comments',
+  '    } else if (item.refund) {',
+  '      total -= 1;',
+  '    } else {',
+  '      continue;',
+  '    }',
+  '  }',
+  '  do { total++; } while (total < 0);',
+  '  const label = "if (a && b)";',
+  '  items.forEach((item) => { if (item.x) { total += 1; } });',
+  '  return coupon ? total * 0.9 : total ?? 0;',
+  '}',
+  '',
+  'export const id = (x) => x;',
+  '',
+].join('\n');
+
+const SERVER_GO = [
+  'package api',
+  '',
+  'func (s *Server) Route(method, path string, handlers map[string]Handler) error {',
+  '\tswitch method {',
+  '\tcase "GET":',
+  '\t\treturn nil',
+  '\tcase "POST":',
+  '\t\tif path == "" {',
+  '\t\t\treturn nil',
+  '\t\t}',
+  '\t}',
+  '\treturn nil',
+  '}',
+  '',
+].join('\n');
+
+const PARSER_RS = [
+  'impl Parser {',
+  "    pub fn next<'a>(&'a mut self, input: &'a str) -> Result<Token, Error> {",
+  '        let c = input.chars().next().ok_or(Error::Eof)?;',
+  '        match c {',
+  "            '0'..='9' => Ok(Token::Digit),",
+  "            'a'..='z' if self.lenient => Ok(Token::Word),",
+  '            _ => Err(Error::Unexpected(c)),',
+  '        }',
+  '    }',
+  '}',
+  '',
+].join('\n');
+
+const ORDERS_PY = [
+  'def classify(order, *, strict: bool = False) -> str:',
+  '    if not order.lines and strict:  # if or and',
+  '        raise ValueError("if")',
+  '    elif order.total > 100 or order.vip:',
+  '        return "big" if order.total > 1000 else "large"',
+  '    for line in order.lines:',
+  '        try:',
+  '            line.settle()',
+  '        except TimeoutError:',
+  '            continue',
+  '    return "small"',
+  '',
+].join('\n');
+
+describe('code metrics', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('scores branches, nesting, boolean runs, parameters, and size per function', () => {
+    expect(measureDeclarations(PRICE_TS, 'src/price.ts')).toEqual([
+      { name: 'price', kind: 'function', path: 'src/price.ts', line: 1, endLine: 16, cyclomatic: 10, cognitive: 11, nesting: 2, parameters: 3, lines: 16, codeLines: 16 },
+      { name: 'id', kind: 'function', path: 'src/price.ts', line: 18, endLine: 18, cyclomatic: 1, cognitive: 0, nesting: 0, parameters: 1, lines: 1, codeLines: 1 },
+    ]);
+    expect(measureDeclarations(SERVER_GO, 'api/server.go')).toEqual([
+      expect.objectContaining({ name: 'Server.Route', kind: 'method', cyclomatic: 4, cognitive: 3, nesting: 2, parameters: 3 }),
+    ]);
+    // Match arms branch like cases; `self` is not a parameter and `?` is not a ternary.
+    expect(measureDeclarations(PARSER_RS, 'src/parser.rs')).toEqual([
+      { name: 'Parser.next', kind: 'method', path: 'src/parser.rs', line: 2, endLine: 9, cyclomatic: 5, cognitive: 3, nesting: 1, parameters: 1, lines: 8, codeLines: 8 },
+    ]);
+    // Python nests by indentation; `and`/`or` and conditional expressions branch too.
+    expect(measureDeclarations(ORDERS_PY, 'orders.py')).toEqual([
+      { name: 'classify', kind: 'function', path: 'orders.py', line: 1, endLine: 11, cyclomatic: 8, cognitive: 9, nesting: 2, parameters: 2, lines: 11, codeLines: 11 },
+    ]);
+  });
+
+  it('ranks the workspace worst first and feeds refactor workflows', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'src'), { recursive: true });
+    await mkdir(join(tempDir, 'api'), { recursive: true });
+    await mkdir(join(tempDir, 'workflows'), { recursive: true });
+    await writeFile(join(tempDir, 'src', 'price.ts'), PRICE_TS, 'utf8');
+    await writeFile(join(tempDir, 'api', 'server.go'), SERVER_GO, 'utf8');
+    await writeFile(join(tempDir, 'workflows', 'refactor.json'), JSON.stringify({
+      workflowId: 'refactor',
+      name: 'Refactor',
+      version: '1.0.0',
+      steps: [{ stepId: 'plan', type: 'prompt', config: { prompt: 'Simplify:\n{{metrics.list}}' } }],
+    }), 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const report = await runtime.getCodeMetrics();
+    expect(report.symbols.map((entry) => entry.name)).toEqual(['price', 'Server.Route', 'id']);
+    expect(report.summary).toMatchObject({
+      functions: 3,
+      maxCognitive: 11,
+      maxCyclomatic: 10,
+      overCyclomatic: 0,
+      distribution: [{ band: '1-5', count: 2 }, { band: '6-10', count: 1 }, { band: '11-20', count: 0 }, { band: '21+', count: 0 }],
+      hotspots: [{ path: 'src/price.ts', functions: 2, cognitive: 11 }, { path: 'api/server.go', functions: 1, cognitive: 3 }],
+    });
+    expect((await runtime.getCodeMetrics({ sort: 'parameters', limit: 1 })).symbols.map((entry) => entry.name)).toEqual(['price']);
+    expect((await runtime.getCodeMetrics({ sort: 'nesting' })).symbols.map((entry) => [entry.name, entry.nesting])).toEqual([['price', 2], ['Server.Route', 2], ['id', 0]]);
+    expect((await runtime.getCodeMetrics({ query: 'kind:method' })).symbols.map((entry) => entry.name)).toEqual(['Server.Route']);
+    expect((await runtime.getCodeMetrics({ paths: ['src'], minCognitive: 1 })).symbols.map((entry) => entry.name)).toEqual(['price']);
+    await expect(runtime.getCodeMetrics({ sort: 'size' as never })).rejects.toThrow('Unknown metrics sort');
+
+    const preview = await runtime.previewTemplates({ workflowId: 'refactor', workflowDir: join(tempDir, 'workflows'), input: { metrics: { top: 1 } } });
+    expect(preview.templates[0]!.text).toBe('Simplify:\n- src/price.ts:1 price cognitive 11, cyclomatic 10, nesting 2, 3 params, 16 lines');
+  });
+
+  it('reads workflow metrics scopes', () => {
+    expect(resolveMetricsScope(undefined)).toBeUndefined();
+    expect(resolveMetricsScope(true)).toEqual({ limit: 10 });
+    expect(resolveMetricsScope('packages/cli')).toEqual({ paths: ['packages/cli'], limit: 10 });
+    expect(resolveMetricsScope({ paths: ['src'], sort: 'cyclomatic', top: 3, minCognitive: 15, query: 'exported:true' }))
+      .toEqual({ paths: ['src'], sort: 'cyclomatic', minCognitive: 15, query: 'exported:true', limit: 3 });
+    expect(resolveMetricsScope({ sort: 'size' })).toEqual({ limit: 10 });
+  });
+});
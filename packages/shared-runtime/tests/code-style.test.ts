@@ -0,0 +1,111 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { conformToFileStyle, detectCodeStyle } from '../src/code-style.js';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `code-style-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const TAB_FILE = [
+  "import { readFile } from 'node:fs/promises';",
+  "import { join } from 'node:path';",
+  "import { load } from './load.js';",
+  '',
+  'export async function read(dir) {',
+  "\tconst path = join(dir, 'a.txt');",
+  '\tif (path) {',
+  "\t\treturn readFile(path, 'utf8');",
+  '\t}',
+  "\treturn load('fallback');",
+  '}',
+  '',
+].join('\r\n');
+
+describe('formatting-preserving code generation', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('detects a file style and rewrites generated content to match it', () => {
+    expect(detectCodeStyle(TAB_FILE, 'src/read.js')).toEqual({
+      indent: { kind: 'tab' },
+      quote: 'single',
+      lineEnding: '\r\n',
+      sortedImports: true,
+    });
+    expect(detectCodeStyle('a:\n  b:\n    c: 1\n', 'config.yaml')).toMatchObject({ indent: { kind: 'space', width: 2 }, quote: undefined });
+
+    const generated = [
+      'import { join } from "node:path";',
+      'import { load } from "./load.js";',
+      'import { readFile } from "node:fs/promises";',
+      '',
+      'export async function read(dir) {',
+      '    const path = join(dir, "b.txt");',
+      '    if (path) {',
+      '        return readFile(path, "utf8");',
+      '    }',
+      '    return load("it\'s missing", /"/.source);',
+      '}',
+      '',
+    ].join('\n');
+    const result = conformToFileStyle(generated, TAB_FILE, 'src/read.js');
+    expect(result.content).toBe(TAB_FILE.replace("'a.txt'", "'b.txt'").replace("load('fallback')", 'load("it\'s missing", /"/.source)'));
+    expect(result.adjustments).toEqual(['quotes → single', 'indentation → tabs', 'line endings → CRLF', 'imports sorted']);
+  });
+
+  it('restyles applied hunks unless preserveStyle is turned off', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'src'), { recursive: true });
+    await writeFile(join(tempDir, 'src', 'read.js'), TAB_FILE, 'utf8');
+    const patch = [
+      '--- a/src/read.js',
+      '+++ b/src/read.js',
+      '@@ -1,2 +1,3 @@',
+      '+import { dirname } from "node:path";',
+      " import { readFile } from 'node:fs/promises';",
+      " import { join } from 'node:path';",
+      '@@ -9,3 +10,4 @@',
+      '   }',
+      "-  return load('fallback');",
+      '+  const dir2 = dirname(dir);',
+      '+  return load(dir2);',
+      ' }',
+      '',
+    ].join('\n');
+    const decisions = [
+      { path: 'src/read.js', hunkIndex: 0, decision: 'accept' as const },
+      { path: 'src/read.js', hunkIndex: 1, decision: 'accept' as const },
+    ];
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const result = await runtime.reviewPatch({ patch, decisions });
+    expect(result.restyled).toEqual([{
+      path: 'src/read.js',
+      adjustments: ['quotes → single', 'line endings → CRLF', 'indentation → tabs', 'imports sorted'],
+    }]);
+    const written = await readFile(join(tempDir, 'src', 'read.js'), 'utf8');
+    expect(written.split('\r\n').slice(0, 4)).toEqual([
+      "import { readFile } from 'node:fs/promises';",
+      "import { dirname } from 'node:path';",
+      "import { join } from 'node:path';",
+      "import { load } from './load.js';",
+    ]);
+    expect(written).toContain('\tconst dir2 = dirname(dir);\r\n\treturn load(dir2);\r\n}');
+
+    await writeFile(join(tempDir, 'src', 'read.js'), TAB_FILE, 'utf8');
+    await mkdir(join(tempDir, '.automatosx'), { recursive: true });
+    await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({ patch: { preserveStyle: false } }), 'utf8');
+    const raw = await runtime.reviewPatch({ patch, decisions: decisions.slice(0, 1) });
+    expect(raw.restyled).toEqual([]);
+    expect(await readFile(join(tempDir, 'src', 'read.js'), 'utf8')).toContain('import { dirname } from "node:path";\n');
+  });
+});
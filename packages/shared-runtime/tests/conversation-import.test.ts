@@ -0,0 +1,94 @@
+import { createHash } from 'node:crypto';
+import { mkdirSync } from 'node:fs';
+import { rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { readConversationHistory } from '../src/conversation-import.js';
+
+function createTempDir(): { workspace: string; home: string } {
+  const dir = join(process.cwd(), '.tmp', `conversation-import-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  const workspace = join(dir, 'repo');
+  mkdirSync(join(workspace, '.automatosx'), { recursive: true });
+  return { workspace, home: join(dir, 'home') };
+}
+
+async function writeHistory(workspace: string, home: string): Promise<void> {
+  const claudeDir = join(home, '.claude', 'projects', workspace.replace(/[^A-Za-z0-9]/g, '-'));
+  mkdirSync(claudeDir, { recursive: true });
+  const session = { sessionId: 'claude-1', cwd: workspace };
+  await writeFile(join(claudeDir, 'claude-1.jsonl'), [
+    { ...session, type: 'user', uuid: 'u1', timestamp: '2026-05-01T10:00:00.000Z', message: { role: 'user', content: 'Why do sessions expire after an hour?' } },
+    { ...session, type: 'assistant', uuid: 'a1', timestamp: '2026-05-01T10:00:05.000Z', message: { role: 'assistant', model: 'claude-sonnet', content: [{ type: 'tool_use', name: 'Read', input: {} }] } },
+    { ...session, type: 'user', uuid: 't1', message: { role: 'user', content: [{ type: 'tool_result', content: 'const TTL = 3600;' }] } },
+    { ...session, type: 'assistant', uuid: 'a2', message: { role: 'assistant', model: 'claude-sonnet', content: [{ type: 'text', text: 'TTL is 3600 seconds in src/session.ts.' }] } },
+    { ...session, type: 'user', uuid: 'u2', isMeta: true, message: { role: 'user', content: 'Caveat: local commands follow' } },
+    { ...session, type: 'user', uuid: 'u3', timestamp: '2026-05-01T10:01:00.000Z', message: { role: 'user', content: '<command-name>/clear</command-name>' } },
+    { ...session, type: 'user', uuid: 'u4', timestamp: '2026-05-02T09:00:00.000Z', message: { role: 'user', content: 'Rotate the key sk-abcdefghijklmnop1234 next' } },
+    { ...session, type: 'assistant', uuid: 'a3', message: { role: 'assistant', content: [{ type: 'text', text: 'Done.' }] } },
+  ].map((line) => JSON.stringify(line)).join('\n'));
+
+  const geminiDir = join(home, '.gemini', 'tmp', createHash('sha256').update(workspace).digest('hex'), 'chats');
+  mkdirSync(geminiDir, { recursive: true });
+  await writeFile(join(geminiDir, 'session-1.json'), JSON.stringify({
+    sessionId: 'gemini-1',
+    messages: [
+      { id: 'g1', type: 'user', timestamp: '2026-05-03T08:00:00.000Z', content: 'Which database does the worker use?' },
+      { id: 'g2', type: 'gemini', model: 'gemini-pro', content: 'Postgres, through the pool in worker/db.ts.' },
+      { id: 'g3', type: 'user', content: '/stats' },
+      { id: 'g4', type: 'user', content: 'Unanswered question' },
+    ],
+  }));
+}
+
+describe('conversation import', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(join(tempDir, '..'), { recursive: true, force: true })));
+  });
+
+  it('pairs prompts with their answers and leaves out tool traffic and commands', async () => {
+    const { workspace, home } = createTempDir();
+    tempDirs.push(workspace);
+    await writeHistory(workspace, home);
+
+    const [claude, gemini] = await readConversationHistory({ basePath: workspace, homeDir: home });
+    expect(claude?.exchanges.map((exchange) => [exchange.messageId, exchange.prompt, exchange.response])).toEqual([
+      ['u1', 'Why do sessions expire after an hour?', 'TTL is 3600 seconds in src/session.ts.'],
+      ['u4', 'Rotate the key sk-abcdefghijklmnop1234 next', 'Done.'],
+    ]);
+    expect(claude?.exchanges[0]?.model).toBe('claude-sonnet');
+    expect(gemini).toMatchObject({ source: 'gemini-cli', sessions: 1 });
+    expect(gemini?.exchanges.map((exchange) => exchange.response)).toEqual(['Postgres, through the pool in worker/db.ts.']);
+
+    const recent = await readConversationHistory({ basePath: workspace, homeDir: home, sources: ['claude-code'], since: '2026-05-02T00:00:00.000Z' });
+    expect(recent.map((source) => [source.source, source.exchanges.length])).toEqual([['claude-code', 1]]);
+  });
+
+  it('stores exchanges as searchable semantic memory once, with provenance and secrets redacted', async () => {
+    const { workspace, home } = createTempDir();
+    tempDirs.push(workspace);
+    await writeHistory(workspace, home);
+    const runtime = createSharedRuntimeService({ basePath: workspace });
+
+    expect(await runtime.importConversations({ homeDir: home, dryRun: true })).toMatchObject({ dryRun: true, imported: 3 });
+    expect(await runtime.listSemantic({ namespace: 'conversations' })).toHaveLength(0);
+
+    const result = await runtime.importConversations({ homeDir: home });
+    expect(result).toMatchObject({ namespace: 'conversations', imported: 3, skipped: 0 });
+    expect(result.sources).toEqual([
+      { source: 'claude-code', sessions: 1, exchanges: 2, imported: 2, skipped: 0 },
+      { source: 'gemini-cli', sessions: 1, exchanges: 1, imported: 1, skipped: 0 },
+    ]);
+    const entry = await runtime.getSemantic('claude-code/claude-1/u1', 'conversations');
+    expect(entry?.content).toBe('User: Why do sessions expire after an hour?\n\nAssistant: TTL is 3600 seconds in src/session.ts.');
+    expect(entry?.tags).toEqual(['claude-code', 'conversation']);
+    expect(entry?.metadata).toMatchObject({ source: 'claude-code', sessionId: 'claude-1', messageId: 'u1', at: '2026-05-01T10:00:00.000Z', model: 'claude-sonnet' });
+    expect((await runtime.getSemantic('claude-code/claude-1/u4', 'conversations'))?.content).toContain('[REDACTED:api-key]');
+    const hits = await runtime.searchSemantic('database worker', { namespace: 'conversations' });
+    expect(hits[0]?.key).toBe('gemini-cli/gemini-1/g1');
+
+    expect(await runtime.importConversations({ homeDir: home })).toMatchObject({ imported: 0, skipped: 3 });
+  });
+});
@@ -0,0 +1,76 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { sanitizeText } from '../src/crash-report.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `crash-report-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('crash reports', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('strips secrets and local paths from free text', () => {
+    const text = sanitizeText('failed /work/app/src/a.ts with Bearer abc.def and sk-ant-1234567890abcdefgh token=hunter2', '/work/app');
+
+    expect(text).toBe('failed <workspace>/src/a.ts with Bearer [REDACTED] and [REDACTED] token=[REDACTED]');
+  });
+
+  it('writes a sanitized diagnostic bundle for a crash', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, '.automatosx'), { recursive: true });
+    await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({
+      providers: { claude: { apiKey: 'plain-secret', model: 'default' } },
+      webhook: 'https://hooks.example.test/?token=abc123',
+    }), 'utf8');
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    await runtime.callProvider({ prompt: 'hello', provider: 'claude' });
+    const error = Object.assign(new Error(`ENOENT: no such file ${join(tempDir, 'missing.json')}`), { code: 'ENOENT' });
+    const crash = await runtime.recordCrash({ error, command: 'review', version: '14.0.0' });
+
+    expect(crash.bundle).toMatchObject({
+      surface: 'cli',
+      category: 'filesystem',
+      command: 'review',
+      versions: { automatosx: '14.0.0', node: process.version },
+      error: { code: 'ENOENT', message: 'ENOENT: no such file <workspace>/missing.json' },
+      config: {
+        providers: { claude: { apiKey: '[REDACTED]', model: 'default' } },
+        webhook: 'https://hooks.example.test/?token=[REDACTED]',
+      },
+    });
+    expect(crash.bundle.recentActivity).toHaveLength(1);
+    expect(crash.bundle.recentActivity[0]).toContain('cli call completed');
+
+    const written = await readFile(crash.bundlePath, 'utf8');
+    expect(written).not.toContain('plain-secret');
+    expect(written).not.toContain(tempDir);
+  });
+
+  it('prefills a bug report from the latest bundle', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    await expect(runtime.prepareBugReport()).rejects.toThrow('No crash reports found');
+
+    await runtime.recordCrash({ error: new Error('provider exploded'), surface: 'mcp', command: 'ax_review_analyze' });
+    const report = await runtime.prepareBugReport();
+
+    expect(report.title).toBe('[crash] mcp ax_review_analyze: provider error (Error)');
+    expect(report.body).toContain('Diagnostic bundle (secrets stripped)');
+    expect(report.issueUrl.startsWith('https://github.com/defai-digital/AutomatosX/issues/new?')).toBe(true);
+    expect(new URL(report.issueUrl).searchParams.get('title')).toBe(report.title);
+    expect(report.submittedIssueUrl).toBeUndefined();
+  });
+});
@@ -0,0 +1,165 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `dead-code-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const SAMPLE_GO = [
+  'package sample',
+  '',
+  '// Server serves requests.',
+  'type Server struct{}',
+  '',
+  '// Start starts the server.',
+  'func (s *Server) Start() error {',
+  '\treturn s.listen()',
+  '}',
+  '',
+  '// Stop stops the server.',
+  'func (s *Server) Stop() error {',
+  '\treturn nil',
+  '}',
+  '',
+  'func (s *Server) listen() error {',
+  '\treturn nil',
+  '}',
+  '',
+  '// retry calls itself, which does not count as a use.',
+  'func retry(n int) int {',
+  '\tif n == 0 {',
+  '\t\treturn 0',
+  '\t}',
+  '\treturn retry(n - 1)',
+  '}',
+  '',
+  'func main() {',
+  '\tserver := &Server{}',
+  '\t_ = server.Start()',
+  '}',
+  '',
+].join('\n');
+
+const UTIL_TS = [
+  'export function formatPrice(cents: number): string {',
+  '  return `$${toDollars(cents)}`;',
+  '}',
+  '',
+  'function toDollars(cents: number): string {',
+  '  return (cents / 100).toFixed(2);',
+  '}',
+  '',
+  '// legacyRound is mentioned here but never called.',
+  'function legacyRound(value: number): number {',
+  '  return Math.round(value);',
+  '}',
+  '',
+  'export const TAX_RATE = 0.2;',
+  '',
+  '// The quote inside this regex must not swallow the lines below.',
+  'const QUOTES = /["\']+/g;',
+  '',
+  'export function stripQuotes(value: string): string {',
+  '  return value.replace(QUOTES, "");',
+  '}',
+  '',
+].join('\n');
+
+describe('dead code detection', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('flags symbols with no references outside their own definition', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'cmd'), { recursive: true });
+    await mkdir(join(tempDir, 'web'), { recursive: true });
+    await writeFile(join(tempDir, 'cmd', 'sample2.go'), SAMPLE_GO, 'utf8');
+    await writeFile(join(tempDir, 'web', 'util.ts'), UTIL_TS, 'utf8');
+    await writeFile(join(tempDir, 'web', 'util.test.ts'), "import { formatPrice, stripQuotes } from './util.js';\nformatPrice(100);\nstripQuotes('\"x\"');\n", 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const report = await runtime.findDeadCode();
+    expect(report.symbols.map((symbol) => `${symbol.path}:${symbol.line} ${symbol.receiver ?? ''}${symbol.receiver === undefined ? '' : '.'}${symbol.name}`)).toEqual([
+      'cmd/sample2.go:12 Server.Stop',
+      'cmd/sample2.go:21 retry',
+      'web/util.ts:10 legacyRound',
+      'web/util.ts:14 TAX_RATE',
+    ]);
+    expect(report.counts).toEqual({ exported: 2, unexported: 2 });
+    expect(report.scannedFiles).toBe(3);
+
+    const unexported = await runtime.findDeadCode({ paths: ['web'], includeExported: false });
+    expect(unexported.symbols.map((symbol) => symbol.name)).toEqual(['legacyRound']);
+    expect(unexported.declarations).toBe(6);
+
+    const limited = await runtime.findDeadCode({ limit: 1 });
+    expect(limited).toMatchObject({ truncated: true, counts: { exported: 2, unexported: 2 } });
+  });
+
+  it('leaves framework-wired Java, Kotlin, and Python declarations alone', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'src'), { recursive: true });
+    await writeFile(join(tempDir, 'src', 'HealthController.java'), [
+      '@RestController',
+      'public class HealthController {',
+      '    @GetMapping("/health")',
+      '    public String health() {',
+      '        return "ok";',
+      '    }',
+      '',
+      '    private String unusedFormat(String value) {',
+      '        return value.trim();',
+      '    }',
+      '}',
+      '',
+    ].join('\n'), 'utf8');
+    await writeFile(join(tempDir, 'src', 'Jobs.kt'), [
+      '@Component',
+      'class Jobs {',
+      '    @Scheduled(fixedDelay = 1000)',
+      '    fun sweep() {}',
+      '}',
+      '',
+      'private fun orphan() = 42',
+      '',
+    ].join('\n'), 'utf8');
+    await writeFile(join(tempDir, 'src', 'JobsTest.kt'), 'class JobsTest {\n    @Test\n    fun sweeps() {}\n}\n', 'utf8');
+    await writeFile(join(tempDir, 'src', 'app.py'), [
+      '@app.route("/health")',
+      'def health():',
+      '    return str(Cache().size)  # _stale is only mentioned here',
+      '',
+      '',
+      'class Cache:',
+      '    def __init__(self):',
+      '        self.items = {}',
+      '',
+      '    @property',
+      '    def size(self):',
+      '        return len(self.items)',
+      '',
+      '    def _stale(self):',
+      '        return None',
+      '',
+    ].join('\n'), 'utf8');
+    await writeFile(join(tempDir, 'src', 'test_app.py'), 'def test_health():\n    pass\n', 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const report = await runtime.findDeadCode();
+    expect(report.symbols.map((symbol) => `${symbol.path}:${symbol.line} ${symbol.name}`)).toEqual([
+      'src/HealthController.java:8 unusedFormat',
+      'src/Jobs.kt:7 orphan',
+      'src/app.py:14 _stale',
+    ]);
+  });
+});
@@ -0,0 +1,126 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { parseDoneCriteria, resolveDefinitionOfDone, verifyDefinitionOfDone } from '../src/definition-of-done.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `definition-of-done-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+async function writeWorkflow(tempDir: string, metadata?: Record<string, unknown>): Promise<string> {
+  const workflowDir = join(tempDir, 'workflows');
+  await mkdir(workflowDir, { recursive: true });
+  await writeFile(join(workflowDir, 'build.json'), `${JSON.stringify({
+    workflowId: 'build',
+    name: 'Build',
+    version: '1.0.0',
+    steps: [{ stepId: 'plan', type: 'prompt', config: { prompt: 'Plan the build' } }],
+    ...(metadata !== undefined ? { metadata } : {}),
+  }, null, 2)}\n`, 'utf8');
+  return workflowDir;
+}
+
+describe('definition of done', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('writes the first definition to an editable file and reads it back on later runs', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeFile(join(tempDir, 'package.json'), '{ "scripts": { "test": "vitest run" } }\n', 'utf8');
+
+    const first = await resolveDefinitionOfDone({ basePath: tempDir, workflowId: 'ship' });
+    expect(first).toEqual({
+      workflowId: 'ship',
+      source: 'default',
+      path: join(tempDir, '.automatosx', 'done', 'ship.json'),
+      criteria: [{ id: 'tests', kind: 'test', description: 'The test suite passes', command: 'npm test' }],
+    });
+    expect(JSON.parse(await readFile(first?.path ?? '', 'utf8'))).toEqual({ workflowId: 'ship', criteria: first?.criteria });
+
+    await writeFile(first?.path ?? '', JSON.stringify({ criteria: ['npm run lint', { kind: 'file', path: 'CHANGELOG.md' }] }), 'utf8');
+    const edited = await resolveDefinitionOfDone({ basePath: tempDir, workflowId: 'ship', workflowDone: ['ignored'] });
+    expect(edited?.source).toBe('file');
+    expect(edited?.criteria).toEqual([
+      { id: 'test-1', kind: 'test', command: 'npm run lint' },
+      { id: 'file-2', kind: 'file', path: 'CHANGELOG.md' },
+    ]);
+
+    expect((await resolveDefinitionOfDone({ basePath: tempDir, workflowId: 'ship', input: { done: ['true'] } }))?.source).toBe('input');
+    expect(await resolveDefinitionOfDone({ basePath: tempDir, workflowId: 'ship', input: { done: false } })).toBeUndefined();
+    expect(() => parseDoneCriteria([{ kind: 'file' }], 'the done input')).toThrow('criterion 1 is a file without a path');
+    expect(() => parseDoneCriteria(['true', { id: 'test-1', kind: 'test', command: 'true' }], 'x')).toThrow('duplicate criterion id "test-1"');
+  });
+
+  it('checks tests, files, and behaviors mechanically', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeFile(join(tempDir, 'CHANGELOG.md'), '## Unreleased\n- Faster checkout\n', 'utf8');
+
+    const verification = await verifyDefinitionOfDone({
+      basePath: tempDir,
+      now: new Date('2026-05-01T00:00:00.000Z'),
+      criteria: parseDoneCriteria([
+        { id: 'tests', kind: 'test', command: 'node -e "process.exit(0)"' },
+        { id: 'changelog', kind: 'file', path: 'CHANGELOG.md', contains: 'Faster checkout' },
+        { id: 'readme', kind: 'file', path: 'README.md' },
+        { id: 'escape', kind: 'file', path: '../outside.txt' },
+        { id: 'greets', kind: 'behavior', command: 'node -e "console.log(\'hello\')"', expect: 'goodbye' },
+        { id: 'lint', kind: 'test', command: 'node -e "console.error(\'2 problems\'); process.exit(2)"' },
+        { id: 'ux', kind: 'behavior', description: 'Reads well on mobile' },
+      ], 'test'),
+    });
+
+    expect(verification.passed).toBe(false);
+    expect(verification.checkedAt).toBe('2026-05-01T00:00:00.000Z');
+    expect(verification.results.map((result) => [result.id, result.status])).toEqual([
+      ['tests', 'passed'],
+      ['changelog', 'passed'],
+      ['readme', 'failed'],
+      ['escape', 'failed'],
+      ['greets', 'failed'],
+      ['lint', 'failed'],
+      ['ux', 'manual'],
+    ]);
+    expect(verification.results.find((result) => result.id === 'readme')?.detail).toBe('README.md does not exist');
+    expect(verification.results.find((result) => result.id === 'escape')?.detail).toBe('../outside.txt is outside the workspace');
+    expect(verification.results.find((result) => result.id === 'greets')?.detail).toBe('output did not include "goodbye": hello');
+    expect(verification.results.find((result) => result.id === 'lint')?.detail).toContain('exited with 2: 2 problems');
+  });
+
+  it('fails a workflow run whose steps succeeded but whose definition of done is not met', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const workflowDir = await writeWorkflow(tempDir, { done: [{ id: 'report', kind: 'file', path: 'report.md' }] });
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const preview = await runtime.previewTemplates({ workflowId: 'build', workflowDir });
+    expect(preview.definitionOfDone?.source).toBe('workflow');
+    expect(preview.definitionOfDone?.criteria).toEqual([{ id: 'report', kind: 'file', path: 'report.md' }]);
+
+    const failed = await runtime.runWorkflow({ workflowId: 'build', workflowDir, traceId: 'done-trace-001' });
+    expect(failed.stepResults.every((step) => step.success)).toBe(true);
+    expect(failed.success).toBe(false);
+    expect(failed.error).toEqual({ code: 'DEFINITION_OF_DONE_FAILED', message: 'Definition of done not met: report' });
+    expect(failed.definitionOfDone?.source).toBe('file');
+    const trace = await runtime.getTrace('done-trace-001');
+    expect(trace?.status).toBe('failed');
+    expect(trace?.metadata?.doneVerification).toMatchObject({ passed: false });
+
+    await writeFile(join(tempDir, 'report.md'), '# Build report\n', 'utf8');
+    const passed = await runtime.runWorkflow({ workflowId: 'build', workflowDir });
+    expect(passed.success).toBe(true);
+    expect(passed.doneVerification?.results.map((result) => [result.id, result.status])).toEqual([['report', 'passed']]);
+
+    const skipped = await runtime.checkDefinitionOfDone({ workflowId: 'build', workflowDir, input: { done: false } });
+    expect(skipped).toEqual({ definitionOfDone: undefined, verification: undefined });
+    expect((await runtime.checkDefinitionOfDone({ workflowId: 'build', workflowDir, verify: true })).verification?.passed).toBe(true);
+  });
+});
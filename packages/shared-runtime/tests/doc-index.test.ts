@@ -0,0 +1,77 @@
+import { mkdirSync, writeFileSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { extractDeclarations } from '../src/structural-diff.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `doc-index-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(join(dir, 'store'), { recursive: true });
+  return dir;
+}
+
+const STORE_SOURCE = [
+  'package store',
+  '',
+  '// Store keeps sessions in memory.',
+  '// It is safe for concurrent use.',
+  'type Store struct{}',
+  '',
+  '/*',
+  ' * Expire drops sessions idle for longer than their TTL.',
+  ' */',
+  'func (s *Store) Expire() {}',
+  '',
+  '//go:noinline',
+  'func helper() {}',
+  '',
+  '// Open returns an empty store.',
+  '//',
+  '//go:noinline',
+  'func Open() *Store { return &Store{} }',
+].join('\n');
+
+describe('doc comment index', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((dir) => rm(dir, { recursive: true, force: true })));
+  });
+
+  it('links Go doc comments to their declarations, without directives', () => {
+    const docs = extractDeclarations(STORE_SOURCE, 'store/store.go').map((declaration) => [declaration.name, declaration.doc]);
+    expect(docs).toEqual([
+      ['Store', 'Store keeps sessions in memory.\nIt is safe for concurrent use.'],
+      ['Store.Expire', 'Expire drops sessions idle for longer than their TTL.'],
+      ['helper', undefined],
+      ['Open', 'Open returns an empty store.'],
+    ]);
+  });
+
+  it('stores documented symbols as searchable entries and drops docs that disappear', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    writeFileSync(join(tempDir, 'store', 'store.go'), STORE_SOURCE);
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const first = await runtime.indexDocComments();
+    expect(first.namespace).toBe('code-docs');
+    expect(first.indexed.map((entry) => entry.key)).toEqual(['store/store.go#Store', 'store/store.go#Store.Expire', 'store/store.go#Open']);
+
+    const [hit] = await runtime.searchSemantic('drop idle sessions past their TTL', { namespace: 'code-docs', topK: 1 });
+    expect(hit).toMatchObject({
+      key: 'store/store.go#Store.Expire',
+      tags: ['doc-comment'],
+      metadata: { path: 'store/store.go', symbol: 'Store.Expire', kind: 'method', startLine: 10 },
+    });
+
+    writeFileSync(join(tempDir, 'store', 'store.go'), STORE_SOURCE.replace('// Open returns an empty store.\n', ''));
+    const second = await runtime.indexDocComments({ paths: ['store'] });
+    expect(second.removed).toEqual(['store/store.go#Open']);
+    expect((await runtime.listSemantic({ namespace: 'code-docs' })).map((entry) => entry.key).sort()).toEqual([
+      'store/store.go#Store',
+      'store/store.go#Store.Expire',
+    ]);
+  });
+});
@@ -0,0 +1,50 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { basename, join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { buildEditorLink, resolveEditorLinkTemplate } from '../src/editor-links.js';
+import { createSharedRuntimeService } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `editor-links-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+describe('editor deep links', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('resolves presets, custom templates, and the per-user override', () => {
+        expect(resolveEditorLinkTemplate(undefined, {})).toBe('vscode://file/{path}:{line}:{column}');
+        expect(resolveEditorLinkTemplate({ scheme: 'jetbrains' }, {})).toContain('jetbrains://');
+        expect(resolveEditorLinkTemplate('vscode', { AX_EDITOR_LINK: 'myeditor://{relativePath}#L{line}' })).toBe('myeditor://{relativePath}#L{line}');
+        expect(resolveEditorLinkTemplate({ scheme: 'none' }, {})).toBeUndefined();
+        expect(() => resolveEditorLinkTemplate({ scheme: 'notepad' }, {})).toThrow('Unknown editor link scheme');
+        expect(buildEditorLink('vscode://file/{path}:{line}:{column}', '/work/my app', { file: 'src/a b.ts', line: 12 }))
+            .toBe('vscode://file/work/my%20app/src/a%20b.ts:12:1');
+        expect(buildEditorLink('jetbrains://idea/navigate/reference?project={project}&path={relativePath}:{line}', '/work/app', { file: 'src/a.ts', line: 3 }))
+            .toBe('jetbrains://idea/navigate/reference?project=app&path=src/a.ts:3');
+    });
+    it('attaches links to review findings and ask sources', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'src'), { recursive: true });
+        await mkdir(join(tempDir, '.automatosx'), { recursive: true });
+        await writeFile(join(tempDir, 'src', 'debug.ts'), 'export const x = 1;\nconsole.log(x);\n', 'utf8');
+        await writeFile(join(tempDir, 'README.md'), '# App\n\n## Logging\nUse the logger, not console.\n', 'utf8');
+        await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({
+            editor: { linkTemplate: 'myeditor://open?project={project}&file={relativePath}&line={line}' },
+        }), 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const review = await runtime.analyzeReview({ paths: ['src'], focus: 'maintainability' });
+        const finding = review.findings.find((entry) => entry.ruleId === 'maintainability.console-log');
+        const project = encodeURIComponent(basename(tempDir));
+        expect(finding?.link).toBe(`myeditor://open?project=${project}&file=src/debug.ts&line=2`);
+        expect(await readFile(review.reportPath, 'utf8')).toContain(`[src/debug.ts:2](myeditor://open?project=${project}&file=src/debug.ts&line=2)`);
+        const answer = await runtime.askQuestion({ question: 'logging guidance', provider: 'claude' });
+        expect(answer.sources[0]).toMatchObject({
+            ref: 'README.md#Logging',
+            link: `myeditor://open?project=${project}&file=README.md&line=3`,
+        });
+    });
+});
@@ -0,0 +1,81 @@
+import { mkdirSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createOrchestrator, type OrchestratorEvent } from '../src/embed.js';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `embed-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('embedding API', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('runs workflows with orchestrator defaults and streams run events', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const orchestrator = createOrchestrator({ basePath: tempDir, sessionId: 'embed-session' });
+
+    const received: OrchestratorEvent[] = [];
+    const unsubscribe = orchestrator.on((event) => received.push(event));
+    const controller = new AbortController();
+    const streamed: OrchestratorEvent[] = [];
+    const consumer = (async () => {
+      for await (const event of orchestrator.events({ signal: controller.signal })) {
+        streamed.push(event);
+        if (event.type === 'run.completed' || event.type === 'run.failed') {
+          break;
+        }
+      }
+    })();
+
+    const result = await orchestrator.runWorkflow('ship', {
+      workflowDir: join(process.cwd(), 'workflows'),
+      traceId: 'embed-trace-001',
+      input: { prompt: 'ship checkout flow' },
+    });
+    await consumer;
+    unsubscribe();
+
+    expect(result.success).toBe(true);
+    const trace = await orchestrator.runtime.getTrace('embed-trace-001');
+    expect(trace?.metadata).toMatchObject({ sessionId: 'embed-session' });
+    expect(received.map((event) => event.type)).toEqual([
+      'run.started',
+      ...trace!.stepResults.map(() => 'step.completed'),
+      'run.completed',
+    ]);
+    expect(received.every((event) => event.traceId === 'embed-trace-001' && event.workflowId === 'ship')).toBe(true);
+    expect(streamed).toEqual(received);
+
+    // Unsubscribed listeners and aborted streams see nothing further.
+    controller.abort();
+    const idle = orchestrator.events({ signal: controller.signal });
+    await expect(idle.next()).resolves.toEqual({ value: undefined, done: true });
+    await orchestrator.runWorkflow('ship', { workflowDir: join(process.cwd(), 'workflows'), input: { prompt: 'again' } });
+    expect(received).toHaveLength(trace!.stepResults.length + 2);
+  });
+
+  it('shares memory with the runtime used by the CLI and MCP server', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const orchestrator = createOrchestrator({ basePath: tempDir });
+
+    await orchestrator.memory.store('deploy-window', { day: 'tuesday' }, 'ops');
+    await orchestrator.memory.semantic.store({ key: 'runbook', namespace: 'ops', content: 'Roll back the checkout service by redeploying the previous tag.' });
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    expect(await runtime.getMemory('deploy-window', 'ops')).toMatchObject({ value: { day: 'tuesday' } });
+    expect((await orchestrator.memory.list('ops')).map((entry) => entry.key)).toEqual(['deploy-window']);
+    expect((await orchestrator.memory.semantic.search('roll back checkout', { namespace: 'ops' }))[0]?.key).toBe('runbook');
+    expect(await orchestrator.memory.delete('deploy-window', 'ops')).toBe(true);
+    expect(await runtime.getMemory('deploy-window', 'ops')).toBeUndefined();
+  });
+});
@@ -0,0 +1,120 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import {
+  createLocalEmbeddingProvider,
+  EMBEDDING_INDEX_FILE,
+  fetchEmbeddingModel,
+  resolveEmbeddingConfig,
+  type EmbeddingProvider,
+} from '../src/embeddings.js';
+
+// Words on the same axis are synonyms, which term vectors cannot see.
+const AXES: Record<string, number> = { car: 0, automobile: 0, vehicle: 0, invoice: 1, billing: 1, payment: 1, login: 2, auth: 2 };
+
+function createFakeProvider(): EmbeddingProvider & { embedded: string[] } {
+  const embedded: string[] = [];
+  return {
+    id: 'fake:axes',
+    embedded,
+    async embed(texts) {
+      embedded.push(...texts);
+      return texts.map((text) => {
+        const vector = [0, 0, 0, 0.01];
+        for (const word of text.toLowerCase().split(/\W+/)) {
+          const axis = AXES[word];
+          if (axis !== undefined) {
+            vector[axis]! += 1;
+          }
+        }
+        return vector;
+      });
+    },
+  };
+}
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `embeddings-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('embeddings', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('ranks semantic memory by embedding and reuses indexed vectors', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const provider = createFakeProvider();
+    const runtime = createSharedRuntimeService({ basePath: tempDir, embeddingProvider: provider });
+
+    await runtime.storeSemantic({ key: 'fleet', content: 'Each car is serviced yearly' });
+    await runtime.storeSemantic({ key: 'ledger', content: 'Invoice numbers are sequential' });
+    await runtime.storeSemantic({ key: 'session', content: 'Login tokens expire hourly' });
+    expect(provider.embedded).toHaveLength(3);
+
+    const results = await runtime.searchSemantic('automobile maintenance', { mode: 'vector', topK: 1 });
+    expect(results.map((result) => result.key)).toEqual(['fleet']);
+    expect(results[0]!.score).toBeGreaterThan(0.9);
+    // Stored entries were embedded on write; the search embedded only its query.
+    expect(provider.embedded).toHaveLength(4);
+
+    const hybrid = await runtime.searchSemantic('billing', { mode: 'hybrid' });
+    expect(hybrid[0]).toMatchObject({ key: 'ledger' });
+    expect(hybrid[0]!.vectorScore).toBeGreaterThan(0.9);
+
+    const index = JSON.parse(await readFile(join(tempDir, EMBEDDING_INDEX_FILE), 'utf8')) as { provider: string; vectors: Record<string, string> };
+    expect(index.provider).toBe('fake:axes');
+    expect(Object.keys(index.vectors)).toHaveLength(3);
+
+    await runtime.deleteSemantic('session');
+    await runtime.searchSemantic('car', { mode: 'vector' });
+    const pruned = JSON.parse(await readFile(join(tempDir, EMBEDDING_INDEX_FILE), 'utf8')) as { vectors: Record<string, string> };
+    expect(Object.keys(pruned.vectors)).toHaveLength(2);
+  });
+
+  it('seals the embedding index when memory is encrypted', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, '.automatosx'), { recursive: true });
+    await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({ memory: { encryption: { keyEnv: 'AX_TEST_EMBEDDING_KEY', keychain: false } } }), 'utf8');
+    process.env.AX_TEST_EMBEDDING_KEY = 'embedding secret';
+    try {
+      const provider = createFakeProvider();
+      const runtime = createSharedRuntimeService({ basePath: tempDir, embeddingProvider: provider });
+      await runtime.storeSemantic({ key: 'fleet', content: 'Each car is serviced yearly' });
+
+      const stored = JSON.parse(await readFile(join(tempDir, EMBEDDING_INDEX_FILE), 'utf8')) as Record<string, unknown>;
+      expect(Object.keys(stored)).toEqual(['sealed']);
+      expect((await runtime.searchSemantic('vehicle', { mode: 'vector' }))[0]).toMatchObject({ key: 'fleet' });
+      expect(provider.embedded).toHaveLength(2);
+    } finally {
+      delete process.env.AX_TEST_EMBEDDING_KEY;
+    }
+  });
+
+  it('reads the local provider from config and explains how to install it', async () => {
+    expect(resolveEmbeddingConfig(undefined, '/work')).toBeUndefined();
+    expect(resolveEmbeddingConfig({ provider: 'terms' }, '/work')).toBeUndefined();
+    // Offline unless config opts in to downloads.
+    expect(resolveEmbeddingConfig({ provider: 'local' }, '/work')).toEqual({
+      model: 'Xenova/all-MiniLM-L6-v2',
+      modelsDir: join('/work', '.automatosx', 'models'),
+      offline: true,
+      batchSize: 16,
+    });
+    expect(resolveEmbeddingConfig({ provider: 'local', offline: false }, '/work')?.offline).toBe(false);
+    expect(() => resolveEmbeddingConfig({ provider: 'openai' }, '/work')).toThrow('must be "local" or "terms"');
+
+    const provider = createLocalEmbeddingProvider(resolveEmbeddingConfig({ provider: 'local' }, process.cwd())!);
+    expect(provider.id).toBe('local:Xenova/all-MiniLM-L6-v2');
+    await expect(provider.embed(['hello'])).rejects.toThrow('npm install @huggingface/transformers');
+    await expect(fetchEmbeddingModel(resolveEmbeddingConfig({ provider: 'local' }, process.cwd())!)).rejects.toThrow('npm install @huggingface/transformers');
+  });
+});
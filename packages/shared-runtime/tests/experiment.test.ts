@@ -0,0 +1,138 @@
+import { execFile } from 'node:child_process';
+import { mkdirSync } from 'node:fs';
+import { readdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { promisify } from 'node:util';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { planExperimentVariants, rankExperimentVariants, type ExperimentVariantResult } from '../src/experiment.js';
+
+const execFileAsync = promisify(execFile);
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `experiment-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+// claude makes the check pass, gemini makes it fail, and codex answers without a diff.
+async function configureMockProviders(tempDir: string): Promise<void> {
+  const scriptPath = join(tempDir, 'mock-provider.mjs');
+  await writeFile(scriptPath, [
+    "let input = '';",
+    "process.stdin.setEncoding('utf8');",
+    "process.stdin.on('data', (chunk) => { input += chunk; });",
+    "process.stdin.on('end', () => {",
+    "  const payload = JSON.parse(input || '{}');",
+    "  const provider = payload.provider || 'unknown';",
+    "  const value = provider === 'claude' ? 'ok' : 'bad';",
+    "  const content = provider === 'codex'",
+    "    ? 'I would change value.txt.'",
+    "    : ['Here is the change.', '--- a/value.txt', '+++ b/value.txt', '@@ -1 +1 @@', '-todo', `+${value}`, ''].join('\\n');",
+    "  process.stdout.write(JSON.stringify({ success: true, provider, model: `mock-${provider}`, content, usage: { inputTokens: 3, outputTokens: 5, totalTokens: 8 } }));",
+    "});",
+  ].join('\n'), 'utf8');
+
+  process.env.AUTOMATOSX_PROVIDER_EXECUTION_MODE = 'require-real';
+  for (const provider of ['claude', 'gemini', 'codex']) {
+    const prefix = `AUTOMATOSX_PROVIDER_${provider.toUpperCase()}`;
+    process.env[`${prefix}_CMD`] = 'node';
+    process.env[`${prefix}_ARGS`] = JSON.stringify([scriptPath]);
+  }
+}
+
+function clearProviderExecutorEnv(): void {
+  for (const key of Object.keys(process.env)) {
+    if (key.startsWith('AUTOMATOSX_PROVIDER_')) {
+      delete process.env[key];
+    }
+  }
+}
+
+function variant(variantId: string, overrides: Partial<ExperimentVariantResult>): ExperimentVariantResult {
+  return {
+    variantId,
+    strategy: 'minimal',
+    status: 'passed',
+    passedCriteria: 1,
+    checkedCriteria: 1,
+    files: ['a.ts'],
+    additions: 1,
+    deletions: 1,
+    latencyMs: 100,
+    patch: '',
+    ...overrides,
+  };
+}
+
+describe('experiments', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    clearProviderExecutorEnv();
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('spreads variants over strategies and providers in turn', () => {
+    const guidance = { minimal: 'small', thorough: 'full', refactor: 'clean' };
+    expect(planExperimentVariants({ count: 4, providers: ['claude', 'gemini'], guidance })).toEqual([
+      { strategy: 'minimal', provider: 'claude' },
+      { strategy: 'thorough', provider: 'gemini' },
+      { strategy: 'refactor', provider: 'claude' },
+      { strategy: 'minimal', provider: 'gemini' },
+    ]);
+    expect(() => planExperimentVariants({ count: 2, strategies: ['fast'], guidance })).toThrow('Unknown experiment strategy fast');
+    expect(() => planExperimentVariants({ count: 0, guidance })).toThrow('variants must be an integer');
+  });
+
+  it('ranks passing variants first, then by criteria passed, diff size, and speed', () => {
+    const ranked = rankExperimentVariants([
+      variant('v1', { status: 'error', passedCriteria: 0 }),
+      variant('v2', { status: 'failed', passedCriteria: 0 }),
+      variant('v3', { additions: 9 }),
+      variant('v4', { latencyMs: 50 }),
+      variant('v5', {}),
+    ]);
+    expect(ranked.map((entry) => entry.variantId)).toEqual(['v4', 'v5', 'v3', 'v2', 'v1']);
+  });
+
+  it('checks each variant in its own sandbox and applies the picked one', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await execFileAsync('git', ['init', '-b', 'main'], { cwd: tempDir });
+    await writeFile(join(tempDir, 'value.txt'), 'todo\n', 'utf8');
+    await writeFile(join(tempDir, 'check.mjs'), "import { readFileSync } from 'node:fs';\nprocess.exit(readFileSync('value.txt', 'utf8').trim() === 'ok' ? 0 : 1);\n", 'utf8');
+    await configureMockProviders(tempDir);
+    const sandboxesBefore = (await readdir(tmpdir())).filter((name) => name.startsWith('ax-experiment-'));
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const record = await runtime.runExperiment({
+      task: 'Set value.txt to the right value',
+      variants: 3,
+      providers: ['gemini', 'claude', 'codex'],
+      done: ['node check.mjs'],
+    });
+
+    expect(record.variants.map((entry) => [entry.variantId, entry.provider, entry.strategy, entry.status, entry.passedCriteria, entry.checkedCriteria])).toEqual([
+      ['v2', 'claude', 'thorough', 'passed', 1, 1],
+      ['v1', 'gemini', 'minimal', 'failed', 0, 1],
+      ['v3', 'codex', 'refactor', 'error', 0, 0],
+    ]);
+    expect(record.variants[0]).toMatchObject({ files: ['value.txt'], additions: 1, deletions: 1, tokens: 8 });
+    expect(record.variants[2]?.error).toBe('The response had no unified diff.');
+    expect(record.recommended).toBe('v2');
+    expect(await readFile(join(tempDir, 'value.txt'), 'utf8')).toBe('todo\n');
+    expect((await readdir(tmpdir())).filter((name) => name.startsWith('ax-experiment-'))).toEqual(sandboxesBefore);
+
+    expect((await runtime.listExperiments()).map((entry) => entry.experimentId)).toEqual([record.experimentId]);
+    await expect(runtime.pickExperimentVariant({ experimentId: record.experimentId, variantId: 'v3' })).rejects.toThrow('Variant v3 has no diff to apply');
+
+    const picked = await runtime.pickExperimentVariant({ experimentId: record.experimentId });
+    expect(picked.variantId).toBe('v2');
+    expect(picked.patch.applied).toEqual([{ path: 'value.txt', hunks: [0] }]);
+    expect(await readFile(join(tempDir, 'value.txt'), 'utf8')).toBe('ok\n');
+    expect((await runtime.getExperiment({ experimentId: record.experimentId })).picked?.variantId).toBe('v2');
+    await expect(runtime.getExperiment({ experimentId: 'exp-missing' })).rejects.toThrow('Experiment exp-missing not found');
+  });
+});
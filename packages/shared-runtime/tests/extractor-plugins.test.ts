@@ -0,0 +1,104 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { parseDeclarationExtractor } from '../src/extractor-plugins.js';
+import { createSharedRuntimeService } from '../src/index.js';
+import { supportsStructuralDiff } from '../src/structural-diff.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `extractor-plugins-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+// One declaration per `resource "type" "name" {` block, closed by the next line holding only `}`.
+const TERRAFORM_PLUGIN = `
+export default {
+  language: 'Terraform',
+  extensions: ['.tf'],
+  extract(content) {
+    const lines = content.split('\\n');
+    const declarations = [];
+    lines.forEach((line, index) => {
+      const match = /^resource "([\\w-]+)" "([\\w-]+)"/.exec(line);
+      if (match === null) {
+        return;
+      }
+      const end = lines.findIndex((candidate, after) => after > index && candidate === '}');
+      declarations.push({
+        kind: 'type',
+        name: match[1] + '.' + match[2],
+        exported: true,
+        signature: line.replace(/\\s*\\{$/, ''),
+        body: lines.slice(index + 1, end).map((entry) => entry.trim()).join(' '),
+        line: index + 1,
+        endLine: end + 1,
+      });
+    });
+    return declarations;
+  },
+};
+`;
+
+describe('extractor plugins', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('validates plugin shape and the declarations an extractor returns', () => {
+    expect(() => parseDeclarationExtractor(undefined, 'none.js')).toThrow('must export an object');
+    expect(() => parseDeclarationExtractor({ language: 'sql', extensions: ['sql'], extract: () => [] }, 'sql.js')).toThrow('file extensions');
+    expect(() => parseDeclarationExtractor({ language: 'sql', extensions: ['.sql'] }, 'sql.js')).toThrow('extract(content, path)');
+    expect(() => parseDeclarationExtractor({ language: 'sql', extensions: ['.sql'], extract: () => 'nope' }, 'sql.js')).toThrow('expected an array');
+
+    const extractor = parseDeclarationExtractor({
+      language: 'SQL',
+      extensions: ['.SQL'],
+      extract: (content: string) => (content.length === 0 ? [] : [{ kind: 'table', name: 'users', line: 1 }]),
+    }, 'sql.js');
+    expect(extractor).toMatchObject({ language: 'sql', extensions: ['.sql'] });
+    expect(() => extractor.extract('create table users ();', 'schema.sql')).toThrow('unknown kind "table"');
+  });
+
+  it('loads plugins from the workspace and uses them for symbol search', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, '.automatosx', 'extractors'), { recursive: true });
+    await mkdir(join(tempDir, 'infra'), { recursive: true });
+    await writeFile(join(tempDir, '.automatosx', 'extractors', 'terraform.mjs'), TERRAFORM_PLUGIN, 'utf8');
+    await writeFile(
+      join(tempDir, '.automatosx', 'extractors', 'typescript.mjs'),
+      "export const extractor = { language: 'ts2', extensions: ['.ts'], extract: () => [] };\n",
+      'utf8',
+    );
+    await writeFile(join(tempDir, '.automatosx', 'extractors', 'README.md'), 'not a plugin\n', 'utf8');
+    await writeFile(join(tempDir, 'infra', 'main.tf'), [
+      'resource "aws_s3_bucket" "logs" {',
+      '  bucket = "logs"',
+      '}',
+      '',
+      'resource "aws_iam_role" "deploy" {',
+      '  name = "deploy"',
+      '}',
+      '',
+    ].join('\n'), 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const report = await runtime.loadExtractorPlugins();
+    expect(report.loaded).toEqual([
+      { language: 'terraform', extensions: ['.tf'], sourcePath: join(tempDir, '.automatosx', 'extractors', 'terraform.mjs') },
+    ]);
+    expect(report.errors).toEqual([
+      { sourcePath: join(tempDir, '.automatosx', 'extractors', 'typescript.mjs'), error: 'Extension .ts is handled by a built-in extractor' },
+    ]);
+    expect(supportsStructuralDiff('infra/network.tf')).toBe(true);
+
+    const symbols = await runtime.findSymbols({ query: 'lang:terraform name:aws_s3*' });
+    expect(symbols.symbols.map((symbol) => ({ name: symbol.name, path: symbol.path, line: symbol.line }))).toEqual([
+      { name: 'aws_s3_bucket.logs', path: 'infra/main.tf', line: 1 },
+    ]);
+  });
+});
@@ -0,0 +1,66 @@
+import { mkdirSync } from 'node:fs';
+import { rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { packFiles, resolveFileAnchors } from '../src/file-pack.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `file-pack-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(join(dir, 'src'), { recursive: true });
+    return dir;
+}
+describe('file pack', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('packs files in path order with numbered lines and resolves cited anchors', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writeFile(join(tempDir, 'src', 'b.ts'), 'export const b = 2;\n');
+        await writeFile(join(tempDir, 'src', 'a.ts'), ['export function a() {', '  return 1;', '}', ''].join('\n'));
+        await writeFile(join(tempDir, 'README.md'), 'Uses ``` fences\n');
+        await writeFile(join(tempDir, '.axignore'), 'secrets.env\n');
+        await writeFile(join(tempDir, 'secrets.env'), 'TOKEN=x\n');
+        const files = ['src/b.ts', './src/a.ts', 'src/a.ts', '../outside.ts', 'secrets.env', 'missing.ts'];
+        const pack = await packFiles({ basePath: tempDir, files, provider: 'claude' });
+        expect(pack.files).toEqual([
+            { path: 'src/a.ts', lines: 3, truncated: false },
+            { path: 'src/b.ts', lines: 1, truncated: false },
+        ]);
+        expect(pack.text).toBe([
+            '<file path="src/a.ts" lines="1-3">',
+            '1| export function a() {',
+            '2|   return 1;',
+            '3| }',
+            '</file>',
+            '',
+            '<file path="src/b.ts" lines="1-1">',
+            '1| export const b = 2;',
+            '</file>',
+        ].join('\n'));
+        expect(pack.skipped.map((entry) => entry.path)).toEqual(['../outside.ts', 'missing.ts', 'secrets.env']);
+        expect((await packFiles({ basePath: tempDir, files: [...files].reverse(), provider: 'claude' })).text).toBe(pack.text);
+        const markdown = await packFiles({ basePath: tempDir, files: ['README.md', 'src/a.ts'], provider: 'gemini', maxChars: 40 });
+        expect(markdown.text.split('\n').slice(0, 4)).toEqual(['### README.md (lines 1-1)', '````md', '1| Uses ``` fences', '````']);
+        expect(markdown.files[1]).toEqual({ path: 'src/a.ts', lines: 1, truncated: true });
+        const answer = 'The constant lives at src/b.ts:1; `a` is defined in ./src/a.ts:1-3 and returns at src/a.ts:2, not src/a.ts:9 or lib/c.ts:4.';
+        expect(resolveFileAnchors(answer, pack)).toEqual([
+            { path: 'src/b.ts', line: 1 },
+            { path: 'src/a.ts', line: 1, endLine: 3 },
+            { path: 'src/a.ts', line: 2 },
+        ]);
+    });
+    it('packs files into agent runs and warns about the ones left out', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writeFile(join(tempDir, 'src', 'a.ts'), 'export const a = 1;\n');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        await runtime.registerAgent({ agentId: 'reviewer', name: 'Reviewer' });
+        const result = await runtime.runAgent({ agentId: 'reviewer', task: 'Review src/a.ts', files: ['src/a.ts', 'gone.ts'] });
+        expect(result.anchors).toEqual([]);
+        expect(result.warnings[0]).toBe('Left gone.ts out of the file pack: not readable.');
+        const trace = await runtime.getTrace(result.traceId);
+        expect(trace?.input).toMatchObject({ files: ['src/a.ts'] });
+    });
+});
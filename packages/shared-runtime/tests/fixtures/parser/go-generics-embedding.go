@@ -0,0 +1,38 @@
+package store
+
+import (
+	"embed"
+	"net/http"
+)
+
+//go:embed static/*
+var assets embed.FS
+
+//
+type List[T any] struct {
+	items []T
+}
+
+func (l *List[T]) Push(item T) {
+	l.items = append(l.items, item)
+}
+
+func Map[T, U any](xs []T, f func(T) U) []U {
+	out := make([]U, 0, len(xs))
+	for _, x := range xs {
+		out = append(out, f(x))
+	}
+	return out
+}
+
+type Number interface {
+	~int | ~float64
+}
+
+type Server struct {
+	http.Handler
+	*Logger
+	name string
+}
+
+type Logger struct{}
@@ -0,0 +1,23 @@
+package com.example.orders;
+
+import java.util.List;
+import org.springframework.stereotype.Service;
+
+/**/
+@Service
+public class OrderService<T extends Order> implements Comparable<OrderService<T>> {
+    private static final String TABLE = "xxxxxx";
+
+    public record Line(String sku, int quantity) {}
+
+    enum Status { OPEN, CLOSED }
+
+    public <R> List<R> map(java.util.function.Function<T, R> mapper) {
+        return List.of();
+    }
+
+    @Override
+    public int compareTo(OrderService<T> other) {
+        return 0;
+    }
+}
@@ -0,0 +1,63 @@
+#!/usr/bin/env python3
+"""xxxxxxxxxxxxxxx
+
+xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx
+"""
+from __future__ import annotations
+
+import dataclasses
+from typing import Generic, TypeVar
+
+T = TypeVar("x")
+MAX_ITEMS: int = 50  #
+_cache: dict[str, "xxxxx"] = {}
+type Price = float
+
+
+@dataclasses.dataclass(frozen=True)
+class Line:
+    """xxxxxxxxxxxxxxx"""
+
+    sku: str
+    quantity: int = 1
+
+    def total(self, unit: Price) -> Price:
+        return unit * self.quantity
+
+
+class Repository(Generic[T]):
+    table = "xxxxxx"
+
+    def __init__(self, conn, *, timeout: float = 1.0) -> None:
+        self._conn = conn
+
+    @property
+    def size(self) -> int:
+        return len(_cache)
+
+    async def fetch(
+        self,
+        key: str,
+        default: T | None = None,
+    ) -> T | None:
+        rows = await self._conn.query(f"xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx", key)
+        return rows[0] if rows else default
+
+    def _evict(self, key):
+        _cache.pop(key, None)
+
+    class Meta:
+        ordering = ["xxx"]
+
+
+@app.route("xxxxxxxxxxxx", methods=["xxx"])
+def get_order(id: str) -> dict[str, int]:
+    total = sum(n // 2 for n in range(10))
+    return {"xx": int(id), "xxxxx": total}
+
+
+def _helper(): return 42
+
+
+if __name__ == "xxxxxxxx":
+    get_order("x")
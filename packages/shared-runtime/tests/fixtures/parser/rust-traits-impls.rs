@@ -0,0 +1,97 @@
+//
+#![allow(dead_code)]
+
+use std::fmt;
+
+//
+pub const MAX_SIDES: usize = 12;
+static mut COUNTER: u32 = 0;
+
+#[derive(Debug, Clone, PartialEq)]
+pub struct Point<T> {
+    pub x: T,
+    pub y: T,
+}
+
+pub struct Meters(f64);
+
+pub(crate) enum Shape<'a> {
+    Circle { center: Point<f64>, radius: f64 },
+    Polygon(&'a [Point<f64>]),
+}
+
+pub type Result<T> = std::result::Result<T, ShapeError>;
+
+pub trait Area {
+    const UNIT: &'static str = "xx";
+    fn area(&self) -> f64;
+    fn describe(&self) -> String {
+        format!("xxxxx", self.area(), Self::UNIT)
+    }
+}
+
+impl<T: Copy + Into<f64>> Point<T> {
+    pub fn new(x: T, y: T) -> Self {
+        Point { x, y }
+    }
+
+    fn norm(&self) -> f64 {
+        let (x, y): (f64, f64) = (self.x.into(), self.y.into());
+        (x * x + y * y).sqrt()
+    }
+}
+
+impl<'a> Area for Shape<'a> {
+    fn area(&self) -> f64 {
+        match self {
+            Shape::Circle { radius, .. } => std::f64::consts::PI * radius * radius,
+            Shape::Polygon(points) => shoelace(points),
+        }
+    }
+}
+
+impl fmt::Display for Meters {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "xxx", self.0)
+    }
+}
+
+fn shoelace<'a>(points: &'a [Point<f64>]) -> f64 {
+    let c = 'x';
+    'outer: for p in points {
+        if p.x > 0.0 { continue 'outer; }
+    }
+    0.0
+}
+
+pub async fn fetch<T>(url: &str) -> Option<T>
+where
+    T: Default,
+{
+    None
+}
+
+#[macro_export]
+macro_rules! square {
+    ($x:expr) => {
+        $x * $x
+    };
+}
+
+extern "x" {
+    pub fn abs(input: i32) -> i32;
+}
+
+mod geometry {
+    pub fn hidden() {}
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn norm_of_origin_is_zero() {
+        assert_eq!(Point::new(0.0, 0.0).norm(), 0.0);
+    }
+}
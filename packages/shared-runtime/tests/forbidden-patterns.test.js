@@ -0,0 +1,151 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { createTreeSitterQueryRunner, findForbiddenPatterns, loadForbiddenPatterns, parseForbiddenPattern, patternsForPath, } from '../src/forbidden-patterns.js';
+const PATTERNS = [
+    'patterns:',
+    '  - id: no-println',
+    '    language: go',
+    '    query: fmt.Println',
+    '    message: Log through slog instead of printing.',
+    '    fix: slog.Info{{args}}',
+    "    exclude: ['**_test.go']",
+    '',
+].join('\n');
+const ORIGINAL = [
+    'package main',
+    '',
+    'func main() {',
+    '\tfmt.Println("legacy")',
+    '\tstart()',
+    '}',
+    '',
+    'func start() {',
+    '\tready()',
+    '}',
+    '',
+].join('\n');
+const PATCH = [
+    'diff --git a/main.go b/main.go',
+    '--- a/main.go',
+    '+++ b/main.go',
+    '@@ -4,3 +4,3 @@ func main() {',
+    ' \tfmt.Println("legacy")',
+    '-\tstart()',
+    '+\tstart(true)',
+    ' }',
+    '@@ -8,3 +8,4 @@ func start() {',
+    ' func start() {',
+    '+\tfmt.Println("starting")',
+    ' \tready()',
+    ' }',
+    '',
+].join('\n');
+// Stands in for tree-sitter: the query is a literal to find, and the call's arguments are captured as `args`.
+const fakeRunner = async ({ source, query }) => source.split('\n').flatMap((line, index) => {
+    const column = line.indexOf(query);
+    if (column === -1) {
+        return [];
+    }
+    const text = line.slice(column).trim();
+    return [[
+        { name: 'match', text, startLine: index + 1, startColumn: column + 1, endLine: index + 1 },
+        { name: 'args', text: text.slice(query.length), startLine: index + 1, startColumn: column + query.length + 1, endLine: index + 1 },
+    ]];
+});
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `forbidden-patterns-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+async function writePatterns(tempDir) {
+    await mkdir(join(tempDir, '.automatosx', 'patterns'), { recursive: true });
+    await writeFile(join(tempDir, '.automatosx', 'patterns', 'go.yaml'), PATTERNS, 'utf8');
+}
+describe('forbidden patterns', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('loads pattern files and matches only the paths they cover', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writePatterns(tempDir);
+        const patterns = await loadForbiddenPatterns(tempDir);
+        expect(patterns).toHaveLength(1);
+        expect(patterns[0]).toMatchObject({ id: 'no-println', language: 'go', severity: 'warning', category: 'maintainability', exclude: ['**_test.go'] });
+        expect(patternsForPath(patterns, 'cmd/main.go')).toHaveLength(1);
+        expect(patternsForPath(patterns, 'cmd/main_test.go')).toEqual([]);
+        expect(patternsForPath(patterns, 'src/main.ts')).toEqual([]);
+        const matches = await findForbiddenPatterns({ path: 'main.go', content: ORIGINAL, patterns, runQuery: fakeRunner });
+        expect(matches).toEqual([{
+            patternId: 'no-println',
+            path: 'main.go',
+            line: 4,
+            column: 2,
+            endLine: 4,
+            text: 'fmt.Println("legacy")',
+            message: 'Log through slog instead of printing.',
+            severity: 'warning',
+            category: 'maintainability',
+            fix: 'slog.Info("legacy")',
+        }]);
+        expect(() => parseForbiddenPattern({ id: 'x', language: 'cobol', query: '(x)', message: 'm' }, 'p.yaml')).toThrow('needs a language of');
+        expect(() => parseForbiddenPattern({ id: 'x', language: 'go', message: 'm' }, 'p.yaml')).toThrow('Forbidden pattern x is missing query');
+        await writeFile(join(tempDir, '.automatosx', 'patterns', 'more.yml'), PATTERNS, 'utf8');
+        await expect(loadForbiddenPatterns(tempDir)).rejects.toThrow('Forbidden pattern no-println is defined twice');
+    });
+    it('reports matches as review findings with their suggested fix', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writePatterns(tempDir);
+        await writeFile(join(tempDir, 'main.go'), ORIGINAL, 'utf8');
+        await writeFile(join(tempDir, 'main_test.go'), ORIGINAL, 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir, queryRunner: fakeRunner });
+        const review = await runtime.analyzeReview({ paths: ['.'], focus: 'maintainability', basePath: tempDir });
+        expect(review.findings).toHaveLength(1);
+        expect(review.findings[0]).toMatchObject({
+            severity: 'warning',
+            category: 'maintainability',
+            ruleId: 'forbidden.no-println',
+            message: 'Log through slog instead of printing.',
+            file: 'main.go',
+            line: 4,
+            fix: 'slog.Info("legacy")',
+        });
+        expect((await runtime.analyzeReview({ paths: ['.'], focus: 'security', basePath: tempDir })).findings).toEqual([]);
+    });
+    it('bounces hunks that introduce a forbidden pattern back to the agent', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writePatterns(tempDir);
+        await writeFile(join(tempDir, 'main.go'), ORIGINAL, 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir, queryRunner: fakeRunner });
+        const result = await runtime.reviewPatch({
+            patch: PATCH,
+            agentId: 'backend',
+            decisions: [
+                { path: 'main.go', hunkIndex: 0, decision: 'accept' },
+                { path: 'main.go', hunkIndex: 1, decision: 'accept' },
+            ],
+        });
+        // The Println that was already there is left alone; only the new one is refused.
+        expect(result.applied).toEqual([{ path: 'main.go', hunks: [0] }]);
+        expect(result.rejected).toEqual([{
+            path: 'main.go',
+            hunkIndex: 1,
+            bounced: true,
+            reason: 'forbidden pattern no-println at line 9 (`fmt.Println("starting")`): Log through slog instead of printing. Suggested fix: replace it with `slog.Info("starting")`.',
+        }]);
+        expect(result.feedbackIds).toHaveLength(1);
+        const patched = await readFile(join(tempDir, 'main.go'), 'utf8');
+        expect(patched).toContain('start(true)');
+        expect(patched).not.toContain('starting');
+    });
+    it('explains how to install tree-sitter when it is missing', async () => {
+        const runQuery = createTreeSitterQueryRunner(process.cwd());
+        await expect(runQuery({ language: 'go', source: 'package main', query: '(identifier) @match' })).rejects.toThrow('npm install web-tree-sitter tree-sitter-wasms');
+    });
+});
@@ -0,0 +1,72 @@
+import { mkdirSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { detectGeneratedCode } from '../src/generated-code.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `generated-code-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(join(dir, '.automatosx'), { recursive: true });
+  return dir;
+}
+
+describe('generated code', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('detects generated files from headers, names, and minified content', () => {
+    expect(detectGeneratedCode('api/user.ts', '// Generated by the protocol buffer compiler.  DO NOT EDIT!\nexport {};\n')).toEqual({
+      kind: 'protobuf',
+      reason: 'protoc header',
+    });
+    expect(detectGeneratedCode('api/user.pb.go')).toEqual({ kind: 'protobuf', reason: 'protobuf output file name' });
+    expect(detectGeneratedCode('store/store.go', '// Code generated by MockGen. DO NOT EDIT.\npackage store\n')?.kind).toBe('mock');
+    expect(detectGeneratedCode('internal/mocks/client.go')?.kind).toBe('mock');
+    expect(detectGeneratedCode('dist/app.min.js')?.kind).toBe('bundle');
+    expect(detectGeneratedCode('dist/app.js', `${'var a=1;'.repeat(200)}\n`)).toEqual({ kind: 'bundle', reason: 'minified content' });
+    expect(detectGeneratedCode('sql/queries.go', '// Code generated by sqlc. DO NOT EDIT.\npackage sql\n')).toEqual({
+      kind: 'generated',
+      reason: '"Code generated by sqlc. DO NOT EDIT" header',
+    });
+  });
+
+  it('ignores markers outside leading comments', () => {
+    expect(detectGeneratedCode('src/banner.ts', "export const BANNER = '// Code generated by tool. DO NOT EDIT.';\n")).toBeUndefined();
+    expect(detectGeneratedCode('src/late.ts', `${'const x = 1;\n'.repeat(20)}// @generated\n`)).toBeUndefined();
+    expect(detectGeneratedCode('src/cart.ts', 'export const total = 1;\n')).toBeUndefined();
+  });
+
+  it('tags generated chunks and leaves them out of semantic search by default', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const generated = await runtime.storeSemanticFile({
+      path: 'src/gen/cart_pb.ts',
+      content: '// @generated by protoc-gen-es v1.2.0\nexport function cartTotal(): number {\n  return 0;\n}\n',
+      namespace: 'code',
+    });
+    expect(generated.generated).toBe('protobuf');
+    const handWritten = await runtime.storeSemanticFile({
+      path: 'src/cart.ts',
+      content: 'export function cartTotal(): number {\n  return 1;\n}\n',
+      namespace: 'code',
+    });
+    expect(handWritten.generated).toBeUndefined();
+
+    const defaults = await runtime.searchSemantic('cartTotal', { namespace: 'code', mode: 'keyword' });
+    expect(defaults.map((entry) => entry.metadata?.path)).toEqual(['src/cart.ts']);
+
+    const included = await runtime.searchSemantic('cartTotal', { namespace: 'code', mode: 'keyword', includeGenerated: true });
+    expect(included.map((entry) => entry.metadata?.path)).toEqual(['src/cart.ts', 'src/gen/cart_pb.ts']);
+    expect(included[1]?.tags).toContain('generated');
+    expect(included[1]?.metadata?.generated).toBe('protobuf');
+
+    const tagged = await runtime.searchSemantic('cartTotal', { namespace: 'code', mode: 'keyword', filterTags: ['generated'] });
+    expect(tagged.map((entry) => entry.metadata?.path)).toEqual(['src/gen/cart_pb.ts']);
+  });
+});
@@ -0,0 +1,113 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { matchesEmbedPattern } from '../src/go-embeds.js';
+import { createSharedRuntimeService } from '../src/index.js';
+import { extractDeclarations } from '../src/structural-diff.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `go-embeds-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const WEB_GO = [
+  'package web',
+  '',
+  'import "embed"',
+  '',
+  '// Static holds the site assets.',
+  '//go:embed static',
+  'var Static embed.FS',
+  '',
+  '//go:embed "index.html" tmpl/*.tmpl',
+  'var pages embed.FS',
+  '',
+  '//go:embed all:static',
+  'var everything embed.FS',
+  '',
+].join('\n');
+
+const SQL_GO = [
+  'package sql',
+  '',
+  '/*',
+  '#include <stdlib.h>',
+  '*/',
+  'import "C"',
+  'import "unsafe"',
+  '',
+  '//go:embed schema.sql',
+  'var schema string',
+  '',
+  'func Free(p *C.char) {',
+  '\tC.free(unsafe.Pointer(p)) // C.ignored',
+  '}',
+  '',
+].join('\n');
+
+describe('go embeds', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('attaches embed patterns and cgo names to Go declarations', () => {
+    expect(extractDeclarations(SQL_GO, 'sql/sql.go').map((declaration) => ({
+      name: declaration.name,
+      embeds: declaration.embeds,
+      cgo: declaration.cgo,
+    }))).toEqual([
+      { name: 'schema', embeds: ['schema.sql'], cgo: undefined },
+      { name: 'Free', embeds: undefined, cgo: ['char', 'free'] },
+    ]);
+    expect(extractDeclarations(WEB_GO, 'web/web.go').map((declaration) => declaration.embeds)).toEqual([
+      ['static'],
+      ['index.html', 'tmpl/*.tmpl'],
+      ['all:static'],
+    ]);
+
+    expect(matchesEmbedPattern('static', 'static/css/site.css')).toBe(true);
+    expect(matchesEmbedPattern('static', 'static/_draft/x.js')).toBe(false);
+    expect(matchesEmbedPattern('all:static', 'static/_draft/x.js')).toBe(true);
+    expect(matchesEmbedPattern('*.sql', 'schema.sql')).toBe(true);
+    expect(matchesEmbedPattern('*.sql', 'migrations/001.sql')).toBe(false);
+    expect(matchesEmbedPattern('img/[a-c]?.png', 'img/b1.png')).toBe(true);
+  });
+
+  it('resolves directives to workspace files and reports files other code embeds', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'web', 'static', 'css'), { recursive: true });
+    await mkdir(join(tempDir, 'web', 'static', '_draft'), { recursive: true });
+    await mkdir(join(tempDir, 'sql'), { recursive: true });
+    await writeFile(join(tempDir, 'web', 'web.go'), WEB_GO, 'utf8');
+    await writeFile(join(tempDir, 'sql', 'sql.go'), SQL_GO, 'utf8');
+    for (const file of ['web/index.html', 'web/static/app.js', 'web/static/css/site.css', 'web/static/_draft/x.js', 'sql/schema.sql']) {
+      await writeFile(join(tempDir, file), '', 'utf8');
+    }
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const result = await runtime.findGoEmbeds({ files: ['web/static/css', 'sql/schema.sql', 'web/index.html', 'README.md'] });
+    expect(result.scannedFiles).toBe(2);
+    expect(result.directives.map((directive) => `${directive.path}:${directive.line} ${directive.symbol} ${directive.files.join(',')}${directive.unmatched.length > 0 ? ` unmatched=${directive.unmatched.join(',')}` : ''}`)).toEqual([
+      'sql/sql.go:10 schema sql/schema.sql',
+      'web/web.go:7 Static web/static/app.js,web/static/css/site.css',
+      'web/web.go:10 pages web/index.html unmatched=tmpl/*.tmpl',
+      'web/web.go:13 everything web/static/_draft/x.js,web/static/app.js,web/static/css/site.css',
+    ]);
+    expect(result.referenced).toEqual({
+      'web/static/css': [
+        { path: 'web/web.go', line: 7, symbol: 'Static', pattern: 'static' },
+        { path: 'web/web.go', line: 13, symbol: 'everything', pattern: 'all:static' },
+      ],
+      'sql/schema.sql': [{ path: 'sql/sql.go', line: 10, symbol: 'schema', pattern: 'schema.sql' }],
+      'web/index.html': [{ path: 'web/web.go', line: 10, symbol: 'pages', pattern: 'index.html' }],
+    });
+
+    const symbols = await runtime.findSymbols({ query: 'name:schema' });
+    expect(symbols.symbols[0]).toMatchObject({ name: 'schema', embeds: ['schema.sql'] });
+  });
+});
@@ -0,0 +1,108 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { auditGoDependencies, parseGoMod, parseGoWork } from '../src/go-modules.js';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `go-modules-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const API_GO_MOD = [
+  'module example.com/shop/api // service entrypoint',
+  '',
+  'go 1.22',
+  '',
+  'toolchain go1.22.4',
+  '',
+  'require (',
+  '\tgithub.com/google/uuid v1.5.0',
+  '\tgithub.com/pkg/errors v0.9.1 // indirect',
+  '\tgolang.org/x/exp v0.0.0-20240506185415-9bf2ced13842',
+  '\tgithub.com/docker/docker v20.10.24+incompatible',
+  ')',
+  '',
+  'require example.com/shop/common v0.0.0',
+  '',
+  'replace example.com/shop/common => ../common',
+  'replace (',
+  '\tgithub.com/google/uuid v1.5.0 => github.com/acme/uuid v1.5.1-acme',
+  ')',
+  '',
+  'exclude github.com/pkg/errors v0.9.0',
+  '',
+].join('\n');
+
+describe('go module dependency audit', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('parses go.mod and go.work directives', () => {
+    const parsed = parseGoMod(API_GO_MOD, 'api/go.mod');
+    expect(parsed).toMatchObject({ module: 'example.com/shop/api', goVersion: '1.22', toolchain: 'go1.22.4' });
+    expect(parsed.requires).toEqual([
+      { path: 'github.com/google/uuid', version: 'v1.5.0', indirect: false },
+      { path: 'github.com/pkg/errors', version: 'v0.9.1', indirect: true },
+      { path: 'golang.org/x/exp', version: 'v0.0.0-20240506185415-9bf2ced13842', indirect: false },
+      { path: 'github.com/docker/docker', version: 'v20.10.24+incompatible', indirect: false },
+      { path: 'example.com/shop/common', version: 'v0.0.0', indirect: false },
+    ]);
+    expect(parsed.replaces).toEqual([
+      { oldPath: 'example.com/shop/common', oldVersion: undefined, newPath: '../common', newVersion: undefined, local: true },
+      { oldPath: 'github.com/google/uuid', oldVersion: 'v1.5.0', newPath: 'github.com/acme/uuid', newVersion: 'v1.5.1-acme', local: false },
+    ]);
+    expect(parsed.excludes).toEqual([{ path: 'github.com/pkg/errors', version: 'v0.9.0' }]);
+
+    expect(parseGoWork('go 1.22\n\nuse (\n\t./api\n\t"./common"\n)\n\nreplace golang.org/x/net => golang.org/x/net v0.25.0\n')).toEqual({
+      file: 'go.work',
+      goVersion: '1.22',
+      use: ['./api', './common'],
+      replaces: [{ oldPath: 'golang.org/x/net', oldVersion: undefined, newPath: 'golang.org/x/net', newVersion: 'v0.25.0', local: false }],
+    });
+  });
+
+  it('flags risky and outdated modules across a workspace', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'api'), { recursive: true });
+    await mkdir(join(tempDir, 'common'), { recursive: true });
+    await writeFile(join(tempDir, 'go.work'), 'go 1.22\n\nuse (\n\t./api\n\t./common\n\t./tools\n)\n', 'utf8');
+    await writeFile(join(tempDir, 'api', 'go.mod'), API_GO_MOD, 'utf8');
+    await writeFile(join(tempDir, 'common', 'go.mod'), 'module example.com/shop/common\n\ngo 1.22\n', 'utf8');
+
+    const audit = await auditGoDependencies({
+      basePath: tempDir,
+      listUpdates: async (moduleDir) => {
+        if (moduleDir.endsWith('common')) {
+          throw new Error('go: command not found');
+        }
+        return new Map([['github.com/google/uuid', 'v1.6.0'], ['github.com/pkg/errors', 'v0.9.1']]);
+      },
+    });
+
+    expect(audit.modules.map((module) => module.file)).toEqual(['api/go.mod', 'common/go.mod']);
+    expect(audit.findings.map((finding) => [finding.kind, finding.module])).toEqual([
+      ['local-replace', 'example.com/shop/common'],
+      ['replaced', 'github.com/google/uuid'],
+      ['pseudo-version', 'golang.org/x/exp'],
+      ['incompatible', 'github.com/docker/docker'],
+      ['outdated', 'github.com/google/uuid'],
+    ]);
+    expect(audit.findings.at(-1)).toMatchObject({ version: 'v1.5.0', latest: 'v1.6.0', file: 'api/go.mod' });
+    expect(audit.warnings).toEqual([
+      'go.work uses ./tools, but it has no go.mod.',
+      'Skipped update check for common/go.mod: go: command not found',
+    ]);
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const offline = await runtime.auditDependencies({ checkUpdates: false });
+    expect(offline.findings.some((finding) => finding.kind === 'outdated')).toBe(false);
+    expect(offline.workspace?.use).toEqual(['./api', './common', './tools']);
+  });
+});
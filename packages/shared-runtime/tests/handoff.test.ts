@@ -0,0 +1,71 @@
+import { mkdirSync } from 'node:fs';
+import { readFile, rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { buildOnCallHandoff } from '../src/handoff.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `handoff-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('on-call handoff', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('classifies incidents, fix sessions, follow-ups, and risky changes within the window', () => {
+    const now = new Date('2026-03-10T12:00:00.000Z');
+    const handoff = buildOnCallHandoff({
+      now,
+      windowHours: 24,
+      traces: [
+        { traceId: 'trace-failed', workflowId: 'release', surface: 'cli', status: 'failed', startedAt: '2026-03-10T08:00:00.000Z', stepResults: [], error: { message: 'deploy timed out' } },
+        { traceId: 'trace-old', workflowId: 'release', surface: 'cli', status: 'failed', startedAt: '2026-03-01T08:00:00.000Z', stepResults: [] },
+        { traceId: 'trace-running', workflowId: 'migrate', surface: 'cli', status: 'running', startedAt: '2026-03-10T11:00:00.000Z', stepResults: [] },
+      ],
+      sessions: [
+        { sessionId: 'fix-1', task: 'Hotfix checkout timeout', initiator: 'alice', status: 'completed', summary: 'Raised pool size', participants: [], createdAt: '2026-03-10T09:00:00.000Z', updatedAt: '2026-03-10T10:00:00.000Z' },
+        { sessionId: 'feature-1', task: 'Add dark mode', initiator: 'bob', status: 'active', participants: [{ agentId: 'coder', role: 'initiator', joinedAt: '2026-03-10T09:00:00.000Z' }], createdAt: '2026-03-10T09:00:00.000Z', updatedAt: '2026-03-10T09:30:00.000Z' },
+        { sessionId: 'failed-1', task: 'Rotate API keys', initiator: 'carol', status: 'failed', error: { message: 'vault unreachable' }, participants: [], createdAt: '2026-03-10T07:00:00.000Z', updatedAt: '2026-03-10T07:30:00.000Z' },
+      ],
+      backlog: [
+        { key: 'todo-1', namespace: 'backlog', value: { title: 'Add alert for pool saturation', owner: 'alice', priority: 'high' }, updatedAt: '2026-03-10T10:00:00.000Z' },
+        { key: 'todo-2', namespace: 'backlog', value: { title: 'Old cleanup', status: 'done' }, updatedAt: '2026-03-09T10:00:00.000Z' },
+      ],
+      uncommittedFiles: ['src/checkout.ts'],
+    });
+
+    expect(handoff.incidents.map((incident) => incident.traceId)).toEqual(['trace-failed']);
+    expect(handoff.fixSessions).toMatchObject([{ sessionId: 'fix-1', outcome: 'Raised pool size' }]);
+    expect(handoff.followUps.map((followUp) => followUp.id)).toEqual(['todo-1', 'failed-1']);
+    expect(handoff.riskyChanges.map((change) => change.kind)).toEqual(['running-trace', 'active-session', 'uncommitted']);
+    expect(handoff.markdown).toContain('## Open Follow-ups');
+    expect(handoff.markdown).toContain('Add alert for pool saturation (owner: alice) [high]');
+  });
+
+  it('writes a handoff document from runtime session history and the backlog namespace', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const session = await runtime.createSession({ task: 'Investigate incident on payments', initiator: 'oncall' });
+    await runtime.failSession(session.sessionId, 'provider outage');
+    await runtime.storeMemory({ key: 'follow-up-1', namespace: 'backlog', value: { title: 'Add payments runbook' } });
+
+    const outputPath = join(tempDir, 'handoff.md');
+    const handoff = await runtime.summarizeHandoff({ outputPath, basePath: tempDir });
+
+    expect(handoff.artifactPath).toBe(outputPath);
+    expect(handoff.fixSessions.map((entry) => entry.sessionId)).toContain(session.sessionId);
+    expect(handoff.followUps.map((entry) => entry.title)).toContain('Add payments runbook');
+
+    const document = await readFile(outputPath, 'utf8');
+    expect(document).toContain('# On-Call Handoff');
+    expect(document).toContain('Investigate incident on payments');
+  });
+});
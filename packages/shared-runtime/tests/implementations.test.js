@@ -0,0 +1,90 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `implementations-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const STORE_GO = [
+    'package store',
+    '',
+    'import "io"',
+    '',
+    'type Getter interface {',
+    '\tGet(key string) (string, error)',
+    '}',
+    '',
+    'type Store interface {',
+    '\tGetter',
+    '\tio.Closer',
+    '\t// Put stores a value.',
+    '\tPut(key, value string) error',
+    '}',
+    '',
+    'type Base struct{}',
+    '',
+    'func (b Base) Close() error { return nil }',
+    '',
+    'type DiskStore struct {',
+    '\tBase',
+    '\tpath string `json:"path"`',
+    '}',
+    '',
+    'func (d *DiskStore) Get(key string) (string, error) { return "", nil }',
+    '',
+    'func (d *DiskStore) Put(key, value string) error { return nil }',
+    '',
+    'type ReadOnly struct{}',
+    '',
+    'func (r ReadOnly) Get(key string) (string, error) { return "", nil }',
+    '',
+    'func (r ReadOnly) Close() error { return nil }',
+    '',
+].join('\n');
+const SHAPES_TS = [
+    'export interface Shape {',
+    '  area(): number;',
+    '}',
+    '',
+    'export class Square implements Shape {',
+    '  area(): number { return 1; }',
+    '}',
+    '',
+    'export class Tile extends Square {}',
+    '',
+    'export class Circle {',
+    '  area(): number { return 3; }',
+    '}',
+    '',
+].join('\n');
+describe('implementations', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('finds Go implementers structurally and TS ones by declaration', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'store'), { recursive: true });
+        await writeFile(join(tempDir, 'store', 'store.go'), STORE_GO, 'utf8');
+        await writeFile(join(tempDir, 'shapes.ts'), SHAPES_TS, 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const store = await runtime.findImplementations({ symbol: 'Store' });
+        expect(store.methods).toEqual(['Close', 'Get', 'Put']);
+        expect(store.implementations).toEqual([
+            { name: 'DiskStore', path: 'store/store.go', line: 20, pointer: true, promoted: ['Close'] },
+        ]);
+        expect((await runtime.findImplementations({ symbol: 'Getter' })).implementations.map((entry) => entry.name)).toEqual(['DiskStore', 'ReadOnly']);
+        expect((await runtime.findImplementations({ symbol: 'io.Closer' })).implementations.map((entry) => entry.name)).toEqual(['Base', 'DiskStore', 'ReadOnly']);
+        expect((await runtime.findImplementations({ symbol: 'ReadOnly' })).interfaces.map((entry) => entry.name)).toEqual(['Getter']);
+        const shapes = await runtime.findImplementations({ symbol: 'Shape' });
+        expect(shapes.implementations).toEqual([
+            { name: 'Square', path: 'shapes.ts', line: 5, explicit: true },
+            { name: 'Tile', path: 'shapes.ts', line: 9, explicit: true },
+        ]);
+        await expect(runtime.findImplementations({ symbol: 'Missing' })).rejects.toThrow('No interface or type named Missing');
+    });
+});
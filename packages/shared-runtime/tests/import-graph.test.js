@@ -0,0 +1,62 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService, formatImportGraphDot } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `import-graph-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+describe('import graph', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('links Go packages through their module paths and reports external packages and cycles', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'internal', 'store'), { recursive: true });
+        await mkdir(join(tempDir, 'internal', 'api'), { recursive: true });
+        await mkdir(join(tempDir, 'cmd', 'server'), { recursive: true });
+        await writeFile(join(tempDir, 'go.mod'), 'module example.com/app\n\ngo 1.22\n', 'utf8');
+        await writeFile(join(tempDir, 'cmd', 'server', 'main.go'), [
+            'package main',
+            '',
+            'import (',
+            '\t"net/http"',
+            '',
+            '\t"example.com/app/internal/api"',
+            ')',
+            '',
+            'func main() { http.ListenAndServe(":8080", api.Handler()) }',
+        ].join('\n'), 'utf8');
+        await writeFile(join(tempDir, 'internal', 'api', 'api.go'), [
+            'package api',
+            '',
+            'import (',
+            '\t"context"',
+            '\tstore "example.com/app/internal/store"',
+            '\t"github.com/go-chi/chi/v5"',
+            ')',
+            '',
+            'func Handler() *chi.Mux { store.Open(context.Background()); return chi.NewRouter() }',
+        ].join('\n'), 'utf8');
+        await writeFile(join(tempDir, 'internal', 'store', 'store.go'), 'package store\n\nimport "example.com/app/internal/api"\n\nvar _ = api.Handler\n', 'utf8');
+        await writeFile(join(tempDir, 'internal', 'store', 'store_test.go'), 'package store_test\n\nimport "example.com/app/cmd/server"\n', 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const graph = await runtime.buildImportGraph();
+        expect(graph.nodes.map((node) => `${node.importPath} (${node.name}) -> ${node.imports.join(', ')}`)).toEqual([
+            'example.com/app/cmd/server (main) -> internal/api',
+            'example.com/app/internal/api (api) -> internal/store',
+            'example.com/app/internal/store (store) -> internal/api',
+        ]);
+        expect(graph.external).toEqual(['context', 'github.com/go-chi/chi/v5', 'net/http']);
+        expect(graph.edges.find((edge) => edge.import === 'context')).toMatchObject({ from: 'internal/api', path: 'internal/api/api.go', line: 4, std: true });
+        expect(graph.edges.find((edge) => edge.import.startsWith('github.com'))?.std).toBeUndefined();
+        expect(graph.cycles).toEqual([['internal/api', 'internal/store']]);
+        const dot = formatImportGraphDot(graph);
+        expect(dot).toContain('"example.com/app/cmd/server" -> "example.com/app/internal/api";');
+        expect(dot).toContain('"example.com/app/internal/store" -> "example.com/app/internal/api" [color=red];');
+    });
+});
@@ -0,0 +1,66 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `include-graph-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('include graph', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('resolves C includes and cgo preambles, and reports external headers and cycles', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'native', 'include'), { recursive: true });
+    await mkdir(join(tempDir, 'wrap'), { recursive: true });
+    await writeFile(join(tempDir, 'native', 'include', 'buf.h'), '#pragma once\n#include <stddef.h>\n#include "ring.h"\n', 'utf8');
+    await writeFile(join(tempDir, 'native', 'include', 'ring.h'), '#pragma once\n#include "buf.h"\n', 'utf8');
+    await writeFile(join(tempDir, 'native', 'buf.c'), '#include "buf.h"\n#include "missing.h"\n#include <stdlib.h>\n', 'utf8');
+    await writeFile(join(tempDir, 'wrap', 'wrap.go'), [
+      'package wrap',
+      '',
+      '// #cgo CFLAGS: -I../native/include',
+      '// #include <stdlib.h>',
+      '// #include "buf.h"',
+      'import "C"',
+      '',
+    ].join('\n'), 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const graph = await runtime.buildIncludeGraph({ includeDirs: ['native/include'] });
+    expect(graph.nodes.map((node) => `${node.path} -> ${node.includes.join(', ')}`)).toEqual([
+      'native/buf.c -> native/include/buf.h',
+      'native/include/buf.h -> native/include/ring.h',
+      'native/include/ring.h -> native/include/buf.h',
+      'wrap/wrap.go -> native/include/buf.h',
+    ]);
+    expect(graph.nodes.find((node) => node.path === 'native/include/buf.h')?.includedBy).toEqual([
+      'native/buf.c',
+      'native/include/ring.h',
+      'wrap/wrap.go',
+    ]);
+    expect(graph.edges.find((edge) => edge.from === 'wrap/wrap.go' && !edge.system)).toEqual({
+      from: 'wrap/wrap.go',
+      line: 5,
+      include: 'buf.h',
+      system: false,
+      resolved: 'native/include/buf.h',
+      cgo: true,
+    });
+    expect(graph.external).toEqual(['stddef.h', 'stdlib.h']);
+    expect(graph.unresolved.map((edge) => `${edge.from}:${edge.line} ${edge.include}`)).toEqual(['native/buf.c:2 missing.h']);
+    expect(graph.cycles).toEqual([['native/include/buf.h', 'native/include/ring.h']]);
+
+    const scoped = await runtime.buildIncludeGraph({ paths: ['wrap'] });
+    expect(scoped.edges.map((edge) => edge.resolved ?? edge.include)).toEqual(['stdlib.h', 'native/include/buf.h']);
+  });
+});
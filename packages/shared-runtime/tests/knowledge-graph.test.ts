@@ -0,0 +1,73 @@
+import { mkdirSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `knowledge-graph-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(join(dir, '.automatosx'), { recursive: true });
+  return dir;
+}
+
+describe('knowledge graph', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('links memories, sessions, and agents to the files and symbols they reference', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    await runtime.storeSemanticFile({
+      path: 'src/server.go',
+      content: 'package server\n\nfunc ListenAndServe(addr string) error {\n\treturn nil\n}\n',
+      namespace: 'code',
+    });
+    const session = await runtime.createSession({ sessionId: 'fix-timeouts', task: 'Fix read timeouts in src/server.go', initiator: 'backend' });
+    await runtime.joinSession({ sessionId: session.sessionId, agentId: 'reviewer' });
+    await runtime.storeMemory({
+      key: 'timeout-decision',
+      namespace: 'decisions',
+      value: { decision: 'ListenAndServe sets a 30s read timeout.', sessionId: 'fix-timeouts' },
+      agentId: 'backend',
+    });
+    await runtime.storeMemory({ key: 'billing', namespace: 'decisions', value: 'Invoices retry twice.' });
+
+    const result = await runtime.queryKnowledgeGraph({ query: './src/server.go' });
+    expect(result.start).toEqual(['file:src/server.go']);
+    const distances = Object.fromEntries(result.nodes.map((node) => [node.id, node.distance]));
+    expect(distances).toMatchObject({
+      'file:src/server.go': 0,
+      'symbol:src/server.go#ListenAndServe': 1,
+      'session:fix-timeouts': 1,
+      'memory:decisions/timeout-decision': 2,
+      'agent:backend': 2,
+      'agent:reviewer': 2,
+    });
+    expect(distances['memory:decisions/billing']).toBeUndefined();
+    expect(result.edges).toContainEqual({ from: 'memory:decisions/timeout-decision', to: 'symbol:src/server.go#ListenAndServe', relation: 'mentions' });
+    expect(result.edges).toContainEqual({ from: 'memory:decisions/timeout-decision', to: 'session:fix-timeouts', relation: 'from-session' });
+    expect(result.nodes.find((node) => node.id === 'session:fix-timeouts')?.summary).toBe('Fix read timeouts in src/server.go');
+
+    const bySymbol = await runtime.queryKnowledgeGraph({ query: 'ListenAndServe', depth: 1 });
+    expect(bySymbol.start).toEqual(['symbol:src/server.go#ListenAndServe']);
+    expect(bySymbol.nodes.map((node) => node.id).sort()).toEqual([
+      'file:src/server.go',
+      'memory:decisions/timeout-decision',
+      'semantic:code/src/server.go#ListenAndServe',
+      'symbol:src/server.go#ListenAndServe',
+    ]);
+
+    const byDirectory = await runtime.queryKnowledgeGraph({ query: 'src', depth: 1, limit: 2 });
+    expect(byDirectory.start).toEqual(['file:src/server.go']);
+    expect(byDirectory.nodes).toHaveLength(2);
+    expect(byDirectory.truncated).toBe(true);
+
+    expect((await runtime.queryKnowledgeGraph({ query: 'src/unknown.ts' })).nodes).toEqual([]);
+    await expect(runtime.queryKnowledgeGraph({ query: 'src', depth: 9 })).rejects.toThrow('Graph depth must be a whole number from 1 to 4');
+  });
+});
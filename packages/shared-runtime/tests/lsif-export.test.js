@@ -0,0 +1,77 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService, LSIF_EXPORT_FILE } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `lsif-export-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+describe('LSIF export', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('writes definitions, hovers, monikers, and name-linked references as LSIF', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'server'), { recursive: true });
+        await mkdir(join(tempDir, 'client'), { recursive: true });
+        await writeFile(join(tempDir, 'server', 'server.go'), [
+            'package server',
+            '',
+            '// Server serves requests.',
+            'type Server struct{}',
+            '',
+            'func (s *Server) Start() error { return nil }',
+            '',
+            'func Run() {',
+            '\ts := &Server{}',
+            '\ts.Start()',
+            '}',
+        ].join('\n'), 'utf8');
+        await writeFile(join(tempDir, 'server', 'helper.go'), 'package server\n\nfunc setup() {}\n', 'utf8');
+        await writeFile(join(tempDir, 'client', 'client.go'), 'package client\n\nfunc setup() {}\n\nfunc Start() {}\n', 'utf8');
+        await writeFile(join(tempDir, 'main.go'), 'package main\n\nfunc main() { setup() }\n', 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const dump = await runtime.exportLsif();
+        expect(dump.outputPath).toBe(join(tempDir, LSIF_EXPORT_FILE));
+        expect(dump).toMatchObject({ documents: 4, definitions: 7, references: 3, ambiguous: 1 });
+        const elements = (await readFile(dump.outputPath, 'utf8')).trim().split('\n').map((line) => JSON.parse(line));
+        expect(elements).toHaveLength(dump.elements);
+        expect(elements[0]).toMatchObject({ type: 'vertex', label: 'metaData', version: '0.5.0', positionEncoding: 'utf-16' });
+        const byId = new Map(elements.map((element) => [element.id, element]));
+        // Edges only point at elements written before them.
+        for (const element of elements.filter((candidate) => candidate.type === 'edge')) {
+            for (const id of [element.outV, element.inV, ...((element.inVs) ?? [])].filter((value) => value !== undefined)) {
+                expect((id) < element.id).toBe(true);
+            }
+        }
+        const document = elements.find((element) => element.label === 'document' && String(element.uri).endsWith('/server/server.go'));
+        expect(document.languageId).toBe('go');
+        const ranges = elements
+            .filter((element) => element.label === 'contains' && element.outV === document.id)
+            .flatMap((element) => element.inVs)
+            .map((id) => byId.get(id));
+        const at = (line, character) => ranges.find((range) => (range.start).line === line
+            && (range.start).character === character);
+        const resultSetOf = (range) => elements.find((element) => element.label === 'next' && element.outV === range.id).inV;
+        // `s.Start()` on line 10 links to the method in its own file, not to client.Start.
+        const startDefinition = at(5, 17);
+        expect(startDefinition.end).toEqual({ line: 5, character: 22 });
+        expect(resultSetOf(at(9, 3))).toBe(resultSetOf(startDefinition));
+        const serverSet = resultSetOf(at(3, 5));
+        expect(resultSetOf(at(8, 7))).toBe(serverSet);
+        const hover = byId.get(elements.find((element) => element.label === 'textDocument/hover' && element.outV === serverSet).inV);
+        expect(hover.result).toEqual({ contents: [{ language: 'go', value: 'type Server struct{}' }, 'Server serves requests.'] });
+        const moniker = byId.get(elements.find((element) => element.label === 'moniker' && element.outV === serverSet).inV);
+        expect(moniker).toMatchObject({ scheme: 'automatosx', identifier: 'server/server.go:Server', kind: 'export' });
+        const referenceResult = elements.find((element) => element.label === 'textDocument/references' && element.outV === serverSet).inV;
+        const items = elements.filter((element) => element.label === 'item' && element.outV === referenceResult);
+        expect(items.map((item) => [item.property, item.shard, (item.inVs).length])).toEqual([
+            ['definitions', document.id, 1],
+            ['references', document.id, 2],
+        ]);
+    });
+});
@@ -0,0 +1,49 @@
+import { mkdirSync } from 'node:fs';
+import { readFile, rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { appendMcpToolCall, MCP_TOOL_USAGE_FILE, MCP_TOOL_USAGE_PREVIOUS_FILE, queryMcpToolUsage, resolveMcpToolUsageConfig, } from '../src/mcp-tool-usage.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `mcp-tool-usage-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const DEFINITIONS = [
+    { name: 'code.find_symbols', description: 'Find symbols.', inputSchema: { properties: { query: { type: 'string' }, kind: { type: 'string', enum: ['function', 'class'] }, limit: { type: 'integer' } }, required: ['query'] } },
+    { name: 'git.status', description: 'Show git status.', inputSchema: { properties: {} } },
+];
+describe('mcp tool usage', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('summarizes calls per tool, filters by client and time, and rolls the log over', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        expect(resolveMcpToolUsageConfig(undefined).enabled).toBe(false);
+        expect(resolveMcpToolUsageConfig({ usage: { maxFileBytes: 200 } })).toEqual({ enabled: true, maxFileBytes: 200 });
+        const config = resolveMcpToolUsageConfig({ usage: { maxFileBytes: 200 } });
+        const call = { tool: 'code.find_symbols', durationMs: 10 };
+        await appendMcpToolCall(tempDir, config, { ...call, client: 'cursor', outcome: 'ok', arguments: ['kind', 'query'] }, new Date('2026-05-01T10:00:00Z'));
+        await appendMcpToolCall(tempDir, config, { ...call, client: 'cursor', outcome: 'invalid', arguments: ['kind', 'query'], error: 'arguments.kind must be one of: function, class' }, new Date('2026-05-02T10:00:00Z'));
+        await appendMcpToolCall(tempDir, config, { ...call, client: 'gemini-cli', outcome: 'failed', arguments: ['query'], error: 'x'.repeat(500), durationMs: 30 }, new Date('2026-05-03T10:00:00Z'));
+        expect(await readFile(join(tempDir, MCP_TOOL_USAGE_PREVIOUS_FILE), 'utf8')).toContain('"outcome":"ok"');
+        expect((await readFile(join(tempDir, MCP_TOOL_USAGE_FILE), 'utf8')).length).toBeLessThan(400);
+        const report = await queryMcpToolUsage(tempDir, true, DEFINITIONS);
+        expect(report.calls).toBe(3);
+        expect(report.tools[0]).toMatchObject({
+            tool: 'code.find_symbols',
+            calls: 3,
+            invalid: 1,
+            failed: 1,
+            lastAt: '2026-05-03T10:00:00.000Z',
+            averageDurationMs: 17,
+            arguments: [{ name: 'query', count: 3 }, { name: 'kind', count: 2 }],
+        });
+        expect(report.unused).toEqual(['git.status']);
+        // One invalid call is not enough to rewrite a description.
+        expect(report.suggestions).toEqual([]);
+        expect((await queryMcpToolUsage(tempDir, true, DEFINITIONS, { client: 'cursor' })).calls).toBe(2);
+        expect((await queryMcpToolUsage(tempDir, true, DEFINITIONS, { since: '2026-05-02T00:00:00Z', until: '2026-05-02T23:59:59Z' })).tools[0]).toMatchObject({ calls: 1, invalid: 1 });
+    });
+});
@@ -0,0 +1,73 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { appendMemoryAudit, MEMORY_AUDIT_FILE, MEMORY_AUDIT_PREVIOUS_FILE, queryMemoryAudit } from '../src/memory-audit.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `memory-audit-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+async function enableAudit(tempDir, audit) {
+    await mkdir(join(tempDir, '.automatosx'), { recursive: true });
+    await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({ memory: { audit } }), 'utf8');
+}
+describe('memory audit log', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('records who read, wrote, and deleted memory and filters by actor, action, and key', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await enableAudit(tempDir, true);
+        const runtime = createSharedRuntimeService({ basePath: tempDir, memoryActor: { kind: 'user', id: 'alice' } });
+        await runtime.storeMemory({ key: 'token-ttl', namespace: 'decisions', value: { hours: 12 }, agentId: 'architect' });
+        await runtime.getMemory('token-ttl', 'decisions');
+        const tool = runtime.withMemoryActor({ kind: 'tool', id: 'memory.search' });
+        await tool.searchMemory('token', 'decisions');
+        await runtime.deleteMemory('token-ttl', 'decisions');
+        const all = await runtime.queryMemoryAudit();
+        expect(all.enabled).toBe(true);
+        expect(all.matched).toBe(4);
+        expect(all.records.map((record) => [`${record.actor.kind}:${record.actor.id}`, record.action, record.operation])).toEqual([
+            ['user:alice', 'delete', 'memory.delete'],
+            ['tool:memory.search', 'read', 'memory.search'],
+            ['user:alice', 'read', 'memory.get'],
+            ['agent:architect', 'write', 'memory.store'],
+        ]);
+        expect(all.records[1]).toMatchObject({ query: 'token', keys: ['decisions/token-ttl'], count: 1 });
+        expect(all.actors.map((actor) => [actor.actor, actor.reads, actor.writes, actor.deletes])).toEqual([
+            ['user:alice', 1, 0, 1],
+            ['tool:memory.search', 1, 0, 0],
+            ['agent:architect', 0, 1, 0],
+        ]);
+        expect((await runtime.queryMemoryAudit({ actor: 'tool:memory.search' })).matched).toBe(1);
+        expect((await runtime.queryMemoryAudit({ actor: 'alice', action: 'read' })).records.map((record) => record.operation)).toEqual(['memory.get']);
+        expect((await runtime.queryMemoryAudit({ key: 'token-ttl' })).matched).toBe(4);
+        expect((await runtime.queryMemoryAudit({ actorKind: 'agent', limit: 0 })).records).toEqual([]);
+        expect((await runtime.queryMemoryAudit({ since: '2999-01-01T00:00:00.000Z' })).matched).toBe(0);
+    });
+    it('logs nothing while memory.audit is off', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        await runtime.storeMemory({ key: 'note', value: 'hello' });
+        await runtime.getMemory('note');
+        expect(await runtime.queryMemoryAudit()).toEqual({ enabled: false, records: [], matched: 0, actors: [] });
+        await expect(readFile(join(tempDir, MEMORY_AUDIT_FILE), 'utf8')).rejects.toThrow();
+    });
+    it('rolls the log over past maxFileBytes and still reads both files', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const config = { enabled: true, maxFileBytes: 64 };
+        const actor = { kind: 'agent', id: 'writer' };
+        await appendMemoryAudit(tempDir, config, { action: 'write', operation: 'memory.store', actor, key: 'a', count: 1 }, new Date('2026-05-01T00:00:00.000Z'));
+        await appendMemoryAudit(tempDir, config, { action: 'write', operation: 'memory.store', actor, key: 'b', count: 1 }, new Date('2026-05-02T00:00:00.000Z'));
+        expect(await readFile(join(tempDir, MEMORY_AUDIT_PREVIOUS_FILE), 'utf8')).toContain('"key":"a"');
+        const audit = await queryMemoryAudit(tempDir, true, { until: '2026-05-03T00:00:00.000Z' });
+        expect(audit.records.map((record) => record.key)).toEqual(['b', 'a']);
+        expect(audit.actors).toEqual([{ actor: 'agent:writer', reads: 0, writes: 2, deletes: 0, lastAt: '2026-05-02T00:00:00.000Z' }]);
+    });
+});
@@ -0,0 +1,113 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { MEMORY_BUNDLE_SCHEMA_VERSION } from '../src/memory-bundle.js';
+import { readMemoryEncryptionKey, resolveMemoryEncryptionConfig } from '../src/memory-encryption.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `memory-bundle-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('memory bundles', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('exports memory to versioned JSONL and imports it on another workspace', async () => {
+    const source = createTempDir();
+    const target = createTempDir();
+    tempDirs.push(source, target);
+    const exporter = createSharedRuntimeService({ basePath: source });
+    await exporter.storeMemory({ key: 'release', namespace: 'decisions', value: { owner: 'ops', weekly: true } });
+    await exporter.storeMemory({ key: 'scratch', namespace: 'tmp', value: 'ignore me' });
+    await exporter.storeSemantic({ key: 'retry', namespace: 'decisions', content: 'retry failed deploys with backoff', tags: ['deploy'], metadata: { adr: 12 } });
+
+    const bundlePath = join(source, 'decisions.jsonl');
+    const exported = await exporter.exportMemory({ outputPath: bundlePath, namespace: 'decisions' });
+    expect(exported.counts).toEqual({ memory: 1, semantic: 1 });
+    const lines = (await readFile(bundlePath, 'utf8')).trim().split('\n').map((line) => JSON.parse(line) as Record<string, unknown>);
+    expect(lines.map((line) => line.type)).toEqual(['header', 'memory', 'semantic']);
+    expect(lines[0]).toMatchObject({ format: 'automatosx-memory', schemaVersion: MEMORY_BUNDLE_SCHEMA_VERSION, namespace: 'decisions' });
+    expect(lines[2]).toMatchObject({ key: 'retry', tags: ['deploy'], metadata: { adr: 12 }, embedding: { retry: 1, backoff: 1 } });
+
+    const importer = createSharedRuntimeService({ basePath: target });
+    const preview = await importer.importMemory({ inputPath: bundlePath, dryRun: true });
+    expect(preview).toMatchObject({ dryRun: true, imported: { memory: 1, semantic: 1 } });
+    expect(await importer.listMemory('decisions')).toEqual([]);
+
+    const imported = await importer.importMemory({ inputPath: bundlePath });
+    expect(imported.imported).toEqual({ memory: 1, semantic: 1 });
+    expect((await importer.getMemory('release', 'decisions'))?.value).toEqual({ owner: 'ops', weekly: true });
+    expect((await importer.searchSemantic('backoff', { namespace: 'decisions', mode: 'keyword' }))[0]?.key).toBe('retry');
+
+    const again = await importer.importMemory({ inputPath: bundlePath });
+    expect(again).toMatchObject({ imported: { memory: 0, semantic: 0 }, skipped: { memory: 1, semantic: 1 } });
+    expect((await importer.importMemory({ inputPath: bundlePath, overwrite: true })).imported).toEqual({ memory: 1, semantic: 1 });
+  });
+
+  it('rejects bundles it cannot read', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const write = async (lines: unknown[]) => {
+      const path = join(tempDir, 'bundle.jsonl');
+      await writeFile(path, lines.map((line) => JSON.stringify(line)).join('\n'), 'utf8');
+      return path;
+    };
+
+    await expect(runtime.importMemory({ inputPath: await write([{ key: 'a' }]) })).rejects.toThrow('Not a memory bundle');
+    const header = { type: 'header', format: 'automatosx-memory', schemaVersion: 1, exportedAt: '2026-01-01T00:00:00.000Z', machine: 'm' };
+    await expect(runtime.importMemory({ inputPath: await write([{ ...header, schemaVersion: 99 }]) })).rejects.toThrow('schema 99 is newer');
+    await expect(runtime.importMemory({ inputPath: await write([header, { type: 'memory', key: 'a' }]) })).rejects.toThrow('line 2 is not a memory or semantic record');
+  });
+
+  it('encrypts memory and bundles of workspaces with memory.encryption', async () => {
+    const source = createTempDir();
+    const target = createTempDir();
+    tempDirs.push(source, target);
+    const config = JSON.stringify({ memory: { encryption: { keyEnv: 'AX_TEST_MEMORY_KEY', keychain: false } } });
+    for (const basePath of [source, target]) {
+      await mkdir(join(basePath, '.automatosx'), { recursive: true });
+      await writeFile(join(basePath, '.automatosx', 'config.json'), config, 'utf8');
+    }
+    expect(() => createSharedRuntimeService({ basePath: source })).toThrow('AX_TEST_MEMORY_KEY is not set');
+
+    process.env.AX_TEST_MEMORY_KEY = 'bundle secret';
+    try {
+      const exporter = createSharedRuntimeService({ basePath: source });
+      await exporter.storeMemory({ key: 'pricing', value: 'proprietary margin table' });
+      const exported = await exporter.exportMemory();
+      expect(exported).toMatchObject({ encrypted: true, counts: { memory: 1, semantic: 0 } });
+      expect(exported.outputPath).toMatch(/\.jsonl\.enc$/);
+      expect(await readFile(exported.outputPath, 'utf8')).not.toContain('proprietary');
+
+      const unencrypted = createTempDir();
+      tempDirs.push(unencrypted);
+      await expect(createSharedRuntimeService({ basePath: unencrypted }).importMemory({ inputPath: exported.outputPath })).rejects.toThrow('bundle is encrypted');
+      const importer = createSharedRuntimeService({ basePath: target });
+      expect((await importer.importMemory({ inputPath: exported.outputPath })).imported).toEqual({ memory: 1, semantic: 0 });
+      expect((await importer.getMemory('pricing'))?.value).toBe('proprietary margin table');
+
+      process.env.AX_TEST_MEMORY_KEY = 'another secret';
+      await expect(createSharedRuntimeService({ basePath: target }).importMemory({ inputPath: exported.outputPath })).rejects.toThrow('wrong encryption key');
+    } finally {
+      delete process.env.AX_TEST_MEMORY_KEY;
+    }
+  });
+
+  it('reads the memory key from the environment, then the keychain', () => {
+    const config = resolveMemoryEncryptionConfig(true)!;
+    expect(config).toEqual({ keyEnv: 'AX_MEMORY_KEY', keychain: { service: 'automatosx', account: 'memory' } });
+    expect(resolveMemoryEncryptionConfig({ enabled: false })).toBeUndefined();
+    const keychain = (service: string, account: string) => (service === 'automatosx' && account === 'memory' ? 'from-keychain' : undefined);
+    expect(readMemoryEncryptionKey(config, { AX_MEMORY_KEY: 'from-env' }, keychain)).toBe('from-env');
+    expect(readMemoryEncryptionKey(config, {}, keychain)).toBe('from-keychain');
+    expect(() => readMemoryEncryptionKey(config, {}, () => undefined)).toThrow('OS keychain (service "automatosx", account "memory")');
+  });
+});
@@ -0,0 +1,83 @@
+import { mkdirSync, readFileSync, writeFileSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { compactMemoryIfDue, resolveMemoryCompactionConfig } from '../src/memory-compaction.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `memory-compaction-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(join(dir, '.automatosx'), { recursive: true });
+  return dir;
+}
+
+describe('memory compaction', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('summarizes clusters of related entries and archives the originals', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    await runtime.storeSemantic({ key: 'ttl-1', namespace: 'notes', content: 'Session tokens expire after 15 minutes. Clients refresh them.', tags: ['auth'] });
+    await runtime.storeSemantic({ key: 'ttl-2', namespace: 'notes', content: 'Session tokens expire after 15 minutes of inactivity.', tags: ['security'] });
+    await runtime.storeSemantic({ key: 'ttl-3', namespace: 'notes', content: 'Session tokens expire after 15 minutes and refresh silently.' });
+    await runtime.storeSemantic({ key: 'billing', namespace: 'notes', content: 'The billing service retries failed invoices twice.' });
+    await runtime.storeSemanticFile({ path: 'src/session.ts', content: 'export function sessionTokensExpire(): number {\n  return 15;\n}\n', namespace: 'notes' });
+
+    expect((await runtime.compactMemory({ minAgeDays: 1 })).clusters).toEqual([]);
+
+    const preview = await runtime.compactMemory({ minAgeDays: 0, dryRun: true });
+    expect(preview.clusters).toHaveLength(1);
+    expect(preview.clusters[0]?.members.sort()).toEqual(['ttl-1', 'ttl-2', 'ttl-3']);
+    expect(preview.compacted).toBe(0);
+    expect(await runtime.getSemantic('ttl-1', 'notes')).toBeDefined();
+
+    const result = await runtime.compactMemory({ minAgeDays: 0 });
+    expect(result.compacted).toBe(3);
+    const summary = await runtime.getSemantic(result.clusters[0]?.key ?? '', 'notes');
+    expect(summary?.content.split('\n')[0]).toContain('Summary of 3 related entries');
+    expect(summary?.content).toContain('- Session tokens expire after 15 minutes of inactivity.');
+    expect(summary?.tags).toEqual(['auth', 'security']);
+    expect((summary?.metadata?.compactedFrom as Array<{ key: string }>).map((entry) => entry.key).sort()).toEqual(['ttl-1', 'ttl-2', 'ttl-3']);
+    expect(await runtime.getSemantic('ttl-2', 'notes')).toBeUndefined();
+    expect(await runtime.getSemantic('billing', 'notes')).toBeDefined();
+    expect(await runtime.getSemantic('src/session.ts#sessionTokensExpire', 'notes')).toBeDefined();
+
+    const archived = readFileSync(result.archivePath ?? '', 'utf8').trim().split('\n').map((line) => JSON.parse(line) as Record<string, unknown>);
+    expect(archived.map((entry) => entry.key).sort()).toEqual(['ttl-1', 'ttl-2', 'ttl-3']);
+    expect(archived[0]?.compactedInto).toBe(result.clusters[0]?.key);
+
+    // Summaries are not compacted again.
+    expect((await runtime.compactMemory({ minAgeDays: 0, minClusterSize: 2 })).clusters).toEqual([]);
+    await expect(runtime.compactMemory({ minClusterSize: 1 })).rejects.toThrow('at least 2');
+  });
+
+  it('runs in the background only when configured and due', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const state = {
+      listSemantic: (options?: { namespace?: string }) => runtime.listSemantic(options),
+      storeSemantic: (entry: Parameters<typeof runtime.storeSemantic>[0]) => runtime.storeSemantic(entry),
+      deleteSemantic: (key: string, namespace?: string) => runtime.deleteSemantic(key, namespace),
+    };
+
+    expect(resolveMemoryCompactionConfig({}).enabled).toBe(false);
+    expect(await compactMemoryIfDue({ basePath: tempDir, state, config: resolveMemoryCompactionConfig({}) })).toBeUndefined();
+
+    const config = resolveMemoryCompactionConfig({ compaction: { minAgeDays: 0, minClusterSize: 2, intervalMinutes: 60 } });
+    expect(config).toMatchObject({ enabled: true, minAgeDays: 0, minClusterSize: 2, similarity: 0.5, intervalMs: 3_600_000 });
+    const now = new Date('2026-10-16T12:00:00.000Z');
+    expect(await compactMemoryIfDue({ basePath: tempDir, state, config, now })).toMatchObject({ compacted: 0 });
+    expect(await compactMemoryIfDue({ basePath: tempDir, state, config, now: new Date('2026-10-16T12:30:00.000Z') })).toBeUndefined();
+    expect(await compactMemoryIfDue({ basePath: tempDir, state, config, now: new Date('2026-10-16T13:00:00.000Z') })).toBeDefined();
+
+    writeFileSync(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({ memory: { compaction: { minAgeDays: 0, minClusterSize: 2 } } }));
+    expect((await runtime.compactMemory({ dryRun: true })).minClusterSize).toBe(2);
+  });
+});
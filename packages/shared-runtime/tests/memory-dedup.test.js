@@ -0,0 +1,75 @@
+import { mkdirSync } from 'node:fs';
+import { readFile, rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { cosineSimilarity, MEMORY_DEDUP_LOG_FILE } from '../src/memory-dedup.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `memory-dedup-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const tick = () => new Promise((resolve) => setTimeout(resolve, 5));
+describe('memory dedup', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('merges identical and near-identical entries into the newest, keeping provenance', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        await runtime.storeMemory({ key: 'owner-a', namespace: 'decisions', value: { owner: 'ops', weekly: true } });
+        await runtime.storeMemory({ key: 'owner-other', namespace: 'other', value: { owner: 'ops', weekly: true } });
+        await tick();
+        await runtime.storeMemory({ key: 'owner-b', namespace: 'decisions', value: { weekly: true, owner: 'ops' } });
+        await runtime.storeMemory({ key: 'owner-c', namespace: 'decisions', value: { owner: 'dev', weekly: true } });
+        await runtime.storeSemantic({ key: 'retry-1', namespace: 'decisions', content: 'Retry failed deploys with exponential backoff and jitter before paging', tags: ['deploy'], metadata: { adr: 12 } });
+        await tick();
+        await runtime.storeSemantic({ key: 'retry-2', namespace: 'decisions', content: '  retry failed deploys WITH exponential backoff and jitter before paging ', tags: ['ops'] });
+        await tick();
+        await runtime.storeSemantic({ key: 'retry-3', namespace: 'decisions', content: 'Retry failed deploys with exponential backoff and jitter before paging oncall', tags: ['oncall'], ttlMs: 60_000 });
+        await runtime.storeSemantic({ key: 'cache', namespace: 'decisions', content: 'Cache build artifacts per commit' });
+        const preview = await runtime.dedupeMemory({ dryRun: true, threshold: 0.9 });
+        expect(preview.dryRun).toBe(true);
+        expect(preview.logPath).toBeUndefined();
+        expect(preview.groups).toEqual([
+            { type: 'memory', namespace: 'decisions', kept: 'owner-b', duplicates: [expect.objectContaining({ key: 'owner-a', reason: 'hash', similarity: 1 })] },
+            {
+                type: 'semantic',
+                namespace: 'decisions',
+                kept: 'retry-3',
+                duplicates: [
+                    expect.objectContaining({ key: 'retry-2', reason: 'similarity' }),
+                    expect.objectContaining({ key: 'retry-1', reason: 'similarity' }),
+                ],
+            },
+        ]);
+        expect(await runtime.listSemantic({ namespace: 'decisions' })).toHaveLength(4);
+        const result = await runtime.dedupeMemory({ threshold: 0.9 });
+        expect(result.removed).toEqual({ memory: 1, semantic: 2 });
+        expect((await runtime.listMemory('decisions')).map((entry) => entry.key).sort()).toEqual(['owner-b', 'owner-c']);
+        expect(await runtime.getMemory('owner-other', 'other')).toBeDefined();
+        const kept = await runtime.getSemantic('retry-3', 'decisions');
+        expect(kept?.tags.sort()).toEqual(['deploy', 'oncall', 'ops']);
+        expect(kept?.expiresAt).toBeUndefined();
+        expect(kept?.metadata).toMatchObject({ adr: 12, mergedFrom: [{ key: 'retry-2', reason: 'similarity' }, { key: 'retry-1', reason: 'similarity' }] });
+        expect((await runtime.listSemantic({ namespace: 'decisions' })).map((entry) => entry.key).sort()).toEqual(['cache', 'retry-3']);
+        const log = (await readFile(join(tempDir, MEMORY_DEDUP_LOG_FILE), 'utf8')).trim().split('\n').map((line) => JSON.parse(line));
+        expect(log.map((line) => [line.type, line.key, line.mergedInto])).toEqual([
+            ['memory', 'owner-a', 'owner-b'],
+            ['semantic', 'retry-2', 'retry-3'],
+            ['semantic', 'retry-1', 'retry-3'],
+        ]);
+        expect(log[2]).toMatchObject({ content: 'Retry failed deploys with exponential backoff and jitter before paging', metadata: { adr: 12 } });
+        expect((await runtime.dedupeMemory()).groups).toEqual([]);
+    });
+    it('compares term embeddings by cosine similarity', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        expect(cosineSimilarity({ retry: 1, deploy: 1 }, { retry: 1, deploy: 1 })).toBe(1);
+        expect(cosineSimilarity({ retry: 1 }, { cache: 1 })).toBe(0);
+        expect(cosineSimilarity({}, { cache: 1 })).toBe(0);
+        await expect(createSharedRuntimeService({ basePath: tempDir }).dedupeMemory({ threshold: 1.5 })).rejects.toThrow('at most 1');
+    });
+});
@@ -0,0 +1,66 @@
+import { mkdirSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { normalizeMemoryFilter } from '../src/memory-facets.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `memory-facets-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(join(dir, '.automatosx'), { recursive: true });
+    return dir;
+}
+describe('memory facets', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('normalizes filters and rejects unparseable dates', () => {
+        expect(normalizeMemoryFilter({ tags: [' Auth', 'auth', ''], path: './src/auth/', since: '2026-10-01' })).toEqual({
+            tags: ['auth'],
+            since: '2026-10-01T00:00:00.000Z',
+            path: 'src/auth',
+        });
+        expect(normalizeMemoryFilter({ agentId: '', until: '' })).toEqual({});
+        expect(() => normalizeMemoryFilter({ until: 'last tuesday' })).toThrow('Invalid until date');
+    });
+    it('filters memory search by tags, agent, and path and counts facets', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        await runtime.storeMemory({ key: 'token-ttl', namespace: 'decisions', value: { path: 'src/auth/token.ts', ttl: '15m' }, tags: ['Auth', 'security'], agentId: 'architect' });
+        await runtime.storeMemory({ key: 'retry-policy', namespace: 'decisions', value: 'retry twice', tags: ['reliability'], agentId: 'backend' });
+        await runtime.storeSemantic({
+            key: 'login-flow',
+            namespace: 'code',
+            content: 'Login validates the session token before refreshing it',
+            tags: ['auth'],
+            metadata: { path: 'src/auth/login.ts' },
+            agentId: 'architect',
+        });
+        expect((await runtime.getMemory('token-ttl', 'decisions'))?.tags).toEqual(['auth', 'security']);
+        expect((await runtime.searchMemory('', 'decisions', { tags: ['auth'] })).map((entry) => entry.key)).toEqual(['token-ttl']);
+        expect((await runtime.searchMemory('retry', undefined, { agentId: 'architect' }))).toEqual([]);
+        expect((await runtime.searchMemory('', undefined, { path: 'src/auth' })).map((entry) => entry.key)).toEqual(['token-ttl']);
+        expect((await runtime.searchMemory('', undefined, { since: '2999-01-01' }))).toEqual([]);
+        const semantic = await runtime.searchSemantic('session token', { mode: 'keyword', path: 'src/auth', topK: 1 });
+        expect(semantic.map((entry) => entry.key)).toEqual(['login-flow']);
+        expect(await runtime.searchSemantic('session token', { mode: 'keyword', agentId: 'backend' })).toEqual([]);
+        const facets = await runtime.memoryFacets();
+        expect(facets.total).toBe(3);
+        expect(facets.tags).toEqual([
+            { value: 'auth', count: 2 },
+            { value: 'reliability', count: 1 },
+            { value: 'security', count: 1 },
+        ]);
+        expect(facets.agents).toEqual([{ value: 'architect', count: 2 }, { value: 'backend', count: 1 }]);
+        expect(facets.paths.map((facet) => facet.value)).toEqual(['src/auth/login.ts', 'src/auth/token.ts']);
+        const drilled = await runtime.memoryFacets({ tags: ['auth'], namespace: 'decisions' });
+        expect(drilled).toEqual({
+            total: 1,
+            namespaces: [{ value: 'decisions', count: 1 }],
+            tags: [{ value: 'auth', count: 1 }, { value: 'security', count: 1 }],
+            agents: [{ value: 'architect', count: 1 }],
+            paths: [{ value: 'src/auth/token.ts', count: 1 }],
+        });
+    });
+});
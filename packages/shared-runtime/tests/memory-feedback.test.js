@@ -0,0 +1,58 @@
+import { mkdirSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { feedbackBoost } from '../src/memory-feedback.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `memory-feedback-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(join(dir, '.automatosx'), { recursive: true });
+    return dir;
+}
+describe('memory feedback', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('weighs votes for similar queries above unrelated ones', () => {
+        const at = '2026-10-16T00:00:00.000Z';
+        expect(feedbackBoost([], 'anything')).toBe(1);
+        const related = feedbackBoost([{ helpful: true, query: 'session expiry', at }], 'session expiry');
+        const unrelated = feedbackBoost([{ helpful: true, query: 'retry policy', at }], 'session expiry');
+        expect(related).toBeGreaterThan(unrelated);
+        expect(unrelated).toBeGreaterThan(1);
+        expect(feedbackBoost([{ helpful: false, at }, { helpful: false, at }], '')).toBeLessThan(1);
+        const many = Array.from({ length: 50 }, () => ({ helpful: true, at }));
+        expect(feedbackBoost(many, '')).toBeLessThanOrEqual(1.5);
+    });
+    it('reranks memory and semantic search by feedback within a memory scope', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        await runtime.storeMemory({ key: 'session-a', namespace: 'decisions', value: 'session tokens expire after 15m' });
+        await runtime.storeMemory({ key: 'session-b', namespace: 'decisions', value: 'session cookies are http-only' });
+        const before = (await runtime.searchMemory('session', 'decisions')).map((entry) => entry.key);
+        expect(before).toHaveLength(2);
+        const first = before[0] ?? '';
+        const second = before[1] ?? '';
+        const result = await runtime.recordMemoryFeedback({ key: first, namespace: 'decisions', helpful: false, query: 'session' });
+        expect(result).toMatchObject({ kind: 'memory', namespace: 'decisions', key: first, helpful: 0, unhelpful: 1 });
+        expect(result.boost).toBeLessThan(1);
+        expect((await runtime.searchMemory('session', 'decisions')).map((entry) => entry.key)).toEqual([second, first]);
+        await runtime.storeSemantic({ key: 'login', namespace: 'code', content: 'login refreshes the session token' });
+        await runtime.storeSemantic({ key: 'logout', namespace: 'code', content: 'logout clears the session token and cookie' });
+        const semantic = await runtime.searchSemantic('session token', { namespace: 'code', mode: 'keyword' });
+        const last = semantic[semantic.length - 1];
+        await runtime.recordMemoryFeedback({ key: last?.key ?? '', namespace: 'code', kind: 'semantic', helpful: true, query: 'session token' });
+        await runtime.recordMemoryFeedback({ key: last?.key ?? '', namespace: 'code', kind: 'semantic', helpful: true, query: 'session token' });
+        const boosted = await runtime.searchSemantic('session token', { namespace: 'code', mode: 'keyword', topK: 1 });
+        expect(boosted.map((entry) => entry.key)).toEqual([last?.key]);
+        expect(boosted[0]?.score).toBeGreaterThan(last?.score ?? 0);
+        // Another project's scope ranks without this scope's votes.
+        const other = runtime.withMemoryScope('other-team');
+        await other.storeMemory({ key: 'session-a', namespace: 'decisions', value: 'session tokens expire after 15m' });
+        await other.storeMemory({ key: 'session-b', namespace: 'decisions', value: 'session cookies are http-only' });
+        expect((await other.searchMemory('session', 'decisions')).map((entry) => entry.key)).toEqual(before);
+        await expect(runtime.recordMemoryFeedback({ key: 'missing', namespace: 'decisions', helpful: true })).rejects.toThrow('Memory entry not found: decisions/missing');
+    });
+});
@@ -0,0 +1,70 @@
+import { mkdirSync } from 'node:fs';
+import { rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { resolveMemorySnapshotConfig } from '../src/memory-snapshots.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `memory-snapshots-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(join(dir, '.automatosx'), { recursive: true });
+  return dir;
+}
+
+const tick = () => new Promise((resolve) => setTimeout(resolve, 5));
+
+describe('memory snapshots', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('resolves the snapshot schedule and keep count from config', () => {
+    expect(resolveMemorySnapshotConfig(undefined)).toEqual({ keep: 7 });
+    expect(resolveMemorySnapshotConfig({ snapshots: { schedule: 'weekly', keep: 3 } })).toEqual({ schedule: 'weekly', keep: 3 });
+    expect(resolveMemorySnapshotConfig({ snapshots: { schedule: 'hourly', keep: 0 } })).toEqual({ keep: 7 });
+  });
+
+  it('snapshots on schedule, keeps the newest, and restores with a safety snapshot', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({ memory: { snapshots: { schedule: 'daily', keep: 2 } } }));
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    await runtime.storeMemory({ key: 'deploy-window', namespace: 'ops', value: { day: 'tuesday' } });
+    await tick();
+    await runtime.storeSemantic({ key: 'rollback', namespace: 'ops', content: 'Roll back by redeploying the previous tag' });
+    const scheduled = await runtime.listMemorySnapshots();
+    expect(scheduled).toHaveLength(1);
+
+    await tick();
+    const manual = await runtime.snapshotMemory();
+    expect(manual.counts).toEqual({ memory: 1, semantic: 1 });
+    expect(manual.removed).toEqual([]);
+
+    await runtime.storeMemory({ key: 'deploy-window', namespace: 'ops', value: { day: 'friday' } });
+    await runtime.storeMemory({ key: 'scratch', value: 'temporary' });
+    const preview = await runtime.restoreMemory({ snapshotId: 'latest', dryRun: true });
+    expect(preview).toMatchObject({ snapshotId: manual.snapshotId, restored: { memory: 1, semantic: 1 }, removed: { memory: 1, semantic: 0 }, dryRun: true });
+    expect(await runtime.getMemory('scratch')).toBeDefined();
+
+    await tick();
+    const restored = await runtime.restoreMemory({ snapshotId: manual.snapshotId });
+    expect(restored.dryRun).toBe(false);
+    expect(restored.safetySnapshotId).toBeDefined();
+    expect(await runtime.getMemory('scratch')).toBeUndefined();
+    expect((await runtime.getMemory('deploy-window', 'ops'))?.value).toEqual({ day: 'tuesday' });
+    expect((await runtime.listMemorySnapshots()).map((snapshot) => snapshot.snapshotId)).toEqual([
+      restored.safetySnapshotId,
+      manual.snapshotId,
+      scheduled[0]!.snapshotId,
+    ]);
+
+    await tick();
+    const pruned = await runtime.snapshotMemory();
+    expect(pruned.removed).toEqual([manual.snapshotId, scheduled[0]!.snapshotId]);
+    expect(await runtime.listMemorySnapshots()).toHaveLength(2);
+    await expect(runtime.restoreMemory({ snapshotId: 'memory-19700101T000000000Z' })).rejects.toThrow('Memory snapshot not found');
+  });
+});
@@ -0,0 +1,94 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { checkMinimalDiff, measureHunk, resolveMinimalDiffPolicy } from '../src/minimal-diff.js';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `minimal-diff-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const ORIGINAL = [
+  "import { join } from 'node:path'",
+  '',
+  'export function paths(dir) {',
+  "  const a = join(dir, 'a')",
+  "  const b = join(dir, 'b')",
+  "  const c = join(dir, 'c')",
+  '  return [a, b, c]',
+  '}',
+  '',
+].join('\n');
+
+const REFORMATTING_PATCH = [
+  '--- a/src/paths.js',
+  '+++ b/src/paths.js',
+  '@@ -1,8 +1,8 @@',
+  "-import { join } from 'node:path'",
+  '+import { join } from "node:path";',
+  ' ',
+  ' export function paths(dir) {',
+  "-  const a = join(dir, 'a')",
+  "-  const b = join(dir, 'b')",
+  "-  const c = join(dir, 'c')",
+  '-  return [a, b, c]',
+  '+    const a = join(dir, "a");',
+  '+    const b = join(dir, "b");',
+  '+    const c = join(dir, "d");',
+  '+    return [a, b, c];',
+  ' }',
+  '',
+].join('\n');
+
+describe('minimal-diff enforcement', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('separates cosmetic rewrites from behavioral changes', () => {
+    expect(measureHunk(REFORMATTING_PATCH.split('\n').slice(3))).toEqual({ changedLines: 5, cosmeticLines: 4 });
+    expect(measureHunk(['-  return a', '+  return b', ' }', '-  x()', '+  x()'])).toEqual({ changedLines: 2, cosmeticLines: 0 });
+
+    expect(resolveMinimalDiffPolicy({})).toBeUndefined();
+    expect(resolveMinimalDiffPolicy({ minimalDiff: true })).toEqual({ maxCosmeticLines: 2, maxRewriteRatio: 0.5, minFileLines: 20 });
+    const policy = resolveMinimalDiffPolicy({ minimalDiff: { maxRewriteRatio: 0.25, minFileLines: 4, maxCosmeticLines: 'many' } });
+    expect(policy).toEqual({ maxCosmeticLines: 2, maxRewriteRatio: 0.25, minFileLines: 4 });
+
+    const violations = checkMinimalDiff([
+      { index: 0, lines: ['-  return a', '+  return b'] },
+      { index: 1, lines: ['-  x()', '+  y()'] },
+    ], 6, policy!);
+    expect(violations.map((violation) => violation.hunkIndex)).toEqual([0, 1]);
+    expect(violations[0]?.reason).toContain('Rewrites 2 of 6 lines (33%), over the 25% minimal-diff limit.');
+  });
+
+  it('bounces reformatting hunks back to the agent when enabled', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'src'), { recursive: true });
+    await writeFile(join(tempDir, 'src', 'paths.js'), ORIGINAL, 'utf8');
+    const decisions = [{ path: 'src/paths.js', hunkIndex: 0, decision: 'accept' as const }];
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const result = await runtime.reviewPatch({ patch: REFORMATTING_PATCH, decisions, agentId: 'refactorer', minimalDiff: true });
+    expect(result.applied).toEqual([]);
+    expect(result.rejected).toEqual([{
+      path: 'src/paths.js',
+      hunkIndex: 0,
+      bounced: true,
+      reason: expect.stringContaining('Reformats 4 of 5 changed lines without changing behavior. Produce a targeted change'),
+    }]);
+    expect(result.feedbackIds).toHaveLength(1);
+    expect(await readFile(join(tempDir, 'src', 'paths.js'), 'utf8')).toBe(ORIGINAL);
+
+    // Off by default: the same patch applies when neither config nor the request asks for the check.
+    const applied = await runtime.reviewPatch({ patch: REFORMATTING_PATCH, decisions, preserveStyle: false });
+    expect(applied.applied).toEqual([{ path: 'src/paths.js', hunks: [0] }]);
+    expect(await readFile(join(tempDir, 'src', 'paths.js'), 'utf8')).toContain('const c = join(dir, "d");');
+  });
+});
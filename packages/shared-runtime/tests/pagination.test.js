@@ -0,0 +1,59 @@
+import { mkdirSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { paginate } from '../src/pagination.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `pagination-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(join(dir, '.automatosx'), { recursive: true });
+    return dir;
+}
+describe('pagination', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('pages through results and refuses cursors from another query', () => {
+        const items = ['a', 'b', 'c', 'd', 'e'];
+        const first = paginate(items, { pageSize: 2 }, { query: 'x' });
+        expect(first).toMatchObject({ items: ['a', 'b'], total: 5, offset: 0 });
+        const second = paginate(items, { pageSize: 2, cursor: first.nextCursor }, { query: 'x' });
+        expect(second).toMatchObject({ items: ['c', 'd'], offset: 2 });
+        const last = paginate(items, { pageSize: 2, cursor: second.nextCursor }, { query: 'x' });
+        expect(last.items).toEqual(['e']);
+        expect(last.nextCursor).toBeUndefined();
+        expect(() => paginate(items, { cursor: first.nextCursor }, { query: 'y' })).toThrow('different query');
+        expect(() => paginate(items, { cursor: 'not-a-cursor' }, { query: 'x' })).toThrow('Invalid cursor');
+        expect(() => paginate(items, { pageSize: 0 }, { query: 'x' })).toThrow('pageSize must be an integer');
+    });
+    it('refuses a cursor once the pages it follows have changed', () => {
+        const first = paginate(['a', 'b', 'c', 'd'], { pageSize: 2 }, { query: 'x' });
+        // An entry inserted before the cursor would shift 'b' onto the next page again.
+        expect(() => paginate(['a', 'new', 'b', 'c', 'd'], { pageSize: 2, cursor: first.nextCursor }, { query: 'x' })).toThrow('results changed');
+        expect(() => paginate(['b', 'c', 'd'], { pageSize: 2, cursor: first.nextCursor }, { query: 'x' })).toThrow('results changed');
+        // Changes after the cursor don't affect what was already served.
+        expect(paginate(['a', 'b', 'c', 'e'], { pageSize: 2, cursor: first.nextCursor }, { query: 'x' }).items).toEqual(['c', 'e']);
+    });
+    it('iterates every memory search match once', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        for (let index = 0; index < 7; index += 1) {
+            await runtime.storeMemory({ key: `session-${index}`, namespace: 'decisions', value: `session expiry note ${index}` });
+        }
+        const keys = [];
+        let cursor;
+        do {
+            const page = await runtime.searchMemoryPage('session', 'decisions', undefined, { pageSize: 3, cursor });
+            expect(page.total).toBe(7);
+            keys.push(...page.entries.map((entry) => entry.key));
+            cursor = page.nextCursor;
+        } while (cursor !== undefined);
+        expect(keys).toHaveLength(7);
+        expect(new Set(keys).size).toBe(7);
+        const first = await runtime.searchMemoryPage('session', 'decisions', undefined, { pageSize: 3 });
+        await runtime.deleteMemory(first.entries[0].key, 'decisions');
+        await expect(runtime.searchMemoryPage('session', 'decisions', undefined, { pageSize: 3, cursor: first.nextCursor })).rejects.toThrow('results changed');
+    });
+});
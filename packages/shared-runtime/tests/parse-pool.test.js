@@ -0,0 +1,49 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService, INDEX_PROGRESS_FILE, resolveParserPoolConfig } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `parse-pool-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+async function writeWorkspace(tempDir, parser) {
+    await mkdir(join(tempDir, '.automatosx'), { recursive: true });
+    await mkdir(join(tempDir, 'pkg'), { recursive: true });
+    await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({ parser }), 'utf8');
+    await writeFile(join(tempDir, 'pkg', 'shared.go'), 'package pkg\n\nfunc Shared() int { return 1 }\n', 'utf8');
+    for (let index = 0; index < 40; index += 1) {
+        await writeFile(join(tempDir, 'pkg', `file${index}.go`), `package pkg\n\nfunc Use${index}() int {\n\treturn Shared() + ${index}\n}\n`, 'utf8');
+    }
+}
+describe('parser worker pool', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('reads worker and in-flight limits from config', () => {
+        expect(resolveParserPoolConfig({ workers: 2, maxInFlightBytes: 4096 })).toEqual({ workers: 2, maxInFlightBytes: 4096 });
+        expect(resolveParserPoolConfig({ workers: 0 }).workers).toBe(0);
+        const defaults = resolveParserPoolConfig({ workers: -1, maxInFlightBytes: 'lots' });
+        expect(defaults.workers).toBeLessThanOrEqual(4);
+        expect(defaults.maxInFlightBytes).toBe(16 * 1024 * 1024);
+    });
+    it('indexes the same references on worker threads as in process, and reports progress', async () => {
+        const pooledDir = createTempDir();
+        const inlineDir = createTempDir();
+        tempDirs.push(pooledDir, inlineDir);
+        // A budget below one file's size still lets one file through at a time.
+        await writeWorkspace(pooledDir, { workers: 2, maxInFlightBytes: 1 });
+        await writeWorkspace(inlineDir, { workers: 0 });
+        const pooled = await createSharedRuntimeService({ basePath: pooledDir }).findReferences({ symbol: 'Shared' });
+        const inline = await createSharedRuntimeService({ basePath: inlineDir }).findReferences({ symbol: 'Shared' });
+        expect(pooled.scannedFiles).toBe(41);
+        expect(pooled.references).toHaveLength(41);
+        expect(pooled.references).toEqual(inline.references);
+        const progress = JSON.parse(await readFile(join(pooledDir, INDEX_PROGRESS_FILE), 'utf8'));
+        expect(progress).toMatchObject({ total: 41, parsed: 41, failed: 0, workers: 2 });
+        expect(typeof progress.finishedAt).toBe('string');
+        expect(await createSharedRuntimeService({ basePath: pooledDir }).getIndexProgress()).toEqual(progress);
+    });
+});
@@ -0,0 +1,93 @@
+import { mkdirSync } from 'node:fs';
+import { readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { checkParserFixtures, sanitizeFixtureSource } from '../src/parser-fixtures.js';
+const FIXTURE_DIR = join(process.cwd(), 'packages/shared-runtime/tests/fixtures/parser');
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `parser-fixtures-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const CLIENT_GO = [
+    'package acme',
+    '',
+    '// #include <stdlib.h>',
+    'import "C"',
+    '',
+    'import (',
+    '\t"github.com/acme/internal/secret"',
+    ')',
+    '',
+    '/* Copyright Acme Corp.',
+    '   All rights reserved. */',
+    '',
+    '//go:embed banner.txt',
+    'var banner string',
+    '',
+    '// AcmeClient talks to "the backend".',
+    'type AcmeClient struct {',
+    '\ttoken string `json:"token"`',
+    '}',
+    '',
+    'const apiKey = "sk-live-12345"',
+    '',
+    'func (c *AcmeClient) Call() error {',
+    '\treturn secret.Do(c.token, `multi',
+    'line`)',
+    '}',
+    '',
+].join('\n');
+describe('parser fixtures', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('parses every checked-in fixture the way its snapshot expects', async () => {
+        const report = await checkParserFixtures({ basePath: process.cwd(), dir: FIXTURE_DIR });
+        expect(report.fixtures.length).toBeGreaterThan(0);
+        expect(report.fixtures.filter((fixture) => !fixture.passed)).toEqual([]);
+    });
+    it('blanks comments and strings, redacts words, and keeps lines and directives', () => {
+        const { content, stats } = sanitizeFixtureSource(CLIENT_GO, 'client.go', ['acme']);
+        expect(content.split('\n')).toHaveLength(CLIENT_GO.split('\n').length);
+        expect(content).toContain('// #include <stdlib.h>');
+        expect(content).toContain('"github.com/redacted/internal/secret"');
+        expect(content).toContain('//go:embed banner.txt');
+        expect(content).toContain('type RedactedClient struct {');
+        expect(content).toContain('const apiKey = "xxxxxxxxxxxxx"');
+        expect(content).toContain('`xxxxx\nxxxx`');
+        expect(content).not.toMatch(/Copyright|backend|sk-live|acme/i);
+        expect(stats).toEqual({ comments: 2, strings: 3, redactions: 4 });
+    });
+    it('writes a fixture whose snapshot fails once the parser disagrees', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writeFile(join(tempDir, 'client.go'), CLIENT_GO, 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const fixture = await runtime.createParserFixture({ path: 'client.go', redact: ['acme'] });
+        expect(fixture).toMatchObject({
+            name: 'client',
+            sourcePath: join('.automatosx', 'parser-fixtures', 'client.go'),
+            snapshotPath: join('.automatosx', 'parser-fixtures', 'client.go.symbols.json'),
+        });
+        expect(fixture.symbols.map((symbol) => [symbol.kind, symbol.receiver, symbol.name, symbol.line])).toEqual([
+            ['variable', undefined, 'banner', 14],
+            ['type', undefined, 'RedactedClient', 17],
+            ['variable', undefined, 'apiKey', 21],
+            ['method', 'RedactedClient', 'Call', 23],
+        ]);
+        expect(fixture.symbols[0].embeds).toEqual(['banner.txt']);
+        expect(await runtime.checkParserFixtures()).toMatchObject({ passed: 1, failed: 0 });
+        const snapshotPath = join(tempDir, fixture.snapshotPath);
+        const snapshot = JSON.parse(await readFile(snapshotPath, 'utf8'));
+        snapshot.symbols[1].name = 'Client';
+        await writeFile(snapshotPath, JSON.stringify(snapshot), 'utf8');
+        const report = await runtime.checkParserFixtures();
+        expect(report).toMatchObject({ passed: 0, failed: 1 });
+        expect(report.fixtures[0].missing.map((symbol) => symbol.name)).toEqual(['Client']);
+        expect(report.fixtures[0].unexpected.map((symbol) => symbol.name)).toEqual(['RedactedClient']);
+        await expect(runtime.createParserFixture({ path: 'notes.md' })).rejects.toThrow('No parser handles .md files.');
+    });
+});
@@ -0,0 +1,155 @@
+import { existsSync, mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `patch-review-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const ORIGINAL = ['export function greet(name) {', '  return "Hello " + name;', '}', '', 'export const VERSION = 1;', ''].join('\n');
+const PATCH = [
+    'diff --git a/src/greet.js b/src/greet.js',
+    '--- a/src/greet.js',
+    '+++ b/src/greet.js',
+    '@@ -1,3 +1,3 @@',
+    ' export function greet(name) {',
+    '-  return "Hello " + name;',
+    '+  return `Hello ${name}`;',
+    ' }',
+    '@@ -4,2 +4,2 @@',
+    ' ',
+    '-export const VERSION = 1;',
+    '+export const VERSION = 2;',
+    '--- /dev/null',
+    '+++ b/src/notes.md',
+    '@@ -0,0 +1,1 @@',
+    '+# Notes',
+    '',
+].join('\n');
+describe('per-hunk patch review', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('writes only accepted and edited hunks', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'src'), { recursive: true });
+        await writeFile(join(tempDir, 'src', 'greet.js'), ORIGINAL, 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        expect(runtime.parsePatch({ patch: PATCH }).map((file) => [file.path, file.hunks.length])).toEqual([
+            ['src/greet.js', 2],
+            ['src/notes.md', 1],
+        ]);
+        const result = await runtime.reviewPatch({
+            patch: PATCH,
+            decisions: [
+                { path: 'src/greet.js', hunkIndex: 0, decision: 'edit', lines: [' export function greet(name) {', '-  return "Hello " + name;', '+  return `Hi ${name}`;', ' }'] },
+                { path: 'src/greet.js', hunkIndex: 1, decision: 'reject', reason: 'Version bumps happen at release time.' },
+            ],
+        });
+        expect(result.applied).toEqual([{ path: 'src/greet.js', hunks: [0] }]);
+        expect(result.edited).toEqual([{ path: 'src/greet.js', hunkIndex: 0 }]);
+        expect(result.rejected).toEqual([
+            { path: 'src/greet.js', hunkIndex: 1, reason: 'Version bumps happen at release time.' },
+            { path: 'src/notes.md', hunkIndex: 0, reason: undefined },
+        ]);
+        expect(await readFile(join(tempDir, 'src', 'greet.js'), 'utf8')).toBe(ORIGINAL.replace('"Hello " + name', '`Hi ${name}`'));
+        expect(existsSync(join(tempDir, 'src', 'notes.md'))).toBe(false);
+    });
+    it('falls back to looser strategies when the file moved or was reformatted', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'src'), { recursive: true });
+        // Two lines were added above, greet() was re-indented, and the line after VERSION changed.
+        await writeFile(join(tempDir, 'src', 'greet.js'), [
+            '// header',
+            '',
+            'export function greet(name) {',
+            '    return "Hello " + name;',
+            '}',
+            '',
+            'export const VERSION = 1;',
+            'export const BUILD = 7;',
+            '',
+        ].join('\n'), 'utf8');
+        const patch = [
+            '--- a/src/greet.js',
+            '+++ b/src/greet.js',
+            '@@ -1,3 +1,3 @@',
+            ' export function greet(name) {',
+            '-  return "Hello " + name;',
+            '+  return `Hello ${name}`;',
+            ' }',
+            '@@ -4,3 +4,3 @@',
+            ' ',
+            '-export const VERSION = 1;',
+            '+export const VERSION = 2;',
+            ' export const NAME = "greet";',
+            '',
+        ].join('\n');
+        const decisions = [
+            { path: 'src/greet.js', hunkIndex: 0, decision: 'accept'          },
+            { path: 'src/greet.js', hunkIndex: 1, decision: 'accept'          },
+        ];
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        await expect(runtime.reviewPatch({ patch, decisions, strategies: ['exact', 'offset'] })).rejects.toThrow('tried exact, offset');
+        const result = await runtime.reviewPatch({ patch, decisions });
+        expect(result.strategies).toEqual([
+            { path: 'src/greet.js', hunkIndex: 0, strategy: 'whitespace' },
+            { path: 'src/greet.js', hunkIndex: 1, strategy: 'fuzz' },
+        ]);
+        expect(await readFile(join(tempDir, 'src', 'greet.js'), 'utf8')).toBe([
+            '// header',
+            '',
+            'export function greet(name) {',
+            '    return `Hello ${name}`;',
+            '}',
+            '',
+            'export const VERSION = 2;',
+            'export const BUILD = 7;',
+            '',
+        ].join('\n'));
+        expect(result.restyled).toEqual([{ path: 'src/greet.js', adjustments: ['indentation → 4 spaces'] }]);
+        const telemetry = await runtime.previewTelemetry();
+        expect(telemetry.patchStrategies).toEqual({ whitespace: 1, fuzz: 1 });
+        expect(telemetry.features.find((feature) => feature.feature === 'patch.apply')).toMatchObject({ runs: 2, failures: 1 });
+    });
+    it('feeds rejection reasons back into the agent prompt', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'src'), { recursive: true });
+        await writeFile(join(tempDir, 'src', 'greet.js'), ORIGINAL, 'utf8');
+        const scriptPath = join(tempDir, 'echo-provider.mjs');
+        await writeFile(scriptPath, [
+            "let input = '';",
+            "process.stdin.setEncoding('utf8');",
+            "process.stdin.on('data', (chunk) => { input += chunk; });",
+            "process.stdin.on('end', () => {",
+            "  const payload = JSON.parse(input || '{}');",
+            "  process.stdout.write(JSON.stringify({ success: true, provider: payload.provider, content: payload.prompt }));",
+            "});",
+        ].join('\n'), 'utf8');
+        await mkdir(join(tempDir, '.automatosx'), { recursive: true });
+        await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({
+            providers: { executors: { claude: { command: 'node', args: [scriptPath] } } },
+        }), 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        await runtime.registerAgent({ agentId: 'refactorer', name: 'Refactorer', capabilities: ['refactoring'] });
+        await runtime.reviewPatch({
+            patch: PATCH,
+            agentId: 'refactorer',
+            decisions: [
+                { path: 'src/greet.js', hunkIndex: 0, decision: 'accept' },
+                { path: 'src/greet.js', hunkIndex: 1, decision: 'reject', reason: 'Version bumps happen at release time.' },
+                { path: 'src/notes.md', hunkIndex: 0, decision: 'accept' },
+            ],
+        });
+        const run = await runtime.runAgent({ agentId: 'refactorer', task: 'Tidy greet.js', basePath: tempDir });
+        expect(run.content).toContain('Previously rejected changes:');
+        expect(run.content).toContain('- src/greet.js @@ -4,2 +4,2 @@: Version bumps happen at release time.');
+        expect(await readFile(join(tempDir, 'src', 'notes.md'), 'utf8')).toBe('# Notes\n');
+    });
+});
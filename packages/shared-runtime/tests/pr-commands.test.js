@@ -0,0 +1,185 @@
+import { createHmac } from 'node:crypto';
+import { describe, expect, it } from 'vitest';
+import { authorizePrCommand, handleAutomationEvent, matchPrCommand, parsePrCommand, resolveAutomationRules, resolvePrCommandConfig, startPrCommandServer, verifyWebhookSignature, } from '../src/pr-commands.js';
+function commentPayload(body, overrides = {}) {
+    return {
+        action: 'created',
+        issue: {
+            number: 42,
+            title: 'Add checkout flow',
+            html_url: 'https://github.com/acme/api/pull/42',
+            ...(overrides.pullRequest === false ? {} : { pull_request: { url: 'https://api.github.com/repos/acme/api/pulls/42' } }),
+        },
+        comment: {
+            id: 7,
+            body,
+            user: { login: overrides.user ?? 'alice' },
+            author_association: overrides.association ?? 'MEMBER',
+        },
+        repository: { full_name: overrides.repository ?? 'acme/api' },
+    };
+}
+function labelPayload(label, labels, sender = 'alice', event = 'issues') {
+    return {
+        action: 'labeled',
+        label: { name: label },
+        [event === 'issues' ? 'issue' : 'pull_request']: {
+            number: 9,
+            title: 'Login fails on Safari',
+            html_url: 'https://github.com/acme/api/issues/9',
+            labels: labels.map((name) => ({ name })),
+        },
+        repository: { full_name: 'acme/api' },
+        sender: { login: sender },
+    };
+}
+describe('PR comment commands', () => {
+    const config = resolvePrCommandConfig({
+        commands: {
+            'fix lint': 'fix-lint',
+            fix: { workflow: 'fix-any', input: { mode: 'auto' } },
+        },
+        repositories: {
+            'acme/api': {},
+            'acme/infra': { allowedUsers: ['ops-bot'], allowedAssociations: [], commands: { deploy: 'deploy-preview' } },
+        },
+    });
+    it('parses, matches, and authorizes commands', () => {
+        expect(parsePrCommand('Looks good.\n/ax fix lint --strict\nthanks')).toBe('fix lint --strict');
+        expect(parsePrCommand('/axe fix lint')).toBeUndefined();
+        expect(parsePrCommand('!bot run', '!bot')).toBe('run');
+        expect(matchPrCommand('fix lint --strict', config.commands)).toEqual({
+            name: 'fix lint',
+            definition: { workflow: 'fix-lint' },
+            args: ['--strict'],
+        });
+        expect(matchPrCommand('Fix types', config.commands)).toMatchObject({ name: 'fix', args: ['types'] });
+        expect(matchPrCommand('deploy', config.commands)).toBeUndefined();
+        expect(authorizePrCommand(config, { repository: 'acme/api', user: 'alice', association: 'MEMBER' })).toEqual({ allowed: true });
+        expect(authorizePrCommand(config, { repository: 'acme/api', user: 'drive-by', association: 'CONTRIBUTOR' }).allowed).toBe(false);
+        expect(authorizePrCommand(config, { repository: 'acme/infra', user: 'alice', association: 'OWNER' }).allowed).toBe(false);
+        expect(authorizePrCommand(config, { repository: 'acme/infra', user: 'Ops-Bot', association: 'NONE' })).toEqual({ allowed: true });
+        expect(authorizePrCommand(config, { repository: 'other/repo', user: 'alice', association: 'OWNER' })).toEqual({
+            allowed: false,
+            reason: 'other/repo is not configured for AutomatosX commands.',
+        });
+        const body = Buffer.from('{"zen":"hi"}');
+        const signature = `sha256=${createHmac('sha256', 'shh').update(body).digest('hex')}`;
+        expect(verifyWebhookSignature('shh', body, signature)).toBe(true);
+        expect(verifyWebhookSignature('other', body, signature)).toBe(false);
+        expect(verifyWebhookSignature('shh', body, undefined)).toBe(false);
+    });
+    it('runs mapped workflows from signed webhooks and reports back on the PR', async () => {
+        const comments = [];
+        const runs = [];
+        const outcomes = [];
+        const server = await startPrCommandServer({
+            config,
+            secret: 'shh',
+            dependencies: {
+                runWorkflow: async (request) => {
+                    runs.push(request);
+                    return request.workflowId === 'fix-lint'
+                        ? { traceId: 'trace-1', success: true, output: { fixed: 3 } }
+                        : { traceId: 'trace-2', success: false, error: { message: 'step lint failed' } };
+                },
+                postComment: async (repository, number, body) => {
+                    comments.push(`${repository}#${number} ${body}`);
+                },
+            },
+            onOutcome: (outcome) => outcomes.push(outcome),
+        });
+        const deliver = async (payload, event = 'issue_comment', secret = 'shh') => {
+            const body = JSON.stringify(payload);
+            const response = await fetch(`http://127.0.0.1:${server.port}/`, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-GitHub-Event': event,
+                    'X-Hub-Signature-256': `sha256=${createHmac('sha256', secret).update(body).digest('hex')}`,
+                },
+                body,
+            });
+            return response.status;
+        };
+        try {
+            expect(await deliver({ zen: 'hi' }, 'ping')).toBe(200);
+            expect(await deliver(commentPayload('/ax fix lint'), 'issue_comment', 'wrong')).toBe(401);
+            expect(await deliver(commentPayload('/ax fix lint --strict'))).toBe(202);
+            expect(await deliver(commentPayload('/ax fix types', { user: 'drive-by', association: 'NONE' }))).toBe(202);
+            expect(await deliver(commentPayload('/ax ship it'))).toBe(202);
+            expect(await deliver(commentPayload('/ax fix lint', { pullRequest: false }))).toBe(202);
+            expect(await deliver(commentPayload('just a comment'))).toBe(202);
+        }
+        finally {
+            await server.close();
+        }
+        expect(runs).toEqual([
+            {
+                workflowId: 'fix-lint',
+                input: {
+                    command: 'fix lint',
+                    args: ['--strict'],
+                    requestedBy: 'alice',
+                    pullRequest: { repository: 'acme/api', number: 42, title: 'Add checkout flow', url: 'https://github.com/acme/api/pull/42', commentId: 7 },
+                },
+            },
+        ]);
+        expect(outcomes.map((outcome) => outcome.status).sort()).toEqual(['completed', 'denied', 'ignored', 'ignored', 'unknown']);
+        expect(comments).toContain('acme/api#42 > /ax fix lint --strict\n\nRunning workflow `fix-lint` for @alice...');
+        expect(comments.find((comment) => comment.includes('completed (trace `trace-1`)'))).toContain('"fixed": 3');
+        expect(comments).toContain('acme/api#42 > /ax fix types\n\n@drive-by is not allowed to run AutomatosX commands in acme/api.');
+        expect(comments).toContain('acme/api#42 > /ax ship it\n\nUnknown command. Available: `/ax fix lint`, `/ax fix`.');
+    });
+    it('runs label automation rules and holds gated rules for approval', async () => {
+        const rules = resolveAutomationRules([
+            { label: 'ax:triage', workflow: 'triage', event: 'issues', input: { depth: 'quick' } },
+            { name: 'autofix', label: 'ax:fix', workflow: 'fix', approval: { users: ['lead'] } },
+            { action: 'opened', event: 'pull_request', workflow: 'review-pr', repositories: ['acme/web'] },
+            { workflow: 'missing-trigger' },
+        ]);
+        expect(rules.map((rule) => `${rule.name} ${rule.event ?? '*'}.${rule.action} -> ${rule.workflow}${rule.approval !== undefined ? ` (approval ${rule.approval.label})` : ''}`)).toEqual([
+            'ax:triage issues.labeled -> triage',
+            'autofix *.labeled -> fix (approval ax:approved)',
+            'rule-3 pull_request.opened -> review-pr',
+        ]);
+        const comments = [];
+        const runs = [];
+        const dependencies = {
+            runWorkflow: async (request) => {
+                runs.push(request);
+                return { traceId: `trace-${runs.length}`, success: true };
+            },
+            postComment: async (repository, number, body) => {
+                comments.push(`${repository}#${number} ${body}`);
+            },
+        };
+        const statuses = async (payload, event = 'issues') =>
+            (await handleAutomationEvent(rules, event, payload, dependencies)).map((outcome) => `${outcome.status}${outcome.rule !== undefined ? ` ${outcome.rule}` : ''}`);
+        expect(await statuses(labelPayload('ax:triage', ['bug', 'ax:triage']))).toEqual(['completed ax:triage']);
+        expect(runs[0]).toEqual({
+            workflowId: 'triage',
+            input: {
+                depth: 'quick',
+                rule: 'ax:triage',
+                trigger: { event: 'issues', action: 'labeled', label: 'ax:triage', sender: 'alice' },
+                issue: { repository: 'acme/api', number: 9, title: 'Login fails on Safari', url: 'https://github.com/acme/api/issues/9', labels: ['bug', 'ax:triage'] },
+            },
+        });
+        expect(await statuses(labelPayload('ax:triage', ['ax:triage'], 'alice', 'pull_request'), 'pull_request')).toEqual(['ignored']);
+        expect(await statuses(labelPayload('ax:fix', ['ax:fix']))).toEqual(['awaiting-approval autofix']);
+        expect(comments).toContain('acme/api#9 > Label `ax:fix` added by @alice\n\nWorkflow `fix` (rule `autofix`) is waiting for approval: add the `ax:approved` label to run it.');
+        expect(await statuses(labelPayload('ax:approved', ['ax:fix', 'ax:approved'], 'alice'))).toEqual(['denied autofix']);
+        expect(await statuses(labelPayload('ax:approved', ['ax:fix', 'ax:approved'], 'Lead'))).toEqual(['completed autofix']);
+        expect(await statuses(labelPayload('ax:approved', ['ax:approved'], 'lead'))).toEqual(['ignored']);
+        expect(runs.map((run) => run.workflowId)).toEqual(['triage', 'fix']);
+        // An approval label put on before the trigger doesn't approve it.
+        expect(await statuses(labelPayload('ax:fix', ['ax:approved', 'ax:fix'], 'alice'))).toEqual(['awaiting-approval autofix']);
+        expect(comments).toContain('acme/api#9 > Label `ax:fix` added by @alice\n\nWorkflow `fix` (rule `autofix`) is waiting for approval: the `ax:approved` label was already on it, so remove it and add it again to run it.');
+        expect(runs.map((run) => run.workflowId)).toEqual(['triage', 'fix']);
+        const opened = (repository) => ({ action: 'opened', pull_request: { number: 3, labels: [] }, repository: { full_name: repository }, sender: { login: 'bob' } });
+        expect(await statuses(opened('acme/api'), 'pull_request')).toEqual(['ignored']);
+        expect(await statuses(opened('acme/web'), 'pull_request')).toEqual(['completed rule-3']);
+        expect(await statuses(labelPayload('ax:triage', ['ax:triage']), 'push')).toEqual(['ignored']);
+    });
+});
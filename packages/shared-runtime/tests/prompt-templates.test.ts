@@ -0,0 +1,92 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { collectTemplateVariables } from '../src/prompt-templates.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `prompt-templates-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('prompt templates', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    delete process.env.AX_TEMPLATE_STAGE;
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('collects project, env, and input variables for a workspace', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeFile(join(tempDir, 'package.json'), '{ "name": "checkout-service" }\n', 'utf8');
+
+    const variables = await collectTemplateVariables({ basePath: tempDir, input: { ticket: 'AX-7' }, env: { STAGE: 'prod' } });
+    expect(variables).toMatchObject({
+      project: { name: 'checkout-service', root: tempDir },
+      env: { STAGE: 'prod' },
+      input: { ticket: 'AX-7' },
+    });
+    expect(Object.keys(variables)).toContain('git');
+  });
+
+  it('previews agent system prompts and workflow step prompts with placeholders filled in', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    process.env.AX_TEMPLATE_STAGE = 'staging';
+    await writeFile(join(tempDir, 'package.json'), '{ "name": "checkout-service" }\n', 'utf8');
+    await mkdir(join(tempDir, 'workflows'));
+    await writeFile(join(tempDir, 'workflows', 'templated.json'), `${JSON.stringify({
+      workflowId: 'templated',
+      name: 'Templated',
+      version: '1.0.0',
+      steps: [
+        { stepId: 'plan', type: 'prompt', config: { prompt: 'Plan {{input.ticket}} for {{project.name}}' } },
+        { stepId: 'review', type: 'prompt', config: { prompt: 'Review {{steps.plan.output.content}} in {{env.AX_TEMPLATE_STAGE}}' } },
+        { stepId: 'lint', type: 'tool', config: { toolName: 'lint' } },
+      ],
+    }, null, 2)}\n`, 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    await runtime.registerAgent({
+      agentId: 'deployer',
+      name: 'Deployer',
+      capabilities: ['deploy'],
+      metadata: { systemPrompt: '{{agent.name}} ships {{project.name}} to {{env.AX_TEMPLATE_STAGE}}: {{task}}. Region {{env.AX_TEMPLATE_REGION}}, not \\{{input.raw}}.' },
+    });
+
+    expect(await runtime.previewTemplates({ agentId: 'deployer', task: 'cut release 2.1' })).toEqual({
+      kind: 'agent',
+      id: 'deployer',
+      templates: [{
+        field: 'systemPrompt',
+        text: 'Deployer ships checkout-service to staging: cut release 2.1. Region {{env.AX_TEMPLATE_REGION}}, not {{input.raw}}.',
+        missing: ['env.AX_TEMPLATE_REGION'],
+        deferred: [],
+      }],
+    });
+
+    expect(await runtime.previewTemplates({ workflowId: 'templated', workflowDir: join(tempDir, 'workflows'), input: { ticket: 'AX-7' } })).toEqual({
+      kind: 'workflow',
+      id: 'templated',
+      templates: [
+        { field: 'plan.prompt', text: 'Plan AX-7 for checkout-service', missing: [], deferred: [] },
+        {
+          field: 'review.prompt',
+          text: 'Review {{steps.plan.output.content}} in staging',
+          missing: [],
+          deferred: ['steps.plan.output.content'],
+        },
+      ],
+      definitionOfDone: {
+        workflowId: 'templated',
+        source: 'default',
+        path: join(tempDir, '.automatosx', 'done', 'templated.json'),
+        criteria: [],
+      },
+    });
+    await expect(runtime.previewTemplates({ agentId: 'missing' })).rejects.toThrow('not registered');
+  });
+});
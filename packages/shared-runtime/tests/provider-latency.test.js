@@ -0,0 +1,57 @@
+import { mkdirSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { readLatencyRoutingStatus, recordProviderLatency, resolveLatencyRoutingConfig, routeByLatency, summarizeProviderLatency, } from '../src/provider-latency.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `provider-latency-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+async function recordMany(basePath, provider, latencies, success = true) {
+    for (const latencyMs of latencies) {
+        await recordProviderLatency(basePath, { provider, model: 'default', latencyMs, success });
+    }
+}
+describe('provider latency routing', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('summarizes rolling percentiles per provider and model', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await Promise.all([100, 200, 300, 400].map((latencyMs) => recordProviderLatency(tempDir, { provider: 'claude', model: 'opus', latencyMs, success: true })));
+        await recordProviderLatency(tempDir, { provider: 'claude', model: 'opus', latencyMs: 30_000, success: false });
+        await recordProviderLatency(tempDir, { provider: 'gemini', latencyMs: 50, success: true });
+        const summaries = await summarizeProviderLatency(tempDir);
+        expect(summaries.map(({ lastAt: _lastAt, ...summary }) => summary)).toEqual([
+            { provider: 'claude', model: 'opus', samples: 5, failures: 1, p50: 200, p95: 400, p99: 400 },
+            { provider: 'gemini', samples: 1, failures: 0, p50: 50, p95: 50, p99: 50 },
+        ]);
+    });
+    it('routes to the fastest qualifying provider and only switches past the margin', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const config = resolveLatencyRoutingConfig({
+            latency: { enabled: true, providers: ['claude', 'gemini', 'codex'], percentile: 'p50', minSamples: 3, switchMargin: 0.2 },
+        });
+        expect(await routeByLatency(tempDir, 'claude', config)).toBe('claude');
+        await recordMany(tempDir, 'claude', [900, 1000, 1100]);
+        await recordMany(tempDir, 'gemini', [500, 600, 700]);
+        await recordMany(tempDir, 'codex', [10, 10]);
+        await recordMany(tempDir, 'grok', [1, 1, 1]);
+        expect(await routeByLatency(tempDir, 'claude', config)).toBe('gemini');
+        // claude is now faster, but not by 20%, so routing holds.
+        await recordMany(tempDir, 'claude', [520, 520, 520, 520]);
+        expect(await routeByLatency(tempDir, 'claude', config)).toBe('gemini');
+        await recordMany(tempDir, 'claude', Array.from({ length: 12 }, () => 300));
+        expect(await routeByLatency(tempDir, 'gemini', config)).toBe('claude');
+        expect(await readLatencyRoutingStatus(tempDir, config)).toMatchObject({ enabled: true, percentile: 'p50', routedProvider: 'claude' });
+        // A provider failing half its calls no longer qualifies.
+        await recordMany(tempDir, 'claude', Array.from({ length: 24 }, () => 30_000), false);
+        expect(await routeByLatency(tempDir, 'claude', config)).toBe('gemini');
+        expect(await routeByLatency(tempDir, 'claude', resolveLatencyRoutingConfig({ latency: { enabled: true, providers: ['gemini'] } }))).toBe('claude');
+        expect(await readLatencyRoutingStatus(tempDir, resolveLatencyRoutingConfig(undefined))).toEqual({ enabled: false, providers: [], percentile: 'p95' });
+    });
+});
@@ -0,0 +1,154 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { extractReferences } from '../src/reference-index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `reference-index-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const SERVER_TS = [
+  'export class Server {',
+  '  start(port: number): void {',
+  '    listen(port);',
+  '  }',
+  '}',
+  '',
+  'export class Client {',
+  '  start(): void {}',
+  '}',
+  '',
+  'function listen(port: number): void {}',
+  '',
+].join('\n');
+
+const MAIN_TS = [
+  "import { Server } from './server';",
+  '',
+  '// start the server',
+  'const server = new Server();',
+  'server.start(8080);',
+  "console.log(`started: ${server.start.name}`, 'start');",
+  '',
+].join('\n');
+
+const STORE_GO = [
+  'package store',
+  '',
+  'type DiskStore struct{}',
+  '',
+  'func (d *DiskStore) Save(key string) error {',
+  '\treturn nil',
+  '}',
+  '',
+  'type MemStore struct{}',
+  '',
+  'func (m *MemStore) Save(key string) error {',
+  '\treturn nil',
+  '}',
+  '',
+  'func Persist(s *MemStore) error {',
+  '\treturn s.Save("id")',
+  '}',
+  '',
+].join('\n');
+
+const GENERIC_GO = [
+  'package pipe',
+  '',
+  'type Processor[T any] struct {',
+  '\titems []T',
+  '}',
+  '',
+  'func (p *Processor[T]) Run(item T) {}',
+  '',
+  'func Apply[K comparable, V any](m map[K]V) {}',
+  '',
+  'func Wire() {',
+  '\tstrings := Processor[string]{}',
+  '\tnumbers := &Processor[int]{}',
+  '\tmore := Processor[ string ]{}',
+  '\tApply[string, int](nil)',
+  '\tstrings.Run("a")',
+  '\t_, _ = numbers, more',
+  '}',
+  '',
+].join('\n');
+
+describe('reference index', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('records identifiers used in code with their qualifiers', () => {
+    expect(extractReferences(MAIN_TS).filter((reference) => reference.name === 'start')).toEqual([
+      { name: 'start', line: 5, column: 8, qualifier: 'server' },
+      { name: 'start', line: 6, column: 32, qualifier: 'server' },
+    ]);
+    expect(extractReferences('p->next = Node::make(p);').map((reference) => reference.qualifier ?? '-')).toEqual(['-', 'p', '-', 'Node', '-']);
+  });
+
+  it('goes to definitions by symbol or position and finds references', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'web'), { recursive: true });
+    await mkdir(join(tempDir, 'store'), { recursive: true });
+    await writeFile(join(tempDir, 'web', 'server.ts'), SERVER_TS, 'utf8');
+    await writeFile(join(tempDir, 'web', 'main.ts'), MAIN_TS, 'utf8');
+    await writeFile(join(tempDir, 'store', 'store.go'), STORE_GO, 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const where = (symbols: Array<{ path: string; line: number; receiver?: string }>) =>
+      symbols.map((symbol) => `${symbol.path}:${symbol.line}${symbol.receiver === undefined ? '' : ` ${symbol.receiver}`}`);
+
+    const saves = await runtime.findDefinition({ symbol: 'Save' });
+    expect(where(saves.definitions)).toEqual(['store/store.go:5 DiskStore', 'store/store.go:11 MemStore']);
+    expect(where((await runtime.findDefinition({ symbol: '(*MemStore).Save' })).definitions)).toEqual(['store/store.go:11 MemStore']);
+
+    const atCall = await runtime.findDefinition({ path: 'web/main.ts', line: 5, column: 10 });
+    expect(atCall.at).toEqual({ path: 'web/main.ts', line: 5, column: 8, qualifier: 'server' });
+    expect(where(atCall.definitions)).toEqual(['web/server.ts:2 Server', 'web/server.ts:8 Client']);
+    expect(where((await runtime.findDefinition({ path: 'web/server.ts', line: 3, column: 5 })).definitions)).toEqual(['web/server.ts:11']);
+
+    const references = await runtime.findReferences({ symbol: 'Server.start' });
+    expect(references.references.map((location) => `${location.path}:${location.line}:${location.column} ${location.kind}`)).toEqual([
+      'web/main.ts:5:8 reference',
+      'web/main.ts:6:32 reference',
+      'web/server.ts:2:3 definition',
+    ]);
+    expect(references.files).toEqual(['web/main.ts', 'web/server.ts']);
+    const uses = await runtime.findReferences({ path: 'web/server.ts', line: 11, column: 10, includeDefinitions: false });
+    expect(uses.references).toEqual([{ path: 'web/server.ts', line: 3, column: 5, kind: 'reference', text: 'listen(port);' }]);
+
+    await writeFile(join(tempDir, 'web', 'main.ts'), `${MAIN_TS}listen(1);\n`, 'utf8');
+    expect((await runtime.findReferences({ symbol: 'listen', includeDefinitions: false })).files).toEqual(['web/main.ts', 'web/server.ts']);
+
+    await expect(runtime.findDefinition({ path: 'web/main.ts', line: 3, column: 5 })).rejects.toThrow('No identifier at web/main.ts:3:5');
+    await expect(runtime.findReferences({})).rejects.toThrow('Pass a symbol');
+  });
+
+  it('tells instantiations of a Go generic apart', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeFile(join(tempDir, 'pipe.go'), GENERIC_GO, 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const [processor] = (await runtime.findDefinition({ symbol: 'Processor[int]' })).definitions;
+    expect(processor?.typeParams).toEqual([{ name: 'T', constraint: 'any' }]);
+    expect((await runtime.findDefinition({ symbol: 'Apply' })).definitions[0]?.typeParams).toEqual([
+      { name: 'K', constraint: 'comparable' },
+      { name: 'V', constraint: 'any' },
+    ]);
+
+    const all = await runtime.findReferences({ symbol: 'Processor', includeDefinitions: false });
+    expect(all.references.map((location) => `${location.line} ${location.typeArgs?.join(',') ?? '-'}`)).toEqual(['7 -', '12 string', '13 int', '14 string']);
+    expect(all.instantiations).toEqual([{ typeArgs: ['string'], count: 2 }, { typeArgs: ['int'], count: 1 }]);
+    expect((await runtime.findReferences({ symbol: 'Processor[int]' })).references.map((location) => location.line)).toEqual([13]);
+    expect((await runtime.findReferences({ symbol: 'Apply[string, int]' })).references.map((location) => location.line)).toEqual([15]);
+  });
+});
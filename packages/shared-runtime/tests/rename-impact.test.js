@@ -0,0 +1,105 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `rename-impact-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const STORE_GO = [
+    'package store',
+    '',
+    'type Store interface {',
+    '\tSave(key string) error',
+    '}',
+    '',
+    'type User struct {',
+    '\tUserID string `json:"user_id" db:"user_id"`',
+    '\tName   string `json:"name"`',
+    '}',
+    '',
+    'type DiskStore struct{}',
+    '',
+    'func (d *DiskStore) Save(key string) error {',
+    '\treturn nil',
+    '}',
+    '',
+    'type MemStore struct{}',
+    '',
+    'func (m *MemStore) Save(key string) error {',
+    '\treturn nil',
+    '}',
+    '',
+    '// Persist calls Save on whichever store it gets.',
+    'func Persist(s Store, u User) error {',
+    '\tlog("Save called")',
+    '\treturn s.Save(u.UserID)',
+    '}',
+    '',
+].join('\n');
+const FORMAT_TS = [
+    'export interface Formatter {',
+    '  format(value: number): string;',
+    '}',
+    '',
+    'export class Price implements Formatter {',
+    '  format(value: number): string {',
+    '    return `$${value}`;',
+    '  }',
+    '}',
+    '',
+    'export function render(f: Formatter): string {',
+    '  // format is called below',
+    '  return `<b>${f.format(1)}</b>`;',
+    '}',
+    '',
+    "export const label = 'format';",
+    '',
+].join('\n');
+describe('rename impact', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('lists definitions, implementations, call sites, tags, and strings to change', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'store'), { recursive: true });
+        await mkdir(join(tempDir, 'web'), { recursive: true });
+        await writeFile(join(tempDir, 'store', 'store.go'), STORE_GO, 'utf8');
+        await writeFile(join(tempDir, 'web', 'format.ts'), FORMAT_TS, 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const describeImpact = async (symbol) =>
+            (await runtime.analyzeRenameImpact({ symbol })).locations.map((location) => `${location.path}:${location.line}:${location.column} ${location.kind}${location.container === undefined ? '' : ` ${location.container}`}`);
+        expect(await describeImpact('(*DiskStore).Save')).toEqual([
+            'store/store.go:4:2 interface-member Store',
+            'store/store.go:14:21 definition DiskStore',
+            'store/store.go:20:20 implementation MemStore',
+            'store/store.go:26:7 string',
+            'store/store.go:27:11 reference',
+        ]);
+        const field = await runtime.analyzeRenameImpact({ symbol: 'UserID' });
+        expect(field.locations.map((location) => `${location.line}:${location.column} ${location.kind}`)).toEqual([
+            '8:2 definition',
+            '8:23 struct-tag',
+            '27:18 reference',
+        ]);
+        expect(field.files).toEqual(['store/store.go']);
+        const method = await runtime.analyzeRenameImpact({ symbol: 'format', paths: ['web'] });
+        expect(method.locations.map((location) => `${location.line} ${location.kind}`)).toEqual([
+            '2 interface-member',
+            '6 definition',
+            '13 reference',
+            '16 string',
+        ]);
+        expect(method).toMatchObject({
+            name: 'format',
+            scannedFiles: 1,
+            truncated: false,
+            counts: { definition: 1, 'interface-member': 1, implementation: 0, 'struct-tag': 0, reference: 1, string: 1 },
+        });
+        await expect(runtime.analyzeRenameImpact({ symbol: 'a.b.c' })).rejects.toThrow(/Invalid symbol "a.b.c"/);
+    });
+});
@@ -0,0 +1,130 @@
+import { mkdirSync } from 'node:fs';
+import { rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createProviderBridge } from '../src/provider-bridge.js';
+import { claimHedgeBudget, countRecentHedges, summarizeProviderLatency } from '../src/provider-latency.js';
+import { resolveHedgingConfig, runHedged } from '../src/request-hedging.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `request-hedging-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+function after<T>(ms: number, value: T, signal?: AbortSignal): Promise<T | 'aborted'> {
+  return new Promise((resolve) => {
+    const timer = setTimeout(() => resolve(value), ms);
+    signal?.addEventListener('abort', () => {
+      clearTimeout(timer);
+      resolve('aborted');
+    });
+  });
+}
+
+describe('request hedging', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('sends a backup after the delay and keeps the first success', async () => {
+    const isSuccess = (result: string) => result.startsWith('ok');
+    let claims = 0;
+    const claim = async () => {
+      claims += 1;
+      return true;
+    };
+
+    const fast = await runHedged({ primary: (signal) => after(5, 'ok-primary', signal), backup: () => after(5, 'ok-backup'), delayMs: 50, claim, isSuccess });
+    expect(fast).toEqual({ result: 'ok-primary', hedged: false, winner: 'primary' });
+    expect(claims).toBe(0);
+
+    const slow = await runHedged({ primary: (signal) => after(1000, 'ok-primary', signal), backup: (signal) => after(5, 'ok-backup', signal), delayMs: 20, claim, isSuccess });
+    expect(slow).toEqual({ result: 'ok-backup', hedged: true, winner: 'backup' });
+
+    const failedBackup = await runHedged({ primary: (signal) => after(60, 'ok-primary', signal), backup: (signal) => after(5, 'error', signal), delayMs: 20, claim, isSuccess });
+    expect(failedBackup).toEqual({ result: 'ok-primary', hedged: true, winner: 'primary' });
+
+    const bothFail = await runHedged({ primary: () => after(60, 'error-primary'), backup: () => after(5, 'error-backup'), delayMs: 20, claim, isSuccess });
+    expect(bothFail).toEqual({ result: 'error-primary', hedged: true, winner: 'primary' });
+
+    const noBudget = await runHedged({ primary: () => after(60, 'ok-primary'), backup: () => after(5, 'ok-backup'), delayMs: 20, claim: async () => false, isSuccess });
+    expect(noBudget).toEqual({ result: 'ok-primary', hedged: false, winner: 'primary' });
+  });
+
+  it('treats a call that throws as a failed one', async () => {
+    const isSuccess = (result: string) => result.startsWith('ok');
+    const claim = async () => true;
+    const broken = async (): Promise<string> => {
+      throw new Error('backup config unreadable');
+    };
+
+    const throwingBackup = await runHedged({ primary: (signal) => after(60, 'ok-primary', signal), backup: broken, delayMs: 20, claim, isSuccess });
+    expect(throwingBackup).toEqual({ result: 'ok-primary', hedged: true, winner: 'primary' });
+
+    const failingPrimary = await runHedged({ primary: () => after(60, 'error-primary'), backup: broken, delayMs: 20, claim, isSuccess });
+    expect(failingPrimary).toEqual({ result: 'error-primary', hedged: true, winner: 'primary' });
+
+    const rejectingPrimary = async (): Promise<string> => {
+      await after(60, undefined);
+      throw new Error('primary crashed');
+    };
+    const rescued = await runHedged({ primary: rejectingPrimary, backup: (signal) => after(5, 'ok-backup', signal), delayMs: 20, claim, isSuccess });
+    expect(rescued).toEqual({ result: 'ok-backup', hedged: true, winner: 'backup' });
+    await expect(runHedged({ primary: rejectingPrimary, backup: broken, delayMs: 20, claim, isSuccess })).rejects.toThrow('primary crashed');
+
+    const brokenClaim = await runHedged({ primary: () => after(60, 'ok-primary'), backup: () => after(5, 'ok-backup'), delayMs: 20, claim: async () => { throw new Error('budget file locked'); }, isSuccess });
+    expect(brokenClaim).toEqual({ result: 'ok-primary', hedged: false, winner: 'primary' });
+  });
+
+  it('caps hedges per hour', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const now = new Date('2026-05-01T12:00:00.000Z');
+
+    expect(await claimHedgeBudget(tempDir, 2, now)).toBe(true);
+    expect(await claimHedgeBudget(tempDir, 2, now)).toBe(true);
+    expect(await claimHedgeBudget(tempDir, 2, now)).toBe(false);
+    expect(await countRecentHedges(tempDir, now)).toBe(2);
+    expect(await claimHedgeBudget(tempDir, 2, new Date('2026-05-01T13:00:00.000Z'))).toBe(true);
+    expect(resolveHedgingConfig({ hedging: { enabled: true, providers: [] } }).enabled).toBe(false);
+  });
+
+  it('kills the slower provider process and records only the winner', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    const scriptPath = join(tempDir, 'provider.mjs');
+    await writeFile(scriptPath, [
+      "const [delay, name] = process.argv.slice(2);",
+      "process.stdin.resume();",
+      "process.stdin.on('end', () => setTimeout(() => process.stdout.write(JSON.stringify({ success: true, content: name })), Number(delay)));",
+    ].join('\n'), 'utf8');
+    mkdirSync(join(tempDir, '.automatosx'), { recursive: true });
+    await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({
+      providers: {
+        executors: {
+          claude: { command: 'node', args: [scriptPath, '10000', 'claude'] },
+          gemini: { command: 'node', args: [scriptPath, '10', 'gemini'] },
+        },
+      },
+    }), 'utf8');
+    const bridge = createProviderBridge({ basePath: tempDir });
+    const request = { prompt: 'hi', model: 'default' };
+
+    const startedAt = Date.now();
+    const hedged = await runHedged({
+      primary: (signal) => bridge.executePrompt({ ...request, provider: 'claude', signal }),
+      backup: (signal) => bridge.executePrompt({ ...request, provider: 'gemini', signal }),
+      delayMs: 50,
+      claim: async () => true,
+      isSuccess: (outcome) => outcome.type === 'response' && outcome.response.success,
+    });
+
+    expect(hedged.winner).toBe('backup');
+    expect(hedged.result.type === 'response' ? hedged.result.response.content : undefined).toBe('gemini');
+    expect(Date.now() - startedAt).toBeLessThan(5000);
+    expect((await summarizeProviderLatency(tempDir)).map((summary) => summary.provider)).toEqual(['gemini']);
+  });
+});
@@ -0,0 +1,82 @@
+import { execFileSync } from 'node:child_process';
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `rollback-playbook-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+function git(cwd, args) {
+    execFileSync('git', ['-c', 'user.name=AutomatosX Test', '-c', 'user.email=test@example.com', ...args], { cwd, stdio: 'ignore' });
+}
+async function writeRepoFile(basePath, path, content) {
+    await mkdir(join(basePath, path, '..'), { recursive: true });
+    await writeFile(join(basePath, path), content, 'utf8');
+}
+async function createReleaseRepo(basePath) {
+    git(basePath, ['init', '-q']);
+    await writeRepoFile(basePath, 'config/flags.json', `${JSON.stringify({ checkout: { newFlow: false }, search: true }, null, 2)}\n`);
+    await writeRepoFile(basePath, 'src/app.ts', 'export const version = 1;\n');
+    git(basePath, ['add', '-A']);
+    git(basePath, ['commit', '-q', '-m', 'initial']);
+    git(basePath, ['tag', 'v1.0.0']);
+    await writeRepoFile(basePath, 'migrations/002_add_orders.up.sql', 'CREATE TABLE orders (id INTEGER);\n');
+    await writeRepoFile(basePath, 'migrations/002_add_orders.down.sql', 'DROP TABLE orders;\n');
+    await writeRepoFile(basePath, 'migrations/003_add_refunds.sql', 'CREATE TABLE refunds (id INTEGER);\n');
+    git(basePath, ['add', '-A']);
+    git(basePath, ['commit', '-q', '-m', 'add order tables']);
+    await writeRepoFile(basePath, 'config/flags.json', `${JSON.stringify({ checkout: { newFlow: true }, search: true }, null, 2)}\n`);
+    await writeRepoFile(basePath, 'src/app.ts', 'export const version = 2;\n');
+    git(basePath, ['add', '-A']);
+    git(basePath, ['commit', '-q', '-m', 'enable new checkout flow']);
+}
+describe('rollback playbook', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('builds reverts, migration reversals, and flag flips from the release range', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await createReleaseRepo(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const outputPath = join(tempDir, 'artifacts', 'rollback-playbook.md');
+        const playbook = await runtime.generateRollbackPlaybook({
+            releaseVersion: '1.1.0',
+            releaseTraceId: 'release-trace-001',
+            sessionId: 'release-session-001',
+            traceId: 'rollback-trace-001',
+            outputPath,
+        });
+        expect(playbook.base).toBe('v1.0.0');
+        expect(playbook.commits).toHaveLength(2);
+        expect(playbook.steps.map((step) => step.kind)).toEqual(['migration', 'flag', 'revert', 'verify']);
+        expect(playbook.steps[0]?.command).toContain('migrations/002_add_orders.down.sql');
+        expect(playbook.steps[1]?.description).toContain('checkout.newFlow');
+        expect(playbook.steps[2]?.command).toMatch(/^git revert --no-edit [0-9a-f]{12} [0-9a-f]{12}$/);
+        expect(playbook.validated).toBe(false);
+        expect(playbook.gaps).toEqual(['Migration migrations/003_add_refunds.sql has no matching down migration in this release.']);
+        const artifact = await readFile(outputPath, 'utf8');
+        expect(artifact).toContain('# Rollback Playbook for 1.1.0');
+        expect(artifact).toContain('## Gaps');
+        const traces = await runtime.listTracesBySession('release-session-001');
+        expect(traces[0]).toMatchObject({
+            traceId: 'rollback-trace-001',
+            workflowId: 'rollback-playbook',
+            metadata: { parentTraceId: 'release-trace-001' },
+        });
+    });
+    it('uses an explicit range when one is given', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await createReleaseRepo(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const playbook = await runtime.generateRollbackPlaybook({ base: 'HEAD~1', head: 'HEAD' });
+        expect(playbook.commits.map((commit) => commit.subject)).toEqual(['enable new checkout flow']);
+        expect(playbook.steps.map((step) => step.kind)).toEqual(['flag', 'revert', 'verify']);
+        expect(playbook.validated).toBe(true);
+    });
+});
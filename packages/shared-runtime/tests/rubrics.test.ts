@@ -0,0 +1,93 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `rubrics-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const GO_SERVICE_RUBRIC = [
+  'rubricId: go-service',
+  'name: Go service standards',
+  'appliesTo: [bench, review]',
+  'passThreshold: 0.75',
+  'criteria:',
+  '  - id: error-wrapping',
+  '    description: must include error wrapping',
+  '    weight: 2',
+  '    mustMatch: "fmt\\\\.Errorf\\\\(.*%w"',
+  '  - id: api-docs',
+  '    description: must update the API docs',
+  '    mustTouch: ["docs/api/**"]',
+  '  - id: no-panics',
+  '    description: must not panic',
+  '    mustNotMatch: "panic\\\\("',
+  '',
+].join('\n');
+
+async function writeRubric(basePath: string, fileName: string, content: string): Promise<void> {
+  await mkdir(join(basePath, '.automatosx', 'rubrics'), { recursive: true });
+  await writeFile(join(basePath, '.automatosx', 'rubrics', fileName), content, 'utf8');
+}
+
+describe('evaluation rubrics', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('loads project rubrics from YAML', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeRubric(tempDir, 'go-service.yaml', GO_SERVICE_RUBRIC);
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const rubrics = await runtime.listRubrics();
+
+    expect(rubrics).toHaveLength(1);
+    expect(rubrics[0]).toMatchObject({
+      rubricId: 'go-service',
+      appliesTo: ['bench', 'review'],
+      passThreshold: 0.75,
+    });
+    expect(rubrics[0]?.criteria.map((criterion) => criterion.weight)).toEqual([2, 1, 1]);
+  });
+
+  it('scores output and changed files against weighted criteria', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeRubric(tempDir, 'go-service.yaml', GO_SERVICE_RUBRIC);
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const passing = await runtime.evaluateRubric({
+      rubricId: 'go-service',
+      output: 'return fmt.Errorf("load config: %w", err)',
+      changedFiles: ['internal/config.go', 'docs/api/config.md'],
+    });
+    expect(passing.score).toBe(1);
+    expect(passing.passed).toBe(true);
+
+    const failing = await runtime.evaluateRubric({
+      rubricId: 'go-service',
+      output: 'if err != nil { panic(err) }',
+    });
+    expect(failing.criteria.map((criterion) => criterion.status)).toEqual(['failed', 'skipped', 'failed']);
+    expect(failing.score).toBe(0);
+    expect(failing.passed).toBe(false);
+  });
+
+  it('rejects rubrics without checkable criteria', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeRubric(tempDir, 'broken.yml', 'rubricId: broken\ncriteria:\n  - id: vague\n    description: be good\n');
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    await expect(runtime.listRubrics()).rejects.toThrow('needs mustMatch, mustNotMatch, or mustTouch');
+    await expect(runtime.evaluateRubric({ rubricId: 'missing', output: '' })).rejects.toThrow();
+  });
+});
@@ -0,0 +1,85 @@
+import { mkdirSync } from 'node:fs';
+import { rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { redactSecrets, resolveSecretRedactionConfig } from '../src/secret-redaction.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `secret-redaction-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(join(dir, '.automatosx'), { recursive: true });
+    return dir;
+}
+describe('secret redaction', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('redacts known secret shapes, credential fields, and high-entropy tokens', () => {
+        const config = resolveSecretRedactionConfig({ redaction: { patterns: { ticket: 'INTERNAL-\\d{6}' } } });
+        const value = {
+            note: 'Deploy with password=hunter2!x and sk-abcdefghijklmnop1234 via postgres://app:s3cret@db/app, see INTERNAL-123456.',
+            apiKey: 'plain-words',
+            stray: 'q8Zr2LmX0vTb7KpWn4YcHs1D',
+            safe: 'handleOAuth2CallbackRequestWithPKCE 3f786850e387550fdab836ed7e6dc881de23001b token: rotate weekly',
+            retries: 3,
+        };
+        const { value: redacted, findings } = redactSecrets(value, config);
+        expect(redacted.note).toBe('Deploy with password=[REDACTED:assignment] and [REDACTED:api-key] via postgres://app:[REDACTED:url-password]@db/app, see [REDACTED:ticket].');
+        expect(redacted.apiKey).toBe('[REDACTED:sensitive-field]');
+        expect(redacted.stray).toBe('[REDACTED:high-entropy]');
+        expect(redacted.safe).toBe(value.safe);
+        expect(redacted.retries).toBe(3);
+        expect(findings.map((finding) => finding.rule).sort()).toEqual(['api-key', 'assignment', 'high-entropy', 'sensitive-field', 'ticket', 'url-password']);
+        expect(redactSecrets(redacted, config)).toEqual({ value: redacted, findings: [] });
+        expect(() => resolveSecretRedactionConfig({ redaction: { patterns: { broken: '(' } } })).toThrow('not a valid regular expression');
+    });
+    it('redacts memory before it is stored and reports what was redacted', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        await runtime.storeMemory({ key: 'deploy', namespace: 'ops', value: { steps: 'export API_KEY=sk-abcdefghijklmnop1234', token: 'abc' } });
+        await runtime.storeMemory({ key: 'plain', namespace: 'ops', value: 'Nothing secret here' });
+        expect((await runtime.getMemory('deploy', 'ops'))?.value).toEqual({ steps: 'export API_KEY=[REDACTED:api-key]', token: '[REDACTED:sensitive-field]' });
+        const report = await runtime.queryMemoryRedactions({ namespace: 'ops' });
+        expect(report.enabled).toBe(true);
+        expect(report.matched).toBe(1);
+        expect(report.records[0]).toMatchObject({ target: 'memory', namespace: 'ops', key: 'deploy' });
+        expect(report.rules).toEqual([{ rule: 'api-key', count: 1 }, { rule: 'sensitive-field', count: 1 }]);
+        expect((await runtime.queryMemoryRedactions({ rule: 'jwt' })).matched).toBe(0);
+    });
+    it('redacts bundle imports and trace records, not just direct writes', async () => {
+        const source = createTempDir();
+        const target = createTempDir();
+        tempDirs.push(source, target);
+        await writeFile(join(source, '.automatosx', 'config.json'), JSON.stringify({ memory: { redaction: false } }), 'utf8');
+        const exporter = createSharedRuntimeService({ basePath: source });
+        await exporter.storeMemory({ key: 'deploy', namespace: 'ops', value: 'export API_KEY=sk-abcdefghijklmnop1234' });
+        await exporter.storeSemantic({ key: 'runbook', namespace: 'ops', content: 'rotate ghp_abcdefghijklmnopqrstuvwx yearly', metadata: { token: 'abc' } });
+        const bundlePath = join(source, 'ops.jsonl');
+        await exporter.exportMemory({ outputPath: bundlePath, namespace: 'ops' });
+        const runtime = createSharedRuntimeService({ basePath: target });
+        expect((await runtime.importMemory({ inputPath: bundlePath })).imported).toEqual({ memory: 1, semantic: 1 });
+        expect((await runtime.getMemory('deploy', 'ops'))?.value).toBe('export API_KEY=[REDACTED:api-key]');
+        expect(await runtime.getSemantic('runbook', 'ops')).toMatchObject({ content: 'rotate [REDACTED:github-token] yearly', metadata: { token: '[REDACTED:sensitive-field]' } });
+        await runtime.getStores().traceStore.upsertTrace({
+            traceId: 'trace-secret',
+            workflowId: 'deploy',
+            surface: 'cli',
+            status: 'completed',
+            startedAt: new Date().toISOString(),
+            stepResults: [],
+            metadata: { prompt: 'use sk-abcdefghijklmnop1234' },
+        });
+        expect((await runtime.getTrace('trace-secret'))?.metadata).toEqual({ prompt: 'use [REDACTED:api-key]' });
+        expect((await runtime.queryMemoryRedactions()).records.map((record) => record.target).sort()).toEqual(['memory', 'semantic', 'trace']);
+    });
+    it('stores values unchanged when redaction is turned off', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({ memory: { redaction: false } }), 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        await runtime.storeMemory({ key: 'deploy', value: 'export API_KEY=sk-abcdefghijklmnop1234' });
+        expect((await runtime.getMemory('deploy'))?.value).toBe('export API_KEY=sk-abcdefghijklmnop1234');
+        expect(await runtime.queryMemoryRedactions()).toMatchObject({ enabled: false, matched: 0 });
+    });
+});
@@ -0,0 +1,93 @@
+import { execFile } from 'node:child_process';
+import { mkdirSync } from 'node:fs';
+import { mkdir, readdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { promisify } from 'node:util';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+const execFileAsync = promisify(execFile);
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `session-replay-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+async function configureMockProvider(tempDir) {
+    const scriptPath = join(tempDir, 'mock-provider.mjs');
+    await writeFile(scriptPath, [
+        "let input = '';",
+        "process.stdin.setEncoding('utf8');",
+        "process.stdin.on('data', (chunk) => { input += chunk; });",
+        "process.stdin.on('end', () => {",
+        "  const payload = JSON.parse(input || '{}');",
+        "  process.stdout.write(JSON.stringify({ success: true, provider: payload.provider, model: 'mock', content: 'Use ok.', usage: { inputTokens: 3, outputTokens: 2, totalTokens: 5 } }));",
+        "});",
+    ].join('\n'), 'utf8');
+    process.env.AUTOMATOSX_PROVIDER_EXECUTION_MODE = 'require-real';
+    process.env.AUTOMATOSX_PROVIDER_CLAUDE_CMD = 'node';
+    process.env.AUTOMATOSX_PROVIDER_CLAUDE_ARGS = JSON.stringify([scriptPath]);
+}
+function clearProviderExecutorEnv() {
+    for (const key of Object.keys(process.env)) {
+        if (key.startsWith('AUTOMATOSX_PROVIDER_')) {
+            delete process.env[key];
+        }
+    }
+}
+const PATCH = ['--- a/value.txt', '+++ b/value.txt', '@@ -1 +1 @@', '-todo', '+ok', ''].join('\n');
+describe('session replay', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        clearProviderExecutorEnv();
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('records a session and replays it with provider responses from the recording', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await execFileAsync('git', ['init', '-b', 'main'], { cwd: tempDir });
+        await writeFile(join(tempDir, 'value.txt'), 'todo\n', 'utf8');
+        await configureMockProvider(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const sessionId = 'replay-session';
+        // Recordings keep prompts and file contents unredacted, so nothing is recorded until config asks for it.
+        await runtime.callProvider({ provider: 'claude', prompt: 'Not recorded either', sessionId: 'unrecorded' });
+        expect(await runtime.getSessionRecording({ sessionId: 'unrecorded' })).toEqual([]);
+        await mkdir(join(tempDir, '.automatosx'), { recursive: true });
+        await writeFile(join(tempDir, '.automatosx', 'config.json'), JSON.stringify({ sessions: { record: true } }), 'utf8');
+        const call = await runtime.callProvider({ provider: 'claude', prompt: 'What should value.txt say?', sessionId });
+        expect(call.success).toBe(true);
+        await runtime.reviewPatch({ patch: PATCH, decisions: [{ path: 'value.txt', hunkIndex: 0, decision: 'accept' }], sessionId });
+        await runtime.callProvider({ provider: 'claude', prompt: 'Not recorded' });
+        const events = await runtime.getSessionRecording({ sessionId });
+        expect(events.map((event) => event.kind)).toEqual(['operation', 'provider', 'result', 'operation', 'file', 'result']);
+        expect(events[0]).toMatchObject({ seq: 1, operation: 'call', traceId: call.traceId, request: { provider: 'claude', prompt: 'What should value.txt say?' } });
+        expect(events[4]).toMatchObject({ kind: 'file', path: 'value.txt', action: 'write' });
+        // With no provider configured, only the recording can answer.
+        clearProviderExecutorEnv();
+        const sandboxesBefore = (await readdir(tmpdir())).filter((name) => name.startsWith('ax-replay-'));
+        const replay = await runtime.replaySession({ sessionId });
+        expect(replay).toMatchObject({
+            deterministic: true,
+            providerCalls: { recorded: 1, served: 1 },
+            files: { recorded: 1, replayed: 1 },
+            divergences: [],
+        });
+        expect(replay.operations.map((operation) => [operation.seq, operation.operation, operation.success])).toEqual([[1, 'call', true], [4, 'patch', true]]);
+        expect(await readFile(join(tempDir, 'value.txt'), 'utf8')).toBe('ok\n');
+        expect((await readdir(tmpdir())).filter((name) => name.startsWith('ax-replay-'))).toEqual(sandboxesBefore);
+        const partial = await runtime.replaySession({ sessionId, to: 3 });
+        expect(partial.operations.map((operation) => operation.operation)).toEqual(['call']);
+        expect(partial.files).toEqual({ recorded: 0, replayed: 0 });
+        // Re-running with a different request sends a different prompt; that's a divergence, but the call is still answered as recorded.
+        const recordingPath = join(tempDir, '.automatosx', 'recordings', `${sessionId}.jsonl`);
+        await writeFile(recordingPath, (await readFile(recordingPath, 'utf8')).replace('"prompt":"What should value.txt say?"', '"prompt":"What does value.txt say?"'), 'utf8');
+        const diverged = await runtime.replaySession({ sessionId });
+        expect(diverged.deterministic).toBe(false);
+        expect(diverged.divergences).toEqual([{
+            seq: 2,
+            kind: 'provider',
+            detail: 'claude prompt differs at line 1: recorded "What should value.txt say?", now "What does value.txt say?"',
+        }]);
+        await expect(runtime.replaySession({ sessionId: 'never-recorded' })).rejects.toThrow('Session never-recorded has no recorded runs to replay');
+    });
+});
@@ -0,0 +1,126 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { createServer } from 'node:http';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { decideSloGate, parseSloStatuses } from '../src/slo-gate.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `slo-gate-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+async function startMetricsServer(body) {
+    const server = createServer((_request, response) => {
+        response.writeHead(200, { 'content-type': 'application/json' });
+        response.end(JSON.stringify(body));
+    });
+    await new Promise((resolve) => server.listen(0, '127.0.0.1', resolve));
+    const address = server.address();
+    return { server, url: `http://127.0.0.1:${address.port}/slos` };
+}
+async function writeSloConfig(basePath, slo) {
+    await mkdir(join(basePath, '.automatosx'), { recursive: true });
+    await writeFile(join(basePath, '.automatosx', 'config.json'), `${JSON.stringify({ slo }, null, 2)}\n`, 'utf8');
+}
+describe('slo gate', () => {
+    const tempDirs = [];
+    const servers = [];
+    afterEach(async () => {
+        await Promise.all(servers.splice(0).map((server) => new Promise((resolve) => server.close(resolve))));
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('decides pass, warn, and block from remaining error budget', () => {
+        const { slos, rejected } = parseSloStatuses({
+            slos: [
+                { name: 'checkout-availability', objective: 0.999, errorBudgetRemaining: 0.4 },
+                { name: 'search-latency', errorBudgetRemaining: 0.05 },
+                { name: 'missing-budget' },
+            ],
+        });
+        expect(slos.map((slo) => slo.name)).toEqual(['checkout-availability', 'search-latency']);
+        expect(rejected).toEqual(['missing-budget']);
+        expect(decideSloGate(slos.slice(0, 1), {}).decision).toBe('pass');
+        expect(decideSloGate(slos, {}).decision).toBe('warn');
+        expect(decideSloGate([{ name: 'api', errorBudgetRemaining: 0 }], {})).toMatchObject({
+            decision: 'block',
+            reasons: ['api: error budget exhausted (0% remaining)'],
+        });
+        expect(decideSloGate([{ name: 'api', errorBudgetRemaining: -0.2 }], { mode: 'warn' }).decision).toBe('warn');
+    });
+    it('fails closed when the report is empty or has entries it cannot read', () => {
+        expect(decideSloGate([], {})).toEqual({ decision: 'block', reasons: ['SLO backend reported no SLOs: budget status unknown'] });
+        const { slos, rejected } = parseSloStatuses([{ name: 'api', errorBudgetRemaining: 0.5 }, { errorBudgetRemaining: 0.5 }, 'latency']);
+        expect(rejected).toEqual(['entry 2', 'entry 3']);
+        expect(decideSloGate(slos, {}, rejected)).toEqual({
+            decision: 'block',
+            reasons: ['entry 2: budget status unknown', 'entry 3: budget status unknown'],
+        });
+        expect(decideSloGate(slos, { mode: 'warn' }, rejected).decision).toBe('warn');
+    });
+    it('skips the gate when no SLO endpoint is configured', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const gate = await runtime.checkSloGate({ workflowId: 'release' });
+        expect(gate.decision).toBe('skipped');
+        expect(gate.traceId).toBeUndefined();
+    });
+    it('blocks release when a budget is exhausted and records the decision on the session', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const { server, url } = await startMetricsServer([
+            { name: 'checkout-availability', errorBudgetRemaining: 0 },
+        ]);
+        servers.push(server);
+        await writeSloConfig(tempDir, { endpoint: url });
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const gate = await runtime.checkSloGate({
+            workflowId: 'release',
+            sessionId: 'release-session-001',
+            traceId: 'slo-gate-trace-001',
+        });
+        expect(gate.decision).toBe('block');
+        expect(gate.reasons[0]).toContain('checkout-availability');
+        const traces = await runtime.listTracesBySession('release-session-001');
+        expect(traces.map((trace) => trace.traceId)).toContain('slo-gate-trace-001');
+        expect(traces[0]?.metadata).toMatchObject({ decision: 'block', gatedWorkflowId: 'release' });
+    });
+    it('blocks release runs in the runtime, whatever surface starts them', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const { server, url } = await startMetricsServer({ slos: [] });
+        servers.push(server);
+        await writeSloConfig(tempDir, { endpoint: url });
+        const workflowDir = join(tempDir, 'workflows');
+        await mkdir(workflowDir, { recursive: true });
+        for (const workflowId of ['release', 'build']) {
+            await writeFile(join(workflowDir, `${workflowId}.json`), `${JSON.stringify({
+                workflowId,
+                name: workflowId,
+                version: '1.0.0',
+                steps: [{ stepId: 'plan', type: 'prompt', config: { prompt: `Plan the ${workflowId}` } }],
+            }, null, 2)}\n`, 'utf8');
+        }
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const run = await runtime.runWorkflow({ workflowId: 'release', workflowDir, traceId: 'release-run-001', surface: 'mcp' });
+        expect(run.success).toBe(false);
+        expect(run.stepResults).toEqual([]);
+        expect(run.error).toEqual({ code: 'SLO_GATE_BLOCKED', message: 'Blocked by SLO gate: SLO backend reported no SLOs: budget status unknown' });
+        expect(run.sloGate?.decision).toBe('block');
+        expect((await runtime.getTrace('release-run-001'))?.status).toBe('failed');
+        expect((await runtime.getTrace(run.sloGate.traceId))?.surface).toBe('mcp');
+        const build = await runtime.runWorkflow({ workflowId: 'build', workflowDir });
+        expect(build.success).toBe(true);
+        expect(build.sloGate).toBeUndefined();
+    });
+    it('warns instead of blocking when the metrics backend is unreachable', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writeSloConfig(tempDir, { endpoint: 'http://127.0.0.1:9/slos', timeoutMs: 500 });
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const gate = await runtime.checkSloGate({ workflowId: 'release' });
+        expect(gate.decision).toBe('warn');
+        expect(gate.error?.code).toBe('SLO_BACKEND_UNAVAILABLE');
+    });
+});
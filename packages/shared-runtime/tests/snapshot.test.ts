@@ -0,0 +1,115 @@
+import { execFile } from 'node:child_process';
+import { mkdirSync } from 'node:fs';
+import { mkdir, readdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { promisify } from 'node:util';
+import { afterEach, describe, expect, it } from 'vitest';
+import { resolveSnapshotConfig } from '../src/snapshot.js';
+import { createSharedRuntimeService } from '../src/index.js';
+
+const execFileAsync = promisify(execFile);
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `snapshot-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+async function countObjects(tempDir: string): Promise<number> {
+  const objectsDir = join(tempDir, '.automatosx', 'snapshots', 'objects');
+  let count = 0;
+  for (const prefix of await readdir(objectsDir)) {
+    count += (await readdir(join(objectsDir, prefix))).length;
+  }
+  return count;
+}
+
+describe('workspace snapshots', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('deduplicates blobs and restores a step in place or into another directory', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await execFileAsync('git', ['init', '-b', 'main'], { cwd: tempDir });
+    await mkdir(join(tempDir, 'src'), { recursive: true });
+    await writeFile(join(tempDir, '.gitignore'), 'dist/\n', 'utf8');
+    await writeFile(join(tempDir, 'src', 'a.ts'), 'export const a = 1;\n', 'utf8');
+    await writeFile(join(tempDir, 'src', 'b.ts'), 'export const b = 2;\n', 'utf8');
+    await mkdir(join(tempDir, 'dist'), { recursive: true });
+    await writeFile(join(tempDir, 'dist', 'a.js'), 'ignored\n', 'utf8');
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const first = await runtime.takeSnapshot({ sessionId: 'session-1', label: 'start' });
+    expect(first).toMatchObject({ snapshotId: 'session-1@1', step: 1, fileCount: 3, newObjects: 3 });
+
+    await writeFile(join(tempDir, 'src', 'a.ts'), 'export const a = 10;\n', 'utf8');
+    await writeFile(join(tempDir, 'src', 'c.ts'), 'export const b = 2;\n', 'utf8');
+    const second = await runtime.takeSnapshot({ sessionId: 'session-1' });
+    // c.ts has the same content as b.ts, so only the edited a.ts needs a new blob.
+    expect(second).toMatchObject({ snapshotId: 'session-1@2', fileCount: 4, newObjects: 1 });
+    expect(await countObjects(tempDir)).toBe(4);
+
+    expect((await runtime.listSnapshots()).map((snapshot) => snapshot.snapshotId)).toEqual(['session-1@1', 'session-1@2']);
+    const latest = await runtime.getSnapshot({ snapshotId: 'sess' });
+    expect(latest.files.map((file) => file.path)).toEqual(['.gitignore', 'src/a.ts', 'src/b.ts', 'src/c.ts']);
+    expect((await runtime.readSnapshotFile({ snapshotId: 'session-1@1', path: 'src/a.ts' })).toString('utf8')).toBe('export const a = 1;\n');
+    await expect(runtime.getSnapshot({ snapshotId: 'session-1@7' })).rejects.toThrow('has no snapshot at step 7');
+
+    const extracted = join(tempDir, 'dist', 'step-1');
+    const into = await runtime.checkoutSnapshot({ snapshotId: 'session-1@1', targetDir: extracted, paths: ['src'] });
+    expect(into).toMatchObject({ written: ['src/a.ts', 'src/b.ts'], removed: [], safetySnapshotId: undefined });
+    expect(await readFile(join(extracted, 'src', 'a.ts'), 'utf8')).toBe('export const a = 1;\n');
+
+    const restored = await runtime.checkoutSnapshot({ snapshotId: 'session-1@1' });
+    expect(restored.removed).toEqual(['src/c.ts']);
+    expect(restored.safetySnapshotId).toBe('pre-checkout@1');
+    expect(await readFile(join(tempDir, 'src', 'a.ts'), 'utf8')).toBe('export const a = 1;\n');
+    await expect(readFile(join(tempDir, 'src', 'c.ts'), 'utf8')).rejects.toThrow();
+    expect(await readFile(join(tempDir, 'dist', 'a.js'), 'utf8')).toBe('ignored\n');
+
+    // The pre-checkout snapshot undoes the restore.
+    await runtime.checkoutSnapshot({ snapshotId: 'pre-checkout@1' });
+    expect(await readFile(join(tempDir, 'src', 'c.ts'), 'utf8')).toBe('export const b = 2;\n');
+  });
+
+  it('snapshots after workflow steps when enabled in config', async () => {
+    expect(resolveSnapshotConfig(undefined)).toEqual({ enabled: false, everySteps: 1, maxFileBytes: 2 * 1024 * 1024 });
+    expect(resolveSnapshotConfig({ enabled: true, everySteps: 0, maxFileBytes: 16 })).toEqual({ enabled: true, everySteps: 1, maxFileBytes: 16 });
+
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, '.automatosx'), { recursive: true });
+    await writeFile(join(tempDir, '.automatosx', 'config.json'), `${JSON.stringify({ snapshots: { enabled: true } })}\n`, 'utf8');
+    await mkdir(join(tempDir, 'workflows'), { recursive: true });
+    await writeFile(join(tempDir, 'workflows', 'two-steps.json'), `${JSON.stringify({
+      workflowId: 'two-steps',
+      name: 'Two Steps',
+      version: '1.0.0',
+      steps: [
+        { stepId: 'plan', type: 'prompt', config: { prompt: 'Plan the change.' } },
+        { stepId: 'apply', type: 'prompt', config: { prompt: 'Apply the change.' } },
+      ],
+    }, null, 2)}\n`, 'utf8');
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    await runtime.runWorkflow({
+      workflowId: 'two-steps',
+      workflowDir: join(tempDir, 'workflows'),
+      traceId: 'snapshot-trace',
+      sessionId: 'session-wf',
+      basePath: tempDir,
+    });
+
+    const snapshots = await runtime.listSnapshots({ sessionId: 'session-wf' });
+    expect(snapshots.map((snapshot) => [snapshot.snapshotId, snapshot.stepId, snapshot.label])).toEqual([
+      ['session-wf@1', 'plan', 'two-steps: plan'],
+      ['session-wf@2', 'apply', 'two-steps: apply'],
+    ]);
+    expect(snapshots[0]).toMatchObject({ traceId: 'snapshot-trace', treeHash: snapshots[1]?.treeHash });
+    expect((await runtime.getTrace('snapshot-trace'))?.metadata?.snapshotIds).toEqual(['session-wf@1', 'session-wf@2']);
+  });
+});
@@ -0,0 +1,169 @@
+import { execFileSync } from 'node:child_process';
+import { existsSync, mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { createStateStore } from '@defai.digital/state-store';
+import { afterEach, beforeEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { decryptPayload } from '../src/state-sync.js';
+import { createSyncPrivacyFilter } from '../src/sync-privacy.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `state-sync-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const GIT_IDENTITY_ENV = {
+    GIT_AUTHOR_NAME: 'AutomatosX Test',
+    GIT_AUTHOR_EMAIL: 'test@example.com',
+    GIT_COMMITTER_NAME: 'AutomatosX Test',
+    GIT_COMMITTER_EMAIL: 'test@example.com',
+};
+async function configureSync(basePath, remoteUrl, privacy) {
+    await mkdir(join(basePath, '.automatosx'), { recursive: true });
+    await writeFile(join(basePath, '.automatosx', 'config.json'), JSON.stringify({ sync: { remote: { type: 'git', url: remoteUrl }, ...(privacy !== undefined ? { privacy } : {}) } }), 'utf8');
+}
+describe('state sync', () => {
+    const tempDirs = [];
+    const savedEnv = {};
+    beforeEach(() => {
+        for (const [key, value] of Object.entries({ ...GIT_IDENTITY_ENV, AX_SYNC_PASSPHRASE: 'correct horse battery staple' })) {
+            savedEnv[key] = process.env[key];
+            process.env[key] = value;
+        }
+    });
+    afterEach(async () => {
+        for (const [key, value] of Object.entries(savedEnv)) {
+            if (value === undefined) {
+                delete process.env[key];
+            }
+            else {
+                process.env[key] = value;
+            }
+        }
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('carries memory and workflow definitions between machines through an encrypted git remote', async () => {
+        const remote = createTempDir();
+        const desktop = createTempDir();
+        const laptop = createTempDir();
+        tempDirs.push(remote, desktop, laptop);
+        execFileSync('git', ['init', '-q', '--bare', remote], { stdio: 'ignore' });
+        await configureSync(desktop, remote);
+        await configureSync(laptop, remote);
+        const desktopRuntime = createSharedRuntimeService({ basePath: desktop });
+        await desktopRuntime.storeMemory({ key: 'release-plan', namespace: 'notes', value: { target: 'v14.1' } });
+        await mkdir(join(desktop, '.automatosx', 'workflows', 'artifacts'), { recursive: true });
+        await writeFile(join(desktop, '.automatosx', 'workflows', 'triage.yaml'), 'workflowId: triage\n', 'utf8');
+        await writeFile(join(desktop, '.automatosx', 'workflows', 'artifacts', 'cache.json'), '{}', 'utf8');
+        const first = await desktopRuntime.syncState();
+        expect(first.pushed).toEqual(expect.arrayContaining(['memory:notes:release-plan', 'file:workflows/triage.yaml']));
+        const blob = execFileSync('git', ['--git-dir', remote, 'show', 'automatosx-sync:automatosx-state.enc'], { encoding: 'utf8' });
+        expect(blob).not.toContain('release-plan');
+        expect(blob).not.toContain('triage');
+        const laptopRuntime = createSharedRuntimeService({ basePath: laptop });
+        const pulled = await laptopRuntime.syncState();
+        expect(pulled.pulled).toEqual(expect.arrayContaining(['memory:notes:release-plan', 'file:workflows/triage.yaml']));
+        expect((await laptopRuntime.getMemory('release-plan', 'notes'))?.value).toEqual({ target: 'v14.1' });
+        expect(await readFile(join(laptop, '.automatosx', 'workflows', 'triage.yaml'), 'utf8')).toBe('workflowId: triage\n');
+        expect(existsSync(join(laptop, '.automatosx', 'workflows', 'artifacts', 'cache.json'))).toBe(false);
+        await laptopRuntime.deleteMemory('release-plan', 'notes');
+        await laptopRuntime.syncState();
+        expect((await desktopRuntime.syncState()).deletedLocally).toEqual(['memory:notes:release-plan']);
+        expect(await desktopRuntime.getMemory('release-plan', 'notes')).toBeUndefined();
+    });
+    it('resolves concurrent edits newest-wins and records the losing side', async () => {
+        const remote = createTempDir();
+        const desktop = createTempDir();
+        const laptop = createTempDir();
+        tempDirs.push(remote, desktop, laptop);
+        execFileSync('git', ['init', '-q', '--bare', remote], { stdio: 'ignore' });
+        await configureSync(desktop, remote);
+        await configureSync(laptop, remote);
+        const desktopRuntime = createSharedRuntimeService({ basePath: desktop });
+        const laptopRuntime = createSharedRuntimeService({ basePath: laptop });
+        await desktopRuntime.storeMemory({ key: 'focus', value: 'initial' });
+        await desktopRuntime.syncState();
+        await laptopRuntime.syncState();
+        await laptopRuntime.storeMemory({ key: 'focus', value: 'laptop edit' });
+        await new Promise((resolve) => setTimeout(resolve, 10));
+        await desktopRuntime.storeMemory({ key: 'focus', value: 'desktop edit' });
+        await laptopRuntime.syncState();
+        const result = await desktopRuntime.syncState();
+        expect(result.conflicts).toEqual([expect.objectContaining({ id: 'memory::focus', winner: 'local' })]);
+        expect(result.conflictLogPath).toBeDefined();
+        expect(await readFile(result.conflictLogPath, 'utf8')).toContain('laptop edit');
+        await laptopRuntime.syncState();
+        expect((await laptopRuntime.getMemory('focus'))?.value).toBe('desktop edit');
+    });
+    it('generalizes or strips identifying details from shared memory', () => {
+        const context = { basePath: '/home/alice/work/app', homeDir: '/home/alice', username: 'alice', hostname: 'alice-laptop' };
+        const note = {
+            path: '/home/alice/work/app/src/auth.ts',
+            log: 'alice ran /home/alice/.cache/ax/run.sh on alice-laptop (10.0.4.12), mail alice@acme.io, see /Users/bob/notes.md and /opt/tools/bin ticket ACME-42',
+            attempts: 3,
+        };
+        const generalize = createSyncPrivacyFilter({ mode: 'generalize', redact: [] }, context);
+        expect(generalize(note)).toEqual({
+            path: '<workspace>/src/auth.ts',
+            log: '<user> ran ~/.cache/ax/run.sh on <host> (<ip>), mail <user>@acme.io, see ~/notes.md and /opt/tools/bin ticket ACME-42',
+            attempts: 3,
+        });
+        expect(generalize(generalize(note))).toEqual(generalize(note));
+        const strip = createSyncPrivacyFilter({ mode: 'strip', redact: ['ACME-[0-9]+'] }, context);
+        expect(strip(note)).toMatchObject({
+            path: '<workspace>/src/auth.ts',
+            log: '<user> ran <path> on <host> (<ip>), mail <email>, see <path> and <path> ticket <redacted>',
+        });
+    });
+    it('pushes rewritten memory without treating it as a local change', async () => {
+        const remote = createTempDir();
+        const workspace = createTempDir();
+        tempDirs.push(remote, workspace);
+        execFileSync('git', ['init', '-q', '--bare', remote], { stdio: 'ignore' });
+        await configureSync(workspace, remote, 'generalize');
+        const runtime = createSharedRuntimeService({ basePath: workspace });
+        expect((await runtime.getSyncStatus()).privacy).toBe('generalize');
+        await runtime.storeMemory({ key: 'flaky-test', value: `Fails in ${join(workspace, 'src', 'auth.test.ts')}` });
+        const first = await runtime.syncState();
+        expect(first.scrubbed).toBe(1);
+        const blob = execFileSync('git', ['--git-dir', remote, 'show', 'automatosx-sync:automatosx-state.enc'], { encoding: 'utf8' });
+        const shared = JSON.parse(decryptPayload(blob, 'correct horse battery staple'));
+        expect(shared.machine).toBe('<host>');
+        expect(shared.memory[0].value).toBe('Fails in <workspace>/src/auth.test.ts');
+        expect(shared.memory[0].updatedAt).toMatch(/T00:00:00\.000Z$/);
+        expect((await runtime.getMemory('flaky-test'))?.value).toContain(workspace);
+        const second = await runtime.syncState();
+        expect(second.pushed).toEqual([]);
+        expect(second.pulled).toEqual([]);
+    });
+    it('applies sync.privacy to every write on a shared Postgres backend', async () => {
+        const workspace = createTempDir();
+        tempDirs.push(workspace);
+        await mkdir(join(workspace, '.automatosx'), { recursive: true });
+        await writeFile(join(workspace, '.automatosx', 'config.json'), JSON.stringify({ sync: { privacy: 'generalize' } }), 'utf8');
+        // Keeps what reaches the database; nothing is read back.
+        const written = [];
+        const query = async (text, values = []) => {
+            if (/^\s*INSERT INTO ax_(memory|semantic)_items/.test(text)) {
+                written.push(values);
+            }
+            return { rows: [], rowCount: 0 };
+        };
+        const pool = { query, connect: async () => ({ query, release: () => undefined }), end: async () => {} };
+        const runtime = createSharedRuntimeService({ basePath: workspace, stateStore: createStateStore({ basePath: workspace, backend: 'postgres', postgres: { pool } }) });
+        await runtime.storeMemory({ key: 'flaky-test', value: `Fails in ${join(workspace, 'src', 'auth.test.ts')}` });
+        await runtime.storeSemantic({ key: 'owner', content: 'Ask ops@acme.io', metadata: { path: join(workspace, 'README.md') } });
+        expect(written.map((values) => values[2])).toEqual([JSON.stringify('Fails in <workspace>/src/auth.test.ts'), 'Ask <user>@acme.io']);
+        expect(JSON.parse(written[1][5])).toEqual({ path: '<workspace>/README.md' });
+    });
+    it('refuses to sync without a passphrase', async () => {
+        const remote = createTempDir();
+        const workspace = createTempDir();
+        tempDirs.push(remote, workspace);
+        await configureSync(workspace, remote);
+        delete process.env.AX_SYNC_PASSPHRASE;
+        const runtime = createSharedRuntimeService({ basePath: workspace });
+        expect((await runtime.getSyncStatus()).passphraseSet).toBe(false);
+        await expect(runtime.syncState()).rejects.toThrow('AX_SYNC_PASSPHRASE');
+    });
+});
@@ -0,0 +1,383 @@
+import { execFile } from 'node:child_process';
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { promisify } from 'node:util';
+import { afterEach, describe, expect, it } from 'vitest';
+import { diffFileStructure, extractDeclarations, summarizeStructuralDiff } from '../src/structural-diff.js';
+import { createSharedRuntimeService } from '../src/index.js';
+const execFileAsync = promisify(execFile);
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `structural-diff-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const BEFORE = [
+    "import { join } from 'node:path';",
+    '',
+    '// Greets.',
+    'export function greet(name: string): string {',
+    "  return 'Hello ' + name;",
+    '}',
+    '',
+    'export const add = (a: number, b: number) => a + b;',
+    '',
+    'export interface Options {',
+    '  verbose: boolean;',
+    '}',
+    '',
+    'export class Greeter {',
+    '  private count = 0;',
+    '',
+    '  greet(name: string): string {',
+    '    this.count += 1;',
+    '    return name;',
+    '  }',
+    '',
+    '  reset() {',
+    '    this.count = 0;',
+    '  }',
+    '}',
+    '',
+    'function unused() {',
+    '  return 1;',
+    '}',
+    '',
+].join('\n');
+const AFTER = [
+    "import { join } from 'node:path';",
+    '',
+    '// Greets warmly.',
+    'export function greet(name: string): string {',
+    '  return "Hello " + name',
+    '}',
+    '',
+    'export const add = (a: number, b: number, c = 0) => a + b + c;',
+    '',
+    'export interface Options {',
+    '  verbose: boolean;',
+    '  color?: boolean;',
+    '}',
+    '',
+    'export class Greeter {',
+    '  private count = 0;',
+    '',
+    '  greet(name: string): string {',
+    '    this.count += 2;',
+    '    return name;',
+    '  }',
+    '',
+    '  async stop(): Promise<void> {}',
+    '}',
+    '',
+    'export type Mode =',
+    "  | 'a'",
+    "  | 'b';",
+    '',
+].join('\n');
+describe('structural diff', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('reports declaration changes and ignores formatting-only edits', () => {
+        const changes = diffFileStructure(BEFORE, AFTER, 'src/greet.ts');
+        expect(changes.map((change) => [change.change, change.kind, change.name])).toEqual([
+            ['signature-changed', 'function', 'add'],
+            ['signature-changed', 'interface', 'Options'],
+            ['body-changed', 'method', 'Greeter.greet'],
+            ['added', 'method', 'Greeter.stop'],
+            ['added', 'type', 'Mode'],
+            ['removed', 'method', 'Greeter.reset'],
+            ['removed', 'function', 'unused'],
+        ]);
+        expect(changes[0]).toMatchObject({
+            before: 'export const add=(a:number,b:number)=>',
+            after: 'export const add=(a:number,b:number,c=0)=>',
+            line: 8,
+        });
+        const goBefore = 'package main\n\nfunc (s *Server) Start(ctx context.Context) error {\n\treturn nil\n}\n\nfunc helper() {}\n';
+        const goAfter = goBefore.replace('return nil', 'return s.run(ctx)').replace('func helper() {}', 'func helper(n int) {}');
+        const goChanges = diffFileStructure(goBefore, goAfter, 'main.go');
+        expect(summarizeStructuralDiff([{ path: 'main.go', status: 'modified', changes: goChanges }]))
+            .toBe('- main.go: changed body of method Server.Start (exported); changed signature of function helper');
+    });
+    it('extracts C and C++ functions, types, macros, and variables', () => {
+        const header = [
+            '#ifndef BUF_H',
+            '#define BUF_H',
+            '',
+            '#include <stddef.h>',
+            '',
+            '#define BUF_MAX(a, b) \\',
+            '  ((a) > (b) ? (a) : (b))',
+            '',
+            'typedef struct buffer {',
+            '  char *data;',
+            '  size_t len;',
+            '} buffer_t;',
+            '',
+            'typedef void (*buf_free_fn)(void *);',
+            '',
+            'enum mode { MODE_READ, MODE_WRITE };',
+            '',
+            'size_t buf_len(const buffer_t *buf);',
+            '',
+            '#endif',
+            '',
+        ].join('\n');
+        const source = [
+            '#include "buf.h"',
+            '',
+            'static int',
+            'grow(buffer_t *buf, size_t n)',
+            '{',
+            '  return n > 0;',
+            '}',
+            '',
+            'int counter = 0;',
+            '',
+            'size_t buf_len(const buffer_t *buf);',
+            '',
+            'size_t buf_len(const buffer_t *buf) {',
+            '  /* len { */',
+            '  return buf->len;',
+            '}',
+            '',
+        ].join('\n');
+        const cpp = ['class Ring {', 'public:', '  int size() const;', '};', '', 'int Ring::size() const {', '  return 0;', '}', ''].join('\n');
+        const describeDeclarations = (content, path) =>
+            extractDeclarations(content, path).map((declaration) => `${declaration.line}-${declaration.endLine} ${declaration.kind} ${declaration.name}${declaration.exported ? ' exported' : ''}`);
+        expect(describeDeclarations(header, 'buf.h')).toEqual([
+            '2-2 macro BUF_H exported',
+            '6-7 macro BUF_MAX exported',
+            '9-12 type buffer_t exported',
+            '14-14 type buf_free_fn exported',
+            '16-16 enum mode exported',
+            '18-18 function buf_len exported',
+        ]);
+        expect(describeDeclarations(source, 'buf.c')).toEqual([
+            '3-7 function grow',
+            '9-9 variable counter exported',
+            '13-16 function buf_len exported',
+        ]);
+        expect(describeDeclarations(cpp, 'ring.cpp')).toEqual(['1-4 class Ring', '6-8 method Ring.size exported']);
+        expect(diffFileStructure(source, source.replace('return n > 0;', 'return n > 1;'), 'buf.c')).toMatchObject([
+            { change: 'body-changed', kind: 'function', name: 'grow' },
+        ]);
+    });
+    it('diffs git refs and the working tree, and feeds PR reviews', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await execFileAsync('git', ['init', '-b', 'main'], { cwd: tempDir });
+        await execFileAsync('git', ['config', 'user.email', 'test@example.com'], { cwd: tempDir });
+        await execFileAsync('git', ['config', 'user.name', 'Test User'], { cwd: tempDir });
+        await mkdir(join(tempDir, 'src'), { recursive: true });
+        await writeFile(join(tempDir, 'src', 'greet.ts'), BEFORE, 'utf8');
+        await writeFile(join(tempDir, 'README.md'), '# Greeter\n', 'utf8');
+        await execFileAsync('git', ['add', '-A'], { cwd: tempDir });
+        await execFileAsync('git', ['commit', '-m', 'initial'], { cwd: tempDir });
+        await execFileAsync('git', ['checkout', '-b', 'feature/greeter'], { cwd: tempDir });
+        await writeFile(join(tempDir, 'src', 'greet.ts'), AFTER, 'utf8');
+        await writeFile(join(tempDir, 'src', 'extra.ts'), 'export function extra() {}\n', 'utf8');
+        await writeFile(join(tempDir, 'README.md'), '# Greeter\n\nNow with modes.\n', 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const worktree = await runtime.structuralDiff({ paths: ['src'] });
+        expect(worktree.files.map((file) => file.path)).toEqual(['src/greet.ts']);
+        expect(worktree.skipped).toEqual([]);
+        await execFileAsync('git', ['add', '-A'], { cwd: tempDir });
+        await execFileAsync('git', ['commit', '-m', 'greeter modes'], { cwd: tempDir });
+        const review = await runtime.reviewPullRequest({ base: 'main', head: 'HEAD' });
+        expect(review.structuralChanges.map((file) => [file.path, file.status])).toEqual([
+            ['src/extra.ts', 'added'],
+            ['src/greet.ts', 'modified'],
+        ]);
+        expect(review.structuralSummary).toContain('- src/extra.ts: added function extra (exported)');
+    });
+    it('extracts Java and Kotlin types, members, and annotations', () => {
+        const java = [
+            'package com.acme.users;',
+            '',
+            '@RestController',
+            '@RequestMapping(',
+            '    value = "/users",',
+            '    produces = "application/json")',
+            'public class UserController implements Api {',
+            '    private final UserService service;',
+            '',
+            '    public UserController(UserService service) {',
+            '        this.service = service;',
+            '    }',
+            '',
+            '    @GetMapping("/{id}") public User get(@PathVariable String id) {',
+            '        return service.find(id);',
+            '    }',
+            '',
+            '    void helper() {}',
+            '}',
+            '',
+            'interface Api {',
+            '    String version();',
+            '}',
+            '',
+            'record Point(int x, int y) {}',
+            '',
+        ].join('\n');
+        const kotlin = [
+            'typealias UserId = String',
+            '',
+            '@Service',
+            'class UserService(private val repo: Repo) {',
+            '    val cache = mutableMapOf<String, User>()',
+            '',
+            '    fun find(id: UserId): User? = repo.get(id)',
+            '',
+            '    private fun log(message: String) {',
+            '        println(message)',
+            '    }',
+            '',
+            '    companion object {',
+            '        fun create() = UserService(MemoryRepo())',
+            '    }',
+            '}',
+            '',
+            'data class User(val id: String)',
+            '',
+            'internal fun String.slugify(): String =',
+            '    lowercase().replace(" ", "-")',
+            '',
+        ].join('\n');
+        const describeDeclarations = (content, path) =>
+            extractDeclarations(content, path).map((declaration) => `${declaration.line}-${declaration.endLine} ${declaration.kind} ${declaration.name}${declaration.exported ? ' exported' : ''}${declaration.annotations === undefined ? '' : ` @${declaration.annotations.join(' @')}`}`);
+        expect(describeDeclarations(java, 'UserController.java')).toEqual([
+            '7-19 class UserController exported @RestController @RequestMapping',
+            '10-12 method UserController.UserController exported',
+            '14-16 method UserController.get exported @GetMapping',
+            '18-18 method UserController.helper',
+            '21-23 interface Api',
+            '22-22 method Api.version',
+            '25-25 class Point',
+        ]);
+        expect(describeDeclarations(kotlin, 'UserService.kt')).toEqual([
+            '1-1 type UserId exported',
+            '4-16 class UserService exported @Service',
+            '7-7 method UserService.find exported',
+            '9-11 method UserService.log',
+            '14-14 method UserService.create exported',
+            '18-18 class User exported',
+            '20-21 function slugify',
+        ]);
+        // Properties stay in the class body; expression bodies are bodies, not signatures.
+        expect(diffFileStructure(kotlin, kotlin.replace('repo.get(id)', 'repo.load(id)').replace('mutableMapOf', 'hashMapOf'), 'UserService.kt')).toMatchObject([
+            { change: 'body-changed', kind: 'class', name: 'UserService' },
+            { change: 'body-changed', kind: 'method', name: 'UserService.find' },
+        ]);
+    });
+    it('extracts Rust items, trait and impl methods, macros, and attributes', () => {
+        const rust = [
+            '#[derive(Debug, Clone)]',
+            'pub struct Stack<T> {',
+            '    items: Vec<T>,',
+            '}',
+            '',
+            'pub trait Peek {',
+            '    type Item;',
+            "    fn peek<'a>(&'a self) -> Option<&'a Self::Item>;",
+            '}',
+            '',
+            'impl<T: Clone> Stack<T> {',
+            '    pub fn push(&mut self, item: T) {',
+            '        self.items.push(item);',
+            '    }',
+            '',
+            "    pub(crate) fn pop(&mut self) -> Option<T> { let open = '{'; self.items.pop() }",
+            '}',
+            '',
+            'impl<T> Peek for Stack<T> {',
+            '    type Item = T;',
+            "    fn peek<'a>(&'a self) -> Option<&'a T> {",
+            '        self.items.last()',
+            '    }',
+            '}',
+            '',
+            '#[macro_export]',
+            'macro_rules! stack {',
+            '    ($($x:expr),*) => { Stack { items: vec![$($x),*] } };',
+            '}',
+            '',
+            'const LIMIT: usize = 64;',
+            '',
+            'mod internal {',
+            '    pub enum Mode { Fifo, Lifo }',
+            '}',
+            '',
+        ].join('\n');
+        const describeDeclarations = (content) => extractDeclarations(content, 'src/stack.rs').map((declaration) => `${declaration.line}-${declaration.endLine} ${declaration.kind} ${declaration.name}${declaration.exported ? ' exported' : ''}${declaration.annotations === undefined ? '' : ` #${declaration.annotations.join(' #')}`}`);
+        expect(describeDeclarations(rust)).toEqual([
+            '2-4 type Stack exported #derive',
+            '6-9 interface Peek exported',
+            '8-8 method Peek.peek exported',
+            '12-14 method Stack.push exported',
+            '16-16 method Stack.pop',
+            '21-23 method Stack.peek exported',
+            '27-29 macro stack exported #macro_export',
+            '31-31 variable LIMIT',
+            '34-34 enum Mode',
+        ]);
+        expect(extractDeclarations(rust, 'src/stack.rs')[0]?.signature).toBe('#[derive(Debug,Clone)]pub struct Stack<T>{items:Vec<T>}');
+        // Lifetimes don't open char literals, so the method body still closes where it should.
+        expect(diffFileStructure(rust, rust.replace('self.items.last()', 'self.items.first()'), 'src/stack.rs')).toMatchObject([
+            { change: 'body-changed', kind: 'method', name: 'Stack.peek' },
+        ]);
+    });
+    it('extracts Python classes, methods, decorators, and dataclass fields', () => {
+        const python = [
+            'from dataclasses import dataclass',
+            '',
+            'RETRIES: int = 3',
+            '',
+            '',
+            '@dataclass(frozen=True)',
+            'class Point:',
+            '    """A point { with braces }."""',
+            '    x: float',
+            '    y: float = 0.0',
+            '',
+            '    def scaled(self, factor: float) -> "Point":',
+            '        return Point(self.x * factor, self.y * factor)  # a comment',
+            '',
+            '',
+            'class _Store:',
+            '    def __init__(self, path):',
+            '        self.path = path',
+            '',
+            '    async def load(',
+            '        self,',
+            '        key: str,',
+            '    ) -> bytes | None:',
+            '        return None',
+            '',
+            '',
+            '@cache',
+            'def area(width, height): return width * height // 1',
+            '',
+        ].join('\n');
+        const describeDeclarations = (content) => extractDeclarations(content, 'geometry.py').map((declaration) => `${declaration.line}-${declaration.endLine} ${declaration.kind} ${declaration.name}${declaration.exported ? ' exported' : ''}${declaration.annotations === undefined ? '' : ` @${declaration.annotations.join(' @')}`}`);
+        expect(describeDeclarations(python)).toEqual([
+            '3-3 variable RETRIES exported',
+            '7-13 class Point exported @dataclass',
+            '12-13 method Point.scaled exported',
+            '16-24 class _Store',
+            '17-18 method _Store.__init__',
+            '20-24 method _Store.load',
+            '28-28 function area exported @cache',
+        ]);
+        // Dataclass fields are the constructor's parameters, so changing one changes the signature.
+        expect(diffFileStructure(python, python.replace('y: float = 0.0', 'y: float = 1.0').replace('# a comment', '# another'), 'geometry.py')).toMatchObject([
+            { change: 'signature-changed', kind: 'class', name: 'Point' },
+        ]);
+        expect(diffFileStructure(python, python.replace('height // 1', 'height // 2'), 'geometry.py')).toMatchObject([
+            { change: 'body-changed', kind: 'function', name: 'area' },
+        ]);
+    });
+});
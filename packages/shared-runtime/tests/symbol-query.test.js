@@ -0,0 +1,238 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { goBuildConstraint, matchesBuildConstraint, parseGoPlatform } from '../src/build-constraints.js';
+import { parseSymbolQuery } from '../src/symbol-query.js';
+import { createSharedRuntimeService } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `symbol-query-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const SERVER_GO = [
+    'package server',
+    '',
+    'type Server struct {',
+    '\taddr string',
+    '}',
+    '',
+    'func (s *Server) Start() error {',
+    '\treturn nil',
+    '}',
+    '',
+    'func (s *Server) startWorkers() {}',
+    '',
+    'func StartAll(servers []*Server) {}',
+    '',
+].join('\n');
+const CLIENT_TS = [
+    'export class Client {',
+    '  async start(): Promise<void> {',
+    '    return;',
+    '  }',
+    '',
+    '  private reconnect(): void {}',
+    '}',
+    '',
+    'export interface ClientOptions {',
+    '  retries: number;',
+    '}',
+    '',
+    'const startDelayMs = 50;',
+    '',
+].join('\n');
+describe('symbol queries', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('parses fields, negation, quoting, and bare words', () => {
+        expect(parseSymbolQuery('kind:func receiver:Server name:~Start exported:true')).toEqual([
+            { field: 'kind', value: 'func', negated: false },
+            { field: 'receiver', value: 'Server', negated: false },
+            { field: 'name', value: '~Start', negated: false },
+            { field: 'exported', value: 'true', negated: false },
+        ]);
+        expect(parseSymbolQuery('-path:"vendor/*" reconnect')).toEqual([
+            { field: 'path', value: 'vendor/*', negated: true },
+            { field: 'name', value: '~reconnect', negated: false },
+        ]);
+        expect(() => parseSymbolQuery('owner:alice')).toThrow(/Unknown symbol query field "owner"/);
+        expect(parseSymbolQuery('kind:macro')).toEqual([{ field: 'kind', value: 'macro', negated: false }]);
+        expect(() => parseSymbolQuery('kind:trait')).toThrow(/Unknown symbol kind "trait"/);
+        expect(() => parseSymbolQuery('exported:yes')).toThrow(/expects true or false/);
+    });
+    it('finds matching declarations across TS/JS and Go files in the workspace', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'server'), { recursive: true });
+        await mkdir(join(tempDir, 'src'), { recursive: true });
+        await writeFile(join(tempDir, 'server', 'server.go'), SERVER_GO, 'utf8');
+        await writeFile(join(tempDir, 'src', 'client.ts'), CLIENT_TS, 'utf8');
+        await writeFile(join(tempDir, 'README.md'), 'func (s *Server) Start() in prose\n', 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const find = async (query, options = {}) =>
+            (await runtime.findSymbols({ query, ...options })).symbols.map((symbol) => `${symbol.path}:${symbol.line} ${symbol.receiver === undefined ? '' : `${symbol.receiver}.`}${symbol.name}`);
+        expect(await find('kind:func receiver:Server name:~Start exported:true')).toEqual(['server/server.go:7 Server.Start']);
+        expect(await find('kind:method name:~^start')).toEqual([
+            'server/server.go:7 Server.Start',
+            'server/server.go:11 Server.startWorkers',
+            'src/client.ts:2 Client.start',
+        ]);
+        expect(await find('kind:func -kind:method start')).toEqual(['server/server.go:13 StartAll']);
+        expect(await find('kind:type lang:go')).toEqual(['server/server.go:3 Server']);
+        expect(await find('kind:type lang:ts')).toEqual(['src/client.ts:9 ClientOptions']);
+        expect(await find('exported:false', { paths: ['src'] })).toEqual(['src/client.ts:6 Client.reconnect', 'src/client.ts:13 startDelayMs']);
+        expect(await find('name:Client*')).toEqual(['src/client.ts:1 Client', 'src/client.ts:9 ClientOptions']);
+        expect(await find('name:Client.start')).toEqual(['src/client.ts:2 Client.start']);
+        const limited = await runtime.findSymbols({ query: 'start', limit: 2 });
+        expect(limited).toMatchObject({ truncated: true, scannedFiles: 1 });
+        expect(limited.symbols).toHaveLength(2);
+    });
+    it('queries Java and Kotlin declarations by language and annotation', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'api'), { recursive: true });
+        await writeFile(join(tempDir, 'api', 'OrderController.java'), [
+            '@RestController',
+            'public class OrderController {',
+            '    @GetMapping("/orders")',
+            '    public List<Order> list() { return List.of(); }',
+            '',
+            '    @PostMapping("/orders")',
+            '    public Order create(@RequestBody Order order) { return order; }',
+            '}',
+            '',
+        ].join('\n'), 'utf8');
+        await writeFile(join(tempDir, 'api', 'OrderService.kt'), '@Service\nclass OrderService {\n    fun total(): Int = 0\n}\n', 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const names = async (query) => (await runtime.findSymbols({ query })).symbols.map((symbol) => `${symbol.receiver === undefined ? '' : `${symbol.receiver}.`}${symbol.name}`);
+        expect(await names('annotation:RestController')).toEqual(['OrderController']);
+        expect(await names('annotation:*Mapping')).toEqual(['OrderController.list', 'OrderController.create']);
+        expect(await names('lang:kotlin')).toEqual(['OrderService', 'OrderService.total']);
+        expect(await names('lang:java -annotation:~mapping')).toEqual(['OrderController']);
+        expect((await runtime.findSymbols({ query: 'annotation:@Service' })).symbols[0]).toMatchObject({ name: 'OrderService', annotations: ['Service'], kind: 'class' });
+    });
+    it('tags Go symbols with build constraints and selects them by platform', async () => {
+        expect(goBuildConstraint('//go:build linux || darwin\n\npackage x\n', 'poll_arm64.go')).toBe('(linux || darwin) && arm64');
+        expect(goBuildConstraint('// +build linux,cgo darwin\n\npackage x\n', 'poll.go')).toBe('linux && cgo || darwin');
+        expect(goBuildConstraint('package x\n\n//go:build ignore\n', 'export_test.go')).toBeUndefined();
+        expect(goBuildConstraint('package x\n', 'sys_windows_amd64_test.go')).toBe('windows && amd64');
+        expect(matchesBuildConstraint('unix && !(arm64 || integration)', parseGoPlatform('darwin'))).toBe(true);
+        expect(matchesBuildConstraint('unix && !(arm64 || integration)', parseGoPlatform('linux/amd64+integration'))).toBe(false);
+        expect(matchesBuildConstraint('linux && go1.21', parseGoPlatform('android/arm64'))).toBe(true);
+        expect(() => parseSymbolQuery('platform:beos')).toThrow(/Invalid platform "beos"/);
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'poll'), { recursive: true });
+        await writeFile(join(tempDir, 'poll', 'poll.go'), 'package poll\n\nfunc Wait() {}\n', 'utf8');
+        await writeFile(join(tempDir, 'poll', 'poll_linux.go'), 'package poll\n\nfunc epoll() {}\n', 'utf8');
+        await writeFile(join(tempDir, 'poll', 'poll_bsd.go'), '//go:build darwin || freebsd\n\npackage poll\n\nfunc kqueue() {}\n', 'utf8');
+        await writeFile(join(tempDir, 'poll', 'poll_windows.go'), 'package poll\n\nfunc iocp() {}\n', 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const names = async (query) => (await runtime.findSymbols({ query })).symbols.map((symbol) => symbol.name).sort();
+        expect((await runtime.findSymbols({ query: 'name:kqueue' })).symbols[0]?.build).toBe('darwin || freebsd');
+        expect(await names('kind:func platform:linux')).toEqual(['Wait', 'epoll']);
+        expect(await names('kind:func platform:darwin/arm64')).toEqual(['Wait', 'kqueue']);
+        expect(await names('kind:func -platform:windows')).toEqual(['epoll', 'kqueue']);
+    });
+    it('lists Go struct fields with their tags and finds structs by tag', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writeFile(join(tempDir, 'user.go'), [
+            'package model',
+            '',
+            'type User struct {',
+            '\tBase',
+            '\t*log.Logger `json:"-"`',
+            '\tID, OrgID int64 `json:"id,omitempty" db:"user_id"`',
+            '\t// Name is shown in the UI.',
+            '\tName string `yaml:"name" json:"name"`',
+            '\tAddress struct {',
+            '\t\tCity string',
+            '\t}',
+            '\tTags map[string]string',
+            '}',
+            '',
+            'type Base struct{ Created int64 }',
+            '',
+        ].join('\n'), 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const [user] = (await runtime.findSymbols({ query: 'name:User' })).symbols;
+        expect(user?.fields).toEqual([
+            { name: 'Base', type: 'Base', embedded: true },
+            { name: 'Logger', type: '*log.Logger', tags: { json: '-' }, embedded: true },
+            { name: 'ID', type: 'int64', tags: { json: 'id,omitempty', db: 'user_id' } },
+            { name: 'OrgID', type: 'int64', tags: { json: 'id,omitempty', db: 'user_id' } },
+            { name: 'Name', type: 'string', tags: { yaml: 'name', json: 'name' } },
+            { name: 'Address', type: 'struct { City string }' },
+            { name: 'Tags', type: 'map[string]string' },
+        ]);
+        expect((await runtime.findSymbols({ query: 'name:Base' })).symbols[0]?.fields).toEqual([{ name: 'Created', type: 'int64' }]);
+        const names = async (query) => (await runtime.findSymbols({ query })).symbols.map((symbol) => symbol.name);
+        expect(await names('tag:db')).toEqual(['User']);
+        expect(await names('tag:json:id')).toEqual(['User']);
+        expect(await names('tag:json:user_id')).toEqual([]);
+    });
+    it('marks React components and summarizes the symbol map', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await mkdir(join(tempDir, 'ui'), { recursive: true });
+        await writeFile(join(tempDir, 'ui', 'Cart.tsx'), [
+            "import React, { forwardRef, memo } from 'react';",
+            '',
+            'export interface CartProps {',
+            '  items: string[];',
+            '}',
+            '',
+            'export function Cart({ items }: CartProps) {',
+            '  return <ul>{items.map((item) => <CartItem key={item} name={item} />)}</ul>;',
+            '}',
+            '',
+            'const CartItem = ({ name }: { name: string }) => (',
+            '  <li>{name}</li>',
+            ');',
+            '',
+            'export const Total = memo(function Total() { return null; });',
+            '',
+            'export const Input = forwardRef<HTMLInputElement>((props, ref) => null);',
+            '',
+            'export class Legacy extends React.Component<CartProps> {',
+            '  render() {',
+            "    return React.createElement('div');",
+            '  }',
+            '}',
+            '',
+            'export function formatPrice(cents: number): string {',
+            '  return `$${cents / 100}`;',
+            '}',
+            '',
+            'export function Price(): string {',
+            "  return 'n/a';",
+            '}',
+            '',
+        ].join('\n'), 'utf8');
+        await writeFile(join(tempDir, 'ui', 'server.go'), SERVER_GO, 'utf8');
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        expect((await runtime.findSymbols({ query: 'annotation:component' })).symbols.map((symbol) => symbol.name))
+            .toEqual(['Cart', 'CartItem', 'Total', 'Input', 'Legacy']);
+        const { central, ...counts } = await runtime.getSymbolMap();
+        expect(counts).toEqual({
+            scannedFiles: 2,
+            declarations: 13,
+            exported: 11,
+            components: 5,
+            languages: [{ language: 'ts', files: 1, declarations: 9 }, { language: 'go', files: 1, declarations: 4 }],
+            kinds: [
+                { kind: 'function', count: 5 },
+                { kind: 'method', count: 3 },
+                { kind: 'variable', count: 2 },
+                { kind: 'class', count: 1 },
+                { kind: 'interface', count: 1 },
+                { kind: 'type', count: 1 },
+            ],
+        });
+        expect(central).toHaveLength(10);
+    });
+});
@@ -0,0 +1,83 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `symbol-rank-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+async function writeWorkspace(tempDir: string): Promise<void> {
+  await mkdir(join(tempDir, 'cache'), { recursive: true });
+  await writeFile(join(tempDir, 'cache', 'store.go'), [
+    'package cache',
+    '',
+    '// Store keeps cached entries by key.',
+    'type Store struct{ entries map[string]string }',
+    '',
+    'func (s *Store) Get(key string) string { return s.entries[normalizeKey(key)] }',
+    '',
+    'func (s *Store) Put(key, value string) { s.entries[normalizeKey(key)] = value }',
+    '',
+    '// normalizeKey lowercases a cache key.',
+    'func normalizeKey(key string) string { return key }',
+  ].join('\n'), 'utf8');
+  await writeFile(join(tempDir, 'cache', 'handlers.go'), [
+    'package cache',
+    '',
+    'func Warm(s *Store) { s.Put("a", "1") }',
+    '',
+    'func Read(s *Store) string { return s.Get("a") }',
+    '',
+    'func Reset(s *Store) *Store { return &Store{} }',
+    '',
+    '// formatCacheKey is only used by tests.',
+    'func formatCacheKey(key string) string { return key }',
+  ].join('\n'), 'utf8');
+}
+
+describe('symbol centrality', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('ranks declarations the code depends on above leaf utilities', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeWorkspace(tempDir);
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const ranked = await runtime.rankSymbols();
+    expect(ranked.declarations).toBe(8);
+    expect(ranked.symbols[0]).toMatchObject({ name: 'Store', kind: 'type', path: 'cache/store.go', line: 4 });
+    expect(ranked.symbols[0]!.references).toBe(7);
+    expect(ranked.symbols[0]!.referrers).toBe(5);
+    const score = (name: string) => ranked.symbols.find((symbol) => symbol.name === name)!.score;
+    expect(score('normalizeKey')).toBeGreaterThan(score('formatCacheKey'));
+    expect(score('Store.Get')).toBeGreaterThan(score('Read'));
+    expect(ranked.symbols.at(-1)!.references).toBe(0);
+    expect((await runtime.rankSymbols({ paths: ['cache/handlers.go'] })).symbols.map((symbol) => symbol.name).sort())
+      .toEqual(['Read', 'Reset', 'Warm', 'formatCacheKey']);
+
+    const symbolMap = await runtime.getSymbolMap();
+    expect(symbolMap.central?.[0]?.name).toBe('Store');
+  });
+
+  it('gives ask the central declarations matching a question before leaf ones', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await writeWorkspace(tempDir);
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const answer = await runtime.askQuestion({ question: 'How is the cache key normalized in the store?', provider: 'claude' });
+    const symbols = answer.sources.filter((source) => source.kind === 'symbol').map((source) => source.ref);
+    expect(symbols.indexOf('cache/store.go#Store')).toBe(0);
+    expect(symbols.indexOf('cache/store.go#normalizeKey')).toBeLessThan(symbols.indexOf('cache/handlers.go#formatCacheKey'));
+    expect(answer.sources.find((source) => source.ref === 'cache/store.go#Store')).toMatchObject({ file: 'cache/store.go', line: 4 });
+  });
+});
@@ -0,0 +1,88 @@
+import { execFile } from 'node:child_process';
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { promisify } from 'node:util';
+import { afterEach, describe, expect, it } from 'vitest';
+import { extractTechDebt } from '../src/tech-debt.js';
+import { createSharedRuntimeService } from '../src/index.js';
+
+const execFileAsync = promisify(execFile);
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `tech-debt-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+const SOURCE = [
+  '// TODO(alice): split this module',
+  'const url = "http://example.com/#TODO: not a comment";',
+  'const total = a + b; // FIXME: overflows on large carts',
+  '// This fixes a BUG in the parser, nothing to track.',
+  '/**',
+  ' * @deprecated use createClient instead',
+  ' */',
+  'export function legacy() {}',
+  '# BUG(#123): retries never back off',
+  '// TODO @bob wire up metrics',
+  '/* TODO */',
+].join('\n');
+
+describe('tech debt harvesting', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('extracts comment markers with owners and references', () => {
+    expect(extractTechDebt(SOURCE, 'src/cart.ts')).toEqual([
+      { kind: 'todo', path: 'src/cart.ts', line: 1, text: 'split this module', owner: 'alice' },
+      { kind: 'fixme', path: 'src/cart.ts', line: 3, text: 'overflows on large carts' },
+      { kind: 'deprecated', path: 'src/cart.ts', line: 6, text: 'use createClient instead' },
+      { kind: 'bug', path: 'src/cart.ts', line: 9, text: 'retries never back off', ref: '#123' },
+      { kind: 'todo', path: 'src/cart.ts', line: 10, text: 'wire up metrics', owner: 'bob' },
+      { kind: 'todo', path: 'src/cart.ts', line: 11, text: '' },
+    ]);
+    expect(extractTechDebt('package api\n\n// Deprecated: use NewClient.\nfunc Dial() {}\n', 'api.go'))
+      .toEqual([{ kind: 'deprecated', path: 'api.go', line: 3, text: 'use NewClient.' }]);
+  });
+
+  it('attributes unowned markers through git blame and filters by owner', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await execFileAsync('git', ['init', '-b', 'main'], { cwd: tempDir });
+    await execFileAsync('git', ['config', 'user.email', 'carol@example.com'], { cwd: tempDir });
+    await execFileAsync('git', ['config', 'user.name', 'carol'], { cwd: tempDir });
+    await mkdir(join(tempDir, 'src'), { recursive: true });
+    await mkdir(join(tempDir, 'vendor'), { recursive: true });
+    await writeFile(join(tempDir, '.gitignore'), 'vendor/\n', 'utf8');
+    await writeFile(join(tempDir, 'src', 'cart.ts'), SOURCE, 'utf8');
+    await writeFile(join(tempDir, 'vendor', 'lib.js'), '// TODO: ignored\n', 'utf8');
+    await execFileAsync('git', ['add', '-A'], { cwd: tempDir });
+    await execFileAsync('git', ['commit', '-m', 'initial'], { cwd: tempDir });
+    await writeFile(join(tempDir, 'src', 'new.ts'), '// FIXME: untracked work in progress\n', 'utf8');
+
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+    const debt = await runtime.listTechDebt({ blame: true, kinds: ['todo', 'fixme'] });
+    expect(debt.counts).toEqual({ todo: 3, fixme: 2, hack: 0, bug: 0, deprecated: 0 });
+    expect(debt.items.map((item) => [item.path, item.line, item.owner ?? item.author ?? null])).toEqual([
+      ['src/cart.ts', 1, 'alice'],
+      ['src/cart.ts', 3, 'carol'],
+      ['src/cart.ts', 10, 'bob'],
+      ['src/cart.ts', 11, 'carol'],
+      ['src/new.ts', 1, null],
+    ]);
+    expect(debt.owners).toEqual([
+      { owner: 'carol', count: 2 },
+      { owner: 'alice', count: 1 },
+      { owner: 'bob', count: 1 },
+      { owner: 'unassigned', count: 1 },
+    ]);
+
+    const carol = await runtime.listTechDebt({ blame: true, owner: '@carol', limit: 1 });
+    expect(carol.items.map((item) => item.line)).toEqual([3]);
+    expect(carol).toMatchObject({ truncated: true, counts: { todo: 1, fixme: 1, bug: 1, deprecated: 1 } });
+  });
+});
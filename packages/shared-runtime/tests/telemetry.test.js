@@ -0,0 +1,74 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+import { buildTelemetryReport } from '../src/telemetry.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `telemetry-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+async function writeConfig(basePath, config) {
+    await mkdir(join(basePath, '.automatosx'), { recursive: true });
+    await writeFile(join(basePath, '.automatosx', 'config.json'), JSON.stringify(config), 'utf8');
+}
+describe('telemetry', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('aggregates usage, error codes, and latency without identifying details', () => {
+        const report = buildTelemetryReport([
+            { traceId: 't1', workflowId: 'ship', surface: 'cli', status: 'completed', startedAt: '2026-03-10T10:00:00.000Z', completedAt: '2026-03-10T10:00:01.000Z', stepResults: [] },
+            { traceId: 't2', workflowId: 'ship', surface: 'mcp', status: 'failed', startedAt: '2026-03-10T11:00:00.000Z', completedAt: '2026-03-10T11:00:03.000Z', stepResults: [], error: { code: 'PROVIDER_TIMEOUT', message: 'timed out calling /home/alice/secret-project' } },
+            { traceId: 't3', workflowId: 'acme-internal-deploy', surface: 'cli', status: 'failed', startedAt: '2026-03-10T09:00:00.000Z', stepResults: [], error: { code: 'see /tmp/log', message: 'boom' } },
+            { traceId: 't4', workflowId: 'ship', surface: 'cli', status: 'completed', startedAt: '2026-02-01T10:00:00.000Z', stepResults: [] },
+        ], { installId: 'install-123', now: new Date('2026-03-10T12:00:00.000Z') });
+        expect(report.reportDate).toBe('2026-03-10');
+        expect(report.installHash).not.toContain('install-123');
+        expect(report.features[0]).toEqual({
+            feature: 'ship',
+            runs: 2,
+            failures: 1,
+            surfaces: { cli: 1, mcp: 1 },
+            latencyMs: { p50: 1000, p95: 3000, p99: 3000 },
+        });
+        expect(report.features[1]?.feature).toMatch(/^custom:[0-9a-f]{8}$/);
+        expect(report.errorCategories).toEqual({ PROVIDER_TIMEOUT: 1, OTHER: 1 });
+        const serialized = JSON.stringify(report);
+        expect(serialized).not.toContain('acme-internal-deploy');
+        expect(serialized).not.toContain('alice');
+        expect(serialized).not.toContain('traceId');
+    });
+    it('refuses to send until telemetry is enabled with an endpoint', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        expect(await runtime.getTelemetryStatus()).toEqual({ enabled: false, endpoint: undefined, lastSentAt: undefined });
+        await expect(runtime.sendTelemetry()).rejects.toThrow('Telemetry is disabled');
+        await writeConfig(tempDir, { telemetry: { enabled: true } });
+        await expect(runtime.sendTelemetry()).rejects.toThrow('No telemetry endpoint configured');
+    });
+    it('sends the previewed report to a self-hosted collector', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        await runtime.callProvider({ prompt: 'hello', provider: 'claude' });
+        const collector = await runtime.startTelemetryCollector();
+        try {
+            await writeConfig(tempDir, { telemetry: { enabled: true, endpoint: `http://127.0.0.1:${collector.port}/` } });
+            const preview = await runtime.previewTelemetry();
+            const sent = await runtime.sendTelemetry();
+            expect(sent.report).toEqual(preview);
+            expect(sent.report.features.map((feature) => feature.feature)).toEqual(['call']);
+            const collected = (await readFile(collector.outputPath, 'utf8')).trim().split('\n');
+            expect(collected).toHaveLength(1);
+            expect(JSON.parse(collected[0] ?? '{}').report).toEqual(preview);
+            expect((await runtime.getTelemetryStatus()).lastSentAt).toBe(sent.sentAt);
+        }
+        finally {
+            await collector.close();
+        }
+    });
+});
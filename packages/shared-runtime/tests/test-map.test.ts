@@ -0,0 +1,77 @@
+import { mkdirSync } from 'node:fs';
+import { mkdir, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `test-map-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+describe('Go test map', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+  });
+
+  it('maps tests to what they are named after and call, and lists untested functions', async () => {
+    const tempDir = createTempDir();
+    tempDirs.push(tempDir);
+    await mkdir(join(tempDir, 'server'), { recursive: true });
+    await writeFile(join(tempDir, 'server', 'server.go'), [
+      'package server',
+      '',
+      'type Server struct{}',
+      '',
+      'func NewServer() *Server { return &Server{} }',
+      '',
+      'func (s *Server) Start() error { return nil }',
+      '',
+      'func (s *Server) Stop() {}',
+      '',
+      'func Parse(input string) string { return input }',
+      '',
+      'func parseHeader(line string) string { return line }',
+      '',
+      'func Reload() {}',
+    ].join('\n'), 'utf8');
+    await writeFile(join(tempDir, 'server', 'server_test.go'), [
+      'package server',
+      '',
+      'import "testing"',
+      '',
+      'func newTestServer(t *testing.T) *Server {',
+      '\treturn NewServer()',
+      '}',
+      '',
+      'func TestServer_Start(t *testing.T) {',
+      '\ts := newTestServer(t)',
+      '\ts.Start()',
+      '}',
+      '',
+      'func TestParseEmpty(t *testing.T) {}',
+      '',
+      'func BenchmarkParseHeader(b *testing.B) {',
+      '\tparseHeader("x")',
+      '}',
+      '',
+      'func TestMain(m *testing.M) {}',
+    ].join('\n'), 'utf8');
+    const runtime = createSharedRuntimeService({ basePath: tempDir });
+
+    const map = await runtime.mapGoTests();
+    expect(map.tests.map((test) => [test.name, test.kind, test.targets.map((target) => `${target.name} (${target.via.join('+')})`)])).toEqual([
+      ['TestServer_Start', 'test', ['NewServer (call)', 'Server.Start (name+call)']],
+      ['TestParseEmpty', 'test', ['Parse (name)']],
+      ['BenchmarkParseHeader', 'benchmark', ['parseHeader (name+call)']],
+    ]);
+    expect(map.tested.find((entry) => entry.name === 'Server.Start')?.tests).toEqual(['TestServer_Start']);
+    expect(map.untested.map((entry) => [entry.name, entry.exported])).toEqual([
+      ['Server.Stop', true],
+      ['Reload', true],
+    ]);
+  });
+});
@@ -0,0 +1,135 @@
+import { execFile } from 'node:child_process';
+import { mkdirSync } from 'node:fs';
+import { access, mkdir, rm, utimes, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { promisify } from 'node:util';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+const execFileAsync = promisify(execFile);
+const OLD = '2020-01-01T00:00:00Z';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `workspace-gc-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(join(dir, 'repo'), { recursive: true });
+    return dir;
+}
+async function git(cwd, args, date) {
+    const { stdout } = await execFileAsync('git', args, { cwd, env: { ...process.env, ...(date !== undefined ? { GIT_AUTHOR_DATE: date, GIT_COMMITTER_DATE: date } : {}) } });
+    return stdout.trim();
+}
+async function commitFile(cwd, path, date) {
+    await writeFile(join(cwd, path), `${path}\n`, 'utf8');
+    await git(cwd, ['add', '-A']);
+    await git(cwd, ['commit', '-m', `add ${path}`], date);
+}
+describe('workspace gc', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('reports stale ax branches, worktrees, and sandboxes, then removes what is safe', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const repo = join(tempDir, 'repo');
+        await git(repo, ['init', '-b', 'main']);
+        await git(repo, ['config', 'user.email', 'carol@example.com']);
+        await git(repo, ['config', 'user.name', 'carol']);
+        await commitFile(repo, 'README.md', OLD);
+        await git(repo, ['branch', 'ax/merged']);
+        await git(repo, ['checkout', '-b', 'ax/unmerged']);
+        await commitFile(repo, 'feature.ts', OLD);
+        await git(repo, ['checkout', '-b', 'ax/fresh', 'main']);
+        await commitFile(repo, 'fresh.ts');
+        await git(repo, ['checkout', 'main']);
+        await git(repo, ['worktree', 'add', '-b', 'ax/dirty', join(tempDir, 'wt-dirty')]);
+        await writeFile(join(tempDir, 'wt-dirty', 'notes.md'), 'draft\n', 'utf8');
+        await git(repo, ['worktree', 'add', '-b', 'ax/gone', join(tempDir, 'wt-gone')]);
+        await rm(join(tempDir, 'wt-gone'), { recursive: true, force: true });
+        const tmpDir = join(tempDir, 'tmp');
+        await mkdir(join(tmpDir, 'ax-apply-abc'), { recursive: true });
+        await writeFile(join(tmpDir, 'ax-apply-abc', 'hunk.diff'), '+x\n', 'utf8');
+        await utimes(join(tmpDir, 'ax-apply-abc'), new Date(OLD), new Date(OLD));
+        await mkdir(join(tmpDir, 'ax-apply-live'));
+        const runtime = createSharedRuntimeService({ basePath: repo });
+        const report = await runtime.collectGarbage({ dryRun: true, tmpDir });
+        expect(report.base).toBe('main');
+        expect(report.branches.map((branch) => [branch.name, branch.action, branch.ahead, branch.reason])).toEqual([
+            ['ax/dirty', 'keep', 0, `checked out in ${join(tempDir, 'wt-dirty')}`],
+            ['ax/gone', 'remove', 0, undefined],
+            ['ax/merged', 'remove', 0, undefined],
+            ['ax/unmerged', 'keep', 1, '1 unmerged commits; --force deletes them'],
+        ]);
+        expect(report.branches.find((branch) => branch.name === 'ax/unmerged')).toMatchObject({ files: 1, subject: 'add feature.ts' });
+        expect(report.worktrees.map((worktree) => [worktree.branch, worktree.missing, worktree.changes, worktree.action])).toEqual([
+            ['ax/dirty', false, 1, 'keep'],
+            ['ax/gone', true, 0, 'remove'],
+        ]);
+        expect(report.sandboxes).toHaveLength(1);
+        expect(report.sandboxes[0]).toMatchObject({ path: join(tmpDir, 'ax-apply-abc'), files: 1, bytes: 3, action: 'remove' });
+        expect(await git(repo, ['branch', '--list', 'ax/*', '--format=%(refname:short)'])).toBe('ax/dirty\nax/fresh\nax/gone\nax/merged\nax/unmerged');
+        await runtime.collectGarbage({ tmpDir });
+        expect(await git(repo, ['branch', '--list', 'ax/*', '--format=%(refname:short)'])).toBe('ax/dirty\nax/fresh\nax/unmerged');
+        await expect(access(join(tmpDir, 'ax-apply-abc'))).rejects.toThrow();
+        await access(join(tmpDir, 'ax-apply-live'));
+        const forced = await runtime.collectGarbage({ force: true, tmpDir });
+        expect(forced.worktrees.map((worktree) => worktree.action)).toEqual(['remove']);
+        expect(await git(repo, ['branch', '--list', 'ax/*', '--format=%(refname:short)'])).toBe('ax/fresh');
+    });
+    it('never removes the base branch, main, or master, and rejects settings that match too much', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const repo = join(tempDir, 'repo');
+        await git(repo, ['init', '-b', 'main']);
+        await git(repo, ['config', 'user.email', 'carol@example.com']);
+        await git(repo, ['config', 'user.name', 'carol']);
+        await commitFile(repo, 'README.md', OLD);
+        await git(repo, ['branch', 'master']);
+        await git(repo, ['branch', 'mainline']);
+        await git(repo, ['checkout', '-b', 'ax/work']);
+        await commitFile(repo, 'work.ts');
+        const runtime = createSharedRuntimeService({ basePath: repo });
+        const tmpDir = join(tempDir, 'tmp');
+        const report = await runtime.collectGarbage({ branchPrefix: 'ma', force: true, tmpDir });
+        expect(report.branches.map((branch) => [branch.name, branch.action, branch.reason])).toEqual([
+            ['main', 'keep', 'base branch'],
+            ['mainline', 'remove', undefined],
+            ['master', 'keep', 'base branch'],
+        ]);
+        expect(await git(repo, ['branch', '--format=%(refname:short)'])).toBe('ax/work\nmain\nmaster');
+        await expect(runtime.collectGarbage({ branchPrefix: ' ', dryRun: true, tmpDir })).rejects.toThrow('gc branch prefix must not be empty');
+        await expect(runtime.collectGarbage({ maxAgeDays: 0, dryRun: true, tmpDir })).rejects.toThrow('gc max age must be a positive number of days, got 0');
+        // Sandboxes younger than the max age are left alone too.
+        await mkdir(join(tmpDir, 'ax-apply-recent'), { recursive: true });
+        const lastWeek = new Date(Date.now() - 7 * 86_400_000);
+        await utimes(join(tmpDir, 'ax-apply-recent'), lastWeek, lastWeek);
+        expect((await runtime.collectGarbage({ dryRun: true, tmpDir })).sandboxes).toEqual([]);
+        expect((await runtime.collectGarbage({ maxAgeDays: 5, dryRun: true, tmpDir })).sandboxes.map((sandbox) => sandbox.ageDays)).toEqual([7]);
+    });
+    it('prunes old session recordings and the file contents only they use', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const repo = join(tempDir, 'repo');
+        const recordings = join(repo, '.automatosx', 'recordings');
+        const objects = join(recordings, 'objects');
+        await mkdir(join(objects, 'aa'), { recursive: true });
+        await mkdir(join(objects, 'bb'), { recursive: true });
+        const fileEvent = (hash) => `${JSON.stringify({ at: OLD, kind: 'file', path: 'a.txt', action: 'write', after: hash })}\n`;
+        await writeFile(join(recordings, 'old.jsonl'), fileEvent('aa01'), 'utf8');
+        await writeFile(join(recordings, 'live.jsonl'), fileEvent('bb01'), 'utf8');
+        await writeFile(join(objects, 'aa', '01'), 'old\n', 'utf8');
+        await writeFile(join(objects, 'bb', '01'), 'live\n', 'utf8');
+        for (const path of [join(recordings, 'old.jsonl'), join(objects, 'aa', '01'), join(objects, 'bb', '01')]) {
+            await utimes(path, new Date(OLD), new Date(OLD));
+        }
+        const runtime = createSharedRuntimeService({ basePath: repo });
+        const tmpDir = join(tempDir, 'tmp');
+        const report = await runtime.collectGarbage({ dryRun: true, tmpDir });
+        expect(report.recordings.map((recording) => [recording.sessionId, recording.events, recording.bytes > 0])).toEqual([['old', 1, true]]);
+        expect(report.recordingObjects).toEqual({ files: 1, bytes: 4 });
+        await access(join(recordings, 'old.jsonl'));
+        await runtime.collectGarbage({ dryRun: false, tmpDir });
+        await expect(access(join(recordings, 'old.jsonl'))).rejects.toThrow();
+        await expect(access(join(objects, 'aa', '01'))).rejects.toThrow();
+        await access(join(recordings, 'live.jsonl'));
+        await access(join(objects, 'bb', '01'));
+    });
+});
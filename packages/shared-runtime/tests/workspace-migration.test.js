@@ -0,0 +1,73 @@
+import { existsSync, mkdirSync } from 'node:fs';
+import { mkdir, readFile, rm, writeFile } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createSharedRuntimeService } from '../src/index.js';
+function createTempDir() {
+    const dir = join(process.cwd(), '.tmp', `workspace-migration-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+    mkdirSync(dir, { recursive: true });
+    return dir;
+}
+const LEGACY_WORKFLOW = [
+    'id: nightly-report',
+    'name: Nightly report',
+    'steps:',
+    '  - id: summarize',
+    '    type: prompt',
+    '    prompt: Summarize yesterday',
+    '    timeout: 500',
+    '',
+].join('\n');
+async function writeLegacyWorkspace(basePath) {
+    await mkdir(join(basePath, '.automatosx', 'workflows'), { recursive: true });
+    await mkdir(join(basePath, '.automatosx', 'agents'), { recursive: true });
+    await writeFile(join(basePath, '.automatosx', 'config.json'), JSON.stringify({ provider: 'gemini', productVersion: '11.4.0' }), 'utf8');
+    await writeFile(join(basePath, '.automatosx', 'workflows', 'nightly-report.yaml'), LEGACY_WORKFLOW, 'utf8');
+    await writeFile(join(basePath, '.automatosx', 'agents', 'backend.json'), JSON.stringify({ name: 'Backend Engineer', role: 'backend', abilities: ['api-design', 'sql'] }), 'utf8');
+}
+describe('workspace migration', () => {
+    const tempDirs = [];
+    afterEach(async () => {
+        await Promise.all(tempDirs.splice(0).map((tempDir) => rm(tempDir, { recursive: true, force: true })));
+    });
+    it('lists legacy formats on a dry run without touching files', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writeLegacyWorkspace(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const result = await runtime.migrateWorkspace({ dryRun: true });
+        expect(result.dryRun).toBe(true);
+        expect(result.backupDir).toBeUndefined();
+        expect(result.changes.map((change) => change.target)).toEqual(['config', 'workflow', 'agent']);
+        expect(result.changes[1]?.changes).toEqual([
+            'Renamed id to workflowId.',
+            'Added version 1.0.0.',
+            'Step 1: renamed id to stepId.',
+            'Step summarize: moved prompt into config.prompt.',
+            'Step summarize: raised timeout 500ms to the 1000ms minimum.',
+        ]);
+        expect(await readFile(join(tempDir, '.automatosx', 'workflows', 'nightly-report.yaml'), 'utf8')).toBe(LEGACY_WORKFLOW);
+    });
+    it('rewrites legacy files to the current schema with backups', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        await writeLegacyWorkspace(tempDir);
+        const runtime = createSharedRuntimeService({ basePath: tempDir });
+        const result = await runtime.migrateWorkspace();
+        expect(result.backupDir).toBeDefined();
+        expect(await readFile(join(result.backupDir, '.automatosx', 'workflows', 'nightly-report.yaml'), 'utf8')).toBe(LEGACY_WORKFLOW);
+        expect(JSON.parse(await readFile(join(tempDir, '.automatosx', 'config.json'), 'utf8'))).toEqual({
+            productVersion: '14.0.0',
+            schemaVersion: 1,
+            defaultProvider: 'gemini',
+        });
+        const workflow = await runtime.describeWorkflow({ workflowId: 'nightly-report' });
+        expect(workflow?.steps).toEqual([expect.objectContaining({ stepId: 'summarize', type: 'prompt' })]);
+        const agent = await runtime.getAgent('backend');
+        expect(agent).toMatchObject({ name: 'Backend Engineer', capabilities: ['api-design', 'sql'], metadata: { role: 'backend' } });
+        expect(existsSync(join(tempDir, '.automatosx', 'agents', 'backend.json'))).toBe(false);
+        const rerun = await runtime.migrateWorkspace();
+        expect(rerun.changes).toEqual([]);
+        expect(rerun.backupDir).toBeUndefined();
+    });
+});
@@ -0,0 +1,61 @@
+import { createCipheriv, createDecipheriv, randomBytes, scryptSync } from 'node:crypto';
+
+// Sealed values carry a version so the format can change without guessing.
+const SEALED_PREFIX = 'axenc:v1:';
+const IV_BYTES = 12;
+const TAG_BYTES = 16;
+// Fixed salt: the key must derive identically on every open of the same store.
+const KEY_SALT = 'automatosx-memory-v1';
+
+export interface MemoryCipher {
+  seal(plaintext: string): string;
+  open(stored: string): string;
+}
+
+/**
+ * AES-256-GCM over individual memory values. A 64-character hex key is used
+ * as-is; anything else is treated as a passphrase and stretched with scrypt.
+ */
+export function createMemoryCipher(secret: string): MemoryCipher {
+  if (secret.length === 0) {
+    throw new Error('Memory encryption key is empty');
+  }
+  const key = /^[0-9a-f]{64}$/i.test(secret) ? Buffer.from(secret, 'hex') : scryptSync(secret, KEY_SALT, 32);
+  return {
+    seal(plaintext) {
+      const iv = randomBytes(IV_BYTES);
+      const cipher = createCipheriv('aes-256-gcm', key, iv);
+      const data = Buffer.concat([cipher.update(plaintext, 'utf8'), cipher.final()]);
+      return `${SEALED_PREFIX}${Buffer.concat([iv, cipher.getAuthTag(), data]).toString('base64')}`;
+    },
+    open(stored) {
+      // Entries written before encryption was turned on stay readable and are sealed on their next write.
+      if (!isSealed(stored)) {
+        return stored;
+      }
+      const raw = Buffer.from(stored.slice(SEALED_PREFIX.length), 'base64');
+      const decipher = createDecipheriv('aes-256-gcm', key, raw.subarray(0, IV_BYTES));
+      decipher.setAuthTag(raw.subarray(IV_BYTES, IV_BYTES + TAG_BYTES));
+      try {
+        return Buffer.concat([decipher.update(raw.subarray(IV_BYTES + TAG_BYTES)), decipher.final()]).toString('utf8');
+      } catch {
+        throw new Error('Cannot decrypt memory: wrong encryption key or corrupted entry');
+      }
+    },
+  };
+}
+
+export function isSealed(value: unknown): value is string {
+  return typeof value === 'string' && value.startsWith(SEALED_PREFIX);
+}
+
+// Reads a stored column, refusing to hand ciphertext to callers that have no key.
+export function openStored(cipher: MemoryCipher | undefined, stored: string): string {
+  if (cipher !== undefined) {
+    return cipher.open(stored);
+  }
+  if (isSealed(stored)) {
+    throw new Error('Memory is encrypted; enable memory.encryption and provide its key to read it');
+  }
+  return stored;
+}
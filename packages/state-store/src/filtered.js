@@ -0,0 +1,120 @@
+/**
+ * Wraps a store so every memory value and semantic entry passes through a
+ * write filter, whoever the caller is. Reads, deletes, and everything that
+ * isn't memory are handed to the wrapped store unchanged.
+ */
+export class FilteredStateStore {
+    store;
+    filter;
+    vectorIndex;
+    constructor(store, filter) {
+        this.store = store;
+        this.filter = filter;
+        if (store.vectorIndex !== undefined) {
+            this.vectorIndex = store.vectorIndex;
+        }
+    }
+    async storeMemory(entry) {
+        if (this.filter.memory === undefined) return this.store.storeMemory(entry);
+        const value = await this.filter.memory(entry.value, { key: entry.key, namespace: entry.namespace });
+        return this.store.storeMemory({ ...entry, value });
+    }
+    getMemory(key, namespace) {
+        return this.store.getMemory(key, namespace);
+    }
+    searchMemory(query, namespace, options) {
+        return this.store.searchMemory(query, namespace, options);
+    }
+    deleteMemory(key, namespace) {
+        return this.store.deleteMemory(key, namespace);
+    }
+    listMemory(namespace) {
+        return this.store.listMemory(namespace);
+    }
+    memoryAsOf(asOf, options) {
+        return this.store.memoryAsOf(asOf, options);
+    }
+    pruneMemory(policy, now) {
+        return this.store.pruneMemory(policy, now);
+    }
+    enforceMemoryQuota(agentId, quota, now) {
+        return this.store.enforceMemoryQuota(agentId, quota, now);
+    }
+    registerPolicy(entry) {
+        return this.store.registerPolicy(entry);
+    }
+    listPolicies() {
+        return this.store.listPolicies();
+    }
+    registerAgent(entry) {
+        return this.store.registerAgent(entry);
+    }
+    getAgent(agentId) {
+        return this.store.getAgent(agentId);
+    }
+    listAgents() {
+        return this.store.listAgents();
+    }
+    removeAgent(agentId) {
+        return this.store.removeAgent(agentId);
+    }
+    listAgentCapabilities() {
+        return this.store.listAgentCapabilities();
+    }
+    async storeSemantic(entry) {
+        if (this.filter.semantic === undefined) return this.store.storeSemantic(entry);
+        const filtered = await this.filter.semantic({ content: entry.content, metadata: entry.metadata }, { key: entry.key, namespace: entry.namespace });
+        return this.store.storeSemantic({ ...entry, content: filtered.content, ...(entry.metadata !== undefined ? { metadata: filtered.metadata } : {}) });
+    }
+    searchSemantic(query, options) {
+        return this.store.searchSemantic(query, options);
+    }
+    getSemantic(key, namespace) {
+        return this.store.getSemantic(key, namespace);
+    }
+    listSemantic(options) {
+        return this.store.listSemantic(options);
+    }
+    deleteSemantic(key, namespace) {
+        return this.store.deleteSemantic(key, namespace);
+    }
+    clearSemantic(namespace) {
+        return this.store.clearSemantic(namespace);
+    }
+    semanticStats(namespace) {
+        return this.store.semanticStats(namespace);
+    }
+    submitFeedback(entry) {
+        return this.store.submitFeedback(entry);
+    }
+    listFeedback(options) {
+        return this.store.listFeedback(options);
+    }
+    createSession(entry) {
+        return this.store.createSession(entry);
+    }
+    getSession(sessionId) {
+        return this.store.getSession(sessionId);
+    }
+    listSessions() {
+        return this.store.listSessions();
+    }
+    joinSession(entry) {
+        return this.store.joinSession(entry);
+    }
+    leaveSession(sessionId, agentId) {
+        return this.store.leaveSession(sessionId, agentId);
+    }
+    completeSession(sessionId, summary) {
+        return this.store.completeSession(sessionId, summary);
+    }
+    failSession(sessionId, message) {
+        return this.store.failSession(sessionId, message);
+    }
+    closeStuckSessions(maxAgeMs) {
+        return this.store.closeStuckSessions(maxAgeMs);
+    }
+}
+export function createFilteredStateStore(store, filter) {
+    return new FilteredStateStore(store, filter);
+}
@@ -0,0 +1,193 @@
+import type {
+  AgentEntry,
+  FeedbackEntry,
+  MemoryAsOf,
+  MemoryEntry,
+  MemoryPruneResult,
+  MemorySearchOptions,
+  MemoryQuota,
+  MemoryQuotaResult,
+  MemoryRetentionPolicy,
+  PolicyEntry,
+  SemanticEntry,
+  SemanticNamespaceStats,
+  SemanticSearchOptions,
+  SemanticSearchResult,
+  SemanticVectorIndex,
+  SessionEntry,
+  SessionParticipantRole,
+  StateStore,
+} from './index.js';
+
+export interface StateStoreWriteRef {
+  key: string;
+  namespace?: string;
+}
+
+/**
+ * Rewrites what reaches the wrapped store, e.g. to redact secrets or drop
+ * private fields. Each hook returns the value to store in place of the
+ * caller's; a missing hook stores the value as given.
+ */
+export interface StateStoreWriteFilter {
+  memory?(value: unknown, ref: StateStoreWriteRef): unknown | Promise<unknown>;
+  semantic?(
+    entry: { content: string; metadata?: Record<string, unknown> },
+    ref: StateStoreWriteRef,
+  ): { content: string; metadata?: Record<string, unknown> } | Promise<{ content: string; metadata?: Record<string, unknown> }>;
+}
+
+/**
+ * Wraps a store so every memory value and semantic entry passes through a
+ * write filter, whoever the caller is. Reads, deletes, and everything that
+ * isn't memory are handed to the wrapped store unchanged.
+ */
+export class FilteredStateStore implements StateStore {
+  private readonly store: StateStore;
+  private readonly filter: StateStoreWriteFilter;
+  readonly vectorIndex?: SemanticVectorIndex;
+
+  constructor(store: StateStore, filter: StateStoreWriteFilter) {
+    this.store = store;
+    this.filter = filter;
+    if (store.vectorIndex !== undefined) {
+      this.vectorIndex = store.vectorIndex;
+    }
+  }
+
+  async storeMemory(entry: { key: string; namespace?: string; value: unknown; tags?: string[]; ttlMs?: number; agentId?: string; importance?: number }): Promise<MemoryEntry> {
+    if (this.filter.memory === undefined) return this.store.storeMemory(entry);
+    const value = await this.filter.memory(entry.value, { key: entry.key, namespace: entry.namespace });
+    return this.store.storeMemory({ ...entry, value });
+  }
+
+  getMemory(key: string, namespace?: string): Promise<MemoryEntry | undefined> {
+    return this.store.getMemory(key, namespace);
+  }
+
+  searchMemory(query: string, namespace?: string, options?: MemorySearchOptions): Promise<MemoryEntry[]> {
+    return this.store.searchMemory(query, namespace, options);
+  }
+
+  deleteMemory(key: string, namespace?: string): Promise<boolean> {
+    return this.store.deleteMemory(key, namespace);
+  }
+
+  listMemory(namespace?: string): Promise<MemoryEntry[]> {
+    return this.store.listMemory(namespace);
+  }
+
+  memoryAsOf(asOf: string, options?: { namespace?: string; key?: string }): Promise<MemoryAsOf> {
+    return this.store.memoryAsOf(asOf, options);
+  }
+
+  pruneMemory(policy?: MemoryRetentionPolicy, now?: Date): Promise<MemoryPruneResult> {
+    return this.store.pruneMemory(policy, now);
+  }
+
+  enforceMemoryQuota(agentId: string, quota: MemoryQuota, now?: Date): Promise<MemoryQuotaResult> {
+    return this.store.enforceMemoryQuota(agentId, quota, now);
+  }
+
+  registerPolicy(entry: { policyId: string; name: string; enabled?: boolean; metadata?: Record<string, unknown> }): Promise<PolicyEntry> {
+    return this.store.registerPolicy(entry);
+  }
+
+  listPolicies(): Promise<PolicyEntry[]> {
+    return this.store.listPolicies();
+  }
+
+  registerAgent(entry: { agentId: string; name: string; capabilities?: string[]; metadata?: Record<string, unknown> }): Promise<AgentEntry> {
+    return this.store.registerAgent(entry);
+  }
+
+  getAgent(agentId: string): Promise<AgentEntry | undefined> {
+    return this.store.getAgent(agentId);
+  }
+
+  listAgents(): Promise<AgentEntry[]> {
+    return this.store.listAgents();
+  }
+
+  removeAgent(agentId: string): Promise<boolean> {
+    return this.store.removeAgent(agentId);
+  }
+
+  listAgentCapabilities(): Promise<string[]> {
+    return this.store.listAgentCapabilities();
+  }
+
+  async storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown>; ttlMs?: number; agentId?: string; importance?: number }): Promise<SemanticEntry> {
+    if (this.filter.semantic === undefined) return this.store.storeSemantic(entry);
+    const filtered = await this.filter.semantic({ content: entry.content, metadata: entry.metadata }, { key: entry.key, namespace: entry.namespace });
+    return this.store.storeSemantic({ ...entry, content: filtered.content, ...(entry.metadata !== undefined ? { metadata: filtered.metadata } : {}) });
+  }
+
+  searchSemantic(query: string, options?: SemanticSearchOptions): Promise<SemanticSearchResult[]> {
+    return this.store.searchSemantic(query, options);
+  }
+
+  getSemantic(key: string, namespace?: string): Promise<SemanticEntry | undefined> {
+    return this.store.getSemantic(key, namespace);
+  }
+
+  listSemantic(options?: { namespace?: string; keyPrefix?: string; filterTags?: string[]; limit?: number }): Promise<SemanticEntry[]> {
+    return this.store.listSemantic(options);
+  }
+
+  deleteSemantic(key: string, namespace?: string): Promise<boolean> {
+    return this.store.deleteSemantic(key, namespace);
+  }
+
+  clearSemantic(namespace: string): Promise<number> {
+    return this.store.clearSemantic(namespace);
+  }
+
+  semanticStats(namespace?: string): Promise<SemanticNamespaceStats[]> {
+    return this.store.semanticStats(namespace);
+  }
+
+  submitFeedback(entry: Parameters<StateStore['submitFeedback']>[0]): Promise<FeedbackEntry> {
+    return this.store.submitFeedback(entry);
+  }
+
+  listFeedback(options?: { agentId?: string; limit?: number; since?: string }): Promise<FeedbackEntry[]> {
+    return this.store.listFeedback(options);
+  }
+
+  createSession(entry: { sessionId?: string; task: string; initiator: string; workspace?: string; metadata?: Record<string, unknown> }): Promise<SessionEntry> {
+    return this.store.createSession(entry);
+  }
+
+  getSession(sessionId: string): Promise<SessionEntry | undefined> {
+    return this.store.getSession(sessionId);
+  }
+
+  listSessions(): Promise<SessionEntry[]> {
+    return this.store.listSessions();
+  }
+
+  joinSession(entry: { sessionId: string; agentId: string; role?: SessionParticipantRole }): Promise<SessionEntry> {
+    return this.store.joinSession(entry);
+  }
+
+  leaveSession(sessionId: string, agentId: string): Promise<SessionEntry> {
+    return this.store.leaveSession(sessionId, agentId);
+  }
+
+  completeSession(sessionId: string, summary?: string): Promise<SessionEntry> {
+    return this.store.completeSession(sessionId, summary);
+  }
+
+  failSession(sessionId: string, message: string): Promise<SessionEntry> {
+    return this.store.failSession(sessionId, message);
+  }
+
+  closeStuckSessions(maxAgeMs?: number): Promise<SessionEntry[]> {
+    return this.store.closeStuckSessions(maxAgeMs);
+  }
+}
+
+export function createFilteredStateStore(store: StateStore, filter: StateStoreWriteFilter): StateStore {
+  return new FilteredStateStore(store, filter);
+}
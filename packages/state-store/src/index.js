@@ -1,7 +1,11 @@
 import { randomUUID } from 'node:crypto';
 import { mkdir, readFile, rename, rm, stat, writeFile } from 'node:fs/promises';
 import { dirname, join } from 'node:path';
-import { createSqliteStateStore } from './sqlite.js';
+import { createMemoryCipher, isSealed, openStored } from './encryption.js';
+import { entryAttribution, expiryFrom, isExpired, planQuota, planRetention } from './retention.js';
+import { fuseSemanticRankings, rankByBm25 } from './semantic-ranking.js';
+import { createPostgresStateStore } from './postgres.js';
+import { createSqliteStateStore, SqliteStateStore } from './sqlite.js';
 const DEFAULT_STATE_STORE_FILE = join('.automatosx', 'runtime', 'state.json');
 const stateStoreQueues = new Map();
 const LOCK_WAIT_TIMEOUT_MS = 5_000;
@@ -9,16 +13,24 @@ const LOCK_STALE_AFTER_MS = 60_000;
 const LOCK_RETRY_DELAY_MS = 10;
 export class FileStateStore {
     storageFile;
+    cipher;
     constructor(config = {}) {
         this.storageFile = config.storageFile ?? join(config.basePath ?? process.cwd(), DEFAULT_STATE_STORE_FILE);
+        this.cipher = config.encryptionKey !== undefined ? createMemoryCipher(config.encryptionKey) : undefined;
     }
     async storeMemory(entry) {
         return this.withMutation(async (data) => {
+            const now = new Date();
+            const expiresAt = expiryFrom(entry.ttlMs, now);
+            const tags = normalizeTags(entry.tags);
             const stored = {
                 key: entry.key,
                 namespace: entry.namespace,
                 value: entry.value,
-                updatedAt: new Date().toISOString(),
+                ...(tags.length > 0 ? { tags } : {}),
+                updatedAt: now.toISOString(),
+                ...(expiresAt !== undefined ? { expiresAt } : {}),
+                ...entryAttribution(entry),
             };
             const index = data.memory.findIndex((item) => item.key === stored.key && item.namespace === stored.namespace);
             if (index >= 0) {
@@ -38,7 +50,47 @@ export class FileStateStore {
         const data = await this.readConsistentData();
         return data.memory.find((entry) => entry.key === key && entry.namespace === namespace);
     }
-    async searchMemory(query, namespace) {
+    async memoryAsOf() {
+        throw new Error('The json memory backend keeps no history; as-of queries need the sqlite or postgres backend');
+    }
+    async pruneMemory(policy = {}, now = new Date()) {
+        return this.withMutation(async (data) => {
+            const plan = planRetention([...data.memory, ...data.semantic].map((entry) => ({
+                id: entry,
+                updatedAt: entry.updatedAt,
+                expiresAt: entry.expiresAt,
+                bytes: Buffer.byteLength(JSON.stringify(entry), 'utf8'),
+            })), policy, now);
+            const removed = new Set(plan.remove);
+            data.memory = data.memory.filter((entry) => !removed.has(entry));
+            data.semantic = data.semantic.filter((entry) => !removed.has(entry));
+            return plan.result;
+        });
+    }
+    async enforceMemoryQuota(agentId, quota, now = new Date()) {
+        return this.withMutation(async (data) => {
+            const owned = [
+                ...data.memory.map((entry) => ({ kind: 'memory', entry })),
+                ...data.semantic.map((entry) => ({ kind: 'semantic', entry })),
+            ].filter(({ entry }) => entry.agentId === agentId);
+            const plan = planQuota(owned.map((item) => ({
+                id: item,
+                updatedAt: item.entry.updatedAt,
+                expiresAt: item.entry.expiresAt,
+                importance: item.entry.importance,
+                bytes: Buffer.byteLength(JSON.stringify(item.entry), 'utf8'),
+            })), quota, now);
+            const removed = new Set(plan.remove.map(({ entry }) => entry));
+            data.memory = data.memory.filter((entry) => !removed.has(entry));
+            data.semantic = data.semantic.filter((entry) => !removed.has(entry));
+            return {
+                agentId,
+                evicted: plan.remove.map(({ kind, entry }) => ({ kind, key: entry.key, ...(entry.namespace !== undefined ? { namespace: entry.namespace } : {}) })),
+                remaining: plan.remaining,
+            };
+        });
+    }
+    async searchMemory(query, namespace, options = {}) {
         const normalized = query.trim().toLowerCase();
         const data = await this.readConsistentData();
         const matches = data.memory.filter((entry) => {
@@ -55,7 +107,9 @@ export class FileStateStore {
             ].join('\n').toLowerCase();
             return haystack.includes(normalized);
         });
-        return matches.sort((left, right) => right.updatedAt.localeCompare(left.updatedAt));
+        return matches
+            .sort((left, right) => right.updatedAt.localeCompare(left.updatedAt) || (left.namespace ?? '').localeCompare(right.namespace ?? '') || left.key.localeCompare(right.key))
+            .slice(0, options.limit ?? matches.length);
     }
     async deleteMemory(key, namespace) {
         return this.withMutation(async (data) => {
@@ -128,6 +182,8 @@ export class FileStateStore {
     }
     async storeSemantic(entry) {
         return this.withMutation(async (data) => {
+            const now = new Date();
+            const expiresAt = expiryFrom(entry.ttlMs, now);
             const stored = {
                 key: entry.key,
                 namespace: entry.namespace,
@@ -135,7 +191,9 @@ export class FileStateStore {
                 tags: normalizeTags(entry.tags),
                 metadata: entry.metadata === undefined ? undefined : sortRecord(entry.metadata),
                 tokenFreq: computeTokenFreq(entry.content),
-                updatedAt: new Date().toISOString(),
+                updatedAt: now.toISOString(),
+                ...(expiresAt !== undefined ? { expiresAt } : {}),
+                ...entryAttribution(entry),
             };
             const index = data.semantic.findIndex((item) => item.key === stored.key && item.namespace === stored.namespace);
             if (index >= 0) {
@@ -152,8 +210,8 @@ export class FileStateStore {
         const queryFreq = computeTokenFreq(query);
         const filterTags = normalizeTags(options.filterTags);
         const minSimilarity = options.minSimilarity ?? 0;
-        const ranked = data.semantic
-            .filter((entry) => {
+        const mode = options.mode ?? 'vector';
+        const candidates = data.semantic.filter((entry) => {
             if (options.namespace !== undefined && entry.namespace !== options.namespace) {
                 return false;
             }
@@ -161,13 +219,25 @@ export class FileStateStore {
                 return false;
             }
             return true;
-        })
+        });
+        const byVector = candidates
             .map((entry) => ({
             ...entry,
             score: scoreSemanticSimilarity(queryFreq, entry.tokenFreq),
         }))
             .filter((entry) => entry.score >= minSimilarity)
             .sort((left, right) => right.score - left.score || right.updatedAt.localeCompare(left.updatedAt) || left.key.localeCompare(right.key));
+        const byKeyword = () => rankByBm25(query, candidates, computeTokenFreq);
+        let ranked;
+        if (mode === 'keyword') {
+            ranked = byKeyword().map(({ entry, score }) => ({ ...entry, score, keywordScore: score }));
+        }
+        else if (mode === 'hybrid') {
+            ranked = fuseSemanticRankings(byVector.filter((entry) => entry.score > 0).map(({ score, ...entry }) => ({ entry, score })), byKeyword(), options.keywordWeight);
+        }
+        else {
+            ranked = byVector;
+        }
         if (options.topK === undefined) {
             return ranked;
         }
@@ -393,9 +463,16 @@ export class FileStateStore {
             return closed;
         });
     }
+    // Reads skip entries past their TTL; pruneMemory is what removes them from disk.
     async readConsistentData() {
         await waitForQueue(this.storageFile, stateStoreQueues);
-        return this.readData();
+        const data = await this.readData();
+        const now = new Date();
+        return {
+            ...data,
+            memory: data.memory.filter((entry) => !isExpired(entry, now)),
+            semantic: data.semantic.filter((entry) => !isExpired(entry, now)),
+        };
     }
     async withMutation(mutate) {
         return enqueueExclusive(this.storageFile, stateStoreQueues, async () => {
@@ -406,47 +483,102 @@ export class FileStateStore {
         });
     }
     async readData() {
+        let parsed;
         try {
-            const raw = await readFile(this.storageFile, 'utf8');
-            const parsed = JSON.parse(raw);
-            return {
-                memory: Array.isArray(parsed.memory) ? parsed.memory : [],
-                policies: Array.isArray(parsed.policies) ? parsed.policies : [],
-                agents: Array.isArray(parsed.agents) ? parsed.agents : [],
-                semantic: Array.isArray(parsed.semantic) ? parsed.semantic : [],
-                feedback: Array.isArray(parsed.feedback) ? parsed.feedback : [],
-                sessions: Array.isArray(parsed.sessions) ? parsed.sessions : [],
-            };
+            parsed = JSON.parse(await readFile(this.storageFile, 'utf8'));
         }
         catch {
-            return {
-                memory: [],
-                policies: [],
-                agents: [],
-                semantic: [],
-                feedback: [],
-                sessions: [],
-            };
+            parsed = {};
         }
+        // Decrypt outside the catch: a wrong key must fail loudly, not read as an empty store that the next write persists.
+        return {
+            memory: Array.isArray(parsed.memory) ? parsed.memory.map((entry) => this.openMemory(entry)) : [],
+            policies: Array.isArray(parsed.policies) ? parsed.policies : [],
+            agents: Array.isArray(parsed.agents) ? parsed.agents : [],
+            semantic: Array.isArray(parsed.semantic) ? parsed.semantic.map((entry) => this.openSemantic(entry)) : [],
+            feedback: Array.isArray(parsed.feedback) ? parsed.feedback : [],
+            sessions: Array.isArray(parsed.sessions) ? parsed.sessions : [],
+        };
     }
     async writeData(data) {
         await mkdir(dirname(this.storageFile), { recursive: true });
         const tempFile = `${this.storageFile}.${process.pid}.${randomUUID()}.tmp`;
-        await writeFile(tempFile, `${JSON.stringify(data, null, 2)}\n`, 'utf8');
+        const cipher = this.cipher;
+        const stored = cipher === undefined ? data : {
+            ...data,
+            memory: data.memory.map((entry) => ({ ...entry, value: cipher.seal(JSON.stringify(entry.value) ?? 'null') })),
+            semantic: data.semantic.map((entry) => ({ ...entry, content: cipher.seal(entry.content), tokenFreq: cipher.seal(JSON.stringify(entry.tokenFreq)) })),
+        };
+        await writeFile(tempFile, `${JSON.stringify(stored, null, 2)}\n`, 'utf8');
         await rename(tempFile, this.storageFile);
     }
+    openMemory(entry) {
+        return isSealed(entry.value) ? { ...entry, value: JSON.parse(openStored(this.cipher, entry.value)) } : entry;
+    }
+    openSemantic(entry) {
+        const content = entry.content;
+        const tokenFreq = entry.tokenFreq;
+        return {
+            ...entry,
+            content: isSealed(content) ? openStored(this.cipher, content) : entry.content,
+            tokenFreq: isSealed(tokenFreq) ? JSON.parse(openStored(this.cipher, tokenFreq)) : entry.tokenFreq,
+        };
+    }
 }
 export function createStateStore(config) {
     if (config?.backend === 'json') {
         return new FileStateStore(config);
     }
+    if (config?.backend === 'postgres') {
+        return createPostgresStateStore({
+            ...config.postgres,
+            encryptionKey: config.encryptionKey,
+            local: createSqliteStateStore({ basePath: config.basePath, dbFile: config.storageFile }),
+        });
+    }
     return createSqliteStateStore({
         basePath: config?.basePath,
         dbFile: config?.storageFile,
+        encryptionKey: config?.encryptionKey,
     });
 }
-export { createSqliteStateStore, SqliteStateStore } from './sqlite.js';
+/**
+ * Migrates the memory schema of the configured backend to `options.to`, or
+ * reports what would change with `dryRun`. Stores opened by createStateStore
+ * migrate forward on their own; this is for previews and rollbacks.
+ */
+export async function migrateStateStoreSchema(config, options = {}) {
+    if (config?.backend === 'json') {
+        throw new Error('The json memory backend has no schema to migrate');
+    }
+    if (config?.backend === 'postgres') {
+        const local = new SqliteStateStore({ basePath: config.basePath, dbFile: config.storageFile });
+        const store = createPostgresStateStore({ ...config.postgres, local });
+        try {
+            return await store.migrate(options);
+        }
+        finally {
+            await store.close();
+            local.close();
+        }
+    }
+    const store = new SqliteStateStore({ basePath: config?.basePath, dbFile: config?.storageFile, migrate: false });
+    try {
+        return store.migrateSchema(options);
+    }
+    finally {
+        store.close();
+    }
+}
+export { createSqliteStateStore, SQLITE_MIGRATIONS, SqliteStateStore } from './sqlite.js';
+export { createPostgresStateStore, POSTGRES_MIGRATIONS, PostgresStateStore } from './postgres.js';
 export { migrateJsonToSqlite } from './migrate.js';
+export { createMemoryCipher } from './encryption.js';
+export { fuseSemanticRankings } from './semantic-ranking.js';
+export { DEFAULT_HISTORY_MAX_AGE_MS, DEFAULT_MEMORY_IMPORTANCE } from './retention.js';
+export { createScopedStateStore, MEMORY_SCOPE_SEPARATOR, normalizeMemoryScope, ScopedStateStore } from './scoped.js';
+export { createFilteredStateStore, FilteredStateStore } from './filtered.js';
+export { planSchemaMigrations } from './schema-migrations.js';
 function requireSession(data, sessionId) {
     const session = data.sessions.find((entry) => entry.sessionId === sessionId);
     if (session === undefined) {
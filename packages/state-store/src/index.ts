@@ -1,13 +1,26 @@
 import { randomUUID } from 'node:crypto';
 import { mkdir, readFile, rename, rm, stat, writeFile } from 'node:fs/promises';
 import { dirname, join } from 'node:path';
-import { createSqliteStateStore } from './sqlite.js';
+import { createMemoryCipher, isSealed, openStored, type MemoryCipher } from './encryption.js';
+import { entryAttribution, expiryFrom, isExpired, planQuota, planRetention } from './retention.js';
+import { fuseSemanticRankings, rankByBm25, type RankedSemanticEntry } from './semantic-ranking.js';
+import { createPostgresStateStore, type PostgresMemoryConfig } from './postgres.js';
+import { createSqliteStateStore, SqliteStateStore } from './sqlite.js';
+import type { SchemaMigrationOptions, SchemaMigrationResult } from './schema-migrations.js';
 
 export interface MemoryEntry {
   key: string;
   namespace?: string;
   value: unknown;
+  // Lowercased and sorted; omitted when the entry has none.
+  tags?: string[];
   updatedAt: string;
+  // Set when the entry was stored with a TTL; expired entries are no longer returned.
+  expiresAt?: string;
+  // The agent that wrote the entry; its memory quota counts the entry.
+  agentId?: string;
+  // From 0 to 1, weighed by importance eviction.
+  importance?: number;
 }
 
 export interface PolicyEntry {
@@ -36,10 +49,58 @@ export interface SemanticEntry {
   metadata?: Record<string, unknown>;
   tokenFreq: Record<string, number>;
   updatedAt: string;
+  expiresAt?: string;
+  agentId?: string;
+  importance?: number;
 }
 
 export interface SemanticSearchResult extends SemanticEntry {
   score: number;
+  // The similarity and BM25 relevance behind a hybrid score.
+  vectorScore?: number;
+  keywordScore?: number;
+}
+
+// `vector` ranks by term-vector similarity, `keyword` by full-text BM25, and
+// `hybrid` fuses both rankings.
+export type SemanticSearchMode = 'vector' | 'keyword' | 'hybrid';
+
+export interface SemanticSearchOptions {
+  namespace?: string;
+  filterTags?: string[];
+  topK?: number;
+  // Applies to the vector ranking only.
+  minSimilarity?: number;
+  // Defaults to vector.
+  mode?: SemanticSearchMode;
+  // Share of a hybrid score given to the keyword ranking, from 0 to 1 (default 0.5).
+  keywordWeight?: number;
+}
+
+export interface SemanticVectorSearchOptions {
+  namespace?: string;
+  filterTags?: string[];
+  minSimilarity?: number;
+  limit?: number;
+}
+
+/**
+ * Dense embeddings kept next to semantic entries and ranked by the store
+ * itself (postgres with pgvector). Vectors are stored per embedding model, so
+ * switching models re-embeds entries instead of comparing across spaces, and
+ * a vector is dropped when its entry's content changes.
+ */
+export interface SemanticVectorIndex {
+  // Entries with no vector from `model`, to embed and `store`.
+  listUnembedded(model: string, options?: { namespace?: string; filterTags?: string[] }): Promise<SemanticEntry[]>;
+  store(model: string, entries: Array<{ key: string; namespace?: string; vector: number[] }>): Promise<void>;
+  // Entries embedded by `model`, most similar to `vector` first.
+  search(model: string, vector: number[], options?: SemanticVectorSearchOptions): Promise<SemanticSearchResult[]>;
+}
+
+export interface MemorySearchOptions {
+  // Most matches returned, best first. The sqlite and postgres backends return 200 unless given; Infinity returns them all.
+  limit?: number;
 }
 
 export interface SemanticNamespaceStats {
@@ -50,6 +111,54 @@ export interface SemanticNamespaceStats {
   lastUpdatedAt?: string;
 }
 
+// Limits applied by pruneMemory across key-value and semantic entries together.
+export interface MemoryRetentionPolicy {
+  // Entries not updated for this long are removed.
+  maxAgeMs?: number;
+  // The least recently updated entries are removed until both caps hold.
+  maxEntries?: number;
+  maxBytes?: number;
+  // Versions kept for as-of queries are dropped this long after they were
+  // replaced; defaults to 30 days. A deleted entry's versions go with it.
+  historyMaxAgeMs?: number;
+}
+
+// Memory as it stood at a past moment, rebuilt from the versions the store keeps of every write.
+export interface MemoryAsOf {
+  asOf: string;
+  memory: MemoryEntry[];
+  semantic: SemanticEntry[];
+}
+
+export interface MemoryPruneResult {
+  // Removed because their TTL passed.
+  expired: number;
+  // Removed for exceeding maxAgeMs.
+  aged: number;
+  // Removed to fit maxEntries and maxBytes.
+  evicted: number;
+  remaining: { entries: number; bytes: number };
+}
+
+// `oldest-write` evicts the entries written longest ago first (reads don't
+// count); `importance` evicts the lowest importance first, halving it for every
+// week without an update.
+export type MemoryEvictionPolicy = 'oldest-write' | 'importance';
+
+// A cap on one agent's key-value and semantic entries together.
+export interface MemoryQuota {
+  maxEntries?: number;
+  maxBytes?: number;
+  // Defaults to oldest-write.
+  eviction?: MemoryEvictionPolicy;
+}
+
+export interface MemoryQuotaResult {
+  agentId: string;
+  evicted: Array<{ kind: 'memory' | 'semantic'; key: string; namespace?: string }>;
+  remaining: { entries: number; bytes: number };
+}
+
 export interface FeedbackEntry {
   feedbackId: string;
   selectedAgent: string;
@@ -92,11 +201,16 @@ export interface SessionEntry {
 }
 
 export interface StateStore {
-  storeMemory(entry: { key: string; namespace?: string; value: unknown }): Promise<MemoryEntry>;
+  storeMemory(entry: { key: string; namespace?: string; value: unknown; tags?: string[]; ttlMs?: number; agentId?: string; importance?: number }): Promise<MemoryEntry>;
   getMemory(key: string, namespace?: string): Promise<MemoryEntry | undefined>;
-  searchMemory(query: string, namespace?: string): Promise<MemoryEntry[]>;
+  searchMemory(query: string, namespace?: string, options?: MemorySearchOptions): Promise<MemoryEntry[]>;
   deleteMemory(key: string, namespace?: string): Promise<boolean>;
   listMemory(namespace?: string): Promise<MemoryEntry[]>;
+  // Entries as they stood at `asOf`, including ones since changed, by namespace and key.
+  memoryAsOf(asOf: string, options?: { namespace?: string; key?: string }): Promise<MemoryAsOf>;
+  pruneMemory(policy?: MemoryRetentionPolicy, now?: Date): Promise<MemoryPruneResult>;
+  // Evicts the agent's entries, per the quota's policy, until the rest fit.
+  enforceMemoryQuota(agentId: string, quota: MemoryQuota, now?: Date): Promise<MemoryQuotaResult>;
   registerPolicy(entry: { policyId: string; name: string; enabled?: boolean; metadata?: Record<string, unknown> }): Promise<PolicyEntry>;
   listPolicies(): Promise<PolicyEntry[]>;
   registerAgent(entry: { agentId: string; name: string; capabilities?: string[]; metadata?: Record<string, unknown> }): Promise<AgentEntry>;
@@ -104,13 +218,15 @@ export interface StateStore {
   listAgents(): Promise<AgentEntry[]>;
   removeAgent(agentId: string): Promise<boolean>;
   listAgentCapabilities(): Promise<string[]>;
-  storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown> }): Promise<SemanticEntry>;
-  searchSemantic(query: string, options?: { namespace?: string; filterTags?: string[]; topK?: number; minSimilarity?: number }): Promise<SemanticSearchResult[]>;
+  storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown>; ttlMs?: number; agentId?: string; importance?: number }): Promise<SemanticEntry>;
+  searchSemantic(query: string, options?: SemanticSearchOptions): Promise<SemanticSearchResult[]>;
   getSemantic(key: string, namespace?: string): Promise<SemanticEntry | undefined>;
   listSemantic(options?: { namespace?: string; keyPrefix?: string; filterTags?: string[]; limit?: number }): Promise<SemanticEntry[]>;
   deleteSemantic(key: string, namespace?: string): Promise<boolean>;
   clearSemantic(namespace: string): Promise<number>;
   semanticStats(namespace?: string): Promise<SemanticNamespaceStats[]>;
+  // Set by stores that rank dense embeddings themselves; the others leave vector search to the caller.
+  readonly vectorIndex?: SemanticVectorIndex;
   submitFeedback(entry: {
     feedbackId?: string;
     selectedAgent: string;
@@ -147,8 +263,14 @@ interface StateStoreFile {
 export interface FileStateStoreConfig {
   basePath?: string;
   storageFile?: string;
-  /** Storage backend. Defaults to 'sqlite'. Use 'json' to keep the legacy file-based store. */
-  backend?: 'sqlite' | 'json';
+  /**
+   * Storage backend. Defaults to 'sqlite'. Use 'json' to keep the legacy file-based store, or
+   * 'postgres' to share memory and semantic entries through `postgres` while the rest stays in SQLite.
+   */
+  backend?: 'sqlite' | 'json' | 'postgres';
+  postgres?: PostgresMemoryConfig;
+  /** Encrypts memory values and semantic content (AES-256-GCM) with this key or passphrase. */
+  encryptionKey?: string;
 }
 
 const DEFAULT_STATE_STORE_FILE = join('.automatosx', 'runtime', 'state.json');
@@ -159,18 +281,26 @@ const LOCK_RETRY_DELAY_MS = 10;
 
 export class FileStateStore implements StateStore {
   private readonly storageFile: string;
+  private readonly cipher?: MemoryCipher;
 
   constructor(config: FileStateStoreConfig = {}) {
     this.storageFile = config.storageFile ?? join(config.basePath ?? process.cwd(), DEFAULT_STATE_STORE_FILE);
+    this.cipher = config.encryptionKey !== undefined ? createMemoryCipher(config.encryptionKey) : undefined;
   }
 
-  async storeMemory(entry: { key: string; namespace?: string; value: unknown }): Promise<MemoryEntry> {
+  async storeMemory(entry: { key: string; namespace?: string; value: unknown; tags?: string[]; ttlMs?: number; agentId?: string; importance?: number }): Promise<MemoryEntry> {
     return this.withMutation(async (data) => {
+      const now = new Date();
+      const expiresAt = expiryFrom(entry.ttlMs, now);
+      const tags = normalizeTags(entry.tags);
       const stored: MemoryEntry = {
         key: entry.key,
         namespace: entry.namespace,
         value: entry.value,
-        updatedAt: new Date().toISOString(),
+        ...(tags.length > 0 ? { tags } : {}),
+        updatedAt: now.toISOString(),
+        ...(expiresAt !== undefined ? { expiresAt } : {}),
+        ...entryAttribution(entry),
       };
       const index = data.memory.findIndex((item) => item.key === stored.key && item.namespace === stored.namespace);
       if (index >= 0) {
@@ -192,7 +322,50 @@ export class FileStateStore implements StateStore {
     return data.memory.find((entry) => entry.key === key && entry.namespace === namespace);
   }
 
-  async searchMemory(query: string, namespace?: string): Promise<MemoryEntry[]> {
+  async memoryAsOf(): Promise<MemoryAsOf> {
+    throw new Error('The json memory backend keeps no history; as-of queries need the sqlite or postgres backend');
+  }
+
+  async pruneMemory(policy: MemoryRetentionPolicy = {}, now = new Date()): Promise<MemoryPruneResult> {
+    return this.withMutation(async (data) => {
+      const plan = planRetention<MemoryEntry | SemanticEntry>([...data.memory, ...data.semantic].map((entry) => ({
+        id: entry,
+        updatedAt: entry.updatedAt,
+        expiresAt: entry.expiresAt,
+        bytes: Buffer.byteLength(JSON.stringify(entry), 'utf8'),
+      })), policy, now);
+      const removed = new Set(plan.remove);
+      data.memory = data.memory.filter((entry) => !removed.has(entry));
+      data.semantic = data.semantic.filter((entry) => !removed.has(entry));
+      return plan.result;
+    });
+  }
+
+  async enforceMemoryQuota(agentId: string, quota: MemoryQuota, now = new Date()): Promise<MemoryQuotaResult> {
+    return this.withMutation(async (data) => {
+      const owned = [
+        ...data.memory.map((entry) => ({ kind: 'memory' as const, entry })),
+        ...data.semantic.map((entry) => ({ kind: 'semantic' as const, entry })),
+      ].filter(({ entry }) => entry.agentId === agentId);
+      const plan = planQuota(owned.map((item) => ({
+        id: item,
+        updatedAt: item.entry.updatedAt,
+        expiresAt: item.entry.expiresAt,
+        importance: item.entry.importance,
+        bytes: Buffer.byteLength(JSON.stringify(item.entry), 'utf8'),
+      })), quota, now);
+      const removed = new Set<MemoryEntry | SemanticEntry>(plan.remove.map(({ entry }) => entry));
+      data.memory = data.memory.filter((entry) => !removed.has(entry));
+      data.semantic = data.semantic.filter((entry) => !removed.has(entry));
+      return {
+        agentId,
+        evicted: plan.remove.map(({ kind, entry }) => ({ kind, key: entry.key, ...(entry.namespace !== undefined ? { namespace: entry.namespace } : {}) })),
+        remaining: plan.remaining,
+      };
+    });
+  }
+
+  async searchMemory(query: string, namespace?: string, options: MemorySearchOptions = {}): Promise<MemoryEntry[]> {
     const normalized = query.trim().toLowerCase();
     const data = await this.readConsistentData();
     const matches = data.memory.filter((entry) => {
@@ -209,7 +382,9 @@ export class FileStateStore implements StateStore {
       ].join('\n').toLowerCase();
       return haystack.includes(normalized);
     });
-    return matches.sort((left, right) => right.updatedAt.localeCompare(left.updatedAt));
+    return matches
+      .sort((left, right) => right.updatedAt.localeCompare(left.updatedAt) || (left.namespace ?? '').localeCompare(right.namespace ?? '') || left.key.localeCompare(right.key))
+      .slice(0, options.limit ?? matches.length);
   }
 
   async deleteMemory(key: string, namespace?: string): Promise<boolean> {
@@ -294,8 +469,10 @@ export class FileStateStore implements StateStore {
     ).sort((left, right) => left.localeCompare(right));
   }
 
-  async storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown> }): Promise<SemanticEntry> {
+  async storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown>; ttlMs?: number; agentId?: string; importance?: number }): Promise<SemanticEntry> {
     return this.withMutation(async (data) => {
+      const now = new Date();
+      const expiresAt = expiryFrom(entry.ttlMs, now);
       const stored: SemanticEntry = {
         key: entry.key,
         namespace: entry.namespace,
@@ -303,7 +480,9 @@ export class FileStateStore implements StateStore {
         tags: normalizeTags(entry.tags),
         metadata: entry.metadata === undefined ? undefined : sortRecord(entry.metadata),
         tokenFreq: computeTokenFreq(entry.content),
-        updatedAt: new Date().toISOString(),
+        updatedAt: now.toISOString(),
+        ...(expiresAt !== undefined ? { expiresAt } : {}),
+        ...entryAttribution(entry),
       };
       const index = data.semantic.findIndex((item) => item.key === stored.key && item.namespace === stored.namespace);
       if (index >= 0) {
@@ -315,31 +494,43 @@ export class FileStateStore implements StateStore {
     });
   }
 
-  async searchSemantic(
-    query: string,
-    options: { namespace?: string; filterTags?: string[]; topK?: number; minSimilarity?: number } = {},
-  ): Promise<SemanticSearchResult[]> {
+  async searchSemantic(query: string, options: SemanticSearchOptions = {}): Promise<SemanticSearchResult[]> {
     const data = await this.readConsistentData();
     const queryFreq = computeTokenFreq(query);
     const filterTags = normalizeTags(options.filterTags);
     const minSimilarity = options.minSimilarity ?? 0;
+    const mode = options.mode ?? 'vector';
 
-    const ranked = data.semantic
-      .filter((entry) => {
-        if (options.namespace !== undefined && entry.namespace !== options.namespace) {
-          return false;
-        }
-        if (filterTags.length > 0 && !filterTags.every((tag) => entry.tags.includes(tag))) {
-          return false;
-        }
-        return true;
-      })
+    const candidates = data.semantic.filter((entry) => {
+      if (options.namespace !== undefined && entry.namespace !== options.namespace) {
+        return false;
+      }
+      if (filterTags.length > 0 && !filterTags.every((tag) => entry.tags.includes(tag))) {
+        return false;
+      }
+      return true;
+    });
+    const byVector = candidates
       .map((entry) => ({
         ...entry,
         score: scoreSemanticSimilarity(queryFreq, entry.tokenFreq),
       }))
       .filter((entry) => entry.score >= minSimilarity)
       .sort((left, right) => right.score - left.score || right.updatedAt.localeCompare(left.updatedAt) || left.key.localeCompare(right.key));
+    const byKeyword = (): RankedSemanticEntry[] => rankByBm25(query, candidates, computeTokenFreq);
+
+    let ranked: SemanticSearchResult[];
+    if (mode === 'keyword') {
+      ranked = byKeyword().map(({ entry, score }) => ({ ...entry, score, keywordScore: score }));
+    } else if (mode === 'hybrid') {
+      ranked = fuseSemanticRankings(
+        byVector.filter((entry) => entry.score > 0).map(({ score, ...entry }) => ({ entry, score })),
+        byKeyword(),
+        options.keywordWeight,
+      );
+    } else {
+      ranked = byVector;
+    }
 
     if (options.topK === undefined) {
       return ranked;
@@ -599,9 +790,16 @@ export class FileStateStore implements StateStore {
     });
   }
 
+  // Reads skip entries past their TTL; pruneMemory is what removes them from disk.
   private async readConsistentData(): Promise<StateStoreFile> {
     await waitForQueue(this.storageFile, stateStoreQueues);
-    return this.readData();
+    const data = await this.readData();
+    const now = new Date();
+    return {
+      ...data,
+      memory: data.memory.filter((entry) => !isExpired(entry, now)),
+      semantic: data.semantic.filter((entry) => !isExpired(entry, now)),
+    };
   }
 
   private async withMutation<T>(mutate: (data: StateStoreFile) => Promise<T> | T): Promise<T> {
@@ -614,51 +812,116 @@ export class FileStateStore implements StateStore {
   }
 
   private async readData(): Promise<StateStoreFile> {
+    let parsed: Partial<StateStoreFile>;
     try {
-      const raw = await readFile(this.storageFile, 'utf8');
-      const parsed = JSON.parse(raw) as Partial<StateStoreFile>;
-      return {
-        memory: Array.isArray(parsed.memory) ? parsed.memory : [],
-        policies: Array.isArray(parsed.policies) ? parsed.policies : [],
-        agents: Array.isArray(parsed.agents) ? parsed.agents : [],
-        semantic: Array.isArray(parsed.semantic) ? parsed.semantic : [],
-        feedback: Array.isArray(parsed.feedback) ? parsed.feedback : [],
-        sessions: Array.isArray(parsed.sessions) ? parsed.sessions : [],
-      };
+      parsed = JSON.parse(await readFile(this.storageFile, 'utf8')) as Partial<StateStoreFile>;
     } catch {
-      return {
-        memory: [],
-        policies: [],
-        agents: [],
-        semantic: [],
-        feedback: [],
-        sessions: [],
-      };
+      parsed = {};
     }
+    // Decrypt outside the catch: a wrong key must fail loudly, not read as an empty store that the next write persists.
+    return {
+      memory: Array.isArray(parsed.memory) ? parsed.memory.map((entry) => this.openMemory(entry)) : [],
+      policies: Array.isArray(parsed.policies) ? parsed.policies : [],
+      agents: Array.isArray(parsed.agents) ? parsed.agents : [],
+      semantic: Array.isArray(parsed.semantic) ? parsed.semantic.map((entry) => this.openSemantic(entry)) : [],
+      feedback: Array.isArray(parsed.feedback) ? parsed.feedback : [],
+      sessions: Array.isArray(parsed.sessions) ? parsed.sessions : [],
+    };
   }
 
   private async writeData(data: StateStoreFile): Promise<void> {
     await mkdir(dirname(this.storageFile), { recursive: true });
     const tempFile = `${this.storageFile}.${process.pid}.${randomUUID()}.tmp`;
-    await writeFile(tempFile, `${JSON.stringify(data, null, 2)}\n`, 'utf8');
+    const cipher = this.cipher;
+    const stored = cipher === undefined ? data : {
+      ...data,
+      memory: data.memory.map((entry) => ({ ...entry, value: cipher.seal(JSON.stringify(entry.value) ?? 'null') })),
+      semantic: data.semantic.map((entry) => ({ ...entry, content: cipher.seal(entry.content), tokenFreq: cipher.seal(JSON.stringify(entry.tokenFreq)) })),
+    };
+    await writeFile(tempFile, `${JSON.stringify(stored, null, 2)}\n`, 'utf8');
     await rename(tempFile, this.storageFile);
   }
+
+  private openMemory(entry: MemoryEntry): MemoryEntry {
+    return isSealed(entry.value) ? { ...entry, value: JSON.parse(openStored(this.cipher, entry.value)) as unknown } : entry;
+  }
+
+  private openSemantic(entry: SemanticEntry): SemanticEntry {
+    const content: unknown = entry.content;
+    const tokenFreq: unknown = entry.tokenFreq;
+    return {
+      ...entry,
+      content: isSealed(content) ? openStored(this.cipher, content) : entry.content,
+      tokenFreq: isSealed(tokenFreq) ? JSON.parse(openStored(this.cipher, tokenFreq)) as Record<string, number> : entry.tokenFreq,
+    };
+  }
 }
 
 export function createStateStore(config?: FileStateStoreConfig): StateStore {
   if (config?.backend === 'json') {
     return new FileStateStore(config);
   }
+  if (config?.backend === 'postgres') {
+    return createPostgresStateStore({
+      ...config.postgres,
+      encryptionKey: config.encryptionKey,
+      local: createSqliteStateStore({ basePath: config.basePath, dbFile: config.storageFile }),
+    });
+  }
   return createSqliteStateStore({
     basePath: config?.basePath,
     dbFile: config?.storageFile,
+    encryptionKey: config?.encryptionKey,
   });
 }
 
-export { createSqliteStateStore, SqliteStateStore } from './sqlite.js';
-export type { SqliteStateStoreConfig } from './sqlite.js';
+/**
+ * Migrates the memory schema of the configured backend to `options.to`, or
+ * reports what would change with `dryRun`. Stores opened by createStateStore
+ * migrate forward on their own; this is for previews and rollbacks.
+ */
+export async function migrateStateStoreSchema(
+  config: FileStateStoreConfig | undefined,
+  options: SchemaMigrationOptions = {},
+): Promise<SchemaMigrationResult> {
+  if (config?.backend === 'json') {
+    throw new Error('The json memory backend has no schema to migrate');
+  }
+  if (config?.backend === 'postgres') {
+    const local = new SqliteStateStore({ basePath: config.basePath, dbFile: config.storageFile });
+    const store = createPostgresStateStore({ ...config.postgres, local });
+    try {
+      return await store.migrate(options);
+    } finally {
+      await store.close();
+      local.close();
+    }
+  }
+  const store = new SqliteStateStore({ basePath: config?.basePath, dbFile: config?.storageFile, migrate: false });
+  try {
+    return store.migrateSchema(options);
+  } finally {
+    store.close();
+  }
+}
+
+export { createSqliteStateStore, SQLITE_MIGRATIONS, SqliteStateStore } from './sqlite.js';
+export type { SqliteMigration, SqliteStateStoreConfig } from './sqlite.js';
+export { createPostgresStateStore, POSTGRES_MIGRATIONS, PostgresStateStore } from './postgres.js';
+export type { PostgresClient, PostgresMemoryConfig, PostgresMigration, PostgresPool, PostgresStateStoreConfig } from './postgres.js';
 export { migrateJsonToSqlite } from './migrate.js';
+export { createMemoryCipher } from './encryption.js';
+export type { MemoryCipher } from './encryption.js';
+export { fuseSemanticRankings } from './semantic-ranking.js';
+export type { RankedSemanticEntry } from './semantic-ranking.js';
+export { DEFAULT_HISTORY_MAX_AGE_MS, DEFAULT_MEMORY_IMPORTANCE } from './retention.js';
+export { createScopedStateStore, MEMORY_SCOPE_SEPARATOR, normalizeMemoryScope, ScopedStateStore } from './scoped.js';
+export type { MemoryScopeResolver } from './scoped.js';
+export { createFilteredStateStore, FilteredStateStore } from './filtered.js';
+export type { StateStoreWriteFilter, StateStoreWriteRef } from './filtered.js';
 export type { MigrateJsonToSqliteOptions, MigrationResult } from './migrate.js';
+export { planSchemaMigrations } from './schema-migrations.js';
+export type { SchemaMigrationOptions, SchemaMigrationPlan, SchemaMigrationResult, SchemaMigrationStep } from './schema-migrations.js';
 
 function requireSession(data: StateStoreFile, sessionId: string): SessionEntry {
   const session = data.sessions.find((entry) => entry.sessionId === sessionId);
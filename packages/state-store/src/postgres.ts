@@ -0,0 +1,873 @@
+import type {
+  AgentEntry,
+  FeedbackEntry,
+  MemoryAsOf,
+  MemoryEntry,
+  MemoryPruneResult,
+  MemorySearchOptions,
+  MemoryQuota,
+  MemoryQuotaResult,
+  MemoryRetentionPolicy,
+  PolicyEntry,
+  SemanticEntry,
+  SemanticNamespaceStats,
+  SemanticSearchOptions,
+  SemanticSearchResult,
+  SemanticVectorIndex,
+  SemanticVectorSearchOptions,
+  SessionEntry,
+  SessionParticipantRole,
+  StateStore,
+} from './index.js';
+import { createMemoryCipher, openStored, type MemoryCipher } from './encryption.js';
+import { asOfTimestamp, DEFAULT_HISTORY_MAX_AGE_MS, entryAttribution, expiryFrom, planQuota, planRetention } from './retention.js';
+import { planSchemaMigrations, type SchemaMigrationOptions, type SchemaMigrationResult } from './schema-migrations.js';
+import { fuseSemanticRankings, keywordTerms, rankByBm25, type RankedSemanticEntry } from './semantic-ranking.js';
+import {
+  computeTokenFreqRecord,
+  MEMORY_SEARCH_LIMIT,
+  normalizeTags,
+  rowToMemory,
+  rowToSemantic,
+  safeJsonParse,
+  tfCosineSimilarity,
+  type MemRow,
+  type SemRow,
+} from './sqlite.js';
+
+// The subset of a `pg` client the store uses.
+export interface PostgresClient {
+  query<R = Record<string, unknown>>(text: string, values?: unknown[]): Promise<{ rows: R[]; rowCount: number | null }>;
+}
+
+// The subset of a `pg` Pool the store uses, so callers can hand in their own pool.
+export interface PostgresPool extends PostgresClient {
+  // A dedicated connection, for work that must share one transaction; hand it back with `release`.
+  connect(): Promise<PostgresClient & { release(): void }>;
+  end(): Promise<void>;
+}
+
+export interface PostgresMemoryConfig {
+  connectionString?: string;
+  /** An existing pool; takes precedence over `connectionString`. */
+  pool?: PostgresPool;
+  /** Pool size when the store opens its own pool. Defaults to 10. */
+  maxConnections?: number;
+}
+
+export interface PostgresStateStoreConfig extends PostgresMemoryConfig {
+  /** Holds agents, policies, feedback, and sessions, which stay per workspace. */
+  local: StateStore;
+  /** Encrypts memory values and semantic content (AES-256-GCM) with this key or passphrase. */
+  encryptionKey?: string;
+}
+
+export interface PostgresMigration {
+  version: number;
+  name: string;
+  // Statements, each sent on its own inside the version's transaction.
+  sql: string[];
+  // Undoes `sql`; a version without it can't be rolled back.
+  down?: string[];
+}
+
+const DEFAULT_MAX_CONNECTIONS = 10;
+// Resolved at runtime so `pg` stays an optional dependency for teams on local storage.
+const PG_MODULE: string = 'pg';
+// Arbitrary, but fixed: every AutomatosX instance migrating the same database takes the same lock.
+const MIGRATION_LOCK_KEY = 0x61786d65;
+const MEM_COLUMNS = `key, namespace, value, tags, updated_at, expires_at, agent_id, importance`;
+const SEM_COLUMNS = `s.key, s.namespace, s.content, s.token_freq, s.tags, s.metadata, s.updated_at, s.expires_at, s.agent_id, s.importance`;
+
+/**
+ * Schema versions, applied in order and recorded in `ax_schema_migrations`.
+ * Append new versions; never edit an applied one. Statements, and their
+ * `down` undo, must be safe to repeat, since two instances starting together
+ * may both apply a version.
+ */
+export const POSTGRES_MIGRATIONS: PostgresMigration[] = [
+  {
+    version: 1,
+    name: 'memory-tables',
+    sql: [
+      `
+        CREATE TABLE IF NOT EXISTS ax_memory_items (
+          id         BIGSERIAL PRIMARY KEY,
+          key        TEXT NOT NULL,
+          namespace  TEXT NOT NULL DEFAULT 'default',
+          value      TEXT NOT NULL,
+          updated_at TEXT NOT NULL,
+          expires_at TEXT,
+          agent_id   TEXT,
+          importance DOUBLE PRECISION,
+          search     TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', key || ' ' || namespace || ' ' || value)) STORED,
+          UNIQUE (key, namespace)
+        )
+      `,
+      `CREATE INDEX IF NOT EXISTS idx_ax_mem_ns     ON ax_memory_items (namespace)`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_mem_upd    ON ax_memory_items (updated_at DESC)`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_mem_exp    ON ax_memory_items (expires_at) WHERE expires_at IS NOT NULL`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_mem_agent  ON ax_memory_items (agent_id) WHERE agent_id IS NOT NULL`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_mem_search ON ax_memory_items USING GIN (search)`,
+      `
+        CREATE TABLE IF NOT EXISTS ax_semantic_items (
+          id         BIGSERIAL PRIMARY KEY,
+          key        TEXT NOT NULL,
+          namespace  TEXT NOT NULL DEFAULT 'default',
+          content    TEXT NOT NULL,
+          token_freq TEXT,
+          tags       TEXT,
+          metadata   TEXT,
+          updated_at TEXT NOT NULL,
+          expires_at TEXT,
+          agent_id   TEXT,
+          importance DOUBLE PRECISION,
+          search     TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', key || ' ' || content || ' ' || coalesce(tags, ''))) STORED,
+          UNIQUE (key, namespace)
+        )
+      `,
+      `CREATE INDEX IF NOT EXISTS idx_ax_sem_ns     ON ax_semantic_items (namespace)`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_sem_upd    ON ax_semantic_items (updated_at DESC)`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_sem_exp    ON ax_semantic_items (expires_at) WHERE expires_at IS NOT NULL`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_sem_agent  ON ax_semantic_items (agent_id) WHERE agent_id IS NOT NULL`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_sem_search ON ax_semantic_items USING GIN (search)`,
+    ],
+  },
+  {
+    version: 2,
+    name: 'memory-tags',
+    sql: [
+      `ALTER TABLE ax_memory_items ADD COLUMN IF NOT EXISTS tags TEXT`,
+    ],
+    down: [
+      `ALTER TABLE ax_memory_items DROP COLUMN IF EXISTS tags`,
+    ],
+  },
+  {
+    // Every version of every entry, for as-of queries; see addMemoryHistory in sqlite.ts.
+    version: 3,
+    name: 'memory-history',
+    sql: [
+      `
+        CREATE TABLE IF NOT EXISTS ax_memory_history (
+          id         BIGSERIAL PRIMARY KEY,
+          key        TEXT NOT NULL,
+          namespace  TEXT NOT NULL,
+          value      TEXT NOT NULL,
+          tags       TEXT,
+          updated_at TEXT NOT NULL,
+          expires_at TEXT,
+          agent_id   TEXT,
+          importance DOUBLE PRECISION,
+          valid_from TEXT NOT NULL,
+          valid_to   TEXT
+        )
+      `,
+      `CREATE INDEX IF NOT EXISTS idx_ax_memh_key ON ax_memory_history (namespace, key, valid_from)`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_memh_to  ON ax_memory_history (valid_to) WHERE valid_to IS NOT NULL`,
+      `
+        CREATE TABLE IF NOT EXISTS ax_semantic_history (
+          id         BIGSERIAL PRIMARY KEY,
+          key        TEXT NOT NULL,
+          namespace  TEXT NOT NULL,
+          content    TEXT NOT NULL,
+          token_freq TEXT,
+          tags       TEXT,
+          metadata   TEXT,
+          updated_at TEXT NOT NULL,
+          expires_at TEXT,
+          agent_id   TEXT,
+          importance DOUBLE PRECISION,
+          valid_from TEXT NOT NULL,
+          valid_to   TEXT
+        )
+      `,
+      `CREATE INDEX IF NOT EXISTS idx_ax_semh_key ON ax_semantic_history (namespace, key, valid_from)`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_semh_to  ON ax_semantic_history (valid_to) WHERE valid_to IS NOT NULL`,
+      `
+        INSERT INTO ax_memory_history (key, namespace, value, tags, updated_at, expires_at, agent_id, importance, valid_from)
+          SELECT key, namespace, value, tags, updated_at, expires_at, agent_id, importance, updated_at FROM ax_memory_items
+          WHERE NOT EXISTS (SELECT 1 FROM ax_memory_history)
+      `,
+      `
+        INSERT INTO ax_semantic_history (key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance, valid_from)
+          SELECT key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance, updated_at FROM ax_semantic_items
+          WHERE NOT EXISTS (SELECT 1 FROM ax_semantic_history)
+      `,
+      `
+        CREATE OR REPLACE FUNCTION ax_record_memory_history() RETURNS trigger AS $$
+        DECLARE
+          stamp TEXT := to_char(clock_timestamp() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"');
+        BEGIN
+          IF TG_OP <> 'INSERT' THEN
+            UPDATE ax_memory_history SET valid_to = stamp WHERE namespace = OLD.namespace AND key = OLD.key AND valid_to IS NULL;
+          END IF;
+          IF TG_OP <> 'DELETE' THEN
+            INSERT INTO ax_memory_history (key, namespace, value, tags, updated_at, expires_at, agent_id, importance, valid_from)
+              VALUES (NEW.key, NEW.namespace, NEW.value, NEW.tags, NEW.updated_at, NEW.expires_at, NEW.agent_id, NEW.importance, stamp);
+          END IF;
+          RETURN NULL;
+        END;
+        $$ LANGUAGE plpgsql
+      `,
+      `DROP TRIGGER IF EXISTS ax_memory_history ON ax_memory_items`,
+      `
+        CREATE TRIGGER ax_memory_history AFTER INSERT OR UPDATE OR DELETE ON ax_memory_items
+          FOR EACH ROW EXECUTE FUNCTION ax_record_memory_history()
+      `,
+      `
+        CREATE OR REPLACE FUNCTION ax_record_semantic_history() RETURNS trigger AS $$
+        DECLARE
+          stamp TEXT := to_char(clock_timestamp() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"');
+        BEGIN
+          IF TG_OP <> 'INSERT' THEN
+            UPDATE ax_semantic_history SET valid_to = stamp WHERE namespace = OLD.namespace AND key = OLD.key AND valid_to IS NULL;
+          END IF;
+          IF TG_OP <> 'DELETE' THEN
+            INSERT INTO ax_semantic_history (key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance, valid_from)
+              VALUES (NEW.key, NEW.namespace, NEW.content, NEW.token_freq, NEW.tags, NEW.metadata, NEW.updated_at, NEW.expires_at, NEW.agent_id, NEW.importance, stamp);
+          END IF;
+          RETURN NULL;
+        END;
+        $$ LANGUAGE plpgsql
+      `,
+      `DROP TRIGGER IF EXISTS ax_semantic_history ON ax_semantic_items`,
+      `
+        CREATE TRIGGER ax_semantic_history AFTER INSERT OR UPDATE OR DELETE ON ax_semantic_items
+          FOR EACH ROW EXECUTE FUNCTION ax_record_semantic_history()
+      `,
+    ],
+    down: [
+      `DROP TRIGGER IF EXISTS ax_memory_history ON ax_memory_items`,
+      `DROP TRIGGER IF EXISTS ax_semantic_history ON ax_semantic_items`,
+      `DROP FUNCTION IF EXISTS ax_record_memory_history()`,
+      `DROP FUNCTION IF EXISTS ax_record_semantic_history()`,
+      `DROP TABLE IF EXISTS ax_memory_history`,
+      `DROP TABLE IF EXISTS ax_semantic_history`,
+    ],
+  },
+  {
+    // Dense embeddings for vector search in the database. The column takes any
+    // dimension, since the model is configurable, so searches are exact scans.
+    version: 4,
+    name: 'pgvector-embeddings',
+    sql: [
+      `CREATE EXTENSION IF NOT EXISTS vector`,
+      `ALTER TABLE ax_semantic_items ADD COLUMN IF NOT EXISTS embedding vector`,
+      `ALTER TABLE ax_semantic_items ADD COLUMN IF NOT EXISTS embedding_model TEXT`,
+      `CREATE INDEX IF NOT EXISTS idx_ax_sem_model ON ax_semantic_items (embedding_model)`,
+    ],
+    down: [
+      `DROP INDEX IF EXISTS idx_ax_sem_model`,
+      `ALTER TABLE ax_semantic_items DROP COLUMN IF EXISTS embedding_model`,
+      `ALTER TABLE ax_semantic_items DROP COLUMN IF EXISTS embedding`,
+    ],
+  },
+  {
+    // Deleting an entry, or evicting it, drops its versions too, so an as-of
+    // query can't bring back what was removed. Fires after the trigger that
+    // closes the current version; history of entries deleted before is purged.
+    version: 5,
+    name: 'history-purge-on-delete',
+    sql: [
+      `
+        CREATE OR REPLACE FUNCTION ax_purge_memory_history() RETURNS trigger AS $$
+        BEGIN
+          DELETE FROM ax_memory_history WHERE namespace = OLD.namespace AND key = OLD.key;
+          RETURN NULL;
+        END;
+        $$ LANGUAGE plpgsql
+      `,
+      `DROP TRIGGER IF EXISTS ax_memory_history_purge ON ax_memory_items`,
+      `CREATE TRIGGER ax_memory_history_purge AFTER DELETE ON ax_memory_items FOR EACH ROW EXECUTE FUNCTION ax_purge_memory_history()`,
+      `
+        CREATE OR REPLACE FUNCTION ax_purge_semantic_history() RETURNS trigger AS $$
+        BEGIN
+          DELETE FROM ax_semantic_history WHERE namespace = OLD.namespace AND key = OLD.key;
+          RETURN NULL;
+        END;
+        $$ LANGUAGE plpgsql
+      `,
+      `DROP TRIGGER IF EXISTS ax_semantic_history_purge ON ax_semantic_items`,
+      `CREATE TRIGGER ax_semantic_history_purge AFTER DELETE ON ax_semantic_items FOR EACH ROW EXECUTE FUNCTION ax_purge_semantic_history()`,
+      `DELETE FROM ax_memory_history h WHERE NOT EXISTS (SELECT 1 FROM ax_memory_items m WHERE m.namespace = h.namespace AND m.key = h.key)`,
+      `DELETE FROM ax_semantic_history h WHERE NOT EXISTS (SELECT 1 FROM ax_semantic_items s WHERE s.namespace = h.namespace AND s.key = h.key)`,
+    ],
+    down: [
+      `DROP TRIGGER IF EXISTS ax_memory_history_purge ON ax_memory_items`,
+      `DROP TRIGGER IF EXISTS ax_semantic_history_purge ON ax_semantic_items`,
+      `DROP FUNCTION IF EXISTS ax_purge_memory_history()`,
+      `DROP FUNCTION IF EXISTS ax_purge_semantic_history()`,
+    ],
+  },
+];
+
+/**
+ * Keeps memory and semantic entries in a shared Postgres database so several
+ * developers and CI runs read and write one memory. Agents, policies,
+ * feedback, and sessions stay in the workspace's local store. The schema is
+ * migrated on first use; rows use the same columns as the SQLite backend, and
+ * searches use Postgres full-text ranking in place of FTS5.
+ */
+export class PostgresStateStore implements StateStore {
+  private readonly local: StateStore;
+  private readonly cipher?: MemoryCipher;
+  private readonly config: PostgresMemoryConfig;
+  private ready?: Promise<PostgresPool>;
+  private connecting?: Promise<PostgresPool>;
+  // Absent with an encryption key: vectors derived from content can't be sealed and still searched.
+  readonly vectorIndex?: SemanticVectorIndex;
+
+  constructor(config: PostgresStateStoreConfig) {
+    if (config.pool === undefined && (config.connectionString === undefined || config.connectionString.length === 0)) {
+      throw new Error('The postgres memory backend needs a connection string or a pool');
+    }
+    this.local = config.local;
+    this.cipher = config.encryptionKey !== undefined ? createMemoryCipher(config.encryptionKey) : undefined;
+    this.config = config;
+    if (this.cipher === undefined) {
+      this.vectorIndex = {
+        listUnembedded: (model, options) => this.listUnembeddedSemantic(model, options),
+        store: (model, entries) => this.storeSemanticEmbeddings(model, entries),
+        search: (model, vector, options) => this.searchSemanticByEmbedding(model, vector, options),
+      };
+    }
+  }
+
+  /**
+   * Brings the schema to `options.to`, by default the latest version. Unlike
+   * SQLite, the database is not backed up first; take a dump before rolling
+   * back.
+   */
+  async migrate(options: SchemaMigrationOptions = {}): Promise<SchemaMigrationResult> {
+    const pool = this.config.pool ?? await this.connect();
+    let logged = true;
+    if (options.dryRun === true) {
+      logged = (await pool.query<{ present: boolean }>(`SELECT to_regclass('ax_schema_migrations') IS NOT NULL AS present`)).rows[0]?.present === true;
+    } else {
+      await pool.query(`CREATE TABLE IF NOT EXISTS ax_schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL)`);
+    }
+    const applied = logged
+      ? (await pool.query<{ version: number }>(`SELECT version FROM ax_schema_migrations`)).rows.map((row) => Number(row.version))
+      : [];
+    const plan = planSchemaMigrations(
+      POSTGRES_MIGRATIONS.map((migration) => ({ version: migration.version, name: migration.name, reversible: migration.down !== undefined })),
+      applied,
+      options,
+    );
+    const result: SchemaMigrationResult = { backend: 'postgres', ...plan, dryRun: options.dryRun === true };
+    if (result.dryRun || plan.steps.length === 0) {
+      return result;
+    }
+    // One connection for every version, so each version's statements, its record, and the lock share a transaction.
+    const client = await pool.connect();
+    try {
+      for (const step of plan.steps) {
+        const migration = POSTGRES_MIGRATIONS.find((entry) => entry.version === step.version)!;
+        try {
+          await client.query('BEGIN');
+          await client.query('SELECT pg_advisory_xact_lock($1)', [MIGRATION_LOCK_KEY]);
+          // Another instance may have applied (or rolled back) this version while we waited for the lock.
+          const recorded = (await client.query(`SELECT 1 FROM ax_schema_migrations WHERE version = $1`, [migration.version])).rows.length > 0;
+          if (recorded !== (plan.direction === 'up')) {
+            for (const statement of plan.direction === 'up' ? migration.sql : migration.down!) {
+              await client.query(statement);
+            }
+            await (plan.direction === 'up'
+              ? client.query(`INSERT INTO ax_schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`, [migration.version, migration.name, new Date().toISOString()])
+              : client.query(`DELETE FROM ax_schema_migrations WHERE version = $1`, [migration.version]));
+          }
+          await client.query('COMMIT');
+        } catch (error) {
+          await client.query('ROLLBACK').catch(() => undefined);
+          throw new Error(`Memory schema migration v${migration.version} (${migration.name}) failed: ${error instanceof Error ? error.message : String(error)}`);
+        }
+      }
+    } finally {
+      client.release();
+    }
+    return result;
+  }
+
+  // Ends the pool this store opened; a pool passed in stays the caller's to end.
+  async close(): Promise<void> {
+    if (this.connecting !== undefined) {
+      await (await this.connecting).end();
+    }
+  }
+
+  // -------------------------------------------------------------------------
+  // Memory
+  // -------------------------------------------------------------------------
+
+  async storeMemory(entry: { key: string; namespace?: string; value: unknown; tags?: string[]; ttlMs?: number; agentId?: string; importance?: number }): Promise<MemoryEntry> {
+    const pool = await this.pool();
+    const namespace = entry.namespace ?? 'default';
+    const date = new Date();
+    const now = date.toISOString();
+    const expiresAt = expiryFrom(entry.ttlMs, date);
+    const attribution = entryAttribution(entry);
+    const tags = normalizeTags(entry.tags);
+    await pool.query(`
+      INSERT INTO ax_memory_items (key, namespace, value, tags, updated_at, expires_at, agent_id, importance) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+      ON CONFLICT (key, namespace) DO UPDATE SET value = excluded.value, tags = excluded.tags, updated_at = excluded.updated_at, expires_at = excluded.expires_at,
+        agent_id = excluded.agent_id, importance = excluded.importance
+    `, [entry.key, namespace, this.seal(JSON.stringify(entry.value)), tags.length > 0 ? tags.join(',') : null, now, expiresAt ?? null, attribution.agentId ?? null, attribution.importance ?? null]);
+    return { key: entry.key, namespace: entry.namespace, value: entry.value, ...(tags.length > 0 ? { tags } : {}), updatedAt: now, ...(expiresAt !== undefined ? { expiresAt } : {}), ...attribution };
+  }
+
+  async getMemory(key: string, namespace?: string): Promise<MemoryEntry | undefined> {
+    const pool = await this.pool();
+    const { rows } = await pool.query<MemRow>(
+      `SELECT ${MEM_COLUMNS} FROM ax_memory_items WHERE key = $1 AND namespace = $2 AND ${live('$3')}`,
+      [key, namespace ?? 'default', new Date().toISOString()],
+    );
+    return rows[0] !== undefined ? rowToMemory(rows[0], this.cipher) : undefined;
+  }
+
+  async searchMemory(query: string, namespace?: string, options: MemorySearchOptions = {}): Promise<MemoryEntry[]> {
+    const trimmed = query.trim();
+    if (trimmed === '') return this.listMemory(namespace);
+    const limit = options.limit ?? MEMORY_SEARCH_LIMIT;
+    if (this.cipher !== undefined) {
+      // The full-text index only ever sees ciphertext, so match decrypted values instead.
+      const normalized = trimmed.toLowerCase();
+      return (await this.listMemory(namespace))
+        .filter((entry) => [entry.key, entry.namespace ?? '', JSON.stringify(entry.value) ?? ''].join('\n').toLowerCase().includes(normalized))
+        .slice(0, limit);
+    }
+
+    const pool = await this.pool();
+    const params: unknown[] = [trimmed, new Date().toISOString()];
+    let sql = `
+      SELECT ${MEM_COLUMNS} FROM ax_memory_items
+      WHERE search @@ phraseto_tsquery('english', $1) AND ${live('$2')}
+    `;
+    if (namespace !== undefined) { params.push(namespace); sql += ` AND namespace = $${params.length}`; }
+    sql += ` ORDER BY ts_rank_cd(search, phraseto_tsquery('english', $1)) DESC, updated_at DESC, namespace, key`;
+    if (Number.isFinite(limit)) { params.push(limit); sql += ` LIMIT $${params.length}`; }
+    const { rows } = await pool.query<MemRow>(sql, params);
+    return rows.map((row) => rowToMemory(row, this.cipher));
+  }
+
+  async deleteMemory(key: string, namespace?: string): Promise<boolean> {
+    const pool = await this.pool();
+    const result = await pool.query(`DELETE FROM ax_memory_items WHERE key = $1 AND namespace = $2`, [key, namespace ?? 'default']);
+    return (result.rowCount ?? 0) > 0;
+  }
+
+  async listMemory(namespace?: string): Promise<MemoryEntry[]> {
+    const pool = await this.pool();
+    const params: unknown[] = [new Date().toISOString()];
+    let sql = `SELECT ${MEM_COLUMNS} FROM ax_memory_items WHERE ${live('$1')}`;
+    if (namespace !== undefined) { params.push(namespace); sql += ` AND namespace = $2`; }
+    const { rows } = await pool.query<MemRow>(`${sql} ORDER BY updated_at DESC`, params);
+    return rows.map((row) => rowToMemory(row, this.cipher));
+  }
+
+  async memoryAsOf(asOf: string, options: { namespace?: string; key?: string } = {}): Promise<MemoryAsOf> {
+    const pool = await this.pool();
+    const at = asOfTimestamp(asOf);
+    const conditions = [`valid_from <= $1`, `(valid_to IS NULL OR valid_to > $1)`, `(expires_at IS NULL OR expires_at > $1)`];
+    const values: string[] = [at];
+    if (options.namespace !== undefined) {
+      values.push(options.namespace);
+      conditions.push(`namespace = $${values.length}`);
+    }
+    if (options.key !== undefined) {
+      values.push(options.key);
+      conditions.push(`key = $${values.length}`);
+    }
+    const where = conditions.join(' AND ');
+    const memory = await pool.query<MemRow>(`SELECT ${MEM_COLUMNS} FROM ax_memory_history WHERE ${where} ORDER BY namespace, key`, values);
+    const semantic = await pool.query<SemRow>(
+      `SELECT key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance FROM ax_semantic_history WHERE ${where} ORDER BY namespace, key`,
+      values,
+    );
+    return { asOf: at, memory: memory.rows.map((row) => rowToMemory(row, this.cipher)), semantic: semantic.rows.map((row) => rowToSemantic(row, this.cipher)) };
+  }
+
+  async pruneMemory(policy: MemoryRetentionPolicy = {}, now = new Date()): Promise<MemoryPruneResult> {
+    const pool = await this.pool();
+    const { rows } = await pool.query<{ kind: 'memory' | 'semantic'; id: string; updated_at: string; expires_at: string | null; bytes: number }>(`
+      SELECT 'memory' AS kind, id, updated_at, expires_at, octet_length(value) AS bytes FROM ax_memory_items
+      UNION ALL
+      SELECT 'semantic' AS kind, id, updated_at, expires_at,
+        octet_length(content) + coalesce(octet_length(token_freq), 0)
+          + coalesce(octet_length(tags), 0) + coalesce(octet_length(metadata), 0) AS bytes
+      FROM ax_semantic_items
+    `);
+    const plan = planRetention(rows.map((row) => ({
+      id: { kind: row.kind, id: row.id },
+      updatedAt: row.updated_at,
+      expiresAt: row.expires_at ?? undefined,
+      bytes: Number(row.bytes),
+    })), policy, now);
+    await this.deleteRows(pool, plan.remove);
+    const cutoff = new Date(now.getTime() - (policy.historyMaxAgeMs ?? DEFAULT_HISTORY_MAX_AGE_MS)).toISOString();
+    await pool.query(`DELETE FROM ax_memory_history WHERE valid_to IS NOT NULL AND valid_to < $1`, [cutoff]);
+    await pool.query(`DELETE FROM ax_semantic_history WHERE valid_to IS NOT NULL AND valid_to < $1`, [cutoff]);
+    return plan.result;
+  }
+
+  async enforceMemoryQuota(agentId: string, quota: MemoryQuota, now = new Date()): Promise<MemoryQuotaResult> {
+    const pool = await this.pool();
+    const { rows } = await pool.query<{ kind: 'memory' | 'semantic'; id: string; key: string; namespace: string; updated_at: string; expires_at: string | null; importance: number | null; bytes: number }>(`
+      SELECT 'memory' AS kind, id, key, namespace, updated_at, expires_at, importance, octet_length(value) AS bytes
+      FROM ax_memory_items WHERE agent_id = $1
+      UNION ALL
+      SELECT 'semantic' AS kind, id, key, namespace, updated_at, expires_at, importance,
+        octet_length(content) + coalesce(octet_length(token_freq), 0)
+          + coalesce(octet_length(tags), 0) + coalesce(octet_length(metadata), 0) AS bytes
+      FROM ax_semantic_items WHERE agent_id = $1
+    `, [agentId]);
+    const plan = planQuota(rows.map((row) => ({
+      id: row,
+      updatedAt: row.updated_at,
+      expiresAt: row.expires_at ?? undefined,
+      importance: row.importance ?? undefined,
+      bytes: Number(row.bytes),
+    })), quota, now);
+    await this.deleteRows(pool, plan.remove);
+    return {
+      agentId,
+      evicted: plan.remove.map((row) => ({ kind: row.kind, key: row.key, ...(row.namespace !== 'default' ? { namespace: row.namespace } : {}) })),
+      remaining: plan.remaining,
+    };
+  }
+
+  // -------------------------------------------------------------------------
+  // Policies, agents (local)
+  // -------------------------------------------------------------------------
+
+  registerPolicy(entry: { policyId: string; name: string; enabled?: boolean; metadata?: Record<string, unknown> }): Promise<PolicyEntry> {
+    return this.local.registerPolicy(entry);
+  }
+
+  listPolicies(): Promise<PolicyEntry[]> {
+    return this.local.listPolicies();
+  }
+
+  registerAgent(entry: { agentId: string; name: string; capabilities?: string[]; metadata?: Record<string, unknown> }): Promise<AgentEntry> {
+    return this.local.registerAgent(entry);
+  }
+
+  getAgent(agentId: string): Promise<AgentEntry | undefined> {
+    return this.local.getAgent(agentId);
+  }
+
+  listAgents(): Promise<AgentEntry[]> {
+    return this.local.listAgents();
+  }
+
+  removeAgent(agentId: string): Promise<boolean> {
+    return this.local.removeAgent(agentId);
+  }
+
+  listAgentCapabilities(): Promise<string[]> {
+    return this.local.listAgentCapabilities();
+  }
+
+  // -------------------------------------------------------------------------
+  // Semantic
+  // -------------------------------------------------------------------------
+
+  async storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown>; ttlMs?: number; agentId?: string; importance?: number }): Promise<SemanticEntry> {
+    const pool = await this.pool();
+    const namespace = entry.namespace ?? 'default';
+    const tags = normalizeTags(entry.tags);
+    const date = new Date();
+    const now = date.toISOString();
+    const expiresAt = expiryFrom(entry.ttlMs, date);
+    const tokenFreq = computeTokenFreqRecord(entry.content);
+    const attribution = entryAttribution(entry);
+    await pool.query(`
+      INSERT INTO ax_semantic_items (key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance)
+      VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+      ON CONFLICT (key, namespace) DO UPDATE SET
+        content = excluded.content, token_freq = excluded.token_freq, tags = excluded.tags,
+        metadata = excluded.metadata, updated_at = excluded.updated_at, expires_at = excluded.expires_at,
+        agent_id = excluded.agent_id, importance = excluded.importance,
+        embedding = CASE WHEN ax_semantic_items.content = excluded.content THEN ax_semantic_items.embedding END,
+        embedding_model = CASE WHEN ax_semantic_items.content = excluded.content THEN ax_semantic_items.embedding_model END
+    `, [entry.key, namespace, this.seal(entry.content), this.seal(JSON.stringify(tokenFreq)), tags.join(','), entry.metadata ? JSON.stringify(entry.metadata) : null, now, expiresAt ?? null, attribution.agentId ?? null, attribution.importance ?? null]);
+    return { key: entry.key, namespace: entry.namespace, content: entry.content, tags, metadata: entry.metadata, tokenFreq, updatedAt: now, ...(expiresAt !== undefined ? { expiresAt } : {}), ...attribution };
+  }
+
+  async searchSemantic(query: string, options: SemanticSearchOptions = {}): Promise<SemanticSearchResult[]> {
+    const pool = await this.pool();
+    const minSimilarity = options.minSimilarity ?? 0;
+    const mode = options.mode ?? 'vector';
+    const queryFreq = computeTokenFreqRecord(query);
+    const params: unknown[] = [new Date().toISOString()];
+    const filters = semanticFilters(params, { namespace: options.namespace, filterTags: options.filterTags });
+
+    let ranked: SemanticSearchResult[];
+    if (mode === 'keyword') {
+      ranked = (await this.rankSemanticByKeyword(pool, query, filters, params)).map(({ entry, score }) => ({ ...entry, score, keywordScore: score }));
+    } else {
+      // Term vectors are compared in process, as on the other backends.
+      const { rows } = await pool.query<SemRow>(`SELECT ${SEM_COLUMNS} FROM ax_semantic_items s WHERE ${filters}`, params);
+      const byVector = rows
+        .map((row) => ({ row, score: tfCosineSimilarity(queryFreq, safeJsonParse<Record<string, number>>(row.token_freq === null ? null : openStored(this.cipher, row.token_freq), {})) }))
+        .filter((r) => r.score >= minSimilarity)
+        .sort((a, b) => b.score - a.score || b.row.updated_at.localeCompare(a.row.updated_at))
+        .map(({ row, score }) => ({ entry: rowToSemantic(row, this.cipher), score }));
+      ranked = mode === 'hybrid'
+        ? fuseSemanticRankings(byVector.filter((r) => r.score > 0), await this.rankSemanticByKeyword(pool, query, filters, params), options.keywordWeight)
+        : byVector.map(({ entry, score }) => ({ ...entry, score }));
+    }
+
+    return options.topK !== undefined ? ranked.slice(0, Math.max(0, options.topK)) : ranked;
+  }
+
+  // Postgres full-text ranking over key, content, and tags; any query word may match.
+  private async rankSemanticByKeyword(pool: PostgresPool, query: string, filters: string, params: unknown[]): Promise<RankedSemanticEntry[]> {
+    const terms = keywordTerms(query);
+    if (terms.length === 0) return [];
+    if (this.cipher !== undefined) {
+      const { rows } = await pool.query<SemRow>(`SELECT ${SEM_COLUMNS} FROM ax_semantic_items s WHERE ${filters}`, params);
+      return rankByBm25(query, rows.map((row) => rowToSemantic(row, this.cipher)), computeTokenFreqRecord).slice(0, 200);
+    }
+    // plainto_tsquery per word, OR-ed together, so no query text is parsed as operators.
+    const termParams = [...params, ...terms];
+    const match = `(${terms.map((_, index) => `plainto_tsquery('english', $${params.length + index + 1})`).join(' || ')})`;
+    const { rows } = await pool.query<SemRow & { rank: number }>(`
+      SELECT ${SEM_COLUMNS}, ts_rank_cd(s.search, ${match}) AS rank
+      FROM ax_semantic_items s
+      WHERE s.search @@ ${match} AND ${filters}
+      ORDER BY rank DESC, s.updated_at DESC LIMIT 200
+    `, termParams);
+    return rows.map((row) => ({ entry: rowToSemantic(row, this.cipher), score: Number(Number(row.rank).toFixed(4)) }));
+  }
+
+  private async listUnembeddedSemantic(model: string, options: { namespace?: string; filterTags?: string[] } = {}): Promise<SemanticEntry[]> {
+    const pool = await this.pool();
+    const params: unknown[] = [new Date().toISOString()];
+    const filters = semanticFilters(params, options);
+    params.push(model);
+    const { rows } = await pool.query<SemRow>(
+      `SELECT ${SEM_COLUMNS} FROM ax_semantic_items s WHERE ${filters} AND s.embedding_model IS DISTINCT FROM $${params.length}`,
+      params,
+    );
+    return rows.map((row) => rowToSemantic(row, this.cipher));
+  }
+
+  private async storeSemanticEmbeddings(model: string, entries: Array<{ key: string; namespace?: string; vector: number[] }>): Promise<void> {
+    const pool = await this.pool();
+    for (const entry of entries) {
+      await pool.query(
+        `UPDATE ax_semantic_items SET embedding = $1::vector, embedding_model = $2 WHERE key = $3 AND namespace = $4`,
+        [toVectorLiteral(entry.vector), model, entry.key, entry.namespace ?? 'default'],
+      );
+    }
+  }
+
+  // Cosine distance through pgvector; only vectors from `model` are compared, so dimensions always match.
+  private async searchSemanticByEmbedding(model: string, vector: number[], options: SemanticVectorSearchOptions = {}): Promise<SemanticSearchResult[]> {
+    const pool = await this.pool();
+    const params: unknown[] = [new Date().toISOString()];
+    const filters = semanticFilters(params, options);
+    params.push(model, toVectorLiteral(vector));
+    const distance = `s.embedding <=> $${params.length}::vector`;
+    let sql = `
+      SELECT ${SEM_COLUMNS}, 1 - (${distance}) AS similarity
+      FROM ax_semantic_items s
+      WHERE s.embedding_model = $${params.length - 1} AND ${filters}
+      ORDER BY ${distance}, s.updated_at DESC
+    `;
+    if (options.limit !== undefined) { params.push(Math.max(0, options.limit)); sql += ` LIMIT $${params.length}`; }
+    const { rows } = await pool.query<SemRow & { similarity: number }>(sql, params);
+    return rows
+      .map((row) => ({ ...rowToSemantic(row, this.cipher), score: Number(Number(row.similarity).toFixed(6)) }))
+      .filter((entry) => entry.score >= (options.minSimilarity ?? 0));
+  }
+
+  async getSemantic(key: string, namespace?: string): Promise<SemanticEntry | undefined> {
+    const pool = await this.pool();
+    const { rows } = await pool.query<SemRow>(
+      `SELECT ${SEM_COLUMNS} FROM ax_semantic_items s WHERE s.key = $1 AND s.namespace = $2 AND ${live('$3', 's.')}`,
+      [key, namespace ?? 'default', new Date().toISOString()],
+    );
+    return rows[0] !== undefined ? rowToSemantic(rows[0], this.cipher) : undefined;
+  }
+
+  async listSemantic(options: { namespace?: string; keyPrefix?: string; filterTags?: string[]; limit?: number } = {}): Promise<SemanticEntry[]> {
+    const pool = await this.pool();
+    const params: unknown[] = [new Date().toISOString()];
+    let sql = `SELECT ${SEM_COLUMNS} FROM ax_semantic_items s WHERE ${semanticFilters(params, options)}`;
+    if (options.keyPrefix !== undefined) { params.push(`${escapeLike(options.keyPrefix)}%`); sql += ` AND s.key LIKE $${params.length}`; }
+    sql += ` ORDER BY s.updated_at DESC`;
+    if (options.limit !== undefined) { params.push(Math.max(0, options.limit)); sql += ` LIMIT $${params.length}`; }
+    const { rows } = await pool.query<SemRow>(sql, params);
+    return rows.map((row) => rowToSemantic(row, this.cipher));
+  }
+
+  async deleteSemantic(key: string, namespace?: string): Promise<boolean> {
+    const pool = await this.pool();
+    const result = await pool.query(`DELETE FROM ax_semantic_items WHERE key = $1 AND namespace = $2`, [key, namespace ?? 'default']);
+    return (result.rowCount ?? 0) > 0;
+  }
+
+  async clearSemantic(namespace: string): Promise<number> {
+    const pool = await this.pool();
+    return (await pool.query(`DELETE FROM ax_semantic_items WHERE namespace = $1`, [namespace])).rowCount ?? 0;
+  }
+
+  async semanticStats(namespace?: string): Promise<SemanticNamespaceStats[]> {
+    const pool = await this.pool();
+    const params: unknown[] = [new Date().toISOString()];
+    const filters = semanticFilters(params, { namespace });
+    const { rows } = await pool.query<{ namespace: string; tags: string | null; updated_at: string }>(
+      `SELECT s.namespace, s.tags, s.updated_at FROM ax_semantic_items s WHERE ${filters}`,
+      params,
+    );
+
+    const byNs = new Map<string, { tagLists: string[][]; last?: string }>();
+    for (const row of rows) {
+      const stats = byNs.get(row.namespace) ?? { tagLists: [] };
+      stats.tagLists.push(row.tags ? row.tags.split(',').filter((t) => t.length > 0) : []);
+      if (stats.last === undefined || row.updated_at > stats.last) stats.last = row.updated_at;
+      byNs.set(row.namespace, stats);
+    }
+
+    return [...byNs.entries()]
+      .sort(([a], [b]) => a.localeCompare(b))
+      .map(([ns, { tagLists, last }]) => {
+        const all = tagLists.flat();
+        return { namespace: ns, totalItems: tagLists.length, totalTags: all.length, uniqueTags: new Set(all).size, lastUpdatedAt: last };
+      });
+  }
+
+  // -------------------------------------------------------------------------
+  // Feedback, sessions (local)
+  // -------------------------------------------------------------------------
+
+  submitFeedback(entry: Parameters<StateStore['submitFeedback']>[0]): Promise<FeedbackEntry> {
+    return this.local.submitFeedback(entry);
+  }
+
+  listFeedback(options?: { agentId?: string; limit?: number; since?: string }): Promise<FeedbackEntry[]> {
+    return this.local.listFeedback(options);
+  }
+
+  createSession(entry: { sessionId?: string; task: string; initiator: string; workspace?: string; metadata?: Record<string, unknown> }): Promise<SessionEntry> {
+    return this.local.createSession(entry);
+  }
+
+  getSession(sessionId: string): Promise<SessionEntry | undefined> {
+    return this.local.getSession(sessionId);
+  }
+
+  listSessions(): Promise<SessionEntry[]> {
+    return this.local.listSessions();
+  }
+
+  joinSession(entry: { sessionId: string; agentId: string; role?: SessionParticipantRole }): Promise<SessionEntry> {
+    return this.local.joinSession(entry);
+  }
+
+  leaveSession(sessionId: string, agentId: string): Promise<SessionEntry> {
+    return this.local.leaveSession(sessionId, agentId);
+  }
+
+  completeSession(sessionId: string, summary?: string): Promise<SessionEntry> {
+    return this.local.completeSession(sessionId, summary);
+  }
+
+  failSession(sessionId: string, message: string): Promise<SessionEntry> {
+    return this.local.failSession(sessionId, message);
+  }
+
+  closeStuckSessions(maxAgeMs?: number): Promise<SessionEntry[]> {
+    return this.local.closeStuckSessions(maxAgeMs);
+  }
+
+  // -------------------------------------------------------------------------
+  // Connection
+  // -------------------------------------------------------------------------
+
+  // The pool, migrated once per store; a failed attempt is retried on the next call.
+  private pool(): Promise<PostgresPool> {
+    if (this.ready === undefined) {
+      this.ready = this.migrate().then(
+        () => this.config.pool ?? this.connect(),
+        (error: unknown) => {
+          this.ready = undefined;
+          throw error;
+        },
+      );
+    }
+    return this.ready;
+  }
+
+  private connect(): Promise<PostgresPool> {
+    this.connecting ??= openPool(this.config.connectionString!, this.config.maxConnections ?? DEFAULT_MAX_CONNECTIONS).catch((error: unknown) => {
+      this.connecting = undefined;
+      throw error;
+    });
+    return this.connecting;
+  }
+
+  private async deleteRows(pool: PostgresPool, rows: Array<{ kind: 'memory' | 'semantic'; id: string }>): Promise<void> {
+    const memoryIds = rows.filter((row) => row.kind === 'memory').map((row) => row.id);
+    const semanticIds = rows.filter((row) => row.kind === 'semantic').map((row) => row.id);
+    if (memoryIds.length === 0 && semanticIds.length === 0) return;
+    // One statement, so a prune never lands half applied.
+    await pool.query(`
+      WITH removed_memory AS (DELETE FROM ax_memory_items WHERE id = ANY($1::bigint[]))
+      DELETE FROM ax_semantic_items WHERE id = ANY($2::bigint[])
+    `, [memoryIds, semanticIds]);
+  }
+
+  // Content columns hold ciphertext when an encryption key is configured.
+  private seal(plaintext: string): string {
+    return this.cipher !== undefined ? this.cipher.seal(plaintext) : plaintext;
+  }
+}
+
+export function createPostgresStateStore(config: PostgresStateStoreConfig): PostgresStateStore {
+  return new PostgresStateStore(config);
+}
+
+// Reads skip expired rows; the pruner deletes them.
+function live(now: string, alias = ''): string {
+  return `(${alias}expires_at IS NULL OR ${alias}expires_at > ${now})`;
+}
+
+// Appends namespace and tag filters to `params`, whose first value is the current time.
+function semanticFilters(params: unknown[], options: { namespace?: string; filterTags?: string[] }): string {
+  let filters = live('$1', 's.');
+  if (options.namespace !== undefined) { params.push(options.namespace); filters += ` AND s.namespace = $${params.length}`; }
+  for (const tag of normalizeTags(options.filterTags)) {
+    params.push(`%,${escapeLike(tag)},%`);
+    filters += ` AND (',' || s.tags || ',') LIKE $${params.length}`;
+  }
+  return filters;
+}
+
+// pgvector's text form, e.g. `[0.1,0.2]`.
+function toVectorLiteral(vector: number[]): string {
+  return `[${vector.join(',')}]`;
+}
+
+function escapeLike(value: string): string {
+  return value.replace(/[\\%_]/g, (match) => `\\${match}`);
+}
+
+async function openPool(connectionString: string, max: number): Promise<PostgresPool> {
+  let pg: { Pool?: PostgresPoolConstructor; default?: { Pool?: PostgresPoolConstructor } };
+  try {
+    pg = await import(PG_MODULE);
+  } catch {
+    throw new Error('The postgres memory backend needs the "pg" package; install it with `npm install pg`');
+  }
+  const Pool = pg.Pool ?? pg.default?.Pool;
+  if (Pool === undefined) {
+    throw new Error('The "pg" package does not export a Pool');
+  }
+  // Idle connections must not keep one-shot CLI commands alive.
+  return new Pool({ connectionString, max, allowExitOnIdle: true });
+}
+
+type PostgresPoolConstructor = new (options: { connectionString: string; max: number; allowExitOnIdle: boolean }) => PostgresPool;
@@ -0,0 +1,115 @@
+// Replaced versions kept for as-of queries are dropped this long after they
+// stopped being current, unless the policy says otherwise.
+export const DEFAULT_HISTORY_MAX_AGE_MS = 30 * 24 * 60 * 60 * 1000;
+// Entries stored without an importance sit in the middle of the range.
+export const DEFAULT_MEMORY_IMPORTANCE = 0.5;
+const IMPORTANCE_HALF_LIFE_MS = 7 * 24 * 60 * 60 * 1000;
+export function expiryFrom(ttlMs, now) {
+    if (ttlMs === undefined || !Number.isFinite(ttlMs) || ttlMs <= 0) {
+        return undefined;
+    }
+    return new Date(now.getTime() + ttlMs).toISOString();
+}
+// An as-of time in the stores' timestamp format, so it compares with version timestamps as a string.
+export function asOfTimestamp(asOf) {
+    const time = Date.parse(asOf);
+    if (Number.isNaN(time)) {
+        throw new Error(`Invalid as-of time: ${asOf}; expected an ISO 8601 timestamp`);
+    }
+    return new Date(time).toISOString();
+}
+export function isExpired(entry, now) {
+    return entry.expiresAt !== undefined && entry.expiresAt <= now.toISOString();
+}
+/**
+ * Decides which entries a prune removes: first those past their TTL, then
+ * those not updated within `maxAgeMs`, then the least recently updated until
+ * the rest fit within `maxEntries` and `maxBytes`. Key-value and semantic
+ * entries share the limits, so callers pass both kinds together.
+ */
+export function planRetention(candidates, policy, now) {
+    const ageCutoff = policy.maxAgeMs !== undefined && policy.maxAgeMs > 0
+        ? new Date(now.getTime() - policy.maxAgeMs).toISOString()
+        : undefined;
+    const remove = [];
+    const kept = [];
+    let expired = 0;
+    let aged = 0;
+    for (const candidate of candidates) {
+        if (isExpired(candidate, now)) {
+            expired += 1;
+            remove.push(candidate.id);
+        }
+        else if (ageCutoff !== undefined && candidate.updatedAt < ageCutoff) {
+            aged += 1;
+            remove.push(candidate.id);
+        }
+        else {
+            kept.push(candidate);
+        }
+    }
+    kept.sort((left, right) => left.updatedAt.localeCompare(right.updatedAt));
+    let entries = kept.length;
+    let bytes = kept.reduce((total, candidate) => total + candidate.bytes, 0);
+    let evicted = 0;
+    for (const candidate of kept) {
+        const overEntries = policy.maxEntries !== undefined && entries > policy.maxEntries;
+        const overBytes = policy.maxBytes !== undefined && bytes > policy.maxBytes;
+        if (!overEntries && !overBytes) {
+            break;
+        }
+        evicted += 1;
+        entries -= 1;
+        bytes -= candidate.bytes;
+        remove.push(candidate.id);
+    }
+    return { remove, result: { expired, aged, evicted, remaining: { entries, bytes } } };
+}
+/**
+ * Decides which of one agent's entries a quota evicts. Entries past their TTL
+ * go first, then the rest in eviction order until both caps hold: oldest
+ * write first for `oldest-write`, or lowest importance for `importance`, where an
+ * entry's importance halves for every week since its last update.
+ */
+export function planQuota(candidates, quota, now) {
+    const remove = [];
+    const kept = [];
+    for (const candidate of candidates) {
+        if (isExpired(candidate, now)) {
+            remove.push(candidate.id);
+        }
+        else {
+            kept.push({ ...candidate, score: quota.eviction === 'importance' ? decayedImportance(candidate, now) : 0 });
+        }
+    }
+    kept.sort((left, right) => left.score - right.score || left.updatedAt.localeCompare(right.updatedAt));
+    let entries = kept.length;
+    let bytes = kept.reduce((total, candidate) => total + candidate.bytes, 0);
+    for (const candidate of kept) {
+        const overEntries = quota.maxEntries !== undefined && entries > quota.maxEntries;
+        const overBytes = quota.maxBytes !== undefined && bytes > quota.maxBytes;
+        if (!overEntries && !overBytes) {
+            break;
+        }
+        entries -= 1;
+        bytes -= candidate.bytes;
+        remove.push(candidate.id);
+    }
+    return { remove, remaining: { entries, bytes } };
+}
+// The agent and importance a store call attributes an entry to, with
+// importance clamped to 0..1 and dropped when it is not a number.
+export function entryAttribution(entry) {
+    const agentId = entry.agentId?.trim();
+    const importance = typeof entry.importance === 'number' && Number.isFinite(entry.importance)
+        ? Math.max(0, Math.min(1, entry.importance))
+        : undefined;
+    return {
+        ...(agentId !== undefined && agentId.length > 0 ? { agentId } : {}),
+        ...(importance !== undefined ? { importance } : {}),
+    };
+}
+function decayedImportance(candidate, now) {
+    const ageMs = Math.max(0, now.getTime() - Date.parse(candidate.updatedAt));
+    return (candidate.importance ?? DEFAULT_MEMORY_IMPORTANCE) * 0.5 ** (ageMs / IMPORTANCE_HALF_LIFE_MS);
+}
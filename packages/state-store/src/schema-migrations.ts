@@ -0,0 +1,73 @@
+// Versioned schema migrations shared by the SQLite and Postgres memory backends.
+
+export interface SchemaMigrationStep {
+  version: number;
+  name: string;
+}
+
+export interface SchemaMigrationOptions {
+  // Version to migrate to; below the current one rolls back. Defaults to the latest.
+  to?: number;
+  // Plan only: report the steps without changing the database.
+  dryRun?: boolean;
+}
+
+export interface SchemaMigrationResult {
+  backend: 'sqlite' | 'postgres';
+  from: number;
+  to: number;
+  direction: 'up' | 'down' | 'none';
+  // Applied in this order, or with `dryRun`, the order they would be.
+  steps: SchemaMigrationStep[];
+  dryRun: boolean;
+  // Copy of the database taken before changing it, where the backend can make one.
+  backupPath?: string;
+}
+
+export interface SchemaMigrationPlan {
+  from: number;
+  to: number;
+  direction: 'up' | 'down' | 'none';
+  steps: SchemaMigrationStep[];
+}
+
+/**
+ * Works out which migrations take the schema from its applied versions to
+ * `options.to`. A database at a version this code doesn't know was written by
+ * a newer AutomatosX, so it is refused rather than read or written with a
+ * schema that doesn't match. Rolling back needs every version above the
+ * target to have a down step.
+ */
+export function planSchemaMigrations(
+  migrations: Array<SchemaMigrationStep & { reversible: boolean }>,
+  appliedVersions: number[],
+  options: SchemaMigrationOptions = {},
+): SchemaMigrationPlan {
+  const known = [...migrations].sort((left, right) => left.version - right.version);
+  const latest = known.at(-1)?.version ?? 0;
+  const applied = new Set(appliedVersions);
+  const from = Math.max(0, ...appliedVersions);
+  const unknown = appliedVersions.filter((version) => !known.some((migration) => migration.version === version));
+  if (unknown.length > 0) {
+    throw new Error(`Memory schema is at v${from}, newer than this version of AutomatosX supports (v${latest}). Upgrade AutomatosX, or roll the schema back with the newer version's \`ax memory migrate --to ${latest}\`.`);
+  }
+  const to = options.to ?? latest;
+  if (!Number.isInteger(to) || to < 1 || to > latest) {
+    throw new Error(`Memory schema version must be between 1 and ${latest}, not ${to}`);
+  }
+
+  if (to >= from) {
+    const steps = known.filter((migration) => migration.version <= to && !applied.has(migration.version));
+    return { from, to, direction: steps.length > 0 ? 'up' : 'none', steps: steps.map(toStep) };
+  }
+  const steps = known.filter((migration) => migration.version > to && applied.has(migration.version)).reverse();
+  const irreversible = steps.find((migration) => !migration.reversible);
+  if (irreversible !== undefined) {
+    throw new Error(`Memory schema migration v${irreversible.version} (${irreversible.name}) cannot be rolled back`);
+  }
+  return { from, to, direction: 'down', steps: steps.map(toStep) };
+}
+
+function toStep(migration: SchemaMigrationStep): SchemaMigrationStep {
+  return { version: migration.version, name: migration.name };
+}
@@ -0,0 +1,221 @@
+// Joins a scope and a namespace in the underlying store, e.g. `team-a/web::decisions`.
+export const MEMORY_SCOPE_SEPARATOR = '::';
+/**
+ * Wraps a store so memory and semantic entries live under a project or team
+ * scope. Namespaces are stored as `<scope>::<namespace>` and handed back
+ * without the prefix; reads that don't name a namespace only see the scope's
+ * entries. Without a scope the wrapped store is used as is, which also makes
+ * it the view across every scope. Agents, policies, feedback, sessions,
+ * retention, and per-agent quotas stay shared.
+ */
+export class ScopedStateStore {
+    store;
+    scope;
+    vectorIndex;
+    constructor(store, scope) {
+        this.store = store;
+        this.scope = scope;
+        if (store.vectorIndex !== undefined) {
+            this.vectorIndex = this.scopeVectorIndex(store.vectorIndex);
+        }
+    }
+    scopeVectorIndex(index) {
+        return {
+            listUnembedded: async (model, options = {}) => {
+                const scope = await this.resolveScope();
+                if (scope === undefined) return index.listUnembedded(model, options);
+                return withinScope(scope, await index.listUnembedded(model, { ...options, namespace: options.namespace === undefined ? undefined : toScope(scope, options.namespace) }));
+            },
+            store: async (model, entries) => {
+                const scope = await this.resolveScope();
+                if (scope === undefined) return index.store(model, entries);
+                return index.store(model, entries.map((entry) => ({ ...entry, namespace: toScope(scope, entry.namespace) })));
+            },
+            search: async (model, vector, options = {}) => {
+                const scope = await this.resolveScope();
+                if (scope === undefined) return index.search(model, vector, options);
+                if (options.namespace !== undefined) {
+                    return withinScope(scope, await index.search(model, vector, { ...options, namespace: toScope(scope, options.namespace) }));
+                }
+                const results = withinScope(scope, await index.search(model, vector, { ...options, limit: undefined }));
+                return options.limit === undefined ? results : results.slice(0, Math.max(0, options.limit));
+            },
+        };
+    }
+    async storeMemory(entry) {
+        const scope = await this.resolveScope();
+        if (scope === undefined)
+            return this.store.storeMemory(entry);
+        return fromScope(scope, await this.store.storeMemory({ ...entry, namespace: toScope(scope, entry.namespace) }));
+    }
+    async getMemory(key, namespace) {
+        const scope = await this.resolveScope();
+        if (scope === undefined)
+            return this.store.getMemory(key, namespace);
+        const entry = await this.store.getMemory(key, toScope(scope, namespace));
+        return entry === undefined ? undefined : fromScope(scope, entry);
+    }
+    async searchMemory(query, namespace, options) {
+        const scope = await this.resolveScope();
+        if (scope === undefined)
+            return this.store.searchMemory(query, namespace, options);
+        const entries = await this.store.searchMemory(query, namespace === undefined ? undefined : toScope(scope, namespace), options);
+        return withinScope(scope, entries);
+    }
+    async deleteMemory(key, namespace) {
+        const scope = await this.resolveScope();
+        return this.store.deleteMemory(key, scope === undefined ? namespace : toScope(scope, namespace));
+    }
+    async listMemory(namespace) {
+        const scope = await this.resolveScope();
+        if (scope === undefined)
+            return this.store.listMemory(namespace);
+        return withinScope(scope, await this.store.listMemory(namespace === undefined ? undefined : toScope(scope, namespace)));
+    }
+    async memoryAsOf(asOf, options = {}) {
+        const scope = await this.resolveScope();
+        if (scope === undefined) return this.store.memoryAsOf(asOf, options);
+        const result = await this.store.memoryAsOf(asOf, { ...options, namespace: options.namespace === undefined ? undefined : toScope(scope, options.namespace) });
+        return { asOf: result.asOf, memory: withinScope(scope, result.memory), semantic: withinScope(scope, result.semantic) };
+    }
+    pruneMemory(policy, now) {
+        return this.store.pruneMemory(policy, now);
+    }
+    enforceMemoryQuota(agentId, quota, now) {
+        return this.store.enforceMemoryQuota(agentId, quota, now);
+    }
+    registerPolicy(entry) {
+        return this.store.registerPolicy(entry);
+    }
+    listPolicies() {
+        return this.store.listPolicies();
+    }
+    registerAgent(entry) {
+        return this.store.registerAgent(entry);
+    }
+    getAgent(agentId) {
+        return this.store.getAgent(agentId);
+    }
+    listAgents() {
+        return this.store.listAgents();
+    }
+    removeAgent(agentId) {
+        return this.store.removeAgent(agentId);
+    }
+    listAgentCapabilities() {
+        return this.store.listAgentCapabilities();
+    }
+    async storeSemantic(entry) {
+        const scope = await this.resolveScope();
+        if (scope === undefined)
+            return this.store.storeSemantic(entry);
+        return fromScope(scope, await this.store.storeSemantic({ ...entry, namespace: toScope(scope, entry.namespace) }));
+    }
+    async searchSemantic(query, options = {}) {
+        const scope = await this.resolveScope();
+        if (scope === undefined)
+            return this.store.searchSemantic(query, options);
+        if (options.namespace !== undefined) {
+            return withinScope(scope, await this.store.searchSemantic(query, { ...options, namespace: toScope(scope, options.namespace) }));
+        }
+        // Other scopes' entries are filtered out here, so topK can only be applied afterwards.
+        const results = withinScope(scope, await this.store.searchSemantic(query, { ...options, topK: undefined }));
+        return options.topK === undefined ? results : results.slice(0, Math.max(0, options.topK));
+    }
+    async getSemantic(key, namespace) {
+        const scope = await this.resolveScope();
+        if (scope === undefined)
+            return this.store.getSemantic(key, namespace);
+        const entry = await this.store.getSemantic(key, toScope(scope, namespace));
+        return entry === undefined ? undefined : fromScope(scope, entry);
+    }
+    async listSemantic(options = {}) {
+        const scope = await this.resolveScope();
+        if (scope === undefined)
+            return this.store.listSemantic(options);
+        if (options.namespace !== undefined) {
+            return withinScope(scope, await this.store.listSemantic({ ...options, namespace: toScope(scope, options.namespace) }));
+        }
+        const entries = withinScope(scope, await this.store.listSemantic({ ...options, limit: undefined }));
+        return options.limit === undefined ? entries : entries.slice(0, Math.max(0, options.limit));
+    }
+    async deleteSemantic(key, namespace) {
+        const scope = await this.resolveScope();
+        return this.store.deleteSemantic(key, scope === undefined ? namespace : toScope(scope, namespace));
+    }
+    async clearSemantic(namespace) {
+        const scope = await this.resolveScope();
+        return this.store.clearSemantic(scope === undefined ? namespace : toScope(scope, namespace));
+    }
+    async semanticStats(namespace) {
+        const scope = await this.resolveScope();
+        if (scope === undefined)
+            return this.store.semanticStats(namespace);
+        const stats = await this.store.semanticStats(namespace === undefined ? undefined : toScope(scope, namespace));
+        const prefix = `${scope}${MEMORY_SCOPE_SEPARATOR}`;
+        return stats
+            .filter((entry) => entry.namespace.startsWith(prefix))
+            .map((entry) => ({ ...entry, namespace: entry.namespace.slice(prefix.length) }));
+    }
+    submitFeedback(entry) {
+        return this.store.submitFeedback(entry);
+    }
+    listFeedback(options) {
+        return this.store.listFeedback(options);
+    }
+    createSession(entry) {
+        return this.store.createSession(entry);
+    }
+    getSession(sessionId) {
+        return this.store.getSession(sessionId);
+    }
+    listSessions() {
+        return this.store.listSessions();
+    }
+    joinSession(entry) {
+        return this.store.joinSession(entry);
+    }
+    leaveSession(sessionId, agentId) {
+        return this.store.leaveSession(sessionId, agentId);
+    }
+    completeSession(sessionId, summary) {
+        return this.store.completeSession(sessionId, summary);
+    }
+    failSession(sessionId, message) {
+        return this.store.failSession(sessionId, message);
+    }
+    closeStuckSessions(maxAgeMs) {
+        return this.store.closeStuckSessions(maxAgeMs);
+    }
+    async resolveScope() {
+        const scope = typeof this.scope === 'function' ? await this.scope() : this.scope;
+        return normalizeMemoryScope(scope);
+    }
+}
+export function createScopedStateStore(store, scope) {
+    return new ScopedStateStore(store, scope);
+}
+// A usable scope, or undefined for blank values. The separator is reserved.
+export function normalizeMemoryScope(scope) {
+    const trimmed = scope?.trim();
+    if (trimmed === undefined || trimmed.length === 0) {
+        return undefined;
+    }
+    if (trimmed.includes(MEMORY_SCOPE_SEPARATOR)) {
+        throw new Error(`Memory scope "${trimmed}" must not contain "${MEMORY_SCOPE_SEPARATOR}".`);
+    }
+    return trimmed;
+}
+function toScope(scope, namespace) {
+    return `${scope}${MEMORY_SCOPE_SEPARATOR}${namespace ?? 'default'}`;
+}
+function fromScope(scope, entry) {
+    const namespace = entry.namespace?.slice(scope.length + MEMORY_SCOPE_SEPARATOR.length);
+    return { ...entry, namespace: namespace === 'default' ? undefined : namespace };
+}
+function withinScope(scope, entries) {
+    const prefix = `${scope}${MEMORY_SCOPE_SEPARATOR}`;
+    return entries
+        .filter((entry) => entry.namespace?.startsWith(prefix) === true)
+        .map((entry) => fromScope(scope, entry));
+}
@@ -0,0 +1,276 @@
+import type {
+  AgentEntry,
+  FeedbackEntry,
+  MemoryAsOf,
+  MemoryEntry,
+  MemoryPruneResult,
+  MemorySearchOptions,
+  MemoryQuota,
+  MemoryQuotaResult,
+  MemoryRetentionPolicy,
+  PolicyEntry,
+  SemanticEntry,
+  SemanticNamespaceStats,
+  SemanticSearchOptions,
+  SemanticSearchResult,
+  SemanticVectorIndex,
+  SessionEntry,
+  SessionParticipantRole,
+  StateStore,
+} from './index.js';
+
+// Joins a scope and a namespace in the underlying store, e.g. `team-a/web::decisions`.
+export const MEMORY_SCOPE_SEPARATOR = '::';
+
+export type MemoryScopeResolver = string | undefined | (() => Promise<string | undefined>);
+
+/**
+ * Wraps a store so memory and semantic entries live under a project or team
+ * scope. Namespaces are stored as `<scope>::<namespace>` and handed back
+ * without the prefix; reads that don't name a namespace only see the scope's
+ * entries. Without a scope the wrapped store is used as is, which also makes
+ * it the view across every scope. Agents, policies, feedback, sessions,
+ * retention, and per-agent quotas stay shared.
+ */
+export class ScopedStateStore implements StateStore {
+  private readonly store: StateStore;
+  private readonly scope: MemoryScopeResolver;
+  readonly vectorIndex?: SemanticVectorIndex;
+
+  constructor(store: StateStore, scope: MemoryScopeResolver) {
+    this.store = store;
+    this.scope = scope;
+    if (store.vectorIndex !== undefined) {
+      this.vectorIndex = this.scopeVectorIndex(store.vectorIndex);
+    }
+  }
+
+  private scopeVectorIndex(index: SemanticVectorIndex): SemanticVectorIndex {
+    return {
+      listUnembedded: async (model, options = {}) => {
+        const scope = await this.resolveScope();
+        if (scope === undefined) return index.listUnembedded(model, options);
+        return withinScope(scope, await index.listUnembedded(model, { ...options, namespace: options.namespace === undefined ? undefined : toScope(scope, options.namespace) }));
+      },
+      store: async (model, entries) => {
+        const scope = await this.resolveScope();
+        if (scope === undefined) return index.store(model, entries);
+        return index.store(model, entries.map((entry) => ({ ...entry, namespace: toScope(scope, entry.namespace) })));
+      },
+      search: async (model, vector, options = {}) => {
+        const scope = await this.resolveScope();
+        if (scope === undefined) return index.search(model, vector, options);
+        if (options.namespace !== undefined) {
+          return withinScope(scope, await index.search(model, vector, { ...options, namespace: toScope(scope, options.namespace) }));
+        }
+        const results = withinScope(scope, await index.search(model, vector, { ...options, limit: undefined }));
+        return options.limit === undefined ? results : results.slice(0, Math.max(0, options.limit));
+      },
+    };
+  }
+
+  async storeMemory(entry: { key: string; namespace?: string; value: unknown; tags?: string[]; ttlMs?: number; agentId?: string; importance?: number }): Promise<MemoryEntry> {
+    const scope = await this.resolveScope();
+    if (scope === undefined) return this.store.storeMemory(entry);
+    return fromScope(scope, await this.store.storeMemory({ ...entry, namespace: toScope(scope, entry.namespace) }));
+  }
+
+  async getMemory(key: string, namespace?: string): Promise<MemoryEntry | undefined> {
+    const scope = await this.resolveScope();
+    if (scope === undefined) return this.store.getMemory(key, namespace);
+    const entry = await this.store.getMemory(key, toScope(scope, namespace));
+    return entry === undefined ? undefined : fromScope(scope, entry);
+  }
+
+  async searchMemory(query: string, namespace?: string, options?: MemorySearchOptions): Promise<MemoryEntry[]> {
+    const scope = await this.resolveScope();
+    if (scope === undefined) return this.store.searchMemory(query, namespace, options);
+    const entries = await this.store.searchMemory(query, namespace === undefined ? undefined : toScope(scope, namespace), options);
+    return withinScope(scope, entries);
+  }
+
+  async deleteMemory(key: string, namespace?: string): Promise<boolean> {
+    const scope = await this.resolveScope();
+    return this.store.deleteMemory(key, scope === undefined ? namespace : toScope(scope, namespace));
+  }
+
+  async listMemory(namespace?: string): Promise<MemoryEntry[]> {
+    const scope = await this.resolveScope();
+    if (scope === undefined) return this.store.listMemory(namespace);
+    return withinScope(scope, await this.store.listMemory(namespace === undefined ? undefined : toScope(scope, namespace)));
+  }
+
+  async memoryAsOf(asOf: string, options: { namespace?: string; key?: string } = {}): Promise<MemoryAsOf> {
+    const scope = await this.resolveScope();
+    if (scope === undefined) return this.store.memoryAsOf(asOf, options);
+    const result = await this.store.memoryAsOf(asOf, { ...options, namespace: options.namespace === undefined ? undefined : toScope(scope, options.namespace) });
+    return { asOf: result.asOf, memory: withinScope(scope, result.memory), semantic: withinScope(scope, result.semantic) };
+  }
+
+  pruneMemory(policy?: MemoryRetentionPolicy, now?: Date): Promise<MemoryPruneResult> {
+    return this.store.pruneMemory(policy, now);
+  }
+
+  enforceMemoryQuota(agentId: string, quota: MemoryQuota, now?: Date): Promise<MemoryQuotaResult> {
+    return this.store.enforceMemoryQuota(agentId, quota, now);
+  }
+
+  registerPolicy(entry: { policyId: string; name: string; enabled?: boolean; metadata?: Record<string, unknown> }): Promise<PolicyEntry> {
+    return this.store.registerPolicy(entry);
+  }
+
+  listPolicies(): Promise<PolicyEntry[]> {
+    return this.store.listPolicies();
+  }
+
+  registerAgent(entry: { agentId: string; name: string; capabilities?: string[]; metadata?: Record<string, unknown> }): Promise<AgentEntry> {
+    return this.store.registerAgent(entry);
+  }
+
+  getAgent(agentId: string): Promise<AgentEntry | undefined> {
+    return this.store.getAgent(agentId);
+  }
+
+  listAgents(): Promise<AgentEntry[]> {
+    return this.store.listAgents();
+  }
+
+  removeAgent(agentId: string): Promise<boolean> {
+    return this.store.removeAgent(agentId);
+  }
+
+  listAgentCapabilities(): Promise<string[]> {
+    return this.store.listAgentCapabilities();
+  }
+
+  async storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown>; ttlMs?: number; agentId?: string; importance?: number }): Promise<SemanticEntry> {
+    const scope = await this.resolveScope();
+    if (scope === undefined) return this.store.storeSemantic(entry);
+    return fromScope(scope, await this.store.storeSemantic({ ...entry, namespace: toScope(scope, entry.namespace) }));
+  }
+
+  async searchSemantic(query: string, options: SemanticSearchOptions = {}): Promise<SemanticSearchResult[]> {
+    const scope = await this.resolveScope();
+    if (scope === undefined) return this.store.searchSemantic(query, options);
+    if (options.namespace !== undefined) {
+      return withinScope(scope, await this.store.searchSemantic(query, { ...options, namespace: toScope(scope, options.namespace) }));
+    }
+    // Other scopes' entries are filtered out here, so topK can only be applied afterwards.
+    const results = withinScope(scope, await this.store.searchSemantic(query, { ...options, topK: undefined }));
+    return options.topK === undefined ? results : results.slice(0, Math.max(0, options.topK));
+  }
+
+  async getSemantic(key: string, namespace?: string): Promise<SemanticEntry | undefined> {
+    const scope = await this.resolveScope();
+    if (scope === undefined) return this.store.getSemantic(key, namespace);
+    const entry = await this.store.getSemantic(key, toScope(scope, namespace));
+    return entry === undefined ? undefined : fromScope(scope, entry);
+  }
+
+  async listSemantic(options: { namespace?: string; keyPrefix?: string; filterTags?: string[]; limit?: number } = {}): Promise<SemanticEntry[]> {
+    const scope = await this.resolveScope();
+    if (scope === undefined) return this.store.listSemantic(options);
+    if (options.namespace !== undefined) {
+      return withinScope(scope, await this.store.listSemantic({ ...options, namespace: toScope(scope, options.namespace) }));
+    }
+    const entries = withinScope(scope, await this.store.listSemantic({ ...options, limit: undefined }));
+    return options.limit === undefined ? entries : entries.slice(0, Math.max(0, options.limit));
+  }
+
+  async deleteSemantic(key: string, namespace?: string): Promise<boolean> {
+    const scope = await this.resolveScope();
+    return this.store.deleteSemantic(key, scope === undefined ? namespace : toScope(scope, namespace));
+  }
+
+  async clearSemantic(namespace: string): Promise<number> {
+    const scope = await this.resolveScope();
+    return this.store.clearSemantic(scope === undefined ? namespace : toScope(scope, namespace));
+  }
+
+  async semanticStats(namespace?: string): Promise<SemanticNamespaceStats[]> {
+    const scope = await this.resolveScope();
+    if (scope === undefined) return this.store.semanticStats(namespace);
+    const stats = await this.store.semanticStats(namespace === undefined ? undefined : toScope(scope, namespace));
+    const prefix = `${scope}${MEMORY_SCOPE_SEPARATOR}`;
+    return stats
+      .filter((entry) => entry.namespace.startsWith(prefix))
+      .map((entry) => ({ ...entry, namespace: entry.namespace.slice(prefix.length) }));
+  }
+
+  submitFeedback(entry: Parameters<StateStore['submitFeedback']>[0]): Promise<FeedbackEntry> {
+    return this.store.submitFeedback(entry);
+  }
+
+  listFeedback(options?: { agentId?: string; limit?: number; since?: string }): Promise<FeedbackEntry[]> {
+    return this.store.listFeedback(options);
+  }
+
+  createSession(entry: { sessionId?: string; task: string; initiator: string; workspace?: string; metadata?: Record<string, unknown> }): Promise<SessionEntry> {
+    return this.store.createSession(entry);
+  }
+
+  getSession(sessionId: string): Promise<SessionEntry | undefined> {
+    return this.store.getSession(sessionId);
+  }
+
+  listSessions(): Promise<SessionEntry[]> {
+    return this.store.listSessions();
+  }
+
+  joinSession(entry: { sessionId: string; agentId: string; role?: SessionParticipantRole }): Promise<SessionEntry> {
+    return this.store.joinSession(entry);
+  }
+
+  leaveSession(sessionId: string, agentId: string): Promise<SessionEntry> {
+    return this.store.leaveSession(sessionId, agentId);
+  }
+
+  completeSession(sessionId: string, summary?: string): Promise<SessionEntry> {
+    return this.store.completeSession(sessionId, summary);
+  }
+
+  failSession(sessionId: string, message: string): Promise<SessionEntry> {
+    return this.store.failSession(sessionId, message);
+  }
+
+  closeStuckSessions(maxAgeMs?: number): Promise<SessionEntry[]> {
+    return this.store.closeStuckSessions(maxAgeMs);
+  }
+
+  private async resolveScope(): Promise<string | undefined> {
+    const scope = typeof this.scope === 'function' ? await this.scope() : this.scope;
+    return normalizeMemoryScope(scope);
+  }
+}
+
+export function createScopedStateStore(store: StateStore, scope: MemoryScopeResolver): StateStore {
+  return new ScopedStateStore(store, scope);
+}
+
+// A usable scope, or undefined for blank values. The separator is reserved.
+export function normalizeMemoryScope(scope: string | undefined): string | undefined {
+  const trimmed = scope?.trim();
+  if (trimmed === undefined || trimmed.length === 0) {
+    return undefined;
+  }
+  if (trimmed.includes(MEMORY_SCOPE_SEPARATOR)) {
+    throw new Error(`Memory scope "${trimmed}" must not contain "${MEMORY_SCOPE_SEPARATOR}".`);
+  }
+  return trimmed;
+}
+
+function toScope(scope: string, namespace: string | undefined): string {
+  return `${scope}${MEMORY_SCOPE_SEPARATOR}${namespace ?? 'default'}`;
+}
+
+function fromScope<T extends { namespace?: string }>(scope: string, entry: T): T {
+  const namespace = entry.namespace?.slice(scope.length + MEMORY_SCOPE_SEPARATOR.length);
+  return { ...entry, namespace: namespace === 'default' ? undefined : namespace };
+}
+
+function withinScope<T extends { namespace?: string }>(scope: string, entries: T[]): T[] {
+  const prefix = `${scope}${MEMORY_SCOPE_SEPARATOR}`;
+  return entries
+    .filter((entry) => entry.namespace?.startsWith(prefix) === true)
+    .map((entry) => fromScope(scope, entry));
+}
@@ -0,0 +1,58 @@
+// Rank offset from the original RRF paper; it keeps the top few ranks of one
+// list from drowning out agreement between both lists.
+const RRF_K = 60;
+const DEFAULT_KEYWORD_WEIGHT = 0.5;
+/**
+ * Reciprocal rank fusion of a vector ranking and a keyword ranking, each
+ * ordered best first. An entry scores `(1 - w) / (k + vectorRank) + w / (k + keywordRank)`,
+ * with absent ranks contributing nothing, so exact identifiers found only by
+ * keyword still surface next to paraphrases found only by similarity.
+ */
+export function fuseSemanticRankings(vector, keyword, keywordWeight = DEFAULT_KEYWORD_WEIGHT) {
+    const weight = Math.min(1, Math.max(0, keywordWeight));
+    const fused = new Map();
+    const add = (ranking, share, field) => {
+        ranking.forEach(({ entry, score }, index) => {
+            const id = `${entry.namespace ?? ''}\u0000${entry.key}`;
+            const result = fused.get(id) ?? { ...entry, score: 0 };
+            result.score += share / (RRF_K + index + 1);
+            result[field] = score;
+            fused.set(id, result);
+        });
+    };
+    add(vector, 1 - weight, 'vectorScore');
+    add(keyword, weight, 'keywordScore');
+    return [...fused.values()]
+        .filter((result) => result.score > 0)
+        .map((result) => ({ ...result, score: Number(result.score.toFixed(6)) }))
+        .sort((left, right) => right.score - left.score || right.updatedAt.localeCompare(left.updatedAt) || left.key.localeCompare(right.key));
+}
+// Whitespace-separated words of a query, for keyword matching.
+export function keywordTerms(query) {
+    return query.split(/\s+/).filter((term) => /[\p{L}\p{N}]/u.test(term));
+}
+// Okapi BM25 over key, content, and tags, matching SQLite's full-text ranking; used
+// where content cannot be indexed by SQLite (the JSON store, encrypted memory).
+export function rankByBm25(query, entries, computeTokenFreq) {
+    const terms = [...new Set(keywordTerms(query).flatMap((term) => Object.keys(computeTokenFreq(term))))];
+    if (terms.length === 0 || entries.length === 0) {
+        return [];
+    }
+    const documents = entries.map((entry) => ({ entry, freq: computeTokenFreq([entry.key, entry.content, entry.tags.join(' ')].join('\n')) }));
+    const lengths = documents.map(({ freq }) => Object.values(freq).reduce((sum, count) => sum + count, 0));
+    const averageLength = lengths.reduce((sum, length) => sum + length, 0) / documents.length || 1;
+    const idf = new Map(terms.map((term) => {
+        const containing = documents.filter(({ freq }) => (freq[term] ?? 0) > 0).length;
+        return [term, Math.log(1 + (documents.length - containing + 0.5) / (containing + 0.5))];
+    }));
+    return documents
+        .map(({ entry, freq }, index) => ({
+            entry,
+            score: Number(terms.reduce((sum, term) => {
+                const count = freq[term] ?? 0;
+                return sum + (idf.get(term) ?? 0) * (count * 2.2) / (count + 1.2 * (0.25 + 0.75 * (lengths[index] / averageLength)));
+            }, 0).toFixed(4)),
+        }))
+        .filter(({ score }) => score > 0)
+        .sort((left, right) => right.score - left.score || right.entry.updatedAt.localeCompare(left.entry.updatedAt) || left.entry.key.localeCompare(right.entry.key));
+}
@@ -2,29 +2,40 @@
 // No native compilation required.
 
 import { randomUUID } from 'node:crypto';
-import { mkdirSync } from 'node:fs';
+import { mkdirSync, rmSync } from 'node:fs';
 import { dirname, join } from 'node:path';
 import { DatabaseSync } from 'node:sqlite';
 import type {
   StateStore,
   MemoryEntry,
+  MemorySearchOptions,
   PolicyEntry,
   AgentEntry,
   SemanticEntry,
   SemanticSearchResult,
+  SemanticSearchOptions,
   SemanticNamespaceStats,
+  MemoryRetentionPolicy,
+  MemoryPruneResult,
+  MemoryAsOf,
+  MemoryQuota,
+  MemoryQuotaResult,
   FeedbackEntry,
   SessionEntry,
   SessionParticipant,
   SessionParticipantRole,
   SessionStatus,
 } from './index.js';
+import { createMemoryCipher, openStored, type MemoryCipher } from './encryption.js';
+import { asOfTimestamp, DEFAULT_HISTORY_MAX_AGE_MS, entryAttribution, expiryFrom, planQuota, planRetention } from './retention.js';
+import { planSchemaMigrations, type SchemaMigrationOptions, type SchemaMigrationResult } from './schema-migrations.js';
+import { fuseSemanticRankings, keywordTerms, rankByBm25, type RankedSemanticEntry } from './semantic-ranking.js';
 
 // ---------------------------------------------------------------------------
 // Helpers
 // ---------------------------------------------------------------------------
 
-function normalizeTags(tags: string[] | undefined): string[] {
+export function normalizeTags(tags: string[] | undefined): string[] {
   return Array.from(
     new Set((tags ?? []).map((t) => t.trim().toLowerCase()).filter((t) => t.length > 0)),
   ).sort();
@@ -35,19 +46,19 @@ function normalizeRating(rating: number | undefined): number | undefined {
   return Math.max(1, Math.min(5, Math.round(rating)));
 }
 
-function safeJsonParse<T>(json: string | null | undefined, fallback: T): T {
+export function safeJsonParse<T>(json: string | null | undefined, fallback: T): T {
   if (json == null) return fallback;
   try { return JSON.parse(json) as T; } catch { return fallback; }
 }
 
-function computeTokenFreqRecord(content: string): Record<string, number> {
+export function computeTokenFreqRecord(content: string): Record<string, number> {
   const tokens = content.toLowerCase().split(/[^a-z0-9_-]+/i).map((t) => t.trim()).filter((t) => t.length >= 2);
   const freq: Record<string, number> = {};
   for (const t of tokens) freq[t] = (freq[t] ?? 0) + 1;
   return freq;
 }
 
-function tfCosineSimilarity(a: Record<string, number>, b: Record<string, number>): number {
+export function tfCosineSimilarity(a: Record<string, number>, b: Record<string, number>): number {
   const aKeys = Object.keys(a);
   if (aKeys.length === 0 || Object.keys(b).length === 0) return 0;
   let dot = 0, aMag = 0, bMag = 0;
@@ -75,9 +86,15 @@ function asRows<T>(value: unknown): T[] {
 export interface SqliteStateStoreConfig {
   basePath?: string;
   dbFile?: string;
+  /** Encrypts memory values and semantic content (AES-256-GCM) with this key or passphrase. */
+  encryptionKey?: string;
+  /** Set false to open without migrating the schema, e.g. to plan or roll back migrations. */
+  migrate?: boolean;
 }
 
 const DEFAULT_DB_FILE = join('.automatosx', 'runtime', 'state.db');
+// Matches a memory search returns unless asked for more.
+export const MEMORY_SEARCH_LIMIT = 200;
 const JOURNAL_MODE_SETUP_ATTEMPTS = 20;
 const JOURNAL_MODE_SETUP_INITIAL_DELAY_MS = 5;
 
@@ -114,153 +131,227 @@ function withJournalModeRetry<T>(operation: () => T): T {
   throw lastError;
 }
 
+export interface SqliteMigration {
+  version: number;
+  name: string;
+  up(db: DatabaseSync): void;
+  // Undoes `up`, dropping what it added; a version without one can't be rolled back.
+  down?(db: DatabaseSync): void;
+}
+
+/**
+ * Schema versions, applied in order, each in its own transaction, and recorded
+ * in `ax_schema_migrations`. Append new versions; never edit an applied one.
+ * Steps must also be safe on databases created before versions were recorded,
+ * which already have some of them in place.
+ */
+export const SQLITE_MIGRATIONS: SqliteMigration[] = [
+  { version: 1, name: 'base-tables', up: createBaseTables },
+  {
+    version: 2,
+    name: 'semantic-fts',
+    up: createSemanticFts,
+    down: (db) => db.exec(`
+      DROP TRIGGER IF EXISTS sem_ai;
+      DROP TRIGGER IF EXISTS sem_ad;
+      DROP TRIGGER IF EXISTS sem_au;
+      DROP TABLE IF EXISTS semantic_fts;
+    `),
+  },
+  {
+    version: 3,
+    name: 'entry-expiry',
+    up: addExpiryColumns,
+    down: (db) => db.exec(`
+      DROP INDEX IF EXISTS idx_mem_exp;
+      DROP INDEX IF EXISTS idx_sem_exp;
+      ALTER TABLE memory_items DROP COLUMN expires_at;
+      ALTER TABLE semantic_items DROP COLUMN expires_at;
+    `),
+  },
+  {
+    version: 4,
+    name: 'memory-attribution',
+    up: addAttributionColumns,
+    down: (db) => db.exec(`
+      DROP INDEX IF EXISTS idx_mem_agent;
+      DROP INDEX IF EXISTS idx_sem_agent;
+      ALTER TABLE memory_items DROP COLUMN agent_id;
+      ALTER TABLE memory_items DROP COLUMN importance;
+      ALTER TABLE semantic_items DROP COLUMN agent_id;
+      ALTER TABLE semantic_items DROP COLUMN importance;
+    `),
+  },
+  {
+    version: 5,
+    name: 'memory-tags',
+    up: addMemoryTags,
+    down: (db) => db.exec(`ALTER TABLE memory_items DROP COLUMN tags`),
+  },
+  {
+    version: 6,
+    name: 'memory-history',
+    up: addMemoryHistory,
+    down: (db) => db.exec(`
+      DROP TRIGGER IF EXISTS memh_ai;
+      DROP TRIGGER IF EXISTS memh_au;
+      DROP TRIGGER IF EXISTS memh_ad;
+      DROP TRIGGER IF EXISTS semh_ai;
+      DROP TRIGGER IF EXISTS semh_au;
+      DROP TRIGGER IF EXISTS semh_ad;
+      DROP TABLE IF EXISTS memory_history;
+      DROP TABLE IF EXISTS semantic_history;
+    `),
+  },
+  {
+    version: 7,
+    name: 'history-purge-on-delete',
+    up: purgeHistoryOnDelete,
+    down: (db) => db.exec(`
+      DROP TRIGGER IF EXISTS memh_purge;
+      DROP TRIGGER IF EXISTS semh_purge;
+    `),
+  },
+];
+
 export class SqliteStateStore implements StateStore {
   private readonly db: DatabaseSync;
+  private readonly dbFile: string;
+  private readonly cipher?: MemoryCipher;
 
   constructor(config: SqliteStateStoreConfig = {}) {
-    const dbFile = config.dbFile ?? join(config.basePath ?? process.cwd(), DEFAULT_DB_FILE);
-    mkdirSync(dirname(dbFile), { recursive: true });
-    this.db = new DatabaseSync(dbFile);
+    this.cipher = config.encryptionKey !== undefined ? createMemoryCipher(config.encryptionKey) : undefined;
+    this.dbFile = config.dbFile ?? join(config.basePath ?? process.cwd(), DEFAULT_DB_FILE);
+    mkdirSync(dirname(this.dbFile), { recursive: true });
+    this.db = new DatabaseSync(this.dbFile);
     this.db.prepare(`PRAGMA busy_timeout = 10000`).run();
     withJournalModeRetry(() => this.db.prepare(`PRAGMA journal_mode = WAL`).get());
     this.db.prepare(`PRAGMA foreign_keys = ON`).run();
-    this.initialize();
-  }
-
-  private initialize(): void {
-    this.db.exec(`
-      CREATE TABLE IF NOT EXISTS memory_items (
-        id         INTEGER PRIMARY KEY AUTOINCREMENT,
-        key        TEXT NOT NULL,
-        namespace  TEXT NOT NULL DEFAULT 'default',
-        value      TEXT NOT NULL,
-        updated_at TEXT NOT NULL,
-        UNIQUE(key, namespace)
-      );
-      CREATE INDEX IF NOT EXISTS idx_mem_ns  ON memory_items(namespace);
-      CREATE INDEX IF NOT EXISTS idx_mem_upd ON memory_items(updated_at DESC);
-
-      CREATE VIRTUAL TABLE IF NOT EXISTS memory_fts USING fts5(
-        key, namespace, value,
-        content='memory_items',
-        content_rowid='id',
-        tokenize='porter unicode61'
-      );
-      CREATE TRIGGER IF NOT EXISTS mem_ai AFTER INSERT ON memory_items BEGIN
-        INSERT INTO memory_fts(rowid, key, namespace, value) VALUES (new.id, new.key, new.namespace, new.value);
-      END;
-      CREATE TRIGGER IF NOT EXISTS mem_ad AFTER DELETE ON memory_items BEGIN
-        INSERT INTO memory_fts(memory_fts, rowid, key, namespace, value) VALUES ('delete', old.id, old.key, old.namespace, old.value);
-      END;
-      CREATE TRIGGER IF NOT EXISTS mem_au AFTER UPDATE ON memory_items BEGIN
-        INSERT INTO memory_fts(memory_fts, rowid, key, namespace, value) VALUES ('delete', old.id, old.key, old.namespace, old.value);
-        INSERT INTO memory_fts(rowid, key, namespace, value) VALUES (new.id, new.key, new.namespace, new.value);
-      END;
-
-      CREATE TABLE IF NOT EXISTS policies (
-        policy_id  TEXT PRIMARY KEY,
-        name       TEXT NOT NULL,
-        enabled    INTEGER NOT NULL DEFAULT 1,
-        metadata   TEXT,
-        updated_at TEXT NOT NULL
-      );
-
-      CREATE TABLE IF NOT EXISTS agents (
-        agent_id         TEXT PRIMARY KEY,
-        name             TEXT NOT NULL,
-        capabilities     TEXT NOT NULL DEFAULT '[]',
-        metadata         TEXT,
-        registration_key TEXT NOT NULL,
-        registered_at    TEXT NOT NULL,
-        updated_at       TEXT NOT NULL
-      );
-
-      CREATE TABLE IF NOT EXISTS semantic_items (
-        id         INTEGER PRIMARY KEY AUTOINCREMENT,
-        key        TEXT NOT NULL,
-        namespace  TEXT NOT NULL DEFAULT 'default',
-        content    TEXT NOT NULL,
-        token_freq TEXT,
-        tags       TEXT,
-        metadata   TEXT,
-        updated_at TEXT NOT NULL,
-        UNIQUE(key, namespace)
-      );
-      CREATE INDEX IF NOT EXISTS idx_sem_ns  ON semantic_items(namespace);
-      CREATE INDEX IF NOT EXISTS idx_sem_upd ON semantic_items(updated_at DESC);
-
-      CREATE TABLE IF NOT EXISTS feedback (
-        feedback_id       TEXT PRIMARY KEY,
-        selected_agent    TEXT NOT NULL,
-        recommended_agent TEXT,
-        rating            INTEGER,
-        feedback_type     TEXT NOT NULL DEFAULT 'explicit',
-        task_description  TEXT NOT NULL,
-        user_comment      TEXT,
-        outcome           TEXT,
-        duration_ms       INTEGER,
-        session_id        TEXT,
-        metadata          TEXT,
-        created_at        TEXT NOT NULL
-      );
-      CREATE INDEX IF NOT EXISTS idx_fb_agent   ON feedback(selected_agent);
-      CREATE INDEX IF NOT EXISTS idx_fb_created ON feedback(created_at DESC);
-
-      CREATE TABLE IF NOT EXISTS sessions (
-        session_id   TEXT PRIMARY KEY,
-        task         TEXT NOT NULL,
-        initiator    TEXT NOT NULL,
-        status       TEXT NOT NULL DEFAULT 'active',
-        workspace    TEXT,
-        metadata     TEXT,
-        summary      TEXT,
-        error_msg    TEXT,
-        participants TEXT NOT NULL DEFAULT '[]',
-        created_at   TEXT NOT NULL,
-        updated_at   TEXT NOT NULL
-      );
-      CREATE INDEX IF NOT EXISTS idx_sess_status  ON sessions(status);
-      CREATE INDEX IF NOT EXISTS idx_sess_updated ON sessions(updated_at DESC);
-    `);
+    if (config.migrate !== false) {
+      try {
+        this.migrateSchema();
+      } catch (error) {
+        this.db.close();
+        throw error;
+      }
+    }
+  }
+
+  /**
+   * Brings the schema to `options.to`, by default the latest version. An
+   * existing database is first copied to `<dbFile>.v<from>.bak`. A version
+   * that fails is rolled back, leaving the schema at the last one that
+   * completed.
+   */
+  migrateSchema(options: SchemaMigrationOptions = {}): SchemaMigrationResult {
+    const applied = tableExists(this.db, 'ax_schema_migrations')
+      ? asRows<{ version: number }>(this.db.prepare(`SELECT version FROM ax_schema_migrations`).all()).map((row) => Number(row.version))
+      : [];
+    const plan = planSchemaMigrations(
+      SQLITE_MIGRATIONS.map((migration) => ({ version: migration.version, name: migration.name, reversible: migration.down !== undefined })),
+      applied,
+      options,
+    );
+    const result: SchemaMigrationResult = { backend: 'sqlite', ...plan, dryRun: options.dryRun === true };
+    if (result.dryRun || plan.steps.length === 0) {
+      return result;
+    }
+
+    if (tableExists(this.db, 'memory_items')) {
+      result.backupPath = `${this.dbFile}.v${plan.from}.bak`;
+      rmSync(result.backupPath, { force: true });
+      this.db.prepare(`VACUUM INTO ?`).run(result.backupPath);
+    }
+    this.db.exec(`CREATE TABLE IF NOT EXISTS ax_schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL)`);
+    for (const step of plan.steps) {
+      const migration = SQLITE_MIGRATIONS.find((entry) => entry.version === step.version)!;
+      this.db.exec('BEGIN IMMEDIATE');
+      try {
+        // Another process opening the same database may have got here first.
+        const recorded = this.db.prepare(`SELECT 1 FROM ax_schema_migrations WHERE version = ?`).get(step.version) !== undefined;
+        if (plan.direction === 'up' && !recorded) {
+          migration.up(this.db);
+          this.db.prepare(`INSERT INTO ax_schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`).run(step.version, step.name, new Date().toISOString());
+        } else if (plan.direction === 'down' && recorded) {
+          migration.down!(this.db);
+          this.db.prepare(`DELETE FROM ax_schema_migrations WHERE version = ?`).run(step.version);
+        }
+        this.db.exec('COMMIT');
+      } catch (error) {
+        this.db.exec('ROLLBACK');
+        const backup = result.backupPath !== undefined ? `; the database as it was before migrating is at ${result.backupPath}` : '';
+        throw new Error(`Memory schema migration v${step.version} (${step.name}) failed${backup}: ${error instanceof Error ? error.message : String(error)}`);
+      }
+    }
+    return result;
+  }
+
+  // Deletes entries past their TTL so reads never return them.
+  private dropExpired(): void {
+    const now = new Date().toISOString();
+    this.db.prepare(`DELETE FROM memory_items WHERE expires_at IS NOT NULL AND expires_at <= ?`).run(now);
+    this.db.prepare(`DELETE FROM semantic_items WHERE expires_at IS NOT NULL AND expires_at <= ?`).run(now);
+  }
+
+  // Content columns hold ciphertext when an encryption key is configured.
+  private seal(plaintext: string): string {
+    return this.cipher !== undefined ? this.cipher.seal(plaintext) : plaintext;
   }
 
   // -------------------------------------------------------------------------
   // Memory
   // -------------------------------------------------------------------------
 
-  async storeMemory(entry: { key: string; namespace?: string; value: unknown }): Promise<MemoryEntry> {
+  async storeMemory(entry: { key: string; namespace?: string; value: unknown; tags?: string[]; ttlMs?: number; agentId?: string; importance?: number }): Promise<MemoryEntry> {
     const namespace = entry.namespace ?? 'default';
-    const now = new Date().toISOString();
+    const date = new Date();
+    const now = date.toISOString();
+    const expiresAt = expiryFrom(entry.ttlMs, date);
+    const attribution = entryAttribution(entry);
+    const tags = normalizeTags(entry.tags);
     this.db.prepare(`
-      INSERT INTO memory_items (key, namespace, value, updated_at) VALUES (?, ?, ?, ?)
-      ON CONFLICT(key, namespace) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
-    `).run(entry.key, namespace, JSON.stringify(entry.value), now);
-    return { key: entry.key, namespace: entry.namespace, value: entry.value, updatedAt: now };
+      INSERT INTO memory_items (key, namespace, value, tags, updated_at, expires_at, agent_id, importance) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+      ON CONFLICT(key, namespace) DO UPDATE SET value = excluded.value, tags = excluded.tags, updated_at = excluded.updated_at, expires_at = excluded.expires_at,
+        agent_id = excluded.agent_id, importance = excluded.importance
+    `).run(entry.key, namespace, this.seal(JSON.stringify(entry.value)), tags.length > 0 ? tags.join(',') : null, now, expiresAt ?? null, attribution.agentId ?? null, attribution.importance ?? null);
+    return { key: entry.key, namespace: entry.namespace, value: entry.value, ...(tags.length > 0 ? { tags } : {}), updatedAt: now, ...(expiresAt !== undefined ? { expiresAt } : {}), ...attribution };
   }
 
   async getMemory(key: string, namespace?: string): Promise<MemoryEntry | undefined> {
+    this.dropExpired();
     const row = asRow<MemRow>(this.db.prepare(
-      `SELECT key, namespace, value, updated_at FROM memory_items WHERE key = ? AND namespace = ?`,
+      `SELECT key, namespace, value, tags, updated_at, expires_at, agent_id, importance FROM memory_items WHERE key = ? AND namespace = ?`,
     ).get(key, namespace ?? 'default'));
-    return row ? rowToMemory(row) : undefined;
+    return row ? rowToMemory(row, this.cipher) : undefined;
   }
 
-  async searchMemory(query: string, namespace?: string): Promise<MemoryEntry[]> {
+  async searchMemory(query: string, namespace?: string, options: MemorySearchOptions = {}): Promise<MemoryEntry[]> {
     const trimmed = query.trim();
     if (trimmed === '') return this.listMemory(namespace);
+    const limit = options.limit ?? MEMORY_SEARCH_LIMIT;
+    if (this.cipher !== undefined) {
+      // The full-text index only ever sees ciphertext, so match decrypted values instead.
+      const normalized = trimmed.toLowerCase();
+      return (await this.listMemory(namespace))
+        .filter((entry) => [entry.key, entry.namespace ?? '', JSON.stringify(entry.value) ?? ''].join('\n').toLowerCase().includes(normalized))
+        .slice(0, limit);
+    }
+    this.dropExpired();
 
     const escaped = trimmed.replace(/"/g, '""');
     let sql = `
-      SELECT m.key, m.namespace, m.value, m.updated_at
+      SELECT m.key, m.namespace, m.value, m.tags, m.updated_at, m.expires_at, m.agent_id, m.importance
       FROM memory_fts fts JOIN memory_items m ON fts.rowid = m.id
       WHERE memory_fts MATCH ?
     `;
     const params: SqlParameter[] = [`"${escaped}"`];
     if (namespace !== undefined) { sql += ` AND m.namespace = ?`; params.push(namespace); }
-    sql += ` ORDER BY bm25(memory_fts) LIMIT 200`;
+    // Ties break by namespace and key so pages of the same search never overlap.
+    sql += ` ORDER BY bm25(memory_fts), m.namespace, m.key`;
+    if (Number.isFinite(limit)) { sql += ` LIMIT ?`; params.push(limit); }
 
     const rows = asRows<MemRow>(this.db.prepare(sql).all(...params));
-    return rows.map(rowToMemory);
+    return rows.map((row) => rowToMemory(row, this.cipher));
   }
 
   async deleteMemory(key: string, namespace?: string): Promise<boolean> {
@@ -270,10 +361,100 @@ export class SqliteStateStore implements StateStore {
   }
 
   async listMemory(namespace?: string): Promise<MemoryEntry[]> {
+    this.dropExpired();
     const rows = namespace !== undefined
-      ? asRows<MemRow>(this.db.prepare(`SELECT key, namespace, value, updated_at FROM memory_items WHERE namespace = ? ORDER BY updated_at DESC`).all(namespace))
-      : asRows<MemRow>(this.db.prepare(`SELECT key, namespace, value, updated_at FROM memory_items ORDER BY updated_at DESC`).all());
-    return rows.map(rowToMemory);
+      ? asRows<MemRow>(this.db.prepare(`SELECT key, namespace, value, tags, updated_at, expires_at, agent_id, importance FROM memory_items WHERE namespace = ? ORDER BY updated_at DESC`).all(namespace))
+      : asRows<MemRow>(this.db.prepare(`SELECT key, namespace, value, tags, updated_at, expires_at, agent_id, importance FROM memory_items ORDER BY updated_at DESC`).all());
+    return rows.map((row) => rowToMemory(row, this.cipher));
+  }
+
+  async memoryAsOf(asOf: string, options: { namespace?: string; key?: string } = {}): Promise<MemoryAsOf> {
+    const at = asOfTimestamp(asOf);
+    const conditions = [`valid_from <= ?`, `(valid_to IS NULL OR valid_to > ?)`, `(expires_at IS NULL OR expires_at > ?)`];
+    const params: string[] = [at, at, at];
+    if (options.namespace !== undefined) {
+      conditions.push(`namespace = ?`);
+      params.push(options.namespace);
+    }
+    if (options.key !== undefined) {
+      conditions.push(`key = ?`);
+      params.push(options.key);
+    }
+    const where = conditions.join(' AND ');
+    const memory = asRows<MemRow>(this.db.prepare(
+      `SELECT key, namespace, value, tags, updated_at, expires_at, agent_id, importance FROM memory_history WHERE ${where} ORDER BY namespace, key`,
+    ).all(...params));
+    const semantic = asRows<SemRow>(this.db.prepare(
+      `SELECT key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance FROM semantic_history WHERE ${where} ORDER BY namespace, key`,
+    ).all(...params));
+    return { asOf: at, memory: memory.map((row) => rowToMemory(row, this.cipher)), semantic: semantic.map((row) => rowToSemantic(row, this.cipher)) };
+  }
+
+  async pruneMemory(policy: MemoryRetentionPolicy = {}, now = new Date()): Promise<MemoryPruneResult> {
+    const rows = asRows<{ kind: 'memory' | 'semantic'; id: number; updated_at: string; expires_at: string | null; bytes: number }>(this.db.prepare(`
+      SELECT 'memory' AS kind, id, updated_at, expires_at, length(CAST(value AS BLOB)) AS bytes FROM memory_items
+      UNION ALL
+      SELECT 'semantic' AS kind, id, updated_at, expires_at,
+        length(CAST(content AS BLOB)) + coalesce(length(CAST(token_freq AS BLOB)), 0)
+          + coalesce(length(CAST(tags AS BLOB)), 0) + coalesce(length(CAST(metadata AS BLOB)), 0) AS bytes
+      FROM semantic_items
+    `).all());
+    const plan = planRetention(rows.map((row) => ({
+      id: { kind: row.kind, id: row.id },
+      updatedAt: row.updated_at,
+      expiresAt: row.expires_at ?? undefined,
+      bytes: row.bytes,
+    })), policy, now);
+
+    const deleteMem = this.db.prepare(`DELETE FROM memory_items WHERE id = ?`);
+    const deleteSem = this.db.prepare(`DELETE FROM semantic_items WHERE id = ?`);
+    this.db.exec('BEGIN');
+    try {
+      for (const { kind, id } of plan.remove) (kind === 'memory' ? deleteMem : deleteSem).run(id);
+      const cutoff = new Date(now.getTime() - (policy.historyMaxAgeMs ?? DEFAULT_HISTORY_MAX_AGE_MS)).toISOString();
+      this.db.prepare(`DELETE FROM memory_history WHERE valid_to IS NOT NULL AND valid_to < ?`).run(cutoff);
+      this.db.prepare(`DELETE FROM semantic_history WHERE valid_to IS NOT NULL AND valid_to < ?`).run(cutoff);
+      this.db.exec('COMMIT');
+    } catch (err) {
+      this.db.exec('ROLLBACK');
+      throw err;
+    }
+    return plan.result;
+  }
+
+  async enforceMemoryQuota(agentId: string, quota: MemoryQuota, now = new Date()): Promise<MemoryQuotaResult> {
+    const rows = asRows<{ kind: 'memory' | 'semantic'; id: number; key: string; namespace: string; updated_at: string; expires_at: string | null; importance: number | null; bytes: number }>(this.db.prepare(`
+      SELECT 'memory' AS kind, id, key, namespace, updated_at, expires_at, importance, length(CAST(value AS BLOB)) AS bytes
+      FROM memory_items WHERE agent_id = ?
+      UNION ALL
+      SELECT 'semantic' AS kind, id, key, namespace, updated_at, expires_at, importance,
+        length(CAST(content AS BLOB)) + coalesce(length(CAST(token_freq AS BLOB)), 0)
+          + coalesce(length(CAST(tags AS BLOB)), 0) + coalesce(length(CAST(metadata AS BLOB)), 0) AS bytes
+      FROM semantic_items WHERE agent_id = ?
+    `).all(agentId, agentId));
+    const plan = planQuota(rows.map((row) => ({
+      id: row,
+      updatedAt: row.updated_at,
+      expiresAt: row.expires_at ?? undefined,
+      importance: row.importance ?? undefined,
+      bytes: row.bytes,
+    })), quota, now);
+
+    const deleteMem = this.db.prepare(`DELETE FROM memory_items WHERE id = ?`);
+    const deleteSem = this.db.prepare(`DELETE FROM semantic_items WHERE id = ?`);
+    this.db.exec('BEGIN');
+    try {
+      for (const { kind, id } of plan.remove) (kind === 'memory' ? deleteMem : deleteSem).run(id);
+      this.db.exec('COMMIT');
+    } catch (err) {
+      this.db.exec('ROLLBACK');
+      throw err;
+    }
+    return {
+      agentId,
+      evicted: plan.remove.map((row) => ({ kind: row.kind, key: row.key, ...(row.namespace !== 'default' ? { namespace: row.namespace } : {}) })),
+      remaining: plan.remaining,
+    };
   }
 
   // -------------------------------------------------------------------------
@@ -346,57 +527,89 @@ export class SqliteStateStore implements StateStore {
   // Semantic
   // -------------------------------------------------------------------------
 
-  async storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown> }): Promise<SemanticEntry> {
+  async storeSemantic(entry: { key: string; namespace?: string; content: string; tags?: string[]; metadata?: Record<string, unknown>; ttlMs?: number; agentId?: string; importance?: number }): Promise<SemanticEntry> {
     const namespace = entry.namespace ?? 'default';
     const tags = normalizeTags(entry.tags);
-    const now = new Date().toISOString();
+    const date = new Date();
+    const now = date.toISOString();
+    const expiresAt = expiryFrom(entry.ttlMs, date);
     const tokenFreq = computeTokenFreqRecord(entry.content);
+    const attribution = entryAttribution(entry);
 
     this.db.prepare(`
-      INSERT INTO semantic_items (key, namespace, content, token_freq, tags, metadata, updated_at)
-      VALUES (?, ?, ?, ?, ?, ?, ?)
+      INSERT INTO semantic_items (key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance)
+      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
       ON CONFLICT(key, namespace) DO UPDATE SET
         content = excluded.content, token_freq = excluded.token_freq, tags = excluded.tags,
-        metadata = excluded.metadata, updated_at = excluded.updated_at
-    `).run(entry.key, namespace, entry.content, JSON.stringify(tokenFreq), tags.join(','), entry.metadata ? JSON.stringify(entry.metadata) : null, now);
+        metadata = excluded.metadata, updated_at = excluded.updated_at, expires_at = excluded.expires_at,
+        agent_id = excluded.agent_id, importance = excluded.importance
+    `).run(entry.key, namespace, this.seal(entry.content), this.seal(JSON.stringify(tokenFreq)), tags.join(','), entry.metadata ? JSON.stringify(entry.metadata) : null, now, expiresAt ?? null, attribution.agentId ?? null, attribution.importance ?? null);
 
-    return { key: entry.key, namespace: entry.namespace, content: entry.content, tags, metadata: entry.metadata, tokenFreq, updatedAt: now };
+    return { key: entry.key, namespace: entry.namespace, content: entry.content, tags, metadata: entry.metadata, tokenFreq, updatedAt: now, ...(expiresAt !== undefined ? { expiresAt } : {}), ...attribution };
   }
 
-  async searchSemantic(
-    query: string,
-    options: { namespace?: string; filterTags?: string[]; topK?: number; minSimilarity?: number } = {},
-  ): Promise<SemanticSearchResult[]> {
+  async searchSemantic(query: string, options: SemanticSearchOptions = {}): Promise<SemanticSearchResult[]> {
     const filterTags = normalizeTags(options.filterTags);
     const minSimilarity = options.minSimilarity ?? 0;
+    const mode = options.mode ?? 'vector';
     const queryFreq = computeTokenFreqRecord(query);
+    this.dropExpired();
 
-    let sql = `SELECT key, namespace, content, token_freq, tags, metadata, updated_at FROM semantic_items WHERE 1=1`;
+    let filters = '';
     const params: SqlParameter[] = [];
-    if (options.namespace !== undefined) { sql += ` AND namespace = ?`; params.push(options.namespace); }
-    for (const tag of filterTags) { sql += ` AND (',' || tags || ',') LIKE ?`; params.push(`%,${tag},%`); }
+    if (options.namespace !== undefined) { filters += ` AND s.namespace = ?`; params.push(options.namespace); }
+    for (const tag of filterTags) { filters += ` AND (',' || s.tags || ',') LIKE ?`; params.push(`%,${tag},%`); }
+
+    let ranked: SemanticSearchResult[];
+    if (mode === 'keyword') {
+      ranked = this.rankSemanticByKeyword(query, filters, params).map(({ entry, score }) => ({ ...entry, score, keywordScore: score }));
+    } else {
+      const rows = asRows<SemRow>(this.db.prepare(`SELECT s.key, s.namespace, s.content, s.token_freq, s.tags, s.metadata, s.updated_at, s.expires_at, s.agent_id, s.importance FROM semantic_items s WHERE 1=1${filters}`).all(...params));
+      const byVector = rows
+        .map((row) => ({ row, score: tfCosineSimilarity(queryFreq, safeJsonParse<Record<string, number>>(row.token_freq === null ? null : openStored(this.cipher, row.token_freq), {})) }))
+        .filter((r) => r.score >= minSimilarity)
+        .sort((a, b) => b.score - a.score || b.row.updated_at.localeCompare(a.row.updated_at))
+        .map(({ row, score }) => ({ entry: rowToSemantic(row, this.cipher), score }));
+      ranked = mode === 'hybrid'
+        ? fuseSemanticRankings(byVector.filter((r) => r.score > 0), this.rankSemanticByKeyword(query, filters, params), options.keywordWeight)
+        : byVector.map(({ entry, score }) => ({ ...entry, score }));
+    }
 
-    const rows = asRows<SemRow>(this.db.prepare(sql).all(...params));
-    const ranked = rows
-      .map((row) => ({ row, score: tfCosineSimilarity(queryFreq, safeJsonParse<Record<string, number>>(row.token_freq, {})) }))
-      .filter((r) => r.score >= minSimilarity)
-      .sort((a, b) => b.score - a.score || b.row.updated_at.localeCompare(a.row.updated_at));
+    return options.topK !== undefined ? ranked.slice(0, Math.max(0, options.topK)) : ranked;
+  }
 
-    const sliced = options.topK !== undefined ? ranked.slice(0, Math.max(0, options.topK)) : ranked;
-    return sliced.map(({ row, score }) => ({ ...rowToSemantic(row), score }));
+  // FTS5 BM25 over key, content, and tags; any query word may match.
+  private rankSemanticByKeyword(query: string, filters: string, params: SqlParameter[]): RankedSemanticEntry[] {
+    const terms = keywordTerms(query);
+    if (terms.length === 0) return [];
+    if (this.cipher !== undefined) {
+      const rows = asRows<SemRow>(this.db.prepare(`SELECT s.key, s.namespace, s.content, s.token_freq, s.tags, s.metadata, s.updated_at, s.expires_at, s.agent_id, s.importance FROM semantic_items s WHERE 1=1${filters}`).all(...params));
+      return rankByBm25(query, rows.map((row) => rowToSemantic(row, this.cipher)), computeTokenFreqRecord).slice(0, 200);
+    }
+    const match = terms.map((term) => `"${term.replace(/"/g, '""')}"`).join(' OR ');
+    const rows = asRows<SemRow & { rank: number }>(this.db.prepare(`
+      SELECT s.key, s.namespace, s.content, s.token_freq, s.tags, s.metadata, s.updated_at, s.expires_at, s.agent_id, s.importance, bm25(semantic_fts) AS rank
+      FROM semantic_fts JOIN semantic_items s ON semantic_fts.rowid = s.id
+      WHERE semantic_fts MATCH ?${filters}
+      ORDER BY rank LIMIT 200
+    `).all(match, ...params));
+    // bm25() is lower-is-better; flip it so every score reads higher-is-better.
+    return rows.map((row) => ({ entry: rowToSemantic(row, this.cipher), score: -row.rank }));
   }
 
   async getSemantic(key: string, namespace?: string): Promise<SemanticEntry | undefined> {
+    this.dropExpired();
     const row = asRow<SemRow>(
-      this.db.prepare(`SELECT key, namespace, content, token_freq, tags, metadata, updated_at FROM semantic_items WHERE key = ? AND namespace = ?`)
+      this.db.prepare(`SELECT key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance FROM semantic_items WHERE key = ? AND namespace = ?`)
         .get(key, namespace ?? 'default'),
     );
-    return row ? rowToSemantic(row) : undefined;
+    return row ? rowToSemantic(row, this.cipher) : undefined;
   }
 
   async listSemantic(options: { namespace?: string; keyPrefix?: string; filterTags?: string[]; limit?: number } = {}): Promise<SemanticEntry[]> {
     const filterTags = normalizeTags(options.filterTags);
-    let sql = `SELECT key, namespace, content, token_freq, tags, metadata, updated_at FROM semantic_items WHERE 1=1`;
+    this.dropExpired();
+    let sql = `SELECT key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance FROM semantic_items WHERE 1=1`;
     const params: SqlParameter[] = [];
     if (options.namespace !== undefined) { sql += ` AND namespace = ?`; params.push(options.namespace); }
     if (options.keyPrefix !== undefined) { sql += ` AND key LIKE ?`; params.push(`${options.keyPrefix}%`); }
@@ -404,7 +617,7 @@ export class SqliteStateStore implements StateStore {
     sql += ` ORDER BY updated_at DESC`;
     if (options.limit !== undefined) { sql += ` LIMIT ?`; params.push(Math.max(0, options.limit)); }
 
-    return asRows<SemRow>(this.db.prepare(sql).all(...params)).map(rowToSemantic);
+    return asRows<SemRow>(this.db.prepare(sql).all(...params)).map((row) => rowToSemantic(row, this.cipher));
   }
 
   async deleteSemantic(key: string, namespace?: string): Promise<boolean> {
@@ -416,6 +629,7 @@ export class SqliteStateStore implements StateStore {
   }
 
   async semanticStats(namespace?: string): Promise<SemanticNamespaceStats[]> {
+    this.dropExpired();
     const rows = namespace !== undefined
       ? this.db.prepare(`SELECT namespace, tags FROM semantic_items WHERE namespace = ?`).all(namespace) as { namespace: string; tags: string | null }[]
       : this.db.prepare(`SELECT namespace, tags FROM semantic_items`).all() as { namespace: string; tags: string | null }[];
@@ -591,26 +805,26 @@ export class SqliteStateStore implements StateStore {
   // -------------------------------------------------------------------------
 
   async importFromJson(jsonData: {
-    memory?: Array<{ key: string; namespace?: string; value: unknown; updatedAt: string }>;
+    memory?: Array<{ key: string; namespace?: string; value: unknown; updatedAt: string; expiresAt?: string; agentId?: string; importance?: number }>;
     policies?: Array<{ policyId: string; name: string; enabled: boolean; metadata?: Record<string, unknown>; updatedAt: string }>;
     agents?: Array<{ agentId: string; name: string; capabilities: string[]; metadata?: Record<string, unknown>; registrationKey: string; registeredAt: string; updatedAt: string }>;
-    semantic?: Array<{ key: string; namespace?: string; content: string; tags: string[]; metadata?: Record<string, unknown>; tokenFreq: Record<string, number>; updatedAt: string }>;
+    semantic?: Array<{ key: string; namespace?: string; content: string; tags: string[]; metadata?: Record<string, unknown>; tokenFreq: Record<string, number>; updatedAt: string; expiresAt?: string; agentId?: string; importance?: number }>;
     feedback?: Array<FeedbackEntry>;
     sessions?: Array<SessionEntry>;
   }): Promise<void> {
-    const insertMem  = this.db.prepare(`INSERT OR IGNORE INTO memory_items (key, namespace, value, updated_at) VALUES (?, ?, ?, ?)`);
+    const insertMem  = this.db.prepare(`INSERT OR IGNORE INTO memory_items (key, namespace, value, tags, updated_at, expires_at, agent_id, importance) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`);
     const insertPol  = this.db.prepare(`INSERT OR IGNORE INTO policies (policy_id, name, enabled, metadata, updated_at) VALUES (?, ?, ?, ?, ?)`);
     const insertAg   = this.db.prepare(`INSERT OR IGNORE INTO agents (agent_id, name, capabilities, metadata, registration_key, registered_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`);
-    const insertSem  = this.db.prepare(`INSERT OR IGNORE INTO semantic_items (key, namespace, content, token_freq, tags, metadata, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`);
+    const insertSem  = this.db.prepare(`INSERT OR IGNORE INTO semantic_items (key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`);
     const insertFb   = this.db.prepare(`INSERT OR IGNORE INTO feedback (feedback_id, selected_agent, recommended_agent, rating, feedback_type, task_description, user_comment, outcome, duration_ms, session_id, metadata, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`);
     const insertSess = this.db.prepare(`INSERT OR IGNORE INTO sessions (session_id, task, initiator, status, workspace, metadata, summary, error_msg, participants, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`);
 
     this.db.exec('BEGIN');
     try {
-      for (const m of jsonData.memory ?? [])    insertMem.run(m.key, m.namespace ?? 'default', JSON.stringify(m.value), m.updatedAt);
+      for (const m of jsonData.memory ?? [])    insertMem.run(m.key, m.namespace ?? 'default', this.seal(JSON.stringify(m.value)), m.tags?.join(',') ?? null, m.updatedAt, m.expiresAt ?? null, m.agentId ?? null, m.importance ?? null);
       for (const p of jsonData.policies ?? [])  insertPol.run(p.policyId, p.name, p.enabled ? 1 : 0, p.metadata ? JSON.stringify(p.metadata) : null, p.updatedAt);
       for (const a of jsonData.agents ?? [])    insertAg.run(a.agentId, a.name, JSON.stringify(a.capabilities), a.metadata ? JSON.stringify(a.metadata) : null, a.registrationKey, a.registeredAt, a.updatedAt);
-      for (const s of jsonData.semantic ?? [])  insertSem.run(s.key, s.namespace ?? 'default', s.content, JSON.stringify(s.tokenFreq), s.tags.join(','), s.metadata ? JSON.stringify(s.metadata) : null, s.updatedAt);
+      for (const s of jsonData.semantic ?? [])  insertSem.run(s.key, s.namespace ?? 'default', this.seal(s.content), this.seal(JSON.stringify(s.tokenFreq)), s.tags.join(','), s.metadata ? JSON.stringify(s.metadata) : null, s.updatedAt, s.expiresAt ?? null, s.agentId ?? null, s.importance ?? null);
       for (const f of jsonData.feedback ?? [])  insertFb.run(f.feedbackId, f.selectedAgent, f.recommendedAgent ?? null, f.rating ?? null, f.feedbackType, f.taskDescription, f.userComment ?? null, f.outcome ?? null, f.durationMs ?? null, f.sessionId ?? null, f.metadata ? JSON.stringify(f.metadata) : null, f.createdAt);
       for (const sess of jsonData.sessions ?? []) insertSess.run(sess.sessionId, sess.task, sess.initiator, sess.status, sess.workspace ?? null, sess.metadata ? JSON.stringify(sess.metadata) : null, sess.summary ?? null, sess.error?.message ?? null, JSON.stringify(sess.participants), sess.createdAt, sess.updatedAt);
       this.db.exec('COMMIT');
@@ -626,18 +840,288 @@ export class SqliteStateStore implements StateStore {
 }
 
 // ---------------------------------------------------------------------------
-// Row types & converters
+// Schema migrations
 // ---------------------------------------------------------------------------
 
-interface MemRow  { key: string; namespace: string; value: string; updated_at: string; }
+function createBaseTables(db: DatabaseSync): void {
+  db.exec(`
+    CREATE TABLE IF NOT EXISTS memory_items (
+      id         INTEGER PRIMARY KEY AUTOINCREMENT,
+      key        TEXT NOT NULL,
+      namespace  TEXT NOT NULL DEFAULT 'default',
+      value      TEXT NOT NULL,
+      updated_at TEXT NOT NULL,
+      UNIQUE(key, namespace)
+    );
+    CREATE INDEX IF NOT EXISTS idx_mem_ns  ON memory_items(namespace);
+    CREATE INDEX IF NOT EXISTS idx_mem_upd ON memory_items(updated_at DESC);
+
+    CREATE VIRTUAL TABLE IF NOT EXISTS memory_fts USING fts5(
+      key, namespace, value,
+      content='memory_items',
+      content_rowid='id',
+      tokenize='porter unicode61'
+    );
+    CREATE TRIGGER IF NOT EXISTS mem_ai AFTER INSERT ON memory_items BEGIN
+      INSERT INTO memory_fts(rowid, key, namespace, value) VALUES (new.id, new.key, new.namespace, new.value);
+    END;
+    CREATE TRIGGER IF NOT EXISTS mem_ad AFTER DELETE ON memory_items BEGIN
+      INSERT INTO memory_fts(memory_fts, rowid, key, namespace, value) VALUES ('delete', old.id, old.key, old.namespace, old.value);
+    END;
+    CREATE TRIGGER IF NOT EXISTS mem_au AFTER UPDATE ON memory_items BEGIN
+      INSERT INTO memory_fts(memory_fts, rowid, key, namespace, value) VALUES ('delete', old.id, old.key, old.namespace, old.value);
+      INSERT INTO memory_fts(rowid, key, namespace, value) VALUES (new.id, new.key, new.namespace, new.value);
+    END;
+
+    CREATE TABLE IF NOT EXISTS policies (
+      policy_id  TEXT PRIMARY KEY,
+      name       TEXT NOT NULL,
+      enabled    INTEGER NOT NULL DEFAULT 1,
+      metadata   TEXT,
+      updated_at TEXT NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS agents (
+      agent_id         TEXT PRIMARY KEY,
+      name             TEXT NOT NULL,
+      capabilities     TEXT NOT NULL DEFAULT '[]',
+      metadata         TEXT,
+      registration_key TEXT NOT NULL,
+      registered_at    TEXT NOT NULL,
+      updated_at       TEXT NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS semantic_items (
+      id         INTEGER PRIMARY KEY AUTOINCREMENT,
+      key        TEXT NOT NULL,
+      namespace  TEXT NOT NULL DEFAULT 'default',
+      content    TEXT NOT NULL,
+      token_freq TEXT,
+      tags       TEXT,
+      metadata   TEXT,
+      updated_at TEXT NOT NULL,
+      UNIQUE(key, namespace)
+    );
+    CREATE INDEX IF NOT EXISTS idx_sem_ns  ON semantic_items(namespace);
+    CREATE INDEX IF NOT EXISTS idx_sem_upd ON semantic_items(updated_at DESC);
+
+    CREATE TABLE IF NOT EXISTS feedback (
+      feedback_id       TEXT PRIMARY KEY,
+      selected_agent    TEXT NOT NULL,
+      recommended_agent TEXT,
+      rating            INTEGER,
+      feedback_type     TEXT NOT NULL DEFAULT 'explicit',
+      task_description  TEXT NOT NULL,
+      user_comment      TEXT,
+      outcome           TEXT,
+      duration_ms       INTEGER,
+      session_id        TEXT,
+      metadata          TEXT,
+      created_at        TEXT NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS idx_fb_agent   ON feedback(selected_agent);
+    CREATE INDEX IF NOT EXISTS idx_fb_created ON feedback(created_at DESC);
+
+    CREATE TABLE IF NOT EXISTS sessions (
+      session_id   TEXT PRIMARY KEY,
+      task         TEXT NOT NULL,
+      initiator    TEXT NOT NULL,
+      status       TEXT NOT NULL DEFAULT 'active',
+      workspace    TEXT,
+      metadata     TEXT,
+      summary      TEXT,
+      error_msg    TEXT,
+      participants TEXT NOT NULL DEFAULT '[]',
+      created_at   TEXT NOT NULL,
+      updated_at   TEXT NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS idx_sess_status  ON sessions(status);
+    CREATE INDEX IF NOT EXISTS idx_sess_updated ON sessions(updated_at DESC);
+  `);
+}
+
+// Keyword index for semantic search. Databases created before it existed
+// are backfilled once from semantic_items.
+function createSemanticFts(db: DatabaseSync): void {
+  const existed = db.prepare(`SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'semantic_fts'`).get() !== undefined;
+  db.exec(`
+    CREATE VIRTUAL TABLE IF NOT EXISTS semantic_fts USING fts5(
+      key, content, tags,
+      content='semantic_items',
+      content_rowid='id',
+      tokenize='porter unicode61'
+    );
+    CREATE TRIGGER IF NOT EXISTS sem_ai AFTER INSERT ON semantic_items BEGIN
+      INSERT INTO semantic_fts(rowid, key, content, tags) VALUES (new.id, new.key, new.content, new.tags);
+    END;
+    CREATE TRIGGER IF NOT EXISTS sem_ad AFTER DELETE ON semantic_items BEGIN
+      INSERT INTO semantic_fts(semantic_fts, rowid, key, content, tags) VALUES ('delete', old.id, old.key, old.content, old.tags);
+    END;
+    CREATE TRIGGER IF NOT EXISTS sem_au AFTER UPDATE ON semantic_items BEGIN
+      INSERT INTO semantic_fts(semantic_fts, rowid, key, content, tags) VALUES ('delete', old.id, old.key, old.content, old.tags);
+      INSERT INTO semantic_fts(rowid, key, content, tags) VALUES (new.id, new.key, new.content, new.tags);
+    END;
+  `);
+  if (!existed) {
+    db.exec(`INSERT INTO semantic_fts(semantic_fts) VALUES ('rebuild')`);
+  }
+}
+
+// TTL columns, added in place to databases created before entries could expire.
+function addExpiryColumns(db: DatabaseSync): void {
+  for (const table of ['memory_items', 'semantic_items']) {
+    const columns = asRows<{ name: string }>(db.prepare(`PRAGMA table_info(${table})`).all());
+    if (!columns.some((column) => column.name === 'expires_at')) {
+      db.exec(`ALTER TABLE ${table} ADD COLUMN expires_at TEXT`);
+    }
+  }
+  db.exec(`
+    CREATE INDEX IF NOT EXISTS idx_mem_exp ON memory_items(expires_at) WHERE expires_at IS NOT NULL;
+    CREATE INDEX IF NOT EXISTS idx_sem_exp ON semantic_items(expires_at) WHERE expires_at IS NOT NULL;
+  `);
+}
+
+// Agent and importance columns for memory quotas, added in place like expires_at.
+function addAttributionColumns(db: DatabaseSync): void {
+  for (const table of ['memory_items', 'semantic_items']) {
+    const columns = asRows<{ name: string }>(db.prepare(`PRAGMA table_info(${table})`).all());
+    if (!columns.some((column) => column.name === 'agent_id')) {
+      db.exec(`ALTER TABLE ${table} ADD COLUMN agent_id TEXT`);
+    }
+    if (!columns.some((column) => column.name === 'importance')) {
+      db.exec(`ALTER TABLE ${table} ADD COLUMN importance REAL`);
+    }
+  }
+  db.exec(`
+    CREATE INDEX IF NOT EXISTS idx_mem_agent ON memory_items(agent_id) WHERE agent_id IS NOT NULL;
+    CREATE INDEX IF NOT EXISTS idx_sem_agent ON semantic_items(agent_id) WHERE agent_id IS NOT NULL;
+  `);
+}
+
+// Tags on key-value entries, added in place like expires_at. Stored comma-joined, as on semantic_items.
+function addMemoryTags(db: DatabaseSync): void {
+  const columns = asRows<{ name: string }>(db.prepare(`PRAGMA table_info(memory_items)`).all());
+  if (!columns.some((column) => column.name === 'tags')) {
+    db.exec(`ALTER TABLE memory_items ADD COLUMN tags TEXT`);
+  }
+}
+
+// Every version of every entry, for as-of queries. A version holds from
+// valid_from until valid_to, which is set when the entry is next written or
+// deleted (purgeHistoryOnDelete later drops a deleted entry's versions
+// outright). Entries stored
+// before history began are taken to have held since their last update.
+function addMemoryHistory(db: DatabaseSync): void {
+  const stamp = `strftime('%Y-%m-%dT%H:%M:%fZ', 'now')`;
+  db.exec(`
+    CREATE TABLE IF NOT EXISTS memory_history (
+      id         INTEGER PRIMARY KEY AUTOINCREMENT,
+      key        TEXT NOT NULL,
+      namespace  TEXT NOT NULL,
+      value      TEXT NOT NULL,
+      tags       TEXT,
+      updated_at TEXT NOT NULL,
+      expires_at TEXT,
+      agent_id   TEXT,
+      importance REAL,
+      valid_from TEXT NOT NULL,
+      valid_to   TEXT
+    );
+    CREATE INDEX IF NOT EXISTS idx_memh_key ON memory_history(namespace, key, valid_from);
+    CREATE INDEX IF NOT EXISTS idx_memh_to  ON memory_history(valid_to) WHERE valid_to IS NOT NULL;
+
+    CREATE TABLE IF NOT EXISTS semantic_history (
+      id         INTEGER PRIMARY KEY AUTOINCREMENT,
+      key        TEXT NOT NULL,
+      namespace  TEXT NOT NULL,
+      content    TEXT NOT NULL,
+      token_freq TEXT,
+      tags       TEXT,
+      metadata   TEXT,
+      updated_at TEXT NOT NULL,
+      expires_at TEXT,
+      agent_id   TEXT,
+      importance REAL,
+      valid_from TEXT NOT NULL,
+      valid_to   TEXT
+    );
+    CREATE INDEX IF NOT EXISTS idx_semh_key ON semantic_history(namespace, key, valid_from);
+    CREATE INDEX IF NOT EXISTS idx_semh_to  ON semantic_history(valid_to) WHERE valid_to IS NOT NULL;
+
+    INSERT INTO memory_history (key, namespace, value, tags, updated_at, expires_at, agent_id, importance, valid_from)
+      SELECT key, namespace, value, tags, updated_at, expires_at, agent_id, importance, updated_at FROM memory_items
+      WHERE NOT EXISTS (SELECT 1 FROM memory_history);
+    INSERT INTO semantic_history (key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance, valid_from)
+      SELECT key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance, updated_at FROM semantic_items
+      WHERE NOT EXISTS (SELECT 1 FROM semantic_history);
+
+    CREATE TRIGGER IF NOT EXISTS memh_ai AFTER INSERT ON memory_items BEGIN
+      INSERT INTO memory_history (key, namespace, value, tags, updated_at, expires_at, agent_id, importance, valid_from)
+        VALUES (new.key, new.namespace, new.value, new.tags, new.updated_at, new.expires_at, new.agent_id, new.importance, ${stamp});
+    END;
+    CREATE TRIGGER IF NOT EXISTS memh_au AFTER UPDATE ON memory_items BEGIN
+      UPDATE memory_history SET valid_to = ${stamp} WHERE namespace = old.namespace AND key = old.key AND valid_to IS NULL;
+      INSERT INTO memory_history (key, namespace, value, tags, updated_at, expires_at, agent_id, importance, valid_from)
+        VALUES (new.key, new.namespace, new.value, new.tags, new.updated_at, new.expires_at, new.agent_id, new.importance, ${stamp});
+    END;
+    CREATE TRIGGER IF NOT EXISTS memh_ad AFTER DELETE ON memory_items BEGIN
+      UPDATE memory_history SET valid_to = ${stamp} WHERE namespace = old.namespace AND key = old.key AND valid_to IS NULL;
+    END;
+
+    CREATE TRIGGER IF NOT EXISTS semh_ai AFTER INSERT ON semantic_items BEGIN
+      INSERT INTO semantic_history (key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance, valid_from)
+        VALUES (new.key, new.namespace, new.content, new.token_freq, new.tags, new.metadata, new.updated_at, new.expires_at, new.agent_id, new.importance, ${stamp});
+    END;
+    CREATE TRIGGER IF NOT EXISTS semh_au AFTER UPDATE ON semantic_items BEGIN
+      UPDATE semantic_history SET valid_to = ${stamp} WHERE namespace = old.namespace AND key = old.key AND valid_to IS NULL;
+      INSERT INTO semantic_history (key, namespace, content, token_freq, tags, metadata, updated_at, expires_at, agent_id, importance, valid_from)
+        VALUES (new.key, new.namespace, new.content, new.token_freq, new.tags, new.metadata, new.updated_at, new.expires_at, new.agent_id, new.importance, ${stamp});
+    END;
+    CREATE TRIGGER IF NOT EXISTS semh_ad AFTER DELETE ON semantic_items BEGIN
+      UPDATE semantic_history SET valid_to = ${stamp} WHERE namespace = old.namespace AND key = old.key AND valid_to IS NULL;
+    END;
+  `);
+}
+
+// Deleting an entry, or evicting it, drops its versions too, so an as-of query
+// can't bring back what was removed. History of entries deleted before this
+// migration is purged the same way.
+function purgeHistoryOnDelete(db: DatabaseSync): void {
+  db.exec(`
+    CREATE TRIGGER IF NOT EXISTS memh_purge AFTER DELETE ON memory_items BEGIN
+      DELETE FROM memory_history WHERE namespace = old.namespace AND key = old.key;
+    END;
+    CREATE TRIGGER IF NOT EXISTS semh_purge AFTER DELETE ON semantic_items BEGIN
+      DELETE FROM semantic_history WHERE namespace = old.namespace AND key = old.key;
+    END;
+
+    DELETE FROM memory_history WHERE NOT EXISTS (
+      SELECT 1 FROM memory_items m WHERE m.namespace = memory_history.namespace AND m.key = memory_history.key
+    );
+    DELETE FROM semantic_history WHERE NOT EXISTS (
+      SELECT 1 FROM semantic_items s WHERE s.namespace = semantic_history.namespace AND s.key = semantic_history.key
+    );
+  `);
+}
+
+function tableExists(db: DatabaseSync, name: string): boolean {
+  return db.prepare(`SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?`).get(name) !== undefined;
+}
+
+// ---------------------------------------------------------------------------
+// Row types & converters (memory and semantic rows are shared with postgres.ts)
+// ---------------------------------------------------------------------------
+
+export interface MemRow  { key: string; namespace: string; value: string; tags: string | null; updated_at: string; expires_at: string | null; agent_id: string | null; importance: number | null; }
 interface PolRow  { policy_id: string; name: string; enabled: number; metadata: string | null; updated_at: string; }
 interface AgRow   { agent_id: string; name: string; capabilities: string; metadata: string | null; registration_key: string; registered_at: string; updated_at: string; }
-interface SemRow  { key: string; namespace: string; content: string; token_freq: string | null; tags: string | null; metadata: string | null; updated_at: string; }
+export interface SemRow  { key: string; namespace: string; content: string; token_freq: string | null; tags: string | null; metadata: string | null; updated_at: string; expires_at: string | null; agent_id: string | null; importance: number | null; }
 interface FbRow   { feedback_id: string; selected_agent: string; recommended_agent: string | null; rating: number | null; feedback_type: string; task_description: string; user_comment: string | null; outcome: string | null; duration_ms: number | null; session_id: string | null; metadata: string | null; created_at: string; }
 interface SessRow { session_id: string; task: string; initiator: string; status: string; workspace: string | null; metadata: string | null; summary: string | null; error_msg: string | null; participants: string; created_at: string; updated_at: string; }
 
-function rowToMemory(r: MemRow): MemoryEntry {
-  return { key: r.key, namespace: r.namespace === 'default' ? undefined : r.namespace, value: safeJsonParse(r.value, r.value), updatedAt: r.updated_at };
+export function rowToMemory(r: MemRow, cipher?: MemoryCipher): MemoryEntry {
+  const value = openStored(cipher, r.value);
+  return { key: r.key, namespace: r.namespace === 'default' ? undefined : r.namespace, value: safeJsonParse(value, value), ...(r.tags ? { tags: r.tags.split(',').filter((t) => t.length > 0) } : {}), updatedAt: r.updated_at, ...(r.expires_at !== null ? { expiresAt: r.expires_at } : {}), ...rowAttribution(r) };
 }
 function rowToPolicy(r: PolRow): PolicyEntry {
   return { policyId: r.policy_id, name: r.name, enabled: r.enabled !== 0, metadata: safeJsonParse(r.metadata, undefined), updatedAt: r.updated_at };
@@ -645,8 +1129,8 @@ function rowToPolicy(r: PolRow): PolicyEntry {
 function rowToAgent(r: AgRow): AgentEntry {
   return { agentId: r.agent_id, name: r.name, capabilities: safeJsonParse<string[]>(r.capabilities, []), metadata: safeJsonParse(r.metadata, undefined), registrationKey: r.registration_key, registeredAt: r.registered_at, updatedAt: r.updated_at };
 }
-function rowToSemantic(r: SemRow): SemanticEntry {
-  return { key: r.key, namespace: r.namespace === 'default' ? undefined : r.namespace, content: r.content, tags: r.tags ? r.tags.split(',').filter((t) => t.length > 0) : [], metadata: safeJsonParse(r.metadata, undefined), tokenFreq: safeJsonParse<Record<string, number>>(r.token_freq, {}), updatedAt: r.updated_at };
+export function rowToSemantic(r: SemRow, cipher?: MemoryCipher): SemanticEntry {
+  return { key: r.key, namespace: r.namespace === 'default' ? undefined : r.namespace, content: openStored(cipher, r.content), tags: r.tags ? r.tags.split(',').filter((t) => t.length > 0) : [], metadata: safeJsonParse(r.metadata, undefined), tokenFreq: safeJsonParse<Record<string, number>>(r.token_freq === null ? null : openStored(cipher, r.token_freq), {}), updatedAt: r.updated_at, ...(r.expires_at !== null ? { expiresAt: r.expires_at } : {}), ...rowAttribution(r) };
 }
 function rowToFeedback(r: FbRow): FeedbackEntry {
   return { feedbackId: r.feedback_id, selectedAgent: r.selected_agent, recommendedAgent: r.recommended_agent ?? undefined, rating: r.rating ?? undefined, feedbackType: r.feedback_type, taskDescription: r.task_description, userComment: r.user_comment ?? undefined, outcome: r.outcome ?? undefined, durationMs: r.duration_ms ?? undefined, sessionId: r.session_id ?? undefined, metadata: safeJsonParse(r.metadata, undefined), createdAt: r.created_at };
@@ -655,6 +1139,10 @@ function rowToSession(r: SessRow): SessionEntry {
   return { sessionId: r.session_id, task: r.task, initiator: r.initiator, status: r.status as SessionStatus, workspace: r.workspace ?? undefined, metadata: safeJsonParse(r.metadata, undefined), summary: r.summary ?? undefined, error: r.error_msg !== null ? { message: r.error_msg } : undefined, participants: safeJsonParse<SessionParticipant[]>(r.participants, []), createdAt: r.created_at, updatedAt: r.updated_at };
 }
 
+function rowAttribution(r: { agent_id: string | null; importance: number | null }): { agentId?: string; importance?: number } {
+  return { ...(r.agent_id !== null ? { agentId: r.agent_id } : {}), ...(r.importance !== null ? { importance: r.importance } : {}) };
+}
+
 function ensureActiveSession(s: SessionEntry): void {
   if (s.status !== 'active') throw new Error(`Session "${s.sessionId}" is not active`);
 }
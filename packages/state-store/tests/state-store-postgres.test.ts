@@ -0,0 +1,188 @@
+import { mkdirSync } from 'node:fs';
+import { rm } from 'node:fs/promises';
+import { join } from 'node:path';
+import { afterEach, describe, expect, it } from 'vitest';
+import { createPostgresStateStore, createStateStore, migrateStateStoreSchema, POSTGRES_MIGRATIONS, type PostgresPool } from '../src/index.js';
+import { createSqliteStateStore } from '../src/sqlite.js';
+
+// Runs against a real server, e.g. AX_TEST_POSTGRES_URL=postgres://localhost/ax_test with pgvector installed.
+const connectionString = process.env.AX_TEST_POSTGRES_URL;
+
+function createTempDir(): string {
+  const dir = join(process.cwd(), '.tmp', `state-postgres-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
+  mkdirSync(dir, { recursive: true });
+  return dir;
+}
+
+interface FakeMemoryRow {
+  key: string;
+  namespace: string;
+  value: string;
+  tags: string | null;
+  updated_at: string;
+  expires_at: string | null;
+  agent_id: string | null;
+  importance: number | null;
+}
+
+// Just enough of Postgres for the migration log and the memory table, so the
+// store's migration and memory paths run without a server. Every statement is
+// kept, whitespace collapsed, for inspection.
+function createFakePool() {
+  const statements: string[] = [];
+  const versions = new Set<number>();
+  const memory = new Map<string, FakeMemoryRow>();
+  const query = async <R>(text: string, values: unknown[] = []) => {
+    const sql = text.replace(/\s+/g, ' ').trim();
+    statements.push(sql);
+    const result = (rows: unknown[], rowCount = rows.length) => ({ rows: rows as R[], rowCount });
+    if (sql.startsWith('SELECT version FROM ax_schema_migrations')) {
+      return result([...versions].map((version) => ({ version })));
+    }
+    if (sql.startsWith('SELECT 1 FROM ax_schema_migrations')) {
+      return result(versions.has(values[0] as number) ? [{}] : []);
+    }
+    if (sql.startsWith('INSERT INTO ax_schema_migrations')) {
+      versions.add(values[0] as number);
+      return result([]);
+    }
+    if (sql.startsWith('DELETE FROM ax_schema_migrations')) {
+      versions.delete(values[0] as number);
+      return result([]);
+    }
+    if (sql.startsWith('INSERT INTO ax_memory_items')) {
+      const [key, namespace, value, tags, updated_at, expires_at, agent_id, importance] = values as [string, string, string, string | null, string, string | null, string | null, number | null];
+      memory.set(`${namespace}\0${key}`, { key, namespace, value, tags, updated_at, expires_at, agent_id, importance });
+      return result([]);
+    }
+    if (sql.startsWith('SELECT key, namespace, value') && sql.includes('FROM ax_memory_items')) {
+      let rows = [...memory.values()];
+      if (sql.includes('WHERE key = $1')) {
+        rows = rows.filter((row) => row.key === values[0] && row.namespace === values[1]);
+      } else if (sql.includes('AND namespace = $2')) {
+        rows = rows.filter((row) => row.namespace === values[1]);
+      }
+      return result(rows.sort((left, right) => right.updated_at.localeCompare(left.updated_at)));
+    }
+    if (sql.startsWith('DELETE FROM ax_memory_items WHERE key = $1')) {
+      return result([], memory.delete(`${values[1] as string}\0${values[0] as string}`) ? 1 : 0);
+    }
+    return result([]);
+  };
+  const pool: PostgresPool = {
+    query,
+    async connect() {
+      return { query, release: () => undefined };
+    },
+    async end() {},
+  };
+  return { pool, statements, versions, memory };
+}
+
+describe('PostgresStateStore on an in-memory pool', () => {
+  const tempDirs: string[] = [];
+
+  afterEach(async () => {
+    await Promise.all(tempDirs.splice(0).map((d) => rm(d, { recursive: true, force: true })));
+  });
+
+  it('migrates a fresh database through every version once, and back', async () => {
+    const dir = createTempDir(); tempDirs.push(dir);
+    const fake = createFakePool();
+    const config = { basePath: dir, backend: 'postgres' as const, postgres: { pool: fake.pool } };
+    const latest = POSTGRES_MIGRATIONS[POSTGRES_MIGRATIONS.length - 1]!.version;
+
+    expect(await createStateStore(config).listMemory()).toEqual([]);
+    expect([...fake.versions].sort((left, right) => left - right)).toEqual(POSTGRES_MIGRATIONS.map((migration) => migration.version));
+    expect(fake.statements.some((sql) => sql.startsWith('CREATE TABLE IF NOT EXISTS ax_memory_history ('))).toBe(true);
+    expect(fake.statements).toContain('CREATE TRIGGER ax_memory_history AFTER INSERT OR UPDATE OR DELETE ON ax_memory_items FOR EACH ROW EXECUTE FUNCTION ax_record_memory_history()');
+    expect(fake.statements).toContain('CREATE TRIGGER ax_memory_history_purge AFTER DELETE ON ax_memory_items FOR EACH ROW EXECUTE FUNCTION ax_purge_memory_history()');
+    const applied = fake.statements.length;
+    await createStateStore(config).listMemory();
+    expect(fake.statements.slice(applied).filter((sql) => sql === 'BEGIN')).toEqual([]);
+
+    const down = await migrateStateStoreSchema(config, { to: 2 });
+    expect(down.steps.map((step) => step.version)).toEqual(Array.from({ length: latest - 2 }, (_, index) => latest - index));
+    expect(fake.statements).toContain('DROP TABLE IF EXISTS ax_memory_history');
+    expect([...fake.versions].sort((left, right) => left - right)).toEqual([1, 2]);
+    const up = await migrateStateStoreSchema(config);
+    expect(up.steps.map((step) => step.version)).toEqual(Array.from({ length: latest - 2 }, (_, index) => index + 3));
+  });
+
+  it('stores, lists, and deletes memory through the pool', async () => {
+    const dir = createTempDir(); tempDirs.push(dir);
+    const fake = createFakePool();
+    const s = createStateStore({ basePath: dir, backend: 'postgres', postgres: { pool: fake.pool } });
+
+    await s.storeMemory({ key: 'owner', namespace: 'team', value: { name: 'alice' }, tags: ['Ops'], agentId: 'writer' });
+    await s.storeMemory({ key: 'region', value: 'eu-west-1' });
+    expect(await s.getMemory('owner', 'team')).toMatchObject({ key: 'owner', namespace: 'team', value: { name: 'alice' }, tags: ['ops'], agentId: 'writer' });
+    expect((await s.listMemory()).map((entry) => entry.key).sort()).toEqual(['owner', 'region']);
+    expect((await s.listMemory('team')).map((entry) => entry.key)).toEqual(['owner']);
+
+    expect(await s.deleteMemory('owner', 'team')).toBe(true);
+    expect(await s.deleteMemory('owner', 'team')).toBe(false);
+    expect(await s.getMemory('owner', 'team')).toBeUndefined();
+    expect((await s.listMemory()).map((entry) => entry.key)).toEqual(['region']);
+
+    // With a key, only ciphertext reaches the database.
+    const sealed = createStateStore({ basePath: dir, backend: 'postgres', postgres: { pool: fake.pool }, encryptionKey: 'correct horse battery staple' });
+    await sealed.storeMemory({ key: 'pricing', value: 'proprietary formula' });
+    expect(fake.memory.get('default\0pricing')?.value).not.toContain('proprietary');
+    expect((await sealed.getMemory('pricing'))?.value).toBe('proprietary formula');
+  });
+});
+
+describe.skipIf(connectionString === undefined)('PostgresStateStore', () => {
+  const tempDirs: string[] = [];
+  const closers: Array<() => Promise<void>> = [];
+
+  function store(dir: string) {
+    const local = createSqliteStateStore({ basePath: dir });
+    const postgres = createPostgresStateStore({ connectionString, local });
+    closers.push(async () => { await postgres.close(); local.close(); });
+    return postgres;
+  }
+
+  afterEach(async () => {
+    await Promise.all(closers.splice(0).map((close) => close()));
+    await Promise.all(tempDirs.splice(0).map((d) => rm(d, { recursive: true, force: true })));
+  });
+
+  it('migrates to the latest version and back', async () => {
+    const dir = createTempDir(); tempDirs.push(dir);
+    const latest = POSTGRES_MIGRATIONS[POSTGRES_MIGRATIONS.length - 1]!.version;
+    const config = { basePath: dir, backend: 'postgres' as const, postgres: { connectionString } };
+
+    await migrateStateStoreSchema(config);
+    expect((await migrateStateStoreSchema(config, { dryRun: true })).steps).toEqual([]);
+    const down = await migrateStateStoreSchema(config, { to: latest - 1 });
+    expect(down.steps.map((step) => step.version)).toEqual([latest]);
+    const up = await migrateStateStoreSchema(config);
+    expect(up.steps.map((step) => step.version)).toEqual([latest]);
+  });
+
+  it('stores, searches, and ranks semantic entries by pgvector distance', async () => {
+    const dir = createTempDir(); tempDirs.push(dir);
+    const s = store(dir);
+    const namespace = `test-${Date.now()}`;
+    await s.storeSemantic({ key: 'retry', namespace, content: 'retry transient network failures' });
+    await s.storeSemantic({ key: 'cache', namespace, content: 'cache rendered pages' });
+
+    expect((await s.searchSemantic('network retry', { namespace, mode: 'keyword' }))[0]?.key).toBe('retry');
+    const index = s.vectorIndex!;
+    expect((await index.listUnembedded('toy', { namespace })).map((entry) => entry.key).sort()).toEqual(['cache', 'retry']);
+    await index.store('toy', [{ key: 'retry', namespace, vector: [1, 0, 0] }, { key: 'cache', namespace, vector: [0, 1, 0] }]);
+    expect(await index.listUnembedded('toy', { namespace })).toEqual([]);
+
+    const ranked = await index.search('toy', [0.9, 0.1, 0], { namespace });
+    expect(ranked.map((entry) => entry.key)).toEqual(['retry', 'cache']);
+    expect(ranked[0]!.score).toBeGreaterThan(ranked[1]!.score);
+    expect(await index.search('other-model', [1, 0, 0], { namespace })).toEqual([]);
+
+    // Changing the content drops the stale vector.
+    await s.storeSemantic({ key: 'retry', namespace, content: 'retry with jittered backoff' });
+    expect((await index.listUnembedded('toy', { namespace })).map((entry) => entry.key)).toEqual(['retry']);
+    await s.clearSemantic(namespace);
+  });
+});
@@ -1,8 +1,9 @@
-import { mkdirSync } from 'node:fs';
+import { existsSync, mkdirSync } from 'node:fs';
 import { rm } from 'node:fs/promises';
 import { join } from 'node:path';
+import { DatabaseSync } from 'node:sqlite';
 import { afterEach, describe, expect, it } from 'vitest';
-import { SqliteStateStore } from '../src/sqlite.js';
+import { SQLITE_MIGRATIONS, SqliteStateStore } from '../src/sqlite.js';
 
 function createTempDir(): string {
   const dir = join(process.cwd(), '.tmp', `state-sqlite-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
@@ -121,6 +122,32 @@ describe('SqliteStateStore', () => {
     expect(results[0]?.score).toBeGreaterThan(0);
   });
 
+  it('semantic search fuses keyword matches with similarity in hybrid mode', async () => {
+    const dir = createTempDir(); tempDirs.push(dir);
+    const s = store(dir);
+    await s.storeSemantic({ namespace: 'code', key: 'loader', content: 'function loadSettings reads the settings file', tags: ['config'] });
+    await s.storeSemantic({ namespace: 'code', key: 'http', content: 'http client retries failed requests with backoff', tags: ['network'] });
+    await s.storeSemantic({ namespace: 'other', key: 'retry', content: 'retry settings', tags: [] });
+
+    const vector = await s.searchSemantic('retry settings', { namespace: 'code' });
+    expect(vector.map((result) => [result.key, result.score > 0])).toEqual([['loader', true], ['http', false]]);
+
+    const keyword = await s.searchSemantic('retry', { namespace: 'code', mode: 'keyword' });
+    expect(keyword.map((result) => result.key)).toEqual(['http']);
+    expect(keyword[0]?.keywordScore).toBeGreaterThan(0);
+
+    const hybrid = await s.searchSemantic('retry settings', { namespace: 'code', mode: 'hybrid' });
+    expect(hybrid.map((result) => result.key)).toEqual(['loader', 'http']);
+    expect(hybrid[0]?.vectorScore).toBeGreaterThan(0);
+    expect(hybrid[1]?.vectorScore).toBeUndefined();
+
+    const keywordHeavy = await s.searchSemantic('retry settings', { namespace: 'code', mode: 'hybrid', keywordWeight: 1 });
+    expect(keywordHeavy.every((result) => result.keywordScore !== undefined)).toBe(true);
+
+    await s.deleteSemantic('http', 'code');
+    expect(await s.searchSemantic('retry', { namespace: 'code', mode: 'keyword' })).toEqual([]);
+  });
+
   it('semantic stats group by namespace', async () => {
     const dir = createTempDir(); tempDirs.push(dir);
     const s = store(dir);
@@ -226,4 +253,120 @@ describe('SqliteStateStore', () => {
     expect(await s.getMemory('migrated-key', 'import')).toMatchObject({ key: 'migrated-key' });
     expect(await s.getAgent('imported-agent')).toMatchObject({ agentId: 'imported-agent' });
   });
+
+  // -------------------------------------------------------------------------
+  // As-of queries
+  // -------------------------------------------------------------------------
+
+  it('answers as-of queries with entries since changed', async () => {
+    const dir = createTempDir(); tempDirs.push(dir);
+    const s = store(dir);
+    const tick = () => new Promise((resolve) => setTimeout(resolve, 5));
+    await s.storeMemory({ key: 'owner', value: 'alice' });
+    await s.storeSemantic({ key: 'notes', namespace: 'docs', content: 'retry the loader on timeout' });
+    await tick();
+    const before = new Date().toISOString();
+    await tick();
+    await s.storeMemory({ key: 'owner', value: 'bob' });
+    await s.storeSemantic({ key: 'notes', namespace: 'docs', content: 'retry the loader with backoff' });
+    await s.storeMemory({ key: 'later', value: 1 });
+    await tick();
+
+    const past = await s.memoryAsOf(before);
+    expect(past.memory.map((entry) => [entry.key, entry.value])).toEqual([['owner', 'alice']]);
+    expect(past.semantic.map((entry) => [entry.namespace, entry.content])).toEqual([['docs', 'retry the loader on timeout']]);
+    const current = await s.memoryAsOf(new Date().toISOString());
+    expect(current.memory.map((entry) => [entry.key, entry.value])).toEqual([['later', 1], ['owner', 'bob']]);
+    expect(current.semantic.map((entry) => entry.content)).toEqual(['retry the loader with backoff']);
+    expect((await s.memoryAsOf(before, { namespace: 'docs' })).memory).toEqual([]);
+    await expect(s.memoryAsOf('last tuesday')).rejects.toThrow('Invalid as-of time');
+
+    // Replaced versions go once they are older than historyMaxAgeMs, 30 days unless set.
+    await s.pruneMemory({});
+    expect((await s.memoryAsOf(before)).memory).toHaveLength(1);
+    await s.pruneMemory({}, new Date(Date.now() + 31 * 24 * 60 * 60 * 1000));
+    expect(await s.memoryAsOf(before)).toMatchObject({ memory: [], semantic: [] });
+  });
+
+  it('drops the history of deleted and evicted entries', async () => {
+    const dir = createTempDir(); tempDirs.push(dir);
+    const s = store(dir);
+    const tick = () => new Promise((resolve) => setTimeout(resolve, 5));
+    await s.storeMemory({ key: 'token', value: 'first' });
+    await s.storeSemantic({ key: 'notes', namespace: 'docs', content: 'retry the loader on timeout' });
+    await s.storeMemory({ key: 'old', value: 'v1' });
+    await tick();
+    await s.storeMemory({ key: 'old', value: 'v2' });
+    await tick();
+    await s.storeMemory({ key: 'new', value: 'v1' });
+    await tick();
+    const before = new Date().toISOString();
+    await tick();
+
+    await s.deleteMemory('token');
+    await s.deleteSemantic('notes', 'docs');
+    const past = await s.memoryAsOf(before);
+    expect(past.memory.map((entry) => entry.key)).toEqual(['new', 'old']);
+    expect(past.semantic).toEqual([]);
+
+    expect(await s.pruneMemory({ maxEntries: 1 })).toMatchObject({ evicted: 1 });
+    expect((await s.memoryAsOf(before)).memory.map((entry) => entry.key)).toEqual(['new']);
+  });
+
+  // -------------------------------------------------------------------------
+  // Schema migrations
+  // -------------------------------------------------------------------------
+
+  it('migrates a legacy database forward after backing it up, and previews with a dry run', async () => {
+    const dir = createTempDir(); tempDirs.push(dir);
+    const dbFile = join(dir, 'state.db');
+    // A database from before versions were recorded: base tables only.
+    const legacy = new DatabaseSync(dbFile);
+    SQLITE_MIGRATIONS[0]!.up(legacy);
+    legacy.prepare(`INSERT INTO memory_items (key, namespace, value, updated_at) VALUES ('kept', 'default', '"yes"', ?)`).run(new Date().toISOString());
+    legacy.close();
+
+    const preview = new SqliteStateStore({ dbFile, migrate: false });
+    expect(preview.migrateSchema({ dryRun: true })).toMatchObject({ from: 0, to: 7, direction: 'up', dryRun: true });
+    expect(preview.migrateSchema({ dryRun: true }).steps.map((step) => step.name)).toEqual(['base-tables', 'semantic-fts', 'entry-expiry', 'memory-attribution', 'memory-tags', 'memory-history', 'history-purge-on-delete']);
+    preview.close();
+    expect(existsSync(`${dbFile}.v0.bak`)).toBe(false);
+
+    const s = new SqliteStateStore({ dbFile });
+    expect(existsSync(`${dbFile}.v0.bak`)).toBe(true);
+    expect(await s.getMemory('kept')).toMatchObject({ value: 'yes' });
+    await s.storeMemory({ key: 'tagged', value: 1, tags: ['new'] });
+    expect(s.migrateSchema()).toMatchObject({ from: 7, to: 7, direction: 'none', steps: [] });
+    s.close();
+  });
+
+  it('rolls the schema back and refuses a schema newer than it knows', async () => {
+    const dir = createTempDir(); tempDirs.push(dir);
+    const dbFile = join(dir, 'state.db');
+    new SqliteStateStore({ dbFile }).close();
+
+    const s = new SqliteStateStore({ dbFile, migrate: false });
+    const result = s.migrateSchema({ to: 3 });
+    expect(result).toMatchObject({ from: 7, to: 3, direction: 'down', backupPath: `${dbFile}.v7.bak` });
+    expect(result.steps.map((step) => step.version)).toEqual([7, 6, 5, 4]);
+    expect(() => s.migrateSchema({ to: 0 })).toThrow('between 1 and 7');
+    s.close();
+
+    const db = new DatabaseSync(dbFile);
+    const columns = (db.prepare(`PRAGMA table_info(memory_items)`).all() as Array<{ name: string }>).map((column) => column.name);
+    expect(columns).not.toContain('tags');
+    expect(columns).toContain('expires_at');
+    db.close();
+
+    // Opening normally migrates forward again.
+    const reopened = new SqliteStateStore({ dbFile });
+    await reopened.storeMemory({ key: 'tagged', value: 1, tags: ['back'] });
+    expect(await reopened.getMemory('tagged')).toMatchObject({ tags: ['back'] });
+    reopened.close();
+
+    const newer = new DatabaseSync(dbFile);
+    newer.prepare(`INSERT INTO ax_schema_migrations (version, name, applied_at) VALUES (8, 'future', ?)`).run(new Date().toISOString());
+    newer.close();
+    expect(() => new SqliteStateStore({ dbFile })).toThrow('newer than this version of AutomatosX supports');
+  });
 });
@@ -1,10 +1,11 @@
 import { mkdirSync } from 'node:fs';
-import { rm, stat } from 'node:fs/promises';
+import { readdir, readFile, rm, stat } from 'node:fs/promises';
 import { execFile } from 'node:child_process';
 import { join } from 'node:path';
 import { promisify } from 'node:util';
 import { afterEach, describe, expect, it } from 'vitest';
-import { createStateStore } from '../src/index.js';
+import { createScopedStateStore, createStateStore, migrateStateStoreSchema, normalizeMemoryScope, POSTGRES_MIGRATIONS } from '../src/index.js';
+import { planQuota } from '../src/retention.js';
 const execFileAsync = promisify(execFile);
 function createTempDir() {
     const dir = join(process.cwd(), '.tmp', `state-store-${Date.now()}-${Math.random().toString(16).slice(2, 8)}`);
@@ -113,6 +114,161 @@ describe('state store', () => {
     expect(await store.deleteMemory('latest', 'release')).toBe(true);
     expect(await store.getMemory('latest', 'release')).toBeUndefined();
   });
+    it('expires TTL entries and prunes to retention limits on both backends', async () => {
+        for (const backend of ['sqlite', 'json']) {
+            const tempDir = createTempDir();
+            tempDirs.push(tempDir);
+            const store = createStateStore({ basePath: tempDir, backend });
+            const tick = () => new Promise((resolve) => setTimeout(resolve, 5));
+            await store.storeMemory({ key: 'session-token', value: 'abc', ttlMs: 1 });
+            await tick();
+            await store.storeMemory({ key: 'oldest', value: 'first' });
+            await tick();
+            await store.storeSemantic({ key: 'notes', content: 'retry the loader on timeout' });
+            await tick();
+            await store.storeMemory({ key: 'newest', value: 'last' });
+            const pruned = await store.pruneMemory({ maxEntries: 2 });
+            expect(pruned).toMatchObject({ expired: 1, aged: 0, evicted: 1, remaining: { entries: 2 } });
+            expect(await store.getMemory('session-token')).toBeUndefined();
+            expect((await store.listMemory()).map((entry) => entry.key)).toEqual(['newest']);
+            expect(await store.getSemantic('notes')).toBeDefined();
+            const aged = await store.pruneMemory({ maxAgeMs: 1000 }, new Date(Date.now() + 5000));
+            expect(aged).toEqual({ expired: 0, aged: 2, evicted: 0, remaining: { entries: 0, bytes: 0 } });
+        }
+    });
+    it('evicts within one agent quota by write age or decayed importance', async () => {
+        for (const backend of ['sqlite', 'json']) {
+            const tempDir = createTempDir();
+            tempDirs.push(tempDir);
+            const store = createStateStore({ basePath: tempDir, backend });
+            const tick = () => new Promise((resolve) => setTimeout(resolve, 5));
+            await store.storeMemory({ key: 'plan', value: 'v1', agentId: 'planner', importance: 0.9 });
+            await tick();
+            await store.storeSemantic({ key: 'chatter-1', namespace: 'log', content: 'status ping', agentId: 'chatty', importance: 0.1 });
+            await tick();
+            await store.storeMemory({ key: 'decision', value: 'use sqlite', agentId: 'chatty', importance: 2 });
+            await tick();
+            await store.storeMemory({ key: 'chatter-2', value: 'status ping', agentId: 'chatty' });
+            await store.storeMemory({ key: 'shared', value: 'unattributed' });
+            expect(await store.getMemory('decision')).toMatchObject({ agentId: 'chatty', importance: 1 });
+            const byImportance = await store.enforceMemoryQuota('chatty', { maxEntries: 2, eviction: 'importance' });
+            expect(byImportance).toMatchObject({ agentId: 'chatty', evicted: [{ kind: 'semantic', key: 'chatter-1', namespace: 'log' }], remaining: { entries: 2 } });
+            await store.storeMemory({ key: 'notes', value: 'later', agentId: 'chatty' });
+            const byRecency = await store.enforceMemoryQuota('chatty', { maxEntries: 1 });
+            expect(byRecency.evicted.map((entry) => entry.key)).toEqual(['decision', 'chatter-2']);
+            expect((await store.listMemory()).map((entry) => entry.key).sort()).toEqual(['notes', 'plan', 'shared']);
+        }
+        // A month-old important entry decays below a fresh default one.
+        const now = new Date('2026-03-01T00:00:00.000Z');
+        const plan = planQuota([
+            { id: 'old-decision', updatedAt: '2026-01-30T00:00:00.000Z', importance: 1, bytes: 10 },
+            { id: 'fresh', updatedAt: '2026-02-28T00:00:00.000Z', bytes: 10 },
+            { id: 'expired', updatedAt: '2026-02-28T00:00:00.000Z', expiresAt: '2026-02-29T00:00:00.000Z', bytes: 10 },
+        ], { maxBytes: 10, eviction: 'importance' }, now);
+        expect(plan).toEqual({ remove: ['expired', 'old-decision'], remaining: { entries: 1, bytes: 10 } });
+        expect(planQuota([
+            { id: 'old-decision', updatedAt: '2026-01-30T00:00:00.000Z', importance: 1, bytes: 10 },
+            { id: 'fresh', updatedAt: '2026-02-28T00:00:00.000Z', importance: 0, bytes: 10 },
+        ], { maxEntries: 1, eviction: 'oldest-write' }, now).remove).toEqual(['old-decision']);
+    });
+    it('encrypts memory content at rest and still searches it', async () => {
+        for (const backend of ['sqlite', 'json']) {
+            const tempDir = createTempDir();
+            tempDirs.push(tempDir);
+            await createStateStore({ basePath: tempDir, backend }).storeMemory({ key: 'legacy', value: 'written before encryption' });
+            const store = createStateStore({ basePath: tempDir, backend, encryptionKey: 'correct horse battery staple' });
+            await store.storeMemory({ key: 'design', value: { note: 'proprietary pricing formula' } });
+            await store.storeSemantic({ key: 'notes', content: 'the proprietary pricing formula weights margin', tags: ['pricing'] });
+            const runtimeDir = join(tempDir, '.automatosx', 'runtime');
+            const raw = (await Promise.all((await readdir(runtimeDir)).map((file) => readFile(join(runtimeDir, file), 'latin1')))).join('\n');
+            expect(raw).not.toContain('proprietary');
+            expect(await store.getMemory('design')).toMatchObject({ value: { note: 'proprietary pricing formula' } });
+            expect(await store.getMemory('legacy')).toMatchObject({ value: 'written before encryption' });
+            expect((await store.searchMemory('pricing formula')).map((entry) => entry.key)).toEqual(['design']);
+            expect((await store.searchSemantic('margin', { mode: 'keyword' })).map((entry) => entry.key)).toEqual(['notes']);
+            expect((await store.searchSemantic('pricing formula')).map((entry) => entry.key)).toEqual(['notes']);
+            await expect(createStateStore({ basePath: tempDir, backend }).listMemory()).rejects.toThrow(/encrypted/);
+            await expect(createStateStore({ basePath: tempDir, backend, encryptionKey: 'wrong' }).getSemantic('notes')).rejects.toThrow(/wrong encryption key/);
+        }
+    });
+    it('keeps memory of different scopes apart in one store', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const shared = createStateStore({ basePath: tempDir });
+        const web = createScopedStateStore(shared, 'team-a/web');
+        const api = createScopedStateStore(shared, async () => 'team-b/api');
+        await web.storeMemory({ key: 'owner', value: 'alice' });
+        await api.storeMemory({ key: 'owner', value: 'bob' });
+        await web.storeSemantic({ key: 'notes', namespace: 'docs', content: 'retry the loader on timeout' });
+        expect(await web.getMemory('owner')).toMatchObject({ key: 'owner', namespace: undefined, value: 'alice' });
+        expect(await api.getMemory('owner')).toMatchObject({ value: 'bob' });
+        expect((await api.listMemory()).map((entry) => entry.value)).toEqual(['bob']);
+        expect(await api.searchSemantic('loader timeout')).toEqual([]);
+        expect((await web.searchSemantic('loader timeout', { topK: 1 })).map((entry) => [entry.key, entry.namespace])).toEqual([['notes', 'docs']]);
+        expect((await web.semanticStats()).map((entry) => entry.namespace)).toEqual(['docs']);
+        expect((await shared.listMemory()).map((entry) => entry.namespace).sort()).toEqual(['team-a/web::default', 'team-b/api::default']);
+        expect(() => normalizeMemoryScope('a::b')).toThrow('must not contain');
+    });
+    it('migrates a postgres memory backend once and keeps other state local', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const queries = [];
+        let released = 0;
+        const query = async (text, values) => {
+            queries.push({ text, values });
+            return { rows: [], rowCount: 0 };
+        };
+        const pool = {
+            query,
+            async connect() {
+                return { query, release: () => { released += 1; } };
+            },
+            async end() { },
+        };
+        const store = createStateStore({ basePath: tempDir, backend: 'postgres', postgres: { pool } });
+        await store.storeMemory({ key: 'owner', namespace: 'team', value: 'alice', tags: ['Ops', 'people'], agentId: 'writer' });
+        expect(await store.getMemory('owner', 'team')).toBeUndefined();
+        await store.registerAgent({ agentId: 'writer', name: 'Writer' });
+        expect(queries.filter(({ text }) => text.includes('INSERT INTO ax_schema_migrations'))).toHaveLength(POSTGRES_MIGRATIONS.length);
+        expect(queries.filter(({ text }) => text === 'BEGIN')).toHaveLength(POSTGRES_MIGRATIONS.length);
+        expect(queries.filter(({ text }) => text === 'COMMIT')).toHaveLength(POSTGRES_MIGRATIONS.length);
+        expect(queries.findIndex(({ text }) => text.includes('pg_advisory_xact_lock'))).toBe(queries.findIndex(({ text }) => text === 'BEGIN') + 1);
+        expect(released).toBe(1);
+        expect(store.vectorIndex).toBeDefined();
+        expect(queries.find(({ text }) => text.includes('INSERT INTO ax_memory_items'))?.values?.slice(0, 4)).toEqual(['owner', 'team', '"alice"', 'ops,people']);
+        expect(queries.some(({ text }) => text.includes('agents'))).toBe(false);
+        expect((await store.listAgents()).map((agent) => agent.agentId)).toEqual(['writer']);
+        expect(() => createStateStore({ basePath: tempDir, backend: 'postgres' })).toThrow('connection string');
+    });
+    it('rolls a failed postgres migration back on the connection that ran it', async () => {
+        const tempDir = createTempDir();
+        tempDirs.push(tempDir);
+        const clientQueries = [];
+        let released = 0;
+        const pool = {
+            async query() {
+                return { rows: [], rowCount: 0 };
+            },
+            async connect() {
+                return {
+                    async query(text) {
+                        clientQueries.push(text);
+                        if (text.includes('CREATE EXTENSION')) throw new Error('extension "vector" is not available');
+                        return { rows: [], rowCount: 0 };
+                    },
+                    release: () => { released += 1; },
+                };
+            },
+            async end() {},
+        };
+        const config = { basePath: tempDir, backend: 'postgres', postgres: { pool }, encryptionKey: 'k'.repeat(32) };
+        await expect(migrateStateStoreSchema(config)).rejects.toThrow('Memory schema migration v4 (pgvector-embeddings) failed: extension "vector" is not available');
+        expect(clientQueries.slice(-2)).toEqual(['CREATE EXTENSION IF NOT EXISTS vector', 'ROLLBACK']);
+        expect(clientQueries.filter((text) => text === 'COMMIT')).toHaveLength(3);
+        expect(released).toBe(1);
+        // Vectors derived from content would bypass memory encryption.
+        expect(createStateStore(config).vectorIndex).toBeUndefined();
+    });
     it('uses custom storageFile for the default sqlite backend', async () => {
         const tempDir = createTempDir();
         tempDirs.push(tempDir);
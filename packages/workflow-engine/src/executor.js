@@ -91,7 +91,7 @@ function executePromptStep(step, context) {
 }
 function executeToolStep(step, _context) {
     const config = step.config ?? {};
-    const toolName = config.toolName ?? step.tool ?? 'unknown';
+    const toolName = config.toolName ?? config.tool ?? step.tool ?? 'unknown';
     return Promise.resolve({
         type: 'tool',
         stepId: step.stepId,
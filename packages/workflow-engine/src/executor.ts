@@ -123,7 +123,7 @@ function executePromptStep(step: WorkflowStep, context: StepContext): Promise<un
 
 function executeToolStep(step: WorkflowStep, _context: StepContext): Promise<unknown> {
   const config = step.config ?? {};
-  const toolName = (config.toolName as string | undefined) ?? step.tool ?? 'unknown';
+  const toolName = (config.toolName as string | undefined) ?? (config.tool as string | undefined) ?? step.tool ?? 'unknown';
 
   return Promise.resolve({
     type: 'tool',
@@ -2,6 +2,7 @@ export { WorkflowRunner, createWorkflowRunner } from './runner.js';
 export { validateWorkflow, prepareWorkflow, WorkflowValidationError, deepFreezeStepResult, } from './validation.js';
 export { defaultStepExecutor, createStepError, normalizeError, } from './executor.js';
 export { createRealStepExecutor, } from './step-executor-factory.js';
+export { renderTemplate, lookupTemplateValue, stepTemplateVariables, TEMPLATED_STEP_FIELDS, } from './template.js';
 export { DEFAULT_RETRY_POLICY, mergeRetryPolicy, shouldRetry, calculateBackoff, sleep, } from './retry.js';
 export { FileSystemWorkflowLoader, createWorkflowLoader, findWorkflowDir, clearWarnedFilesCache, DEFAULT_WORKFLOW_DIRS, } from './loader.js';
 export { StepGuardEngine, createStepGuardEngine, createGateRegistry, ProgressTracker, createProgressTracker, DEFAULT_STEP_GUARD_ENGINE_CONFIG, } from './step-guard.js';
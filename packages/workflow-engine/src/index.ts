@@ -23,6 +23,13 @@ export {
   type DelegateRunResultLike,
   type RealStepExecutorConfig,
 } from './step-executor-factory.js';
+export {
+  renderTemplate,
+  lookupTemplateValue,
+  stepTemplateVariables,
+  TEMPLATED_STEP_FIELDS,
+  type TemplateRenderResult,
+} from './template.js';
 export {
   DEFAULT_RETRY_POLICY,
   mergeRetryPolicy,
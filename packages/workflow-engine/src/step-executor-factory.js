@@ -1,10 +1,12 @@
 import { getErrorMessage, TIMEOUT_AGENT_STEP_DEFAULT } from '@defai.digital/contracts';
+import { renderTemplate, stepTemplateVariables, TEMPLATED_STEP_FIELDS } from './template.js';
 export function createRealStepExecutor(config) {
-    const { promptExecutor, toolExecutor, discussionExecutor, delegateExecutor, defaultProvider, defaultModel, maxDelegationDepth = 3, } = config;
+    const { promptExecutor, toolExecutor, discussionExecutor, delegateExecutor, defaultProvider, defaultModel, maxDelegationDepth = 3, templateVariables = {}, } = config;
     const delegationDepths = new Map();
     const activeDelegationChain = [];
-    return async (step, context) => {
+    return async (workflowStep, context) => {
         const startTime = Date.now();
+        const step = renderStepTemplates(workflowStep, context, templateVariables);
         try {
             switch (step.type) {
                 case 'prompt':
@@ -87,6 +89,9 @@ async function executePromptStep(step, context, promptExecutor, defaultProvider,
     if ((config.timeout ?? step.timeout) !== undefined) {
         executeRequest.timeout = config.timeout ?? step.timeout;
     }
+    if (config.latencySensitive === true && config.provider === undefined) {
+        executeRequest.latencySensitive = true;
+    }
     const response = await promptExecutor.execute(executeRequest);
     if (response.success) {
         return {
@@ -116,7 +121,7 @@ async function executePromptStep(step, context, promptExecutor, defaultProvider,
 }
 async function executeToolStep(step, context, toolExecutor, startTime) {
     const config = (isRecord(step.config) ? step.config : {});
-    const toolName = config.toolName ?? step.tool;
+    const toolName = config.toolName ?? config.tool ?? step.tool;
     const toolInput = resolveToolInput(config.toolInput, context.input);
     if (toolExecutor === undefined) {
         return {
@@ -504,6 +509,20 @@ async function executeDelegateStep(step, context, delegateExecutor, defaultProvi
         delegationDepths.set(targetAgentId, currentDepth);
     }
 }
+function renderStepTemplates(step, context, templateVariables) {
+    if (!isRecord(step.config) || !TEMPLATED_STEP_FIELDS.some((field) => typeof step.config?.[field] === 'string')) {
+        return step;
+    }
+    const variables = { ...templateVariables, ...stepTemplateVariables(context.previousResults) };
+    const config = { ...step.config };
+    for (const field of TEMPLATED_STEP_FIELDS) {
+        const value = config[field];
+        if (typeof value === 'string') {
+            config[field] = renderTemplate(value, variables).text;
+        }
+    }
+    return { ...step, config };
+}
 function resolvePrompt(configPrompt, input) {
     if (configPrompt) {
         return configPrompt;
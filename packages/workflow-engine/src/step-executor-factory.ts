@@ -1,4 +1,5 @@
 import { getErrorMessage, TIMEOUT_AGENT_STEP_DEFAULT, type WorkflowStep } from '@defai.digital/contracts';
+import { renderTemplate, stepTemplateVariables, TEMPLATED_STEP_FIELDS } from './template.js';
 import type { StepContext, StepExecutor, StepResult } from './types.js';
 
 export interface PromptExecutorLike {
@@ -10,6 +11,8 @@ export interface PromptExecutorLike {
     maxTokens?: number;
     temperature?: number;
     timeout?: number;
+    // Set for steps that asked for the fastest provider and didn't pin one.
+    latencySensitive?: boolean;
   }): Promise<{
     success: boolean;
     content?: string;
@@ -162,6 +165,11 @@ export interface RealStepExecutorConfig {
   defaultModel?: string;
   /** Maximum agent delegation depth. Defaults to 3. */
   maxDelegationDepth?: number;
+  /**
+   * Values for `{{...}}` placeholders in step prompts, such as project, git,
+   * env, and input. Completed steps are added as `steps.<stepId>.output`.
+   */
+  templateVariables?: Record<string, unknown>;
 }
 
 interface PromptStepConfig {
@@ -172,10 +180,13 @@ interface PromptStepConfig {
   maxTokens?: number;
   temperature?: number;
   timeout?: number;
+  latencySensitive?: boolean;
 }
 
 interface ToolStepConfig {
   toolName?: string;
+  // Accepted as well, as the validation gate does.
+  tool?: string;
   toolInput?: Record<string, unknown>;
 }
 
@@ -201,6 +212,7 @@ export function createRealStepExecutor(config: RealStepExecutorConfig): StepExec
     defaultProvider,
     defaultModel,
     maxDelegationDepth = 3,
+    templateVariables = {},
   } = config;
 
   // Per-executor delegation depth tracker: agentId → current depth
@@ -208,8 +220,9 @@ export function createRealStepExecutor(config: RealStepExecutorConfig): StepExec
   // Delegation chain for circular detection: tracks active agent IDs in current chain
   const activeDelegationChain: string[] = [];
 
-  return async (step: WorkflowStep, context: StepContext): Promise<StepResult> => {
+  return async (workflowStep: WorkflowStep, context: StepContext): Promise<StepResult> => {
     const startTime = Date.now();
+    const step = renderStepTemplates(workflowStep, context, templateVariables);
 
     try {
       switch (step.type) {
@@ -312,6 +325,9 @@ async function executePromptStep(
   if ((config.timeout ?? step.timeout) !== undefined) {
     executeRequest.timeout = config.timeout ?? step.timeout;
   }
+  if (config.latencySensitive === true && config.provider === undefined) {
+    executeRequest.latencySensitive = true;
+  }
 
   const response = await promptExecutor.execute(executeRequest);
   if (response.success) {
@@ -349,7 +365,7 @@ async function executeToolStep(
   startTime: number,
 ): Promise<StepResult> {
   const config = (isRecord(step.config) ? step.config : {}) as ToolStepConfig;
-  const toolName = config.toolName ?? step.tool;
+  const toolName = config.toolName ?? config.tool ?? step.tool;
   const toolInput = resolveToolInput(config.toolInput, context.input);
 
   if (toolExecutor === undefined) {
@@ -801,6 +817,25 @@ async function executeDelegateStep(
   }
 }
 
+function renderStepTemplates(
+  step: WorkflowStep,
+  context: StepContext,
+  templateVariables: Record<string, unknown>,
+): WorkflowStep {
+  if (!isRecord(step.config) || !TEMPLATED_STEP_FIELDS.some((field) => typeof step.config?.[field] === 'string')) {
+    return step;
+  }
+  const variables = { ...templateVariables, ...stepTemplateVariables(context.previousResults) };
+  const config: Record<string, unknown> = { ...step.config };
+  for (const field of TEMPLATED_STEP_FIELDS) {
+    const value = config[field];
+    if (typeof value === 'string') {
+      config[field] = renderTemplate(value, variables).text;
+    }
+  }
+  return { ...step, config };
+}
+
 function resolvePrompt(configPrompt: string | undefined, input: unknown): string {
   if (configPrompt) {
     return configPrompt;
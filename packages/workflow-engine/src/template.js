@@ -0,0 +1,48 @@
+// Step config fields rendered as templates before the step runs.
+export const TEMPLATED_STEP_FIELDS = ['prompt', 'systemPrompt', 'context', 'task'];
+const PLACEHOLDER = /\\(\{\{)|\{\{\s*([A-Za-z_][\w-]*(?:\.[\w-]+)*)\s*\}\}/g;
+const DANGEROUS_PROPS = new Set(['__proto__', 'constructor', 'prototype']);
+/**
+ * Replaces `{{dotted.path}}` placeholders with values from `variables`.
+ * Strings are inserted as-is and other values as JSON. `\{{` writes a literal
+ * `{{`, so `\{{env.HOME}}` renders `{{env.HOME}}` untouched. Placeholders
+ * that resolve to nothing stay in the text and are listed in `missing`.
+ */
+export function renderTemplate(template, variables) {
+    const missing = [];
+    const text = template.replace(PLACEHOLDER, (placeholder, escaped, path) => {
+        if (escaped !== undefined) {
+            return escaped;
+        }
+        const value = lookupTemplateValue(variables, path);
+        if (value === undefined || value === null) {
+            if (!missing.includes(path)) {
+                missing.push(path);
+            }
+            return placeholder;
+        }
+        return typeof value === 'string' ? value : JSON.stringify(value);
+    });
+    return { text, missing };
+}
+export function lookupTemplateValue(variables, path) {
+    let current = variables;
+    for (const part of path.split('.')) {
+        if (current === null || typeof current !== 'object' || DANGEROUS_PROPS.has(part)) {
+            return undefined;
+        }
+        if (!Object.prototype.hasOwnProperty.call(current, part)) {
+            return undefined;
+        }
+        current = (current)[part];
+    }
+    return current;
+}
+// Exposes completed steps as `steps.<stepId>.output` (and `.success`).
+export function stepTemplateVariables(previousResults) {
+    const steps = {};
+    for (const result of previousResults) {
+        steps[result.stepId] = { output: result.output, success: result.success };
+    }
+    return { steps };
+}
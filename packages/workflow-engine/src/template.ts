@@ -0,0 +1,60 @@
+import type { StepResult } from './types.js';
+
+export interface TemplateRenderResult {
+  text: string;
+  // Placeholders with no value; they are left in the text as written.
+  missing: string[];
+}
+
+// Step config fields rendered as templates before the step runs.
+export const TEMPLATED_STEP_FIELDS = ['prompt', 'systemPrompt', 'context', 'task'] as const;
+
+const PLACEHOLDER = /\\(\{\{)|\{\{\s*([A-Za-z_][\w-]*(?:\.[\w-]+)*)\s*\}\}/g;
+const DANGEROUS_PROPS = new Set(['__proto__', 'constructor', 'prototype']);
+
+/**
+ * Replaces `{{dotted.path}}` placeholders with values from `variables`.
+ * Strings are inserted as-is and other values as JSON. `\{{` writes a literal
+ * `{{`, so `\{{env.HOME}}` renders `{{env.HOME}}` untouched. Placeholders
+ * that resolve to nothing stay in the text and are listed in `missing`.
+ */
+export function renderTemplate(template: string, variables: Record<string, unknown>): TemplateRenderResult {
+  const missing: string[] = [];
+  const text = template.replace(PLACEHOLDER, (placeholder, escaped: string | undefined, path: string | undefined) => {
+    if (escaped !== undefined) {
+      return escaped;
+    }
+    const value = lookupTemplateValue(variables, path!);
+    if (value === undefined || value === null) {
+      if (!missing.includes(path!)) {
+        missing.push(path!);
+      }
+      return placeholder;
+    }
+    return typeof value === 'string' ? value : JSON.stringify(value);
+  });
+  return { text, missing };
+}
+
+export function lookupTemplateValue(variables: Record<string, unknown>, path: string): unknown {
+  let current: unknown = variables;
+  for (const part of path.split('.')) {
+    if (current === null || typeof current !== 'object' || DANGEROUS_PROPS.has(part)) {
+      return undefined;
+    }
+    if (!Object.prototype.hasOwnProperty.call(current, part)) {
+      return undefined;
+    }
+    current = (current as Record<string, unknown>)[part];
+  }
+  return current;
+}
+
+// Exposes completed steps as `steps.<stepId>.output` (and `.success`).
+export function stepTemplateVariables(previousResults: readonly StepResult[]): Record<string, unknown> {
+  const steps: Record<string, unknown> = {};
+  for (const result of previousResults) {
+    steps[result.stepId] = { output: result.output, success: result.success };
+  }
+  return { steps };
+}
@@ -9,6 +9,7 @@ import {
   createWorkflowLoader,
   createWorkflowRunner,
   findWorkflowDir,
+  renderTemplate,
   type DelegateExecutorLike,
 } from '../src/index.js';
 import { safeValidateWorkflow } from '@defai.digital/contracts';
@@ -319,6 +320,45 @@ describe('workflow-engine', () => {
     });
   });
 
+  it('renders template placeholders in step prompts from variables and earlier step outputs', async () => {
+    expect(renderTemplate('Deploy {{ project.name }} to {{env.STAGE}} ({{env.REGION}}); literal \\{{env.STAGE}}', {
+      project: { name: 'checkout' },
+      env: { STAGE: 'staging' },
+    })).toEqual({
+      text: 'Deploy checkout to staging ({{env.REGION}}); literal {{env.STAGE}}',
+      missing: ['env.REGION'],
+    });
+    expect(renderTemplate('{{input.files}} {{input.__proto__}}', { input: { files: ['a.ts'] } })).toEqual({
+      text: '["a.ts"] {{input.__proto__}}',
+      missing: ['input.__proto__'],
+    });
+
+    const requests: Array<{ prompt: string; systemPrompt?: string }> = [];
+    const stepExecutor = createRealStepExecutor({
+      promptExecutor: {
+        getDefaultProvider: () => 'claude',
+        execute: async (request) => {
+          requests.push({ prompt: request.prompt, systemPrompt: request.systemPrompt });
+          return { success: true, content: 'plan body', provider: 'claude', model: 'test', latencyMs: 1 };
+        },
+      },
+      templateVariables: { git: { branch: 'main' }, input: { ticket: 'AX-12' } },
+    });
+    const plan = await stepExecutor(
+      { stepId: 'plan', type: 'prompt', config: { prompt: 'Plan {{input.ticket}} on {{git.branch}}', systemPrompt: 'Branch {{git.branch}}' } },
+      { workflowId: 'templated', stepIndex: 0, previousResults: [], input: {} },
+    );
+    await stepExecutor(
+      { stepId: 'review', type: 'prompt', config: { prompt: 'Review:\n{{steps.plan.output.content}}' } },
+      { workflowId: 'templated', stepIndex: 1, previousResults: [plan], input: {} },
+    );
+
+    expect(requests).toEqual([
+      { prompt: 'Plan AX-12 on main', systemPrompt: 'Branch main' },
+      { prompt: 'Review:\nplan body', systemPrompt: undefined },
+    ]);
+  });
+
   it('blocks runner execution when a before guard fails with block policy', async () => {
     const stepGuardEngine = createStepGuardEngine();
     stepGuardEngine.registerPolicy({
@@ -365,6 +405,56 @@ describe('workflow-engine', () => {
     });
   });
 
+  it('runs the bundled accessibility workflow past a blocking validation guard', async () => {
+    const stepGuardEngine = createStepGuardEngine();
+    stepGuardEngine.registerPolicy({
+      policyId: 'blocking-policy',
+      name: 'Blocking Policy',
+      enabled: true,
+      priority: 10,
+      workflowPatterns: ['*'],
+      agentPatterns: ['*'],
+      guards: [
+        {
+          guardId: 'blocking-guard',
+          stepId: '*',
+          position: 'before',
+          gates: ['validation'],
+          onFail: 'block',
+          enabled: true,
+        },
+      ],
+    });
+    const workflow = await createWorkflowLoader({ workflowsDir: join(process.cwd(), '..', '..', 'workflows') }).load('accessibility');
+    expect(workflow).toBeDefined();
+
+    const toolCalls: Array<{ toolName: string; args: Record<string, unknown> }> = [];
+    const runner = createWorkflowRunner({
+      stepGuardEngine,
+      stepExecutor: createRealStepExecutor({
+        promptExecutor: {
+          getDefaultProvider: () => 'claude',
+          execute: async () => ({ success: true, content: 'done', latencyMs: 1 }),
+        },
+        toolExecutor: {
+          isToolAvailable: (toolName) => toolName === 'browser',
+          getAvailableTools: () => ['browser'],
+          execute: async (toolName, args) => {
+            toolCalls.push({ toolName, args });
+            return { success: true, output: { violations: [] }, durationMs: 1 };
+          },
+        },
+      }),
+    });
+
+    const result = await runner.run(workflow!);
+    expect(result.success).toBe(true);
+    expect(toolCalls.map((call) => [call.toolName, call.args.action, call.args.reverify ?? false])).toEqual([
+      ['browser', 'axe', false],
+      ['browser', 'axe', true],
+    ]);
+  });
+
   it('reuses a single execution id across all guard checks in one run', async () => {
     const executionIds: string[] = [];
     const stepGuardEngine = createStepGuardEngine();